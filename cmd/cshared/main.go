@@ -0,0 +1,258 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package main exposes the main pdfcpu operations through a C ABI
+// for use by other languages like Python (ctypes), Node.js (ffi) or Java (JNA).
+//
+// Build using:
+//
+//	go build -buildmode=c-shared -o libpdfcpu.so ./cmd/cshared
+//
+// which also generates the header file libpdfcpu.h.
+//
+// Every function takes its file arguments and a JSON object holding options as C strings
+// and returns a JSON object as C string, which has to be released using PdfcpuFree:
+//
+//	{"error": "message"}          if the operation failed
+//	{"output": ["line", ...]}     otherwise, eg. the validation warnings
+//
+// The options, all of them optional:
+//
+//	{
+//		"userPW":    "upw",
+//		"ownerPW":   "opw",
+//		"mode":      "strict",            validate: strict|relaxed, extract: image|font|content|page
+//		"pages":     "1-3,5",             see pdfcpu extract -pages
+//		"stamp":     "Draft, s:0.5",      stamp: the stamp or watermark description
+//		"watermark": true,                stamp: render below the page content
+//		"workers":   4,
+//		"lazy":      true
+//	}
+//
+// Merge takes a JSON array of input files.
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"unsafe"
+
+	"github.com/hhrutter/pdfcpu/pkg/api"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/pkg/errors"
+)
+
+// options are the JSON options accepted by all functions.
+type options struct {
+	UserPW    string `json:"userPW"`
+	OwnerPW   string `json:"ownerPW"`
+	Mode      string `json:"mode"`
+	Pages     string `json:"pages"`
+	Stamp     string `json:"stamp"`
+	Watermark bool   `json:"watermark"`
+	Workers   int    `json:"workers"`
+	Lazy      bool   `json:"lazy"`
+}
+
+// result is the JSON returned by all functions.
+type result struct {
+	Error  string   `json:"error,omitempty"`
+	Output []string `json:"output,omitempty"`
+}
+
+func parseOptions(s *C.char) (*options, error) {
+
+	opts := &options{}
+
+	if s == nil {
+		return opts, nil
+	}
+
+	if js := C.GoString(s); js != "" {
+		if err := json.Unmarshal([]byte(js), opts); err != nil {
+			return nil, errors.Wrap(err, "invalid options")
+		}
+	}
+
+	return opts, nil
+}
+
+func (opts *options) config() *pdfcpu.Configuration {
+	return pdfcpu.NewConfiguration(
+		pdfcpu.WithPassword(opts.UserPW, opts.OwnerPW),
+		pdfcpu.WithWorkers(opts.Workers),
+		pdfcpu.WithLazyLoading(opts.Lazy),
+	)
+}
+
+func (opts *options) pages() ([]string, error) {
+	return api.ParsePageSelection(opts.Pages)
+}
+
+// run builds the command for opts using f, processes it and returns the result as C string.
+func run(jsOpts *C.char, f func(opts *options) (*api.Command, error)) *C.char {
+
+	out, err := func() ([]string, error) {
+
+		opts, err := parseOptions(jsOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, err := f(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.Process(cmd)
+	}()
+
+	res := result{Output: out}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		b = []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+	}
+
+	return C.CString(string(b))
+}
+
+// PdfcpuFree releases a result returned by any other function.
+//
+//export PdfcpuFree
+func PdfcpuFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// PdfcpuValidate validates inFile.
+//
+//export PdfcpuValidate
+func PdfcpuValidate(inFile, jsOpts *C.char) *C.char {
+
+	return run(jsOpts, func(opts *options) (*api.Command, error) {
+
+		config := opts.config()
+
+		switch opts.Mode {
+		case "", "relaxed":
+		case "strict":
+			config.ValidationMode = pdfcpu.ValidationStrict
+		default:
+			return nil, errors.Errorf("validate: unsupported mode %q", opts.Mode)
+		}
+
+		return api.ValidateCommand(C.GoString(inFile), config), nil
+	})
+}
+
+// PdfcpuOptimize optimizes inFile and writes the result to outFile.
+//
+//export PdfcpuOptimize
+func PdfcpuOptimize(inFile, outFile, jsOpts *C.char) *C.char {
+
+	return run(jsOpts, func(opts *options) (*api.Command, error) {
+		return api.OptimizeCommand(C.GoString(inFile), C.GoString(outFile), opts.config()), nil
+	})
+}
+
+// PdfcpuMerge merges the files of the JSON array inFiles into outFile.
+//
+//export PdfcpuMerge
+func PdfcpuMerge(inFiles, outFile, jsOpts *C.char) *C.char {
+
+	return run(jsOpts, func(opts *options) (*api.Command, error) {
+
+		var filesIn []string
+
+		if err := json.Unmarshal([]byte(C.GoString(inFiles)), &filesIn); err != nil {
+			return nil, errors.Wrap(err, "merge: invalid input files")
+		}
+
+		if len(filesIn) < 2 {
+			return nil, errors.New("merge: needs at least 2 input files")
+		}
+
+		return api.MergeCommand(filesIn, C.GoString(outFile), opts.config()), nil
+	})
+}
+
+// PdfcpuSplit writes a single page PDF file for every page of inFile into outDir.
+//
+//export PdfcpuSplit
+func PdfcpuSplit(inFile, outDir, jsOpts *C.char) *C.char {
+
+	return run(jsOpts, func(opts *options) (*api.Command, error) {
+		return api.SplitCommand(C.GoString(inFile), C.GoString(outDir), opts.config()), nil
+	})
+}
+
+// PdfcpuExtract extracts images, fonts, content or pages of inFile into outDir.
+//
+//export PdfcpuExtract
+func PdfcpuExtract(inFile, outDir, jsOpts *C.char) *C.char {
+
+	return run(jsOpts, func(opts *options) (*api.Command, error) {
+
+		pages, err := opts.pages()
+		if err != nil {
+			return nil, err
+		}
+
+		fileIn, dirOut, config := C.GoString(inFile), C.GoString(outDir), opts.config()
+
+		switch opts.Mode {
+		case "image":
+			return api.ExtractImagesCommand(fileIn, dirOut, pages, config), nil
+		case "font":
+			return api.ExtractFontsCommand(fileIn, dirOut, pages, config), nil
+		case "content":
+			return api.ExtractContentCommand(fileIn, dirOut, pages, config), nil
+		case "page":
+			return api.ExtractPagesCommand(fileIn, dirOut, pages, config), nil
+		}
+
+		return nil, errors.Errorf("extract: unsupported mode %q", opts.Mode)
+	})
+}
+
+// PdfcpuStamp adds a stamp or watermark to inFile and writes the result to outFile.
+//
+//export PdfcpuStamp
+func PdfcpuStamp(inFile, outFile, jsOpts *C.char) *C.char {
+
+	return run(jsOpts, func(opts *options) (*api.Command, error) {
+
+		pages, err := opts.pages()
+		if err != nil {
+			return nil, err
+		}
+
+		wm, err := pdfcpu.ParseWatermarkDetails(opts.Stamp, !opts.Watermark)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.AddWatermarksCommand(C.GoString(inFile), C.GoString(outFile), pages, wm, opts.config()), nil
+	})
+}
+
+func main() {}
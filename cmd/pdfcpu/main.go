@@ -18,12 +18,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 
 	"os"
 	"strings"
+	"time"
 
 	"github.com/hhrutter/pdfcpu/pkg/api"
 	PDFCPULog "github.com/hhrutter/pdfcpu/pkg/log"
@@ -32,8 +34,24 @@ import (
 
 var (
 	fileStats, mode, pageSelection string
-	upw, opw, key, perm            string
-	verbose                        bool
+	upw, opw, key, perm, pw        string
+	cert, privKey                  string
+	nameColumn, fieldMap, color    string
+	verbose, needAppearances       bool
+	external, tag, lazy, objStm    bool
+	recompress                     bool
+	fontDir, rules, xref           string
+	producer, creator              string
+	patterns                       string
+	recursive, continueOnError     bool
+	layout, pageMode, duplex       string
+	fitWindow, hideToolbar         bool
+	afRel, afDesc                  string
+	afPage, afObj                  int
+	ocrLang, tesseract             string
+	timeout                        time.Duration
+	workers, level, jobs           int
+	maxMem, maxPixels              int64
 
 	needStackTrace = true
 )
@@ -44,27 +62,96 @@ func init() {
 	flag.StringVar(&fileStats, "stats", "", statsUsage)
 	flag.StringVar(&fileStats, "s", "", statsUsage)
 
-	modeUsage := "validate: strict|relaxed; extract: image|font|content|page; encrypt: rc4|aes"
+	modeUsage := "validate: strict|relaxed|pdfa1b|pdfa2b|pdfx1a|pdfx4|pdfua; diff: json; extract: image|font|content|page|media; encrypt: rc4|aes; form multifill: single|merge; annot markup: highlight|underline|strikeout|squiggly; pdfa: 1b|2b; intent: pdfx|pdfa; linearize: check"
 	flag.StringVar(&mode, "mode", "", modeUsage)
 	flag.StringVar(&mode, "m", "", modeUsage)
 
-	keyUsage := "encrypt: 40|128"
+	keyUsage := "encrypt: 40|128|256"
 	flag.StringVar(&key, "key", "128", keyUsage)
 	flag.StringVar(&key, "k", "128", keyUsage)
 
-	permUsage := "encrypt, perm set: none|all"
+	permUsage := "encrypt, perm set: none|all|comma separated list of print, printhighres, modify, copy, annotate, fillforms, accessibility, assemble"
 	flag.StringVar(&perm, "perm", "none", permUsage)
 
 	pageSelectionUsage := "a comma separated list of pages or page ranges, see pdfcpu help split/extract"
 	flag.StringVar(&pageSelection, "pages", "", pageSelectionUsage)
 	flag.StringVar(&pageSelection, "p", "", pageSelectionUsage)
 
+	needAppearancesUsage := "form fill: ask viewers to regenerate field appearances"
+	flag.BoolVar(&needAppearances, "needappearances", false, needAppearancesUsage)
+	flag.BoolVar(&needAppearances, "na", false, needAppearancesUsage)
+
+	flag.StringVar(&nameColumn, "name", "", "form multifill: csv column naming the filled copies")
+	flag.StringVar(&fieldMap, "map", "", "form multifill: comma separated list of field:column pairs")
+
+	flag.BoolVar(&tag, "tag", false, "stamp, qrstamp, barcode, annot flatten, annot link: add generated content to the structure tree")
+
+	flag.BoolVar(&external, "external", false, "sanitize: remove actions referring to external resources (URI, Launch, SubmitForm...); export-json: write streams to separate files")
+
+	flag.StringVar(&fontDir, "fonts", "", "pdfa: directory of TrueType fonts for embedding missing fonts")
+
+	flag.StringVar(&color, "color", "", "annot markup, note, freetext, attach page, redact: #RRGGBB")
+
 	flag.BoolVar(&verbose, "verbose", false, "")
 	flag.BoolVar(&verbose, "v", false, "")
 
 	flag.StringVar(&upw, "upw", "", "user password")
 	flag.StringVar(&opw, "opw", "", "owner password")
 
+	flag.StringVar(&pw, "pw", "", "sign: password of the PKCS#12 file")
+
+	flag.StringVar(&cert, "cert", "", "encrypt: comma separated list of recipient certificate PEM files; verify, ltv: trusted certificate PEM files; otherwise certificate PEM file")
+	flag.StringVar(&privKey, "privkey", "", "private key PEM file belonging to cert")
+
+	flag.StringVar(&rules, "rules", "", "comma separated list of rule:action pairs overriding the validation mode, action: error|warn|ignore")
+
+	flag.DurationVar(&timeout, "timeout", 0, "abort processing after the given duration, eg. 30s")
+
+	flag.BoolVar(&lazy, "lazy", false, "load stream content on demand, saves memory for large files")
+
+	flag.IntVar(&workers, "workers", 1, "validate, optimize: number of pages processed concurrently")
+
+	flag.BoolVar(&objStm, "objstm", false, "pack all non-stream objects into object streams when writing")
+
+	flag.StringVar(&xref, "xref", "", "cross reference format when writing: table|stream|hybrid")
+
+	flag.IntVar(&level, "level", -1, "zlib compression level for Flate encoding: -1 (default) or 0 (none) to 9 (best)")
+
+	flag.BoolVar(&recompress, "recompress", false, "optimize: recompress uncompressed and Flate encoded streams at -level")
+
+	flag.Int64Var(&maxMem, "maxmem", 0, "limit the total size of decoded streams in MB, 0 means no limit")
+
+	flag.Int64Var(&maxPixels, "maxpixels", 0, "limit the number of pixels of decoded images, 0 means no limit")
+
+	flag.StringVar(&producer, "producer", "", "Producer to write instead of pdfcpu, an empty string removes it")
+
+	flag.StringVar(&creator, "creator", "", "Creator to write, an empty string removes it")
+
+	patternUsage := "batch: comma separated list of file name patterns, defaults to *.pdf"
+	flag.StringVar(&patterns, "pattern", "", patternUsage)
+
+	recursiveUsage := "batch: process sub directories"
+	flag.BoolVar(&recursive, "recursive", false, recursiveUsage)
+	flag.BoolVar(&recursive, "r", false, recursiveUsage)
+
+	flag.IntVar(&jobs, "jobs", 1, "batch: number of files processed concurrently")
+
+	flag.BoolVar(&continueOnError, "continue", false, "batch: keep going after a file failed")
+
+	flag.StringVar(&layout, "layout", "", "viewer set: SinglePage|OneColumn|TwoColumnLeft|TwoColumnRight|TwoPageLeft|TwoPageRight")
+	flag.StringVar(&pageMode, "pagemode", "", "viewer set: UseNone|UseOutlines|UseThumbs|FullScreen|UseOC|UseAttachments")
+	flag.StringVar(&duplex, "duplex", "", "viewer set: Simplex|DuplexFlipShortEdge|DuplexFlipLongEdge")
+	flag.BoolVar(&fitWindow, "fitwindow", false, "viewer set: resize the window to fit the first page")
+	flag.BoolVar(&hideToolbar, "hidetoolbar", false, "viewer set: hide the toolbar")
+
+	flag.StringVar(&afRel, "rel", "", "af add: Source|Data|Alternative|Supplement|EncryptedPayload|FormData|Schema|Unspecified")
+	flag.StringVar(&afDesc, "desc", "", "af add: description of the associated file")
+	flag.IntVar(&afPage, "page", 0, "af add: associate with this page instead of the document")
+	flag.IntVar(&afObj, "obj", 0, "af add: associate with this object instead of the document")
+
+	flag.StringVar(&ocrLang, "lang", "", "ocr: Tesseract languages, eg. eng+deu")
+	flag.StringVar(&tesseract, "tesseract", "", "ocr: path of the tesseract executable")
+
 }
 
 func main() {
@@ -76,6 +163,31 @@ func main() {
 	config := pdfcpu.NewDefaultConfiguration()
 	config.UserPW = upw
 	config.OwnerPW = opw
+	config.NeedAppearances = needAppearances
+	config.TagContent = tag
+	config.LazyLoading = lazy
+	config.Workers = workers
+	config.CompressObjects = objStm
+	config.MaxDecodedSize = maxMem << 20
+	config.MaxImagePixels = maxPixels
+
+	if command != "encrypt" && command != "enc" && command != "verify" && command != "ltv" {
+		setupCertificate(config)
+	}
+
+	setupValidationPolicy(config)
+
+	setupXRef(config)
+
+	setupCompression(config)
+
+	setupProducer(config)
+
+	if timeout > 0 {
+		c, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		config.Context = c
+	}
 
 	var cmd *api.Command
 
@@ -86,29 +198,73 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Like diff(1) the diff command exits with 1 if the files differ.
+	if command == "diff" {
+		os.Exit(diff(prepareDiffCommand(config)))
+	}
+
+	// The batch command exits with 1 if any file failed.
+	if command == "batch" {
+		os.Exit(batch(prepareBatchCommand(config)))
+	}
+
 	for k, v := range map[string]func(config *pdfcpu.Configuration) *api.Command{
-		"validate":  prepareValidateCommand,
-		"optimize":  prepareOptimizeCommand,
-		"o":         prepareOptimizeCommand,
-		"split":     prepareSplitCommand,
-		"s":         prepareSplitCommand,
-		"merge":     prepareMergeCommand,
-		"m":         prepareMergeCommand,
-		"extract":   prepareExtractCommand,
-		"ext":       prepareExtractCommand,
-		"trim":      prepareTrimCommand,
-		"t":         prepareTrimCommand,
-		"attach":    prepareAttachmentCommand,
-		"decrypt":   prepareDecryptCommand,
-		"d":         prepareDecryptCommand,
-		"dec":       prepareDecryptCommand,
-		"encrypt":   prepareEncryptCommand,
-		"enc":       prepareEncryptCommand,
-		"changeupw": prepareChangeUserPasswordCommand,
-		"changeopw": prepareChangeOwnerPasswordCommand,
-		"perm":      preparePermissionsCommand,
-		"stamp":     prepareAddStampsCommand,
-		"watermark": prepareAddWatermarksCommand,
+		"validate":    prepareValidateCommand,
+		"optimize":    prepareOptimizeCommand,
+		"o":           prepareOptimizeCommand,
+		"split":       prepareSplitCommand,
+		"s":           prepareSplitCommand,
+		"merge":       prepareMergeCommand,
+		"m":           prepareMergeCommand,
+		"extract":     prepareExtractCommand,
+		"ext":         prepareExtractCommand,
+		"trim":        prepareTrimCommand,
+		"t":           prepareTrimCommand,
+		"attach":      prepareAttachmentCommand,
+		"decrypt":     prepareDecryptCommand,
+		"d":           prepareDecryptCommand,
+		"dec":         prepareDecryptCommand,
+		"encrypt":     prepareEncryptCommand,
+		"enc":         prepareEncryptCommand,
+		"changeupw":   prepareChangeUserPasswordCommand,
+		"changeopw":   prepareChangeOwnerPasswordCommand,
+		"perm":        preparePermissionsCommand,
+		"stamp":       prepareAddStampsCommand,
+		"watermark":   prepareAddWatermarksCommand,
+		"form":        prepareFormCommand,
+		"qrstamp":     prepareAddQRCodeStampCommand,
+		"barcode":     prepareAddBarcodeStampCommand,
+		"annot":       prepareAnnotationsCommand,
+		"redact":      prepareRedactCommand,
+		"js":          prepareJavaScriptCommand,
+		"sign":        prepareSignCommand,
+		"verify":      prepareVerifySignaturesCommand,
+		"timestamp":   prepareDocTimeStampCommand,
+		"ltv":         prepareEnableLTVCommand,
+		"sanitize":    prepareSanitizeCommand,
+		"revisions":   prepareRevisionsCommand,
+		"rmrights":    prepareRemoveUsageRightsCommand,
+		"pdfa":        prepareConvertToPDFACommand,
+		"intent":      prepareOutputIntentCommand,
+		"linearize":   prepareLinearizeCommand,
+		"xmp":         prepareXMPCommand,
+		"info":        prepareInfoCommand,
+		"keywords":    prepareKeywordsCommand,
+		"properties":  preparePropertiesCommand,
+		"bookmarks":   prepareBookmarksCommand,
+		"dests":       prepareNamedDestsCommand,
+		"layers":      prepareLayersCommand,
+		"viewer":      prepareViewerCommand,
+		"transitions": prepareTransitionsCommand,
+		"portfolio":   preparePortfolioCommand,
+		"af":          prepareAssociatedFilesCommand,
+		"geo":         prepareGeoCommand,
+		"ocr":         prepareOCRCommand,
+		"import-ocr":  prepareImportOCRCommand,
+		"struct":      prepareStructCommand,
+		"repair":      prepareRepairCommand,
+		"export-json": prepareExportJSONCommand,
+		"import-json": prepareImportJSONCommand,
 	} {
 		if command == k {
 			cmd = v(config)
@@ -128,6 +284,34 @@ func ensurePdfExtension(filename string) {
 	}
 }
 
+func ensureFormDataExtension(filename string) {
+	s := strings.ToLower(filename)
+	for _, ext := range []string{".fdf", ".xfdf", ".json", ".csv"} {
+		if strings.HasSuffix(s, ext) {
+			return
+		}
+	}
+	log.Fatalf("%s needs extension \".fdf\", \".xfdf\", \".json\" or \".csv\".", filename)
+}
+
+func ensureCSVExtension(filename string) {
+	if !strings.HasSuffix(strings.ToLower(filename), ".csv") {
+		log.Fatalf("%s needs extension \".csv\".", filename)
+	}
+}
+
+func ensureJSONExtension(filename string) {
+	if !strings.HasSuffix(strings.ToLower(filename), ".json") {
+		log.Fatalf("%s needs extension \".json\".", filename)
+	}
+}
+
+func ensureXFDFExtension(filename string) {
+	if !strings.HasSuffix(strings.ToLower(filename), ".xfdf") {
+		log.Fatalf("%s needs extension \".xfdf\".", filename)
+	}
+}
+
 func defaultFilenameOut(filename string) string {
 	ensurePdfExtension(filename)
 	return filename[:len(filename)-4] + "_new.pdf"
@@ -149,21 +333,57 @@ func helpString(topic string) string {
 		usageShort, usageLong string
 		usagePageSelection    bool
 	}{
-		"validate":  {usageValidate, usageLongValidate, false},
-		"optimize":  {usageOptimize, usageLongOptimize, false},
-		"split":     {usageSplit, usageLongSplit, false},
-		"merge":     {usageMerge, usageLongMerge, false},
-		"extract":   {usageValidate, usageLongValidate, false},
-		"trim":      {usageTrim, usageLongTrim, true},
-		"attach":    {usageAttach, usageLongAttach, false},
-		"perm":      {usagePerm, usageLongPerm, false},
-		"encrypt":   {usageEncrypt, usageLongEncrypt, false},
-		"decrypt":   {usageDecrypt, usageLongDecrypt, false},
-		"changeupw": {usageChangeUserPW, usageLongChangeUserPW, false},
-		"changeopw": {usageChangeOwnerPW, usageLongChangeOwnerPW, false},
-		"stamp":     {usageStamp, usageLongStamp, true},
-		"watermark": {usageWatermark, usageLongWatermark, true},
-		"version":   {usageVersion, usageLongVersion, false},
+		"validate":    {usageValidate, usageLongValidate, false},
+		"optimize":    {usageOptimize, usageLongOptimize, false},
+		"split":       {usageSplit, usageLongSplit, false},
+		"merge":       {usageMerge, usageLongMerge, false},
+		"extract":     {usageValidate, usageLongValidate, false},
+		"trim":        {usageTrim, usageLongTrim, true},
+		"attach":      {usageAttach, usageLongAttach, false},
+		"perm":        {usagePerm, usageLongPerm, false},
+		"encrypt":     {usageEncrypt, usageLongEncrypt, false},
+		"decrypt":     {usageDecrypt, usageLongDecrypt, false},
+		"changeupw":   {usageChangeUserPW, usageLongChangeUserPW, false},
+		"changeopw":   {usageChangeOwnerPW, usageLongChangeOwnerPW, false},
+		"stamp":       {usageStamp, usageLongStamp, true},
+		"watermark":   {usageWatermark, usageLongWatermark, true},
+		"form":        {usageForm, usageLongForm, false},
+		"qrstamp":     {usageQRStamp, usageLongQRStamp, true},
+		"barcode":     {usageBarcode, usageLongBarcode, true},
+		"annot":       {usageAnnot, usageLongAnnot, true},
+		"redact":      {usageRedact, usageLongRedact, true},
+		"js":          {usageJS, usageLongJS, false},
+		"sign":        {usageSign, usageLongSign, false},
+		"verify":      {usageVerify, usageLongVerify, false},
+		"timestamp":   {usageTimestamp, usageLongTimestamp, false},
+		"ltv":         {usageLTV, usageLongLTV, false},
+		"sanitize":    {usageSanitize, usageLongSanitize, false},
+		"revisions":   {usageRevisions, usageLongRevisions, false},
+		"rmrights":    {usageRemoveUsageRights, usageLongRemoveUsageRights, false},
+		"pdfa":        {usageConvertToPDFA, usageLongConvertToPDFA, false},
+		"intent":      {usageOutputIntent, usageLongOutputIntent, false},
+		"linearize":   {usageLinearize, usageLongLinearize, false},
+		"xmp":         {usageXMP, usageLongXMP, false},
+		"info":        {usageInfo, usageLongInfo, false},
+		"keywords":    {usageKeywords, usageLongKeywords, false},
+		"properties":  {usageProperties, usageLongProperties, false},
+		"bookmarks":   {usageBookmarks, usageLongBookmarks, false},
+		"dests":       {usageDests, usageLongDests, false},
+		"layers":      {usageLayers, usageLongLayers, false},
+		"viewer":      {usageViewer, usageLongViewer, false},
+		"transitions": {usageTransitions, usageLongTransitions, false},
+		"portfolio":   {usagePortfolio, usageLongPortfolio, false},
+		"af":          {usageAssociatedFiles, usageLongAssociatedFiles, false},
+		"geo":         {usageGeo, usageLongGeo, false},
+		"ocr":         {usageOCR, usageLongOCR, true},
+		"import-ocr":  {usageImportOCR, usageLongImportOCR, true},
+		"struct":      {usageStruct, usageLongStruct, false},
+		"repair":      {usageRepair, usageLongRepair, false},
+		"export-json": {usageExportJSON, usageLongExportJSON, false},
+		"import-json": {usageImportJSON, usageLongImportJSON, false},
+		"diff":        {usageDiff, usageLongDiff, false},
+		"batch":       {usageBatch, usageLongBatch, false},
+		"version":     {usageVersion, usageLongVersion, false},
 	} {
 		if topic == k {
 			if v.usagePageSelection {
@@ -237,6 +457,173 @@ func parseFlagsAndGetCommand() (command string) {
 		i = 3
 	}
 
+	// The form command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "form" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageForm)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The annot command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "annot" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageAnnot)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The js command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "js" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageJS)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The revisions command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "revisions" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageRevisions)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The xmp command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "xmp" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageXMP)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The info command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "info" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageInfo)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The keywords command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "keywords" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageKeywords)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The properties command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "properties" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageProperties)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The bookmarks command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "bookmarks" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageBookmarks)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The dests command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "dests" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageDests)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The layers command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "layers" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageLayers)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The viewer command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "viewer" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageViewer)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The transitions command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "transitions" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageTransitions)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The portfolio command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "portfolio" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usagePortfolio)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The af command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "af" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageAssociatedFiles)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The geo command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "geo" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageGeo)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The intent command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "intent" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageOutputIntent)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The struct command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "struct" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageStruct)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The stamp and watermark commands have optional subcommands => start flag processing after 3rd argument.
+	if (command == "stamp" || command == "watermark") && len(os.Args) > 2 && (os.Args[2] == "remove" || os.Args[2] == "update") {
+		i = 3
+	}
+
 	// Parse commandline flags.
 	err := flag.CommandLine.Parse(os.Args[i:])
 	if err != nil {
@@ -266,6 +653,59 @@ func process(cmd *api.Command) {
 	}
 }
 
+// diff processes cmd and returns the exit status: 0 if the files are equal, 1 if they differ and 2 on errors.
+func diff(cmd *api.Command) int {
+
+	r, err := api.Diff(cmd.InFiles[0], cmd.InFiles[1], cmd.Config)
+	if err == nil {
+		var out []string
+		if out, err = api.DiffOutput(r, cmd.JSONOutput); err == nil {
+			for _, l := range out {
+				fmt.Fprintln(os.Stdout, l)
+			}
+			if r.Equal() {
+				return 0
+			}
+			return 1
+		}
+	}
+
+	if needStackTrace {
+		fmt.Fprintf(os.Stderr, "Fatal: %+v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+
+	return 2
+}
+
+// batch processes cmd and returns the exit status: 0 if all files were processed, 1 otherwise.
+func batch(cmd *api.Command) int {
+
+	r, err := api.Batch(*cmd.InDir, *cmd.OutDir, cmd.Pipeline, cmd.Batch)
+
+	if r != nil {
+		for _, l := range r.Summary() {
+			fmt.Fprintln(os.Stdout, l)
+		}
+	}
+
+	if err != nil {
+		if needStackTrace {
+			fmt.Fprintf(os.Stderr, "Fatal: %+v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		return 1
+	}
+
+	if r.Failed() > 0 {
+		return 1
+	}
+
+	return 0
+}
+
 func handleVersion(command string) {
 	if (command == "v" || command == "version") && len(flag.Args()) == 0 {
 		version()
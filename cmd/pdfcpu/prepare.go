@@ -17,18 +17,23 @@ limitations under the License.
 package main
 
 import (
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/hhrutter/pdfcpu/pkg/api"
+	"github.com/hhrutter/pdfcpu/pkg/ocr"
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
 )
 
 func prepareValidateCommand(config *pdfcpu.Configuration) *api.Command {
 
-	if len(flag.Args()) == 0 || len(flag.Args()) > 1 || pageSelection != "" {
+	if len(flag.Args()) == 0 {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageValidate)
 		os.Exit(1)
 	}
@@ -36,18 +41,56 @@ func prepareValidateCommand(config *pdfcpu.Configuration) *api.Command {
 	filenameIn := flag.Arg(0)
 	ensurePdfExtension(filenameIn)
 
-	if mode != "" && mode != "strict" && mode != "s" && mode != "relaxed" && mode != "r" {
+	var objNrs []int
+	for _, arg := range flag.Args()[1:] {
+		objNr, err := strconv.Atoi(arg)
+		if err != nil || objNr <= 0 {
+			fmt.Fprintf(os.Stderr, "%s\n\n", usageValidate)
+			os.Exit(1)
+		}
+		objNrs = append(objNrs, objNr)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("validate: problem with flag pageSelection: %v", err)
+	}
+
+	if (pages != nil || objNrs != nil) && (pages != nil && objNrs != nil || mode != "" && mode != "strict" && mode != "s" && mode != "relaxed" && mode != "r") {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageValidate)
+		os.Exit(1)
+	}
+
+	if mode != "" && mode != "strict" && mode != "s" && mode != "relaxed" && mode != "r" && mode != "pdfa1b" && mode != "pdfa2b" && mode != "pdfx1a" && mode != "pdfx4" && mode != "pdfua" {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageValidate)
 		os.Exit(1)
 	}
 
 	switch mode {
+	case "pdfa1b":
+		return api.ValidatePDFACommand(filenameIn, pdfcpu.PDFA1B, config)
+	case "pdfa2b":
+		return api.ValidatePDFACommand(filenameIn, pdfcpu.PDFA2B, config)
+	case "pdfx1a":
+		return api.ValidatePDFXCommand(filenameIn, pdfcpu.PDFX1A, config)
+	case "pdfx4":
+		return api.ValidatePDFXCommand(filenameIn, pdfcpu.PDFX4, config)
+	case "pdfua":
+		return api.ValidatePDFUACommand(filenameIn, config)
 	case "strict", "s":
 		config.ValidationMode = pdfcpu.ValidationStrict
 	case "relaxed", "r":
 		config.ValidationMode = pdfcpu.ValidationRelaxed
 	}
 
+	if pages != nil {
+		return api.ValidatePagesCommand(filenameIn, pages, config)
+	}
+
+	if objNrs != nil {
+		return api.ValidateObjectsCommand(filenameIn, objNrs, config)
+	}
+
 	return api.ValidateCommand(filenameIn, config)
 }
 
@@ -115,8 +158,8 @@ func prepareMergeCommand(config *pdfcpu.Configuration) *api.Command {
 func prepareExtractCommand(config *pdfcpu.Configuration) *api.Command {
 
 	if len(flag.Args()) != 2 || mode == "" ||
-		(mode != "image" && mode != "font" && mode != "page" && mode != "content") &&
-			(mode != "i" && mode != "p" && mode != "c") {
+		(mode != "image" && mode != "font" && mode != "page" && mode != "content" && mode != "media") &&
+			(mode != "i" && mode != "p" && mode != "c" && mode != "m") {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageExtract)
 		os.Exit(1)
 	}
@@ -146,6 +189,9 @@ func prepareExtractCommand(config *pdfcpu.Configuration) *api.Command {
 
 	case "content", "c":
 		cmd = api.ExtractContentCommand(filenameIn, dirnameOut, pages, config)
+
+	case "media", "m":
+		cmd = api.ExtractMediaCommand(filenameIn, dirnameOut, pages, config)
 	}
 
 	return cmd
@@ -258,6 +304,33 @@ func prepareExtractAttachmentsCommand(config *pdfcpu.Configuration) *api.Command
 	return api.ExtractAttachmentsCommand(filenameIn, dirnameOut, filenames, config)
 }
 
+func prepareAddPageAttachmentsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 3 || len(flag.Args()) > 4 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageAttachPage)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	n, err := pdfcpu.ParseNoteDetails(flag.Arg(1), false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	n.Contents = flag.Arg(3)
+	n.Color = color
+
+	return api.AddPageAttachmentsCommand(filenameIn, flag.Arg(2), pages, n, config)
+}
+
 func prepareAttachmentCommand(config *pdfcpu.Configuration) *api.Command {
 
 	if len(os.Args) == 2 {
@@ -283,6 +356,9 @@ func prepareAttachmentCommand(config *pdfcpu.Configuration) *api.Command {
 	case "extract":
 		cmd = prepareExtractAttachmentsCommand(config)
 
+	case "page":
+		cmd = prepareAddPageAttachmentsCommand(config)
+
 	default:
 		fmt.Fprintln(os.Stderr, usageAttach)
 		os.Exit(1)
@@ -306,8 +382,7 @@ func prepareListPermissionsCommand(config *pdfcpu.Configuration) *api.Command {
 
 func prepareAddPermissionsCommand(config *pdfcpu.Configuration) *api.Command {
 
-	if len(flag.Args()) != 1 || pageSelection != "" ||
-		!(perm == "" || perm == "none" || perm == "all") {
+	if len(flag.Args()) != 1 || pageSelection != "" {
 		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usagePermAdd)
 		os.Exit(1)
 	}
@@ -315,9 +390,7 @@ func prepareAddPermissionsCommand(config *pdfcpu.Configuration) *api.Command {
 	filenameIn := flag.Arg(0)
 	ensurePdfExtension(filenameIn)
 
-	if perm == "all" {
-		config.UserAccessPermissions = pdfcpu.PermissionsAll
-	}
+	setupPermissions(config, usagePermAdd)
 
 	return api.AddPermissionsCommand(filenameIn, config)
 }
@@ -372,16 +445,102 @@ func prepareDecryptCommand(config *pdfcpu.Configuration) *api.Command {
 func validEncryptOptions() bool {
 	return pageSelection == "" &&
 		(mode == "" || mode == "rc4" || mode == "aes") &&
-		(key == "" || key == "40" || key == "128") &&
-		(perm == "" || perm == "none" || perm == "all")
+		(key == "" || key == "40" || key == "128" || key == "256") &&
+		!(mode == "rc4" && key == "256")
 }
 
-func prepareEncryptCommand(config *pdfcpu.Configuration) *api.Command {
+// setupPermissions sets the user access permissions passed with -perm.
+func setupPermissions(config *pdfcpu.Configuration, usage string) {
 
-	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || !validEncryptOptions() {
-		fmt.Fprintf(os.Stderr, "%s\n\n", usageEncrypt)
+	p, err := pdfcpu.ParsePermissions(perm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n%s\n\n", err, usage)
+		os.Exit(1)
+	}
+
+	config.SetPermissions(*p)
+}
+
+// setupRecipients loads the recipient certificates for certificate based encryption.
+func setupRecipients(config *pdfcpu.Configuration) {
+
+	for _, fileName := range strings.Split(cert, ",") {
+		certs, err := api.ReadCertificates(strings.TrimSpace(fileName), config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		config.Recipients = append(config.Recipients, certs...)
+	}
+}
+
+// setupCertificate loads certificate and private key for opening documents encrypted for certificate recipients.
+func setupCertificate(config *pdfcpu.Configuration) {
+
+	if cert == "" && privKey == "" {
+		return
+	}
+
+	if cert == "" || privKey == "" {
+		fmt.Fprintln(os.Stderr, "please supply both -cert and -privkey")
+		os.Exit(1)
+	}
+
+	certs, err := api.ReadCertificates(cert, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	config.Certificate = certs[0]
+
+	if config.PrivateKey, err = api.ReadPrivateKey(privKey, config); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func setupValidationPolicy(config *pdfcpu.Configuration) {
+
+	if rules == "" {
+		return
+	}
+
+	p, err := pdfcpu.ParseValidationPolicy(rules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	config.ValidationPolicy = p
+}
+
+func setupXRef(config *pdfcpu.Configuration) {
+
+	switch xref {
+
+	case "":
+		return
+
+	case "table":
+		config.WriteObjectStream = false
+		config.WriteXRefStream = false
+
+	case "stream":
+		config.WriteObjectStream = true
+		config.WriteXRefStream = true
+
+	case "hybrid":
+		config.WriteObjectStream = true
+		config.WriteXRefStream = true
+		config.WriteHybridXRef = true
+
+	default:
+		fmt.Fprintf(os.Stderr, "xref must be one of: table, stream, hybrid\n")
 		os.Exit(1)
 	}
+}
+
+func setupEncryption(config *pdfcpu.Configuration, usage string) {
 
 	if mode == "rc4" {
 		config.EncryptUsingAES = false
@@ -391,10 +550,26 @@ func prepareEncryptCommand(config *pdfcpu.Configuration) *api.Command {
 		config.EncryptUsing128BitKey = false
 	}
 
-	if perm == "all" {
-		config.UserAccessPermissions = pdfcpu.PermissionsAll
+	if key == "256" {
+		config.EncryptUsing256BitKey = true
+	}
+
+	setupPermissions(config, usage)
+
+	if cert != "" {
+		setupRecipients(config)
+	}
+}
+
+func prepareEncryptCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || !validEncryptOptions() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageEncrypt)
+		os.Exit(1)
 	}
 
+	setupEncryption(config, usageEncrypt)
+
 	filenameIn := flag.Arg(0)
 	ensurePdfExtension(filenameIn)
 	filenameOut := filenameIn
@@ -461,8 +636,146 @@ func prepareChangePasswordCommand(config *pdfcpu.Configuration, s string) *api.C
 
 func prepareWatermarksCommand(config *pdfcpu.Configuration, onTop bool) *api.Command {
 
+	usage := usageWatermark
+	if onTop {
+		usage = usageStamp
+	}
+
+	var subCmd string
+	if len(os.Args) > 2 && (os.Args[2] == "remove" || os.Args[2] == "update") {
+		subCmd = os.Args[2]
+	}
+
+	if subCmd == "remove" {
+
+		if len(flag.Args()) < 1 || len(flag.Args()) > 2 {
+			fmt.Fprintf(os.Stderr, "%s\n\n", usage)
+			os.Exit(1)
+		}
+
+		pages, err := api.ParsePageSelection(pageSelection)
+		if err != nil {
+			log.Fatalf("problem with flag pageSelection: %v", err)
+		}
+
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+
+		filenameOut := defaultFilenameOut(filenameIn)
+		if len(flag.Args()) == 2 {
+			filenameOut = flag.Arg(1)
+			ensurePdfExtension(filenameOut)
+		}
+
+		return api.RemoveWatermarksCommand(filenameIn, filenameOut, pages, onTop, config)
+	}
+
+	args := flag.Args()
+
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usage)
+		os.Exit(1)
+	}
+
+	// The first description applies to -pages, further descriptions follow their own -pages flag.
+	sels := []string{pageSelection}
+	descs := []string{args[0]}
+	args = args[1:]
+
+	for len(args) > 0 && (args[0] == "-pages" || args[0] == "-p") {
+		if len(args) < 3 {
+			fmt.Fprintf(os.Stderr, "%s\n\n", usage)
+			os.Exit(1)
+		}
+		sels = append(sels, args[1])
+		descs = append(descs, args[2])
+		args = args[3:]
+	}
+
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usage)
+		os.Exit(1)
+	}
+
+	wms := []api.WatermarkSelection{}
+
+	for i, desc := range descs {
+
+		pages, err := api.ParsePageSelection(sels[i])
+		if err != nil {
+			log.Fatalf("problem with flag pageSelection: %v", err)
+		}
+
+		//fmt.Printf("details: <%s>\n", desc)
+		wm, err := pdfcpu.ParseWatermarkDetails(desc, onTop)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		wms = append(wms, api.WatermarkSelection{PageSelection: pages, Watermark: wm})
+	}
+
+	filenameIn := args[0]
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(args) == 2 {
+		filenameOut = args[1]
+		ensurePdfExtension(filenameOut)
+	}
+
+	if subCmd == "update" {
+		return api.UpdateWatermarksCommand(filenameIn, filenameOut, wms, config)
+	}
+
+	if len(wms) == 1 {
+		return api.AddWatermarksCommand(filenameIn, filenameOut, wms[0].PageSelection, wms[0].Watermark, config)
+	}
+
+	return api.AddMultipleWatermarksCommand(filenameIn, filenameOut, wms, config)
+}
+
+func prepareAddStampsCommand(config *pdfcpu.Configuration) *api.Command {
+	return prepareWatermarksCommand(config, true)
+}
+
+func prepareAddWatermarksCommand(config *pdfcpu.Configuration) *api.Command {
+	return prepareWatermarksCommand(config, false)
+}
+
+func prepareAddQRCodeStampCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageQRStamp)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	wm, err := pdfcpu.ParseQRCodeStampDetails(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	filenameIn := flag.Arg(1)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.AddQRCodeStampCommand(filenameIn, filenameOut, pages, wm, config)
+}
+
+func prepareAddBarcodeStampCommand(config *pdfcpu.Configuration) *api.Command {
+
 	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
-		fmt.Fprintf(os.Stderr, "%s\n\n", usageStamp)
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageBarcode)
 		os.Exit(1)
 	}
 
@@ -471,8 +784,7 @@ func prepareWatermarksCommand(config *pdfcpu.Configuration, onTop bool) *api.Com
 		log.Fatalf("problem with flag pageSelection: %v", err)
 	}
 
-	//fmt.Printf("details: <%s>\n", flag.Arg(0))
-	wm, err := pdfcpu.ParseWatermarkDetails(flag.Arg(0), onTop)
+	wm, err := pdfcpu.ParseBarcodeStampDetails(flag.Arg(0))
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
@@ -489,10 +801,2102 @@ func prepareWatermarksCommand(config *pdfcpu.Configuration, onTop bool) *api.Com
 	return api.AddWatermarksCommand(filenameIn, filenameOut, pages, wm, config)
 }
 
-func prepareAddStampsCommand(config *pdfcpu.Configuration) *api.Command {
-	return prepareWatermarksCommand(config, true)
+func prepareListFormFieldsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListFormFieldsCommand(filenameIn, config)
 }
 
-func prepareAddWatermarksCommand(config *pdfcpu.Configuration) *api.Command {
-	return prepareWatermarksCommand(config, false)
+func prepareFillFormCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormFill)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameData := flag.Arg(1)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.FillFormCommand(filenameIn, filenameData, filenameOut, config)
+}
+
+func prepareExportFormCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormExport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensureFormDataExtension(filenameOut)
+
+	return api.ExportFormCommand(filenameIn, filenameOut, config)
+}
+
+func prepareMultiFillFormCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 3 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormMultiFill)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameCSV := flag.Arg(1)
+	ensureCSVExtension(filenameCSV)
+
+	mfc := &pdfcpu.MultiFillConfig{NameColumn: nameColumn}
+
+	if fieldMap != "" {
+		m, err := pdfcpu.ParseFieldMap(fieldMap)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		mfc.FieldMap = m
+	}
+
+	var dirOut, filenameOut string
+
+	switch mode {
+
+	case "", "single":
+		dirOut = flag.Arg(2)
+
+	case "merge":
+		mfc.Merge = true
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+
+	default:
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormMultiFill)
+		os.Exit(1)
+	}
+
+	return api.MultiFillFormCommand(filenameIn, filenameCSV, dirOut, filenameOut, mfc, config)
+}
+
+func prepareFieldAppearancesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormAppearances)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.FieldAppearancesCommand(filenameIn, filenameOut, config)
+}
+
+func prepareListFormOrderCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormOrder)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListFormOrderCommand(filenameIn, config)
+}
+
+// parseFormFieldArgs parses inFile [outFile] arg...
+func parseFormFieldArgs(usage string) (filenameIn, filenameOut string, args []string) {
+
+	if len(flag.Args()) < 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+		os.Exit(1)
+	}
+
+	filenameIn = flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut = filenameIn
+
+	args = flag.Args()[1:]
+	if len(args) > 0 && strings.HasSuffix(strings.ToLower(args[0]), ".pdf") {
+		filenameOut = args[0]
+		args = args[1:]
+	}
+
+	return
+}
+
+func prepareSetTabOrderCommand(config *pdfcpu.Configuration) *api.Command {
+
+	filenameIn, filenameOut, args := parseFormFieldArgs(usageFormTabs)
+
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormTabs)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	var tabs string
+	switch args[0] {
+	case "R", "C", "S":
+		tabs = args[0]
+		args = args[1:]
+	}
+
+	return api.SetTabOrderCommand(filenameIn, filenameOut, pages, tabs, args, config)
+}
+
+func prepareSetCalculationOrderCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormCalcOrder)
+		os.Exit(1)
+	}
+
+	filenameIn, filenameOut, fieldNames := parseFormFieldArgs(usageFormCalcOrder)
+
+	return api.SetCalculationOrderCommand(filenameIn, filenameOut, fieldNames, config)
+}
+
+func prepareAddSignatureFieldCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormSignature)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	fa, label, err := pdfcpu.ParseSignatureFieldDetails(flag.Arg(len(flag.Args()) - 1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	return api.AddSignatureFieldCommand(filenameIn, filenameOut, fa, label, config)
+}
+
+func prepareResetFormCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormReset)
+		os.Exit(1)
+	}
+
+	filenameIn, filenameOut, fieldNames := parseFormFieldArgs(usageFormReset)
+
+	return api.ResetFormCommand(filenameIn, filenameOut, fieldNames, config)
+}
+
+func prepareListChoiceOptionsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormOptions)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListChoiceOptionsCommand(filenameIn, flag.Arg(1), config)
+}
+
+func prepareChoiceOptionsCommand(config *pdfcpu.Configuration, subCmd string) *api.Command {
+
+	usage := map[string]string{
+		"addoptions":    usageFormAddOptions,
+		"removeoptions": usageFormRemoveOptions,
+		"setoptions":    usageFormSetOptions,
+	}[subCmd]
+
+	if pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+		os.Exit(1)
+	}
+
+	filenameIn, filenameOut, args := parseFormFieldArgs(usage)
+
+	if len(args) == 0 || len(args) == 1 && subCmd != "setoptions" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+		os.Exit(1)
+	}
+
+	opts := []pdfcpu.ChoiceOption{}
+	for _, arg := range args[1:] {
+		opts = append(opts, pdfcpu.ParseChoiceOption(arg))
+	}
+
+	switch subCmd {
+	case "addoptions":
+		return api.AddChoiceOptionsCommand(filenameIn, filenameOut, args[0], opts, config)
+	case "removeoptions":
+		return api.RemoveChoiceOptionsCommand(filenameIn, filenameOut, args[0], opts, config)
+	}
+
+	return api.SetChoiceOptionsCommand(filenameIn, filenameOut, args[0], opts, config)
+}
+
+func prepareFormCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageForm)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListFormFieldsCommand(config)
+
+	case "fill":
+		cmd = prepareFillFormCommand(config)
+
+	case "export":
+		cmd = prepareExportFormCommand(config)
+
+	case "multifill":
+		cmd = prepareMultiFillFormCommand(config)
+
+	case "appearances":
+		cmd = prepareFieldAppearancesCommand(config)
+
+	case "order":
+		cmd = prepareListFormOrderCommand(config)
+
+	case "tabs":
+		cmd = prepareSetTabOrderCommand(config)
+
+	case "calcorder":
+		cmd = prepareSetCalculationOrderCommand(config)
+
+	case "signature":
+		cmd = prepareAddSignatureFieldCommand(config)
+
+	case "reset":
+		cmd = prepareResetFormCommand(config)
+
+	case "options":
+		cmd = prepareListChoiceOptionsCommand(config)
+
+	case "addoptions", "removeoptions", "setoptions":
+		cmd = prepareChoiceOptionsCommand(config, subCmd)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageForm)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareListAnnotationsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageAnnotList)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListAnnotationsCommand(filenameIn, pages, config)
+}
+
+// parseAnnotationArgs parses inFile [outFile] [subtype|objNr...].
+func parseAnnotationArgs(usage string) (filenameIn, filenameOut string, pages, subtypes []string, objNrs []int) {
+
+	if len(flag.Args()) < 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn = flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut = filenameIn
+
+	for i, arg := range flag.Args()[1:] {
+		if i == 0 && strings.HasSuffix(strings.ToLower(arg), ".pdf") {
+			filenameOut = arg
+			continue
+		}
+		if objNr, err := strconv.Atoi(arg); err == nil {
+			objNrs = append(objNrs, objNr)
+			continue
+		}
+		subtypes = append(subtypes, arg)
+	}
+
+	return
+}
+
+func prepareRemoveAnnotationsCommand(config *pdfcpu.Configuration) *api.Command {
+	filenameIn, filenameOut, pages, subtypes, objNrs := parseAnnotationArgs(usageAnnotRemove)
+	return api.RemoveAnnotationsCommand(filenameIn, filenameOut, pages, subtypes, objNrs, config)
+}
+
+func prepareFlattenAnnotationsCommand(config *pdfcpu.Configuration) *api.Command {
+	filenameIn, filenameOut, pages, subtypes, objNrs := parseAnnotationArgs(usageAnnotFlatten)
+	return api.FlattenAnnotationsCommand(filenameIn, filenameOut, pages, subtypes, objNrs, config)
+}
+
+func prepareExportAnnotationsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageAnnotExport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensureXFDFExtension(filenameOut)
+
+	return api.ExportAnnotationsCommand(filenameIn, filenameOut, config)
+}
+
+func prepareImportAnnotationsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageAnnotImport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameXFDF := flag.Arg(1)
+	ensureXFDFExtension(filenameXFDF)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.ImportAnnotationsCommand(filenameIn, filenameXFDF, filenameOut, config)
+}
+
+func prepareAddLinksCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 1 || len(flag.Args()) > 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageAnnotLink)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+
+	args := flag.Args()[1:]
+	if len(args) > 0 && strings.HasSuffix(strings.ToLower(args[0]), ".pdf") {
+		filenameOut = args[0]
+		args = args[1:]
+	}
+
+	var link *pdfcpu.Link
+
+	switch len(args) {
+
+	case 0:
+
+	case 1:
+		link, err = pdfcpu.ParseLinkDetails(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageAnnotLink)
+		os.Exit(1)
+	}
+
+	return api.AddLinksCommand(filenameIn, filenameOut, pages, link, config)
+}
+
+func prepareHighlightTextCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageAnnotMarkup)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	tmc := pdfcpu.NewTextMarkupConfig()
+	tmc.Color = color
+
+	switch mode {
+	case "", "highlight":
+	case "underline":
+		tmc.Subtype = "Underline"
+	case "strikeout":
+		tmc.Subtype = "StrikeOut"
+	case "squiggly":
+		tmc.Subtype = "Squiggly"
+	default:
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageAnnotMarkup)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	query := flag.Arg(len(flag.Args()) - 1)
+
+	return api.HighlightTextCommand(filenameIn, filenameOut, pages, query, tmc, config)
+}
+
+func prepareAddNotesCommand(config *pdfcpu.Configuration, freeText bool) *api.Command {
+
+	usage := usageAnnotNote
+	if freeText {
+		usage = usageAnnotFree
+	}
+
+	if len(flag.Args()) < 3 || len(flag.Args()) > 4 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 4 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	args := flag.Args()[len(flag.Args())-2:]
+
+	n, err := pdfcpu.ParseNoteDetails(args[0], freeText)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	n.Contents = strings.Replace(args[1], "\\n", "\n", -1)
+	n.Color = color
+
+	if freeText {
+		return api.AddFreeTextsCommand(filenameIn, filenameOut, pages, n, config)
+	}
+
+	return api.AddNotesCommand(filenameIn, filenameOut, pages, n, config)
+}
+
+func prepareAnnotationsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageAnnot)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListAnnotationsCommand(config)
+
+	case "remove":
+		cmd = prepareRemoveAnnotationsCommand(config)
+
+	case "flatten":
+		cmd = prepareFlattenAnnotationsCommand(config)
+
+	case "export":
+		cmd = prepareExportAnnotationsCommand(config)
+
+	case "import":
+		cmd = prepareImportAnnotationsCommand(config)
+
+	case "link":
+		cmd = prepareAddLinksCommand(config)
+
+	case "markup":
+		cmd = prepareHighlightTextCommand(config)
+
+	case "note":
+		cmd = prepareAddNotesCommand(config, false)
+
+	case "freetext":
+		cmd = prepareAddNotesCommand(config, true)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageAnnot)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareRedactCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 1 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageRedact)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+
+	args := flag.Args()[1:]
+	if len(args) > 0 && strings.HasSuffix(strings.ToLower(args[0]), ".pdf") {
+		filenameOut = args[0]
+		args = args[1:]
+	}
+
+	rc := &pdfcpu.RedactConfig{Color: color}
+
+	for _, arg := range args {
+
+		if !strings.HasPrefix(arg, "rect:") {
+			rc.Terms = append(rc.Terms, arg)
+			continue
+		}
+
+		r, err := pdfcpu.ParseRedactRect(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+
+		rc.Rects = append(rc.Rects, r)
+	}
+
+	return api.RedactCommand(filenameIn, filenameOut, pages, rc, config)
+}
+
+func prepareListJavaScriptCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageJSList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListJavaScriptCommand(filenameIn, config)
+}
+
+func prepareRemoveJavaScriptCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageJSRemove)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.RemoveJavaScriptCommand(filenameIn, filenameOut, config)
+}
+
+func prepareJavaScriptCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageJS)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListJavaScriptCommand(config)
+
+	case "remove":
+		cmd = prepareRemoveJavaScriptCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageJS)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareSanitizeCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", usageSanitize)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.SanitizeCommand(filenameIn, filenameOut, external, config)
+}
+
+func prepareRemoveUsageRightsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "%s\n", usageRemoveUsageRights)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.RemoveUsageRightsCommand(filenameIn, filenameOut, config)
+}
+
+func prepareConvertToPDFACommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", usageConvertToPDFA)
+		os.Exit(1)
+	}
+
+	level := pdfcpu.PDFA2B
+	switch mode {
+	case "":
+	case pdfcpu.PDFA1B, pdfcpu.PDFA2B:
+		level = mode
+	default:
+		fmt.Fprintf(os.Stderr, "%s\n", usageConvertToPDFA)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.ConvertToPDFACommand(filenameIn, filenameOut, level, fontDir, config)
+}
+
+func outputIntentSubtype(usage string, all bool) string {
+
+	switch mode {
+	case "":
+		if all {
+			return ""
+		}
+		return pdfcpu.OutputIntentPDFX
+	case "pdfx":
+		return pdfcpu.OutputIntentPDFX
+	case "pdfa":
+		return pdfcpu.OutputIntentPDFA
+	}
+
+	fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+	os.Exit(1)
+
+	return ""
+}
+
+func prepareOutputIntentCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 || pageSelection != "" {
+		fmt.Fprintln(os.Stderr, usageOutputIntent)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageOutputIntentList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListOutputIntentsCommand(filenameIn, config)
+
+	case "add", "replace":
+		usage := usageOutputIntentAdd
+		if subCmd == "replace" {
+			usage = usageOutputIntentReplace
+		}
+		if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usage)
+			os.Exit(1)
+		}
+		subtype := outputIntentSubtype(usage, false)
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		filenameOut := filenameIn
+		if len(flag.Args()) == 3 {
+			filenameOut = flag.Arg(2)
+			ensurePdfExtension(filenameOut)
+		}
+		if subCmd == "add" {
+			cmd = api.AddOutputIntentCommand(filenameIn, flag.Arg(1), filenameOut, subtype, config)
+		} else {
+			cmd = api.ReplaceOutputIntentCommand(filenameIn, flag.Arg(1), filenameOut, subtype, config)
+		}
+
+	case "remove":
+		if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageOutputIntentRemove)
+			os.Exit(1)
+		}
+		subtype := outputIntentSubtype(usageOutputIntentRemove, true)
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		filenameOut := filenameIn
+		if len(flag.Args()) == 2 {
+			filenameOut = flag.Arg(1)
+			ensurePdfExtension(filenameOut)
+		}
+		cmd = api.RemoveOutputIntentsCommand(filenameIn, filenameOut, subtype, config)
+
+	case "extract":
+		if len(flag.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageOutputIntentExtract)
+			os.Exit(1)
+		}
+		subtype := outputIntentSubtype(usageOutputIntentExtract, false)
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ExtractOutputIntentCommand(filenameIn, flag.Arg(1), subtype, config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageOutputIntent)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareLinearizeCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", usageLinearize)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	switch mode {
+
+	case "":
+
+	case "check":
+		if len(flag.Args()) > 1 {
+			fmt.Fprintf(os.Stderr, "%s\n", usageLinearize)
+			os.Exit(1)
+		}
+		return api.CheckLinearizationCommand(filenameIn, config)
+
+	default:
+		fmt.Fprintf(os.Stderr, "%s\n", usageLinearize)
+		os.Exit(1)
+	}
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.LinearizeCommand(filenameIn, filenameOut, config)
+}
+
+func prepareListRevisionsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageRevisionsList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListRevisionsCommand(filenameIn, config)
+}
+
+func prepareExtractRevisionCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageRevisionsExtract)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	n, err := strconv.Atoi(flag.Arg(1))
+	if err != nil || n < 1 {
+		fmt.Fprintf(os.Stderr, "invalid revision: %s\n", flag.Arg(1))
+		os.Exit(1)
+	}
+
+	filenameOut := flag.Arg(2)
+	ensurePdfExtension(filenameOut)
+
+	return api.ExtractRevisionCommand(filenameIn, filenameOut, n, config)
+}
+
+func prepareRevisionsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageRevisions)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListRevisionsCommand(config)
+
+	case "extract":
+		cmd = prepareExtractRevisionCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageRevisions)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareListXMPCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageXMPList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListXMPCommand(filenameIn, config)
+}
+
+func prepareSetXMPCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageXMPSet)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensurePdfExtension(filenameOut)
+
+	props := map[string]string{}
+	for _, arg := range flag.Args()[2:] {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid property: %s\n", arg)
+			os.Exit(1)
+		}
+		props[arg[:i]] = arg[i+1:]
+	}
+
+	return api.SetXMPCommand(filenameIn, filenameOut, props, config)
+}
+
+func prepareXMPCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageXMP)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListXMPCommand(config)
+
+	case "set":
+		cmd = prepareSetXMPCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageXMP)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareListInfoCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageInfoList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListInfoCommand(filenameIn, config)
+}
+
+func prepareSetInfoCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageInfoSet)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensurePdfExtension(filenameOut)
+
+	props := map[string]string{}
+	for _, arg := range flag.Args()[2:] {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid entry: %s\n", arg)
+			os.Exit(1)
+		}
+		props[arg[:i]] = arg[i+1:]
+	}
+
+	return api.SetInfoCommand(filenameIn, filenameOut, props, config)
+}
+
+func prepareRemoveInfoCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageInfoRemove)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensurePdfExtension(filenameOut)
+
+	return api.RemoveInfoCommand(filenameIn, filenameOut, flag.Args()[2:], config)
+}
+
+func prepareInfoCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageInfo)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListInfoCommand(config)
+
+	case "set":
+		cmd = prepareSetInfoCommand(config)
+
+	case "remove":
+		cmd = prepareRemoveInfoCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageInfo)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+// inOutFiles returns the input and output file of the keywords and properties subcommands.
+func inOutFiles() (string, string) {
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensurePdfExtension(filenameOut)
+
+	return filenameIn, filenameOut
+}
+
+func prepareKeywordsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageKeywords)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageKeywordsList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListKeywordsCommand(filenameIn, config)
+
+	case "add":
+		if len(flag.Args()) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageKeywordsAdd)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.AddKeywordsCommand(filenameIn, filenameOut, flag.Args()[2:], config)
+
+	case "remove":
+		if len(flag.Args()) < 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageKeywordsRemove)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RemoveKeywordsCommand(filenameIn, filenameOut, flag.Args()[2:], config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageKeywords)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareBookmarksCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageBookmarks)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListBookmarksCommand(filenameIn, config)
+
+	case "export":
+		if len(flag.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksExport)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ExportBookmarksCommand(filenameIn, flag.Arg(1), config)
+
+	case "import":
+		if len(flag.Args()) != 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksImport)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := flag.Arg(0), flag.Arg(2)
+		ensurePdfExtension(filenameIn)
+		ensurePdfExtension(filenameOut)
+		cmd = api.ImportBookmarksCommand(filenameIn, flag.Arg(1), filenameOut, config)
+
+	case "generate":
+		if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksGenerate)
+			os.Exit(1)
+		}
+		n := 1
+		if len(flag.Args()) == 3 {
+			var err error
+			if n, err = strconv.Atoi(flag.Arg(2)); err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksGenerate)
+				os.Exit(1)
+			}
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.GenerateBookmarksCommand(filenameIn, filenameOut, n, config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageBookmarks)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareNamedDestsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageDests)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageDestsList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListNamedDestsCommand(filenameIn, config)
+
+	case "add":
+		if len(flag.Args()) < 4 || len(flag.Args()) > 5 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageDestsAdd)
+			os.Exit(1)
+		}
+		page, err := strconv.Atoi(flag.Arg(3))
+		if err != nil || page < 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageDestsAdd)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		dest := pdfcpu.NamedDest{Name: flag.Arg(2), Page: page, View: flag.Arg(4)}
+		cmd = api.AddNamedDestCommand(filenameIn, filenameOut, dest, config)
+
+	case "remove":
+		if len(flag.Args()) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageDestsRemove)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RemoveNamedDestsCommand(filenameIn, filenameOut, flag.Args()[2:], config)
+
+	case "rename":
+		if len(flag.Args()) != 4 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageDestsRename)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RenameNamedDestCommand(filenameIn, filenameOut, flag.Arg(2), flag.Arg(3), config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageDests)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareLayersCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageLayers)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageLayersList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListLayersCommand(filenameIn, config)
+
+	case "show", "hide":
+		if len(flag.Args()) < 3 {
+			u := usageLayersShow
+			if subCmd == "hide" {
+				u = usageLayersHide
+			}
+			fmt.Fprintf(os.Stderr, "usage: %s\n", u)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.SetLayerVisibilityCommand(filenameIn, filenameOut, flag.Args()[2:], subCmd == "show", config)
+
+	case "rename":
+		if len(flag.Args()) != 4 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageLayersRename)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RenameLayerCommand(filenameIn, filenameOut, flag.Arg(2), flag.Arg(3), config)
+
+	case "remove":
+		if len(flag.Args()) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageLayersRemove)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RemoveLayersCommand(filenameIn, filenameOut, flag.Args()[2:], config)
+
+	case "flatten":
+		if len(flag.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageLayersFlatten)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.FlattenLayersCommand(filenameIn, filenameOut, config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageLayers)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareSetViewerPreferencesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageViewerSet)
+		os.Exit(1)
+	}
+
+	vp := pdfcpu.ViewerPrefs{PageLayout: layout, PageMode: pageMode, Duplex: duplex}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "fitwindow":
+			vp.FitWindow = &fitWindow
+		case "hidetoolbar":
+			vp.HideToolbar = &hideToolbar
+		}
+	})
+
+	filenameIn, filenameOut := inOutFiles()
+
+	return api.SetViewerPreferencesCommand(filenameIn, filenameOut, vp, config)
+}
+
+func prepareSetOpenActionCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 3 || len(flag.Args()) > 4 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageViewerOpen)
+		os.Exit(1)
+	}
+
+	page, err := strconv.Atoi(flag.Arg(2))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid page: %s\n", flag.Arg(2))
+		os.Exit(1)
+	}
+
+	filenameIn, filenameOut := inOutFiles()
+
+	return api.SetViewerPreferencesCommand(filenameIn, filenameOut, pdfcpu.ViewerPrefs{OpenPage: page, OpenView: flag.Arg(3)}, config)
+}
+
+func prepareViewerCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageViewer)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageViewerList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListViewerPreferencesCommand(filenameIn, config)
+
+	case "set":
+		cmd = prepareSetViewerPreferencesCommand(config)
+
+	case "open":
+		cmd = prepareSetOpenActionCommand(config)
+
+	case "remove":
+		if len(flag.Args()) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageViewerRemove)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RemoveViewerPreferencesCommand(filenameIn, filenameOut, flag.Args()[2:], config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageViewer)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareTransitionsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageTransitions)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageTransitionsList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListTransitionsCommand(filenameIn, config)
+
+	case "set":
+		if len(flag.Args()) != 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageTransitionsSet)
+			os.Exit(1)
+		}
+		pages, err := api.ParsePageSelection(pageSelection)
+		if err != nil {
+			log.Fatalf("problem with flag pageSelection: %v", err)
+		}
+		t, err := pdfcpu.ParseTransitionDetails(flag.Arg(2))
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.SetTransitionsCommand(filenameIn, filenameOut, pages, *t, config)
+
+	case "remove":
+		if len(flag.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageTransitionsRemove)
+			os.Exit(1)
+		}
+		pages, err := api.ParsePageSelection(pageSelection)
+		if err != nil {
+			log.Fatalf("problem with flag pageSelection: %v", err)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RemoveTransitionsCommand(filenameIn, filenameOut, pages, config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageTransitions)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareCreatePortfolioCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usagePortfolioCreate)
+		os.Exit(1)
+	}
+
+	filenameIn, filenameOut := inOutFiles()
+
+	files := flag.Args()[2:]
+
+	p := api.PortfolioFromFiles(files)
+
+	if len(files) == 1 && strings.ToLower(filepath.Ext(files[0])) == ".json" {
+		var err error
+		if p, err = api.ReadPortfolio(files[0], config); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	return api.CreatePortfolioCommand(filenameIn, filenameOut, p, config)
+}
+
+func preparePortfolioCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usagePortfolio)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usagePortfolioList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListPortfolioCommand(filenameIn, config)
+
+	case "create":
+		cmd = prepareCreatePortfolioCommand(config)
+
+	case "extract":
+		if len(flag.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usagePortfolioExtract)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ExtractPortfolioCommand(filenameIn, flag.Arg(1), config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usagePortfolio)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareAssociatedFilesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageAssociatedFiles)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageAssociatedFilesList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListAssociatedFilesCommand(filenameIn, config)
+
+	case "add":
+		if len(flag.Args()) != 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageAssociatedFilesAdd)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		af := pdfcpu.AssociatedFile{File: flag.Arg(2), Relationship: afRel, Description: afDesc, Page: afPage, ObjNr: afObj}
+		cmd = api.AddAssociatedFileCommand(filenameIn, filenameOut, af, config)
+
+	case "validate":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageAssociatedFilesValidate)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ValidateAssociatedFilesCommand(filenameIn, config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageAssociatedFiles)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareGeoCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageGeo)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageGeoList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListViewportsCommand(filenameIn, config)
+
+	case "add":
+		if len(flag.Args()) != 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageGeoAdd)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		vps, err := api.ReadViewports(flag.Arg(2), config)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		cmd = api.AddViewportsCommand(filenameIn, filenameOut, vps, config)
+
+	case "remove":
+		if len(flag.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageGeoRemove)
+			os.Exit(1)
+		}
+		pages, err := api.ParsePageSelection(pageSelection)
+		if err != nil {
+			log.Fatalf("problem with flag pageSelection: %v", err)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RemoveViewportsCommand(filenameIn, filenameOut, pages, config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageGeo)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareOCRCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "%s\n", usageOCR)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	engine := ocr.Tesseract{Path: tesseract, Languages: ocrLang}
+
+	return api.OCRCommand(filenameIn, filenameOut, pages, engine, config)
+}
+
+func prepareImportOCRCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+		fmt.Fprintf(os.Stderr, "%s\n", usageImportOCR)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	ocrFile := flag.Arg(1)
+	if ext := strings.ToLower(filepath.Ext(ocrFile)); ext != ".hocr" && ext != ".html" && ext != ".xml" {
+		log.Fatalf("%s needs extension \".hocr\", \".html\" or \".xml\".", ocrFile)
+	}
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.ImportOCRCommand(filenameIn, ocrFile, filenameOut, pages, config)
+}
+
+func prepareAddPropertiesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usagePropertiesAdd)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensurePdfExtension(filenameOut)
+
+	props := map[string]string{}
+	for _, arg := range flag.Args()[2:] {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid property: %s\n", arg)
+			os.Exit(1)
+		}
+		props[arg[:i]] = arg[i+1:]
+	}
+
+	return api.AddPropertiesCommand(filenameIn, filenameOut, props, config)
+}
+
+func preparePropertiesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageProperties)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usagePropertiesList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListPropertiesCommand(filenameIn, config)
+
+	case "add":
+		cmd = prepareAddPropertiesCommand(config)
+
+	case "remove":
+		if len(flag.Args()) < 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usagePropertiesRemove)
+			os.Exit(1)
+		}
+		filenameIn, filenameOut := inOutFiles()
+		cmd = api.RemovePropertiesCommand(filenameIn, filenameOut, flag.Args()[2:], config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageProperties)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareStructCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageStruct)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageStructList)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ListStructTreeCommand(filenameIn, config)
+
+	case "export":
+		if len(flag.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s\n", usageStructExport)
+			os.Exit(1)
+		}
+		filenameIn := flag.Arg(0)
+		ensurePdfExtension(filenameIn)
+		cmd = api.ExportStructTreeCommand(filenameIn, flag.Arg(1), config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageStruct)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareRepairCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageRepair)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.RepairCommand(filenameIn, filenameOut, config)
+}
+
+func prepareSignCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 4 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", usageSign)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	signer, err := api.ReadPKCS12(flag.Arg(1), pw, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	filenameOut := defaultFilenameOut(filenameIn)
+
+	args := flag.Args()[2:]
+	if len(args) > 0 && strings.HasSuffix(strings.ToLower(args[0]), ".pdf") {
+		filenameOut = args[0]
+		args = args[1:]
+	}
+
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "%s\n", usageSign)
+		os.Exit(1)
+	}
+
+	var s string
+	if len(args) == 1 {
+		s = args[0]
+	}
+
+	sa, err := pdfcpu.ParseSignatureAttributes(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	return api.SignCommand(filenameIn, filenameOut, sa, signer, config)
+}
+
+// trustedRoots returns the certificates of the PEM files passed with -cert or nil for the system roots.
+func trustedRoots(config *pdfcpu.Configuration) *x509.CertPool {
+
+	if cert == "" {
+		return nil
+	}
+
+	roots := x509.NewCertPool()
+
+	for _, fileName := range strings.Split(cert, ",") {
+		certs, err := api.ReadCertificates(strings.TrimSpace(fileName), config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		for _, c := range certs {
+			roots.AddCert(c)
+		}
+	}
+
+	return roots
+}
+
+func prepareVerifySignaturesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", usageVerify)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.VerifySignaturesCommand(filenameIn, trustedRoots(config), config)
+}
+
+func prepareEnableLTVCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 1 || len(flag.Args()) > 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", usageLTV)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.EnableLTVCommand(filenameIn, filenameOut, trustedRoots(config), config)
+}
+
+func prepareDocTimeStampCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 4 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", usageTimestamp)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	ts := pdfcpu.NewTSAClient(flag.Arg(1))
+
+	filenameOut := defaultFilenameOut(filenameIn)
+
+	args := flag.Args()[2:]
+	if len(args) > 0 && strings.HasSuffix(strings.ToLower(args[0]), ".pdf") {
+		filenameOut = args[0]
+		args = args[1:]
+	}
+
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "%s\n", usageTimestamp)
+		os.Exit(1)
+	}
+
+	var fieldName string
+	if len(args) == 1 {
+		fieldName = args[0]
+	}
+
+	return api.DocTimeStampCommand(filenameIn, filenameOut, fieldName, ts, config)
+}
+
+func setupCompression(config *pdfcpu.Configuration) {
+
+	if level < -1 || level > 9 {
+		fmt.Fprintf(os.Stderr, "level must be -1 or between 0 and 9\n")
+		os.Exit(1)
+	}
+
+	config.CompressionLevel = level
+	config.RecompressStreams = recompress
+}
+
+// setupProducer replaces Producer and Creator by the values of -producer and -creator if given.
+func setupProducer(config *pdfcpu.Configuration) {
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "producer":
+			config.ProducerOverride = &producer
+		case "creator":
+			config.CreatorOverride = &creator
+		}
+	})
+}
+
+func prepareExportJSONCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageExportJSON)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensureJSONExtension(filenameOut)
+
+	return api.ExportJSONCommand(filenameIn, filenameOut, external, config)
+}
+
+func prepareImportJSONCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageImportJSON)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensureJSONExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensurePdfExtension(filenameOut)
+
+	return api.ImportJSONCommand(filenameIn, filenameOut, config)
+}
+
+func prepareDiffCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" || mode != "" && mode != "json" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageDiff)
+		os.Exit(2)
+	}
+
+	filenameA, filenameB := flag.Arg(0), flag.Arg(1)
+	ensurePdfExtension(filenameA)
+	ensurePdfExtension(filenameB)
+
+	return api.DiffCommand(filenameA, filenameB, mode == "json", config)
+}
+
+func prepareBatchCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 3 || pageSelection != "" || jobs < 1 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageBatch)
+		os.Exit(1)
+	}
+
+	names := strings.Split(flag.Arg(2), ",")
+	for _, name := range names {
+		if strings.TrimSpace(name) == "encrypt" {
+			if !validEncryptOptions() {
+				fmt.Fprintf(os.Stderr, "%s\n\n", usageBatch)
+				os.Exit(1)
+			}
+			setupEncryption(config, usageBatch)
+		}
+	}
+
+	config.StatsFileName = fileStats
+
+	bc := &api.BatchConfig{
+		Recursive:       recursive,
+		Jobs:            jobs,
+		ContinueOnError: continueOnError,
+		Context:         config.Context,
+	}
+
+	if patterns != "" {
+		for _, p := range strings.Split(patterns, ",") {
+			bc.Patterns = append(bc.Patterns, strings.TrimSpace(p))
+		}
+	}
+
+	cmds, err := api.BatchCommands(names, external, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	return api.BatchCommand(flag.Arg(0), flag.Arg(1), cmds, bc, config)
 }
@@ -39,36 +39,115 @@ The commands are:
 	changeopw	change owner password
 	stamp		add stamps
 	watermark	add watermarks
+	qrstamp		add QR code linking to a document verification URL
+	barcode		add QR code, Code 128 or EAN barcodes
+	form		list, fill, export form fields
+	annot		list, remove, flatten, export, import annotations
+	redact		remove page content for good
+	js		list, remove JavaScript
+	sanitize	remove scripts, embedded files, metadata and prior revisions
+	revisions	list, extract revisions of incrementally updated files
+	rmrights	remove usage rights of Reader enabled files
+	pdfa		convert to PDF/A-1b or PDF/A-2b
+	intent		list, add, remove, extract, replace output intents
+	linearize	write or check linearized PDF for fast web view
+	xmp		list, set XMP metadata
+	info		list, set, remove document info entries
+	keywords	list, add, remove keywords
+	properties	list, add, remove custom document properties
+	bookmarks	list, export, import, generate outline
+	dests		list, add, remove, rename named destinations
+	layers		list, show, hide, rename, remove, flatten optional content
+	viewer		list, set, remove viewer preferences and the open action
+	transitions	list, set, remove page transitions for presentations
+	portfolio	list, create, extract portfolios
+	af		list, add, validate associated files
+	geo		list, add, remove measurement and geospatial viewports
+	ocr		make scanned pages searchable by an invisible text layer
+	import-ocr	lay the words of hOCR or ALTO files over pages as invisible text
+	struct		list, export logical structure of tagged PDF
+	repair		recover damaged or truncated PDF
+	export-json	export all objects as JSON
+	import-json	create PDF from JSON export
+	diff		compare two PDF files
+	batch		apply commands to all PDF files of a directory tree
+	sign		add digital signature
+	verify		verify digital signatures
+	timestamp	add document time stamp
+	ltv		embed validation data for signatures
 	version		print version
    
 	Single-letter Unix-style supported for commands and flags.
 
+	Every command accepts -timeout duration (eg. 30s) to abort processing of pathological files.
+	Every command accepts -lazy to load stream content on demand when processing large files.
+	Every command accepts -workers n to validate and optimize n pages concurrently.
+	Every command accepts -maxmem n to fail cleanly once decoded streams exceed n MB
+	and -maxpixels n to reject images with more than n pixels.
+	Every command writing a PDF accepts -objstm to pack all non-stream objects into object streams
+	and -xref table|stream|hybrid to select the cross reference format.
+	Every command writing a PDF accepts -level n to set the zlib compression level (-1, 0..9) for Flate encoding.
+	Every command writing a PDF accepts -producer string and -creator string to replace the Producer and Creator,
+	an empty string removes them.
+
 Use "pdfcpu help [command]" for more information about a command.`
 
-	usageValidate     = "usage: pdfcpu validate [-verbose] [-mode strict|relaxed] [-upw userpw] [-opw ownerpw] inFile"
+	usageValidate     = "usage: pdfcpu validate [-verbose] [-mode strict|relaxed|pdfa1b|pdfa2b|pdfx1a|pdfx4|pdfua] [-rules rule:action,...] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile [objNr...]"
 	usageLongValidate = `Validate checks inFile for specification compliance.
 
 verbose ... extensive log output
    mode ... validation mode
+  rules ... comma separated list of rule:action pairs overriding the validation mode
+  pages ... page selection, validates the selected pages only
     upw ... user password
     opw ... owner password
  inFile ... input pdf file
+  objNr ... validate selected objects only along with all objects reachable from them
 		
 The validation modes are:
 
  strict ... (default) validates against PDF 32000-1:2008 (PDF 1.7)
-relaxed ... like strict but doesn't complain about common seen spec violations.`
+relaxed ... like strict but doesn't complain about common seen spec violations.
+ pdfa1b ... checks the rules of PDF/A-1b (ISO 19005-1) and prints a JSON report rule by rule.
+ pdfa2b ... checks the rules of PDF/A-2b (ISO 19005-2) and prints a JSON report rule by rule.
+ pdfx1a ... checks the conditions of PDF/X-1a:2001 (ISO 15930-1) and prints a JSON report rule by rule.
+  pdfx4 ... checks the conditions of PDF/X-4 (ISO 15930-7) and prints a JSON report rule by rule.
+  pdfua ... checks the accessibility requirements of PDF/UA-1 (ISO 14289-1) and prints a JSON report rule by rule.
+
+The rules cover spec violations commonly seen in the wild. Each rule may be set to one of:
+
+  error ... fail validation (default for strict)
+   warn ... print a warning and continue
+ ignore ... continue silently (default for relaxed)
+
+    version ... entries introduced by a PDF version later than the version of inFile
+   required ... missing required entries, eg. Widths of TrueType fonts
+   filespec ... file specifications of type F, second-class AFRelationship names
+       info ... document info entries not being text strings or dates
+   outlines ... cyclic or corrupted outline item lists
+destination ... FitH destinations lacking the top coordinate
+    shading ... shading BitsPerFlag values greater than 3
+ structtree ... structure parent trees not being valid number trees
+ encryption ... PDF 2.0 files not encrypted using AES-256
+
+The rules apply to the validation performed by every command.
 
-	usageOptimize     = "usage: pdfcpu optimize [-verbose] [-stats csvFile] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+e.g. pdfcpu validate -mode strict -rules 'version:warn, outlines:ignore' in.pdf
+     pdfcpu validate -pages 10-20 in.pdf
+     pdfcpu validate in.pdf 12 17`
+
+	usageOptimize     = "usage: pdfcpu optimize [-verbose] [-stats csvFile] [-recompress] [-level n] [-upw userpw] [-opw ownerpw] inFile [outFile]"
 	usageLongOptimize = `Optimize reads inFile, removes redundant page resources like embedded fonts and images and writes the result to outFile.
 
-verbose ... extensive log output
-  stats ... appends a stats line to a csv file with information about the usage of root and page entries.
-            useful for batch optimization and debugging PDFs.
-    upw ... user password
-    opw ... owner password
- inFile ... input pdf file
-outFile ... output pdf file (default: inFile-new.pdf)`
+   verbose ... extensive log output
+     stats ... appends a stats line to a csv file with information about the usage of root and page entries.
+               useful for batch optimization and debugging PDFs.
+recompress ... recompresses uncompressed and Flate encoded streams whenever this saves space.
+     level ... zlib compression level used for recompression: -1 (default) or 0 (none) to 9 (best)
+       upw ... user password
+       opw ... owner password
+    inFile ... input pdf file
+   outFile ... output pdf file (default: inFile_new.pdf)`
 
 	usageSplit     = "usage: pdfcpu split [-verbose] [-upw userpw] [-opw ownerpw] inFile outDir"
 	usageLongSplit = `Split generates a set of single page PDFs for the input file in outDir.
@@ -86,8 +165,8 @@ verbose ... extensive log output
 outFile	... output pdf file
 inFiles ... a list of at least 2 pdf files subject to concatenation.`
 
-	usageExtract     = "usage: pdfcpu extract [-verbose] -mode image|font|content|page [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile outDir"
-	usageLongExtract = `Extract exports inFile's images, fonts, content or pages into outDir.
+	usageExtract     = "usage: pdfcpu extract [-verbose] -mode image|font|content|page|media [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile outDir"
+	usageLongExtract = `Extract exports inFile's images, fonts, content, pages or media into outDir.
 
 verbose ... extensive log output
    mode ... extraction mode
@@ -102,7 +181,8 @@ verbose ... extensive log output
   image ... extract images (supported PDF filters: Flate, DCTDecode, JPXDecode)
    font ... extract font files (supported font types: TrueType)
 content ... extract raw page content
-   page ... extract single page PDFs`
+   page ... extract single page PDFs
+  media ... extract U3D/PRC streams of 3D annotations and media assets of RichMedia and Screen annotations`
 
 	usageTrim     = "usage: pdfcpu trim [-verbose] -pages pageSelection [-upw userpw] [-opw ownerpw] inFile [outFile]"
 	usageLongTrim = `Trim generates a trimmed version of inFile for selected pages.
@@ -112,7 +192,7 @@ verbose ... extensive log output
     upw ... user password
     opw ... owner password
  inFile ... input pdf file
-outFile ... output pdf file (default: inFile-new.pdf)`
+outFile ... output pdf file (default: inFile_new.pdf)`
 
 	usagePageSelection = `<pages> selects pages for processing and is a comma separated list of expressions:
 
@@ -132,26 +212,41 @@ outFile ... output pdf file (default: inFile-new.pdf)`
 e.g. -3,5,7- or 4-7,!6 or 1-,!5 or odd,n1`
 
 	usageAttachList    = "pdfcpu attach list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
-	usageAttachAdd     = "pdfcpu attach add [-verbose] [-upw userpw] [-opw ownerpw] inFile file..."
+	usageAttachAdd     = "pdfcpu attach add [-verbose] [-upw userpw] [-opw ownerpw] inFile file[,description]..."
 	usageAttachRemove  = "pdfcpu attach remove [-verbose] [-upw userpw] [-opw ownerpw] inFile [file...]"
 	usageAttachExtract = "pdfcpu attach extract [-verbose] [-upw userpw] [-opw ownerpw] inFile outDir [file...]"
+	usageAttachPage    = "pdfcpu attach page [-verbose] [-pages pageSelection] [-color #RRGGBB] [-upw userpw] [-opw ownerpw] inFile 'rect:llx lly urx ury[, author:name][, icon:name]' file [contents]"
 
 	usageAttach = "usage: " + usageAttachList +
 		"\n       " + usageAttachAdd +
 		"\n       " + usageAttachRemove +
-		"\n       " + usageAttachExtract
+		"\n       " + usageAttachExtract +
+		"\n       " + usageAttachPage
 
 	usageLongAttach = `Attach manages embedded file attachments.
 	
-verbose ... extensive log output
-   perm ... user access permissions
-    upw ... user password
-    opw ... owner password
- inFile ... input pdf file
- outDir ... output directory`
+ verbose ... extensive log output
+   pages ... page selection
+   color ... icon color
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+  outDir ... output directory
+    rect ... icon rectangle in user space
+  author ... annotation author
+    icon ... Graph, PushPin, Paperclip, Tag (default: PushPin)
+contents ... annotation text (default: file name)
+
+Embedded files carry their MIME type, size, modification date and MD5 checksum.
+List prints them along with the description, extract warns about checksum mismatches.
+Page attaches file to all selected pages by file attachment annotations sharing the embedded file.
+List, remove and extract apply to document level attachments only.
+
+e.g. pdfcpu attach add in.pdf 'invoice.xml, Invoice data' log.txt
+     pdfcpu attach page -pages 1 in.pdf 'rect:500 750 520 770, icon:Paperclip' log.txt 'Validation log'`
 
 	usagePermList = "pdfcpu perm list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
-	usagePermAdd  = "pdfcpu perm add [-verbose] [-perm none|all] [-upw userpw] -opw ownerpw inFile"
+	usagePermAdd  = "pdfcpu perm add [-verbose] [-perm none|all|perm,...] [-upw userpw] -opw ownerpw inFile"
 
 	usagePerm = "usage: " + usagePermList +
 		"\n       " + usagePermAdd
@@ -159,31 +254,54 @@ verbose ... extensive log output
 	usageLongPerm = `Perm manages user access permissions.
 	
 verbose ... extensive log output
-   perm ... user access permissions
+   perm ... user access permissions: none, all or a comma separated list of
+            print ......... print (in degraded quality unless printhighres)
+            printhighres .. print in full quality
+            modify ........ modify other than annotate, fillforms and assemble
+            copy .......... copy or extract text and graphics
+            annotate ...... add or modify annotations, fill in form fields
+            fillforms ..... fill in existing form fields
+            accessibility . extract text and graphics for accessibility
+            assemble ...... insert, rotate or delete pages, create bookmarks
     upw ... user password
     opw ... owner password
- inFile ... input pdf file`
+ inFile ... input pdf file
+
+e.g. pdfcpu perm add -perm print,printhighres,fillforms -opw secret in.pdf`
 
-	usageEncrypt     = "usage: pdfcpu encrypt [-verbose] [-mode rc4|aes] [-key 40|128] [perm none|all] [-upw userpw] [-opw ownerpw] inFile [outFile]"
-	usageLongEncrypt = `Encrypt sets a password protection based on user and owner password.
+	usageEncrypt     = "usage: pdfcpu encrypt [-verbose] [-mode rc4|aes] [-key 40|128|256] [-perm none|all|perm,...] [-upw userpw] [-opw ownerpw] [-cert certFile,...] inFile [outFile]"
+	usageLongEncrypt = `Encrypt sets a password protection based on user and owner password
+or encrypts for a set of recipient certificates.
 
 verbose ... extensive log output
    mode ... algorithm (default=aes)
     key ... key length in bits (default=128)
-   perm ... user access permissions
+   perm ... user access permissions: none, all or a comma separated list (see pdfcpu help perm)
     upw ... user password
     opw ... owner password
+   cert ... comma separated list of PEM files holding recipient certificates
  inFile ... input pdf file
-outFile ... output pdf file`
+outFile ... output pdf file
 
-	usageDecrypt     = "usage: pdfcpu decrypt [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
-	usageLongDecrypt = `Decrypt removes a password protection.
+A 256 bit key implies AES-256 as defined by PDF 2.0 and supported by Acrobat X and later.
+Passwords may contain Unicode characters in this case.
+
+Using cert the document gets encrypted by the public-key security handler using AES-256.
+Each recipient opens it with the private key belonging to its RSA certificate, see decrypt.
+Passwords and key length are ignored in this case.`
+
+	usageDecrypt     = "usage: pdfcpu decrypt [-verbose] [-upw userpw] [-opw ownerpw] [-cert certFile -privkey keyFile] inFile [outFile]"
+	usageLongDecrypt = `Decrypt removes a password protection or certificate based encryption.
 
 verbose ... extensive log output
     upw ... user password
     opw ... owner password
+   cert ... PEM file holding the recipient certificate
+privkey ... PEM file holding the RSA private key belonging to cert
  inFile ... input pdf file
-outFile ... output pdf file`
+outFile ... output pdf file
+
+cert and privkey also open documents encrypted for certificate recipients with any other command.`
 
 	usageChangeUserPW     = "usage: pdfcpu changeupw [-verbose] [-opw ownerpw] inFile upwOld upwNew"
 	usageLongChangeUserPW = `Changeupw changes the user password.
@@ -207,6 +325,13 @@ verbose ... extensive log output
 	
     1st entry: display text string or image file name with extension png
 
+               The text may contain variables resolved for each page:
+
+                   %p ... page number          %d ... date (yyyy-mm-dd)
+                   %P ... page count           %t ... time (hh:mm)
+                   %f ... input file name      %u{name} ... user variable defined by v:name=value
+                   %% ... %
+
     optional entries:
 	
          (defaults: 'f:Helvetica, p:24, s:0.5 rel, c:0.5 0.5 0.5, d:1, o:1, m:0')
@@ -217,40 +342,1096 @@ verbose ... extensive log output
       c: color: 3 fill color intensities, where 0.0 < i < 1.0, eg 1.0, 0.0 0.0 = red (default:0.5 0.5 0.5 = gray)
       r: rotation, where -180.0 <= x <= 180.0
       d: render along diagonal, 1..lower left to upper right, 2..upper left to lower right
-      o: opacity, where 0.0 <= x <= 1.0
+      o: opacity, where 0.0 <= x <= 1.0 followed by an optional stroke opacity
       m: render mode: 0 ... fill
                       1 ... stroke
                       2 ... fill & stroke
+    pos: position: tl, tc, tr, l, c, r, bl, bc, br (default: c)
+    off: offset from position: dx dy in user space units, eg. 10 -10
+     bm: blend mode: Normal, Multiply, Screen, Overlay, Darken, Lighten, ColorDodge, ColorBurn,
+                     HardLight, SoftLight, Difference, Exclusion, Hue, Saturation, Color, Luminosity
+   tile: repeat across the page along the rotation or diagonal, followed by the horizontal
+         and an optional vertical gap between tiles in user space units, eg. 20 or 20 40
+      v: user variable: name=value, may be repeated
 
     Only one of rotation and diagonal is allowed.
 
 e.g. 'Draft'                                                  'logo.png'
      'Draft, d:2'                                             'logo.png, o:0,5, s:0.5 abs, r:0'
      'Intentionally left blank, p:48'
-     'Confidental, f:Courier, s:0.75, c: 0.5 0.0 0.0, r:20'`
+     'Confidental, f:Courier, s:0.75, c: 0.5 0.0 0.0, r:20'
+     'Draft, s:0.2, r:30, o:0.3 0.6, m:2, bm:Multiply, tile:40 60'
+     'Page %p of %P, pos:bc, s:0.2, r:0'
+     '%u{dept} %f %d, v:dept=Sales, pos:tl, s:0.3, r:0'`
 
-	usageStamp     = "usage: pdfcpu stamp [-verbose] -pages pageSelection description inFile [outFile]"
+	usageStamp = "usage: pdfcpu stamp [-verbose] [-tag] -pages pageSelection description [-pages pageSelection description ...] inFile [outFile]" +
+		"\n       pdfcpu stamp update [-verbose] [-tag] -pages pageSelection description [-pages pageSelection description ...] inFile [outFile]" +
+		"\n       pdfcpu stamp remove [-verbose] [-pages pageSelection] inFile [outFile]"
 	usageLongStamp = `Stamp adds stamps for selected pages. 
 
     verbose ... extensive log output
+        tag ... add the stamps to the structure tree (P for text, Figure for images)
       pages ... page selection
 description ... font, text, color, rotation
      inFile ... input pdf file
-    outFile ... output pdf file (default: inFile-new.pdf)
+    outFile ... output pdf file (default: inFile_new.pdf)
+
+Further pairs of page selection and description apply their own stamps in the same pass,
+e.g. -pages 1-10 'Draft' -pages 11- 'Confidential' in.pdf out.pdf
+
+update replaces the stamps added by pdfcpu to the selected pages, remove removes them.
+Only stamps added by this version of pdfcpu or later are recognized.
 
 ` + usageWMDescription
 
-	usageWatermark     = "usage: pdfcpu watermark [-verbose] -pages pageSelection description inFile [outFile]"
+	usageWatermark = "usage: pdfcpu watermark [-verbose] -pages pageSelection description [-pages pageSelection description ...] inFile [outFile]" +
+		"\n       pdfcpu watermark update [-verbose] -pages pageSelection description [-pages pageSelection description ...] inFile [outFile]" +
+		"\n       pdfcpu watermark remove [-verbose] [-pages pageSelection] inFile [outFile]"
 	usageLongWatermark = `Watermark adds watermarks for selected pages. 
 
     verbose ... extensive log output
       pages ... page selection
 description ... font, text, color, rotation
      inFile ... input pdf file
-    outFile ... output pdf file (default: inFile-new.pdf)
+    outFile ... output pdf file (default: inFile_new.pdf)
+
+Further pairs of page selection and description apply their own watermarks in the same pass,
+e.g. -pages 1-10 'Draft' -pages 11- 'Confidential' in.pdf out.pdf
+
+update replaces the watermarks added by pdfcpu to the selected pages, remove removes them.
+Only watermarks added by this version of pdfcpu or later are recognized.
 
 ` + usageWMDescription
 
+	usageQRStamp     = "usage: pdfcpu qrstamp [-verbose] [-tag] -pages pageSelection description inFile [outFile]"
+	usageLongQRStamp = `QRStamp writes inFile to outFile, calculates the SHA-256 hash of the result
+and stamps selected pages of outFile with a QR code linking to a verification URL containing this hash.
+The hash and the verification URL are printed for registration with the verification service.
+The stamps are appended as an incremental update, so the hash covers revision 1 of outFile:
+recompute it using 'pdfcpu revisions extract outFile 1 rev1.pdf' and hashing rev1.pdf, eg. with sha256sum.
+
+    verbose ... extensive log output
+        tag ... add the QR codes to the structure tree as Figure
+      pages ... page selection
+description ... verification URL template, scaling, rotation, opacity
+     inFile ... input pdf file
+    outFile ... output pdf file (default: inFile_new.pdf)
+
+<description> is a comma separated configuration string containing:
+
+    1st entry: verification URL template containing the placeholder {hash}
+
+    optional entries:
+
+         (defaults: 's:0.2 rel, r:0, o:1')
+
+      s: scale factor, 0.0 <= x <= 1.0 followed by optional 'abs|rel'
+      r: rotation, where -180.0 <= x <= 180.0
+      d: render along diagonal, 1..lower left to upper right, 2..upper left to lower right
+      o: opacity, where 0.0 <= x <= 1.0
+    pos: position: tl, tc, tr, l, c, r, bl, bc, br (default: c)
+    off: offset from position: dx dy in user space units, eg. 10 -10
+
+e.g. 'https://example.com/verify?sha256={hash}'
+     'https://example.com/verify/{hash}, s:0.3'`
+
+	usageBarcode     = "usage: pdfcpu barcode [-verbose] [-tag] -pages pageSelection description inFile [outFile]"
+	usageLongBarcode = `Barcode stamps selected pages with a QR code, Code 128 or EAN barcode painted as vector graphics.
+
+    verbose ... extensive log output
+        tag ... add the barcodes to the structure tree as Figure
+      pages ... page selection
+description ... barcode type and data, position, scaling, rotation, color, opacity
+     inFile ... input pdf file
+    outFile ... output pdf file (default: inFile_new.pdf)
+
+<description> is a comma separated configuration string containing:
+
+    1st entry: type:data
+
+         type: qr, code128 (ASCII), ean13 (12 or 13 digits), ean8 (7 or 8 digits)
+         data: may contain the variables %p (page number), %P (page count), %f (input file name),
+               %d (date), %t (time), %u{name} (user variable), see pdfcpu help stamp
+
+    optional entries:
+
+         (defaults: 'pos:br, s:0.2 rel, r:0, c:0 0 0, o:1')
+
+    pos: position: tl, tc, tr, l, c, r, bl, bc, br
+    off: offset from position: dx dy in user space units, eg. 10 -10
+      s: scale factor, 0.0 <= x <= 1.0 followed by optional 'abs|rel', abs: x points per module
+      r: rotation, where -180.0 <= x <= 180.0
+      c: color of the bars: 3 intensities, where 0.0 < i < 1.0
+      o: opacity, where 0.0 <= x <= 1.0
+      v: user variable: name=value, may be repeated
+
+e.g. 'qr:https://example.com/track/4711?page=%p'
+     'code128:INV-4711 %p/%P, pos:tl, off:20 -20'
+     'ean13:400638133393, pos:bc, s:1 abs'`
+
+	usageRedact     = "usage: pdfcpu redact [-verbose] [-pages pageSelection] [-color #RRGGBB] [-upw userpw] [-opw ownerpw] inFile [outFile] ['rect:llx lly urx ury'|term...]"
+	usageLongRedact = `Redact applies the Redact annotations of selected pages along with the given areas and writes the result to outFile.
+Text, images and vector graphics within redacted areas are removed from the document and the areas are covered by opaque boxes.
+Annotations overlapping an area are removed, so are form fields having a widget overlapping an area.
+
+verbose ... extensive log output
+  pages ... page selection
+  color ... box color for areas and Redact annotations lacking an interior color (default: #000000)
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output pdf file (default: inFile_new.pdf)
+   rect ... area in user space redacted on all selected pages
+   term ... text to be redacted on all selected pages ignoring case
+
+Glyphs touching an area are removed. Images and paths touching an area are removed as a whole
+unless a path encloses the area like a background or frame.
+
+e.g. pdfcpu redact -pages 1 in.pdf out.pdf 'rect:50 700 300 720' 'John Doe'`
+
+	usageFormList          = "pdfcpu form list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageFormFill          = "pdfcpu form fill [-verbose] [-needappearances] [-upw userpw] [-opw ownerpw] inFile dataFile [outFile]"
+	usageFormExport        = "pdfcpu form export [-verbose] [-upw userpw] [-opw ownerpw] inFile dataFile"
+	usageFormMultiFill     = "pdfcpu form multifill [-verbose] [-needappearances] [-mode single|merge] [-name column] [-map 'field:column, ...'] [-upw userpw] [-opw ownerpw] inFile csvFile outDir|outFile"
+	usageFormAppearances   = "pdfcpu form appearances [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageFormOrder         = "pdfcpu form order [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageFormTabs          = "pdfcpu form tabs [-verbose] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile [outFile] [R|C|S] [field...]"
+	usageFormCalcOrder     = "pdfcpu form calcorder [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile] [field...]"
+	usageFormSignature     = "pdfcpu form signature [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile] 'name:field[, page:n][, rect:llx lly urx ury][, label:text][, tooltip:text]'"
+	usageFormReset         = "pdfcpu form reset [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile] [field...]"
+	usageFormOptions       = "pdfcpu form options [-verbose] [-upw userpw] [-opw ownerpw] inFile field"
+	usageFormAddOptions    = "pdfcpu form addoptions [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile] field option..."
+	usageFormRemoveOptions = "pdfcpu form removeoptions [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile] field value..."
+	usageFormSetOptions    = "pdfcpu form setoptions [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile] field [option...]"
+
+	usageForm = "usage: " + usageFormList +
+		"\n       " + usageFormFill +
+		"\n       " + usageFormExport +
+		"\n       " + usageFormMultiFill +
+		"\n       " + usageFormAppearances +
+		"\n       " + usageFormOrder +
+		"\n       " + usageFormTabs +
+		"\n       " + usageFormCalcOrder +
+		"\n       " + usageFormSignature +
+		"\n       " + usageFormReset +
+		"\n       " + usageFormOptions +
+		"\n       " + usageFormAddOptions +
+		"\n       " + usageFormRemoveOptions +
+		"\n       " + usageFormSetOptions
+
+	usageLongForm = `Form manages interactive form fields.
+
+           list ... print all fields with name, type, value, default value, flags, page and rect as JSON.
+           fill ... set field values and regenerate their appearances.
+         export ... write field values to dataFile (.fdf, .xfdf, .json or .csv).
+      multifill ... fill the form once for every record of csvFile.
+    appearances ... regenerate field appearances according to the field values.
+          order ... print the tab order of all pages with fields and the calculation order as JSON.
+           tabs ... set the tab order of selected pages and move the widgets of the given fields to the front.
+      calcorder ... set the order of field calculations.
+      signature ... add an unsigned signature field.
+          reset ... restore the default values of fields.
+        options ... print the options of a combo box or list box as JSON.
+     addoptions ... append options to a combo box or list box.
+  removeoptions ... remove options from a combo box or list box.
+     setoptions ... replace the options of a combo box or list box, e.g. to reorder them.
+
+        verbose ... extensive log output
+needappearances ... ask viewers to regenerate all field appearances
+           mode ... multifill: single ... write one file per record into outDir (default)
+                               merge ... write all filled copies into outFile
+           name ... multifill: column providing the names of the filled copies (default: record number)
+            map ... multifill: comma separated list of field:column pairs (default: columns named after fields)
+          pages ... tabs: page selection
+            upw ... user password
+            opw ... owner password
+         inFile ... input pdf file
+       dataFile ... form data (.json, .fdf or .xfdf)
+        csvFile ... form data with a header line of column names and one line per filled copy
+         outDir ... output directory
+        outFile ... output pdf file (default: inFile for tabs, calcorder, signature, reset and options, inFile_new.pdf otherwise)
+          R|C|S ... tab order by rows, columns or document structure
+          field ... fully qualified field name
+         option ... export value optionally followed by =display text, e.g. DE=Germany
+          value ... export value
+
+Form data may be exchanged in FDF or XFDF format as used by Acrobat.
+Export writes FDF, XFDF, JSON or CSV depending on the extension of dataFile.
+JSON export produces an object mapping field names to values suitable for "pdfcpu form fill".
+CSV export produces a header line with the field names followed by a line with the values.
+Multifill accepts this format extended by one line per filled copy.
+Merged copies keep their fields below a new top level field named after the copy, e.g. "Joe.name".
+Appearances renders text and choice fields honoring font, size and color of their default appearance, quadding,
+multiline and comb flags and sets checkbox and radio button states. Viewers then display the form alike
+without regenerating appearances, which is why any NeedAppearances request is removed.
+
+Tabs with fields reorders the widget annotations, which determines the tab order of pages without R, C or S.
+Calcorder without fields removes the calculation order.
+Signature adds an empty signature field on page n (default: 1) to be signed later by pdfcpu or other tools.
+Without rect the field is invisible, otherwise its widget shows a border and a signature line with an optional label below.
+Reset without fields resets the whole form, a field also selects its descendants. Push buttons and signatures are kept.
+Changing options deselects values no longer available unless the field is an editable combo box.
+
+A JSON <dataFile> contains either the output of "pdfcpu form list" or an object mapping fully qualified field names to values:
+
+    text fields ... string
+     checkboxes ... true|false
+  radio buttons ... name of the selected button
+    combo boxes ... string
+     list boxes ... string or array of strings for multiple selections
+
+e.g. {"name": "Joe", "married": true, "gender": "male", "languages": ["English", "German"]}`
+
+	usageAnnotList    = "pdfcpu annot list [-verbose] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile"
+	usageAnnotRemove  = "pdfcpu annot remove [-verbose] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile [outFile] [subtype|objNr...]"
+	usageAnnotFlatten = "pdfcpu annot flatten [-verbose] [-tag] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile [outFile] [subtype|objNr...]"
+
+	usageAnnotExport = "pdfcpu annot export [-verbose] [-upw userpw] [-opw ownerpw] inFile xfdfFile"
+	usageAnnotImport = "pdfcpu annot import [-verbose] [-upw userpw] [-opw ownerpw] inFile xfdfFile [outFile]"
+	usageAnnotLink   = "pdfcpu annot link [-verbose] [-tag] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile [outFile] ['target, rect:llx lly urx ury[, zoom:percent]']"
+	usageAnnotMarkup = "pdfcpu annot markup [-verbose] [-pages pageSelection] [-mode highlight|underline|strikeout|squiggly] [-color #RRGGBB] [-upw userpw] [-opw ownerpw] inFile [outFile] query"
+	usageAnnotNote   = "pdfcpu annot note [-verbose] [-pages pageSelection] [-color #RRGGBB] [-upw userpw] [-opw ownerpw] inFile [outFile] 'rect:llx lly urx ury[, author:name][, icon:name]' contents"
+	usageAnnotFree   = "pdfcpu annot freetext [-verbose] [-pages pageSelection] [-color #RRGGBB] [-upw userpw] [-opw ownerpw] inFile [outFile] 'rect:llx lly urx ury[, author:name][, size:fontSize]' contents"
+
+	usageAnnot = "usage: " + usageAnnotList +
+		"\n       " + usageAnnotRemove +
+		"\n       " + usageAnnotFlatten +
+		"\n       " + usageAnnotExport +
+		"\n       " + usageAnnotImport +
+		"\n       " + usageAnnotLink +
+		"\n       " + usageAnnotMarkup +
+		"\n       " + usageAnnotNote +
+		"\n       " + usageAnnotFree
+
+	usageLongAnnot = `Annot manages annotations.
+
+    list ... print all annotations with page, subtype, rect, contents, author, modification date, object number and media assets as JSON.
+  remove ... remove annotations matching any given subtype or object number.
+ flatten ... draw annotations matching any given subtype or object number into the page content and remove them.
+  export ... write markup annotations to xfdfFile.
+  import ... add markup annotations from xfdfFile.
+    link ... add a link or turn the URLs found in the page text into links.
+  markup ... highlight, underline, strike out or squiggly underline all occurrences of query in the page text.
+    note ... add a sticky note.
+freetext ... add a free text annotation.
+
+ verbose ... extensive log output
+     tag ... flatten, link: add the generated content to the structure tree
+   pages ... page selection
+    mode ... markup annotation type (default: highlight)
+   color ... markup color (default: #FFFF00 for highlight, #FF0000 otherwise), note icon color, free text and border color (default: black)
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+xfdfFile ... XFDF file
+ outFile ... output pdf file (default: inFile for remove, flatten, link, markup, note and freetext, inFile_new.pdf for import)
+ subtype ... annotation subtype, e.g. Text, Popup, Link, Highlight
+   objNr ... object number as printed by "pdfcpu annot list"
+  target ... URI or destination page number
+    rect ... link, note icon or free text rectangle in user space
+  author ... note author
+    icon ... Comment, Key, Note, Help, NewParagraph, Paragraph, Insert (default: Note)
+    size ... free text font size (default: 12)
+contents ... note text, use \n for line breaks
+    zoom ... magnification of the destination page in percent (default: keep current zoom)
+   query ... text to search for ignoring case
+
+Remove and flatten without subtypes and object numbers process all annotations except form field widgets.
+The popup of a removed annotation is removed as well.
+Flatten leaves hidden annotations and annotations without appearance stream untouched.
+With -tag flattened annotations keep their structure element or become a Figure described by their contents.
+
+Export and import support text, free text, square, circle, highlight, underline, strikeout, squiggly, stamp and ink annotations
+along with their popups. Import replaces existing annotations with the same name and creates appearances for text markup and ink.
+
+Link adds the given link to all selected pages. Without link details the URLs found in the text of selected pages
+become links unless they are already covered by a link.
+With -tag links become Link structure elements.
+
+Markup finds occurrences of query within a single line of text only.
+
+Note and freetext add the annotation to all selected pages. Free text is set in Helvetica and wrapped to fit rect.
+
+e.g. pdfcpu annot remove in.pdf out.pdf Text Popup
+     pdfcpu annot link -pages 1 in.pdf out.pdf '3, rect:50 700 150 720, zoom:150'
+     pdfcpu annot markup -mode underline -color #0000FF in.pdf out.pdf 'Go'
+     pdfcpu annot note -pages 2 -color #FF0000 in.pdf 'rect:500 750 520 770, author:QA, icon:Comment' 'Missing logo'
+     pdfcpu annot freetext -pages 1 in.pdf out.pdf 'rect:50 50 250 100, size:10' 'Checked by QA'`
+
+	usageSanitize     = "usage: pdfcpu sanitize [-verbose] [-external] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongSanitize = `Sanitize removes hidden risks from inFile in one pass and reports what was removed:
+
+  - JavaScript (document level scripts and JavaScript actions)
+  - XFA forms
+  - embedded files and file attachment annotations
+  - document info and XMP metadata
+  - page thumbnails
+  - prior revisions (incremental updates), which also invalidates any signatures
+
+ verbose ... extensive log output
+external ... also remove actions referring to external resources (GoToE, GoToR, ImportData, Launch, SubmitForm, URI)
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file (default: inFile)
+
+e.g. pdfcpu sanitize in.pdf out.pdf
+     pdfcpu sanitize -external in.pdf`
+
+	usageRemoveUsageRights     = "usage: pdfcpu rmrights [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongRemoveUsageRights = `Rmrights removes the usage rights signatures (UR3) of a Reader enabled inFile.
+
+Usage rights enable features like saving filled in forms in viewers which would not offer them otherwise.
+Once such a file has been modified by any other software its usage rights signature breaks
+and viewers complain about invalidated rights. Removing the usage rights gets rid of the warning.
+The permissions dict is removed along with them unless it holds a certification signature (DocMDP).
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file (default: inFile)
+
+e.g. pdfcpu rmrights form.pdf formOut.pdf`
+
+	usageConvertToPDFA     = "usage: pdfcpu pdfa [-verbose] [-mode 1b|2b] [-fonts dir] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongConvertToPDFA = `Pdfa fixes the PDF/A violations of inFile as far as possible.
+
+Encryption, JavaScript and other forbidden actions are removed, LZW compressed streams recompressed,
+annotations made printable, an sRGB output intent added and conformant XMP metadata synthesized
+from the document info. Fonts not embedded are embedded using TrueType fonts named after the font,
+e.g. Helvetica.ttf or Arial-Bold.ttf for Arial,Bold, found in the fonts directory.
+Anything that cannot be fixed is reported along with the violations remaining in outFile.
+
+ verbose ... extensive log output
+    mode ... conformance level: 1b or 2b (default)
+   fonts ... directory of TrueType fonts
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file (default: inFile)
+
+e.g. pdfcpu pdfa -mode 1b -fonts /usr/share/fonts/truetype in.pdf out.pdf`
+
+	usageOutputIntentList    = "pdfcpu intent list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageOutputIntentAdd     = "pdfcpu intent add [-verbose] [-mode pdfx|pdfa] [-upw userpw] [-opw ownerpw] inFile iccFile [outFile]"
+	usageOutputIntentRemove  = "pdfcpu intent remove [-verbose] [-mode pdfx|pdfa] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageOutputIntentExtract = "pdfcpu intent extract [-verbose] [-mode pdfx|pdfa] [-upw userpw] [-opw ownerpw] inFile iccFile"
+	usageOutputIntentReplace = "pdfcpu intent replace [-verbose] [-mode pdfx|pdfa] [-upw userpw] [-opw ownerpw] inFile iccFile [outFile]"
+
+	usageOutputIntent = "usage: " + usageOutputIntentList +
+		"\n       " + usageOutputIntentAdd +
+		"\n       " + usageOutputIntentRemove +
+		"\n       " + usageOutputIntentExtract +
+		"\n       " + usageOutputIntentReplace
+
+	usageLongOutputIntent = `Intent manages output intents describing the intended printing condition.
+
+   list ... print output intents along with their ICC profiles.
+    add ... embed an ICC profile as output intent replacing an existing output intent of the same kind.
+            The output condition identifier is taken from the profile description.
+ remove ... remove the output intent for mode or all output intents if no mode is given.
+extract ... write the ICC profile of the output intent for mode to iccFile.
+replace ... replace the ICC profile of the output intent for mode keeping the output condition identifier.
+
+ verbose ... extensive log output
+    mode ... output intent for PDF/X (default) or PDF/A
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ iccFile ... ICC profile, eg. an output profile for PDF/X or sRGB for PDF/A
+ outFile ... output pdf file (default: inFile)
+
+e.g. pdfcpu intent add in.pdf ISOcoated_v2_eci.icc out.pdf
+     pdfcpu intent extract -mode pdfa in.pdf sRGB.icc`
+
+	usageLinearize     = "usage: pdfcpu linearize [-verbose] [-mode check] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongLinearize = `Linearize writes inFile as linearized PDF (aka "fast web view") so a viewer can display
+the first page before the whole file has been downloaded.
+With mode check inFile is checked for valid linearization.
+An incremental update appended to a linearized file breaks linearization.
+
+ verbose ... extensive log output
+    mode ... check linearization of inFile
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file (default: inFile_new.pdf)
+
+e.g. pdfcpu linearize in.pdf out.pdf
+     pdfcpu linearize -mode check out.pdf`
+
+	usageXMPList = "pdfcpu xmp list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageXMPSet  = "pdfcpu xmp set [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile name=value..."
+
+	usageXMP = "usage: " + usageXMPList +
+		"\n       " + usageXMPSet
+
+	usageLongXMP = `XMP manages the XMP metadata of inFile.
+Properties are named by prefix and local name like dc:title, dc:creator, pdf:Keywords or xmp:CreatorTool.
+Setting a property also updates the corresponding document info entry, an empty value removes it.
+On write pdfcpu keeps the document info dict and the XMP metadata in sync.
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file
+    name ... property name
+   value ... property value
+
+e.g. pdfcpu xmp list in.pdf
+     pdfcpu xmp set in.pdf out.pdf "dc:title=Annual Report" "dc:creator=Jane Doe"`
+
+	usageInfoList   = "pdfcpu info list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageInfoSet    = "pdfcpu info set [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile key=value..."
+	usageInfoRemove = "pdfcpu info remove [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile key..."
+
+	usageInfo = "usage: " + usageInfoList +
+		"\n       " + usageInfoSet +
+		"\n       " + usageInfoRemove
+
+	usageLongInfo = `Info manages the document info dict of inFile.
+Keys are Title, Author, Subject, Keywords, Creator, CreationDate, Trapped or any custom key.
+Dates are given like D:20180101120000 or 2018-01-01T12:00:00Z, Trapped takes True, False or Unknown.
+Setting an empty value removes an entry. On write pdfcpu keeps the document info dict and the XMP metadata in sync.
+Producer and ModDate are set on write, use -producer to replace or remove the Producer.
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file
+     key ... document info key
+   value ... entry value
+
+e.g. pdfcpu info list in.pdf
+     pdfcpu info set in.pdf out.pdf "Title=Annual Report" "Author=Jane Doe" "Department=Finance"
+     pdfcpu info remove in.pdf out.pdf Keywords Department
+     pdfcpu info set -producer "" -creator "Report Generator" in.pdf out.pdf "Title=Annual Report"`
+
+	usageKeywordsList   = "pdfcpu keywords list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageKeywordsAdd    = "pdfcpu keywords add [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile keyword..."
+	usageKeywordsRemove = "pdfcpu keywords remove [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile [keyword...]"
+
+	usageKeywords = "usage: " + usageKeywordsList +
+		"\n       " + usageKeywordsAdd +
+		"\n       " + usageKeywordsRemove
+
+	usageLongKeywords = `Keywords manages the keywords of inFile.
+Keywords are kept in the document info dict and in the XMP metadata as pdf:Keywords and dc:subject.
+Remove without keywords removes all keywords.
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file
+ keyword ... keyword, must not contain commas or semicolons
+
+e.g. pdfcpu keywords list in.pdf
+     pdfcpu keywords add in.pdf out.pdf invoice "fiscal year 2018"
+     pdfcpu keywords remove in.pdf out.pdf invoice`
+
+	usagePropertiesList   = "pdfcpu properties list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usagePropertiesAdd    = "pdfcpu properties add [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile name=value..."
+	usagePropertiesRemove = "pdfcpu properties remove [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile [name...]"
+
+	usageProperties = "usage: " + usagePropertiesList +
+		"\n       " + usagePropertiesAdd +
+		"\n       " + usagePropertiesRemove
+
+	usageLongProperties = `Properties manages the custom document properties of inFile.
+Custom properties are document info entries other than Title, Author, Subject, Keywords, Creator, Producer,
+CreationDate, ModDate and Trapped. They are mirrored into the XMP metadata using the pdfx namespace.
+Names start with a letter or underscore followed by letters, digits, underscores, hyphens or dots.
+Remove without names removes all custom properties.
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+ outFile ... output pdf file
+    name ... property name
+   value ... property value
+
+e.g. pdfcpu properties list in.pdf
+     pdfcpu properties add in.pdf out.pdf DocumentNumber=4711 "Department=Finance"
+     pdfcpu properties remove in.pdf out.pdf Department`
+
+	usageBookmarksList     = "pdfcpu bookmarks list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageBookmarksExport   = "pdfcpu bookmarks export [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile"
+	usageBookmarksImport   = "pdfcpu bookmarks import [-verbose] [-upw userpw] [-opw ownerpw] inFile bookmarkFile outFile"
+	usageBookmarksGenerate = "pdfcpu bookmarks generate [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile [n]"
+
+	usageBookmarks = "usage: " + usageBookmarksList +
+		"\n       " + usageBookmarksExport +
+		"\n       " + usageBookmarksImport +
+		"\n       " + usageBookmarksGenerate
+
+	usageLongBookmarks = `Bookmarks manages the outline of inFile.
+
+    list ... print the outline.
+  export ... write the outline as JSON or YAML.
+  import ... replace the outline by the bookmarks of a JSON or YAML file, an empty list removes the outline.
+generate ... replace the outline by a bookmark for every n-th page.
+
+     verbose ... extensive log output
+         upw ... user password
+         opw ... owner password
+      inFile ... input pdf file
+     outFile ... output file, for export the extension selects the format: .json, .yaml or .yml
+bookmarkFile ... bookmarks as written by export
+           n ... bookmark interval in pages, defaults to 1
+
+A bookmark has a title and jumps to a page, a named destination (dest) or an URI.
+The optional view of a page destination is one of XYZ left top zoom, Fit, FitH top, FitV left,
+FitR left bottom right top, FitB, FitBH top, FitBV left. Use null to keep the current value.
+Bookmarks may be bold, italic, colored (#RRGGBB) and open, showing their kids initially.
+
+e.g. pdfcpu bookmarks list in.pdf
+     pdfcpu bookmarks export in.pdf bookmarks.yaml
+     pdfcpu bookmarks import in.pdf bookmarks.yaml out.pdf
+     pdfcpu bookmarks generate in.pdf out.pdf 10
+
+     - title: "Chapter 1"
+       page: 1
+       bold: true
+       open: true
+       kids:
+         - title: "Section 1.1"
+           page: 2
+           view: "XYZ 0 792 null"`
+
+	usageDestsList   = "pdfcpu dests list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageDestsAdd    = "pdfcpu dests add [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile name page [view]"
+	usageDestsRemove = "pdfcpu dests remove [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile name..."
+	usageDestsRename = "pdfcpu dests rename [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile oldName newName"
+
+	usageDests = "usage: " + usageDestsList +
+		"\n       " + usageDestsAdd +
+		"\n       " + usageDestsRemove +
+		"\n       " + usageDestsRename
+
+	usageLongDests = `Dests manages the named destinations of inFile kept in the Dests name tree or the Dests dict of the catalog.
+
+  list ... print the named destinations along with their page and view.
+   add ... add a named destination to the Dests name tree.
+remove ... remove named destinations, links and outline items referring to them jump to the explicit destination instead.
+rename ... rename a named destination and update the links and outline items referring to it.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output pdf file
+   name ... destination name
+   page ... destination page
+   view ... one of XYZ left top zoom, Fit, FitH top, FitV left, FitR left bottom right top, FitB, FitBH top, FitBV left
+            use null to keep the current value, defaults to "XYZ null null null"
+
+e.g. pdfcpu dests list in.pdf
+     pdfcpu dests add in.pdf out.pdf appendix 12 "FitH 800"
+     pdfcpu dests remove in.pdf out.pdf appendix
+     pdfcpu dests rename in.pdf out.pdf chapter1 introduction`
+
+	usageLayersList    = "pdfcpu layers list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageLayersShow    = "pdfcpu layers show [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile name..."
+	usageLayersHide    = "pdfcpu layers hide [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile name..."
+	usageLayersRename  = "pdfcpu layers rename [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile oldName newName"
+	usageLayersRemove  = "pdfcpu layers remove [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile name..."
+	usageLayersFlatten = "pdfcpu layers flatten [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile"
+
+	usageLayers = "usage: " + usageLayersList +
+		"\n       " + usageLayersShow +
+		"\n       " + usageLayersHide +
+		"\n       " + usageLayersRename +
+		"\n       " + usageLayersRemove +
+		"\n       " + usageLayersFlatten
+
+	usageLongLayers = `Layers manages the optional content groups of inFile.
+
+   list ... print the layers along with their initial visibility and the membership dicts combining layers.
+   show ... make layers visible initially.
+   hide ... hide layers initially.
+ rename ... rename a layer.
+ remove ... remove layers along with their content.
+flatten ... turn the content of visible layers into unconditional content and remove hidden content.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output pdf file
+   name ... layer name, applies to all layers of that name
+
+e.g. pdfcpu layers list in.pdf
+     pdfcpu layers hide in.pdf out.pdf Watermark
+     pdfcpu layers remove in.pdf out.pdf "Construction Notes"
+     pdfcpu layers flatten in.pdf out.pdf`
+
+	usageViewerList   = "pdfcpu viewer list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageViewerSet    = "pdfcpu viewer set [-verbose] [-upw userpw] [-opw ownerpw] [-layout l] [-pagemode m] [-duplex d] [-fitwindow] [-hidetoolbar] inFile outFile"
+	usageViewerOpen   = "pdfcpu viewer open [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile page [view]"
+	usageViewerRemove = "pdfcpu viewer remove [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile key..."
+
+	usageViewer = "usage: " + usageViewerList +
+		"\n       " + usageViewerSet +
+		"\n       " + usageViewerOpen +
+		"\n       " + usageViewerRemove
+
+	usageLongViewer = `Viewer manages how inFile gets presented when opened.
+
+  list ... print the page layout, page mode, viewer preferences and the open action.
+   set ... set page layout, page mode or viewer preferences.
+  open ... open at page using view.
+remove ... remove viewer preferences, remove OpenAction to get rid of actions performed on opening.
+
+    verbose ... extensive log output
+        upw ... user password
+        opw ... owner password
+     layout ... SinglePage, OneColumn, TwoColumnLeft, TwoColumnRight, TwoPageLeft, TwoPageRight
+   pagemode ... UseNone, UseOutlines, UseThumbs, FullScreen, UseOC, UseAttachments
+     duplex ... Simplex, DuplexFlipShortEdge, DuplexFlipLongEdge
+  fitwindow ... resize the window to fit the first page, -fitwindow=false resets
+hidetoolbar ... hide the toolbar, -hidetoolbar=false resets
+     inFile ... input pdf file
+    outFile ... output pdf file
+       page ... page number
+       view ... destination type and parameters, defaults to "XYZ null null null", eg. "Fit" or "XYZ null null 1.5" for 150%
+        key ... PageLayout, PageMode, OpenAction, Duplex, HideToolbar, HideMenubar, HideWindowUI, FitWindow, CenterWindow, DisplayDocTitle
+
+e.g. pdfcpu viewer set -layout TwoColumnLeft -fitwindow in.pdf out.pdf
+     pdfcpu viewer open in.pdf out.pdf 3 "XYZ null null 1.5"
+     pdfcpu viewer remove in.pdf out.pdf OpenAction`
+
+	usageTransitionsList   = "pdfcpu transitions list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageTransitionsSet    = "pdfcpu transitions set [-pages pageSelection] [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile description"
+	usageTransitionsRemove = "pdfcpu transitions remove [-pages pageSelection] [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile"
+
+	usageTransitions = "usage: " + usageTransitionsList +
+		"\n       " + usageTransitionsSet +
+		"\n       " + usageTransitionsRemove
+
+	usageLongTransitions = `Transitions manages the effects and display durations of pages in presentation mode.
+
+  list ... print the transitions by page.
+   set ... set the transition of selected pages.
+remove ... remove the transitions of selected pages, eg. for a print version.
+
+      pages ... page selection, defaults to all pages
+    verbose ... extensive log output
+        upw ... user password
+        opw ... owner password
+     inFile ... input pdf file
+    outFile ... output pdf file
+description ... comma separated configuration string:
+
+  The first entry is the style, it may be omitted for setting the display duration only:
+     Split, Blinds, Box, Wipe, Dissolve, Glitter, R (replace), Fly, Push, Cover, Uncover, Fade
+
+  Optional entries:
+     d  ... duration of the effect in seconds, defaults to 1
+     dm ... dimension for Split and Blinds: H or V
+     m  ... motion for Split, Box and Fly: I (inward) or O (outward)
+     di ... direction in degrees for Wipe, Glitter, Fly, Cover, Uncover and Push: 0, 90, 180, 270 or 315
+     dur ... display duration in seconds before advancing to the next page
+
+e.g. pdfcpu transitions set in.pdf out.pdf "dissolve, d:1.5, dur:5"
+     pdfcpu transitions set -pages 2-4 in.pdf out.pdf "wipe, di:90"
+     pdfcpu transitions remove in.pdf out.pdf`
+
+	usagePortfolioList    = "pdfcpu portfolio list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usagePortfolioCreate  = "pdfcpu portfolio create [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile (file...|description.json)"
+	usagePortfolioExtract = "pdfcpu portfolio extract [-verbose] [-upw userpw] [-opw ownerpw] inFile outDir"
+
+	usagePortfolio = "usage: " + usagePortfolioList +
+		"\n       " + usagePortfolioCreate +
+		"\n       " + usagePortfolioExtract
+
+	usageLongPortfolio = `Portfolio manages PDF portfolios (collections of embedded files).
+
+   list ... print the view, the columns and the members along with their metadata.
+ create ... turn inFile into a portfolio, inFile becomes the cover sheet.
+extract ... write all members and their metadata as portfolio.json to outDir.
+
+        verbose ... extensive log output
+            upw ... user password
+            opw ... owner password
+         inFile ... input pdf file
+        outFile ... output pdf file
+         outDir ... output directory
+           file ... file to embed using the default columns file name, description, size and modification date
+description.json ... portfolio description as written by extract, member files are relative to its directory:
+
+  {
+    "view": "details",
+    "fields": [
+      {"key": "FileName", "name": "Name", "type": "filename"},
+      {"key": "Author", "name": "Author", "type": "text"},
+      {"key": "Due", "name": "Due", "type": "date"}
+    ],
+    "sort": ["Due"],
+    "initial": "a.pdf",
+    "members": [
+      {"file": "a.pdf", "description": "Proposal", "values": {"Author": "Ann", "Due": "2019-03-01"}}
+    ]
+  }
+
+  views: details, tile, hidden
+  field types: text, date, number, filename, description, moddate, creationdate, size, compressedsize
+
+e.g. pdfcpu portfolio create cover.pdf out.pdf a.pdf b.docx
+     pdfcpu portfolio extract out.pdf dir
+     pdfcpu portfolio create cover.pdf copy.pdf dir/portfolio.json`
+
+	usageAssociatedFilesList     = "pdfcpu af list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageAssociatedFilesAdd      = "pdfcpu af add [-rel relationship] [-desc description] [-page page | -obj objNr] [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile file"
+	usageAssociatedFilesValidate = "pdfcpu af validate [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+
+	usageAssociatedFiles = "usage: " + usageAssociatedFilesList +
+		"\n       " + usageAssociatedFilesAdd +
+		"\n       " + usageAssociatedFilesValidate
+
+	usageLongAssociatedFiles = `AF manages associated files, the machine-readable counterparts of hybrid documents.
+
+    list ... print the associated files of the document, its pages and objects.
+     add ... embed file and associate it with the document, a page or an object.
+validate ... check that all associated files are embedded and declare a valid relationship
+             and that all attachments are associated.
+
+         rel ... relationship of file to its owner, defaults to Unspecified:
+                 Source, Data, Alternative, Supplement, EncryptedPayload, FormData, Schema, Unspecified
+        desc ... description of file
+        page ... associate file with this page
+         obj ... associate file with this object, eg. an annotation or an image
+     verbose ... extensive log output
+         upw ... user password
+         opw ... owner password
+      inFile ... input pdf file
+     outFile ... output pdf file
+        file ... file to be associated
+
+e.g. pdfcpu af add -rel Alternative -desc "invoice data" in.pdf out.pdf invoice.xml
+     pdfcpu af add -rel Data -page 3 in.pdf out.pdf chart.csv
+     pdfcpu af validate out.pdf`
+
+	usageGeoList   = "pdfcpu geo list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageGeoAdd    = "pdfcpu geo add [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile viewports.json"
+	usageGeoRemove = "pdfcpu geo remove [-pages pageSelection] [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile"
+
+	usageGeo = "usage: " + usageGeoList +
+		"\n       " + usageGeoAdd +
+		"\n       " + usageGeoRemove
+
+	usageLongGeo = `Geo manages viewports, regions of pages with their own measurement or geo-registration (GeoPDF).
+
+  list ... print the viewports by page along with their scale ratio or geo-registration.
+   add ... georeference regions of pages by geospatial viewports.
+remove ... remove the viewports of selected pages.
+
+         pages ... page selection, defaults to all pages
+       verbose ... extensive log output
+           upw ... user password
+           opw ... owner password
+        inFile ... input pdf file
+       outFile ... output pdf file
+viewports.json ... JSON array of geospatial viewports:
+
+  [
+    {
+      "page": 1,
+      "name": "Boulder",
+      "bbox": [36, 36, 576, 756],
+      "gcs": {"type": "GEOGCS", "epsg": 4326},
+      "pdu": ["KM", "SQKM", "DEG"],
+      "gpts": [40.0, -105.3, 40.1, -105.3, 40.1, -105.2, 40.0, -105.2]
+    }
+  ]
+
+  page ... defaults to 1
+  bbox ... region of the page in user space: llx lly urx ury
+   gcs ... coordinate system of gpts: type GEOGCS or PROJCS along with an EPSG code or WKT
+   dcs ... optional coordinate system for displaying positions
+   pdu ... optional preferred linear (M, KM, FT, USFT, MI, NM), area (SQM, HA, SQKM, SQFT, A, SQMI)
+           and angular (DEG, GRD) display units
+bounds ... optional region of the unit square of bbox covered by the map, defaults to the unit square
+  gpts ... latitude longitude pairs of at least 3 points
+  lpts ... the points of gpts in the unit square of bbox,
+           may be omitted for 4 points matching the corners 0 0, 0 1, 1 1, 1 0
+
+e.g. pdfcpu geo add map.pdf out.pdf viewports.json
+     pdfcpu geo list out.pdf
+     pdfcpu geo remove -pages 2 out.pdf out.pdf`
+
+	usageOCR     = "usage: pdfcpu ocr [-verbose] [-pages pageSelection] [-lang languages] [-tesseract path] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongOCR = `OCR recognizes the words of the images on scanned pages and lays them over the images as invisible text,
+so the pages can be searched and their text selected and copied. Pages already showing text are skipped.
+Recognition is done by Tesseract which needs to be installed.
+
+      verbose ... extensive log output
+        pages ... page selection, defaults to all pages
+         lang ... Tesseract languages, eg. eng or eng+deu, defaults to eng
+    tesseract ... path of the tesseract executable, defaults to tesseract in PATH
+          upw ... user password
+          opw ... owner password
+       inFile ... input pdf file
+      outFile ... output pdf file (default: inFile)
+
+e.g. pdfcpu ocr scan.pdf searchable.pdf
+     pdfcpu ocr -pages 2-5 -lang eng+deu scan.pdf`
+
+	usageImportOCR     = "usage: pdfcpu import-ocr [-verbose] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile ocrFile [outFile]"
+	usageLongImportOCR = `Import-ocr lays the words recognized by an external OCR engine over the pages of inFile as invisible text,
+so the pages can be searched and their text selected and copied.
+
+Each page of ocrFile covers the visible region of a page. It goes to the page recorded in ocrFile
+(hOCR: ppageno, ALTO: PHYSICAL_IMG_NR, otherwise its position) or to the selected pages in ascending order.
+
+      verbose ... extensive log output
+        pages ... page selection, the pages of ocrFile go to the selected pages in ascending order
+          upw ... user password
+          opw ... owner password
+       inFile ... input pdf file
+      ocrFile ... hOCR (.hocr, .html) or ALTO (.xml) file
+      outFile ... output pdf file (default: inFile)
+
+e.g. pdfcpu import-ocr scan.pdf scan.hocr searchable.pdf
+     pdfcpu import-ocr -pages 5 scan.pdf page5.xml`
+
+	usageStructList   = "pdfcpu struct list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageStructExport = "pdfcpu struct export [-verbose] [-upw userpw] [-opw ownerpw] inFile outFile"
+
+	usageStruct = "usage: " + usageStructList +
+		"\n       " + usageStructExport
+
+	usageLongStruct = `Struct traverses the structure tree of a tagged PDF.
+
+  list ... print the structure elements along with their text.
+export ... write headings, paragraphs, lists, tables, figures with alternate text etc. as JSON or simple reflowable HTML.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output file, the extension selects the format: .json or .html
+
+e.g. pdfcpu struct list in.pdf
+     pdfcpu struct export in.pdf out.html`
+
+	usageRevisionsList    = "pdfcpu revisions list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageRevisionsExtract = "pdfcpu revisions extract [-verbose] [-upw userpw] [-opw ownerpw] inFile revision outFile"
+
+	usageRevisions = "usage: " + usageRevisionsList +
+		"\n       " + usageRevisionsExtract
+
+	usageLongRevisions = `Revisions lists or extracts the revisions of inFile.
+Each incremental update appended to a file creates a new revision, the original document being revision 1.
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+revision ... revision number, 1 for the original document
+ outFile ... output pdf file
+
+The list shows the size, page count and number of objects of each revision
+along with the number of objects changed by an update and the signatures covering a revision.
+An extracted revision is the file exactly as it was at that point, which is what a signature signing that revision covers.
+
+e.g. pdfcpu revisions list in.pdf
+     pdfcpu revisions extract in.pdf 1 original.pdf`
+
+	usageJSList   = "pdfcpu js list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageJSRemove = "pdfcpu js remove [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+
+	usageJS = "usage: " + usageJSList +
+		"\n       " + usageJSRemove
+
+	usageLongJS = `JS manages JavaScript.
+
+   list ... print all scripts with location, trigger, page, field, object number and source as JSON.
+ remove ... remove all scripts.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output pdf file (default: inFile)
+
+Scripts are looked up in document level scripts, the document open action, document, page, annotation and
+field additional actions as well as annotation, form field widget and outline actions.
+Remove retains non JavaScript actions chained to a removed script.
+
+e.g. pdfcpu js list in.pdf
+     pdfcpu js remove in.pdf out.pdf`
+
+	usageSign     = "usage: pdfcpu sign [-verbose] [-pw password] inFile p12File [outFile] ['field:name, page:n, rect:llx lly urx ury, reason:text, location:text, contact:text, tsa:url, name:text, image|bgimage:imageFile']"
+	usageLongSign = `Sign adds a PAdES digital signature (baseline B-B or B-T) to inFile using the private key and certificate chain of p12File
+and writes the result to outFile. The signature is appended as an incremental update leaving inFile's bytes untouched.
+
+ verbose ... extensive log output
+      pw ... password of p12File
+  inFile ... input pdf file
+ p12File ... PKCS#12 file (.p12, .pfx) holding the private key and the certificate chain
+ outFile ... output pdf file (default: inFile_new.pdf)
+
+The signature configuration string consists of optional comma separated entries:
+
+   field ... name of an unsigned signature field to be signed or of a new signature field (default: Signature<n>)
+    page ... page of a new signature field (default: 1)
+    rect ... widget of a new visible signature field, omit for an invisible signature
+  reason ... reason for signing
+location ... location of signing
+ contact ... contact info of the signer
+     tsa ... URL of a RFC 3161 time stamp authority for time stamping the signature (PAdES B-T)
+    name ... signer name shown by a visible signature (default: common name of the signing certificate)
+   image ... PNG or TIFF image (scanned signature, logo) drawn left of the text of a visible signature
+ bgimage ... PNG or TIFF image drawn behind the text of a visible signature
+
+A visible signature shows the signer name, the signing date and the optional reason and location.
+
+e.g. pdfcpu sign -pw secret in.pdf cert.p12 out.pdf
+     pdfcpu sign -pw secret in.pdf cert.p12 out.pdf 'rect:400 50 580 100, reason:Approved, location:Vienna'
+     pdfcpu sign -pw secret in.pdf cert.p12 out.pdf 'field:Approval'
+     pdfcpu sign -pw secret in.pdf cert.p12 out.pdf 'rect:400 50 580 100, name:Jane Doe, image:signature.png'
+     pdfcpu sign -pw secret in.pdf cert.p12 out.pdf 'tsa:http://timestamp.example.com'`
+
+	usageVerify     = "usage: pdfcpu verify [-verbose] [-cert certFile,...] inFile"
+	usageLongVerify = `Verify checks all digital signatures of inFile and prints a report per signature.
+
+verbose ... extensive log output
+   cert ... comma separated list of PEM files holding trusted root certificates (default: system roots)
+ inFile ... input pdf file
+
+A signature is valid if its byte range covers a complete revision, the message digest and the signature match,
+the signing certificate chains up to a trusted root and later incremental updates did not modify signed content.
+Filling in form fields, signing, adding annotations and updating the document security store are no modifications.
+
+e.g. pdfcpu verify signed.pdf
+     pdfcpu verify -cert ca.pem signed.pdf`
+
+	usageTimestamp     = "usage: pdfcpu timestamp [-verbose] inFile tsaURL [outFile] [field]"
+	usageLongTimestamp = `Timestamp adds a document time stamp obtained from a RFC 3161 time stamp authority to inFile
+and writes the result to outFile. The time stamp is appended as an incremental update.
+Document time stamps extend the validity of earlier signatures (PAdES B-LTA).
+
+verbose ... extensive log output
+ inFile ... input pdf file
+ tsaURL ... URL of the time stamp authority
+outFile ... output pdf file (default: inFile_new.pdf)
+  field ... name of an unsigned signature field or of a new invisible signature field (default: Signature<n>)
+
+e.g. pdfcpu timestamp signed.pdf http://timestamp.example.com out.pdf`
+
+	usageLTV     = "usage: pdfcpu ltv [-verbose] [-cert certFile,...] inFile [outFile]"
+	usageLongLTV = `LTV fetches the certificates and revocation information (OCSP responses or CRLs) needed to validate
+all signatures and time stamps of inFile and embeds them into the document security store (PAdES B-LT).
+The validation data is appended as an incremental update, existing signatures remain valid.
+
+verbose ... extensive log output
+   cert ... comma separated list of PEM files holding trusted root certificates (default: system roots)
+ inFile ... input pdf file
+outFile ... output pdf file (default: inFile_new.pdf)
+
+e.g. pdfcpu ltv signed.pdf out.pdf
+     pdfcpu ltv -cert ca.pem signed.pdf`
+
+	usageRepair     = "usage: pdfcpu repair [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongRepair = `Repair recovers as much as possible from a damaged or truncated inFile, writes the result to outFile
+and prints a report of the damage found.
+
+The last complete revision of a truncated file is used if there is one.
+Otherwise the cross reference table is rebuilt by scanning inFile for objects.
+Objects that cannot be read and pages failing validation are dropped.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output pdf file (default: inFile_new.pdf)
+
+e.g. pdfcpu repair broken.pdf fixed.pdf`
+
+	usageExportJSON     = "usage: pdfcpu export-json [-verbose] [-upw userpw] [-opw ownerpw] [-external] inFile outFile"
+	usageLongExportJSON = `Export-json writes the cross reference table of inFile along with all objects to outFile.
+
+The JSON output lends itself to scripted low level surgery and diffing:
+
+      integer ... 12           real ... 12.5        name ... "/Name"
+string literal ... "(text)"     hex ... "<4142>"     ref ... "12 0 R"
+
+Streams keep their encoded content and go base64 encoded into outFile unless -external is set.
+Object streams and cross reference streams are dissolved into their objects.
+Encrypted files are exported decrypted.
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+external ... write the content of stream obj#n to outFile_n.bin next to outFile
+  inFile ... input pdf file
+ outFile ... output json file
+
+e.g. pdfcpu export-json in.pdf in.json
+     pdfcpu export-json -external in.pdf in.json`
+
+	usageImportJSON     = "usage: pdfcpu import-json [-verbose] inFile outFile"
+	usageLongImportJSON = `Import-json creates outFile from inFile as written by pdfcpu export-json.
+
+Stream files referenced by inFile are expected next to inFile.
+
+verbose ... extensive log output
+ inFile ... input json file
+outFile ... output pdf file
+
+e.g. pdfcpu import-json in.json out.pdf`
+
+	usageDiff     = "usage: pdfcpu diff [-verbose] [-mode json] [-upw userpw] [-opw ownerpw] inFileA inFileB"
+	usageLongDiff = `Diff compares inFileA with inFileB and reports:
+
+page count differences,
+changes of the page dicts and the objects reachable from them, eg. boxes, resources, annotations,
+changes of the page content along with the text lines removed (-) and added (+),
+changes of document level objects like outlines, forms, metadata and the document info dict.
+
+Objects are compared by structure regardless of their object numbers,
+streams by their decoded content.
+
+Like diff(1) pdfcpu diff exits with 0 if the files are equal, 1 if they differ and 2 on errors.
+
+verbose ... extensive log output
+   mode ... json for machine-readable output
+    upw ... user password
+    opw ... owner password
+inFileA ... input pdf file
+inFileB ... input pdf file
+
+e.g. pdfcpu diff expected.pdf actual.pdf
+     pdfcpu diff -mode json expected.pdf actual.pdf`
+
+	usageBatch     = "usage: pdfcpu batch [-verbose] [-upw userpw] [-opw ownerpw] [-pattern patterns] [-recursive] [-jobs n] [-continue] inDir outDir command,..."
+	usageLongBatch = `Batch applies a pipeline of commands to every PDF file in inDir.
+
+Each file gets copied to the corresponding path in outDir, then the commands are applied in order.
+Use the same directory for inDir and outDir to process files in place.
+Commands take their options from the usual flags, eg. -mode, -key and -perm for encrypt.
+
+pdfcpu batch prints a summary and exits with 1 if any file failed.
+
+  verbose ... extensive log output
+      upw ... user password
+      opw ... owner password
+  pattern ... comma separated list of file name patterns, defaults to *.pdf
+recursive ... process sub directories
+     jobs ... number of files processed concurrently
+ continue ... keep going after a file failed
+    inDir ... input directory
+   outDir ... output directory
+  command ... decrypt, encrypt, linearize, optimize, repair, rmrights, sanitize, validate
+
+e.g. pdfcpu batch -recursive -jobs 4 in out validate,optimize
+     pdfcpu batch -continue -pattern "invoice*.pdf" -upw secret in out decrypt,sanitize`
+
 	usageVersion     = "usage: pdfcpu version"
 	usageLongVersion = "Version prints the pdfcpu version"
 )
@@ -0,0 +1,213 @@
+//go:build js && wasm
+// +build js,wasm
+
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package main exposes pdfcpu to JavaScript running pdfcpu in the browser or in Node.js.
+//
+// Build using:
+//
+//	GOOS=js GOARCH=wasm go build -o pdfcpu.wasm ./cmd/wasm
+//
+// and load pdfcpu.wasm using wasm_exec.js from $(go env GOROOT)/misc/wasm (lib/wasm as of go1.24).
+// Once running the global object pdfcpu provides functions taking PDF files as Uint8Arrays
+// and returning Promises:
+//
+//	pdfcpu.validate(pdf)          resolves to the validation warnings as array of strings.
+//	pdfcpu.optimize(pdf)          resolves to the optimized PDF.
+//	pdfcpu.split(pdf)             resolves to an array of single page PDFs.
+//	pdfcpu.merge([pdf1, pdf2..])  resolves to the merged PDF.
+//
+// Errors and panics reject the Promise with a JavaScript Error.
+// No file system is needed, all processing happens in memory.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"syscall/js"
+
+	"github.com/hhrutter/pdfcpu/pkg/api"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/pkg/errors"
+)
+
+// bytesArg returns the Uint8Array v as byte slice.
+func bytesArg(v js.Value) ([]byte, error) {
+
+	if !v.InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errors.New("expected Uint8Array")
+	}
+
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+
+	return b, nil
+}
+
+// bytesValue returns b as Uint8Array.
+func bytesValue(b []byte) js.Value {
+	v := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(v, b)
+	return v
+}
+
+func validate(args []js.Value) (interface{}, error) {
+
+	b, err := bytesArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := api.ValidateContext(bytes.NewReader(b), pdfcpu.NewDefaultConfiguration())
+	if err != nil {
+		return nil, err
+	}
+
+	vv := make([]interface{}, len(warnings))
+	for i, s := range warnings {
+		vv[i] = s
+	}
+
+	return vv, nil
+}
+
+func optimize(args []js.Value) (interface{}, error) {
+
+	b, err := bytesArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if err = api.OptimizeContext(bytes.NewReader(b), &buf, pdfcpu.NewDefaultConfiguration()); err != nil {
+		return nil, err
+	}
+
+	return bytesValue(buf.Bytes()), nil
+}
+
+func split(args []js.Value) (interface{}, error) {
+
+	b, err := bytesArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var bufs []*bytes.Buffer
+
+	err = api.SplitContext(bytes.NewReader(b), pdfcpu.NewDefaultConfiguration(), func(pageNr int) (io.Writer, error) {
+		buf := &bytes.Buffer{}
+		bufs = append(bufs, buf)
+		return buf, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]interface{}, len(bufs))
+	for i, buf := range bufs {
+		pages[i] = bytesValue(buf.Bytes())
+	}
+
+	return pages, nil
+}
+
+func merge(args []js.Value) (interface{}, error) {
+
+	if len(args) == 0 || !js.Global().Get("Array").Call("isArray", args[0]).Bool() {
+		return nil, errors.New("expected array of Uint8Arrays")
+	}
+
+	n := args[0].Length()
+	if n < 2 {
+		return nil, errors.New("merge needs at least 2 files")
+	}
+
+	rss := make([]io.ReadSeeker, n)
+
+	for i := 0; i < n; i++ {
+		b, err := bytesArg(args[0].Index(i))
+		if err != nil {
+			return nil, err
+		}
+		rss[i] = bytes.NewReader(b)
+	}
+
+	var buf bytes.Buffer
+
+	if err := api.MergeContext(rss, &buf, pdfcpu.NewDefaultConfiguration()); err != nil {
+		return nil, err
+	}
+
+	return bytesValue(buf.Bytes()), nil
+}
+
+// export returns f as JavaScript function returning a Promise.
+// f runs in its own goroutine so the JavaScript event loop does not get blocked.
+func export(f func(args []js.Value) (interface{}, error)) js.Func {
+
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+
+		executor := js.FuncOf(func(this js.Value, cb []js.Value) interface{} {
+
+			resolve, reject := cb[0], cb[1]
+
+			go func() {
+
+				defer func() {
+					if r := recover(); r != nil {
+						reject.Invoke(js.Global().Get("Error").New(fmt.Sprintf("pdfcpu: %v", r)))
+					}
+				}()
+
+				if len(args) == 0 {
+					reject.Invoke(js.Global().Get("Error").New("missing argument"))
+					return
+				}
+
+				v, err := f(args)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New(err.Error()))
+					return
+				}
+
+				resolve.Invoke(v)
+			}()
+
+			return nil
+		})
+		defer executor.Release()
+
+		return js.Global().Get("Promise").New(executor)
+	})
+}
+
+func main() {
+
+	js.Global().Set("pdfcpu", map[string]interface{}{
+		"validate": export(validate),
+		"optimize": export(optimize),
+		"split":    export(split),
+		"merge":    export(merge),
+	})
+
+	// Keep the exported functions alive.
+	select {}
+}
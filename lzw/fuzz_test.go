@@ -0,0 +1,31 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lzw_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/lzw"
+)
+
+// Run eg. go test -fuzz FuzzDecode ./lzw for fuzzing.
+func FuzzDecode(f *testing.F) {
+
+	for _, oneOff := range []bool{false, true} {
+		var b bytes.Buffer
+		w := lzw.NewWriter(&b, oneOff)
+		w.Write([]byte("TOBEORNOTTOBEORTOBEORNOT"))
+		w.Close()
+		f.Add(b.Bytes(), oneOff)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte, oneOff bool) {
+		r := lzw.NewReader(bytes.NewReader(b), oneOff)
+		defer r.Close()
+		ioutil.ReadAll(r)
+	})
+}
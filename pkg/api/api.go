@@ -18,10 +18,17 @@ limitations under the License.
 package api
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"io"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -60,12 +67,42 @@ func Read(fileIn string, config *pdfcpu.Configuration) (*pdfcpu.PDFContext, erro
 	return ctx, nil
 }
 
+// readInput reads fileIn from config.FS.
+func readInput(fileIn string, config *pdfcpu.Configuration) ([]byte, error) {
+
+	if config == nil {
+		config = pdfcpu.NewDefaultConfiguration()
+	}
+
+	return config.ReadInput(fileIn)
+}
+
+// writeOutput writes b to fileOut created using config.CreateFile.
+func writeOutput(fileOut string, b []byte, config *pdfcpu.Configuration) error {
+
+	if config == nil {
+		config = pdfcpu.NewDefaultConfiguration()
+	}
+
+	return config.WriteOutput(fileOut, b)
+}
+
 // Validate validates a PDF file against ISO-32000-1:2008.
+// The result lists the violations of validation rules set to warn by the validation policy.
+// A page selection or object numbers restrict validation to the selected pages or objects.
 func Validate(cmd *Command) ([]string, error) {
 
 	config := cmd.Config
 	fileIn := *cmd.InFile
 
+	if len(cmd.PageSelection) > 0 {
+		return ValidatePages(fileIn, cmd.PageSelection, config)
+	}
+
+	if len(cmd.ObjNrs) > 0 {
+		return ValidateObjects(fileIn, cmd.ObjNrs, config)
+	}
+
 	from1 := time.Now()
 
 	fmt.Printf("validating(mode=%s) %s ...\n", config.ValidationModeString(), fileIn)
@@ -99,7 +136,65 @@ func Validate(cmd *Command) ([]string, error) {
 	// at this stage: no binary breakup available!
 	ctx.Read.LogStats(ctx.Optimized)
 
-	return nil, err
+	return ctx.Warnings, err
+}
+
+// ValidatePages validates selected pages of a PDF file including their resources and annotations
+// without a full document pass.
+func ValidatePages(fileIn string, pageSelection []string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fmt.Printf("validating(mode=%s) %s, pages %s ...\n", config.ValidationModeString(), fileIn, strings.Join(pageSelection, ","))
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.EnsurePageCount()
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pages) == 0 {
+		return nil, errors.New("validate: no pages selected")
+	}
+
+	err = pdfcpu.ValidatePages(ctx.XRefTable, pages)
+	if err != nil {
+		return ctx.Warnings, errors.Wrap(err, "validation error (try -mode=relaxed)")
+	}
+
+	fmt.Println("validation ok")
+
+	return ctx.Warnings, nil
+}
+
+// ValidateObjects validates selected objects of a PDF file along with all objects reachable from them
+// without a full document pass.
+func ValidateObjects(fileIn string, objNrs []int, config *pdfcpu.Configuration) ([]string, error) {
+
+	fmt.Printf("validating(mode=%s) %s, objects %v ...\n", config.ValidationModeString(), fileIn, objNrs)
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, objNr := range objNrs {
+		err = pdfcpu.ValidateObject(ctx.XRefTable, objNr)
+		if err != nil {
+			return ctx.Warnings, errors.Wrap(err, "validation error (try -mode=relaxed)")
+		}
+	}
+
+	fmt.Println("validation ok")
+
+	return ctx.Warnings, nil
 }
 
 // Write generates a PDF file for a given PDFContext.
@@ -239,10 +334,29 @@ func Optimize(cmd *Command) ([]string, error) {
 	ctx.Read.LogStats(ctx.Optimized)
 	ctx.Write.LogStats()
 
-	return nil, nil
+	var report []string
+
+	if ctx.Optimize.PageTreeRebalanced {
+		report = append(report, "rebalanced page tree")
+	}
+
+	if n := ctx.Optimize.FixedPageCounts; n > 0 {
+		report = append(report, fmt.Sprintf("fixed %d page counts", n))
+	}
+
+	if n, _ := ctx.Optimize.UnreachableObjectsString(); n > 0 {
+		report = append(report, fmt.Sprintf("removed %d unreachable objects (%d bytes)", n, ctx.Optimize.UnreachableBytes))
+	}
+
+	if n := ctx.Optimize.RecompressedStreams; n > 0 {
+		report = append(report, fmt.Sprintf("recompressed %d streams (%d bytes saved)", n, ctx.Optimize.RecompressedBytes))
+	}
+
+	return report, nil
 }
 
 // Split generates a sequence of single page PDF files in dirOut creating one file for every page of inFile.
+// Objects get loaded on demand page by page, so inFile never needs to be held in memory as a whole.
 func Split(cmd *Command) ([]string, error) {
 
 	fileIn := *cmd.InFile
@@ -253,14 +367,31 @@ func Split(cmd *Command) ([]string, error) {
 
 	fmt.Printf("splitting %s into %s ...\n", fileIn, dirOut)
 
-	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if config == nil {
+		config = pdfcpu.NewDefaultConfiguration()
+	}
+
+	f, err := config.OpenReadSeeker(fileIn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	src, err := pdfcpu.NewSplitSource(fileIn, f, config)
 	if err != nil {
 		return nil, err
 	}
 
+	durRead := time.Since(fromStart).Seconds()
 	fromWrite := time.Now()
 
-	err = writeSinglePagePDFs(ctx, nil, dirOut)
+	baseFileName := strings.TrimSuffix(filepath.Base(fileIn), ".pdf")
+
+	err = writeSplitPages(src, func(pageNr int) (io.Writer, error) {
+		fileName := filepath.Join(dirOut, baseFileName+"_"+strconv.Itoa(pageNr)+".pdf")
+		fmt.Printf("writing %s ...\n", fileName)
+		return config.CreateOutput(fileName)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -268,15 +399,10 @@ func Split(cmd *Command) ([]string, error) {
 	durWrite := time.Since(fromWrite).Seconds()
 	durTotal := time.Since(fromStart).Seconds()
 
-	log.Stats.Printf("XRefTable:\n%s\n", ctx)
 	log.Stats.Println("Timing:")
 	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
-	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
-	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
 	log.Stats.Printf("split                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
 	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
-	ctx.Read.LogStats(ctx.Optimized)
-	ctx.Write.LogStats()
 
 	return nil, nil
 }
@@ -369,21 +495,31 @@ func imageObjNrs(ctx *pdfcpu.PDFContext, page int) []int {
 	return o
 }
 
-func imageFilenameWithoutExtension(dir, resID string, pageNr, objNr int) string {
-	return filepath.Join(dir, fmt.Sprintf("%s_%d_%d", resID, pageNr, objNr))
+func imageFilenameWithoutExtension(resID string, pageNr, objNr int) string {
+	return fmt.Sprintf("%s_%d_%d", resID, pageNr, objNr)
 }
 
-func doExtractImages(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet) error {
+func doExtractImages(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet, f ExtractWriterFunc) error {
 
 	visited := pdfcpu.IntSet{}
 
-	for pageNr, v := range selectedPages {
+	var pageNr, objNr int
+
+	// WriteImage appends the file extension matching the image type to the file name.
+	ctx.XRefTable.CreateFile = func(name string) (io.WriteCloser, error) {
+		w, err := f(pageNr, objNr, name)
+		return writeCloser(w), err
+	}
+
+	for p, v := range selectedPages {
 
 		if v {
 
+			pageNr = p
+
 			log.Info.Printf("writing images for page %d\n", pageNr)
 
-			for _, objNr := range imageObjNrs(ctx, pageNr) {
+			for _, objNr = range imageObjNrs(ctx, pageNr) {
 
 				if visited[objNr] {
 					continue
@@ -391,18 +527,18 @@ func doExtractImages(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet) error
 
 				visited[objNr] = true
 
-				io, err := pdfcpu.ExtractImageData(ctx, objNr)
+				imageObj, err := pdfcpu.ExtractImageData(ctx, objNr)
 				if err != nil {
 					return err
 				}
 
-				if io == nil {
+				if imageObj == nil {
 					continue
 				}
 
-				filename := imageFilenameWithoutExtension(ctx.Write.DirName, io.ResourceNames[0], pageNr, objNr)
+				filename := imageFilenameWithoutExtension(imageObj.ResourceNames[0], pageNr, objNr)
 
-				_, err = pdfcpu.WriteImage(ctx.XRefTable, filename, io.ImageDict, objNr)
+				_, err = pdfcpu.WriteImage(ctx.XRefTable, filename, imageObj.ImageDict, objNr)
 				if err != nil {
 					return err
 				}
@@ -443,7 +579,7 @@ func ExtractImages(cmd *Command) ([]string, error) {
 	ensureSelectedPages(ctx, &pages)
 
 	ctx.Write.DirName = dirOut
-	err = doExtractImages(ctx, pages)
+	err = doExtractImages(ctx, pages, fileWriter(dirOut, config))
 	if err != nil {
 		return nil, err
 	}
@@ -475,7 +611,7 @@ func fontObjNrs(ctx *pdfcpu.PDFContext, page int) []int {
 	return o
 }
 
-func doExtractFonts(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet) error {
+func doExtractFonts(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet, f ExtractWriterFunc) error {
 
 	visited := pdfcpu.IntSet{}
 
@@ -502,9 +638,9 @@ func doExtractFonts(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet) error {
 					continue
 				}
 
-				fileName := fmt.Sprintf("%s/%s_%d_%d.%s", ctx.Write.DirName, fo.ResourceNames[0], p, objNr, fo.Extension)
+				name := fmt.Sprintf("%s_%d_%d.%s", fo.ResourceNames[0], p, objNr, fo.Extension)
 
-				err = ioutil.WriteFile(fileName, fo.Data, os.ModePerm)
+				err = writeExtracted(f, p, objNr, name, fo.Data)
 				if err != nil {
 					return err
 				}
@@ -545,7 +681,7 @@ func ExtractFonts(cmd *Command) ([]string, error) {
 	ensureSelectedPages(ctx, &pages)
 
 	ctx.Write.DirName = dirOut
-	err = doExtractFonts(ctx, pages)
+	err = doExtractFonts(ctx, pages, fileWriter(dirOut, config))
 	if err != nil {
 		return nil, err
 	}
@@ -672,7 +808,7 @@ func contentObjNrs(ctx *pdfcpu.PDFContext, page int) ([]int, error) {
 	return objNrs, nil
 }
 
-func doExtractContent(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet) error {
+func doExtractContent(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet, f ExtractWriterFunc) error {
 
 	visited := pdfcpu.IntSet{}
 
@@ -708,9 +844,9 @@ func doExtractContent(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet) error
 					continue
 				}
 
-				fileName := fmt.Sprintf("%s/%d_%d.txt", ctx.Write.DirName, p, objNr)
+				name := fmt.Sprintf("%d_%d.txt", p, objNr)
 
-				err = ioutil.WriteFile(fileName, b, os.ModePerm)
+				err = writeExtracted(f, p, objNr, name, b)
 				if err != nil {
 					return err
 				}
@@ -751,7 +887,7 @@ func ExtractContent(cmd *Command) ([]string, error) {
 	ensureSelectedPages(ctx, &pages)
 
 	ctx.Write.DirName = dirOut
-	err = doExtractContent(ctx, pages)
+	err = doExtractContent(ctx, pages, fileWriter(dirOut, config))
 	if err != nil {
 		return nil, err
 	}
@@ -770,6 +906,77 @@ func ExtractContent(cmd *Command) ([]string, error) {
 	return nil, nil
 }
 
+func doExtractMedia(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet, f ExtractWriterFunc) error {
+
+	for p, v := range selectedPages {
+
+		if !v {
+			continue
+		}
+
+		log.Info.Printf("writing media for page %d\n", p)
+
+		mm, err := pdfcpu.ExtractMediaData(ctx.XRefTable, p)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range mm {
+			if err = writeExtracted(f, p, m.ObjNr, m.FileName(), m.Data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ExtractMedia dumps the U3D/PRC streams of 3D annotations and the media assets
+// of RichMedia and Screen annotations from fileIn into dirOut for selected pages.
+func ExtractMedia(cmd *Command) ([]string, error) {
+
+	fileIn := *cmd.InFile
+	dirOut := *cmd.OutDir
+	pageSelection := cmd.PageSelection
+	config := cmd.Config
+
+	fromStart := time.Now()
+
+	fmt.Printf("extracting media from %s into %s ...\n", fileIn, dirOut)
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromWrite := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	err = doExtractMedia(ctx, pages, fileWriter(dirOut, config))
+	if err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("write media          : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil, nil
+}
+
 // Trim generates a trimmed version of fileIn containing all pages selected.
 func Trim(cmd *Command) ([]string, error) {
 
@@ -834,6 +1041,65 @@ func Decrypt(cmd *Command) ([]string, error) {
 	return Optimize(cmd)
 }
 
+// ReadCertificates returns the X.509 certificates contained in the PEM file fileName read from config.FS.
+func ReadCertificates(fileName string, config *pdfcpu.Configuration) ([]*x509.Certificate, error) {
+
+	b, err := readInput(fileName, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", fileName)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.Errorf("%s: no certificate found", fileName)
+	}
+
+	return certs, nil
+}
+
+// ReadPrivateKey returns the RSA private key contained in the PEM file fileName
+// read from config.FS using either PKCS#1 or unencrypted PKCS#8 encoding.
+func ReadPrivateKey(fileName string, config *pdfcpu.Configuration) (crypto.PrivateKey, error) {
+
+	b, err := readInput(fileName, config)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			return x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+	}
+
+	return nil, errors.Errorf("%s: no private key found", fileName)
+}
+
 // ChangeUserPassword of fileIn and write result to fileOut.
 func ChangeUserPassword(cmd *Command) ([]string, error) {
 	cmd.Config.UserPW = *cmd.PWOld
@@ -848,7 +1114,40 @@ func ChangeOwnerPassword(cmd *Command) ([]string, error) {
 	return Optimize(cmd)
 }
 
-// ListAttachments returns a list of embedded file attachments.
+// attachmentInfo returns a line describing the attachment a.
+func attachmentInfo(a pdfcpu.Attachment) string {
+
+	ss := []string{a.FileName}
+
+	if a.Desc != "" {
+		ss = append(ss, fmt.Sprintf("%q", a.Desc))
+	}
+
+	if a.MimeType != "" {
+		ss = append(ss, a.MimeType)
+	}
+
+	if a.Size >= 0 {
+		ss = append(ss, fmt.Sprintf("%d bytes", a.Size))
+	}
+
+	if !a.CreationDate.IsZero() {
+		ss = append(ss, "created "+a.CreationDate.Format("2006-01-02 15:04:05"))
+	}
+
+	if !a.ModDate.IsZero() {
+		ss = append(ss, "modified "+a.ModDate.Format("2006-01-02 15:04:05"))
+	}
+
+	if a.CheckSum != "" {
+		ss = append(ss, "md5 "+a.CheckSum)
+	}
+
+	return strings.Join(ss, " ")
+}
+
+// ListAttachments returns a list of embedded file attachments
+// along with their description, MIME type, size, dates and checksum.
 func ListAttachments(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
 
 	fromStart := time.Now()
@@ -862,11 +1161,16 @@ func ListAttachments(fileIn string, config *pdfcpu.Configuration) ([]string, err
 
 	fromWrite := time.Now()
 
-	list, err := pdfcpu.AttachList(ctx.XRefTable)
+	aa, err := pdfcpu.Attachments(ctx.XRefTable)
 	if err != nil {
 		return nil, err
 	}
 
+	var list []string
+	for _, a := range aa {
+		list = append(list, attachmentInfo(a))
+	}
+
 	durWrite := time.Since(fromWrite).Seconds()
 	durTotal := time.Since(fromStart).Seconds()
 
@@ -882,6 +1186,7 @@ func ListAttachments(fileIn string, config *pdfcpu.Configuration) ([]string, err
 }
 
 // AddAttachments embeds files into a PDF.
+// A file may be followed by a description separated by a comma: "invoice.xml, Invoice data".
 func AddAttachments(fileIn string, files []string, config *pdfcpu.Configuration) error {
 
 	fromStart := time.Now()
@@ -936,6 +1241,19 @@ func AddAttachments(fileIn string, files []string, config *pdfcpu.Configuration)
 	return nil
 }
 
+// AddPageAttachments attaches a file to the selected pages of a PDF as file attachment annotation located at n.Rect.
+func AddPageAttachments(fileIn, file string, pageSelection []string, n *pdfcpu.Note, config *pdfcpu.Configuration) error {
+	return updateAnnotations(fileIn, fileIn, pageSelection, config, "added",
+		func(xRefTable *pdfcpu.XRefTable, selectedPages pdfcpu.IntSet) (int, error) {
+
+			if n == nil {
+				return 0, errors.New("missing attachment location")
+			}
+
+			return pdfcpu.AttachAddToPages(xRefTable, selectedPages, *n, file)
+		})
+}
+
 // RemoveAttachments deletes embedded files from a PDF.
 func RemoveAttachments(fileIn string, files []string, config *pdfcpu.Configuration) error {
 
@@ -1042,7 +1360,7 @@ func ListPermissions(fileIn string, config *pdfcpu.Configuration) ([]string, err
 	}
 
 	fromList := time.Now()
-	list := pdfcpu.Permissions(ctx)
+	list := pdfcpu.ListPermissions(ctx)
 	durList := time.Since(fromList).Seconds()
 
 	durTotal := time.Since(fromStart).Seconds()
@@ -1098,14 +1416,8 @@ func AddPermissions(fileIn string, config *pdfcpu.Configuration) error {
 	return nil
 }
 
-// AddWatermarks adds watermarks to all pages selected.
-func AddWatermarks(cmd *Command) ([]string, error) {
-
-	fileIn := *cmd.InFile
-	fileOut := *cmd.OutFile
-	pageSelection := cmd.PageSelection
-	wm := cmd.Watermark
-	config := cmd.Config
+// ListFormFields returns a JSON representation of all form fields.
+func ListFormFields(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
 
 	fromStart := time.Now()
 
@@ -1114,23 +1426,75 @@ func AddWatermarks(cmd *Command) ([]string, error) {
 		return nil, err
 	}
 
-	fmt.Printf("%sing %s ...\n", wm.OnTopString(), fileIn)
+	fromList := time.Now()
 
-	from := time.Now()
+	fields, err := pdfcpu.ListFormFields(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
 
-	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	bb, err := json.MarshalIndent(fields, "", "\t")
 	if err != nil {
 		return nil, err
 	}
 
-	ensureSelectedPages(ctx, &pages)
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
 
-	err = pdfcpu.AddWatermarks(ctx.XRefTable, pages, wm)
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("list form fields     : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return []string{string(bb)}, nil
+}
+
+// parseFormDataFile parses form data depending on the file extension.
+func parseFormDataFile(fileName string, config *pdfcpu.Configuration) (map[string]interface{}, error) {
+
+	bb, err := config.ReadInput(fileName)
 	if err != nil {
 		return nil, err
 	}
 
-	durStamp := time.Since(from).Seconds()
+	switch strings.ToLower(filepath.Ext(fileName)) {
+
+	case ".fdf":
+		return pdfcpu.ParseFDF(bb)
+
+	case ".xfdf":
+		return pdfcpu.ParseXFDF(bb)
+	}
+
+	return pdfcpu.ParseFormData(bb)
+}
+
+// FillForm sets form field values from a JSON, FDF or XFDF file and writes the result to fileOut.
+func FillForm(fileIn, fileData, fileOut string, config *pdfcpu.Configuration) error {
+
+	values, err := parseFormDataFile(fileData, config)
+	if err != nil {
+		return err
+	}
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	err = pdfcpu.FillForm(ctx.XRefTable, values, config.NeedAppearances)
+	if err != nil {
+		return err
+	}
+
+	durFill := time.Since(from).Seconds()
 
 	fromWrite := time.Now()
 
@@ -1140,7 +1504,7 @@ func AddWatermarks(cmd *Command) ([]string, error) {
 
 	err = Write(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	durWrite := time.Since(fromWrite).Seconds()
@@ -1151,11 +1515,2906 @@ func AddWatermarks(cmd *Command) ([]string, error) {
 	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
 	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
 	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
-	log.Stats.Printf("watermark            : %6.3fs  %4.1f%%\n", durStamp, durStamp/durTotal*100)
+	log.Stats.Printf("fill form            : %6.3fs  %4.1f%%\n", durFill, durFill/durTotal*100)
 	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
 	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
 	ctx.Read.LogStats(ctx.Optimized)
 	ctx.Write.LogStats()
 
-	return nil, nil
+	return nil
+}
+
+// ExportForm writes the form data of fileIn to fileOut.
+// The export format is FDF, XFDF, JSON or CSV depending on the extension of fileOut.
+func ExportForm(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	var export func(*pdfcpu.XRefTable) ([]byte, error)
+
+	switch strings.ToLower(filepath.Ext(fileOut)) {
+
+	case ".fdf":
+		export = func(xRefTable *pdfcpu.XRefTable) ([]byte, error) {
+			return pdfcpu.ExportFDF(xRefTable, filepath.Base(fileIn))
+		}
+
+	case ".xfdf":
+		export = func(xRefTable *pdfcpu.XRefTable) ([]byte, error) {
+			return pdfcpu.ExportXFDF(xRefTable, filepath.Base(fileIn))
+		}
+
+	case ".json":
+		export = pdfcpu.ExportFormJSON
+
+	case ".csv":
+		export = pdfcpu.ExportFormCSV
+
+	default:
+		return errors.Errorf("ExportForm: unsupported export format: %s", fileOut)
+	}
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fromExport := time.Now()
+
+	bb, err := export(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	err = config.WriteOutput(fileOut, bb)
+	if err != nil {
+		return err
+	}
+
+	durExport := time.Since(fromExport).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("export form          : %6.3fs  %4.1f%%\n", durExport, durExport/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// fillFormCopy reads fileIn and fills its form with the values of record r.
+func fillFormCopy(fileIn string, r pdfcpu.FormRecord, config *pdfcpu.Configuration) (*pdfcpu.PDFContext, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = pdfcpu.FillForm(ctx.XRefTable, r.Values, config.NeedAppearances)
+	if err != nil {
+		return nil, errors.Wrapf(err, "record %s", r.Name)
+	}
+
+	return ctx, nil
+}
+
+// MultiFillForm fills the form of fileIn once for every record of the CSV file fileCSV.
+// The filled copies are written to dirOut or merged into fileOut if mfc.Merge is set.
+// When merging the fields of each copy are moved below a field named after the copy.
+func MultiFillForm(fileIn, fileCSV, dirOut, fileOut string, mfc *pdfcpu.MultiFillConfig, config *pdfcpu.Configuration) error {
+
+	bb, err := config.ReadInput(fileCSV)
+	if err != nil {
+		return err
+	}
+
+	records, err := pdfcpu.ParseFormRecords(bb, mfc)
+	if err != nil {
+		return err
+	}
+
+	fromStart := time.Now()
+
+	var ctxDest *pdfcpu.PDFContext
+
+	for _, r := range records {
+
+		ctx, err := fillFormCopy(fileIn, r, config)
+		if err != nil {
+			return err
+		}
+
+		if !mfc.Merge {
+
+			fileName := r.Name + ".pdf"
+			if mfc.NameColumn == "" {
+				fileName = strings.TrimSuffix(filepath.Base(fileIn), filepath.Ext(fileIn)) + "_" + fileName
+			}
+
+			ctx.Write.DirName, ctx.Write.FileName = filepath.Split(filepath.Join(dirOut, fileName))
+
+			err = Write(ctx)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err = pdfcpu.QualifyFormFields(ctx.XRefTable, r.Name)
+		if err != nil {
+			return err
+		}
+
+		if ctxDest == nil {
+			ctxDest = ctx
+			continue
+		}
+
+		err = pdfcpu.MergeXRefTables(ctx, ctxDest)
+		if err != nil {
+			return err
+		}
+
+		err = pdfcpu.MergeFormFields(ctx, ctxDest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctxDest != nil {
+
+		if ctxDest.XRefTable.Version() < pdfcpu.V15 {
+			v, _ := pdfcpu.Version("1.5")
+			ctxDest.XRefTable.RootVersion = &v
+			log.Stats.Println("Ensure V1.5 for writing object & xref streams")
+		}
+
+		err = pdfcpu.OptimizeXRefTable(ctxDest)
+		if err != nil {
+			return err
+		}
+
+		err = pdfcpu.ValidateXRefTable(ctxDest.XRefTable)
+		if err != nil {
+			return err
+		}
+
+		ctxDest.Write.DirName, ctxDest.Write.FileName = filepath.Split(fileOut)
+
+		err = Write(ctxDest)
+		if err != nil {
+			return err
+		}
+
+		log.Stats.Printf("XRefTable:\n%s\n", ctxDest)
+	}
+
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// GenerateFieldAppearances regenerates the appearance streams of the form fields of fileIn
+// according to their values and writes the result to fileOut.
+func GenerateFieldAppearances(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	n, err := pdfcpu.GenerateFieldAppearances(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("generated appearances for %d fields.\n", n)
+
+	durGen := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("field appearances    : %6.3fs  %4.1f%%\n", durGen, durGen/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// ListFormOrder returns a JSON representation of the tab order and the calculation order of the form fields of fileIn.
+func ListFormOrder(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromList := time.Now()
+
+	fo, err := pdfcpu.ListFormOrder(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err := json.MarshalIndent(fo, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("list form order      : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return []string{string(bb)}, nil
+}
+
+// updateForm applies a form operation to fileIn and writes the result to fileOut.
+func updateForm(fileIn, fileOut string, config *pdfcpu.Configuration, op func(xRefTable *pdfcpu.XRefTable) error) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	if err = op(ctx.XRefTable); err != nil {
+		return err
+	}
+
+	durOp := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("form                 : %6.3fs  %4.1f%%\n", durOp, durOp/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// SetTabOrder sets the tab order of the selected pages of fileIn to tabs (R, C or S) unless tabs is empty
+// and moves the widgets of fieldNames to the front of the annotations of these pages.
+// The result is written to fileOut.
+func SetTabOrder(fileIn, fileOut string, pageSelection []string, tabs string, fieldNames []string, config *pdfcpu.Configuration) error {
+
+	if tabs == "" && len(fieldNames) == 0 {
+		return errors.New("SetTabOrder: missing tab order or field names")
+	}
+
+	return updateForm(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+
+		pages, err := pagesForPageSelection(xRefTable.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+
+		if len(fieldNames) > 0 {
+			if _, err = pdfcpu.SetWidgetOrder(xRefTable, pages, fieldNames); err != nil {
+				return err
+			}
+		}
+
+		if tabs == "" {
+			return nil
+		}
+
+		n, err := pdfcpu.SetTabOrder(xRefTable, pages, tabs)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("set tab order of %d pages.\n", n)
+
+		if xRefTable.Version() < pdfcpu.V15 {
+			v, _ := pdfcpu.Version("1.5")
+			xRefTable.RootVersion = &v
+			log.Stats.Println("Ensure V1.5 for page entry Tabs")
+		}
+
+		return nil
+	})
+}
+
+// SetCalculationOrder sets the calculation order of the form fields of fileIn and writes the result to fileOut.
+// Without fieldNames the calculation order is removed.
+func SetCalculationOrder(fileIn, fileOut string, fieldNames []string, config *pdfcpu.Configuration) error {
+	return updateForm(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		return pdfcpu.SetCalculationOrder(xRefTable, fieldNames)
+	})
+}
+
+// AddSignatureField adds an unsigned signature field to fileIn and writes the result to fileOut.
+// A field without rect is invisible, otherwise its widget shows a placeholder with an optional label.
+func AddSignatureField(fileIn, fileOut string, fa *pdfcpu.FieldAttributes, label string, config *pdfcpu.Configuration) error {
+
+	if fa == nil {
+		return errors.New("AddSignatureField: missing field attributes")
+	}
+
+	return updateForm(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		return pdfcpu.AddSignatureField(xRefTable, *fa, label)
+	})
+}
+
+// ResetFormFields restores the default values of the fields of fileIn selected by fieldNames
+// and writes the result to fileOut. Without fieldNames all fields are reset.
+func ResetFormFields(fileIn, fileOut string, fieldNames []string, config *pdfcpu.Configuration) error {
+	return updateForm(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+
+		n, err := pdfcpu.ResetFormFields(xRefTable, fieldNames)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("reset %d fields.\n", n)
+
+		return nil
+	})
+}
+
+// ListChoiceOptions returns a JSON representation of the options of the combo box or list box fieldName of fileIn.
+func ListChoiceOptions(fileIn, fieldName string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromList := time.Now()
+
+	opts, err := pdfcpu.ListChoiceOptions(ctx.XRefTable, fieldName)
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err := json.MarshalIndent(opts, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("list options         : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return []string{string(bb)}, nil
+}
+
+// AddChoiceOptions appends opts to the options of the combo box or list box fieldName of fileIn and writes the result to fileOut.
+func AddChoiceOptions(fileIn, fileOut, fieldName string, opts []pdfcpu.ChoiceOption, config *pdfcpu.Configuration) error {
+
+	if len(opts) == 0 {
+		return errors.New("AddChoiceOptions: missing options")
+	}
+
+	return updateForm(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		return pdfcpu.AddChoiceOptions(xRefTable, fieldName, opts)
+	})
+}
+
+// RemoveChoiceOptions removes the options with the export values of opts from the combo box or list box fieldName of fileIn
+// and writes the result to fileOut.
+func RemoveChoiceOptions(fileIn, fileOut, fieldName string, opts []pdfcpu.ChoiceOption, config *pdfcpu.Configuration) error {
+
+	if len(opts) == 0 {
+		return errors.New("RemoveChoiceOptions: missing options")
+	}
+
+	var values []string
+	for _, o := range opts {
+		values = append(values, o.Value)
+	}
+
+	return updateForm(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		return pdfcpu.RemoveChoiceOptions(xRefTable, fieldName, values)
+	})
+}
+
+// SetChoiceOptions replaces the options of the combo box or list box fieldName of fileIn by opts and writes the result to fileOut.
+func SetChoiceOptions(fileIn, fileOut, fieldName string, opts []pdfcpu.ChoiceOption, config *pdfcpu.Configuration) error {
+	return updateForm(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		return pdfcpu.SetChoiceOptions(xRefTable, fieldName, opts)
+	})
+}
+
+// ReadPKCS12 returns a signer using the private key and the certificate chain contained in the PKCS#12 file fileName read from config.FS.
+func ReadPKCS12(fileName, password string, config *pdfcpu.Configuration) (pdfcpu.Signer, error) {
+
+	b, err := readInput(fileName, config)
+	if err != nil {
+		return nil, err
+	}
+
+	key, certs, err := pdfcpu.DecodePKCS12(b, password)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", fileName)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("%s: unsupported private key", fileName)
+	}
+
+	return pdfcpu.NewSigner(signer, certs), nil
+}
+
+// Sign signs fileIn using signer.
+// The signature is appended to fileIn as an incremental update and the result is written to fileOut.
+func Sign(fileIn, fileOut string, sa *pdfcpu.SignatureAttributes, signer pdfcpu.Signer, config *pdfcpu.Configuration) error {
+
+	if sa == nil {
+		sa = &pdfcpu.SignatureAttributes{Page: 1}
+	}
+
+	fromStart := time.Now()
+
+	orig, err := readInput(fileIn, config)
+	if err != nil {
+		return err
+	}
+
+	// An incremental update has to leave the original file untouched, so there is no optimization.
+	ctx, durRead, durVal, err := readAndValidate(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	b, err := pdfcpu.Sign(ctx, orig, sa, signer)
+	if err != nil {
+		return err
+	}
+
+	durSign := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	if err = writeOutput(fileOut, b, config); err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("sign                 : %6.3fs  %4.1f%%\n", durSign, durSign/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// AddDocTimeStamp adds a document time stamp obtained from ts to fileIn using the signature field fieldName,
+// which gets created if necessary, and writes the result to fileOut.
+func AddDocTimeStamp(fileIn, fileOut, fieldName string, ts pdfcpu.Timestamper, config *pdfcpu.Configuration) error {
+
+	orig, err := readInput(fileIn, config)
+	if err != nil {
+		return err
+	}
+
+	ctx, _, _, err := readAndValidate(fileIn, config, time.Now())
+	if err != nil {
+		return err
+	}
+
+	b, err := pdfcpu.DocTimeStamp(ctx, orig, fieldName, ts)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(fileOut, b, config)
+}
+
+// EnableLTV embeds the certificates and revocation information needed to validate all signatures of fileIn
+// into its document security store and writes the result to fileOut.
+// Certificate chains are built using roots or the system roots if nil.
+func EnableLTV(fileIn, fileOut string, roots *x509.CertPool, config *pdfcpu.Configuration) error {
+
+	orig, err := readInput(fileIn, config)
+	if err != nil {
+		return err
+	}
+
+	ctx, _, _, err := readAndValidate(fileIn, config, time.Now())
+	if err != nil {
+		return err
+	}
+
+	b, err := pdfcpu.EnableLTV(ctx, orig, roots)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(fileOut, b, config)
+}
+
+// VerifySignatures verifies all signatures of fileIn using roots as trust anchors or the system roots if nil.
+func VerifySignatures(fileIn string, roots *x509.CertPool, config *pdfcpu.Configuration) ([]*pdfcpu.SignatureReport, error) {
+
+	b, err := readInput(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signed revisions are compared object by object, so there is no validation or optimization.
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.VerifySignatures(ctx, b, roots)
+}
+
+// ListRevisions returns the revisions of fileIn, the original document first.
+func ListRevisions(fileIn string, config *pdfcpu.Configuration) ([]*pdfcpu.Revision, error) {
+
+	b, err := readInput(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ListRevisions(ctx, b)
+}
+
+// ExtractRevision writes revision n of fileIn to fileOut, revision 1 being the original document.
+func ExtractRevision(fileIn, fileOut string, n int, config *pdfcpu.Configuration) error {
+
+	b, err := readInput(fileIn, config)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return err
+	}
+
+	rev, err := pdfcpu.ExtractRevision(ctx, b, n)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("writing revision %d to %s ...\n", n, fileOut)
+
+	return writeOutput(fileOut, rev, config)
+}
+
+// ValidatePDFA checks fileIn against the rules of the PDF/A conformance level given, one of 1b and 2b.
+func ValidatePDFA(fileIn, level string, config *pdfcpu.Configuration) (*pdfcpu.ComplianceReport, error) {
+
+	b, err := readInput(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("validating(mode=pdfa%s) %s ...\n", level, fileIn)
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ValidatePDFA(ctx, b, level)
+}
+
+// ValidatePDFX checks fileIn against the conditions of the PDF/X conformance level given, one of 1a and 4.
+func ValidatePDFX(fileIn, level string, config *pdfcpu.Configuration) (*pdfcpu.ComplianceReport, error) {
+
+	fmt.Printf("validating(mode=pdfx%s) %s ...\n", level, fileIn)
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ValidatePDFX(ctx, level)
+}
+
+// ValidatePDFUA checks fileIn against the accessibility requirements of PDF/UA-1.
+func ValidatePDFUA(fileIn string, config *pdfcpu.Configuration) (*pdfcpu.ComplianceReport, error) {
+
+	fmt.Printf("validating(mode=pdfua) %s ...\n", fileIn)
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ValidatePDFUA(ctx)
+}
+
+func updateOutputIntents(fileIn, fileOut string, config *pdfcpu.Configuration, op func(xRefTable *pdfcpu.XRefTable) error) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	if err = op(ctx.XRefTable); err != nil {
+		return err
+	}
+
+	durOp := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("output intents       : %6.3fs  %4.1f%%\n", durOp, durOp/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// ListOutputIntents returns the output intents of fileIn along with their destination output profiles.
+func ListOutputIntents(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ListOutputIntents(ctx)
+}
+
+// AddOutputIntent embeds the ICC profile profileFile as output intent of the given subtype into fileIn
+// and writes the result to fileOut.
+func AddOutputIntent(fileIn, profileFile, fileOut, subtype string, config *pdfcpu.Configuration) error {
+
+	b, err := config.ReadInput(profileFile)
+	if err != nil {
+		return err
+	}
+
+	return updateOutputIntents(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		return pdfcpu.AddOutputIntent(xRefTable, b, subtype, "")
+	})
+}
+
+// RemoveOutputIntents removes the output intents of the given subtypes, or all output intents if subtypes is empty,
+// from fileIn and writes the result to fileOut.
+func RemoveOutputIntents(fileIn, fileOut string, subtypes []string, config *pdfcpu.Configuration) error {
+
+	return updateOutputIntents(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		n, err := pdfcpu.RemoveOutputIntents(xRefTable, subtypes)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New("no matching output intents available")
+		}
+		return nil
+	})
+}
+
+// ExtractOutputIntentProfile writes the ICC profile embedded in the output intent of the given subtype of fileIn to profileFile.
+func ExtractOutputIntentProfile(fileIn, profileFile, subtype string, config *pdfcpu.Configuration) error {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return err
+	}
+
+	b, err := pdfcpu.OutputIntentProfile(ctx.XRefTable, subtype)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("writing %s ...\n", profileFile)
+
+	return config.WriteOutput(profileFile, b)
+}
+
+// ReplaceOutputIntentProfile replaces the ICC profile of the output intent of the given subtype of fileIn by profileFile
+// and writes the result to fileOut.
+func ReplaceOutputIntentProfile(fileIn, profileFile, fileOut, subtype string, config *pdfcpu.Configuration) error {
+
+	b, err := config.ReadInput(profileFile)
+	if err != nil {
+		return err
+	}
+
+	return updateOutputIntents(fileIn, fileOut, config, func(xRefTable *pdfcpu.XRefTable) error {
+		return pdfcpu.ReplaceOutputIntentProfile(xRefTable, subtype, b)
+	})
+}
+
+// ConvertToPDFA fixes the violations of the rules of the PDF/A conformance level given, one of 1b and 2b,
+// and writes the result to fileOut. Missing simple fonts are embedded using the TrueType fonts found in fontDir.
+// The report returned lists the fixes applied followed by the violations remaining in fileOut.
+func ConvertToPDFA(fileIn, fileOut, level, fontDir string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Now()
+
+	report, err := pdfcpu.ConvertToPDFA(ctx, level, fontDir)
+	if err != nil {
+		return nil, err
+	}
+
+	durConvert := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("convert to PDF/A     : %6.3fs  %4.1f%%\n", durConvert, durConvert/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	// Flag anything left unfixed.
+	b, err := readInput(fileOut, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx, err = pdfcpu.Read(bytes.NewReader(b), pdfcpu.NewDefaultConfiguration()); err != nil {
+		return nil, err
+	}
+
+	rep, err := pdfcpu.ValidatePDFA(ctx, b, level)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range rep.Rules {
+		for _, s := range r.Failures {
+			report = append(report, fmt.Sprintf("violation %s: %s", r.Clause, s))
+		}
+	}
+
+	if rep.Compliant {
+		report = append(report, fmt.Sprintf("%s is PDF/A-%s compliant.", fileOut, level))
+	}
+
+	return report, nil
+}
+
+// AddWatermarks adds watermarks to all pages selected.
+// In mode UPDATEWATERMARKS the watermarks added by pdfcpu before get replaced.
+func AddWatermarks(cmd *Command) ([]string, error) {
+
+	fileIn := *cmd.InFile
+	fileOut := *cmd.OutFile
+	config := cmd.Config
+
+	wmsel := cmd.Watermarks
+	if len(wmsel) == 0 {
+		wmsel = []WatermarkSelection{{PageSelection: cmd.PageSelection, Watermark: cmd.Watermark}}
+	}
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("%sing %s ...\n", wmsel[0].Watermark.OnTopString(), fileIn)
+
+	from := time.Now()
+
+	var (
+		selectedPages []pdfcpu.IntSet
+		wms           []*pdfcpu.Watermark
+	)
+
+	for _, sel := range wmsel {
+
+		pages, err := pagesForPageSelection(ctx.PageCount, sel.PageSelection)
+		if err != nil {
+			return nil, err
+		}
+
+		// With several selections a selection matching no pages must not fall back to all pages.
+		if len(wmsel) == 1 || len(sel.PageSelection) == 0 {
+			ensureSelectedPages(ctx, &pages)
+		}
+
+		sel.Watermark.SetFileName(filepath.Base(fileIn))
+
+		selectedPages = append(selectedPages, pages)
+		wms = append(wms, sel.Watermark)
+	}
+
+	if cmd.Mode == pdfcpu.UPDATEWATERMARKS {
+		err = pdfcpu.UpdateWatermarks(ctx.XRefTable, selectedPages, wms)
+	} else {
+		err = pdfcpu.AddMultipleWatermarks(ctx.XRefTable, selectedPages, wms)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	durStamp := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("watermark            : %6.3fs  %4.1f%%\n", durStamp, durStamp/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil, nil
+}
+
+// Redact applies the Redact annotations of selected pages and redacts the areas given by cmd.Redact.
+// Content within redacted areas is removed from the document and covered by opaque boxes.
+func Redact(cmd *Command) ([]string, error) {
+
+	fileIn := *cmd.InFile
+	fileOut := *cmd.OutFile
+	pageSelection := cmd.PageSelection
+	config := cmd.Config
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("redacting %s ...\n", fileIn)
+
+	from := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := pdfcpu.Redact(ctx.XRefTable, pages, cmd.Redact)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		fmt.Println("nothing redacted.")
+	} else {
+		fmt.Printf("redacted %d areas.\n", n)
+	}
+
+	durRedact := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("redact               : %6.3fs  %4.1f%%\n", durRedact, durRedact/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil, nil
+}
+
+// AddQRCodeStamp stamps all selected pages with a QR code linking to a verification URL.
+//
+// This takes two passes: First fileIn gets written to fileOut and the SHA-256 hash of the result is calculated.
+// Then the verification URL for this hash is encoded as QR code and appended to fileOut as an incremental update.
+// So the hash covers revision 1 of fileOut, which is everything but the stamps and may be recomputed
+// by hashing the output of ExtractRevision(fileOut, f, 1, config).
+// The hash and the URL are returned so the caller may register them with the verification service.
+func AddQRCodeStamp(cmd *Command) ([]string, error) {
+
+	fileIn := *cmd.InFile
+	fileOut := *cmd.OutFile
+	pageSelection := cmd.PageSelection
+	wm := cmd.Watermark
+	config := cmd.Config
+
+	if !wm.IsQRCode() {
+		return nil, errors.New("AddQRCodeStamp: missing QR code configuration")
+	}
+
+	fromStart := time.Now()
+
+	// 1st pass: write the document to be certified.
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+
+	fromHash := time.Now()
+
+	orig, err := readInput(fileOut, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revision 1 ends with the EOL separating it from the incremental update.
+	if !bytes.HasSuffix(orig, []byte("\n")) && !bytes.HasSuffix(orig, []byte("\r")) {
+		orig = append(orig, ctx.Write.Eol...)
+	}
+
+	h := sha256.Sum256(orig)
+	hash := hex.EncodeToString(h[:])
+
+	err = wm.SetQRCodeHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	durHash := time.Since(fromHash).Seconds()
+
+	// 2nd pass: stamp the certified document using an incremental update, so it stays untouched.
+
+	ctx, _, _, err = readAndValidate(fileOut, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	snap := ctx.Snapshot()
+
+	fmt.Printf("stamping %s ...\n", fileOut)
+
+	from := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	err = pdfcpu.AddWatermarks(ctx.XRefTable, pages, wm)
+	if err != nil {
+		return nil, err
+	}
+
+	durStamp := time.Since(from).Seconds()
+
+	fromWrite = time.Now()
+
+	fmt.Printf("writing %s ...\n", fileOut)
+
+	b, err := pdfcpu.WriteIncrement(ctx, orig, ctx.ModifiedObjects(snap))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = writeOutput(fileOut, b, config); err != nil {
+		return nil, err
+	}
+
+	durWrite += time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("hash                 : %6.3fs  %4.1f%%\n", durHash, durHash/durTotal*100)
+	log.Stats.Printf("stamp                : %6.3fs  %4.1f%%\n", durStamp, durStamp/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return []string{"SHA-256: " + hash, "URL: " + wm.QRCodeURL(hash)}, nil
+}
+
+// ListAnnotations returns a JSON representation of the annotations of selected pages.
+func ListAnnotations(fileIn string, pageSelection []string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromList := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	annots, err := pdfcpu.ListAnnotations(ctx.XRefTable, pages)
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err := json.MarshalIndent(annots, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("list annotations     : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return []string{string(bb)}, nil
+}
+
+// updateAnnotations applies an annotation operation to the selected pages of fileIn and writes the result to fileOut.
+func updateAnnotations(fileIn, fileOut string, pageSelection []string, config *pdfcpu.Configuration, opName string,
+	op func(xRefTable *pdfcpu.XRefTable, selectedPages pdfcpu.IntSet) (int, error)) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	n, err := op(ctx.XRefTable, pages)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		fmt.Printf("no annotation %s.\n", opName)
+	} else {
+		fmt.Printf("%s %d annotations.\n", opName, n)
+	}
+
+	durOp := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("annotations          : %6.3fs  %4.1f%%\n", durOp, durOp/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// RemoveAnnotations removes annotations of selected pages matching any of the given subtypes or object numbers.
+// Without subtypes and object numbers all annotations except form field widgets are removed.
+func RemoveAnnotations(fileIn, fileOut string, pageSelection, subtypes []string, objNrs []int, config *pdfcpu.Configuration) error {
+	return updateAnnotations(fileIn, fileOut, pageSelection, config, "removed",
+		func(xRefTable *pdfcpu.XRefTable, selectedPages pdfcpu.IntSet) (int, error) {
+			return pdfcpu.RemoveAnnotations(xRefTable, selectedPages, subtypes, objNrs)
+		})
+}
+
+// FlattenAnnotations draws annotations of selected pages matching any of the given subtypes or object numbers
+// into the page content and removes them.
+// Without subtypes and object numbers all annotations except form field widgets are flattened.
+func FlattenAnnotations(fileIn, fileOut string, pageSelection, subtypes []string, objNrs []int, config *pdfcpu.Configuration) error {
+	return updateAnnotations(fileIn, fileOut, pageSelection, config, "flattened",
+		func(xRefTable *pdfcpu.XRefTable, selectedPages pdfcpu.IntSet) (int, error) {
+			return pdfcpu.FlattenAnnotations(xRefTable, selectedPages, subtypes, objNrs)
+		})
+}
+
+// AddLinks adds link to the selected pages of fileIn and writes the result to fileOut.
+// Without link the URLs found in the text of selected pages are turned into links.
+func AddLinks(fileIn, fileOut string, pageSelection []string, link *pdfcpu.Link, config *pdfcpu.Configuration) error {
+	return updateAnnotations(fileIn, fileOut, pageSelection, config, "added",
+		func(xRefTable *pdfcpu.XRefTable, selectedPages pdfcpu.IntSet) (int, error) {
+
+			if link == nil {
+				return pdfcpu.LinkURLs(xRefTable, selectedPages)
+			}
+
+			var n int
+			for i := 1; i <= xRefTable.PageCount; i++ {
+				if len(selectedPages) > 0 && !selectedPages[i] {
+					continue
+				}
+				if err := pdfcpu.AddLink(xRefTable, i, link.Rect, link.Target); err != nil {
+					return 0, err
+				}
+				n++
+			}
+
+			return n, nil
+		})
+}
+
+// HighlightText marks up all occurrences of query within the text of selected pages of fileIn and writes the result to fileOut.
+func HighlightText(fileIn, fileOut string, pageSelection []string, query string, tmc *pdfcpu.TextMarkupConfig, config *pdfcpu.Configuration) error {
+	return updateAnnotations(fileIn, fileOut, pageSelection, config, "added",
+		func(xRefTable *pdfcpu.XRefTable, selectedPages pdfcpu.IntSet) (int, error) {
+			return pdfcpu.HighlightText(xRefTable, selectedPages, query, tmc)
+		})
+}
+
+// AddNotes adds a sticky note or a free text annotation to the selected pages of fileIn and writes the result to fileOut.
+func AddNotes(fileIn, fileOut string, pageSelection []string, n *pdfcpu.Note, freeText bool, config *pdfcpu.Configuration) error {
+	return updateAnnotations(fileIn, fileOut, pageSelection, config, "added",
+		func(xRefTable *pdfcpu.XRefTable, selectedPages pdfcpu.IntSet) (int, error) {
+
+			if n == nil {
+				return 0, errors.New("missing note")
+			}
+
+			add := pdfcpu.AddStickyNote
+			if freeText {
+				add = pdfcpu.AddFreeText
+			}
+
+			var count int
+			for i := 1; i <= xRefTable.PageCount; i++ {
+				if len(selectedPages) > 0 && !selectedPages[i] {
+					continue
+				}
+				note := *n
+				note.Page = i
+				if err := add(xRefTable, note); err != nil {
+					return 0, err
+				}
+				count++
+			}
+
+			return count, nil
+		})
+}
+
+// ExportAnnotations writes the markup annotations of fileIn to the XFDF file fileOut.
+func ExportAnnotations(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fromExport := time.Now()
+
+	bb, err := pdfcpu.ExportXFDFAnnotations(ctx.XRefTable, filepath.Base(fileIn))
+	if err != nil {
+		return err
+	}
+
+	err = config.WriteOutput(fileOut, bb)
+	if err != nil {
+		return err
+	}
+
+	durExport := time.Since(fromExport).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("export annotations   : %6.3fs  %4.1f%%\n", durExport, durExport/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// ImportAnnotations adds the markup annotations of the XFDF file fileXFDF to fileIn and writes the result to fileOut.
+func ImportAnnotations(fileIn, fileXFDF, fileOut string, config *pdfcpu.Configuration) error {
+
+	bb, err := config.ReadInput(fileXFDF)
+	if err != nil {
+		return err
+	}
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	n, err := pdfcpu.ImportXFDFAnnotations(ctx.XRefTable, bb)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d annotations.\n", n)
+
+	if ctx.XRefTable.Version() < pdfcpu.V15 {
+		v, _ := pdfcpu.Version("1.5")
+		ctx.XRefTable.RootVersion = &v
+		log.Stats.Println("Ensure V1.5 for markup annotation entries")
+	}
+
+	durImport := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("import annotations   : %6.3fs  %4.1f%%\n", durImport, durImport/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// ListJavaScript returns a JSON representation of the document level scripts and JavaScript actions of fileIn.
+func ListJavaScript(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromList := time.Now()
+
+	scripts, err := pdfcpu.ListJavaScript(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(scripts) == 0 {
+		return []string{"no JavaScript found."}, nil
+	}
+
+	bb, err := json.MarshalIndent(scripts, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("list JavaScript      : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return []string{string(bb)}, nil
+}
+
+// RemoveJavaScript removes the document level scripts and JavaScript actions of fileIn and writes the result to fileOut.
+func RemoveJavaScript(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	n, err := pdfcpu.RemoveJavaScript(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		fmt.Println("no JavaScript removed.")
+	} else {
+		fmt.Printf("removed %d scripts.\n", n)
+	}
+
+	durRemove := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("remove JavaScript    : %6.3fs  %4.1f%%\n", durRemove, durRemove/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// RemoveUsageRights removes the usage rights signatures of a Reader enabled fileIn and writes the result to fileOut.
+func RemoveUsageRights(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	removed, err := pdfcpu.RemoveUsageRights(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	if removed {
+		fmt.Println("removed usage rights.")
+	} else {
+		fmt.Println("no usage rights removed.")
+	}
+
+	durRemove := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("remove usage rights  : %6.3fs  %4.1f%%\n", durRemove, durRemove/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// Sanitize removes JavaScript, XFA forms, embedded files, metadata, thumbnails and prior revisions from fileIn
+// and, if external is set, all actions referring to external resources.
+// The result is written to fileOut. Sanitize returns a report of the items removed.
+func Sanitize(fileIn, fileOut string, external bool, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Now()
+
+	report, err := pdfcpu.Sanitize(ctx, external)
+	if err != nil {
+		return nil, err
+	}
+
+	durSanitize := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	if err = Write(ctx); err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("sanitize             : %6.3fs  %4.1f%%\n", durSanitize, durSanitize/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return report, nil
+}
+
+// Linearize writes fileIn as linearized file fileOut optimized for fast web view.
+func Linearize(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fromWrite := time.Now()
+
+	fmt.Printf("writing %s ...\n", fileOut)
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	if err = pdfcpu.WriteLinearizedFile(ctx); err != nil {
+		return errors.Wrap(err, "Linearize failed.")
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("write linearized     : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// CheckLinearization reports whether fileIn is validly linearized and lists any problems found.
+func CheckLinearization(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fmt.Printf("checking linearization of %s ...\n", fileIn)
+
+	bb, err := readInput(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := pdfcpu.LinearizationProblems(ctx, bb)
+	if len(ss) == 0 {
+		return []string{fmt.Sprintf("%s is linearized.", fileIn)}, nil
+	}
+
+	return append([]string{fmt.Sprintf("%s is not validly linearized:", fileIn)}, ss...), nil
+}
+
+// ListXMP returns the XMP metadata properties of fileIn sorted by name.
+func ListXMP(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := pdfcpu.ReadXMP(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if x == nil {
+		return []string{"no XMP metadata"}, nil
+	}
+
+	m := x.Properties()
+
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ss []string
+	for _, k := range keys {
+		ss = append(ss, fmt.Sprintf("%s = %s", k, m[k]))
+	}
+
+	return ss, nil
+}
+
+// SetXMP sets the XMP metadata properties props of fileIn, updates the document info dict accordingly
+// and writes the result to fileOut. An empty value removes a property.
+func SetXMP(fileIn, fileOut string, props map[string]string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	if err = pdfcpu.SetMetadata(ctx.XRefTable, props); err != nil {
+		return err
+	}
+
+	durSet := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	if err = Write(ctx); err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("set metadata         : %6.3fs  %4.1f%%\n", durSet, durSet/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// ListInfo returns the document info dict entries of fileIn sorted by key.
+func ListInfo(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ListInfo(ctx)
+}
+
+// SetInfo sets the document info dict entries props of fileIn and writes the result to fileOut.
+// An empty value removes an entry. Producer and ModDate are set on write, see Configuration.ProducerOverride.
+func SetInfo(fileIn, fileOut string, props map[string]string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	if err = pdfcpu.SetInfo(ctx, props); err != nil {
+		return err
+	}
+
+	durSet := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	if err = Write(ctx); err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("set info             : %6.3fs  %4.1f%%\n", durSet, durSet/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// RemoveInfo removes the document info dict entries keys of fileIn and writes the result to fileOut.
+func RemoveInfo(fileIn, fileOut string, keys []string, config *pdfcpu.Configuration) error {
+
+	props := map[string]string{}
+	for _, k := range keys {
+		props[k] = ""
+	}
+
+	return SetInfo(fileIn, fileOut, props, config)
+}
+
+// updateMetadata applies a metadata operation to fileIn and writes the result to fileOut.
+func updateMetadata(fileIn, fileOut string, config *pdfcpu.Configuration, op func(ctx *pdfcpu.PDFContext) error) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now()
+
+	if err = op(ctx); err != nil {
+		return err
+	}
+
+	durOp := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	if err = Write(ctx); err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("metadata             : %6.3fs  %4.1f%%\n", durOp, durOp/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// ListKeywords returns the keywords of fileIn.
+func ListKeywords(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	kk, err := pdfcpu.Keywords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(kk) == 0 {
+		return []string{"no keywords"}, nil
+	}
+
+	return kk, nil
+}
+
+// AddKeywords adds keywords to fileIn and writes the result to fileOut.
+// The keywords are kept in the document info dict and the XMP metadata.
+func AddKeywords(fileIn, fileOut string, keywords []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.AddKeywords(ctx, keywords)
+	})
+}
+
+// RemoveKeywords removes keywords from fileIn, all of them if keywords is empty, and writes the result to fileOut.
+func RemoveKeywords(fileIn, fileOut string, keywords []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.RemoveKeywords(ctx, keywords)
+	})
+}
+
+// ListProperties returns the custom document properties of fileIn sorted by name.
+func ListProperties(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ListProperties(ctx)
+}
+
+// AddProperties adds or replaces the custom document properties props of fileIn and writes the result to fileOut.
+func AddProperties(fileIn, fileOut string, props map[string]string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.AddProperties(ctx, props)
+	})
+}
+
+// RemoveProperties removes the custom document properties names from fileIn, all of them if names is empty,
+// and writes the result to fileOut.
+func RemoveProperties(fileIn, fileOut string, names []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.RemoveProperties(ctx, names)
+	})
+}
+
+func listBookmarks(bb []*pdfcpu.Bookmark, indent string, ss *[]string) {
+	for _, b := range bb {
+		s := indent + b.Title
+		switch {
+		case b.Page > 0:
+			s += fmt.Sprintf(" (page %d)", b.Page)
+		case b.Dest != "":
+			s += fmt.Sprintf(" (%s)", b.Dest)
+		case b.URI != "":
+			s += fmt.Sprintf(" (%s)", b.URI)
+		}
+		*ss = append(*ss, s)
+		listBookmarks(b.Kids, indent+"  ", ss)
+	}
+}
+
+// ListBookmarks returns the outline of fileIn indented by level.
+func ListBookmarks(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err := pdfcpu.Bookmarks(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bb) == 0 {
+		return []string{"no bookmarks"}, nil
+	}
+
+	var ss []string
+	listBookmarks(bb, "", &ss)
+
+	return ss, nil
+}
+
+// ExportBookmarks writes the outline of fileIn to fileOut.
+// The format is taken from the extension of fileOut: .json, .yaml or .yml
+func ExportBookmarks(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	ext := strings.ToLower(filepath.Ext(fileOut))
+	if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+		return errors.Errorf("ExportBookmarks: unsupported export format: %s", fileOut)
+	}
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return err
+	}
+
+	bb, err := pdfcpu.Bookmarks(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+
+	if ext == ".json" {
+		if b, err = pdfcpu.BookmarksJSON(bb); err != nil {
+			return err
+		}
+	} else {
+		b = pdfcpu.BookmarksYAML(bb)
+	}
+
+	return config.WriteOutput(fileOut, b)
+}
+
+// ReadBookmarks reads bookmarks from a JSON or YAML file as written by ExportBookmarks.
+func ReadBookmarks(fileName string, config *pdfcpu.Configuration) ([]*pdfcpu.Bookmark, error) {
+
+	b, err := config.ReadInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+
+	case ".json":
+		return pdfcpu.ParseBookmarksJSON(b)
+
+	case ".yaml", ".yml":
+		return pdfcpu.ParseBookmarksYAML(b)
+	}
+
+	return nil, errors.Errorf("ReadBookmarks: unsupported format: %s", fileName)
+}
+
+// ImportBookmarks replaces the outline of fileIn by the bookmarks read from bookmarkFile and writes the result to fileOut.
+func ImportBookmarks(fileIn, bookmarkFile, fileOut string, config *pdfcpu.Configuration) error {
+
+	bb, err := ReadBookmarks(bookmarkFile, config)
+	if err != nil {
+		return err
+	}
+
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.SetBookmarks(ctx.XRefTable, bb)
+	})
+}
+
+// GenerateBookmarks replaces the outline of fileIn by a bookmark for every n-th page and writes the result to fileOut.
+func GenerateBookmarks(fileIn, fileOut string, n int, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		bb, err := pdfcpu.GenerateBookmarks(ctx.PageCount, n)
+		if err != nil {
+			return err
+		}
+		return pdfcpu.SetBookmarks(ctx.XRefTable, bb)
+	})
+}
+
+// OCR lets engine recognize the images of the selected pages of fileIn, all pages for an empty page selection,
+// and writes the result with the recognized words as invisible text to fileOut. Pages already showing text are skipped.
+func OCR(fileIn, fileOut string, pageSelection []string, engine pdfcpu.OCREngine, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+		ensureSelectedPages(ctx, &pages)
+		n, err := pdfcpu.OCR(ctx.XRefTable, pages, engine)
+		if err != nil {
+			return err
+		}
+		log.Info.Printf("%d words recognized\n", n)
+		return nil
+	})
+}
+
+// ImportOCR lays the words of the hOCR or ALTO file ocrFile over the pages of fileIn as invisible text
+// and writes the result to fileOut. The pages of ocrFile go to the pages recorded in ocrFile
+// or, for a non empty page selection, to the selected pages in ascending order.
+func ImportOCR(fileIn, ocrFile, fileOut string, pageSelection []string, config *pdfcpu.Configuration) error {
+
+	b, err := config.ReadInput(ocrFile)
+	if err != nil {
+		return err
+	}
+
+	tls, err := pdfcpu.ParseOCR(b)
+	if err != nil {
+		return err
+	}
+
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+
+		if len(pages) > 0 {
+			var pageNrs []int
+			for i, ok := range pages {
+				if ok {
+					pageNrs = append(pageNrs, i)
+				}
+			}
+			sort.Ints(pageNrs)
+			if len(tls) > len(pageNrs) {
+				return errors.Errorf("ImportOCR: %s has %d pages, %d selected", ocrFile, len(tls), len(pageNrs))
+			}
+			for i := range tls {
+				tls[i].Page = pageNrs[i]
+			}
+		}
+
+		n, err := pdfcpu.AddTextLayers(ctx.XRefTable, tls)
+		if err != nil {
+			return err
+		}
+		log.Info.Printf("%d words imported\n", n)
+		return nil
+	})
+}
+
+// RemoveWatermarks removes the stamps (onTop) or watermarks added by pdfcpu from the selected pages of fileIn
+// and writes the result to fileOut.
+func RemoveWatermarks(fileIn, fileOut string, pageSelection []string, onTop bool, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+
+		ensureSelectedPages(ctx, &pages)
+
+		return pdfcpu.RemoveWatermarks(ctx.XRefTable, pages, onTop)
+	})
+}
+
+// ReadViewports reads a JSON array of geospatial viewports from fileName.
+func ReadViewports(fileName string, config *pdfcpu.Configuration) ([]pdfcpu.Viewport, error) {
+
+	b, err := config.ReadInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ParseViewportsJSON(b)
+}
+
+// ListViewports returns the viewports of fileIn along with their measurement or geo-registration.
+func ListViewports(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	vps, err := pdfcpu.Viewports(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vps) == 0 {
+		return []string{"no viewports"}, nil
+	}
+
+	var ss []string
+	for _, vp := range vps {
+		ss = append(ss, vp.String())
+	}
+
+	return ss, nil
+}
+
+// AddViewports georeferences fileIn by the geospatial viewports vps and writes the result to fileOut.
+func AddViewports(fileIn, fileOut string, vps []pdfcpu.Viewport, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.AddViewports(ctx.XRefTable, vps)
+	})
+}
+
+// RemoveViewports removes the viewports of the selected pages of fileIn, all pages for an empty page selection,
+// and writes the result to fileOut.
+func RemoveViewports(fileIn, fileOut string, pageSelection []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+		ensureSelectedPages(ctx, &pages)
+		n, err := pdfcpu.RemoveViewports(ctx.XRefTable, pages)
+		if err != nil {
+			return err
+		}
+		log.Info.Printf("viewports of %d pages removed\n", n)
+		return nil
+	})
+}
+
+// ListAssociatedFiles returns the files associated with the document, the pages and the objects of fileIn.
+func ListAssociatedFiles(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	aa, err := pdfcpu.AssociatedFiles(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(aa) == 0 {
+		return []string{"no associated files"}, nil
+	}
+
+	var ss []string
+
+	for _, af := range aa {
+		s := fmt.Sprintf("%s: %s", af.Owner(), af.File)
+		if af.Relationship != "" {
+			s += " " + af.Relationship
+		}
+		if af.Description != "" {
+			s += fmt.Sprintf(" %q", af.Description)
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// AddAssociatedFile embeds af.File, associates it with the document, a page or an object of fileIn
+// and writes the result to fileOut.
+func AddAssociatedFile(fileIn, fileOut string, af pdfcpu.AssociatedFile, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.AddAssociatedFile(ctx.XRefTable, af)
+	})
+}
+
+// ValidateAssociatedFiles checks the associated files of fileIn and returns the problems found.
+func ValidateAssociatedFiles(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ss, err := pdfcpu.ValidateAssociatedFiles(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ss) == 0 {
+		return []string{"associated files ok"}, nil
+	}
+
+	return ss, nil
+}
+
+// portfolioFile is the name of the portfolio description written by ExtractPortfolio.
+const portfolioFile = "portfolio.json"
+
+// PortfolioFromFiles returns a portfolio of files using the default columns.
+func PortfolioFromFiles(files []string) *pdfcpu.Portfolio {
+
+	p := &pdfcpu.Portfolio{}
+
+	for _, f := range files {
+		p.Members = append(p.Members, pdfcpu.PortfolioMember{File: f})
+	}
+
+	return p
+}
+
+// ReadPortfolio reads a portfolio description from a JSON file as written by ExtractPortfolio.
+// Relative member paths are relative to the directory of fileName.
+func ReadPortfolio(fileName string, config *pdfcpu.Configuration) (*pdfcpu.Portfolio, error) {
+
+	b, err := config.ReadInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := pdfcpu.ParsePortfolioJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(fileName)
+
+	for i, m := range p.Members {
+		if !filepath.IsAbs(m.File) {
+			p.Members[i].File = filepath.Join(dir, m.File)
+		}
+	}
+
+	return p, nil
+}
+
+// ListPortfolio returns the view, the columns and the members of the portfolio fileIn.
+func ListPortfolio(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := pdfcpu.PortfolioInfo(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if p == nil {
+		return []string{"no portfolio"}, nil
+	}
+
+	ss := []string{"view: " + p.View}
+
+	for _, f := range p.Fields {
+		s := fmt.Sprintf("field %s: %q %s", f.Key, f.Name, f.Type)
+		if f.Hidden {
+			s += " hidden"
+		}
+		ss = append(ss, s)
+	}
+
+	if len(p.Sort) > 0 {
+		order := "ascending"
+		if p.Descending {
+			order = "descending"
+		}
+		ss = append(ss, fmt.Sprintf("sort: %s %s", strings.Join(p.Sort, ", "), order))
+	}
+
+	if p.Initial != "" {
+		ss = append(ss, "initial: "+p.Initial)
+	}
+
+	for _, m := range p.Members {
+		s := m.File
+		if m.Description != "" {
+			s += fmt.Sprintf(" %q", m.Description)
+		}
+		var kk []string
+		for k := range m.Values {
+			kk = append(kk, k)
+		}
+		sort.Strings(kk)
+		for _, k := range kk {
+			s += fmt.Sprintf(" %s=%s", k, m.Values[k])
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// CreatePortfolio turns fileIn into a portfolio of the members of p and writes the result to fileOut.
+// fileIn becomes the cover sheet.
+func CreatePortfolio(fileIn, fileOut string, p *pdfcpu.Portfolio, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.CreatePortfolio(ctx.XRefTable, p)
+	})
+}
+
+// ExtractPortfolio writes all members of the portfolio fileIn to dirOut
+// along with a portfolio description recreating the portfolio, see ReadPortfolio.
+func ExtractPortfolio(fileIn, dirOut string, config *pdfcpu.Configuration) error {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return err
+	}
+
+	p, err := pdfcpu.PortfolioInfo(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	if p == nil {
+		return errors.Errorf("ExtractPortfolio: %s is no portfolio", fileIn)
+	}
+
+	ctx.Write.DirName = dirOut
+	if err = pdfcpu.AttachExtract(ctx, nil); err != nil {
+		return err
+	}
+
+	b, err := pdfcpu.PortfolioJSON(p)
+	if err != nil {
+		return err
+	}
+
+	return config.WriteOutput(filepath.Join(dirOut, portfolioFile), b)
+}
+
+// ListTransitions returns the page transitions and display durations of fileIn.
+func ListTransitions(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := pdfcpu.Transitions(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m) == 0 {
+		return []string{"no page transitions"}, nil
+	}
+
+	var ss []string
+
+	for i := 1; i <= ctx.PageCount; i++ {
+		if t, ok := m[i]; ok {
+			ss = append(ss, fmt.Sprintf("page %d: %s", i, t))
+		}
+	}
+
+	return ss, nil
+}
+
+// SetTransitions sets the transition t for the selected pages of fileIn, all pages for an empty page selection,
+// and writes the result to fileOut.
+func SetTransitions(fileIn, fileOut string, pageSelection []string, t pdfcpu.Transition, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+		ensureSelectedPages(ctx, &pages)
+		return pdfcpu.SetTransitions(ctx.XRefTable, pages, t)
+	})
+}
+
+// RemoveTransitions removes the page transitions and display durations of the selected pages of fileIn,
+// all pages for an empty page selection, and writes the result to fileOut.
+func RemoveTransitions(fileIn, fileOut string, pageSelection []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+		ensureSelectedPages(ctx, &pages)
+		n, err := pdfcpu.RemoveTransitions(ctx.XRefTable, pages)
+		if err != nil {
+			return err
+		}
+		log.Info.Printf("%d page transitions removed\n", n)
+		return nil
+	})
+}
+
+// ListViewerPreferences returns the presentation settings of fileIn.
+func ListViewerPreferences(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	vp, err := pdfcpu.ViewerPreferences(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var ss []string
+
+	add := func(k, v string) {
+		if v != "" {
+			ss = append(ss, fmt.Sprintf("%s: %s", k, v))
+		}
+	}
+
+	add("PageLayout", vp.PageLayout)
+	add("PageMode", vp.PageMode)
+
+	for _, f := range []struct {
+		k string
+		v *bool
+	}{
+		{"HideToolbar", vp.HideToolbar},
+		{"HideMenubar", vp.HideMenubar},
+		{"HideWindowUI", vp.HideWindowUI},
+		{"FitWindow", vp.FitWindow},
+		{"CenterWindow", vp.CenterWindow},
+		{"DisplayDocTitle", vp.DisplayDocTitle},
+	} {
+		if f.v != nil {
+			add(f.k, fmt.Sprintf("%t", *f.v))
+		}
+	}
+
+	add("Duplex", vp.Duplex)
+
+	if vp.OpenPage > 0 {
+		add("OpenAction", strings.TrimSpace(fmt.Sprintf("page %d %s", vp.OpenPage, vp.OpenView)))
+	}
+	add("OpenAction", vp.OpenAction)
+
+	if len(ss) == 0 {
+		return []string{"no viewer preferences"}, nil
+	}
+
+	return ss, nil
+}
+
+// SetViewerPreferences sets the non empty entries of vp for fileIn and writes the result to fileOut.
+func SetViewerPreferences(fileIn, fileOut string, vp pdfcpu.ViewerPrefs, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.SetViewerPreferences(ctx.XRefTable, vp)
+	})
+}
+
+// RemoveViewerPreferences removes the viewer preferences keys of fileIn and writes the result to fileOut.
+// Removing OpenAction gets rid of actions performed on opening.
+func RemoveViewerPreferences(fileIn, fileOut string, keys []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.RemoveViewerPreferences(ctx.XRefTable, keys)
+	})
+}
+
+// ListNamedDests returns the named destinations of fileIn sorted by name.
+func ListNamedDests(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	dd, err := pdfcpu.NamedDests(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dd) == 0 {
+		return []string{"no named destinations"}, nil
+	}
+
+	var ss []string
+
+	for _, d := range dd {
+		s := d.Name
+		if d.Page > 0 {
+			s += fmt.Sprintf(": page %d", d.Page)
+		}
+		if d.View != "" {
+			s += " " + d.View
+		}
+		if d.Legacy {
+			s += " (Dests dict)"
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// AddNamedDest adds the named destination dest to fileIn and writes the result to fileOut.
+func AddNamedDest(fileIn, fileOut string, dest pdfcpu.NamedDest, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.AddNamedDest(ctx.XRefTable, dest)
+	})
+}
+
+// RemoveNamedDests removes the named destinations names from fileIn and writes the result to fileOut.
+// Links and outline items referring to them jump to their explicit destinations instead.
+func RemoveNamedDests(fileIn, fileOut string, names []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.RemoveNamedDests(ctx.XRefTable, names)
+	})
+}
+
+// RenameNamedDest renames the named destination oldName of fileIn along with all references and writes the result to fileOut.
+func RenameNamedDest(fileIn, fileOut, oldName, newName string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.RenameNamedDest(ctx.XRefTable, oldName, newName)
+	})
+}
+
+// ListLayers returns the layers of fileIn along with their initial visibility followed by the membership dicts.
+func ListLayers(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ll, mm, err := pdfcpu.Layers(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ll) == 0 {
+		return []string{"no layers"}, nil
+	}
+
+	var ss []string
+
+	for _, l := range ll {
+		state := "visible"
+		if !l.Visible {
+			state = "hidden"
+		}
+		if l.Locked {
+			state += ", locked"
+		}
+		ss = append(ss, fmt.Sprintf("%s (%s)", l.Name, state))
+	}
+
+	for _, m := range mm {
+		ss = append(ss, fmt.Sprintf("membership obj#%d: %s %s", m.ObjNr, m.Policy, strings.Join(m.Layers, ", ")))
+	}
+
+	return ss, nil
+}
+
+// SetLayerVisibility sets the initial visibility of the layers names of fileIn and writes the result to fileOut.
+func SetLayerVisibility(fileIn, fileOut string, names []string, visible bool, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.SetLayerVisibility(ctx.XRefTable, names, visible)
+	})
+}
+
+// RenameLayer renames the layer oldName of fileIn and writes the result to fileOut.
+func RenameLayer(fileIn, fileOut, oldName, newName string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.RenameLayer(ctx.XRefTable, oldName, newName)
+	})
+}
+
+// RemoveLayers removes the layers names of fileIn along with their content and writes the result to fileOut.
+func RemoveLayers(fileIn, fileOut string, names []string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.RemoveLayers(ctx.XRefTable, names)
+	})
+}
+
+// FlattenLayers turns the visible layers of fileIn into unconditional content, drops hidden layers
+// and writes the result to fileOut.
+func FlattenLayers(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+	return updateMetadata(fileIn, fileOut, config, func(ctx *pdfcpu.PDFContext) error {
+		return pdfcpu.FlattenLayers(ctx.XRefTable)
+	})
+}
+
+// ListStructTree returns the structure elements of the tagged file fileIn along with their text.
+func ListStructTree(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, _, _, _, err := readValidateAndOptimize(fileIn, config, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.ListStructTree(ctx)
+}
+
+// ExportStructTree writes the logical structure of the tagged file fileIn to fileOut.
+// The format is taken from the extension of fileOut: .json or .html
+func ExportStructTree(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	var export func(*pdfcpu.PDFContext) ([]byte, error)
+
+	switch strings.ToLower(filepath.Ext(fileOut)) {
+
+	case ".json":
+		export = pdfcpu.ExportStructTreeJSON
+
+	case ".html", ".htm":
+		export = pdfcpu.ExportStructTreeHTML
+
+	default:
+		return errors.Errorf("ExportStructTree: unsupported export format: %s", fileOut)
+	}
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fromExport := time.Now()
+
+	bb, err := export(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = config.WriteOutput(fileOut, bb)
+	if err != nil {
+		return err
+	}
+
+	durExport := time.Since(fromExport).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("export structure     : %6.3fs  %4.1f%%\n", durExport, durExport/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// Repair reads a damaged fileIn on a best effort basis, drops broken pages and writes the result to fileOut.
+// It returns a report of the damage found.
+func Repair(fileIn, fileOut string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fmt.Printf("repairing %s ...\n", fileIn)
+
+	fromStart := time.Now()
+
+	config = config.With()
+	config.Mode = pdfcpu.REPAIR
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped, err := pdfcpu.DropBrokenPages(ctx.XRefTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "Repair failed.")
+	}
+
+	report := append(ctx.Read.Damage, dropped...)
+
+	durRead := time.Since(fromStart).Seconds()
+	fromValidate := time.Now()
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+
+		// Keep the pages only.
+		keys, err1 := pdfcpu.StripCatalog(ctx.XRefTable)
+		if err1 != nil || len(keys) == 0 {
+			return nil, errors.Wrap(err, "Repair failed.")
+		}
+
+		report = append(report, fmt.Sprintf("dropped document level entries %s: %v", strings.Join(keys, ", "), err))
+
+		if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+			return nil, errors.Wrap(err, "Repair failed.")
+		}
+	}
+
+	durVal := time.Since(fromValidate).Seconds()
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	if err = Write(ctx); err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	if len(report) == 0 {
+		report = []string{"no damage found"}
+	}
+
+	return report, nil
+}
+
+// ExportJSON writes the JSON representation of all objects of fileIn to fileOut.
+// If external is true the content of streams goes into separate files next to fileOut.
+func ExportJSON(fileIn, fileOut string, external bool, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return err
+	}
+
+	durRead := time.Since(fromStart).Seconds()
+	fromExport := time.Now()
+
+	var writeStream func(objNr int, raw []byte) (string, error)
+
+	if external {
+		dirOut := filepath.Dir(fileOut)
+		prefix := strings.TrimSuffix(filepath.Base(fileOut), filepath.Ext(fileOut))
+		writeStream = func(objNr int, raw []byte) (string, error) {
+			fileName := fmt.Sprintf("%s_%d.bin", prefix, objNr)
+			return fileName, config.WriteOutput(filepath.Join(dirOut, fileName), raw)
+		}
+	}
+
+	bb, err := pdfcpu.ExportJSON(ctx, writeStream)
+	if err != nil {
+		return err
+	}
+
+	if err = config.WriteOutput(fileOut, bb); err != nil {
+		return err
+	}
+
+	durExport := time.Since(fromExport).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("export JSON          : %6.3fs  %4.1f%%\n", durExport, durExport/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// ImportJSON creates the PDF file fileOut from the JSON representation fileIn as written by ExportJSON.
+func ImportJSON(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	bb, err := config.ReadInput(fileIn)
+	if err != nil {
+		return err
+	}
+
+	dirIn := filepath.Dir(fileIn)
+
+	// Stream files live next to fileIn.
+	readStream := func(fileName string) ([]byte, error) {
+		if fileName != filepath.Base(fileName) {
+			return nil, errors.Errorf("ImportJSON: invalid stream file name: %s", fileName)
+		}
+		return config.ReadInput(filepath.Join(dirIn, fileName))
+	}
+
+	ctx, err := pdfcpu.ImportJSON(bb, readStream, config)
+	if err != nil {
+		return err
+	}
+
+	durImport := time.Since(fromStart).Seconds()
+	fromValidate := time.Now()
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		return err
+	}
+
+	durVal := time.Since(fromValidate).Seconds()
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	if err = Write(ctx); err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("import JSON          : %6.3fs  %4.1f%%\n", durImport, durImport/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// Diff compares fileA with fileB and returns the differences found.
+func Diff(fileA, fileB string, config *pdfcpu.Configuration) (*pdfcpu.DiffReport, error) {
+
+	fromStart := time.Now()
+
+	ctxA, _, _, err := readAndValidate(fileA, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	ctxB, _, _, err := readAndValidate(fileB, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	durRead := time.Since(fromStart).Seconds()
+	fromDiff := time.Now()
+
+	r, err := pdfcpu.Diff(ctxA, ctxB)
+	if err != nil {
+		return nil, err
+	}
+
+	durDiff := time.Since(fromDiff).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read, validate       : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("diff                 : %6.3fs  %4.1f%%\n", durDiff, durDiff/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return r, nil
 }
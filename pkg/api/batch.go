@@ -0,0 +1,363 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/pkg/errors"
+)
+
+// Batch processing
+//
+// A batch applies a pipeline of commands to every PDF file found in a directory tree.
+// Each file gets copied to the corresponding path in the output directory first,
+// then all commands are run against this copy in order, leaving the input tree untouched.
+// The InFile and OutFile of a pipeline command serve as placeholders and get replaced for each file.
+
+// BatchConfig controls which files a batch processes and how.
+type BatchConfig struct {
+	Patterns        []string        // file name patterns matched case insensitively, defaults to *.pdf
+	Recursive       bool            // descend into sub directories
+	Jobs            int             // number of files processed concurrently, defaults to 1
+	ContinueOnError bool            // keep going after a file failed
+	Context         context.Context // stops scheduling files once done
+}
+
+// BatchResult is the outcome of processing a single file.
+type BatchResult struct {
+	FileIn   string
+	FileOut  string
+	Out      []string // collected output of all commands
+	Err      error
+	Skipped  bool // not processed because of an earlier failure or cancellation
+	Duration time.Duration
+}
+
+// BatchReport summarizes a batch run.
+type BatchReport struct {
+	Results  []*BatchResult // sorted by input file
+	Duration time.Duration
+}
+
+// Failed returns the number of files that failed.
+func (r *BatchReport) Failed() int {
+	i := 0
+	for _, res := range r.Results {
+		if res.Err != nil {
+			i++
+		}
+	}
+	return i
+}
+
+// Skipped returns the number of files not processed.
+func (r *BatchReport) Skipped() int {
+	i := 0
+	for _, res := range r.Results {
+		if res.Skipped {
+			i++
+		}
+	}
+	return i
+}
+
+// Summary returns a human readable report listing the failed files.
+func (r *BatchReport) Summary() []string {
+
+	var ss []string
+
+	for _, res := range r.Results {
+		if res.Err != nil {
+			ss = append(ss, fmt.Sprintf("%s: %v", res.FileIn, res.Err))
+		}
+	}
+
+	failed, skipped := r.Failed(), r.Skipped()
+	ok := len(r.Results) - failed - skipped
+
+	ss = append(ss, fmt.Sprintf("%d files: %d ok, %d failed, %d skipped in %.3fs", len(r.Results), ok, failed, skipped, r.Duration.Seconds()))
+
+	return ss
+}
+
+func (bc *BatchConfig) matches(relPath string) (bool, error) {
+
+	patterns := bc.Patterns
+	if len(patterns) == 0 {
+		patterns = []string{"*.pdf"}
+	}
+
+	relPath = strings.ToLower(filepath.ToSlash(relPath))
+
+	for _, p := range patterns {
+		p = strings.ToLower(filepath.ToSlash(p))
+		s := relPath
+		if !strings.Contains(p, "/") {
+			s = s[strings.LastIndex(s, "/")+1:]
+		}
+		ok, err := filepath.Match(p, s)
+		if err != nil {
+			return false, errors.Errorf("pdfcpu: invalid pattern: %s", p)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// BatchFiles returns the files below inDir matching bc relative to inDir in lexical order.
+func BatchFiles(inDir string, bc *BatchConfig) ([]string, error) {
+
+	var ff []string
+
+	err := filepath.Walk(inDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(inDir, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel != "." && !bc.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		ok, err := bc.matches(rel)
+		if ok {
+			ff = append(ff, rel)
+		}
+		return err
+	})
+
+	return ff, err
+}
+
+func copyBatchFile(fileIn, fileOut string, config *pdfcpu.Configuration) (err error) {
+
+	if config.CreateFile == nil {
+		if err = os.MkdirAll(filepath.Dir(fileOut), os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	from, err := config.OpenInput(fileIn)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	to, err := config.CreateOutput(fileOut)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := to.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = io.Copy(to, from)
+	return err
+}
+
+// batchFile runs cmds against a copy of fileIn written to fileOut
+// using the file system configured for the first command.
+func batchFile(fileIn, fileOut string, cmds []*Command) ([]string, error) {
+
+	if fileIn != fileOut {
+		config := cmds[0].Config
+		if config == nil {
+			config = pdfcpu.NewDefaultConfiguration()
+		}
+		if err := copyBatchFile(fileIn, fileOut, config); err != nil {
+			return nil, err
+		}
+	}
+
+	var out []string
+
+	for _, c := range cmds {
+
+		// Process modifies the command.
+		cmd := *c
+		cmd.InFile = &fileOut
+		if cmd.OutFile != nil {
+			cmd.OutFile = &fileOut
+		}
+
+		ss, err := Process(&cmd)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, ss...)
+	}
+
+	return out, nil
+}
+
+// Batch applies the pipeline cmds to all files below inDir matching bc
+// and writes the results to the corresponding paths below outDir.
+// If outDir is empty or equal to inDir the files get processed in place.
+// The returned error is the one of the first failing file in lexical order unless bc.ContinueOnError is set.
+func Batch(inDir, outDir string, cmds []*Command, bc *BatchConfig) (*BatchReport, error) {
+
+	if len(cmds) == 0 {
+		return nil, errors.New("pdfcpu: batch: missing commands")
+	}
+
+	if bc == nil {
+		bc = &BatchConfig{}
+	}
+
+	if outDir == "" {
+		outDir = inDir
+	}
+
+	from := time.Now()
+
+	ff, err := BatchFiles(inDir, bc)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &BatchReport{Results: make([]*BatchResult, len(ff))}
+	for i, f := range ff {
+		r.Results[i] = &BatchResult{FileIn: filepath.Join(inDir, f), FileOut: filepath.Join(outDir, f), Skipped: true}
+	}
+
+	jobs := bc.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		failed bool
+		wg     sync.WaitGroup
+	)
+
+	stop := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed && !bc.ContinueOnError || bc.Context != nil && bc.Context.Err() != nil
+	}
+
+	queue := make(chan *BatchResult)
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for res := range queue {
+				if stop() {
+					continue
+				}
+				t := time.Now()
+				res.Out, res.Err = batchFile(res.FileIn, res.FileOut, cmds)
+				res.Duration = time.Since(t)
+				res.Skipped = false
+				log.Info.Printf("batch: %s %.3fs\n", res.FileIn, res.Duration.Seconds())
+				if res.Err != nil {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, res := range r.Results {
+		queue <- res
+	}
+	close(queue)
+
+	wg.Wait()
+
+	r.Duration = time.Since(from)
+
+	if bc.Context != nil && bc.Context.Err() != nil {
+		return r, bc.Context.Err()
+	}
+
+	if !bc.ContinueOnError {
+		for _, res := range r.Results {
+			if res.Err != nil {
+				return r, errors.Wrap(res.Err, res.FileIn)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// BatchCommandNames lists the commands available for batch pipelines by name.
+var BatchCommandNames = []string{"decrypt", "encrypt", "linearize", "optimize", "repair", "rmrights", "sanitize", "validate"}
+
+// BatchCommands returns the pipeline for the given command names.
+// All commands share config, external applies to sanitize.
+func BatchCommands(names []string, external bool, config *pdfcpu.Configuration) ([]*Command, error) {
+
+	var cmds []*Command
+
+	for _, name := range names {
+
+		var c *Command
+
+		// The file names get set for each file processed.
+		switch strings.TrimSpace(name) {
+		case "decrypt":
+			c = DecryptCommand("", "", config)
+		case "encrypt":
+			c = EncryptCommand("", "", config)
+		case "linearize":
+			c = LinearizeCommand("", "", config)
+		case "optimize":
+			c = OptimizeCommand("", "", config)
+		case "repair":
+			c = RepairCommand("", "", config)
+		case "rmrights":
+			c = RemoveUsageRightsCommand("", "", config)
+		case "sanitize":
+			c = SanitizeCommand("", "", external, config)
+		case "validate":
+			c = ValidateCommand("", config)
+		default:
+			return nil, errors.Errorf("pdfcpu: batch: unsupported command %q, use one of %s", name, strings.Join(BatchCommandNames, ", "))
+		}
+
+		cmds = append(cmds, c)
+	}
+
+	return cmds, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+)
+
+// Benchmark suite
+//
+// BenchmarkCorpus measures every processing phase for a corpus of representative files
+// read from and written to memory, so disk I/O does not get measured.
+// Compare runs before and after a change in order to detect performance regressions:
+//
+//	go test ./pkg/api -run NONE -bench Corpus -benchmem -count 10 > old.txt
+//	go test ./pkg/api -run NONE -bench Corpus -benchmem -count 10 > new.txt
+//	benchstat old.txt new.txt
+//
+// Restrict a run to a phase or file using eg. -bench Corpus/optimize/gobook.
+// CPU profiles taken with -cpuprofile carry the label pdfcpu=<phase>.
+
+// benchCorpus covers the characteristics of PDF files affecting performance.
+var benchCorpus = []string{
+	"go.pdf",                          // Small text only document.
+	"gobook.0.pdf",                    // Many pages sharing lots of fonts and images.
+	"ProgrammingInJava.pdf",           // Large page tree.
+	"CenterOfWhy.pdf",                 // Image heavy.
+	"Hybrid-PDF.pdf",                  // Hybrid reference file.
+	"adobe_supplement_iso32000_1.pdf", // Object streams and xref streams.
+	"Acroforms2.pdf",                  // Forms.
+}
+
+func benchConfig() *pdfcpu.Configuration {
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ProfileLabels = true
+	return config
+}
+
+// benchRead reads buf into a new PDFContext going through the phases up to but excluding phase.
+func benchRead(b *testing.B, buf []byte, phase string) *pdfcpu.PDFContext {
+
+	ctx, err := pdfcpu.Read(bytes.NewReader(buf), benchConfig())
+	if err != nil {
+		b.Fatalf("read: %v\n", err)
+	}
+
+	if phase == pdfcpu.PhaseValidate {
+		return ctx
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		b.Fatalf("validate: %v\n", err)
+	}
+
+	if phase == pdfcpu.PhaseOptimize {
+		return ctx
+	}
+
+	if err = pdfcpu.OptimizeXRefTable(ctx); err != nil {
+		b.Fatalf("optimize: %v\n", err)
+	}
+
+	return ctx
+}
+
+func benchPhase(b *testing.B, buf []byte, phase string) {
+
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+
+		if phase == pdfcpu.PhaseRead {
+			if _, err := pdfcpu.Read(bytes.NewReader(buf), benchConfig()); err != nil {
+				b.Fatalf("read: %v\n", err)
+			}
+			continue
+		}
+
+		// Every phase needs a fresh context since validation and optimization modify it.
+		b.StopTimer()
+		ctx := benchRead(b, buf, phase)
+		b.StartTimer()
+
+		var err error
+
+		switch phase {
+		case pdfcpu.PhaseValidate:
+			err = pdfcpu.ValidateXRefTable(ctx.XRefTable)
+		case pdfcpu.PhaseOptimize:
+			err = pdfcpu.OptimizeXRefTable(ctx)
+		case pdfcpu.PhaseWrite:
+			err = pdfcpu.WritePDF(ctx, ioutil.Discard)
+		}
+
+		if err != nil {
+			b.Fatalf("%s: %v\n", phase, err)
+		}
+	}
+}
+
+func BenchmarkCorpus(b *testing.B) {
+
+	for _, phase := range []string{pdfcpu.PhaseRead, pdfcpu.PhaseValidate, pdfcpu.PhaseOptimize, pdfcpu.PhaseWrite} {
+
+		b.Run(phase, func(b *testing.B) {
+
+			for _, fileName := range benchCorpus {
+
+				buf, err := ioutil.ReadFile(filepath.Join(inDir, fileName))
+				if err != nil {
+					b.Fatalf("BenchmarkCorpus: %v\n", err)
+				}
+
+				b.Run(fileName, func(b *testing.B) { benchPhase(b, buf, phase) })
+			}
+		})
+	}
+}
@@ -24,14 +24,11 @@ import (
 
 func exampleProcessValidate() {
 
-	config := pdfcpu.NewDefaultConfiguration()
-
-	// Set optional password(s).
-	//config.UserPW = "upw"
-	//config.OwnerPW = "opw"
-
-	// Set relaxed validation mode.
-	config.ValidationMode = pdfcpu.ValidationRelaxed
+	// Set relaxed validation mode and optional password(s).
+	config := pdfcpu.NewConfiguration(
+		pdfcpu.WithValidationMode(pdfcpu.ValidationRelaxed),
+		//pdfcpu.WithPassword("upw", "opw"),
+	)
 
 	_, err := Process(ValidateCommand("in.pdf", config))
 	if err != nil {
@@ -313,6 +310,22 @@ func exampleProcessAddPermissions() {
 
 }
 
+func exampleProcessSetPermissions() {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+
+	// Allow printing in full quality and filling in forms only.
+	config.SetPermissions(pdfcpu.Permissions{Print: true, PrintHighRes: true, FillForms: true})
+
+	_, err := Process(AddPermissionsCommand("in.pdf", config))
+	if err != nil {
+		return
+	}
+
+}
+
 func exampleProcessStamp() {
 
 	// Stamp all but the first page.
@@ -0,0 +1,184 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/pkg/errors"
+)
+
+// Pipelines
+//
+// Every file based command reads, validates and writes the whole file.
+// A pipeline chains several operations on a single PDFContext instead:
+//
+//	err := api.Open("in.pdf", config).
+//		Decrypt().
+//		RemovePages([]string{"1"}).
+//		AddWatermarks(nil, wm).
+//		Optimize().
+//		Encrypt().
+//		Write("out.pdf")
+//
+// The first failing operation stops the pipeline, its error gets returned by Write or Err.
+// Encryption is done on write using the passwords, key length and permissions of config.
+
+// Pipeline applies a sequence of operations to a PDF read once and written once.
+type Pipeline struct {
+	ctx *pdfcpu.PDFContext
+	err error
+}
+
+func openPipeline(ctx *pdfcpu.PDFContext, err error) *Pipeline {
+
+	if err != nil {
+		return &Pipeline{err: err}
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		return &Pipeline{err: err}
+	}
+
+	return &Pipeline{ctx: ctx}
+}
+
+// pipelineConfig returns a copy of config to be owned by the pipeline.
+func pipelineConfig(config *pdfcpu.Configuration) *pdfcpu.Configuration {
+	c := config.With()
+	c.Mode = pdfcpu.OPTIMIZE
+	return c
+}
+
+// Open starts a pipeline by reading and validating fileIn.
+func Open(fileIn string, config *pdfcpu.Configuration) *Pipeline {
+	return openPipeline(Read(fileIn, pipelineConfig(config)))
+}
+
+// OpenContext starts a pipeline by reading and validating the PDF read from rs.
+func OpenContext(rs io.ReadSeeker, config *pdfcpu.Configuration) *Pipeline {
+	return openPipeline(ReadContext(rs, pipelineConfig(config)))
+}
+
+// Err returns the error of the first failed operation.
+func (p *Pipeline) Err() error {
+	return p.err
+}
+
+// Context returns the PDFContext the pipeline operates on.
+func (p *Pipeline) Context() (*pdfcpu.PDFContext, error) {
+	return p.ctx, p.err
+}
+
+// Apply runs f unless an earlier operation failed.
+func (p *Pipeline) Apply(name string, f func(ctx *pdfcpu.PDFContext) error) *Pipeline {
+
+	if p.err != nil {
+		return p
+	}
+
+	log.Info.Printf("pipeline: %s\n", name)
+
+	if err := f(p.ctx); err != nil {
+		p.err = errors.Wrapf(err, "pipeline: %s", name)
+	}
+
+	return p
+}
+
+// Decrypt removes the encryption on write.
+func (p *Pipeline) Decrypt() *Pipeline {
+	return p.Apply("decrypt", func(ctx *pdfcpu.PDFContext) error {
+		if ctx.Encrypt == nil {
+			return pdfcpu.ErrNotEncrypted
+		}
+		ctx.Mode = pdfcpu.DECRYPT
+		return nil
+	})
+}
+
+// Encrypt encrypts on write using the passwords and encryption settings of the configuration.
+func (p *Pipeline) Encrypt() *Pipeline {
+	return p.Apply("encrypt", pdfcpu.PrepareEncryption)
+}
+
+// RemovePages removes the selected pages.
+// Page numbers of later operations refer to the remaining pages.
+func (p *Pipeline) RemovePages(pageSelection []string) *Pipeline {
+	return p.Apply("remove pages", func(ctx *pdfcpu.PDFContext) error {
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+		if len(pages) == 0 {
+			return errors.New("missing page selection")
+		}
+		return pdfcpu.RemovePages(ctx.XRefTable, pages)
+	})
+}
+
+// AddWatermarks adds wm as stamp or watermark to the selected pages, all pages for an empty page selection.
+func (p *Pipeline) AddWatermarks(pageSelection []string, wm *pdfcpu.Watermark) *Pipeline {
+	return p.Apply("add watermarks", func(ctx *pdfcpu.PDFContext) error {
+		pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+		if err != nil {
+			return err
+		}
+		ensureSelectedPages(ctx, &pages)
+		if ctx.Read.FileName != "" {
+			wm.SetFileName(filepath.Base(ctx.Read.FileName))
+		}
+		return pdfcpu.AddWatermarks(ctx.XRefTable, pages, wm)
+	})
+}
+
+// Optimize gets rid of duplicate fonts, images and streams, unused resources and unreachable objects.
+// The optimization runs once, preferably after all operations adding content.
+func (p *Pipeline) Optimize() *Pipeline {
+	return p.Apply("optimize", func(ctx *pdfcpu.PDFContext) error {
+		if ctx.Optimized {
+			log.Info.Println("pipeline: already optimized")
+			return nil
+		}
+		return pdfcpu.OptimizeXRefTable(ctx)
+	})
+}
+
+// Write writes the result to fileOut and returns the error of the first failed operation.
+func (p *Pipeline) Write(fileOut string) error {
+
+	if p.err != nil {
+		return p.err
+	}
+
+	p.ctx.Write.DirName, p.ctx.Write.FileName = filepath.Split(fileOut)
+
+	return Write(p.ctx)
+}
+
+// WriteContext writes the result to w and returns the error of the first failed operation.
+func (p *Pipeline) WriteContext(w io.Writer) error {
+
+	if p.err != nil {
+		return p.err
+	}
+
+	return WriteContext(p.ctx, w)
+}
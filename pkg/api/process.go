@@ -17,6 +17,9 @@ limitations under the License.
 package api
 
 import (
+	"crypto/x509"
+	"encoding/json"
+
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
 	"github.com/pkg/errors"
 )
@@ -34,6 +37,48 @@ type Command struct {
 	PWOld         *string               //    -         -        -      -       -      -      -       -       -      -       -        -         *          *       -     -       -
 	PWNew         *string               //    -         -        -      -       -      -      -       -       -      -       -        -         *          *       -     -       -
 	Watermark     *pdfcpu.Watermark     //    -         -        -      -       -      -      -       -       -      -       -        -         -          -       -     -       -
+	Watermarks    []WatermarkSelection  // watermark, stamp: watermarks for distinct page selections in one pass
+	OnTop         bool                  // stamp remove: stamps rather than watermarks
+	MultiFill     *pdfcpu.MultiFillConfig
+	Subtypes      []string // annotation subtypes
+	ObjNrs        []int    // object numbers
+	Link          *pdfcpu.Link
+	TextMarkup    *pdfcpu.TextMarkupConfig
+	Query         string // text search
+	Note          *pdfcpu.Note
+	Redact        *pdfcpu.RedactConfig
+	TabOrder      string   // R, C or S
+	FieldNames    []string // form field names
+	Field         *pdfcpu.FieldAttributes
+	Label         string // signature field placeholder label
+	FieldName     string
+	Options       []pdfcpu.ChoiceOption
+	Signature     *pdfcpu.SignatureAttributes
+	Signer        pdfcpu.Signer
+	Roots         *x509.CertPool // trust anchors for signature verification, nil for the system roots
+	Timestamper   pdfcpu.Timestamper
+	External      bool   // sanitize: remove actions referring to external resources, export-json: write streams to separate files
+	Revision      int    // revisions extract: 1 for the original document
+	Level         string // PDF/A or PDF/X conformance level
+	FontDir       string // pdfa: directory of TrueType fonts for embedding missing fonts
+	ProfileFile   string // intent: ICC profile
+	OutputIntent  string // intent: GTS_PDFX or GTS_PDFA1
+	Metadata      map[string]string
+	JSONOutput    bool                   // diff: machine-readable output
+	Keywords      []string               // keywords add, remove
+	Pipeline      []*Command             // batch: commands applied to each file
+	Batch         *BatchConfig           // batch: file selection and scheduling
+	BookmarkFile  string                 // bookmarks import: JSON or YAML file
+	Interval      int                    // bookmarks generate: one bookmark every Interval pages
+	NamedDest     *pdfcpu.NamedDest      // dests add
+	Names         []string               // dests, layers: names to process, rename: old and new name, viewer remove: keys
+	ViewerPrefs   *pdfcpu.ViewerPrefs    // viewer set, open
+	Transition    *pdfcpu.Transition     // transitions set
+	Portfolio     *pdfcpu.Portfolio      // portfolio create
+	AF            *pdfcpu.AssociatedFile // af add
+	Viewports     []pdfcpu.Viewport      // geo add
+	OCR           pdfcpu.OCREngine       // ocr
+	OCRFile       string                 // import-ocr: hOCR or ALTO file
 }
 
 // Process executes a pdfcpu command.
@@ -46,29 +91,130 @@ func Process(cmd *Command) (out []string, err error) {
 		}
 	}()
 
+	// Leave the caller's configuration untouched so it may be shared by concurrent calls.
+	cmd.Config = cmd.Config.With()
 	cmd.Config.Mode = cmd.Mode
 
 	for k, v := range map[pdfcpu.CommandMode]func(cmd *Command) ([]string, error){
-		pdfcpu.VALIDATE:           Validate,
-		pdfcpu.OPTIMIZE:           Optimize,
-		pdfcpu.SPLIT:              Split,
-		pdfcpu.MERGE:              Merge,
-		pdfcpu.EXTRACTIMAGES:      ExtractImages,
-		pdfcpu.EXTRACTFONTS:       ExtractFonts,
-		pdfcpu.EXTRACTPAGES:       ExtractPages,
-		pdfcpu.EXTRACTCONTENT:     ExtractContent,
-		pdfcpu.TRIM:               Trim,
-		pdfcpu.ADDWATERMARKS:      AddWatermarks,
-		pdfcpu.LISTATTACHMENTS:    processAttachments,
-		pdfcpu.ADDATTACHMENTS:     processAttachments,
-		pdfcpu.REMOVEATTACHMENTS:  processAttachments,
-		pdfcpu.EXTRACTATTACHMENTS: processAttachments,
-		pdfcpu.ENCRYPT:            processEncryption,
-		pdfcpu.DECRYPT:            processEncryption,
-		pdfcpu.CHANGEUPW:          processEncryption,
-		pdfcpu.CHANGEOPW:          processEncryption,
-		pdfcpu.LISTPERMISSIONS:    processPermissions,
-		pdfcpu.ADDPERMISSIONS:     processPermissions,
+		pdfcpu.VALIDATE:            Validate,
+		pdfcpu.OPTIMIZE:            Optimize,
+		pdfcpu.SPLIT:               Split,
+		pdfcpu.MERGE:               Merge,
+		pdfcpu.EXTRACTIMAGES:       ExtractImages,
+		pdfcpu.EXTRACTFONTS:        ExtractFonts,
+		pdfcpu.EXTRACTPAGES:        ExtractPages,
+		pdfcpu.EXTRACTCONTENT:      ExtractContent,
+		pdfcpu.TRIM:                Trim,
+		pdfcpu.ADDWATERMARKS:       AddWatermarks,
+		pdfcpu.ADDQRCODESTAMP:      AddQRCodeStamp,
+		pdfcpu.REDACT:              Redact,
+		pdfcpu.LISTATTACHMENTS:     processAttachments,
+		pdfcpu.ADDATTACHMENTS:      processAttachments,
+		pdfcpu.REMOVEATTACHMENTS:   processAttachments,
+		pdfcpu.EXTRACTATTACHMENTS:  processAttachments,
+		pdfcpu.ADDPAGEATTACHMENTS:  processAttachments,
+		pdfcpu.ENCRYPT:             processEncryption,
+		pdfcpu.DECRYPT:             processEncryption,
+		pdfcpu.CHANGEUPW:           processEncryption,
+		pdfcpu.CHANGEOPW:           processEncryption,
+		pdfcpu.LISTPERMISSIONS:     processPermissions,
+		pdfcpu.ADDPERMISSIONS:      processPermissions,
+		pdfcpu.LISTFORMFIELDS:      processForm,
+		pdfcpu.FILLFORM:            processForm,
+		pdfcpu.EXPORTFORM:          processForm,
+		pdfcpu.MULTIFILLFORM:       processForm,
+		pdfcpu.FIELDAPPEARANCES:    processForm,
+		pdfcpu.LISTFORMORDER:       processForm,
+		pdfcpu.SETTABORDER:         processForm,
+		pdfcpu.SETCALCULATIONORDER: processForm,
+		pdfcpu.ADDSIGNATUREFIELD:   processForm,
+		pdfcpu.LISTCHOICEOPTIONS:   processForm,
+		pdfcpu.ADDCHOICEOPTIONS:    processForm,
+		pdfcpu.REMOVECHOICEOPTIONS: processForm,
+		pdfcpu.SETCHOICEOPTIONS:    processForm,
+		pdfcpu.RESETFORM:           processForm,
+		pdfcpu.SIGN:                processSignatures,
+		pdfcpu.VERIFYSIGNATURES:    processSignatures,
+		pdfcpu.DOCTIMESTAMP:        processSignatures,
+		pdfcpu.ENABLELTV:           processSignatures,
+		pdfcpu.LISTANNOTATIONS:     processAnnotations,
+		pdfcpu.REMOVEANNOTATIONS:   processAnnotations,
+		pdfcpu.FLATTENANNOTATIONS:  processAnnotations,
+		pdfcpu.EXPORTANNOTATIONS:   processAnnotations,
+		pdfcpu.IMPORTANNOTATIONS:   processAnnotations,
+		pdfcpu.ADDLINKS:            processAnnotations,
+		pdfcpu.MARKUPTEXT:          processAnnotations,
+		pdfcpu.ADDNOTES:            processAnnotations,
+		pdfcpu.ADDFREETEXTS:        processAnnotations,
+		pdfcpu.LISTJAVASCRIPT:      processJavaScript,
+		pdfcpu.REMOVEJAVASCRIPT:    processJavaScript,
+		pdfcpu.SANITIZE:            processSanitize,
+		pdfcpu.LISTREVISIONS:       processRevisions,
+		pdfcpu.EXTRACTREVISION:     processRevisions,
+		pdfcpu.REMOVEUSAGERIGHTS:   processRemoveUsageRights,
+		pdfcpu.VALIDATEPDFA:        processValidateCompliance,
+		pdfcpu.VALIDATEPDFX:        processValidateCompliance,
+		pdfcpu.VALIDATEPDFUA:       processValidateCompliance,
+		pdfcpu.CONVERTPDFA:         processConvertToPDFA,
+		pdfcpu.ADDOUTPUTINTENT:     processOutputIntents,
+		pdfcpu.LISTOUTPUTINTENTS:   processOutputIntents,
+		pdfcpu.REMOVEOUTPUTINTENTS: processOutputIntents,
+		pdfcpu.EXTRACTOUTPUTINTENT: processOutputIntents,
+		pdfcpu.REPLACEOUTPUTINTENT: processOutputIntents,
+		pdfcpu.LINEARIZE:           processLinearization,
+		pdfcpu.CHECKLINEARIZATION:  processLinearization,
+		pdfcpu.LISTXMP:             processXMP,
+		pdfcpu.SETXMP:              processXMP,
+		pdfcpu.LISTINFO:            processInfo,
+		pdfcpu.SETINFO:             processInfo,
+		pdfcpu.REMOVEINFO:          processInfo,
+		pdfcpu.LISTKEYWORDS:        processKeywords,
+		pdfcpu.ADDKEYWORDS:         processKeywords,
+		pdfcpu.REMOVEKEYWORDS:      processKeywords,
+		pdfcpu.LISTPROPERTIES:      processProperties,
+		pdfcpu.ADDPROPERTIES:       processProperties,
+		pdfcpu.REMOVEPROPERTIES:    processProperties,
+		pdfcpu.LISTSTRUCTTREE:      processStructTree,
+		pdfcpu.EXPORTSTRUCTTREE:    processStructTree,
+		pdfcpu.REPAIR:              processRepair,
+		pdfcpu.EXPORTJSON:          processJSON,
+		pdfcpu.IMPORTJSON:          processJSON,
+		pdfcpu.DIFF:                processDiff,
+		pdfcpu.BATCH:               processBatch,
+		pdfcpu.LISTBOOKMARKS:       processBookmarks,
+		pdfcpu.EXPORTBOOKMARKS:     processBookmarks,
+		pdfcpu.IMPORTBOOKMARKS:     processBookmarks,
+		pdfcpu.GENERATEBOOKMARKS:   processBookmarks,
+		pdfcpu.LISTNAMEDDESTS:      processNamedDests,
+		pdfcpu.ADDNAMEDDEST:        processNamedDests,
+		pdfcpu.REMOVENAMEDDESTS:    processNamedDests,
+		pdfcpu.RENAMENAMEDDEST:     processNamedDests,
+		pdfcpu.LISTLAYERS:          processLayers,
+		pdfcpu.SHOWLAYERS:          processLayers,
+		pdfcpu.HIDELAYERS:          processLayers,
+		pdfcpu.RENAMELAYER:         processLayers,
+		pdfcpu.REMOVELAYERS:        processLayers,
+		pdfcpu.FLATTENLAYERS:       processLayers,
+		pdfcpu.LISTVIEWERPREFS:     processViewerPreferences,
+		pdfcpu.SETVIEWERPREFS:      processViewerPreferences,
+		pdfcpu.REMOVEVIEWERPREFS:   processViewerPreferences,
+		pdfcpu.LISTTRANSITIONS:     processTransitions,
+		pdfcpu.SETTRANSITIONS:      processTransitions,
+		pdfcpu.REMOVETRANSITIONS:   processTransitions,
+		pdfcpu.LISTPORTFOLIO:       processPortfolio,
+		pdfcpu.CREATEPORTFOLIO:     processPortfolio,
+		pdfcpu.EXTRACTPORTFOLIO:    processPortfolio,
+		pdfcpu.LISTASSOCFILES:      processAssociatedFiles,
+		pdfcpu.ADDASSOCFILE:        processAssociatedFiles,
+		pdfcpu.VALIDATEASSOCFILES:  processAssociatedFiles,
+		pdfcpu.LISTVIEWPORTS:       processViewports,
+		pdfcpu.ADDVIEWPORTS:        processViewports,
+		pdfcpu.REMOVEVIEWPORTS:     processViewports,
+		pdfcpu.EXTRACTMEDIA:        ExtractMedia,
+		pdfcpu.OCRPAGES:            processOCR,
+		pdfcpu.IMPORTOCR:           processImportOCR,
+		pdfcpu.REMOVEWATERMARKS:    processRemoveWatermarks,
+		pdfcpu.UPDATEWATERMARKS:    AddWatermarks,
 	} {
 		if cmd.Mode == k {
 			return v(cmd)
@@ -86,6 +232,24 @@ func ValidateCommand(pdfFileName string, config *pdfcpu.Configuration) *Command
 		Config: config}
 }
 
+// ValidatePagesCommand creates a new command to validate selected pages of a file.
+func ValidatePagesCommand(pdfFileName string, pageSelection []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.VALIDATE,
+		InFile:        &pdfFileName,
+		PageSelection: pageSelection,
+		Config:        config}
+}
+
+// ValidateObjectsCommand creates a new command to validate selected objects of a file.
+func ValidateObjectsCommand(pdfFileName string, objNrs []int, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.VALIDATE,
+		InFile: &pdfFileName,
+		ObjNrs: objNrs,
+		Config: config}
+}
+
 // OptimizeCommand creates a new command to optimize a file.
 func OptimizeCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
 	return &Command{
@@ -156,6 +320,16 @@ func ExtractContentCommand(pdfFileNameIn, dirNameOut string, pageSelection []str
 		Config:        config}
 }
 
+// ExtractMediaCommand creates a new command to extract the 3D streams and media assets of annotations.
+func ExtractMediaCommand(pdfFileNameIn, dirNameOut string, pageSelection []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.EXTRACTMEDIA,
+		InFile:        &pdfFileNameIn,
+		OutDir:        &dirNameOut,
+		PageSelection: pageSelection,
+		Config:        config}
+}
+
 // TrimCommand creates a new command to trim the pages of a file.
 func TrimCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, config *pdfcpu.Configuration) *Command {
 	// A slice parameter may be called with nil => empty slice.
@@ -259,6 +433,159 @@ func AddPermissionsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *
 		Config: config}
 }
 
+// ListFormFieldsCommand creates a new command to list form fields.
+func ListFormFieldsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTFORMFIELDS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// FillFormCommand creates a new command to fill form fields with values from a JSON, FDF or XFDF file.
+func FillFormCommand(pdfFileNameIn, dataFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.FILLFORM,
+		InFile:  &pdfFileNameIn,
+		InFiles: []string{dataFileNameIn},
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// ExportFormCommand creates a new command to export form data.
+func ExportFormCommand(pdfFileNameIn, dataFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.EXPORTFORM,
+		InFile:  &pdfFileNameIn,
+		OutFile: &dataFileNameOut,
+		Config:  config}
+}
+
+// MultiFillFormCommand creates a new command to fill a form once for every record of a CSV file.
+// The filled copies are written to outDir or merged into pdfFileNameOut.
+func MultiFillFormCommand(pdfFileNameIn, csvFileNameIn, outDir, pdfFileNameOut string, mfc *pdfcpu.MultiFillConfig, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.MULTIFILLFORM,
+		InFile:    &pdfFileNameIn,
+		InFiles:   []string{csvFileNameIn},
+		OutDir:    &outDir,
+		OutFile:   &pdfFileNameOut,
+		MultiFill: mfc,
+		Config:    config}
+}
+
+// FieldAppearancesCommand creates a new command to regenerate the appearance streams of form fields.
+func FieldAppearancesCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.FIELDAPPEARANCES,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// ListFormOrderCommand creates a new command to list the tab order and the calculation order of form fields.
+func ListFormOrderCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTFORMORDER,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// SetTabOrderCommand creates a new command to set the tab order of selected pages and to reorder their widgets.
+func SetTabOrderCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, tabs string, fieldNames []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.SETTABORDER,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		TabOrder:      tabs,
+		FieldNames:    fieldNames,
+		Config:        config}
+}
+
+// SetCalculationOrderCommand creates a new command to set the calculation order of form fields.
+func SetCalculationOrderCommand(pdfFileNameIn, pdfFileNameOut string, fieldNames []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:       pdfcpu.SETCALCULATIONORDER,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		FieldNames: fieldNames,
+		Config:     config}
+}
+
+// AddSignatureFieldCommand creates a new command to add an unsigned signature field.
+func AddSignatureFieldCommand(pdfFileNameIn, pdfFileNameOut string, fa *pdfcpu.FieldAttributes, label string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.ADDSIGNATUREFIELD,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Field:   fa,
+		Label:   label,
+		Config:  config}
+}
+
+// ResetFormCommand creates a new command to reset form fields to their default values.
+func ResetFormCommand(pdfFileNameIn, pdfFileNameOut string, fieldNames []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:       pdfcpu.RESETFORM,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		FieldNames: fieldNames,
+		Config:     config}
+}
+
+// ListChoiceOptionsCommand creates a new command to list the options of a combo box or list box.
+func ListChoiceOptionsCommand(pdfFileNameIn, fieldName string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.LISTCHOICEOPTIONS,
+		InFile:    &pdfFileNameIn,
+		FieldName: fieldName,
+		Config:    config}
+}
+
+// AddChoiceOptionsCommand creates a new command to append options to a combo box or list box.
+func AddChoiceOptionsCommand(pdfFileNameIn, pdfFileNameOut, fieldName string, opts []pdfcpu.ChoiceOption, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.ADDCHOICEOPTIONS,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		FieldName: fieldName,
+		Options:   opts,
+		Config:    config}
+}
+
+// RemoveChoiceOptionsCommand creates a new command to remove options from a combo box or list box.
+func RemoveChoiceOptionsCommand(pdfFileNameIn, pdfFileNameOut, fieldName string, opts []pdfcpu.ChoiceOption, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.REMOVECHOICEOPTIONS,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		FieldName: fieldName,
+		Options:   opts,
+		Config:    config}
+}
+
+// SetChoiceOptionsCommand creates a new command to replace the options of a combo box or list box.
+func SetChoiceOptionsCommand(pdfFileNameIn, pdfFileNameOut, fieldName string, opts []pdfcpu.ChoiceOption, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.SETCHOICEOPTIONS,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		FieldName: fieldName,
+		Options:   opts,
+		Config:    config}
+}
+
+// AddPageAttachmentsCommand creates a new command to attach a file to selected pages.
+func AddPageAttachmentsCommand(pdfFileNameIn, fileNameIn string, pageSelection []string, n *pdfcpu.Note, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.ADDPAGEATTACHMENTS,
+		InFile:        &pdfFileNameIn,
+		InFiles:       []string{fileNameIn},
+		PageSelection: pageSelection,
+		Note:          n,
+		Config:        config}
+}
+
 func processAttachments(cmd *Command) (out []string, err error) {
 
 	switch cmd.Mode {
@@ -274,6 +601,9 @@ func processAttachments(cmd *Command) (out []string, err error) {
 
 	case pdfcpu.EXTRACTATTACHMENTS:
 		err = ExtractAttachments(*cmd.InFile, *cmd.OutDir, cmd.InFiles, cmd.Config)
+
+	case pdfcpu.ADDPAGEATTACHMENTS:
+		err = AddPageAttachments(*cmd.InFile, cmd.InFiles[0], cmd.PageSelection, cmd.Note, cmd.Config)
 	}
 
 	return out, err
@@ -324,3 +654,1299 @@ func AddWatermarksCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []
 		Watermark:     wm,
 		Config:        config}
 }
+
+// WatermarkSelection pairs a watermark with the pages it applies to.
+type WatermarkSelection struct {
+	PageSelection []string
+	Watermark     *pdfcpu.Watermark
+}
+
+// AddMultipleWatermarksCommand creates a new command to add watermarks for distinct page selections to a file in one pass.
+func AddMultipleWatermarksCommand(pdfFileNameIn, pdfFileNameOut string, wms []WatermarkSelection, config *pdfcpu.Configuration) *Command {
+
+	return &Command{
+		Mode:       pdfcpu.ADDWATERMARKS,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		Watermarks: wms,
+		Config:     config}
+}
+
+// UpdateWatermarksCommand creates a new command to replace the watermarks added by pdfcpu to the selected pages of a file.
+func UpdateWatermarksCommand(pdfFileNameIn, pdfFileNameOut string, wms []WatermarkSelection, config *pdfcpu.Configuration) *Command {
+
+	return &Command{
+		Mode:       pdfcpu.UPDATEWATERMARKS,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		Watermarks: wms,
+		Config:     config}
+}
+
+// RemoveWatermarksCommand creates a new command to remove the stamps (onTop) or watermarks added by pdfcpu from the selected pages of a file.
+func RemoveWatermarksCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, onTop bool, config *pdfcpu.Configuration) *Command {
+
+	return &Command{
+		Mode:          pdfcpu.REMOVEWATERMARKS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		OnTop:         onTop,
+		Config:        config}
+}
+
+func processRemoveWatermarks(cmd *Command) ([]string, error) {
+	return nil, RemoveWatermarks(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.OnTop, cmd.Config)
+}
+
+// ListAnnotationsCommand creates a new command to list the annotations of selected pages.
+func ListAnnotationsCommand(pdfFileNameIn string, pageSelection []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.LISTANNOTATIONS,
+		InFile:        &pdfFileNameIn,
+		PageSelection: pageSelection,
+		Config:        config}
+}
+
+// RemoveAnnotationsCommand creates a new command to remove annotations of selected pages by subtype or object number.
+func RemoveAnnotationsCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection, subtypes []string, objNrs []int, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.REMOVEANNOTATIONS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Subtypes:      subtypes,
+		ObjNrs:        objNrs,
+		Config:        config}
+}
+
+// FlattenAnnotationsCommand creates a new command to flatten annotations of selected pages by subtype or object number.
+func FlattenAnnotationsCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection, subtypes []string, objNrs []int, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.FLATTENANNOTATIONS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Subtypes:      subtypes,
+		ObjNrs:        objNrs,
+		Config:        config}
+}
+
+// ExportAnnotationsCommand creates a new command to export markup annotations to XFDF.
+func ExportAnnotationsCommand(pdfFileNameIn, xfdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.EXPORTANNOTATIONS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &xfdfFileNameOut,
+		Config:  config}
+}
+
+// ImportAnnotationsCommand creates a new command to import markup annotations from XFDF.
+func ImportAnnotationsCommand(pdfFileNameIn, xfdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.IMPORTANNOTATIONS,
+		InFile:  &pdfFileNameIn,
+		InFiles: []string{xfdfFileNameIn},
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// AddLinksCommand creates a new command to add a link to selected pages.
+// Without link the URLs found in the text of selected pages are turned into links.
+func AddLinksCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, link *pdfcpu.Link, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.ADDLINKS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Link:          link,
+		Config:        config}
+}
+
+// HighlightTextCommand creates a new command to mark up all occurrences of query within the text of selected pages.
+func HighlightTextCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, query string, tmc *pdfcpu.TextMarkupConfig, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.MARKUPTEXT,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Query:         query,
+		TextMarkup:    tmc,
+		Config:        config}
+}
+
+// AddNotesCommand creates a new command to add a sticky note to selected pages.
+func AddNotesCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, n *pdfcpu.Note, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.ADDNOTES,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Note:          n,
+		Config:        config}
+}
+
+// AddFreeTextsCommand creates a new command to add a free text annotation to selected pages.
+func AddFreeTextsCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, n *pdfcpu.Note, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.ADDFREETEXTS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Note:          n,
+		Config:        config}
+}
+
+func processAnnotations(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTANNOTATIONS:
+		out, err = ListAnnotations(*cmd.InFile, cmd.PageSelection, cmd.Config)
+
+	case pdfcpu.REMOVEANNOTATIONS:
+		err = RemoveAnnotations(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Subtypes, cmd.ObjNrs, cmd.Config)
+
+	case pdfcpu.FLATTENANNOTATIONS:
+		err = FlattenAnnotations(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Subtypes, cmd.ObjNrs, cmd.Config)
+
+	case pdfcpu.EXPORTANNOTATIONS:
+		err = ExportAnnotations(*cmd.InFile, *cmd.OutFile, cmd.Config)
+
+	case pdfcpu.IMPORTANNOTATIONS:
+		err = ImportAnnotations(*cmd.InFile, cmd.InFiles[0], *cmd.OutFile, cmd.Config)
+
+	case pdfcpu.ADDLINKS:
+		err = AddLinks(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Link, cmd.Config)
+
+	case pdfcpu.MARKUPTEXT:
+		err = HighlightText(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Query, cmd.TextMarkup, cmd.Config)
+
+	case pdfcpu.ADDNOTES:
+		err = AddNotes(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Note, false, cmd.Config)
+
+	case pdfcpu.ADDFREETEXTS:
+		err = AddNotes(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Note, true, cmd.Config)
+	}
+
+	return out, err
+}
+
+func processForm(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTFORMFIELDS:
+		out, err = ListFormFields(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.FILLFORM:
+		err = FillForm(*cmd.InFile, cmd.InFiles[0], *cmd.OutFile, cmd.Config)
+
+	case pdfcpu.EXPORTFORM:
+		err = ExportForm(*cmd.InFile, *cmd.OutFile, cmd.Config)
+
+	case pdfcpu.MULTIFILLFORM:
+		err = MultiFillForm(*cmd.InFile, cmd.InFiles[0], *cmd.OutDir, *cmd.OutFile, cmd.MultiFill, cmd.Config)
+
+	case pdfcpu.FIELDAPPEARANCES:
+		err = GenerateFieldAppearances(*cmd.InFile, *cmd.OutFile, cmd.Config)
+
+	case pdfcpu.LISTFORMORDER:
+		out, err = ListFormOrder(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.SETTABORDER:
+		err = SetTabOrder(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.TabOrder, cmd.FieldNames, cmd.Config)
+
+	case pdfcpu.SETCALCULATIONORDER:
+		err = SetCalculationOrder(*cmd.InFile, *cmd.OutFile, cmd.FieldNames, cmd.Config)
+
+	case pdfcpu.ADDSIGNATUREFIELD:
+		err = AddSignatureField(*cmd.InFile, *cmd.OutFile, cmd.Field, cmd.Label, cmd.Config)
+
+	case pdfcpu.LISTCHOICEOPTIONS:
+		out, err = ListChoiceOptions(*cmd.InFile, cmd.FieldName, cmd.Config)
+
+	case pdfcpu.ADDCHOICEOPTIONS:
+		err = AddChoiceOptions(*cmd.InFile, *cmd.OutFile, cmd.FieldName, cmd.Options, cmd.Config)
+
+	case pdfcpu.REMOVECHOICEOPTIONS:
+		err = RemoveChoiceOptions(*cmd.InFile, *cmd.OutFile, cmd.FieldName, cmd.Options, cmd.Config)
+
+	case pdfcpu.SETCHOICEOPTIONS:
+		err = SetChoiceOptions(*cmd.InFile, *cmd.OutFile, cmd.FieldName, cmd.Options, cmd.Config)
+
+	case pdfcpu.RESETFORM:
+		err = ResetFormFields(*cmd.InFile, *cmd.OutFile, cmd.FieldNames, cmd.Config)
+	}
+
+	return out, err
+}
+
+// SignCommand creates a new command to sign a file.
+func SignCommand(pdfFileNameIn, pdfFileNameOut string, sa *pdfcpu.SignatureAttributes, signer pdfcpu.Signer, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.SIGN,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		Signature: sa,
+		Signer:    signer,
+		Config:    config}
+}
+
+// VerifySignaturesCommand creates a new command to verify the signatures of a file.
+func VerifySignaturesCommand(pdfFileNameIn string, roots *x509.CertPool, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.VERIFYSIGNATURES,
+		InFile: &pdfFileNameIn,
+		Roots:  roots,
+		Config: config}
+}
+
+// DocTimeStampCommand creates a new command to add a document time stamp to a file.
+func DocTimeStampCommand(pdfFileNameIn, pdfFileNameOut, fieldName string, ts pdfcpu.Timestamper, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:        pdfcpu.DOCTIMESTAMP,
+		InFile:      &pdfFileNameIn,
+		OutFile:     &pdfFileNameOut,
+		FieldName:   fieldName,
+		Timestamper: ts,
+		Config:      config}
+}
+
+// EnableLTVCommand creates a new command to embed validation data for all signatures of a file.
+func EnableLTVCommand(pdfFileNameIn, pdfFileNameOut string, roots *x509.CertPool, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.ENABLELTV,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Roots:   roots,
+		Config:  config}
+}
+
+func processSignatures(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.SIGN:
+		err = Sign(*cmd.InFile, *cmd.OutFile, cmd.Signature, cmd.Signer, cmd.Config)
+
+	case pdfcpu.DOCTIMESTAMP:
+		err = AddDocTimeStamp(*cmd.InFile, *cmd.OutFile, cmd.FieldName, cmd.Timestamper, cmd.Config)
+
+	case pdfcpu.ENABLELTV:
+		err = EnableLTV(*cmd.InFile, *cmd.OutFile, cmd.Roots, cmd.Config)
+
+	case pdfcpu.VERIFYSIGNATURES:
+		var srs []*pdfcpu.SignatureReport
+		if srs, err = VerifySignatures(*cmd.InFile, cmd.Roots, cmd.Config); err != nil {
+			return nil, err
+		}
+		if len(srs) == 0 {
+			out = append(out, "no signatures")
+		}
+		for _, sr := range srs {
+			out = append(out, sr.String())
+		}
+	}
+
+	return out, err
+}
+
+// AddQRCodeStampCommand creates a new command to add a QR code verification stamp to a file.
+func AddQRCodeStampCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, wm *pdfcpu.Watermark, config *pdfcpu.Configuration) *Command {
+
+	return &Command{
+		Mode:          pdfcpu.ADDQRCODESTAMP,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Watermark:     wm,
+		Config:        config}
+}
+
+// RedactCommand creates a new command to redact selected pages.
+func RedactCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, rc *pdfcpu.RedactConfig, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.REDACT,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Redact:        rc,
+		Config:        config}
+}
+
+// ListJavaScriptCommand creates a new command to list the document level scripts and JavaScript actions of a file.
+func ListJavaScriptCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTJAVASCRIPT,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// RemoveJavaScriptCommand creates a new command to remove the document level scripts and JavaScript actions of a file.
+func RemoveJavaScriptCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.REMOVEJAVASCRIPT,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+func processJavaScript(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTJAVASCRIPT:
+		out, err = ListJavaScript(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.REMOVEJAVASCRIPT:
+		err = RemoveJavaScript(*cmd.InFile, *cmd.OutFile, cmd.Config)
+	}
+
+	return out, err
+}
+
+// SanitizeCommand creates a new command to remove scripts, embedded files, metadata, thumbnails
+// and prior revisions from a file and optionally all actions referring to external resources.
+func SanitizeCommand(pdfFileNameIn, pdfFileNameOut string, external bool, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.SANITIZE,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		External: external,
+		Config:   config}
+}
+
+func processSanitize(cmd *Command) ([]string, error) {
+	return Sanitize(*cmd.InFile, *cmd.OutFile, cmd.External, cmd.Config)
+}
+
+// ListRevisionsCommand creates a new command to list the revisions of a file.
+func ListRevisionsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTREVISIONS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// ExtractRevisionCommand creates a new command to extract a revision of a file into a standalone PDF file.
+func ExtractRevisionCommand(pdfFileNameIn, pdfFileNameOut string, revision int, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.EXTRACTREVISION,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Revision: revision,
+		Config:   config}
+}
+
+// RemoveUsageRightsCommand creates a new command to remove the usage rights signatures of a file.
+func RemoveUsageRightsCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.REMOVEUSAGERIGHTS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+func processRemoveUsageRights(cmd *Command) ([]string, error) {
+	return nil, RemoveUsageRights(*cmd.InFile, *cmd.OutFile, cmd.Config)
+}
+
+func processRevisions(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTREVISIONS:
+		var revs []*pdfcpu.Revision
+		if revs, err = ListRevisions(*cmd.InFile, cmd.Config); err != nil {
+			return nil, err
+		}
+		for _, r := range revs {
+			out = append(out, r.String())
+		}
+
+	case pdfcpu.EXTRACTREVISION:
+		err = ExtractRevision(*cmd.InFile, *cmd.OutFile, cmd.Revision, cmd.Config)
+	}
+
+	return out, err
+}
+
+// ValidatePDFACommand creates a new command to check a file against the rules of a PDF/A conformance level.
+func ValidatePDFACommand(pdfFileNameIn, level string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.VALIDATEPDFA,
+		InFile: &pdfFileNameIn,
+		Level:  level,
+		Config: config}
+}
+
+// ValidatePDFXCommand creates a new command to check a file against the conditions of a PDF/X conformance level.
+func ValidatePDFXCommand(pdfFileNameIn, level string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.VALIDATEPDFX,
+		InFile: &pdfFileNameIn,
+		Level:  level,
+		Config: config}
+}
+
+// ValidatePDFUACommand creates a new command to check a file against the accessibility requirements of PDF/UA.
+func ValidatePDFUACommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.VALIDATEPDFUA,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+func processValidateCompliance(cmd *Command) ([]string, error) {
+
+	var (
+		rep *pdfcpu.ComplianceReport
+		err error
+	)
+
+	switch cmd.Mode {
+
+	case pdfcpu.VALIDATEPDFA:
+		rep, err = ValidatePDFA(*cmd.InFile, cmd.Level, cmd.Config)
+
+	case pdfcpu.VALIDATEPDFX:
+		rep, err = ValidatePDFX(*cmd.InFile, cmd.Level, cmd.Config)
+
+	case pdfcpu.VALIDATEPDFUA:
+		rep, err = ValidatePDFUA(*cmd.InFile, cmd.Config)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err := json.MarshalIndent(rep, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(bb)}, nil
+}
+
+// ConvertToPDFACommand creates a new command to fix the violations of the rules of a PDF/A conformance level.
+func ConvertToPDFACommand(pdfFileNameIn, pdfFileNameOut, level, fontDir string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.CONVERTPDFA,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Level:   level,
+		FontDir: fontDir,
+		Config:  config}
+}
+
+func processConvertToPDFA(cmd *Command) ([]string, error) {
+	return ConvertToPDFA(*cmd.InFile, *cmd.OutFile, cmd.Level, cmd.FontDir, cmd.Config)
+}
+
+// AddOutputIntentCommand creates a new command to embed an ICC profile as output intent of the given subtype.
+func AddOutputIntentCommand(pdfFileNameIn, profileFile, pdfFileNameOut, subtype string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:         pdfcpu.ADDOUTPUTINTENT,
+		InFile:       &pdfFileNameIn,
+		OutFile:      &pdfFileNameOut,
+		ProfileFile:  profileFile,
+		OutputIntent: subtype,
+		Config:       config}
+}
+
+// ListOutputIntentsCommand creates a new command to list the output intents of a file.
+func ListOutputIntentsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTOUTPUTINTENTS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// RemoveOutputIntentsCommand creates a new command to remove the output intents of the given subtype or all output intents if subtype is empty.
+func RemoveOutputIntentsCommand(pdfFileNameIn, pdfFileNameOut, subtype string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:         pdfcpu.REMOVEOUTPUTINTENTS,
+		InFile:       &pdfFileNameIn,
+		OutFile:      &pdfFileNameOut,
+		OutputIntent: subtype,
+		Config:       config}
+}
+
+// ExtractOutputIntentCommand creates a new command to extract the ICC profile of the output intent of the given subtype.
+func ExtractOutputIntentCommand(pdfFileNameIn, profileFile, subtype string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:         pdfcpu.EXTRACTOUTPUTINTENT,
+		InFile:       &pdfFileNameIn,
+		ProfileFile:  profileFile,
+		OutputIntent: subtype,
+		Config:       config}
+}
+
+// ReplaceOutputIntentCommand creates a new command to replace the ICC profile of the output intent of the given subtype.
+func ReplaceOutputIntentCommand(pdfFileNameIn, profileFile, pdfFileNameOut, subtype string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:         pdfcpu.REPLACEOUTPUTINTENT,
+		InFile:       &pdfFileNameIn,
+		OutFile:      &pdfFileNameOut,
+		ProfileFile:  profileFile,
+		OutputIntent: subtype,
+		Config:       config}
+}
+
+func processOutputIntents(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTOUTPUTINTENTS:
+		return ListOutputIntents(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.REMOVEOUTPUTINTENTS:
+		var subtypes []string
+		if cmd.OutputIntent != "" {
+			subtypes = []string{cmd.OutputIntent}
+		}
+		return nil, RemoveOutputIntents(*cmd.InFile, *cmd.OutFile, subtypes, cmd.Config)
+
+	case pdfcpu.EXTRACTOUTPUTINTENT:
+		return nil, ExtractOutputIntentProfile(*cmd.InFile, cmd.ProfileFile, cmd.OutputIntent, cmd.Config)
+
+	case pdfcpu.REPLACEOUTPUTINTENT:
+		return nil, ReplaceOutputIntentProfile(*cmd.InFile, cmd.ProfileFile, *cmd.OutFile, cmd.OutputIntent, cmd.Config)
+	}
+
+	return nil, AddOutputIntent(*cmd.InFile, cmd.ProfileFile, *cmd.OutFile, cmd.OutputIntent, cmd.Config)
+}
+
+// LinearizeCommand creates a new command to write a linearized file optimized for fast web view.
+func LinearizeCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.LINEARIZE,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// CheckLinearizationCommand creates a new command to check if a file is validly linearized.
+func CheckLinearizationCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.CHECKLINEARIZATION,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+func processLinearization(cmd *Command) ([]string, error) {
+
+	if cmd.Mode == pdfcpu.CHECKLINEARIZATION {
+		return CheckLinearization(*cmd.InFile, cmd.Config)
+	}
+
+	return nil, Linearize(*cmd.InFile, *cmd.OutFile, cmd.Config)
+}
+
+// ListXMPCommand creates a new command to list the XMP metadata properties of a file.
+func ListXMPCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTXMP,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// SetXMPCommand creates a new command to set XMP metadata properties and the corresponding document info entries.
+func SetXMPCommand(pdfFileNameIn, pdfFileNameOut string, props map[string]string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.SETXMP,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Metadata: props,
+		Config:   config}
+}
+
+func processXMP(cmd *Command) ([]string, error) {
+
+	if cmd.Mode == pdfcpu.LISTXMP {
+		return ListXMP(*cmd.InFile, cmd.Config)
+	}
+
+	return nil, SetXMP(*cmd.InFile, *cmd.OutFile, cmd.Metadata, cmd.Config)
+}
+
+// ListInfoCommand creates a new command to list the document info dict entries of a file.
+func ListInfoCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTINFO,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// SetInfoCommand creates a new command to set document info dict entries.
+func SetInfoCommand(pdfFileNameIn, pdfFileNameOut string, props map[string]string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.SETINFO,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Metadata: props,
+		Config:   config}
+}
+
+// RemoveInfoCommand creates a new command to remove document info dict entries.
+func RemoveInfoCommand(pdfFileNameIn, pdfFileNameOut string, keys []string, config *pdfcpu.Configuration) *Command {
+
+	props := map[string]string{}
+	for _, k := range keys {
+		props[k] = ""
+	}
+
+	return &Command{
+		Mode:     pdfcpu.REMOVEINFO,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Metadata: props,
+		Config:   config}
+}
+
+func processInfo(cmd *Command) ([]string, error) {
+
+	if cmd.Mode == pdfcpu.LISTINFO {
+		return ListInfo(*cmd.InFile, cmd.Config)
+	}
+
+	return nil, SetInfo(*cmd.InFile, *cmd.OutFile, cmd.Metadata, cmd.Config)
+}
+
+// ListKeywordsCommand creates a new command to list the keywords of a file.
+func ListKeywordsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTKEYWORDS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// AddKeywordsCommand creates a new command to add keywords.
+func AddKeywordsCommand(pdfFileNameIn, pdfFileNameOut string, keywords []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.ADDKEYWORDS,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Keywords: keywords,
+		Config:   config}
+}
+
+// RemoveKeywordsCommand creates a new command to remove keywords, all of them if keywords is empty.
+func RemoveKeywordsCommand(pdfFileNameIn, pdfFileNameOut string, keywords []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.REMOVEKEYWORDS,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Keywords: keywords,
+		Config:   config}
+}
+
+func processKeywords(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTKEYWORDS:
+		return ListKeywords(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.ADDKEYWORDS:
+		return nil, AddKeywords(*cmd.InFile, *cmd.OutFile, cmd.Keywords, cmd.Config)
+	}
+
+	return nil, RemoveKeywords(*cmd.InFile, *cmd.OutFile, cmd.Keywords, cmd.Config)
+}
+
+// ListPropertiesCommand creates a new command to list the custom document properties of a file.
+func ListPropertiesCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTPROPERTIES,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// AddPropertiesCommand creates a new command to add custom document properties.
+func AddPropertiesCommand(pdfFileNameIn, pdfFileNameOut string, props map[string]string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.ADDPROPERTIES,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Metadata: props,
+		Config:   config}
+}
+
+// RemovePropertiesCommand creates a new command to remove custom document properties, all of them if names is empty.
+func RemovePropertiesCommand(pdfFileNameIn, pdfFileNameOut string, names []string, config *pdfcpu.Configuration) *Command {
+
+	props := map[string]string{}
+	for _, k := range names {
+		props[k] = ""
+	}
+
+	return &Command{
+		Mode:     pdfcpu.REMOVEPROPERTIES,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Metadata: props,
+		Config:   config}
+}
+
+func processProperties(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTPROPERTIES:
+		return ListProperties(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.ADDPROPERTIES:
+		return nil, AddProperties(*cmd.InFile, *cmd.OutFile, cmd.Metadata, cmd.Config)
+	}
+
+	var names []string
+	for k := range cmd.Metadata {
+		names = append(names, k)
+	}
+
+	return nil, RemoveProperties(*cmd.InFile, *cmd.OutFile, names, cmd.Config)
+}
+
+// ListBookmarksCommand creates a new command to list the outline of a file.
+func ListBookmarksCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTBOOKMARKS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// ExportBookmarksCommand creates a new command to export the outline of a file as JSON or YAML.
+func ExportBookmarksCommand(pdfFileNameIn, fileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.EXPORTBOOKMARKS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &fileNameOut,
+		Config:  config}
+}
+
+// ImportBookmarksCommand creates a new command to replace the outline of a file by the bookmarks of a JSON or YAML file.
+func ImportBookmarksCommand(pdfFileNameIn, bookmarkFile, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:         pdfcpu.IMPORTBOOKMARKS,
+		InFile:       &pdfFileNameIn,
+		OutFile:      &pdfFileNameOut,
+		BookmarkFile: bookmarkFile,
+		Config:       config}
+}
+
+// GenerateBookmarksCommand creates a new command to replace the outline of a file by a bookmark for every n-th page.
+func GenerateBookmarksCommand(pdfFileNameIn, pdfFileNameOut string, n int, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.GENERATEBOOKMARKS,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Interval: n,
+		Config:   config}
+}
+
+func processBookmarks(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTBOOKMARKS:
+		return ListBookmarks(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.EXPORTBOOKMARKS:
+		return nil, ExportBookmarks(*cmd.InFile, *cmd.OutFile, cmd.Config)
+
+	case pdfcpu.IMPORTBOOKMARKS:
+		return nil, ImportBookmarks(*cmd.InFile, cmd.BookmarkFile, *cmd.OutFile, cmd.Config)
+	}
+
+	return nil, GenerateBookmarks(*cmd.InFile, *cmd.OutFile, cmd.Interval, cmd.Config)
+}
+
+// ListNamedDestsCommand creates a new command to list the named destinations of a file.
+func ListNamedDestsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTNAMEDDESTS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// AddNamedDestCommand creates a new command to add a named destination.
+func AddNamedDestCommand(pdfFileNameIn, pdfFileNameOut string, dest pdfcpu.NamedDest, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.ADDNAMEDDEST,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		NamedDest: &dest,
+		Config:    config}
+}
+
+// RemoveNamedDestsCommand creates a new command to remove named destinations.
+func RemoveNamedDestsCommand(pdfFileNameIn, pdfFileNameOut string, names []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.REMOVENAMEDDESTS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Names:   names,
+		Config:  config}
+}
+
+// RenameNamedDestCommand creates a new command to rename a named destination.
+func RenameNamedDestCommand(pdfFileNameIn, pdfFileNameOut, oldName, newName string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.RENAMENAMEDDEST,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Names:   []string{oldName, newName},
+		Config:  config}
+}
+
+func processNamedDests(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTNAMEDDESTS:
+		return ListNamedDests(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.ADDNAMEDDEST:
+		return nil, AddNamedDest(*cmd.InFile, *cmd.OutFile, *cmd.NamedDest, cmd.Config)
+
+	case pdfcpu.REMOVENAMEDDESTS:
+		return nil, RemoveNamedDests(*cmd.InFile, *cmd.OutFile, cmd.Names, cmd.Config)
+	}
+
+	return nil, RenameNamedDest(*cmd.InFile, *cmd.OutFile, cmd.Names[0], cmd.Names[1], cmd.Config)
+}
+
+// ListLayersCommand creates a new command to list the layers of a file.
+func ListLayersCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTLAYERS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// SetLayerVisibilityCommand creates a new command to show or hide layers initially.
+func SetLayerVisibilityCommand(pdfFileNameIn, pdfFileNameOut string, names []string, visible bool, config *pdfcpu.Configuration) *Command {
+
+	mode := pdfcpu.SHOWLAYERS
+	if !visible {
+		mode = pdfcpu.HIDELAYERS
+	}
+
+	return &Command{
+		Mode:    mode,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Names:   names,
+		Config:  config}
+}
+
+// RenameLayerCommand creates a new command to rename a layer.
+func RenameLayerCommand(pdfFileNameIn, pdfFileNameOut, oldName, newName string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.RENAMELAYER,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Names:   []string{oldName, newName},
+		Config:  config}
+}
+
+// RemoveLayersCommand creates a new command to remove layers along with their content.
+func RemoveLayersCommand(pdfFileNameIn, pdfFileNameOut string, names []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.REMOVELAYERS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Names:   names,
+		Config:  config}
+}
+
+// FlattenLayersCommand creates a new command to turn all layers into unconditional content.
+func FlattenLayersCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.FLATTENLAYERS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+func processLayers(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTLAYERS:
+		return ListLayers(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.SHOWLAYERS, pdfcpu.HIDELAYERS:
+		return nil, SetLayerVisibility(*cmd.InFile, *cmd.OutFile, cmd.Names, cmd.Mode == pdfcpu.SHOWLAYERS, cmd.Config)
+
+	case pdfcpu.RENAMELAYER:
+		return nil, RenameLayer(*cmd.InFile, *cmd.OutFile, cmd.Names[0], cmd.Names[1], cmd.Config)
+
+	case pdfcpu.REMOVELAYERS:
+		return nil, RemoveLayers(*cmd.InFile, *cmd.OutFile, cmd.Names, cmd.Config)
+	}
+
+	return nil, FlattenLayers(*cmd.InFile, *cmd.OutFile, cmd.Config)
+}
+
+// ListViewerPreferencesCommand creates a new command to list the viewer preferences of a file.
+func ListViewerPreferencesCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTVIEWERPREFS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// SetViewerPreferencesCommand creates a new command to set viewer preferences and the open action.
+func SetViewerPreferencesCommand(pdfFileNameIn, pdfFileNameOut string, vp pdfcpu.ViewerPrefs, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:        pdfcpu.SETVIEWERPREFS,
+		InFile:      &pdfFileNameIn,
+		OutFile:     &pdfFileNameOut,
+		ViewerPrefs: &vp,
+		Config:      config}
+}
+
+// RemoveViewerPreferencesCommand creates a new command to remove viewer preferences or the open action.
+func RemoveViewerPreferencesCommand(pdfFileNameIn, pdfFileNameOut string, keys []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.REMOVEVIEWERPREFS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Names:   keys,
+		Config:  config}
+}
+
+func processViewerPreferences(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTVIEWERPREFS:
+		return ListViewerPreferences(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.SETVIEWERPREFS:
+		return nil, SetViewerPreferences(*cmd.InFile, *cmd.OutFile, *cmd.ViewerPrefs, cmd.Config)
+	}
+
+	return nil, RemoveViewerPreferences(*cmd.InFile, *cmd.OutFile, cmd.Names, cmd.Config)
+}
+
+// ListTransitionsCommand creates a new command to list the page transitions of a file.
+func ListTransitionsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTTRANSITIONS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// SetTransitionsCommand creates a new command to set the transition of selected pages.
+func SetTransitionsCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, t pdfcpu.Transition, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.SETTRANSITIONS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Transition:    &t,
+		Config:        config}
+}
+
+// RemoveTransitionsCommand creates a new command to remove the transitions of selected pages.
+func RemoveTransitionsCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.REMOVETRANSITIONS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Config:        config}
+}
+
+func processTransitions(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTTRANSITIONS:
+		return ListTransitions(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.SETTRANSITIONS:
+		return nil, SetTransitions(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, *cmd.Transition, cmd.Config)
+	}
+
+	return nil, RemoveTransitions(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Config)
+}
+
+// ListPortfolioCommand creates a new command to list the members of a portfolio.
+func ListPortfolioCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTPORTFOLIO,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// CreatePortfolioCommand creates a new command to turn a file into a portfolio.
+func CreatePortfolioCommand(pdfFileNameIn, pdfFileNameOut string, p *pdfcpu.Portfolio, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.CREATEPORTFOLIO,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		Portfolio: p,
+		Config:    config}
+}
+
+// ExtractPortfolioCommand creates a new command to extract the members of a portfolio along with their metadata.
+func ExtractPortfolioCommand(pdfFileNameIn, dirNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.EXTRACTPORTFOLIO,
+		InFile: &pdfFileNameIn,
+		OutDir: &dirNameOut,
+		Config: config}
+}
+
+func processPortfolio(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTPORTFOLIO:
+		return ListPortfolio(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.CREATEPORTFOLIO:
+		return nil, CreatePortfolio(*cmd.InFile, *cmd.OutFile, cmd.Portfolio, cmd.Config)
+	}
+
+	return nil, ExtractPortfolio(*cmd.InFile, *cmd.OutDir, cmd.Config)
+}
+
+// ListAssociatedFilesCommand creates a new command to list the associated files of a file.
+func ListAssociatedFilesCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTASSOCFILES,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// AddAssociatedFileCommand creates a new command to associate a file with a document, a page or an object.
+func AddAssociatedFileCommand(pdfFileNameIn, pdfFileNameOut string, af pdfcpu.AssociatedFile, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.ADDASSOCFILE,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		AF:      &af,
+		Config:  config}
+}
+
+// ValidateAssociatedFilesCommand creates a new command to validate the associated files of a file.
+func ValidateAssociatedFilesCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.VALIDATEASSOCFILES,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+func processAssociatedFiles(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTASSOCFILES:
+		return ListAssociatedFiles(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.ADDASSOCFILE:
+		return nil, AddAssociatedFile(*cmd.InFile, *cmd.OutFile, *cmd.AF, cmd.Config)
+	}
+
+	return ValidateAssociatedFiles(*cmd.InFile, cmd.Config)
+}
+
+// ListViewportsCommand creates a new command to list the viewports of a file.
+func ListViewportsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTVIEWPORTS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// AddViewportsCommand creates a new command to georeference a file by geospatial viewports.
+func AddViewportsCommand(pdfFileNameIn, pdfFileNameOut string, vps []pdfcpu.Viewport, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:      pdfcpu.ADDVIEWPORTS,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		Viewports: vps,
+		Config:    config}
+}
+
+// RemoveViewportsCommand creates a new command to remove the viewports of selected pages.
+func RemoveViewportsCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.REMOVEVIEWPORTS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Config:        config}
+}
+
+func processViewports(cmd *Command) ([]string, error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTVIEWPORTS:
+		return ListViewports(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.ADDVIEWPORTS:
+		return nil, AddViewports(*cmd.InFile, *cmd.OutFile, cmd.Viewports, cmd.Config)
+	}
+
+	return nil, RemoveViewports(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Config)
+}
+
+// OCRCommand creates a new command to add a text layer recognized by engine to the scanned pages of a file.
+func OCRCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, engine pdfcpu.OCREngine, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.OCRPAGES,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		OCR:           engine,
+		Config:        config}
+}
+
+func processOCR(cmd *Command) ([]string, error) {
+	return nil, OCR(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.OCR, cmd.Config)
+}
+
+// ImportOCRCommand creates a new command to lay the words of an hOCR or ALTO file over the pages of a file as invisible text.
+func ImportOCRCommand(pdfFileNameIn, ocrFileName, pdfFileNameOut string, pageSelection []string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.IMPORTOCR,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		OCRFile:       ocrFileName,
+		PageSelection: pageSelection,
+		Config:        config}
+}
+
+func processImportOCR(cmd *Command) ([]string, error) {
+	return nil, ImportOCR(*cmd.InFile, cmd.OCRFile, *cmd.OutFile, cmd.PageSelection, cmd.Config)
+}
+
+// ListStructTreeCommand creates a new command to list the logical structure of a tagged file.
+func ListStructTreeCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTSTRUCTTREE,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// ExportStructTreeCommand creates a new command to export the logical structure of a tagged file as JSON or HTML.
+func ExportStructTreeCommand(pdfFileNameIn, fileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.EXPORTSTRUCTTREE,
+		InFile:  &pdfFileNameIn,
+		OutFile: &fileNameOut,
+		Config:  config}
+}
+
+func processStructTree(cmd *Command) ([]string, error) {
+
+	if cmd.Mode == pdfcpu.LISTSTRUCTTREE {
+		return ListStructTree(*cmd.InFile, cmd.Config)
+	}
+
+	return nil, ExportStructTree(*cmd.InFile, *cmd.OutFile, cmd.Config)
+}
+
+// RepairCommand creates a new command to recover as much as possible from a damaged or truncated file.
+func RepairCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.REPAIR,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+func processRepair(cmd *Command) ([]string, error) {
+	return Repair(*cmd.InFile, *cmd.OutFile, cmd.Config)
+}
+
+// ExportJSONCommand creates a new command to export all objects of a file as JSON.
+func ExportJSONCommand(pdfFileNameIn, fileNameOut string, external bool, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.EXPORTJSON,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &fileNameOut,
+		External: external,
+		Config:   config}
+}
+
+// ImportJSONCommand creates a new command to create a file from its JSON representation.
+func ImportJSONCommand(fileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.IMPORTJSON,
+		InFile:  &fileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+func processJSON(cmd *Command) ([]string, error) {
+
+	if cmd.Mode == pdfcpu.EXPORTJSON {
+		return nil, ExportJSON(*cmd.InFile, *cmd.OutFile, cmd.External, cmd.Config)
+	}
+
+	return nil, ImportJSON(*cmd.InFile, *cmd.OutFile, cmd.Config)
+}
+
+// DiffCommand creates a new command to compare two files.
+func DiffCommand(pdfFileNameA, pdfFileNameB string, jsonOutput bool, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:       pdfcpu.DIFF,
+		InFiles:    []string{pdfFileNameA, pdfFileNameB},
+		JSONOutput: jsonOutput,
+		Config:     config}
+}
+
+// DiffOutput returns the differences of r as text lines or as JSON.
+func DiffOutput(r *pdfcpu.DiffReport, jsonOutput bool) ([]string, error) {
+
+	if !jsonOutput {
+		return r.Lines(), nil
+	}
+
+	bb, err := r.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(bb)}, nil
+}
+
+func processDiff(cmd *Command) ([]string, error) {
+
+	r, err := Diff(cmd.InFiles[0], cmd.InFiles[1], cmd.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffOutput(r, cmd.JSONOutput)
+}
+
+// BatchCommand creates a new command to apply the pipeline cmds to all files of a directory tree.
+func BatchCommand(dirNameIn, dirNameOut string, cmds []*Command, bc *BatchConfig, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.BATCH,
+		InDir:    &dirNameIn,
+		OutDir:   &dirNameOut,
+		Pipeline: cmds,
+		Batch:    bc,
+		Config:   config}
+}
+
+func processBatch(cmd *Command) ([]string, error) {
+
+	r, err := Batch(*cmd.InDir, *cmd.OutDir, cmd.Pipeline, cmd.Batch)
+	if r == nil {
+		return nil, err
+	}
+
+	return r.Summary(), err
+}
@@ -17,15 +17,36 @@ limitations under the License.
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
 )
 
 var inDir, outDir string
@@ -111,6 +132,96 @@ func TestValidateOneFile(t *testing.T) {
 
 }
 
+func TestPartialValidation(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationRelaxed
+
+	inFile := filepath.Join(inDir, "CenterOfWhy.pdf")
+
+	_, err := Process(ValidatePagesCommand(inFile, []string{"2-4", "25"}, config))
+	if err != nil {
+		t.Fatalf("TestPartialValidation: %v\n", err)
+	}
+
+	if _, err = Process(ValidatePagesCommand(inFile, []string{"30-"}, config)); err == nil {
+		t.Fatalf("TestPartialValidation: validating nonexistent pages should fail\n")
+	}
+
+	ctx, err := Read(inFile, config)
+	if err != nil {
+		t.Fatalf("TestPartialValidation: %v\n", err)
+	}
+
+	// Validate the page tree root and the dict of page 3 object by object.
+	root, err := ctx.Pages()
+	if err != nil {
+		t.Fatalf("TestPartialValidation: %v\n", err)
+	}
+
+	indRef, err := ctx.PageDictIndRef(3)
+	if err != nil || indRef == nil {
+		t.Fatalf("TestPartialValidation: page 3 not found: %v\n", err)
+	}
+
+	objNrs := []int{root.ObjectNumber.Value(), indRef.ObjectNumber.Value()}
+
+	_, err = Process(ValidateObjectsCommand(inFile, objNrs, config))
+	if err != nil {
+		t.Fatalf("TestPartialValidation: %v\n", err)
+	}
+
+	if _, err = Process(ValidateObjectsCommand(inFile, []int{*ctx.Size + 1}, config)); err == nil {
+		t.Fatalf("TestPartialValidation: validating a nonexistent object should fail\n")
+	}
+}
+
+func TestValidationPolicy(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "annotTest.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationStrict
+
+	if _, err := Process(ValidateCommand(inFile, config)); err == nil {
+		t.Fatalf("TestValidationPolicy: strict validation should fail\n")
+	}
+
+	var ss []string
+	for _, rule := range pdfcpu.ValidationRules {
+		ss = append(ss, rule+":warn")
+	}
+
+	p, err := pdfcpu.ParseValidationPolicy(strings.Join(ss, ", "))
+	if err != nil {
+		t.Fatalf("TestValidationPolicy: %v\n", err)
+	}
+	config.ValidationPolicy = p
+
+	out, err := Process(ValidateCommand(inFile, config))
+	if err != nil {
+		t.Fatalf("TestValidationPolicy: %v\n", err)
+	}
+
+	if len(out) == 0 || !strings.HasPrefix(out[0], pdfcpu.RuleVersion+": ") {
+		t.Fatalf("TestValidationPolicy: want version warnings, got: %v\n", out)
+	}
+
+	// Relaxed validation enforcing a single rule.
+	config = pdfcpu.NewDefaultConfiguration()
+	config.ValidationPolicy = pdfcpu.ValidationPolicy{pdfcpu.RuleVersion: pdfcpu.RuleError}
+
+	if _, err = Process(ValidateCommand(inFile, config)); err == nil || !strings.Contains(err.Error(), "unsupported in version") {
+		t.Fatalf("TestValidationPolicy: want version error, got: %v\n", err)
+	}
+
+	for _, s := range []string{"version", "bogus:warn", "version:maybe"} {
+		if _, err = pdfcpu.ParseValidationPolicy(s); err == nil {
+			t.Errorf("TestValidationPolicy: %s should fail\n", s)
+		}
+	}
+}
+
 func BenchmarkValidateCommand(b *testing.B) {
 
 	config := pdfcpu.NewDefaultConfiguration()
@@ -127,6 +238,21 @@ func BenchmarkValidateCommand(b *testing.B) {
 	}
 }
 
+func BenchmarkRead(b *testing.B) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "adobe_supplement_iso32000_1.pdf")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := Read(inFile, config); err != nil {
+			b.Fatalf("BenchmarkRead: %v\n", err)
+		}
+	}
+}
+
 // Optimize all PDFs in testdata and write with (default) end of line sequence "\n".
 func TestOptimizeCommandWithLF(t *testing.T) {
 
@@ -326,6 +452,238 @@ func TestStampCommand(t *testing.T) {
 
 }
 
+// Stamp page 1 with Draft, the remaining pages with Confidential and watermark all pages in one pass.
+func TestMultipleWatermarksCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "pike-stanford.pdf")
+	outFile := filepath.Join(outDir, "testMultiStamp.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	var wms []WatermarkSelection
+
+	for _, tt := range []struct {
+		pages string
+		desc  string
+		onTop bool
+	}{
+		{"1", "Draft, o:0.5", true},
+		{"2-", "Confidential, o:0.9", true},
+		{"", "Copy, o:0.3", false},
+	} {
+		wm, err := pdfcpu.ParseWatermarkDetails(tt.desc, tt.onTop)
+		if err != nil {
+			t.Fatalf("TestMultipleWatermarksCommand: %v\n", err)
+		}
+		pages, err := ParsePageSelection(tt.pages)
+		if err != nil {
+			t.Fatalf("TestMultipleWatermarksCommand: %v\n", err)
+		}
+		wms = append(wms, WatermarkSelection{PageSelection: pages, Watermark: wm})
+	}
+
+	if _, err := Process(AddMultipleWatermarksCommand(inFile, outFile, wms, config)); err != nil {
+		t.Fatalf("TestMultipleWatermarksCommand: %v\n", err)
+	}
+
+	ctx, _, _, _, err := readValidateAndOptimize(outFile, config, time.Now())
+	if err != nil {
+		t.Fatalf("TestMultipleWatermarksCommand: %v\n", err)
+	}
+
+	// One optional content group for the stamps and one for the watermark.
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		t.Fatalf("TestMultipleWatermarksCommand: %v\n", err)
+	}
+	d, err := ctx.DereferenceDict(rootDict.Dict["OCProperties"])
+	if err != nil || d == nil {
+		t.Fatalf("TestMultipleWatermarksCommand: missing OCProperties: %v\n", err)
+	}
+	a, err := ctx.DereferenceArray(d.Dict["OCGs"])
+	if err != nil || a == nil || len(*a) != 2 {
+		t.Fatalf("TestMultipleWatermarksCommand: want 2 OCGs: %v %v\n", a, err)
+	}
+
+	opacities := map[pdfcpu.PDFFloat]bool{}
+	for _, entry := range ctx.Table {
+		if d, ok := entry.Object.(pdfcpu.PDFDict); ok && d.Type() != nil && *d.Type() == "ExtGState" {
+			if f, ok := d.Dict["ca"].(pdfcpu.PDFFloat); ok {
+				opacities[f] = true
+			}
+		}
+	}
+	for _, f := range []pdfcpu.PDFFloat{0.5, 0.9, 0.3} {
+		if !opacities[f] {
+			t.Errorf("TestMultipleWatermarksCommand: missing ExtGState with ca %v\n", f)
+		}
+	}
+}
+
+func TestRemoveWatermarksCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "pike-stanford.pdf")
+	stampFile := filepath.Join(outDir, "testStampRemove.pdf")
+	outFile := filepath.Join(outDir, "testStampRemoved.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	selection := func(pages, desc string, onTop bool) WatermarkSelection {
+		wm, err := pdfcpu.ParseWatermarkDetails(desc, onTop)
+		if err != nil {
+			t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+		}
+		sel, err := ParsePageSelection(pages)
+		if err != nil {
+			t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+		}
+		return WatermarkSelection{PageSelection: sel, Watermark: wm}
+	}
+
+	ocgCount := func(fileName string) int {
+		ctx, _, _, _, err := readValidateAndOptimize(fileName, config, time.Now())
+		if err != nil {
+			t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+		}
+		rootDict, err := ctx.Catalog()
+		if err != nil {
+			t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+		}
+		d, err := ctx.DereferenceDict(rootDict.Dict["OCProperties"])
+		if err != nil || d == nil {
+			return 0
+		}
+		a, err := ctx.DereferenceArray(d.Dict["OCGs"])
+		if err != nil || a == nil {
+			return 0
+		}
+		return len(*a)
+	}
+
+	wms := []WatermarkSelection{selection("", "Draft", true), selection("", "Copy", false)}
+	if _, err := Process(AddMultipleWatermarksCommand(inFile, stampFile, wms, config)); err != nil {
+		t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+	}
+
+	// Removing the stamps keeps the watermarks.
+	if _, err := Process(RemoveWatermarksCommand(stampFile, outFile, nil, true, config)); err != nil {
+		t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+	}
+	if n := ocgCount(outFile); n != 1 {
+		t.Fatalf("TestRemoveWatermarksCommand: want 1 OCG, got %d\n", n)
+	}
+
+	if _, err := Process(RemoveWatermarksCommand(outFile, outFile, nil, true, config)); err == nil {
+		t.Fatal("TestRemoveWatermarksCommand: expected error removing stamps twice\n")
+	}
+
+	// Updating the watermarks of odd pages keeps those of even pages along with their group.
+	wms = []WatermarkSelection{selection("odd", "Confidential, o:0.4", false)}
+	if _, err := Process(UpdateWatermarksCommand(outFile, outFile, wms, config)); err != nil {
+		t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+	}
+	if n := ocgCount(outFile); n != 2 {
+		t.Fatalf("TestRemoveWatermarksCommand: want 2 OCGs after update, got %d\n", n)
+	}
+
+	if _, err := Process(RemoveWatermarksCommand(outFile, outFile, nil, false, config)); err != nil {
+		t.Fatalf("TestRemoveWatermarksCommand: %v\n", err)
+	}
+	if n := ocgCount(outFile); n != 0 {
+		t.Fatalf("TestRemoveWatermarksCommand: want no OCGs, got %d\n", n)
+	}
+}
+
+func TestQRCodeStampCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "pike-stanford.pdf")
+	outFile := filepath.Join(outDir, "testqrstamp.pdf")
+
+	wm, err := pdfcpu.ParseQRCodeStampDetails("https://example.com/verify?sha256={hash}, s:0.25, o:0.9")
+	if err != nil {
+		t.Fatalf("TestQRCodeStampCommand: %v\n", err)
+	}
+
+	out, err := Process(AddQRCodeStampCommand(inFile, outFile, []string{"1"}, wm, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestQRCodeStampCommand: %v\n", err)
+	}
+
+	if len(out) != 2 || !strings.HasPrefix(out[1], "URL: https://example.com/verify?sha256=") || strings.Contains(out[1], pdfcpu.QRCodeHashPlaceholder) {
+		t.Fatalf("TestQRCodeStampCommand: unexpected output: %v\n", out)
+	}
+
+	if _, err = Process(ValidateCommand(outFile, pdfcpu.NewDefaultConfiguration())); err != nil {
+		t.Fatalf("TestQRCodeStampCommand: validate: %v\n", err)
+	}
+
+	// Anybody may recompute the hash from revision 1 of the stamped file.
+	revFile := filepath.Join(outDir, "testqrstampRev1.pdf")
+	if err = ExtractRevision(outFile, revFile, 1, pdfcpu.NewDefaultConfiguration()); err != nil {
+		t.Fatalf("TestQRCodeStampCommand: %v\n", err)
+	}
+
+	b, err := ioutil.ReadFile(revFile)
+	if err != nil {
+		t.Fatalf("TestQRCodeStampCommand: %v\n", err)
+	}
+
+	h := sha256.Sum256(b)
+	hash := hex.EncodeToString(h[:])
+
+	if out[0] != "SHA-256: "+hash || !strings.HasSuffix(out[1], hash) {
+		t.Fatalf("TestQRCodeStampCommand: hash of revision 1 %s does not match %v\n", hash, out)
+	}
+
+	_, err = pdfcpu.ParseQRCodeStampDetails("https://example.com/verify")
+	if err == nil {
+		t.Fatal("TestQRCodeStampCommand: should have failed for missing hash placeholder")
+	}
+}
+
+func TestBarcodeStampCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "pike-stanford.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	for i, s := range []string{
+		"qr:https://example.com/doc/4711?page=%p",
+		"code128:DOC-4711 %p/%P, pos:tl, off:10 -10, s:0.3",
+		"ean13:400638133393, pos:bc, s:1 abs, o:0.8",
+		"ean8:9638507, pos:r, r:90",
+	} {
+		wm, err := pdfcpu.ParseBarcodeStampDetails(s)
+		if err != nil {
+			t.Fatalf("TestBarcodeStampCommand: %s: %v\n", s, err)
+		}
+
+		outFile := filepath.Join(outDir, fmt.Sprintf("testbarcode%d.pdf", i))
+
+		if _, err = Process(AddWatermarksCommand(inFile, outFile, nil, wm, config)); err != nil {
+			t.Fatalf("TestBarcodeStampCommand: %s: %v\n", s, err)
+		}
+
+		if _, err = Process(ValidateCommand(outFile, config)); err != nil {
+			t.Fatalf("TestBarcodeStampCommand: %s: validate: %v\n", s, err)
+		}
+	}
+
+	for _, s := range []string{"4711", "upc:4711", "code128:", "ean13:4006381333932", "code128:4711, pos:top"} {
+		if _, err := pdfcpu.ParseBarcodeStampDetails(s); err == nil {
+			t.Fatalf("TestBarcodeStampCommand: %s: should have failed\n", s)
+		}
+	}
+
+	// Data containing page variables gets checked when stamping.
+	wm, err := pdfcpu.ParseBarcodeStampDetails("ean8:96385%p")
+	if err != nil {
+		t.Fatalf("TestBarcodeStampCommand: %v\n", err)
+	}
+
+	outFile := filepath.Join(outDir, "testbarcodeFail.pdf")
+	if _, err = Process(AddWatermarksCommand(inFile, outFile, nil, wm, config)); err == nil {
+		t.Fatalf("TestBarcodeStampCommand: %s should have failed\n", "ean8:96385%p")
+	}
+}
+
 func TestWatermarkImage(t *testing.T) {
 
 	inFile := filepath.Join(inDir, "Acroforms2.pdf")
@@ -344,6 +702,46 @@ func TestWatermarkImage(t *testing.T) {
 
 }
 
+func TestWatermarkTiles(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "pike-stanford.pdf")
+	outFile := filepath.Join(outDir, "testWMTiles.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	wm, err := pdfcpu.ParseWatermarkDetails("Draft, s:0.2, r:30, o:0.3 0.6, m:2, bm:multiply, tile:40 60", false)
+	if err != nil {
+		t.Fatalf("TestWatermarkTiles: %v\n", err)
+	}
+
+	if _, err = Process(AddWatermarksCommand(inFile, outFile, []string{"1-2"}, wm, config)); err != nil {
+		t.Fatalf("TestWatermarkTiles: %v\n", err)
+	}
+
+	ctx, _, _, _, err := readValidateAndOptimize(outFile, config, time.Now())
+	if err != nil {
+		t.Fatalf("TestWatermarkTiles: %v\n", err)
+	}
+
+	var found bool
+	for _, entry := range ctx.Table {
+		if d, ok := entry.Object.(pdfcpu.PDFDict); ok && d.NameEntry("BM") != nil {
+			if *d.NameEntry("BM") != "Multiply" || d.Dict["ca"] != pdfcpu.PDFFloat(0.3) || d.Dict["CA"] != pdfcpu.PDFFloat(0.6) {
+				t.Fatalf("TestWatermarkTiles: unexpected ExtGState: %s\n", d)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("TestWatermarkTiles: missing ExtGState with blend mode\n")
+	}
+
+	for _, s := range []string{"Draft, o:0.3 0.6 0.9", "Draft, bm:Burn", "Draft, tile:-10", "Draft, tile:a"} {
+		if _, err := pdfcpu.ParseWatermarkDetails(s, false); err == nil {
+			t.Fatalf("TestWatermarkTiles: %s: should have failed\n", s)
+		}
+	}
+}
+
 func TestExtractImagesCommand(t *testing.T) {
 
 	files, err := ioutil.ReadDir(inDir)
@@ -381,870 +779,5751 @@ func TestExtractImagesCommand(t *testing.T) {
 
 }
 
-func TestExtractFontsCommand(t *testing.T) {
+// memFile is an in memory file created by memFiles.
+type memFile struct {
+	bytes.Buffer
+}
 
-	cmd := ExtractFontsCommand("", outDir, nil, pdfcpu.NewDefaultConfiguration())
+func (f *memFile) Close() error { return nil }
 
-	for _, fn := range []string{"5116.DCT_Filter.pdf", "testImage.pdf", "go.pdf"} {
+// memFiles collects files created by pdfcpu.Configuration.CreateFile.
+type memFiles map[string]*memFile
 
-		fn = filepath.Join(inDir, fn)
-		cmd.InFile = &fn
+func (m memFiles) create(name string) (io.WriteCloser, error) {
+	f := &memFile{}
+	m[name] = f
+	return f, nil
+}
 
-		_, err := Process(cmd)
-		if err != nil {
-			t.Fatalf("TestExtractFontsCommand: %v\n", err)
-		}
+func TestFileSystem(t *testing.T) {
 
+	png, err := ioutil.ReadFile("../../resources/pdfchip3.png")
+	if err != nil {
+		t.Fatalf("TestFileSystem: %v\n", err)
 	}
 
-	inFile := filepath.Join(inDir, "go.pdf")
-	_, err := Process(ExtractFontsCommand(inFile, outDir, []string{"1-3"}, pdfcpu.NewDefaultConfiguration()))
+	pdf, err := ioutil.ReadFile(filepath.Join(inDir, "testImage.pdf"))
 	if err != nil {
-		t.Fatalf("TestExtractFontsCommand: %v\n", err)
+		t.Fatalf("TestFileSystem: %v\n", err)
 	}
 
-}
+	files := memFiles{}
 
-func TestExtractContentCommand(t *testing.T) {
+	fsys := fstest.MapFS{"images/chip.png": {Data: png}, "testdata/testImage.pdf": {Data: pdf}}
+	config := pdfcpu.NewConfiguration(pdfcpu.WithFileSystem(fsys, files.create))
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	// The PDF file and the watermark image get read from config.FS, the result gets created by config.CreateFile.
+	wm, err := pdfcpu.ParseWatermarkDetails("images/chip.png, r:0", false)
+	if err != nil {
+		t.Fatalf("TestFileSystem: %v\n", err)
+	}
 
-	_, err := Process(ExtractContentCommand(inFile, outDir, nil, pdfcpu.NewDefaultConfiguration()))
+	_, err = Process(AddWatermarksCommand(filepath.Join(inDir, "testImage.pdf"), "wm/out.pdf", nil, wm, config))
 	if err != nil {
-		t.Fatalf("TestExtractContentCommand: %v\n", err)
+		t.Fatalf("TestFileSystem: %v\n", err)
 	}
 
-}
+	f, ok := files["wm/out.pdf"]
+	if !ok {
+		t.Fatalf("TestFileSystem: missing wm/out.pdf, got %v\n", files)
+	}
 
-func TestExtractPagesCommand(t *testing.T) {
+	if _, err = ValidateContext(bytes.NewReader(f.Bytes()), pdfcpu.NewDefaultConfiguration()); err != nil {
+		t.Fatalf("TestFileSystem: validate wm/out.pdf: %v\n", err)
+	}
 
-	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	if _, err = os.Stat("wm"); !os.IsNotExist(err) {
+		t.Errorf("TestFileSystem: wm written to disk\n")
+	}
 
-	_, err := Process(ExtractPagesCommand(inFile, outDir, []string{"1"}, pdfcpu.NewDefaultConfiguration()))
+	// Extraction targets get created by config.CreateFile.
+	_, err = Process(ExtractImagesCommand(filepath.Join(inDir, "testImage.pdf"), "images", nil, config))
 	if err != nil {
-		t.Fatalf("TestExtractPagesCommand: %v\n", err)
+		t.Fatalf("TestFileSystem: %v\n", err)
+	}
+
+	n := 0
+	for name, f := range files {
+		if strings.HasPrefix(name, "images/") {
+			if f.Len() == 0 {
+				t.Errorf("TestFileSystem: %s is empty\n", name)
+			}
+			n++
+		}
+	}
+
+	if n == 0 {
+		t.Errorf("TestFileSystem: no images extracted, got %v\n", files)
 	}
 
+	// Inputs missing from config.FS do not fall back to disk.
+	wm, _ = pdfcpu.ParseWatermarkDetails("../../resources/pdfchip3.png, r:0", false)
+	_, err = Process(AddWatermarksCommand(filepath.Join(inDir, "testImage.pdf"), "wm/out.pdf", nil, wm, config))
+	if err == nil {
+		t.Errorf("TestFileSystem: watermark image read from disk\n")
+	}
 }
 
-func TestEncryptUPWOnly(t *testing.T) {
+func TestSignFileSystem(t *testing.T) {
 
-	// Test for setting only the user password.
+	pdf, err := ioutil.ReadFile(filepath.Join(inDir, "5116.DCT_Filter.pdf"))
+	if err != nil {
+		t.Fatalf("TestSignFileSystem: %v\n", err)
+	}
 
-	t.Log("running TestEncryptUPWOnly..")
+	cert, key := selfSignedCertificate("alice", 1, t)
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
-	outFile := filepath.Join(outDir, "test.pdf")
+	files := memFiles{}
 
-	// Encrypt upw only
-	t.Log("Encrypt upw only")
-	config := pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err := Process(EncryptCommand(inFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - encrypt with upw only to %s: %v\n", outFile, err)
+	fsys := fstest.MapFS{
+		"in.pdf":          {Data: pdf},
+		"certs/alice.pem": {Data: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})},
 	}
+	config := pdfcpu.NewConfiguration(pdfcpu.WithFileSystem(fsys, files.create))
 
-	// Validate wrong upw
-	t.Log("Validate wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong upw should fail!\n", outFile)
+	sa := &pdfcpu.SignatureAttributes{Page: 1, Reason: "Approved"}
+	if err = Sign("in.pdf", "signed.pdf", sa, pdfcpu.NewSigner(key, []*x509.Certificate{cert}), config); err != nil {
+		t.Fatalf("TestSignFileSystem - sign: %v\n", err)
 	}
 
-	// Validate wrong opw
-	t.Log("Validate wrong opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong opw should fail!\n", outFile)
+	f, ok := files["signed.pdf"]
+	if !ok {
+		t.Fatalf("TestSignFileSystem: missing signed.pdf, got %v\n", files)
 	}
 
-	// Validate default opw=upw (if there is no ownerpw set)
-	t.Log("Validate default opw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "upw"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using default opw: %s!\n", outFile, err)
+	if !bytes.HasPrefix(f.Bytes(), pdf) {
+		t.Fatalf("TestSignFileSystem: not an incremental update\n")
 	}
 
-	// Validate upw
-	t.Log("Validate upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err = Process(ValidateCommand(outFile, config))
+	if _, err = os.Stat("signed.pdf"); !os.IsNotExist(err) {
+		t.Errorf("TestSignFileSystem: signed.pdf written to disk\n")
+	}
+
+	// Verification reads both the signed file and the trust anchors from config.FS.
+	fsys["signed.pdf"] = &fstest.MapFile{Data: f.Bytes()}
+
+	certs, err := ReadCertificates("certs/alice.pem", config)
 	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using upw: %v\n", outFile, err)
+		t.Fatalf("TestSignFileSystem: %v\n", err)
 	}
 
-	// Optimize wrong opw
-	t.Log("Optimize wrong opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong opw should fail!\n", outFile)
+	roots := x509.NewCertPool()
+	roots.AddCert(certs[0])
+
+	srs, err := VerifySignatures("signed.pdf", roots, config)
+	if err != nil {
+		t.Fatalf("TestSignFileSystem - verify: %v\n", err)
 	}
 
-	// Optimize empty opw
-	t.Log("Optimize empty opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = ""
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using empty opw should fail!\n", outFile)
+	if len(srs) != 1 || !srs[0].Valid() {
+		t.Fatalf("TestSignFileSystem - verify: %v\n", srs)
 	}
+}
+
+func TestExtractFontsCommand(t *testing.T) {
+
+	cmd := ExtractFontsCommand("", outDir, nil, pdfcpu.NewDefaultConfiguration())
+
+	for _, fn := range []string{"5116.DCT_Filter.pdf", "testImage.pdf", "go.pdf"} {
+
+		fn = filepath.Join(inDir, fn)
+		cmd.InFile = &fn
+
+		_, err := Process(cmd)
+		if err != nil {
+			t.Fatalf("TestExtractFontsCommand: %v\n", err)
+		}
 
-	// Optimize wrong upw
-	t.Log("Optimize wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong upw should fail!\n", outFile)
 	}
 
-	// Optimize upw
-	t.Log("Optimize upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	inFile := filepath.Join(inDir, "go.pdf")
+	_, err := Process(ExtractFontsCommand(inFile, outDir, []string{"1-3"}, pdfcpu.NewDefaultConfiguration()))
 	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using upw: %v\n", outFile, err)
+		t.Fatalf("TestExtractFontsCommand: %v\n", err)
 	}
 
-	//Change upw wrong upwOld
-	t.Log("ChangeUserPW wrong upwOld fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	pwOld := "upwWrong"
-	pwNew := "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - %s change userPW using wrong upwOld should fail\n", outFile)
+}
+
+// extractAll extracts the content streams, images and fonts of ctx keyed by kind and object number.
+func extractAll(ctx *pdfcpu.PDFContext) (map[string][]byte, error) {
+
+	m := map[string][]byte{}
+
+	for p := 1; p <= ctx.PageCount; p++ {
+
+		objNrs, err := contentObjNrs(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, objNr := range objNrs {
+			if m[fmt.Sprintf("content%d", objNr)], err = pdfcpu.ExtractContentData(ctx, objNr); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	// Change upw
-	t.Log("ChangeUserPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	pwOld = "upw"
-	pwNew = "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - %s change userPW: %v\n", outFile, err)
+	for objNr := range ctx.Optimize.ImageObjects {
+		io, err := pdfcpu.ExtractImageData(ctx, objNr)
+		if err != nil {
+			return nil, err
+		}
+		if io != nil {
+			m[fmt.Sprintf("image%d", objNr)] = io.ImageDict.Content
+		}
 	}
 
-	// Decrypt wrong opw
-	t.Log("Decrypt wrong opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong opw should fail\n", outFile)
+	for objNr := range ctx.Optimize.FontObjects {
+		fo, err := pdfcpu.ExtractFontData(ctx, objNr)
+		if err != nil {
+			return nil, err
+		}
+		if fo != nil {
+			m[fmt.Sprintf("font%d", objNr)] = fo.Data
+		}
 	}
 
-	// Decrypt wrong upw
-	t.Log("Decrypt wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong upw should fail\n", outFile)
+	return m, nil
+}
+
+// Extract from a shared context using multiple goroutines, run using -race.
+func TestConcurrentReadOnlyContext(t *testing.T) {
+
+	for _, lazy := range []bool{false, true} {
+
+		for _, fn := range []string{"testImage.pdf", "go.pdf"} {
+
+			config := pdfcpu.NewDefaultConfiguration()
+			config.LazyLoading = lazy
+
+			ctx, _, _, _, err := readValidateAndOptimize(filepath.Join(inDir, fn), config, time.Now())
+			if err != nil {
+				t.Fatalf("TestConcurrentReadOnlyContext: %s: %v\n", fn, err)
+			}
+
+			want, err := extractAll(ctx)
+			if err != nil {
+				t.Fatalf("TestConcurrentReadOnlyContext: %s: %v\n", fn, err)
+			}
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < 4; i++ {
+
+				wg.Add(1)
+
+				go func() {
+
+					defer wg.Done()
+
+					got, err := extractAll(ctx)
+					if err != nil {
+						t.Errorf("TestConcurrentReadOnlyContext: %s: %v\n", fn, err)
+						return
+					}
+
+					for k, b := range want {
+						if !bytes.Equal(got[k], b) {
+							t.Errorf("TestConcurrentReadOnlyContext: %s: %s differs\n", fn, k)
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			// Extraction leaves the shared image objects untouched.
+			for objNr, io := range ctx.Optimize.ImageObjects {
+				if io.ImageDict.Content != nil {
+					t.Errorf("TestConcurrentReadOnlyContext: %s: image obj#%d decoded in place\n", fn, objNr)
+				}
+			}
+		}
 	}
+}
 
-	// Decrypt upw
-	t.Log("Decrypt upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwNew"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
+func TestExtractContentCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+
+	_, err := Process(ExtractContentCommand(inFile, outDir, nil, pdfcpu.NewDefaultConfiguration()))
 	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - %s decrypt using upw: %v\n", outFile, err)
+		t.Fatalf("TestExtractContentCommand: %v\n", err)
 	}
 
 }
 
-func TestEncryptOPWOnly(t *testing.T) {
+func TestExtractPagesCommand(t *testing.T) {
 
-	// Test for setting only the owner password.
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
 
-	t.Log("running TestEncryptOPWOnly..")
+	_, err := Process(ExtractPagesCommand(inFile, outDir, []string{"1"}, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestExtractPagesCommand: %v\n", err)
+	}
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
-	outFile := filepath.Join(outDir, "test.pdf")
+}
 
-	// Encrypt opw only
-	t.Log("Encrypt opw only")
-	config := pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err := Process(EncryptCommand(inFile, outFile, config))
+func TestExtractMediaCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - encrypt with opw only to %s: %v\n", outFile, err)
+		t.Fatalf("TestExtractMediaCommand: %v\n", err)
 	}
 
-	// Validate wrong opw succeeds with fallback to empty upw
-	t.Log("Validate wrong opw succeeds with fallback to empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "mediaDemo.pdf")
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong opw succeeds falling back to empty upw!: %v\n", outFile, err)
+		t.Fatalf("TestExtractMediaCommand: %v\n", err)
 	}
 
-	// Validate opw
-	t.Log("Validate opw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err = Process(ValidateCommand(outFile, config))
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationRelaxed
+
+	inFile := filepath.Join(outDir, "mediaDemo.pdf")
+	dirOut := filepath.Join(outDir, "media")
+
+	if err = os.MkdirAll(dirOut, 0755); err != nil {
+		t.Fatalf("TestExtractMediaCommand: %v\n", err)
+	}
+
+	out, err := Process(ListAnnotationsCommand(inFile, nil, config))
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s using opw: %v\n", outFile, err)
+		t.Fatalf("TestExtractMediaCommand: %v\n", err)
 	}
 
-	// Validate wrong upw
-	t.Log("Validate wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong upw should fail!\n", outFile)
+	var annots []pdfcpu.Annotation
+	if err = json.Unmarshal([]byte(out[0]), &annots); err != nil {
+		t.Fatalf("TestExtractMediaCommand: %v\n", err)
 	}
 
-	// Validate no pw using empty upw
-	t.Log("Validate no pw using empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s no pw using empty upw: %v\n", outFile, err)
+	want := map[string]string{"3D": "U3D", "RichMedia": "test.wav", "Screen": "test.wav"}
+
+	var files []string
+
+	for _, a := range annots {
+		name, ok := want[a.Subtype]
+		if !ok {
+			continue
+		}
+		if len(a.Media) != 1 || a.Media[0] != name {
+			t.Fatalf("TestExtractMediaCommand: %s: got media %v want %s\n", a.Subtype, a.Media, name)
+		}
+		m := pdfcpu.MediaObject{Page: a.Page, ObjNr: a.ObjNr, Subtype: a.Subtype, Name: name}
+		files = append(files, m.FileName())
 	}
 
-	// Optimize wrong opw, succeeds with fallback to empty upw
-	t.Log("Optimize wrong opw succeeds with fallback to empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong opw succeeds falling back to empty upw: %v\n", outFile, err)
+	if len(files) != len(want) {
+		t.Fatalf("TestExtractMediaCommand: got %d media annotations want %d\n", len(files), len(want))
 	}
 
-	// Optimize opw
-	t.Log("Optimize opw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	_, err = Process(ExtractMediaCommand(inFile, dirOut, nil, config))
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using opw: %v\n", outFile, err)
+		t.Fatalf("TestExtractMediaCommand: %v\n", err)
 	}
 
-	// Optimize wrong upw
-	t.Log("Optimize wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong upw should fail!\n", outFile)
+	wav, err := ioutil.ReadFile(filepath.Join(inDir, "test.wav"))
+	if err != nil {
+		t.Fatalf("TestExtractMediaCommand: %v\n", err)
 	}
 
-	// Optimize empty upw
-	t.Log("Optimize empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using upw: %v\n", outFile, err)
+	for _, fn := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dirOut, fn))
+		if err != nil {
+			t.Fatalf("TestExtractMediaCommand: %v\n", err)
+		}
+		if strings.HasSuffix(fn, ".u3d") {
+			if !bytes.HasPrefix(b, []byte("U3D")) {
+				t.Fatalf("TestExtractMediaCommand: %s is no U3D file\n", fn)
+			}
+			continue
+		}
+		if !bytes.Equal(b, wav) {
+			t.Fatalf("TestExtractMediaCommand: %s differs from test.wav\n", fn)
+		}
 	}
+}
 
-	// Change opw wrong upw
-	t.Log("ChangeOwnerPW wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	pwOld := "opw"
-	pwNew := "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong upw should fail\n", outFile)
+func TestStreamCommands(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	b1, err := ioutil.ReadFile(filepath.Join(inDir, "Acroforms2.pdf"))
+	if err != nil {
+		t.Fatalf("TestStreamCommands: %v\n", err)
 	}
 
-	// Change opw wrong opwOld
-	t.Log("ChangeOwnerPW wrong opwOld fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	pwOld = "opwOldWrong"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong opwOld should fail\n", outFile)
+	b2, err := ioutil.ReadFile(filepath.Join(inDir, "5116.DCT_Filter.pdf"))
+	if err != nil {
+		t.Fatalf("TestStreamCommands: %v\n", err)
 	}
 
-	// Change opw
-	t.Log("ChangeOwnerPW")
+	pageCount := func(b []byte) int {
+		ctx, err := ReadContext(bytes.NewReader(b), config)
+		if err != nil {
+			t.Fatalf("TestStreamCommands: %v\n", err)
+		}
+		if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+			t.Fatalf("TestStreamCommands: %v\n", err)
+		}
+		return ctx.PageCount
+	}
+
+	n1, n2 := pageCount(b1), pageCount(b2)
+
+	var buf bytes.Buffer
+	if err = OptimizeContext(bytes.NewReader(b1), &buf, config); err != nil {
+		t.Fatalf("TestStreamCommands optimize: %v\n", err)
+	}
+
+	if n := pageCount(buf.Bytes()); n != n1 {
+		t.Fatalf("TestStreamCommands optimize: want %d pages, got %d\n", n1, n)
+	}
+
+	buf.Reset()
+	if err = MergeContext([]io.ReadSeeker{bytes.NewReader(b1), bytes.NewReader(b2)}, &buf, config); err != nil {
+		t.Fatalf("TestStreamCommands merge: %v\n", err)
+	}
+
+	if n := pageCount(buf.Bytes()); n != n1+n2 {
+		t.Fatalf("TestStreamCommands merge: want %d pages, got %d\n", n1+n2, n)
+	}
+
+	pages := map[int]*bytes.Buffer{}
+	newPage := func(pageNr int) (io.Writer, error) {
+		pages[pageNr] = &bytes.Buffer{}
+		return pages[pageNr], nil
+	}
+
+	if err = SplitContext(bytes.NewReader(b1), config, newPage); err != nil {
+		t.Fatalf("TestStreamCommands split: %v\n", err)
+	}
+
+	if len(pages) != n1 {
+		t.Fatalf("TestStreamCommands split: want %d pages, got %d\n", n1, len(pages))
+	}
+
+	for pageNr, buf := range pages {
+		if n := pageCount(buf.Bytes()); n != 1 {
+			t.Fatalf("TestStreamCommands split: page %d: want 1 page, got %d\n", pageNr, n)
+		}
+	}
+
+	pages = map[int]*bytes.Buffer{}
+	if err = ExtractPagesContext(bytes.NewReader(b2), []string{"1"}, config, newPage); err != nil {
+		t.Fatalf("TestStreamCommands extract pages: %v\n", err)
+	}
+
+	if len(pages) != 1 || pages[1] == nil {
+		t.Fatalf("TestStreamCommands extract pages: want page 1, got %d pages\n", len(pages))
+	}
+
+	var names []string
+	err = ExtractContentContext(bytes.NewReader(b2), nil, config, func(pageNr, objNr int, name string) (io.Writer, error) {
+		names = append(names, name)
+		return ioutil.Discard, nil
+	})
+	if err != nil {
+		t.Fatalf("TestStreamCommands extract content: %v\n", err)
+	}
+
+	if len(names) == 0 || !strings.HasSuffix(names[0], ".txt") {
+		t.Fatalf("TestStreamCommands extract content: unexpected content: %v\n", names)
+	}
+}
+
+func TestCancellation(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "CenterOfWhy.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.Context = c
+
+	_, err := Process(ValidateCommand(inFile, config))
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("TestCancellation: want %v, got %v\n", context.Canceled, err)
+	}
+
+	c, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
 	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	pwOld = "opw"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	config.Context = c
+
+	_, err = Process(OptimizeCommand(inFile, outFile, config))
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("TestCancellation: want %v, got %v\n", context.DeadlineExceeded, err)
+	}
+
+	// A live context does not interfere with processing.
+	config = pdfcpu.NewDefaultConfiguration()
+	config.Context = context.Background()
+
+	if _, err = Process(OptimizeCommand(inFile, outFile, config)); err != nil {
+		t.Fatalf("TestCancellation: %v\n", err)
+	}
+}
+
+func TestLazyLoading(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.LazyLoading = true
+
+	ctx, err := pdfcpu.ReadPDFFile(inFile, config)
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - %s change opw: %v\n", outFile, err)
+		t.Fatalf("TestLazyLoading: %v\n", err)
+	}
+
+	unloaded := 0
+	for _, entry := range ctx.Table {
+		if sd, ok := entry.Object.(pdfcpu.PDFStreamDict); ok && sd.Raw == nil {
+			unloaded++
+		}
+	}
+
+	if unloaded == 0 {
+		t.Fatalf("TestLazyLoading: want unloaded streams after reading\n")
+	}
+
+	if err = ctx.LoadStreams(); err != nil {
+		t.Fatalf("TestLazyLoading: %v\n", err)
+	}
+
+	for objNr, entry := range ctx.Table {
+		if sd, ok := entry.Object.(pdfcpu.PDFStreamDict); ok && sd.Raw == nil {
+			t.Fatalf("TestLazyLoading: obj#%d not loaded\n", objNr)
+		}
+	}
+
+	for _, cmd := range []*Command{
+		ValidateCommand(inFile, config),
+		OptimizeCommand(inFile, outFile, config),
+		ExtractPagesCommand(inFile, outDir, []string{"1"}, config),
+		MergeCommand([]string{inFile, inFile}, outFile, config),
+	} {
+		if _, err = Process(cmd); err != nil {
+			t.Fatalf("TestLazyLoading mode %d: %v\n", cmd.Mode, err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		t.Fatalf("TestLazyLoading: %v\n", err)
+	}
+
+	var buf bytes.Buffer
+	if err = OptimizeContext(bytes.NewReader(b), &buf, config); err != nil {
+		t.Fatalf("TestLazyLoading optimize: %v\n", err)
+	}
+
+	ctx, err = ReadContext(bytes.NewReader(buf.Bytes()), pdfcpu.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("TestLazyLoading: %v\n", err)
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("TestLazyLoading: %v\n", err)
+	}
+}
+
+// Validate and optimize all PDFs in testdata processing pages concurrently
+// and compare the results with sequential processing.
+func TestConcurrentPageProcessing(t *testing.T) {
+
+	files, err := ioutil.ReadDir(inDir)
+	if err != nil {
+		t.Fatalf("TestConcurrentPageProcessing: %v\n", err)
+	}
+
+	process := func(fileName string, workers int, lazy bool) (*pdfcpu.PDFContext, error) {
+
+		config := pdfcpu.NewDefaultConfiguration()
+		config.ValidationMode = pdfcpu.ValidationRelaxed
+		config.Workers = workers
+		config.LazyLoading = lazy
+
+		ctx, err := pdfcpu.ReadPDFFile(fileName, config)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+			return nil, err
+		}
+
+		return ctx, pdfcpu.OptimizeXRefTable(ctx)
+	}
+
+	for _, file := range files {
+
+		if !strings.HasSuffix(file.Name(), "pdf") {
+			continue
+		}
+
+		inFile := filepath.Join(inDir, file.Name())
+
+		ctx1, err1 := process(inFile, 1, false)
+
+		for _, lazy := range []bool{false, true} {
+
+			ctx2, err2 := process(inFile, 4, lazy)
+
+			if (err1 == nil) != (err2 == nil) {
+				t.Fatalf("TestConcurrentPageProcessing %s: sequential: %v, concurrent: %v\n", file.Name(), err1, err2)
+			}
+
+			if err1 != nil {
+				continue
+			}
+
+			n1, _ := ctx1.Optimize.DuplicateImageObjectsString()
+			n2, _ := ctx2.Optimize.DuplicateImageObjectsString()
+			if n1 != n2 {
+				t.Fatalf("TestConcurrentPageProcessing %s: want %d duplicate image objects, got %d\n", file.Name(), n1, n2)
+			}
+
+			n1, _ = ctx1.Optimize.DuplicateFontObjectsString()
+			n2, _ = ctx2.Optimize.DuplicateFontObjectsString()
+			if n1 != n2 {
+				t.Fatalf("TestConcurrentPageProcessing %s: want %d duplicate font objects, got %d\n", file.Name(), n1, n2)
+			}
+		}
+	}
+}
+
+func TestCompressObjects(t *testing.T) {
+
+	for _, fileName := range []string{"5116.DCT_Filter.pdf", "ProgrammingInJava.pdf", "networkProgr.pdf", "schmager_plateau10.pdf"} {
+
+		inFile := filepath.Join(inDir, fileName)
+
+		optimize := func(compress bool) []byte {
+			config := pdfcpu.NewDefaultConfiguration()
+			config.CompressObjects = compress
+			b, err := ioutil.ReadFile(inFile)
+			if err != nil {
+				t.Fatalf("TestCompressObjects: %v\n", err)
+			}
+			var buf bytes.Buffer
+			if err = OptimizeContext(bytes.NewReader(b), &buf, config); err != nil {
+				t.Fatalf("TestCompressObjects %s: %v\n", fileName, err)
+			}
+			return buf.Bytes()
+		}
+
+		b1, b2 := optimize(false), optimize(true)
+
+		if len(b2) >= len(b1) {
+			t.Fatalf("TestCompressObjects %s: want less than %d bytes, got %d\n", fileName, len(b1), len(b2))
+		}
+
+		ctx, err := ReadContext(bytes.NewReader(b2), pdfcpu.NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("TestCompressObjects %s: %v\n", fileName, err)
+		}
+
+		if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+			t.Fatalf("TestCompressObjects %s: %v\n", fileName, err)
+		}
+
+		// Only streams remain outside of object streams.
+		for objNr, entry := range ctx.Table {
+			if entry.Free || entry.ObjectStream != nil {
+				continue
+			}
+			if _, ok := entry.Object.(pdfcpu.PDFStreamDict); !ok && !ctx.Read.IsObjectStreamObject(objNr) && !ctx.Read.IsXRefStreamObject(objNr) {
+				t.Fatalf("TestCompressObjects %s: obj#%d not compressed: %T\n", fileName, objNr, entry.Object)
+			}
+		}
+	}
+}
+
+func TestDuplicateStreams(t *testing.T) {
+
+	for _, fileName := range []string{"go.pdf", "testImage.pdf", "5116.DCT_Filter.pdf", "networkProgr.pdf"} {
+
+		b, err := ioutil.ReadFile(filepath.Join(inDir, fileName))
+		if err != nil {
+			t.Fatalf("TestDuplicateStreams: %v\n", err)
+		}
+
+		streams := func(b []byte) int {
+			ctx, err := ReadContext(bytes.NewReader(b), pdfcpu.NewDefaultConfiguration())
+			if err != nil {
+				t.Fatalf("TestDuplicateStreams %s: %v\n", fileName, err)
+			}
+			if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+				t.Fatalf("TestDuplicateStreams %s: %v\n", fileName, err)
+			}
+			var n int
+			for objNr, entry := range ctx.Table {
+				if _, ok := entry.Object.(pdfcpu.PDFStreamDict); ok && !entry.Free &&
+					!ctx.Read.IsObjectStreamObject(objNr) && !ctx.Read.IsXRefStreamObject(objNr) {
+					n++
+				}
+			}
+			return n
+		}
+
+		var buf1 bytes.Buffer
+		if err = OptimizeContext(bytes.NewReader(b), &buf1, pdfcpu.NewDefaultConfiguration()); err != nil {
+			t.Fatalf("TestDuplicateStreams %s: %v\n", fileName, err)
+		}
+
+		// Merging a file with itself duplicates all of its streams.
+		var buf2 bytes.Buffer
+		rss := []io.ReadSeeker{bytes.NewReader(b), bytes.NewReader(b)}
+		if err = MergeContext(rss, &buf2, pdfcpu.NewDefaultConfiguration()); err != nil {
+			t.Fatalf("TestDuplicateStreams %s: %v\n", fileName, err)
+		}
+
+		if n1, n2 := streams(buf1.Bytes()), streams(buf2.Bytes()); n1 != n2 {
+			t.Fatalf("TestDuplicateStreams %s: want %d streams, got %d\n", fileName, n1, n2)
+		}
+	}
+}
+
+func TestRemoveUnreachableObjects(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "ProgrammingInJava.pdf")
+	outFile := filepath.Join(outDir, "unreachable.pdf")
+
+	report, err := Process(OptimizeCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestRemoveUnreachableObjects: %v\n", err)
+	}
+
+	if len(report) != 1 || !strings.HasPrefix(report[0], "removed ") {
+		t.Fatalf("TestRemoveUnreachableObjects: unexpected report: %v\n", report)
+	}
+
+	ctxIn, err := Read(inFile, config)
+	if err != nil {
+		t.Fatalf("TestRemoveUnreachableObjects: %v\n", err)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestRemoveUnreachableObjects: %v\n", err)
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("TestRemoveUnreachableObjects: %v\n", err)
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctxIn.XRefTable); err != nil {
+		t.Fatalf("TestRemoveUnreachableObjects: %v\n", err)
+	}
+
+	if ctx.PageCount != ctxIn.PageCount {
+		t.Fatalf("TestRemoveUnreachableObjects: want %d pages, got %d\n", ctxIn.PageCount, ctx.PageCount)
+	}
+
+	// Nothing left to remove.
+	if report, err = Process(OptimizeCommand(outFile, outFile, config)); err != nil {
+		t.Fatalf("TestRemoveUnreachableObjects: %v\n", err)
+	}
+
+	if len(report) > 0 {
+		t.Fatalf("TestRemoveUnreachableObjects: unexpected report: %v\n", report)
+	}
+}
+
+func TestRecompressStreams(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	outFile := filepath.Join(outDir, "recompressed.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.RecompressStreams = true
+	config.CompressionLevel = 9
+
+	report, err := Process(OptimizeCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestRecompressStreams: %v\n", err)
+	}
+
+	// The page tree of this file gets rebalanced too.
+	if len(report) == 0 || !strings.HasPrefix(report[len(report)-1], "recompressed ") {
+		t.Fatalf("TestRecompressStreams: unexpected report: %v\n", report)
+	}
+
+	optFile := filepath.Join(outDir, "notRecompressed.pdf")
+	if _, err = Process(OptimizeCommand(inFile, optFile, pdfcpu.NewDefaultConfiguration())); err != nil {
+		t.Fatalf("TestRecompressStreams: %v\n", err)
+	}
+
+	fi1, err := os.Stat(optFile)
+	if err != nil {
+		t.Fatalf("TestRecompressStreams: %v\n", err)
+	}
+
+	fi2, err := os.Stat(outFile)
+	if err != nil {
+		t.Fatalf("TestRecompressStreams: %v\n", err)
+	}
+
+	if fi2.Size() >= fi1.Size() {
+		t.Fatalf("TestRecompressStreams: recompressed file not smaller: %d >= %d bytes\n", fi2.Size(), fi1.Size())
+	}
+
+	if _, err = Process(ValidateCommand(outFile, pdfcpu.NewDefaultConfiguration())); err != nil {
+		t.Fatalf("TestRecompressStreams: %v\n", err)
+	}
+}
+
+func TestRepairXRefTable(t *testing.T) {
+
+	for _, fileName := range []string{"go.pdf", "annotTest.pdf"} {
+
+		b, err := ioutil.ReadFile(filepath.Join(inDir, fileName))
+		if err != nil {
+			t.Fatalf("TestRepairXRefTable: %v\n", err)
+		}
+
+		ctxIn, err := ReadContext(bytes.NewReader(b), pdfcpu.NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("TestRepairXRefTable: %s: %v\n", fileName, err)
+		}
+
+		if ctxIn.Read.Repaired {
+			t.Fatalf("TestRepairXRefTable: %s: unexpected repair\n", fileName)
+		}
+
+		if err = pdfcpu.ValidateXRefTable(ctxIn.XRefTable); err != nil {
+			t.Fatalf("TestRepairXRefTable: %s: %v\n", fileName, err)
+		}
+
+		i := bytes.LastIndex(b, []byte("startxref"))
+
+		for _, c := range []struct {
+			name string
+			b    []byte
+		}{
+			{"bad startxref", append(append([]byte{}, b[:i]...), []byte("startxref\n123\n%%EOF\n")...)},
+			{"missing xref", bytes.Replace(b, []byte("xref"), []byte("xxxx"), -1)},
+			{"shifted offsets", append([]byte("garbage preceding the header\n"), b...)},
+		} {
+
+			ctx, err := ReadContext(bytes.NewReader(c.b), pdfcpu.NewDefaultConfiguration())
+			if err != nil {
+				t.Fatalf("TestRepairXRefTable: %s, %s: %v\n", fileName, c.name, err)
+			}
+
+			if !ctx.Read.Repaired {
+				t.Fatalf("TestRepairXRefTable: %s, %s: xref table not repaired\n", fileName, c.name)
+			}
+
+			if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+				t.Fatalf("TestRepairXRefTable: %s, %s: %v\n", fileName, c.name, err)
+			}
+
+			if ctx.PageCount != ctxIn.PageCount {
+				t.Fatalf("TestRepairXRefTable: %s, %s: want %d pages, got %d\n", fileName, c.name, ctxIn.PageCount, ctx.PageCount)
+			}
+
+			// Writing a repaired file fixes it.
+			var buf bytes.Buffer
+			if err = WriteContext(ctx, &buf); err != nil {
+				t.Fatalf("TestRepairXRefTable: %s, %s: %v\n", fileName, c.name, err)
+			}
+
+			if ctx, err = ReadContext(bytes.NewReader(buf.Bytes()), pdfcpu.NewDefaultConfiguration()); err != nil {
+				t.Fatalf("TestRepairXRefTable: %s, %s: %v\n", fileName, c.name, err)
+			}
+
+			if ctx.Read.Repaired {
+				t.Fatalf("TestRepairXRefTable: %s, %s: written file needs repair\n", fileName, c.name)
+			}
+		}
+	}
+}
+
+func TestXRefFormats(t *testing.T) {
+
+	b, err := ioutil.ReadFile(filepath.Join(inDir, "go.pdf"))
+	if err != nil {
+		t.Fatalf("TestXRefFormats: %v\n", err)
+	}
+
+	read := func(b []byte, reader15 bool) *pdfcpu.PDFContext {
+		config := pdfcpu.NewDefaultConfiguration()
+		config.Reader15 = reader15
+		ctx, err := ReadContext(bytes.NewReader(b), config)
+		if err != nil {
+			t.Fatalf("TestXRefFormats: %v\n", err)
+		}
+		return ctx
+	}
+
+	for _, f := range []struct {
+		name                    string
+		xRefStream, hybrid      bool
+		wantXRef, wantXRefStm   bool
+		wantObjectStreamObjects bool
+	}{
+		{"table", false, false, true, false, false},
+		{"stream", true, false, false, false, true},
+		{"hybrid", true, true, true, true, true},
+	} {
+
+		config := pdfcpu.NewDefaultConfiguration()
+		config.WriteObjectStream = f.xRefStream
+		config.WriteXRefStream = f.xRefStream
+		config.WriteHybridXRef = f.hybrid
+
+		var buf bytes.Buffer
+		if err = OptimizeContext(bytes.NewReader(b), &buf, config); err != nil {
+			t.Fatalf("TestXRefFormats %s: %v\n", f.name, err)
+		}
+
+		if got := bytes.Contains(buf.Bytes(), []byte("\nxref")); got != f.wantXRef {
+			t.Fatalf("TestXRefFormats %s: xref table: want %t, got %t\n", f.name, f.wantXRef, got)
+		}
+
+		if got := bytes.Contains(buf.Bytes(), []byte("/XRefStm")); got != f.wantXRefStm {
+			t.Fatalf("TestXRefFormats %s: XRefStm: want %t, got %t\n", f.name, f.wantXRefStm, got)
+		}
+
+		ctx := read(buf.Bytes(), true)
+
+		if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+			t.Fatalf("TestXRefFormats %s: %v\n", f.name, err)
+		}
+
+		if got := len(ctx.Read.ObjectStreams) > 0; got != f.wantObjectStreamObjects {
+			t.Fatalf("TestXRefFormats %s: object streams: want %t, got %t\n", f.name, f.wantObjectStreamObjects, got)
+		}
+
+		if !f.hybrid {
+			continue
+		}
+
+		// PDF 1.4 readers ignore the structure tree hidden in object streams but see all pages.
+		ctx14 := read(buf.Bytes(), false)
+
+		if err = ctx14.EnsurePageCount(); err != nil {
+			t.Fatalf("TestXRefFormats %s: %v\n", f.name, err)
+		}
+
+		if ctx14.PageCount != ctx.PageCount {
+			t.Fatalf("TestXRefFormats %s: want %d pages, got %d\n", f.name, ctx.PageCount, ctx14.PageCount)
+		}
+	}
+}
+
+func TestEncryptUPWOnly(t *testing.T) {
+
+	// Test for setting only the user password.
+
+	t.Log("running TestEncryptUPWOnly..")
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	// Encrypt upw only
+	t.Log("Encrypt upw only")
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - encrypt with upw only to %s: %v\n", outFile, err)
+	}
+
+	// Validate wrong upw
+	t.Log("Validate wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if !errors.Is(err, pdfcpu.ErrWrongPassword) {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong upw should fail with ErrWrongPassword: %v\n", outFile, err)
+	}
+
+	// Validate wrong opw
+	t.Log("Validate wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong opw should fail!\n", outFile)
+	}
+
+	// Validate default opw=upw (if there is no ownerpw set)
+	t.Log("Validate default opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "upw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using default opw: %s!\n", outFile, err)
+	}
+
+	// Validate upw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using upw: %v\n", outFile, err)
+	}
+
+	// Optimize wrong opw
+	t.Log("Optimize wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong opw should fail!\n", outFile)
+	}
+
+	// Optimize empty opw
+	t.Log("Optimize empty opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = ""
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using empty opw should fail!\n", outFile)
+	}
+
+	// Optimize wrong upw
+	t.Log("Optimize wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Optimize upw
+	t.Log("Optimize upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using upw: %v\n", outFile, err)
+	}
+
+	//Change upw wrong upwOld
+	t.Log("ChangeUserPW wrong upwOld fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	pwOld := "upwWrong"
+	pwNew := "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - %s change userPW using wrong upwOld should fail\n", outFile)
+	}
+
+	// Change upw
+	t.Log("ChangeUserPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	pwOld = "upw"
+	pwNew = "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - %s change userPW: %v\n", outFile, err)
+	}
+
+	// Decrypt wrong opw
+	t.Log("Decrypt wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong opw should fail\n", outFile)
+	}
+
+	// Decrypt wrong upw
+	t.Log("Decrypt wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong upw should fail\n", outFile)
+	}
+
+	// Decrypt upw
+	t.Log("Decrypt upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwNew"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - %s decrypt using upw: %v\n", outFile, err)
+	}
+
+	// Decrypt decrypted
+	t.Log("Decrypt decrypted fails")
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if !errors.Is(err, pdfcpu.ErrNotEncrypted) {
+		t.Fatalf("TestEncryptUPWOnly - %s decrypt decrypted should fail with ErrNotEncrypted: %v\n", outFile, err)
+	}
+
+}
+
+func TestEncryptOPWOnly(t *testing.T) {
+
+	// Test for setting only the owner password.
+
+	t.Log("running TestEncryptOPWOnly..")
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	// Encrypt opw only
+	t.Log("Encrypt opw only")
+	config := pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - encrypt with opw only to %s: %v\n", outFile, err)
+	}
+
+	// Validate wrong opw succeeds with fallback to empty upw
+	t.Log("Validate wrong opw succeeds with fallback to empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong opw succeeds falling back to empty upw!: %v\n", outFile, err)
+	}
+
+	// Validate opw
+	t.Log("Validate opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s using opw: %v\n", outFile, err)
+	}
+
+	// Validate wrong upw
+	t.Log("Validate wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Validate no pw using empty upw
+	t.Log("Validate no pw using empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s no pw using empty upw: %v\n", outFile, err)
+	}
+
+	// Optimize wrong opw, succeeds with fallback to empty upw
+	t.Log("Optimize wrong opw succeeds with fallback to empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong opw succeeds falling back to empty upw: %v\n", outFile, err)
+	}
+
+	// Optimize opw
+	t.Log("Optimize opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using opw: %v\n", outFile, err)
+	}
+
+	// Optimize wrong upw
+	t.Log("Optimize wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Optimize empty upw
+	t.Log("Optimize empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using upw: %v\n", outFile, err)
+	}
+
+	// Change opw wrong upw
+	t.Log("ChangeOwnerPW wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	pwOld := "opw"
+	pwNew := "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong upw should fail\n", outFile)
+	}
+
+	// Change opw wrong opwOld
+	t.Log("ChangeOwnerPW wrong opwOld fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	pwOld = "opwOldWrong"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong opwOld should fail\n", outFile)
+	}
+
+	// Change opw
+	t.Log("ChangeOwnerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	pwOld = "opw"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - %s change opw: %v\n", outFile, err)
+	}
+
+	// Decrypt wrong upw
+	t.Log("Decrypt wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - %s decrypt using wrong upw should fail \n", outFile)
+	}
+
+	// Decrypt wrong opw succeeds because of fallback to empty upw.
+	t.Log("Decrypt wrong opw succeeds because of fallback to empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - %s decrypt using opw: %v\n", outFile, err)
+	}
+
+}
+
+func TestEncrypt(t *testing.T) {
+
+	t.Log("running TestEncrypt..")
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	// Encrypt opw and upw
+	t.Log("Encrypt")
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - encrypt to %s: %v\n", outFile, err)
+	}
+
+	// Validate wrong opw
+	t.Log("Validate wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncrypt - validate %s using wrong opw should fail!\n", outFile)
+	}
+
+	// Validate opw
+	t.Log("Validate opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s using opw: %v\n", outFile, err)
+	}
+
+	// Validate wrong upw
+	t.Log("Validate wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncrypt - validate %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Validate upw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+	}
+
+	// Change upw to "" = remove document open password.
+	t.Log("ChangeUserPW to \"\"")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	pwOld := "upw"
+	pwNew := ""
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncrypt - %s change userPW to \"\": %v\n", outFile, err)
+	}
+
+	// Validate upw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+	}
+
+	// Validate no pw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s: %v\n", outFile, err)
+	}
+
+	// Change opw
+	t.Log("ChangeOwnerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	pwOld = "opw"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncrypt - %s change opw: %v\n", outFile, err)
+	}
+
+	// Decrypt wrong upw
+	t.Log("Decrypt wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncrypt - %s decrypt using wrong upw should fail\n", outFile)
+	}
+
+	// Decrypt wrong opw succeeds on empty upw
+	t.Log("Decrypt wrong opw succeeds on empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - %s decrypt wrong opw, empty upw: %v\n", outFile, err)
+	}
+}
+
+func encryptDecrypt(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+
+	inFile := filepath.Join(inDir, fileName)
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	t.Log(inFile)
+
+	// Encrypt
+	t.Log("Encrypt")
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+	}
+
+	// Encrypt already encrypted
+	t.Log("Encrypt already encrypted")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	_, err = Process(EncryptCommand(outFile, outFile, config))
+	if !errors.Is(err, pdfcpu.ErrEncrypted) {
+		t.Fatalf("TestEncryptDecrypt - encrypt encrypted %s: %v\n", outFile, err)
+	}
+
+	// Validate using wrong owner pw
+	t.Log("Validate wrong ownerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - validate %s using wrong ownerPW: %v\n", outFile, err)
+	}
+
+	// Optimize using wrong owner pw
+	t.Log("Optimize wrong ownerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - optimize %s using wrong ownerPW: %v\n", outFile, err)
+	}
+
+	// Trim using wrong owner pw, falls back to upw and fails with insufficient permissions.
+	t.Log("Trim wrong ownerPW, fallback to upw and fail with insufficient permissions.")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	// pageSelection = nil, writes w/o trimming anything, but sufficient for testing.
+	_, err = Process(TrimCommand(outFile, outFile, nil, config))
+	if err == nil {
+		t.Fatalf("TestEncryptDecrypt - trim %s using wrong ownerPW should fail: \n", outFile)
+	}
+
+	// Split using wrong owner pw, falls back to upw and fails with insufficient permissions.
+	t.Log("Split wrong ownerPW, fallback to upw and fail with insufficient permissions.")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(SplitCommand(outFile, outDir, config))
+	if err == nil {
+		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW should fail: \n", outFile)
+	}
+
+	// Add permissions
+	t.Log("Add user access permissions")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.UserAccessPermissions = pdfcpu.PermissionsAll
+	_, err = Process(AddPermissionsCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - %s add permissions: %v\n", outFile, err)
+	}
+
+	// Split using wrong owner pw, falls back to upw
+	t.Log("Split wrong ownerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(SplitCommand(outFile, outDir, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW: %v\n", outFile, err)
+	}
+
+	// Validate
+	t.Log("Validate")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - validate %s: %v\n", outFile, err)
+	}
+
+	// ChangeUserPW using wrong userpw
+	t.Log("ChangeUserPW wrong userpw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	pwOld := "upwWrong"
+	pwNew := "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryption - %s change userPW using wrong userPW should fail:\n", outFile)
+	}
+
+	// ChangeUserPW
+	t.Log("ChangeUserPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	pwOld = "upw"
+	pwNew = "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryption - change userPW %s: %v\n", outFile, err)
+	}
+
+	// ChangeOwnerPW
+	t.Log("ChangeOwnerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwNew"
+	pwOld = "opw"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryption - change ownerPW %s: %v\n", outFile, err)
+	}
+
+	// Decrypt using wrong pw
+	t.Log("\nDecrypt using wrong pw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptDecrypt - decrypt using wrong pw %s\n", outFile)
+	}
+
+	// Decrypt
+	t.Log("\nDecrypt")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwNew"
+	config.OwnerPW = "opwNew"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - decrypt %s: %v\n", outFile, err)
+	}
+
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	encryptDecrypt("5116.DCT_Filter.pdf", config, t)
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.EncryptUsingAES = false
+	config.EncryptUsing128BitKey = false
+	encryptDecrypt("networkProgr.pdf", config, t)
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.EncryptUsing256BitKey = true
+	encryptDecrypt("5116.DCT_Filter.pdf", config, t)
+}
+
+// encrypted/aes256R6.pdf is AES-256 (revision 6) encrypted using the user password "user" and the owner password "owner".
+// It was produced by a writer independent of pdfcpu and lives apart from the files processed without passwords.
+func TestDecryptAES256R6(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "encrypted", "aes256R6.pdf")
+	outFile := filepath.Join(outDir, "aes256R6.pdf")
+
+	for _, pw := range []struct{ upw, opw string }{{"user", ""}, {"", "owner"}} {
+
+		config := pdfcpu.NewDefaultConfiguration()
+		config.UserPW, config.OwnerPW = pw.upw, pw.opw
+
+		if _, err := Process(DecryptCommand(inFile, outFile, config)); err != nil {
+			t.Fatalf("TestDecryptAES256R6 %v: %v\n", pw, err)
+		}
+
+		b, err := ioutil.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("TestDecryptAES256R6: %v\n", err)
+		}
+
+		for _, s := range []string{"(Decrypted by pdfcpu)", "(AES-256 R6 test)"} {
+			if !bytes.Contains(b, []byte(s)) {
+				t.Errorf("TestDecryptAES256R6 %v: %s missing\n", pw, s)
+			}
+		}
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "wrong"
+
+	if _, err := Process(DecryptCommand(inFile, outFile, config)); err == nil {
+		t.Fatal("TestDecryptAES256R6: should have failed for wrong password")
+	}
+}
+
+func selfSignedCertificate(cn string, serial int64, t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	return selfSignedCertificateValid(cn, serial, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), t)
+}
+
+func selfSignedCertificateValid(cn string, serial int64, notBefore, notAfter time.Time, t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %v\n", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v\n", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v\n", err)
+	}
+
+	return cert, key
+}
+
+func TestEncryptForRecipients(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	alice, aliceKey := selfSignedCertificate("alice", 1, t)
+	bob, bobKey := selfSignedCertificate("bob", 2, t)
+	eve, eveKey := selfSignedCertificate("eve", 3, t)
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.Recipients = []*x509.Certificate{alice, bob}
+	config.UserAccessPermissions = pdfcpu.PermissionsAll
+	if _, err := Process(EncryptCommand(inFile, outFile, config)); err != nil {
+		t.Fatalf("TestEncryptForRecipients - encrypt %s: %v\n", inFile, err)
+	}
+
+	// Missing certificate.
+	config = pdfcpu.NewDefaultConfiguration()
+	if _, err := Process(ValidateCommand(outFile, config)); err == nil {
+		t.Fatalf("TestEncryptForRecipients - validate %s without certificate\n", outFile)
+	}
+
+	// Not a recipient.
+	config.Certificate, config.PrivateKey = eve, eveKey
+	if _, err := Process(ValidateCommand(outFile, config)); err == nil {
+		t.Fatalf("TestEncryptForRecipients - validate %s as non recipient\n", outFile)
+	}
+
+	config.Certificate, config.PrivateKey = bob, bobKey
+	if _, err := Process(ValidateCommand(outFile, config)); err != nil {
+		t.Fatalf("TestEncryptForRecipients - validate %s as recipient: %v\n", outFile, err)
+	}
+
+	// Optimize keeps the encryption.
+	if _, err := Process(OptimizeCommand(outFile, outFile, config)); err != nil {
+		t.Fatalf("TestEncryptForRecipients - optimize %s: %v\n", outFile, err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.Certificate, config.PrivateKey = alice, aliceKey
+	if _, err := Process(DecryptCommand(outFile, outFile, config)); err != nil {
+		t.Fatalf("TestEncryptForRecipients - decrypt %s: %v\n", outFile, err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	if _, err := Process(ValidateCommand(outFile, config)); err != nil {
+		t.Fatalf("TestEncryptForRecipients - validate decrypted %s: %v\n", outFile, err)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	p, err := pdfcpu.ParsePermissions("print, copy")
+	if err != nil {
+		t.Fatalf("TestPermissions - parse: %v\n", err)
+	}
+
+	if _, err = pdfcpu.ParsePermissions("print, fly"); err == nil {
+		t.Fatal("TestPermissions - parse unknown permission should fail")
+	}
+
+	permissions := func() pdfcpu.Permissions {
+		config := pdfcpu.NewDefaultConfiguration()
+		config.UserPW = "upw"
+		config.OwnerPW = "opw"
+		ctx, err := Read(outFile, config)
+		if err != nil {
+			t.Fatalf("TestPermissions - read %s: %v\n", outFile, err)
+		}
+		return pdfcpu.NewPermissions(int16(ctx.E.P))
+	}
+
+	for _, key256 := range []bool{false, true} {
+
+		config := pdfcpu.NewDefaultConfiguration()
+		config.UserPW = "upw"
+		config.OwnerPW = "opw"
+		config.EncryptUsing256BitKey = key256
+		config.SetPermissions(*p)
+		if _, err := Process(EncryptCommand(inFile, outFile, config)); err != nil {
+			t.Fatalf("TestPermissions - encrypt %s: %v\n", inFile, err)
+		}
+
+		if got := permissions(); got != *p {
+			t.Fatalf("TestPermissions - encrypt: got %s, want %s\n", got, p)
+		}
+
+		// Grant printing in full quality and page assembly only.
+		config = pdfcpu.NewDefaultConfiguration()
+		config.UserPW = "upw"
+		config.OwnerPW = "opw"
+		want := pdfcpu.Permissions{Print: true, PrintHighRes: true, Assemble: true}
+		config.SetPermissions(want)
+		if _, err := Process(AddPermissionsCommand(outFile, config)); err != nil {
+			t.Fatalf("TestPermissions - add permissions %s: %v\n", outFile, err)
+		}
+
+		if got := permissions(); got != want {
+			t.Fatalf("TestPermissions - add permissions: got %s, want %s\n", got, want)
+		}
+	}
+}
+
+// externalSigner simulates a signing device holding the private key.
+type externalSigner struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+func (s externalSigner) Certificates() []*x509.Certificate {
+	return []*x509.Certificate{s.cert}
+}
+
+func (s externalSigner) Sign(digest []byte) ([]byte, error) {
+	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest)
+}
+
+func TestSignCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	fieldFile := filepath.Join(outDir, "signatureFieldToSign.pdf")
+	outFile := filepath.Join(outDir, "signed.pdf")
+
+	cert, key := selfSignedCertificate("alice", 1, t)
+
+	signers := []pdfcpu.Signer{
+		pdfcpu.NewSigner(key, []*x509.Certificate{cert}),
+		externalSigner{cert, key},
+		pdfcpu.NewSigner(key, []*x509.Certificate{cert}),
+		pdfcpu.NewSigner(key, []*x509.Certificate{cert}),
+		pdfcpu.NewSigner(key, []*x509.Certificate{cert}),
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	fa := &pdfcpu.FieldAttributes{Name: "approval", Page: 1, Rect: types.NewRectangle(300, 50, 500, 110)}
+	if _, err := Process(AddSignatureFieldCommand(inFile, fieldFile, fa, "", config)); err != nil {
+		t.Fatalf("TestSignCommand - add field: %v\n", err)
+	}
+
+	for i, s := range []string{
+		"field:approval, reason:Approved, location:Vienna",
+		"page:1, rect:50 50 250 110, contact:alice@example.com",
+		"",
+		"rect:50 150 250 210, name:Alice Smith, reason:Reviewed, image:../../resources/pdfchip3.png",
+		"rect:300 150 500 210, location:Vienna, bgimage:../../resources/pdfchip3.png",
+	} {
+
+		if i > 0 {
+			fieldFile = outFile
+		}
+
+		orig, err := ioutil.ReadFile(fieldFile)
+		if err != nil {
+			t.Fatalf("TestSignCommand: %v\n", err)
+		}
+
+		sa, err := pdfcpu.ParseSignatureAttributes(s)
+		if err != nil {
+			t.Fatalf("TestSignCommand - parse %s: %v\n", s, err)
+		}
+
+		if _, err = Process(SignCommand(fieldFile, outFile, sa, signers[i], config)); err != nil {
+			t.Fatalf("TestSignCommand - sign %s: %v\n", s, err)
+		}
+
+		b, err := ioutil.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("TestSignCommand: %v\n", err)
+		}
+
+		// Signing must not touch the bytes of earlier revisions.
+		if !bytes.HasPrefix(b, orig) {
+			t.Fatalf("TestSignCommand - sign %s: not an incremental update\n", s)
+		}
+
+		if _, err = Process(ValidateCommand(outFile, config)); err != nil {
+			t.Fatalf("TestSignCommand - validate %s: %v\n", outFile, err)
+		}
+	}
+
+	if _, err := pdfcpu.ParseSignatureAttributes("image:a.png, bgimage:b.png"); err == nil {
+		t.Fatalf("TestSignCommand - parse two images should fail\n")
+	}
+
+	// A signed field can't be signed again.
+	sa := &pdfcpu.SignatureAttributes{FieldName: "approval", Page: 1}
+	if _, err := Process(SignCommand(outFile, outFile, sa, signers[0], config)); err == nil {
+		t.Fatalf("TestSignCommand - sign signed field\n")
+	}
+}
+
+func TestVerifySignatures(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "signedVerify.pdf")
+
+	cert, key := selfSignedCertificate("alice", 1, t)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	sa := &pdfcpu.SignatureAttributes{Page: 1, Reason: "Approved"}
+	if _, err := Process(SignCommand(inFile, outFile, sa, pdfcpu.NewSigner(key, []*x509.Certificate{cert}), config)); err != nil {
+		t.Fatalf("TestVerifySignatures - sign: %v\n", err)
+	}
+
+	// The signing time defaults to now without touching the caller's attributes.
+	if !sa.Time.IsZero() {
+		t.Fatalf("TestVerifySignatures - sign modified the signature attributes\n")
+	}
+
+	verify := func(fileName string, roots *x509.CertPool) *pdfcpu.SignatureReport {
+		srs, err := VerifySignatures(fileName, roots, config)
+		if err != nil {
+			t.Fatalf("TestVerifySignatures - verify %s: %v\n", fileName, err)
+		}
+		if len(srs) != 1 {
+			t.Fatalf("TestVerifySignatures - verify %s: %d signatures\n", fileName, len(srs))
+		}
+		return srs[0]
+	}
+
+	if sr := verify(outFile, roots); !sr.Valid() || sr.Reason != "Approved" {
+		t.Fatalf("TestVerifySignatures - signature invalid:\n%s\n", sr)
+	}
+
+	// Self signed certificates are untrusted unless part of the trust anchors.
+	if sr := verify(outFile, x509.NewCertPool()); sr.Valid() || sr.ChainTrusted || !sr.SignatureValid {
+		t.Fatalf("TestVerifySignatures - untrusted signature:\n%s\n", sr)
+	}
+
+	b, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("TestVerifySignatures: %v\n", err)
+	}
+
+	// Modify the page content by an incremental update.
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestVerifySignatures - read: %v\n", err)
+	}
+
+	snap := ctx.Snapshot()
+
+	d, _, err := ctx.PageDict(1)
+	if err != nil {
+		t.Fatalf("TestVerifySignatures - page: %v\n", err)
+	}
+
+	o, _ := d.Find("Contents")
+	if arr, err := ctx.DereferenceArray(o); err == nil && arr != nil {
+		o = (*arr)[0]
+	}
+
+	entry, _ := ctx.FindTableEntryLight(o.(pdfcpu.PDFIndirectRef).ObjectNumber.Value())
+	sd := entry.Object.(pdfcpu.PDFStreamDict)
+	sd.Raw = append(sd.Raw, ' ')
+	entry.Object = sd
+
+	bb, err := pdfcpu.WriteIncrement(ctx, b, ctx.ModifiedObjects(snap))
+	if err != nil {
+		t.Fatalf("TestVerifySignatures - write increment: %v\n", err)
+	}
+
+	modFile := filepath.Join(outDir, "signedModified.pdf")
+	if err = ioutil.WriteFile(modFile, bb, 0644); err != nil {
+		t.Fatalf("TestVerifySignatures: %v\n", err)
+	}
+
+	if sr := verify(modFile, roots); sr.Valid() || !sr.ContentModified || !sr.DigestValid || sr.WholeFile {
+		t.Fatalf("TestVerifySignatures - modified content:\n%s\n", sr)
+	}
+
+	// Tamper with the signed bytes.
+	b[10] ^= 0xFF
+	if err = ioutil.WriteFile(modFile, b, 0644); err != nil {
+		t.Fatalf("TestVerifySignatures: %v\n", err)
+	}
+
+	if sr := verify(modFile, roots); sr.Valid() || sr.DigestValid {
+		t.Fatalf("TestVerifySignatures - tampered file:\n%s\n", sr)
+	}
+}
+
+func TestVerifySignatureExpired(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "signedExpired.pdf")
+
+	// The certificate expired an hour ago, the signer claims to have signed before.
+	cert, key := selfSignedCertificateValid("alice", 1, time.Now().Add(-3*time.Hour), time.Now().Add(-time.Hour), t)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	sa := &pdfcpu.SignatureAttributes{Page: 1, Time: time.Now().Add(-2 * time.Hour)}
+	if _, err := Process(SignCommand(inFile, outFile, sa, pdfcpu.NewSigner(key, []*x509.Certificate{cert}), config)); err != nil {
+		t.Fatalf("TestVerifySignatureExpired - sign: %v\n", err)
+	}
+
+	srs, err := VerifySignatures(outFile, roots, config)
+	if err != nil {
+		t.Fatalf("TestVerifySignatureExpired - verify: %v\n", err)
+	}
+
+	if len(srs) != 1 || srs[0].Valid() || srs[0].ChainTrusted || !srs[0].SignatureValid {
+		t.Fatalf("TestVerifySignatureExpired - backdated signature of expired certificate:\n%v\n", srs)
+	}
+}
+
+func copyFile(srcFileName, destFileName string) (err error) {
+
+	from, err := os.Open(srcFileName)
+	if err != nil {
+		return
+	}
+	defer from.Close()
+
+	to, err := os.OpenFile(destFileName, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return
+	}
+	defer to.Close()
+
+	_, err = io.Copy(to, from)
+
+	return
+}
+
+func prepareForAttachmentTest() (err error) {
+
+	for _, fileName := range []string{"go.pdf", "golang.pdf", "T4.pdf", "go-lecture.pdf", "test.wav"} {
+		inFile := filepath.Join(inDir, fileName)
+		outFile := filepath.Join(outDir, fileName)
+		err = copyFile(inFile, outFile)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func testAttachmentsStage1(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+
+	// attach list must be 0
+	list, err := Process(ListAttachmentsCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	if len(list) > 0 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+	}
+
+	// attach add 4 files
+	_, err = Process(AddAttachmentsCommand(fileName,
+		[]string{outDir + "/golang.pdf",
+			outDir + "/T4.pdf",
+			outDir + "/go-lecture.pdf",
+			outDir + "/test.wav"},
+		config))
+
+	if err != nil {
+		t.Fatalf("TestAttachments - add attachments to %s: %v\n", fileName, err)
+	}
+
+	// attach list must be 4
+	list, err = Process(ListAttachmentsCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	if len(list) != 4 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 4 attachments\n", fileName)
+	}
+	for _, s := range list {
+		t.Log(s)
+	}
+
+}
+
+func testAttachmentsStage2(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+
+	// attach extract all
+	_, err := Process(ExtractAttachmentsCommand(fileName, outDir, nil, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - extract all attachments from %s to %s: %v\n", fileName, ".", err)
+	}
+
+	// attach extract 1 file
+	_, err = Process(ExtractAttachmentsCommand(fileName, outDir, []string{"golang.pdf"}, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - extract 1 attachment from %s to %s: %v\n", fileName, ".", err)
+	}
+
+	// attach remove 1 file
+	_, err = Process(RemoveAttachmentsCommand(fileName, []string{"golang.pdf"}, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - remove attachment from %s: %v\n", fileName, err)
+	}
+
+	// attach list must be 3
+	list, err := Process(ListAttachmentsCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 3 attachments\n", fileName)
+	}
+
+	// attach remove all
+	_, err = Process(RemoveAttachmentsCommand(fileName, nil, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - remove all attachment from %s: %v\n", fileName, err)
+	}
+
+	// attach list must be 0.
+	list, err = Process(ListAttachmentsCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	t.Log(list)
+	if len(list) > 0 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+
+	err := prepareForAttachmentTest()
+	if err != nil {
+		t.Fatalf("prepare for attachments: %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	fileName := filepath.Join(outDir, "go.pdf")
+
+	testAttachmentsStage1(fileName, config, t)
+	testAttachmentsStage2(fileName, config, t)
+}
+
+func TestAttachmentInfo(t *testing.T) {
+
+	fileName := filepath.Join(outDir, "attachmentInfo.pdf")
+	if err := copyFile(filepath.Join(inDir, "empty.pdf"), fileName); err != nil {
+		t.Fatalf("TestAttachmentInfo: %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	goFile := filepath.Join(inDir, "go.pdf")
+
+	_, err := Process(AddAttachmentsCommand(fileName, []string{goFile + ", Slides", filepath.Join(inDir, "test.wav")}, config))
+	if err != nil {
+		t.Fatalf("TestAttachmentInfo - add: %v\n", err)
+	}
+
+	list, err := Process(ListAttachmentsCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("TestAttachmentInfo - list: %v\n", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("TestAttachmentInfo - list: %v\n", list)
+	}
+
+	b, err := ioutil.ReadFile(goFile)
+	if err != nil {
+		t.Fatalf("TestAttachmentInfo: %v\n", err)
+	}
+
+	want := fmt.Sprintf(`go.pdf "Slides" application/pdf %d bytes modified `, len(b))
+	if !strings.HasPrefix(list[0], want) || !strings.HasSuffix(list[0], fmt.Sprintf(" md5 %x", md5.Sum(b))) {
+		t.Fatalf("TestAttachmentInfo - list: %s, want %s...\n", list[0], want)
+	}
+
+	if !strings.HasPrefix(list[1], `test.wav "attached by`) || !strings.Contains(list[1], " audio/") {
+		t.Fatalf("TestAttachmentInfo - list: %s\n", list[1])
+	}
+
+	dir := filepath.Join(outDir, "attachmentInfo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("TestAttachmentInfo: %v\n", err)
+	}
+
+	if _, err := Process(ExtractAttachmentsCommand(fileName, dir, []string{"go.pdf"}, config)); err != nil {
+		t.Fatalf("TestAttachmentInfo - extract: %v\n", err)
+	}
+
+	b2, err := ioutil.ReadFile(filepath.Join(dir, "go.pdf"))
+	if err != nil {
+		t.Fatalf("TestAttachmentInfo - extract: %v\n", err)
+	}
+
+	if !bytes.Equal(b, b2) {
+		t.Fatal("TestAttachmentInfo - extract: content mismatch")
+	}
+}
+
+func TestAddPageAttachmentsCommand(t *testing.T) {
+
+	fileName := filepath.Join(outDir, "pageAttachments.pdf")
+	if err := copyFile(filepath.Join(inDir, "golang.pdf"), fileName); err != nil {
+		t.Fatalf("TestAddPageAttachmentsCommand: %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	n, err := pdfcpu.ParseNoteDetails("rect:500 750 520 770, icon:Paperclip", false)
+	if err != nil {
+		t.Fatalf("TestAddPageAttachmentsCommand: %v\n", err)
+	}
+
+	_, err = Process(AddPageAttachmentsCommand(fileName, filepath.Join(inDir, "test.wav"), []string{"1", "3"}, n, config))
+	if err != nil {
+		t.Fatalf("TestAddPageAttachmentsCommand: %v\n", err)
+	}
+
+	if got := annotationSubtypes(t, fileName)["FileAttachment"]; got != 2 {
+		t.Fatalf("TestAddPageAttachmentsCommand: got %d file attachments want 2\n", got)
+	}
+
+	// Page attachments do not show up as document level attachments.
+	list, err := Process(ListAttachmentsCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("TestAddPageAttachmentsCommand: %v\n", err)
+	}
+	if len(list) > 0 {
+		t.Fatalf("TestAddPageAttachmentsCommand: should have 0 attachments\n")
+	}
+
+	n.Icon = "Comment"
+	_, err = Process(AddPageAttachmentsCommand(fileName, filepath.Join(inDir, "test.wav"), nil, n, config))
+	if err == nil {
+		t.Fatal("TestAddPageAttachmentsCommand: should have failed for unsupported icon\n")
+	}
+}
+
+func TestListPermissionsCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	_, err := Process(ListPermissionsCommand(inFile, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestListPermissionsCommand: for unencrypted %s: %v\n", inFile, err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	_, err = Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	_, err = Process(ListPermissionsCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestListPermissionsCommand: for encrypted %s: %v\n", outFile, err)
+	}
+
+}
+
+func TestUnknownCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(outDir, "go.pdf")
+
+	cmd := &Command{
+		Mode:   99,
+		InFile: &inFile,
+		Config: config}
+
+	_, err := Process(cmd)
+	if err == nil {
+		t.Fatal("TestUnknowncommand - should have failed")
+	}
+
+}
+
+func xxxTestDemoXRef(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
+	if err != nil {
+		t.Fatalf("testDemoXRef %v\n", err)
+	}
+	if xRefTable == nil {
+		t.Fatal("testDemoXRef: xRefTable == nil")
+	}
+
+	err = pdfcpu.ValidateXRefTable(xRefTable)
+	if err != nil {
+		t.Fatalf("testDemoXRef %v\n", err)
+	}
+
+	// var logStr []string
+	// logStr = xRefTable.List(logStr)
+	// t.Logf("XRefTable:\n%s\n", strings.Join(logStr, ""))
+}
+
+func TestCreateDemoPDF(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("testCreateDemoPDF %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "demo.pdf")
+	if err != nil {
+		t.Fatalf("testCreateDemoPDF %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationRelaxed
+
+	outFile := filepath.Join(outDir, "demo.pdf")
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("testCreateDemoPDF %v\n", err)
+	}
+}
+
+func TestAnnotationDemoPDF(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
+	if err != nil {
+		t.Fatalf("testAnnotationDemoPDF %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "annotationDemo.pdf")
+	if err != nil {
+		t.Fatalf("testAnnotationDemoPDF %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationRelaxed
+
+	outFile := filepath.Join(outDir, "annotationDemo.pdf")
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("testAnnotationDemoPDF %v\n", err)
+	}
+
+}
+
+func TestAcroformDemoPDF(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("testAcroformDemoPDF %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "acroFormDemo.pdf")
+	if err != nil {
+		t.Fatalf("testAcroformDemoPDF %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationRelaxed
+
+	outFile := filepath.Join(outDir, "acroFormDemo.pdf")
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("testAcroformDemoPDF %v\n", err)
+	}
+
+}
+
+func TestListFormFieldsCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestListFormFieldsCommand %v\n", err)
+	}
+
+	fileName := "acroFormFields.pdf"
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", fileName)
+	if err != nil {
+		t.Fatalf("TestListFormFieldsCommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationRelaxed
+
+	inFile := filepath.Join(outDir, fileName)
+	out, err := Process(ListFormFieldsCommand(inFile, config))
+	if err != nil {
+		t.Fatalf("TestListFormFieldsCommand %v\n", err)
+	}
+
+	if len(out) != 1 || !strings.Contains(out[0], `"name": "inputField"`) {
+		t.Fatalf("TestListFormFieldsCommand: missing field inputField in:\n%v\n", out)
+	}
+
+	inFile = filepath.Join(inDir, "Acroforms2.pdf")
+	_, err = Process(ListFormFieldsCommand(inFile, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestListFormFieldsCommand %s: %v\n", inFile, err)
+	}
+}
+
+func TestFillFormCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "acroFormFill.pdf")
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	inFile := filepath.Join(outDir, "acroFormFill.pdf")
+	outFile := filepath.Join(outDir, "acroFormFilled.pdf")
+	jsonFile := filepath.Join(outDir, "acroFormFill.json")
+
+	data := `{"inputField": "Hello", "CheckBox": false, "Credit card": "card2"}`
+	err = ioutil.WriteFile(jsonFile, []byte(data), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.NeedAppearances = true
+	_, err = Process(FillFormCommand(inFile, jsonFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	out, err := Process(ListFormFieldsCommand(outFile, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	for _, s := range []string{`"value": "Hello"`, `"value": "Off"`, `"value": "card2"`} {
+		if !strings.Contains(out[0], s) {
+			t.Fatalf("TestFillFormCommand: missing %s in:\n%s\n", s, out[0])
+		}
+	}
+
+	// Filling with the output of form list must work too.
+	err = ioutil.WriteFile(jsonFile, []byte(out[0]), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	_, err = Process(FillFormCommand(outFile, jsonFile, outFile, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	// Invalid radio button option.
+	err = ioutil.WriteFile(jsonFile, []byte(`{"Credit card": "card3"}`), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestFillFormCommand %v\n", err)
+	}
+
+	_, err = Process(FillFormCommand(inFile, jsonFile, outFile, pdfcpu.NewDefaultConfiguration()))
+	if err == nil {
+		t.Fatal("TestFillFormCommand: should have failed for invalid radio button option")
+	}
+}
+
+func TestExportFormCommand(t *testing.T) {
+
+	inFile := filepath.Join(outDir, "acroFormFill.pdf")
+	filledFile := filepath.Join(outDir, "acroFormFilled.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	want, err := Process(ListFormFieldsCommand(filledFile, config))
+	if err != nil {
+		t.Fatalf("TestExportFormCommand %v\n", err)
+	}
+
+	for _, ext := range []string{".fdf", ".xfdf"} {
+
+		dataFile := filepath.Join(outDir, "acroFormFilled"+ext)
+		outFile := filepath.Join(outDir, "acroFormImported"+ext+".pdf")
+
+		_, err = Process(ExportFormCommand(filledFile, dataFile, config))
+		if err != nil {
+			t.Fatalf("TestExportFormCommand %s: %v\n", ext, err)
+		}
+
+		_, err = Process(FillFormCommand(inFile, dataFile, outFile, config))
+		if err != nil {
+			t.Fatalf("TestExportFormCommand %s: %v\n", ext, err)
+		}
+
+		got, err := Process(ListFormFieldsCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestExportFormCommand %s: %v\n", ext, err)
+		}
+
+		if got[0] != want[0] {
+			t.Fatalf("TestExportFormCommand %s: got:\n%s\nwant:\n%s\n", ext, got[0], want[0])
+		}
+	}
+
+	_, err = Process(ExportFormCommand(filledFile, filepath.Join(outDir, "acroFormFilled.txt"), config))
+	if err == nil {
+		t.Fatal("TestExportFormCommand: should have failed for unsupported export format")
+	}
+}
+
+func TestExportFormDataCommand(t *testing.T) {
+
+	inFile := filepath.Join(outDir, "acroFormFill.pdf")
+	filledFile := filepath.Join(outDir, "acroFormFilled.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	jsonFile := filepath.Join(outDir, "acroFormFilledData.json")
+	_, err := Process(ExportFormCommand(filledFile, jsonFile, config))
+	if err != nil {
+		t.Fatalf("TestExportFormDataCommand %v\n", err)
+	}
+
+	// The JSON export is valid input for form fill.
+	outFile := filepath.Join(outDir, "acroFormImportedJSON.pdf")
+	_, err = Process(FillFormCommand(inFile, jsonFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestExportFormDataCommand %v\n", err)
+	}
+
+	csvFile := filepath.Join(outDir, "acroFormFilledData.csv")
+	_, err = Process(ExportFormCommand(outFile, csvFile, config))
+	if err != nil {
+		t.Fatalf("TestExportFormDataCommand %v\n", err)
+	}
+
+	bb, err := ioutil.ReadFile(csvFile)
+	if err != nil {
+		t.Fatalf("TestExportFormDataCommand %v\n", err)
+	}
+
+	want := "inputField,CheckBox,Credit card\nHello,Off,card2\n"
+	if string(bb) != want {
+		t.Fatalf("TestExportFormDataCommand: got:\n%s\nwant:\n%s\n", bb, want)
+	}
+}
+
+func TestMultiFillFormCommand(t *testing.T) {
+
+	inFile := filepath.Join(outDir, "acroFormFill.pdf")
+
+	csvFile := filepath.Join(outDir, "acroFormRecords.csv")
+	err := ioutil.WriteFile(csvFile, []byte("name,text,check,card\nJoe,Hello,true,card1\nAnn,World,Off,card2\n"), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestMultiFillFormCommand %v\n", err)
+	}
+
+	mfc := &pdfcpu.MultiFillConfig{NameColumn: "name"}
+	mfc.FieldMap, err = pdfcpu.ParseFieldMap("inputField:text, CheckBox:check, Credit card:card")
+	if err != nil {
+		t.Fatalf("TestMultiFillFormCommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	// Write one file per record.
+	_, err = Process(MultiFillFormCommand(inFile, csvFile, outDir, "", mfc, config))
+	if err != nil {
+		t.Fatalf("TestMultiFillFormCommand %v\n", err)
+	}
+
+	for name, want := range map[string]string{
+		"Joe": "inputField,CheckBox,Credit card\nHello,Yes,card1\n",
+		"Ann": "inputField,CheckBox,Credit card\nWorld,Off,card2\n",
+	} {
+		dataFile := filepath.Join(outDir, name+".csv")
+		_, err = Process(ExportFormCommand(filepath.Join(outDir, name+".pdf"), dataFile, config))
+		if err != nil {
+			t.Fatalf("TestMultiFillFormCommand %v\n", err)
+		}
+
+		bb, err := ioutil.ReadFile(dataFile)
+		if err != nil {
+			t.Fatalf("TestMultiFillFormCommand %v\n", err)
+		}
+
+		if string(bb) != want {
+			t.Fatalf("TestMultiFillFormCommand: got:\n%s\nwant:\n%s\n", bb, want)
+		}
+	}
+
+	// Merge all filled copies into one file.
+	mfc.Merge = true
+	outFile := filepath.Join(outDir, "acroFormMerged.pdf")
+	_, err = Process(MultiFillFormCommand(inFile, csvFile, "", outFile, mfc, config))
+	if err != nil {
+		t.Fatalf("TestMultiFillFormCommand %v\n", err)
+	}
+
+	dataFile := filepath.Join(outDir, "acroFormMerged.csv")
+	_, err = Process(ExportFormCommand(outFile, dataFile, config))
+	if err != nil {
+		t.Fatalf("TestMultiFillFormCommand %v\n", err)
+	}
+
+	bb, err := ioutil.ReadFile(dataFile)
+	if err != nil {
+		t.Fatalf("TestMultiFillFormCommand %v\n", err)
+	}
+
+	want := "Joe.inputField,Joe.CheckBox,Joe.Credit card,Ann.inputField,Ann.CheckBox,Ann.Credit card\nHello,Yes,card1,World,Off,card2\n"
+	if string(bb) != want {
+		t.Fatalf("TestMultiFillFormCommand: got:\n%s\nwant:\n%s\n", bb, want)
+	}
+
+	// Unmapped columns must exist.
+	mfc.FieldMap["inputField"] = "missing"
+	_, err = Process(MultiFillFormCommand(inFile, csvFile, "", outFile, mfc, config))
+	if err == nil {
+		t.Fatal("TestMultiFillFormCommand: should have failed for unknown column")
+	}
+}
+
+func TestListAnnotationsCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "annotTest.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	out, err := Process(ListAnnotationsCommand(inFile, nil, config))
+	if err != nil {
+		t.Fatalf("TestListAnnotationsCommand %v\n", err)
+	}
+
+	var annots []pdfcpu.Annotation
+	err = json.Unmarshal([]byte(out[0]), &annots)
+	if err != nil {
+		t.Fatalf("TestListAnnotationsCommand %v\n", err)
+	}
+
+	if len(annots) == 0 {
+		t.Fatal("TestListAnnotationsCommand: no annotations found")
+	}
+
+	for _, a := range annots {
+		if a.Page == 0 || a.Subtype == "" || len(a.Rect) != 4 {
+			t.Fatalf("TestListAnnotationsCommand: incomplete annotation: %v\n", a)
+		}
+	}
+
+	// Restrict to the last page.
+	p := annots[len(annots)-1].Page
+	out, err = Process(ListAnnotationsCommand(inFile, []string{strconv.Itoa(p)}, config))
+	if err != nil {
+		t.Fatalf("TestListAnnotationsCommand %v\n", err)
+	}
+
+	var annotsPage []pdfcpu.Annotation
+	err = json.Unmarshal([]byte(out[0]), &annotsPage)
+	if err != nil {
+		t.Fatalf("TestListAnnotationsCommand %v\n", err)
+	}
+
+	if len(annotsPage) == 0 {
+		t.Fatal("TestListAnnotationsCommand: no annotations found on last page")
+	}
+
+	for _, a := range annotsPage {
+		if a.Page != p {
+			t.Fatalf("TestListAnnotationsCommand: got annotation for page %d, want page %d\n", a.Page, p)
+		}
+	}
+}
+
+func annotationSubtypes(t *testing.T, fileName string) map[string]int {
+
+	out, err := Process(ListAnnotationsCommand(fileName, nil, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("annotationSubtypes %v\n", err)
+	}
+
+	var annots []pdfcpu.Annotation
+	err = json.Unmarshal([]byte(out[0]), &annots)
+	if err != nil {
+		t.Fatalf("annotationSubtypes %v\n", err)
+	}
+
+	m := map[string]int{}
+	for _, a := range annots {
+		m[a.Subtype]++
+	}
+
+	return m
+}
+
+func TestRemoveAnnotationsCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "annotTest.pdf")
+	outFile := filepath.Join(outDir, "annotTestRemoved.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	before := annotationSubtypes(t, inFile)
+	if before["Text"] == 0 || before["Stamp"] == 0 {
+		t.Fatalf("TestRemoveAnnotationsCommand: unexpected annotations: %v\n", before)
+	}
+
+	_, err := Process(RemoveAnnotationsCommand(inFile, outFile, nil, []string{"Text", "Popup"}, nil, config))
+	if err != nil {
+		t.Fatalf("TestRemoveAnnotationsCommand %v\n", err)
+	}
+
+	after := annotationSubtypes(t, outFile)
+	if after["Text"] > 0 || after["Popup"] > 0 {
+		t.Fatalf("TestRemoveAnnotationsCommand: Text and Popup annotations left: %v\n", after)
+	}
+
+	if after["Stamp"] != before["Stamp"] {
+		t.Fatalf("TestRemoveAnnotationsCommand: got %d Stamp annotations, want %d\n", after["Stamp"], before["Stamp"])
+	}
+
+	// Remove everything else.
+	_, err = Process(RemoveAnnotationsCommand(outFile, outFile, nil, nil, nil, config))
+	if err != nil {
+		t.Fatalf("TestRemoveAnnotationsCommand %v\n", err)
+	}
+
+	if after = annotationSubtypes(t, outFile); len(after) > 0 {
+		t.Fatalf("TestRemoveAnnotationsCommand: annotations left: %v\n", after)
+	}
+
+	// Form field widgets cannot be removed.
+	inFile = filepath.Join(outDir, "acroFormFill.pdf")
+	_, err = Process(RemoveAnnotationsCommand(inFile, outFile, nil, []string{"Widget"}, nil, config))
+	if err == nil {
+		t.Fatal("TestRemoveAnnotationsCommand: should have failed for widget annotations")
+	}
+}
+
+func TestFlattenAnnotationsCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "annotTest.pdf")
+	outFile := filepath.Join(outDir, "annotTestFlattened.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	before := annotationSubtypes(t, inFile)
+
+	_, err := Process(FlattenAnnotationsCommand(inFile, outFile, nil, []string{"Stamp"}, nil, config))
+	if err != nil {
+		t.Fatalf("TestFlattenAnnotationsCommand %v\n", err)
+	}
+
+	after := annotationSubtypes(t, outFile)
+	if after["Stamp"] > 0 {
+		t.Fatalf("TestFlattenAnnotationsCommand: Stamp annotations left: %v\n", after)
+	}
+
+	if after["FreeText"] != before["FreeText"] {
+		t.Fatalf("TestFlattenAnnotationsCommand: got %d FreeText annotations, want %d\n", after["FreeText"], before["FreeText"])
+	}
+
+	// Flatten everything else.
+	_, err = Process(FlattenAnnotationsCommand(outFile, outFile, nil, nil, nil, config))
+	if err != nil {
+		t.Fatalf("TestFlattenAnnotationsCommand %v\n", err)
+	}
+
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestFlattenAnnotationsCommand %v\n", err)
+	}
+}
+
+func TestImportExportAnnotationsCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "CenterOfWhy.pdf")
+	outFile := filepath.Join(outDir, "annotImported.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	xfdf := `<?xml version="1.0" encoding="UTF-8"?>
+<xfdf xmlns="http://ns.adobe.com/xfdf/">
+	<annots>
+		<highlight page="0" rect="100,700,300,720" name="h1" title="Joe" color="#FFFF00" coords="100,720,300,720,100,700,300,700" date="D:20180101120000Z">
+			<contents>Check this</contents>
+			<popup page="0" rect="320,620,520,720" open="yes"/>
+		</highlight>
+		<ink page="0" rect="100,500,200,600" name="i1" color="#FF0000" width="2">
+			<inklist>
+				<gesture>100,500;150,600;200,500</gesture>
+			</inklist>
+		</ink>
+		<text page="0" rect="50,50,70,70" name="t1" icon="Comment" flags="print,nozoom,norotate">
+			<contents>Note</contents>
+		</text>
+	</annots>
+</xfdf>`
+
+	xfdfFile := filepath.Join(outDir, "annots.xfdf")
+	err := ioutil.WriteFile(xfdfFile, []byte(xfdf), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestImportExportAnnotationsCommand %v\n", err)
+	}
+
+	_, err = Process(ImportAnnotationsCommand(inFile, xfdfFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestImportExportAnnotationsCommand %v\n", err)
+	}
+
+	// Importing again replaces annotations with the same name.
+	_, err = Process(ImportAnnotationsCommand(outFile, xfdfFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestImportExportAnnotationsCommand %v\n", err)
+	}
+
+	got := annotationSubtypes(t, outFile)
+	want := map[string]int{"Highlight": 1, "Popup": 1, "Ink": 1, "Text": 1}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("TestImportExportAnnotationsCommand: got %v want %v\n", got, want)
+		}
+	}
+
+	exportFile := filepath.Join(outDir, "annotsExported.xfdf")
+	_, err = Process(ExportAnnotationsCommand(outFile, exportFile, config))
+	if err != nil {
+		t.Fatalf("TestImportExportAnnotationsCommand %v\n", err)
+	}
+
+	bb, err := ioutil.ReadFile(exportFile)
+	if err != nil {
+		t.Fatalf("TestImportExportAnnotationsCommand %v\n", err)
+	}
+
+	for _, s := range []string{
+		`<highlight page="0" rect="100,700,300,720" name="h1" title="Joe" date="D:20180101120000Z" color="#FFFF00" coords="100,720,300,720,100,700,300,700">`,
+		`<contents>Check this</contents>`,
+		`<popup page="0" rect="320,620,520,720" open="yes"></popup>`,
+		`<gesture>100,500;150,600;200,500</gesture>`,
+		`<text page="0" rect="50,50,70,70" name="t1" flags="print,nozoom,norotate" icon="Comment">`,
+	} {
+		if !strings.Contains(string(bb), s) {
+			t.Fatalf("TestImportExportAnnotationsCommand: missing %s in:\n%s\n", s, bb)
+		}
+	}
+
+	// Imported text markup and ink annotations come with appearances.
+	_, err = Process(FlattenAnnotationsCommand(outFile, outFile, nil, []string{"Highlight", "Ink"}, nil, config))
+	if err != nil {
+		t.Fatalf("TestImportExportAnnotationsCommand %v\n", err)
+	}
+
+	if got = annotationSubtypes(t, outFile); got["Highlight"] > 0 || got["Ink"] > 0 || got["Popup"] > 0 {
+		t.Fatalf("TestImportExportAnnotationsCommand: got %v after flattening\n", got)
+	}
+}
+
+func TestAddLinksCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "FOSDEM14_HPC_devroom_14_GoCUDA.pdf")
+	outFile := filepath.Join(outDir, "links.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	before := annotationSubtypes(t, inFile)["Link"]
+
+	// Turn the URLs of the page text into links.
+	_, err := Process(AddLinksCommand(inFile, outFile, nil, nil, config))
+	if err != nil {
+		t.Fatalf("TestAddLinksCommand %v\n", err)
+	}
+
+	urlLinks := annotationSubtypes(t, outFile)["Link"]
+	if urlLinks <= before {
+		t.Fatalf("TestAddLinksCommand: no URL links added\n")
+	}
+
+	// URLs already covered by a link are skipped.
+	_, err = Process(AddLinksCommand(outFile, outFile, nil, nil, config))
+	if err != nil {
+		t.Fatalf("TestAddLinksCommand %v\n", err)
+	}
+
+	if got := annotationSubtypes(t, outFile)["Link"]; got != urlLinks {
+		t.Fatalf("TestAddLinksCommand: got %d links want %d\n", got, urlLinks)
+	}
+
+	for _, s := range []string{
+		"https://github.com/hhrutter/pdfcpu, rect:50 50 250 70",
+		"2, rect:50 80 250 100, zoom:150",
+	} {
+		link, err := pdfcpu.ParseLinkDetails(s)
+		if err != nil {
+			t.Fatalf("TestAddLinksCommand %v\n", err)
+		}
+
+		_, err = Process(AddLinksCommand(outFile, outFile, []string{"1"}, link, config))
+		if err != nil {
+			t.Fatalf("TestAddLinksCommand %v\n", err)
+		}
+	}
+
+	if got := annotationSubtypes(t, outFile)["Link"]; got != urlLinks+2 {
+		t.Fatalf("TestAddLinksCommand: got %d links want %d\n", got, urlLinks+2)
+	}
+
+	for _, s := range []string{
+		"http://example.com",
+		"http://example.com, rect:50 50 250 70, zoom:150",
+		"0, rect:50 50 250 70",
+		"2, rect:250 50 50 70",
+	} {
+		if _, err := pdfcpu.ParseLinkDetails(s); err == nil {
+			t.Fatalf("TestAddLinksCommand: ParseLinkDetails should have failed for %s\n", s)
+		}
+	}
+
+	link, _ := pdfcpu.ParseLinkDetails("999, rect:50 50 250 70")
+	_, err = Process(AddLinksCommand(outFile, outFile, nil, link, config))
+	if err == nil {
+		t.Fatal("TestAddLinksCommand: should have failed for invalid destination page\n")
+	}
+}
+
+func TestHighlightTextCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "golang.pdf")
+	outFile := filepath.Join(outDir, "highlighted.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	_, err := Process(HighlightTextCommand(inFile, outFile, []string{"1-3"}, "golang", nil, config))
+	if err != nil {
+		t.Fatalf("TestHighlightTextCommand %v\n", err)
+	}
+
+	// "golang" occurs in six URLs on page 3.
+	if n := annotationSubtypes(t, outFile)["Highlight"]; n != 6 {
+		t.Fatalf("TestHighlightTextCommand: got %d highlights want 6\n", n)
+	}
+
+	tmc := &pdfcpu.TextMarkupConfig{Subtype: "StrikeOut", Color: "#0000FF", MatchCase: true}
+	_, err = Process(HighlightTextCommand(outFile, outFile, []string{"1-3"}, "GOLANG", tmc, config))
+	if err != nil {
+		t.Fatalf("TestHighlightTextCommand %v\n", err)
+	}
+
+	if got := annotationSubtypes(t, outFile)["StrikeOut"]; got != 0 {
+		t.Fatalf("TestHighlightTextCommand: got %d strikeouts want 0\n", got)
+	}
+
+	tmc.Subtype = "Link"
+	_, err = Process(HighlightTextCommand(outFile, outFile, nil, "golang", tmc, config))
+	if err == nil {
+		t.Fatal("TestHighlightTextCommand: should have failed for unsupported subtype\n")
+	}
+}
+
+func TestAddNotesCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "golang.pdf")
+	outFile := filepath.Join(outDir, "notes.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	n, err := pdfcpu.ParseNoteDetails("rect:50 700 70 720, author:QA, icon:Comment", false)
+	if err != nil {
+		t.Fatalf("TestAddNotesCommand %v\n", err)
+	}
+	n.Contents = "Check this"
+	n.Color = "#FF0000"
+
+	_, err = Process(AddNotesCommand(inFile, outFile, []string{"1-2"}, n, config))
+	if err != nil {
+		t.Fatalf("TestAddNotesCommand %v\n", err)
+	}
+
+	n, err = pdfcpu.ParseNoteDetails("rect:50 50 250 100, size:10", true)
+	if err != nil {
+		t.Fatalf("TestAddNotesCommand %v\n", err)
+	}
+	n.Contents = "Checked by an automated test\nwith a rather long line of text that needs wrapping."
+
+	_, err = Process(AddFreeTextsCommand(outFile, outFile, []string{"1"}, n, config))
+	if err != nil {
+		t.Fatalf("TestAddNotesCommand %v\n", err)
+	}
+
+	m := annotationSubtypes(t, outFile)
+	if m["Text"] != 2 || m["FreeText"] != 1 {
+		t.Fatalf("TestAddNotesCommand: got %d notes, %d free texts want 2, 1\n", m["Text"], m["FreeText"])
+	}
+
+	if _, err = pdfcpu.ParseNoteDetails("author:QA", false); err == nil {
+		t.Fatal("TestAddNotesCommand: should have failed for missing rect\n")
+	}
+
+	if _, err = pdfcpu.ParseNoteDetails("rect:50 700 70 720, size:10", false); err == nil {
+		t.Fatal("TestAddNotesCommand: should have failed for size on sticky note\n")
+	}
+
+	n.Icon = "Unknown"
+	_, err = Process(AddNotesCommand(outFile, outFile, nil, n, config))
+	if err == nil {
+		t.Fatal("TestAddNotesCommand: should have failed for unsupported icon\n")
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "golang.pdf")
+	outFile := filepath.Join(outDir, "redacted.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	r, err := pdfcpu.ParseRedactRect("rect:50 50 200 200")
+	if err != nil {
+		t.Fatalf("TestRedactCommand %v\n", err)
+	}
+
+	rc := &pdfcpu.RedactConfig{Rects: []types.Rectangle{r}, Terms: []string{"golang"}, Color: "#808080"}
+
+	_, err = Process(RedactCommand(inFile, outFile, []string{"1-3"}, rc, config))
+	if err != nil {
+		t.Fatalf("TestRedactCommand %v\n", err)
+	}
+
+	// The redacted text must be gone while the remaining text is still there.
+	_, err = Process(HighlightTextCommand(outFile, outFile, []string{"1-3"}, "golang", nil, config))
+	if err != nil {
+		t.Fatalf("TestRedactCommand %v\n", err)
+	}
+
+	if n := annotationSubtypes(t, outFile)["Highlight"]; n != 0 {
+		t.Fatalf("TestRedactCommand: got %d occurrences of redacted text want 0\n", n)
+	}
+
+	_, err = Process(HighlightTextCommand(outFile, outFile, []string{"3"}, "language", nil, config))
+	if err != nil {
+		t.Fatalf("TestRedactCommand %v\n", err)
+	}
+
+	if n := annotationSubtypes(t, outFile)["Highlight"]; n == 0 {
+		t.Fatal("TestRedactCommand: unredacted text missing\n")
+	}
+
+	if _, err = pdfcpu.ParseRedactRect("rect:50 50 20 20"); err == nil {
+		t.Fatal("TestRedactCommand: should have failed for invalid rect\n")
+	}
+}
+
+func TestRedactFormFieldCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+	}
+
+	if err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "acroFormRedact.pdf"); err != nil {
+		t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+	}
+
+	inFile := filepath.Join(outDir, "acroFormRedact.pdf")
+	outFile := filepath.Join(outDir, "acroFormRedacted.pdf")
+	jsonFile := filepath.Join(outDir, "acroFormRedact.json")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	if err = ioutil.WriteFile(jsonFile, []byte(`{"inputField": "Secret"}`), os.ModePerm); err != nil {
+		t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+	}
+
+	if _, err = Process(FillFormCommand(inFile, jsonFile, inFile, config)); err != nil {
+		t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+	}
+
+	fields := func(fileName string) map[string]pdfcpu.FormField {
+		out, err := Process(ListFormFieldsCommand(fileName, config))
+		if err != nil {
+			t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+		}
+		var ff []pdfcpu.FormField
+		if err = json.Unmarshal([]byte(out[0]), &ff); err != nil {
+			t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+		}
+		m := map[string]pdfcpu.FormField{}
+		for _, f := range ff {
+			m[f.Name] = f
+		}
+		return m
+	}
+
+	before := fields(inFile)
+
+	f, ok := before["inputField"]
+	if !ok || f.Value != "Secret" || len(f.Rect) != 4 {
+		t.Fatalf("TestRedactFormFieldCommand: unexpected inputField: %v\n", f)
+	}
+
+	// Redact a part of the widget.
+	r := types.NewRectangle(f.Rect[0]+1, f.Rect[1]+1, f.Rect[0]+5, f.Rect[1]+5)
+	rc := &pdfcpu.RedactConfig{Rects: []types.Rectangle{r}}
+
+	if _, err = Process(RedactCommand(inFile, outFile, []string{strconv.Itoa(f.Page)}, rc, config)); err != nil {
+		t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+	}
+
+	after := fields(outFile)
+
+	if _, ok := after["inputField"]; ok {
+		t.Fatal("TestRedactFormFieldCommand: redacted field inputField still there\n")
+	}
+
+	if len(after) != len(before)-1 {
+		t.Fatalf("TestRedactFormFieldCommand: got %d fields want %d\n", len(after), len(before)-1)
+	}
+
+	// Neither the value nor its appearance may be left in the file.
+	config.DecodeAllStreams = true
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestRedactFormFieldCommand %v\n", err)
+	}
+
+	for objNr, entry := range ctx.Table {
+		if entry.Free || entry.Object == nil {
+			continue
+		}
+		if sd, ok := entry.Object.(pdfcpu.PDFStreamDict); ok && bytes.Contains(sd.Content, []byte("Secret")) {
+			t.Errorf("TestRedactFormFieldCommand: redacted value in stream obj#%d\n", objNr)
+		}
+		if strings.Contains(fmt.Sprint(entry.Object), "Secret") {
+			t.Errorf("TestRedactFormFieldCommand: redacted value in obj#%d\n", objNr)
+		}
+	}
+}
+
+func TestJavaScriptCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
+	if err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	// The annotation demo opens with a script followed by a movie action.
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "jsDemo.pdf")
+	if err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(outDir, "jsDemo.pdf")
+	outFile := filepath.Join(outDir, "jsDemoOut.pdf")
+
+	out, err := Process(ListJavaScriptCommand(inFile, config))
+	if err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	var scripts []pdfcpu.JavaScript
+	if err = json.Unmarshal([]byte(out[0]), &scripts); err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	if len(scripts) != 1 || scripts[0].Trigger != "OpenAction" {
+		t.Fatalf("TestJavaScriptCommand: got %v want the open action script\n", scripts)
+	}
+
+	_, err = Process(RemoveJavaScriptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	scripts, err = pdfcpu.ListJavaScript(ctx.XRefTable)
+	if err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	if len(scripts) != 0 {
+		t.Fatalf("TestJavaScriptCommand: got %d scripts want 0\n", len(scripts))
+	}
+
+	// The chained movie action is retained.
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		t.Fatalf("TestJavaScriptCommand %v\n", err)
+	}
+
+	d, err := ctx.DereferenceDict(rootDict.Dict["OpenAction"])
+	if err != nil || d == nil || d.NameEntry("S") == nil || *d.NameEntry("S") != "Movie" {
+		t.Fatalf("TestJavaScriptCommand: open action movie missing: %v\n", err)
+	}
+}
+
+func TestFieldAppearancesCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "acroFormAppearances.pdf")
+	if err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	inFile := filepath.Join(outDir, "acroFormAppearances.pdf")
+	outFile := filepath.Join(outDir, "acroFormAppearancesOut.pdf")
+	jsonFile := filepath.Join(outDir, "acroFormAppearances.json")
+
+	err = ioutil.WriteFile(jsonFile, []byte(`{"inputField": "Hello", "Credit card": "card2"}`), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.NeedAppearances = true
+	_, err = Process(FillFormCommand(inFile, jsonFile, inFile, config))
+	if err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	_, err = Process(FieldAppearancesCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		t.Fatalf("TestFieldAppearancesCommand %v\n", err)
+	}
+
+	acroForm, err := ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+	if err != nil || acroForm == nil {
+		t.Fatalf("TestFieldAppearancesCommand: missing form %v\n", err)
+	}
+
+	if _, found := acroForm.Find("NeedAppearances"); found {
+		t.Fatal("TestFieldAppearancesCommand: NeedAppearances not removed\n")
+	}
+
+	// Generating appearances for a document without form fails.
+	_, err = Process(FieldAppearancesCommand(filepath.Join(inDir, "Acroforms2.pdf"), outFile, config))
+	if err == nil {
+		t.Fatal("TestFieldAppearancesCommand: should have failed for missing form\n")
+	}
+}
+
+func TestFormOrderCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestFormOrderCommand %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "acroFormOrder.pdf")
+	if err != nil {
+		t.Fatalf("TestFormOrderCommand %v\n", err)
+	}
+
+	inFile := filepath.Join(outDir, "acroFormOrder.pdf")
+	outFile := filepath.Join(outDir, "acroFormOrderOut.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	_, err = Process(SetTabOrderCommand(inFile, outFile, nil, "R", []string{"Submit", "CheckBox"}, config))
+	if err != nil {
+		t.Fatalf("TestFormOrderCommand %v\n", err)
+	}
+
+	_, err = Process(SetCalculationOrderCommand(outFile, outFile, []string{"CheckBox", "inputField"}, config))
+	if err != nil {
+		t.Fatalf("TestFormOrderCommand %v\n", err)
+	}
+
+	ss, err := Process(ListFormOrderCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestFormOrderCommand %v\n", err)
+	}
+
+	var fo pdfcpu.FormOrder
+	if err = json.Unmarshal([]byte(ss[0]), &fo); err != nil {
+		t.Fatalf("TestFormOrderCommand %v\n", err)
+	}
+
+	if len(fo.Pages) != 1 || fo.Pages[0].Tabs != "R" || len(fo.Pages[0].Fields) < 2 ||
+		fo.Pages[0].Fields[0] != "Submit" || fo.Pages[0].Fields[1] != "CheckBox" {
+		t.Fatalf("TestFormOrderCommand: unexpected tab order: %v\n", fo.Pages)
+	}
+
+	if len(fo.CalculationOrder) != 2 || fo.CalculationOrder[0] != "CheckBox" || fo.CalculationOrder[1] != "inputField" {
+		t.Fatalf("TestFormOrderCommand: unexpected calculation order: %v\n", fo.CalculationOrder)
+	}
+
+	// Unknown fields are rejected.
+	_, err = Process(SetCalculationOrderCommand(outFile, outFile, []string{"unknown"}, config))
+	if err == nil {
+		t.Fatal("TestFormOrderCommand: should have failed for unknown field\n")
+	}
+}
+
+func TestAddSignatureFieldCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "signatureField.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	for i, s := range []string{
+		"name:sig, page:2, rect:300 50 500 110, label:Sign here",
+		"name:approval",
+	} {
+
+		fa, label, err := pdfcpu.ParseSignatureFieldDetails(s)
+		if err != nil {
+			t.Fatalf("TestAddSignatureFieldCommand %v\n", err)
+		}
+
+		if i > 0 {
+			inFile = outFile
+		}
+
+		_, err = Process(AddSignatureFieldCommand(inFile, outFile, fa, label, config))
+		if err != nil {
+			t.Fatalf("TestAddSignatureFieldCommand %v\n", err)
+		}
+	}
+
+	ss, err := Process(ListFormFieldsCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestAddSignatureFieldCommand %v\n", err)
+	}
+
+	var fields []pdfcpu.FormField
+	if err = json.Unmarshal([]byte(ss[0]), &fields); err != nil {
+		t.Fatalf("TestAddSignatureFieldCommand %v\n", err)
+	}
+
+	if len(fields) != 2 || fields[0].Type != "signature" || fields[0].Page != 2 || fields[1].Name != "approval" {
+		t.Fatalf("TestAddSignatureFieldCommand: unexpected fields: %v\n", fields)
+	}
+
+	// Field names are unique.
+	fa := &pdfcpu.FieldAttributes{Name: "sig", Page: 1}
+	if _, err = Process(AddSignatureFieldCommand(outFile, outFile, fa, "", config)); err == nil {
+		t.Fatal("TestAddSignatureFieldCommand: should have failed for duplicate field name\n")
+	}
+}
+
+func TestChoiceOptionsCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	fa := pdfcpu.FieldAttributes{Name: "language", Page: 1, Rect: types.NewRectangle(50, 300, 200, 320)}
+	err = pdfcpu.AddComboBox(xRefTable, fa, []string{"English", "German", "French"}, "German", false)
+	if err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "choiceOptions.pdf")
+	if err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	fileName := filepath.Join(outDir, "choiceOptions.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	opts := []pdfcpu.ChoiceOption{pdfcpu.ParseChoiceOption("es=Spanish")}
+	if _, err = Process(AddChoiceOptionsCommand(fileName, fileName, "language", opts, config)); err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	opts = []pdfcpu.ChoiceOption{{Value: "English"}}
+	if _, err = Process(RemoveChoiceOptionsCommand(fileName, fileName, "language", opts, config)); err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	opts = []pdfcpu.ChoiceOption{{Value: "es", Text: "Spanish"}, {Value: "French"}, {Value: "German"}}
+	if _, err = Process(SetChoiceOptionsCommand(fileName, fileName, "language", opts, config)); err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	ss, err := Process(ListChoiceOptionsCommand(fileName, "language", config))
+	if err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	var got []pdfcpu.ChoiceOption
+	if err = json.Unmarshal([]byte(ss[0]), &got); err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	if !reflect.DeepEqual(got, opts) {
+		t.Fatalf("TestChoiceOptionsCommand: got %v want %v\n", got, opts)
+	}
+
+	// Removing the selected option deselects it.
+	opts = []pdfcpu.ChoiceOption{{Value: "German"}}
+	if _, err = Process(RemoveChoiceOptionsCommand(fileName, fileName, "language", opts, config)); err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	ctx, err := Read(fileName, config)
+	if err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	fields, err := pdfcpu.ListFormFields(ctx.XRefTable)
+	if err != nil {
+		t.Fatalf("TestChoiceOptionsCommand %v\n", err)
+	}
+
+	if len(fields) != 1 || fields[0].Value != "" {
+		t.Fatalf("TestChoiceOptionsCommand: unexpected fields: %v\n", fields)
+	}
+
+	// Options are unique.
+	opts = []pdfcpu.ChoiceOption{{Value: "French"}}
+	if _, err = Process(AddChoiceOptionsCommand(fileName, fileName, "language", opts, config)); err == nil {
+		t.Fatal("TestChoiceOptionsCommand: should have failed for duplicate option\n")
+	}
+}
+
+func TestResetFormCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "acroFormReset.pdf")
+	if err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	inFile := filepath.Join(outDir, "acroFormReset.pdf")
+	outFile := filepath.Join(outDir, "acroFormResetOut.pdf")
+	jsonFile := filepath.Join(outDir, "acroFormReset.json")
+
+	err = ioutil.WriteFile(jsonFile, []byte(`{"inputField": "Hello", "Credit card": "card2"}`), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	if _, err = Process(FillFormCommand(inFile, jsonFile, outFile, config)); err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	if _, err = Process(ResetFormCommand(outFile, outFile, nil, config)); err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	fields, err := pdfcpu.ListFormFields(ctx.XRefTable)
+	if err != nil {
+		t.Fatalf("TestResetFormCommand %v\n", err)
+	}
+
+	for _, f := range fields {
+		if f.Type != "pushbutton" && f.Type != "signature" && f.Value != f.Default && !(f.Value == "Off" && f.Default == "") {
+			t.Errorf("TestResetFormCommand: field %s: got %q want default value %q\n", f.Name, f.Value, f.Default)
+		}
+	}
+
+	// Unknown fields are rejected.
+	if _, err = Process(ResetFormCommand(outFile, outFile, []string{"unknown"}, config)); err == nil {
+		t.Fatal("TestResetFormCommand: should have failed for unknown field\n")
+	}
+}
+
+func TestSanitizeCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
+	if err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "sanitize.pdf")
+	if err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(outDir, "sanitize.pdf")
+	outFile := filepath.Join(outDir, "sanitizeOut.pdf")
+
+	// The demo already embeds one file.
+	_, err = Process(AddAttachmentsCommand(inFile, []string{filepath.Join(inDir, "test.wav")}, config))
+	if err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	report, err := Process(SanitizeCommand(inFile, outFile, true, config))
+	if err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	for _, want := range []string{"removed 1 JavaScript action", "removed 2 embedded files", "removed 1 Launch action", "removed 1 URI action"} {
+		found := false
+		for _, s := range report {
+			if s == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("TestSanitizeCommand: %q missing from report %v\n", want, report)
+		}
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	scripts, err := pdfcpu.ListJavaScript(ctx.XRefTable)
+	if err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	if len(scripts) != 0 {
+		t.Fatalf("TestSanitizeCommand: got %d scripts want 0\n", len(scripts))
+	}
+
+	list, err := Process(ListAttachmentsCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestSanitizeCommand %v\n", err)
+	}
+
+	if len(list) != 0 {
+		t.Fatalf("TestSanitizeCommand: got %v want no attachments\n", list)
+	}
+}
+
+func TestRevisionsCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "revisions.pdf")
+	revFile := filepath.Join(outDir, "revision.pdf")
+
+	cert, key := selfSignedCertificate("alice", 1, t)
+	signer := pdfcpu.NewSigner(key, []*x509.Certificate{cert})
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	orig, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		t.Fatalf("TestRevisionsCommand: %v\n", err)
+	}
+
+	// Two signatures make two incremental updates.
+	for i, s := range []string{"page:1, rect:50 50 250 110", "page:1, rect:300 50 500 110"} {
+
+		sa, err := pdfcpu.ParseSignatureAttributes(s)
+		if err != nil {
+			t.Fatalf("TestRevisionsCommand - parse %s: %v\n", s, err)
+		}
+
+		fileIn := outFile
+		if i == 0 {
+			fileIn = inFile
+		}
+
+		if _, err = Process(SignCommand(fileIn, outFile, sa, signer, config)); err != nil {
+			t.Fatalf("TestRevisionsCommand - sign %s: %v\n", s, err)
+		}
+	}
+
+	revs, err := ListRevisions(outFile, config)
+	if err != nil {
+		t.Fatalf("TestRevisionsCommand - list: %v\n", err)
+	}
+
+	if len(revs) != 3 {
+		t.Fatalf("TestRevisionsCommand - list: got %d revisions want 3\n", len(revs))
+	}
+
+	for i, r := range revs {
+		if want := i > 0; (len(r.Signatures) == 1) != want || r.Number != i+1 {
+			t.Errorf("TestRevisionsCommand - list: unexpected revision\n%s\n", r)
+		}
+		if r.PageCount == 0 || i > 0 && r.Changed == 0 {
+			t.Errorf("TestRevisionsCommand - list: unexpected revision\n%s\n", r)
+		}
+	}
+
+	if _, err = Process(ExtractRevisionCommand(outFile, revFile, 1, config)); err != nil {
+		t.Fatalf("TestRevisionsCommand - extract: %v\n", err)
+	}
+
+	b, err := ioutil.ReadFile(revFile)
+	if err != nil {
+		t.Fatalf("TestRevisionsCommand: %v\n", err)
+	}
+
+	if !bytes.Equal(b, orig) {
+		t.Fatalf("TestRevisionsCommand - extract: revision 1 differs from the original document\n")
+	}
+
+	// The second revision carries the first signature only, which covers the whole extracted file.
+	if _, err = Process(ExtractRevisionCommand(outFile, revFile, 2, config)); err != nil {
+		t.Fatalf("TestRevisionsCommand - extract: %v\n", err)
+	}
+
+	srs, err := VerifySignatures(revFile, nil, config)
+	if err != nil {
+		t.Fatalf("TestRevisionsCommand - verify: %v\n", err)
+	}
+
+	if len(srs) != 1 || !srs[0].WholeFile || !srs[0].DigestValid {
+		t.Fatalf("TestRevisionsCommand - verify: %v\n", srs)
+	}
+
+	if _, err = Process(ExtractRevisionCommand(outFile, revFile, 4, config)); err == nil {
+		t.Fatalf("TestRevisionsCommand - extract: revision 4 should fail\n")
+	}
+}
+
+func TestRemoveUsageRightsCommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+	}
+
+	// Fake a Reader enabled form, once without and once with a certification signature.
+	for i, keys := range [][]string{{"UR3"}, {"UR3", "DocMDP"}} {
+
+		rootDict, err := xRefTable.Catalog()
+		if err != nil {
+			t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+		}
+
+		d := pdfcpu.NewPDFDict()
+		for _, k := range keys {
+			sigDict := pdfcpu.NewPDFDict()
+			sigDict.InsertName("Type", "Sig")
+			sigDict.InsertName("Filter", "Adobe.PPKLite")
+			indRef, err := xRefTable.IndRefForNewObject(sigDict)
+			if err != nil {
+				t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+			}
+			d.Insert(k, *indRef)
+		}
+		rootDict.Update("Perms", d)
+
+		fileName := fmt.Sprintf("usageRights%d.pdf", i)
+		if err = pdfcpu.CreatePDF(xRefTable, outDir+"/", fileName); err != nil {
+			t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+		}
+
+		config := pdfcpu.NewDefaultConfiguration()
+		inFile := filepath.Join(outDir, fileName)
+		outFile := filepath.Join(outDir, "usageRightsOut.pdf")
+
+		if _, err = Process(RemoveUsageRightsCommand(inFile, outFile, config)); err != nil {
+			t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+		}
+
+		ctx, err := Read(outFile, config)
+		if err != nil {
+			t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+		}
+
+		if rootDict, err = ctx.Catalog(); err != nil {
+			t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+		}
+
+		perms, err := ctx.DereferenceDict(rootDict.Dict["Perms"])
+		if err != nil {
+			t.Fatalf("TestRemoveUsageRightsCommand %v\n", err)
+		}
+
+		if len(keys) == 1 && perms != nil {
+			t.Fatalf("TestRemoveUsageRightsCommand: permissions dict should be gone: %s\n", perms)
+		}
+
+		if len(keys) == 2 && (perms == nil || perms.Len() != 1 || perms.Dict["DocMDP"] == nil) {
+			t.Fatalf("TestRemoveUsageRightsCommand: permissions dict should hold DocMDP only: %v\n", perms)
+		}
+	}
+}
+
+func TestValidatePDFACommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+
+	for _, level := range []string{pdfcpu.PDFA1B, pdfcpu.PDFA2B} {
+
+		out, err := Process(ValidatePDFACommand(inFile, level, config))
+		if err != nil {
+			t.Fatalf("TestValidatePDFACommand %v\n", err)
+		}
+
+		var rep pdfcpu.ComplianceReport
+		if err = json.Unmarshal([]byte(out[0]), &rep); err != nil {
+			t.Fatalf("TestValidatePDFACommand: invalid JSON report: %v\n", err)
+		}
+
+		if rep.Level != level || rep.Compliant {
+			t.Fatalf("TestValidatePDFACommand: %s: want non compliant report, got level=%s compliant=%t\n", level, rep.Level, rep.Compliant)
+		}
+
+		results := map[string]bool{}
+		for _, r := range rep.Rules {
+			if r.Passed != (len(r.Failures) == 0) {
+				t.Errorf("TestValidatePDFACommand: %s: rule %s passed=%t with failures %v\n", level, r.Clause, r.Passed, r.Failures)
+			}
+			results[r.Description] = r.Passed
+		}
+
+		// The fonts are not embedded, there is neither an output intent nor XMP metadata.
+		for desc, want := range map[string]bool{
+			"no encryption":      true,
+			"all fonts embedded": false,
+			"PDF/A output intent for device dependent colors":                 false,
+			"unfiltered XMP metadata identifying the PDF/A conformance level": false,
+		} {
+			if got, found := results[desc]; !found || got != want {
+				t.Errorf("TestValidatePDFACommand: %s: %q: got %t (found=%t), want %t\n", level, desc, got, found, want)
+			}
+		}
+
+		if _, found := results["no transparency"]; found != (level == pdfcpu.PDFA1B) {
+			t.Errorf("TestValidatePDFACommand: %s: transparency rule applies to PDF/A-1 only\n", level)
+		}
+	}
+
+	if _, err := Process(ValidatePDFACommand(inFile, "3u", config)); err == nil {
+		t.Fatalf("TestValidatePDFACommand: unsupported level should fail\n")
+	}
+}
+
+// testTrueTypeFont returns a minimal TrueType font program mapping U+0020 to U+00FF to glyphs of width 600.
+func testTrueTypeFont() []byte {
+
+	be := binary.BigEndian
+
+	head := make([]byte, 54)
+	be.PutUint16(head[18:], 1000) // unitsPerEm
+	for i, v := range []int16{-100, -200, 900, 800} {
+		be.PutUint16(head[36+2*i:], uint16(v))
+	}
+
+	hhea := make([]byte, 36)
+	be.PutUint16(hhea[4:], 800)
+	be.PutUint16(hhea[6:], uint16(0xFFFF-200+1)) // -200
+	be.PutUint16(hhea[34:], 2)
+
+	hmtx := []byte{0x01, 0xF4, 0, 0, 0x02, 0x58, 0, 0} // 500, 600
+
+	post := make([]byte, 32)
+	be.PutUint32(post, 0x00030000)
+
+	// cmap format 4: 0x20-0xFF => glyph 1, 0xFFFF => .notdef
+	sub := []uint16{4, 32, 0, 4, 4, 1, 0, 0xFF, 0xFFFF, 0, 0x20, 0xFFFF, 0x10000 + 1 - 0x20, 1, 0, 0}
+	cmap := []byte{0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 12}
+	for _, v := range sub {
+		cmap = append(cmap, byte(v>>8), byte(v))
+	}
+
+	tables := []struct {
+		tag string
+		b   []byte
+	}{{"cmap", cmap}, {"head", head}, {"hhea", hhea}, {"hmtx", hmtx}, {"post", post}}
+
+	b := make([]byte, 12+16*len(tables))
+	be.PutUint32(b, 0x00010000)
+	be.PutUint16(b[4:], uint16(len(tables)))
+
+	for i, t := range tables {
+		rec := b[12+16*i:]
+		copy(rec, t.tag)
+		be.PutUint32(rec[8:], uint32(len(b)))
+		be.PutUint32(rec[12:], uint32(len(t.b)))
+		b = append(b, t.b...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+	}
+
+	return b
+}
+
+func TestConvertToPDFACommand(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("TestConvertToPDFACommand %v\n", err)
+	}
+
+	if err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "pdfaIn.pdf"); err != nil {
+		t.Fatalf("TestConvertToPDFACommand %v\n", err)
+	}
+
+	fontDir := filepath.Join(outDir, "pdfaFonts")
+	if err = os.MkdirAll(fontDir, 0755); err != nil {
+		t.Fatalf("TestConvertToPDFACommand %v\n", err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(fontDir, "Helvetica.ttf"), testTrueTypeFont(), 0644); err != nil {
+		t.Fatalf("TestConvertToPDFACommand %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(outDir, "pdfaIn.pdf")
+
+	// The demo page does not show any text, put Helvetica to use.
+	wm, err := pdfcpu.ParseWatermarkDetails("Demo", true)
+	if err != nil {
+		t.Fatalf("TestConvertToPDFACommand %v\n", err)
+	}
+
+	if _, err = Process(AddWatermarksCommand(inFile, inFile, []string{"1-"}, wm, config)); err != nil {
+		t.Fatalf("TestConvertToPDFACommand %v\n", err)
+	}
+
+	for _, level := range []string{pdfcpu.PDFA1B, pdfcpu.PDFA2B} {
+
+		outFile := filepath.Join(outDir, "pdfa"+level+".pdf")
+
+		report, err := Process(ConvertToPDFACommand(inFile, outFile, level, fontDir, config))
+		if err != nil {
+			t.Fatalf("TestConvertToPDFACommand %v\n", err)
+		}
+
+		s := strings.Join(report, "\n")
+		for _, want := range []string{"embedded Helvetica.ttf", "added sRGB output intent", "synthesized PDF/A-" + level + " XMP metadata"} {
+			if !strings.Contains(s, want) {
+				t.Errorf("TestConvertToPDFACommand: %s: report misses %q:\n%s\n", level, want, s)
+			}
+		}
+
+		rep, err := ValidatePDFA(outFile, level, config)
+		if err != nil {
+			t.Fatalf("TestConvertToPDFACommand %v\n", err)
+		}
+
+		for _, r := range rep.Rules {
+			switch r.Description {
+			case "all fonts embedded", "PDF/A output intent for device dependent colors",
+				"unfiltered XMP metadata identifying the PDF/A conformance level", "permitted actions only":
+				if !r.Passed {
+					t.Errorf("TestConvertToPDFACommand: %s: %s: %v\n", level, r.Description, r.Failures)
+				}
+			}
+		}
+	}
+
+	// The font program embedded has to match the widths.
+	ctx, err := Read(filepath.Join(outDir, "pdfa2b.pdf"), config)
+	if err != nil {
+		t.Fatalf("TestConvertToPDFACommand %v\n", err)
+	}
+
+	for _, entry := range ctx.Table {
+		d, ok := entry.Object.(pdfcpu.PDFDict)
+		if !ok || d.Type() == nil || *d.Type() != "Font" {
+			continue
+		}
+		if st := d.Subtype(); st == nil || *st != "TrueType" {
+			t.Fatalf("TestConvertToPDFACommand: font not converted to TrueType: %v\n", d)
+		}
+		widths := d.PDFArrayEntry("Widths")
+		if widths == nil || len(*widths) != 224 || (*widths)[0] != pdfcpu.PDFInteger(600) {
+			t.Fatalf("TestConvertToPDFACommand: invalid widths: %v\n", widths)
+		}
+	}
+}
+
+// testCMYKProfile returns a minimal ICC output profile without tags for the CMYK color space.
+func testCMYKProfile() []byte {
+
+	b := make([]byte, 132)
+	binary.BigEndian.PutUint32(b, 132)
+	binary.BigEndian.PutUint32(b[8:], 0x02100000)
+	copy(b[12:], "prtr")
+	copy(b[16:], "CMYK")
+	copy(b[20:], "Lab ")
+	copy(b[36:], "acsp")
+
+	return b
+}
+
+func TestValidatePDFXCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+
+	failures := func(fileName, level string) map[string][]string {
+
+		out, err := Process(ValidatePDFXCommand(fileName, level, config))
+		if err != nil {
+			t.Fatalf("TestValidatePDFXCommand %v\n", err)
+		}
+
+		var rep pdfcpu.ComplianceReport
+		if err = json.Unmarshal([]byte(out[0]), &rep); err != nil {
+			t.Fatalf("TestValidatePDFXCommand: invalid JSON report: %v\n", err)
+		}
+
+		if rep.Standard != "PDF/X" || rep.Level != level || rep.Compliant {
+			t.Fatalf("TestValidatePDFXCommand: %s: want non compliant report, got %s-%s compliant=%t\n", level, rep.Standard, rep.Level, rep.Compliant)
+		}
+
+		m := map[string][]string{}
+		for _, r := range rep.Rules {
+			m[r.Description] = r.Failures
+		}
+
+		return m
+	}
+
+	const outputIntent = "PDF/X output intent with output condition and ICC output profile"
+
+	for _, level := range []string{pdfcpu.PDFX1A, pdfcpu.PDFX4} {
+
+		m := failures(inFile, level)
+
+		// Neither trim box nor output intent is present.
+		for _, desc := range []string{outputIntent, "TrimBox or ArtBox within BleedBox within MediaBox"} {
+			if len(m[desc]) == 0 {
+				t.Errorf("TestValidatePDFXCommand: %s: %q should fail\n", level, desc)
+			}
+		}
+
+		if _, found := m["CMYK, gray and spot colors only"]; found != (level == pdfcpu.PDFX1A) {
+			t.Errorf("TestValidatePDFXCommand: %s: color rule applies to PDF/X-1a only\n", level)
+		}
+	}
+
+	profileFile := filepath.Join(outDir, "cmyk.icc")
+	if err := ioutil.WriteFile(profileFile, testCMYKProfile(), os.ModePerm); err != nil {
+		t.Fatalf("TestValidatePDFXCommand %v\n", err)
+	}
+
+	outFile := filepath.Join(outDir, "pdfx.pdf")
+	if _, err := Process(AddOutputIntentCommand(inFile, profileFile, outFile, pdfcpu.OutputIntentPDFX, config)); err != nil {
+		t.Fatalf("TestValidatePDFXCommand %v\n", err)
+	}
+
+	// Adding the output intent twice replaces the first one.
+	if _, err := Process(AddOutputIntentCommand(outFile, profileFile, outFile, pdfcpu.OutputIntentPDFX, config)); err != nil {
+		t.Fatalf("TestValidatePDFXCommand %v\n", err)
+	}
+
+	if ss := failures(outFile, pdfcpu.PDFX4)[outputIntent]; len(ss) > 0 {
+		t.Errorf("TestValidatePDFXCommand: output intent: %v\n", ss)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestValidatePDFXCommand %v\n", err)
+	}
+
+	rootDict, _ := ctx.Catalog()
+	if arr, _ := ctx.DereferenceArray(rootDict.Dict["OutputIntents"]); arr == nil || len(*arr) != 1 {
+		t.Errorf("TestValidatePDFXCommand: want 1 output intent, got %v\n", arr)
+	}
+
+	if _, err := Process(AddOutputIntentCommand(inFile, inFile, outFile, pdfcpu.OutputIntentPDFX, config)); err == nil {
+		t.Fatalf("TestValidatePDFXCommand: invalid ICC profile should fail\n")
+	}
+}
+
+func TestOutputIntentCommands(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "intents.pdf")
+
+	cmyk := testCMYKProfile()
+	cmykFile := filepath.Join(outDir, "cmyk.icc")
+	if err := ioutil.WriteFile(cmykFile, cmyk, os.ModePerm); err != nil {
+		t.Fatalf("TestOutputIntentCommands %v\n", err)
+	}
+
+	gray := testCMYKProfile()
+	copy(gray[16:], "GRAY")
+	grayFile := filepath.Join(outDir, "gray.icc")
+	if err := ioutil.WriteFile(grayFile, gray, os.ModePerm); err != nil {
+		t.Fatalf("TestOutputIntentCommands %v\n", err)
+	}
+
+	list := func() []string {
+		out, err := Process(ListOutputIntentsCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestOutputIntentCommands - list: %v\n", err)
+		}
+		return out
+	}
+
+	if _, err := Process(AddOutputIntentCommand(inFile, cmykFile, outFile, pdfcpu.OutputIntentPDFX, config)); err != nil {
+		t.Fatalf("TestOutputIntentCommands - add: %v\n", err)
+	}
+
+	if _, err := Process(AddOutputIntentCommand(outFile, cmykFile, outFile, pdfcpu.OutputIntentPDFA, config)); err != nil {
+		t.Fatalf("TestOutputIntentCommands - add: %v\n", err)
+	}
+
+	if out := list(); len(out) != 2 || !strings.HasPrefix(out[0], pdfcpu.OutputIntentPDFX) || !strings.Contains(out[0], "CMYK") {
+		t.Fatalf("TestOutputIntentCommands - list: unexpected output intents: %v\n", out)
+	}
+
+	// Replace the PDF/X profile and extract it again.
+	if _, err := Process(ReplaceOutputIntentCommand(outFile, grayFile, outFile, pdfcpu.OutputIntentPDFX, config)); err != nil {
+		t.Fatalf("TestOutputIntentCommands - replace: %v\n", err)
+	}
+
+	iccFile := filepath.Join(outDir, "extracted.icc")
+	if _, err := Process(ExtractOutputIntentCommand(outFile, iccFile, pdfcpu.OutputIntentPDFX, config)); err != nil {
+		t.Fatalf("TestOutputIntentCommands - extract: %v\n", err)
+	}
+
+	b, err := ioutil.ReadFile(iccFile)
+	if err != nil {
+		t.Fatalf("TestOutputIntentCommands %v\n", err)
+	}
+
+	if !bytes.Equal(b, gray) {
+		t.Fatalf("TestOutputIntentCommands - extract: want replaced profile\n")
+	}
+
+	if _, err := Process(RemoveOutputIntentsCommand(outFile, outFile, pdfcpu.OutputIntentPDFA, config)); err != nil {
+		t.Fatalf("TestOutputIntentCommands - remove: %v\n", err)
+	}
+
+	if out := list(); len(out) != 1 || !strings.HasPrefix(out[0], pdfcpu.OutputIntentPDFX) || !strings.Contains(out[0], "GRAY") {
+		t.Fatalf("TestOutputIntentCommands - remove: unexpected output intents: %v\n", out)
+	}
+
+	if _, err := Process(ExtractOutputIntentCommand(outFile, iccFile, pdfcpu.OutputIntentPDFA, config)); err == nil {
+		t.Fatalf("TestOutputIntentCommands - extract: missing output intent should fail\n")
+	}
+
+	// Remove all output intents.
+	if _, err := Process(RemoveOutputIntentsCommand(outFile, outFile, "", config)); err != nil {
+		t.Fatalf("TestOutputIntentCommands - remove: %v\n", err)
+	}
+
+	if _, err := Process(RemoveOutputIntentsCommand(outFile, outFile, "", config)); err == nil {
+		t.Fatalf("TestOutputIntentCommands - remove: no output intents should fail\n")
+	}
+}
+
+func TestValidatePDFUACommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	for fileName, want := range map[string]map[string]bool{
+		// untagged
+		"5116.DCT_Filter.pdf": {
+			"tagged with structure tree":  false,
+			"natural language specified":  false,
+			"figures with alternate text": true,
+			"tab order follows structure": false,
+		},
+		// tagged, figures lacking alternate text, tables lacking header cells
+		"adobe_supplement_iso32000_1.pdf": {
+			"tagged with structure tree":               true,
+			"document title displayed":                 true,
+			"figures with alternate text":              false,
+			"tables tagged with rows and header cells": false,
+		},
+	} {
+
+		out, err := Process(ValidatePDFUACommand(filepath.Join(inDir, fileName), config))
+		if err != nil {
+			t.Fatalf("TestValidatePDFUACommand: %s: %v\n", fileName, err)
+		}
+
+		var rep pdfcpu.ComplianceReport
+		if err = json.Unmarshal([]byte(out[0]), &rep); err != nil {
+			t.Fatalf("TestValidatePDFUACommand: invalid JSON report: %v\n", err)
+		}
+
+		if rep.Standard != "PDF/UA" || rep.Compliant {
+			t.Fatalf("TestValidatePDFUACommand: %s: want non compliant PDF/UA report, got %s compliant=%t\n", fileName, rep.Standard, rep.Compliant)
+		}
+
+		results := map[string]*pdfcpu.ComplianceRule{}
+		for _, r := range rep.Rules {
+			results[r.Description] = r
+		}
+
+		for desc, passed := range want {
+			r, found := results[desc]
+			if !found || r.Passed != passed {
+				t.Errorf("TestValidatePDFUACommand: %s: %q: got %v, want passed=%t\n", fileName, desc, r, passed)
+				continue
+			}
+			// Violations are reported per page or object.
+			for _, s := range r.Failures {
+				if !strings.HasPrefix(s, "page ") && !strings.HasPrefix(s, "catalog: ") && !strings.Contains(s, "(obj#") {
+					t.Errorf("TestValidatePDFUACommand: %s: %q: unlocated violation %q\n", fileName, desc, s)
+				}
+			}
+		}
+	}
+}
+
+func TestPDF20(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "pdf20.pdf")
+
+	ctx, err := Read(inFile, config)
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	v := pdfcpu.V20
+	ctx.RootVersion = &v
+
+	// Unencrypted wrapper document with an associated encrypted payload
+	sd, err := ctx.NewEmbeddedFileStreamDict(filepath.Join(inDir, "empty.pdf"))
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	indRef, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	fileSpecDict, err := ctx.NewFileSpecDict("empty.pdf", *indRef)
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+	fileSpecDict.InsertName("AFRelationship", "EncryptedPayload")
+
+	ep := pdfcpu.NewPDFDict()
+	ep.InsertName("Type", "EncryptedPayload")
+	ep.InsertName("Subtype", "ExampleCryptoFilter")
+	fileSpecDict.Insert("EP", ep)
+
+	if indRef, err = ctx.IndRefForNewObject(*fileSpecDict); err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+	ctx.RootDict.Insert("AF", pdfcpu.PDFArray{*indRef})
+
+	// Structure tree using a namespace
+	ns := pdfcpu.NewPDFDict()
+	ns.InsertName("Type", "Namespace")
+	ns.Insert("NS", pdfcpu.PDFStringLiteral("http://iso.org/pdf2/ssn"))
+
+	nsRef, err := ctx.IndRefForNewObject(ns)
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	root := pdfcpu.NewPDFDict()
+	root.InsertName("Type", "StructTreeRoot")
+	root.Insert("Namespaces", pdfcpu.PDFArray{*nsRef})
+
+	rootRef, err := ctx.IndRefForNewObject(root)
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	elem := pdfcpu.NewPDFDict()
+	elem.InsertName("Type", "StructElem")
+	elem.InsertName("S", "Document")
+	elem.Insert("P", *rootRef)
+	elem.Insert("NS", *nsRef)
+
+	elemRef, err := ctx.IndRefForNewObject(elem)
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+	root.Insert("K", *elemRef)
+	ctx.RootDict.Insert("StructTreeRoot", *rootRef)
+
+	ctx.Write.DirName = outDir + "/"
+	ctx.Write.FileName = "pdf20.pdf"
+	if err = Write(ctx); err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	hasHeader := func(fileName, header string) bool {
+		b, err := ioutil.ReadFile(fileName)
+		return err == nil && bytes.HasPrefix(b, []byte(header))
+	}
+
+	if !hasHeader(outFile, "%PDF-2.0") {
+		t.Fatalf("TestPDF20: missing PDF 2.0 header\n")
+	}
+
+	if _, err = Process(ValidateCommand(outFile, config)); err != nil {
+		t.Fatalf("TestPDF20: validate: %v\n", err)
+	}
+
+	// PDF 2.0 mandates AES-256.
+	encFile := filepath.Join(outDir, "pdf20enc.pdf")
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	if _, err = Process(EncryptCommand(outFile, encFile, config)); err != nil {
+		t.Fatalf("TestPDF20: encrypt: %v\n", err)
+	}
+
+	if !hasHeader(encFile, "%PDF-2.0") {
+		t.Fatalf("TestPDF20: missing PDF 2.0 header after encryption\n")
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	if ctx, err = Read(encFile, config); err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	if ctx.E.R != 6 {
+		t.Fatalf("TestPDF20: want revision 6, got %d\n", ctx.E.R)
+	}
+
+	// AES-256 needs no developer extension in PDF 2.0.
+	if _, found := ctx.RootDict.Find("Extensions"); found {
+		t.Fatalf("TestPDF20: unexpected extensions dict\n")
+	}
+
+	// A PDF 2.0 file using RC4 is valid in relaxed mode only.
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.EncryptUsingAES = false
+	config.EncryptUsing128BitKey = false
+	if _, err = Process(EncryptCommand(inFile, encFile, config)); err != nil {
+		t.Fatalf("TestPDF20: encrypt: %v\n", err)
+	}
+
+	b, err := ioutil.ReadFile(encFile)
+	if err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+	copy(b, "%PDF-2.0")
+	if err = ioutil.WriteFile(encFile, b, os.ModePerm); err != nil {
+		t.Fatalf("TestPDF20: %v\n", err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	if _, err = Process(ValidateCommand(encFile, config)); err != nil {
+		t.Fatalf("TestPDF20: relaxed validation: %v\n", err)
+	}
+
+	config.ValidationMode = pdfcpu.ValidationStrict
+	if _, err = Process(ValidateCommand(encFile, config)); err == nil {
+		t.Fatalf("TestPDF20: strict validation of RC4 encrypted PDF 2.0 file should fail\n")
+	}
+}
+
+func TestLinearizeCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	isLinearized := func(fileName string) bool {
+		out, err := Process(CheckLinearizationCommand(fileName, pdfcpu.NewDefaultConfiguration()))
+		if err != nil {
+			t.Fatalf("TestLinearizeCommand - check %s: %v\n", fileName, err)
+		}
+		return strings.HasSuffix(out[0], "is linearized.")
+	}
+
+	if isLinearized(filepath.Join(inDir, "go.pdf")) {
+		t.Fatalf("TestLinearizeCommand: go.pdf is not linearized\n")
+	}
+
+	for _, fileName := range []string{"go.pdf", "CenterOfWhy.pdf", "adobe_supplement_iso32000_1.pdf"} {
+
+		inFile := filepath.Join(inDir, fileName)
+		outFile := filepath.Join(outDir, "linearized_"+fileName)
+
+		if _, err := Process(LinearizeCommand(inFile, outFile, config)); err != nil {
+			t.Fatalf("TestLinearizeCommand - linearize %s: %v\n", fileName, err)
+		}
+
+		if !isLinearized(outFile) {
+			t.Fatalf("TestLinearizeCommand: %s should be linearized\n", outFile)
+		}
+
+		if _, err := Process(ValidateCommand(outFile, pdfcpu.NewDefaultConfiguration())); err != nil {
+			t.Fatalf("TestLinearizeCommand - validate %s: %v\n", outFile, err)
+		}
+	}
+
+	// An incremental update invalidates linearization.
+	outFile := filepath.Join(outDir, "linearized_go.pdf")
+
+	b, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("TestLinearizeCommand: %v\n", err)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestLinearizeCommand - read: %v\n", err)
+	}
+
+	snap := ctx.Snapshot()
+	ctx.RootDict.Update("Lang", pdfcpu.PDFStringLiteral("en-US"))
+
+	bb, err := pdfcpu.WriteIncrement(ctx, b, ctx.ModifiedObjects(snap))
+	if err != nil {
+		t.Fatalf("TestLinearizeCommand - write increment: %v\n", err)
+	}
+
+	modFile := filepath.Join(outDir, "linearizedModified.pdf")
+	if err = ioutil.WriteFile(modFile, bb, 0644); err != nil {
+		t.Fatalf("TestLinearizeCommand: %v\n", err)
+	}
+
+	if isLinearized(modFile) {
+		t.Fatalf("TestLinearizeCommand: %s should no longer be linearized\n", modFile)
+	}
+}
+
+func TestXMPCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	list := func(fileName string) map[string]string {
+		out, err := Process(ListXMPCommand(fileName, config))
+		if err != nil {
+			t.Fatalf("TestXMPCommand - list %s: %v\n", fileName, err)
+		}
+		m := map[string]string{}
+		for _, s := range out {
+			if kv := strings.SplitN(s, " = ", 2); len(kv) == 2 {
+				m[kv[0]] = kv[1]
+			}
+		}
+		return m
+	}
+
+	info := func(fileName, key string) string {
+		ctx, err := Read(fileName, config)
+		if err != nil {
+			t.Fatalf("TestXMPCommand - read %s: %v\n", fileName, err)
+		}
+		d, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil || d == nil {
+			t.Fatalf("TestXMPCommand - %s: missing info dict\n", fileName)
+		}
+		s, err := pdfcpu.StringLiteralToString(d.PDFStringLiteralEntry(key).Value())
+		if err != nil {
+			t.Fatalf("TestXMPCommand - %s: %v\n", fileName, err)
+		}
+		return s
+	}
+
+	inFile := filepath.Join(inDir, "adobe_supplement_iso32000_1.pdf")
+	outFile := filepath.Join(outDir, "xmp.pdf")
+
+	if m := list(inFile); m["dc:title"] != "Adobe Extensions to ISO 32000-1:2008, Level 5" || m["xmp:CreatorTool"] != "Adobe InDesign CS4 (6.0.2)" {
+		t.Fatalf("TestXMPCommand - list: %v\n", m)
+	}
+
+	props := map[string]string{"dc:title": "Annual Report", "dc:creator": "Jane Doe", "pdf:Keywords": "finance, 2018"}
+	if _, err := Process(SetXMPCommand(inFile, outFile, props, config)); err != nil {
+		t.Fatalf("TestXMPCommand - set: %v\n", err)
+	}
+
+	m := list(outFile)
+	for k, v := range props {
+		if m[k] != v {
+			t.Fatalf("TestXMPCommand - %s: got %q, want %q\n", k, m[k], v)
+		}
+	}
+	if m["pdf:Producer"] != pdfcpu.PDFCPULongVersion || m["xmpMM:DocumentID"] == "" {
+		t.Fatalf("TestXMPCommand - set: %v\n", m)
+	}
+
+	if s := info(outFile, "Title"); s != "Annual Report" {
+		t.Fatalf("TestXMPCommand - info Title: %q\n", s)
+	}
+
+	// Document info changes make it into the XMP metadata on write.
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestXMPCommand - read: %v\n", err)
+	}
+	d, _ := ctx.DereferenceDict(*ctx.Info)
+	d.Update("Title", pdfcpu.PDFStringLiteral("Quarterly Report"))
+	d.Delete("Creator")
+
+	ctx.Write.DirName, ctx.Write.FileName = filepath.Split(outFile)
+	if err = Write(ctx); err != nil {
+		t.Fatalf("TestXMPCommand - write: %v\n", err)
+	}
+
+	if m = list(outFile); m["dc:title"] != "Quarterly Report" {
+		t.Fatalf("TestXMPCommand - synced dc:title: %q\n", m["dc:title"])
+	}
+
+	// Creator is restored from XMP.
+	if s := info(outFile, "Creator"); s != "Adobe InDesign CS4 (6.0.2)" {
+		t.Fatalf("TestXMPCommand - synced info Creator: %q\n", s)
+	}
+
+	// Metadata is synthesized from the document info dict for files without XMP.
+	inFile = filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	if _, err := Process(SetXMPCommand(inFile, outFile, map[string]string{"dc:title": "DCT"}, config)); err != nil {
+		t.Fatalf("TestXMPCommand - set: %v\n", err)
+	}
+
+	if m = list(outFile); m["dc:title"] != "DCT" || m["xmp:CreatorTool"] != "FrameMaker 5.5.6" {
+		t.Fatalf("TestXMPCommand - synthesized: %v\n", m)
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	list := func(fileName string, config *pdfcpu.Configuration, cmd func(string, *pdfcpu.Configuration) *Command) map[string]string {
+		out, err := Process(cmd(fileName, config))
+		if err != nil {
+			t.Fatalf("TestInfoCommand - list %s: %v\n", fileName, err)
+		}
+		m := map[string]string{}
+		for _, s := range out {
+			if kv := strings.SplitN(s, " = ", 2); len(kv) == 2 {
+				m[kv[0]] = kv[1]
+			}
+		}
+		return m
+	}
+
+	inFile := filepath.Join(inDir, "adobe_supplement_iso32000_1.pdf")
+	outFile := filepath.Join(outDir, "info.pdf")
+
+	props := map[string]string{
+		"Title":        "Annual Report",
+		"Author":       "Jane Doe",
+		"Department":   "Finance",
+		"CreationDate": "D:20180101120000Z",
+	}
+	if _, err := Process(SetInfoCommand(inFile, outFile, props, config)); err != nil {
+		t.Fatalf("TestInfoCommand - set: %v\n", err)
+	}
+
+	m := list(outFile, config, ListInfoCommand)
+	if m["Title"] != "Annual Report" || m["Author"] != "Jane Doe" || m["Department"] != "Finance" ||
+		m["CreationDate"] != "2018-01-01T12:00:00+00:00" || m["Producer"] != pdfcpu.PDFCPULongVersion {
+		t.Fatalf("TestInfoCommand - set: %v\n", m)
+	}
+
+	if x := list(outFile, config, ListXMPCommand); x["dc:title"] != "Annual Report" || x["dc:creator"] != "Jane Doe" {
+		t.Fatalf("TestInfoCommand - set XMP: %v\n", x)
+	}
+
+	for _, props := range []map[string]string{{"Producer": "me"}, {"Trapped": "Maybe"}, {"Bad Key": "x"}, {"CreationDate": "yesterday"}} {
+		if _, err := Process(SetInfoCommand(inFile, outFile, props, config)); err == nil {
+			t.Fatalf("TestInfoCommand - set %v: missing error\n", props)
+		}
+	}
+
+	// Removed entries must not be restored from XMP on write.
+	if _, err := Process(RemoveInfoCommand(outFile, outFile, []string{"Author", "Department"}, config)); err != nil {
+		t.Fatalf("TestInfoCommand - remove: %v\n", err)
+	}
+
+	m = list(outFile, config, ListInfoCommand)
+	if _, found := m["Author"]; found || m["Department"] != "" || m["Title"] != "Annual Report" {
+		t.Fatalf("TestInfoCommand - remove: %v\n", m)
+	}
+
+	if x := list(outFile, config, ListXMPCommand); x["dc:creator"] != "" {
+		t.Fatalf("TestInfoCommand - remove XMP: %v\n", x)
+	}
+
+	// Replace the Producer and remove the Creator.
+	c := config.With(pdfcpu.WithProducer("Report Generator 1.0"), pdfcpu.WithCreator(""))
+	if _, err := Process(OptimizeCommand(outFile, outFile, c)); err != nil {
+		t.Fatalf("TestInfoCommand - optimize: %v\n", err)
+	}
+
+	m = list(outFile, config, ListInfoCommand)
+	x := list(outFile, config, ListXMPCommand)
+	if m["Producer"] != "Report Generator 1.0" || x["pdf:Producer"] != "Report Generator 1.0" {
+		t.Fatalf("TestInfoCommand - producer: %v %v\n", m, x)
+	}
+	if _, found := m["Creator"]; found || x["xmp:CreatorTool"] != "" {
+		t.Fatalf("TestInfoCommand - creator: %v %v\n", m, x)
+	}
+
+	// A missing document info dict is created for the supplied producer.
+	inFile = filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	ctx, err := Read(inFile, config)
+	if err != nil {
+		t.Fatalf("TestInfoCommand - read: %v\n", err)
+	}
+	ctx.Info = nil
+	ctx.Write.DirName, ctx.Write.FileName = filepath.Split(outFile)
+	if err = Write(ctx); err != nil {
+		t.Fatalf("TestInfoCommand - write: %v\n", err)
+	}
+
+	if m = list(outFile, config, ListInfoCommand); len(m) != 0 {
+		t.Fatalf("TestInfoCommand - no info: %v\n", m)
+	}
+
+	if _, err := Process(OptimizeCommand(outFile, outFile, config.With(pdfcpu.WithProducer("me")))); err != nil {
+		t.Fatalf("TestInfoCommand - optimize: %v\n", err)
+	}
+
+	if m = list(outFile, config, ListInfoCommand); m["Producer"] != "me" {
+		t.Fatalf("TestInfoCommand - created info: %v\n", m)
+	}
+}
+
+func TestKeywordsAndPropertiesCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	list := func(cmd *Command) []string {
+		out, err := Process(cmd)
+		if err != nil {
+			t.Fatalf("TestKeywordsAndPropertiesCommand - list %s: %v\n", *cmd.InFile, err)
+		}
+		return out
+	}
+
+	inFile := filepath.Join(inDir, "adobe_supplement_iso32000_1.pdf")
+	outFile := filepath.Join(outDir, "keywords.pdf")
+
+	if _, err := Process(RemoveKeywordsCommand(inFile, outFile, nil, config)); err != nil {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove all keywords: %v\n", err)
+	}
+
+	if _, err := Process(AddKeywordsCommand(outFile, outFile, []string{"invoice", "fiscal year 2018", "invoice"}, config)); err != nil {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - add keywords: %v\n", err)
+	}
+
+	if kk := list(ListKeywordsCommand(outFile, config)); strings.Join(kk, "|") != "invoice|fiscal year 2018" {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - add keywords: %v\n", kk)
+	}
+
+	if _, err := Process(AddKeywordsCommand(outFile, outFile, []string{"a,b"}, config)); err == nil {
+		t.Fatal("TestKeywordsAndPropertiesCommand - add invalid keyword: missing error")
+	}
+
+	if _, err := Process(RemoveKeywordsCommand(outFile, outFile, []string{"invoice"}, config)); err != nil {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove keywords: %v\n", err)
+	}
+
+	if kk := list(ListKeywordsCommand(outFile, config)); len(kk) != 1 || kk[0] != "fiscal year 2018" {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove keywords: %v\n", kk)
+	}
+
+	if _, err := Process(RemoveKeywordsCommand(outFile, outFile, []string{"invoice"}, config)); err == nil {
+		t.Fatal("TestKeywordsAndPropertiesCommand - remove missing keyword: missing error")
+	}
+
+	if _, err := Process(RemovePropertiesCommand(outFile, outFile, nil, config)); err != nil {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove all properties: %v\n", err)
+	}
+
+	props := map[string]string{"DocumentNumber": "4711", "Department": "Finance"}
+	if _, err := Process(AddPropertiesCommand(outFile, outFile, props, config)); err != nil {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - add properties: %v\n", err)
+	}
+
+	for _, props := range []map[string]string{{"Title": "x"}, {"Bad Name": "x"}, {"Empty": " "}} {
+		if _, err := Process(AddPropertiesCommand(outFile, outFile, props, config)); err == nil {
+			t.Fatalf("TestKeywordsAndPropertiesCommand - add %v: missing error\n", props)
+		}
+	}
+
+	if _, err := Process(RemovePropertiesCommand(outFile, outFile, []string{"Department"}, config)); err != nil {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove properties: %v\n", err)
+	}
+
+	if pp := list(ListPropertiesCommand(outFile, config)); len(pp) != 1 || pp[0] != "DocumentNumber = 4711" {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove properties: %v\n", pp)
+	}
+
+	if _, err := Process(RemovePropertiesCommand(outFile, outFile, nil, config)); err != nil {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove all properties: %v\n", err)
+	}
+
+	if pp := list(ListPropertiesCommand(outFile, config)); len(pp) != 1 || pp[0] != "no properties" {
+		t.Fatalf("TestKeywordsAndPropertiesCommand - remove all properties: %v\n", pp)
+	}
+}
+
+func TestBookmarksCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "adobe_supplement_iso32000_1.pdf")
+	outFile := filepath.Join(outDir, "bookmarks.pdf")
+	jsonFile := filepath.Join(outDir, "bookmarks.json")
+	yamlFile := filepath.Join(outDir, "bookmarks.yaml")
+
+	export := func(fileIn, fileOut string) []*pdfcpu.Bookmark {
+		if _, err := Process(ExportBookmarksCommand(fileIn, fileOut, config)); err != nil {
+			t.Fatalf("TestBookmarksCommand - export %s: %v\n", fileOut, err)
+		}
+		bb, err := ReadBookmarks(fileOut, config)
+		if err != nil {
+			t.Fatalf("TestBookmarksCommand - read %s: %v\n", fileOut, err)
+		}
+		return bb
+	}
+
+	bb := export(inFile, jsonFile)
+	if len(bb) == 0 {
+		t.Fatal("TestBookmarksCommand - export: missing bookmarks")
+	}
+
+	if bb1 := export(inFile, yamlFile); !reflect.DeepEqual(bb, bb1) {
+		t.Fatal("TestBookmarksCommand - export: YAML differs from JSON")
+	}
+
+	// Replace the outline by the export and compare.
+	if _, err := Process(ImportBookmarksCommand(inFile, yamlFile, outFile, config)); err != nil {
+		t.Fatalf("TestBookmarksCommand - import: %v\n", err)
+	}
+
+	if bb1 := export(outFile, jsonFile); !reflect.DeepEqual(bb, bb1) {
+		t.Fatal("TestBookmarksCommand - import: outline differs")
+	}
+
+	bb = []*pdfcpu.Bookmark{
+		{Title: "Chapter 1", Page: 1, Bold: true, Color: "#FF0000", Open: true, Kids: []*pdfcpu.Bookmark{
+			{Title: "Section: \"1.1\"", Page: 2, View: "XYZ 0 792 null", Italic: true},
+			{Title: "pdfcpu", URI: "https://pdfcpu.io"},
+		}},
+		{Title: "Chapter 2 äöü", Page: 3, View: "FitH 500", Kids: []*pdfcpu.Bookmark{
+			{Title: "Index", Dest: "index"},
+		}},
+	}
+
+	if err := config.WriteOutput(yamlFile, pdfcpu.BookmarksYAML(bb)); err != nil {
+		t.Fatalf("TestBookmarksCommand - write yaml: %v\n", err)
+	}
+
+	if _, err := Process(ImportBookmarksCommand(inFile, yamlFile, outFile, config)); err != nil {
+		t.Fatalf("TestBookmarksCommand - import: %v\n", err)
+	}
+
+	if bb1 := export(outFile, jsonFile); !reflect.DeepEqual(bb, bb1) {
+		t.Fatalf("TestBookmarksCommand - import: outline differs: %s\n", pdfcpu.BookmarksYAML(bb1))
+	}
+
+	bb[0].Kids[0].View = "XYZ 0"
+	if err := config.WriteOutput(yamlFile, pdfcpu.BookmarksYAML(bb)); err != nil {
+		t.Fatalf("TestBookmarksCommand - write yaml: %v\n", err)
+	}
+
+	if _, err := Process(ImportBookmarksCommand(inFile, yamlFile, outFile, config)); err == nil {
+		t.Fatal("TestBookmarksCommand - import invalid view: missing error")
+	}
+
+	if _, err := Process(GenerateBookmarksCommand(inFile, outFile, 3, config)); err != nil {
+		t.Fatalf("TestBookmarksCommand - generate: %v\n", err)
+	}
+
+	ctx, err := Open(outFile, config).Context()
+	if err != nil {
+		t.Fatalf("TestBookmarksCommand - read: %v\n", err)
+	}
+
+	out, err := Process(ListBookmarksCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestBookmarksCommand - list: %v\n", err)
+	}
+
+	if len(out) != (ctx.PageCount+2)/3 || out[0] != "Pages 1-3 (page 1)" {
+		t.Fatalf("TestBookmarksCommand - generate: %v\n", out)
+	}
+}
+
+func TestNamedDestsCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "dests.pdf")
+	jsonFile := filepath.Join(outDir, "dests.json")
+
+	list := func() []string {
+		out, err := Process(ListNamedDestsCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestNamedDestsCommand - list: %v\n", err)
+		}
+		return out
+	}
+
+	bookmark := func() *pdfcpu.Bookmark {
+		if _, err := Process(ExportBookmarksCommand(outFile, jsonFile, config)); err != nil {
+			t.Fatalf("TestNamedDestsCommand - export bookmarks: %v\n", err)
+		}
+		bb, err := ReadBookmarks(jsonFile, config)
+		if err != nil || len(bb) != 1 {
+			t.Fatalf("TestNamedDestsCommand - read bookmarks: %v %v\n", bb, err)
+		}
+		return bb[0]
+	}
+
+	dest := pdfcpu.NamedDest{Name: "intro", Page: 2, View: "FitH 500"}
+	if _, err := Process(AddNamedDestCommand(inFile, outFile, dest, config)); err != nil {
+		t.Fatalf("TestNamedDestsCommand - add: %v\n", err)
+	}
+
+	if _, err := Process(AddNamedDestCommand(outFile, outFile, dest, config)); err == nil {
+		t.Fatal("TestNamedDestsCommand - add twice: missing error")
+	}
+
+	// Refer to the destination by an outline item.
+	bb := []*pdfcpu.Bookmark{{Title: "Introduction", Dest: "intro"}}
+	b, err := pdfcpu.BookmarksJSON(bb)
+	if err != nil {
+		t.Fatalf("TestNamedDestsCommand - bookmarks: %v\n", err)
+	}
+	if err = config.WriteOutput(jsonFile, b); err != nil {
+		t.Fatalf("TestNamedDestsCommand - bookmarks: %v\n", err)
+	}
+	if _, err := Process(ImportBookmarksCommand(outFile, jsonFile, outFile, config)); err != nil {
+		t.Fatalf("TestNamedDestsCommand - import bookmarks: %v\n", err)
+	}
+
+	if _, err := Process(RenameNamedDestCommand(outFile, outFile, "intro", "chapter1", config)); err != nil {
+		t.Fatalf("TestNamedDestsCommand - rename: %v\n", err)
+	}
+
+	if ss := list(); len(ss) != 1 || ss[0] != "chapter1: page 2 FitH 500" {
+		t.Fatalf("TestNamedDestsCommand - rename: %v\n", ss)
+	}
+
+	if b := bookmark(); b.Dest != "chapter1" {
+		t.Fatalf("TestNamedDestsCommand - rename: bookmark refers to %q\n", b.Dest)
+	}
+
+	if _, err := Process(RemoveNamedDestsCommand(outFile, outFile, []string{"intro"}, config)); err == nil {
+		t.Fatal("TestNamedDestsCommand - remove missing: missing error")
+	}
+
+	if _, err := Process(RemoveNamedDestsCommand(outFile, outFile, []string{"chapter1"}, config)); err != nil {
+		t.Fatalf("TestNamedDestsCommand - remove: %v\n", err)
+	}
+
+	if ss := list(); len(ss) != 1 || ss[0] != "no named destinations" {
+		t.Fatalf("TestNamedDestsCommand - remove: %v\n", ss)
+	}
+
+	// The outline item jumps to the explicit destination now.
+	if b := bookmark(); b.Dest != "" || b.Page != 2 || b.View != "FitH 500" {
+		t.Fatalf("TestNamedDestsCommand - remove: %+v\n", b)
+	}
+}
+
+func TestLayersCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "pike-stanford.pdf")
+	outFile := filepath.Join(outDir, "layers.pdf")
+
+	list := func() []string {
+		out, err := Process(ListLayersCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestLayersCommand - list: %v\n", err)
+		}
+		return out
+	}
+
+	// Stamps are marked as optional content of the layer "Watermark".
+	stamp := func(fileIn string) error {
+		wm, err := pdfcpu.ParseWatermarkDetails("Draft", true)
+		if err != nil {
+			t.Fatalf("TestLayersCommand - stamp: %v\n", err)
+		}
+		_, err = Process(AddWatermarksCommand(fileIn, outFile, nil, wm, config))
+		return err
+	}
+
+	if err := stamp(inFile); err != nil {
+		t.Fatalf("TestLayersCommand - stamp: %v\n", err)
+	}
+
+	if ss := list(); len(ss) != 1 || ss[0] != "Watermark (visible)" {
+		t.Fatalf("TestLayersCommand - list: %v\n", ss)
+	}
+
+	if _, err := Process(SetLayerVisibilityCommand(outFile, outFile, []string{"Watermark"}, false, config)); err != nil {
+		t.Fatalf("TestLayersCommand - hide: %v\n", err)
+	}
+
+	if _, err := Process(RenameLayerCommand(outFile, outFile, "Watermark", "Draft", config)); err != nil {
+		t.Fatalf("TestLayersCommand - rename: %v\n", err)
+	}
+
+	if ss := list(); len(ss) != 1 || ss[0] != "Draft (hidden)" {
+		t.Fatalf("TestLayersCommand - rename: %v\n", ss)
+	}
+
+	if _, err := Process(RemoveLayersCommand(outFile, outFile, []string{"Watermark"}, config)); err == nil {
+		t.Fatal("TestLayersCommand - remove missing: missing error")
+	}
+
+	if _, err := Process(RemoveLayersCommand(outFile, outFile, []string{"Draft"}, config)); err != nil {
+		t.Fatalf("TestLayersCommand - remove: %v\n", err)
+	}
+
+	if ss := list(); len(ss) != 1 || ss[0] != "no layers" {
+		t.Fatalf("TestLayersCommand - remove: %v\n", ss)
+	}
+
+	// With the layer gone the file may be stamped again.
+	if err := stamp(outFile); err != nil {
+		t.Fatalf("TestLayersCommand - stamp after remove: %v\n", err)
+	}
+
+	if _, err := Process(FlattenLayersCommand(outFile, outFile, config)); err != nil {
+		t.Fatalf("TestLayersCommand - flatten: %v\n", err)
+	}
+
+	if ss := list(); len(ss) != 1 || ss[0] != "no layers" {
+		t.Fatalf("TestLayersCommand - flatten: %v\n", ss)
+	}
+}
+
+func TestViewerPreferencesCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "viewer.pdf")
+
+	list := func() []string {
+		out, err := Process(ListViewerPreferencesCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestViewerPreferencesCommand - list: %v\n", err)
+		}
+		return out
+	}
+
+	if _, err := Process(SetViewerPreferencesCommand(inFile, outFile, pdfcpu.ViewerPrefs{PageLayout: "TwoColumns"}, config)); err == nil {
+		t.Fatal("TestViewerPreferencesCommand - invalid layout: missing error")
+	}
+
+	fitWindow := true
+	vp := pdfcpu.ViewerPrefs{PageLayout: "TwoColumnLeft", FitWindow: &fitWindow, Duplex: "DuplexFlipLongEdge"}
+	if _, err := Process(SetViewerPreferencesCommand(inFile, outFile, vp, config)); err != nil {
+		t.Fatalf("TestViewerPreferencesCommand - set: %v\n", err)
+	}
+
+	vp = pdfcpu.ViewerPrefs{OpenPage: 2, OpenView: "XYZ null null 1.5"}
+	if _, err := Process(SetViewerPreferencesCommand(outFile, outFile, vp, config)); err != nil {
+		t.Fatalf("TestViewerPreferencesCommand - open: %v\n", err)
+	}
+
+	want := []string{"PageLayout: TwoColumnLeft", "FitWindow: true", "Duplex: DuplexFlipLongEdge", "OpenAction: page 2 XYZ null null 1.5"}
+	if ss := list(); !reflect.DeepEqual(ss, want) {
+		t.Fatalf("TestViewerPreferencesCommand - list: %v\n", ss)
+	}
+
+	keys := []string{"OpenAction", "FitWindow", "Duplex"}
+	if _, err := Process(RemoveViewerPreferencesCommand(outFile, outFile, keys, config)); err != nil {
+		t.Fatalf("TestViewerPreferencesCommand - remove: %v\n", err)
+	}
+
+	if ss := list(); len(ss) != 1 || ss[0] != "PageLayout: TwoColumnLeft" {
+		t.Fatalf("TestViewerPreferencesCommand - remove: %v\n", ss)
+	}
+}
+
+func TestTransitionsCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "transitions.pdf")
+
+	list := func() []string {
+		out, err := Process(ListTransitionsCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestTransitionsCommand - list: %v\n", err)
+		}
+		return out
+	}
+
+	if _, err := pdfcpu.ParseTransitionDetails("spin, d:1"); err == nil {
+		t.Fatal("TestTransitionsCommand - invalid style: missing error")
+	}
+
+	tr, err := pdfcpu.ParseTransitionDetails("dissolve, d:1.5, dur:5")
+	if err != nil {
+		t.Fatalf("TestTransitionsCommand - parse: %v\n", err)
+	}
+
+	if _, err = Process(SetTransitionsCommand(inFile, outFile, nil, *tr, config)); err != nil {
+		t.Fatalf("TestTransitionsCommand - set: %v\n", err)
+	}
+
+	if tr, err = pdfcpu.ParseTransitionDetails("wipe, di:90"); err != nil {
+		t.Fatalf("TestTransitionsCommand - parse: %v\n", err)
+	}
+
+	if _, err = Process(SetTransitionsCommand(outFile, outFile, []string{"2"}, *tr, config)); err != nil {
+		t.Fatalf("TestTransitionsCommand - set: %v\n", err)
+	}
+
+	ss := list()
+	if len(ss) < 2 || ss[0] != "page 1: Dissolve, d:1.5, dur:5" || ss[1] != "page 2: Wipe, di:90, dur:5" {
+		t.Fatalf("TestTransitionsCommand - list: %v\n", ss)
+	}
+
+	// Print version
+	if _, err = Process(RemoveTransitionsCommand(outFile, outFile, nil, config)); err != nil {
+		t.Fatalf("TestTransitionsCommand - remove: %v\n", err)
+	}
+
+	if ss = list(); len(ss) != 1 || ss[0] != "no page transitions" {
+		t.Fatalf("TestTransitionsCommand - remove: %v\n", ss)
+	}
+}
+
+func TestPortfolioCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "empty.pdf")
+	outFile := filepath.Join(outDir, "portfolio.pdf")
+	outFile2 := filepath.Join(outDir, "portfolio2.pdf")
+	dir := filepath.Join(outDir, "portfolio")
+
+	list := func(fileName string) []string {
+		out, err := Process(ListPortfolioCommand(fileName, config))
+		if err != nil {
+			t.Fatalf("TestPortfolioCommand - list: %v\n", err)
+		}
+		return out
+	}
+
+	p := &pdfcpu.Portfolio{
+		Fields: []pdfcpu.PortfolioField{
+			{Key: "FileName", Name: "Name", Type: "filename"},
+			{Key: "Author", Name: "Author", Type: "text"},
+			{Key: "Due", Name: "Due", Type: "date"},
+		},
+		Sort:    []string{"Due"},
+		Initial: "go.pdf",
+		Members: []pdfcpu.PortfolioMember{
+			{File: filepath.Join(inDir, "go.pdf"), Description: "Slides", Values: map[string]string{"Author": "Rob", "Due": "2019-03-01"}},
+			{File: filepath.Join(inDir, "test.wav"), Values: map[string]string{"Author": "Ken"}},
+		},
+	}
+
+	bad := *p
+	bad.Sort = []string{"Size"}
+	if _, err := Process(CreatePortfolioCommand(inFile, outFile, &bad, config)); err == nil {
+		t.Fatal("TestPortfolioCommand - unknown sort field: missing error")
+	}
+
+	if _, err := Process(CreatePortfolioCommand(inFile, outFile, p, config)); err != nil {
+		t.Fatalf("TestPortfolioCommand - create: %v\n", err)
+	}
+
+	want := list(outFile)
+	if len(want) != 8 || want[6] != `go.pdf "Slides" Author=Rob Due=2019-03-01` || want[7] != "test.wav Author=Ken" {
+		t.Fatalf("TestPortfolioCommand - list: %v\n", want)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("TestPortfolioCommand - extract: %v\n", err)
+	}
+
+	if _, err := Process(ExtractPortfolioCommand(outFile, dir, config)); err != nil {
+		t.Fatalf("TestPortfolioCommand - extract: %v\n", err)
+	}
+
+	// The extracted description recreates the portfolio.
+	p, err := ReadPortfolio(filepath.Join(dir, "portfolio.json"), config)
+	if err != nil {
+		t.Fatalf("TestPortfolioCommand - read: %v\n", err)
+	}
+
+	if _, err := Process(CreatePortfolioCommand(inFile, outFile2, p, config)); err != nil {
+		t.Fatalf("TestPortfolioCommand - recreate: %v\n", err)
+	}
+
+	if got := list(outFile2); !reflect.DeepEqual(got, want) {
+		t.Fatalf("TestPortfolioCommand - recreate: %v, want %v\n", got, want)
+	}
+}
+
+func TestAssociatedFilesCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "associatedFiles.pdf")
+	wavFile := filepath.Join(inDir, "test.wav")
+
+	list := func(fileName string) []string {
+		out, err := Process(ListAssociatedFilesCommand(fileName, config))
+		if err != nil {
+			t.Fatalf("TestAssociatedFilesCommand - list: %v\n", err)
+		}
+		return out
+	}
+
+	validate := func(fileName string) []string {
+		out, err := Process(ValidateAssociatedFilesCommand(fileName, config))
+		if err != nil {
+			t.Fatalf("TestAssociatedFilesCommand - validate: %v\n", err)
+		}
+		return out
+	}
+
+	if ss := list(inFile); len(ss) != 1 || ss[0] != "no associated files" {
+		t.Fatalf("TestAssociatedFilesCommand - list: %v\n", ss)
+	}
+
+	for _, af := range []pdfcpu.AssociatedFile{
+		{File: wavFile, Relationship: "Recording"},
+		{File: wavFile, Page: 2, ObjNr: 1},
+		{File: wavFile, Page: 999},
+	} {
+		if _, err := Process(AddAssociatedFileCommand(inFile, outFile, af, config)); err == nil {
+			t.Fatalf("TestAssociatedFilesCommand - add %v: missing error\n", af)
+		}
+	}
+
+	af := pdfcpu.AssociatedFile{File: wavFile, Relationship: "data", Description: "Recording"}
+	if _, err := Process(AddAssociatedFileCommand(inFile, outFile, af, config)); err != nil {
+		t.Fatalf("TestAssociatedFilesCommand - add document: %v\n", err)
+	}
+
+	af = pdfcpu.AssociatedFile{File: wavFile, Page: 2}
+	if _, err := Process(AddAssociatedFileCommand(outFile, outFile, af, config)); err != nil {
+		t.Fatalf("TestAssociatedFilesCommand - add page: %v\n", err)
+	}
+
+	want := []string{`document: test.wav Data "Recording"`, "page 2: test.wav Unspecified"}
+	if ss := list(outFile); !reflect.DeepEqual(ss, want) {
+		t.Fatalf("TestAssociatedFilesCommand - list: %v, want %v\n", ss, want)
+	}
+
+	if ss := validate(outFile); len(ss) != 1 || ss[0] != "associated files ok" {
+		t.Fatalf("TestAssociatedFilesCommand - validate: %v\n", ss)
+	}
+
+	// Plain attachments are not associated.
+	if _, err := Process(AddAttachmentsCommand(outFile, []string{filepath.Join(inDir, "empty.pdf")}, config)); err != nil {
+		t.Fatalf("TestAssociatedFilesCommand - attach: %v\n", err)
+	}
+
+	if ss := validate(outFile); len(ss) != 1 || ss[0] != "attachment empty.pdf: not associated" {
+		t.Fatalf("TestAssociatedFilesCommand - validate: %v\n", ss)
+	}
+}
+
+func TestGeoCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "geo.pdf")
+	jsonFile := filepath.Join(outDir, "viewports.json")
+
+	list := func(fileName string) []string {
+		out, err := Process(ListViewportsCommand(fileName, config))
+		if err != nil {
+			t.Fatalf("TestGeoCommand - list: %v\n", err)
+		}
+		return out
+	}
+
+	if ss := list(inFile); len(ss) != 1 || ss[0] != "no viewports" {
+		t.Fatalf("TestGeoCommand - list: %v\n", ss)
+	}
+
+	gcs := &pdfcpu.CoordSystem{Type: "GEOGCS", EPSG: 4326}
+	gpts := []float64{40.0123456, -105.3, 40.1, -105.3, 40.1, -105.2, 40.0123456, -105.2}
+
+	for _, vp := range []pdfcpu.Viewport{
+		{BBox: []float64{36, 36, 576, 756}, GPTS: gpts},
+		{BBox: []float64{36, 36, 576, 756}, GCS: &pdfcpu.CoordSystem{Type: "GEOGCS"}, GPTS: gpts},
+		{BBox: []float64{36, 36, 576, 756}, GCS: gcs, GPTS: gpts[:5]},
+		{BBox: []float64{36, 36, 576, 756}, GCS: gcs, GPTS: gpts[:6]},
+		{BBox: []float64{36, 36, 576, 756}, GCS: gcs, GPTS: gpts, PDU: []string{"KM", "DEG", "SQKM"}},
+		{BBox: []float64{36, 36}, GCS: gcs, GPTS: gpts},
+	} {
+		if _, err := Process(AddViewportsCommand(inFile, outFile, []pdfcpu.Viewport{vp}, config)); err == nil {
+			t.Fatalf("TestGeoCommand - add %v: missing error\n", vp)
+		}
+	}
+
+	desc := `[
+		{"name": "Boulder", "bbox": [36, 36, 576, 756], "gcs": {"type": "GEOGCS", "epsg": 4326}, "pdu": ["KM", "SQKM", "DEG"],
+		 "gpts": [40.0123456, -105.3, 40.1, -105.3, 40.1, -105.2, 40.0123456, -105.2]},
+		{"page": 2, "bbox": [0, 0, 100, 100], "gcs": {"type": "PROJCS", "wkt": "PROJCS[\"NAD83 / UTM zone 13N\"]"},
+		 "gpts": [40, -105, 41, -105, 41, -104], "lpts": [0, 0, 0, 1, 1, 1]}
+	]`
+
+	if err := ioutil.WriteFile(jsonFile, []byte(desc), 0644); err != nil {
+		t.Fatalf("TestGeoCommand: %v\n", err)
+	}
+
+	vps, err := ReadViewports(jsonFile, config)
+	if err != nil {
+		t.Fatalf("TestGeoCommand - read: %v\n", err)
+	}
+
+	if _, err := Process(AddViewportsCommand(inFile, outFile, vps, config)); err != nil {
+		t.Fatalf("TestGeoCommand - add: %v\n", err)
+	}
+
+	if _, err := Process(ValidateCommand(outFile, config)); err != nil {
+		t.Fatalf("TestGeoCommand - validate: %v\n", err)
+	}
+
+	want := []string{
+		`page 1 "Boulder": bbox 36 36 576 756, GEO, gcs GEOGCS EPSG:4326, pdu KM SQKM DEG, gpts 40.0123456 -105.3 40.1 -105.3 40.1 -105.2 40.0123456 -105.2, lpts 0 0 0 1 1 1 1 0`,
+		"page 2: bbox 0 0 100 100, GEO, gcs PROJCS WKT, gpts 40 -105 41 -105 41 -104, lpts 0 0 0 1 1 1",
+	}
+
+	if ss := list(outFile); !reflect.DeepEqual(ss, want) {
+		t.Fatalf("TestGeoCommand - list: %v, want %v\n", ss, want)
+	}
+
+	if _, err := Process(RemoveViewportsCommand(outFile, outFile, []string{"2"}, config)); err != nil {
+		t.Fatalf("TestGeoCommand - remove: %v\n", err)
+	}
+
+	if ss := list(outFile); !reflect.DeepEqual(ss, want[:1]) {
+		t.Fatalf("TestGeoCommand - remove: %v, want %v\n", ss, want[:1])
+	}
+}
+
+// fakeOCR recognizes the same words in every image.
+type fakeOCR struct {
+	images []pdfcpu.OCRImage
+}
+
+func (e *fakeOCR) Recognize(img pdfcpu.OCRImage) ([]pdfcpu.OCRWord, error) {
+	e.images = append(e.images, img)
+	w, h := float64(img.Width), float64(img.Height)
+	return []pdfcpu.OCRWord{
+		{Text: "Hello", X0: 0.1 * w, Y0: 0.1 * h, X1: 0.3 * w, Y1: 0.15 * h},
+		{Text: "pdfcpu", X0: 0.35 * w, Y0: 0.1 * h, X1: 0.6 * w, Y1: 0.15 * h},
+	}, nil
+}
+
+func TestOCRCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "testImage.pdf")
+	outFile := filepath.Join(outDir, "testImageOCR.pdf")
+
+	engine := &fakeOCR{}
+
+	if _, err := Process(OCRCommand(inFile, outFile, nil, engine, config)); err != nil {
+		t.Fatalf("TestOCRCommand: %v\n", err)
+	}
+
+	if len(engine.images) == 0 {
+		t.Fatal("TestOCRCommand: no images recognized\n")
+	}
+
+	for _, img := range engine.images {
+		if len(img.Data) == 0 || img.Width == 0 || img.Height == 0 {
+			t.Fatalf("TestOCRCommand: invalid image: %s %dx%d\n", img.FileName, img.Width, img.Height)
+		}
+	}
+
+	if _, err := Process(ValidateCommand(outFile, config)); err != nil {
+		t.Fatalf("TestOCRCommand - validate: %v\n", err)
+	}
+
+	ctx, _, _, _, err := readValidateAndOptimize(outFile, config, time.Now())
+	if err != nil {
+		t.Fatalf("TestOCRCommand: %v\n", err)
+	}
+
+	n, err := pdfcpu.HighlightText(ctx.XRefTable, nil, "pdfcpu", pdfcpu.NewTextMarkupConfig())
+	if err != nil {
+		t.Fatalf("TestOCRCommand - search: %v\n", err)
+	}
+	if n != len(engine.images) {
+		t.Fatalf("TestOCRCommand - search: %d matches, want %d\n", n, len(engine.images))
+	}
+
+	// Pages showing text are skipped.
+	engine.images = nil
+
+	if _, err := Process(OCRCommand(outFile, outFile, nil, engine, config)); err != nil {
+		t.Fatalf("TestOCRCommand - rerun: %v\n", err)
+	}
+
+	if len(engine.images) > 0 {
+		t.Fatalf("TestOCRCommand - rerun: %d images recognized, want 0\n", len(engine.images))
+	}
+}
+
+func TestImportOCRCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "testImage.pdf")
+	outFile := filepath.Join(outDir, "testImageImportOCR.pdf")
+
+	hocrFile := filepath.Join(outDir, "scan.hocr")
+	hocr := `<html><body>
+<div class='ocr_page' title='bbox 0 0 1000 1400; ppageno 0'>
+ <span class='ocrx_word' title='bbox 100 100 300 140'>Hello</span>
+ <span class='ocrx_word' title='bbox 320 100 600 140'>pdfcpu</span>
+</div>
+</body></html>`
+
+	altoFile := filepath.Join(outDir, "scan.xml")
+	alto := `<alto><Layout><Page WIDTH="1000" HEIGHT="1400">
+<String CONTENT="pdfcpu" HPOS="100" VPOS="100" WIDTH="300" HEIGHT="40"/>
+</Page></Layout></alto>`
+
+	for fn, s := range map[string]string{hocrFile: hocr, altoFile: alto} {
+		if err := ioutil.WriteFile(fn, []byte(s), 0644); err != nil {
+			t.Fatalf("TestImportOCRCommand: %v\n", err)
+		}
 	}
 
-	// Decrypt wrong upw
-	t.Log("Decrypt wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - %s decrypt using wrong upw should fail \n", outFile)
+	// hOCR to page 1 as recorded by ppageno, ALTO to the selected page 2.
+	if _, err := Process(ImportOCRCommand(inFile, hocrFile, outFile, nil, config)); err != nil {
+		t.Fatalf("TestImportOCRCommand - hOCR: %v\n", err)
 	}
 
-	// Decrypt wrong opw succeeds because of fallback to empty upw.
-	t.Log("Decrypt wrong opw succeeds because of fallback to empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if _, err := Process(ImportOCRCommand(outFile, altoFile, outFile, []string{"2"}, config)); err != nil {
+		t.Fatalf("TestImportOCRCommand - ALTO: %v\n", err)
+	}
+
+	if _, err := Process(ValidateCommand(outFile, config)); err != nil {
+		t.Fatalf("TestImportOCRCommand - validate: %v\n", err)
+	}
+
+	ctx, _, _, _, err := readValidateAndOptimize(outFile, config, time.Now())
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - %s decrypt using opw: %v\n", outFile, err)
+		t.Fatalf("TestImportOCRCommand: %v\n", err)
 	}
 
+	for _, page := range []int{1, 2} {
+		n, err := pdfcpu.HighlightText(ctx.XRefTable, pdfcpu.IntSet{page: true}, "pdfcpu", pdfcpu.NewTextMarkupConfig())
+		if err != nil {
+			t.Fatalf("TestImportOCRCommand - search: %v\n", err)
+		}
+		if n != 1 {
+			t.Fatalf("TestImportOCRCommand - search page %d: %d matches, want 1\n", page, n)
+		}
+	}
 }
 
-func TestEncrypt(t *testing.T) {
+func TestTagContent(t *testing.T) {
 
-	t.Log("running TestEncrypt..")
+	config := pdfcpu.NewDefaultConfiguration()
+	config.TagContent = true
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
-	outFile := filepath.Join(outDir, "test.pdf")
+	// structElems returns the alternate descriptions of the structure elements of fileName by structure type.
+	structElems := func(fileName string) map[string][]string {
 
-	// Encrypt opw and upw
-	t.Log("Encrypt")
-	config := pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err := Process(EncryptCommand(inFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncrypt - encrypt to %s: %v\n", outFile, err)
+		ctx, err := Read(fileName, pdfcpu.NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("TestTagContent - read %s: %v\n", fileName, err)
+		}
+
+		m := map[string][]string{}
+		visited := map[int]bool{}
+
+		var walk func(o pdfcpu.PDFObject)
+		walk = func(o pdfcpu.PDFObject) {
+			if indRef, ok := o.(pdfcpu.PDFIndirectRef); ok {
+				if visited[indRef.ObjectNumber.Value()] {
+					return
+				}
+				visited[indRef.ObjectNumber.Value()] = true
+			}
+			o, _ = ctx.Dereference(o)
+			switch o := o.(type) {
+			case pdfcpu.PDFArray:
+				for _, o1 := range o {
+					walk(o1)
+				}
+			case pdfcpu.PDFDict:
+				if s := o.NameEntry("S"); s != nil {
+					var alt string
+					if sl := o.PDFStringLiteralEntry("Alt"); sl != nil {
+						alt, _ = pdfcpu.StringLiteralToString(sl.Value())
+					}
+					m[*s] = append(m[*s], alt)
+					walk(o.Dict["K"])
+				}
+			}
+		}
+
+		if root, _ := ctx.DereferenceDict(ctx.RootDict.Dict["StructTreeRoot"]); root != nil {
+			walk(root.Dict["K"])
+		}
+
+		return m
 	}
 
-	// Validate wrong opw
-	t.Log("Validate wrong opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncrypt - validate %s using wrong opw should fail!\n", outFile)
+	contains := func(ss []string, s string) bool {
+		for _, s1 := range ss {
+			if s1 == s {
+				return true
+			}
+		}
+		return false
 	}
 
-	// Validate opw
-	t.Log("Validate opw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s using opw: %v\n", outFile, err)
+	stamp := func(inFile, outFile string) {
+		wm, err := pdfcpu.ParseWatermarkDetails("Approved", true)
+		if err != nil {
+			t.Fatalf("TestTagContent: %v\n", err)
+		}
+		if _, err = Process(AddWatermarksCommand(inFile, outFile, []string{"1-2"}, wm, config)); err != nil {
+			t.Fatalf("TestTagContent - stamp %s: %v\n", inFile, err)
+		}
+		if _, err = Process(ValidateCommand(outFile, pdfcpu.NewDefaultConfiguration())); err != nil {
+			t.Fatalf("TestTagContent - validate %s: %v\n", outFile, err)
+		}
 	}
 
-	// Validate wrong upw
-	t.Log("Validate wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncrypt - validate %s using wrong upw should fail!\n", outFile)
+	// A structure tree gets created for untagged files.
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "tagContentUntagged.pdf")
+
+	if m := structElems(inFile); len(m) > 0 {
+		t.Fatalf("TestTagContent: %s should be untagged, got %v\n", inFile, m)
 	}
 
-	// Validate upw
-	t.Log("Validate upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+	stamp(inFile, outFile)
+
+	if m := structElems(outFile); len(m["Document"]) != 1 || !contains(m["P"], "Approved") {
+		t.Fatalf("TestTagContent - untagged: %v\n", m)
 	}
 
-	// Change upw to "" = remove document open password.
-	t.Log("ChangeUserPW to \"\"")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	pwOld := "upw"
-	pwNew := ""
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err != nil {
-		t.Fatalf("TestEncrypt - %s change userPW to \"\": %v\n", outFile, err)
+	// Tagged files get extended.
+	inFile = filepath.Join(inDir, "adobe_supplement_iso32000_1.pdf")
+	outFile = filepath.Join(outDir, "tagContentTagged.pdf")
+
+	before := structElems(inFile)
+	stamp(inFile, outFile)
+
+	after := structElems(outFile)
+	if len(after["P"]) != len(before["P"])+2 || !contains(after["P"], "Approved") {
+		t.Fatalf("TestTagContent - tagged: got %d P elements, want %d\n", len(after["P"]), len(before["P"])+2)
 	}
 
-	// Validate upw
-	t.Log("Validate upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+	link := &pdfcpu.Link{Rect: types.NewRectangle(50, 700, 150, 720), Target: pdfcpu.LinkTarget{URI: "https://golang.org"}}
+	if _, err := Process(AddLinksCommand(outFile, outFile, []string{"1"}, link, config)); err != nil {
+		t.Fatalf("TestTagContent - link: %v\n", err)
 	}
 
-	// Validate no pw
-	t.Log("Validate upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s: %v\n", outFile, err)
+	if m := structElems(outFile); !contains(m["Link"], "Link to https://golang.org") {
+		t.Fatalf("TestTagContent - link: %v\n", m["Link"])
 	}
 
-	// Change opw
-	t.Log("ChangeOwnerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	pwOld = "opw"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err != nil {
-		t.Fatalf("TestEncrypt - %s change opw: %v\n", outFile, err)
+	// Flattened annotations become figures.
+	inFile = filepath.Join(inDir, "annotTest.pdf")
+	outFile = filepath.Join(outDir, "tagContentFlattened.pdf")
+
+	if _, err := Process(FlattenAnnotationsCommand(inFile, outFile, nil, []string{"Stamp"}, nil, config)); err != nil {
+		t.Fatalf("TestTagContent - flatten: %v\n", err)
 	}
 
-	// Decrypt wrong upw
-	t.Log("Decrypt wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncrypt - %s decrypt using wrong upw should fail\n", outFile)
+	if _, err := Process(ValidateCommand(outFile, pdfcpu.NewDefaultConfiguration())); err != nil {
+		t.Fatalf("TestTagContent - validate %s: %v\n", outFile, err)
 	}
 
-	// Decrypt wrong opw succeeds on empty upw
-	t.Log("Decrypt wrong opw succeeds on empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncrypt - %s decrypt wrong opw, empty upw: %v\n", outFile, err)
+	if m := structElems(outFile); len(m["Figure"]) == 0 {
+		t.Fatalf("TestTagContent - flatten: no figures: %v\n", m)
 	}
 }
 
-func encryptDecrypt(fileName string, config *pdfcpu.Configuration, t *testing.T) {
-
-	inFile := filepath.Join(inDir, fileName)
-	outFile := filepath.Join(outDir, "test.pdf")
+func TestStructTreeCommand(t *testing.T) {
 
-	t.Log(inFile)
+	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "adobe_supplement_iso32000_1.pdf")
 
-	// Encrypt
-	t.Log("Encrypt")
-	_, err := Process(EncryptCommand(inFile, outFile, config))
+	out, err := Process(ListStructTreeCommand(inFile, config))
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+		t.Fatalf("TestStructTreeCommand - list: %v\n", err)
 	}
 
-	// Encrypt already encrypted
-	t.Log("Encrypt already encrypted")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err = Process(EncryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - encrypt encrypted %s\n", outFile)
+	if len(out) == 0 || !strings.HasPrefix(out[0], "Document") || !strings.Contains(strings.Join(out, "\n"), `"ISO 32000-1"`) {
+		t.Fatalf("TestStructTreeCommand - list: unexpected structure: %v\n", out)
 	}
 
-	// Validate using wrong owner pw
-	t.Log("Validate wrong ownerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - validate %s using wrong ownerPW: %v\n", outFile, err)
+	jsonFile := filepath.Join(outDir, "structTree.json")
+	if _, err = Process(ExportStructTreeCommand(inFile, jsonFile, config)); err != nil {
+		t.Fatalf("TestStructTreeCommand - export JSON: %v\n", err)
 	}
 
-	// Optimize using wrong owner pw
-	t.Log("Optimize wrong ownerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	bb, err := ioutil.ReadFile(jsonFile)
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - optimize %s using wrong ownerPW: %v\n", outFile, err)
+		t.Fatalf("TestStructTreeCommand: %v\n", err)
 	}
 
-	// Trim using wrong owner pw, falls back to upw and fails with insufficient permissions.
-	t.Log("Trim wrong ownerPW, fallback to upw and fail with insufficient permissions.")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	// pageSelection = nil, writes w/o trimming anything, but sufficient for testing.
-	_, err = Process(TrimCommand(outFile, outFile, nil, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - trim %s using wrong ownerPW should fail: \n", outFile)
+	var nodes []*pdfcpu.StructNode
+	if err = json.Unmarshal(bb, &nodes); err != nil || len(nodes) != 1 || nodes[0].Type != "Document" {
+		t.Fatalf("TestStructTreeCommand - export JSON: invalid structure: %v\n", err)
 	}
 
-	// Split using wrong owner pw, falls back to upw and fails with insufficient permissions.
-	t.Log("Split wrong ownerPW, fallback to upw and fail with insufficient permissions.")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(SplitCommand(outFile, outDir, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW should fail: \n", outFile)
+	htmlFile := filepath.Join(outDir, "structTree.html")
+	if _, err = Process(ExportStructTreeCommand(inFile, htmlFile, config)); err != nil {
+		t.Fatalf("TestStructTreeCommand - export HTML: %v\n", err)
 	}
 
-	// Add permissions
-	t.Log("Add user access permissions")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	config.UserAccessPermissions = pdfcpu.PermissionsAll
-	_, err = Process(AddPermissionsCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - %s add permissions: %v\n", outFile, err)
+	if bb, err = ioutil.ReadFile(htmlFile); err != nil {
+		t.Fatalf("TestStructTreeCommand: %v\n", err)
 	}
 
-	// Split using wrong owner pw, falls back to upw
-	t.Log("Split wrong ownerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(SplitCommand(outFile, outDir, config))
-	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW: %v\n", outFile, err)
+	s := string(bb)
+	for _, want := range []string{"<title>Adobe Extensions to ISO 32000-1:2008, Level 5</title>", "<p>ISO 32000-1</p>", "<table>", "<td>"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("TestStructTreeCommand - export HTML: missing %s\n", want)
+		}
 	}
 
-	// Validate
-	t.Log("Validate")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - validate %s: %v\n", outFile, err)
+	// Untagged files have no logical structure.
+	if _, err = Process(ListStructTreeCommand(filepath.Join(inDir, "5116.DCT_Filter.pdf"), config)); err == nil {
+		t.Fatalf("TestStructTreeCommand - list untagged: want error\n")
 	}
+}
 
-	// ChangeUserPW using wrong userpw
-	t.Log("ChangeUserPW wrong userpw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	pwOld := "upwWrong"
-	pwNew := "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryption - %s change userPW using wrong userPW should fail:\n", outFile)
+func TestRepairTruncatedFile(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+
+	b, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		t.Fatalf("TestRepairTruncatedFile: %v\n", err)
 	}
 
-	// ChangeUserPW
-	t.Log("ChangeUserPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	pwOld = "upw"
-	pwNew = "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	// Append an incremental update.
+	ctx, err := Read(inFile, config)
 	if err != nil {
-		t.Fatalf("TestEncryption - change userPW %s: %v\n", outFile, err)
+		t.Fatalf("TestRepairTruncatedFile - read: %v\n", err)
 	}
 
-	// ChangeOwnerPW
-	t.Log("ChangeOwnerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwNew"
-	pwOld = "opw"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	snap := ctx.Snapshot()
+	ctx.RootDict.Update("Lang", pdfcpu.PDFStringLiteral("x-update"))
+
+	bb, err := pdfcpu.WriteIncrement(ctx, b, ctx.ModifiedObjects(snap))
 	if err != nil {
-		t.Fatalf("TestEncryption - change ownerPW %s: %v\n", outFile, err)
+		t.Fatalf("TestRepairTruncatedFile - write increment: %v\n", err)
 	}
 
-	// Decrypt using wrong pw
-	t.Log("\nDecrypt using wrong pw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - decrypt using wrong pw %s\n", outFile)
+	for _, c := range []struct {
+		name     string
+		b        []byte
+		revision bool // The original revision is complete.
+	}{
+		{"truncated update", bb[:len(b)+(len(bb)-len(b))/2], true},
+		{"truncated file", b[:len(b)*2/3], false},
+	} {
+
+		damagedFile := filepath.Join(outDir, "damaged.pdf")
+		if err = ioutil.WriteFile(damagedFile, c.b, 0644); err != nil {
+			t.Fatalf("TestRepairTruncatedFile: %v\n", err)
+		}
+
+		if _, err = Process(ValidateCommand(damagedFile, pdfcpu.NewDefaultConfiguration())); err == nil && !c.revision {
+			t.Fatalf("TestRepairTruncatedFile - %s: validation should fail\n", c.name)
+		}
+
+		outFile := filepath.Join(outDir, "repaired.pdf")
+
+		report, err := Process(RepairCommand(damagedFile, outFile, pdfcpu.NewDefaultConfiguration()))
+		if err != nil {
+			t.Fatalf("TestRepairTruncatedFile - %s: %v\n", c.name, err)
+		}
+
+		if len(report) == 0 || !strings.HasPrefix(report[0], "truncated file") {
+			t.Fatalf("TestRepairTruncatedFile - %s: missing damage report: %v\n", c.name, report)
+		}
+
+		if _, err = Process(ValidateCommand(outFile, pdfcpu.NewDefaultConfiguration())); err != nil {
+			t.Fatalf("TestRepairTruncatedFile - %s: validate repaired file: %v\n", c.name, err)
+		}
+
+		ctx, err := Read(outFile, pdfcpu.NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("TestRepairTruncatedFile - %s: %v\n", c.name, err)
+		}
+
+		if ctx.Read.Repaired {
+			t.Fatalf("TestRepairTruncatedFile - %s: repaired file needs repair\n", c.name)
+		}
+
+		if lang := ctx.RootDict.PDFStringLiteralEntry("Lang"); lang != nil && *lang == "x-update" {
+			t.Fatalf("TestRepairTruncatedFile - %s: incomplete update applied\n", c.name)
+		}
+
+		t.Logf("%s: %v\n", c.name, report)
 	}
+}
 
-	// Decrypt
-	t.Log("\nDecrypt")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwNew"
-	config.OwnerPW = "opwNew"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - decrypt %s: %v\n", outFile, err)
+func TestMemoryLimits(t *testing.T) {
+
+	// An attachment of 8MB zeros takes up a few KB Flate encoded.
+	zeroFile := filepath.Join(outDir, "zeros.bin")
+	if err := ioutil.WriteFile(zeroFile, make([]byte, 8<<20), 0644); err != nil {
+		t.Fatalf("TestMemoryLimits: %v\n", err)
 	}
 
-}
+	inFile := filepath.Join(outDir, "bomb.pdf")
+	if err := copyFile(filepath.Join(inDir, "go.pdf"), inFile); err != nil {
+		t.Fatalf("TestMemoryLimits: %v\n", err)
+	}
 
-func TestEncryptDecrypt(t *testing.T) {
+	if err := AddAttachments(inFile, []string{zeroFile}, pdfcpu.NewDefaultConfiguration()); err != nil {
+		t.Fatalf("TestMemoryLimits - add attachment: %v\n", err)
+	}
 
-	config := pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	encryptDecrypt("5116.DCT_Filter.pdf", config, t)
+	for _, limit := range []string{"MaxStreamSize", "MaxDecodedSize"} {
 
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	config.EncryptUsingAES = false
-	config.EncryptUsing128BitKey = false
-	encryptDecrypt("networkProgr.pdf", config, t)
-}
+		config := pdfcpu.NewDefaultConfiguration()
+		if limit == "MaxStreamSize" {
+			config.MaxStreamSize = 1 << 20
+		} else {
+			config.MaxDecodedSize = 1 << 20
+		}
 
-func copyFile(srcFileName, destFileName string) (err error) {
+		err := ExtractAttachments(inFile, outDir, nil, config)
+		if !pdfcpu.IsMemoryLimitError(err) {
+			t.Fatalf("TestMemoryLimits - %s: expected memory limit error, got: %v\n", limit, err)
+		}
 
-	from, err := os.Open(srcFileName)
-	if err != nil {
-		return
+		if e := errors.Cause(err).(*pdfcpu.MemoryLimitError); e.Limit != limit {
+			t.Fatalf("TestMemoryLimits - %s: unexpected limit: %s\n", limit, e.Limit)
+		}
 	}
-	defer from.Close()
 
-	to, err := os.OpenFile(destFileName, os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
-		return
+	if err := ExtractAttachments(inFile, outDir, nil, pdfcpu.NewDefaultConfiguration()); err != nil {
+		t.Fatalf("TestMemoryLimits - extract attachment: %v\n", err)
 	}
-	defer to.Close()
 
-	_, err = io.Copy(to, from)
+	// Reject images larger than 10x10 pixels.
+	config := pdfcpu.NewDefaultConfiguration()
+	config.MaxImagePixels = 100
 
-	return
+	_, err := Process(ExtractImagesCommand(filepath.Join(inDir, "pike-stanford.pdf"), outDir, nil, config))
+	if !pdfcpu.IsMemoryLimitError(err) {
+		t.Fatalf("TestMemoryLimits - extract images: expected memory limit error, got: %v\n", err)
+	}
 }
 
-func prepareForAttachmentTest() (err error) {
+func TestJSONCommand(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	for _, fileName := range []string{"go.pdf", "Hybrid-PDF.pdf", "annotTest.pdf"} {
 
-	for _, fileName := range []string{"go.pdf", "golang.pdf", "T4.pdf", "go-lecture.pdf", "test.wav"} {
 		inFile := filepath.Join(inDir, fileName)
-		outFile := filepath.Join(outDir, fileName)
-		err = copyFile(inFile, outFile)
+
+		ctxIn, err := Read(inFile, config)
 		if err != nil {
-			return
+			t.Fatalf("TestJSONCommand: %s: %v\n", fileName, err)
 		}
-	}
 
-	return
-}
+		if err = pdfcpu.ValidateXRefTable(ctxIn.XRefTable); err != nil {
+			t.Fatalf("TestJSONCommand: %s: %v\n", fileName, err)
+		}
 
-func testAttachmentsStage1(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+		for _, external := range []bool{false, true} {
 
-	// attach list must be 0
-	list, err := Process(ListAttachmentsCommand(fileName, config))
-	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
-	}
-	if len(list) > 0 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
-	}
+			base := fmt.Sprintf("%s_%t", strings.TrimSuffix(fileName, ".pdf"), external)
+			jsonFile := filepath.Join(outDir, base+".json")
+			pdfFile := filepath.Join(outDir, base+".pdf")
 
-	// attach add 4 files
-	_, err = Process(AddAttachmentsCommand(fileName,
-		[]string{outDir + "/golang.pdf",
-			outDir + "/T4.pdf",
-			outDir + "/go-lecture.pdf",
-			outDir + "/test.wav"},
-		config))
+			if _, err = Process(ExportJSONCommand(inFile, jsonFile, external, config)); err != nil {
+				t.Fatalf("TestJSONCommand - export %s: %v\n", base, err)
+			}
 
-	if err != nil {
-		t.Fatalf("TestAttachments - add attachments to %s: %v\n", fileName, err)
-	}
+			bb, err := ioutil.ReadFile(jsonFile)
+			if err != nil {
+				t.Fatalf("TestJSONCommand: %v\n", err)
+			}
 
-	// attach list must be 4
-	list, err = Process(ListAttachmentsCommand(fileName, config))
-	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
-	}
-	if len(list) != 4 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 4 attachments\n", fileName)
-	}
-	for _, s := range list {
-		t.Log(s)
-	}
+			var f pdfcpu.JSONFile
+			if err = json.Unmarshal(bb, &f); err != nil {
+				t.Fatalf("TestJSONCommand - export %s: %v\n", base, err)
+			}
 
-}
+			var streams, files int
+			for _, o := range f.Objects {
+				if o.Stream != nil {
+					streams++
+					if o.Stream.File != "" {
+						files++
+					}
+				}
+			}
 
-func testAttachmentsStage2(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+			if streams == 0 || external != (files == streams) {
+				t.Errorf("TestJSONCommand - export %s: %d streams, %d stream files\n", base, streams, files)
+			}
 
-	// attach extract all
-	_, err := Process(ExtractAttachmentsCommand(fileName, outDir, nil, config))
-	if err != nil {
-		t.Fatalf("TestAttachments - extract all attachments from %s to %s: %v\n", fileName, ".", err)
-	}
+			if _, err = Process(ImportJSONCommand(jsonFile, pdfFile, config)); err != nil {
+				t.Fatalf("TestJSONCommand - import %s: %v\n", base, err)
+			}
 
-	// attach extract 1 file
-	_, err = Process(ExtractAttachmentsCommand(fileName, outDir, []string{"golang.pdf"}, config))
-	if err != nil {
-		t.Fatalf("TestAttachments - extract 1 attachment from %s to %s: %v\n", fileName, ".", err)
+			ctx, err := Read(pdfFile, config)
+			if err != nil {
+				t.Fatalf("TestJSONCommand - read %s: %v\n", base, err)
+			}
+
+			if err = pdfcpu.ValidateXRefTable(ctx.XRefTable); err != nil {
+				t.Fatalf("TestJSONCommand - validate %s: %v\n", base, err)
+			}
+
+			if ctx.PageCount != ctxIn.PageCount {
+				t.Errorf("TestJSONCommand - %s: got %d pages, want %d\n", base, ctx.PageCount, ctxIn.PageCount)
+			}
+		}
 	}
 
-	// attach remove 1 file
-	_, err = Process(RemoveAttachmentsCommand(fileName, []string{"golang.pdf"}, config))
+	// Surgery: Rename the document using the JSON representation.
+	jsonFile := filepath.Join(outDir, "go_false.json")
+
+	bb, err := ioutil.ReadFile(jsonFile)
 	if err != nil {
-		t.Fatalf("TestAttachments - remove attachment from %s: %v\n", fileName, err)
+		t.Fatalf("TestJSONCommand: %v\n", err)
 	}
 
-	// attach list must be 3
-	list, err := Process(ListAttachmentsCommand(fileName, config))
-	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	i := bytes.Index(bb, []byte(`"Title": "(`))
+	if i < 0 {
+		t.Fatalf("TestJSONCommand: missing title\n")
 	}
-	if len(list) != 3 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 3 attachments\n", fileName)
+
+	j := i + bytes.Index(bb[i:], []byte(`)"`))
+	bb = append(append(append([]byte{}, bb[:i]...), `"Title": "(Surgery`...), bb[j:]...)
+
+	if err = ioutil.WriteFile(jsonFile, bb, os.ModePerm); err != nil {
+		t.Fatalf("TestJSONCommand: %v\n", err)
 	}
 
-	// attach remove all
-	_, err = Process(RemoveAttachmentsCommand(fileName, nil, config))
-	if err != nil {
-		t.Fatalf("TestAttachments - remove all attachment from %s: %v\n", fileName, err)
+	pdfFile := filepath.Join(outDir, "go_surgery.pdf")
+	if _, err = Process(ImportJSONCommand(jsonFile, pdfFile, config)); err != nil {
+		t.Fatalf("TestJSONCommand - import: %v\n", err)
 	}
 
-	// attach list must be 0.
-	list, err = Process(ListAttachmentsCommand(fileName, config))
+	ctx, err := Read(pdfFile, config)
 	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+		t.Fatalf("TestJSONCommand - read: %v\n", err)
 	}
-	t.Log(list)
-	if len(list) > 0 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+
+	d, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil || d == nil || d.StringEntry("Title") == nil || *d.StringEntry("Title") != "Surgery" {
+		t.Errorf("TestJSONCommand: title not changed: %v\n", d)
 	}
 }
 
-func TestAttachments(t *testing.T) {
-
-	err := prepareForAttachmentTest()
-	if err != nil {
-		t.Fatalf("prepare for attachments: %v\n", err)
-	}
+func TestDiffCommand(t *testing.T) {
 
 	config := pdfcpu.NewDefaultConfiguration()
+	inFile := filepath.Join(inDir, "Hybrid-PDF.pdf")
 
-	fileName := filepath.Join(outDir, "go.pdf")
-
-	testAttachmentsStage1(fileName, config, t)
-	testAttachmentsStage2(fileName, config, t)
-}
+	out, err := Process(DiffCommand(inFile, inFile, false, config))
+	if err != nil || len(out) > 0 {
+		t.Fatalf("TestDiffCommand - same file: %v %v\n", out, err)
+	}
 
-func TestListPermissionsCommand(t *testing.T) {
+	// Recompressed and renumbered objects do not make a difference.
+	optFile := filepath.Join(outDir, "diffOptimized.pdf")
+	if _, err = Process(OptimizeCommand(inFile, optFile, config)); err != nil {
+		t.Fatalf("TestDiffCommand - optimize: %v\n", err)
+	}
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
-	outFile := filepath.Join(outDir, "test.pdf")
+	r, err := Diff(inFile, optFile, config)
+	if err != nil || !r.Equal() {
+		t.Fatalf("TestDiffCommand - optimized: %v %v\n", r.Lines(), err)
+	}
 
-	_, err := Process(ListPermissionsCommand(inFile, pdfcpu.NewDefaultConfiguration()))
+	wm, err := pdfcpu.ParseWatermarkDetails("Draft", true)
 	if err != nil {
-		t.Fatalf("TestListPermissionsCommand: for unencrypted %s: %v\n", inFile, err)
+		t.Fatalf("TestDiffCommand: %v\n", err)
 	}
 
-	config := pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err = Process(EncryptCommand(inFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+	stampFile := filepath.Join(outDir, "diffStamped.pdf")
+	if _, err = Process(AddWatermarksCommand(inFile, stampFile, nil, wm, config)); err != nil {
+		t.Fatalf("TestDiffCommand - stamp: %v\n", err)
 	}
 
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err = Process(ListPermissionsCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestListPermissionsCommand: for encrypted %s: %v\n", outFile, err)
+	out, err = Process(DiffCommand(inFile, stampFile, true, config))
+	if err != nil || len(out) != 1 {
+		t.Fatalf("TestDiffCommand - stamped: %v %v\n", out, err)
 	}
 
-}
+	if err = json.Unmarshal([]byte(out[0]), &r); err != nil {
+		t.Fatalf("TestDiffCommand - stamped: %v\n", err)
+	}
 
-func TestUnknownCommand(t *testing.T) {
+	if r.Equal() || len(r.Pages) != 1 || r.Pages[0].PageNr != 1 {
+		t.Fatalf("TestDiffCommand - stamped: unexpected differences: %s\n", out[0])
+	}
 
-	config := pdfcpu.NewDefaultConfiguration()
-	inFile := filepath.Join(outDir, "go.pdf")
+	if p := r.Pages[0]; len(p.Text) != 1 || p.Text[0] != "+Draft" || len(p.Objects) == 0 {
+		t.Errorf("TestDiffCommand - stamped: unexpected page differences: %v %v\n", p.Objects, p.Text)
+	}
 
-	cmd := &Command{
-		Mode:   99,
-		InFile: &inFile,
-		Config: config}
+	inFile = filepath.Join(inDir, "go.pdf")
+	trimFile := filepath.Join(outDir, "diffTrimmed.pdf")
+	if _, err = Process(TrimCommand(inFile, trimFile, []string{"1-2"}, config)); err != nil {
+		t.Fatalf("TestDiffCommand - trim: %v\n", err)
+	}
 
-	_, err := Process(cmd)
-	if err == nil {
-		t.Fatal("TestUnknowncommand - should have failed")
+	if r, err = Diff(inFile, trimFile, config); err != nil {
+		t.Fatalf("TestDiffCommand - trimmed: %v\n", err)
 	}
 
+	if r.PageCount != [2]int{23, 2} || len(r.Lines()) == 0 || r.Lines()[0] != "page count: 23 => 2" {
+		t.Errorf("TestDiffCommand - trimmed: %v\n", r.Lines())
+	}
 }
 
-func xxxTestDemoXRef(t *testing.T) {
+func TestBatchCommand(t *testing.T) {
 
-	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
-	if err != nil {
-		t.Fatalf("testDemoXRef %v\n", err)
-	}
-	if xRefTable == nil {
-		t.Fatal("testDemoXRef: xRefTable == nil")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	batchInDir := filepath.Join(outDir, "batchIn")
+	batchOutDir := filepath.Join(outDir, "batchOut")
+
+	for _, f := range []string{"go.pdf", "T4.pdf", filepath.Join("sub", "Paclitaxel.PDF"), filepath.Join("sub", "test.wav")} {
+		fileOut := filepath.Join(batchInDir, f)
+		if err := os.MkdirAll(filepath.Dir(fileOut), os.ModePerm); err != nil {
+			t.Fatalf("TestBatchCommand: %v\n", err)
+		}
+		if err := copyFile(filepath.Join(inDir, filepath.Base(f)), fileOut); err != nil {
+			t.Fatalf("TestBatchCommand: %v\n", err)
+		}
 	}
 
-	err = pdfcpu.ValidateXRefTable(xRefTable)
+	cmds, err := BatchCommands([]string{"validate", "optimize"}, false, config)
 	if err != nil {
-		t.Fatalf("testDemoXRef %v\n", err)
+		t.Fatalf("TestBatchCommand: %v\n", err)
 	}
 
-	// var logStr []string
-	// logStr = xRefTable.List(logStr)
-	// t.Logf("XRefTable:\n%s\n", strings.Join(logStr, ""))
-}
+	bc := &BatchConfig{Recursive: true, Jobs: 2}
+	if _, err = Process(BatchCommand(batchInDir, batchOutDir, cmds, bc, config)); err != nil {
+		t.Fatalf("TestBatchCommand: %v\n", err)
+	}
 
-func TestCreateDemoPDF(t *testing.T) {
+	for _, f := range []string{"go.pdf", "T4.pdf", filepath.Join("sub", "Paclitaxel.PDF")} {
+		if _, err = os.Stat(filepath.Join(batchOutDir, f)); err != nil {
+			t.Fatalf("TestBatchCommand: %v\n", err)
+		}
+	}
 
-	xRefTable, err := pdfcpu.CreateDemoXRef()
-	if err != nil {
-		t.Fatalf("testCreateDemoPDF %v\n", err)
+	if _, err = os.Stat(filepath.Join(batchOutDir, "sub", "test.wav")); err == nil {
+		t.Fatal("TestBatchCommand: test.wav should not have been processed")
 	}
 
-	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "demo.pdf")
+	// Treat everything as PDF and keep going after test.wav failed.
+	bc = &BatchConfig{Patterns: []string{"*"}, Recursive: true, ContinueOnError: true}
+	r, err := Batch(batchInDir, batchOutDir, cmds, bc)
 	if err != nil {
-		t.Fatalf("testCreateDemoPDF %v\n", err)
+		t.Fatalf("TestBatchCommand - continue: %v\n", err)
+	}
+	if len(r.Results) != 4 || r.Failed() != 1 || r.Skipped() != 0 {
+		t.Fatalf("TestBatchCommand - continue: %v\n", r.Summary())
 	}
 
-	config := pdfcpu.NewDefaultConfiguration()
-	config.ValidationMode = pdfcpu.ValidationRelaxed
+	bc.ContinueOnError = false
+	if _, err = Batch(batchInDir, batchOutDir, cmds, bc); err == nil {
+		t.Fatal("TestBatchCommand - stop: missing error")
+	}
 
-	outFile := filepath.Join(outDir, "demo.pdf")
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("testCreateDemoPDF %v\n", err)
+	if _, err = BatchCommands([]string{"split"}, false, config); err == nil {
+		t.Fatal("TestBatchCommand - split: missing error")
 	}
 }
 
-func TestAnnotationDemoPDF(t *testing.T) {
+func TestPipeline(t *testing.T) {
 
-	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
+	config := pdfcpu.NewDefaultConfiguration()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "pipeline.pdf")
+
+	ctx, err := Open(inFile, config).Context()
 	if err != nil {
-		t.Fatalf("testAnnotationDemoPDF %v\n", err)
+		t.Fatalf("TestPipeline: %v\n", err)
 	}
+	pageCount := ctx.PageCount
 
-	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "annotationDemo.pdf")
+	wm, err := pdfcpu.ParseWatermarkDetails("Draft, s:0.7, r:20", true)
 	if err != nil {
-		t.Fatalf("testAnnotationDemoPDF %v\n", err)
+		t.Fatalf("TestPipeline: %v\n", err)
 	}
 
-	config := pdfcpu.NewDefaultConfiguration()
-	config.ValidationMode = pdfcpu.ValidationRelaxed
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
 
-	outFile := filepath.Join(outDir, "annotationDemo.pdf")
-	_, err = Process(ValidateCommand(outFile, config))
+	err = Open(inFile, config).
+		RemovePages([]string{"1", "3"}).
+		AddWatermarks([]string{"1"}, wm).
+		Optimize().
+		Encrypt().
+		Write(outFile)
 	if err != nil {
-		t.Fatalf("testAnnotationDemoPDF %v\n", err)
+		t.Fatalf("TestPipeline: %v\n", err)
 	}
 
-}
-
-func TestAcroformDemoPDF(t *testing.T) {
+	// Encrypting an encrypted file requires decryption first.
+	if err = Open(outFile, config).Encrypt().Err(); !errors.Is(err, pdfcpu.ErrEncrypted) {
+		t.Fatalf("TestPipeline - encrypt twice: %v\n", err)
+	}
 
-	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
-	if err != nil {
-		t.Fatalf("testAcroformDemoPDF %v\n", err)
+	var buf bytes.Buffer
+	if err = Open(outFile, config).Decrypt().WriteContext(&buf); err != nil {
+		t.Fatalf("TestPipeline - decrypt: %v\n", err)
 	}
 
-	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "acroFormDemo.pdf")
+	ctx, err = OpenContext(bytes.NewReader(buf.Bytes()), pdfcpu.NewDefaultConfiguration()).Context()
 	if err != nil {
-		t.Fatalf("testAcroformDemoPDF %v\n", err)
+		t.Fatalf("TestPipeline - read decrypted: %v\n", err)
 	}
 
-	config := pdfcpu.NewDefaultConfiguration()
-	config.ValidationMode = pdfcpu.ValidationRelaxed
-
-	outFile := filepath.Join(outDir, "acroFormDemo.pdf")
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("testAcroformDemoPDF %v\n", err)
+	if ctx.Encrypt != nil || ctx.PageCount != pageCount-2 {
+		t.Fatalf("TestPipeline: encrypted:%t pageCount:%d\n", ctx.Encrypt != nil, ctx.PageCount)
 	}
 
+	if err = Open(inFile, config).RemovePages([]string{"1-"}).Write(outFile); err == nil {
+		t.Fatal("TestPipeline - remove all pages: missing error")
+	}
 }
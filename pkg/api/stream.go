@@ -0,0 +1,382 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+// io.Reader and io.Writer based variants of the file based commands
+// for applications that process PDFs in memory.
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+
+	"github.com/pkg/errors"
+)
+
+// PageWriterFunc returns the writer receiving the single page PDF generated for page pageNr.
+// Writers implementing io.Closer get closed once the page has been written.
+type PageWriterFunc func(pageNr int) (io.Writer, error)
+
+// ExtractWriterFunc returns the writer receiving the data of object objNr extracted for page pageNr.
+// name is the file name used by the corresponding file based command.
+// Writers implementing io.Closer get closed once the data has been written.
+type ExtractWriterFunc func(pageNr, objNr int, name string) (io.Writer, error)
+
+// closeWriter closes w if w is an io.Closer.
+func closeWriter(w io.Writer, err error) error {
+
+	c, ok := w.(io.Closer)
+	if !ok {
+		return err
+	}
+
+	// Processing error takes precedence.
+	if err1 := c.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
+
+// nopCloser turns an io.Writer into an io.WriteCloser whose Close does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// writeCloser returns w as io.WriteCloser.
+func writeCloser(w io.Writer) io.WriteCloser {
+
+	if wc, ok := w.(io.WriteCloser); ok {
+		return wc
+	}
+
+	return nopCloser{w}
+}
+
+func writeExtracted(f ExtractWriterFunc, pageNr, objNr int, name string, b []byte) error {
+
+	w, err := f(pageNr, objNr, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return closeWriter(w, err)
+}
+
+// fileWriter returns an ExtractWriterFunc creating files in dirOut using config.CreateFile.
+func fileWriter(dirOut string, config *pdfcpu.Configuration) ExtractWriterFunc {
+	return func(pageNr, objNr int, name string) (io.Writer, error) {
+		return config.CreateOutput(filepath.Join(dirOut, name))
+	}
+}
+
+// ReadContext reads in a PDF from rs and builds an internal structure holding its cross reference table aka the PDFContext.
+func ReadContext(rs io.ReadSeeker, config *pdfcpu.Configuration) (*pdfcpu.PDFContext, error) {
+
+	ctx, err := pdfcpu.Read(rs, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "Read failed.")
+	}
+
+	return ctx, nil
+}
+
+// WriteContext generates a PDF for a given PDFContext and writes it to w.
+func WriteContext(ctx *pdfcpu.PDFContext, w io.Writer) error {
+
+	err := pdfcpu.WritePDF(ctx, w)
+	if err != nil {
+		return errors.Wrap(err, "Write failed.")
+	}
+
+	return nil
+}
+
+func readValidateAndOptimizeContext(rs io.ReadSeeker, config *pdfcpu.Configuration) (*pdfcpu.PDFContext, error) {
+
+	ctx, err := ReadContext(rs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pdfcpu.ValidateXRefTable(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pdfcpu.OptimizeXRefTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
+
+// ValidateContext validates the PDF read from rs against ISO-32000-1:2008.
+// The result lists the violations of validation rules set to warn by the validation policy.
+func ValidateContext(rs io.ReadSeeker, config *pdfcpu.Configuration) ([]string, error) {
+
+	ctx, err := ReadContext(rs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pdfcpu.ValidateXRefTable(ctx.XRefTable)
+	if err != nil {
+		return ctx.Warnings, errors.Wrap(err, "validation error (try -mode=relaxed)")
+	}
+
+	return ctx.Warnings, nil
+}
+
+// OptimizeContext reads a PDF from rs, does validation, optimization and writes the result to w.
+func OptimizeContext(rs io.ReadSeeker, w io.Writer, config *pdfcpu.Configuration) error {
+
+	ctx, err := readValidateAndOptimizeContext(rs, config)
+	if err != nil {
+		return err
+	}
+
+	err = WriteContext(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// MergeContext merges the PDFs read from rss in the given order and writes the result to w.
+func MergeContext(rss []io.ReadSeeker, w io.Writer, config *pdfcpu.Configuration) error {
+
+	if len(rss) == 0 {
+		return errors.New("merge: missing input")
+	}
+
+	ctxDest, err := ReadContext(rss[0], config)
+	if err != nil {
+		return err
+	}
+
+	err = pdfcpu.ValidateXRefTable(ctxDest.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	if ctxDest.XRefTable.Version() < pdfcpu.V15 {
+		v, _ := pdfcpu.Version("1.5")
+		ctxDest.XRefTable.RootVersion = &v
+		log.Stats.Println("Ensure V1.5 for writing object & xref streams")
+	}
+
+	for i, rs := range rss[1:] {
+
+		log.Stats.Printf("MergeContext: appending input #%d\n", i+2)
+
+		ctxSource, err := ReadContext(rs, config)
+		if err != nil {
+			return err
+		}
+
+		err = pdfcpu.ValidateXRefTable(ctxSource.XRefTable)
+		if err != nil {
+			return err
+		}
+
+		err = pdfcpu.MergeXRefTables(ctxSource, ctxDest)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = pdfcpu.OptimizeXRefTable(ctxDest)
+	if err != nil {
+		return err
+	}
+
+	err = pdfcpu.ValidateXRefTable(ctxDest.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	ctxDest.Write.Command = "Merge"
+
+	return WriteContext(ctxDest, w)
+}
+
+func writeSinglePagePDFContexts(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet, f PageWriterFunc) error {
+
+	ensureSelectedPages(ctx, &selectedPages)
+
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+
+		if !selectedPages[pageNr] {
+			continue
+		}
+
+		w, err := f(pageNr)
+		if err != nil {
+			return err
+		}
+
+		ctx.ResetWriteContext()
+		ctx.Write.Command = "Split"
+		ctx.Write.ExtractPageNr = pageNr
+
+		err = closeWriter(w, WriteContext(ctx, w))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSplitPages writes a single page PDF for every page of src to the writer returned by f.
+func writeSplitPages(src *pdfcpu.SplitSource, f PageWriterFunc) error {
+
+	for pageNr := 1; pageNr <= src.PageCount(); pageNr++ {
+
+		w, err := f(pageNr)
+		if err != nil {
+			return err
+		}
+
+		err = closeWriter(w, src.WritePages(w, pageNr, pageNr))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SplitContext generates a single page PDF for every page of the PDF read from rs
+// and writes it to the writer returned by f.
+// Objects get read from rs on demand page by page.
+func SplitContext(rs io.ReadSeeker, config *pdfcpu.Configuration, f PageWriterFunc) error {
+
+	src, err := pdfcpu.NewSplitSource("", rs, config)
+	if err != nil {
+		return err
+	}
+
+	return writeSplitPages(src, f)
+}
+
+// ExtractPagesContext generates single page PDFs for selected pages of the PDF read from rs
+// and writes them to the writers returned by f.
+func ExtractPagesContext(rs io.ReadSeeker, pageSelection []string, config *pdfcpu.Configuration, f PageWriterFunc) error {
+
+	ctx, err := readValidateAndOptimizeContext(rs, config)
+	if err != nil {
+		return err
+	}
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	return writeSinglePagePDFContexts(ctx, pages, f)
+}
+
+// ExtractImagesContext extracts embedded images for selected pages of the PDF read from rs
+// and writes them to the writers returned by f.
+func ExtractImagesContext(rs io.ReadSeeker, pageSelection []string, config *pdfcpu.Configuration, f ExtractWriterFunc) error {
+
+	ctx, err := readValidateAndOptimizeContext(rs, config)
+	if err != nil {
+		return err
+	}
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	return doExtractImages(ctx, pages, f)
+}
+
+// AddWatermarksContext adds wm to selected pages of the PDF read from rs and writes the result to w.
+func AddWatermarksContext(rs io.ReadSeeker, w io.Writer, pageSelection []string, wm *pdfcpu.Watermark, config *pdfcpu.Configuration) error {
+
+	ctx, err := readValidateAndOptimizeContext(rs, config)
+	if err != nil {
+		return err
+	}
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	if err = pdfcpu.AddWatermarks(ctx.XRefTable, pages, wm); err != nil {
+		return err
+	}
+
+	return WriteContext(ctx, w)
+}
+
+// ExtractFontsContext extracts embedded fontfiles for selected pages of the PDF read from rs
+// and writes them to the writers returned by f.
+func ExtractFontsContext(rs io.ReadSeeker, pageSelection []string, config *pdfcpu.Configuration, f ExtractWriterFunc) error {
+
+	ctx, err := readValidateAndOptimizeContext(rs, config)
+	if err != nil {
+		return err
+	}
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	return doExtractFonts(ctx, pages, f)
+}
+
+// ExtractContentContext extracts "PDF source" for selected pages of the PDF read from rs
+// and writes it to the writers returned by f.
+func ExtractContentContext(rs io.ReadSeeker, pageSelection []string, config *pdfcpu.Configuration, f ExtractWriterFunc) error {
+
+	ctx, err := readValidateAndOptimizeContext(rs, config)
+	if err != nil {
+		return err
+	}
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	return doExtractContent(ctx, pages, f)
+}
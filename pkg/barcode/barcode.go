@@ -0,0 +1,61 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package barcode implements encoders for the linear barcodes Code 128 (ISO/IEC 15417)
+// and EAN-13/EAN-8 (ISO/IEC 15420).
+//
+// A symbol is a sequence of modules of equal width, dark modules forming the bars.
+package barcode
+
+// Code represents an encoded linear barcode symbol.
+type Code struct {
+	Symbology  string // Code128, EAN13 or EAN8
+	Text       string // human readable text including any check digit
+	QuietLeft  int    // light modules required left of the symbol
+	QuietRight int    // light modules required right of the symbol
+
+	modules []bool // true for dark modules
+}
+
+// Width returns the number of modules excluding the quiet zones.
+func (c *Code) Width() int {
+	return len(c.modules)
+}
+
+// Black returns true if module x is dark.
+// Modules outside the symbol are treated as light (quiet zone).
+func (c *Code) Black(x int) bool {
+	if x < 0 || x >= len(c.modules) {
+		return false
+	}
+	return c.modules[x]
+}
+
+// appendWidths appends alternating bars and spaces of the given widths in modules starting with a bar.
+func (c *Code) appendWidths(widths string) {
+	for i, w := range widths {
+		for j := 0; j < int(w-'0'); j++ {
+			c.modules = append(c.modules, i%2 == 0)
+		}
+	}
+}
+
+// appendBits appends modules for a pattern of '1' (dark) and '0' (light).
+func (c *Code) appendBits(bits string) {
+	for _, b := range bits {
+		c.modules = append(c.modules, b == '1')
+	}
+}
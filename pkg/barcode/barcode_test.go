@@ -0,0 +1,143 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package barcode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bits renders the modules of c as '1' and '0'.
+func bits(c *Code) string {
+	var sb strings.Builder
+	for x := 0; x < c.Width(); x++ {
+		if c.Black(x) {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+	return sb.String()
+}
+
+func TestCode128Patterns(t *testing.T) {
+
+	for v, p := range code128Patterns {
+		n := 0
+		for _, w := range p {
+			n += int(w - '0')
+		}
+		want := 11
+		if v == code128Stop {
+			want = 13
+		}
+		if n != want {
+			t.Errorf("pattern %d: %d modules, want %d\n", v, n, want)
+		}
+	}
+}
+
+func TestCode128Values(t *testing.T) {
+
+	for _, tt := range []struct {
+		in   string
+		want []int
+	}{
+		{"PJJ123C", []int{code128StartB, 48, 42, 42, 17, 18, 19, 35}},
+		{"12", []int{code128StartC, 12}},
+		{"123456", []int{code128StartC, 12, 34, 56}},
+		{"12345", []int{code128StartC, 12, 34, code128CodeB, 21}},
+		{"INV1234567X", []int{code128StartB, 41, 46, 54, 17, code128CodeC, 23, 45, 67, code128CodeB, 56}},
+		{"A12B", []int{code128StartB, 33, 17, 18, 34}},
+	} {
+		got, err := code128Values(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v\n", tt.in, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v\n", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := EncodeCode128("tab\t"); err == nil {
+		t.Error("expected error for control character\n")
+	}
+}
+
+func TestEncodeCode128(t *testing.T) {
+
+	c, err := EncodeCode128("PJJ123C")
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	// Start B, 7 characters, check character, stop.
+	if c.Width() != 9*11+13 {
+		t.Fatalf("width: %d, want %d\n", c.Width(), 9*11+13)
+	}
+
+	s := bits(c)
+
+	if !strings.HasPrefix(s, "11010010000") {
+		t.Errorf("missing start B: %s\n", s[:11])
+	}
+
+	// The check character is (104 + 1*48 + 2*42 + 3*42 + 4*17 + 5*18 + 6*19 + 7*35) % 103 = 55.
+	if got := s[8*11 : 9*11]; got != "11101000110" {
+		t.Errorf("check character: got %s, want 55\n", got)
+	}
+
+	if !strings.HasSuffix(s, "1100011101011") {
+		t.Errorf("missing stop: %s\n", s[len(s)-13:])
+	}
+}
+
+func TestEncodeEAN(t *testing.T) {
+
+	c, err := EncodeEAN13("400638133393")
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if c.Text != "4006381333931" || c.Width() != 95 {
+		t.Fatalf("ean13: %s, %d modules\n", c.Text, c.Width())
+	}
+
+	want := "101" + // start guard
+		"0001101" + "0100111" + "0101111" + "0111101" + "0001001" + "0110011" + // 0 0 6 3 8 1 in parities LGLLGG
+		"01010" + // center guard
+		"1000010" + "1000010" + "1000010" + "1110100" + "1000010" + "1100110" + // 3 3 3 9 3 1
+		"101" // end guard
+
+	if got := bits(c); got != want {
+		t.Errorf("ean13:\ngot  %s\nwant %s\n", got, want)
+	}
+
+	c, err = EncodeEAN8("96385074")
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+	if c.Text != "96385074" || c.Width() != 67 {
+		t.Fatalf("ean8: %s, %d modules\n", c.Text, c.Width())
+	}
+
+	for _, s := range []string{"4006381333932", "40063813339", "40063813339x"} {
+		if _, err := EncodeEAN13(s); err == nil {
+			t.Errorf("ean13: expected error for %s\n", s)
+		}
+	}
+}
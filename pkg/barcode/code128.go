@@ -0,0 +1,144 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package barcode
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Bar and space widths of the Code 128 symbol characters indexed by value.
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128CodeC  = 99
+	code128CodeB  = 100
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+
+	code128QuietZone = 10
+)
+
+// digits returns the number of consecutive digits of s starting at i.
+func digits(s string, i int) int {
+	n := 0
+	for ; i+n < len(s) && s[i+n] >= '0' && s[i+n] <= '9'; n++ {
+	}
+	return n
+}
+
+// code128Values returns the symbol character values for s using code set B for ASCII text
+// and code set C for runs of digits.
+func code128Values(s string) ([]int, error) {
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < 32 || s[i] > 127 {
+			return nil, errors.Errorf("code128: unsupported character %q at %d", s[i], i)
+		}
+	}
+
+	var vv []int
+
+	// useC returns true if code set C pays off for the digits at i:
+	// 2 digits making up s, 4 digits at the start or the end and 6 digits in between.
+	useC := func(i int) bool {
+		n := digits(s, i)
+		if i == 0 && n == len(s) && n == 2 {
+			return true
+		}
+		if i == 0 || i+n == len(s) {
+			return n >= 4
+		}
+		return n >= 6
+	}
+
+	c := useC(0)
+	if c {
+		vv = append(vv, code128StartC)
+	} else {
+		vv = append(vv, code128StartB)
+	}
+
+	for i := 0; i < len(s); {
+
+		if c {
+			if digits(s, i) >= 2 {
+				vv = append(vv, int(s[i]-'0')*10+int(s[i+1]-'0'))
+				i += 2
+				continue
+			}
+			vv = append(vv, code128CodeB)
+			c = false
+		}
+
+		if useC(i) {
+			// An odd run starts in code set B.
+			if digits(s, i)%2 == 1 {
+				vv = append(vv, int(s[i])-32)
+				i++
+			}
+			vv = append(vv, code128CodeC)
+			c = true
+			continue
+		}
+
+		vv = append(vv, int(s[i])-32)
+		i++
+	}
+
+	return vv, nil
+}
+
+// EncodeCode128 returns the Code 128 symbol for s which may contain ASCII characters 32 through 127.
+func EncodeCode128(s string) (*Code, error) {
+
+	if len(s) == 0 {
+		return nil, errors.New("code128: missing data")
+	}
+
+	vv, err := code128Values(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// The check character is the weighted sum modulo 103 with the start character weighted 1.
+	sum := vv[0]
+	for i, v := range vv[1:] {
+		sum += (i + 1) * v
+	}
+	vv = append(vv, sum%103, code128Stop)
+
+	c := &Code{Symbology: "Code128", Text: s, QuietLeft: code128QuietZone, QuietRight: code128QuietZone}
+
+	for _, v := range vv {
+		c.appendWidths(code128Patterns[v])
+	}
+
+	return c, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package barcode
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Digit encodings of the left half using odd parity (set A).
+var eanL = [...]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// Digit encodings of the left half using even parity (set B).
+var eanG = [...]string{
+	"0100111", "0110011", "0011011", "0100001", "0011101",
+	"0111001", "0000101", "0010001", "0001001", "0010111",
+}
+
+// Digit encodings of the right half (set C).
+var eanR = [...]string{
+	"1110010", "1100110", "1101100", "1000010", "1011100",
+	"1001110", "1010000", "1000100", "1001000", "1110100",
+}
+
+// The parities of the left half of an EAN-13 symbol encode its first digit.
+var ean13Parities = [...]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+const (
+	eanGuard  = "101"
+	eanCenter = "01010"
+)
+
+// eanCheckDigit returns the check digit for the digits of s weighting the rightmost digit by 3.
+func eanCheckDigit(s string) int {
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		d := int(s[i] - '0')
+		if (len(s)-i)%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// eanDigits validates s as n-1 digits or n digits including the check digit and returns all n digits.
+func eanDigits(symbology string, s string, n int) (string, error) {
+
+	if len(s) != n-1 && len(s) != n {
+		return "", errors.Errorf("%s: %d or %d digits expected: %s", symbology, n-1, n, s)
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return "", errors.Errorf("%s: digits expected: %s", symbology, s)
+		}
+	}
+
+	c := strconv.Itoa(eanCheckDigit(s[:n-1]))
+
+	if len(s) == n && s[n-1:] != c {
+		return "", errors.Errorf("%s: invalid check digit: %s, want %s", symbology, s, c)
+	}
+
+	return s[:n-1] + c, nil
+}
+
+// EncodeEAN13 returns the EAN-13 symbol for 12 digits or 13 digits including the check digit.
+func EncodeEAN13(s string) (*Code, error) {
+
+	s, err := eanDigits("ean13", s, 13)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Code{Symbology: "EAN13", Text: s, QuietLeft: 11, QuietRight: 7}
+
+	parities := ean13Parities[s[0]-'0']
+
+	c.appendBits(eanGuard)
+	for i := 1; i <= 6; i++ {
+		if parities[i-1] == 'L' {
+			c.appendBits(eanL[s[i]-'0'])
+		} else {
+			c.appendBits(eanG[s[i]-'0'])
+		}
+	}
+	c.appendBits(eanCenter)
+	for i := 7; i <= 12; i++ {
+		c.appendBits(eanR[s[i]-'0'])
+	}
+	c.appendBits(eanGuard)
+
+	return c, nil
+}
+
+// EncodeEAN8 returns the EAN-8 symbol for 7 digits or 8 digits including the check digit.
+func EncodeEAN8(s string) (*Code, error) {
+
+	s, err := eanDigits("ean8", s, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Code{Symbology: "EAN8", Text: s, QuietLeft: 7, QuietRight: 7}
+
+	c.appendBits(eanGuard)
+	for i := 0; i < 4; i++ {
+		c.appendBits(eanL[s[i]-'0'])
+	}
+	c.appendBits(eanCenter)
+	for i := 4; i < 8; i++ {
+		c.appendBits(eanR[s[i]-'0'])
+	}
+	c.appendBits(eanGuard)
+
+	return c, nil
+}
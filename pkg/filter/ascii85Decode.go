@@ -67,7 +67,7 @@ func (f ascii85Decode) Decode(r io.Reader) (*bytes.Buffer, error) {
 
 	decoder := ascii85.NewDecoder(bytes.NewReader(p))
 
-	buf, err := ioutil.ReadAll(decoder)
+	buf, err := ioutil.ReadAll(f.limited(decoder))
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +71,10 @@ func (f asciiHexDecode) Decode(r io.Reader) (*bytes.Buffer, error) {
 		p = append(p, '0')
 	}
 
+	if err = f.checkLen(hex.DecodedLen(len(p))); err != nil {
+		return nil, err
+	}
+
 	dst := make([]byte, hex.DecodedLen(len(p)))
 
 	_, err = hex.Decode(dst, p)
@@ -21,6 +21,7 @@ package filter
 
 import (
 	"bytes"
+	"compress/zlib"
 	"io"
 
 	"github.com/hhrutter/pdfcpu/pkg/log"
@@ -38,12 +39,18 @@ const (
 	JBIG2     = "JBIG2Decode"
 	DCT       = "DCTDecode"
 	JPX       = "JPXDecode"
+
+	// Crypt is handled by the security handler when a stream gets decrypted and therefore passes data through.
+	Crypt = "Crypt"
 )
 
 var (
 
 	// ErrUnsupportedFilter signals an unsupported filter type.
 	ErrUnsupportedFilter = errors.New("Filter not supported")
+
+	// ErrLimitExceeded signals decoded content exceeding the limit of a filter.
+	ErrLimitExceeded = errors.New("Filter decode limit exceeded")
 )
 
 // Filter defines an interface for encoding/decoding buffers.
@@ -54,23 +61,32 @@ type Filter interface {
 
 // NewFilter returns a filter for given filterName and an optional parameter dictionary.
 func NewFilter(filterName string, parms map[string]int) (filter Filter, err error) {
+	return NewLimitedFilter(filterName, parms, 0)
+}
+
+// NewLimitedFilter returns a filter for given filterName and an optional parameter dictionary
+// whose Decode fails with ErrLimitExceeded as soon as the decoded content exceeds maxLen bytes.
+// A maxLen of 0 means no limit.
+func NewLimitedFilter(filterName string, parms map[string]int, maxLen int64) (filter Filter, err error) {
+
+	bf := baseFilter{parms: parms, maxLen: maxLen}
 
 	switch filterName {
 
 	case ASCII85:
-		filter = ascii85Decode{baseFilter{}}
+		filter = ascii85Decode{bf}
 
 	case ASCIIHex:
-		filter = asciiHexDecode{baseFilter{}}
+		filter = asciiHexDecode{bf}
 
 	case RunLength:
-		filter = runLengthDecode{baseFilter{parms}}
+		filter = runLengthDecode{bf}
 
 	case LZW:
-		filter = lzwDecode{baseFilter{parms}}
+		filter = lzwDecode{bf}
 
 	case Flate:
-		filter = flate{baseFilter{parms}}
+		filter = flate{bf, zlib.DefaultCompression}
 
 	// CCITTFax
 	// JBIG2
@@ -78,18 +94,71 @@ func NewFilter(filterName string, parms map[string]int) (filter Filter, err erro
 	// JPX
 
 	default:
-		log.Info.Printf("Filter not supported: <%s>", filterName)
-		err = ErrUnsupportedFilter
+		rf, ok := registeredFilterNamed(filterName)
+		if !ok {
+			log.Info.Printf("Filter not supported: <%s>", filterName)
+			return nil, ErrUnsupportedFilter
+		}
+		filter = customFilter{bf, filterName, rf}
 	}
 
 	return filter, err
 }
 
-// List return the list of all supported PDF filters.
+// NewFlateFilter returns a Flate filter encoding at the given zlib compression level
+// ranging from zlib.HuffmanOnly to zlib.BestCompression.
+func NewFlateFilter(parms map[string]int, level int) (Filter, error) {
+
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		return nil, errors.Errorf("Filter FlateDecode: invalid compression level: %d", level)
+	}
+
+	return flate{baseFilter{parms: parms}, level}, nil
+}
+
+// List return the list of all supported PDF filters including registered filters.
 func List() []string {
-	return []string{ASCII85, ASCIIHex, RunLength, LZW, Flate}
+	return append([]string{ASCII85, ASCIIHex, RunLength, LZW, Flate}, registeredNames()...)
 }
 
 type baseFilter struct {
-	parms map[string]int
+	parms  map[string]int
+	maxLen int64 // Limit for decoded content, 0 means no limit.
+}
+
+// limited returns a reader failing with ErrLimitExceeded once more than f.maxLen bytes have been read from r.
+func (f baseFilter) limited(r io.Reader) io.Reader {
+	if f.maxLen <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, n: f.maxLen}
+}
+
+// checkLen returns ErrLimitExceeded if n bytes exceed f.maxLen.
+func (f baseFilter) checkLen(n int) error {
+	if f.maxLen > 0 && int64(n) > f.maxLen {
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+type limitedReader struct {
+	r io.Reader
+	n int64 // Remaining bytes.
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+
+	// Read one byte beyond the limit in order to detect excess content.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrLimitExceeded
+	}
+
+	return n, err
 }
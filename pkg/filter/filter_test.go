@@ -19,6 +19,7 @@ package filter_test
 import (
 	"bufio"
 	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -78,6 +79,37 @@ func TestEncodeDecode(t *testing.T) {
 
 }
 
+func TestFlateCompressionLevel(t *testing.T) {
+
+	input := bytes.Repeat([]byte("Hello, Gopher! "), 100)
+
+	for level := -2; level <= 9; level++ {
+
+		f, err := filter.NewFlateFilter(nil, level)
+		if err != nil {
+			t.Fatalf("level %d: %v\n", level, err)
+		}
+
+		b, err := f.Encode(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("level %d: problem encoding: %v\n", level, err)
+		}
+
+		c, err := f.Decode(b)
+		if err != nil {
+			t.Fatalf("level %d: problem decoding: %v\n", level, err)
+		}
+
+		if !bytes.Equal(input, c.Bytes()) {
+			t.Fatalf("level %d: original content != decoded content\n", level)
+		}
+	}
+
+	if _, err := filter.NewFlateFilter(nil, 10); err == nil {
+		t.Fatal("expected error for invalid compression level")
+	}
+}
+
 var filenames = []string{
 	"testdata/gettysburg.txt",
 	"testdata/e.txt",
@@ -150,3 +182,51 @@ func TestWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestRegisterFilter(t *testing.T) {
+
+	xor := func(r io.Reader, parms map[string]int) (*bytes.Buffer, error) {
+		b, err := ioutil.ReadAll(r)
+		for i := range b {
+			b[i] ^= byte(parms["Key"])
+		}
+		return bytes.NewBuffer(b), err
+	}
+
+	if err := filter.RegisterFilter(filter.Flate, xor, xor); err == nil {
+		t.Errorf("replaced built-in filter\n")
+	}
+
+	if err := filter.RegisterFilter("XORDecode", xor, nil); err == nil {
+		t.Errorf("registered filter without decoder\n")
+	}
+
+	if err := filter.RegisterFilter("XORDecode", xor, xor); err != nil {
+		t.Fatalf("RegisterFilter: %v\n", err)
+	}
+
+	if l := filter.List(); l[len(l)-1] != "XORDecode" {
+		t.Errorf("List: got %v\n", l)
+	}
+
+	encodeDecodeUsingFilterNamed(t, "XORDecode")
+
+	f, err := filter.NewLimitedFilter("XORDecode", map[string]int{"Key": 0x55}, 4)
+	if err != nil {
+		t.Fatalf("NewLimitedFilter: %v\n", err)
+	}
+
+	if b, err := f.Decode(bytes.NewReader([]byte{0x55, 0x54})); err != nil || !bytes.Equal(b.Bytes(), []byte{0, 1}) {
+		t.Errorf("Decode: got % X %v\n", b, err)
+	}
+
+	if _, err = f.Decode(bytes.NewReader(make([]byte, 5))); err != filter.ErrLimitExceeded {
+		t.Errorf("Decode: got %v, want ErrLimitExceeded\n", err)
+	}
+
+	filter.UnregisterFilter("XORDecode")
+
+	if _, err = filter.NewFilter("XORDecode", nil); err != filter.ErrUnsupportedFilter {
+		t.Errorf("unregistered filter: got %v\n", err)
+	}
+}
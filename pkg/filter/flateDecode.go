@@ -51,8 +51,13 @@ const (
 	PNGPaeth   = 0x04
 )
 
+// maxRowSize limits the size of a pixel row subject to prediction
+// in order not to allocate arbitrary amounts of memory for corrupt decode parameters.
+const maxRowSize = 1 << 24
+
 type flate struct {
 	baseFilter
+	level int // zlib compression level used for encoding.
 }
 
 // Encode implements encoding for a Flate filter.
@@ -63,7 +68,10 @@ func (f flate) Encode(r io.Reader) (*bytes.Buffer, error) {
 	// TODO Optional decode parameters may need predictor preprocessing.
 
 	var b bytes.Buffer
-	w := zlib.NewWriter(&b)
+	w, err := zlib.NewWriterLevel(&b, f.level)
+	if err != nil {
+		return nil, err
+	}
 	defer w.Close()
 
 	written, err := io.Copy(w, r)
@@ -87,7 +95,7 @@ func (f flate) Decode(r io.Reader) (*bytes.Buffer, error) {
 	defer rc.Close()
 
 	// Optional decode parameters need postprocessing.
-	return f.decodePostProcess(rc)
+	return f.decodePostProcess(f.limited(rc))
 }
 
 func passThru(rin io.Reader) (*bytes.Buffer, error) {
@@ -217,7 +225,7 @@ func (f flate) parameters() (colors, bpc, columns int, err error) {
 	colors, found := f.parms["Colors"]
 	if !found {
 		colors = 1
-	} else if colors == 0 {
+	} else if colors <= 0 || colors > maxRowSize {
 		return 0, 0, 0, errors.Errorf("Filter FlateDecode: \"Colors\" must be > 0")
 	}
 
@@ -237,6 +245,12 @@ func (f flate) parameters() (colors, bpc, columns int, err error) {
 	columns, found = f.parms["Columns"]
 	if !found {
 		columns = 1
+	} else if columns <= 0 || columns > maxRowSize {
+		return 0, 0, 0, errors.Errorf("Filter FlateDecode: Unexpected \"Columns\": %d", columns)
+	}
+
+	if bpc*colors*columns > 8*maxRowSize {
+		return 0, 0, 0, errors.New("Filter FlateDecode: pixel row too large")
 	}
 
 	return colors, bpc, columns, nil
@@ -270,7 +284,10 @@ func (f flate) decodePostProcess(r io.Reader) (*bytes.Buffer, error) {
 
 	bytesPerPixel := (bpc*colors + 7) / 8
 
-	rowSize := bpc * colors * columns / 8
+	// Each row starts at a byte boundary.
+	rowBytes := (bpc*colors*columns + 7) / 8
+
+	rowSize := rowBytes
 	if predictor != PredictorTIFF {
 		// PNG prediction uses a row filter byte prefixing the pixelbytes of a row.
 		rowSize++
@@ -319,7 +336,7 @@ func (f flate) decodePostProcess(r io.Reader) (*bytes.Buffer, error) {
 		pr, cr = cr, pr
 	}
 
-	if b.Len()%(bpc*colors*columns/8) > 0 {
+	if b.Len()%rowBytes > 0 {
 		log.Info.Printf("failed postprocessing: %d %d\n", b.Len(), rowSize)
 		return nil, errors.New("filter FlateDecode: postprocessing failed")
 	}
@@ -0,0 +1,64 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+)
+
+// Run eg. go test -fuzz FuzzDecode ./pkg/filter for fuzzing.
+func FuzzDecode(f *testing.F) {
+
+	for _, filterName := range filter.List() {
+
+		fi, err := filter.NewFilter(filterName, nil)
+		if err != nil {
+			f.Fatalf("Problem: %v\n", err)
+		}
+
+		b, err := fi.Encode(bytes.NewReader([]byte("Hello, Gopher!")))
+		if err != nil {
+			f.Fatalf("Problem encoding: %v\n", err)
+		}
+
+		f.Add(filterName, 0, 0, 0, 0, b.Bytes())
+	}
+
+	// PNG Up prediction for rows of 3 bytes.
+	f.Add(filter.Flate, filter.PredictorUp, 3, 8, 1,
+		[]byte{0x78, 0x9c, 0x62, 0x62, 0x64, 0x62, 0x06, 0x04, 0x00, 0x00, 0xff, 0xff, 0x00, 0x14, 0x00, 0x07})
+
+	f.Fuzz(func(t *testing.T, filterName string, predictor, colors, bpc, columns int, b []byte) {
+
+		parms := map[string]int{}
+		for k, v := range map[string]int{"Predictor": predictor, "Colors": colors, "BitsPerComponent": bpc, "Columns": columns} {
+			if v != 0 {
+				parms[k] = v
+			}
+		}
+
+		fi, err := filter.NewFilter(filterName, parms)
+		if err != nil {
+			return
+		}
+
+		fi.Decode(bytes.NewReader(b))
+	})
+}
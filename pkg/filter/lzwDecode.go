@@ -72,7 +72,7 @@ func (f lzwDecode) Decode(r io.Reader) (*bytes.Buffer, error) {
 	defer rc.Close()
 
 	var b bytes.Buffer
-	written, err := io.Copy(&b, rc)
+	written, err := io.Copy(&b, f.limited(rc))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,146 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Encoder encodes the content read from r using the filter parameters parms.
+type Encoder func(r io.Reader, parms map[string]int) (*bytes.Buffer, error)
+
+// Decoder decodes the content read from r using the filter parameters parms.
+type Decoder func(r io.Reader, parms map[string]int) (*bytes.Buffer, error)
+
+type registeredFilter struct {
+	enc Encoder
+	dec Decoder
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]registeredFilter{}
+)
+
+// builtin returns true for filters implemented by this package.
+func builtin(filterName string) bool {
+	switch filterName {
+	case ASCII85, ASCIIHex, RunLength, LZW, Flate, Crypt:
+		return true
+	}
+	return false
+}
+
+// RegisterFilter makes the filter filterName available for decoding and, if enc is not nil, for encoding.
+// Use it to supply filters pdfcpu does not ship, eg. DCTDecode, JBIG2Decode or proprietary ones.
+// Streams using a registered filter get decoded for validation and extraction like streams using built-in filters.
+// Registering a filter again replaces it, built-in filters cannot be replaced.
+// RegisterFilter is usually called from an init function and is safe for concurrent use.
+func RegisterFilter(filterName string, enc Encoder, dec Decoder) error {
+
+	if filterName == "" || dec == nil {
+		return errors.Errorf("RegisterFilter: %q needs a decoder", filterName)
+	}
+
+	if builtin(filterName) {
+		return errors.Errorf("RegisterFilter: %q is a built-in filter", filterName)
+	}
+
+	registryMu.Lock()
+	registry[filterName] = registeredFilter{enc, dec}
+	registryMu.Unlock()
+
+	return nil
+}
+
+// UnregisterFilter removes the filter filterName registered by RegisterFilter.
+func UnregisterFilter(filterName string) {
+	registryMu.Lock()
+	delete(registry, filterName)
+	registryMu.Unlock()
+}
+
+// Registered returns true if filterName has been registered by RegisterFilter.
+func Registered(filterName string) bool {
+	registryMu.RLock()
+	_, ok := registry[filterName]
+	registryMu.RUnlock()
+	return ok
+}
+
+func registeredFilterNamed(filterName string) (registeredFilter, bool) {
+	registryMu.RLock()
+	rf, ok := registry[filterName]
+	registryMu.RUnlock()
+	return rf, ok
+}
+
+// registeredNames returns the names of all registered filters in alphabetical order.
+func registeredNames() []string {
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var ss []string
+	for k := range registry {
+		ss = append(ss, k)
+	}
+
+	sort.Strings(ss)
+
+	return ss
+}
+
+// customFilter adapts a registered filter to Filter.
+type customFilter struct {
+	baseFilter
+	name string
+	registeredFilter
+}
+
+// Encode implements encoding for a registered filter.
+func (f customFilter) Encode(r io.Reader) (*bytes.Buffer, error) {
+
+	if f.enc == nil {
+		return nil, ErrUnsupportedFilter
+	}
+
+	return f.enc(r, f.parms)
+}
+
+// Decode implements decoding for a registered filter.
+func (f customFilter) Decode(r io.Reader) (*bytes.Buffer, error) {
+
+	b, err := f.dec(f.limited(r), f.parms)
+	if err != nil {
+		if errors.Cause(err) == ErrLimitExceeded {
+			return nil, ErrLimitExceeded
+		}
+		return nil, errors.Wrapf(err, "Filter %s", f.name)
+	}
+
+	if err = f.checkLen(b.Len()); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -20,13 +20,19 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+
+	"github.com/pkg/errors"
 )
 
 type runLengthDecode struct {
 	baseFilter
 }
 
-func (f runLengthDecode) decode(w io.ByteWriter, src []byte) {
+var errRunLengthTruncated = errors.New("RunLengthDecode: truncated run")
+
+func (f runLengthDecode) decode(w io.ByteWriter, src []byte) error {
+
+	n := 0
 
 	for i := 0; i < len(src); {
 		b := src[i]
@@ -37,6 +43,12 @@ func (f runLengthDecode) decode(w io.ByteWriter, src []byte) {
 		i++
 		if b < 0x80 {
 			c := int(b) + 1
+			if i+c > len(src) {
+				return errRunLengthTruncated
+			}
+			if n += c; f.checkLen(n) != nil {
+				return ErrLimitExceeded
+			}
 			for j := 0; j < c; j++ {
 				w.WriteByte(src[i])
 				i++
@@ -44,13 +56,19 @@ func (f runLengthDecode) decode(w io.ByteWriter, src []byte) {
 			continue
 		}
 		c := 257 - int(b)
+		if i >= len(src) {
+			return errRunLengthTruncated
+		}
+		if n += c; f.checkLen(n) != nil {
+			return ErrLimitExceeded
+		}
 		for j := 0; j < c; j++ {
 			w.WriteByte(src[i])
 		}
 		i++
 	}
 
-	return
+	return nil
 }
 
 func (f runLengthDecode) encode(w io.ByteWriter, src []byte) {
@@ -58,6 +76,11 @@ func (f runLengthDecode) encode(w io.ByteWriter, src []byte) {
 	const maxLen = 0x80
 	const eod = 0x80
 
+	if len(src) == 0 {
+		w.WriteByte(eod)
+		return
+	}
+
 	i := 0
 	b := src[i]
 	start := i
@@ -135,7 +158,9 @@ func (f runLengthDecode) Decode(r io.Reader) (*bytes.Buffer, error) {
 	}
 
 	var b bytes.Buffer
-	f.decode(&b, p)
+	if err = f.decode(&b, p); err != nil {
+		return nil, err
+	}
 
 	return &b, nil
 }
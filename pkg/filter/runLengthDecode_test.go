@@ -71,7 +71,9 @@ func TestRunLengthEncoding(t *testing.T) {
 		compare(t, enc.Bytes(), []byte(tt.enc))
 
 		var raw bytes.Buffer
-		f.decode(&raw, enc.Bytes())
+		if err := f.decode(&raw, enc.Bytes()); err != nil {
+			t.Fatalf("decode %v: %v\n", enc.Bytes(), err)
+		}
 		compare(t, raw.Bytes(), []byte(tt.raw))
 	}
 
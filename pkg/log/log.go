@@ -119,7 +119,7 @@ func (l *logger) Fatalf(format string, args ...interface{}) {
 		return
 	}
 
-	l.log.Fatalf(format, args)
+	l.log.Fatalf(format, args...)
 }
 
 func (l *logger) Fatalln(args ...interface{}) {
@@ -128,5 +128,5 @@ func (l *logger) Fatalln(args ...interface{}) {
 		return
 	}
 
-	l.log.Fatalln(args)
+	l.log.Fatalln(args...)
 }
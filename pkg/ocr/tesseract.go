@@ -0,0 +1,102 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ocr implements OCR engines for creating searchable PDFs with pdfcpu.OCR.
+//
+// Any other engine, eg. a cloud service, plugs in by implementing pdfcpu.OCREngine.
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/pkg/errors"
+)
+
+// Tesseract recognizes words by running the tesseract command line tool (version 3.05 or later).
+type Tesseract struct {
+	Path      string // the tesseract executable, tesseract in PATH if empty
+	Languages string // eg. eng+deu, the default of tesseract if empty
+}
+
+// Recognize feeds img into tesseract and returns the words found.
+func (t Tesseract) Recognize(img pdfcpu.OCRImage) ([]pdfcpu.OCRWord, error) {
+
+	path := t.Path
+	if path == "" {
+		path = "tesseract"
+	}
+
+	args := []string{"stdin", "stdout"}
+	if t.Languages != "" {
+		args = append(args, "-l", t.Languages)
+	}
+	args = append(args, "tsv")
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(img.Data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("tesseract: %s: %v %s", img.FileName, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseTSV(stdout.Bytes())
+}
+
+// parseTSV returns the words of tesseract's tab separated output.
+// The columns are: level page_num block_num par_num line_num word_num left top width height conf text
+func parseTSV(b []byte) ([]pdfcpu.OCRWord, error) {
+
+	var ww []pdfcpu.OCRWord
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+
+	for i := 0; s.Scan(); i++ {
+
+		ss := strings.Split(s.Text(), "\t")
+
+		// Skip the header and anything but words.
+		if i == 0 || len(ss) < 12 || ss[0] != "5" {
+			continue
+		}
+
+		text := strings.TrimSpace(ss[11])
+		if text == "" {
+			continue
+		}
+
+		var ff [4]float64
+		for j := range ff {
+			f, err := strconv.ParseFloat(ss[6+j], 64)
+			if err != nil {
+				return nil, errors.Errorf("tesseract: line %d: invalid %s", i+1, ss[6+j])
+			}
+			ff[j] = f
+		}
+
+		ww = append(ww, pdfcpu.OCRWord{Text: text, X0: ff[0], Y0: ff[1], X1: ff[0] + ff[2], Y1: ff[1] + ff[3]})
+	}
+
+	return ww, s.Err()
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+)
+
+func TestParseTSV(t *testing.T) {
+
+	tsv := "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n" +
+		"1\t1\t0\t0\t0\t0\t0\t0\t1700\t2200\t-1\t\n" +
+		"4\t1\t1\t1\t1\t0\t100\t200\t400\t50\t-1\t\n" +
+		"5\t1\t1\t1\t1\t1\t100\t200\t180\t50\t96.5\tHello\n" +
+		"5\t1\t1\t1\t1\t2\t300\t202\t200\t48\t91\tWorld\n" +
+		"5\t1\t1\t1\t1\t3\t520\t202\t10\t48\t0\t \n"
+
+	ww, err := parseTSV([]byte(tsv))
+	if err != nil {
+		t.Fatalf("TestParseTSV: %v\n", err)
+	}
+
+	want := []pdfcpu.OCRWord{
+		{Text: "Hello", X0: 100, Y0: 200, X1: 280, Y1: 250},
+		{Text: "World", X0: 300, Y0: 202, X1: 500, Y1: 250},
+	}
+
+	if !reflect.DeepEqual(ww, want) {
+		t.Fatalf("TestParseTSV: got %v want %v\n", ww, want)
+	}
+
+	if _, err = parseTSV([]byte(tsv + "5\t1\t1\t1\t1\t4\tx\t0\t1\t1\t90\tbad\n")); err == nil {
+		t.Fatal("TestParseTSV: should have failed for invalid coordinates\n")
+	}
+}
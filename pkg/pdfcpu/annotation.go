@@ -0,0 +1,375 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Annotation represents a PDF annotation.
+type Annotation struct {
+	Page     int       `json:"page"`
+	Subtype  string    `json:"subtype"`
+	Rect     []float64 `json:"rect,omitempty"`
+	Contents string    `json:"contents,omitempty"`
+	Author   string    `json:"author,omitempty"`  // the text label of markup annotations
+	ModDate  string    `json:"modDate,omitempty"` // date of the last modification
+	ObjNr    int       `json:"objNr,omitempty"`   // object number of the annotation dict, 0 for direct objects
+	Media    []string  `json:"media,omitempty"`   // U3D or PRC for 3D, the embedded assets of RichMedia and Screen annotations
+}
+
+// pageAnnotations returns the annotations array of a page dict.
+func pageAnnotations(xRefTable *XRefTable, pageDict *PDFDict) (PDFArray, error) {
+
+	obj, found := pageDict.Find("Annots")
+	if !found || obj == nil {
+		return nil, nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return nil, err
+	}
+
+	return *arr, nil
+}
+
+func newAnnotation(xRefTable *XRefTable, d *PDFDict, page, objNr int) (*Annotation, error) {
+
+	a := &Annotation{Page: page, ObjNr: objNr}
+
+	if st := d.NameEntry("Subtype"); st != nil {
+		a.Subtype = *st
+	}
+
+	r, err := widgetRect(xRefTable, d)
+	if err != nil {
+		return nil, err
+	}
+	a.Rect = r
+
+	for k, s := range map[string]*string{"Contents": &a.Contents, "T": &a.Author, "M": &a.ModDate} {
+		o, found := d.Find(k)
+		if !found {
+			continue
+		}
+		if *s, err = fieldValueString(xRefTable, o); err != nil {
+			return nil, errors.Wrapf(err, "annotation %s", k)
+		}
+	}
+
+	assets, err := annotationMedia(xRefTable, d, a.Subtype)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range assets {
+		a.Media = append(a.Media, m.name)
+	}
+
+	return a, nil
+}
+
+// ListAnnotations returns the annotations of the selected pages in page order.
+// If selectedPages is empty all pages are processed.
+func ListAnnotations(xRefTable *XRefTable, selectedPages IntSet) ([]Annotation, error) {
+
+	log.Debug.Println("ListAnnotations begin")
+
+	aa := []Annotation{}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		arr, err := pageAnnotations(xRefTable, pageDict)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range arr {
+
+			var objNr int
+			if indRef, ok := v.(PDFIndirectRef); ok {
+				objNr = indRef.ObjectNumber.Value()
+			}
+
+			d, err := xRefTable.DereferenceDict(v)
+			if err != nil {
+				return nil, err
+			}
+
+			if d == nil {
+				continue
+			}
+
+			a, err := newAnnotation(xRefTable, d, i, objNr)
+			if err != nil {
+				return nil, err
+			}
+
+			aa = append(aa, *a)
+		}
+	}
+
+	log.Debug.Println("ListAnnotations end")
+
+	return aa, nil
+}
+
+// annotationHandler processes an annotation of a page and returns true if the annotation is to be removed.
+type annotationHandler func(page int, pageDict, d *PDFDict, subtype string, objNr int) (bool, error)
+
+// annotationSelector returns a func selecting annotations matching any of the given subtypes or object numbers.
+// Without any subtypes or object numbers all annotations except form field widgets are selected.
+func annotationSelector(subtypes []string, objNrs []int) func(subtype string, objNr int) (bool, error) {
+
+	st := StringSet{}
+	for _, s := range subtypes {
+		st[s] = true
+	}
+
+	ids := IntSet{}
+	for _, i := range objNrs {
+		ids[i] = true
+	}
+
+	return func(subtype string, objNr int) (bool, error) {
+
+		if len(st) == 0 && len(ids) == 0 {
+			return subtype != "Widget", nil
+		}
+
+		if !st[subtype] && (objNr == 0 || !ids[objNr]) {
+			return false, nil
+		}
+
+		if subtype == "Widget" {
+			return false, errors.Errorf("widget annotation %d belongs to a form field", objNr)
+		}
+
+		return true, nil
+	}
+}
+
+// processPageAnnotations calls fn for every annotation of a page and removes the annotations fn selects.
+// It returns the object numbers of removed popups and of the popups of removed annotations.
+func processPageAnnotations(xRefTable *XRefTable, page int, pageDict *PDFDict, fn annotationHandler) (int, IntSet, error) {
+
+	arr, err := pageAnnotations(xRefTable, pageDict)
+	if err != nil || arr == nil {
+		return 0, nil, err
+	}
+
+	popups := IntSet{}
+	kept := PDFArray{}
+
+	for _, v := range arr {
+
+		var objNr int
+		if indRef, ok := v.(PDFIndirectRef); ok {
+			objNr = indRef.ObjectNumber.Value()
+		}
+
+		d, err := xRefTable.DereferenceDict(v)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		var subtype string
+		if st := d.NameEntry("Subtype"); st != nil {
+			subtype = *st
+		}
+
+		remove, err := fn(page, pageDict, d, subtype, objNr)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if !remove {
+			kept = append(kept, v)
+			continue
+		}
+
+		if subtype == "Popup" && objNr > 0 {
+			popups[objNr] = true
+		}
+
+		if indRef := d.IndirectRefEntry("Popup"); indRef != nil {
+			popups[indRef.ObjectNumber.Value()] = true
+		}
+	}
+
+	removed := len(arr) - len(kept)
+
+	if removed == 0 {
+		return 0, popups, nil
+	}
+
+	if len(kept) == 0 {
+		pageDict.Delete("Annots")
+	} else {
+		pageDict.Update("Annots", kept)
+	}
+
+	return removed, popups, nil
+}
+
+// removePopups removes the popup annotations given by object number from a page
+// and deletes the references to removed popups from the remaining annotations.
+func removePopups(xRefTable *XRefTable, pageDict *PDFDict, popups IntSet) (int, error) {
+
+	arr, err := pageAnnotations(xRefTable, pageDict)
+	if err != nil || arr == nil {
+		return 0, err
+	}
+
+	kept := PDFArray{}
+
+	for _, v := range arr {
+
+		if indRef, ok := v.(PDFIndirectRef); ok && popups[indRef.ObjectNumber.Value()] {
+			continue
+		}
+
+		kept = append(kept, v)
+
+		d, err := xRefTable.DereferenceDict(v)
+		if err != nil {
+			return 0, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		if indRef := d.IndirectRefEntry("Popup"); indRef != nil && popups[indRef.ObjectNumber.Value()] {
+			d.Delete("Popup")
+		}
+	}
+
+	removed := len(arr) - len(kept)
+
+	if len(kept) == 0 {
+		pageDict.Delete("Annots")
+	} else if removed > 0 {
+		pageDict.Update("Annots", kept)
+	}
+
+	return removed, nil
+}
+
+// processAnnotations calls fn for every annotation of the selected pages and removes the annotations fn selects
+// along with their popups. It returns the number of removed annotations.
+// If selectedPages is empty all pages are processed.
+func processAnnotations(xRefTable *XRefTable, selectedPages IntSet, fn annotationHandler) (int, error) {
+
+	var count int
+	popups := IntSet{}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		n, pp, err := processPageAnnotations(xRefTable, i, pageDict, fn)
+		if err != nil {
+			return 0, err
+		}
+
+		count += n
+
+		for k := range pp {
+			popups[k] = true
+		}
+	}
+
+	if len(popups) == 0 {
+		return count, nil
+	}
+
+	// Popups may live on other pages than their parents.
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		n, err := removePopups(xRefTable, pageDict, popups)
+		if err != nil {
+			return 0, err
+		}
+
+		count += n
+	}
+
+	return count, nil
+}
+
+// RemoveAnnotations removes annotations of selected pages matching any of the given subtypes or object numbers
+// and returns the number of removed annotations.
+// Without subtypes and object numbers all annotations except form field widgets are removed.
+// The popup annotation of a removed annotation is also removed.
+// If selectedPages is empty all pages are processed.
+func RemoveAnnotations(xRefTable *XRefTable, selectedPages IntSet, subtypes []string, objNrs []int) (int, error) {
+
+	log.Debug.Println("RemoveAnnotations begin")
+
+	selected := annotationSelector(subtypes, objNrs)
+
+	n, err := processAnnotations(xRefTable, selectedPages, func(page int, pageDict, d *PDFDict, subtype string, objNr int) (bool, error) {
+		return selected(subtype, objNr)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "RemoveAnnotations")
+	}
+
+	log.Debug.Println("RemoveAnnotations end")
+
+	return n, nil
+}
@@ -0,0 +1,382 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Associated files
+//
+// The AF array of the catalog, a page or any other object like an annotation, an XObject or a structure element
+// lists the files associated with it (14.13). The AFRelationship entry of a file specification
+// tells how a file relates to its owner, eg. the Data of a chart or the Source of the document.
+// Hybrid documents like electronic invoices carry their machine-readable counterpart this way.
+
+// AFRelationships are the first-class AFRelationship names, see 14.13.2.
+var AFRelationships = []string{"Source", "Data", "Alternative", "Supplement", "EncryptedPayload", "FormData", "Schema", "Unspecified"}
+
+// AssociatedFile represents a file associated with the document, a page or an object.
+// Page and ObjNr are 0 for the document level.
+type AssociatedFile struct {
+	File         string // file name, the path of the file to attach for AddAssociatedFile
+	Relationship string // AFRelationship
+	Description  string
+	Page         int // page level
+	ObjNr        int // object level
+}
+
+// Owner returns the level af is associated with.
+func (af AssociatedFile) Owner() string {
+
+	if af.Page > 0 {
+		return fmt.Sprintf("page %d", af.Page)
+	}
+
+	if af.ObjNr > 0 {
+		return fmt.Sprintf("obj#%d", af.ObjNr)
+	}
+
+	return "document"
+}
+
+// afRelationship returns the AFRelationship name for s ignoring case.
+func afRelationship(s string) (string, error) {
+
+	if s == "" {
+		return "Unspecified", nil
+	}
+
+	for _, rel := range AFRelationships {
+		if strings.EqualFold(s, rel) {
+			return rel, nil
+		}
+	}
+
+	return "", errors.Errorf("pdfcpu: unsupported AFRelationship: %s, use one of %s", s, strings.Join(AFRelationships, ", "))
+}
+
+// afOwnerDict returns the dict of the document, page or object the AF entry of af belongs to.
+func afOwnerDict(xRefTable *XRefTable, af AssociatedFile) (*PDFDict, error) {
+
+	if af.Page > 0 && af.ObjNr > 0 {
+		return nil, errors.New("pdfcpu: associate a file with either a page or an object")
+	}
+
+	if af.Page > 0 {
+		if af.Page > xRefTable.PageCount {
+			return nil, errors.Errorf("pdfcpu: invalid page: %d", af.Page)
+		}
+		d, _, err := xRefTable.PageDict(af.Page)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			return nil, errors.Errorf("pdfcpu: page %d not found", af.Page)
+		}
+		return d, nil
+	}
+
+	if af.ObjNr > 0 {
+		o, err := xRefTable.FindObject(af.ObjNr)
+		if err != nil {
+			return nil, err
+		}
+		switch o := o.(type) {
+		case PDFDict:
+			return &o, nil
+		case PDFStreamDict:
+			return &o.PDFDict, nil
+		}
+		return nil, errors.Errorf("pdfcpu: obj#%d is no dict", af.ObjNr)
+	}
+
+	return xRefTable.Catalog()
+}
+
+// AddAssociatedFile embeds af.File and associates it with the document, a page or an object.
+// Files associated with the document are listed as attachments as well.
+func AddAssociatedFile(xRefTable *XRefTable, af AssociatedFile) error {
+
+	rel, err := afRelationship(af.Relationship)
+	if err != nil {
+		return err
+	}
+
+	d, err := afOwnerDict(xRefTable, af)
+	if err != nil {
+		return err
+	}
+
+	if af.Page == 0 && af.ObjNr == 0 && xRefTable.Names["EmbeddedFiles"] == nil {
+		if err = xRefTable.LocateNameTree("EmbeddedFiles", true); err != nil {
+			return err
+		}
+	}
+
+	indRef, err := fileSpectDict(xRefTable, af.File)
+	if err != nil {
+		return err
+	}
+
+	fs, err := xRefTable.DereferenceDict(*indRef)
+	if err != nil {
+		return err
+	}
+
+	_, fn := filepath.Split(af.File)
+
+	// Record the file name only, the local path means nothing to consumers.
+	fs.Update("F", PDFStringLiteral(fn))
+	fs.Update("UF", PDFStringLiteral(fn))
+	fs.InsertName("AFRelationship", rel)
+
+	fs.Delete("Desc")
+	if af.Description != "" {
+		fs.Insert("Desc", encodeText(af.Description))
+	}
+
+	arr, err := xRefTable.DereferenceArray(d.Dict["AF"])
+	if err != nil {
+		return err
+	}
+
+	if arr == nil {
+		arr = &PDFArray{}
+	}
+
+	d.Update("AF", append(*arr, *indRef))
+
+	if af.Page == 0 && af.ObjNr == 0 {
+		if err = xRefTable.Names["EmbeddedFiles"].Add(xRefTable, fn, *indRef); err != nil {
+			return err
+		}
+	}
+
+	// Associated files need V2.0, PDF/A-3 uses them with V1.7.
+	if xRefTable.Version() < V17 {
+		log.Debug.Printf("AddAssociatedFile: %s ensures V2.0\n", af.File)
+		v := V20
+		xRefTable.RootVersion = &v
+	}
+
+	return nil
+}
+
+// afOwners returns the dicts having an AF entry by AssociatedFile without File.
+func afOwners(xRefTable *XRefTable) ([]AssociatedFile, []*PDFDict, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aa := []AssociatedFile{{}}
+	dd := []*PDFDict{rootDict}
+
+	pages, err := pageNrs(xRefTable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objNrs := map[int]*PDFDict{}
+
+	for objNr, entry := range xRefTable.Table {
+		if entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		if xRefTable.Root != nil && objNr == xRefTable.Root.ObjectNumber.Value() {
+			continue
+		}
+		var d PDFDict
+		switch o := entry.Object.(type) {
+		case PDFDict:
+			d = o
+		case PDFStreamDict:
+			d = o.PDFDict
+		default:
+			continue
+		}
+		if _, found := d.Find("AF"); found {
+			objNrs[objNr] = &d
+		}
+	}
+
+	var keys []int
+	for objNr := range objNrs {
+		keys = append(keys, objNr)
+	}
+
+	// Pages first, then the remaining objects in order.
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := pages[keys[i]], pages[keys[j]]
+		if (pi > 0) != (pj > 0) {
+			return pi > 0
+		}
+		if pi != pj {
+			return pi < pj
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, objNr := range keys {
+		af := AssociatedFile{Page: pages[objNr]}
+		if af.Page == 0 {
+			af.ObjNr = objNr
+		}
+		aa = append(aa, af)
+		dd = append(dd, objNrs[objNr])
+	}
+
+	return aa, dd, nil
+}
+
+// fileSpecName returns the name of the file specified by d.
+func fileSpecName(xRefTable *XRefTable, d *PDFDict) string {
+
+	for _, k := range []string{"UF", "F"} {
+		if o, found := d.Find(k); found {
+			if s, err := fieldValueString(xRefTable, o); err == nil && s != "" {
+				return s
+			}
+		}
+	}
+
+	return "unknown"
+}
+
+// AssociatedFiles returns the files associated with the document, its pages and objects in this order.
+func AssociatedFiles(xRefTable *XRefTable) ([]AssociatedFile, error) {
+
+	owners, dd, err := afOwners(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var aa []AssociatedFile
+
+	for i, owner := range owners {
+
+		arr, err := xRefTable.DereferenceArray(dd[i].Dict["AF"])
+		if err != nil || arr == nil {
+			continue
+		}
+
+		for _, o := range *arr {
+
+			d, err := xRefTable.DereferenceDict(o)
+			if err != nil || d == nil {
+				continue
+			}
+
+			af := owner
+			af.File = fileSpecName(xRefTable, d)
+			af.Relationship = nameEntry(xRefTable, d, "AFRelationship")
+
+			if o, found := d.Find("Desc"); found {
+				if af.Description, err = fieldValueString(xRefTable, o); err != nil {
+					return nil, err
+				}
+			}
+
+			aa = append(aa, af)
+		}
+	}
+
+	return aa, nil
+}
+
+// ValidateAssociatedFiles checks the associations of the document and returns the problems found.
+// Associated files have to be embedded and declare a first-class relationship.
+// Like PDF/A-3 demands, all attachments have to be associated with the document, a page or an object.
+func ValidateAssociatedFiles(xRefTable *XRefTable) ([]string, error) {
+
+	owners, dd, err := afOwners(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var ss []string
+
+	associated := IntSet{}
+
+	for i, owner := range owners {
+
+		o, found := dd[i].Find("AF")
+		if !found {
+			continue
+		}
+
+		arr, err := xRefTable.DereferenceArray(o)
+		if err != nil || arr == nil {
+			ss = append(ss, fmt.Sprintf("%s: AF is no array", owner.Owner()))
+			continue
+		}
+
+		for j, o := range *arr {
+
+			if indRef, ok := o.(PDFIndirectRef); ok {
+				associated[indRef.ObjectNumber.Value()] = true
+			}
+
+			d, err := xRefTable.DereferenceDict(o)
+			if err != nil || d == nil {
+				ss = append(ss, fmt.Sprintf("%s: AF[%d] is no file specification dict", owner.Owner(), j))
+				continue
+			}
+
+			fn := fileSpecName(xRefTable, d)
+
+			if _, found := d.Find("EF"); !found {
+				ss = append(ss, fmt.Sprintf("%s: %s not embedded", owner.Owner(), fn))
+			}
+
+			rel := nameEntry(xRefTable, d, "AFRelationship")
+			if rel == "" {
+				ss = append(ss, fmt.Sprintf("%s: %s: missing AFRelationship", owner.Owner(), fn))
+			} else if !memberOf(rel, AFRelationships) {
+				ss = append(ss, fmt.Sprintf("%s: %s: invalid AFRelationship %s", owner.Owner(), fn, rel))
+			}
+		}
+	}
+
+	if xRefTable.Names["EmbeddedFiles"] == nil {
+		if err = xRefTable.LocateNameTree("EmbeddedFiles", false); err != nil {
+			return nil, err
+		}
+	}
+
+	if tree := xRefTable.Names["EmbeddedFiles"]; tree != nil {
+		err = tree.Process(xRefTable, func(xRefTable *XRefTable, k string, v PDFObject) error {
+			if indRef, ok := v.(PDFIndirectRef); !ok || !associated[indRef.ObjectNumber.Value()] {
+				ss = append(ss, fmt.Sprintf("attachment %s: not associated", k))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ss, nil
+}
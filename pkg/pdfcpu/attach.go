@@ -17,15 +17,99 @@ limitations under the License.
 package pdfcpu
 
 import (
-	"io/ioutil"
-	"os"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/log"
 	"github.com/pkg/errors"
 )
 
+// fileAttachmentIcons lists the standard icons of file attachment annotations, see 12.5.6.15.
+var fileAttachmentIcons = []string{"Graph", "PushPin", "Paperclip", "Tag"}
+
+// Attachment represents an embedded file along with its metadata, see 7.11.4.
+type Attachment struct {
+	FileName     string
+	Desc         string
+	MimeType     string
+	Size         int       // uncompressed size, -1 if unknown
+	CreationDate time.Time // zero if unknown
+	ModDate      time.Time // zero if unknown
+	CheckSum     string    // MD5 of the uncompressed file as hex string, empty if unknown
+}
+
+// mimeType returns the MIME type of the file fileName with content b, eg. application/pdf.
+func mimeType(fileName string, b []byte) string {
+
+	mt := mime.TypeByExtension(filepath.Ext(fileName))
+	if mt == "" {
+		mt = http.DetectContentType(b)
+	}
+
+	// Drop parameters like charset.
+	if i := strings.Index(mt, ";"); i >= 0 {
+		mt = mt[:i]
+	}
+
+	return strings.TrimSpace(mt)
+}
+
+// encodeName escapes s for use as PDF name, eg. application/pdf becomes application#2Fpdf.
+func encodeName(s string) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || c == '#' || contentDelimiter(c) {
+			fmt.Fprintf(&b, "#%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// decodeName resolves the #xx escapes of the PDF name s.
+func decodeName(s string) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// attachmentDesc splits s into a file name and an optional description separated by a comma.
+func attachmentDesc(s string) (string, string) {
+
+	i := strings.Index(s, ",")
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], strings.TrimSpace(s[i+1:])
+}
+
 func decodedFileSpecStreamDict(xRefTable *XRefTable, fileName string, o PDFObject) (*PDFStreamDict, error) {
 
 	d, err := xRefTable.DereferenceDict(o)
@@ -40,7 +124,7 @@ func decodedFileSpecStreamDict(xRefTable *XRefTable, fileName string, o PDFObjec
 	}
 
 	d, err = xRefTable.DereferenceDict(o)
-	if err != nil || o == nil {
+	if err != nil || d == nil {
 		return nil, err
 	}
 
@@ -76,7 +160,7 @@ func decodedFileSpecStreamDict(xRefTable *XRefTable, fileName string, o PDFObjec
 		}
 
 		// Decode streamDict for supported filters only.
-		err = decodeStream(sd)
+		err = xRefTable.decodeStream(sd)
 		if err != nil {
 			return nil, err
 		}
@@ -99,11 +183,20 @@ func extractAttachedFiles(ctx *PDFContext, files StringSet) error {
 			return err
 		}
 
+		if sd == nil {
+			log.Info.Printf("extractAttachedFiles: skipping %s\n", fileName)
+			return nil
+		}
+
+		if ok, err := verifyCheckSum(xRefTable, sd); err != nil {
+			return err
+		} else if !ok {
+			log.Info.Printf("extractAttachedFiles: %s: checksum mismatch\n", fileName)
+		}
+
 		log.Info.Printf("writing %s\n", path)
 
-		// TODO Refactor into returning only stream object numbers for files to be extracted.
-		// No writing to file in library!
-		err = ioutil.WriteFile(path, sd.Content, os.ModePerm)
+		err = ctx.writeFile(path, sd.Content)
 		if err != nil {
 			return err
 		}
@@ -171,13 +264,23 @@ func addAttachedFiles(xRefTable *XRefTable, files StringSet) (ok bool, err error
 		return false, err
 	}
 
-	for fileName := range files {
+	for s := range files {
+
+		fileName, desc := attachmentDesc(s)
 
 		indRef, err := fileSpectDict(xRefTable, fileName)
 		if err != nil {
 			return false, err
 		}
 
+		if desc != "" {
+			d, err := xRefTable.DereferenceDict(*indRef)
+			if err != nil {
+				return false, err
+			}
+			d.Update("Desc", encodeText(desc))
+		}
+
 		_, fn := filepath.Split(fileName)
 
 		xRefTable.Names["EmbeddedFiles"].Add(xRefTable, fn, *indRef)
@@ -276,6 +379,122 @@ func AttachList(xRefTable *XRefTable) (list []string, err error) {
 	return list, nil
 }
 
+// embeddedFileParams returns the parameter dict of the embedded file stream sd.
+func embeddedFileParams(xRefTable *XRefTable, sd *PDFStreamDict) *PDFDict {
+
+	d, err := xRefTable.DereferenceDict(sd.Dict["Params"])
+	if err != nil {
+		return nil
+	}
+
+	return d
+}
+
+// verifyCheckSum returns false if the decoded embedded file stream sd does not match its checksum.
+func verifyCheckSum(xRefTable *XRefTable, sd *PDFStreamDict) (bool, error) {
+
+	d := embeddedFileParams(xRefTable, sd)
+	if d == nil {
+		return true, nil
+	}
+
+	o, err := xRefTable.Dereference(d.Dict["CheckSum"])
+	if err != nil || o == nil {
+		return true, err
+	}
+
+	b, ok := stringBytes(o)
+	if !ok {
+		return true, nil
+	}
+
+	sum := md5.Sum(sd.Content)
+
+	return bytes.Equal(b, sum[:]), nil
+}
+
+// attachment returns the metadata of the embedded file fileName specified by o.
+func attachment(xRefTable *XRefTable, fileName string, o PDFObject) (*Attachment, error) {
+
+	a := &Attachment{FileName: fileName, Size: -1}
+
+	fs, err := xRefTable.DereferenceDict(o)
+	if err != nil || fs == nil {
+		return nil, err
+	}
+
+	if o, found := fs.Find("Desc"); found {
+		if a.Desc, err = fieldValueString(xRefTable, o); err != nil {
+			return nil, err
+		}
+	}
+
+	ef, err := xRefTable.DereferenceDict(fs.Dict["EF"])
+	if err != nil || ef == nil {
+		return a, err
+	}
+
+	sd, err := xRefTable.DereferenceStreamDict(ef.Dict["F"])
+	if err != nil || sd == nil {
+		return a, err
+	}
+
+	a.MimeType = decodeName(nameEntry(xRefTable, &sd.PDFDict, "Subtype"))
+
+	d := embeddedFileParams(xRefTable, sd)
+	if d == nil {
+		return a, nil
+	}
+
+	if i, err := xRefTable.DereferenceInteger(d.Dict["Size"]); err == nil && i != nil {
+		a.Size = i.Value()
+	}
+
+	for k, t := range map[string]*time.Time{"CreationDate": &a.CreationDate, "ModDate": &a.ModDate} {
+		if s, err := fieldValueString(xRefTable, d.Dict[k]); err == nil && s != "" {
+			*t, _ = parseDate(s)
+		}
+	}
+
+	if o, err := xRefTable.Dereference(d.Dict["CheckSum"]); err == nil && o != nil {
+		if b, ok := stringBytes(o); ok {
+			a.CheckSum = hex.EncodeToString(b)
+		}
+	}
+
+	return a, nil
+}
+
+// Attachments returns the embedded files along with their metadata.
+func Attachments(xRefTable *XRefTable) ([]Attachment, error) {
+
+	if !xRefTable.Valid && xRefTable.Names["EmbeddedFiles"] == nil {
+		if err := xRefTable.LocateNameTree("EmbeddedFiles", false); err != nil {
+			return nil, err
+		}
+	}
+
+	if xRefTable.Names["EmbeddedFiles"] == nil {
+		return nil, nil
+	}
+
+	var aa []Attachment
+
+	err := xRefTable.Names["EmbeddedFiles"].Process(xRefTable, func(xRefTable *XRefTable, k string, v PDFObject) error {
+		a, err := attachment(xRefTable, k, v)
+		if err != nil || a == nil {
+			return err
+		}
+		aa = append(aa, *a)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return aa, nil
+}
+
 // AttachExtract exports specified embedded files.
 // If no files specified extract all embedded files.
 func AttachExtract(ctx *PDFContext, files StringSet) (err error) {
@@ -347,3 +566,89 @@ func AttachRemove(xRefTable *XRefTable, files StringSet) (ok bool, err error) {
 
 	return ok, err
 }
+
+// fileAttachmentDict creates a file attachment annotation dict for the file specification fs.
+func fileAttachmentDict(n Note, fs PDFIndirectRef) PDFDict {
+
+	d := noteDict(n, "FileAttachment")
+	d.InsertInt("F", annotFlagPrint|annotFlagNoZoom|annotFlagNoRotate)
+	d.Insert("FS", fs)
+
+	icon := n.Icon
+	if icon == "" {
+		icon = "PushPin"
+	}
+	d.InsertName("Name", icon)
+
+	if n.Color != "" {
+		c, _ := parseXFDFColor(n.Color)
+		d.Insert("C", c)
+	}
+
+	return d
+}
+
+// AttachAddToPages embeds a file and attaches it to selected pages by file attachment annotations located at n.Rect.
+// Unlike AttachAdd the file does not become part of the document level EmbeddedFiles.
+// Contents defaults to the file name. All annotations share the embedded file.
+// If selectedPages is empty all pages are processed.
+func AttachAddToPages(xRefTable *XRefTable, selectedPages IntSet, n Note, fileName string) (int, error) {
+
+	log.Debug.Println("AttachAddToPages begin")
+
+	n.Page = 1
+	if err := n.validate(xRefTable, fileAttachmentIcons); err != nil {
+		return 0, errors.Wrap(err, "AttachAddToPages")
+	}
+
+	_, fn := filepath.Split(fileName)
+
+	if n.Contents == "" {
+		n.Contents = fn
+	}
+
+	sd, err := xRefTable.NewEmbeddedFileStreamDict(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = encodeStream(sd); err != nil {
+		return 0, err
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return 0, err
+	}
+
+	fsDict, err := xRefTable.NewFileSpecDict(fn, *indRef)
+	if err != nil {
+		return 0, err
+	}
+
+	fs, err := xRefTable.IndRefForNewObject(*fsDict)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		n.Page = i
+
+		if err = addNote(xRefTable, n, fileAttachmentDict(n, *fs)); err != nil {
+			return 0, err
+		}
+
+		count++
+	}
+
+	log.Debug.Println("AttachAddToPages end")
+
+	return count, nil
+}
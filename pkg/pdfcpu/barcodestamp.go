@@ -0,0 +1,205 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/barcode"
+	"github.com/hhrutter/pdfcpu/pkg/qrcode"
+
+	"github.com/pkg/errors"
+)
+
+// Barcode stamps
+//
+// A barcode stamp paints a QR code, Code 128 or EAN symbol as vector graphics: one filled rectangle
+// per run of dark modules on a white background including the quiet zone.
+// The data may contain variables which get resolved for each page stamped,
+// so each page may carry its own symbol.
+
+// BarcodeTypes are the supported symbologies of barcode stamps.
+var BarcodeTypes = []string{"qr", "code128", "ean13", "ean8"}
+
+// barcodeSymbol is an encoded barcode including its quiet zone measured in modules.
+type barcodeSymbol struct {
+	text  string                // encoded data
+	w, h  int                   // width and height
+	rows  int                   // distinct rows, 1 for linear barcodes
+	black func(x, row int) bool // true for dark modules
+}
+
+// encodeBarcode returns the symbol of type typ for data.
+func encodeBarcode(typ, data string) (*barcodeSymbol, error) {
+
+	if typ == "qr" {
+		c, err := qrcode.Encode([]byte(data), qrcode.Medium)
+		if err != nil {
+			return nil, err
+		}
+		n := c.Size + 2*qrCodeQuietZone
+		black := func(x, y int) bool { return c.Black(x-qrCodeQuietZone, y-qrCodeQuietZone) }
+		return &barcodeSymbol{text: data, w: n, h: n, rows: n, black: black}, nil
+	}
+
+	var (
+		c   *barcode.Code
+		err error
+	)
+
+	switch typ {
+	case "code128":
+		c, err = barcode.EncodeCode128(data)
+	case "ean13":
+		c, err = barcode.EncodeEAN13(data)
+	case "ean8":
+		c, err = barcode.EncodeEAN8(data)
+	default:
+		err = errors.Errorf("unsupported barcode type: %s, use one of %s", typ, strings.Join(BarcodeTypes, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w := c.QuietLeft + c.Width() + c.QuietRight
+
+	// EAN symbols have a nominal height of 69 modules for 113 modules including the quiet zone.
+	h := w * 69 / 113
+	if c.Symbology == "Code128" {
+		h = w / 4
+		if h < 30 {
+			h = 30
+		}
+	}
+
+	black := func(x, _ int) bool { return c.Black(x - c.QuietLeft) }
+
+	return &barcodeSymbol{text: data, w: w, h: h, rows: 1, black: black}, nil
+}
+
+// content returns the operators painting s into a box of width x height.
+func (s barcodeSymbol) content(width, height float64, color simpleColor) []byte {
+
+	mw, mh := width/float64(s.w), height/float64(s.rows)
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "q 1 g 0 0 %.2f %.2f re f %.3f %.3f %.3f rg ", width, height, color.r, color.g, color.b)
+
+	for row := 0; row < s.rows; row++ {
+
+		y := height - float64(row+1)*mh
+
+		for x := 0; x < s.w; {
+			if !s.black(x, row) {
+				x++
+				continue
+			}
+			x0 := x
+			for x < s.w && s.black(x, row) {
+				x++
+			}
+			fmt.Fprintf(&b, "%.3f %.3f %.3f %.3f re ", float64(x0)*mw, y, float64(x-x0)*mw, mh)
+		}
+	}
+
+	b.WriteString("f Q")
+
+	return b.Bytes()
+}
+
+// ParseBarcodeStampDetails parses a barcode stamp command string into an internal structure.
+// The first entry is the barcode type followed by a colon and the data, which may contain variables.
+func ParseBarcodeStampDetails(s string) (*Watermark, error) {
+
+	// Set default barcode stamp
+	wm := &Watermark{
+		onTop:         true,
+		scale:         0.2,
+		scaleAbs:      false,
+		color:         simpleColor{0, 0, 0},
+		diagonal:      noDiagonal,
+		opacity:       1.0,
+		strokeOpacity: 1.0,
+		pos:           posBottomRight,
+		objs:          IntSet{},
+		fCache:        formCache{},
+	}
+
+	ss := strings.Split(s, ",")
+
+	i := strings.Index(ss[0], ":")
+	if i < 0 {
+		return nil, errors.Errorf("barcode stamp must start with type:data, type is one of %s: %s\n", strings.Join(BarcodeTypes, ", "), ss[0])
+	}
+
+	wm.barcode = strings.ToLower(strings.TrimSpace(ss[0][:i]))
+	wm.barcodeData = strings.TrimSpace(ss[0][i+1:])
+
+	if wm.barcodeData == "" {
+		return nil, errors.New("barcode stamp: missing data")
+	}
+
+	err := parseWatermarkConfig(ss[1:], wm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the data right away unless it varies by page.
+	if !hasVars(wm.barcodeData) {
+		if _, err := encodeBarcode(wm.barcode, wm.barcodeData); err != nil {
+			return nil, err
+		}
+	} else if !memberOf(wm.barcode, BarcodeTypes) {
+		return nil, errors.Errorf("unsupported barcode type: %s, use one of %s", wm.barcode, strings.Join(BarcodeTypes, ", "))
+	} else if err = wm.checkUserVars(wm.barcodeData); err != nil {
+		return nil, err
+	}
+
+	return wm, nil
+}
+
+// IsBarcode returns whether the watermark content is a barcode.
+func (wm Watermark) IsBarcode() bool {
+	return len(wm.barcode) > 0
+}
+
+// setBarcodePage encodes the barcode data for a page.
+func (wm *Watermark) setBarcodePage(pageNr, pageCount int) error {
+
+	data := wm.resolveVars(wm.barcodeData, pageNr, pageCount)
+
+	if wm.bcSymbol != nil && wm.bcSymbol.text == data {
+		return nil
+	}
+
+	s, err := encodeBarcode(wm.barcode, data)
+	if err != nil {
+		return errors.Wrapf(err, "page %d", pageNr)
+	}
+
+	wm.bcSymbol = s
+	wm.imgWidth = s.w
+	wm.imgHeight = s.h
+
+	// Forms depend on the symbol now.
+	wm.fCache = formCache{}
+
+	return nil
+}
@@ -16,6 +16,14 @@ limitations under the License.
 
 package pdfcpu
 
+import (
+	"compress/zlib"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"io/fs"
+)
+
 const (
 
 	// ValidationStrict ensures 100% compliance with the spec (PDF 32000-1:2008).
@@ -61,6 +69,105 @@ const (
 	CHANGEOPW
 	STAMP
 	ADDWATERMARKS
+	LISTFORMFIELDS
+	ADDQRCODESTAMP
+	FILLFORM
+	EXPORTFORM
+	MULTIFILLFORM
+	LISTANNOTATIONS
+	REMOVEANNOTATIONS
+	FLATTENANNOTATIONS
+	EXPORTANNOTATIONS
+	IMPORTANNOTATIONS
+	ADDLINKS
+	MARKUPTEXT
+	ADDNOTES
+	ADDFREETEXTS
+	ADDPAGEATTACHMENTS
+	REDACT
+	LISTJAVASCRIPT
+	REMOVEJAVASCRIPT
+	FIELDAPPEARANCES
+	LISTFORMORDER
+	SETTABORDER
+	SETCALCULATIONORDER
+	ADDSIGNATUREFIELD
+	LISTCHOICEOPTIONS
+	ADDCHOICEOPTIONS
+	REMOVECHOICEOPTIONS
+	SETCHOICEOPTIONS
+	RESETFORM
+	SIGN
+	VERIFYSIGNATURES
+	DOCTIMESTAMP
+	ENABLELTV
+	SANITIZE
+	LISTREVISIONS
+	EXTRACTREVISION
+	REMOVEUSAGERIGHTS
+	VALIDATEPDFA
+	CONVERTPDFA
+	VALIDATEPDFX
+	ADDOUTPUTINTENT
+	LISTOUTPUTINTENTS
+	REMOVEOUTPUTINTENTS
+	EXTRACTOUTPUTINTENT
+	REPLACEOUTPUTINTENT
+	VALIDATEPDFUA
+	LINEARIZE
+	CHECKLINEARIZATION
+	LISTXMP
+	SETXMP
+	LISTSTRUCTTREE
+	EXPORTSTRUCTTREE
+	REPAIR
+	EXPORTJSON
+	IMPORTJSON
+	DIFF
+	LISTINFO
+	SETINFO
+	REMOVEINFO
+	LISTKEYWORDS
+	ADDKEYWORDS
+	REMOVEKEYWORDS
+	LISTPROPERTIES
+	ADDPROPERTIES
+	REMOVEPROPERTIES
+	BATCH
+	LISTBOOKMARKS
+	EXPORTBOOKMARKS
+	IMPORTBOOKMARKS
+	GENERATEBOOKMARKS
+	LISTNAMEDDESTS
+	ADDNAMEDDEST
+	REMOVENAMEDDESTS
+	RENAMENAMEDDEST
+	LISTLAYERS
+	SHOWLAYERS
+	HIDELAYERS
+	RENAMELAYER
+	REMOVELAYERS
+	FLATTENLAYERS
+	LISTVIEWERPREFS
+	SETVIEWERPREFS
+	REMOVEVIEWERPREFS
+	LISTTRANSITIONS
+	SETTRANSITIONS
+	REMOVETRANSITIONS
+	LISTPORTFOLIO
+	CREATEPORTFOLIO
+	EXTRACTPORTFOLIO
+	LISTASSOCFILES
+	ADDASSOCFILE
+	VALIDATEASSOCFILES
+	LISTVIEWPORTS
+	ADDVIEWPORTS
+	REMOVEVIEWPORTS
+	EXTRACTMEDIA
+	OCRPAGES
+	IMPORTOCR
+	REMOVEWATERMARKS
+	UPDATEWATERMARKS
 )
 
 // Configuration of a PDFContext.
@@ -72,9 +179,16 @@ type Configuration struct {
 	// Enables decoding of all streams (fontfiles, images..) for logging purposes.
 	DecodeAllStreams bool
 
+	// Enables loading stream content on first access instead of reading all streams into memory.
+	// Saves memory when processing a few pages of large files.
+	LazyLoading bool
+
 	// Validate against ISO-32000: strict or relaxed
 	ValidationMode int
 
+	// ValidationPolicy overrides the validation mode for individual validation rules.
+	ValidationPolicy ValidationPolicy
+
 	// End of line char sequence for writing.
 	Eol string
 
@@ -87,6 +201,23 @@ type Configuration struct {
 	// Switches between xRefSection (<=V1.4) and objectStream/xRefStream (>=V1.5) writing.
 	WriteXRefStream bool
 
+	// Writes a hybrid-reference file: an xRefSection readable by PDF 1.4 readers
+	// plus an xRefStream for objects within object streams. Applies if WriteXRefStream is true.
+	// Only the structure tree gets embedded into object streams.
+	WriteHybridXRef bool
+
+	// Packs all non-stream objects into object streams instead of the page tree only.
+	// Applies if WriteObjectStream and WriteXRefStream are true and WriteHybridXRef is false.
+	CompressObjects bool
+
+	// The zlib compression level used for Flate encoding streams when writing and optimizing
+	// ranging from -1 (zlib default) and 0 (no compression) to 9 (best compression).
+	CompressionLevel int
+
+	// Recompresses uncompressed and Flate encoded streams at CompressionLevel during optimization
+	// whenever this reduces their size.
+	RecompressStreams bool
+
 	// Turns on stats collection.
 	CollectStats bool
 
@@ -111,9 +242,65 @@ type Configuration struct {
 	// false: use 40 bit key
 	EncryptUsing128BitKey bool
 
+	// EncryptUsing256BitKey ensures AES-256 encryption using the security handler revision 6 (PDF 2.0).
+	// Takes precedence over EncryptUsingAES and EncryptUsing128BitKey.
+	EncryptUsing256BitKey bool
+
+	// Recipients enables certificate based encryption using the public-key security handler.
+	// Each recipient may open the document using the private key belonging to its certificate.
+	Recipients []*x509.Certificate
+
+	// Supplied certificate and RSA private key for opening a document encrypted for certificate recipients.
+	Certificate *x509.Certificate
+	PrivateKey  crypto.PrivateKey
+
 	// Supplied user access permissions, see Table 22
 	UserAccessPermissions int16
 
+	// ProducerOverride replaces the Producer pdfcpu writes into the document info dict and the XMP metadata.
+	// nil means pdfcpu, an empty string removes the Producer.
+	ProducerOverride *string
+
+	// CreatorOverride replaces the Creator of the document info dict and the XMP metadata on write.
+	// nil leaves the Creator untouched, an empty string removes it.
+	CreatorOverride *string
+
+	// NeedAppearances asks viewers to regenerate the appearance streams of filled form fields.
+	NeedAppearances bool
+
+	// TagContent adds content generated by stamping, annotation flattening and link creation to the structure tree.
+	TagContent bool
+
+	// Context cancels reading, validation, optimization and writing once done.
+	// Use it to enforce deadlines on processing pathological files.
+	Context context.Context
+
+	// Memory limits guarding against decompression bombs and oversized images, 0 means no limit.
+	// Processing exceeding a limit fails with a *MemoryLimitError.
+
+	// MaxStreamSize limits the decoded size of any single stream in bytes.
+	MaxStreamSize int64
+
+	// MaxDecodedSize limits the total size of all streams decoded for a document in bytes.
+	MaxDecodedSize int64
+
+	// MaxImagePixels limits the number of pixels (width * height) of images being decoded.
+	MaxImagePixels int64
+
+	// Workers is the number of goroutines validating pages and preparing page resources for optimization.
+	// Values below 2 mean pages are processed sequentially.
+	Workers int
+
+	// FS provides input files like PDF files, images, fonts and attachments, nil means the local file system.
+	FS fs.FS
+
+	// CreateFile creates output files like extracted images and fonts, nil means the local file system.
+	CreateFile CreateFunc
+
+	// Labels CPU profile samples with the phase being processed: read, validate, optimize or write.
+	// See runtime/pprof.
+	ProfileLabels bool
+
 	// Command being executed.
 	Mode CommandMode
 }
@@ -128,6 +315,7 @@ func NewDefaultConfiguration() *Configuration {
 		Eol:                   EolLF,
 		WriteObjectStream:     true,
 		WriteXRefStream:       true,
+		CompressionLevel:      zlib.DefaultCompression,
 		CollectStats:          true,
 		EncryptUsingAES:       true,
 		EncryptUsing128BitKey: true,
@@ -138,13 +326,18 @@ func NewDefaultConfiguration() *Configuration {
 // ValidationModeString returns a string rep for the validation mode in effect.
 func (c *Configuration) ValidationModeString() string {
 
-	if c.ValidationMode == ValidationStrict {
-		return "strict"
+	var s string
+
+	switch c.ValidationMode {
+	case ValidationStrict:
+		s = "strict"
+	case ValidationRelaxed:
+		s = "relaxed"
 	}
 
-	if c.ValidationMode == ValidationRelaxed {
-		return "relaxed"
+	if len(c.ValidationPolicy) > 0 {
+		s += ", " + c.ValidationPolicy.String()
 	}
 
-	return ""
+	return s
 }
@@ -18,8 +18,9 @@ package pdfcpu
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"fmt"
-	"os"
+	"io"
 	"sort"
 	"strings"
 
@@ -27,6 +28,11 @@ import (
 )
 
 // PDFContext represents the context for processing PDF files.
+//
+// Once read, validated and optimized a PDFContext may serve read-only operations
+// like PageDict, Dereference, ExtractContentData, ExtractImageData and ExtractFontData
+// from multiple goroutines concurrently, also for files read using LazyLoading.
+// Any other operation, including validation, requires exclusive access.
 type PDFContext struct {
 	*Configuration
 	*XRefTable
@@ -35,14 +41,14 @@ type PDFContext struct {
 	Write    *WriteContext
 }
 
-// NewPDFContext initializes a new PDFContext.
-func NewPDFContext(fileName string, file *os.File, config *Configuration) (*PDFContext, error) {
+// NewPDFContext initializes a new PDFContext for reading the PDF file fileName from rs.
+func NewPDFContext(fileName string, rs io.ReadSeeker, config *Configuration) (*PDFContext, error) {
 
 	if config == nil {
 		config = NewDefaultConfiguration()
 	}
 
-	fileInfo, err := file.Stat()
+	fileSize, err := rs.Seek(0, io.SeekEnd)
 	if err != nil {
 		return nil, err
 	}
@@ -50,11 +56,22 @@ func NewPDFContext(fileName string, file *os.File, config *Configuration) (*PDFC
 	ctx := &PDFContext{
 		config,
 		newXRefTable(config.ValidationMode),
-		newReadContext(fileName, file, fileInfo.Size()),
+		newReadContext(fileName, rs, fileSize),
 		newOptimizationContext(),
 		NewWriteContext(config.Eol),
 	}
 
+	ctx.XRefTable.ValidationPolicy = config.ValidationPolicy
+	ctx.XRefTable.TagContent = config.TagContent
+	ctx.XRefTable.Context = config.Context
+	ctx.XRefTable.Workers = config.Workers
+	ctx.XRefTable.MaxStreamSize = config.MaxStreamSize
+	ctx.XRefTable.MaxDecodedSize = config.MaxDecodedSize
+	ctx.XRefTable.MaxImagePixels = config.MaxImagePixels
+	ctx.XRefTable.ProfileLabels = config.ProfileLabels
+	ctx.XRefTable.FS = config.FS
+	ctx.XRefTable.CreateFile = config.CreateFile
+
 	return ctx, nil
 }
 
@@ -157,7 +174,7 @@ type ReadContext struct {
 
 	// The PDF-File which gets processed.
 	FileName string
-	File     *os.File
+	RS       io.ReadSeeker
 	FileSize int64
 
 	BinaryTotalSize     int64 // total stream data
@@ -169,23 +186,47 @@ type ReadContext struct {
 	Linearized bool // File is linearized.
 	Hybrid     bool // File is a hybrid PDF file.
 
+	XRefSections int // Number of cross reference sections including the sections of incremental updates.
+
 	UsingObjectStreams bool   // File is using object streams.
 	ObjectStreams      IntSet // All object numbers of any object streams found which need to be decoded.
 
 	UsingXRefStreams bool   // File is using xref streams.
 	XRefStreams      IntSet // All object numbers of any xref streams found.
+
+	Repaired    bool     // The xref table has been rebuilt by scanning the file for objects.
+	Damage      []string // Damage found and repaired while reading.
+	CorruptObjs IntSet   // Objects dropped in REPAIR mode since they failed to read.
 }
 
-func newReadContext(fileName string, file *os.File, fileSize int64) *ReadContext {
+func newReadContext(fileName string, rs io.ReadSeeker, fileSize int64) *ReadContext {
 	return &ReadContext{
 		FileName:      fileName,
-		File:          file,
+		RS:            rs,
 		FileSize:      fileSize,
 		ObjectStreams: IntSet{},
 		XRefStreams:   IntSet{},
+		CorruptObjs:   IntSet{},
 	}
 }
 
+// Revisions returns the number of revisions read, that is one plus the number of incremental updates.
+func (rc *ReadContext) Revisions() int {
+
+	n := rc.XRefSections
+
+	// The first page cross reference section of a linearized file belongs to the original revision.
+	if rc.Linearized && n > 1 {
+		n--
+	}
+
+	if n < 1 {
+		n = 1
+	}
+
+	return n
+}
+
 // IsObjectStreamObject returns true if object i is a an object stream.
 // All compressed objects are object streams.
 func (rc *ReadContext) IsObjectStreamObject(i int) bool {
@@ -281,9 +322,23 @@ type OptimizationContext struct {
 	ImageObjects       map[int]*ImageObject
 	DuplicateImageObjs IntSet
 	DuplicateImages    map[int]*PDFStreamDict
+	imageDigests       map[int][sha256.Size]byte // Content digests of images computed during concurrent page processing.
 
 	DuplicateInfoObjects IntSet // Possible result of manual info dict modification.
 
+	DuplicateStreamObjs IntSet // Byte identical streams, eg. content streams or ICC profiles of merged files.
+
+	FixedPageCounts    int  // Number of page tree nodes with a wrong Count.
+	PageTreeRebalanced bool // True if a degenerate page tree has been rebuilt.
+
+	PrunedResources int // Number of fonts, XObjects and graphics states removed from page resources as unused.
+
+	UnreachableObjs  IntSet // Objects not reachable from the trailer.
+	UnreachableBytes int64  // Approximate size of unreachable objects.
+
+	RecompressedStreams int   // Number of streams shrunk by recompression.
+	RecompressedBytes   int64 // Bytes saved by recompression.
+
 	NonReferencedObjs []int // Objects that are not referenced.
 }
 
@@ -297,6 +352,8 @@ func newOptimizationContext() *OptimizationContext {
 		DuplicateImageObjs:   IntSet{},
 		DuplicateImages:      map[int]*PDFStreamDict{},
 		DuplicateInfoObjects: IntSet{},
+		DuplicateStreamObjs:  IntSet{},
+		UnreachableObjs:      IntSet{},
 	}
 }
 
@@ -372,6 +429,49 @@ func (oc *OptimizationContext) DuplicateInfoObjectsString() (int, string) {
 	return len(dupInfos), strings.Join(dupInfos, ",")
 }
 
+// IsDuplicateStreamObject returns true if object #i is a duplicate stream object.
+func (oc *OptimizationContext) IsDuplicateStreamObject(i int) bool {
+	return oc.DuplicateStreamObjs[i]
+}
+
+// DuplicateStreamObjectsString returns a formatted string and the number of objs.
+func (oc *OptimizationContext) DuplicateStreamObjectsString() (int, string) {
+
+	var objs []int
+	for k := range oc.DuplicateStreamObjs {
+		if oc.DuplicateStreamObjs[k] {
+			objs = append(objs, k)
+		}
+	}
+	sort.Ints(objs)
+
+	var dupStreams []string
+	for _, i := range objs {
+		dupStreams = append(dupStreams, fmt.Sprintf("%d", i))
+	}
+
+	return len(dupStreams), strings.Join(dupStreams, ",")
+}
+
+// UnreachableObjectsString returns a formatted string and the number of objs.
+func (oc *OptimizationContext) UnreachableObjectsString() (int, string) {
+
+	var objs []int
+	for k := range oc.UnreachableObjs {
+		if oc.UnreachableObjs[k] {
+			objs = append(objs, k)
+		}
+	}
+	sort.Ints(objs)
+
+	var unreachable []string
+	for _, i := range objs {
+		unreachable = append(unreachable, fmt.Sprintf("%d", i))
+	}
+
+	return len(unreachable), strings.Join(unreachable, ",")
+}
+
 // NonReferencedObjsString returns a formatted string and the number of objs.
 func (oc *OptimizationContext) NonReferencedObjsString() (int, string) {
 
@@ -562,6 +662,9 @@ type WriteContext struct {
 
 	WriteToObjectStream bool // if true start to embed objects into object streams and obey ObjectStreamMaxObjects.
 	CurrentObjStream    *int // if not nil, any new non-stream-object gets added to the object stream with this object number.
+	CompressObjects     bool // if true embed all non-stream objects into object streams.
+
+	XRefStm *int64 // offset of the xref stream of a hybrid-reference file.
 
 	Eol string // end of line char sequence
 }
@@ -933,8 +933,38 @@ func createWaterMarkAnnotation(xRefTable *XRefTable, pageIndRef *PDFIndirectRef,
 	return xRefTable.IndRefForNewObject(d)
 }
 
+func create3DStream(xRefTable *XRefTable) (*PDFIndirectRef, error) {
+
+	// The file header block of an empty U3D file.
+	buf := []byte{0x55, 0x33, 0x44, 0x00, 0x18, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	sd := &PDFStreamDict{
+		PDFDict: PDFDict{
+			Dict: map[string]PDFObject{
+				"Type":    PDFName("3D"),
+				"Subtype": PDFName("U3D"),
+			},
+		},
+		Content:        buf,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	sd.InsertName("Filter", filter.Flate)
+
+	err := encodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.IndRefForNewObject(*sd)
+}
+
 func create3DAnnotation(xRefTable *XRefTable, pageIndRef *PDFIndirectRef, annotRect *PDFArray) (*PDFIndirectRef, error) {
 
+	indRef, err := create3DStream(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
 	d := PDFDict{
 		Dict: map[string]PDFObject{
 			"Type":     PDFName("Annot"),
@@ -945,7 +975,7 @@ func create3DAnnotation(xRefTable *XRefTable, pageIndRef *PDFIndirectRef, annotR
 			"Border":   NewIntegerArray(0, 0, 3),
 			"C":        NewNumberArray(0.2, 0.8, 0.5),
 			"F":        PDFInteger(0),
-			"3DD":      NewPDFDict(), // stream or 3D reference dict
+			"3DD":      *indRef, // stream or 3D reference dict
 			"3DV":      PDFName("F"),
 			"3DA":      NewPDFDict(), // activation dict
 			"3DI":      PDFBoolean(true),
@@ -955,6 +985,42 @@ func create3DAnnotation(xRefTable *XRefTable, pageIndRef *PDFIndirectRef, annotR
 	return xRefTable.IndRefForNewObject(d)
 }
 
+func createRichMediaAnnotation(xRefTable *XRefTable, pageIndRef *PDFIndirectRef, annotRect *PDFArray) (*PDFIndirectRef, error) {
+
+	fileSpecDict, err := createFileSpecDict(xRefTable, testAudioFileWAV)
+	if err != nil {
+		return nil, err
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*fileSpecDict)
+	if err != nil {
+		return nil, err
+	}
+
+	d := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type":     PDFName("Annot"),
+			"Subtype":  PDFName("RichMedia"),
+			"Contents": PDFStringLiteral("RichMedia Annotation"),
+			"Rect":     *annotRect,
+			"P":        *pageIndRef,
+			"F":        PDFInteger(0),
+			"RichMediaContent": PDFDict{
+				Dict: map[string]PDFObject{
+					"Type": PDFName("RichMediaContent"),
+					"Assets": PDFDict{
+						Dict: map[string]PDFObject{
+							"Names": PDFArray{PDFStringLiteral("test.wav"), *indRef},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return xRefTable.IndRefForNewObject(d)
+}
+
 func createRedactAnnotation(xRefTable *XRefTable, pageIndRef *PDFIndirectRef, annotRect *PDFArray) (*PDFIndirectRef, error) {
 
 	// Create a quad points array corresponding to annot rect.
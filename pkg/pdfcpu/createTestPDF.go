@@ -767,6 +767,7 @@ func createAnnotsArray(xRefTable *XRefTable, pageIndRef *PDFIndirectRef, mediaBo
 		createPrinterMarkAnnotation,
 		createWaterMarkAnnotation,
 		create3DAnnotation,
+		createRichMediaAnnotation,
 		createRedactAnnotation,
 		createLinkAnnotationWithRemoteGoToAction,
 		createLinkAnnotationWithEmbeddedGoToAction,
@@ -31,6 +31,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/log"
 	"github.com/pkg/errors"
 )
@@ -67,7 +68,8 @@ var (
 )
 
 // NewEncryptDict creates a new EncryptDict using the standard security handler.
-func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
+// keyLength is 40, 128 or 256 bits, the latter implying AES.
+func newEncryptDict(needAES bool, keyLength int, permissions int16) *PDFDict {
 
 	d := NewPDFDict()
 
@@ -75,11 +77,16 @@ func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
 
 	d.Insert("Filter", PDFName("Standard"))
 
-	if need128BitKey {
+	switch keyLength {
+	case 256:
+		d.Insert("Length", PDFInteger(256))
+		d.Insert("R", PDFInteger(6))
+		d.Insert("V", PDFInteger(5))
+	case 128:
 		d.Insert("Length", PDFInteger(128))
 		d.Insert("R", PDFInteger(4))
 		d.Insert("V", PDFInteger(4))
-	} else {
+	default:
 		d.Insert("R", PDFInteger(2))
 		d.Insert("V", PDFInteger(1))
 	}
@@ -93,17 +100,16 @@ func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
 	d1 := NewPDFDict()
 	d1.Insert("AuthEvent", PDFName("DocOpen"))
 
-	if needAES {
+	switch {
+	case keyLength == 256:
+		d1.Insert("CFM", PDFName("AESV3"))
+	case needAES:
 		d1.Insert("CFM", PDFName("AESV2"))
-	} else {
+	default:
 		d1.Insert("CFM", PDFName("V2"))
 	}
 
-	if need128BitKey {
-		d1.Insert("Length", PDFInteger(16))
-	} else {
-		d1.Insert("Length", PDFInteger(5))
-	}
+	d1.Insert("Length", PDFInteger(keyLength/8))
 
 	d2 := NewPDFDict()
 	d2.Insert("StdCF", d1)
@@ -111,6 +117,18 @@ func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
 	d.Insert("CF", d2)
 
 	h := "0000000000000000000000000000000000000000000000000000000000000000"
+
+	if keyLength == 256 {
+		// Placeholders for the 48 byte entries O, U, the 32 byte entries OE, UE and the 16 byte entry Perms.
+		h48 := h + h[:32]
+		d.Insert("U", PDFHexLiteral(h48))
+		d.Insert("O", PDFHexLiteral(h48))
+		d.Insert("UE", PDFHexLiteral(h))
+		d.Insert("OE", PDFHexLiteral(h))
+		d.Insert("Perms", PDFHexLiteral(h[:32]))
+		return &d
+	}
+
 	d.Insert("U", PDFHexLiteral(h))
 	d.Insert("O", PDFHexLiteral(h))
 
@@ -171,6 +189,10 @@ func encKey(userpw string, e *Enc) (key []byte) {
 // ValidateUserPassword validates the user password aka document open password.
 func validateUserPassword(ctx *PDFContext) (ok bool, key []byte, err error) {
 
+	if ctx.E.R >= 5 {
+		return validateUserPasswordAES256(ctx)
+	}
+
 	// Alg.4/5 p63
 	// 4a/5a create encryption key using Alg.2 p61
 
@@ -335,6 +357,10 @@ func u(ctx *PDFContext) (u []byte, key []byte, err error) {
 // ValidateOwnerPassword validates the owner password aka change permissions password.
 func validateOwnerPassword(ctx *PDFContext) (ok bool, k []byte, err error) {
 
+	if ctx.E.R >= 5 {
+		return validateOwnerPasswordAES256(ctx)
+	}
+
 	ownerpw := ctx.OwnerPW
 	userpw := ctx.UserPW
 
@@ -395,7 +421,7 @@ func validateOwnerPassword(ctx *PDFContext) (ok bool, k []byte, err error) {
 func supportedCFEntry(d *PDFDict) (bool, error) {
 
 	cfm := d.NameEntry("CFM")
-	if cfm != nil && *cfm != "V2" && *cfm != "AESV2" {
+	if cfm != nil && *cfm != "None" && *cfm != "V2" && *cfm != "AESV2" && *cfm != "AESV3" {
 		return false, errors.New("supportedCFEntry: invalid entry \"CFM\"")
 	}
 
@@ -404,12 +430,13 @@ func supportedCFEntry(d *PDFDict) (bool, error) {
 		return false, errors.New("supportedCFEntry: invalid entry \"AuthEvent\"")
 	}
 
+	// Length is given in bytes but some writers use bits for AESV3.
 	l := d.IntEntry("Length")
-	if l != nil && (*l < 8 || *l > 128 || *l%8 > 1) {
+	if l != nil && (*l < 8 || *l > 128 || *l%8 > 1) && !(cfm != nil && *cfm == "AESV3" && *l == 256) {
 		return false, errors.New("supportedCFEntry: invalid entry \"Length\"")
 	}
 
-	return cfm != nil && *cfm == "AESV2", nil
+	return cfm != nil && (*cfm == "AESV2" || *cfm == "AESV3"), nil
 }
 
 func perms(p int) (list []string) {
@@ -427,8 +454,8 @@ func perms(p int) (list []string) {
 	return list
 }
 
-// Permissions returns a list of set permissions.
-func Permissions(ctx *PDFContext) (list []string) {
+// ListPermissions returns a list of set permissions.
+func ListPermissions(ctx *PDFContext) (list []string) {
 
 	if ctx.E == nil {
 		return append(list, "full access")
@@ -509,8 +536,8 @@ func getV(dict *PDFDict) (*int, error) {
 
 	v := dict.IntEntry("V")
 
-	if v == nil || (*v != 1 && *v != 2 && *v != 4) {
-		return nil, errors.Errorf("getV: \"V\" must be one of 1,2,4")
+	if v == nil || (*v != 1 && *v != 2 && *v != 4 && *v != 5) {
+		return nil, errors.Errorf("getV: \"V\" must be one of 1,2,4,5")
 	}
 
 	return v, nil
@@ -541,8 +568,8 @@ func checkV(ctx *PDFContext, dict *PDFDict) (*int, error) {
 		return nil, err
 	}
 
-	// Right now we support only 4
-	if *v != 4 {
+	// Crypt filters apply to 4 and 5 only.
+	if *v < 4 {
 		return v, nil
 	}
 
@@ -552,6 +579,15 @@ func checkV(ctx *PDFContext, dict *PDFDict) (*int, error) {
 		return nil, errors.Errorf("checkV: required entry \"CF\" missing.")
 	}
 
+	ctx.CryptFilters = map[string]string{}
+	for k, v := range cfDict.Dict {
+		cfm := "V2"
+		if d, ok := v.(PDFDict); ok && d.NameEntry("CFM") != nil {
+			cfm = *d.NameEntry("CFM")
+		}
+		ctx.CryptFilters[k] = cfm
+	}
+
 	// StmF
 	stmf := dict.NameEntry("StmF")
 	err = checkStmf(ctx, stmf, cfDict)
@@ -559,6 +595,11 @@ func checkV(ctx *PDFContext, dict *PDFDict) (*int, error) {
 		return nil, err
 	}
 
+	ctx.StmF = "Identity"
+	if stmf != nil {
+		ctx.StmF = *stmf
+	}
+
 	// StrF
 	strf := dict.NameEntry("StrF")
 	if strf != nil && *strf != "Identity" {
@@ -575,21 +616,146 @@ func checkV(ctx *PDFContext, dict *PDFDict) (*int, error) {
 
 	// EFF
 	eff := dict.NameEntry("EFF")
-	if eff != nil && *strf != "Identity" {
+	if eff != nil && *eff != "Identity" {
 		d := cfDict.PDFDictEntry(*eff)
 		if d == nil {
 			return nil, errors.Errorf("checkV: entry \"%s\" missing in \"CF\"", *eff)
 		}
 		aes, err := supportedCFEntry(d)
 		if err != nil {
-			return nil, errors.Wrapf(err, "checkV: unsupported \"%s\" entry in \"CF\"", *strf)
+			return nil, errors.Wrapf(err, "checkV: unsupported \"%s\" entry in \"CF\"", *eff)
 		}
 		ctx.AES4EmbeddedStreams = aes
 	}
 
+	ctx.EFF = ctx.StmF
+	if eff != nil {
+		ctx.EFF = *eff
+	}
+
 	return v, nil
 }
 
+// cryptFilterMethod returns whether the crypt filter cf encrypts and if so whether it uses AES.
+func (xRefTable *XRefTable) cryptFilterMethod(cf string) (bool, bool, error) {
+
+	if cf == "Identity" {
+		return false, false, nil
+	}
+
+	cfm, found := xRefTable.CryptFilters[cf]
+	if !found {
+		return false, false, errors.Errorf("unknown crypt filter: %s", cf)
+	}
+
+	switch cfm {
+	case "None":
+		return false, false, nil
+	case "AESV2", "AESV3":
+		return true, true, nil
+	}
+
+	return true, false, nil
+}
+
+// streamCryptFilter returns the name of the crypt filter in the filter pipeline of sd, see 7.4.10.
+func streamCryptFilter(sd *PDFStreamDict) (string, bool) {
+
+	for _, f := range sd.FilterPipeline {
+		if f.Name != filter.Crypt {
+			continue
+		}
+		if f.DecodeParms != nil {
+			if n := f.DecodeParms.NameEntry("Name"); n != nil {
+				return *n, true
+			}
+		}
+		return "Identity", true
+	}
+
+	return "", false
+}
+
+// streamCrypt returns whether the stream sd of an encrypted document is encrypted and if so whether by AES, see 7.6.5.
+// Crypt filters let streams deviate from the encryption of the document:
+// a Crypt filter in the filter pipeline, unencrypted metadata and a separate crypt filter for embedded file streams.
+func streamCrypt(ctx *PDFContext, sd *PDFStreamDict) (bool, bool, error) {
+
+	if sd.Type() != nil && *sd.Type() == "XRef" {
+		return false, false, nil
+	}
+
+	// Crypt filters apply to V 4 and 5 only.
+	if ctx.CryptFilters == nil {
+		return true, ctx.AES4Streams, nil
+	}
+
+	if cf, found := streamCryptFilter(sd); found {
+		return ctx.cryptFilterMethod(cf)
+	}
+
+	t := sd.Type()
+
+	if t != nil && *t == "Metadata" && ctx.E != nil && !ctx.E.Emd {
+		return false, false, nil
+	}
+
+	if t != nil && *t == "EmbeddedFile" {
+		return ctx.cryptFilterMethod(ctx.EFF)
+	}
+
+	return ctx.cryptFilterMethod(ctx.StmF)
+}
+
+// removeCryptFilter removes any Crypt filter from the filter pipeline of sd, which is meaningless without encryption.
+func removeCryptFilter(sd *PDFStreamDict) {
+
+	if _, found := streamCryptFilter(sd); !found {
+		return
+	}
+
+	var fpl []PDFFilter
+	for _, f := range sd.FilterPipeline {
+		if f.Name != filter.Crypt {
+			fpl = append(fpl, f)
+		}
+	}
+
+	sd.FilterPipeline = fpl
+
+	sd.Delete("Filter")
+	sd.Delete("DecodeParms")
+
+	switch len(fpl) {
+
+	case 0:
+		sd.FilterPipeline = nil
+
+	case 1:
+		sd.Insert("Filter", PDFName(fpl[0].Name))
+		if fpl[0].DecodeParms != nil {
+			sd.Insert("DecodeParms", *fpl[0].DecodeParms)
+		}
+
+	default:
+		var names, parms PDFArray
+		hasParms := false
+		for _, f := range fpl {
+			names = append(names, PDFName(f.Name))
+			if f.DecodeParms != nil {
+				parms = append(parms, *f.DecodeParms)
+				hasParms = true
+			} else {
+				parms = append(parms, nil)
+			}
+		}
+		sd.Insert("Filter", names)
+		if hasParms {
+			sd.Insert("DecodeParms", parms)
+		}
+	}
+}
+
 func length(dict *PDFDict) (int, error) {
 
 	l := dict.IntEntry("Length")
@@ -597,7 +763,7 @@ func length(dict *PDFDict) (int, error) {
 		return 40, nil
 	}
 
-	if *l < 40 || *l > 128 || *l%8 > 0 {
+	if (*l < 40 || *l > 128 || *l%8 > 0) && *l != 256 {
 		return 0, errors.Errorf("length: \"Length\" %d not supported\n", *l)
 	}
 
@@ -607,8 +773,8 @@ func length(dict *PDFDict) (int, error) {
 func getR(dict *PDFDict) (int, error) {
 
 	r := dict.IntEntry("R")
-	if r == nil || (*r != 2 && *r != 3 && *r != 4) {
-		return 0, errors.New("getR: \"R\" must be 2,3,4")
+	if r == nil || *r < 2 || *r > 6 {
+		return 0, errors.New("getR: \"R\" must be 2,3,4,5,6")
 	}
 
 	return *r, nil
@@ -647,12 +813,18 @@ func supportedEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
 		return nil, err
 	}
 
+	// O and U are 32 bytes long up to revision 4 and 48 bytes long for revision 5 and 6.
+	n := 32
+	if r >= 5 {
+		n = 48
+	}
+
 	// O
 	o, err := dict.StringEntryBytes("O")
 	if err != nil {
 		return nil, err
 	}
-	if o == nil || len(o) != 32 {
+	if o == nil || len(o) < n {
 		return nil, errors.New("unsupported encryption: required entry \"O\" missing or invalid")
 	}
 
@@ -661,10 +833,36 @@ func supportedEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
 	if err != nil {
 		return nil, err
 	}
-	if u == nil || len(u) != 32 {
+	if u == nil || len(u) < n {
 		return nil, errors.Errorf("unsupported encryption: required entry \"U\" missing or invalid %d", len(u))
 	}
 
+	var oe, ue, perms []byte
+
+	if r >= 5 {
+
+		// OE
+		if oe, err = dict.StringEntryBytes("OE"); err != nil {
+			return nil, err
+		}
+		if len(oe) != 32 {
+			return nil, errors.New("unsupported encryption: required entry \"OE\" missing or invalid")
+		}
+
+		// UE
+		if ue, err = dict.StringEntryBytes("UE"); err != nil {
+			return nil, err
+		}
+		if len(ue) != 32 {
+			return nil, errors.New("unsupported encryption: required entry \"UE\" missing or invalid")
+		}
+
+		// Perms
+		if perms, err = dict.StringEntryBytes("Perms"); err != nil {
+			return nil, err
+		}
+	}
+
 	// P
 	p := dict.IntEntry("P")
 	if p == nil {
@@ -678,13 +876,18 @@ func supportedEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
 		encMeta = *emd
 	}
 
-	return &Enc{O: o, U: u, L: l, P: *p, R: r, V: *v, Emd: encMeta}, nil
+	return &Enc{O: o[:n], U: u[:n], OE: oe, UE: ue, Perms: perms, L: l, P: *p, R: r, V: *v, Emd: encMeta}, nil
 }
 
 func decryptKey(objNumber, generation int, key []byte, aes bool) []byte {
 
 	log.Debug.Printf("decryptKey: obj:%d gen:%d key:%x aes:%t\n", objNumber, generation, key, aes)
 
+	// AES-256 uses the file encryption key for all objects, see 7.6.3.3 Algorithm 1.A.
+	if len(key) == 32 {
+		return key
+	}
+
 	m := md5.New()
 
 	nr := uint32(objNumber)
@@ -0,0 +1,384 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with AES-256 encryption using the standard security handler revision 6 (PDF 2.0)
+// and its deprecated predecessor revision 5 (Adobe Extension Level 3).
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// saslPrep prepares a password according to the SASLprep profile of stringprep (RFC 4013)
+// and truncates the UTF-8 result to 127 bytes, see 7.6.4.3.3 Algorithm 2.A.
+// Unicode normalization (NFKC) is omitted, passwords are expected to be normalized already.
+func saslPrep(pw string) ([]byte, error) {
+
+	if !utf8.ValidString(pw) {
+		return nil, errors.New("saslPrep: password is not valid UTF-8")
+	}
+
+	var b bytes.Buffer
+
+	for _, r := range pw {
+
+		switch {
+
+		// C.1.2 Non-ASCII space characters are mapped to space.
+		case r != ' ' && unicode.Is(unicode.Zs, r):
+			b.WriteRune(' ')
+			continue
+
+		// B.1 Characters commonly mapped to nothing.
+		case r == 0x00AD || r == 0x034F || r == 0x1806 || r >= 0x180B && r <= 0x180D ||
+			r >= 0x200B && r <= 0x200D || r == 0x2060 || r >= 0xFE00 && r <= 0xFE0F || r == 0xFEFF:
+			continue
+
+		// C.2 Control characters, C.3 private use, C.4 non-character code points, C.5 surrogates.
+		case unicode.IsControl(r) || unicode.Is(unicode.Co, r) || unicode.Is(unicode.Cs, r) ||
+			r >= 0xFDD0 && r <= 0xFDEF || r&0xFFFE == 0xFFFE:
+			return nil, errors.Errorf("saslPrep: prohibited character %U in password", r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	bb := b.Bytes()
+
+	// Truncate on a character boundary.
+	for len(bb) > 127 {
+		_, size := utf8.DecodeLastRune(bb)
+		bb = bb[:len(bb)-size]
+	}
+
+	return bb, nil
+}
+
+// hashAES256 computes the password hash for revision 5 and 6, see 7.6.4.3.4 Algorithm 2.B.
+// u is the 48 byte U entry when hashing the owner password and nil otherwise.
+func hashAES256(pw, salt, u []byte, r int) ([]byte, error) {
+
+	h := sha256.New()
+	h.Write(pw)
+	h.Write(salt)
+	h.Write(u)
+	k := h.Sum(nil)
+
+	if r < 6 {
+		return k, nil
+	}
+
+	var e []byte
+
+	for i := 0; i < 64 || int(e[len(e)-1]) > i-32; i++ {
+
+		var k1 []byte
+		for j := 0; j < 64; j++ {
+			k1 = append(k1, pw...)
+			k1 = append(k1, k...)
+			k1 = append(k1, u...)
+		}
+
+		cb, err := aes.NewCipher(k[:16])
+		if err != nil {
+			return nil, err
+		}
+
+		e = make([]byte, len(k1))
+		cipher.NewCBCEncrypter(cb, k[16:32]).CryptBlocks(e, k1)
+
+		var sum int
+		for _, c := range e[:16] {
+			sum += int(c)
+		}
+
+		var h hash.Hash
+		switch sum % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		case 2:
+			h = sha512.New()
+		}
+
+		h.Write(e)
+		k = h.Sum(nil)
+	}
+
+	return k[:32], nil
+}
+
+// aes256CBC en- or decrypts b using AES-256 in CBC mode with a zero initialization vector and no padding.
+func aes256CBC(b, key []byte, encrypt bool) ([]byte, error) {
+
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	data := make([]byte, len(b))
+
+	if encrypt {
+		cipher.NewCBCEncrypter(cb, iv).CryptBlocks(data, b)
+	} else {
+		cipher.NewCBCDecrypter(cb, iv).CryptBlocks(data, b)
+	}
+
+	return data, nil
+}
+
+// validateUserPasswordAES256 validates the user password for revision 5 and 6, see 7.6.4.4.10 Algorithm 11.
+// On success the file encryption key gets decrypted from UE, see 7.6.4.3.3 Algorithm 2.A.
+func validateUserPasswordAES256(ctx *PDFContext) (ok bool, key []byte, err error) {
+
+	e := ctx.E
+
+	pw, err := saslPrep(ctx.UserPW)
+	if err != nil {
+		return false, nil, err
+	}
+
+	h, err := hashAES256(pw, e.U[32:40], nil, e.R)
+	if err != nil || !bytes.Equal(h, e.U[:32]) {
+		return false, nil, err
+	}
+
+	h, err = hashAES256(pw, e.U[40:48], nil, e.R)
+	if err != nil {
+		return false, nil, err
+	}
+
+	key, err = aes256CBC(e.UE, h, false)
+	if err != nil {
+		return false, nil, err
+	}
+
+	checkPerms(e, key)
+
+	return true, key, nil
+}
+
+// validateOwnerPasswordAES256 validates the owner password for revision 5 and 6, see 7.6.4.4.11 Algorithm 12.
+// On success the file encryption key gets decrypted from OE, see 7.6.4.3.3 Algorithm 2.A.
+func validateOwnerPasswordAES256(ctx *PDFContext) (ok bool, key []byte, err error) {
+
+	e := ctx.E
+
+	pw, err := saslPrep(ctx.OwnerPW)
+	if err != nil {
+		return false, nil, err
+	}
+
+	h, err := hashAES256(pw, e.O[32:40], e.U[:48], e.R)
+	if err != nil || !bytes.Equal(h, e.O[:32]) {
+		return false, nil, err
+	}
+
+	h, err = hashAES256(pw, e.O[40:48], e.U[:48], e.R)
+	if err != nil {
+		return false, nil, err
+	}
+
+	key, err = aes256CBC(e.OE, h, false)
+	if err != nil {
+		return false, nil, err
+	}
+
+	checkPerms(e, key)
+
+	return true, key, nil
+}
+
+// permsAES256 returns the 16 byte Perms entry encrypting the permissions, see 7.6.4.4.9 Algorithm 10.
+func permsAES256(e *Enc, key []byte) ([]byte, error) {
+
+	b := make([]byte, 16)
+
+	p := uint32(e.P)
+	copy(b, []byte{byte(p), byte(p >> 8), byte(p >> 16), byte(p >> 24), 0xFF, 0xFF, 0xFF, 0xFF})
+
+	b[8] = 'F'
+	if e.Emd {
+		b[8] = 'T'
+	}
+	copy(b[9:12], "adb")
+
+	if _, err := io.ReadFull(rand.Reader, b[12:]); err != nil {
+		return nil, err
+	}
+
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	cb.Encrypt(b, b)
+
+	return b, nil
+}
+
+// checkPerms logs a warning if the Perms entry does not match P, see 7.6.4.4.12 Algorithm 13.
+// Like most readers we do not refuse files failing this check.
+func checkPerms(e *Enc, key []byte) {
+	if err := validatePerms(e, key); err != nil {
+		log.Info.Printf("%v\n", err)
+	}
+}
+
+// validatePerms checks the Perms entry against P.
+func validatePerms(e *Enc, key []byte) error {
+
+	// Perms is optional for revision 5.
+	if len(e.Perms) != 16 {
+		if e.R < 6 {
+			return nil
+		}
+		return errors.New("validatePerms: invalid entry \"Perms\"")
+	}
+
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 16)
+	cb.Decrypt(b, e.Perms)
+
+	if string(b[9:12]) != "adb" {
+		return errors.New("validatePerms: invalid entry \"Perms\"")
+	}
+
+	p := uint32(e.P)
+	if !bytes.Equal(b[:4], []byte{byte(p), byte(p >> 8), byte(p >> 16), byte(p >> 24)}) {
+		return errors.New("validatePerms: \"Perms\" does not match \"P\"")
+	}
+
+	return nil
+}
+
+// fileKeyAES256 generates a random 256 bit file encryption key.
+func fileKeyAES256() ([]byte, error) {
+
+	key := make([]byte, 32)
+
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// passwordEntriesAES256 computes the entries U, UE, O, OE and Perms of an AES-256 encrypt dict for the file key ctx.EncKey
+// and updates both ctx.E and d, see 7.6.4.4.7 Algorithm 8 and 7.6.4.4.8 Algorithm 9.
+func passwordEntriesAES256(ctx *PDFContext, d *PDFDict) error {
+
+	e := ctx.E
+	key := ctx.EncKey
+
+	// Always write revision 6.
+	e.R = 6
+	d.Update("R", PDFInteger(6))
+
+	upw, err := saslPrep(ctx.UserPW)
+	if err != nil {
+		return err
+	}
+
+	opw, err := saslPrep(ctx.OwnerPW)
+	if err != nil {
+		return err
+	}
+
+	if len(opw) == 0 {
+		opw = upw
+	}
+
+	// Validation salt and key salt for U and O.
+	salts := make([]byte, 32)
+	if _, err = io.ReadFull(rand.Reader, salts); err != nil {
+		return err
+	}
+
+	h, err := hashAES256(upw, salts[:8], nil, e.R)
+	if err != nil {
+		return err
+	}
+	e.U = append(h, salts[:16]...)
+
+	if h, err = hashAES256(upw, salts[8:16], nil, e.R); err != nil {
+		return err
+	}
+	if e.UE, err = aes256CBC(key, h, true); err != nil {
+		return err
+	}
+
+	if h, err = hashAES256(opw, salts[16:24], e.U, e.R); err != nil {
+		return err
+	}
+	e.O = append(h, salts[16:32]...)
+
+	if h, err = hashAES256(opw, salts[24:32], e.U, e.R); err != nil {
+		return err
+	}
+	if e.OE, err = aes256CBC(key, h, true); err != nil {
+		return err
+	}
+
+	if e.Perms, err = permsAES256(e, key); err != nil {
+		return err
+	}
+
+	d.Update("U", PDFHexLiteral(hex.EncodeToString(e.U)))
+	d.Update("O", PDFHexLiteral(hex.EncodeToString(e.O)))
+	d.Update("UE", PDFHexLiteral(hex.EncodeToString(e.UE)))
+	d.Update("OE", PDFHexLiteral(hex.EncodeToString(e.OE)))
+	d.Update("Perms", PDFHexLiteral(hex.EncodeToString(e.Perms)))
+
+	return nil
+}
+
+// ensureExtensionLevelAES256 declares the use of AES-256 encryption for PDF 1.7 readers
+// by Adobe extension level 8 and ensures V1.7.
+// PDF 2.0 supports AES-256 natively.
+func ensureExtensionLevelAES256(ctx *PDFContext) error {
+
+	if ctx.Version() == V20 {
+		return nil
+	}
+
+	if ctx.Version() < V17 {
+		v := V17
+		ctx.RootVersion = &v
+	}
+
+	return ensureExtensionLevel(ctx.XRefTable, "ADBE", 8)
+}
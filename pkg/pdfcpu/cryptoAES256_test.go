@@ -0,0 +1,140 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// The expected values below were computed by an implementation of the algorithms of ISO 32000-2
+// independent of pdfcpu, based on the SHA-2 and AES primitives of OpenSSL.
+
+func hexBytes(s string, t *testing.T) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hexBytes: %v\n", err)
+	}
+	return b
+}
+
+func TestSASLPrep(t *testing.T) {
+
+	for _, tt := range []struct {
+		in, want string
+		ok       bool
+	}{
+		// RFC 4013, 3. Examples
+		{"I\u00ADX", "IX", true},
+		{"user", "user", true},
+		{"USER", "USER", true},
+		{"\u0007", "", false},
+
+		{"pass\u00A0word", "pass word", true},
+		{"pass\u3000word", "pass word", true},
+		{"\uFEFFpass\u200Bword", "password", true},
+		{"pass\uE000", "", false},
+		{"pass\uFFFF", "", false},
+		{"\xff", "", false},
+
+		// Truncated to 127 bytes on a character boundary.
+		{strings.Repeat("a", 130), strings.Repeat("a", 127), true},
+		{strings.Repeat("a", 126) + "\u00E4", strings.Repeat("a", 126), true},
+	} {
+		b, err := saslPrep(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("saslPrep(%q): got error %v, want ok %t\n", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && string(b) != tt.want {
+			t.Errorf("saslPrep(%q): got %q want %q\n", tt.in, b, tt.want)
+		}
+	}
+}
+
+func TestHashAES256(t *testing.T) {
+
+	validationSalt := hexBytes("0102030405060708", t)
+	ownerValidationSalt := hexBytes("2122232425262728", t)
+
+	for _, tt := range []struct {
+		r                  int
+		u, owner, userHash string
+	}{
+		{
+			r:        5,
+			u:        "ad7c98e251cb1c7b3e2830b6f0becbd8352a6ab712232d6e82f2fca45f304dcc01020304050607081112131415161718",
+			userHash: "ad7c98e251cb1c7b3e2830b6f0becbd8352a6ab712232d6e82f2fca45f304dcc",
+			owner:    "2771877bdc4e534cc30f6538a3fadf323430c974e379860da52d2bad9401a270",
+		},
+		{
+			r:        6,
+			u:        "17424b40ead366f7ddef0ff073608aa68ba701714b5cef3409b94c4ffa76372601020304050607081112131415161718",
+			userHash: "17424b40ead366f7ddef0ff073608aa68ba701714b5cef3409b94c4ffa763726",
+			owner:    "7e1314d50a58a555c4f7b9cf875a1981c87fca8fcde1587f76a28fcfdf5e00d3",
+		},
+	} {
+		h, err := hashAES256([]byte("user"), validationSalt, nil, tt.r)
+		if err != nil {
+			t.Fatalf("hashAES256: %v\n", err)
+		}
+		if got := hex.EncodeToString(h); got != tt.userHash {
+			t.Errorf("hashAES256 R%d user: got %s want %s\n", tt.r, got, tt.userHash)
+		}
+
+		h, err = hashAES256([]byte("owner"), ownerValidationSalt, hexBytes(tt.u, t), tt.r)
+		if err != nil {
+			t.Fatalf("hashAES256: %v\n", err)
+		}
+		if got := hex.EncodeToString(h); got != tt.owner {
+			t.Errorf("hashAES256 R%d owner: got %s want %s\n", tt.r, got, tt.owner)
+		}
+	}
+
+	// For this salt the last byte of E equals the round number - 32 at some point, which ends the loop.
+	h, err := hashAES256([]byte("user"), hexBytes("0000000000000004", t), nil, 6)
+	if err != nil {
+		t.Fatalf("hashAES256: %v\n", err)
+	}
+	if got, want := hex.EncodeToString(h), "69d4ca7b63302879cbd8b6c47a75df5d8dfdb02beae648db25d450e356289715"; got != want {
+		t.Errorf("hashAES256 R6 loop end: got %s want %s\n", got, want)
+	}
+}
+
+func TestValidatePerms(t *testing.T) {
+
+	key := hexBytes("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", t)
+	perms := hexBytes("3df4bbd293346014510a8b2482d141ee", t)
+
+	for _, tt := range []struct {
+		e   Enc
+		key []byte
+		ok  bool
+	}{
+		{Enc{R: 6, P: -1028, Perms: perms}, key, true},
+		{Enc{R: 6, P: -1028, Perms: perms}, make([]byte, 32), false},
+		{Enc{R: 6, P: -4, Perms: perms}, key, false},
+		{Enc{R: 6, P: -1028}, key, false},
+		{Enc{R: 5, P: -1028}, key, true},
+	} {
+		err := validatePerms(&tt.e, tt.key)
+		if (err == nil) != tt.ok {
+			t.Errorf("validatePerms R%d P%d: got error %v, want ok %t\n", tt.e.R, tt.e.P, err, tt.ok)
+		}
+	}
+}
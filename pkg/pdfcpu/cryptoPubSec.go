@@ -0,0 +1,487 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with certificate based encryption using the public-key security handler Adobe.PubSec, see 7.6.5.
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"io"
+	"math/big"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// PKCS#7 / CMS structures needed for enveloped data, see RFC 5652.
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []recipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type recipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  issuerAndSerialNumber
+	KeyEncryptionAlgorithm algorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm algorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+// pkcs7Pad pads b to a multiple of the block size n.
+func pkcs7Pad(b []byte, n int) []byte {
+	c := n - len(b)%n
+	return append(b, bytes.Repeat([]byte{byte(c)}, c)...)
+}
+
+// envelope encrypts data for recipients using AES-256 in CBC mode for the content
+// and RSA for the content encryption key and returns the DER encoded PKCS#7 enveloped data.
+func envelope(data []byte, recipients []*x509.Certificate) ([]byte, error) {
+
+	cek := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+
+	if _, err := io.ReadFull(rand.Reader, cek); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	cb, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	b := pkcs7Pad(append([]byte{}, data...), aes.BlockSize)
+	cipher.NewCBCEncrypter(cb, iv).CryptBlocks(b, b)
+
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	ed := envelopedData{
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType: oidData,
+			ContentEncryptionAlgorithm: algorithmIdentifier{
+				Algorithm:  oidAES256CBC,
+				Parameters: asn1.RawValue{FullBytes: ivParam},
+			},
+			EncryptedContent: b,
+		},
+	}
+
+	for _, cert := range recipients {
+
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("envelope: unsupported public key of recipient %s, need RSA", cert.Subject.CommonName)
+		}
+
+		ek, err := rsa.EncryptPKCS1v15(rand.Reader, pub, cek)
+		if err != nil {
+			return nil, err
+		}
+
+		ed.RecipientInfos = append(ed.RecipientInfos, recipientInfo{
+			IssuerAndSerialNumber: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: algorithmIdentifier{
+				Algorithm:  oidRSAEncryption,
+				Parameters: asn1.NullRawValue,
+			},
+			EncryptedKey: ek,
+		})
+	}
+
+	content, err := asn1.Marshal(ed)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+}
+
+// openEnvelope decrypts DER encoded PKCS#7 enveloped data using the private key of cert.
+// It returns nil if cert is not among the recipients.
+func openEnvelope(der []byte, cert *x509.Certificate, key *rsa.PrivateKey) ([]byte, error) {
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, errors.Wrap(err, "openEnvelope: corrupt recipient")
+	}
+
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, errors.New("openEnvelope: recipient is not enveloped data")
+	}
+
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, errors.Wrap(err, "openEnvelope: corrupt enveloped data")
+	}
+
+	var cek []byte
+
+	for _, ri := range ed.RecipientInfos {
+
+		ias := ri.IssuerAndSerialNumber
+		if !bytes.Equal(ias.Issuer.FullBytes, cert.RawIssuer) || ias.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+
+		var err error
+		if cek, err = rsa.DecryptPKCS1v15(rand.Reader, key, ri.EncryptedKey); err != nil {
+			return nil, errors.Wrap(err, "openEnvelope")
+		}
+
+		break
+	}
+
+	if cek == nil {
+		return nil, nil
+	}
+
+	eci := ed.EncryptedContentInfo
+	alg := eci.ContentEncryptionAlgorithm.Algorithm
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, errors.Wrap(err, "openEnvelope: corrupt initialization vector")
+	}
+
+	var cb cipher.Block
+	var err error
+
+	switch {
+	case alg.Equal(oidAES128CBC), alg.Equal(oidAES192CBC), alg.Equal(oidAES256CBC):
+		cb, err = aes.NewCipher(cek)
+	case alg.Equal(oidDESEDE3CBC):
+		cb, err = des.NewTripleDESCipher(cek)
+	default:
+		return nil, errors.Errorf("openEnvelope: unsupported content encryption algorithm %v", alg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := eci.EncryptedContent
+	if len(iv) != cb.BlockSize() || len(b) == 0 || len(b)%cb.BlockSize() > 0 {
+		return nil, errors.New("openEnvelope: corrupt encrypted content")
+	}
+
+	data := make([]byte, len(b))
+	cipher.NewCBCDecrypter(cb, iv).CryptBlocks(data, b)
+
+	// Remove padding.
+	if c := int(data[len(data)-1]); c > 0 && c <= cb.BlockSize() {
+		data = data[:len(data)-c]
+	}
+
+	return data, nil
+}
+
+// pubSecKey derives the file encryption key from the seed and the recipients, see 7.6.5.3.
+func pubSecKey(seed []byte, recipients [][]byte, encryptMetadata bool, keyLength int) []byte {
+
+	h := sha1.New()
+	if keyLength == 256 {
+		h = sha256.New()
+	}
+
+	h.Write(seed)
+
+	for _, r := range recipients {
+		h.Write(r)
+	}
+
+	if !encryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+
+	return h.Sum(nil)[:keyLength/8]
+}
+
+// newPubSecEncryptDict creates an encrypt dict for the public-key security handler granting permissions to recipients
+// using AES-256 and returns it along with the file encryption key.
+func newPubSecEncryptDict(recipients []*x509.Certificate, permissions int16) (*PDFDict, []byte, error) {
+
+	seed := make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, seed[:20]); err != nil {
+		return nil, nil, err
+	}
+
+	// The permissions follow the seed as 4 byte big endian value.
+	p := uint32(int32(permissions))
+	copy(seed[20:], []byte{byte(p >> 24), byte(p >> 16), byte(p >> 8), byte(p)})
+
+	der, err := envelope(seed, recipients)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d := NewPDFDict()
+	d.Insert("Filter", PDFName("Adobe.PubSec"))
+	d.Insert("SubFilter", PDFName("adbe.pkcs7.s5"))
+	d.Insert("V", PDFInteger(5))
+	d.Insert("Length", PDFInteger(256))
+
+	cf := NewPDFDict()
+	cf.Insert("CFM", PDFName("AESV3"))
+	cf.Insert("AuthEvent", PDFName("DocOpen"))
+	cf.Insert("Length", PDFInteger(32))
+	cf.Insert("Recipients", PDFArray{PDFHexLiteral(hex.EncodeToString(der))})
+
+	d.Insert("CF", PDFDict{Dict: map[string]PDFObject{"DefaultCryptFilter": cf}})
+	d.Insert("StmF", PDFName("DefaultCryptFilter"))
+	d.Insert("StrF", PDFName("DefaultCryptFilter"))
+
+	return &d, pubSecKey(seed[:20], [][]byte{der}, true, 256), nil
+}
+
+// pubSecRecipients returns the DER encoded recipients, the key length in bits and the EncryptMetadata flag of a public-key encrypt dict.
+func pubSecRecipients(ctx *PDFContext, dict *PDFDict) ([][]byte, int, bool, error) {
+
+	subFilter := dict.NameEntry("SubFilter")
+	if subFilter == nil {
+		return nil, 0, false, errors.New("unsupported encryption: missing \"SubFilter\"")
+	}
+
+	v := dict.IntEntry("V")
+	if v == nil {
+		return nil, 0, false, errors.New("unsupported encryption: missing \"V\"")
+	}
+
+	keyLength := 40
+	if l := dict.IntEntry("Length"); l != nil {
+		keyLength = *l
+	}
+
+	encMeta := true
+	d := dict
+
+	switch *subFilter {
+
+	case "adbe.pkcs7.s4":
+		// Recipients are located in the encrypt dict.
+
+	case "adbe.pkcs7.s5":
+		// Recipients are located in the crypt filter for streams.
+		if _, err := checkV(ctx, dict); err != nil {
+			return nil, 0, false, err
+		}
+
+		stmf := dict.NameEntry("StmF")
+		cfDict := dict.PDFDictEntry("CF")
+		if stmf == nil || cfDict == nil || cfDict.PDFDictEntry(*stmf) == nil {
+			return nil, 0, false, errors.New("unsupported encryption: missing crypt filter")
+		}
+		d = cfDict.PDFDictEntry(*stmf)
+
+		keyLength = 128
+		if *v == 5 {
+			keyLength = 256
+		}
+
+		if emd := d.BooleanEntry("EncryptMetadata"); emd != nil {
+			encMeta = *emd
+		}
+
+	default:
+		return nil, 0, false, errors.Errorf("unsupported encryption: \"SubFilter\" %s", *subFilter)
+	}
+
+	if keyLength < 40 || keyLength > 256 || keyLength%8 > 0 {
+		return nil, 0, false, errors.Errorf("unsupported encryption: \"Length\" %d", keyLength)
+	}
+
+	obj, found := d.Find("Recipients")
+	if !found {
+		return nil, 0, false, errors.New("unsupported encryption: missing \"Recipients\"")
+	}
+
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	if arr == nil {
+		// A single recipient may be given as string.
+		arr = &PDFArray{obj}
+	}
+
+	var recipients [][]byte
+
+	for _, o := range *arr {
+
+		o, err = ctx.Dereference(o)
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		var b []byte
+
+		switch o := o.(type) {
+		case PDFStringLiteral:
+			b, err = Unescape(o.Value())
+		case PDFHexLiteral:
+			b, err = o.Bytes()
+		default:
+			err = errors.New("unsupported encryption: corrupt \"Recipients\"")
+		}
+
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		recipients = append(recipients, b)
+	}
+
+	return recipients, keyLength, encMeta, nil
+}
+
+// setupPubSecKey sets up the file encryption key of a document encrypted by the public-key security handler
+// using ctx.Certificate and ctx.PrivateKey.
+func setupPubSecKey(ctx *PDFContext, dict *PDFDict) error {
+
+	if ctx.Mode == CHANGEUPW || ctx.Mode == CHANGEOPW || ctx.Mode == ADDPERMISSIONS {
+		return errors.New("certificate based encryption: passwords and permissions cannot be changed, please decrypt and encrypt again")
+	}
+
+	if ctx.Certificate == nil || ctx.PrivateKey == nil {
+		return errors.New("certificate based encryption: missing certificate and private key")
+	}
+
+	key, ok := ctx.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("certificate based encryption: unsupported private key, need RSA")
+	}
+
+	recipients, keyLength, encMeta, err := pubSecRecipients(ctx, dict)
+	if err != nil {
+		return err
+	}
+
+	var seed []byte
+
+	for _, r := range recipients {
+		if seed, err = openEnvelope(r, ctx.Certificate, key); err != nil {
+			return err
+		}
+		if seed != nil {
+			break
+		}
+	}
+
+	if seed == nil {
+		return errors.New("certificate based encryption: certificate is not among the recipients")
+	}
+
+	if len(seed) < 24 {
+		return errors.New("certificate based encryption: corrupt seed")
+	}
+
+	p := int(int32(uint32(seed[20])<<24 | uint32(seed[21])<<16 | uint32(seed[22])<<8 | uint32(seed[23])))
+
+	ctx.E = &Enc{L: keyLength, P: p, R: 4, V: 4, Emd: encMeta, PubSec: true}
+
+	if v := dict.IntEntry("V"); v != nil {
+		ctx.E.V = *v
+	}
+
+	ctx.EncKey = pubSecKey(seed[:20], recipients, encMeta, keyLength)
+
+	log.Debug.Printf("setupPubSecKey: key length %d, permissions %d\n", keyLength, p)
+
+	if !hasNeededPermissions(ctx.Mode, ctx.E) {
+		return errors.New("Insufficient access permissions")
+	}
+
+	return nil
+}
+
+// setupPubSecEncryption sets up AES-256 encryption for ctx.Recipients granting ctx.UserAccessPermissions
+// and returns the corresponding encrypt dict.
+func setupPubSecEncryption(ctx *PDFContext) (*PDFDict, error) {
+
+	dict, key, err := newPubSecEncryptDict(ctx.Recipients, ctx.UserAccessPermissions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sets up the crypt filters.
+	if _, err = checkV(ctx, dict); err != nil {
+		return nil, err
+	}
+
+	ctx.E = &Enc{L: 256, P: int(ctx.UserAccessPermissions), R: 4, V: 5, Emd: true, PubSec: true}
+	ctx.EncKey = key
+
+	if err = ensureExtensionLevelAES256(ctx); err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
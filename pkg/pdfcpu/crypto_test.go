@@ -0,0 +1,229 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+)
+
+func testStream(xRefTable *XRefTable, typ, content string, fpl []PDFFilter, t *testing.T) PDFIndirectRef {
+
+	d := NewPDFDict()
+	if typ != "" {
+		d.InsertName("Type", typ)
+	}
+
+	for _, f := range fpl {
+		d.InsertName("Filter", f.Name)
+		if f.DecodeParms != nil {
+			d.Insert("DecodeParms", *f.DecodeParms)
+		}
+	}
+
+	sd := NewPDFStreamDict(d, 0, nil, nil, fpl)
+	sd.Content = []byte(content)
+
+	if err := encodeStream(&sd); err != nil {
+		t.Fatalf("encodeStream: %v\n", err)
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(sd)
+	if err != nil {
+		t.Fatalf("IndRefForNewObject: %v\n", err)
+	}
+
+	return *indRef
+}
+
+func writeTestContext(ctx *PDFContext, fileName string, t *testing.T) {
+
+	ctx.Write = NewWriteContext(ctx.Eol)
+	ctx.Write.DirName, ctx.Write.FileName = filepath.Split(fileName)
+
+	if err := WritePDFFile(ctx); err != nil {
+		t.Fatalf("WritePDFFile: %v\n", err)
+	}
+}
+
+func streamContent(ctx *PDFContext, o PDFObject, t *testing.T) string {
+
+	sd, err := ctx.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		t.Fatalf("DereferenceStreamDict: %v\n", err)
+	}
+
+	if err = decodeStream(sd); err != nil {
+		t.Fatalf("decodeStream: %v\n", err)
+	}
+
+	return string(sd.Content)
+}
+
+func TestCryptFilters(t *testing.T) {
+
+	xRefTable, err := CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("CreateDemoXRef: %v\n", err)
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		t.Fatalf("Catalog: %v\n", err)
+	}
+
+	metadata := testStream(xRefTable, "Metadata", "plain metadata", nil, t)
+	rootDict.Insert("Metadata", metadata)
+
+	// The catalog gets written entry by entry, so the other streams hang off the metadata stream dict.
+	sd, err := xRefTable.DereferenceStreamDict(metadata)
+	if err != nil {
+		t.Fatalf("DereferenceStreamDict: %v\n", err)
+	}
+
+	sd.Insert("Embedded", testStream(xRefTable, "EmbeddedFile", "plain embedded file", nil, t))
+	sd.Insert("Encrypted", testStream(xRefTable, "", "secret stream", nil, t))
+
+	fileName := filepath.Join(outDir, "cryptFilters.pdf")
+
+	if err = CreatePDF(xRefTable, outDir+"/", "cryptFilters.pdf"); err != nil {
+		t.Fatalf("CreatePDF: %v\n", err)
+	}
+
+	// Encrypt using AES-256.
+	config := NewDefaultConfiguration()
+	config.UserPW, config.OwnerPW = "upw", "opw"
+	config.EncryptUsingAES, config.EncryptUsing256BitKey = true, true
+	config.Mode = ENCRYPT
+
+	ctx, err := ReadPDFFile(fileName, config)
+	if err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	writeTestContext(ctx, fileName, t)
+
+	// Leave metadata and embedded files unencrypted, add a stream using the Identity crypt filter.
+	config = NewDefaultConfiguration()
+	config.UserPW, config.OwnerPW = "upw", "opw"
+
+	if ctx, err = ReadPDFFile(fileName, config); err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	if rootDict, err = ctx.Catalog(); err != nil {
+		t.Fatalf("Catalog: %v\n", err)
+	}
+
+	if sd, err = ctx.DereferenceStreamDict(rootDict.Dict["Metadata"]); err != nil || sd == nil {
+		t.Fatalf("DereferenceStreamDict: %v\n", err)
+	}
+
+	identity := NewPDFDict()
+	identity.InsertName("Name", "Identity")
+
+	sd.Insert("Identity", testStream(ctx.XRefTable, "", "plain identity", []PDFFilter{{Name: filter.Crypt, DecodeParms: &identity}}, t))
+
+	encryptDict, err := ctx.DereferenceDict(*ctx.Encrypt)
+	if err != nil {
+		t.Fatalf("DereferenceDict: %v\n", err)
+	}
+
+	encryptDict.Insert("EncryptMetadata", PDFBoolean(false))
+	encryptDict.InsertName("EFF", "Identity")
+	ctx.E.Emd = false
+	ctx.EFF = "Identity"
+
+	writeTestContext(ctx, fileName, t)
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	for s, want := range map[string]bool{
+		"plain metadata":      true,
+		"plain embedded file": true,
+		"plain identity":      true,
+		"secret stream":       false,
+	} {
+		if bytes.Contains(b, []byte(s)) != want {
+			t.Errorf("%q in plain text: want %t\n", s, want)
+		}
+	}
+
+	if ctx, err = ReadPDFFile(fileName, config); err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	if rootDict, err = ctx.Catalog(); err != nil {
+		t.Fatalf("Catalog: %v\n", err)
+	}
+
+	if sd, err = ctx.DereferenceStreamDict(rootDict.Dict["Metadata"]); err != nil || sd == nil {
+		t.Fatalf("DereferenceStreamDict: %v\n", err)
+	}
+
+	for k, want := range map[string]string{
+		"Embedded":  "plain embedded file",
+		"Identity":  "plain identity",
+		"Encrypted": "secret stream",
+	} {
+		if s := streamContent(ctx, sd.Dict[k], t); s != want {
+			t.Errorf("%s: got %q want %q\n", k, s, want)
+		}
+	}
+
+	if s := streamContent(ctx, rootDict.Dict["Metadata"], t); s != "plain metadata" {
+		t.Errorf("Metadata: got %q\n", s)
+	}
+
+	// Decryption drops the Crypt filter.
+	config.Mode = DECRYPT
+
+	if ctx, err = ReadPDFFile(fileName, config); err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	writeTestContext(ctx, fileName, t)
+
+	if ctx, err = ReadPDFFile(fileName, NewDefaultConfiguration()); err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	if rootDict, err = ctx.Catalog(); err != nil {
+		t.Fatalf("Catalog: %v\n", err)
+	}
+
+	if sd, err = ctx.DereferenceStreamDict(rootDict.Dict["Metadata"]); err != nil || sd == nil {
+		t.Fatalf("DereferenceStreamDict: %v\n", err)
+	}
+
+	identityRef := sd.Dict["Identity"]
+
+	if sd, err = ctx.DereferenceStreamDict(identityRef); err != nil || sd == nil || sd.FilterPipeline != nil || sd.Dict["DecodeParms"] != nil {
+		t.Fatalf("Identity: Crypt filter left over: %v\n", sd)
+	}
+
+	if s := streamContent(ctx, identityRef, t); s != "plain identity" {
+		t.Errorf("Identity: got %q\n", s)
+	}
+}
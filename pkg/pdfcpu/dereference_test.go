@@ -0,0 +1,113 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import "testing"
+
+func TestDereference(t *testing.T) {
+
+	xRefTable := newXRefTable(ValidationRelaxed)
+
+	// 1 0 obj 2 0 R, 2 0 obj 3 0 R, 3 0 obj 1 0 R
+	for i := 1; i <= 3; i++ {
+		xRefTable.Table[i] = NewXRefTableEntryGen0(*NewPDFIndirectRef(i%3+1, 0))
+	}
+
+	// 4 0 obj 5 0 R, 5 0 obj 42
+	xRefTable.Table[4] = NewXRefTableEntryGen0(*NewPDFIndirectRef(5, 0))
+	xRefTable.Table[5] = NewXRefTableEntryGen0(PDFInteger(42))
+
+	// 6 free
+	xRefTable.Table[6] = NewXRefTableEntryGen0(nil)
+	xRefTable.Table[6].Free = true
+
+	i, err := xRefTable.DereferenceInteger(*NewPDFIndirectRef(4, 0))
+	if err != nil || i == nil || *i != 42 {
+		t.Errorf("Dereference chain: got %v, %v\n", i, err)
+	}
+
+	for _, objNr := range []int{6, 7} {
+		o, err := xRefTable.Dereference(*NewPDFIndirectRef(objNr, 0))
+		if err != nil || o != nil {
+			t.Errorf("Dereference obj#%d: want null, got %v, %v\n", objNr, o, err)
+		}
+	}
+
+	for _, objNr := range []int{1, 2} {
+		_, err = xRefTable.Dereference(*NewPDFIndirectRef(objNr, 0))
+		e, ok := err.(*ReferenceCycleError)
+		if !ok {
+			t.Fatalf("Dereference obj#%d: want ReferenceCycleError, got %v\n", objNr, err)
+		}
+		if len(e.ObjNrs) != 3 || e.ObjNrs[0] != 1 {
+			t.Errorf("Dereference obj#%d: unexpected cycle %v\n", objNr, e.ObjNrs)
+		}
+	}
+
+	if len(xRefTable.Cycles) != 1 {
+		t.Errorf("want 1 reported cycle, got %d\n", len(xRefTable.Cycles))
+	}
+}
+
+func TestDereferenceModified(t *testing.T) {
+
+	xRefTable := newXRefTable(ValidationRelaxed)
+	xRefTable.Table[0] = NewFreeHeadXRefTableEntry()
+
+	// 1 0 obj 2 0 R, 2 0 obj 3 0 R, 3 0 obj 42
+	xRefTable.Table[1] = NewXRefTableEntryGen0(*NewPDFIndirectRef(2, 0))
+	xRefTable.Table[2] = NewXRefTableEntryGen0(*NewPDFIndirectRef(3, 0))
+	xRefTable.Table[3] = NewXRefTableEntryGen0(PDFInteger(42))
+	xRefTable.Table[4] = NewXRefTableEntryGen0(PDFInteger(4711))
+
+	// 5 0 obj 6 0 R, 6 0 obj 7
+	xRefTable.Table[5] = NewXRefTableEntryGen0(*NewPDFIndirectRef(6, 0))
+	xRefTable.Table[6] = NewXRefTableEntryGen0(PDFInteger(7))
+
+	deref := func(want PDFObject) {
+		t.Helper()
+		o, err := xRefTable.Dereference(*NewPDFIndirectRef(1, 0))
+		if err != nil || o != want {
+			t.Errorf("Dereference obj#1: want %v, got %v, %v\n", want, o, err)
+		}
+	}
+
+	deref(PDFInteger(42))
+
+	// Modified end of chain.
+	xRefTable.Table[3].Object = PDFInteger(43)
+	deref(PDFInteger(43))
+
+	// Extended chain.
+	xRefTable.Table[3].Object = *NewPDFIndirectRef(4, 0)
+	deref(PDFInteger(4711))
+
+	// Re-pointed start of chain.
+	xRefTable.Table[1].Object = *NewPDFIndirectRef(5, 0)
+	deref(PDFInteger(7))
+
+	// Modified start of chain.
+	xRefTable.Table[1].Object = PDFInteger(1)
+	deref(PDFInteger(1))
+	xRefTable.Table[1].Object = *NewPDFIndirectRef(2, 0)
+
+	// Freed link of chain.
+	if err := xRefTable.DeleteObject(2); err != nil {
+		t.Fatalf("DeleteObject: %v\n", err)
+	}
+	deref(nil)
+}
@@ -0,0 +1,636 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/pkg/errors"
+)
+
+// Comparing PDF files
+//
+// Diff compares two validated files page by page and the document level objects reachable from the catalog
+// and the document info dict. Objects are compared by structure, object numbers do not matter.
+// References to pages match if they refer to the same page number.
+// Streams are compared by their decoded content, so recompressed streams are equal.
+// Pixel based comparison needs rendering which pdfcpu does not provide.
+
+// diffIgnoredInfoKeys are document info entries updated whenever a file gets written.
+var diffIgnoredInfoKeys = StringSet{"ModDate": true, "Producer": true}
+
+// diffIgnoredStreamKeys are stream dict entries describing the encoding of the stream content.
+var diffIgnoredStreamKeys = StringSet{"Length": true, "Filter": true, "DecodeParms": true, "DL": true}
+
+// DiffReport lists the differences between two files A and B.
+type DiffReport struct {
+	PageCount [2]int      `json:"pageCount"`          // The page counts of A and B.
+	Document  []string    `json:"document,omitempty"` // Differences of document level objects.
+	Pages     []*PageDiff `json:"pages,omitempty"`    // Differences of pages present in A and B.
+}
+
+// PageDiff lists the differences of a page.
+type PageDiff struct {
+	PageNr  int      `json:"page"`
+	Objects []string `json:"objects,omitempty"` // Differences of the page dict and the objects reachable from it.
+	Text    []string `json:"text,omitempty"`    // Text lines removed ("-line") or added ("+line").
+}
+
+// Equal returns true if no differences have been found.
+func (r *DiffReport) Equal() bool {
+	return r.PageCount[0] == r.PageCount[1] && len(r.Document) == 0 && len(r.Pages) == 0
+}
+
+// Lines returns the differences as human readable lines, nil if there are none.
+func (r *DiffReport) Lines() []string {
+
+	if r.Equal() {
+		return nil
+	}
+
+	var ss []string
+
+	if r.PageCount[0] != r.PageCount[1] {
+		ss = append(ss, fmt.Sprintf("page count: %d => %d", r.PageCount[0], r.PageCount[1]))
+	}
+
+	for _, s := range r.Document {
+		ss = append(ss, "document: "+s)
+	}
+
+	for _, p := range r.Pages {
+		for _, s := range p.Objects {
+			ss = append(ss, fmt.Sprintf("page %d: %s", p.PageNr, s))
+		}
+		for _, s := range p.Text {
+			ss = append(ss, fmt.Sprintf("page %d: text %s", p.PageNr, s))
+		}
+	}
+
+	return ss
+}
+
+// JSON returns the JSON representation of r.
+func (r *DiffReport) JSON() ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(r); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// differ compares the object graphs of two files.
+type differ struct {
+	a, b           *XRefTable
+	pagesA, pagesB map[int]int     // page numbers by object number
+	visited        map[[2]int]bool // pairs of compared objects
+	diffs          []string
+}
+
+func (d *differ) add(path, format string, args ...interface{}) {
+	d.diffs = append(d.diffs, path+": "+fmt.Sprintf(format, args...))
+}
+
+// shortString returns the PDF representation of o cut to a reasonable length.
+func shortString(o PDFObject) string {
+
+	if o == nil {
+		return "null"
+	}
+
+	s := o.PDFString()
+
+	// Show text strings as text.
+	switch o := o.(type) {
+	case PDFStringLiteral:
+		if s1, err := StringLiteralToString(o.Value()); err == nil && utf8.ValidString(s1) {
+			s = "(" + s1 + ")"
+		}
+	case PDFHexLiteral:
+		if s1, err := HexLiteralToString(o.Value()); err == nil && utf8.ValidString(s1) {
+			s = "(" + s1 + ")"
+		}
+	}
+
+	if rr := []rune(s); len(rr) > 64 {
+		s = string(rr[:61]) + "..."
+	}
+
+	return s
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// pageNrs returns the page numbers of xRefTable by object number.
+func pageNrs(xRefTable *XRefTable) (map[int]int, error) {
+
+	m := map[int]int{}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+		indRef, err := xRefTable.PageDictIndRef(i)
+		if err != nil {
+			return nil, err
+		}
+		if indRef != nil {
+			m[indRef.ObjectNumber.Value()] = i
+		}
+	}
+
+	return m, nil
+}
+
+// compareRefs returns true if oa and ob are references that do not need to be followed.
+func (d *differ) compareRefs(path string, oa, ob PDFObject) bool {
+
+	ra, okA := oa.(PDFIndirectRef)
+	rb, okB := ob.(PDFIndirectRef)
+	if !okA || !okB {
+		return false
+	}
+
+	objNrA, objNrB := ra.ObjectNumber.Value(), rb.ObjectNumber.Value()
+
+	pageA, isPageA := d.pagesA[objNrA]
+	pageB, isPageB := d.pagesB[objNrB]
+
+	if isPageA || isPageB {
+		if pageA != pageB {
+			d.add(path, "page %d => page %d", pageA, pageB)
+		}
+		return true
+	}
+
+	key := [2]int{objNrA, objNrB}
+	if d.visited[key] {
+		return true
+	}
+	d.visited[key] = true
+
+	return false
+}
+
+func equalNumbers(oa, ob PDFObject) (equal, ok bool) {
+
+	number := func(o PDFObject) (float64, bool) {
+		switch o := o.(type) {
+		case PDFInteger:
+			return float64(o), true
+		case PDFFloat:
+			return float64(o), true
+		}
+		return 0, false
+	}
+
+	fa, okA := number(oa)
+	fb, okB := number(ob)
+	if !okA || !okB {
+		return false, false
+	}
+
+	// Reals get written with 2 decimal places.
+	return math.Abs(fa-fb) <= 0.005, true
+}
+
+func stringBytesForDiff(o PDFObject) ([]byte, bool) {
+
+	switch o := o.(type) {
+	case PDFStringLiteral:
+		b, err := Unescape(o.Value())
+		return b, err == nil
+	case PDFHexLiteral:
+		b, err := o.Bytes()
+		return b, err == nil
+	}
+
+	return nil, false
+}
+
+func (d *differ) compare(path string, oa, ob PDFObject) error {
+
+	if err := d.a.canceled(); err != nil {
+		return err
+	}
+
+	if d.compareRefs(path, oa, ob) {
+		return nil
+	}
+
+	oa, err := d.a.Dereference(oa)
+	if err != nil {
+		return err
+	}
+
+	ob, err = d.b.Dereference(ob)
+	if err != nil {
+		return err
+	}
+
+	switch a := oa.(type) {
+
+	case PDFDict:
+		if b, ok := ob.(PDFDict); ok {
+			return d.compareDicts(path, a, b, nil)
+		}
+
+	case PDFStreamDict:
+		if b, ok := ob.(PDFStreamDict); ok {
+			return d.compareStreams(path, a, b)
+		}
+
+	case PDFArray:
+		if b, ok := ob.(PDFArray); ok {
+			return d.compareArrays(path, a, b)
+		}
+
+	default:
+		if equal, ok := equalNumbers(oa, ob); ok {
+			if !equal {
+				d.add(path, "%s => %s", shortString(oa), shortString(ob))
+			}
+			return nil
+		}
+
+		if ba, ok := stringBytesForDiff(oa); ok {
+			if bb, ok := stringBytesForDiff(ob); ok && bytes.Equal(ba, bb) {
+				return nil
+			}
+		}
+
+		if oa == nil && ob == nil || oa != nil && ob != nil && oa.PDFString() == ob.PDFString() {
+			return nil
+		}
+	}
+
+	d.add(path, "%s => %s", shortString(oa), shortString(ob))
+
+	return nil
+}
+
+func (d *differ) compareArrays(path string, a, b PDFArray) error {
+
+	for i := 0; i < len(a) || i < len(b); i++ {
+
+		p := fmt.Sprintf("%s[%d]", path, i)
+
+		switch {
+
+		case i >= len(b):
+			d.add(p, "removed %s", shortString(a[i]))
+
+		case i >= len(a):
+			d.add(p, "added %s", shortString(b[i]))
+
+		default:
+			if err := d.compare(p, a[i], b[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *differ) compareDicts(path string, a, b PDFDict, ignore StringSet) error {
+
+	keys := StringSet{}
+	for k := range a.Dict {
+		keys[k] = true
+	}
+	for k := range b.Dict {
+		keys[k] = true
+	}
+
+	var kk []string
+	for k := range keys {
+		// Parent entries lead back up the tree.
+		if k != "Parent" && !ignore[k] {
+			kk = append(kk, k)
+		}
+	}
+	sort.Strings(kk)
+
+	for _, k := range kk {
+
+		oa, foundA := a.Find(k)
+		ob, foundB := b.Find(k)
+		p := joinPath(path, k)
+
+		switch {
+
+		case !foundB:
+			d.add(p, "removed %s", shortString(oa))
+
+		case !foundA:
+			d.add(p, "added %s", shortString(ob))
+
+		default:
+			if err := d.compare(p, oa, ob); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// decodedStreamContent returns the decoded content of sd or the encoded content for unsupported filters.
+func decodedStreamContent(xRefTable *XRefTable, sd PDFStreamDict) ([]byte, error) {
+
+	err := xRefTable.decodeStream(&sd)
+	if errors.Cause(err) == filter.ErrUnsupportedFilter {
+		return sd.Raw, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sd.Content, nil
+}
+
+func (d *differ) compareStreams(path string, a, b PDFStreamDict) error {
+
+	if err := d.compareDicts(path, a.PDFDict, b.PDFDict, diffIgnoredStreamKeys); err != nil {
+		return err
+	}
+
+	ca, err := decodedStreamContent(d.a, a)
+	if err != nil {
+		return err
+	}
+
+	cb, err := decodedStreamContent(d.b, b)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(ca, cb) {
+		if path == "" {
+			path = "stream"
+		}
+		d.add(path, "stream content differs (%d => %d bytes)", len(ca), len(cb))
+	}
+
+	return nil
+}
+
+// diffLines returns the lines removed from la ("-line") and added in lb ("+line") based on a longest common subsequence.
+func diffLines(la, lb []string) []string {
+
+	n, m := len(la), len(lb)
+
+	// lcs[i][j] is the length of the longest common subsequence of la[i:] and lb[j:].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if la[i] == lb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ss []string
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && la[i] == lb[j]:
+			i++
+			j++
+		case j == m || i < n && lcs[i+1][j] >= lcs[i][j+1]:
+			ss = append(ss, "-"+la[i])
+			i++
+		default:
+			ss = append(ss, "+"+lb[j])
+			j++
+		}
+	}
+
+	return ss
+}
+
+func pageText(xRefTable *XRefTable, pageNr int) ([]string, error) {
+
+	lines, err := pageTextLines(xRefTable, pageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := make([]string, len(lines))
+	for i, l := range lines {
+		ss[i] = l.String()
+	}
+
+	return ss, nil
+}
+
+// effectivePageDict returns a copy of the page dict of pageNr including inherited attributes.
+func effectivePageDict(xRefTable *XRefTable, pageNr int) (PDFDict, error) {
+
+	pageDict, inhPAttrs, err := xRefTable.PageDict(pageNr)
+	if err != nil {
+		return PDFDict{}, err
+	}
+
+	if pageDict == nil {
+		return PDFDict{}, errors.Errorf("pdfcpu: diff: missing page %d", pageNr)
+	}
+
+	d := NewPDFDict()
+	for k, v := range pageDict.Dict {
+		d.Dict[k] = v
+	}
+
+	if _, found := d.Find("Resources"); !found && inhPAttrs.resources != nil {
+		d.Insert("Resources", *inhPAttrs.resources)
+	}
+
+	if _, found := d.Find("MediaBox"); !found && inhPAttrs.mediaBox != nil {
+		d.Insert("MediaBox", *inhPAttrs.mediaBox)
+	}
+
+	if _, found := d.Find("CropBox"); !found && inhPAttrs.cropBox != nil {
+		d.Insert("CropBox", *inhPAttrs.cropBox)
+	}
+
+	if _, found := d.Find("Rotate"); !found && inhPAttrs.rotate != 0 {
+		d.Insert("Rotate", PDFFloat(inhPAttrs.rotate))
+	}
+
+	return d, nil
+}
+
+func (d *differ) comparePage(pageNr int) (*PageDiff, error) {
+
+	da, err := effectivePageDict(d.a, pageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := effectivePageDict(d.b, pageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	d.diffs = nil
+
+	// The page content may be split into several streams, compare it as a whole.
+	if err = d.compareDicts("", da, db, StringSet{"Contents": true}); err != nil {
+		return nil, err
+	}
+
+	ca, err := pageContent(d.a, &da, false)
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := pageContent(d.b, &db, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(ca, cb) {
+		d.add("Contents", "content differs (%d => %d bytes)", len(ca), len(cb))
+	}
+
+	pd := &PageDiff{PageNr: pageNr, Objects: d.diffs}
+
+	ta, err := pageText(d.a, pageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	tb, err := pageText(d.b, pageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	pd.Text = diffLines(ta, tb)
+
+	return pd, nil
+}
+
+func (d *differ) compareDocument() ([]string, error) {
+
+	d.diffs = nil
+
+	catA, err := d.a.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	catB, err := d.b.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	// Pages are compared one by one.
+	if err = d.compareDicts("Root", *catA, *catB, StringSet{"Pages": true}); err != nil {
+		return nil, err
+	}
+
+	var infoA, infoB PDFObject
+	if d.a.Info != nil {
+		infoA = *d.a.Info
+	}
+	if d.b.Info != nil {
+		infoB = *d.b.Info
+	}
+
+	ia, err := d.a.DereferenceDict(infoA)
+	if err != nil {
+		return nil, err
+	}
+
+	ib, err := d.b.DereferenceDict(infoB)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ia != nil && ib != nil:
+		err = d.compareDicts("Info", *ia, *ib, diffIgnoredInfoKeys)
+	case ia != nil:
+		d.add("Info", "removed")
+	case ib != nil:
+		d.add("Info", "added")
+	}
+
+	return d.diffs, err
+}
+
+// Diff compares the validated files ctxA and ctxB.
+func Diff(ctxA, ctxB *PDFContext) (*DiffReport, error) {
+
+	d := &differ{a: ctxA.XRefTable, b: ctxB.XRefTable, visited: map[[2]int]bool{}}
+
+	var err error
+
+	if d.pagesA, err = pageNrs(d.a); err != nil {
+		return nil, err
+	}
+
+	if d.pagesB, err = pageNrs(d.b); err != nil {
+		return nil, err
+	}
+
+	r := &DiffReport{PageCount: [2]int{ctxA.PageCount, ctxB.PageCount}}
+
+	for i := 1; i <= ctxA.PageCount && i <= ctxB.PageCount; i++ {
+
+		pd, err := d.comparePage(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pd.Objects) > 0 || len(pd.Text) > 0 {
+			r.Pages = append(r.Pages, pd)
+		}
+	}
+
+	// Objects already compared for pages are not reported again.
+	if r.Document, err = d.compareDocument(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
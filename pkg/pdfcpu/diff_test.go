@@ -0,0 +1,39 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+
+	for _, c := range []struct {
+		a, b, want []string
+	}{
+		{[]string{"a", "b", "c"}, []string{"a", "b", "c"}, nil},
+		{[]string{"a", "b", "c"}, []string{"a", "c"}, []string{"-b"}},
+		{[]string{"a", "c"}, []string{"a", "b", "c", "d"}, []string{"+b", "+d"}},
+		{[]string{"a", "b"}, []string{"a", "x"}, []string{"-b", "+x"}},
+		{nil, []string{"a"}, []string{"+a"}},
+	} {
+		if got := diffLines(c.a, c.b); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("diffLines(%v, %v): got %v, want %v\n", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Errors
+//
+// Failures callers may want to react on are reported by errors matching the following sentinels by errors.Is
+// or by the error types ValidationError, MemoryLimitError and ReferenceCycleError matching by errors.As.
+// Errors matching sentinels keep their messages, errors.Cause still yields the underlying error.
+
+var (
+	// ErrEncrypted reports an encrypted file where an unencrypted file is required.
+	ErrEncrypted = errors.New("pdfcpu: file is encrypted")
+
+	// ErrNotEncrypted reports an unencrypted file where an encrypted file is required.
+	ErrNotEncrypted = errors.New("pdfcpu: file is not encrypted")
+
+	// ErrWrongPassword reports a failed password authentication.
+	ErrWrongPassword = errors.New("pdfcpu: wrong password")
+
+	// ErrCorruptXRef reports a cross reference table that cannot be read.
+	ErrCorruptXRef = errors.New("pdfcpu: corrupt cross reference table")
+)
+
+// kindError is an error matching a sentinel by errors.Is.
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+
+func (e *kindError) Unwrap() error { return e.err }
+
+func (e *kindError) Cause() error { return e.err }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// newErrorf returns an error formatted like errors.Errorf matching kind.
+func newErrorf(kind error, format string, args ...interface{}) error {
+	return &kindError{errors.Errorf(format, args...), kind}
+}
+
+// markError returns err matching kind unless err is nil or has been caused by cancellation or a memory limit.
+func markError(kind error, err error) error {
+
+	if err == nil || isInterruption(err) {
+		return err
+	}
+
+	return &kindError{err, kind}
+}
+
+// isInterruption returns true if err has been caused by cancellation or by exceeding a memory limit.
+func isInterruption(err error) bool {
+
+	switch errors.Cause(err) {
+	case context.Canceled, context.DeadlineExceeded:
+		return true
+	}
+
+	return IsMemoryLimitError(err)
+}
+
+// ValidationError reports a violation of ISO 32000 found during validation.
+// ObjNr and PageNr locate the violation if known.
+type ValidationError struct {
+	ObjNr  int   // The object being validated or 0 if unknown.
+	PageNr int   // The page being validated or 0 if unknown.
+	Err    error // The violation.
+}
+
+func (e *ValidationError) Error() string {
+
+	switch {
+	case e.PageNr > 0:
+		return fmt.Sprintf("validation error: page %d (obj#%d): %v", e.PageNr, e.ObjNr, e.Err)
+	case e.ObjNr > 0:
+		return fmt.Sprintf("validation error: obj#%d: %v", e.ObjNr, e.Err)
+	}
+
+	return fmt.Sprintf("validation error: %v", e.Err)
+}
+
+// Unwrap returns the violation.
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Cause returns the violation, see github.com/pkg/errors.
+func (e *ValidationError) Cause() error { return e.Err }
+
+// validationError returns err as *ValidationError located at objNr and pageNr.
+// Errors already located as well as interruptions are returned unchanged.
+func validationError(err error, objNr, pageNr int) error {
+
+	if err == nil || isInterruption(err) {
+		return err
+	}
+
+	var e *ValidationError
+	if errors.As(err, &e) {
+		return err
+	}
+
+	return &ValidationError{ObjNr: objNr, PageNr: pageNr, Err: err}
+}
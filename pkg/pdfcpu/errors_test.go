@@ -0,0 +1,103 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// orphanPageFile returns a two page PDF file whose second page is missing its parent.
+func orphanPageFile() []byte {
+
+	var buf bytes.Buffer
+	var offsets []int
+
+	buf.WriteString("%PDF-1.7\n")
+
+	for _, s := range []string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids[3 0 R 4 0 R]/Count 2/MediaBox[0 0 612 792]>>",
+		"<</Type/Page/Parent 2 0 R/Resources<<>>/Contents 5 0 R>>",
+		"<</Type/Page/Resources<<>>/Contents 5 0 R>>",
+		"<</Length 8>>\nstream\n0 0 m S\n\nendstream",
+	} {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), s)
+	}
+
+	xrefOff := buf.Len()
+
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<</Size %d/Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOff)
+
+	return buf.Bytes()
+}
+
+func TestValidationError(t *testing.T) {
+
+	for _, workers := range []int{0, 4} {
+
+		config := NewDefaultConfiguration()
+		config.Workers = workers
+
+		ctx, err := Read(bytes.NewReader(orphanPageFile()), config)
+		if err != nil {
+			t.Fatalf("workers=%d: %v\n", workers, err)
+		}
+
+		err = ValidateXRefTable(ctx.XRefTable)
+
+		var e *ValidationError
+		if !errors.As(err, &e) {
+			t.Fatalf("workers=%d: got %v, want *ValidationError\n", workers, err)
+		}
+
+		if e.PageNr != 2 || e.ObjNr != 4 {
+			t.Errorf("workers=%d: got page %d obj#%d, want page 2 obj#4\n", workers, e.PageNr, e.ObjNr)
+		}
+
+		// Selected pages get located too.
+		err = ValidatePages(ctx.XRefTable, IntSet{2: true})
+		if !errors.As(err, &e) || e.PageNr != 2 || e.ObjNr != 4 {
+			t.Errorf("workers=%d: ValidatePages: got %v\n", workers, err)
+		}
+
+		if err = ValidateObject(ctx.XRefTable, 4); !errors.As(err, &e) || e.ObjNr != 4 {
+			t.Errorf("workers=%d: ValidateObject: got %v\n", workers, err)
+		}
+	}
+}
+
+func TestErrCorruptXRef(t *testing.T) {
+
+	_, err := Read(bytes.NewReader([]byte("%PDF-1.7\nno objects, no xref\n")), NewDefaultConfiguration())
+
+	if !errors.Is(err, ErrCorruptXRef) {
+		t.Fatalf("got %v, want ErrCorruptXRef\n", err)
+	}
+
+	if errors.Is(err, ErrEncrypted) {
+		t.Errorf("%v matches ErrEncrypted\n", err)
+	}
+}
@@ -29,9 +29,11 @@ import (
 // TODO: Implementation and usage of these filters: DCTDecode and JPXDecode.
 func ExtractImageData(ctx *PDFContext, objNr int) (*ImageObject, error) {
 
-	imageObj := ctx.Optimize.ImageObjects[objNr]
+	// Images get decoded into a copy since concurrent extractions share ctx.
+	imageObj := *ctx.Optimize.ImageObjects[objNr]
 
-	imageDict := imageObj.ImageDict
+	imageDict := *imageObj.ImageDict
+	imageObj.ImageDict = &imageDict
 
 	fpl := imageDict.FilterPipeline
 	if fpl == nil {
@@ -68,22 +70,28 @@ func ExtractImageData(ctx *PDFContext, objNr int) (*ImageObject, error) {
 		return nil, nil
 	}
 
-	switch fpl[0].Name {
+	switch name := fpl[0].Name; {
 
-	case filter.Flate:
+	case name == filter.DCT:
+		//imageObj.Extension = "jpg"
+
+	case name == filter.JPX:
+		//imageObj.Extension = "jpx"
+
+	case name == filter.Flate, filter.Registered(name):
+		// Registered filters decode into image samples like Flate.
 		//imageObj.Extension = "png"
 		// If color space is CMYK then write .tif else write .png
-		err := decodeStream(imageDict)
+		if w, h := imageDict.IntEntry("Width"), imageDict.IntEntry("Height"); w != nil && h != nil {
+			if err := ctx.checkImageSize(*w, *h, objNr); err != nil {
+				return nil, err
+			}
+		}
+		err := ctx.decodeStreamObj(&imageDict, objNr)
 		if err != nil {
 			return nil, err
 		}
 
-	case filter.DCT:
-		//imageObj.Extension = "jpg"
-
-	case filter.JPX:
-		//imageObj.Extension = "jpx"
-
 	//case filter.CCITTFax:
 	// use 	T6.pdf
 
@@ -92,14 +100,15 @@ func ExtractImageData(ctx *PDFContext, objNr int) (*ImageObject, error) {
 		return nil, nil
 	}
 
-	return imageObj, nil
+	return &imageObj, nil
 }
 
 // ExtractFontData extracts font data (the "fontfile") for objNr.
 // Supported fontTypes: TrueType
 func ExtractFontData(ctx *PDFContext, objNr int) (*FontObject, error) {
 
-	fontObject := ctx.Optimize.FontObjects[objNr]
+	// Font data gets extracted into a copy since concurrent extractions share ctx.
+	fontObject := *ctx.Optimize.FontObjects[objNr]
 
 	// Only embedded fonts have binary data.
 	if !fontObject.Embedded() {
@@ -140,7 +149,7 @@ func ExtractFontData(ctx *PDFContext, objNr int) (*FontObject, error) {
 		}
 
 		// Decode streamDict if used filter is supported only.
-		err = decodeStream(sd)
+		err = ctx.decodeStreamObj(sd, objNr)
 		if err == filter.ErrUnsupportedFilter {
 			return nil, nil
 		}
@@ -156,7 +165,7 @@ func ExtractFontData(ctx *PDFContext, objNr int) (*FontObject, error) {
 		return nil, nil
 	}
 
-	return fontObject, nil
+	return &fontObject, nil
 }
 
 // ExtractContentData extracts page content in PDF notation for objNr.
@@ -175,7 +184,7 @@ func ExtractContentData(ctx *PDFContext, objNr int) (data []byte, err error) {
 	}
 
 	// Decode streamDict for supported filters only.
-	err = decodeStream(sd)
+	err = ctx.decodeStreamObj(sd, objNr)
 	if err == filter.ErrUnsupportedFilter {
 		return nil, nil
 	}
@@ -0,0 +1,498 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// This file implements form data exchange using
+// the Forms Data Format FDF (see 12.7.8) and its XML counterpart XFDF (see the Adobe XFDF specification).
+
+const xfdfNamespace = "http://ns.adobe.com/xfdf/"
+
+// formDataNode represents a node of the field hierarchy of exported form data.
+type formDataNode struct {
+	name   string      // partial field name
+	value  interface{} // nil, string or []string
+	isName bool        // value is written as PDF name (button fields)
+	kids   []*formDataNode
+}
+
+func (n *formDataNode) kid(name string) *formDataNode {
+
+	for _, k := range n.kids {
+		if k.name == name {
+			return k
+		}
+	}
+
+	k := &formDataNode{name: name}
+	n.kids = append(n.kids, k)
+
+	return k
+}
+
+// exportValue returns the value of a field as string or []string for multiple selections.
+func exportValue(xRefTable *XRefTable, obj PDFObject) (interface{}, error) {
+
+	o, err := xRefTable.Dereference(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	arr, ok := o.(PDFArray)
+	if !ok {
+		return fieldValueString(xRefTable, o)
+	}
+
+	ss := []string{}
+
+	for _, v := range arr {
+		s, err := fieldValueString(xRefTable, v)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// formData returns the values of all exportable fields of the interactive form of a PDF as field hierarchy.
+func formData(xRefTable *XRefTable) (*formDataNode, error) {
+
+	root := &formDataNode{}
+
+	err := processFormFields(xRefTable, func(f *formField) error {
+
+		t := fieldTypeString(f.fa.ft, f.fa.ff)
+		if t == "pushbutton" || t == "signature" || f.fa.ff&FieldNoExport > 0 {
+			return nil
+		}
+
+		v, err := exportValue(xRefTable, f.fa.v)
+		if err != nil {
+			return err
+		}
+
+		isName := t == "checkbox" || t == "radio"
+		if isName && v == "" {
+			v = "Off"
+		}
+
+		n := root
+		for _, s := range strings.Split(f.name, ".") {
+			n = n.kid(s)
+		}
+
+		n.value = v
+		n.isName = isName
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+func fdfFieldDict(n *formDataNode) PDFDict {
+
+	d := NewPDFDict()
+	d.Insert("T", encodeText(n.name))
+
+	switch v := n.value.(type) {
+
+	case string:
+		if n.isName {
+			d.Insert("V", PDFName(v))
+		} else {
+			d.Insert("V", encodeText(v))
+		}
+
+	case []string:
+		arr := PDFArray{}
+		for _, s := range v {
+			arr = append(arr, encodeText(s))
+		}
+		d.Insert("V", arr)
+	}
+
+	if len(n.kids) > 0 {
+		kids := PDFArray{}
+		for _, k := range n.kids {
+			kids = append(kids, fdfFieldDict(k))
+		}
+		d.Insert("Kids", kids)
+	}
+
+	return d
+}
+
+// ExportFDF returns the form data of a PDF as FDF file.
+// fileName is the name of the PDF file the data belongs to and may be empty.
+func ExportFDF(xRefTable *XRefTable, fileName string) ([]byte, error) {
+
+	log.Debug.Println("ExportFDF begin")
+
+	root, err := formData(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := PDFArray{}
+	for _, k := range root.kids {
+		fields = append(fields, fdfFieldDict(k))
+	}
+
+	fdfDict := NewPDFDict()
+	fdfDict.Insert("Fields", fields)
+	if len(fileName) > 0 {
+		fdfDict.Insert("F", encodeText(fileName))
+	}
+
+	catalog := NewPDFDict()
+	catalog.Insert("FDF", fdfDict)
+
+	var b bytes.Buffer
+	b.WriteString("%FDF-1.2\n%\xE2\xE3\xCF\xD3\n")
+	b.WriteString("1 0 obj\n")
+	b.WriteString(catalog.PDFString())
+	b.WriteString("\nendobj\ntrailer\n<</Root 1 0 R>>\n%%EOF\n")
+
+	log.Debug.Println("ExportFDF end")
+
+	return b.Bytes(), nil
+}
+
+// xfdf represents the root element of an XFDF file.
+type xfdf struct {
+	XMLName xml.Name    `xml:"xfdf"`
+	Xmlns   string      `xml:"xmlns,attr,omitempty"`
+	Fields  []xfdfField `xml:"fields>field"`
+	F       *xfdfFile   `xml:"f,omitempty"`
+}
+
+type xfdfField struct {
+	Name   string      `xml:"name,attr"`
+	Values []string    `xml:"value"`
+	Fields []xfdfField `xml:"field"`
+}
+
+type xfdfFile struct {
+	Href string `xml:"href,attr"`
+}
+
+func xfdfFieldFor(n *formDataNode) xfdfField {
+
+	f := xfdfField{Name: n.name}
+
+	switch v := n.value.(type) {
+
+	case string:
+		f.Values = []string{v}
+
+	case []string:
+		f.Values = v
+	}
+
+	for _, k := range n.kids {
+		f.Fields = append(f.Fields, xfdfFieldFor(k))
+	}
+
+	return f
+}
+
+// ExportXFDF returns the form data of a PDF as XFDF file.
+// fileName is the name of the PDF file the data belongs to and may be empty.
+func ExportXFDF(xRefTable *XRefTable, fileName string) ([]byte, error) {
+
+	log.Debug.Println("ExportXFDF begin")
+
+	root, err := formData(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	x := xfdf{Xmlns: xfdfNamespace}
+
+	for _, k := range root.kids {
+		x.Fields = append(x.Fields, xfdfFieldFor(k))
+	}
+
+	if len(fileName) > 0 {
+		x.F = &xfdfFile{Href: fileName}
+	}
+
+	bb, err := xml.MarshalIndent(x, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("ExportXFDF end")
+
+	return append([]byte(xml.Header), append(bb, '\n')...), nil
+}
+
+func addXFDFField(m map[string]interface{}, prefix string, f xfdfField) {
+
+	name := f.Name
+	if len(prefix) > 0 {
+		name = prefix + "." + name
+	}
+
+	for _, k := range f.Fields {
+		addXFDFField(m, name, k)
+	}
+
+	switch len(f.Values) {
+
+	case 0:
+		if len(f.Fields) == 0 {
+			m[name] = ""
+		}
+
+	case 1:
+		m[name] = f.Values[0]
+
+	default:
+		arr := make([]interface{}, len(f.Values))
+		for i, s := range f.Values {
+			arr[i] = s
+		}
+		m[name] = arr
+	}
+}
+
+// ParseXFDF parses XFDF form data into a map of fully qualified field names to values as expected by FillForm.
+func ParseXFDF(bb []byte) (map[string]interface{}, error) {
+
+	var x xfdf
+
+	err := xml.Unmarshal(bb, &x)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseXFDF")
+	}
+
+	m := map[string]interface{}{}
+
+	for _, f := range x.Fields {
+		addXFDFField(m, "", f)
+	}
+
+	return m, nil
+}
+
+var fdfObjHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// fdfObjects parses all indirect objects and the trailer of an FDF file.
+func fdfObjects(s string) (map[int]PDFObject, *PDFDict, error) {
+
+	objs := map[int]PDFObject{}
+
+	for {
+
+		loc := fdfObjHeader.FindStringSubmatchIndex(s)
+		if loc == nil {
+			break
+		}
+
+		objNr, err := strconv.Atoi(s[loc[2]:loc[3]])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s = s[loc[1]:]
+
+		o, err := parseObject(&s)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "fdf: corrupt object #%d", objNr)
+		}
+
+		objs[objNr] = o
+	}
+
+	i := strings.Index(s, "trailer")
+	if i < 0 {
+		return objs, nil, nil
+	}
+
+	s = s[i+len("trailer"):]
+
+	o, err := parseObject(&s)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "fdf: corrupt trailer")
+	}
+
+	trailer, ok := o.(PDFDict)
+	if !ok {
+		return nil, nil, errors.New("fdf: corrupt trailer")
+	}
+
+	return objs, &trailer, nil
+}
+
+// fdfDereference resolves indirect references within an FDF file.
+func fdfDereference(objs map[int]PDFObject, o PDFObject) PDFObject {
+
+	// Bound the number of hops in order to survive reference cycles.
+	for i := 0; i <= len(objs); i++ {
+		indRef, ok := o.(PDFIndirectRef)
+		if !ok {
+			return o
+		}
+		o = objs[indRef.ObjectNumber.Value()]
+	}
+
+	return nil
+}
+
+func fdfString(o PDFObject) (string, error) {
+
+	switch o := o.(type) {
+
+	case PDFStringLiteral:
+		return StringLiteralToString(o.Value())
+
+	case PDFHexLiteral:
+		return HexLiteralToString(o.Value())
+
+	case PDFName:
+		return o.Value(), nil
+
+	case nil:
+		return "", nil
+	}
+
+	return "", errors.Errorf("fdf: unsupported value: %v", o)
+}
+
+func addFDFField(objs map[int]PDFObject, m map[string]interface{}, prefix string, o PDFObject, depth int) error {
+
+	if depth > 32 {
+		return errors.New("fdf: field hierarchy too deep")
+	}
+
+	d, ok := fdfDereference(objs, o).(PDFDict)
+	if !ok {
+		return errors.New("fdf: corrupt field dict")
+	}
+
+	t, err := fdfString(fdfDereference(objs, d.Dict["T"]))
+	if err != nil {
+		return err
+	}
+
+	name := t
+	if len(prefix) > 0 {
+		name = prefix + "." + t
+	}
+
+	if kids, ok := fdfDereference(objs, d.Dict["Kids"]).(PDFArray); ok {
+		for _, k := range kids {
+			err = addFDFField(objs, m, name, k, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	v, found := d.Find("V")
+	if !found {
+		return nil
+	}
+
+	v = fdfDereference(objs, v)
+
+	arr, ok := v.(PDFArray)
+	if !ok {
+		s, err := fdfString(v)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", name)
+		}
+		m[name] = s
+		return nil
+	}
+
+	vv := make([]interface{}, len(arr))
+	for i, o := range arr {
+		s, err := fdfString(fdfDereference(objs, o))
+		if err != nil {
+			return errors.Wrapf(err, "field %s", name)
+		}
+		vv[i] = s
+	}
+
+	m[name] = vv
+
+	return nil
+}
+
+// ParseFDF parses FDF form data into a map of fully qualified field names to values as expected by FillForm.
+func ParseFDF(bb []byte) (map[string]interface{}, error) {
+
+	if !bytes.HasPrefix(bytes.TrimSpace(bb), []byte("%FDF-")) {
+		return nil, errors.New("ParseFDF: missing FDF header")
+	}
+
+	objs, trailer, err := fdfObjects(string(bb))
+	if err != nil {
+		return nil, err
+	}
+
+	// Locate the catalog.
+	var catalog PDFObject
+	if trailer != nil {
+		catalog = fdfDereference(objs, trailer.Dict["Root"])
+	}
+	if catalog == nil {
+		catalog = objs[1]
+	}
+
+	d, ok := catalog.(PDFDict)
+	if !ok {
+		return nil, errors.New("ParseFDF: missing catalog")
+	}
+
+	fdfDict, ok := fdfDereference(objs, d.Dict["FDF"]).(PDFDict)
+	if !ok {
+		return nil, errors.New("ParseFDF: missing FDF dict")
+	}
+
+	m := map[string]interface{}{}
+
+	fields, _ := fdfDereference(objs, fdfDict.Dict["Fields"]).(PDFArray)
+
+	for _, f := range fields {
+		err = addFDFField(objs, m, "", f, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// File systems
+//
+// Files used as input like images, fonts, attachments, ICC profiles, form data or XFDF files
+// get read from Configuration.FS, eg. an embed.FS, an fstest.MapFS or a cloud storage adapter.
+// Files being written like PDF files, extracted images, fonts, content, attachments or exported data
+// get created by Configuration.CreateFile.
+// If unset the local file system is used.
+//
+// File names get passed to FS and CreateFile as cleaned slash separated paths.
+// For FS they need to be relative to its root, see fs.ValidPath.
+// The PDF files being processed get read from Configuration.FS too. Reading them needs to seek,
+// so files not implementing io.Seeker get read into memory as a whole.
+
+// CreateFunc creates the file name for writing.
+type CreateFunc func(name string) (io.WriteCloser, error)
+
+// fsName returns name as path valid for fs.FS.
+func fsName(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+func openFile(fsys fs.FS, name string) (io.ReadCloser, error) {
+
+	if fsys == nil {
+		return os.Open(name)
+	}
+
+	return fsys.Open(fsName(name))
+}
+
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+
+	if fsys == nil {
+		return ioutil.ReadFile(name)
+	}
+
+	return fs.ReadFile(fsys, fsName(name))
+}
+
+type readSeekNopCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekNopCloser) Close() error { return nil }
+
+// openReadSeeker opens name for reading with seeking,
+// files not implementing io.Seeker get read into memory.
+func openReadSeeker(fsys fs.FS, name string) (io.ReadSeekCloser, error) {
+
+	if fsys == nil {
+		return os.Open(name)
+	}
+
+	f, err := fsys.Open(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if rsc, ok := f.(io.ReadSeekCloser); ok {
+		return rsc, nil
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err = closeFile(f, err); err != nil {
+		return nil, err
+	}
+
+	return readSeekNopCloser{bytes.NewReader(b)}, nil
+}
+
+func statFile(fsys fs.FS, name string) (fs.FileInfo, error) {
+
+	if fsys == nil {
+		return os.Stat(name)
+	}
+
+	return fs.Stat(fsys, fsName(name))
+}
+
+func createFile(create CreateFunc, name string) (io.WriteCloser, error) {
+
+	if create == nil {
+		return os.Create(name)
+	}
+
+	return create(fsName(name))
+}
+
+// closeFile closes w returning err or else the closing error.
+func closeFile(w io.Closer, err error) error {
+
+	// Processing error takes precedence.
+	if err1 := w.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
+
+func writeFile(create CreateFunc, name string, b []byte) error {
+
+	w, err := createFile(create, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return closeFile(w, err)
+}
+
+// OpenInput opens the input file name from c.FS.
+func (c *Configuration) OpenInput(name string) (io.ReadCloser, error) {
+	return openFile(c.FS, name)
+}
+
+// OpenReadSeeker opens the input file name from c.FS for reading with seeking.
+// Files not implementing io.Seeker get read into memory.
+func (c *Configuration) OpenReadSeeker(name string) (io.ReadSeekCloser, error) {
+	return openReadSeeker(c.FS, name)
+}
+
+// ReadInput reads the input file name from c.FS.
+func (c *Configuration) ReadInput(name string) ([]byte, error) {
+	return readFile(c.FS, name)
+}
+
+// CreateOutput creates the output file name using c.CreateFile.
+func (c *Configuration) CreateOutput(name string) (io.WriteCloser, error) {
+	return createFile(c.CreateFile, name)
+}
+
+// WriteOutput writes b to the output file name created using c.CreateFile.
+func (c *Configuration) WriteOutput(name string, b []byte) error {
+	return writeFile(c.CreateFile, name, b)
+}
+
+func (xRefTable *XRefTable) openFile(name string) (io.ReadCloser, error) {
+	return openFile(xRefTable.FS, name)
+}
+
+func (xRefTable *XRefTable) openReadSeeker(name string) (io.ReadSeekCloser, error) {
+	return openReadSeeker(xRefTable.FS, name)
+}
+
+func (xRefTable *XRefTable) readFile(name string) ([]byte, error) {
+	return readFile(xRefTable.FS, name)
+}
+
+func (xRefTable *XRefTable) statFile(name string) (fs.FileInfo, error) {
+	return statFile(xRefTable.FS, name)
+}
+
+func (xRefTable *XRefTable) createFile(name string) (io.WriteCloser, error) {
+	return createFile(xRefTable.CreateFile, name)
+}
+
+func (xRefTable *XRefTable) writeFile(name string, b []byte) error {
+	return writeFile(xRefTable.CreateFile, name, b)
+}
@@ -20,8 +20,8 @@ package pdfcpu
 
 import (
 	"bytes"
+	"compress/zlib"
 	"encoding/hex"
-	"fmt"
 	"io"
 
 	"github.com/hhrutter/pdfcpu/pkg/filter"
@@ -50,6 +50,12 @@ func parmsForFilter(d *PDFDict) map[string]int {
 
 // encodeStream encodes stream dict data by applying its filter pipeline.
 func encodeStream(sd *PDFStreamDict) error {
+	return encodeStreamLevel(sd, zlib.DefaultCompression)
+}
+
+// encodeStreamLevel encodes stream dict data by applying its filter pipeline
+// using the zlib compression level level for Flate encoding.
+func encodeStreamLevel(sd *PDFStreamDict, level int) error {
 
 	log.Debug.Printf("encodeStream begin")
 
@@ -69,7 +75,8 @@ func encodeStream(sd *PDFStreamDict) error {
 	var b io.Reader
 	b = bytes.NewReader(sd.Content)
 
-	var c *bytes.Buffer
+	// A pipeline consisting of a Crypt filter only leaves the content as is.
+	c := bytes.NewBuffer(sd.Content)
 
 	// Apply each filter in the pipeline to result of preceding filter.
 	for _, f := range sd.FilterPipeline {
@@ -80,10 +87,22 @@ func encodeStream(sd *PDFStreamDict) error {
 			log.Debug.Printf("encodeStream: encoding filter:%s\n", f.Name)
 		}
 
+		// Decryption is up to the security handler.
+		if f.Name == filter.Crypt {
+			continue
+		}
+
 		// make parms map[string]int
 		parms := parmsForFilter(f.DecodeParms)
 
-		fi, err := filter.NewFilter(f.Name, parms)
+		var fi filter.Filter
+		var err error
+
+		if f.Name == filter.Flate {
+			fi, err = filter.NewFlateFilter(parms, level)
+		} else {
+			fi, err = filter.NewFilter(f.Name, parms)
+		}
 		if err != nil {
 			return err
 		}
@@ -113,6 +132,13 @@ func encodeStream(sd *PDFStreamDict) error {
 
 // decodeStream decodes streamDict data by applying its filter pipeline.
 func decodeStream(sd *PDFStreamDict) error {
+	return decodeStreamLimited(sd, 0)
+}
+
+// decodeStreamLimited decodes streamDict data by applying its filter pipeline
+// failing with filter.ErrLimitExceeded if any filter produces more than maxLen bytes.
+// A maxLen of 0 means no limit.
+func decodeStreamLimited(sd *PDFStreamDict, maxLen int64) error {
 
 	log.Debug.Printf("decodeStream begin \n%s\n", sd)
 
@@ -124,7 +150,7 @@ func decodeStream(sd *PDFStreamDict) error {
 	// No filter specified, nothing to decode.
 	if sd.FilterPipeline == nil {
 		sd.Content = sd.Raw
-		fmt.Printf("decodedStream returning %d(#%02x)bytes: \n%s\n", len(sd.Content), len(sd.Content), hex.Dump(sd.Content))
+		log.Debug.Printf("decodedStream returning %d(#%02x)bytes: \n%s\n", len(sd.Content), len(sd.Content), hex.Dump(sd.Content))
 		return nil
 	}
 
@@ -133,7 +159,8 @@ func decodeStream(sd *PDFStreamDict) error {
 
 	//fmt.Printf("decodedStream before:\n%s\n", hex.Dump(sd.Raw))
 
-	var c *bytes.Buffer
+	// A pipeline consisting of a Crypt filter only leaves the content as is.
+	c := bytes.NewBuffer(sd.Raw)
 
 	// Apply each filter in the pipeline to result of preceding filter.
 	for _, f := range sd.FilterPipeline {
@@ -144,10 +171,15 @@ func decodeStream(sd *PDFStreamDict) error {
 			log.Debug.Printf("decodeStream: decoding filter:%s\n", f.Name)
 		}
 
+		// Decryption is up to the security handler.
+		if f.Name == filter.Crypt {
+			continue
+		}
+
 		// make parms map[string]int
 		parms := parmsForFilter(f.DecodeParms)
 
-		fi, err := filter.NewFilter(f.Name, parms)
+		fi, err := filter.NewLimitedFilter(f.Name, parms, maxLen)
 		if err != nil {
 			return err
 		}
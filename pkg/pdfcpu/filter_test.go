@@ -0,0 +1,147 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+)
+
+// xor flips all bits of the content read from r.
+func xor(r io.Reader, parms map[string]int) (*bytes.Buffer, error) {
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range b {
+		b[i] ^= 0xFF
+	}
+
+	return bytes.NewBuffer(b), nil
+}
+
+func xorBytes(s string) string {
+	b, _ := xor(bytes.NewReader([]byte(s)), nil)
+	return b.String()
+}
+
+// xorFile returns a single page PDF file whose content stream and image use the filter XORDecode.
+func xorFile() []byte {
+
+	var buf bytes.Buffer
+	var offsets []int
+
+	buf.WriteString("%PDF-1.7\n")
+
+	content := xorBytes("q 2 0 0 2 0 0 cm /Im1 Do Q")
+	samples := xorBytes("\x00\x40\x80\xFF")
+
+	for _, s := range []string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids[3 0 R]/Count 1/MediaBox[0 0 612 792]>>",
+		"<</Type/Page/Parent 2 0 R/Resources<</XObject<</Im1 5 0 R>>>>/Contents 4 0 R>>",
+		fmt.Sprintf("<</Length %d/Filter/XORDecode>>\nstream\n%s\nendstream", len(content), content),
+		fmt.Sprintf("<</Type/XObject/Subtype/Image/Width 2/Height 2/ColorSpace/DeviceGray/BitsPerComponent 8/Length %d/Filter/XORDecode>>\nstream\n%s\nendstream", len(samples), samples),
+	} {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), s)
+	}
+
+	xrefOff := buf.Len()
+
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<</Size %d/Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOff)
+
+	return buf.Bytes()
+}
+
+func readXORFile(t *testing.T) *PDFContext {
+
+	ctx, err := Read(bytes.NewReader(xorFile()), NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("Read: %v\n", err)
+	}
+
+	if err = ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("ValidateXRefTable: %v\n", err)
+	}
+
+	if err = OptimizeXRefTable(ctx); err != nil {
+		t.Fatalf("OptimizeXRefTable: %v\n", err)
+	}
+
+	return ctx
+}
+
+func TestRegisteredFilter(t *testing.T) {
+
+	// Unsupported filters leave streams undecoded.
+	ctx := readXORFile(t)
+
+	if b, err := ExtractContentData(ctx, 4); err != nil || b != nil {
+		t.Fatalf("unregistered: got %q %v\n", b, err)
+	}
+
+	if img, err := ExtractImageData(ctx, 5); err != nil || img != nil {
+		t.Fatalf("unregistered: got image %v %v\n", img, err)
+	}
+
+	if err := filter.RegisterFilter("XORDecode", xor, xor); err != nil {
+		t.Fatalf("RegisterFilter: %v\n", err)
+	}
+	defer filter.UnregisterFilter("XORDecode")
+
+	ctx = readXORFile(t)
+
+	if b, err := ExtractContentData(ctx, 4); err != nil || string(b) != "q 2 0 0 2 0 0 cm /Im1 Do Q" {
+		t.Errorf("content: got %q %v\n", b, err)
+	}
+
+	img, err := ExtractImageData(ctx, 5)
+	if err != nil || img == nil {
+		t.Fatalf("image: got %v %v\n", img, err)
+	}
+
+	if !bytes.Equal(img.ImageDict.Content, []byte{0x00, 0x40, 0x80, 0xFF}) {
+		t.Errorf("image: got samples % X\n", img.ImageDict.Content)
+	}
+
+	var png bytes.Buffer
+
+	ctx.XRefTable.CreateFile = func(name string) (io.WriteCloser, error) { return nopWriteCloser{&png}, nil }
+
+	fileName, err := WriteImage(ctx.XRefTable, "Im1", img.ImageDict, 5)
+	if err != nil || fileName != "Im1.png" || png.Len() == 0 {
+		t.Errorf("WriteImage: got %s %d bytes %v\n", fileName, png.Len(), err)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
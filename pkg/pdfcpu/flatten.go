@@ -0,0 +1,326 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const annotFlagHidden = 1 << 1 // see 12.5.3 Table 165
+
+// flattenedPage collects the appearances of the flattened annotations of a page.
+type flattenedPage struct {
+	content   bytes.Buffer
+	xObjects  PDFDict
+	pageDict  *PDFDict
+	resources *PDFDict // resources in effect
+	page      int
+	tags      *tagger // structure tree for tagging the flattened appearances
+}
+
+// normalAppearance returns the normal appearance stream of an annotation in effect or nil.
+func normalAppearance(xRefTable *XRefTable, d *PDFDict) (*PDFIndirectRef, error) {
+
+	obj, found := d.Find("AP")
+	if !found {
+		return nil, nil
+	}
+
+	ap, err := xRefTable.DereferenceDict(obj)
+	if err != nil || ap == nil {
+		return nil, err
+	}
+
+	obj, found = ap.Find("N")
+	if !found {
+		return nil, nil
+	}
+
+	indRef, ok := obj.(PDFIndirectRef)
+	if !ok {
+		return nil, nil
+	}
+
+	o, err := xRefTable.Dereference(indRef)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o := o.(type) {
+
+	case PDFStreamDict:
+		return &indRef, nil
+
+	case PDFDict:
+		// The appearance state selects among multiple appearances.
+		as := d.NameEntry("AS")
+		if as == nil {
+			return nil, nil
+		}
+		return o.IndirectRefEntry(*as), nil
+	}
+
+	return nil, nil
+}
+
+// appearanceMatrix returns the matrix mapping the transformed bounding box of an appearance stream onto the annotation rectangle.
+// See 12.5.5 Appearance Streams, Algorithm: Appearance streams.
+func appearanceMatrix(xRefTable *XRefTable, sd *PDFStreamDict, annotRect []float64) (matrix, bool) {
+
+	obj, found := sd.Find("BBox")
+	if !found {
+		return matrix{}, false
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil || len(*arr) != 4 {
+		return matrix{}, false
+	}
+
+	bb := rect(xRefTable, *arr)
+
+	m := identMatrix
+	if obj, found := sd.Find("Matrix"); found {
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err == nil && arr != nil && len(*arr) == 6 {
+			for i := 0; i < 6; i++ {
+				m[i/2][i%2] = xRefTable.DereferenceNumber((*arr)[i])
+			}
+		}
+	}
+
+	// Transform the bounding box and compute its bounding rectangle.
+	llx, lly := math.MaxFloat64, math.MaxFloat64
+	urx, ury := -math.MaxFloat64, -math.MaxFloat64
+	for _, p := range [][2]float64{{bb.LL.X, bb.LL.Y}, {bb.UR.X, bb.LL.Y}, {bb.UR.X, bb.UR.Y}, {bb.LL.X, bb.UR.Y}} {
+		x := p[0]*m[0][0] + p[1]*m[1][0] + m[2][0]
+		y := p[0]*m[0][1] + p[1]*m[1][1] + m[2][1]
+		llx, lly = math.Min(llx, x), math.Min(lly, y)
+		urx, ury = math.Max(urx, x), math.Max(ury, y)
+	}
+
+	w, h := urx-llx, ury-lly
+	if w == 0 || h == 0 {
+		return matrix{}, false
+	}
+
+	sx := (annotRect[2] - annotRect[0]) / w
+	sy := (annotRect[3] - annotRect[1]) / h
+
+	return matrix{{sx, 0, 0}, {0, sy, 0}, {annotRect[0] - llx*sx, annotRect[1] - lly*sy, 1}}, true
+}
+
+// annotationAlt returns a text string describing an annotation.
+func annotationAlt(d *PDFDict, subtype string) PDFObject {
+
+	for _, k := range []string{"Contents", "TU", "T"} {
+		switch o := d.Dict[k].(type) {
+		case PDFStringLiteral:
+			if len(o) > 0 {
+				return o
+			}
+		case PDFHexLiteral:
+			if len(o) > 0 {
+				return o
+			}
+		}
+	}
+
+	return encodeText(subtype + " annotation")
+}
+
+// flattenAnnotation draws the normal appearance of an annotation into fp.
+// It returns false if the annotation has no visible appearance.
+func flattenAnnotation(xRefTable *XRefTable, fp *flattenedPage, d *PDFDict, subtype string, objNr int) (bool, error) {
+
+	if f := d.IntEntry("F"); f != nil && *f&annotFlagHidden > 0 {
+		return false, nil
+	}
+
+	indRef, err := normalAppearance(xRefTable, d)
+	if err != nil || indRef == nil {
+		return false, err
+	}
+
+	sd, err := xRefTable.DereferenceStreamDict(*indRef)
+	if err != nil || sd == nil {
+		return false, err
+	}
+
+	r, err := widgetRect(xRefTable, d)
+	if err != nil || r == nil {
+		return false, err
+	}
+
+	m, ok := appearanceMatrix(xRefTable, sd, r)
+	if !ok {
+		return false, nil
+	}
+
+	// Appearance streams are form XObjects.
+	sd.Insert("Type", PDFName("XObject"))
+	sd.Insert("Subtype", PDFName("Form"))
+
+	id := fmt.Sprintf("Annot%d", len(fp.xObjects.Dict))
+	fp.xObjects.Insert(id, *indRef)
+
+	bb := []byte(fmt.Sprintf("q %.5f %.5f %.5f %.5f %.5f %.5f cm /%s Do Q\n", m[0][0], m[0][1], m[1][0], m[1][1], m[2][0], m[2][1], id))
+
+	if fp.tags != nil {
+		// An annotation already in the structure tree keeps its structure element.
+		bb1, ok, err := fp.tags.retagAnnotation(fp.page, fp.pageDict, d, objNr, bb)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			bb1, err = fp.tags.markContent(fp.page, fp.pageDict, "Figure", annotationAlt(d, subtype), bb)
+			if err != nil {
+				return false, err
+			}
+		}
+		bb = append(bb1, '\n')
+	}
+
+	fp.content.Write(bb)
+
+	return true, nil
+}
+
+// addContentStream appends a new content stream to the content of a page.
+// The existing content is wrapped in q/Q so that it cannot affect the graphics state of the new content.
+func addContentStream(xRefTable *XRefTable, pageDict *PDFDict, bb []byte) error {
+
+	newStream := func(bb []byte) (*PDFIndirectRef, error) {
+		sd := &PDFStreamDict{PDFDict: NewPDFDict()}
+		sd.Content = bb
+		if err := encodeStream(sd); err != nil {
+			return nil, err
+		}
+		return xRefTable.IndRefForNewObject(*sd)
+	}
+
+	last, err := newStream(append([]byte("Q\n"), bb...))
+	if err != nil {
+		return err
+	}
+
+	obj, found := pageDict.Find("Contents")
+	if !found || obj == nil {
+		pageDict.Update("Contents", *last)
+		return nil
+	}
+
+	first, err := newStream([]byte("q\n"))
+	if err != nil {
+		return err
+	}
+
+	arr := PDFArray{*first}
+
+	o, err := xRefTable.Dereference(obj)
+	if err != nil {
+		return err
+	}
+
+	if a, ok := o.(PDFArray); ok {
+		arr = append(arr, a...)
+	} else {
+		arr = append(arr, obj)
+	}
+
+	pageDict.Update("Contents", append(arr, *last))
+
+	return nil
+}
+
+// FlattenAnnotations draws the normal appearance of annotations of selected pages into the page content
+// and removes the annotations along with their popups. It returns the number of removed annotations.
+// Annotations matching any of the given subtypes or object numbers are flattened.
+// Without subtypes and object numbers all annotations except form field widgets are flattened.
+// Hidden annotations and annotations lacking a normal appearance are left untouched.
+// If selectedPages is empty all pages are processed.
+func FlattenAnnotations(xRefTable *XRefTable, selectedPages IntSet, subtypes []string, objNrs []int) (int, error) {
+
+	log.Debug.Println("FlattenAnnotations begin")
+
+	selected := annotationSelector(subtypes, objNrs)
+
+	pages := map[int]*flattenedPage{}
+
+	var tags *tagger
+	if xRefTable.TagContent {
+		tags = newTagger(xRefTable)
+	}
+
+	n, err := processAnnotations(xRefTable, selectedPages, func(page int, pageDict, d *PDFDict, subtype string, objNr int) (bool, error) {
+
+		ok, err := selected(subtype, objNr)
+		if err != nil || !ok {
+			return false, err
+		}
+
+		fp := pages[page]
+		if fp == nil {
+			_, inhPAttrs, err := xRefTable.PageDict(page)
+			if err != nil {
+				return false, err
+			}
+			fp = &flattenedPage{xObjects: NewPDFDict(), pageDict: pageDict, resources: inhPAttrs.resources, page: page, tags: tags}
+			pages[page] = fp
+		}
+
+		return flattenAnnotation(xRefTable, fp, d, subtype, objNr)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "FlattenAnnotations")
+	}
+
+	for _, fp := range pages {
+
+		if len(fp.xObjects.Dict) == 0 {
+			continue
+		}
+
+		bb := fp.content.Bytes()
+		res := &PDFDict{Dict: map[string]PDFObject{"XObject": fp.xObjects}}
+
+		if fp.resources == nil {
+			fp.pageDict.Insert("Resources", *res)
+		} else {
+			// Rename appearances colliding with page resources.
+			renames, err := mergeResources(xRefTable, fp.resources, res)
+			if err != nil {
+				return 0, err
+			}
+			bb = renameResourceNames(bb, renames)
+		}
+
+		err = addContentStream(xRefTable, fp.pageDict, bb)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	log.Debug.Println("FlattenAnnotations end")
+
+	return n, nil
+}
@@ -0,0 +1,491 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Field flags common to all field types, see 12.7.3.1 Table 221.
+const (
+	FieldReadOnly = 1 << iota
+	FieldRequired
+	FieldNoExport
+)
+
+// Field flags specific to button fields, see 12.7.4.2.1 Table 226.
+const (
+	FieldNoToggleToOff = 1 << 14
+	FieldRadio         = 1 << 15
+	FieldPushbutton    = 1 << 16
+)
+
+// Field flags specific to choice fields, see 12.7.4.4 Table 230.
+const (
+	FieldCombo       = 1 << 17
+	FieldMultiSelect = 1 << 21
+)
+
+// FormField represents a terminal field of an interactive form.
+type FormField struct {
+	Name     string    `json:"name"`              // fully qualified field name
+	Type     string    `json:"type"`              // text, checkbox, radio, pushbutton, combobox, listbox, signature
+	Value    string    `json:"value,omitempty"`   // current value
+	Default  string    `json:"default,omitempty"` // default value
+	Required bool      `json:"required"`
+	ReadOnly bool      `json:"readOnly"`
+	Page     int       `json:"page,omitempty"` // page of the first widget annotation
+	Rect     []float64 `json:"rect,omitempty"` // rect of the first widget annotation
+	ObjNr    int       `json:"objNr"`          // object number of the field dict
+}
+
+// fieldAttrs represents the inheritable attributes of a field.
+type fieldAttrs struct {
+	ft     string
+	ff     int
+	v, dv  PDFObject
+	da     *string
+	q      *int
+	prefix string
+}
+
+// formField represents a terminal field of an interactive form along with its widget annotations.
+type formField struct {
+	name    string
+	indRef  PDFIndirectRef
+	dict    *PDFDict
+	fa      fieldAttrs
+	widgets []PDFIndirectRef // either the field itself or its kids.
+}
+
+func (f formField) objNr() int {
+	return f.indRef.ObjectNumber.Value()
+}
+
+func fieldTypeString(ft string, ff int) string {
+
+	switch ft {
+
+	case "Tx":
+		return "text"
+
+	case "Btn":
+		if ff&FieldPushbutton > 0 {
+			return "pushbutton"
+		}
+		if ff&FieldRadio > 0 {
+			return "radio"
+		}
+		return "checkbox"
+
+	case "Ch":
+		if ff&FieldCombo > 0 {
+			return "combobox"
+		}
+		return "listbox"
+
+	case "Sig":
+		return "signature"
+	}
+
+	return ft
+}
+
+// fieldValueString returns a string representation for a field value.
+func fieldValueString(xRefTable *XRefTable, obj PDFObject) (string, error) {
+
+	obj, err := xRefTable.Dereference(obj)
+	if err != nil || obj == nil {
+		return "", err
+	}
+
+	switch o := obj.(type) {
+
+	case PDFStringLiteral:
+		return StringLiteralToString(o.Value())
+
+	case PDFHexLiteral:
+		return HexLiteralToString(o.Value())
+
+	case PDFName:
+		return o.Value(), nil
+
+	case PDFArray:
+		// Multiple selections of a choice field.
+		var ss []string
+		for _, v := range o {
+			s, err := fieldValueString(xRefTable, v)
+			if err != nil {
+				return "", err
+			}
+			ss = append(ss, s)
+		}
+		return strings.Join(ss, ","), nil
+
+	case PDFStreamDict:
+		// Rich text may be supplied as a text stream.
+		err = xRefTable.decodeStream(&o)
+		if err != nil {
+			return "", err
+		}
+		return string(o.Content), nil
+	}
+
+	return obj.String(), nil
+}
+
+// annotationPages returns a map from annotation object numbers to page numbers.
+func annotationPages(xRefTable *XRefTable) (map[int]int, error) {
+
+	m := map[int]int{}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		d, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		obj, found := d.Find("Annots")
+		if !found || obj == nil {
+			continue
+		}
+
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if arr == nil {
+			continue
+		}
+
+		for _, v := range *arr {
+			if indRef, ok := v.(PDFIndirectRef); ok {
+				m[indRef.ObjectNumber.Value()] = i
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// isFieldParent returns true if any kid is a field rather than a pure widget annotation.
+func isFieldParent(xRefTable *XRefTable, kids PDFArray) (bool, error) {
+
+	for _, v := range kids {
+
+		d, err := xRefTable.DereferenceDict(v)
+		if err != nil {
+			return false, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		if _, found := d.Find("T"); found {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func widgetRect(xRefTable *XRefTable, d *PDFDict) ([]float64, error) {
+
+	obj, found := d.Find("Rect")
+	if !found {
+		return nil, nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil || len(*arr) != 4 {
+		return nil, err
+	}
+
+	r := rect(xRefTable, *arr)
+
+	return []float64{r.LL.X, r.LL.Y, r.UR.X, r.UR.Y}, nil
+}
+
+func newFormField(xRefTable *XRefTable, f *formField) (*FormField, error) {
+
+	v, err := fieldValueString(xRefTable, f.fa.v)
+	if err != nil {
+		return nil, err
+	}
+
+	dv, err := fieldValueString(xRefTable, f.fa.dv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FormField{
+		Name:     f.name,
+		Type:     fieldTypeString(f.fa.ft, f.fa.ff),
+		Value:    v,
+		Default:  dv,
+		Required: f.fa.ff&FieldRequired > 0,
+		ReadOnly: f.fa.ff&FieldReadOnly > 0,
+		ObjNr:    f.objNr(),
+	}, nil
+}
+
+func inheritFieldAttrs(d *PDFDict, fa fieldAttrs) fieldAttrs {
+
+	if ft := d.NameEntry("FT"); ft != nil {
+		fa.ft = *ft
+	}
+
+	if ff := d.IntEntry("Ff"); ff != nil {
+		fa.ff = *ff
+	}
+
+	if o, found := d.Find("V"); found {
+		fa.v = o
+	}
+
+	if o, found := d.Find("DV"); found {
+		fa.dv = o
+	}
+
+	if da := d.StringEntry("DA"); da != nil {
+		fa.da = da
+	}
+
+	if q := d.IntEntry("Q"); q != nil {
+		fa.q = q
+	}
+
+	return fa
+}
+
+func walkFormFields(xRefTable *XRefTable, indRef PDFIndirectRef, fa fieldAttrs, visited IntSet, fn func(f *formField) error) error {
+
+	objNr := indRef.ObjectNumber.Value()
+	if visited[objNr] {
+		return nil
+	}
+	visited[objNr] = true
+
+	d, err := xRefTable.DereferenceDict(indRef)
+	if err != nil || d == nil {
+		return err
+	}
+
+	fa = inheritFieldAttrs(d, fa)
+
+	name := fa.prefix
+	if t, found := d.Find("T"); found {
+		s, err := fieldValueString(xRefTable, t)
+		if err != nil {
+			return err
+		}
+		if len(name) > 0 {
+			name += "."
+		}
+		name += s
+	}
+
+	var kids PDFArray
+	if o, found := d.Find("Kids"); found {
+		arr, err := xRefTable.DereferenceArray(o)
+		if err != nil {
+			return err
+		}
+		if arr != nil {
+			kids = *arr
+		}
+	}
+
+	// The kids of a radio button field are its widgets.
+	parent := false
+	if fa.ft != "Btn" || fa.ff&FieldRadio == 0 {
+		parent, err = isFieldParent(xRefTable, kids)
+		if err != nil {
+			return err
+		}
+	}
+
+	if parent {
+		fa.prefix = name
+		for _, v := range kids {
+			kid, ok := v.(PDFIndirectRef)
+			if !ok {
+				return errors.New("walkFormFields: corrupt kids array: entries must be indirect reference")
+			}
+			err = walkFormFields(xRefTable, kid, fa, visited, fn)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// d is a terminal field.
+	f := &formField{name: name, indRef: indRef, dict: d, fa: fa}
+
+	if len(kids) == 0 {
+		f.widgets = []PDFIndirectRef{indRef}
+	}
+
+	for _, v := range kids {
+		if kid, ok := v.(PDFIndirectRef); ok {
+			f.widgets = append(f.widgets, kid)
+		}
+	}
+
+	return fn(f)
+}
+
+// acroFormDict returns the interactive form dict of a PDF or nil.
+func acroFormDict(xRefTable *XRefTable) (*PDFDict, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, found := rootDict.Find("AcroForm")
+	if !found || obj == nil {
+		return nil, nil
+	}
+
+	return xRefTable.DereferenceDict(obj)
+}
+
+// processFormFields calls fn for every terminal field of the interactive form of a PDF.
+func processFormFields(xRefTable *XRefTable, fn func(f *formField) error) error {
+
+	acroFormDict, err := acroFormDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	if acroFormDict == nil {
+		// No interactive form.
+		return nil
+	}
+
+	obj, found := acroFormDict.Find("Fields")
+	if !found || obj == nil {
+		return nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil {
+		return err
+	}
+
+	if arr == nil {
+		return nil
+	}
+
+	fa := fieldAttrs{}
+	if da := acroFormDict.StringEntry("DA"); da != nil {
+		fa.da = da
+	}
+	if q := acroFormDict.IntEntry("Q"); q != nil {
+		fa.q = q
+	}
+
+	visited := IntSet{}
+
+	for _, v := range *arr {
+
+		indRef, ok := v.(PDFIndirectRef)
+		if !ok {
+			return errors.New("processFormFields: corrupt form field array entry")
+		}
+
+		err = walkFormFields(xRefTable, indRef, fa, visited, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListFormFields returns all terminal fields of the interactive form of a PDF.
+func ListFormFields(xRefTable *XRefTable) ([]FormField, error) {
+
+	log.Debug.Println("ListFormFields begin")
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if acroForm == nil {
+		// No interactive form, eg. /AcroForm missing or resolving to null.
+		return []FormField{}, nil
+	}
+
+	pages, err := annotationPages(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []FormField{}
+
+	err = processFormFields(xRefTable, func(f *formField) error {
+
+		ff, err := newFormField(xRefTable, f)
+		if err != nil {
+			return err
+		}
+
+		if len(f.widgets) > 0 {
+
+			// Locate the first widget annotation.
+			widget, err := xRefTable.DereferenceDict(f.widgets[0])
+			if err != nil {
+				return err
+			}
+
+			ff.Page = pages[f.widgets[0].ObjectNumber.Value()]
+
+			if widget != nil {
+				ff.Rect, err = widgetRect(xRefTable, widget)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		fields = append(fields, *ff)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("ListFormFields end")
+
+	return fields, nil
+}
@@ -0,0 +1,567 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// value returns the possibly inherited value of a field.
+func (f *formField) value() PDFObject {
+	if o, found := f.dict.Find("V"); found {
+		return o
+	}
+	return f.fa.v
+}
+
+// choiceValues returns the selected export values of a choice field.
+func choiceValues(xRefTable *XRefTable, obj PDFObject) ([]string, error) {
+
+	obj, err := xRefTable.Dereference(obj)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+
+	arr, ok := obj.(PDFArray)
+	if !ok {
+		arr = PDFArray{obj}
+	}
+
+	var ss []string
+	for _, o := range arr {
+		s, err := fieldValueString(xRefTable, o)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// widgetRectangle returns the rectangle of a widget annotation.
+func widgetRectangle(xRefTable *XRefTable, wd *PDFDict) (*types.Rectangle, error) {
+
+	obj, found := wd.Find("Rect")
+	if !found {
+		return nil, nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil || len(*arr) != 4 {
+		return nil, err
+	}
+
+	r := rect(xRefTable, *arr)
+
+	return &r, nil
+}
+
+// widgetColor returns the color operator for the color array mk[key] of an appearance characteristics dict, see 12.5.6.19.
+func widgetColor(xRefTable *XRefTable, mk *PDFDict, key string, stroke bool) (string, error) {
+
+	obj, found := mk.Find(key)
+	if !found {
+		return "", nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return "", err
+	}
+
+	var op string
+	switch len(*arr) {
+	case 1:
+		op = "g"
+	case 3:
+		op = "rg"
+	case 4:
+		op = "k"
+	default:
+		// Transparent
+		return "", nil
+	}
+
+	if stroke {
+		op = strings.ToUpper(op)
+	}
+
+	return strings.Replace(xfdfNumbers(xRefTable, *arr), ",", " ", -1) + " " + op, nil
+}
+
+// widgetFrame returns content drawing the background and border of a widget as specified by its MK entry.
+func widgetFrame(xRefTable *XRefTable, wd *PDFDict, w, h float64) (string, error) {
+
+	obj, found := wd.Find("MK")
+	if !found {
+		return "", nil
+	}
+
+	mk, err := xRefTable.DereferenceDict(obj)
+	if err != nil || mk == nil {
+		return "", err
+	}
+
+	var s string
+
+	bg, err := widgetColor(xRefTable, mk, "BG", false)
+	if err != nil {
+		return "", err
+	}
+
+	if bg != "" {
+		s += fmt.Sprintf("q %s 0 0 %.2f %.2f re f Q ", bg, w, h)
+	}
+
+	bc, err := widgetColor(xRefTable, mk, "BC", true)
+	if err != nil || bc == "" {
+		return s, err
+	}
+
+	lw := 1.0
+
+	if obj, found = wd.Find("BS"); found {
+		bs, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return "", err
+		}
+		if bs != nil {
+			if o, found := bs.Find("W"); found {
+				lw = xRefTable.DereferenceNumber(o)
+			}
+		}
+	}
+
+	if lw > 0 {
+		s += fmt.Sprintf("q %s %.2f w %.2f %.2f %.2f %.2f re S Q ", bc, lw, lw/2, lw/2, w-lw, h-lw)
+	}
+
+	return s, nil
+}
+
+// combAppearance returns the content of a normal appearance stream for a comb field of width w and height h.
+// The field is divided into maxLen cells holding one character each.
+func combAppearance(s, da, baseFont string, fontSize float64, maxLen int, w, h float64) []byte {
+
+	const border = textFieldBorder
+
+	rr := []rune(strings.Replace(s, "\n", " ", -1))
+	if len(rr) > maxLen {
+		rr = rr[:maxLen]
+	}
+
+	cw := w / float64(maxLen)
+
+	if fontSize == 0 {
+		// Auto size
+		fontSize = minFloat((h-2*border)*0.7, cw)
+		if fontSize < 4 {
+			fontSize = 4
+		}
+		da = replaceFontSize(da, fontSize)
+	}
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "/Tx BMC q %.2f %.2f %.2f %.2f re W n BT %s ", border/2, border/2, w-border, h-border, da)
+
+	y := (h-fontSize)/2 + fontSize*0.22
+
+	for i, r := range rr {
+		c := string(r)
+		x := cw*float64(i) + (cw-textWidth(c, baseFont, fontSize))/2
+		esc, _ := Escape(winAnsiString(c))
+		fmt.Fprintf(&b, "1 0 0 1 %.2f %.2f Tm (%s) Tj ", x, y, *esc)
+	}
+
+	b.WriteString("ET Q EMC")
+
+	return b.Bytes()
+}
+
+// listBoxAppearance returns the content of a normal appearance stream for a list box of width w and height h
+// listing the options starting at index top with selected options highlighted.
+func listBoxAppearance(texts []string, selected IntSet, top int, da, baseFont string, fontSize float64, q int, w, h float64) []byte {
+
+	const border = textFieldBorder
+
+	if fontSize == 0 {
+		// Auto size
+		fontSize = 12
+		da = replaceFontSize(da, fontSize)
+	}
+
+	lh := fontSize * 1.15
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "/Tx BMC q %.2f %.2f %.2f %.2f re W n ", border/2, border/2, w-border, h-border)
+
+	y := h - border
+	for i := top; i < len(texts) && y > 0; i++ {
+		if selected[i] {
+			fmt.Fprintf(&b, "q 0.6 0.75 0.85 rg %.2f %.2f %.2f %.2f re f Q ", border/2, y-lh, w-border, lh)
+		}
+		y -= lh
+	}
+
+	fmt.Fprintf(&b, "BT %s ", da)
+
+	y = h - border
+	for i := top; i < len(texts) && y > 0; i++ {
+
+		x := border
+		switch q {
+		case 1:
+			x = (w - textWidth(texts[i], baseFont, fontSize)) / 2
+		case 2:
+			x = w - border - textWidth(texts[i], baseFont, fontSize)
+		}
+
+		esc, _ := Escape(winAnsiString(texts[i]))
+		fmt.Fprintf(&b, "1 0 0 1 %.2f %.2f Tm (%s) Tj ", x, y-lh+(lh-fontSize)/2+fontSize*0.22, *esc)
+
+		y -= lh
+	}
+
+	b.WriteString("ET Q EMC")
+
+	return b.Bytes()
+}
+
+// listBoxSelection returns the display texts, the selected option indices and the top index of a list box.
+func listBoxSelection(xRefTable *XRefTable, f *formField) ([]string, IntSet, int, error) {
+
+	exports, texts, err := choiceOptions(xRefTable, f.dict)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	selected := IntSet{}
+
+	if obj, found := f.dict.Find("I"); found {
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if arr != nil {
+			for _, o := range *arr {
+				selected[int(xRefTable.DereferenceNumber(o))] = true
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		ss, err := choiceValues(xRefTable, f.value())
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for _, s := range ss {
+			if i := indexOf(exports, s); i >= 0 {
+				selected[i] = true
+			}
+		}
+	}
+
+	var top int
+	if ti := f.dict.IntEntry("TI"); ti != nil && *ti > 0 {
+		top = *ti
+	}
+
+	return texts, selected, top, nil
+}
+
+// updateTextAppearances regenerates the normal appearance streams for all widgets of a text or choice field.
+// s is the text displayed by text fields and combo boxes, list boxes display all of their options.
+func updateTextAppearances(xRefTable *XRefTable, acroForm *PDFDict, f *formField, s string) error {
+
+	da := defaultFieldDA
+	if f.fa.da != nil {
+		da = *f.fa.da
+	}
+
+	fontID, fontSize := parseDA(da)
+
+	fontObj, baseFont, err := fontResource(xRefTable, acroForm, f.dict, fontID)
+	if err != nil {
+		return err
+	}
+
+	q := 0
+	if f.fa.q != nil {
+		q = *f.fa.q
+	}
+
+	multiline := f.fa.ft == "Tx" && f.fa.ff&FieldMultiline > 0
+
+	var maxLen int
+	if f.fa.ft == "Tx" && f.fa.ff&(FieldComb|FieldMultiline) == FieldComb {
+		if i := f.dict.IntEntry("MaxLen"); i != nil {
+			maxLen = *i
+		}
+	}
+
+	listBox := f.fa.ft == "Ch" && f.fa.ff&FieldCombo == 0
+
+	var (
+		texts    []string
+		selected IntSet
+		top      int
+	)
+
+	if listBox {
+		if texts, selected, top, err = listBoxSelection(xRefTable, f); err != nil {
+			return err
+		}
+	}
+
+	widgets, err := f.widgetDicts(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	for _, wd := range widgets {
+
+		r, err := widgetRectangle(xRefTable, wd)
+		if err != nil {
+			return err
+		}
+
+		if r == nil {
+			continue
+		}
+
+		widgetDA := da
+		if s := wd.StringEntry("DA"); s != nil {
+			widgetDA = *s
+		}
+
+		w, h := r.Width(), r.Height()
+
+		frame, err := widgetFrame(xRefTable, wd, w, h)
+		if err != nil {
+			return err
+		}
+
+		var content []byte
+
+		switch {
+
+		case listBox:
+			content = listBoxAppearance(texts, selected, top, widgetDA, baseFont, fontSize, q, w, h)
+
+		case maxLen > 0:
+			content = combAppearance(s, widgetDA, baseFont, fontSize, maxLen, w, h)
+
+		default:
+			content = textAppearance(s, widgetDA, baseFont, fontSize, q, multiline, w, h)
+		}
+
+		sd := &PDFStreamDict{
+			PDFDict: PDFDict{
+				Dict: map[string]PDFObject{
+					"Type":    PDFName("XObject"),
+					"Subtype": PDFName("Form"),
+					"BBox":    NewRectangle(0, 0, w, h),
+					"Resources": PDFDict{
+						Dict: map[string]PDFObject{
+							"Font": PDFDict{Dict: map[string]PDFObject{fontID: fontObj}},
+						},
+					},
+				},
+			},
+			Content: append([]byte(frame), content...),
+		}
+
+		err = encodeStream(sd)
+		if err != nil {
+			return err
+		}
+
+		indRef, err := xRefTable.IndRefForNewObject(*sd)
+		if err != nil {
+			return err
+		}
+
+		wd.Update("AP", PDFDict{Dict: map[string]PDFObject{"N": *indRef}})
+	}
+
+	return nil
+}
+
+// updateChoiceAppearances regenerates the normal appearance streams for all widgets of a choice field
+// displaying the texts of the selected options.
+func updateChoiceAppearances(xRefTable *XRefTable, acroForm *PDFDict, f *formField) error {
+
+	ss, err := choiceValues(xRefTable, f.value())
+	if err != nil {
+		return err
+	}
+
+	exports, texts, err := choiceOptions(xRefTable, f.dict)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range ss {
+		if j := indexOf(exports, s); j >= 0 {
+			ss[i] = texts[j]
+		}
+	}
+
+	return updateTextAppearances(xRefTable, acroForm, f, strings.Join(ss, "\n"))
+}
+
+// updateButtonAppearances sets the appearance states of the widgets of a checkbox or radio button field
+// according to the field value and creates missing normal appearances.
+func updateButtonAppearances(xRefTable *XRefTable, acroForm *PDFDict, f *formField) error {
+
+	radio := f.fa.ff&FieldRadio > 0
+
+	v := "Off"
+
+	obj, err := xRefTable.Dereference(f.value())
+	if err != nil {
+		return err
+	}
+
+	if n, ok := obj.(PDFName); ok {
+		v = n.Value()
+	}
+
+	widgets, err := f.widgetDicts(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	for i, wd := range widgets {
+
+		ss, err := appearanceStates(xRefTable, wd)
+		if err != nil {
+			return err
+		}
+
+		on, err := onState(xRefTable, wd)
+		if err != nil {
+			return err
+		}
+
+		if len(ss) == 0 {
+
+			r, err := widgetRectangle(xRefTable, wd)
+			if err != nil {
+				return err
+			}
+
+			if r == nil {
+				continue
+			}
+
+			// Without appearances the on states of radio buttons are their indices, see 12.7.4.2.4.
+			if radio {
+				on = strconv.Itoa(i)
+			}
+
+			n, err := buttonAppearances(xRefTable, acroForm, on, radio, r.Width(), r.Height())
+			if err != nil {
+				return err
+			}
+
+			wd.Update("AP", PDFDict{Dict: map[string]PDFObject{"N": n}})
+		}
+
+		state := "Off"
+		if on == v {
+			state = on
+		}
+
+		wd.Update("AS", PDFName(state))
+	}
+
+	return nil
+}
+
+// GenerateFieldAppearances regenerates the appearance streams of all text fields, combo boxes and list boxes
+// according to their values, DA strings, quadding, multiline and comb flags.
+// Checkboxes and radio buttons get their appearance states set according to their values and missing appearances created.
+// NeedAppearances gets removed so that all viewers display the form alike.
+// It returns the number of fields processed.
+func GenerateFieldAppearances(xRefTable *XRefTable) (int, error) {
+
+	log.Debug.Println("GenerateFieldAppearances begin")
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return 0, err
+	}
+
+	if acroForm == nil {
+		return 0, errors.New("GenerateFieldAppearances: no form available")
+	}
+
+	var n int
+
+	err = processFormFields(xRefTable, func(f *formField) error {
+
+		switch fieldTypeString(f.fa.ft, f.fa.ff) {
+
+		case "text":
+			s, err := fieldValueString(xRefTable, f.value())
+			if err != nil {
+				return err
+			}
+			if err = updateTextAppearances(xRefTable, acroForm, f, s); err != nil {
+				return err
+			}
+
+		case "combobox", "listbox":
+			if err := updateChoiceAppearances(xRefTable, acroForm, f); err != nil {
+				return err
+			}
+
+		case "checkbox", "radio":
+			if err := updateButtonAppearances(xRefTable, acroForm, f); err != nil {
+				return err
+			}
+
+		default:
+			return nil
+		}
+
+		n++
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, errors.Wrap(err, "GenerateFieldAppearances")
+	}
+
+	acroForm.Delete("NeedAppearances")
+
+	log.Debug.Println("GenerateFieldAppearances end")
+
+	return n, nil
+}
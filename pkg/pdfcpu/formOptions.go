@@ -0,0 +1,264 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// ChoiceOption represents an option of a combo box or list box.
+type ChoiceOption struct {
+	Value string `json:"value"`          // export value
+	Text  string `json:"text,omitempty"` // display text, defaults to the export value
+}
+
+// ParseChoiceOption parses an option given as "value" or "value=text".
+func ParseChoiceOption(s string) ChoiceOption {
+
+	ss := strings.SplitN(s, "=", 2)
+
+	opt := ChoiceOption{Value: ss[0]}
+	if len(ss) == 2 {
+		opt.Text = ss[1]
+	}
+
+	return opt
+}
+
+// choiceField returns the combo box or list box named fieldName.
+func choiceField(xRefTable *XRefTable, fieldName string) (*formField, error) {
+
+	fields, _, err := formFieldsByName(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := fields[fieldName]
+	if !ok {
+		return nil, errors.Errorf("unknown field: %s", fieldName)
+	}
+
+	if f.fa.ft != "Ch" {
+		return nil, errors.Errorf("field %s is not a choice field", fieldName)
+	}
+
+	return f, nil
+}
+
+// readChoiceOptions returns the options of a choice field.
+func readChoiceOptions(xRefTable *XRefTable, f *formField) ([]ChoiceOption, error) {
+
+	exports, texts, err := choiceOptions(xRefTable, f.dict)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []ChoiceOption{}
+	for i, export := range exports {
+		opt := ChoiceOption{Value: export}
+		if texts[i] != export {
+			opt.Text = texts[i]
+		}
+		opts = append(opts, opt)
+	}
+
+	return opts, nil
+}
+
+// writeChoiceOptions replaces the options of a choice field, adjusts its selection and regenerates its appearances.
+// Selected values no longer available get deselected unless the field is an editable combo box.
+func writeChoiceOptions(xRefTable *XRefTable, f *formField, opts []ChoiceOption) error {
+
+	var exports []string
+
+	opt := PDFArray{}
+	for _, o := range opts {
+		if indexOf(exports, o.Value) >= 0 {
+			return errors.Errorf("choice field %s: duplicate option %s", f.name, o.Value)
+		}
+		exports = append(exports, o.Value)
+		if o.Text == "" || o.Text == o.Value {
+			opt = append(opt, encodeText(o.Value))
+			continue
+		}
+		opt = append(opt, PDFArray{encodeText(o.Value), encodeText(o.Text)})
+	}
+
+	f.dict.Update("Opt", opt)
+
+	ss, err := choiceValues(xRefTable, f.value())
+	if err != nil {
+		return err
+	}
+
+	editable := f.fa.ff&FieldCombo > 0 && f.fa.ff&FieldEdit > 0
+
+	var values []string
+	var ii []int
+
+	for _, s := range ss {
+		i := indexOf(exports, s)
+		if i < 0 && !editable {
+			continue
+		}
+		values = append(values, s)
+		if i >= 0 {
+			ii = append(ii, i)
+		}
+	}
+
+	if len(values) != len(ss) {
+		switch len(values) {
+		case 0:
+			f.dict.Delete("V")
+		case 1:
+			f.dict.Update("V", encodeText(values[0]))
+		default:
+			arr := PDFArray{}
+			for _, s := range values {
+				arr = append(arr, encodeText(s))
+			}
+			f.dict.Update("V", arr)
+		}
+	}
+
+	f.dict.Delete("I")
+	if len(ii) > 0 && !editable {
+		sort.Ints(ii)
+		f.dict.Insert("I", NewIntegerArray(ii...))
+	}
+
+	// Scroll list boxes back to the top since the option at TI may be gone.
+	f.dict.Delete("TI")
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	return updateChoiceAppearances(xRefTable, acroForm, f)
+}
+
+// ListChoiceOptions returns the options of the combo box or list box named fieldName.
+func ListChoiceOptions(xRefTable *XRefTable, fieldName string) ([]ChoiceOption, error) {
+
+	log.Debug.Println("ListChoiceOptions begin")
+
+	f, err := choiceField(xRefTable, fieldName)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := readChoiceOptions(xRefTable, f)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("ListChoiceOptions end")
+
+	return opts, nil
+}
+
+// AddChoiceOptions appends opts to the options of the combo box or list box named fieldName.
+func AddChoiceOptions(xRefTable *XRefTable, fieldName string, opts []ChoiceOption) error {
+
+	log.Debug.Println("AddChoiceOptions begin")
+
+	f, err := choiceField(xRefTable, fieldName)
+	if err != nil {
+		return err
+	}
+
+	oo, err := readChoiceOptions(xRefTable, f)
+	if err != nil {
+		return err
+	}
+
+	if err = writeChoiceOptions(xRefTable, f, append(oo, opts...)); err != nil {
+		return err
+	}
+
+	log.Debug.Println("AddChoiceOptions end")
+
+	return nil
+}
+
+// RemoveChoiceOptions removes the options with the given export values from the combo box or list box named fieldName.
+func RemoveChoiceOptions(xRefTable *XRefTable, fieldName string, values []string) error {
+
+	log.Debug.Println("RemoveChoiceOptions begin")
+
+	f, err := choiceField(xRefTable, fieldName)
+	if err != nil {
+		return err
+	}
+
+	oo, err := readChoiceOptions(xRefTable, f)
+	if err != nil {
+		return err
+	}
+
+	var exports []string
+	for _, o := range oo {
+		exports = append(exports, o.Value)
+	}
+
+	for _, v := range values {
+		if indexOf(exports, v) < 0 {
+			return errors.Errorf("choice field %s: unknown option %s", fieldName, v)
+		}
+	}
+
+	opts := []ChoiceOption{}
+	for _, o := range oo {
+		if indexOf(values, o.Value) < 0 {
+			opts = append(opts, o)
+		}
+	}
+
+	if err = writeChoiceOptions(xRefTable, f, opts); err != nil {
+		return err
+	}
+
+	log.Debug.Println("RemoveChoiceOptions end")
+
+	return nil
+}
+
+// SetChoiceOptions replaces the options of the combo box or list box named fieldName by opts.
+// Use it to reorder options or to change their export values and display texts.
+func SetChoiceOptions(xRefTable *XRefTable, fieldName string, opts []ChoiceOption) error {
+
+	log.Debug.Println("SetChoiceOptions begin")
+
+	f, err := choiceField(xRefTable, fieldName)
+	if err != nil {
+		return err
+	}
+
+	if err = writeChoiceOptions(xRefTable, f, opts); err != nil {
+		return err
+	}
+
+	log.Debug.Println("SetChoiceOptions end")
+
+	return nil
+}
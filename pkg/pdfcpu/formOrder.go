@@ -0,0 +1,356 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// PageFormOrder represents the tab order of the form fields of a page.
+type PageFormOrder struct {
+	Page   int      `json:"page"`
+	Tabs   string   `json:"tabs,omitempty"` // R (row order), C (column order), S (structure order) or empty
+	Fields []string `json:"fields"`         // fully qualified field names in widget annotation order
+}
+
+// FormOrder represents the tab order and the calculation order of an interactive form.
+type FormOrder struct {
+	Pages            []PageFormOrder `json:"pages"`
+	CalculationOrder []string        `json:"calculationOrder"` // fully qualified field names
+}
+
+// formFieldsByName returns the terminal fields of the interactive form of a PDF by name
+// along with a map from widget object numbers to field names.
+func formFieldsByName(xRefTable *XRefTable) (map[string]*formField, map[int]string, error) {
+
+	fields := map[string]*formField{}
+	widgets := map[int]string{}
+
+	err := processFormFields(xRefTable, func(f *formField) error {
+		fields[f.name] = f
+		for _, indRef := range f.widgets {
+			widgets[indRef.ObjectNumber.Value()] = f.name
+		}
+		return nil
+	})
+
+	return fields, widgets, err
+}
+
+// pageWidgetFields returns the names of the fields of the widget annotations of a page in annotation order.
+// Fields with multiple widgets on the page are listed once.
+func pageWidgetFields(xRefTable *XRefTable, pageDict *PDFDict, widgets map[int]string) ([]string, error) {
+
+	arr, err := pageAnnotations(xRefTable, pageDict)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := []string{}
+	listed := map[string]bool{}
+
+	for _, v := range arr {
+
+		indRef, ok := v.(PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		name, ok := widgets[indRef.ObjectNumber.Value()]
+		if !ok || listed[name] {
+			continue
+		}
+
+		listed[name] = true
+		ss = append(ss, name)
+	}
+
+	return ss, nil
+}
+
+// ListFormOrder returns the tab order of the pages and the calculation order of the interactive form of a PDF.
+// Pages without form fields are omitted.
+func ListFormOrder(xRefTable *XRefTable) (*FormOrder, error) {
+
+	log.Debug.Println("ListFormOrder begin")
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if acroForm == nil {
+		return nil, errors.New("ListFormOrder: no form available")
+	}
+
+	fields, widgets, err := formFieldsByName(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	fo := &FormOrder{Pages: []PageFormOrder{}, CalculationOrder: []string{}}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		ss, err := pageWidgetFields(xRefTable, pageDict, widgets)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ss) == 0 {
+			continue
+		}
+
+		po := PageFormOrder{Page: i, Fields: ss}
+		if tabs := pageDict.NameEntry("Tabs"); tabs != nil {
+			po.Tabs = *tabs
+		}
+
+		fo.Pages = append(fo.Pages, po)
+	}
+
+	// Map field object numbers to field names.
+	names := map[int]string{}
+	for name, f := range fields {
+		names[f.objNr()] = name
+	}
+
+	if obj, found := acroForm.Find("CO"); found {
+
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if arr != nil {
+			for _, v := range *arr {
+				if indRef, ok := v.(PDFIndirectRef); ok {
+					if name, ok := names[indRef.ObjectNumber.Value()]; ok {
+						fo.CalculationOrder = append(fo.CalculationOrder, name)
+					}
+				}
+			}
+		}
+	}
+
+	log.Debug.Println("ListFormOrder end")
+
+	return fo, nil
+}
+
+// SetTabOrder sets the tab order of the selected pages to R (row order), C (column order) or S (structure order).
+// It returns the number of pages processed.
+// If selectedPages is empty all pages are processed.
+func SetTabOrder(xRefTable *XRefTable, selectedPages IntSet, tabs string) (int, error) {
+
+	log.Debug.Println("SetTabOrder begin")
+
+	if !memberOf(tabs, []string{"R", "C", "S"}) {
+		return 0, errors.Errorf("SetTabOrder: invalid tab order: %s, use one of R, C, S", tabs)
+	}
+
+	var n int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		pageDict.Update("Tabs", PDFName(tabs))
+		n++
+	}
+
+	log.Debug.Println("SetTabOrder end")
+
+	return n, nil
+}
+
+// SetWidgetOrder reorders the widget annotations of the selected pages so that the widgets of the given fields
+// come first in the given order followed by the remaining widgets in their original order.
+// Other annotations keep their positions. Fields without widgets on a page are ignored for this page.
+// It returns the number of pages processed.
+// If selectedPages is empty all pages are processed.
+func SetWidgetOrder(xRefTable *XRefTable, selectedPages IntSet, fieldNames []string) (int, error) {
+
+	log.Debug.Println("SetWidgetOrder begin")
+
+	fields, widgets, err := formFieldsByName(xRefTable)
+	if err != nil {
+		return 0, err
+	}
+
+	rank := map[string]int{}
+	for i, name := range fieldNames {
+		if _, ok := fields[name]; !ok {
+			return 0, errors.Errorf("SetWidgetOrder: unknown field: %s", name)
+		}
+		rank[name] = i
+	}
+
+	var n int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		arr, err := pageAnnotations(xRefTable, pageDict)
+		if err != nil {
+			return 0, err
+		}
+
+		// Collect the widget slots and sort the widgets into them.
+		var slots []int
+		ranked := make([][]PDFObject, len(fieldNames))
+		var unranked []PDFObject
+
+		for j, v := range arr {
+
+			indRef, ok := v.(PDFIndirectRef)
+			if !ok {
+				continue
+			}
+
+			name, ok := widgets[indRef.ObjectNumber.Value()]
+			if !ok {
+				continue
+			}
+
+			slots = append(slots, j)
+
+			if r, ok := rank[name]; ok {
+				ranked[r] = append(ranked[r], v)
+				continue
+			}
+
+			unranked = append(unranked, v)
+		}
+
+		if len(slots) == 0 {
+			continue
+		}
+
+		var ordered []PDFObject
+		for _, oo := range ranked {
+			ordered = append(ordered, oo...)
+		}
+		ordered = append(ordered, unranked...)
+
+		annots := make(PDFArray, len(arr))
+		copy(annots, arr)
+		for j, slot := range slots {
+			annots[slot] = ordered[j]
+		}
+
+		pageDict.Update("Annots", annots)
+
+		n++
+	}
+
+	log.Debug.Println("SetWidgetOrder end")
+
+	return n, nil
+}
+
+// SetCalculationOrder sets the order in which the calculation actions of the given fields are performed.
+// An empty list removes the calculation order.
+func SetCalculationOrder(xRefTable *XRefTable, fieldNames []string) error {
+
+	log.Debug.Println("SetCalculationOrder begin")
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	if acroForm == nil {
+		return errors.New("SetCalculationOrder: no form available")
+	}
+
+	if len(fieldNames) == 0 {
+		acroForm.Delete("CO")
+		return nil
+	}
+
+	fields, _, err := formFieldsByName(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	arr := PDFArray{}
+	listed := map[string]bool{}
+
+	var unknown []string
+
+	for _, name := range fieldNames {
+
+		f, ok := fields[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if listed[name] {
+			return errors.Errorf("SetCalculationOrder: duplicate field: %s", name)
+		}
+		listed[name] = true
+
+		arr = append(arr, f.indRef)
+	}
+
+	if len(unknown) > 0 {
+		return errors.Errorf("SetCalculationOrder: unknown fields: %s", strings.Join(unknown, ","))
+	}
+
+	acroForm.Update("CO", arr)
+
+	log.Debug.Println("SetCalculationOrder end")
+
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// selectedField returns the entry of fieldNames selecting the field named name
+// either by its fully qualified name or by the name of one of its ancestors.
+func selectedField(name string, fieldNames []string) (string, bool) {
+	for _, s := range fieldNames {
+		if name == s || strings.HasPrefix(name, s+".") {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// resetChoiceSelection sets the option indices of a choice field according to its value.
+func resetChoiceSelection(xRefTable *XRefTable, f *formField) error {
+
+	f.dict.Delete("I")
+	f.dict.Delete("TI")
+
+	if f.fa.ff&FieldCombo > 0 && f.fa.ff&FieldEdit > 0 {
+		return nil
+	}
+
+	ss, err := choiceValues(xRefTable, f.value())
+	if err != nil {
+		return err
+	}
+
+	exports, _, err := choiceOptions(xRefTable, f.dict)
+	if err != nil {
+		return err
+	}
+
+	var ii []int
+	for _, s := range ss {
+		if i := indexOf(exports, s); i >= 0 {
+			ii = append(ii, i)
+		}
+	}
+
+	if len(ii) > 0 {
+		sort.Ints(ii)
+		f.dict.Insert("I", NewIntegerArray(ii...))
+	}
+
+	return nil
+}
+
+// resetField sets the value of a field to its default value and regenerates its appearances.
+func resetField(xRefTable *XRefTable, acroForm *PDFDict, f *formField) error {
+
+	if f.fa.dv == nil {
+		f.dict.Delete("V")
+	} else {
+		f.dict.Update("V", f.fa.dv)
+	}
+
+	switch fieldTypeString(f.fa.ft, f.fa.ff) {
+
+	case "text":
+		s, err := fieldValueString(xRefTable, f.value())
+		if err != nil {
+			return err
+		}
+		return updateTextAppearances(xRefTable, acroForm, f, s)
+
+	case "combobox", "listbox":
+		if err := resetChoiceSelection(xRefTable, f); err != nil {
+			return err
+		}
+		return updateChoiceAppearances(xRefTable, acroForm, f)
+
+	case "checkbox", "radio":
+		return updateButtonAppearances(xRefTable, acroForm, f)
+	}
+
+	return nil
+}
+
+// ResetFormFields restores the default values of the selected fields and regenerates their appearances
+// like a ResetForm action does, see 12.7.5.3.
+// A field gets selected by its fully qualified name or by the name of one of its ancestors.
+// If fieldNames is empty all fields are reset. Push buttons and signature fields are left untouched.
+// It returns the number of fields reset.
+func ResetFormFields(xRefTable *XRefTable, fieldNames []string) (int, error) {
+
+	log.Debug.Println("ResetFormFields begin")
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return 0, err
+	}
+
+	if acroForm == nil {
+		return 0, errors.New("ResetFormFields: no form available")
+	}
+
+	var n int
+	selected := map[string]bool{}
+
+	err = processFormFields(xRefTable, func(f *formField) error {
+
+		if len(fieldNames) > 0 {
+			s, ok := selectedField(f.name, fieldNames)
+			if !ok {
+				return nil
+			}
+			selected[s] = true
+		}
+
+		switch fieldTypeString(f.fa.ft, f.fa.ff) {
+		case "pushbutton", "signature":
+			return nil
+		}
+
+		n++
+
+		return resetField(xRefTable, acroForm, f)
+	})
+
+	if err != nil {
+		return 0, errors.Wrap(err, "ResetFormFields")
+	}
+
+	var unknown []string
+	for _, s := range fieldNames {
+		if !selected[s] {
+			unknown = append(unknown, s)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return 0, errors.Errorf("ResetFormFields: unknown fields: %s", strings.Join(unknown, ","))
+	}
+
+	log.Debug.Println("ResetFormFields end")
+
+	return n, nil
+}
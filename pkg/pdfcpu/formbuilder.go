@@ -0,0 +1,731 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// FieldAttributes represents the attributes common to all fields created by the form builder.
+type FieldAttributes struct {
+	Name     string          // field name, must not contain periods
+	Page     int             // page number of the widget annotation
+	Rect     types.Rectangle // widget annotation rectangle in default user space
+	Tooltip  string          // optional alternate field name displayed as tooltip
+	ReadOnly bool
+	Required bool
+}
+
+// RadioButton represents a single button of a radio button group.
+type RadioButton struct {
+	Value string          // export value, must be usable as PDF name
+	Rect  types.Rectangle // widget annotation rectangle in default user space
+}
+
+const (
+	defaultFieldDA   = "/Helv 0 Tf 0 g" // auto sized Helvetica
+	annotFlagPrint   = 1 << 2           // see 12.5.3 Table 165
+	formBuilderLineW = 1.0              // line width of field borders
+
+	// The ZapfDingbats check mark used for checkboxes and its width in text space units.
+	checkGlyph      = "4"
+	checkGlyphWidth = 0.846
+)
+
+func (fa FieldAttributes) validate(xRefTable *XRefTable) error {
+
+	if len(fa.Name) == 0 || strings.Contains(fa.Name, ".") {
+		return errors.Errorf("invalid field name: %q", fa.Name)
+	}
+
+	exists := false
+
+	err := processFormFields(xRefTable, func(f *formField) error {
+		if f.name == fa.Name || strings.HasPrefix(f.name, fa.Name+".") {
+			exists = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.Errorf("field %s already exists", fa.Name)
+	}
+
+	return nil
+}
+
+func (fa FieldAttributes) flags() int {
+
+	ff := 0
+
+	if fa.ReadOnly {
+		ff |= FieldReadOnly
+	}
+
+	if fa.Required {
+		ff |= FieldRequired
+	}
+
+	return ff
+}
+
+// fieldDict returns a field dict for fa.
+func (fa FieldAttributes) fieldDict(ft string, ff int) PDFDict {
+
+	d := NewPDFDict()
+	d.InsertName("FT", ft)
+	d.Insert("T", encodeText(fa.Name))
+
+	if ff |= fa.flags(); ff != 0 {
+		d.InsertInt("Ff", ff)
+	}
+
+	if len(fa.Tooltip) > 0 {
+		d.Insert("TU", encodeText(fa.Tooltip))
+	}
+
+	return d
+}
+
+// addWidgetEntries turns d into a widget annotation for r.
+func addWidgetEntries(d PDFDict, r types.Rectangle) {
+	d.InsertName("Type", "Annot")
+	d.InsertName("Subtype", "Widget")
+	d.Insert("Rect", NewRectangle(r.LL.X, r.LL.Y, r.UR.X, r.UR.Y))
+	d.InsertInt("F", annotFlagPrint)
+}
+
+// appendToArrayEntry appends obj to the array entry key of d which may be an indirect reference.
+func appendToArrayEntry(xRefTable *XRefTable, d *PDFDict, key string, obj PDFObject) error {
+
+	o, found := d.Find(key)
+	if !found || o == nil {
+		d.Update(key, PDFArray{obj})
+		return nil
+	}
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		arr, ok := o.(PDFArray)
+		if !ok {
+			return errors.Errorf("appendToArrayEntry: corrupt entry %s", key)
+		}
+		d.Update(key, append(arr, obj))
+		return nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(indRef)
+	if err != nil {
+		return err
+	}
+
+	entry, found := xRefTable.FindTableEntryForIndRef(&indRef)
+	if !found || arr == nil {
+		d.Update(key, PDFArray{obj})
+		return nil
+	}
+
+	entry.Object = append(*arr, obj)
+
+	return nil
+}
+
+// removeFromArrayEntry removes the indirect references to objNrs from the array entry key of d.
+func removeFromArrayEntry(xRefTable *XRefTable, d *PDFDict, key string, objNrs IntSet) error {
+
+	o, found := d.Find(key)
+	if !found || o == nil {
+		return nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(o)
+	if err != nil || arr == nil {
+		return err
+	}
+
+	a := PDFArray{}
+	for _, v := range *arr {
+		if indRef, ok := v.(PDFIndirectRef); ok && objNrs[indRef.ObjectNumber.Value()] {
+			continue
+		}
+		a = append(a, v)
+	}
+
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		if entry, found := xRefTable.FindTableEntryForIndRef(&indRef); found {
+			entry.Object = a
+			return nil
+		}
+	}
+
+	d.Update(key, a)
+
+	return nil
+}
+
+// ensureAcroForm returns the interactive form dict of a PDF and creates it if necessary.
+func ensureAcroForm(xRefTable *XRefTable) (*PDFDict, error) {
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil || acroForm != nil {
+		return acroForm, err
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewPDFDict()
+	d.Insert("Fields", PDFArray{})
+	d.InsertString("DA", defaultFieldDA)
+
+	rootDict.Insert("AcroForm", d)
+
+	return &d, nil
+}
+
+// defaultResourceFont returns the font named id of the default resources of acroForm.
+// If there is none a simple font for baseFont gets created.
+func defaultResourceFont(xRefTable *XRefTable, acroForm *PDFDict, id, baseFont string) (PDFObject, error) {
+
+	dr, err := resourceCategoryDict(xRefTable, acroForm, "DR")
+	if err != nil {
+		return nil, err
+	}
+
+	fonts, err := resourceCategoryDict(xRefTable, dr, "Font")
+	if err != nil {
+		return nil, err
+	}
+
+	if o, found := fonts.Find(id); found {
+		return o, nil
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Font")
+	d.InsertName("Subtype", "Type1")
+	d.InsertName("BaseFont", baseFont)
+	if baseFont != "ZapfDingbats" {
+		d.InsertName("Encoding", "WinAnsiEncoding")
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return nil, err
+	}
+
+	fonts.Insert(id, *indRef)
+
+	return *indRef, nil
+}
+
+// appearanceStream creates a Form XObject of width w and height h.
+func appearanceStream(xRefTable *XRefTable, w, h float64, content string, res *PDFDict) (*PDFIndirectRef, error) {
+
+	sd := &PDFStreamDict{
+		PDFDict: PDFDict{
+			Dict: map[string]PDFObject{
+				"Type":    PDFName("XObject"),
+				"Subtype": PDFName("Form"),
+				"BBox":    NewRectangle(0, 0, w, h),
+			},
+		},
+		Content: []byte(content),
+	}
+
+	if res != nil {
+		sd.Insert("Resources", *res)
+	}
+
+	err := encodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.IndRefForNewObject(*sd)
+}
+
+// borderContent returns the content for a rectangular field border.
+func borderContent(w, h float64) string {
+	lw := formBuilderLineW
+	return fmt.Sprintf("q 0 G %.1f w %.2f %.2f %.2f %.2f re S Q ", lw, lw/2, lw/2, w-lw, h-lw)
+}
+
+// circleContent returns a path for a circle approximated by Bézier curves.
+func circleContent(cx, cy, r float64) string {
+
+	const k = 0.5523 // 4/3*(sqrt(2)-1)
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%.2f %.2f m ", cx+r, cy)
+	fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f %.2f %.2f c ", cx+r, cy+k*r, cx+k*r, cy+r, cx, cy+r)
+	fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f %.2f %.2f c ", cx-k*r, cy+r, cx-r, cy+k*r, cx-r, cy)
+	fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f %.2f %.2f c ", cx-r, cy-k*r, cx-k*r, cy-r, cx, cy-r)
+	fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f %.2f %.2f c ", cx+k*r, cy-r, cx+r, cy-k*r, cx+r, cy)
+
+	return b.String()
+}
+
+// validName returns true if s may be written as PDF name without escaping.
+func validName(s string) bool {
+
+	if len(s) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || c == '#' || contentDelimiter(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// addField registers a new field in the interactive form and its widgets on their pages.
+func addField(xRefTable *XRefTable, acroForm *PDFDict, field PDFIndirectRef, widgets []PDFIndirectRef, pages []int) error {
+
+	for i, w := range widgets {
+
+		pageDict, _, err := xRefTable.PageDict(pages[i])
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			return errors.Errorf("page %d not found", pages[i])
+		}
+
+		err = appendToArrayEntry(xRefTable, pageDict, "Annots", w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return appendToArrayEntry(xRefTable, acroForm, "Fields", field)
+}
+
+// addTextOrChoiceField adds a field along with a single widget and generates its appearance.
+func addTextOrChoiceField(xRefTable *XRefTable, fa FieldAttributes, d PDFDict, value string) error {
+
+	acroForm, err := ensureAcroForm(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	_, err = defaultResourceFont(xRefTable, acroForm, "Helv", "Helvetica")
+	if err != nil {
+		return err
+	}
+
+	da := defaultFieldDA
+	if s := acroForm.StringEntry("DA"); s != nil {
+		da = *s
+	}
+
+	addWidgetEntries(d, fa.Rect)
+
+	if len(value) > 0 {
+		d.Insert("V", encodeText(value))
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	f := &formField{
+		name:    fa.Name,
+		indRef:  *indRef,
+		dict:    &d,
+		fa:      fieldAttrs{da: &da},
+		widgets: []PDFIndirectRef{*indRef},
+	}
+	f.fa = inheritFieldAttrs(&d, f.fa)
+
+	err = updateTextAppearances(xRefTable, acroForm, f, value)
+	if err != nil {
+		return err
+	}
+
+	return addField(xRefTable, acroForm, *indRef, []PDFIndirectRef{*indRef}, []int{fa.Page})
+}
+
+// AddTextField adds a text field with an optional initial value.
+func AddTextField(xRefTable *XRefTable, fa FieldAttributes, value string, multiline bool) error {
+
+	err := fa.validate(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	ff := 0
+	if multiline {
+		ff = FieldMultiline
+	}
+
+	return addTextOrChoiceField(xRefTable, fa, fa.fieldDict("Tx", ff), value)
+}
+
+// AddComboBox adds a combo box offering options with an optional initial value.
+// An editable combo box also accepts values not contained in options.
+func AddComboBox(xRefTable *XRefTable, fa FieldAttributes, options []string, value string, editable bool) error {
+
+	err := fa.validate(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	if len(value) > 0 && !editable && indexOf(options, value) < 0 {
+		return errors.Errorf("invalid value for combo box %s: %s, valid options: %s", fa.Name, value, strings.Join(options, ","))
+	}
+
+	ff := FieldCombo
+	if editable {
+		ff |= FieldEdit
+	}
+
+	d := fa.fieldDict("Ch", ff)
+
+	opt := PDFArray{}
+	for _, s := range options {
+		opt = append(opt, encodeText(s))
+	}
+	d.Insert("Opt", opt)
+
+	if i := indexOf(options, value); i >= 0 {
+		d.Insert("I", NewIntegerArray(i))
+	}
+
+	return addTextOrChoiceField(xRefTable, fa, d, value)
+}
+
+// buttonAppearances returns the normal appearance dict for a checkbox or radio button widget of width w and height h.
+func buttonAppearances(xRefTable *XRefTable, acroForm *PDFDict, onState string, radio bool, w, h float64) (PDFDict, error) {
+
+	var on, off string
+
+	if radio {
+		r := (minFloat(w, h) - formBuilderLineW) / 2
+		off = fmt.Sprintf("q 0 G %.1f w %sS Q ", formBuilderLineW, circleContent(w/2, h/2, r))
+		on = off + fmt.Sprintf("q 0 g %sf Q ", circleContent(w/2, h/2, r/2))
+	} else {
+		off = borderContent(w, h)
+		fs := minFloat(w, h) * 0.8
+		on = off + fmt.Sprintf("q 0 g BT /ZaDb %.2f Tf %.2f %.2f Td (%s) Tj ET Q ", fs, (w-checkGlyphWidth*fs)/2, (h-0.7*fs)/2, checkGlyph)
+	}
+
+	var res *PDFDict
+
+	if !radio {
+		font, err := defaultResourceFont(xRefTable, acroForm, "ZaDb", "ZapfDingbats")
+		if err != nil {
+			return PDFDict{}, err
+		}
+		res = &PDFDict{Dict: map[string]PDFObject{"Font": PDFDict{Dict: map[string]PDFObject{"ZaDb": font}}}}
+	}
+
+	onRef, err := appearanceStream(xRefTable, w, h, on, res)
+	if err != nil {
+		return PDFDict{}, err
+	}
+
+	offRef, err := appearanceStream(xRefTable, w, h, off, nil)
+	if err != nil {
+		return PDFDict{}, err
+	}
+
+	return PDFDict{Dict: map[string]PDFObject{onState: *onRef, "Off": *offRef}}, nil
+}
+
+func minFloat(x, y float64) float64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// AddCheckBox adds a checkbox using "Yes" as on state.
+func AddCheckBox(xRefTable *XRefTable, fa FieldAttributes, checked bool) error {
+
+	err := fa.validate(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	acroForm, err := ensureAcroForm(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	d := fa.fieldDict("Btn", 0)
+	addWidgetEntries(d, fa.Rect)
+
+	state := "Off"
+	if checked {
+		state = "Yes"
+	}
+	d.Insert("V", PDFName(state))
+	d.Insert("AS", PDFName(state))
+	d.Insert("MK", PDFDict{Dict: map[string]PDFObject{"CA": PDFStringLiteral(checkGlyph)}})
+
+	n, err := buttonAppearances(xRefTable, acroForm, "Yes", false, fa.Rect.Width(), fa.Rect.Height())
+	if err != nil {
+		return err
+	}
+	d.Insert("AP", PDFDict{Dict: map[string]PDFObject{"N": n}})
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	return addField(xRefTable, acroForm, *indRef, []PDFIndirectRef{*indRef}, []int{fa.Page})
+}
+
+// AddRadioGroup adds a group of mutually exclusive radio buttons on page fa.Page.
+// selected is the value of the initially selected button and may be empty. fa.Rect is ignored.
+func AddRadioGroup(xRefTable *XRefTable, fa FieldAttributes, buttons []RadioButton, selected string) error {
+
+	err := fa.validate(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	if len(buttons) == 0 {
+		return errors.Errorf("radio group %s: missing buttons", fa.Name)
+	}
+
+	var values []string
+
+	for _, b := range buttons {
+		if !validName(b.Value) || b.Value == "Off" {
+			return errors.Errorf("radio group %s: invalid button value %q", fa.Name, b.Value)
+		}
+		if indexOf(values, b.Value) >= 0 {
+			return errors.Errorf("radio group %s: duplicate button value %s", fa.Name, b.Value)
+		}
+		values = append(values, b.Value)
+	}
+
+	if len(selected) > 0 && indexOf(values, selected) < 0 {
+		return errors.Errorf("invalid value for radio button %s: %s, valid options: %s", fa.Name, selected, strings.Join(values, ","))
+	}
+
+	acroForm, err := ensureAcroForm(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	state := "Off"
+	if len(selected) > 0 {
+		state = selected
+	}
+
+	d := fa.fieldDict("Btn", FieldRadio|FieldNoToggleToOff)
+	d.Insert("V", PDFName(state))
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	var widgets []PDFIndirectRef
+	var pages []int
+
+	for _, b := range buttons {
+
+		wd := NewPDFDict()
+		addWidgetEntries(wd, b.Rect)
+		wd.Insert("Parent", *indRef)
+
+		as := "Off"
+		if b.Value == selected {
+			as = b.Value
+		}
+		wd.Insert("AS", PDFName(as))
+
+		n, err := buttonAppearances(xRefTable, acroForm, b.Value, true, b.Rect.Width(), b.Rect.Height())
+		if err != nil {
+			return err
+		}
+		wd.Insert("AP", PDFDict{Dict: map[string]PDFObject{"N": n}})
+
+		wRef, err := xRefTable.IndRefForNewObject(wd)
+		if err != nil {
+			return err
+		}
+
+		widgets = append(widgets, *wRef)
+		pages = append(pages, fa.Page)
+	}
+
+	kids := PDFArray{}
+	for _, w := range widgets {
+		kids = append(kids, w)
+	}
+	d.Insert("Kids", kids)
+
+	return addField(xRefTable, acroForm, *indRef, widgets, pages)
+}
+
+// ParseSignatureFieldDetails parses a signature field command string into field attributes and a placeholder label.
+// The string consists of the entry "name:fieldName" and optionally "page:n" (default: 1),
+// "rect:llx lly urx ury" (default: invisible field), "label:text" and "tooltip:text".
+func ParseSignatureFieldDetails(s string) (*FieldAttributes, string, error) {
+
+	fa := &FieldAttributes{Page: 1}
+	var label string
+
+	for _, s := range strings.Split(s, ",") {
+
+		ss := strings.SplitN(s, ":", 2)
+		if len(ss) != 2 {
+			return nil, "", errors.Errorf("invalid signature field configuration: %s", s)
+		}
+
+		k := strings.TrimSpace(ss[0])
+		v := strings.TrimSpace(ss[1])
+
+		switch k {
+
+		case "name":
+			fa.Name = v
+
+		case "page":
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 1 {
+				return nil, "", errors.Errorf("invalid page: %s", v)
+			}
+			fa.Page = i
+
+		case "rect":
+			l := &Link{}
+			if err := parseLinkRect(v, l); err != nil {
+				return nil, "", err
+			}
+			fa.Rect = l.Rect
+
+		case "label":
+			label = v
+
+		case "tooltip":
+			fa.Tooltip = v
+
+		default:
+			return nil, "", errors.Errorf("invalid signature field configuration: %s", s)
+		}
+	}
+
+	if len(fa.Name) == 0 {
+		return nil, "", errors.New("missing signature field name")
+	}
+
+	return fa, label, nil
+}
+
+// signaturePlaceholder returns the content of a visual placeholder of width w and height h
+// consisting of a border and a signature line with an optional label below.
+func signaturePlaceholder(label string, w, h float64) string {
+
+	const margin = 6.0
+
+	fs := minFloat(8, h/4)
+	y := fs + 4
+
+	var b bytes.Buffer
+
+	b.WriteString(borderContent(w, h))
+	fmt.Fprintf(&b, "q 0 G 0.5 w %.2f %.2f m %.2f %.2f l S Q ", margin, y, w-margin, y)
+
+	if len(label) > 0 {
+		esc, _ := Escape(winAnsiString(label))
+		fmt.Fprintf(&b, "q 0 g BT /Helv %.2f Tf %.2f 2 Td (%s) Tj ET Q ", fs, margin, *esc)
+	}
+
+	return b.String()
+}
+
+// AddSignatureField adds an unsigned signature field ready to be signed.
+// A field with an empty rect is invisible, otherwise the widget shows a placeholder
+// consisting of a border and a signature line with an optional label below.
+func AddSignatureField(xRefTable *XRefTable, fa FieldAttributes, label string) error {
+
+	err := fa.validate(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	w, h := fa.Rect.Width(), fa.Rect.Height()
+	if w < 0 || h < 0 {
+		return errors.Errorf("invalid rect: %s", fa.Rect)
+	}
+
+	acroForm, err := ensureAcroForm(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	d := fa.fieldDict("Sig", 0)
+	addWidgetEntries(d, fa.Rect)
+
+	if w > 0 && h > 0 {
+
+		var res *PDFDict
+
+		if len(label) > 0 {
+			font, err := defaultResourceFont(xRefTable, acroForm, "Helv", "Helvetica")
+			if err != nil {
+				return err
+			}
+			res = &PDFDict{Dict: map[string]PDFObject{"Font": PDFDict{Dict: map[string]PDFObject{"Helv": font}}}}
+		}
+
+		ap, err := appearanceStream(xRefTable, w, h, signaturePlaceholder(label, w, h), res)
+		if err != nil {
+			return err
+		}
+		d.Insert("AP", PDFDict{Dict: map[string]PDFObject{"N": *ap}})
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	// Signature fields require SigFlags SignaturesExist.
+	sigFlags := 0
+	if i := acroForm.IntEntry("SigFlags"); i != nil {
+		sigFlags = *i
+	}
+	acroForm.Update("SigFlags", PDFInteger(sigFlags|1))
+
+	return addField(xRefTable, acroForm, *indRef, []PDFIndirectRef{*indRef}, []int{fa.Page})
+}
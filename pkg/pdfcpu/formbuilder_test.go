@@ -0,0 +1,244 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/types"
+)
+
+func buildDemoForm(xRefTable *XRefTable) error {
+
+	fa := FieldAttributes{Name: "name", Page: 1, Rect: types.NewRectangle(50, 500, 250, 520), Tooltip: "Your name", Required: true}
+	if err := AddTextField(xRefTable, fa, "Joe", false); err != nil {
+		return err
+	}
+
+	fa = FieldAttributes{Name: "comment", Page: 1, Rect: types.NewRectangle(50, 400, 250, 480)}
+	if err := AddTextField(xRefTable, fa, "", true); err != nil {
+		return err
+	}
+
+	fa = FieldAttributes{Name: "married", Page: 1, Rect: types.NewRectangle(50, 370, 65, 385)}
+	if err := AddCheckBox(xRefTable, fa, true); err != nil {
+		return err
+	}
+
+	fa = FieldAttributes{Name: "gender", Page: 1}
+	buttons := []RadioButton{
+		{Value: "female", Rect: types.NewRectangle(50, 340, 65, 355)},
+		{Value: "male", Rect: types.NewRectangle(100, 340, 115, 355)},
+	}
+	if err := AddRadioGroup(xRefTable, fa, buttons, "male"); err != nil {
+		return err
+	}
+
+	fa = FieldAttributes{Name: "language", Page: 1, Rect: types.NewRectangle(50, 300, 200, 320)}
+	if err := AddComboBox(xRefTable, fa, []string{"English", "German", "French"}, "German", false); err != nil {
+		return err
+	}
+
+	fa = FieldAttributes{Name: "signature", Page: 1, Rect: types.NewRectangle(50, 200, 250, 250)}
+	if err := AddSignatureField(xRefTable, fa, "Signature"); err != nil {
+		return err
+	}
+
+	// An invisible signature field.
+	return AddSignatureField(xRefTable, FieldAttributes{Name: "approval", Page: 1}, "")
+}
+
+func TestFormBuilder(t *testing.T) {
+
+	xRefTable, err := CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("CreateDemoXRef: %v\n", err)
+	}
+
+	err = buildDemoForm(xRefTable)
+	if err != nil {
+		t.Fatalf("buildDemoForm: %v\n", err)
+	}
+
+	// Field names are unique.
+	fa := FieldAttributes{Name: "name", Page: 1, Rect: types.NewRectangle(0, 0, 10, 10)}
+	if err = AddCheckBox(xRefTable, fa, false); err == nil {
+		t.Fatal("AddCheckBox: should have failed for duplicate field name")
+	}
+
+	fa.Name = "country"
+	if err = AddComboBox(xRefTable, fa, []string{"Germany"}, "France", false); err == nil {
+		t.Fatal("AddComboBox: should have failed for invalid value")
+	}
+
+	err = FillForm(xRefTable, map[string]interface{}{"comment": "Hello\nWorld", "married": false, "gender": "female", "language": "French"}, false)
+	if err != nil {
+		t.Fatalf("FillForm: %v\n", err)
+	}
+
+	fileName := "formBuilder.pdf"
+	err = CreatePDF(xRefTable, outDir+"/", fileName)
+	if err != nil {
+		t.Fatalf("CreatePDF: %v\n", err)
+	}
+
+	ctx, err := ReadPDFFile(filepath.Join(outDir, fileName), NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	err = ValidateXRefTable(ctx.XRefTable)
+	if err != nil {
+		t.Fatalf("ValidateXRefTable: %v\n", err)
+	}
+
+	fields, err := ListFormFields(ctx.XRefTable)
+	if err != nil {
+		t.Fatalf("ListFormFields: %v\n", err)
+	}
+
+	want := []struct{ name, typ, value string }{
+		{"name", "text", "Joe"},
+		{"comment", "text", "Hello\nWorld"},
+		{"married", "checkbox", "Off"},
+		{"gender", "radio", "female"},
+		{"language", "combobox", "French"},
+		{"signature", "signature", ""},
+		{"approval", "signature", ""},
+	}
+
+	if len(fields) != len(want) {
+		t.Fatalf("ListFormFields: got %d fields want %d\n", len(fields), len(want))
+	}
+
+	for i, w := range want {
+		f := fields[i]
+		if f.Name != w.name || f.Type != w.typ || f.Value != w.value || f.Page != 1 {
+			t.Errorf("field %d: got %s %s %q page %d, want %s %s %q page 1\n", i, f.Name, f.Type, f.Value, f.Page, w.name, w.typ, w.value)
+		}
+	}
+
+	if !fields[0].Required {
+		t.Error("field name should be required")
+	}
+}
+
+func TestListFormFieldsPage(t *testing.T) {
+
+	xRefTable := newXRefTable(ValidationRelaxed)
+	xRefTable.Table[0] = NewFreeHeadXRefTableEntry()
+
+	indRef := func(objNr int) PDFIndirectRef { return *NewPDFIndirectRef(objNr, 0) }
+
+	dict := func(m map[string]PDFObject) PDFDict { return PDFDict{Dict: m} }
+
+	xRefTable.Table[1] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":  PDFName("Catalog"),
+		"Pages": indRef(2),
+	}))
+
+	xRefTable.Table[2] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":  PDFName("Pages"),
+		"Kids":  PDFArray{indRef(3), indRef(4)},
+		"Count": PDFInteger(2),
+	}))
+
+	// The annotations of page 1 resolve to null.
+	xRefTable.Table[3] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":     PDFName("Page"),
+		"Parent":   indRef(2),
+		"MediaBox": NewRectangle(0, 0, 200, 200),
+		"Annots":   indRef(5),
+	}))
+
+	xRefTable.Table[4] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":     PDFName("Page"),
+		"Parent":   indRef(2),
+		"MediaBox": NewRectangle(0, 0, 200, 200),
+	}))
+
+	xRefTable.Table[5] = NewXRefTableEntryGen0(nil)
+
+	root := indRef(1)
+	xRefTable.Root = &root
+	xRefTable.PageCount = 2
+
+	size := 6
+	xRefTable.Size = &size
+
+	fa := FieldAttributes{Name: "name", Page: 2, Rect: types.NewRectangle(50, 100, 150, 120)}
+	if err := AddTextField(xRefTable, fa, "Joe", false); err != nil {
+		t.Fatalf("AddTextField: %v\n", err)
+	}
+
+	fields, err := ListFormFields(xRefTable)
+	if err != nil {
+		t.Fatalf("ListFormFields: %v\n", err)
+	}
+
+	if len(fields) != 1 || fields[0].Page != 2 {
+		t.Fatalf("ListFormFields: got %v, want field name on page 2\n", fields)
+	}
+}
+
+func TestListFormFieldsNullAcroForm(t *testing.T) {
+
+	xRefTable := newXRefTable(ValidationRelaxed)
+	xRefTable.Table[0] = NewFreeHeadXRefTableEntry()
+
+	indRef := func(objNr int) PDFIndirectRef { return *NewPDFIndirectRef(objNr, 0) }
+
+	dict := func(m map[string]PDFObject) PDFDict { return PDFDict{Dict: m} }
+
+	// The interactive form resolves to null.
+	xRefTable.Table[1] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":     PDFName("Catalog"),
+		"Pages":    indRef(2),
+		"AcroForm": indRef(4),
+	}))
+
+	xRefTable.Table[2] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":  PDFName("Pages"),
+		"Kids":  PDFArray{indRef(3)},
+		"Count": PDFInteger(1),
+	}))
+
+	xRefTable.Table[3] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":     PDFName("Page"),
+		"Parent":   indRef(2),
+		"MediaBox": NewRectangle(0, 0, 200, 200),
+	}))
+
+	xRefTable.Table[4] = NewXRefTableEntryGen0(nil)
+
+	root := indRef(1)
+	xRefTable.Root = &root
+	xRefTable.PageCount = 1
+
+	size := 5
+	xRefTable.Size = &size
+
+	fields, err := ListFormFields(xRefTable)
+	if err != nil {
+		t.Fatalf("ListFormFields: %v\n", err)
+	}
+
+	if fields == nil || len(fields) != 0 {
+		t.Fatalf("ListFormFields: got %v, want no fields\n", fields)
+	}
+}
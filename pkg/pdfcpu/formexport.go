@@ -0,0 +1,136 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+)
+
+// formValue represents the value of a terminal field.
+type formValue struct {
+	name  string      // fully qualified field name
+	value interface{} // string or []string
+}
+
+// formValues returns the values of all exportable fields in the order of the field hierarchy.
+func formValues(xRefTable *XRefTable) ([]formValue, error) {
+
+	root, err := formData(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var vv []formValue
+
+	var walk func(n *formDataNode, prefix string)
+	walk = func(n *formDataNode, prefix string) {
+		name := n.name
+		if len(prefix) > 0 {
+			name = prefix + "." + name
+		}
+		if n.value != nil {
+			vv = append(vv, formValue{name, n.value})
+		}
+		for _, k := range n.kids {
+			walk(k, name)
+		}
+	}
+
+	for _, k := range root.kids {
+		walk(k, "")
+	}
+
+	return vv, nil
+}
+
+// ExportFormJSON returns the form data of a PDF as JSON object mapping fully qualified field names to values.
+// The result may be used as input for FillForm.
+func ExportFormJSON(xRefTable *XRefTable) ([]byte, error) {
+
+	vv, err := formValues(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString("{")
+
+	for i, v := range vv {
+
+		if i > 0 {
+			b.WriteString(",")
+		}
+
+		k, err := json.Marshal(v.name)
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := json.Marshal(v.value)
+		if err != nil {
+			return nil, err
+		}
+
+		b.WriteString("\n\t")
+		b.Write(k)
+		b.WriteString(": ")
+		b.Write(val)
+	}
+
+	b.WriteString("\n}\n")
+
+	return b.Bytes(), nil
+}
+
+// ExportFormCSV returns the form data of a PDF as CSV
+// consisting of a header line with the fully qualified field names and a line with the corresponding values.
+// Multiple selections are separated by commas.
+func ExportFormCSV(xRefTable *XRefTable) ([]byte, error) {
+
+	vv, err := formValues(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]string, len(vv))
+	values := make([]string, len(vv))
+
+	for i, v := range vv {
+		header[i] = v.name
+		switch val := v.value.(type) {
+		case string:
+			values[i] = val
+		case []string:
+			values[i] = strings.Join(val, ",")
+		}
+	}
+
+	var b bytes.Buffer
+
+	w := csv.NewWriter(&b)
+
+	err = w.WriteAll([][]string{header, values})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,736 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hhrutter/pdfcpu/pkg/fonts/metrics"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+
+	"github.com/pkg/errors"
+)
+
+// More field flags, see 12.7.4.3 Table 228 and 12.7.4.4 Table 230.
+const (
+	FieldMultiline = 1 << 12
+	FieldEdit      = 1 << 18
+	FieldComb      = 1 << 24
+)
+
+// textFieldBorder is the padding of text within the appearance of a text or choice field.
+const textFieldBorder = 2.0
+
+// ParseFormData parses JSON form data.
+//
+// Supported are an object mapping fully qualified field names to values,
+// where values are strings, numbers, booleans (checkboxes) or arrays of strings (multiple selection list boxes)
+// or the array of fields as returned by "pdfcpu form list" with read-only fields, push buttons and signature fields being skipped.
+func ParseFormData(bb []byte) (map[string]interface{}, error) {
+
+	bb = bytes.TrimSpace(bb)
+
+	if len(bb) > 0 && bb[0] == '[' {
+
+		var fields []FormField
+		err := json.Unmarshal(bb, &fields)
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseFormData")
+		}
+
+		m := map[string]interface{}{}
+		for _, f := range fields {
+			if f.ReadOnly || f.Type == "pushbutton" || f.Type == "signature" {
+				continue
+			}
+			m[f.Name] = f.Value
+		}
+
+		return m, nil
+	}
+
+	m := map[string]interface{}{}
+
+	err := json.Unmarshal(bb, &m)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseFormData")
+	}
+
+	return m, nil
+}
+
+// encodeText returns a string literal for s using UTF-16BE if s is not plain ASCII.
+func encodeText(s string) PDFStringLiteral {
+
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			s = EncodeUTF16String(s)
+			break
+		}
+	}
+
+	s1, _ := Escape(s)
+
+	return PDFStringLiteral(*s1)
+}
+
+func formValueString(v interface{}) (string, error) {
+
+	switch v := v.(type) {
+
+	case string:
+		return v, nil
+
+	case bool:
+		return strconv.FormatBool(v), nil
+
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+
+	case nil:
+		return "", nil
+	}
+
+	return "", errors.Errorf("unsupported value: %v", v)
+}
+
+func formValueStrings(v interface{}) ([]string, error) {
+
+	arr, ok := v.([]interface{})
+	if !ok {
+		s, err := formValueString(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+
+	ss := make([]string, len(arr))
+
+	for i, v := range arr {
+		s, err := formValueString(v)
+		if err != nil {
+			return nil, err
+		}
+		ss[i] = s
+	}
+
+	return ss, nil
+}
+
+// appearanceStates returns the names of the normal appearance states of a widget.
+func appearanceStates(xRefTable *XRefTable, widget *PDFDict) ([]string, error) {
+
+	obj, found := widget.Find("AP")
+	if !found {
+		return nil, nil
+	}
+
+	ap, err := xRefTable.DereferenceDict(obj)
+	if err != nil || ap == nil {
+		return nil, err
+	}
+
+	obj, found = ap.Find("N")
+	if !found {
+		return nil, nil
+	}
+
+	obj, err = xRefTable.Dereference(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	d, ok := obj.(PDFDict)
+	if !ok {
+		return nil, nil
+	}
+
+	var ss []string
+	for k := range d.Dict {
+		ss = append(ss, k)
+	}
+
+	sort.Strings(ss)
+
+	return ss, nil
+}
+
+// onState returns the name of the on state of a checkbox or radio button widget.
+func onState(xRefTable *XRefTable, widget *PDFDict) (string, error) {
+
+	ss, err := appearanceStates(xRefTable, widget)
+	if err != nil {
+		return "", err
+	}
+
+	for _, s := range ss {
+		if s != "Off" {
+			return s, nil
+		}
+	}
+
+	return "Yes", nil
+}
+
+func (f *formField) widgetDicts(xRefTable *XRefTable) ([]*PDFDict, error) {
+
+	var dd []*PDFDict
+
+	for _, indRef := range f.widgets {
+		d, err := xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			dd = append(dd, d)
+		}
+	}
+
+	return dd, nil
+}
+
+func fillCheckBox(xRefTable *XRefTable, f *formField, v interface{}) error {
+
+	widgets, err := f.widgetDicts(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	on := "Yes"
+	if len(widgets) > 0 {
+		on, err = onState(xRefTable, widgets[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	var checked bool
+
+	switch v := v.(type) {
+
+	case bool:
+		checked = v
+
+	case string:
+		switch v {
+		case on, "true", "Yes", "On":
+			checked = true
+		case "Off", "false", "":
+			checked = false
+		default:
+			return errors.Errorf("invalid value for checkbox %s: %s", f.name, v)
+		}
+
+	default:
+		return errors.Errorf("invalid value for checkbox %s: %v", f.name, v)
+	}
+
+	state := "Off"
+	if checked {
+		state = on
+	}
+
+	f.dict.Update("V", PDFName(state))
+
+	for _, w := range widgets {
+		w.Update("AS", PDFName(state))
+	}
+
+	return nil
+}
+
+func fillRadioButton(xRefTable *XRefTable, f *formField, v interface{}) error {
+
+	s, err := formValueString(v)
+	if err != nil {
+		return errors.Errorf("invalid value for radio button %s: %v", f.name, v)
+	}
+
+	if s == "" {
+		s = "Off"
+	}
+
+	widgets, err := f.widgetDicts(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	var options []string
+	found := s == "Off"
+
+	for _, w := range widgets {
+
+		on, err := onState(xRefTable, w)
+		if err != nil {
+			return err
+		}
+
+		options = append(options, on)
+
+		state := "Off"
+		if on == s {
+			state = on
+			found = true
+		}
+
+		w.Update("AS", PDFName(state))
+	}
+
+	if !found {
+		return errors.Errorf("invalid value for radio button %s: %s, valid options: %s", f.name, s, strings.Join(options, ","))
+	}
+
+	f.dict.Update("V", PDFName(s))
+
+	return nil
+}
+
+// choiceOptions returns the export values and the display texts of the options of a choice field.
+func choiceOptions(xRefTable *XRefTable, d *PDFDict) ([]string, []string, error) {
+
+	obj, found := d.Find("Opt")
+	if !found {
+		return nil, nil, nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return nil, nil, err
+	}
+
+	var exports, texts []string
+
+	for _, o := range *arr {
+
+		o, err = xRefTable.Dereference(o)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		export, text := o, o
+
+		if a, ok := o.(PDFArray); ok && len(a) == 2 {
+			export, text = a[0], a[1]
+		}
+
+		e, err := fieldValueString(xRefTable, export)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		t, err := fieldValueString(xRefTable, text)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		exports = append(exports, e)
+		texts = append(texts, t)
+	}
+
+	return exports, texts, nil
+}
+
+func indexOf(ss []string, s string) int {
+	for i, v := range ss {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func fillChoice(xRefTable *XRefTable, acroForm *PDFDict, f *formField, v interface{}) error {
+
+	ss, err := formValueStrings(v)
+	if err != nil {
+		return errors.Errorf("invalid value for choice field %s: %v", f.name, v)
+	}
+
+	exports, texts, err := choiceOptions(xRefTable, f.dict)
+	if err != nil {
+		return err
+	}
+
+	multi := f.fa.ff&FieldMultiSelect > 0
+	editable := f.fa.ff&FieldCombo > 0 && f.fa.ff&FieldEdit > 0
+
+	// Values may have been exported as comma separated list.
+	if multi && len(ss) == 1 && indexOf(exports, ss[0]) < 0 {
+		ss = strings.Split(ss[0], ",")
+	}
+
+	if len(ss) > 1 && !multi {
+		return errors.Errorf("choice field %s does not allow multiple selections", f.name)
+	}
+
+	var ii []int
+	var displayed []string
+
+	for _, s := range ss {
+
+		if s == "" {
+			continue
+		}
+
+		i := indexOf(exports, s)
+
+		if i < 0 && !editable && len(exports) > 0 {
+			return errors.Errorf("invalid value for choice field %s: %s, valid options: %s", f.name, s, strings.Join(exports, ","))
+		}
+
+		if i < 0 {
+			displayed = append(displayed, s)
+			continue
+		}
+
+		ii = append(ii, i)
+		displayed = append(displayed, texts[i])
+	}
+
+	switch len(ss) {
+	case 0:
+		f.dict.Delete("V")
+	case 1:
+		f.dict.Update("V", encodeText(ss[0]))
+	default:
+		arr := PDFArray{}
+		for _, s := range ss {
+			arr = append(arr, encodeText(s))
+		}
+		f.dict.Update("V", arr)
+	}
+
+	f.dict.Delete("I")
+	if len(ii) > 0 && !editable {
+		sort.Ints(ii)
+		f.dict.Insert("I", NewIntegerArray(ii...))
+	}
+
+	return updateTextAppearances(xRefTable, acroForm, f, strings.Join(displayed, "\n"))
+}
+
+func fillText(xRefTable *XRefTable, acroForm *PDFDict, f *formField, v interface{}) error {
+
+	s, err := formValueString(v)
+	if err != nil {
+		return errors.Errorf("invalid value for text field %s: %v", f.name, v)
+	}
+
+	f.dict.Update("V", encodeText(s))
+
+	return updateTextAppearances(xRefTable, acroForm, f, s)
+}
+
+// parseDA returns the font resource name and font size of a default appearance string.
+func parseDA(da string) (string, float64) {
+
+	ss := strings.Fields(da)
+
+	for i, s := range ss {
+		if s == "Tf" && i >= 2 {
+			size, err := strconv.ParseFloat(ss[i-1], 64)
+			if err != nil {
+				break
+			}
+			return strings.TrimPrefix(ss[i-2], "/"), size
+		}
+	}
+
+	return "Helv", 0
+}
+
+// fontResource looks up the font resource for fontID in the default resources of a field or the interactive form.
+// If there is none a Helvetica font gets created.
+func fontResource(xRefTable *XRefTable, acroForm, field *PDFDict, fontID string) (PDFObject, string, error) {
+
+	for _, d := range []*PDFDict{field, acroForm} {
+
+		if d == nil {
+			continue
+		}
+
+		obj, found := d.Find("DR")
+		if !found {
+			continue
+		}
+
+		dr, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return nil, "", err
+		}
+		if dr == nil {
+			continue
+		}
+
+		obj, found = dr.Find("Font")
+		if !found {
+			continue
+		}
+
+		fonts, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return nil, "", err
+		}
+		if fonts == nil {
+			continue
+		}
+
+		fontObj, found := fonts.Find(fontID)
+		if !found {
+			continue
+		}
+
+		fontDict, err := xRefTable.DereferenceDict(fontObj)
+		if err != nil {
+			return nil, "", err
+		}
+
+		baseFont := ""
+		if fontDict != nil {
+			if bf := fontDict.NameEntry("BaseFont"); bf != nil {
+				baseFont = *bf
+			}
+		}
+
+		return fontObj, baseFont, nil
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Font")
+	d.InsertName("Subtype", "Type1")
+	d.InsertName("BaseFont", "Helvetica")
+	d.InsertName("Encoding", "WinAnsiEncoding")
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return *indRef, "Helvetica", nil
+}
+
+func textWidth(s, baseFont string, fontSize float64) float64 {
+
+	for _, fn := range metrics.FontNames() {
+		if fn == baseFont {
+			return metrics.TextWidth(s, baseFont, 1000) * fontSize / 1000
+		}
+	}
+
+	// Rough estimate for non standard fonts.
+	return float64(utf8.RuneCountInString(s)) * fontSize / 2
+}
+
+// replaceFontSize replaces the font size of the Tf operator within a default appearance string.
+func replaceFontSize(da string, fontSize float64) string {
+
+	ss := strings.Fields(da)
+
+	for i, s := range ss {
+		if s == "Tf" && i >= 2 {
+			ss[i-1] = strconv.FormatFloat(fontSize, 'f', 2, 64)
+		}
+	}
+
+	return strings.Join(ss, " ")
+}
+
+// winAnsiString maps s to single byte characters for use with simple fonts.
+func winAnsiString(s string) string {
+
+	var b bytes.Buffer
+
+	for _, r := range s {
+		if r > 0xFF {
+			r = '?'
+		}
+		b.WriteByte(byte(r))
+	}
+
+	return b.String()
+}
+
+// wrapText breaks the lines of s into lines fitting width.
+func wrapText(s, baseFont string, fontSize, width float64) string {
+
+	var lines []string
+
+	for _, para := range strings.Split(s, "\n") {
+
+		var line string
+
+		for _, w := range strings.Fields(para) {
+
+			if line == "" {
+				line = w
+				continue
+			}
+
+			if textWidth(line+" "+w, baseFont, fontSize) > width {
+				lines = append(lines, line)
+				line = w
+				continue
+			}
+
+			line += " " + w
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// textAppearance returns the content of a normal appearance stream for text of width w and height h.
+// Multiline text gets wrapped to fit w.
+func textAppearance(s, da, baseFont string, fontSize float64, q int, multiline bool, w, h float64) []byte {
+
+	const border = textFieldBorder
+
+	if !multiline {
+		s = strings.Replace(s, "\n", " ", -1)
+	}
+
+	if fontSize == 0 {
+		// Auto size
+		fontSize = 12
+		if !multiline {
+			fontSize = (h - 2*border) * 0.7
+			if tw := textWidth(s, baseFont, fontSize); tw > w-2*border && tw > 0 {
+				fontSize *= (w - 2*border) / tw
+			}
+			if fontSize < 4 {
+				fontSize = 4
+			}
+		}
+		da = replaceFontSize(da, fontSize)
+	}
+
+	lines := []string{s}
+	if multiline {
+		lines = strings.Split(wrapText(s, baseFont, fontSize, w-2*border), "\n")
+	}
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "/Tx BMC q %.2f %.2f %.2f %.2f re W n BT %s ", border/2, border/2, w-border, h-border, da)
+
+	y := (h-fontSize)/2 + fontSize*0.22
+	if multiline {
+		y = h - border - fontSize
+	}
+
+	for _, line := range lines {
+
+		x := border
+		switch q {
+		case 1:
+			x = (w - textWidth(line, baseFont, fontSize)) / 2
+		case 2:
+			x = w - border - textWidth(line, baseFont, fontSize)
+		}
+
+		esc, _ := Escape(winAnsiString(line))
+		fmt.Fprintf(&b, "1 0 0 1 %.2f %.2f Tm (%s) Tj ", x, y, *esc)
+
+		y -= fontSize * 1.15
+	}
+
+	b.WriteString("ET Q EMC")
+
+	return b.Bytes()
+}
+
+// FillForm sets the values of form fields and regenerates their appearance streams.
+// If needAppearances is true, viewers are also asked to regenerate all appearance streams.
+func FillForm(xRefTable *XRefTable, values map[string]interface{}, needAppearances bool) error {
+
+	log.Debug.Println("FillForm begin")
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	if acroForm == nil {
+		return errors.New("FillForm: no form available")
+	}
+
+	filled := map[string]bool{}
+
+	err = processFormFields(xRefTable, func(f *formField) error {
+
+		v, ok := values[f.name]
+		if !ok {
+			return nil
+		}
+
+		filled[f.name] = true
+
+		if f.fa.ff&FieldReadOnly > 0 {
+			return errors.Errorf("FillForm: field %s is read-only", f.name)
+		}
+
+		switch fieldTypeString(f.fa.ft, f.fa.ff) {
+
+		case "text":
+			return fillText(xRefTable, acroForm, f, v)
+
+		case "checkbox":
+			return fillCheckBox(xRefTable, f, v)
+
+		case "radio":
+			return fillRadioButton(xRefTable, f, v)
+
+		case "combobox", "listbox":
+			return fillChoice(xRefTable, acroForm, f, v)
+		}
+
+		return errors.Errorf("FillForm: field %s of type %s cannot be filled", f.name, fieldTypeString(f.fa.ft, f.fa.ff))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	for k := range values {
+		if !filled[k] {
+			unknown = append(unknown, k)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.Errorf("FillForm: unknown fields: %s", strings.Join(unknown, ","))
+	}
+
+	if needAppearances {
+		acroForm.Update("NeedAppearances", PDFBoolean(true))
+	}
+
+	log.Debug.Println("FillForm end")
+
+	return nil
+}
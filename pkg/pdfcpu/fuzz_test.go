@@ -0,0 +1,128 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+)
+
+// The fuzz targets run their seed corpus as part of go test.
+// Run eg. go test -fuzz FuzzRead ./pkg/pdfcpu for fuzzing.
+
+func FuzzParseObject(f *testing.F) {
+
+	for _, s := range []string{
+		"null", "true", "-.5", "1 0 R", "/Name#20x", "(a\\(b\\)c)", "<AB12>",
+		"[true%comment\x0Anull]",
+		"<</Key[/Val1/Val2\x0d%gopher\x0atrue]/D<</N 1 2 R>>>>",
+		strings.Repeat("[", 1000) + strings.Repeat("]", 1000),
+		strings.Repeat("<</K", 1000) + strings.Repeat(">>", 1000),
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		parseObject(&s)
+	})
+}
+
+// demoPDF returns the bytes of a demo file created by create.
+func demoPDF(f *testing.F, fileName string, create func() (*XRefTable, error)) []byte {
+
+	xRefTable, err := create()
+	if err != nil {
+		f.Fatalf("%s: %v\n", fileName, err)
+	}
+
+	if err = CreatePDF(xRefTable, outDir+"/", fileName); err != nil {
+		f.Fatalf("%s: %v\n", fileName, err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(outDir, fileName))
+	if err != nil {
+		f.Fatalf("%s: %v\n", fileName, err)
+	}
+
+	return b
+}
+
+func FuzzRead(f *testing.F) {
+
+	f.Add([]byte("%PDF-1.7\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n" +
+		"2 0 obj\n<</Type/Pages/Kids[3 0 R]/Count 1>>\nendobj\n" +
+		"3 0 obj\n<</Type/Page/Parent 2 0 R/MediaBox[0 0 612 792]/Contents 4 0 R>>\nendobj\n" +
+		"4 0 obj\n<</Length 8>>\nstream\n0 0 m S\n\nendstream\nendobj\n" +
+		"trailer\n<</Size 5/Root 1 0 R>>\n%%EOF\n"))
+	f.Add(demoPDF(f, "fuzzDemo.pdf", CreateDemoXRef))
+	f.Add(demoPDF(f, "fuzzAcroFormDemo.pdf", CreateAcroFormDemoXRef))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+
+		ctx, err := Read(bytes.NewReader(b), NewDefaultConfiguration())
+		if err != nil {
+			return
+		}
+
+		if err = ValidateXRefTable(ctx.XRefTable); err != nil {
+			return
+		}
+
+		OptimizeXRefTable(ctx)
+	})
+}
+
+func FuzzWriteImage(f *testing.F) {
+
+	for _, c := range []struct {
+		dict    string
+		content []byte
+	}{
+		{"<</Width 2/Height 2/BitsPerComponent 8/ColorSpace/DeviceGray>>", []byte{0, 1, 2, 3}},
+		{"<</Width 2/Height 1/BitsPerComponent 8/ColorSpace/DeviceRGB>>", []byte{0, 1, 2, 3, 4, 5}},
+		{"<</Width 1/Height 1/BitsPerComponent 8/ColorSpace/DeviceCMYK>>", []byte{0, 1, 2, 3}},
+		{"<</Width 4/Height 1/BitsPerComponent 1/ColorSpace/DeviceGray/Decode[1 0]>>", []byte{0xA0}},
+		{"<</Width 2/Height 1/BitsPerComponent 4/ColorSpace[/Indexed/DeviceRGB 1<000000FFFFFF>]>>", []byte{0x10}},
+		{"<</Width 1/Height 1/BitsPerComponent 8/ColorSpace[/Indexed/DeviceCMYK 0(abcd)]>>", []byte{0}},
+		{"<</Width 1/Height 1/BitsPerComponent 8/ColorSpace[/CalRGB<<>>]>>", []byte{0, 1, 2}},
+	} {
+		f.Add(c.dict, c.content)
+	}
+
+	f.Fuzz(func(t *testing.T, s string, content []byte) {
+
+		o, err := parseObject(&s)
+		if err != nil {
+			return
+		}
+
+		d, ok := o.(PDFDict)
+		if !ok {
+			return
+		}
+
+		sd := NewPDFStreamDict(d, 0, nil, nil, []PDFFilter{{Name: filter.Flate}})
+		sd.Content = content
+
+		WriteImage(xRefTable, filepath.Join(t.TempDir(), "img"), &sd, 1)
+	})
+}
@@ -0,0 +1,466 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Measurement and geospatial viewports
+//
+// The VP array of a page lists rectangular regions with their own measure dict (12.9).
+// Rectilinear measure dicts (Subtype RL) scale distances eg. of floor plans.
+// Geospatial measure dicts (Subtype GEO, ISO 32000-2 12.10) register a map with a geographic or projected
+// coordinate system: GPTS holds latitude/longitude pairs of the points LPTS locates in the unit square
+// of the viewport. Bounds optionally restricts the unit square to the region covered by the map.
+
+// CoordSystem represents a geographic (GEOGCS) or projected (PROJCS) coordinate system.
+type CoordSystem struct {
+	Type string `json:"type"`           // GEOGCS or PROJCS
+	EPSG int    `json:"epsg,omitempty"` // EPSG reference code
+	WKT  string `json:"wkt,omitempty"`  // well known text
+}
+
+func (cs CoordSystem) String() string {
+
+	if cs.EPSG > 0 {
+		return fmt.Sprintf("%s EPSG:%d", cs.Type, cs.EPSG)
+	}
+
+	return cs.Type + " WKT"
+}
+
+// Viewport represents a viewport of a page along with its measure dict.
+type Viewport struct {
+	Page    int          `json:"page,omitempty"`    // defaults to 1 for AddViewports
+	Name    string       `json:"name,omitempty"`    // descriptive text
+	BBox    []float64    `json:"bbox"`              // region of the page in default user space
+	Subtype string       `json:"subtype,omitempty"` // measure dict type RL or GEO, empty for none
+	Ratio   string       `json:"ratio,omitempty"`   // RL: scale ratio, eg. "1in = 0.1m"
+	GCS     *CoordSystem `json:"gcs,omitempty"`     // GEO: coordinate system of GPTS
+	DCS     *CoordSystem `json:"dcs,omitempty"`     // GEO: coordinate system for displaying positions
+	PDU     []string     `json:"pdu,omitempty"`     // GEO: preferred linear, area and angular display units
+	Bounds  []float64    `json:"bounds,omitempty"`  // GEO: region of the unit square covered by the map
+	GPTS    []float64    `json:"gpts,omitempty"`    // GEO: latitude longitude pairs
+	LPTS    []float64    `json:"lpts,omitempty"`    // GEO: x y pairs in the unit square
+}
+
+// See ISO 32000-2 Table 268
+var (
+	geoLinearUnits  = []string{"M", "KM", "FT", "USFT", "MI", "NM"}
+	geoAreaUnits    = []string{"SQM", "HA", "SQKM", "SQFT", "A", "SQMI"}
+	geoAngularUnits = []string{"DEG", "GRD"}
+)
+
+// defaultLPTS are the corners of the unit square in the order of the default Bounds.
+var defaultLPTS = []float64{0, 0, 0, 1, 1, 1, 1, 0}
+
+func formatFloats(ff []float64) string {
+
+	ss := make([]string, len(ff))
+	for i, f := range ff {
+		ss[i] = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return strings.Join(ss, " ")
+}
+
+// String returns a one line description of vp.
+func (vp Viewport) String() string {
+
+	s := fmt.Sprintf("page %d", vp.Page)
+	if vp.Name != "" {
+		s += fmt.Sprintf(" %q", vp.Name)
+	}
+
+	ss := []string{s + ": bbox " + formatFloats(vp.BBox)}
+
+	switch vp.Subtype {
+
+	case "RL":
+		ss = append(ss, "RL "+vp.Ratio)
+
+	case "GEO":
+		ss = append(ss, "GEO")
+		if vp.GCS != nil {
+			ss = append(ss, "gcs "+vp.GCS.String())
+		}
+		if vp.DCS != nil {
+			ss = append(ss, "dcs "+vp.DCS.String())
+		}
+		if len(vp.PDU) > 0 {
+			ss = append(ss, "pdu "+strings.Join(vp.PDU, " "))
+		}
+		if len(vp.Bounds) > 0 {
+			ss = append(ss, "bounds "+formatFloats(vp.Bounds))
+		}
+		ss = append(ss, "gpts "+formatFloats(vp.GPTS))
+		if len(vp.LPTS) > 0 {
+			ss = append(ss, "lpts "+formatFloats(vp.LPTS))
+		}
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+func numberArrayEntry(xRefTable *XRefTable, d *PDFDict, key string) []float64 {
+
+	arr, err := xRefTable.DereferenceArray(d.Dict[key])
+	if err != nil || arr == nil {
+		return nil
+	}
+
+	ff := make([]float64, len(*arr))
+	for i, o := range *arr {
+		ff[i] = xRefTable.DereferenceNumber(o)
+	}
+
+	return ff
+}
+
+func coordSystem(xRefTable *XRefTable, o PDFObject) (*CoordSystem, error) {
+
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	cs := &CoordSystem{Type: nameEntry(xRefTable, d, "Type")}
+
+	if i, err := xRefTable.DereferenceInteger(d.Dict["EPSG"]); err == nil && i != nil {
+		cs.EPSG = i.Value()
+	}
+
+	if cs.WKT, err = fieldValueString(xRefTable, d.Dict["WKT"]); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+func viewport(xRefTable *XRefTable, d *PDFDict, page int) (*Viewport, error) {
+
+	vp := &Viewport{Page: page, BBox: numberArrayEntry(xRefTable, d, "BBox")}
+
+	var err error
+	if vp.Name, err = fieldValueString(xRefTable, d.Dict["Name"]); err != nil {
+		return nil, err
+	}
+
+	m, err := xRefTable.DereferenceDict(d.Dict["Measure"])
+	if err != nil || m == nil {
+		return vp, err
+	}
+
+	vp.Subtype = nameEntry(xRefTable, m, "Subtype")
+	if vp.Subtype == "" {
+		vp.Subtype = "RL"
+	}
+
+	if vp.Subtype == "RL" {
+		if vp.Ratio, err = fieldValueString(xRefTable, m.Dict["R"]); err != nil {
+			return nil, err
+		}
+		return vp, nil
+	}
+
+	if vp.GCS, err = coordSystem(xRefTable, m.Dict["GCS"]); err != nil {
+		return nil, err
+	}
+
+	if vp.DCS, err = coordSystem(xRefTable, m.Dict["DCS"]); err != nil {
+		return nil, err
+	}
+
+	if arr, err := xRefTable.DereferenceArray(m.Dict["PDU"]); err == nil && arr != nil {
+		for _, o := range *arr {
+			if n, ok := o.(PDFName); ok {
+				vp.PDU = append(vp.PDU, n.Value())
+			}
+		}
+	}
+
+	vp.Bounds = numberArrayEntry(xRefTable, m, "Bounds")
+	vp.GPTS = numberArrayEntry(xRefTable, m, "GPTS")
+	vp.LPTS = numberArrayEntry(xRefTable, m, "LPTS")
+
+	return vp, nil
+}
+
+// Viewports returns the viewports of all pages in page order.
+func Viewports(xRefTable *XRefTable) ([]Viewport, error) {
+
+	var vps []Viewport
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		arr, err := xRefTable.DereferenceArray(pageDict.Dict["VP"])
+		if err != nil || arr == nil {
+			continue
+		}
+
+		for _, o := range *arr {
+
+			d, err := xRefTable.DereferenceDict(o)
+			if err != nil || d == nil {
+				continue
+			}
+
+			vp, err := viewport(xRefTable, d, i)
+			if err != nil {
+				return nil, err
+			}
+
+			vps = append(vps, *vp)
+		}
+	}
+
+	return vps, nil
+}
+
+func (cs *CoordSystem) validate(key string) error {
+
+	if cs.Type != "GEOGCS" && cs.Type != "PROJCS" {
+		return errors.Errorf("pdfcpu: %s: type must be GEOGCS or PROJCS: %s", key, cs.Type)
+	}
+
+	if cs.EPSG <= 0 && cs.WKT == "" {
+		return errors.Errorf("pdfcpu: %s: missing EPSG code or WKT", key)
+	}
+
+	return nil
+}
+
+func validatePointArray(key string, ff []float64) error {
+
+	if len(ff) < 6 || len(ff)%2 != 0 {
+		return errors.Errorf("pdfcpu: %s: need at least 3 pairs of numbers", key)
+	}
+
+	return nil
+}
+
+// validate checks the geospatial viewport vp and fills in defaults.
+func (vp *Viewport) validate(pageCount int) error {
+
+	if vp.Page == 0 {
+		vp.Page = 1
+	}
+
+	if vp.Page < 0 || vp.Page > pageCount {
+		return errors.Errorf("pdfcpu: viewport: invalid page: %d", vp.Page)
+	}
+
+	if len(vp.BBox) != 4 {
+		return errors.New("pdfcpu: viewport: bbox must be 4 numbers: llx lly urx ury")
+	}
+
+	if vp.Subtype == "" {
+		vp.Subtype = "GEO"
+	}
+
+	if vp.Subtype != "GEO" {
+		return errors.Errorf("pdfcpu: viewport: unsupported measure subtype: %s", vp.Subtype)
+	}
+
+	if vp.GCS == nil {
+		return errors.New("pdfcpu: viewport: missing gcs")
+	}
+
+	if err := vp.GCS.validate("gcs"); err != nil {
+		return err
+	}
+
+	if vp.DCS != nil {
+		if err := vp.DCS.validate("dcs"); err != nil {
+			return err
+		}
+	}
+
+	if len(vp.PDU) > 0 {
+		if len(vp.PDU) != 3 || !memberOf(vp.PDU[0], geoLinearUnits) || !memberOf(vp.PDU[1], geoAreaUnits) || !memberOf(vp.PDU[2], geoAngularUnits) {
+			return errors.Errorf("pdfcpu: viewport: pdu must be linear (%s), area (%s) and angular (%s) units",
+				strings.Join(geoLinearUnits, ","), strings.Join(geoAreaUnits, ","), strings.Join(geoAngularUnits, ","))
+		}
+	}
+
+	if len(vp.Bounds) > 0 {
+		if err := validatePointArray("bounds", vp.Bounds); err != nil {
+			return err
+		}
+	}
+
+	if err := validatePointArray("gpts", vp.GPTS); err != nil {
+		return err
+	}
+
+	// Four points default to the corners of the unit square.
+	if len(vp.LPTS) == 0 && len(vp.GPTS) == len(defaultLPTS) {
+		vp.LPTS = defaultLPTS
+	}
+
+	if len(vp.LPTS) != len(vp.GPTS) {
+		return errors.New("pdfcpu: viewport: lpts and gpts must have the same length")
+	}
+
+	return nil
+}
+
+func coordSystemDict(cs *CoordSystem) PDFDict {
+
+	d := NewPDFDict()
+	d.InsertName("Type", cs.Type)
+
+	if cs.EPSG > 0 {
+		d.InsertInt("EPSG", cs.EPSG)
+	}
+
+	if cs.WKT != "" {
+		d.InsertString("WKT", cs.WKT)
+	}
+
+	return d
+}
+
+func (vp *Viewport) viewportDict() PDFDict {
+
+	m := NewPDFDict()
+	m.InsertName("Type", "Measure")
+	m.InsertName("Subtype", "GEO")
+
+	if len(vp.Bounds) > 0 {
+		m.Insert("Bounds", NewNumberArray(vp.Bounds...))
+	}
+
+	m.Insert("GCS", coordSystemDict(vp.GCS))
+
+	if vp.DCS != nil {
+		m.Insert("DCS", coordSystemDict(vp.DCS))
+	}
+
+	if len(vp.PDU) > 0 {
+		m.Insert("PDU", NewNameArray(vp.PDU...))
+	}
+
+	m.Insert("GPTS", NewNumberArray(vp.GPTS...))
+	m.Insert("LPTS", NewNumberArray(vp.LPTS...))
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Viewport")
+	d.Insert("BBox", NewRectangle(vp.BBox[0], vp.BBox[1], vp.BBox[2], vp.BBox[3]))
+
+	if vp.Name != "" {
+		d.Insert("Name", encodeText(vp.Name))
+	}
+
+	d.Insert("Measure", m)
+
+	return d
+}
+
+// AddViewports georeferences regions of pages by adding the geospatial viewports vps.
+func AddViewports(xRefTable *XRefTable, vps []Viewport) error {
+
+	for i := range vps {
+		if err := vps[i].validate(xRefTable.PageCount); err != nil {
+			return err
+		}
+	}
+
+	for _, vp := range vps {
+
+		pageDict, _, err := xRefTable.PageDict(vp.Page)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			return errors.Errorf("pdfcpu: page %d not found", vp.Page)
+		}
+
+		arr, err := xRefTable.DereferenceArray(pageDict.Dict["VP"])
+		if err != nil {
+			return err
+		}
+
+		if arr == nil {
+			arr = &PDFArray{}
+		}
+
+		pageDict.Update("VP", append(*arr, vp.viewportDict()))
+	}
+
+	// Geospatial measure dicts need V2.0, GeoPDFs using the Adobe extension go with V1.7.
+	if xRefTable.Version() < V17 {
+		log.Debug.Println("AddViewports: ensures V2.0")
+		v := V20
+		xRefTable.RootVersion = &v
+	}
+
+	return nil
+}
+
+// RemoveViewports removes the viewports of the selected pages.
+func RemoveViewports(xRefTable *XRefTable, pages IntSet) (int, error) {
+
+	var n int
+
+	for i, v := range pages {
+
+		if !v || i < 1 || i > xRefTable.PageCount {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict != nil && pageDict.Delete("VP") != nil {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// ParseViewportsJSON parses a JSON array of viewports.
+func ParseViewportsJSON(b []byte) ([]Viewport, error) {
+
+	var vps []Viewport
+
+	if err := json.Unmarshal(b, &vps); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: invalid viewports")
+	}
+
+	return vps, nil
+}
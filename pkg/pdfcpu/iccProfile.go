@@ -20,6 +20,8 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"strings"
+	"unicode/utf16"
 
 	"github.com/pkg/errors"
 )
@@ -308,3 +310,81 @@ func (p iccProfile) String() string {
 
 	return s
 }
+
+// newICCProfile returns the ICC profile b after checking its header and tag table.
+func newICCProfile(b []byte) (*iccProfile, error) {
+
+	p := &iccProfile{b: b}
+
+	if len(b) < 132 || p.fileSig() != "acsp" {
+		return nil, errors.New("icc profile: missing profile header")
+	}
+
+	if int(p.size()) > len(b) || 132+12*p.tagCount() > len(b) {
+		return nil, errors.New("icc profile: truncated")
+	}
+
+	for i, j := 0, 132; i < p.tagCount(); i, j = i+1, j+12 {
+		off := binary.BigEndian.Uint32(b[j+4:])
+		size := binary.BigEndian.Uint32(b[j+8:])
+		if uint64(off)+uint64(size) > uint64(len(b)) {
+			return nil, errors.Errorf("icc profile: corrupt tag %s", b[j:j+4])
+		}
+	}
+
+	return p, nil
+}
+
+// components returns the number of color components of the data color space, 0 if not supported by PDF.
+func (p iccProfile) components() int {
+
+	switch p.dataColorSpace() {
+	case "GRAY":
+		return 1
+	case "RGB ", "Lab ":
+		return 3
+	case "CMYK":
+		return 4
+	}
+
+	return 0
+}
+
+// description returns the profile description of a version 2 (textDescriptionType)
+// or version 4 profile (multiLocalizedUnicodeType, first record).
+func (p iccProfile) description() string {
+
+	off, size, err := p.tag("desc")
+	if err != nil || size < 12 {
+		return ""
+	}
+
+	b := p.b[off : off+size]
+
+	switch string(b[:4]) {
+
+	case "desc":
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		if n == 0 || 12+n > len(b) {
+			return ""
+		}
+		return strings.TrimRight(string(b[12:12+n]), "\x00")
+
+	case "mluc":
+		if size < 28 || binary.BigEndian.Uint32(b[8:]) == 0 {
+			return ""
+		}
+		n := int(binary.BigEndian.Uint32(b[20:]))
+		o := int(binary.BigEndian.Uint32(b[24:]))
+		if o+n > len(b) {
+			return ""
+		}
+		u := make([]uint16, n/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(b[o+2*i:])
+		}
+		return string(utf16.Decode(u))
+	}
+
+	return ""
+}
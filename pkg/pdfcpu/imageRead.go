@@ -20,7 +20,8 @@ import (
 	"image"
 	"image/color"
 	"image/png"
-	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/tiff"
@@ -256,7 +257,7 @@ func imgToImageDict(xRefTable *XRefTable, img image.Image) (*PDFStreamDict, erro
 // and appends this object to the cross reference table.
 func ReadPNGFile(xRefTable *XRefTable, fileName string) (*PDFStreamDict, error) {
 
-	f, err := os.Open(fileName)
+	f, err := xRefTable.openFile(fileName)
 	if err != nil {
 		return nil, err
 	}
@@ -270,11 +271,22 @@ func ReadPNGFile(xRefTable *XRefTable, fileName string) (*PDFStreamDict, error)
 	return imgToImageDict(xRefTable, img)
 }
 
+// ReadImageFile generates a PDF image object for a PNG or TIFF file
+// and appends this object to the cross reference table.
+func ReadImageFile(xRefTable *XRefTable, fileName string) (*PDFStreamDict, error) {
+
+	if strings.ToLower(filepath.Ext(fileName)) == ".png" {
+		return ReadPNGFile(xRefTable, fileName)
+	}
+
+	return ReadTIFFFile(xRefTable, fileName)
+}
+
 // ReadTIFFFile generates a PDF image object for a TIFF file
 // and appends this object to the cross reference table.
 func ReadTIFFFile(xRefTable *XRefTable, fileName string) (*PDFStreamDict, error) {
 
-	f, err := os.Open(fileName)
+	f, err := xRefTable.openFile(fileName)
 	if err != nil {
 		return nil, err
 	}
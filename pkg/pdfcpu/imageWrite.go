@@ -21,8 +21,6 @@ import (
 	"image"
 	"image/color"
 	"image/png"
-	"io/ioutil"
-	"os"
 
 	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/log"
@@ -51,6 +49,7 @@ type PDFImage struct {
 	w, h     int
 	softMask []byte
 	decode   []colValRange
+	create   CreateFunc // Creates image files, see Configuration.
 }
 
 func decodeArr(arr *PDFArray) []colValRange {
@@ -88,13 +87,30 @@ func decodeArr(arr *PDFArray) []colValRange {
 
 func pdfImage(xRefTable *XRefTable, sd *PDFStreamDict, objNr int) (*PDFImage, error) {
 
-	bpc := *sd.IntEntry("BitsPerComponent")
+	pbpc, pw, ph := sd.IntEntry("BitsPerComponent"), sd.IntEntry("Width"), sd.IntEntry("Height")
+	if pbpc == nil || pw == nil || ph == nil {
+		return nil, errors.Errorf("pdfImage: objNr=%d missing image dimensions", objNr)
+	}
+
+	bpc, w, h := *pbpc, *pw, *ph
 	if bpc == 16 {
 		return nil, ErrUnsupported16BPC
 	}
 
-	w := *sd.IntEntry("Width")
-	h := *sd.IntEntry("Height")
+	if !intMemberOf(bpc, []int{1, 2, 4, 8}) {
+		return nil, errors.Errorf("pdfImage: objNr=%d invalid bpc=%d", objNr, bpc)
+	}
+
+	if err := xRefTable.checkImageSize(w, h, objNr); err != nil {
+		return nil, err
+	}
+
+	// Each row starts at a byte boundary.
+	// Reject dimensions not covered by the image data before allocating any image buffer.
+	l := len(sd.Content)
+	if w <= 0 || h <= 0 || w > 8*l/bpc || h > l/((bpc*w+7)/8) {
+		return nil, errors.Errorf("pdfImage: objNr=%d corrupt image dimensions w=%d h=%d", objNr, w, h)
+	}
 
 	decode := decodeArr(sd.PDFArrayEntry("Decode"))
 	//fmt.Printf("decode: %v\n", decode)
@@ -112,6 +128,7 @@ func pdfImage(xRefTable *XRefTable, sd *PDFStreamDict, objNr int) (*PDFImage, er
 		h:        h,
 		softMask: sm,
 		decode:   decode,
+		create:   xRefTable.CreateFile,
 	}, nil
 }
 
@@ -135,7 +152,7 @@ func colorLookupTable(xRefTable *XRefTable, o PDFObject) ([]byte, error) {
 		}
 
 	case PDFStreamDict:
-		lookup, err = streamBytes(&o)
+		lookup, err = streamBytes(xRefTable, &o)
 		if err != nil || lookup == nil {
 			return nil, err
 		}
@@ -149,7 +166,7 @@ func decodePixelColorValue(p uint8, bpc, c int, decode []colValRange) uint8 {
 	// p ...the color value for this pixel
 	// c ...applicable index of a color component in the decode array for this pixel.
 
-	if decode == nil {
+	if c >= len(decode) {
 		return p
 	}
 
@@ -170,12 +187,12 @@ func decodePixelColorValue(p uint8, bpc, c int, decode []colValRange) uint8 {
 	return uint8(v * 255)
 }
 
-func streamBytes(sd *PDFStreamDict) ([]byte, error) {
+func streamBytes(xRefTable *XRefTable, sd *PDFStreamDict) ([]byte, error) {
 
 	fpl := sd.FilterPipeline
-	if fpl == nil {
+	if len(fpl) == 0 {
 		log.Info.Printf("streamBytes: no filter pipeline\n")
-		err := decodeStream(sd)
+		err := xRefTable.decodeStream(sd)
 		if err != nil {
 			return nil, err
 		}
@@ -191,7 +208,7 @@ func streamBytes(sd *PDFStreamDict) ([]byte, error) {
 	switch fpl[0].Name {
 
 	case filter.Flate:
-		err := decodeStream(sd)
+		err := xRefTable.decodeStream(sd)
 		if err != nil {
 			return nil, err
 		}
@@ -218,11 +235,11 @@ func softMask(xRefTable *XRefTable, d *PDFStreamDict, w, h, objNr int) ([]byte,
 	// Soft mask present.
 
 	sd, err := xRefTable.DereferenceStreamDict(o)
-	if err != nil {
+	if err != nil || sd == nil {
 		return nil, err
 	}
 
-	sm, err := streamBytes(sd)
+	sm, err := streamBytes(xRefTable, sd)
 	if err != nil {
 		return nil, err
 	}
@@ -250,39 +267,38 @@ func softMask(xRefTable *XRefTable, d *PDFStreamDict, w, h, objNr int) ([]byte,
 	return sm, nil
 }
 
-func writeImgToJPG(filename string, sd *PDFStreamDict) (string, error) {
+func writeImgToJPG(create CreateFunc, filename string, sd *PDFStreamDict) (string, error) {
 
 	filename += ".jpg"
 	//fmt.Printf("writing %s\n", filename)
 
 	// TODO WriteJPG(fileName, img)
 
-	return filename, ioutil.WriteFile(filename, sd.Raw, os.ModePerm)
+	return filename, writeFile(create, filename, sd.Raw)
 }
 
-func writeImgToJPX(filename string, sd *PDFStreamDict) (string, error) {
+func writeImgToJPX(create CreateFunc, filename string, sd *PDFStreamDict) (string, error) {
 
 	filename += ".jpx"
 	//fmt.Printf("writing %s\n", filename)
 
 	// TODO WriteJPX(fileName, img)
 
-	return filename, ioutil.WriteFile(filename, sd.Raw, os.ModePerm)
+	return filename, writeFile(create, filename, sd.Raw)
 }
 
-func writeImgToTIFF(filename string, img *image.CMYK) (string, error) {
+func writeImgToTIFF(create CreateFunc, filename string, img *image.CMYK) (string, error) {
 
 	filename += ".tif"
 	fmt.Printf("writing %s\n", filename)
 
-	f, err := os.Create(filename)
+	f, err := createFile(create, filename)
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
 
 	// TODO softmask handling.
-	err = tiff.Encode(f, img, nil)
+	err = closeFile(f, tiff.Encode(f, img, nil))
 
 	fmt.Println("tif written")
 
@@ -295,6 +311,10 @@ func writeDeviceCMYKToTIFF(filename string, im *PDFImage) (string, error) {
 
 	log.Debug.Printf("writeDeviceCMYKToTIFF: CMYK objNr=%d w=%d h=%d bpc=%d buflen=%d\n", im.objNr, im.w, im.h, im.bpc, len(b))
 
+	if len(b) < 4*im.w*im.h {
+		return "", errors.Errorf("writeDeviceCMYKToTIFF: objNr=%d corrupt image object\n", im.objNr)
+	}
+
 	img := image.NewCMYK(image.Rect(0, 0, im.w, im.h))
 
 	i := 0
@@ -308,22 +328,21 @@ func writeDeviceCMYKToTIFF(filename string, im *PDFImage) (string, error) {
 		}
 	}
 
-	return writeImgToTIFF(filename, img)
+	return writeImgToTIFF(im.create, filename, img)
 }
 
-func writeImgToPNG(filename string, img image.Image) (string, error) {
+func writeImgToPNG(create CreateFunc, filename string, img image.Image) (string, error) {
 
 	filename += ".png"
 
-	f, err := os.Create(filename)
+	f, err := createFile(create, filename)
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
 
 	//fmt.Println("png written")
 
-	return filename, png.Encode(f, img)
+	return filename, closeFile(f, png.Encode(f, img))
 }
 
 func writeDeviceGrayToPNG(filename string, im *PDFImage) (string, error) {
@@ -345,7 +364,7 @@ func writeDeviceGrayToPNG(filename string, im *PDFImage) (string, error) {
 	for y := 0; y < im.h; y++ {
 		for x := 0; x < im.w; {
 			p := b[i]
-			for j := 0; j < 8/im.bpc; j++ {
+			for j := 0; j < 8/im.bpc && x < im.w; j++ {
 				pix := p >> (8 - uint8(im.bpc))
 				v := decodePixelColorValue(pix, im.bpc, 0, im.decode)
 				//fmt.Printf("x=%d y=%d pix=#%02x v=#%02x\n", x, y, pix, v)
@@ -357,7 +376,7 @@ func writeDeviceGrayToPNG(filename string, im *PDFImage) (string, error) {
 		}
 	}
 
-	return writeImgToPNG(filename, img)
+	return writeImgToPNG(im.create, filename, img)
 }
 
 func writeDeviceRGBToPNG(filename string, im *PDFImage) (string, error) {
@@ -368,7 +387,7 @@ func writeDeviceRGBToPNG(filename string, im *PDFImage) (string, error) {
 
 	// Validate buflen.
 	// Sometimes there is a trailing 0x0A in addition to the imagebytes.
-	if len(b) < (3*im.bpc*im.w*im.h+7)/8 {
+	if len(b) < 3*im.w*im.h {
 		return "", errors.Errorf("writeDeviceRGBToPNG: objNr=%d corrupt image object\n", im.objNr)
 	}
 
@@ -387,7 +406,7 @@ func writeDeviceRGBToPNG(filename string, im *PDFImage) (string, error) {
 		}
 	}
 
-	return writeImgToPNG(filename, img)
+	return writeImgToPNG(im.create, filename, img)
 }
 
 func ensureDeviceRGBCS(xRefTable *XRefTable, o PDFObject) bool {
@@ -411,7 +430,7 @@ func writeCalRGBToPNG(filename string, im *PDFImage) (string, error) {
 
 	log.Debug.Printf("writeCalRGBToPNG: objNr=%d w=%d h=%d bpc=%d buflen=%d\n", im.objNr, im.w, im.h, im.bpc, len(b))
 
-	if len(b) < (3*im.bpc*im.w*im.h+7)/8 {
+	if len(b) < 3*im.w*im.h {
 		return "", errors.Errorf("writeCalRGBToPNG: objNr=%d corrupt image object %v\n", im.objNr, *im.sd)
 	}
 
@@ -428,7 +447,7 @@ func writeCalRGBToPNG(filename string, im *PDFImage) (string, error) {
 			i += 3
 		}
 	}
-	return writeImgToPNG(filename, img)
+	return writeImgToPNG(im.create, filename, img)
 }
 
 func writeICCBased(xRefTable *XRefTable, filename string, im *PDFImage, cs PDFArray) (string, error) {
@@ -436,14 +455,16 @@ func writeICCBased(xRefTable *XRefTable, filename string, im *PDFImage, cs PDFAr
 	//  Any ICC profile >= ICC.1:2004:10 is sufficient for any PDF version <= 1.7
 	//  If the embedded ICC profile version is newer than the one used by the Reader, substitute with Alternate color space.
 
-	iccProfileStream, _ := xRefTable.DereferenceStreamDict(cs[1])
+	n, err := iccProfileComponents(xRefTable, cs)
+	if err != nil {
+		return "", errors.Wrapf(err, "writeICCBasedToPNGFile: objNr=%d", im.objNr)
+	}
 
 	b := im.sd.Content
 
 	log.Debug.Printf("writeICCBasedToPNGFile: objNr=%d w=%d h=%d bpc=%d buflen=%d\n", im.objNr, im.w, im.h, im.bpc, len(b))
 
 	// 1,3 or 4 color components.
-	n := *iccProfileStream.IntEntry("N")
 
 	if !intMemberOf(n, []int{1, 3, 4}) {
 		return "", errors.Errorf("writeICCBasedToPNGFile: objNr=%d, N must be 1,3 or 4, got:%d\n", im.objNr, n)
@@ -476,7 +497,38 @@ func writeICCBased(xRefTable *XRefTable, filename string, im *PDFImage, cs PDFAr
 	return "", nil
 }
 
-func writeIndexedRGBToPNG(filename string, im *PDFImage, lookup []byte) (string, error) {
+// iccProfileComponents returns the number of color components of an ICCBased color space.
+func iccProfileComponents(xRefTable *XRefTable, cs PDFArray) (int, error) {
+
+	if len(cs) < 2 {
+		return 0, errors.New("corrupt ICCBased color space")
+	}
+
+	iccProfileStream, err := xRefTable.DereferenceStreamDict(cs[1])
+	if err != nil {
+		return 0, err
+	}
+
+	if iccProfileStream == nil || iccProfileStream.IntEntry("N") == nil {
+		return 0, errors.New("corrupt ICC profile stream")
+	}
+
+	return *iccProfileStream.IntEntry("N"), nil
+}
+
+// lookupIndex returns the position of the color for the index value ind in a lookup table with n color components.
+// Out of range index values are adjusted to the nearest valid value.
+func lookupIndex(ind uint8, maxInd, n int) int {
+
+	i := int(ind)
+	if i > maxInd {
+		i = maxInd
+	}
+
+	return n * i
+}
+
+func writeIndexedRGBToPNG(filename string, im *PDFImage, maxInd int, lookup []byte) (string, error) {
 
 	b := im.sd.Content
 
@@ -488,14 +540,14 @@ func writeIndexedRGBToPNG(filename string, im *PDFImage, lookup []byte) (string,
 	for y := 0; y < im.h; y++ {
 		for x := 0; x < im.w; {
 			p := b[i]
-			for j := 0; j < 8/im.bpc; j++ {
+			for j := 0; j < 8/im.bpc && x < im.w; j++ {
 				ind := p >> (8 - uint8(im.bpc))
 				//fmt.Printf("x=%d y=%d i=%d j=%d p=#%02x ind=#%02x\n", x, y, i, j, p, ind)
 				alpha := uint8(255)
 				if im.softMask != nil {
 					alpha = im.softMask[y*im.w+x]
 				}
-				l := 3 * int(ind)
+				l := lookupIndex(ind, maxInd, 3)
 				img.Set(x, y, color.NRGBA{R: lookup[l], G: lookup[l+1], B: lookup[l+2], A: alpha})
 				p <<= uint8(im.bpc)
 				x++
@@ -504,10 +556,10 @@ func writeIndexedRGBToPNG(filename string, im *PDFImage, lookup []byte) (string,
 		}
 	}
 
-	return writeImgToPNG(filename, img)
+	return writeImgToPNG(im.create, filename, img)
 }
 
-func writeIndexedCMYKToTIFF(filename string, im *PDFImage, lookup []byte) (string, error) {
+func writeIndexedCMYKToTIFF(filename string, im *PDFImage, maxInd int, lookup []byte) (string, error) {
 
 	b := im.sd.Content
 
@@ -519,10 +571,10 @@ func writeIndexedCMYKToTIFF(filename string, im *PDFImage, lookup []byte) (strin
 	for y := 0; y < im.h; y++ {
 		for x := 0; x < im.w; {
 			p := b[i]
-			for j := 0; j < 8/im.bpc; j++ {
+			for j := 0; j < 8/im.bpc && x < im.w; j++ {
 				ind := p >> (8 - uint8(im.bpc))
 				//fmt.Printf("x=%d y=%d i=%d j=%d p=#%02x ind=#%02x\n", x, y, i, j, p, ind)
-				l := 4 * int(ind)
+				l := lookupIndex(ind, maxInd, 4)
 				img.Set(x, y, color.CMYK{C: lookup[l], M: lookup[l+1], Y: lookup[l+2], K: lookup[l+3]})
 				p <<= uint8(im.bpc)
 				x++
@@ -531,7 +583,7 @@ func writeIndexedCMYKToTIFF(filename string, im *PDFImage, lookup []byte) (strin
 		}
 	}
 
-	return writeImgToTIFF(filename, img)
+	return writeImgToTIFF(im.create, filename, img)
 }
 
 func writeIndexedNameCS(filename string, im *PDFImage, cs PDFName, maxInd int, lookup []byte) (string, error) {
@@ -544,7 +596,7 @@ func writeIndexedNameCS(filename string, im *PDFImage, cs PDFName, maxInd int, l
 			return "", errors.Errorf("writeIndexedNameCS: objNr=%d, corrupt DeviceRGB lookup table\n", im.objNr)
 		}
 
-		return writeIndexedRGBToPNG(filename, im, lookup)
+		return writeIndexedRGBToPNG(filename, im, maxInd, lookup)
 
 	case DeviceCMYKCS:
 
@@ -552,7 +604,7 @@ func writeIndexedNameCS(filename string, im *PDFImage, cs PDFName, maxInd int, l
 			return "", errors.Errorf("writeIndexedNameCS: objNr=%d, corrupt DeviceCMYK lookup table\n", im.objNr)
 		}
 
-		return writeIndexedCMYKToTIFF(filename, im, lookup)
+		return writeIndexedCMYKToTIFF(filename, im, maxInd, lookup)
 	}
 
 	log.Info.Printf("writeIndexedNameCS: objNr=%d, unsupported base colorspace %s\n", im.objNr, cs.String())
@@ -564,16 +616,21 @@ func writeIndexedArrayCS(xRefTable *XRefTable, filename string, im *PDFImage, cs
 
 	b := im.sd.Content
 
-	cs, _ := csa[0].(PDFName)
+	var cs PDFName
+	if len(csa) > 0 {
+		cs, _ = csa[0].(PDFName)
+	}
 
 	switch cs {
 
 	case ICCBasedCS:
 
-		iccProfileStream, _ := xRefTable.DereferenceStreamDict(csa[1])
-
 		// 1,3 or 4 color components.
-		n := *iccProfileStream.IntEntry("N")
+		n, err := iccProfileComponents(xRefTable, csa)
+		if err != nil {
+			return "", errors.Wrapf(err, "writeIndexedArrayCS: objNr=%d", im.objNr)
+		}
+
 		if !intMemberOf(n, []int{1, 3, 4}) {
 			return "", errors.Errorf("writeIndexedArrayCS: objNr=%d, N must be 1,3 or 4, got:%d\n", im.objNr, n)
 		}
@@ -592,6 +649,9 @@ func writeIndexedArrayCS(xRefTable *XRefTable, filename string, im *PDFImage, cs
 			// Gray
 			// TODO use lookupTable!
 			// TODO handle bpc, decode and softmask.
+			if len(b) < im.w*im.h {
+				return "", errors.Errorf("writeIndexedArrayCS: objNr=%d corrupt image object\n", im.objNr)
+			}
 			img := image.NewGray(image.Rect(0, 0, im.w, im.h))
 			i := 0
 			for y := 0; y < im.h; y++ {
@@ -600,16 +660,16 @@ func writeIndexedArrayCS(xRefTable *XRefTable, filename string, im *PDFImage, cs
 					i++
 				}
 			}
-			return writeImgToPNG(filename, img)
+			return writeImgToPNG(im.create, filename, img)
 
 		case 3:
 			// RGB
-			return writeIndexedRGBToPNG(filename, im, lookup)
+			return writeIndexedRGBToPNG(filename, im, maxInd, lookup)
 
 		case 4:
 			// CMYK
 			log.Debug.Printf("writeIndexedArrayCS: CMYK objNr=%d w=%d h=%d bpc=%d buflen=%d\n", im.objNr, im.w, im.h, im.bpc, len(b))
-			return writeIndexedCMYKToTIFF(filename, im, lookup)
+			return writeIndexedCMYKToTIFF(filename, im, maxInd, lookup)
 		}
 	}
 
@@ -620,11 +680,18 @@ func writeIndexedArrayCS(xRefTable *XRefTable, filename string, im *PDFImage, cs
 
 func writeIndexed(xRefTable *XRefTable, filename string, im *PDFImage, cs PDFArray) (string, error) {
 
+	if len(cs) < 4 {
+		return "", errors.Errorf("writeIndexed: objNr=%d corrupt IndexedCS %s\n", im.objNr, cs)
+	}
+
 	// Identify the base color space.
 	baseCS, _ := xRefTable.Dereference(cs[1])
 
 	// Identify the max index into the color lookup table.
 	maxInd, _ := xRefTable.DereferenceInteger(cs[2])
+	if maxInd == nil || maxInd.Value() < 0 || maxInd.Value() > 255 {
+		return "", errors.Errorf("writeIndexed: objNr=%d IndexedCS with corrupt hival %s\n", im.objNr, cs)
+	}
 
 	// Identify the color lookup table.
 	var lookup []byte
@@ -693,7 +760,10 @@ func writeFlateEncodedImage(xRefTable *XRefTable, filename string, sd *PDFStream
 		}
 
 	case PDFArray:
-		csn, _ := cs[0].(PDFName)
+		var csn PDFName
+		if len(cs) > 0 {
+			csn, _ = cs[0].(PDFName)
+		}
 
 		switch csn {
 
@@ -717,12 +787,23 @@ func writeFlateEncodedImage(xRefTable *XRefTable, filename string, sd *PDFStream
 	return fn, err
 }
 
-// WriteImage writes a PDF image object to disk.
+// WriteImage writes a PDF image object to a file created by xRefTable.CreateFile.
 func WriteImage(xRefTable *XRefTable, filename string, sd *PDFStreamDict, objNr int) (string, error) {
 
-	switch sd.FilterPipeline[0].Name {
+	if len(sd.FilterPipeline) == 0 {
+		return "", nil
+	}
 
-	case filter.Flate:
+	switch name := sd.FilterPipeline[0].Name; {
+
+	case name == filter.DCT:
+		return writeImgToJPG(xRefTable.CreateFile, filename, sd)
+
+	case name == filter.JPX:
+		return writeImgToJPX(xRefTable.CreateFile, filename, sd)
+
+	case name == filter.Flate, filter.Registered(name):
+		// Registered filters decode into image samples like Flate.
 		// If color space is CMYK then write .tif else write .png
 		fn, err := writeFlateEncodedImage(xRefTable, filename, sd, objNr)
 		if err != nil {
@@ -737,12 +818,6 @@ func WriteImage(xRefTable *XRefTable, filename string, sd *PDFStreamDict, objNr
 		}
 		return fn, err
 
-	case filter.DCT:
-		return writeImgToJPG(filename, sd)
-
-	case filter.JPX:
-		return writeImgToJPX(filename, sd)
-
 	}
 
 	return "", nil
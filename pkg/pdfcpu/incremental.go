@@ -0,0 +1,330 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with incremental updates, see 7.5.6.
+// An incremental update appends modified and new objects followed by a cross reference section
+// to the unchanged original file. This is a must for adding signatures since earlier signatures
+// cover the bytes of the original file.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// ObjectSnapshot records the state of all objects of a cross reference table.
+type ObjectSnapshot map[int][sha1.Size]byte
+
+func objectFingerprint(o PDFObject) [sha1.Size]byte {
+
+	h := sha1.New()
+
+	switch o := o.(type) {
+	case nil:
+		h.Write([]byte("null"))
+	case PDFStreamDict:
+		h.Write([]byte(o.PDFDict.PDFString()))
+		h.Write(o.Raw)
+	default:
+		h.Write([]byte(o.PDFString()))
+	}
+
+	var fp [sha1.Size]byte
+	copy(fp[:], h.Sum(nil))
+
+	return fp
+}
+
+// Snapshot returns a snapshot of all objects in use for detecting modifications.
+func (xRefTable *XRefTable) Snapshot() ObjectSnapshot {
+
+	snap := ObjectSnapshot{}
+
+	for objNr, entry := range xRefTable.Table {
+		if entry.Free {
+			continue
+		}
+		// Fingerprints cover stream content, so load lazily read streams.
+		if err := xRefTable.ensureStreamLoaded(entry, objNr); err != nil {
+			log.Info.Printf("Snapshot: %v\n", err)
+		}
+		snap[objNr] = objectFingerprint(entry.Object)
+	}
+
+	return snap
+}
+
+// ModifiedObjects returns the sorted numbers of all objects modified or added since snap was taken.
+func (xRefTable *XRefTable) ModifiedObjects(snap ObjectSnapshot) []int {
+
+	var objNrs []int
+
+	for objNr, entry := range xRefTable.Table {
+		if entry.Free {
+			continue
+		}
+		fp, ok := snap[objNr]
+		if !ok || fp != objectFingerprint(entry.Object) {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+
+	sort.Ints(objNrs)
+
+	return objNrs
+}
+
+func writeIncrementObject(ctx *PDFContext, objNr int) error {
+
+	entry, found := ctx.FindTableEntryLight(objNr)
+	if !found || entry.Free {
+		return errors.Errorf("writeIncrementObject: missing object #%d", objNr)
+	}
+
+	if err := ctx.ensureStreamLoaded(entry, objNr); err != nil {
+		return err
+	}
+
+	genNr := *entry.Generation
+
+	switch o := entry.Object.(type) {
+
+	case nil:
+		return writeNullObject(ctx, objNr, genNr)
+
+	case PDFDict:
+		return writePDFDictObject(ctx, objNr, genNr, o)
+
+	case PDFStreamDict:
+		if o.Raw == nil {
+			return errors.Errorf("writeIncrementObject: stream object #%d not encoded", objNr)
+		}
+		l := int64(len(o.Raw))
+		o.StreamLength = &l
+		o.Update("Length", PDFInteger(l))
+		return writePDFStreamDictObject(ctx, objNr, genNr, o)
+
+	case PDFArray:
+		return writePDFArrayObject(ctx, objNr, genNr, o)
+
+	case PDFInteger:
+		return writePDFIntegerObject(ctx, objNr, genNr, o)
+
+	case PDFFloat:
+		return writePDFFloatObject(ctx, objNr, genNr, o)
+
+	case PDFStringLiteral:
+		return writePDFStringLiteralObject(ctx, objNr, genNr, o)
+
+	case PDFHexLiteral:
+		return writePDFHexLiteralObject(ctx, objNr, genNr, o)
+
+	case PDFBoolean:
+		return writePDFBooleanObject(ctx, objNr, genNr, o)
+
+	case PDFName:
+		return writePDFNameObject(ctx, objNr, genNr, o)
+	}
+
+	return errors.Errorf("writeIncrementObject: undefined PDF object #%d %T", objNr, entry.Object)
+}
+
+// xRefSubsections groups sorted object numbers into runs of consecutive numbers.
+func xRefSubsections(objNrs []int) [][]int {
+
+	var ss [][]int
+
+	for i, objNr := range objNrs {
+		if i == 0 || objNr != objNrs[i-1]+1 {
+			ss = append(ss, nil)
+		}
+		ss[len(ss)-1] = append(ss[len(ss)-1], objNr)
+	}
+
+	return ss
+}
+
+func incrementTrailerDict(ctx *PDFContext, prev int64) PDFDict {
+
+	d := NewPDFDict()
+	d.Insert("Size", PDFInteger(*ctx.Size))
+	d.Insert("Root", *ctx.Root)
+	d.Insert("Prev", PDFInteger(prev))
+
+	if ctx.Info != nil {
+		d.Insert("Info", *ctx.Info)
+	}
+
+	if ctx.ID != nil {
+		d.Insert("ID", *ctx.ID)
+	}
+
+	return d
+}
+
+func writeIncrementXRefTable(ctx *PDFContext, objNrs []int, prev int64) error {
+
+	w := ctx.Write
+
+	if _, err := w.WriteString("xref" + w.Eol); err != nil {
+		return err
+	}
+
+	for _, ss := range xRefSubsections(objNrs) {
+		if _, err := w.WriteString(fmt.Sprintf("%d %d%s", ss[0], len(ss), w.Eol)); err != nil {
+			return err
+		}
+		for _, objNr := range ss {
+			entry, _ := ctx.FindTableEntryLight(objNr)
+			s := fmt.Sprintf("%010d %05d n%2s", w.Table[objNr], *entry.Generation, w.Eol)
+			if _, err := w.WriteString(s); err != nil {
+				return err
+			}
+		}
+	}
+
+	d := incrementTrailerDict(ctx, prev)
+
+	_, err := w.WriteString("trailer" + w.Eol + d.PDFString() + w.Eol)
+
+	return err
+}
+
+func writeIncrementXRefStream(ctx *PDFContext, objNrs []int, prev int64) error {
+
+	// The xref stream covers itself.
+	objNr := *ctx.Size
+	*ctx.Size++
+	objNrs = append(objNrs, objNr)
+
+	w := ctx.Write
+	w.SetWriteOffset(objNr)
+
+	// Byte count of the offset field.
+	n := 4
+	if w.Offset >= 1<<32 {
+		n = 8
+	}
+
+	index := PDFArray{}
+	var buf bytes.Buffer
+
+	for _, ss := range xRefSubsections(objNrs) {
+		index = append(index, PDFInteger(ss[0]), PDFInteger(len(ss)))
+		for _, objNr := range ss {
+			buf.WriteByte(1)
+			buf.Write(int64ToBuf(w.Table[objNr], n))
+			gen := 0
+			if entry, found := ctx.FindTableEntryLight(objNr); found && entry.Generation != nil {
+				gen = *entry.Generation
+			}
+			buf.Write(int64ToBuf(int64(gen), 2))
+		}
+	}
+
+	d := incrementTrailerDict(ctx, prev)
+	d.Insert("Type", PDFName("XRef"))
+	d.Insert("W", NewIntegerArray(1, n, 2))
+	d.Insert("Index", index)
+	d.Insert("Length", PDFInteger(buf.Len()))
+
+	s := fmt.Sprintf("%d 0 obj%s%sstream%s", objNr, w.Eol, d.PDFString(), w.Eol)
+	if _, err := w.WriteString(s); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := w.WriteString(w.Eol + "endstream" + w.Eol + "endobj" + w.Eol)
+
+	return err
+}
+
+// WriteIncrement appends an incremental update holding the objects objNrs of ctx to orig, the original file,
+// and returns the resulting file.
+// The cross reference information is written in the style of the original file.
+func WriteIncrement(ctx *PDFContext, orig []byte, objNrs []int) ([]byte, error) {
+
+	log.Debug.Printf("WriteIncrement begin: %v\n", objNrs)
+
+	if ctx.Encrypt != nil {
+		return nil, newErrorf(ErrEncrypted, "incremental updates of encrypted files are not supported")
+	}
+
+	prev, err := offsetLastXRefSection(bytes.NewReader(orig), int64(len(orig)))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(orig)
+
+	if !bytes.HasSuffix(orig, []byte("\n")) && !bytes.HasSuffix(orig, []byte("\r")) {
+		buf.WriteString(ctx.Write.Eol)
+	}
+
+	w := NewWriteContext(ctx.Write.Eol)
+	w.Writer = bufio.NewWriter(&buf)
+	w.Offset = int64(buf.Len())
+
+	save := ctx.Write
+	ctx.Write = w
+	defer func() { ctx.Write = save }()
+
+	for _, objNr := range objNrs {
+		if err = writeIncrementObject(ctx, objNr); err != nil {
+			return nil, err
+		}
+	}
+
+	xrefOffset := w.Offset
+
+	// Offsets beyond 10 digits require an xref stream.
+	if ctx.Read != nil && ctx.Read.UsingXRefStreams || xrefOffset > maxXRefTableOffset {
+		err = writeIncrementXRefStream(ctx, objNrs, *prev)
+	} else {
+		err = writeIncrementXRefTable(ctx, objNrs, *prev)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.WriteString(fmt.Sprintf("startxref%s%d%s", w.Eol, xrefOffset, w.Eol)); err != nil {
+		return nil, err
+	}
+
+	if _, err = writeTrailer(w); err != nil {
+		return nil, err
+	}
+
+	if err = w.Flush(); err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("WriteIncrement end")
+
+	return buf.Bytes(), nil
+}
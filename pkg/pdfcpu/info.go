@@ -0,0 +1,239 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// infoDict returns the document info dict, creating an empty one if it is missing and create is true.
+func infoDict(ctx *PDFContext, create bool) (*PDFDict, error) {
+
+	if ctx.Info != nil {
+		d, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil || d != nil || !create {
+			return d, err
+		}
+	}
+
+	if !create {
+		return nil, nil
+	}
+
+	indRef, err := ctx.IndRefForNewObject(NewPDFDict())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Info = indRef
+
+	return ctx.DereferenceDict(*indRef)
+}
+
+// updateInfoText sets the text string entry key of the document info dict d, an empty s removes the entry.
+func updateInfoText(d *PDFDict, key, s string) {
+
+	if s == "" {
+		d.Delete(key)
+		return
+	}
+
+	d.Update(key, encodeText(s))
+}
+
+// validInfoKey returns true if key is usable as name without escaping.
+func validInfoKey(key string) bool {
+
+	if key == "" {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c <= ' ' || c > '~' || strings.IndexByte("()<>[]{}/%#", c) >= 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// infoEntryString returns a readable representation of the document info dict entry o.
+func infoEntryString(ctx *PDFContext, d *PDFDict, key string, o PDFObject) string {
+
+	if s := infoValue(ctx, d, key); s != "" {
+		return s
+	}
+
+	o, err := ctx.Dereference(o)
+	if err != nil || o == nil {
+		return ""
+	}
+
+	if s, err := textString(ctx, o); err == nil {
+		return s
+	}
+
+	return o.PDFString()
+}
+
+// ListInfo returns the entries of the document info dict sorted by key.
+// Dates are represented like in XMP metadata.
+func ListInfo(ctx *PDFContext) ([]string, error) {
+
+	d, err := infoDict(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil || d.Len() == 0 {
+		return []string{"no document info"}, nil
+	}
+
+	var keys []string
+	for k := range d.Dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ss []string
+	for _, k := range keys {
+		ss = append(ss, fmt.Sprintf("%s = %s", k, infoEntryString(ctx, d, k, d.Dict[k])))
+	}
+
+	return ss, nil
+}
+
+func checkInfoKey(key string) error {
+
+	if !validInfoKey(key) {
+		return errors.Errorf("pdfcpu: invalid document info key: %q", key)
+	}
+
+	switch key {
+	case "Producer":
+		return errors.New("pdfcpu: Producer gets set on write, use Configuration.ProducerOverride")
+	case "ModDate":
+		return errors.New("pdfcpu: ModDate gets set on write")
+	}
+
+	return nil
+}
+
+// SetInfo sets the document info dict entries props creating the document info dict if necessary.
+// Dates are accepted as PDF dates (D:YYYYMMDDHHmmSS) or like in XMP metadata (YYYY-MM-DDThh:mm:ssTZD),
+// Trapped takes True, False or Unknown. An empty value removes an entry.
+// Entries corresponding to XMP properties are removed from the XMP metadata too,
+// all others get synced on write.
+func SetInfo(ctx *PDFContext, props map[string]string) error {
+
+	var remove []string
+	set := map[string]string{}
+
+	for k, v := range props {
+
+		if err := checkInfoKey(k); err != nil {
+			return err
+		}
+
+		if v == "" {
+			remove = append(remove, k)
+			continue
+		}
+
+		switch k {
+
+		case "CreationDate":
+			t, ok := parseXMPDate(v)
+			if strings.HasPrefix(v, "D:") {
+				t, ok = parseDate(v)
+			}
+			if !ok {
+				return errors.Errorf("pdfcpu: invalid date for %s: %s", k, v)
+			}
+			v = xmpDate(t)
+
+		case "Trapped":
+			if v != "True" && v != "False" && v != "Unknown" {
+				return errors.New("pdfcpu: Trapped must be one of: True, False, Unknown")
+			}
+		}
+
+		set[k] = v
+	}
+
+	if len(set) > 0 {
+
+		d, err := infoDict(ctx, true)
+		if err != nil {
+			return err
+		}
+
+		for k, v := range set {
+			setInfoValue(d, k, v)
+		}
+	}
+
+	return RemoveInfo(ctx, remove)
+}
+
+// RemoveInfo removes the document info dict entries keys along with the corresponding XMP metadata properties.
+func RemoveInfo(ctx *PDFContext, keys []string) error {
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	for _, k := range keys {
+		if err := checkInfoKey(k); err != nil {
+			return err
+		}
+	}
+
+	d, err := infoDict(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	if d != nil {
+		for _, k := range keys {
+			d.Delete(k)
+		}
+	}
+
+	x, err := ReadXMP(ctx.XRefTable)
+	if err != nil || x == nil {
+		return err
+	}
+
+	for _, k := range keys {
+		for _, e := range xmpInfo {
+			if e.key != k {
+				continue
+			}
+			if err = x.RemoveProperty(e.prop); err != nil {
+				return err
+			}
+		}
+	}
+
+	return WriteXMP(ctx.XRefTable, x)
+}
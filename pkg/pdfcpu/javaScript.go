@@ -0,0 +1,473 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// JavaScript represents a JavaScript action or a document level script.
+type JavaScript struct {
+	Location string `json:"location"`        // document, page, annotation, field or outline
+	Trigger  string `json:"trigger"`         // OpenAction, A, an additional actions key or the name of a document level script
+	Page     int    `json:"page,omitempty"`  // page number for page, annotation and widget actions
+	Field    string `json:"field,omitempty"` // fully qualified field name
+	ObjNr    int    `json:"objNr,omitempty"` // object number of the action dict, 0 for direct objects
+	Script   string `json:"script"`
+}
+
+// jsWalker collects JavaScript actions or actions of other types and optionally strips them.
+type jsWalker struct {
+	xRefTable *XRefTable
+	types     StringSet // action types processed
+	remove    bool
+	actions   map[int]PDFObject // indirect actions processed along with their replacement
+	dicts     IntSet            // annotation and field dicts processed
+	scripts   []JavaScript
+	counts    map[string]int // actions processed by type
+}
+
+// action collects the JavaScript actions of the action or action array obj including their Next actions.
+// It returns the replacement for obj with JavaScript actions removed, nil if nothing remains.
+func (w *jsWalker) action(obj PDFObject, js JavaScript) (PDFObject, error) {
+
+	js.ObjNr = 0
+
+	indRef, ok := obj.(PDFIndirectRef)
+	if ok {
+		objNr := indRef.ObjectNumber.Value()
+		if r, found := w.actions[objNr]; found {
+			return r, nil
+		}
+		// Guard against cycles.
+		w.actions[objNr] = obj
+		js.ObjNr = objNr
+	}
+
+	r, err := w.resolveAction(obj, js)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		w.actions[indRef.ObjectNumber.Value()] = r
+	}
+
+	return r, nil
+}
+
+func (w *jsWalker) resolveAction(obj PDFObject, js JavaScript) (PDFObject, error) {
+
+	o, err := w.xRefTable.Dereference(obj)
+	if err != nil || o == nil {
+		return nil, err
+	}
+
+	switch o := o.(type) {
+
+	case PDFArray:
+		var arr PDFArray
+		for _, v := range o {
+			r, err := w.action(v, js)
+			if err != nil {
+				return nil, err
+			}
+			if r != nil {
+				arr = append(arr, r)
+			}
+		}
+		if len(arr) == 0 {
+			return nil, nil
+		}
+		if len(arr) == len(o) {
+			return obj, nil
+		}
+		return arr, nil
+
+	case PDFDict:
+		if err = w.actionEntry(&o, "Next", js, false); err != nil {
+			return nil, err
+		}
+
+		s := o.NameEntry("S")
+		if s == nil || !w.types[*s] {
+			return obj, nil
+		}
+
+		w.counts[*s]++
+
+		if *s == "JavaScript" {
+			if o, found := o.Find("JS"); found {
+				if js.Script, err = fieldValueString(w.xRefTable, o); err != nil {
+					return nil, err
+				}
+			}
+			w.scripts = append(w.scripts, js)
+		}
+
+		if !w.remove {
+			return obj, nil
+		}
+
+		// Replace the action by its remaining Next actions.
+		next, _ := o.Find("Next")
+		return next, nil
+	}
+
+	return obj, nil
+}
+
+// actionEntry processes the action or action array d[key].
+func (w *jsWalker) actionEntry(d *PDFDict, key string, js JavaScript, setTrigger bool) error {
+
+	obj, found := d.Find(key)
+	if !found || obj == nil {
+		return nil
+	}
+
+	if setTrigger {
+		js.Trigger = key
+	}
+
+	r, err := w.action(obj, js)
+	if err != nil || !w.remove {
+		return err
+	}
+
+	if r == nil {
+		d.Delete(key)
+		return nil
+	}
+
+	d.Update(key, r)
+
+	return nil
+}
+
+// additionalActions processes the additional-actions dict of d, see 12.6.3.
+func (w *jsWalker) additionalActions(d *PDFDict, js JavaScript) error {
+
+	obj, found := d.Find("AA")
+	if !found || obj == nil {
+		return nil
+	}
+
+	aa, err := w.xRefTable.DereferenceDict(obj)
+	if err != nil || aa == nil {
+		return err
+	}
+
+	var keys []string
+	for k := range aa.Dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err = w.actionEntry(aa, k, js, true); err != nil {
+			return err
+		}
+	}
+
+	if w.remove && aa.Len() == 0 {
+		d.Delete("AA")
+	}
+
+	return nil
+}
+
+// annotationDict processes the actions of an annotation or field dict.
+func (w *jsWalker) annotationDict(d *PDFDict, objNr int, js JavaScript) error {
+
+	if objNr > 0 {
+		if w.dicts[objNr] {
+			return nil
+		}
+		w.dicts[objNr] = true
+	}
+
+	if err := w.actionEntry(d, "A", js, true); err != nil {
+		return err
+	}
+
+	return w.additionalActions(d, js)
+}
+
+func (w *jsWalker) document() error {
+
+	rootDict, err := w.xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	js := JavaScript{Location: "document"}
+
+	if obj, found := rootDict.Find("OpenAction"); found {
+		// OpenAction is either an action dict or a destination array.
+		o, err := w.xRefTable.Dereference(obj)
+		if err != nil {
+			return err
+		}
+		if _, ok := o.(PDFDict); ok {
+			if err = w.actionEntry(rootDict, "OpenAction", js, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.additionalActions(rootDict, js)
+}
+
+// documentScripts processes the document level scripts of the JavaScript name tree, see 12.6.4.16.
+func (w *jsWalker) documentScripts() error {
+
+	if !w.xRefTable.Valid && w.xRefTable.Names["JavaScript"] == nil {
+		if err := w.xRefTable.LocateNameTree("JavaScript", false); err != nil {
+			return err
+		}
+	}
+
+	tree := w.xRefTable.Names["JavaScript"]
+	if tree == nil {
+		return nil
+	}
+
+	err := tree.Process(w.xRefTable, func(xRefTable *XRefTable, k string, v PDFObject) error {
+		_, err := w.action(v, JavaScript{Location: "document", Trigger: k})
+		return err
+	})
+	if err != nil || !w.remove {
+		return err
+	}
+
+	// The name tree holds JavaScript actions only.
+	delete(w.xRefTable.Names, "JavaScript")
+
+	return w.xRefTable.RemoveNameTree("JavaScript")
+}
+
+func (w *jsWalker) pages(fieldNames map[int]string) error {
+
+	for i := 1; i <= w.xRefTable.PageCount; i++ {
+
+		pageDict, _, err := w.xRefTable.PageDict(i)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		if err = w.additionalActions(pageDict, JavaScript{Location: "page", Page: i}); err != nil {
+			return err
+		}
+
+		arr, err := pageAnnotations(w.xRefTable, pageDict)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range arr {
+
+			var objNr int
+			if indRef, ok := v.(PDFIndirectRef); ok {
+				objNr = indRef.ObjectNumber.Value()
+			}
+
+			d, err := w.xRefTable.DereferenceDict(v)
+			if err != nil {
+				return err
+			}
+
+			if d == nil {
+				continue
+			}
+
+			js := JavaScript{Location: "annotation", Page: i}
+			if name, ok := fieldNames[objNr]; ok && objNr > 0 {
+				js.Location, js.Field = "field", name
+			}
+
+			if err = w.annotationDict(d, objNr, js); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *jsWalker) outlines() error {
+
+	rootDict, err := w.xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	obj, found := rootDict.Find("Outlines")
+	if !found || obj == nil {
+		return nil
+	}
+
+	d, err := w.xRefTable.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return err
+	}
+
+	first, _ := d.Find("First")
+
+	return w.outlineItems(first, IntSet{})
+}
+
+func (w *jsWalker) outlineItems(obj PDFObject, visited IntSet) error {
+
+	for obj != nil {
+
+		indRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			return errors.New("outlineItems: corrupt outline item: must be indirect reference")
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+		if visited[objNr] {
+			return nil
+		}
+		visited[objNr] = true
+
+		d, err := w.xRefTable.DereferenceDict(obj)
+		if err != nil || d == nil {
+			return err
+		}
+
+		if err = w.actionEntry(d, "A", JavaScript{Location: "outline"}, true); err != nil {
+			return err
+		}
+
+		if first, found := d.Find("First"); found {
+			if err = w.outlineItems(first, visited); err != nil {
+				return err
+			}
+		}
+
+		obj, _ = d.Find("Next")
+	}
+
+	return nil
+}
+
+// processActions walks the places of a PDF that may trigger actions of the given types:
+// document level scripts, the document open action and additional actions,
+// page additional actions, annotation and field actions as well as outline actions.
+func processActions(xRefTable *XRefTable, types StringSet, remove bool) (*jsWalker, error) {
+
+	w := &jsWalker{
+		xRefTable: xRefTable,
+		types:     types,
+		remove:    remove,
+		actions:   map[int]PDFObject{},
+		dicts:     IntSet{},
+		counts:    map[string]int{},
+	}
+
+	// Map widget annotations to the names of their fields.
+	fieldNames := map[int]string{}
+	var fields []*formField
+
+	err := processFormFields(xRefTable, func(f *formField) error {
+		for _, indRef := range f.widgets {
+			fieldNames[indRef.ObjectNumber.Value()] = f.name
+		}
+		fields = append(fields, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = w.document(); err != nil {
+		return nil, err
+	}
+
+	if types["JavaScript"] {
+		if err = w.documentScripts(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = w.pages(fieldNames); err != nil {
+		return nil, err
+	}
+
+	// Field dicts separate from their widgets carry the field actions.
+	for _, f := range fields {
+		if err = w.annotationDict(f.dict, f.objNr(), JavaScript{Location: "field", Field: f.name}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = w.outlines(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func processJavaScript(xRefTable *XRefTable, remove bool) ([]JavaScript, error) {
+
+	w, err := processActions(xRefTable, StringSet{"JavaScript": true}, remove)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.scripts, nil
+}
+
+// ListJavaScript returns the document level scripts and all JavaScript actions of a PDF.
+func ListJavaScript(xRefTable *XRefTable) ([]JavaScript, error) {
+
+	log.Debug.Println("ListJavaScript begin")
+
+	scripts, err := processJavaScript(xRefTable, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "ListJavaScript")
+	}
+
+	log.Debug.Println("ListJavaScript end")
+
+	return scripts, nil
+}
+
+// RemoveJavaScript removes the document level scripts and all JavaScript actions of a PDF
+// and returns the number of scripts removed.
+// Actions chained to a JavaScript action by Next are retained.
+func RemoveJavaScript(xRefTable *XRefTable) (int, error) {
+
+	log.Debug.Println("RemoveJavaScript begin")
+
+	scripts, err := processJavaScript(xRefTable, true)
+	if err != nil {
+		return 0, errors.Wrap(err, "RemoveJavaScript")
+	}
+
+	log.Debug.Println("RemoveJavaScript end")
+
+	return len(scripts), nil
+}
@@ -0,0 +1,482 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// JSON representation of the object tree
+//
+// ExportJSON writes all objects of a cross reference table as JSON, ImportJSON turns this JSON back into a PDFContext.
+// PDF objects map to JSON values as follows:
+//
+//	null, boolean      null, true, false
+//	integer            12
+//	real               12.5, 12.0 (always with a decimal point)
+//	name               "/Name"
+//	string literal     "(literal)" (escaped as in the PDF file)
+//	hex literal        "<48656C6C6F>"
+//	indirect ref       "12 0 R"
+//	array              [...]
+//	dict               {"Key": ...}
+//
+// Streams keep their encoded content, either base64 encoded in place or in a separate file.
+// Object streams and cross reference streams are dissolved into their objects.
+// Encrypted files get exported decrypted.
+
+// JSONFile is the JSON representation of a PDF file.
+type JSONFile struct {
+	Version string                 `json:"version"`
+	Trailer map[string]interface{} `json:"trailer"`
+	Objects []*JSONObject          `json:"objects"`
+}
+
+// JSONObject is the JSON representation of a cross reference table entry.
+type JSONObject struct {
+	ObjNr  int         `json:"obj"`
+	GenNr  int         `json:"gen"`
+	Free   bool        `json:"free,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	Stream *JSONStream `json:"stream,omitempty"`
+}
+
+// JSONStream is the JSON representation of a stream dict.
+// The encoded stream content is either held in Data or in the file named File.
+type JSONStream struct {
+	Dict map[string]interface{} `json:"dict"`
+	Data []byte                 `json:"data,omitempty"`
+	File string                 `json:"file,omitempty"`
+}
+
+func jsonValue(o PDFObject) (interface{}, error) {
+
+	switch o := o.(type) {
+
+	case nil:
+		return nil, nil
+
+	case PDFBoolean:
+		return bool(o), nil
+
+	case PDFInteger:
+		return int(o), nil
+
+	case PDFFloat:
+		s := strconv.FormatFloat(float64(o), 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return json.Number(s), nil
+
+	case PDFName:
+		return "/" + string(o), nil
+
+	case PDFStringLiteral:
+		if utf8.ValidString(string(o)) {
+			return "(" + string(o) + ")", nil
+		}
+		// JSON strings are UTF-8, binary strings become hex literals.
+		b, err := Unescape(string(o))
+		if err != nil {
+			return nil, err
+		}
+		return "<" + hex.EncodeToString(b) + ">", nil
+
+	case PDFHexLiteral:
+		return "<" + string(o) + ">", nil
+
+	case PDFIndirectRef:
+		return o.PDFString(), nil
+
+	case PDFArray:
+		a := make([]interface{}, len(o))
+		for i, o1 := range o {
+			v, err := jsonValue(o1)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return a, nil
+
+	case PDFDict:
+		return jsonDict(o)
+
+	}
+
+	return nil, errors.Errorf("pdfcpu: JSON: unsupported object %T", o)
+}
+
+func jsonDict(d PDFDict) (map[string]interface{}, error) {
+
+	m := map[string]interface{}{}
+
+	for k, o := range d.Dict {
+		v, err := jsonValue(o)
+		if err != nil {
+			return nil, errors.Wrapf(err, "entry %s", k)
+		}
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+func parseJSONString(s string) (PDFObject, error) {
+
+	switch {
+
+	case strings.HasPrefix(s, "/"):
+		return PDFName(s[1:]), nil
+
+	case len(s) >= 2 && s[0] == '(' && s[len(s)-1] == ')':
+		return PDFStringLiteral(s[1 : len(s)-1]), nil
+
+	case len(s) >= 2 && s[0] == '<' && s[len(s)-1] == '>':
+		return PDFHexLiteral(s[1 : len(s)-1]), nil
+
+	}
+
+	var objNr, genNr int
+	if _, err := fmt.Sscanf(s, "%d %d R", &objNr, &genNr); err == nil {
+		indRef := NewPDFIndirectRef(objNr, genNr)
+		if indRef.PDFString() == s {
+			return *indRef, nil
+		}
+	}
+
+	return nil, errors.Errorf("pdfcpu: JSON: invalid string %q, want /name, (literal), <hex> or indirect reference", s)
+}
+
+func pdfObjectForJSON(v interface{}) (PDFObject, error) {
+
+	switch v := v.(type) {
+
+	case nil:
+		return nil, nil
+
+	case bool:
+		return PDFBoolean(v), nil
+
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			f, err := v.Float64()
+			return PDFFloat(f), err
+		}
+		i, err := v.Int64()
+		return PDFInteger(i), err
+
+	case string:
+		return parseJSONString(v)
+
+	case []interface{}:
+		a := make(PDFArray, len(v))
+		for i, v1 := range v {
+			o, err := pdfObjectForJSON(v1)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = o
+		}
+		return a, nil
+
+	case map[string]interface{}:
+		return pdfDictForJSON(v)
+
+	}
+
+	return nil, errors.Errorf("pdfcpu: JSON: unsupported value %v", v)
+}
+
+func pdfDictForJSON(m map[string]interface{}) (PDFDict, error) {
+
+	d := NewPDFDict()
+
+	for k, v := range m {
+		o, err := pdfObjectForJSON(v)
+		if err != nil {
+			return d, errors.Wrapf(err, "entry %s", k)
+		}
+		d.Dict[k] = o
+	}
+
+	return d, nil
+}
+
+// skipForJSON returns true for objects not making it into the JSON representation.
+func skipForJSON(ctx *PDFContext, objNr int) bool {
+
+	if objNr == 0 || ctx.Encrypt != nil && objNr == ctx.Encrypt.ObjectNumber.Value() {
+		return true
+	}
+
+	return ctx.Read != nil && (ctx.Read.IsObjectStreamObject(objNr) || ctx.Read.IsXRefStreamObject(objNr))
+}
+
+func jsonObject(ctx *PDFContext, objNr int, entry *XRefTableEntry, writeStream func(objNr int, raw []byte) (string, error)) (*JSONObject, error) {
+
+	jo := &JSONObject{ObjNr: objNr}
+
+	if entry.Generation != nil {
+		jo.GenNr = *entry.Generation
+	}
+
+	if entry.Free {
+		jo.Free = true
+		return jo, nil
+	}
+
+	o, err := ctx.object(entry, objNr)
+	if err != nil {
+		return nil, err
+	}
+
+	sd, ok := o.(PDFStreamDict)
+	if !ok {
+		if jo.Value, err = jsonValue(o); err != nil {
+			return nil, errors.Wrapf(err, "obj#%d", objNr)
+		}
+		return jo, nil
+	}
+
+	jo.Stream = &JSONStream{}
+
+	if jo.Stream.Dict, err = jsonDict(sd.PDFDict); err != nil {
+		return nil, errors.Wrapf(err, "obj#%d", objNr)
+	}
+
+	if writeStream == nil {
+		jo.Stream.Data = sd.Raw
+		return jo, nil
+	}
+
+	if jo.Stream.File, err = writeStream(objNr, sd.Raw); err != nil {
+		return nil, err
+	}
+
+	return jo, nil
+}
+
+// ExportJSON returns the JSON representation of all objects of ctx.
+// The encoded content of streams gets embedded base64 encoded unless writeStream is not nil,
+// in which case writeStream stores the content of stream objNr and returns the file name to be recorded.
+func ExportJSON(ctx *PDFContext, writeStream func(objNr int, raw []byte) (string, error)) ([]byte, error) {
+
+	trailer := NewPDFDict()
+	trailer.Insert("Size", PDFInteger(*ctx.Size))
+	trailer.Insert("Root", *ctx.Root)
+
+	if ctx.Info != nil {
+		trailer.Insert("Info", *ctx.Info)
+	}
+
+	if ctx.ID != nil {
+		trailer.Insert("ID", *ctx.ID)
+	}
+
+	if ctx.AdditionalStreams != nil {
+		trailer.Insert("AdditionalStreams", *ctx.AdditionalStreams)
+	}
+
+	m, err := jsonDict(trailer)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &JSONFile{Version: ctx.VersionString(), Trailer: m}
+
+	var objNrs []int
+	for objNr := range ctx.Table {
+		if !skipForJSON(ctx, objNr) {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+
+		if err := ctx.canceled(); err != nil {
+			return nil, err
+		}
+
+		jo, err := jsonObject(ctx, objNr, ctx.Table[objNr], writeStream)
+		if err != nil {
+			return nil, err
+		}
+
+		f.Objects = append(f.Objects, jo)
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	if err = enc.Encode(f); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func importJSONObject(ctx *PDFContext, jo *JSONObject, readStream func(name string) ([]byte, error)) error {
+
+	genNr := jo.GenNr
+	entry := &XRefTableEntry{Generation: &genNr}
+
+	ctx.Table[jo.ObjNr] = entry
+
+	if jo.Free {
+		zero := int64(0)
+		entry.Free = true
+		entry.Offset = &zero
+		return nil
+	}
+
+	if jo.Stream == nil {
+		o, err := pdfObjectForJSON(jo.Value)
+		if err != nil {
+			return errors.Wrapf(err, "obj#%d", jo.ObjNr)
+		}
+		entry.Object = o
+		return nil
+	}
+
+	d, err := pdfDictForJSON(jo.Stream.Dict)
+	if err != nil {
+		return errors.Wrapf(err, "obj#%d", jo.ObjNr)
+	}
+
+	raw := jo.Stream.Data
+
+	if jo.Stream.File != "" {
+		if readStream == nil {
+			return errors.Errorf("pdfcpu: JSON: obj#%d: missing content of stream file %s", jo.ObjNr, jo.Stream.File)
+		}
+		if raw, err = readStream(jo.Stream.File); err != nil {
+			return err
+		}
+	}
+
+	if raw == nil {
+		raw = []byte{}
+	}
+
+	l := int64(len(raw))
+	sd := NewPDFStreamDict(d, 0, &l, nil, nil)
+	sd.Raw = raw
+
+	entry.Object = sd
+
+	return nil
+}
+
+// ImportJSON creates a PDFContext from the JSON representation bb as produced by ExportJSON.
+// readStream returns the encoded content of streams kept in separate files.
+func ImportJSON(bb []byte, readStream func(name string) ([]byte, error), config *Configuration) (*PDFContext, error) {
+
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	dec.UseNumber()
+
+	f := &JSONFile{}
+	if err := dec.Decode(f); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: JSON")
+	}
+
+	ctx, err := NewPDFContext("", bytes.NewReader(nil), config)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := Version(f.Version)
+	if err != nil {
+		return nil, errors.Errorf("pdfcpu: JSON: unsupported version %q", f.Version)
+	}
+	ctx.HeaderVersion = &v
+
+	trailer, err := pdfDictForJSON(f.Trailer)
+	if err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: JSON: trailer")
+	}
+
+	if ctx.Root = trailer.IndirectRefEntry("Root"); ctx.Root == nil {
+		return nil, errors.New("pdfcpu: JSON: trailer: missing Root")
+	}
+	ctx.Info = trailer.IndirectRefEntry("Info")
+	ctx.ID = trailer.PDFArrayEntry("ID")
+	ctx.AdditionalStreams = trailer.PDFArrayEntry("AdditionalStreams")
+
+	size := 0
+	if i := trailer.IntEntry("Size"); i != nil {
+		size = *i
+	}
+
+	ctx.Table[0] = NewFreeHeadXRefTableEntry()
+
+	for _, jo := range f.Objects {
+
+		if jo.ObjNr <= 0 {
+			return nil, errors.Errorf("pdfcpu: JSON: invalid object number %d", jo.ObjNr)
+		}
+
+		if _, found := ctx.Table[jo.ObjNr]; found {
+			return nil, errors.Errorf("pdfcpu: JSON: duplicate obj#%d", jo.ObjNr)
+		}
+
+		if err = importJSONObject(ctx, jo, readStream); err != nil {
+			return nil, err
+		}
+
+		if jo.ObjNr >= size {
+			size = jo.ObjNr + 1
+		}
+	}
+
+	ctx.Size = &size
+
+	// Filter parameters may be indirect objects, so wait until all objects are in place.
+	for objNr, entry := range ctx.Table {
+
+		sd, ok := entry.Object.(PDFStreamDict)
+		if !ok {
+			continue
+		}
+
+		if sd.FilterPipeline, err = pdfFilterPipeline(ctx, sd.PDFDict); err != nil {
+			return nil, errors.Wrapf(err, "pdfcpu: JSON: obj#%d", objNr)
+		}
+
+		if err = saveDecodedStreamContent(ctx, &sd, objNr, *entry.Generation, ctx.DecodeAllStreams); err != nil {
+			return nil, errors.Wrapf(err, "pdfcpu: JSON: obj#%d", objNr)
+		}
+
+		entry.Object = sd
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONValues(t *testing.T) {
+
+	d := NewPDFDict()
+	d.Insert("Int", PDFInteger(2))
+	d.Insert("Float", PDFFloat(2))
+	d.Insert("Name", PDFName("Name"))
+	d.Insert("Literal", PDFStringLiteral(`a \(b\) c`))
+	d.Insert("Hex", PDFHexLiteral("4142"))
+	d.Insert("Ref", *NewPDFIndirectRef(12, 0))
+	d.Insert("Array", PDFArray{PDFBoolean(true), nil, PDFFloat(-0.5)})
+
+	m, err := jsonDict(d)
+	if err != nil {
+		t.Fatalf("jsonDict: %v\n", err)
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err = enc.Encode(m); err != nil {
+		t.Fatalf("json.Encode: %v\n", err)
+	}
+
+	bb := buf.Bytes()
+
+	want := `{"Array":[true,null,-0.5],"Float":2.0,"Hex":"<4142>","Int":2,"Literal":"(a \\(b\\) c)","Name":"/Name","Ref":"12 0 R"}` + "\n"
+	if string(bb) != want {
+		t.Errorf("got %s\nwant %s\n", bb, want)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	dec.UseNumber()
+
+	var m1 map[string]interface{}
+	if err = dec.Decode(&m1); err != nil {
+		t.Fatalf("json.Decode: %v\n", err)
+	}
+
+	d1, err := pdfDictForJSON(m1)
+	if err != nil {
+		t.Fatalf("pdfDictForJSON: %v\n", err)
+	}
+
+	if d1.PDFString() != d.PDFString() {
+		t.Errorf("got %s\nwant %s\n", d1.PDFString(), d.PDFString())
+	}
+
+	// Binary string literals turn into hex literals.
+	if v, err := jsonValue(PDFStringLiteral("\xFF\\101")); err != nil || v != "<ff41>" {
+		t.Errorf("binary literal: got %v %v\n", v, err)
+	}
+
+	for _, s := range []string{"", "text", "12 0 R x", "1 R"} {
+		if _, err := parseJSONString(s); err == nil {
+			t.Errorf("parseJSONString(%q): want error\n", s)
+		}
+	}
+}
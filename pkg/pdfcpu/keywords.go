@@ -0,0 +1,158 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// splitKeywords returns the keywords of s separated by commas or semicolons.
+func splitKeywords(s string) []string {
+
+	var kk []string
+
+	for _, k := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if k = strings.TrimSpace(k); k != "" {
+			kk = append(kk, k)
+		}
+	}
+
+	return kk
+}
+
+// Keywords returns the keywords of the document info dict or, if missing, of the XMP metadata.
+func Keywords(ctx *PDFContext) ([]string, error) {
+
+	d, err := infoDict(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if d != nil {
+		if s := infoValue(ctx, d, "Keywords"); s != "" {
+			return splitKeywords(s), nil
+		}
+	}
+
+	x, err := ReadXMP(ctx.XRefTable)
+	if err != nil || x == nil {
+		return nil, err
+	}
+
+	if s := x.Property("pdf:Keywords"); s != "" {
+		return splitKeywords(s), nil
+	}
+
+	return x.Values("dc:subject"), nil
+}
+
+// setKeywords replaces the keywords of the document info dict and the XMP metadata by kk.
+// The XMP metadata holds them as pdf:Keywords and as dc:subject.
+func setKeywords(ctx *PDFContext, kk []string) error {
+
+	if len(kk) == 0 {
+		if err := RemoveInfo(ctx, []string{"Keywords"}); err != nil {
+			return err
+		}
+	} else {
+		d, err := infoDict(ctx, true)
+		if err != nil {
+			return err
+		}
+		updateInfoText(d, "Keywords", strings.Join(kk, ", "))
+	}
+
+	x, err := ReadXMP(ctx.XRefTable)
+	if err != nil || x == nil {
+		return err
+	}
+
+	if len(kk) == 0 {
+		err = x.RemoveProperty("dc:subject")
+	} else if err = x.SetProperty("pdf:Keywords", strings.Join(kk, ", ")); err == nil {
+		err = x.SetProperty("dc:subject", kk...)
+	}
+	if err != nil {
+		return err
+	}
+
+	return WriteXMP(ctx.XRefTable, x)
+}
+
+// AddKeywords adds the keywords kk skipping keywords already present.
+func AddKeywords(ctx *PDFContext, kk []string) error {
+
+	if len(kk) == 0 {
+		return errors.New("pdfcpu: missing keywords")
+	}
+
+	old, err := Keywords(ctx)
+	if err != nil {
+		return err
+	}
+
+	m := StringSet{}
+	for _, k := range old {
+		m[k] = true
+	}
+
+	for _, k := range kk {
+		k = strings.TrimSpace(k)
+		if k == "" || strings.ContainsAny(k, ",;") {
+			return errors.Errorf("pdfcpu: invalid keyword: %q", k)
+		}
+		if !m[k] {
+			old = append(old, k)
+			m[k] = true
+		}
+	}
+
+	return setKeywords(ctx, old)
+}
+
+// RemoveKeywords removes the keywords kk or all keywords if kk is empty.
+func RemoveKeywords(ctx *PDFContext, kk []string) error {
+
+	if len(kk) == 0 {
+		return setKeywords(ctx, nil)
+	}
+
+	old, err := Keywords(ctx)
+	if err != nil {
+		return err
+	}
+
+	m := StringSet{}
+	for _, k := range kk {
+		m[strings.TrimSpace(k)] = true
+	}
+
+	var keep []string
+	for _, k := range old {
+		if !m[k] {
+			keep = append(keep, k)
+		}
+	}
+
+	if len(keep) == len(old) {
+		return errors.Errorf("pdfcpu: no such keywords: %s", strings.Join(kk, ", "))
+	}
+
+	return setKeywords(ctx, keep)
+}
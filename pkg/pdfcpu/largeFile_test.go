@@ -0,0 +1,183 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// sparseFile is a virtual file made up of zeros except for its segments.
+type sparseFile struct {
+	size int64
+	segs map[int64][]byte
+	off  int64
+	read int64 // Total number of bytes read.
+}
+
+func (f *sparseFile) Read(p []byte) (int, error) {
+
+	if f.off >= f.size {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > f.size-f.off {
+		p = p[:f.size-f.off]
+	}
+
+	// Reading the whole file would exhaust memory, eg. during repair.
+	if f.read += int64(len(p)); f.read > 1<<26 {
+		return 0, errors.New("sparseFile: too many bytes read")
+	}
+
+	for i := range p {
+		p[i] = 0
+	}
+
+	for off, b := range f.segs {
+		if off < f.off+int64(len(p)) && off+int64(len(b)) > f.off {
+			if off >= f.off {
+				copy(p[off-f.off:], b)
+			} else {
+				copy(p, b[f.off-off:])
+			}
+		}
+	}
+
+	f.off += int64(len(p))
+
+	return len(p), nil
+}
+
+func (f *sparseFile) Seek(offset int64, whence int) (int64, error) {
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("sparseFile: negative offset")
+	}
+
+	f.off = offset
+
+	return offset, nil
+}
+
+// hugeFile returns a one page PDF file of more than 4GB with all objects located beyond 4GB.
+func hugeFile(xrefStream bool) *sparseFile {
+
+	const base int64 = 5 << 30
+
+	var buf bytes.Buffer
+	var offsets []int64
+
+	for _, s := range []string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids[3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox[0 0 612 792]/Resources<<>>/Contents 4 0 R>>",
+		"<</Length 8>>\nstream\n0 0 m S\n\nendstream",
+	} {
+		offsets = append(offsets, base+int64(buf.Len()))
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), s)
+	}
+
+	xrefOff := base + int64(buf.Len())
+
+	if xrefStream {
+		b := []byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF}
+		for _, off := range append(offsets, xrefOff) {
+			b = append(b, 1)
+			b = append(b, int64ToBuf(off, 5)...)
+			b = append(b, 0, 0)
+		}
+		fmt.Fprintf(&buf, "5 0 obj\n<</Type/XRef/Size 6/W[1 5 2]/Root 1 0 R/Length %d>>\nstream\n%s\nendstream\nendobj\n", len(b), b)
+	} else {
+		fmt.Fprintf(&buf, "xref\n0 5\n0000000000 65535 f\r\n")
+		for _, off := range offsets {
+			fmt.Fprintf(&buf, "%010d 00000 n\r\n", off)
+		}
+		fmt.Fprintf(&buf, "trailer\n<</Size 5/Root 1 0 R>>\n")
+	}
+
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xrefOff)
+
+	return &sparseFile{
+		size: base + int64(buf.Len()),
+		segs: map[int64][]byte{0: []byte("%PDF-1.7\n"), base: buf.Bytes()},
+	}
+}
+
+func TestReadHugeFile(t *testing.T) {
+
+	for _, xrefStream := range []bool{false, true} {
+
+		f := hugeFile(xrefStream)
+
+		ctx, err := Read(f, NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("xrefStream=%t: %v\n", xrefStream, err)
+		}
+
+		if err = ValidateXRefTable(ctx.XRefTable); err != nil {
+			t.Fatalf("xrefStream=%t: %v\n", xrefStream, err)
+		}
+
+		if ctx.PageCount != 1 {
+			t.Errorf("xrefStream=%t: got %d pages, want 1\n", xrefStream, ctx.PageCount)
+		}
+
+		entry, _ := ctx.Find(4)
+		if *entry.Offset <= 1<<32 {
+			t.Errorf("xrefStream=%t: got offset %d\n", xrefStream, *entry.Offset)
+		}
+
+		sd, ok := entry.Object.(PDFStreamDict)
+		if !ok || string(sd.Raw) != "0 0 m S\n" {
+			t.Errorf("xrefStream=%t: corrupt content stream: %v\n", xrefStream, entry.Object)
+		}
+	}
+}
+
+func TestXRefStreamFieldsBeyond4GB(t *testing.T) {
+
+	for _, off := range []int64{1<<32 - 1, 1 << 32, 5<<30 + 17, 1<<40 + 1} {
+
+		n := byteCount(off)
+
+		b := int64ToBuf(off, n)
+		if len(b) != n {
+			t.Fatalf("offset %d: got %d bytes, want %d\n", off, len(b), n)
+		}
+
+		var got int64
+		for _, c := range b {
+			got = got<<8 | int64(c)
+		}
+
+		if got != off {
+			t.Errorf("got offset %d, want %d\n", got, off)
+		}
+	}
+}
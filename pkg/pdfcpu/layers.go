@@ -0,0 +1,853 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Layers
+//
+// Optional content groups (8.11.2) aka layers are listed in the OCProperties dict of the catalog.
+// Content belongs to a layer if it is enclosed in a marked-content sequence /OC /name BDC ... EMC
+// or if it is a form XObject, image XObject or annotation with an OC entry.
+// An OC entry may also refer to an optional content membership dict (OCMD) combining several layers.
+// Layers are addressed by name. All layers sharing a name are affected alike.
+
+// Layer represents an optional content group.
+type Layer struct {
+	ObjNr   int    `json:"objNr"`
+	Name    string `json:"name"`
+	Visible bool   `json:"visible"` // initial state of the default configuration
+	Locked  bool   `json:"locked,omitempty"`
+}
+
+// LayerMembership represents an optional content membership dict.
+type LayerMembership struct {
+	ObjNr  int      `json:"objNr"`
+	Layers []string `json:"layers"`
+	Policy string   `json:"policy"` // AllOn, AnyOn, AnyOff or AllOff
+}
+
+type ocState int
+
+const (
+	ocKeep   ocState = iota // leave content and marks alone
+	ocUnwrap                // keep the content, remove the marks
+	ocDrop                  // remove the content
+)
+
+// ocProperties returns the OCProperties dict of the catalog.
+func ocProperties(xRefTable *XRefTable) (*PDFDict, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.DereferenceDict(rootDict.Dict["OCProperties"])
+}
+
+// ocConfigs returns the default configuration followed by the alternate configurations.
+func ocConfigs(xRefTable *XRefTable, ocp *PDFDict) ([]*PDFDict, error) {
+
+	var dd []*PDFDict
+
+	d, err := xRefTable.DereferenceDict(ocp.Dict["D"])
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, errors.New("pdfcpu: OCProperties: missing default configuration")
+	}
+	dd = append(dd, d)
+
+	arr, err := xRefTable.DereferenceArray(ocp.Dict["Configs"])
+	if err != nil || arr == nil {
+		return dd, err
+	}
+
+	for _, o := range *arr {
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			dd = append(dd, d)
+		}
+	}
+
+	return dd, nil
+}
+
+// objNrs returns the object numbers of the indirect references in the array o.
+func objNrs(xRefTable *XRefTable, o PDFObject) IntSet {
+
+	m := IntSet{}
+
+	arr, err := xRefTable.DereferenceArray(o)
+	if err != nil || arr == nil {
+		if indRef, ok := o.(PDFIndirectRef); ok {
+			m[indRef.ObjectNumber.Value()] = true
+		}
+		return m
+	}
+
+	for _, o := range *arr {
+		if indRef, ok := o.(PDFIndirectRef); ok {
+			m[indRef.ObjectNumber.Value()] = true
+		}
+	}
+
+	return m
+}
+
+// ocVisible returns the initial visibility of the layers of the default configuration d by object number.
+func ocVisible(xRefTable *XRefTable, ocgs IntSet, d *PDFDict) IntSet {
+
+	on, off := objNrs(xRefTable, d.Dict["ON"]), objNrs(xRefTable, d.Dict["OFF"])
+
+	baseOn := true
+	if bs := d.NameEntry("BaseState"); bs != nil && *bs == "OFF" {
+		baseOn = false
+	}
+
+	m := IntSet{}
+	for objNr := range ocgs {
+		m[objNr] = baseOn && !off[objNr] || !baseOn && on[objNr]
+	}
+
+	return m
+}
+
+type layers struct {
+	xRefTable *XRefTable
+	ocp       *PDFDict
+	ocgs      IntSet           // object numbers of all layers
+	names     map[int]string   // layer names by object number
+	visible   IntSet           // initial visibility by object number
+	configs   []*PDFDict       // default configuration first
+	ocmds     map[int]*PDFDict // membership dicts by object number
+}
+
+// loadLayers returns the layers of the document, nil if there are none.
+func loadLayers(xRefTable *XRefTable) (*layers, error) {
+
+	ocp, err := ocProperties(xRefTable)
+	if err != nil || ocp == nil {
+		return nil, err
+	}
+
+	l := &layers{
+		xRefTable: xRefTable,
+		ocp:       ocp,
+		ocgs:      objNrs(xRefTable, ocp.Dict["OCGs"]),
+		names:     map[int]string{},
+		ocmds:     map[int]*PDFDict{},
+	}
+
+	for objNr := range l.ocgs {
+		d, err := xRefTable.DereferenceDict(*NewPDFIndirectRef(objNr, 0))
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			continue
+		}
+		if l.names[objNr], err = fieldValueString(xRefTable, d.Dict["Name"]); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.configs, err = ocConfigs(xRefTable, ocp); err != nil {
+		return nil, err
+	}
+
+	l.visible = ocVisible(xRefTable, l.ocgs, l.configs[0])
+
+	// Membership dicts are not listed anywhere.
+	for objNr, entry := range xRefTable.Table {
+		if entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		if d, ok := entry.Object.(PDFDict); ok {
+			if t := d.Type(); t != nil && *t == "OCMD" {
+				l.ocmds[objNr] = &d
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// selected returns the object numbers of the layers named names.
+func (l *layers) selected(names []string) (IntSet, error) {
+
+	m := IntSet{}
+
+	for _, name := range names {
+		found := false
+		for objNr, s := range l.names {
+			if s == name {
+				m[objNr] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("pdfcpu: layer %q not found", name)
+		}
+	}
+
+	return m, nil
+}
+
+// ocmdPolicy returns the visibility policy of an OCMD.
+func ocmdPolicy(d *PDFDict) string {
+	if p := d.NameEntry("P"); p != nil {
+		return *p
+	}
+	return "AnyOn"
+}
+
+// ocmdVisible evaluates an OCMD against the initial visibility of its layers.
+// Visibility expressions are not supported, the policy applies instead.
+func (l *layers) ocmdVisible(d *PDFDict) bool {
+
+	on, n := 0, 0
+	for objNr := range objNrs(l.xRefTable, d.Dict["OCGs"]) {
+		n++
+		if l.visible[objNr] {
+			on++
+		}
+	}
+
+	switch ocmdPolicy(d) {
+	case "AllOn":
+		return on == n
+	case "AnyOff":
+		return on < n
+	case "AllOff":
+		return on == 0
+	}
+
+	return n == 0 || on > 0
+}
+
+// state returns the state of an OC entry or a property of a marked-content sequence using fn for layers and OCMDs.
+func (l *layers) state(o PDFObject, fn func(objNr int, ocmd *PDFDict) ocState) ocState {
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		return ocKeep
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+
+	if l.ocgs[objNr] {
+		return fn(objNr, nil)
+	}
+
+	if d, ok := l.ocmds[objNr]; ok {
+		return fn(objNr, d)
+	}
+
+	return ocKeep
+}
+
+// Layers returns the layers and the membership dicts of the document.
+func Layers(xRefTable *XRefTable) ([]Layer, []LayerMembership, error) {
+
+	l, err := loadLayers(xRefTable)
+	if err != nil || l == nil {
+		return nil, nil, err
+	}
+
+	locked := objNrs(xRefTable, l.configs[0].Dict["Locked"])
+
+	var ll []Layer
+	for objNr := range l.ocgs {
+		ll = append(ll, Layer{ObjNr: objNr, Name: l.names[objNr], Visible: l.visible[objNr], Locked: locked[objNr]})
+	}
+	sort.Slice(ll, func(i, j int) bool { return ll[i].ObjNr < ll[j].ObjNr })
+
+	var mm []LayerMembership
+	for objNr, d := range l.ocmds {
+		m := LayerMembership{ObjNr: objNr, Policy: ocmdPolicy(d)}
+		for objNr := range objNrs(xRefTable, d.Dict["OCGs"]) {
+			m.Layers = append(m.Layers, l.names[objNr])
+		}
+		sort.Strings(m.Layers)
+		mm = append(mm, m)
+	}
+	sort.Slice(mm, func(i, j int) bool { return mm[i].ObjNr < mm[j].ObjNr })
+
+	return ll, mm, nil
+}
+
+func loadSelectedLayers(xRefTable *XRefTable, names []string) (*layers, IntSet, error) {
+
+	l, err := loadLayers(xRefTable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l == nil {
+		return nil, nil, errors.New("pdfcpu: no layers")
+	}
+
+	m, err := l.selected(names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return l, m, nil
+}
+
+// filterRefs returns arr without references to objNrs. Nested arrays are filtered recursively.
+func filterRefs(arr PDFArray, objNrs IntSet) PDFArray {
+
+	a := PDFArray{}
+
+	for _, o := range arr {
+		switch o := o.(type) {
+		case PDFIndirectRef:
+			if objNrs[o.ObjectNumber.Value()] {
+				continue
+			}
+		case PDFArray:
+			a = append(a, filterRefs(o, objNrs))
+			continue
+		}
+		a = append(a, o)
+	}
+
+	return a
+}
+
+// updateRefArray replaces the array d[key] by fn(array).
+func (l *layers) updateRefArray(d *PDFDict, key string, fn func(PDFArray) PDFArray) {
+
+	arr, err := l.xRefTable.DereferenceArray(d.Dict[key])
+	if err != nil {
+		return
+	}
+
+	a := PDFArray{}
+	if arr != nil {
+		a = *arr
+	}
+
+	d.Update(key, fn(a))
+}
+
+// SetLayerVisibility sets the initial visibility of the layers named names in the default configuration.
+func SetLayerVisibility(xRefTable *XRefTable, names []string, visible bool) error {
+
+	l, m, err := loadSelectedLayers(xRefTable, names)
+	if err != nil {
+		return err
+	}
+
+	d := l.configs[0]
+
+	on, off := "ON", "OFF"
+	if !visible {
+		on, off = off, on
+	}
+
+	l.updateRefArray(d, off, func(arr PDFArray) PDFArray { return filterRefs(arr, m) })
+
+	l.updateRefArray(d, on, func(arr PDFArray) PDFArray {
+		arr = filterRefs(arr, m)
+		for objNr := range m {
+			arr = append(arr, *NewPDFIndirectRef(objNr, 0))
+		}
+		return arr
+	})
+
+	return nil
+}
+
+// RenameLayer renames the layers named oldName.
+func RenameLayer(xRefTable *XRefTable, oldName, newName string) error {
+
+	if newName == "" {
+		return errors.New("pdfcpu: layer: missing name")
+	}
+
+	l, m, err := loadSelectedLayers(xRefTable, []string{oldName})
+	if err != nil {
+		return err
+	}
+
+	for objNr := range m {
+		d, err := l.xRefTable.DereferenceDict(*NewPDFIndirectRef(objNr, 0))
+		if err != nil {
+			return err
+		}
+		d.Update("Name", encodeText(newName))
+	}
+
+	return nil
+}
+
+// ocRewriter rewrites content according to the state of its layers.
+type ocRewriter struct {
+	xRefTable *XRefTable
+	state     func(o PDFObject) ocState
+	xObjects  IntSet // XObjects processed
+	stale     []staleResource
+}
+
+// staleResource is a resource no longer used once the content has been rewritten.
+type staleResource struct {
+	dict *PDFDict
+	name string
+}
+
+// resourceEntry returns the resource name of category.
+// Resources whose state is different from ocKeep become stale.
+func (w *ocRewriter) resourceEntry(resources *PDFDict, category, name string) (PDFObject, func(ocState), error) {
+
+	if resources == nil {
+		return nil, nil, nil
+	}
+
+	d, err := w.xRefTable.DereferenceDict(resources.Dict[category])
+	if err != nil || d == nil {
+		return nil, nil, err
+	}
+
+	mark := func(state ocState) {
+		if state != ocKeep {
+			w.stale = append(w.stale, staleResource{d, name})
+		}
+	}
+
+	return d.Dict[name], mark, nil
+}
+
+// xObject returns the state of the XObject name and rewrites its content if it is a form.
+func (w *ocRewriter) xObject(resources *PDFDict, name string, depth int) (ocState, error) {
+
+	o, mark, err := w.resourceEntry(resources, "XObject", name)
+	if err != nil || o == nil {
+		return ocKeep, err
+	}
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		return ocKeep, nil
+	}
+
+	sd, err := w.xRefTable.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return ocKeep, err
+	}
+
+	state := ocKeep
+	if oc, found := sd.Find("OC"); found {
+		state = w.state(oc)
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+
+	if state == ocDrop {
+		mark(state)
+		return state, nil
+	}
+
+	if w.xObjects[objNr] || depth >= maxFormNesting {
+		return state, nil
+	}
+	w.xObjects[objNr] = true
+
+	if st := sd.NameEntry("Subtype"); st == nil || *st != "Form" {
+		return state, nil
+	}
+
+	s := *sd
+
+	err = w.xRefTable.decodeStream(&s)
+	if err == filter.ErrUnsupportedFilter {
+		return state, nil
+	}
+	if err != nil {
+		return ocKeep, err
+	}
+
+	res := resources
+	if d, err := w.xRefTable.DereferenceDict(s.Dict["Resources"]); err != nil {
+		return ocKeep, err
+	} else if d != nil {
+		res = d
+	}
+
+	bb, changed, err := w.content(s.Content, res, depth+1)
+	if err != nil || !changed {
+		return state, err
+	}
+
+	s.Content = bb
+	s.FilterPipeline = []PDFFilter{{Name: filter.Flate}}
+	s.InsertName("Filter", filter.Flate)
+	s.Delete("DecodeParms")
+
+	if err = encodeStream(&s); err != nil {
+		return ocKeep, err
+	}
+
+	// Forms are shared, all uses are affected alike.
+	w.xRefTable.Table[objNr].Object = s
+
+	return state, nil
+}
+
+// content returns bb with the optional content rewritten according to w.state.
+func (w *ocRewriter) content(bb []byte, resources *PDFDict, depth int) ([]byte, bool, error) {
+
+	bb = compactHexStrings(bb)
+
+	var b bytes.Buffer
+	var last int // end of the content copied to b
+	var changed bool
+
+	remove := func(start, end int) {
+		b.Write(bb[last:start])
+		b.WriteByte(' ')
+		last = end
+		changed = true
+	}
+
+	// The marked-content sequences open along with their state and start offset.
+	type seq struct {
+		state ocState
+		start int
+	}
+	var seqs []seq
+
+	dropped := func() bool {
+		for _, s := range seqs {
+			if s.state == ocDrop {
+				return true
+			}
+		}
+		return false
+	}
+
+	err := scanContent(bb, func(op string, operands []PDFObject, start, end int) error {
+
+		switch op {
+
+		case "BMC":
+			seqs = append(seqs, seq{ocKeep, start})
+
+		case "BDC":
+			state := ocKeep
+			if len(operands) == 2 && !dropped() {
+				tag, _ := operands[0].(PDFName)
+				name, ok := operands[1].(PDFName)
+				if tag == "OC" && ok {
+					o, mark, err := w.resourceEntry(resources, "Properties", name.Value())
+					if err != nil {
+						return err
+					}
+					if o != nil {
+						state = w.state(o)
+						mark(state)
+					}
+				}
+			}
+			seqs = append(seqs, seq{state, start})
+			if state == ocUnwrap {
+				remove(start, end)
+			}
+
+		case "EMC":
+			if len(seqs) == 0 {
+				break
+			}
+			s := seqs[len(seqs)-1]
+			seqs = seqs[:len(seqs)-1]
+			switch {
+			case dropped():
+			case s.state == ocDrop:
+				remove(s.start, end)
+			case s.state == ocUnwrap:
+				remove(start, end)
+			}
+
+		case "Do":
+			if len(operands) != 1 || dropped() {
+				break
+			}
+			name, _ := operands[0].(PDFName)
+			state, err := w.xObject(resources, name.Value(), depth)
+			if err != nil {
+				return err
+			}
+			if state == ocDrop {
+				remove(start, end)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !changed {
+		return bb, false, nil
+	}
+
+	b.Write(bb[last:])
+
+	return b.Bytes(), true, nil
+}
+
+// rewritePages rewrites the content of all pages and removes dropped annotations.
+// OC entries of XObjects and annotations in state ocUnwrap get removed.
+func (l *layers) rewritePages(state func(objNr int, ocmd *PDFDict) ocState) error {
+
+	xRefTable := l.xRefTable
+
+	w := &ocRewriter{
+		xRefTable: xRefTable,
+		state:     func(o PDFObject) ocState { return l.state(o, state) },
+		xObjects:  IntSet{},
+	}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, inhPAttrs, err := xRefTable.PageDict(i)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		bb, err := pageContent(xRefTable, pageDict, true)
+		if err != nil {
+			return err
+		}
+
+		bb, changed, err := w.content(bb, inhPAttrs.resources, 0)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			sd := &PDFStreamDict{
+				PDFDict:        NewPDFDict(),
+				Content:        bb,
+				FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+			sd.InsertName("Filter", filter.Flate)
+
+			if err = encodeStream(sd); err != nil {
+				return err
+			}
+
+			indRef, err := xRefTable.IndRefForNewObject(*sd)
+			if err != nil {
+				return err
+			}
+
+			pageDict.Update("Contents", *indRef)
+		}
+
+		arr, err := pageAnnotations(xRefTable, pageDict)
+		if err != nil {
+			return err
+		}
+
+		if len(arr) == 0 {
+			continue
+		}
+
+		annots := PDFArray{}
+
+		for _, o := range arr {
+			d, err := xRefTable.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+			if d != nil {
+				if oc, found := d.Find("OC"); found {
+					switch w.state(oc) {
+					case ocDrop:
+						continue
+					case ocUnwrap:
+						d.Delete("OC")
+					}
+				}
+			}
+			annots = append(annots, o)
+		}
+
+		if len(annots) < len(arr) {
+			if len(annots) == 0 {
+				pageDict.Delete("Annots")
+			} else {
+				pageDict.Update("Annots", annots)
+			}
+		}
+	}
+
+	// Names may be in use by several content streams sharing resources.
+	for _, r := range w.stale {
+		r.dict.Delete(r.name)
+	}
+
+	// XObjects keep their OC entries unless unwrapped.
+	for objNr := range w.xObjects {
+		if sd, ok := xRefTable.Table[objNr].Object.(PDFStreamDict); ok {
+			if oc, found := sd.Find("OC"); found && w.state(oc) == ocUnwrap {
+				sd.Delete("OC")
+			}
+		}
+	}
+
+	return nil
+}
+
+// forget removes the references to the layers m from the optional content properties.
+// The optional content properties go once no layers are left.
+func (l *layers) forget(m IntSet) error {
+
+	xRefTable := l.xRefTable
+
+	l.updateRefArray(l.ocp, "OCGs", func(arr PDFArray) PDFArray { return filterRefs(arr, m) })
+
+	if arr := l.ocp.PDFArrayEntry("OCGs"); arr == nil || len(*arr) == 0 {
+		rootDict, err := xRefTable.Catalog()
+		if err != nil {
+			return err
+		}
+		rootDict.Delete("OCProperties")
+		return nil
+	}
+
+	for _, d := range l.configs {
+		for _, k := range []string{"ON", "OFF", "Locked", "Order", "RBGroups"} {
+			if _, found := d.Find(k); found {
+				l.updateRefArray(d, k, func(arr PDFArray) PDFArray { return filterRefs(arr, m) })
+			}
+		}
+		as, err := xRefTable.DereferenceArray(d.Dict["AS"])
+		if err != nil {
+			return err
+		}
+		if as == nil {
+			continue
+		}
+		for _, o := range *as {
+			if d, err := xRefTable.DereferenceDict(o); err == nil && d != nil {
+				l.updateRefArray(d, "OCGs", func(arr PDFArray) PDFArray { return filterRefs(arr, m) })
+			}
+		}
+	}
+
+	return nil
+}
+
+// RemoveLayers removes the layers named names along with their content.
+// Membership dicts lose their references to removed layers, content governed by membership dicts left without layers gets removed.
+func RemoveLayers(xRefTable *XRefTable, names []string) error {
+
+	l, m, err := loadSelectedLayers(xRefTable, names)
+	if err != nil {
+		return err
+	}
+
+	emptied := IntSet{}
+	for objNr, d := range l.ocmds {
+		ocgs := objNrs(xRefTable, d.Dict["OCGs"])
+		n := 0
+		for ocg := range ocgs {
+			if !m[ocg] {
+				n++
+			}
+		}
+		if n == len(ocgs) {
+			continue
+		}
+		if n == 0 {
+			emptied[objNr] = true
+		}
+		l.updateRefArray(d, "OCGs", func(arr PDFArray) PDFArray { return filterRefs(arr, m) })
+	}
+
+	err = l.rewritePages(func(objNr int, ocmd *PDFDict) ocState {
+		if m[objNr] || emptied[objNr] {
+			return ocDrop
+		}
+		return ocKeep
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = l.forget(m); err != nil {
+		return err
+	}
+
+	log.Debug.Printf("RemoveLayers: %d layers removed\n", len(m))
+
+	return nil
+}
+
+// FlattenLayers turns the content of all layers visible in the default configuration into unconditional content
+// and removes the content of hidden layers along with the optional content properties.
+func FlattenLayers(xRefTable *XRefTable) error {
+
+	l, err := loadLayers(xRefTable)
+	if err != nil || l == nil {
+		return err
+	}
+
+	err = l.rewritePages(func(objNr int, ocmd *PDFDict) ocState {
+		visible := l.visible[objNr]
+		if ocmd != nil {
+			visible = l.ocmdVisible(ocmd)
+		}
+		if visible {
+			return ocUnwrap
+		}
+		return ocDrop
+	})
+	if err != nil {
+		return err
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootDict.Delete("OCProperties")
+
+	return nil
+}
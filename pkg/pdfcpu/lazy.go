@@ -0,0 +1,137 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sync"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// streamLoader loads the encoded content of a stream dict into memory and decodes it if necessary.
+type streamLoader func(sd *PDFStreamDict, objNr, genNr int) error
+
+// objectLoader parses the object of an xRefTable entry from file.
+type objectLoader func(entry *XRefTableEntry, objNr int) (PDFObject, error)
+
+// lazyStreamLoader returns the streamLoader for a PDFContext read using LazyLoading.
+// Files read by name get reopened for loading so there is no need to keep them open.
+func lazyStreamLoader(ctx *PDFContext) streamLoader {
+
+	// Serializes access to ctx.Read.RS for concurrent page processing.
+	var mu sync.Mutex
+
+	return func(sd *PDFStreamDict, objNr, genNr int) error {
+
+		log.Debug.Printf("lazyStreamLoader: loading stream obj#%d\n", objNr)
+
+		mu.Lock()
+		err := readLazyStream(ctx, sd)
+		mu.Unlock()
+
+		if err != nil {
+			return errors.Wrapf(err, "lazyStreamLoader: problem loading stream %d", objNr)
+		}
+
+		return saveDecodedStreamContent(ctx, sd, objNr, genNr, ctx.DecodeAllStreams)
+	}
+}
+
+func readLazyStream(ctx *PDFContext, sd *PDFStreamDict) error {
+
+	if ctx.Read.RS == nil {
+
+		file, err := ctx.openReadSeeker(ctx.Read.FileName)
+		if err != nil {
+			return errors.Wrapf(err, "can't open %q", ctx.Read.FileName)
+		}
+
+		ctx.Read.RS = file
+
+		defer func() {
+			ctx.Read.RS = nil
+			file.Close()
+		}()
+	}
+
+	_, err := loadEncodedStreamContent(ctx, sd)
+
+	return err
+}
+
+// object returns the object of entry after loading it or the content of a lazily read stream dict on first access.
+func (xRefTable *XRefTable) object(entry *XRefTableEntry, objNr int) (PDFObject, error) {
+
+	if entry.Object == nil && xRefTable.loadObject != nil {
+		return xRefTable.loadObject(entry, objNr)
+	}
+
+	if xRefTable.loadStream == nil {
+		return entry.Object, nil
+	}
+
+	xRefTable.loadMu.Lock()
+	o := entry.Object
+	xRefTable.loadMu.Unlock()
+
+	sd, ok := o.(PDFStreamDict)
+	if !ok || sd.Raw != nil {
+		return o, nil
+	}
+
+	genNr := 0
+	if entry.Generation != nil {
+		genNr = *entry.Generation
+	}
+
+	// Load without holding the lock since decoding may dereference filter parameters.
+	if err := xRefTable.loadStream(&sd, objNr, genNr); err != nil {
+		return nil, err
+	}
+
+	xRefTable.loadMu.Lock()
+	defer xRefTable.loadMu.Unlock()
+
+	// Another goroutine may have loaded this stream in the meantime.
+	if sd1, ok := entry.Object.(PDFStreamDict); ok && sd1.Raw == nil {
+		entry.Object = sd
+	}
+
+	return entry.Object, nil
+}
+
+// ensureStreamLoaded loads the content of a lazily read stream dict on first access.
+func (xRefTable *XRefTable) ensureStreamLoaded(entry *XRefTableEntry, objNr int) error {
+	_, err := xRefTable.object(entry, objNr)
+	return err
+}
+
+// LoadStreams loads the content of all streams not loaded yet of a PDFContext read using LazyLoading.
+func (xRefTable *XRefTable) LoadStreams() error {
+
+	for objNr, entry := range xRefTable.Table {
+		if entry.Free {
+			continue
+		}
+		if err := xRefTable.ensureStreamLoaded(entry, objNr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
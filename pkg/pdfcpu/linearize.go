@@ -0,0 +1,911 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// linearization partitions the objects of a document for linearized output, see Annex F.
+//
+// The file is organized as follows:
+//
+//	Part 1: Header
+//	Part 2: Linearization parameter dictionary
+//	Part 3: First-page cross-reference section and trailer
+//	Part 4: Catalog and document level objects
+//	Part 6: First page section
+//	Part 5: Primary hint stream
+//	Part 7: Remaining pages
+//	Part 8: Shared objects of the remaining pages
+//	Part 9: Other objects
+//	Part 11: Main cross-reference section and trailer
+//
+// The hint stream is placed after the first page section which is permitted by F.3.5.
+type linearization struct {
+	ctx      *PDFContext
+	eol      string
+	pages    []int          // page dict object numbers
+	refs     map[int]int    // number of pages referencing an object
+	assigned IntSet         // objects already assigned to a part
+	part4    []int          // catalog and document level objects
+	part6    []int          // first page objects starting with the page dict
+	pageObjs [][]int        // private objects of pages 2..n starting with the page dict
+	pageRefs []IntSet       // objects of pages 2..n including shared objects
+	shared   []int          // objects shared by pages 2..n
+	other    []int          // remaining objects
+	lookup   map[int]int    // new object number by object number
+	objs     map[int][]byte // serialized objects by object number
+}
+
+// closure returns o followed by all objects reachable from o in discovery order.
+// The traversal does not descend into page tree nodes or the catalog other than o itself.
+func (l *linearization) closure(o PDFObject, visited IntSet, objs []int, stop bool) []int {
+
+	switch o := o.(type) {
+
+	case PDFIndirectRef:
+		objNr := o.ObjectNumber.Value()
+		if visited[objNr] {
+			return objs
+		}
+		entry, found := l.ctx.FindTableEntryLight(objNr)
+		if !found || entry.Free || entry.Object == nil {
+			return objs
+		}
+		if stop && len(objs) > 0 {
+			if d, ok := entry.Object.(PDFDict); ok {
+				if t := d.Type(); t != nil && (*t == "Page" || *t == "Pages" || *t == "Catalog") {
+					return objs
+				}
+			}
+		}
+		visited[objNr] = true
+		objs = append(objs, objNr)
+		return l.closure(entry.Object, visited, objs, stop)
+
+	case PDFDict:
+		var keys []string
+		for k := range o.Dict {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if stop && k == "Parent" {
+				continue
+			}
+			objs = l.closure(o.Dict[k], visited, objs, stop)
+		}
+
+	case PDFStreamDict:
+		objs = l.closure(o.PDFDict, visited, objs, stop)
+
+	case PDFArray:
+		for _, v := range o {
+			objs = l.closure(v, visited, objs, stop)
+		}
+
+	}
+
+	return objs
+}
+
+// pageClosure returns the page dict of page i followed by all objects used by this page.
+func (l *linearization) pageClosure(i int) ([]int, error) {
+
+	indRef, err := l.ctx.PageDictIndRef(i)
+	if err != nil {
+		return nil, err
+	}
+	if indRef == nil {
+		return nil, errors.Errorf("linearize: missing page dict for page %d", i)
+	}
+
+	visited := IntSet{}
+	objs := l.closure(*indRef, visited, nil, true)
+
+	// Include inherited resources.
+	d, err := l.ctx.DereferenceDict(*indRef)
+	if err != nil {
+		return nil, err
+	}
+	for j := 0; d != nil && j < 100; j++ {
+		if o, found := d.Find("Resources"); found && j > 0 {
+			objs = l.closure(o, visited, objs, true)
+		}
+		parent, found := d.Find("Parent")
+		if !found {
+			break
+		}
+		if d, err = l.ctx.DereferenceDict(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	return objs, nil
+}
+
+func (l *linearization) assign(part *[]int, objs []int) {
+	for _, objNr := range objs {
+		if !l.assigned[objNr] {
+			l.assigned[objNr] = true
+			*part = append(*part, objNr)
+		}
+	}
+}
+
+// partition assigns all objects reachable from the catalog and the info dict to parts.
+func (l *linearization) partition() error {
+
+	ctx := l.ctx
+
+	pageObjs := make([][]int, ctx.PageCount)
+	l.refs = map[int]int{}
+
+	for i := 1; i <= ctx.PageCount; i++ {
+		objs, err := l.pageClosure(i)
+		if err != nil {
+			return err
+		}
+		l.pages = append(l.pages, objs[0])
+		pageObjs[i-1] = objs
+		for _, objNr := range objs {
+			l.refs[objNr]++
+		}
+	}
+
+	// Part 4: the catalog and objects needed to open the document.
+	rootObjNr := ctx.Root.ObjectNumber.Value()
+	visited := IntSet{rootObjNr: true}
+	objs := []int{rootObjNr}
+	for _, k := range []string{"ViewerPreferences", "OpenAction", "AcroForm", "Threads"} {
+		if o, found := ctx.RootDict.Find(k); found {
+			objs = l.closure(o, visited, objs, true)
+		}
+	}
+	if pm := ctx.RootDict.PDFNameEntry("PageMode"); pm != nil && *pm == "UseOutlines" {
+		if o, found := ctx.RootDict.Find("Outlines"); found {
+			objs = l.closure(o, visited, objs, true)
+		}
+	}
+	l.assign(&l.part4, objs)
+
+	// Part 6: the first page including objects shared with other pages.
+	l.assign(&l.part6, pageObjs[0])
+
+	// Part 7: the remaining pages and their private objects.
+	for i := 1; i < len(pageObjs); i++ {
+		var objs []int
+		pageRefs := IntSet{}
+		for _, objNr := range pageObjs[i] {
+			pageRefs[objNr] = true
+			if objNr == l.pages[i] || l.refs[objNr] == 1 {
+				objs = append(objs, objNr)
+			}
+		}
+		var part []int
+		l.assign(&part, objs)
+		l.pageObjs = append(l.pageObjs, part)
+		l.pageRefs = append(l.pageRefs, pageRefs)
+	}
+
+	// Part 8: objects shared by the remaining pages.
+	for i := 1; i < len(pageObjs); i++ {
+		l.assign(&l.shared, pageObjs[i])
+	}
+
+	// Part 9: everything else.
+	visited = IntSet{}
+	objs = l.closure(*ctx.Root, visited, nil, false)
+	if ctx.Info != nil {
+		objs = l.closure(*ctx.Info, visited, objs, false)
+	}
+	l.assign(&l.other, objs)
+
+	return nil
+}
+
+// renumber assigns new object numbers.
+// The main section comes first, followed by the first page section
+// starting with the linearization dict and ending with the hint stream.
+func (l *linearization) renumber() (linNr, hintNr int) {
+
+	l.lookup = map[int]int{}
+
+	objNr := 1
+	number := func(objs []int) {
+		for _, i := range objs {
+			l.lookup[i] = objNr
+			objNr++
+		}
+	}
+
+	for _, objs := range l.pageObjs {
+		number(objs)
+	}
+	number(l.shared)
+	number(l.other)
+
+	linNr = objNr
+	objNr++
+
+	number(l.part4)
+	number(l.part6)
+
+	return linNr, objNr
+}
+
+// copyObject returns a deep copy of o using the new object numbers.
+// References to missing objects are replaced by null.
+func (l *linearization) copyObject(o PDFObject) PDFObject {
+
+	switch o := o.(type) {
+
+	case PDFIndirectRef:
+		objNr, found := l.lookup[o.ObjectNumber.Value()]
+		if !found {
+			return nil
+		}
+		return *NewPDFIndirectRef(objNr, 0)
+
+	case PDFDict:
+		d := NewPDFDict()
+		for k, v := range o.Dict {
+			d.Dict[k] = l.copyObject(v)
+		}
+		return d
+
+	case PDFArray:
+		arr := PDFArray{}
+		for _, v := range o {
+			arr = append(arr, l.copyObject(v))
+		}
+		return arr
+
+	}
+
+	return o
+}
+
+// serialize renders the object with object number objNr using its new object number.
+func (l *linearization) serialize(objNr int) ([]byte, error) {
+
+	entry, found := l.ctx.FindTableEntryLight(objNr)
+	if !found || entry.Object == nil {
+		return nil, errors.Errorf("linearize: missing obj#%d", objNr)
+	}
+
+	if err := l.ctx.ensureStreamLoaded(entry, objNr); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%d 0 obj%s", l.lookup[objNr], l.eol)
+
+	if sd, ok := entry.Object.(PDFStreamDict); ok {
+		d := l.copyObject(sd.PDFDict).(PDFDict)
+		d.Update("Length", PDFInteger(len(sd.Raw)))
+		fmt.Fprintf(&buf, "%s%sstream%s", d.PDFString(), l.eol, l.eol)
+		buf.Write(sd.Raw)
+		buf.WriteString("endstream")
+	} else {
+		o := l.copyObject(entry.Object)
+		if o == nil {
+			buf.WriteString("null")
+		} else {
+			buf.WriteString(o.PDFString())
+		}
+	}
+
+	fmt.Fprintf(&buf, "%sendobj%s", l.eol, l.eol)
+
+	return buf.Bytes(), nil
+}
+
+// length returns the total length of the serialized objects.
+func (l *linearization) length(objs []int) int {
+	n := 0
+	for _, objNr := range objs {
+		n += len(l.objs[objNr])
+	}
+	return n
+}
+
+// bitWriter writes the bit packed values of hint tables, see F.4.
+type bitWriter struct {
+	buf bytes.Buffer
+	b   byte
+	n   uint
+}
+
+func (w *bitWriter) write(v int, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		w.b = w.b<<1 | byte(v>>uint(i)&1)
+		w.n++
+		if w.n == 8 {
+			w.buf.WriteByte(w.b)
+			w.b, w.n = 0, 0
+		}
+	}
+}
+
+// flush pads the current byte with zero bits.
+func (w *bitWriter) flush() {
+	if w.n > 0 {
+		w.buf.WriteByte(w.b << (8 - w.n))
+		w.b, w.n = 0, 0
+	}
+}
+
+// bitReader reads the bit packed values of hint tables.
+type bitReader struct {
+	b   []byte
+	pos uint // bit position
+}
+
+func (r *bitReader) read(bits int) (int, error) {
+	v := 0
+	for i := 0; i < bits; i++ {
+		if int(r.pos/8) >= len(r.b) {
+			return 0, errors.New("unexpected end of hint table")
+		}
+		v = v<<1 | int(r.b[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v, nil
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) / 8 * 8
+}
+
+// bitsNeeded returns the number of bits needed to represent i.
+func bitsNeeded(i int) int {
+	n := 0
+	for ; i > 0; i >>= 1 {
+		n++
+	}
+	return n
+}
+
+func minMax(vv []int) (int, int) {
+	min, max := vv[0], vv[0]
+	for _, v := range vv {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	return min, max
+}
+
+// hintStream returns the content of the primary hint stream and the offset of the shared object hint table.
+// page1 is the offset of the first page's page dict and main the offset of the main section
+// ignoring the hint stream as required by F.4.
+func (l *linearization) hintStream(page1, main int) ([]byte, int) {
+
+	// Page offset hint table, see F.4.1
+
+	nobjs := []int{len(l.part6)}
+	lengths := []int{l.length(l.part6)}
+	sharedRefs := [][]int{nil}
+
+	sharedID := map[int]int{}
+	for i, objNr := range l.shared {
+		sharedID[objNr] = len(l.part6) + i
+	}
+
+	for i, objs := range l.pageObjs {
+		nobjs = append(nobjs, len(objs))
+		lengths = append(lengths, l.length(objs))
+		var ids []int
+		for _, objNr := range l.shared {
+			if l.pageRefs[i][objNr] {
+				ids = append(ids, sharedID[objNr])
+			}
+		}
+		sharedRefs = append(sharedRefs, ids)
+	}
+
+	minObjs, maxObjs := minMax(nobjs)
+	minLen, maxLen := minMax(lengths)
+
+	maxRefs, maxID := 0, 0
+	for _, ids := range sharedRefs {
+		if len(ids) > maxRefs {
+			maxRefs = len(ids)
+		}
+		for _, id := range ids {
+			if id > maxID {
+				maxID = id
+			}
+		}
+	}
+
+	objBits := bitsNeeded(maxObjs - minObjs)
+	lenBits := bitsNeeded(maxLen - minLen)
+	refBits := bitsNeeded(maxRefs)
+	idBits := bitsNeeded(maxID)
+
+	w := &bitWriter{}
+
+	w.write(minObjs, 32)
+	w.write(page1, 32)
+	w.write(objBits, 16)
+	w.write(minLen, 32)
+	w.write(lenBits, 16)
+	w.write(0, 32) // least content stream offset
+	w.write(0, 16)
+	w.write(minLen, 32) // least content stream length
+	w.write(lenBits, 16)
+	w.write(refBits, 16)
+	w.write(idBits, 16)
+	w.write(0, 16) // numerator bits
+	w.write(1, 16) // denominator
+
+	for _, n := range nobjs {
+		w.write(n-minObjs, objBits)
+	}
+	w.flush()
+
+	for _, n := range lengths {
+		w.write(n-minLen, lenBits)
+	}
+	w.flush()
+
+	for _, ids := range sharedRefs {
+		w.write(len(ids), refBits)
+	}
+	w.flush()
+
+	for _, ids := range sharedRefs {
+		for _, id := range ids {
+			w.write(id, idBits)
+		}
+	}
+	w.flush()
+
+	// Content stream offsets use 0 bits.
+
+	for _, n := range lengths {
+		w.write(n-minLen, lenBits)
+	}
+	w.flush()
+
+	// Shared object hint table, see F.4.2
+
+	s := w.buf.Len()
+
+	var groups []int
+	for _, objNr := range l.part6 {
+		groups = append(groups, len(l.objs[objNr]))
+	}
+	for _, objNr := range l.shared {
+		groups = append(groups, len(l.objs[objNr]))
+	}
+
+	firstObjNr, firstOffset := 0, 0
+	if len(l.shared) > 0 {
+		firstObjNr = l.lookup[l.shared[0]]
+		firstOffset = main
+		for _, objs := range l.pageObjs {
+			firstOffset += l.length(objs)
+		}
+	}
+
+	minGroup, maxGroup := 0, 0
+	if len(groups) > 0 {
+		minGroup, maxGroup = minMax(groups)
+	}
+	groupBits := bitsNeeded(maxGroup - minGroup)
+
+	w.write(firstObjNr, 32)
+	w.write(firstOffset, 32)
+	w.write(len(l.part6), 32)
+	w.write(len(groups), 32)
+	w.write(0, 16) // every group consists of a single object
+	w.write(minGroup, 32)
+	w.write(groupBits, 16)
+
+	for _, n := range groups {
+		w.write(n-minGroup, groupBits)
+	}
+	w.flush()
+
+	// No signatures.
+	for range groups {
+		w.write(0, 1)
+	}
+	w.flush()
+
+	return w.buf.Bytes(), s
+}
+
+// padded renders d with enough trailing blanks to take up the space of the widest possible rendering.
+func padded(d, widest PDFDict) string {
+	s := d.PDFString()
+	return s + string(bytes.Repeat([]byte{' '}, len(widest.PDFString())-len(s)))
+}
+
+func (l *linearization) xrefEntry(offset int) string {
+	return fmt.Sprintf("%010d %05d n%2s", offset, 0, l.eol)
+}
+
+func (l *linearization) write() ([]byte, error) {
+
+	ctx := l.ctx
+
+	if err := l.partition(); err != nil {
+		return nil, err
+	}
+
+	linNr, hintNr := l.renumber()
+
+	l.objs = map[int][]byte{}
+	for objNr := range l.lookup {
+		bb, err := l.serialize(objNr)
+		if err != nil {
+			return nil, err
+		}
+		l.objs[objNr] = bb
+	}
+
+	var buf bytes.Buffer
+
+	v := V17
+	if ctx.Version() == V20 {
+		v = V20
+	}
+	fmt.Fprintf(&buf, "%%PDF-%s%s%%\xe2\xe3\xcf\xd3%s", VersionString(v), l.eol, l.eol)
+
+	// Part 2: Linearization parameter dict.
+
+	const max = 9999999999
+
+	linDict := func(fileLen, hintOff, hintLen, page1, end, mainXRef int) PDFDict {
+		d := NewPDFDict()
+		d.Insert("Linearized", PDFInteger(1))
+		d.Insert("L", PDFInteger(fileLen))
+		d.Insert("H", NewIntegerArray(hintOff, hintLen))
+		d.Insert("O", PDFInteger(page1))
+		d.Insert("E", PDFInteger(end))
+		d.Insert("N", PDFInteger(ctx.PageCount))
+		d.Insert("T", PDFInteger(mainXRef))
+		return d
+	}
+
+	linOff := buf.Len()
+	linWidest := linDict(max, max, max, max, max, max)
+	linLen := len(fmt.Sprintf("%d 0 obj%s%s%sendobj%s", linNr, l.eol, linWidest.PDFString(), l.eol, l.eol))
+
+	// Part 3: First page cross-reference section.
+
+	firstPageObjs := 1 + len(l.part4) + len(l.part6) + 1
+
+	trailer := func(prev int) PDFDict {
+		d := NewPDFDict()
+		d.Insert("Size", PDFInteger(hintNr+1))
+		d.Insert("Root", *NewPDFIndirectRef(l.lookup[ctx.Root.ObjectNumber.Value()], 0))
+		if ctx.Info != nil {
+			if objNr, found := l.lookup[ctx.Info.ObjectNumber.Value()]; found {
+				d.Insert("Info", *NewPDFIndirectRef(objNr, 0))
+			}
+		}
+		if ctx.ID != nil {
+			d.Insert("ID", *ctx.ID)
+		}
+		d.Insert("Prev", PDFInteger(prev))
+		return d
+	}
+
+	xrefOff := linOff + linLen
+	xrefHeader := fmt.Sprintf("xref%s%d %d%s", l.eol, linNr, firstPageObjs, l.eol)
+	trailerWidest := trailer(max)
+	xrefLen := len(xrefHeader) + firstPageObjs*20 +
+		len(fmt.Sprintf("trailer%s%s%sstartxref%s0%s%%%%EOF%s", l.eol, trailerWidest.PDFString(), l.eol, l.eol, l.eol, l.eol))
+
+	// Part 4 and Part 6
+
+	offsets := map[int]int{}
+	off := xrefOff + xrefLen
+	for _, objNr := range append(append([]int{}, l.part4...), l.part6...) {
+		offsets[objNr] = off
+		off += len(l.objs[objNr])
+	}
+	end := off
+
+	// Part 5: Hint stream
+
+	page1 := offsets[l.pages[0]]
+	content, s := l.hintStream(page1, end)
+
+	d := NewPDFDict()
+	d.Insert("Length", PDFInteger(len(content)))
+	d.Insert("S", PDFInteger(s))
+	hint := []byte(fmt.Sprintf("%d 0 obj%s%s%sstream%s", hintNr, l.eol, d.PDFString(), l.eol, l.eol))
+	hint = append(hint, content...)
+	hint = append(hint, []byte(fmt.Sprintf("endstream%sendobj%s", l.eol, l.eol))...)
+
+	hintOff := end
+	off += len(hint)
+
+	// Part 7, 8 and 9
+
+	var main []int
+	for _, objs := range l.pageObjs {
+		main = append(main, objs...)
+	}
+	main = append(append(main, l.shared...), l.other...)
+
+	for _, objNr := range main {
+		offsets[objNr] = off
+		off += len(l.objs[objNr])
+	}
+
+	// Part 11: Main cross-reference section
+
+	mainXRefOff := off
+	mainXRef := fmt.Sprintf("xref%s0 %d%s", l.eol, linNr, l.eol)
+	fileLen := mainXRefOff + len(mainXRef) + 20*linNr +
+		len(fmt.Sprintf("trailer%s<</Size %d>>%sstartxref%s%d%s%%%%EOF%s", l.eol, linNr, l.eol, l.eol, xrefOff, l.eol, l.eol))
+
+	// The hint tables use 32 bit offsets, see F.4
+	if int64(fileLen) > math.MaxUint32 {
+		return nil, errors.Errorf("linearize: file size %d exceeds 4GB", fileLen)
+	}
+
+	// Now everything is in place.
+
+	linPDFString := padded(linDict(fileLen, hintOff, len(hint), l.lookup[l.pages[0]], end, mainXRefOff+len(mainXRef)-1), linWidest)
+	fmt.Fprintf(&buf, "%d 0 obj%s%s%sendobj%s", linNr, l.eol, linPDFString, l.eol, l.eol)
+
+	buf.WriteString(xrefHeader)
+	buf.WriteString(l.xrefEntry(linOff))
+	for _, objNr := range append(append([]int{}, l.part4...), l.part6...) {
+		buf.WriteString(l.xrefEntry(offsets[objNr]))
+	}
+	buf.WriteString(l.xrefEntry(hintOff))
+	fmt.Fprintf(&buf, "trailer%s%s%sstartxref%s0%s%%%%EOF%s", l.eol, padded(trailer(mainXRefOff), trailerWidest), l.eol, l.eol, l.eol, l.eol)
+
+	for _, objNr := range append(append([]int{}, l.part4...), l.part6...) {
+		buf.Write(l.objs[objNr])
+	}
+
+	buf.Write(hint)
+
+	for _, objNr := range main {
+		buf.Write(l.objs[objNr])
+	}
+
+	byObjNr := make([]int, linNr)
+	for _, objNr := range main {
+		byObjNr[l.lookup[objNr]] = offsets[objNr]
+	}
+
+	buf.WriteString(mainXRef)
+	buf.WriteString(fmt.Sprintf("%010d %05d f%2s", 0, 65535, l.eol))
+	for _, offset := range byObjNr[1:] {
+		buf.WriteString(l.xrefEntry(offset))
+	}
+	fmt.Fprintf(&buf, "trailer%s<</Size %d>>%sstartxref%s%d%s%%%%EOF%s", l.eol, linNr, l.eol, l.eol, xrefOff, l.eol, l.eol)
+
+	if buf.Len() != fileLen {
+		return nil, errors.Errorf("linearize: file length %d, expected %d", buf.Len(), fileLen)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteLinearizedFile generates a linearized PDF file (aka "fast web view") for the cross reference table contained in PDFContext.
+func WriteLinearizedFile(ctx *PDFContext) error {
+
+	fileName := ctx.Write.DirName + ctx.Write.FileName
+
+	log.Info.Printf("writing linearized to %s\n", fileName)
+
+	if ctx.Encrypt != nil {
+		return errors.New("linearize: please decrypt this file first")
+	}
+
+	if ctx.PageCount == 0 {
+		return errors.New("linearize: no pages")
+	}
+
+	// Update the document info dict like WritePDFFile does.
+	if err := syncMetadata(ctx); err != nil {
+		return err
+	}
+
+	eol := ctx.Write.Eol
+	if eol == "" {
+		eol = EolLF
+	}
+
+	l := &linearization{ctx: ctx, eol: eol, assigned: IntSet{}}
+
+	bb, err := l.write()
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.writeFile(fileName, bb); err != nil {
+		return errors.Wrapf(err, "can't write %s", fileName)
+	}
+
+	ctx.Write.FileSize = int64(len(bb))
+
+	return nil
+}
+
+var (
+	firstObj  = regexp.MustCompile(`^%PDF-\d\.\d[^\r\n]*[\r\n]+(%[^\r\n]*[\r\n]+)*\s*(\d+)\s+(\d+)\s+obj`)
+	startXRef = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	xrefEntry = regexp.MustCompile(`^\d{10} \d{5} [fn]`)
+)
+
+func (l *linearization) hintProblems(d PDFDict, page1 int) []string {
+
+	ctx := l.ctx
+
+	arr := d.PDFArrayEntry("H")
+	if arr == nil || len(*arr) < 2 {
+		return []string{"linearization dict: missing H"}
+	}
+	hintOff, ok := (*arr)[0].(PDFInteger)
+	if !ok {
+		return []string{"linearization dict: invalid H"}
+	}
+
+	var hintNr int
+	for objNr, entry := range ctx.Table {
+		if entry != nil && !entry.Free && !entry.Compressed && entry.Offset != nil && *entry.Offset == int64(hintOff) {
+			hintNr = objNr
+		}
+	}
+	if hintNr == 0 {
+		return []string{fmt.Sprintf("hint stream: no object at offset %d", hintOff)}
+	}
+
+	sd, err := ctx.DereferenceStreamDict(*NewPDFIndirectRef(hintNr, 0))
+	if err != nil || sd == nil {
+		return []string{fmt.Sprintf("hint stream: obj#%d is no stream", hintNr)}
+	}
+
+	s := *sd
+	if err = l.ctx.decodeStream(&s); err != nil {
+		return []string{fmt.Sprintf("hint stream: %v", err)}
+	}
+
+	var ss []string
+
+	if i := sd.IntEntry("S"); i == nil || *i >= len(s.Content) {
+		ss = append(ss, "hint stream: missing or invalid shared object hint table offset S")
+	}
+
+	// Offsets in hint tables ignore the hint stream itself.
+	if hintLen, ok := (*arr)[1].(PDFInteger); ok && page1 > int(hintOff) {
+		page1 -= hintLen.Value()
+	}
+
+	r := &bitReader{b: s.Content}
+	if _, err := r.read(32); err != nil {
+		return append(ss, fmt.Sprintf("page offset hint table: %v", err))
+	}
+	// Some writers point to the start of the first page section instead of the page object.
+	if off, err := r.read(32); err != nil || off > page1 {
+		ss = append(ss, fmt.Sprintf("page offset hint table: first page at %d, page object at %d", off, page1))
+	}
+
+	return ss
+}
+
+// LinearizationProblems checks if the file bb read into ctx is validly linearized.
+// An empty result means the file qualifies for fast web view.
+func LinearizationProblems(ctx *PDFContext, bb []byte) []string {
+
+	m := firstObj.FindSubmatch(bb)
+	if m == nil {
+		return []string{"missing first object"}
+	}
+
+	linNr, _ := strconv.Atoi(string(m[2]))
+	if !ctx.LinearizationObjs[linNr] {
+		return []string{"first object is no linearization dict"}
+	}
+
+	d, err := ctx.DereferenceDict(*NewPDFIndirectRef(linNr, 0))
+	if err != nil || d == nil {
+		return []string{"missing linearization dict"}
+	}
+
+	var ss []string
+
+	intEntry := func(key string) int {
+		i := d.IntEntry(key)
+		if i == nil {
+			ss = append(ss, fmt.Sprintf("linearization dict: missing %s", key))
+			return -1
+		}
+		return *i
+	}
+
+	if l := intEntry("L"); l >= 0 && l != len(bb) {
+		ss = append(ss, fmt.Sprintf("linearization dict: L=%d, file length is %d", l, len(bb)))
+	}
+
+	pageCount := ctx.PageCount
+	if pageCount == 0 {
+		// ctx has not been validated.
+		if d, err := ctx.DereferenceDict(ctx.RootDict.Dict["Pages"]); err == nil && d != nil && d.IntEntry("Count") != nil {
+			pageCount = *d.IntEntry("Count")
+		}
+	}
+
+	if n := intEntry("N"); n >= 0 && n != pageCount {
+		ss = append(ss, fmt.Sprintf("linearization dict: N=%d, page count is %d", n, pageCount))
+	}
+
+	page1 := -1
+	if indRef, err := ctx.PageDictIndRef(1); err == nil && indRef != nil {
+		objNr := indRef.ObjectNumber.Value()
+		if o := intEntry("O"); o >= 0 && o != objNr {
+			ss = append(ss, fmt.Sprintf("linearization dict: O=%d, first page is obj#%d", o, objNr))
+		}
+		if entry, found := ctx.FindTableEntryLight(objNr); found && entry.Offset != nil && !entry.Compressed {
+			page1 = int(*entry.Offset)
+		}
+	}
+
+	e := intEntry("E")
+	if e >= 0 && (e > len(bb) || page1 < 0 || page1 >= e) {
+		ss = append(ss, fmt.Sprintf("linearization dict: E=%d does not end the first page section", e))
+	}
+
+	if t := intEntry("T"); t >= 0 {
+		if t+21 > len(bb) {
+			ss = append(ss, fmt.Sprintf("linearization dict: T=%d out of range", t))
+		} else if !ctx.Read.UsingXRefStreams && !xrefEntry.Match(bb[t+1:]) {
+			ss = append(ss, fmt.Sprintf("linearization dict: T=%d does not point to the main cross-reference table", t))
+		}
+	}
+
+	l := &linearization{ctx: ctx}
+	ss = append(ss, l.hintProblems(*d, page1)...)
+
+	if m := startXRef.FindSubmatch(bb); m != nil {
+		if off, _ := strconv.Atoi(string(m[1])); e >= 0 && off >= e {
+			ss = append(ss, "startxref does not point to the first page cross-reference section")
+		}
+	}
+
+	if ctx.Read.XRefSections > 2 {
+		ss = append(ss, "file has been updated after linearization")
+	}
+
+	return ss
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// LinkTarget specifies what happens when a link annotation gets activated.
+// A link either resolves URI or jumps to Page.
+type LinkTarget struct {
+	URI  string
+	Page int     // destination page if URI is empty
+	Zoom float64 // magnification of the destination page, 0 retains the current zoom
+}
+
+// Link represents a link annotation.
+type Link struct {
+	Rect   types.Rectangle
+	Target LinkTarget
+}
+
+// urlPattern matches URLs within extracted text.
+var urlPattern = regexp.MustCompile(`(?i)\b(?:(?:https?|ftp)://|www\.)[^\s<>"'()\[\]{}]+`)
+
+func parseLinkRect(v string, l *Link) error {
+
+	ss := strings.Fields(v)
+	if len(ss) != 4 {
+		return errors.Errorf("invalid link rect: %s", v)
+	}
+
+	var ff [4]float64
+	for i, s := range ss {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.Errorf("invalid link rect: %s", v)
+		}
+		ff[i] = f
+	}
+
+	if ff[2] <= ff[0] || ff[3] <= ff[1] {
+		return errors.Errorf("invalid link rect: %s", v)
+	}
+
+	l.Rect = types.NewRectangle(ff[0], ff[1], ff[2], ff[3])
+
+	return nil
+}
+
+// ParseLinkDetails parses a link command string into an internal structure.
+// The first entry is either a URI or a destination page number followed by entries
+// "rect:llx lly urx ury" and optionally "zoom:percent".
+func ParseLinkDetails(s string) (*Link, error) {
+
+	ss := strings.Split(s, ",")
+
+	l := &Link{}
+
+	t := strings.TrimSpace(ss[0])
+	if len(t) == 0 {
+		return nil, errors.New("missing link target")
+	}
+
+	if i, err := strconv.Atoi(t); err == nil {
+		if i <= 0 {
+			return nil, errors.Errorf("invalid link destination page: %s", t)
+		}
+		l.Target.Page = i
+	} else {
+		l.Target.URI = t
+	}
+
+	var rectSet bool
+
+	for _, s := range ss[1:] {
+
+		ss1 := strings.SplitN(s, ":", 2)
+		if len(ss1) != 2 {
+			return nil, errors.Errorf("invalid link configuration: %s", s)
+		}
+
+		k := strings.TrimSpace(ss1[0])
+		v := strings.TrimSpace(ss1[1])
+
+		switch k {
+
+		case "rect":
+			if err := parseLinkRect(v, l); err != nil {
+				return nil, err
+			}
+			rectSet = true
+
+		case "zoom":
+			z, err := strconv.ParseFloat(v, 64)
+			if err != nil || z < 0 {
+				return nil, errors.Errorf("invalid link zoom: %s", v)
+			}
+			l.Target.Zoom = z / 100
+
+		default:
+			return nil, errors.Errorf("invalid link configuration: %s", s)
+		}
+	}
+
+	if !rectSet {
+		return nil, errors.New("missing link rect")
+	}
+
+	if l.Target.URI != "" && l.Target.Zoom > 0 {
+		return nil, errors.New("zoom applies to page destinations only")
+	}
+
+	return l, nil
+}
+
+// linkDict creates a borderless link annotation dict for rect.
+func linkDict(xRefTable *XRefTable, r types.Rectangle, t LinkTarget) (*PDFDict, error) {
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Annot")
+	d.InsertName("Subtype", "Link")
+	d.Insert("Rect", NewRectangle(r.LL.X, r.LL.Y, r.UR.X, r.UR.Y))
+	d.Insert("Border", NewIntegerArray(0, 0, 0))
+	d.InsertInt("F", annotFlagPrint)
+
+	if t.URI != "" {
+
+		for i := 0; i < len(t.URI); i++ {
+			if t.URI[i] >= 0x80 {
+				return nil, errors.Errorf("URI must be 7-bit ASCII: %s", t.URI)
+			}
+		}
+
+		s, err := Escape(t.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Insert("A", PDFDict{
+			Dict: map[string]PDFObject{
+				"S":   PDFName("URI"),
+				"URI": PDFStringLiteral(*s),
+			},
+		})
+
+		return &d, nil
+	}
+
+	if t.Page < 1 || t.Page > xRefTable.PageCount {
+		return nil, errors.Errorf("invalid link destination page: %d", t.Page)
+	}
+
+	pageIndRef, err := xRefTable.PageDictIndRef(t.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	if pageIndRef == nil {
+		return nil, errors.Errorf("link destination page %d not found", t.Page)
+	}
+
+	// Keep the current position and set the magnification, 0 retains the current zoom.
+	d.Insert("Dest", PDFArray{*pageIndRef, PDFName("XYZ"), nil, nil, PDFFloat(t.Zoom)})
+
+	return &d, nil
+}
+
+// AddLink adds a link annotation to a page.
+func AddLink(xRefTable *XRefTable, page int, r types.Rectangle, t LinkTarget) error {
+
+	pageDict, _, err := xRefTable.PageDict(page)
+	if err != nil {
+		return err
+	}
+
+	if pageDict == nil {
+		return errors.Errorf("page %d not found", page)
+	}
+
+	d, err := linkDict(xRefTable, r, t)
+	if err != nil {
+		return err
+	}
+
+	if xRefTable.TagContent {
+		// Describe the link target for assistive technology.
+		alt := encodeText("Link to " + t.URI)
+		if t.URI == "" {
+			alt = encodeText(fmt.Sprintf("Link to page %d", t.Page))
+		}
+		d.Insert("Contents", alt)
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*d)
+	if err != nil {
+		return err
+	}
+
+	if xRefTable.TagContent {
+		if err = newTagger(xRefTable).tagAnnotation(page, *indRef, d, "Link", d.Dict["Contents"]); err != nil {
+			return err
+		}
+	}
+
+	return appendToArrayEntry(xRefTable, pageDict, "Annots", *indRef)
+}
+
+// linkRects returns the rectangles of the link annotations of a page.
+func linkRects(xRefTable *XRefTable, pageDict *PDFDict) ([][]float64, error) {
+
+	arr, err := pageAnnotations(xRefTable, pageDict)
+	if err != nil {
+		return nil, err
+	}
+
+	var rr [][]float64
+
+	for _, v := range arr {
+
+		d, err := xRefTable.DereferenceDict(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		if st := d.NameEntry("Subtype"); st == nil || *st != "Link" {
+			continue
+		}
+
+		r, err := widgetRect(xRefTable, d)
+		if err != nil {
+			return nil, err
+		}
+
+		if r != nil {
+			rr = append(rr, r)
+		}
+	}
+
+	return rr, nil
+}
+
+// quadRect returns the bounding rectangle of a quadrilateral.
+func quadRect(q [8]float64) types.Rectangle {
+
+	llx, lly := math.MaxFloat64, math.MaxFloat64
+	urx, ury := -math.MaxFloat64, -math.MaxFloat64
+
+	for i := 0; i < 8; i += 2 {
+		llx, lly = math.Min(llx, q[i]), math.Min(lly, q[i+1])
+		urx, ury = math.Max(urx, q[i]), math.Max(ury, q[i+1])
+	}
+
+	return types.NewRectangle(llx, lly, urx, ury)
+}
+
+// runeOffsets returns the rune index for every byte offset of s.
+func runeOffsets(s string) []int {
+
+	offsets := make([]int, len(s)+1)
+
+	var n int
+	for i := range s {
+		offsets[i] = n
+		n++
+	}
+	offsets[len(s)] = n
+
+	return offsets
+}
+
+// pageURLLinks adds link annotations for the URLs found in the text of a page.
+func pageURLLinks(xRefTable *XRefTable, page int) (int, error) {
+
+	lines, err := pageTextLines(xRefTable, page)
+	if err != nil || len(lines) == 0 {
+		return 0, err
+	}
+
+	pageDict, _, err := xRefTable.PageDict(page)
+	if err != nil {
+		return 0, err
+	}
+
+	existing, err := linkRects(xRefTable, pageDict)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+
+	for _, tl := range lines {
+
+		s := tl.String()
+		offsets := runeOffsets(s)
+
+		for _, m := range urlPattern.FindAllStringIndex(s, -1) {
+
+			url := strings.TrimRight(s[m[0]:m[1]], ".,;:!?")
+			if len(url) == 0 {
+				continue
+			}
+
+			r := quadRect(tl.quadPoints(offsets[m[0]], offsets[m[0]+len(url)]))
+
+			// Skip URLs already covered by a link.
+			cx, cy := (r.LL.X+r.UR.X)/2, (r.LL.Y+r.UR.Y)/2
+			var covered bool
+			for _, e := range existing {
+				if cx >= e[0] && cx <= e[2] && cy >= e[1] && cy <= e[3] {
+					covered = true
+					break
+				}
+			}
+			if covered {
+				continue
+			}
+
+			if strings.HasPrefix(strings.ToLower(url), "www.") {
+				url = "http://" + url
+			}
+
+			if err = AddLink(xRefTable, page, r, LinkTarget{URI: url}); err != nil {
+				log.Info.Printf("page %d: skipping %s: %v\n", page, url, err)
+				continue
+			}
+
+			existing = append(existing, []float64{r.LL.X, r.LL.Y, r.UR.X, r.UR.Y})
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// LinkURLs turns the URLs found in the text of selected pages into links and returns the number of links added.
+// URLs already covered by a link are skipped.
+// If selectedPages is empty all pages are processed.
+func LinkURLs(xRefTable *XRefTable, selectedPages IntSet) (int, error) {
+
+	log.Debug.Println("LinkURLs begin")
+
+	var count int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		n, err := pageURLLinks(xRefTable, i)
+		if err != nil {
+			return 0, errors.Wrap(err, "LinkURLs")
+		}
+
+		count += n
+	}
+
+	log.Debug.Println("LinkURLs end")
+
+	return count, nil
+}
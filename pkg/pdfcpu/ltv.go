@@ -0,0 +1,664 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with long term validation (LTV) of signatures
+// using the document security store (DSS), see 12.8.4.3 and ETSI EN 319 142-1 5.4.
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+var oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+var x509SignatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+	"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+	"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+	"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+	"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+	"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+	"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+	"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+}
+
+type ocspCertID struct {
+	HashAlgorithm  algorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	RequestList []ocspSingleRequest
+}
+
+type ocspSingleRequest struct {
+	ReqCert ocspCertID
+}
+
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicOCSPResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm algorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Raw         asn1.RawContent
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	CertStatus asn1.RawValue
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// validationData holds the DER encoded certificates, OCSP responses and CRLs needed to validate a signature.
+type validationData struct {
+	certs, ocsps, crls [][]byte
+}
+
+func httpBody(resp *http.Response, err error) ([]byte, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s: %s", resp.Request.URL, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ocspCertificateID returns the OCSP identifier of cert issued by issuer, see RFC 6960 4.1.1.
+func ocspCertificateID(cert, issuer *x509.Certificate) (*ocspCertID, error) {
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, errors.Wrap(err, "corrupt issuer public key")
+	}
+
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+
+	return &ocspCertID{
+		HashAlgorithm:  algorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+		IssuerNameHash: nameHash[:],
+		IssuerKeyHash:  keyHash[:],
+		SerialNumber:   cert.SerialNumber,
+	}, nil
+}
+
+// checkOCSPResponse verifies an OCSP response for cert issued by issuer and checks the certificate status.
+func checkOCSPResponse(b []byte, cert, issuer *x509.Certificate) error {
+
+	var resp ocspResponse
+	if _, err := asn1.Unmarshal(b, &resp); err != nil {
+		return errors.Wrap(err, "corrupt OCSP response")
+	}
+
+	// successful
+	if resp.Status != 0 {
+		return errors.Errorf("OCSP request rejected: status %d", resp.Status)
+	}
+
+	if !resp.ResponseBytes.ResponseType.Equal(oidOCSPBasic) {
+		return errors.Errorf("unsupported OCSP response type: %v", resp.ResponseBytes.ResponseType)
+	}
+
+	var br basicOCSPResponse
+	if _, err := asn1.Unmarshal(resp.ResponseBytes.Response, &br); err != nil {
+		return errors.Wrap(err, "corrupt OCSP response")
+	}
+
+	// The responder is either the issuer or delegated by the issuer.
+	responder := issuer
+
+	if len(br.Certificates) > 0 {
+
+		c, err := x509.ParseCertificate(br.Certificates[0].FullBytes)
+		if err != nil {
+			return errors.Wrap(err, "corrupt OCSP responder certificate")
+		}
+
+		if !bytes.Equal(c.Raw, issuer.Raw) {
+
+			delegated := false
+			for _, ku := range c.ExtKeyUsage {
+				if ku == x509.ExtKeyUsageOCSPSigning {
+					delegated = true
+				}
+			}
+
+			if !delegated || c.CheckSignatureFrom(issuer) != nil {
+				return errors.New("OCSP responder not authorized by issuer")
+			}
+
+			responder = c
+		}
+	}
+
+	alg, ok := x509SignatureAlgorithms[br.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return errors.Errorf("unsupported OCSP signature algorithm: %v", br.SignatureAlgorithm.Algorithm)
+	}
+
+	if err := responder.CheckSignature(alg, br.TBSResponseData.Raw, br.Signature.RightAlign()); err != nil {
+		return errors.Wrap(err, "invalid OCSP response")
+	}
+
+	for _, r := range br.TBSResponseData.Responses {
+
+		if r.CertID.SerialNumber == nil || r.CertID.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+
+		switch r.CertStatus.Tag {
+		case 0:
+			return nil
+		case 1:
+			return errors.Errorf("certificate revoked: %s", cert.Subject)
+		}
+
+		return errors.Errorf("certificate status unknown: %s", cert.Subject)
+	}
+
+	return errors.New("OCSP response does not match request")
+}
+
+// fetchOCSP requests an OCSP response for cert from the OCSP responders named in cert.
+func fetchOCSP(cert, issuer *x509.Certificate) ([]byte, error) {
+
+	if len(cert.OCSPServer) == 0 {
+		return nil, errors.New("missing OCSP responder")
+	}
+
+	id, err := ocspCertificateID(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := asn1.Marshal(ocspRequest{TBSRequest: ocspTBSRequest{RequestList: []ocspSingleRequest{{ReqCert: *id}}}})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, url := range cert.OCSPServer {
+
+		var b []byte
+		b, err = httpBody(http.Post(url, "application/ocsp-request", bytes.NewReader(req)))
+		if err == nil {
+			if err = checkOCSPResponse(b, cert, issuer); err == nil {
+				return b, nil
+			}
+		}
+
+		log.Debug.Printf("fetchOCSP: %s: %v\n", url, err)
+	}
+
+	return nil, err
+}
+
+// checkCRL verifies a CRL issued by issuer and checks whether cert has been revoked.
+func checkCRL(b []byte, cert, issuer *x509.Certificate) error {
+
+	crl, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return errors.Wrap(err, "corrupt CRL")
+	}
+
+	if err = crl.CheckSignatureFrom(issuer); err != nil {
+		return errors.Wrap(err, "invalid CRL")
+	}
+
+	for _, e := range crl.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return errors.Errorf("certificate revoked: %s", cert.Subject)
+		}
+	}
+
+	return nil
+}
+
+// fetchCRL downloads a CRL for cert from the distribution points named in cert.
+func fetchCRL(cert, issuer *x509.Certificate) ([]byte, error) {
+
+	if len(cert.CRLDistributionPoints) == 0 {
+		return nil, errors.New("missing CRL distribution point")
+	}
+
+	var err error
+
+	for _, url := range cert.CRLDistributionPoints {
+
+		var b []byte
+		b, err = httpBody(http.Get(url))
+		if err == nil {
+			if err = checkCRL(b, cert, issuer); err == nil {
+				return b, nil
+			}
+		}
+
+		log.Debug.Printf("fetchCRL: %s: %v\n", url, err)
+	}
+
+	return nil, err
+}
+
+// addRevocationData adds an OCSP response or else a CRL for cert issued by issuer to vd.
+func addRevocationData(cert, issuer *x509.Certificate, vd *validationData) error {
+
+	if len(cert.OCSPServer) == 0 && len(cert.CRLDistributionPoints) == 0 {
+		log.Debug.Printf("addRevocationData: no revocation information available for %s\n", cert.Subject)
+		return nil
+	}
+
+	b, err := fetchOCSP(cert, issuer)
+	if err == nil {
+		vd.ocsps = append(vd.ocsps, b)
+		return nil
+	}
+
+	if len(cert.CRLDistributionPoints) > 0 {
+		if b, err = fetchCRL(cert, issuer); err == nil {
+			vd.crls = append(vd.crls, b)
+			return nil
+		}
+	}
+
+	return errors.Wrapf(err, "revocation information for %s", cert.Subject)
+}
+
+// validationChain returns the certificate chain of certs[0] up to a trusted root or else as far as certs allow.
+func validationChain(certs []*x509.Certificate, roots *x509.CertPool) []*x509.Certificate {
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+
+	if chains, err := certs[0].Verify(opts); err == nil {
+		return chains[0]
+	}
+
+	chain := []*x509.Certificate{certs[0]}
+
+	for c := certs[0]; len(chain) <= len(certs); {
+
+		var issuer *x509.Certificate
+		for _, ic := range certs {
+			if ic != c && bytes.Equal(ic.RawSubject, c.RawIssuer) && c.CheckSignatureFrom(ic) == nil {
+				issuer = ic
+				break
+			}
+		}
+
+		if issuer == nil {
+			break
+		}
+
+		chain = append(chain, issuer)
+		c = issuer
+	}
+
+	return chain
+}
+
+// addValidationData adds the certificate chain of certs[0] along with its revocation information to vd.
+func addValidationData(certs []*x509.Certificate, roots *x509.CertPool, vd *validationData) error {
+
+	chain := validationChain(certs, roots)
+
+	for i, c := range chain {
+
+		vd.certs = append(vd.certs, c.Raw)
+
+		if i+1 == len(chain) {
+			if !bytes.Equal(c.RawSubject, c.RawIssuer) {
+				log.Debug.Printf("addValidationData: missing issuer of %s\n", c.Subject)
+			}
+			break
+		}
+
+		if err := addRevocationData(c, chain[i+1], vd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// signatureValidationData returns the validation data for the CMS signature sig including its signature time stamp.
+func signatureValidationData(sig []byte, roots *x509.CertPool) (*validationData, error) {
+
+	sd, err := parseSignedData(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := signedDataCertificates(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	vd := &validationData{}
+
+	if err = addValidationData(certs, roots, vd); err != nil {
+		return nil, err
+	}
+
+	si := sd.SignerInfos[0]
+	if len(si.UnsignedAttrs.FullBytes) == 0 {
+		return vd, nil
+	}
+
+	var attrs []attribute
+	if _, err := asn1.UnmarshalWithParams(si.UnsignedAttrs.FullBytes, &attrs, "set,tag:1"); err != nil {
+		return nil, errors.Wrap(err, "corrupt unsigned attributes")
+	}
+
+	v := attributeValue(attrs, oidAttrTimeStampToken)
+	if v == nil {
+		return vd, nil
+	}
+
+	tsd, err := parseSignedData(v.FullBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "signature time stamp")
+	}
+
+	if certs, err = signedDataCertificates(tsd); err != nil {
+		return nil, errors.Wrap(err, "signature time stamp")
+	}
+
+	if err = addValidationData(certs, roots, vd); err != nil {
+		return nil, errors.Wrap(err, "signature time stamp")
+	}
+
+	return vd, nil
+}
+
+// dssStreams manages the streams of a document security store.
+type dssStreams struct {
+	xRefTable *XRefTable
+	refs      map[[sha1.Size]byte]PDFIndirectRef
+}
+
+// register records the streams of the DSS array key in d for reuse.
+func (ds dssStreams) register(d *PDFDict, key string) (PDFArray, error) {
+
+	arr, err := ds.xRefTable.DereferenceArray(d.Dict[key])
+	if err != nil || arr == nil {
+		return PDFArray{}, err
+	}
+
+	for _, o := range *arr {
+
+		ir, ok := o.(PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		sd, err := ds.xRefTable.DereferenceStreamDict(ir)
+		if err != nil || sd == nil {
+			continue
+		}
+
+		if err = ds.xRefTable.decodeStream(sd); err != nil {
+			continue
+		}
+
+		ds.refs[sha1.Sum(sd.Content)] = ir
+	}
+
+	return *arr, nil
+}
+
+// streamRefs returns references to streams holding data and appends newly created streams to arr.
+func (ds dssStreams) streamRefs(data [][]byte, arr *PDFArray) (PDFArray, error) {
+
+	var refs PDFArray
+	seen := map[PDFIndirectRef]bool{}
+
+	for _, b := range data {
+
+		k := sha1.Sum(b)
+
+		ir, ok := ds.refs[k]
+		if !ok {
+
+			sd := &PDFStreamDict{
+				PDFDict:        NewPDFDict(),
+				Content:        b,
+				FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+			sd.InsertName("Filter", filter.Flate)
+
+			if err := encodeStream(sd); err != nil {
+				return nil, err
+			}
+
+			indRef, err := ds.xRefTable.IndRefForNewObject(*sd)
+			if err != nil {
+				return nil, err
+			}
+
+			ir = *indRef
+			ds.refs[k] = ir
+			*arr = append(*arr, ir)
+		}
+
+		if !seen[ir] {
+			refs = append(refs, ir)
+			seen[ir] = true
+		}
+	}
+
+	return refs, nil
+}
+
+// dssDict returns the document security store of the catalog, which gets created if missing.
+func dssDict(xRefTable *XRefTable) (*PDFDict, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	if o, found := rootDict.Find("DSS"); found {
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil || d == nil {
+			return nil, errors.New("corrupt DSS")
+		}
+		return d, nil
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "DSS")
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return nil, err
+	}
+
+	rootDict.Insert("DSS", *indRef)
+
+	return &d, nil
+}
+
+// EnableLTV fetches the certificates and revocation information (OCSP responses or CRLs) needed to validate
+// all signatures and document time stamps of ctx and embeds them into the document security store.
+// The result is appended to orig, the original file, as an incremental update.
+func EnableLTV(ctx *PDFContext, orig []byte, roots *x509.CertPool) ([]byte, error) {
+
+	log.Debug.Println("EnableLTV begin")
+
+	if ctx.Encrypt != nil {
+		return nil, newErrorf(ErrEncrypted, "EnableLTV: encrypted documents are not supported")
+	}
+
+	m, err := signatureDicts(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m) == 0 {
+		return nil, errors.New("EnableLTV: no signatures found")
+	}
+
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snap := ctx.Snapshot()
+
+	d, err := dssDict(ctx.XRefTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnableLTV")
+	}
+
+	ds := dssStreams{xRefTable: ctx.XRefTable, refs: map[[sha1.Size]byte]PDFIndirectRef{}}
+
+	var arrs [3]PDFArray
+	for i, key := range []string{"Certs", "OCSPs", "CRLs"} {
+		if arrs[i], err = ds.register(d, key); err != nil {
+			return nil, errors.Wrap(err, "EnableLTV: corrupt DSS")
+		}
+	}
+
+	vri := NewPDFDict()
+	if o, found := d.Find("VRI"); found {
+		vd, err := ctx.DereferenceDict(o)
+		if err != nil || vd == nil {
+			return nil, errors.New("EnableLTV: corrupt VRI")
+		}
+		vri = *vd
+	}
+
+	for _, name := range names {
+
+		hl := m[name].PDFHexLiteralEntry("Contents")
+		if hl == nil {
+			return nil, errors.Errorf("EnableLTV: %s: missing signature", name)
+		}
+
+		sig, err := hl.Bytes()
+		if err != nil {
+			return nil, errors.Wrapf(err, "EnableLTV: %s", name)
+		}
+
+		vd, err := signatureValidationData(sig, roots)
+		if err != nil {
+			return nil, errors.Wrapf(err, "EnableLTV: %s", name)
+		}
+
+		e := NewPDFDict()
+
+		for i, x := range []struct {
+			key  string
+			data [][]byte
+		}{
+			{"Cert", vd.certs},
+			{"OCSP", vd.ocsps},
+			{"CRL", vd.crls},
+		} {
+			refs, err := ds.streamRefs(x.data, &arrs[i])
+			if err != nil {
+				return nil, err
+			}
+			if len(refs) > 0 {
+				e.Insert(x.key, refs)
+			}
+		}
+
+		e.Insert("TU", DateStringLiteral(time.Now()))
+
+		// The VRI key is the SHA-1 digest of the signature's Contents string.
+		h := sha1.Sum(sig)
+		vri.Update(strings.ToUpper(hex.EncodeToString(h[:])), e)
+	}
+
+	for i, key := range []string{"Certs", "OCSPs", "CRLs"} {
+		if len(arrs[i]) > 0 {
+			d.Update(key, arrs[i])
+		}
+	}
+
+	if _, found := d.Find("VRI"); !found {
+		d.Insert("VRI", vri)
+	}
+
+	b, err := WriteIncrement(ctx, orig, ctx.ModifiedObjects(snap))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("EnableLTV end")
+
+	return b, nil
+}
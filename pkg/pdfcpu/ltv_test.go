@@ -0,0 +1,263 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testOCSPResponse returns an OCSP response for id signed by the issuer.
+func testOCSPResponse(id ocspCertID, issuer *x509.Certificate, key *rsa.PrivateKey, t *testing.T) []byte {
+
+	keyHash := sha1.Sum(issuer.RawSubjectPublicKeyInfo)
+
+	responderID, err := asn1.Marshal(keyHash[:])
+	if err != nil {
+		t.Fatalf("testOCSPResponse: %v\n", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tbs, err := asn1.Marshal(ocspResponseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: responderID},
+		ProducedAt:  now,
+		Responses: []ocspSingleResponse{{
+			CertID:     id,
+			CertStatus: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0},
+			ThisUpdate: now,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("testOCSPResponse: %v\n", err)
+	}
+
+	h := sha256.Sum256(tbs)
+
+	sig, err := NewSigner(key, []*x509.Certificate{issuer}).Sign(h[:])
+	if err != nil {
+		t.Fatalf("testOCSPResponse: %v\n", err)
+	}
+
+	br, err := asn1.Marshal(struct {
+		TBSResponseData    asn1.RawValue
+		SignatureAlgorithm algorithmIdentifier
+		Signature          asn1.BitString
+	}{
+		asn1.RawValue{FullBytes: tbs},
+		algorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue},
+		asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	})
+	if err != nil {
+		t.Fatalf("testOCSPResponse: %v\n", err)
+	}
+
+	b, err := asn1.Marshal(ocspResponse{ResponseBytes: ocspResponseBytes{ResponseType: oidOCSPBasic, Response: br}})
+	if err != nil {
+		t.Fatalf("testOCSPResponse: %v\n", err)
+	}
+
+	return b
+}
+
+func TestEnableLTV(t *testing.T) {
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %v\n", err)
+	}
+
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v\n", err)
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v\n", err)
+	}
+
+	ocspDown := false
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ocsp", func(w http.ResponseWriter, r *http.Request) {
+		if ocspDown {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		var req ocspRequest
+		if _, err := asn1.Unmarshal(b, &req); err != nil || len(req.TBSRequest.RequestList) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write(testOCSPResponse(req.TBSRequest.RequestList[0].ReqCert, ca, caKey, t))
+	})
+
+	mux.HandleFunc("/crl", func(w http.ResponseWriter, r *http.Request) {
+		tmpl := &x509.RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: time.Now().Add(-time.Minute),
+			NextUpdate: time.Now().Add(time.Hour),
+			RevokedCertificateEntries: []x509.RevocationListEntry{
+				{SerialNumber: big.NewInt(99), RevocationTime: time.Now().Add(-time.Minute)},
+			},
+		}
+		b, err := x509.CreateRevocationList(rand.Reader, tmpl, ca, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(b)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %v\n", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "alice"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		OCSPServer:            []string{srv.URL + "/ocsp"},
+		CRLDistributionPoints: []string{srv.URL + "/crl"},
+	}
+
+	der, err = x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v\n", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v\n", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	xRefTable, err := CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("CreateDemoXRef: %v\n", err)
+	}
+
+	fileName := filepath.Join(outDir, "ltv.pdf")
+	if err = CreatePDF(xRefTable, outDir+"/", "ltv.pdf"); err != nil {
+		t.Fatalf("CreatePDF: %v\n", err)
+	}
+
+	// Sign, then embed validation data using OCSP and once more using the CRL.
+	for i, f := range []func(ctx *PDFContext, orig []byte) ([]byte, error){
+		func(ctx *PDFContext, orig []byte) ([]byte, error) {
+			return Sign(ctx, orig, &SignatureAttributes{Page: 1}, NewSigner(key, []*x509.Certificate{cert, ca}))
+		},
+		func(ctx *PDFContext, orig []byte) ([]byte, error) {
+			return EnableLTV(ctx, orig, roots)
+		},
+		func(ctx *PDFContext, orig []byte) ([]byte, error) {
+			ocspDown = true
+			return EnableLTV(ctx, orig, roots)
+		},
+	} {
+
+		orig, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			t.Fatalf("%d: %v\n", i, err)
+		}
+
+		ctx, err := ReadPDFFile(fileName, NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("%d: ReadPDFFile: %v\n", i, err)
+		}
+
+		b, err := f(ctx, orig)
+		if err != nil {
+			t.Fatalf("%d: %v\n", i, err)
+		}
+
+		if err = ioutil.WriteFile(fileName, b, 0644); err != nil {
+			t.Fatalf("%d: %v\n", i, err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	ctx, err := ReadPDFFile(fileName, NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	if err = ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("ValidateXRefTable: %v\n", err)
+	}
+
+	d, err := dssDict(ctx.XRefTable)
+	if err != nil {
+		t.Fatalf("dssDict: %v\n", err)
+	}
+
+	for key, want := range map[string]int{"Certs": 2, "OCSPs": 1, "CRLs": 1} {
+		if arr := d.PDFArrayEntry(key); arr == nil || len(*arr) != want {
+			t.Errorf("DSS %s: want %d entries\n%s\n", key, want, d)
+		}
+	}
+
+	vri, err := ctx.DereferenceDict(d.Dict["VRI"])
+	if err != nil || vri == nil || vri.Len() != 1 {
+		t.Errorf("DSS: want 1 VRI entry\n%s\n", d)
+	}
+
+	srs, err := VerifySignatures(ctx, b, roots)
+	if err != nil {
+		t.Fatalf("VerifySignatures: %v\n", err)
+	}
+
+	if len(srs) != 1 || !srs[0].Valid() {
+		t.Fatalf("VerifySignatures: %v\n", srs)
+	}
+}
@@ -0,0 +1,372 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+)
+
+// Media annotations
+//
+// 3D annotations (13.6) carry a U3D or PRC stream in 3DD, either directly or by a 3D reference dict.
+// RichMedia annotations (Adobe extension level 3) embed their assets like videos, Flash movies or 3D models
+// as file specifications in the Assets name tree of RichMediaContent.
+// Screen annotations play the media clips (13.2.4) referenced by the rendition actions in A and AA.
+
+// MediaObject represents a 3D stream or a media asset of an annotation.
+type MediaObject struct {
+	Page    int
+	ObjNr   int    // object number of the annotation dict, 0 for direct objects
+	Subtype string // annotation subtype: 3D, RichMedia or Screen
+	Name    string // U3D or PRC for 3D streams, the asset name otherwise
+	Data    []byte
+}
+
+// FileName returns the name of the file m gets extracted to.
+func (m MediaObject) FileName() string {
+
+	if m.Subtype == "3D" {
+		return fmt.Sprintf("%d_%d.%s", m.Page, m.ObjNr, strings.ToLower(m.Name))
+	}
+
+	return fmt.Sprintf("%d_%d_%s", m.Page, m.ObjNr, m.Name)
+}
+
+// mediaAsset is an embedded stream of a media annotation.
+type mediaAsset struct {
+	name string
+	sd   *PDFStreamDict
+}
+
+// embeddedFileStream returns the embedded file stream of the file specification o.
+func embeddedFileStream(xRefTable *XRefTable, o PDFObject) (*PDFStreamDict, error) {
+
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		// A file specification string refers to an external file.
+		return nil, nil
+	}
+
+	ef, err := xRefTable.DereferenceDict(d.Dict["EF"])
+	if err != nil || ef == nil {
+		return nil, err
+	}
+
+	return xRefTable.DereferenceStreamDict(ef.Dict["F"])
+}
+
+// processNameTreeDict applies handler to each entry of the name tree rooted at d.
+func processNameTreeDict(xRefTable *XRefTable, d *PDFDict, visited IntSet, handler func(k string, v PDFObject) error) error {
+
+	if arr, err := xRefTable.DereferenceArray(d.Dict["Names"]); err != nil {
+		return err
+	} else if arr != nil {
+		for i := 0; i+1 < len(*arr); i += 2 {
+			k, err := fieldValueString(xRefTable, (*arr)[i])
+			if err != nil {
+				return err
+			}
+			if err = handler(k, (*arr)[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	kids, err := xRefTable.DereferenceArray(d.Dict["Kids"])
+	if err != nil || kids == nil {
+		return err
+	}
+
+	for _, o := range *kids {
+
+		if indRef, ok := o.(PDFIndirectRef); ok {
+			if visited[indRef.ObjectNumber.Value()] {
+				continue
+			}
+			visited[indRef.ObjectNumber.Value()] = true
+		}
+
+		kid, err := xRefTable.DereferenceDict(o)
+		if err != nil || kid == nil {
+			return err
+		}
+
+		if err = processNameTreeDict(xRefTable, kid, visited, handler); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// threeDAssets returns the U3D or PRC stream of a 3D annotation.
+func threeDAssets(xRefTable *XRefTable, d *PDFDict) ([]mediaAsset, error) {
+
+	o, err := xRefTable.Dereference(d.Dict["3DD"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Follow a 3D reference dict to the 3D stream it shares.
+	if d1, ok := o.(PDFDict); ok && nameEntry(xRefTable, &d1, "Type") == "3DRef" {
+		if o, err = xRefTable.Dereference(d1.Dict["3DD"]); err != nil {
+			return nil, err
+		}
+	}
+
+	sd, ok := o.(PDFStreamDict)
+	if !ok {
+		return nil, nil
+	}
+
+	subtype := nameEntry(xRefTable, &sd.PDFDict, "Subtype")
+	if subtype != "U3D" && subtype != "PRC" {
+		log.Info.Printf("threeDAssets: ignoring 3D stream of subtype %s\n", subtype)
+		return nil, nil
+	}
+
+	return []mediaAsset{{name: subtype, sd: &sd}}, nil
+}
+
+// richMediaAssets returns the embedded assets of a RichMedia annotation.
+func richMediaAssets(xRefTable *XRefTable, d *PDFDict) ([]mediaAsset, error) {
+
+	content, err := xRefTable.DereferenceDict(d.Dict["RichMediaContent"])
+	if err != nil || content == nil {
+		return nil, err
+	}
+
+	assets, err := xRefTable.DereferenceDict(content.Dict["Assets"])
+	if err != nil || assets == nil {
+		return nil, err
+	}
+
+	var aa []mediaAsset
+
+	err = processNameTreeDict(xRefTable, assets, IntSet{}, func(k string, v PDFObject) error {
+		sd, err := embeddedFileStream(xRefTable, v)
+		if err != nil {
+			return err
+		}
+		if sd != nil {
+			aa = append(aa, mediaAsset{name: k, sd: sd})
+		}
+		return nil
+	})
+
+	return aa, err
+}
+
+// screenAssets collects the media clips played by rendition actions of a Screen annotation.
+type screenAssets struct {
+	xRefTable *XRefTable
+	visited   IntSet
+	names     StringSet
+	assets    []mediaAsset
+}
+
+// dict dereferences o into a dict unless o refers to an object already visited.
+func (s *screenAssets) dict(o PDFObject) (*PDFDict, error) {
+
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		if s.visited[indRef.ObjectNumber.Value()] {
+			return nil, nil
+		}
+		s.visited[indRef.ObjectNumber.Value()] = true
+	}
+
+	return s.xRefTable.DereferenceDict(o)
+}
+
+// mediaClip collects the embedded file of a media clip data dict or the clip a media clip section refers to.
+func (s *screenAssets) mediaClip(o PDFObject) error {
+
+	d, err := s.dict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	if nameEntry(s.xRefTable, d, "S") == "MCS" {
+		return s.mediaClip(d.Dict["D"])
+	}
+
+	sd, err := embeddedFileStream(s.xRefTable, d.Dict["D"])
+	if err != nil || sd == nil {
+		return err
+	}
+
+	fs, err := s.xRefTable.DereferenceDict(d.Dict["D"])
+	if err != nil {
+		return err
+	}
+
+	name := fileSpecName(s.xRefTable, fs)
+	if !s.names[name] {
+		s.names[name] = true
+		s.assets = append(s.assets, mediaAsset{name: name, sd: sd})
+	}
+
+	return nil
+}
+
+// rendition collects the media clips of a media rendition or the renditions of a selector rendition.
+func (s *screenAssets) rendition(o PDFObject) error {
+
+	d, err := s.dict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	if nameEntry(s.xRefTable, d, "S") == "MR" {
+		return s.mediaClip(d.Dict["C"])
+	}
+
+	arr, err := s.xRefTable.DereferenceArray(d.Dict["R"])
+	if err != nil || arr == nil {
+		return err
+	}
+
+	for _, o := range *arr {
+		if err = s.rendition(o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// action collects the media clips of a rendition action.
+func (s *screenAssets) action(o PDFObject) error {
+
+	d, err := s.xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	if nameEntry(s.xRefTable, d, "S") != "Rendition" {
+		return nil
+	}
+
+	return s.rendition(d.Dict["R"])
+}
+
+// screenAnnotAssets returns the embedded media clips of a Screen annotation.
+func screenAnnotAssets(xRefTable *XRefTable, d *PDFDict) ([]mediaAsset, error) {
+
+	s := &screenAssets{xRefTable: xRefTable, visited: IntSet{}, names: StringSet{}}
+
+	if err := s.action(d.Dict["A"]); err != nil {
+		return nil, err
+	}
+
+	aa, err := xRefTable.DereferenceDict(d.Dict["AA"])
+	if err != nil {
+		return nil, err
+	}
+
+	if aa != nil {
+		for _, k := range []string{"E", "X", "D", "U", "Fo", "Bl", "PO", "PC", "PV", "PI"} {
+			if err = s.action(aa.Dict[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s.assets, nil
+}
+
+// annotationMedia returns the 3D streams or media assets of a 3D, RichMedia or Screen annotation.
+func annotationMedia(xRefTable *XRefTable, d *PDFDict, subtype string) ([]mediaAsset, error) {
+
+	switch subtype {
+
+	case "3D":
+		return threeDAssets(xRefTable, d)
+
+	case "RichMedia":
+		return richMediaAssets(xRefTable, d)
+
+	case "Screen":
+		return screenAnnotAssets(xRefTable, d)
+	}
+
+	return nil, nil
+}
+
+// ExtractMediaData extracts the U3D and PRC streams of 3D annotations
+// and the embedded media assets of RichMedia and Screen annotations for pageNr.
+func ExtractMediaData(xRefTable *XRefTable, pageNr int) ([]MediaObject, error) {
+
+	pageDict, _, err := xRefTable.PageDict(pageNr)
+	if err != nil || pageDict == nil {
+		return nil, err
+	}
+
+	arr, err := pageAnnotations(xRefTable, pageDict)
+	if err != nil {
+		return nil, err
+	}
+
+	var mm []MediaObject
+
+	for _, v := range arr {
+
+		var objNr int
+		if indRef, ok := v.(PDFIndirectRef); ok {
+			objNr = indRef.ObjectNumber.Value()
+		}
+
+		d, err := xRefTable.DereferenceDict(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		subtype := nameEntry(xRefTable, d, "Subtype")
+
+		assets, err := annotationMedia(xRefTable, d, subtype)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range assets {
+
+			// Decode a copy, the document keeps its streams encoded.
+			sd := *a.sd
+
+			err = xRefTable.decodeStreamObj(&sd, objNr)
+			if err == filter.ErrUnsupportedFilter {
+				log.Info.Printf("ExtractMediaData: ignoring %s of obj#%d - unsupported filter\n", a.name, objNr)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			mm = append(mm, MediaObject{Page: pageNr, ObjNr: objNr, Subtype: subtype, Name: a.name, Data: sd.Content})
+		}
+	}
+
+	return mm, nil
+}
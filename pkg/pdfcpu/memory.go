@@ -0,0 +1,134 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/pkg/errors"
+)
+
+// Memory limits
+//
+// Decoding streams and images of untrusted documents may require arbitrary amounts of memory,
+// eg. a few KB of Flate encoded zeros expand to GBs.
+// The limits of the Configuration are enforced while decoding
+// and fail processing with a *MemoryLimitError instead of exhausting the process.
+
+// MemoryLimitError reports processing a document exceeding a memory limit of its Configuration.
+type MemoryLimitError struct {
+	Limit string // The name of the limit as in Configuration.
+	Max   int64  // The value of the limit.
+	ObjNr int    // The object being decoded or 0 if unknown.
+}
+
+func (e *MemoryLimitError) Error() string {
+
+	if e.ObjNr > 0 {
+		return fmt.Sprintf("memory limit exceeded: obj#%d: %s=%d", e.ObjNr, e.Limit, e.Max)
+	}
+
+	return fmt.Sprintf("memory limit exceeded: %s=%d", e.Limit, e.Max)
+}
+
+// IsMemoryLimitError returns true if err has been caused by exceeding a memory limit.
+func IsMemoryLimitError(err error) bool {
+	_, ok := errors.Cause(err).(*MemoryLimitError)
+	return ok
+}
+
+// decodeLimit returns the maximum decoded size for the next stream and the limit in effect.
+func (xRefTable *XRefTable) decodeLimit() (int64, string) {
+
+	max, limit := xRefTable.MaxStreamSize, "MaxStreamSize"
+
+	if xRefTable.MaxDecodedSize > 0 {
+		xRefTable.mu.Lock()
+		remaining := xRefTable.MaxDecodedSize - xRefTable.decodedSize
+		xRefTable.mu.Unlock()
+		if remaining <= 0 {
+			// Keep a limit in effect.
+			remaining = 1
+		}
+		if max <= 0 || remaining < max {
+			max, limit = remaining, "MaxDecodedSize"
+		}
+	}
+
+	return max, limit
+}
+
+func (xRefTable *XRefTable) memoryLimitError(limit string, objNr int) error {
+
+	max := xRefTable.MaxStreamSize
+	if limit == "MaxDecodedSize" {
+		max = xRefTable.MaxDecodedSize
+	}
+
+	return &MemoryLimitError{Limit: limit, Max: max, ObjNr: objNr}
+}
+
+// decodeStream decodes sd and charges the decoded content against the memory limits of xRefTable.
+func (xRefTable *XRefTable) decodeStream(sd *PDFStreamDict) error {
+	return xRefTable.decodeStreamObj(sd, 0)
+}
+
+func (xRefTable *XRefTable) decodeStreamObj(sd *PDFStreamDict, objNr int) error {
+
+	if sd.Content != nil || sd.FilterPipeline == nil {
+		return decodeStream(sd)
+	}
+
+	max, limit := xRefTable.decodeLimit()
+
+	err := decodeStreamLimited(sd, max)
+	if errors.Cause(err) == filter.ErrLimitExceeded {
+		return xRefTable.memoryLimitError(limit, objNr)
+	}
+	if err != nil {
+		return err
+	}
+
+	xRefTable.mu.Lock()
+	xRefTable.decodedSize += int64(len(sd.Content))
+	n := xRefTable.decodedSize
+	xRefTable.mu.Unlock()
+
+	if xRefTable.MaxDecodedSize > 0 && n > xRefTable.MaxDecodedSize {
+		// Concurrent decoding may overcommit the remaining budget.
+		sd.Content = nil
+		return xRefTable.memoryLimitError("MaxDecodedSize", objNr)
+	}
+
+	return nil
+}
+
+// checkImageSize returns a *MemoryLimitError if an image of w x h pixels exceeds the image size limit.
+func (xRefTable *XRefTable) checkImageSize(w, h, objNr int) error {
+
+	max := xRefTable.MaxImagePixels
+	if max <= 0 || w <= 0 || h <= 0 {
+		return nil
+	}
+
+	if int64(w) > max || int64(h) > max/int64(w) {
+		return &MemoryLimitError{Limit: "MaxImagePixels", Max: max, ObjNr: objNr}
+	}
+
+	return nil
+}
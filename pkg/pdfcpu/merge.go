@@ -293,6 +293,12 @@ func mergeDuplicateObjNumberIntSets(ctxSource, ctxDest *PDFContext) {
 // MergeXRefTables merges PDFContext ctxSource into ctxDest by appending its page tree.
 func MergeXRefTables(ctxSource, ctxDest *PDFContext) (err error) {
 
+	// Source objects are moved over to ctxDest, so load any lazily read streams beforehand.
+	err = ctxSource.LoadStreams()
+	if err != nil {
+		return err
+	}
+
 	// Sweep over ctxSource cross ref table and ensure valid object numbers in ctxDest's space.
 	patchSourceObjectNumbers(ctxSource, ctxDest)
 
@@ -0,0 +1,245 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MultiFillConfig controls filling a form template once per CSV record.
+type MultiFillConfig struct {
+	Merge      bool              // Write all filled copies into a single PDF.
+	NameColumn string            // Column providing the names of the filled copies.
+	FieldMap   map[string]string // Maps field names to columns. If nil every column fills the field of the same name.
+}
+
+// FormRecord represents the field values for one filled copy of a form.
+type FormRecord struct {
+	Name   string
+	Values map[string]interface{}
+}
+
+// ParseFieldMap parses a field mapping of the form 'field:column, field:column, ...'.
+func ParseFieldMap(s string) (map[string]string, error) {
+
+	m := map[string]string{}
+
+	for _, s1 := range strings.Split(s, ",") {
+
+		ss := strings.Split(s1, ":")
+		if len(ss) != 2 {
+			return nil, errors.Errorf("invalid field mapping: %s", s1)
+		}
+
+		field, column := strings.TrimSpace(ss[0]), strings.TrimSpace(ss[1])
+		if len(field) == 0 || len(column) == 0 {
+			return nil, errors.Errorf("invalid field mapping: %s", s1)
+		}
+
+		if _, found := m[field]; found {
+			return nil, errors.Errorf("duplicate field mapping: %s", field)
+		}
+
+		m[field] = column
+	}
+
+	return m, nil
+}
+
+// validCopyName returns true if s is usable as name of a filled copy.
+// Copy names are used as file names and as partial field names when merging.
+func validCopyName(s string) bool {
+	return validName(s) && !strings.ContainsAny(s, "./\\")
+}
+
+// ParseFormRecords parses CSV data consisting of a header line with column names followed by one line per filled copy.
+// Multiple selections are separated by commas.
+func ParseFormRecords(bb []byte, mfc *MultiFillConfig) ([]FormRecord, error) {
+
+	r := csv.NewReader(bytes.NewReader(bb))
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < 2 {
+		return nil, errors.New("ParseFormRecords: missing form data")
+	}
+
+	header := rows[0]
+
+	columns := map[string]int{}
+	for i, c := range header {
+		if _, found := columns[c]; found {
+			return nil, errors.Errorf("ParseFormRecords: duplicate column: %s", c)
+		}
+		columns[c] = i
+	}
+
+	fieldMap := mfc.FieldMap
+	if fieldMap == nil {
+		fieldMap = map[string]string{}
+		for _, c := range header {
+			if c != mfc.NameColumn {
+				fieldMap[c] = c
+			}
+		}
+	}
+
+	for _, c := range fieldMap {
+		if _, found := columns[c]; !found {
+			return nil, errors.Errorf("ParseFormRecords: unknown column: %s", c)
+		}
+	}
+
+	nameCol := -1
+	if mfc.NameColumn != "" {
+		i, found := columns[mfc.NameColumn]
+		if !found {
+			return nil, errors.Errorf("ParseFormRecords: unknown column: %s", mfc.NameColumn)
+		}
+		nameCol = i
+	}
+
+	var rr []FormRecord
+	names := map[string]bool{}
+
+	for i, row := range rows[1:] {
+
+		name := strconv.Itoa(i + 1)
+		if nameCol >= 0 {
+			name = strings.TrimSpace(row[nameCol])
+		}
+
+		if !validCopyName(name) {
+			return nil, errors.Errorf("ParseFormRecords: invalid name in line %d: %q", i+2, name)
+		}
+
+		if names[name] {
+			return nil, errors.Errorf("ParseFormRecords: duplicate name in line %d: %s", i+2, name)
+		}
+		names[name] = true
+
+		values := map[string]interface{}{}
+		for field, c := range fieldMap {
+			values[field] = row[columns[c]]
+		}
+
+		rr = append(rr, FormRecord{Name: name, Values: values})
+	}
+
+	return rr, nil
+}
+
+// QualifyFormFields moves all top level fields of the interactive form below a new field called name.
+// This keeps field names unique when merging multiple filled copies of the same form.
+func QualifyFormFields(xRefTable *XRefTable, name string) error {
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	if acroForm == nil {
+		return errors.New("QualifyFormFields: no form available")
+	}
+
+	obj, _ := acroForm.Find("Fields")
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil {
+		return err
+	}
+
+	if arr == nil {
+		arr = &PDFArray{}
+	}
+
+	d := NewPDFDict()
+	d.Insert("T", encodeText(name))
+	d.Insert("Kids", *arr)
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range *arr {
+
+		fd, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+
+		if fd == nil {
+			return errors.New("QualifyFormFields: corrupt form field array entry")
+		}
+
+		fd.Update("Parent", *indRef)
+	}
+
+	acroForm.Update("Fields", PDFArray{*indRef})
+
+	return nil
+}
+
+// MergeFormFields appends the top level fields of ctxSource's interactive form to the form of ctxDest.
+// ctxSource needs to be merged into ctxDest via MergeXRefTables beforehand.
+func MergeFormFields(ctxSource, ctxDest *PDFContext) error {
+
+	acroFormSource, err := acroFormDict(ctxSource.XRefTable)
+	if err != nil || acroFormSource == nil {
+		return err
+	}
+
+	obj, _ := acroFormSource.Find("Fields")
+
+	arr, err := ctxSource.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return err
+	}
+
+	acroFormDest, err := acroFormDict(ctxDest.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	if acroFormDest == nil {
+		rootDict, err := ctxDest.Catalog()
+		if err != nil {
+			return err
+		}
+		acroFormSource.Update("Fields", append(PDFArray{}, *arr...))
+		rootDict.Insert("AcroForm", *acroFormSource)
+		return nil
+	}
+
+	for _, o := range *arr {
+		err = appendToArrayEntry(ctxDest.XRefTable, acroFormDest, "Fields", o)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,548 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Named destinations
+//
+// Named destinations (12.3.2.3) live in the Dests name tree of the names dict keyed by strings
+// or, as of PDF 1.1, in the Dests dict of the catalog keyed by names.
+// New destinations go into the name tree. Renamed destinations stay where they are.
+// Links, outline items and the open action referring to a renamed destination get updated,
+// those referring to a removed destination get its explicit destination instead.
+
+// NamedDest represents a named destination.
+type NamedDest struct {
+	Name   string `json:"name"`
+	Page   int    `json:"page,omitempty"`   // 0 for a destination in another document or a dangling destination
+	View   string `json:"view,omitempty"`   // see Bookmark
+	Legacy bool   `json:"legacy,omitempty"` // kept in the Dests dict of the catalog
+}
+
+// destNameTreeKey returns the name tree key for name matching the string used for references.
+func destNameTreeKey(name string) string {
+	return encodeText(name).Value()
+}
+
+// destName returns the name of a destination name tree key.
+func destName(k string) string {
+	s, err := StringLiteralToString(k)
+	if err != nil {
+		return k
+	}
+	return s
+}
+
+// legacyDests returns the Dests dict of the catalog.
+func legacyDests(xRefTable *XRefTable) (*PDFDict, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.DereferenceDict(rootDict.Dict["Dests"])
+}
+
+func destTree(xRefTable *XRefTable) (*Node, error) {
+
+	if !xRefTable.Valid && xRefTable.Names["Dests"] == nil {
+		if err := xRefTable.LocateNameTree("Dests", false); err != nil {
+			return nil, err
+		}
+	}
+
+	return xRefTable.Names["Dests"], nil
+}
+
+// namedDestValue returns the destination named name along with the name tree key or whether it is a legacy destination.
+func namedDestValue(xRefTable *XRefTable, name string) (v PDFObject, key string, legacy, found bool, err error) {
+
+	tree, err := destTree(xRefTable)
+	if err != nil {
+		return nil, "", false, false, err
+	}
+
+	if tree != nil {
+		err = tree.Process(xRefTable, func(xRefTable *XRefTable, k string, o PDFObject) error {
+			if !found && destName(k) == name {
+				v, key, found = o, k, true
+			}
+			return nil
+		})
+		if err != nil || found {
+			return v, key, false, found, err
+		}
+	}
+
+	d, err := legacyDests(xRefTable)
+	if err != nil || d == nil {
+		return nil, "", false, false, err
+	}
+
+	v, found = d.Find(name)
+
+	return v, "", found, found, nil
+}
+
+// NamedDests returns all named destinations sorted by name.
+func NamedDests(xRefTable *XRefTable) ([]NamedDest, error) {
+
+	pages, err := pageNrs(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &outlineReader{xRefTable: xRefTable, pages: pages, visited: IntSet{}}
+
+	var dd []NamedDest
+
+	namedDest := func(name string, v PDFObject, legacy bool) error {
+		b := &Bookmark{}
+		if err := r.destination(v, b); err != nil {
+			return err
+		}
+		dd = append(dd, NamedDest{Name: name, Page: b.Page, View: b.View, Legacy: legacy})
+		return nil
+	}
+
+	tree, err := destTree(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if tree != nil {
+		err = tree.Process(xRefTable, func(xRefTable *XRefTable, k string, v PDFObject) error {
+			return namedDest(destName(k), v, false)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d, err := legacyDests(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if d != nil {
+		for k, v := range d.Dict {
+			if err = namedDest(k, v, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(dd, func(i, j int) bool { return dd[i].Name < dd[j].Name })
+
+	return dd, nil
+}
+
+// AddNamedDest adds a destination for page to the Dests name tree.
+func AddNamedDest(xRefTable *XRefTable, dest NamedDest) error {
+
+	if dest.Name == "" {
+		return errors.New("pdfcpu: named destination: missing name")
+	}
+
+	if dest.Page < 1 || dest.Page > xRefTable.PageCount {
+		return errors.Errorf("pdfcpu: named destination %q: invalid page: %d", dest.Name, dest.Page)
+	}
+
+	_, _, _, found, err := namedDestValue(xRefTable, dest.Name)
+	if err != nil {
+		return err
+	}
+	if found {
+		return errors.Errorf("pdfcpu: named destination %q already exists", dest.Name)
+	}
+
+	view, err := parseDestView(dest.View)
+	if err != nil {
+		return err
+	}
+
+	pageIndRef, err := xRefTable.PageDictIndRef(dest.Page)
+	if err != nil {
+		return err
+	}
+	if pageIndRef == nil {
+		return errors.Errorf("pdfcpu: named destination %q: page %d not found", dest.Name, dest.Page)
+	}
+
+	if err = xRefTable.LocateNameTree("Dests", true); err != nil {
+		return err
+	}
+
+	return xRefTable.Names["Dests"].Add(xRefTable, destNameTreeKey(dest.Name), append(PDFArray{*pageIndRef}, view...))
+}
+
+// removeDestTree removes an empty Dests name tree and a resulting empty names dict.
+// Unlike RemoveNameTree this leaves the objects referenced by the tree alone.
+func removeDestTree(xRefTable *XRefTable) error {
+
+	delete(xRefTable.Names, "Dests")
+
+	namesDict, err := xRefTable.NamesDict()
+	if err != nil {
+		return err
+	}
+
+	namesDict.Delete("Dests")
+	if namesDict.Len() > 0 {
+		return nil
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootDict.Delete("Names")
+
+	return nil
+}
+
+// removeNamedDest removes the destination named name and returns its value.
+func removeNamedDest(xRefTable *XRefTable, name string) (PDFObject, error) {
+
+	v, key, legacy, found, err := namedDestValue(xRefTable, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, errors.Errorf("pdfcpu: named destination %q not found", name)
+	}
+
+	if legacy {
+		d, err := legacyDests(xRefTable)
+		if err != nil {
+			return nil, err
+		}
+		d.Delete(name)
+		if d.Len() == 0 {
+			rootDict, err := xRefTable.Catalog()
+			if err != nil {
+				return nil, err
+			}
+			rootDict.Delete("Dests")
+		}
+		return v, nil
+	}
+
+	// The destination value refers to a page and must not be deleted along with the entry.
+	empty, _, err := xRefTable.Names["Dests"].Remove(nil, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if empty {
+		err = removeDestTree(xRefTable)
+	}
+
+	return v, err
+}
+
+// explicitDest returns the explicit destination of the named destination value v.
+func explicitDest(xRefTable *XRefTable, v PDFObject) PDFObject {
+
+	if d, err := xRefTable.DereferenceDict(v); err == nil && d != nil {
+		return d.Dict["D"]
+	}
+
+	return v
+}
+
+// RemoveNamedDests removes the named destinations names.
+// References to them get replaced by their explicit destinations.
+func RemoveNamedDests(xRefTable *XRefTable, names []string) error {
+
+	dests := map[string]PDFObject{}
+
+	for _, name := range names {
+		v, err := removeNamedDest(xRefTable, name)
+		if err != nil {
+			return err
+		}
+		dests[name] = explicitDest(xRefTable, v)
+	}
+
+	n, err := updateDestRefs(xRefTable, func(name string, legacy bool) (PDFObject, bool) {
+		v, ok := dests[name]
+		return v, ok
+	})
+
+	log.Debug.Printf("RemoveNamedDests: %d references resolved\n", n)
+
+	return err
+}
+
+// RenameNamedDest renames the named destination oldName to newName and updates all references.
+func RenameNamedDest(xRefTable *XRefTable, oldName, newName string) error {
+
+	if newName == "" {
+		return errors.New("pdfcpu: named destination: missing name")
+	}
+
+	_, _, _, found, err := namedDestValue(xRefTable, newName)
+	if err != nil {
+		return err
+	}
+	if found {
+		return errors.Errorf("pdfcpu: named destination %q already exists", newName)
+	}
+
+	_, _, legacy, _, err := namedDestValue(xRefTable, oldName)
+	if err != nil {
+		return err
+	}
+
+	v, err := removeNamedDest(xRefTable, oldName)
+	if err != nil {
+		return err
+	}
+
+	if legacy {
+		d, err := legacyDests(xRefTable)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			rootDict, err := xRefTable.Catalog()
+			if err != nil {
+				return err
+			}
+			dict := NewPDFDict()
+			rootDict.Insert("Dests", dict)
+			d = &dict
+		}
+		d.Insert(newName, v)
+	} else {
+		if err = xRefTable.LocateNameTree("Dests", true); err != nil {
+			return err
+		}
+		if err = xRefTable.Names["Dests"].Add(xRefTable, destNameTreeKey(newName), v); err != nil {
+			return err
+		}
+	}
+
+	n, err := updateDestRefs(xRefTable, func(name string, legacy bool) (PDFObject, bool) {
+		if name != oldName {
+			return nil, false
+		}
+		if legacy {
+			return PDFName(newName), true
+		}
+		return encodeText(newName), true
+	})
+
+	log.Debug.Printf("RenameNamedDest: %d references updated\n", n)
+
+	return err
+}
+
+// destRefUpdater replaces references to named destinations.
+type destRefUpdater struct {
+	xRefTable *XRefTable
+	visited   IntSet
+	replace   func(name string, legacy bool) (PDFObject, bool)
+	n         int // references replaced
+}
+
+// dest replaces the named destination d[key].
+func (u *destRefUpdater) dest(d *PDFDict, key string) error {
+
+	o, err := u.xRefTable.Dereference(d.Dict[key])
+	if err != nil || o == nil {
+		return err
+	}
+
+	var (
+		name   string
+		legacy bool
+	)
+
+	switch o := o.(type) {
+	case PDFName:
+		name, legacy = o.Value(), true
+	case PDFStringLiteral, PDFHexLiteral:
+		if name, err = fieldValueString(u.xRefTable, o); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	if v, ok := u.replace(name, legacy); ok {
+		d.Update(key, v)
+		u.n++
+	}
+
+	return nil
+}
+
+// action processes an action or an array of actions including their Next actions.
+func (u *destRefUpdater) action(o PDFObject) error {
+
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		if u.visited[indRef.ObjectNumber.Value()] {
+			return nil
+		}
+		u.visited[indRef.ObjectNumber.Value()] = true
+	}
+
+	o, err := u.xRefTable.Dereference(o)
+	if err != nil || o == nil {
+		return err
+	}
+
+	switch o := o.(type) {
+
+	case PDFArray:
+		for _, v := range o {
+			if err = u.action(v); err != nil {
+				return err
+			}
+		}
+
+	case PDFDict:
+		if s := o.NameEntry("S"); s != nil && *s == "GoTo" {
+			if err = u.dest(&o, "D"); err != nil {
+				return err
+			}
+		}
+		if next, found := o.Find("Next"); found {
+			return u.action(next)
+		}
+	}
+
+	return nil
+}
+
+// destOrAction processes the Dest or A entry of a link annotation or an outline item.
+func (u *destRefUpdater) destOrAction(d *PDFDict) error {
+
+	if _, found := d.Find("Dest"); found {
+		return u.dest(d, "Dest")
+	}
+
+	if a, found := d.Find("A"); found {
+		return u.action(a)
+	}
+
+	return nil
+}
+
+func (u *destRefUpdater) outlineItems(o PDFObject) error {
+
+	for o != nil {
+
+		indRef, ok := o.(PDFIndirectRef)
+		if !ok || u.visited[indRef.ObjectNumber.Value()] {
+			return nil
+		}
+		u.visited[indRef.ObjectNumber.Value()] = true
+
+		d, err := u.xRefTable.DereferenceDict(indRef)
+		if err != nil || d == nil {
+			return err
+		}
+
+		if err = u.destOrAction(d); err != nil {
+			return err
+		}
+
+		if err = u.outlineItems(d.Dict["First"]); err != nil {
+			return err
+		}
+
+		o = d.Dict["Next"]
+	}
+
+	return nil
+}
+
+// updateDestRefs applies replace to all references to named destinations of
+// the open action, outline items and link annotations and returns the number of references replaced.
+func updateDestRefs(xRefTable *XRefTable, replace func(name string, legacy bool) (PDFObject, bool)) (int, error) {
+
+	u := &destRefUpdater{xRefTable: xRefTable, visited: IntSet{}, replace: replace}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return 0, err
+	}
+
+	if o, found := rootDict.Find("OpenAction"); found {
+		if err = u.action(o); err != nil {
+			return 0, err
+		}
+	}
+
+	outlines, err := xRefTable.DereferenceDict(rootDict.Dict["Outlines"])
+	if err != nil {
+		return 0, err
+	}
+
+	if outlines != nil {
+		if err = u.outlineItems(outlines.Dict["First"]); err != nil {
+			return 0, err
+		}
+	}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		arr, err := pageAnnotations(xRefTable, pageDict)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, v := range arr {
+
+			d, err := xRefTable.DereferenceDict(v)
+			if err != nil {
+				return 0, err
+			}
+
+			if d == nil || d.Subtype() == nil || *d.Subtype() != "Link" {
+				continue
+			}
+
+			if err = u.destOrAction(d); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return u.n, nil
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	annotFlagNoZoom   = 1 << 3 // see 12.5.3 Table 165
+	annotFlagNoRotate = 1 << 4
+
+	defaultNoteFontSize = 12
+)
+
+// noteIcons lists the standard icons of sticky notes, see 12.5.6.4 Text Annotations.
+var noteIcons = []string{"Comment", "Key", "Note", "Help", "NewParagraph", "Paragraph", "Insert"}
+
+// Note describes a sticky note, a free text or a file attachment annotation.
+type Note struct {
+	Page     int
+	Rect     types.Rectangle // icon location of a sticky note, text box of a free text annotation
+	Contents string
+	Author   string
+	Icon     string  // one of noteIcons or fileAttachmentIcons, defaults to Note or PushPin
+	Color    string  // #RRGGBB, icon color, text and border color of a free text annotation
+	FontSize float64 // free text only, defaults to 12
+}
+
+// ParseNoteDetails parses a note command string into an internal structure.
+// The string consists of entries "rect:llx lly urx ury" and optionally "author:name", "icon:name" and "size:fontSize".
+func ParseNoteDetails(s string, freeText bool) (*Note, error) {
+
+	n := &Note{}
+
+	var rectSet bool
+
+	for _, s := range strings.Split(s, ",") {
+
+		ss := strings.SplitN(s, ":", 2)
+		if len(ss) != 2 {
+			return nil, errors.Errorf("invalid note configuration: %s", s)
+		}
+
+		k := strings.TrimSpace(ss[0])
+		v := strings.TrimSpace(ss[1])
+
+		switch {
+
+		case k == "rect":
+			l := &Link{}
+			if err := parseLinkRect(v, l); err != nil {
+				return nil, err
+			}
+			n.Rect = l.Rect
+			rectSet = true
+
+		case k == "author":
+			n.Author = v
+
+		case k == "icon" && !freeText:
+			n.Icon = v
+
+		case k == "size" && freeText:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 {
+				return nil, errors.Errorf("invalid font size: %s", v)
+			}
+			n.FontSize = f
+
+		default:
+			return nil, errors.Errorf("invalid note configuration: %s", s)
+		}
+	}
+
+	if !rectSet {
+		return nil, errors.New("missing note rect")
+	}
+
+	return n, nil
+}
+
+func (n Note) validate(xRefTable *XRefTable, icons []string) error {
+
+	if n.Page < 1 || n.Page > xRefTable.PageCount {
+		return errors.Errorf("invalid page: %d", n.Page)
+	}
+
+	if n.Rect.Width() <= 0 || n.Rect.Height() <= 0 {
+		return errors.Errorf("invalid rect: %s", n.Rect)
+	}
+
+	if n.Icon != "" && !memberOf(n.Icon, icons) {
+		return errors.Errorf("unsupported icon: %s, try one of %s", n.Icon, strings.Join(icons, ", "))
+	}
+
+	if n.Color != "" {
+		if _, err := parseXFDFColor(n.Color); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// noteDict creates an annotation dict with the entries common to sticky notes and free text annotations.
+func noteDict(n Note, subtype string) PDFDict {
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Annot")
+	d.InsertName("Subtype", subtype)
+	d.Insert("Rect", NewRectangle(n.Rect.LL.X, n.Rect.LL.Y, n.Rect.UR.X, n.Rect.UR.Y))
+	d.Insert("Contents", encodeText(n.Contents))
+
+	if n.Author != "" {
+		d.Insert("T", encodeText(n.Author))
+	}
+
+	now := DateStringLiteral(time.Now())
+	d.Insert("M", now)
+	d.Insert("CreationDate", now)
+
+	return d
+}
+
+// addNote adds an annotation dict to the page of a note.
+func addNote(xRefTable *XRefTable, n Note, d PDFDict) error {
+
+	pageDict, _, err := xRefTable.PageDict(n.Page)
+	if err != nil {
+		return err
+	}
+
+	if pageDict == nil {
+		return errors.Errorf("page %d not found", n.Page)
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	return appendToArrayEntry(xRefTable, pageDict, "Annots", *indRef)
+}
+
+// AddStickyNote adds a closed Text annotation displayed as icon to a page.
+func AddStickyNote(xRefTable *XRefTable, n Note) error {
+
+	if err := n.validate(xRefTable, noteIcons); err != nil {
+		return errors.Wrap(err, "AddStickyNote")
+	}
+
+	d := noteDict(n, "Text")
+	d.InsertInt("F", annotFlagPrint|annotFlagNoZoom|annotFlagNoRotate)
+	d.Insert("Open", PDFBoolean(false))
+
+	icon := n.Icon
+	if icon == "" {
+		icon = "Note"
+	}
+	d.InsertName("Name", icon)
+
+	if n.Color != "" {
+		c, _ := parseXFDFColor(n.Color)
+		d.Insert("C", c)
+	}
+
+	return addNote(xRefTable, n, d)
+}
+
+// AddFreeText adds a FreeText annotation displaying its contents in Helvetica within a bordered box to a page.
+func AddFreeText(xRefTable *XRefTable, n Note) error {
+
+	if err := n.validate(xRefTable, nil); err != nil {
+		return errors.Wrap(err, "AddFreeText")
+	}
+
+	fontSize := n.FontSize
+	if fontSize == 0 {
+		fontSize = defaultNoteFontSize
+	}
+
+	col := "0 0 0"
+	if n.Color != "" {
+		c, _ := parseXFDFColor(n.Color)
+		col = strings.Replace(xfdfNumbers(xRefTable, c), ",", " ", -1)
+	}
+
+	da := fmt.Sprintf("/Helv %s Tf %s rg", strconv.FormatFloat(fontSize, 'f', -1, 64), col)
+
+	d := noteDict(n, "FreeText")
+	d.InsertInt("F", annotFlagPrint)
+	d.InsertString("DA", da)
+	d.Insert("BS", PDFDict{Dict: map[string]PDFObject{"W": PDFFloat(formBuilderLineW)}})
+
+	fontObj, baseFont, err := fontResource(xRefTable, nil, nil, "Helv")
+	if err != nil {
+		return err
+	}
+
+	w, h := n.Rect.Width(), n.Rect.Height()
+
+	lw := formBuilderLineW
+	border := fmt.Sprintf("q %s RG %.1f w %.2f %.2f %.2f %.2f re S Q ", col, lw, lw/2, lw/2, w-lw, h-lw)
+
+	content := border + string(textAppearance(n.Contents, da, baseFont, fontSize, 0, true, w, h))
+
+	res := &PDFDict{Dict: map[string]PDFObject{"Font": PDFDict{Dict: map[string]PDFObject{"Helv": fontObj}}}}
+
+	indRef, err := appearanceStream(xRefTable, w, h, content, res)
+	if err != nil {
+		return err
+	}
+
+	d.Insert("AP", PDFDict{Dict: map[string]PDFObject{"N": *indRef}})
+
+	return addNote(xRefTable, n, d)
+}
@@ -0,0 +1,383 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Text layers
+//
+// Scanned pages consist of images only. An OCR engine recognizes the words of these images
+// and pdfcpu lays the words over the images as invisible text (text rendering mode 3, 9.3.6)
+// so viewers can search and select the text while the page looks the same.
+// The text uses Helvetica with WinAnsiEncoding, characters outside Latin-1 turn into '?'.
+
+// OCRWord is a recognized word along with its bounding box in pixels, the origin being the upper left corner.
+type OCRWord struct {
+	Text           string
+	X0, Y0, X1, Y1 float64
+}
+
+// TextLayer is the text recognized in an image of Width x Height pixels covering a page.
+type TextLayer struct {
+	Page          int
+	Width, Height float64
+	Words         []OCRWord
+	m             *matrix // maps the unit square of the image into user space, nil for the visible region of the page
+}
+
+// OCRImage is an image shown on a page as handed to an OCR engine.
+type OCRImage struct {
+	Page          int
+	ObjNr         int
+	FileName      string // the extension tells the image type: jpg, jpx, png or tif
+	Data          []byte // encoded image
+	Width, Height int    // in pixels
+}
+
+// OCREngine recognizes the words of images.
+type OCREngine interface {
+	Recognize(img OCRImage) ([]OCRWord, error)
+}
+
+// imagePlacement is an image XObject painted with the current transformation matrix m.
+type imagePlacement struct {
+	objNr int
+	sd    *PDFStreamDict
+	m     matrix
+}
+
+// pageImagePlacements returns the images painted by the content of a page including its form XObjects.
+func pageImagePlacements(xRefTable *XRefTable, page int) ([]imagePlacement, error) {
+
+	pageDict, inhPAttrs, err := xRefTable.PageDict(page)
+	if err != nil || pageDict == nil {
+		return nil, err
+	}
+
+	bb, err := pageContent(xRefTable, pageDict, false)
+	if err != nil || len(bb) == 0 {
+		return nil, err
+	}
+
+	var pp []imagePlacement
+
+	te := &textExtractor{xRefTable: xRefTable, fonts: map[int]*textFont{}}
+	te.image = func(o PDFObject, sd *PDFStreamDict, ctm matrix) {
+		if indRef, ok := o.(PDFIndirectRef); ok {
+			pp = append(pp, imagePlacement{objNr: indRef.ObjectNumber.Value(), sd: sd, m: ctm})
+		}
+	}
+
+	err = te.processContent(bb, inhPAttrs.resources, identMatrix, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "page %d", page)
+	}
+
+	return pp, nil
+}
+
+type bufWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (bufWriteCloser) Close() error { return nil }
+
+// ocrImage encodes the image XObject sd into a file format OCR engines understand.
+// It returns nil for unsupported images.
+func ocrImage(xRefTable *XRefTable, sd PDFStreamDict, page, objNr int) (*OCRImage, error) {
+
+	if len(sd.FilterPipeline) != 1 {
+		log.Info.Printf("ocrImage: ignoring obj#%d - one filter expected\n", objNr)
+		return nil, nil
+	}
+
+	if im := sd.BooleanEntry("ImageMask"); im != nil && *im {
+		return nil, nil
+	}
+
+	w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if w == nil || h == nil {
+		return nil, nil
+	}
+
+	if name := sd.FilterPipeline[0].Name; name == filter.Flate || filter.Registered(name) {
+		err := xRefTable.decodeStreamObj(&sd, objNr)
+		if err == filter.ErrUnsupportedFilter {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+
+	create := xRefTable.CreateFile
+	xRefTable.CreateFile = func(name string) (io.WriteCloser, error) { return bufWriteCloser{&b}, nil }
+	defer func() { xRefTable.CreateFile = create }()
+
+	fileName, err := WriteImage(xRefTable, fmt.Sprintf("%d_%d", page, objNr), &sd, objNr)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileName == "" || b.Len() == 0 {
+		log.Info.Printf("ocrImage: ignoring obj#%d - unsupported image\n", objNr)
+		return nil, nil
+	}
+
+	return &OCRImage{Page: page, ObjNr: objNr, FileName: fileName, Data: b.Bytes(), Width: *w, Height: *h}, nil
+}
+
+// pageHasText returns true if a page shows any text.
+func pageHasText(xRefTable *XRefTable, page int) (bool, error) {
+
+	chars, err := pageChars(xRefTable, page)
+	if err != nil {
+		return false, err
+	}
+
+	return len(chars) > 0, nil
+}
+
+// OCR lets engine recognize the images of the selected pages and adds the words found as a text layer.
+// Pages already showing text are skipped. It returns the number of words added.
+// If selectedPages is empty all pages are processed.
+func OCR(xRefTable *XRefTable, selectedPages IntSet, engine OCREngine) (int, error) {
+
+	log.Debug.Println("OCR begin")
+
+	var tls []TextLayer
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		ok, err := pageHasText(xRefTable, i)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok {
+			log.Info.Printf("OCR: skipping page %d - has text\n", i)
+			continue
+		}
+
+		pp, err := pageImagePlacements(xRefTable, i)
+		if err != nil {
+			return 0, err
+		}
+
+		// An image shown more than once gets recognized once.
+		words := map[int][]OCRWord{}
+
+		for _, p := range pp {
+
+			ww, found := words[p.objNr]
+
+			if !found {
+				img, err := ocrImage(xRefTable, *p.sd, i, p.objNr)
+				if err != nil {
+					return 0, err
+				}
+				if img != nil {
+					if ww, err = engine.Recognize(*img); err != nil {
+						return 0, errors.Wrapf(err, "OCR: page %d obj#%d", i, p.objNr)
+					}
+				}
+				words[p.objNr] = ww
+			}
+
+			if len(ww) == 0 {
+				continue
+			}
+
+			w, h := p.sd.IntEntry("Width"), p.sd.IntEntry("Height")
+			m := p.m
+			tls = append(tls, TextLayer{Page: i, Width: float64(*w), Height: float64(*h), Words: ww, m: &m})
+		}
+	}
+
+	n, err := AddTextLayers(xRefTable, tls)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Debug.Println("OCR end")
+
+	return n, nil
+}
+
+// pageMatrix returns the matrix mapping the unit square onto the visible region of a page.
+func pageMatrix(xRefTable *XRefTable, page int) (*matrix, error) {
+
+	_, inhPAttrs, err := xRefTable.PageDict(page)
+	if err != nil {
+		return nil, err
+	}
+
+	vr := inhPAttrs.mediaBox
+	if inhPAttrs.cropBox != nil {
+		vr = inhPAttrs.cropBox
+	}
+
+	if vr == nil {
+		return nil, errors.Errorf("page %d: missing MediaBox", page)
+	}
+
+	r := rect(xRefTable, *vr)
+
+	return &matrix{{r.Width(), 0, 0}, {0, r.Height(), 0}, {r.LL.X, r.LL.Y, 1}}, nil
+}
+
+// content writes the text showing operators laying the words of tl over the image into b and returns the number of words.
+func (tl TextLayer) content(b *bytes.Buffer) int {
+
+	var n int
+
+	for _, w := range tl.Words {
+
+		s := strings.TrimSpace(w.Text)
+		if s == "" || w.X1 <= w.X0 || w.Y1 <= w.Y0 {
+			continue
+		}
+
+		s = winAnsiString(s)
+
+		tw := textWidth(s, "Helvetica", 1)
+		if tw == 0 {
+			continue
+		}
+
+		// The corners of the word box in user space.
+		ll := transform(*tl.m, w.X0/tl.Width, 1-w.Y1/tl.Height)
+		lr := transform(*tl.m, w.X1/tl.Width, 1-w.Y1/tl.Height)
+		ul := transform(*tl.m, w.X0/tl.Width, 1-w.Y0/tl.Height)
+
+		// Stretch the text horizontally to the box width and vertically to the box height from descent to ascent.
+		h := textAscent - textDescent
+		a, b1 := (lr[0]-ll[0])/tw, (lr[1]-ll[1])/tw
+		c, d := (ul[0]-ll[0])/h, (ul[1]-ll[1])/h
+		e, f := ll[0]-textDescent*c, ll[1]-textDescent*d
+
+		esc, _ := Escape(s)
+		fmt.Fprintf(b, "%.4f %.4f %.4f %.4f %.2f %.2f Tm (%s) Tj\n", a, b1, c, d, e, f, *esc)
+		n++
+	}
+
+	return n
+}
+
+// AddTextLayers lays the words of tls as invisible text over the pages they belong to.
+// Text layers without an image placement cover the visible region of their page.
+// It returns the number of words added.
+func AddTextLayers(xRefTable *XRefTable, tls []TextLayer) (int, error) {
+
+	pages := map[int]*bytes.Buffer{}
+	var pageNrs []int
+
+	var n int
+
+	for _, tl := range tls {
+
+		if tl.Page < 1 || tl.Page > xRefTable.PageCount {
+			return 0, errors.Errorf("pdfcpu: invalid page: %d", tl.Page)
+		}
+
+		if tl.Width <= 0 || tl.Height <= 0 {
+			return 0, errors.Errorf("pdfcpu: page %d: invalid text layer dimensions %.0f x %.0f", tl.Page, tl.Width, tl.Height)
+		}
+
+		if tl.m == nil {
+			m, err := pageMatrix(xRefTable, tl.Page)
+			if err != nil {
+				return 0, err
+			}
+			tl.m = m
+		}
+
+		b := pages[tl.Page]
+		if b == nil {
+			b = &bytes.Buffer{}
+			pages[tl.Page] = b
+			pageNrs = append(pageNrs, tl.Page)
+		}
+
+		n += tl.content(b)
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Font")
+	d.InsertName("Subtype", "Type1")
+	d.InsertName("BaseFont", "Helvetica")
+	d.InsertName("Encoding", "WinAnsiEncoding")
+
+	font, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, i := range pageNrs {
+
+		b := pages[i]
+		if b.Len() == 0 {
+			continue
+		}
+
+		pageDict, inhPAttrs, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		bb := append([]byte("BT 3 Tr /F0 1 Tf\n"), b.Bytes()...)
+		bb = append(bb, "ET\n"...)
+
+		res := &PDFDict{Dict: map[string]PDFObject{"Font": PDFDict{Dict: map[string]PDFObject{"F0": *font}}}}
+
+		if inhPAttrs.resources == nil {
+			pageDict.Insert("Resources", *res)
+		} else {
+			// Rename the font if it collides with page resources.
+			renames, err := mergeResources(xRefTable, inhPAttrs.resources, res)
+			if err != nil {
+				return 0, err
+			}
+			bb = renameResourceNames(bb, renames)
+		}
+
+		if err = addContentStream(xRefTable, pageDict, bb); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
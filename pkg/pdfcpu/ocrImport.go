@@ -0,0 +1,290 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OCR output import
+//
+// hOCR (http://kba.cloud/hocr-spec) embeds the recognized layout in HTML by class names and title properties:
+// an ocr_page element with the bbox of the scanned page contains ocrx_word elements with their own bbox.
+// ALTO (https://www.loc.gov/standards/alto) describes Page elements of WIDTH x HEIGHT
+// containing String elements with HPOS, VPOS, WIDTH, HEIGHT and CONTENT.
+// Both use the upper left corner as origin. The words of each page end up in a TextLayer
+// covering the visible region of the corresponding PDF page.
+
+// ParseOCR parses an hOCR or ALTO file into text layers, one per page, and sets their page numbers
+// to those recorded in the file, if any, otherwise to their position.
+func ParseOCR(b []byte) ([]TextLayer, error) {
+
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))))
+	d.Strict = false
+
+	for {
+
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseOCR")
+		}
+
+		if se, ok := t.(xml.StartElement); ok {
+			if strings.EqualFold(se.Name.Local, "alto") {
+				return ParseALTO(b)
+			}
+			return ParseHOCR(b)
+		}
+	}
+
+	return nil, errors.New("ParseOCR: no hOCR or ALTO content")
+}
+
+// xmlAttrValue returns the value of the attribute local of se.
+func xmlAttrValue(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if strings.EqualFold(a.Name.Local, local) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// hocrClass returns true if the class attribute of se contains class.
+func hocrClass(se xml.StartElement, class string) bool {
+	for _, c := range strings.Fields(xmlAttrValue(se, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// hocrProperty returns the values of the hOCR property name of the title attribute of se,
+// eg. "bbox 0 0 2480 3508; ppageno 0".
+func hocrProperty(se xml.StartElement, name string) []string {
+	for _, p := range strings.Split(xmlAttrValue(se, "title"), ";") {
+		ss := strings.Fields(p)
+		if len(ss) > 0 && ss[0] == name {
+			return ss[1:]
+		}
+	}
+	return nil
+}
+
+// hocrBBox returns the bbox property of se.
+func hocrBBox(se xml.StartElement) ([4]float64, bool) {
+
+	var bb [4]float64
+
+	ss := hocrProperty(se, "bbox")
+	if len(ss) != 4 {
+		return bb, false
+	}
+
+	for i, s := range ss {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return bb, false
+		}
+		bb[i] = f
+	}
+
+	return bb, true
+}
+
+// ParseHOCR parses the ocr_page elements of an hOCR file into text layers.
+func ParseHOCR(b []byte) ([]TextLayer, error) {
+
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var (
+		tls    []TextLayer
+		page   *TextLayer
+		origin [2]float64 // upper left corner of the page bbox
+		word   *OCRWord
+		depth  int // nesting level of the current word element
+	)
+
+	for {
+
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseHOCR")
+		}
+
+		switch t := t.(type) {
+
+		case xml.StartElement:
+
+			if word != nil {
+				depth++
+				continue
+			}
+
+			if hocrClass(t, "ocr_page") {
+				bb, ok := hocrBBox(t)
+				if !ok {
+					return nil, errors.Errorf("ParseHOCR: page %d: missing bbox", len(tls)+1)
+				}
+				tls = append(tls, TextLayer{Page: len(tls) + 1, Width: bb[2] - bb[0], Height: bb[3] - bb[1]})
+				page = &tls[len(tls)-1]
+				origin = [2]float64{bb[0], bb[1]}
+				if ss := hocrProperty(t, "ppageno"); len(ss) == 1 {
+					if i, err := strconv.Atoi(ss[0]); err == nil && i >= 0 {
+						page.Page = i + 1
+					}
+				}
+				continue
+			}
+
+			if page != nil && hocrClass(t, "ocrx_word") {
+				if bb, ok := hocrBBox(t); ok {
+					word = &OCRWord{X0: bb[0] - origin[0], Y0: bb[1] - origin[1], X1: bb[2] - origin[0], Y1: bb[3] - origin[1]}
+					depth = 0
+				}
+			}
+
+		case xml.EndElement:
+
+			if word == nil {
+				continue
+			}
+
+			if depth > 0 {
+				depth--
+				continue
+			}
+
+			word.Text = strings.TrimSpace(word.Text)
+			if word.Text != "" {
+				page.Words = append(page.Words, *word)
+			}
+			word = nil
+
+		case xml.CharData:
+			if word != nil {
+				word.Text += string(t)
+			}
+
+		}
+	}
+
+	if len(tls) == 0 {
+		return nil, errors.New("ParseHOCR: no ocr_page found")
+	}
+
+	return tls, nil
+}
+
+// altoFloat returns the float value of the attribute local of se.
+func altoFloat(se xml.StartElement, local string) (float64, error) {
+
+	s := xmlAttrValue(se, local)
+	if s == "" {
+		return 0, errors.Errorf("missing %s", local)
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid %s: %s", local, s)
+	}
+
+	return f, nil
+}
+
+// ParseALTO parses the Page elements of an ALTO file into text layers.
+// Coordinates may use any MeasurementUnit since text layers relate words to their page dimensions.
+func ParseALTO(b []byte) ([]TextLayer, error) {
+
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))))
+
+	var (
+		tls  []TextLayer
+		page *TextLayer
+	)
+
+	for {
+
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseALTO")
+		}
+
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch se.Name.Local {
+
+		case "Page":
+			w, err := altoFloat(se, "WIDTH")
+			if err != nil {
+				return nil, errors.Wrapf(err, "ParseALTO: page %d", len(tls)+1)
+			}
+			h, err := altoFloat(se, "HEIGHT")
+			if err != nil {
+				return nil, errors.Wrapf(err, "ParseALTO: page %d", len(tls)+1)
+			}
+			tls = append(tls, TextLayer{Page: len(tls) + 1, Width: w, Height: h})
+			page = &tls[len(tls)-1]
+			if i, err := strconv.Atoi(xmlAttrValue(se, "PHYSICAL_IMG_NR")); err == nil && i > 0 {
+				page.Page = i
+			}
+
+		case "String":
+			if page == nil {
+				continue
+			}
+			var ff [4]float64
+			for i, k := range []string{"HPOS", "VPOS", "WIDTH", "HEIGHT"} {
+				if ff[i], err = altoFloat(se, k); err != nil {
+					return nil, errors.Wrapf(err, "ParseALTO: page %d: String", page.Page)
+				}
+			}
+			s := strings.TrimSpace(xmlAttrValue(se, "CONTENT"))
+			if s != "" {
+				page.Words = append(page.Words, OCRWord{Text: s, X0: ff[0], Y0: ff[1], X1: ff[0] + ff[2], Y1: ff[1] + ff[3]})
+			}
+		}
+	}
+
+	if len(tls) == 0 {
+		return nil, errors.New("ParseALTO: no Page found")
+	}
+
+	return tls, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOCR(t *testing.T) {
+
+	hocr := `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+ <head>
+  <meta http-equiv="Content-Type" content="text/html;charset=utf-8">
+  <meta name='ocr-system' content='tesseract 4.1.1' />
+ </head>
+ <body>
+  <div class='ocr_page' id='page_1' title='image "scan.png"; bbox 0 0 2480 3508; ppageno 1'>
+   <div class='ocr_carea' title="bbox 100 200 900 260">
+    <span class='ocr_line' title="bbox 100 200 900 260; baseline 0 -10">
+     <span class='ocrx_word' title='bbox 100 200 400 260; x_wconf 96'>Caf&eacute;</span>
+     <span class='ocrx_word' title='bbox 450 200 900 260; x_wconf 91'><strong>Bonjour</strong>!</span>
+     <span class='ocrx_word' title='bbox 950 200 960 260; x_wconf 10'> </span>
+    </span>
+   </div>
+  </div>
+ </body>
+</html>`
+
+	alto := `<?xml version="1.0" encoding="UTF-8"?>
+<alto xmlns="http://www.loc.gov/standards/alto/ns-v3#">
+  <Description><MeasurementUnit>pixel</MeasurementUnit></Description>
+  <Layout>
+    <Page ID="p1" WIDTH="1000" HEIGHT="2000" PHYSICAL_IMG_NR="1">
+      <PrintSpace>
+        <TextBlock><TextLine>
+          <String CONTENT="Hello" HPOS="10" VPOS="20" WIDTH="100" HEIGHT="30"/><SP/>
+          <String CONTENT="pdfcpu" HPOS="120" VPOS="20" WIDTH="150" HEIGHT="30"/>
+        </TextLine></TextBlock>
+      </PrintSpace>
+    </Page>
+    <Page ID="p2" WIDTH="1000" HEIGHT="2000">
+      <PrintSpace/>
+    </Page>
+  </Layout>
+</alto>`
+
+	for _, tt := range []struct {
+		name string
+		in   string
+		want []TextLayer
+	}{
+		{"hOCR", hocr, []TextLayer{
+			{Page: 2, Width: 2480, Height: 3508, Words: []OCRWord{
+				{Text: "Café", X0: 100, Y0: 200, X1: 400, Y1: 260},
+				{Text: "Bonjour!", X0: 450, Y0: 200, X1: 900, Y1: 260}}}}},
+		{"ALTO", alto, []TextLayer{
+			{Page: 1, Width: 1000, Height: 2000, Words: []OCRWord{
+				{Text: "Hello", X0: 10, Y0: 20, X1: 110, Y1: 50},
+				{Text: "pdfcpu", X0: 120, Y0: 20, X1: 270, Y1: 50}}},
+			{Page: 2, Width: 1000, Height: 2000}}},
+	} {
+		got, err := ParseOCR([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("%s: %v\n", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v\n", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseOCR([]byte("<html><body><p>no OCR</p></body></html>")); err == nil {
+		t.Error("ParseOCR: expected error for missing ocr_page")
+	}
+}
@@ -17,10 +17,14 @@ limitations under the License.
 package pdfcpu
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
+	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/log"
 	"github.com/pkg/errors"
 )
@@ -295,6 +299,12 @@ func handleDuplicateImageObject(ctx *PDFContext, image *PDFStreamDict, resourceN
 	// Process image dict, check if this is a duplicate.
 	for imageObjectNumber, imageObject := range ctx.Optimize.ImageObjects {
 
+		if d1, found := ctx.Optimize.imageDigests[objNr]; found {
+			if d2, found := ctx.Optimize.imageDigests[imageObjectNumber]; found && d1 != d2 {
+				continue
+			}
+		}
+
 		log.Debug.Printf("handleDuplicateImageObject: comparing with imagedict Obj %d\n", imageObjectNumber)
 
 		ok, err := equalPDFStreamDicts(imageObject.ImageDict, image, ctx.XRefTable)
@@ -514,6 +524,10 @@ func parsePagesDict(ctx *PDFContext, pagesDict *PDFDict, pageNumber int) (int, e
 	kidsArray := pagesDict.PDFArrayEntry("Kids")
 	for _, v := range *kidsArray {
 
+		if err := ctx.canceled(); err != nil {
+			return 0, err
+		}
+
 		// Dereference next page node dict.
 		indRef, _ := v.(PDFIndirectRef)
 		log.Debug.Printf("parsePagesDict PageNode: %s\n", indRef)
@@ -656,6 +670,95 @@ func calcRedundantObjects(ctx *PDFContext) error {
 	return nil
 }
 
+// collectImageDigests computes the content digests of the images referenced by the XObject resources of dict
+// including the images used by form XObjects.
+func collectImageDigests(xRefTable *XRefTable, dict *PDFDict, visited IntSet, digest func(objNr int, sum [sha256.Size]byte)) error {
+
+	resourcesDict, err := resourcesDictForPageDict(xRefTable, dict, 0)
+	if err != nil || resourcesDict == nil {
+		return err
+	}
+
+	obj, found := resourcesDict.Find("XObject")
+	if !found {
+		return nil
+	}
+
+	xObjectResourcesDict, err := xRefTable.DereferenceDict(obj)
+	if err != nil || xObjectResourcesDict == nil {
+		return err
+	}
+
+	for _, v := range xObjectResourcesDict.Dict {
+
+		indRef, ok := v.(PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+		if visited[objNr] {
+			continue
+		}
+		visited[objNr] = true
+
+		o, err := xRefTable.Dereference(indRef)
+		if err != nil {
+			return err
+		}
+
+		sd, ok := o.(PDFStreamDict)
+		if !ok || sd.Subtype() == nil {
+			continue
+		}
+
+		switch *sd.Subtype() {
+
+		case "Image":
+			if sd.Raw != nil {
+				digest(objNr, sha256.Sum256(sd.Raw))
+			}
+
+		case "Form":
+			if err = collectImageDigests(xRefTable, &sd.PDFDict, visited, digest); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// prepareImageDigests loads the images of all pages concurrently and computes their content digests
+// so the detection of duplicate images only compares images of equal content.
+func prepareImageDigests(ctx *PDFContext, pageTreeRootDict *PDFDict, objNr int) error {
+
+	pages, err := collectPageNodes(ctx.XRefTable, pageTreeRootDict, objNr)
+	if err != nil {
+		return err
+	}
+
+	digests := map[int][sha256.Size]byte{}
+	var mu sync.Mutex
+
+	digest := func(objNr int, sum [sha256.Size]byte) {
+		mu.Lock()
+		digests[objNr] = sum
+		mu.Unlock()
+	}
+
+	err = ctx.processConcurrently(len(pages), func(i int) error {
+		return collectImageDigests(ctx.XRefTable, pages[i].dict, IntSet{}, digest)
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx.Optimize.imageDigests = digests
+
+	return nil
+}
+
 // Iterate over all pages and optimize resources.
 // Get rid of duplicate embedded fonts and images.
 func optimizeFontAndImages(ctx *PDFContext) error {
@@ -694,6 +797,13 @@ func optimizeFontAndImages(ctx *PDFContext) error {
 	ctx.Optimize.PageFonts = make([]IntSet, ctx.PageCount)
 	ctx.Optimize.PageImages = make([]IntSet, ctx.PageCount)
 
+	if ctx.XRefTable.Workers > 1 {
+		err = prepareImageDigests(ctx, pageTreeRootDict, indRefPages.ObjectNumber.Value())
+		if err != nil {
+			return err
+		}
+	}
+
 	// Iterate over page dicts and optimize resources.
 	_, err = parsePagesDict(ctx, pageTreeRootDict, 0)
 	if err != nil {
@@ -1014,19 +1124,490 @@ func calcBinarySizes(ctx *PDFContext) error {
 	return nil
 }
 
+// replaceIndirectRefs replaces all indirect references of obj matching an entry of m.
+// Dicts and arrays get updated in place, the returned object is meant to replace obj.
+func replaceIndirectRefs(obj PDFObject, m map[int]PDFIndirectRef) PDFObject {
+
+	switch obj := obj.(type) {
+
+	case PDFIndirectRef:
+		if indRef, found := m[obj.ObjectNumber.Value()]; found {
+			return indRef
+		}
+
+	case PDFDict:
+		for k, v := range obj.Dict {
+			obj.Dict[k] = replaceIndirectRefs(v, m)
+		}
+
+	case PDFStreamDict:
+		replaceIndirectRefs(obj.PDFDict, m)
+
+	case PDFArray:
+		for i, v := range obj {
+			obj[i] = replaceIndirectRefs(v, m)
+		}
+
+	}
+
+	return obj
+}
+
+// streamDictKey returns the string representation of a stream dict ignoring its length.
+func streamDictKey(sd PDFStreamDict) string {
+
+	d := NewPDFDict()
+	for k, v := range sd.Dict {
+		if k != "Length" {
+			d.Dict[k] = v
+		}
+	}
+
+	return d.PDFString()
+}
+
+// calcDuplicateStreams returns a map from the object numbers of streams
+// duplicating the content and stream dict of a stream with a lower object number
+// to an indirect reference of the latter.
+func calcDuplicateStreams(ctx *PDFContext) (map[int]PDFIndirectRef, error) {
+
+	xRefTable := ctx.XRefTable
+
+	// Group the candidates by stream dict first in order to avoid loading streams that cannot have a duplicate.
+	var keys []string
+	groups := map[string][]int{}
+
+	for i := 0; i < *xRefTable.Size; i++ {
+
+		entry, found := xRefTable.Find(i)
+		if !found || entry.Free || ctx.Optimize.IsDuplicateStreamObject(i) {
+			continue
+		}
+
+		sd, ok := entry.Object.(PDFStreamDict)
+		if !ok {
+			continue
+		}
+
+		// Embedded files get removed along with their object graph and therefore must not be shared.
+		if t := sd.Type(); t != nil && (*t == "ObjStm" || *t == "XRef" || *t == "EmbeddedFile") {
+			continue
+		}
+
+		k := streamDictKey(sd)
+		if _, found := groups[k]; !found {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	duplicates := map[int]PDFIndirectRef{}
+
+	for _, k := range keys {
+
+		objNrs := groups[k]
+		if len(objNrs) < 2 {
+			continue
+		}
+
+		streams := map[[sha256.Size]byte]PDFIndirectRef{}
+
+		for _, objNr := range objNrs {
+
+			if err := xRefTable.canceled(); err != nil {
+				return nil, err
+			}
+
+			entry, _ := xRefTable.Find(objNr)
+
+			if err := xRefTable.ensureStreamLoaded(entry, objNr); err != nil {
+				return nil, err
+			}
+
+			sd := entry.Object.(PDFStreamDict)
+			if sd.Raw == nil {
+				continue
+			}
+
+			sum := sha256.Sum256(sd.Raw)
+
+			if indRef, found := streams[sum]; found {
+				log.Debug.Printf("calcDuplicateStreams: obj#%d duplicates obj#%d\n", objNr, indRef.ObjectNumber)
+				duplicates[objNr] = indRef
+				continue
+			}
+
+			genNr := 0
+			if entry.Generation != nil {
+				genNr = *entry.Generation
+			}
+
+			streams[sum] = *NewPDFIndirectRef(objNr, genNr)
+		}
+	}
+
+	return duplicates, nil
+}
+
+// optimizeStreams collapses byte identical streams like content streams, form XObjects, ICC profiles
+// or appearance streams into a single object.
+// Since merging documents frequently produces such duplicates this is repeated
+// until stream dicts referring to collapsed streams do not become identical anymore.
+func optimizeStreams(ctx *PDFContext) error {
+
+	log.Debug.Println("optimizeStreams begin")
+
+	xRefTable := ctx.XRefTable
+
+	for {
+
+		duplicates, err := calcDuplicateStreams(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(duplicates) == 0 {
+			break
+		}
+
+		for objNr := range duplicates {
+			ctx.Optimize.DuplicateStreamObjs[objNr] = true
+		}
+
+		for i := 0; i < *xRefTable.Size; i++ {
+			entry, found := xRefTable.Find(i)
+			if !found || entry.Free || ctx.Optimize.IsDuplicateStreamObject(i) {
+				continue
+			}
+			entry.Object = replaceIndirectRefs(entry.Object, duplicates)
+		}
+	}
+
+	log.Debug.Println("optimizeStreams end")
+
+	return nil
+}
+
+// markReachableObjects marks all objects reachable from obj.
+func markReachableObjects(xRefTable *XRefTable, obj PDFObject, reachable IntSet) {
+
+	switch obj := obj.(type) {
+
+	case PDFIndirectRef:
+		objNr := obj.ObjectNumber.Value()
+		if reachable[objNr] {
+			return
+		}
+		reachable[objNr] = true
+		// Don't dereference in order to avoid loading lazy streams.
+		if entry, found := xRefTable.Find(objNr); found && entry != nil && !entry.Free {
+			markReachableObjects(xRefTable, entry.Object, reachable)
+		}
+
+	case PDFDict:
+		for _, v := range obj.Dict {
+			markReachableObjects(xRefTable, v, reachable)
+		}
+
+	case PDFStreamDict:
+		markReachableObjects(xRefTable, obj.PDFDict, reachable)
+
+	case PDFArray:
+		for _, v := range obj {
+			markReachableObjects(xRefTable, v, reachable)
+		}
+
+	}
+}
+
+// objectSize returns the approximate number of bytes obj takes up in a PDF file.
+func objectSize(obj PDFObject) int64 {
+
+	switch obj := obj.(type) {
+
+	case nil:
+		return 0
+
+	case PDFStreamDict:
+		size := int64(len(obj.PDFDict.PDFString()))
+		if obj.Raw != nil {
+			return size + int64(len(obj.Raw))
+		}
+		if obj.StreamLength != nil {
+			return size + *obj.StreamLength
+		}
+		return size
+
+	}
+
+	return int64(len(obj.PDFString()))
+}
+
+// isLinearizationObject returns true if the entry for object #i belongs to the linearization of the original file.
+func isLinearizationObject(ctx *PDFContext, entry *XRefTableEntry, i int) bool {
+
+	if ctx.IsLinearizationObject(i) {
+		return true
+	}
+
+	if !ctx.Read.Linearized || entry.Offset == nil {
+		return false
+	}
+
+	// Hint streams are identified by offset.
+	if _, ok := entry.Object.(PDFStreamDict); !ok {
+		return false
+	}
+
+	return (ctx.OffsetPrimaryHintTable != nil && *entry.Offset == *ctx.OffsetPrimaryHintTable) ||
+		(ctx.OffsetOverflowHintTable != nil && *entry.Offset == *ctx.OffsetOverflowHintTable)
+}
+
+// removeUnreachableObjects frees all objects not reachable from the trailer,
+// eg. leftovers of incremental updates or objects written by sloppy producers.
+// Objects already identified as redundant during optimization and linearization objects are left to the writer.
+func removeUnreachableObjects(ctx *PDFContext) error {
+
+	log.Debug.Println("removeUnreachableObjects begin")
+
+	xRefTable := ctx.XRefTable
+
+	reachable := IntSet{}
+
+	for _, indRef := range []*PDFIndirectRef{xRefTable.Root, xRefTable.Info, xRefTable.Encrypt} {
+		if indRef != nil {
+			markReachableObjects(xRefTable, *indRef, reachable)
+		}
+	}
+
+	oc := ctx.Optimize
+
+	for i := 1; i < *xRefTable.Size; i++ {
+
+		if reachable[i] {
+			continue
+		}
+
+		entry, found := xRefTable.Find(i)
+		if !found || entry == nil || entry.Free {
+			continue
+		}
+
+		if oc.IsDuplicateFontObject(i) || oc.IsDuplicateImageObject(i) || oc.IsDuplicateStreamObject(i) ||
+			oc.IsDuplicateInfoObject(i) || ctx.Read.IsObjectStreamObject(i) || ctx.Read.IsXRefStreamObject(i) ||
+			isLinearizationObject(ctx, entry, i) {
+			continue
+		}
+
+		log.Debug.Printf("removeUnreachableObjects: removing obj#%d\n", i)
+
+		oc.UnreachableObjs[i] = true
+		oc.UnreachableBytes += objectSize(entry.Object)
+
+		err := xRefTable.DeleteObject(i)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Debug.Println("removeUnreachableObjects end")
+
+	return nil
+}
+
+// recompressible returns true for uncompressed streams and streams encoded by Flate only without predictor.
+func recompressible(sd *PDFStreamDict) bool {
+
+	// XMP metadata should stay readable for tools not knowing PDF.
+	if t := sd.Type(); t != nil && (*t == "XRef" || *t == "ObjStm" || *t == "Metadata") {
+		return false
+	}
+
+	// Stream data located in an external file.
+	if _, found := sd.Find("F"); found {
+		return false
+	}
+
+	switch len(sd.FilterPipeline) {
+
+	case 0:
+		return true
+
+	case 1:
+		f := sd.FilterPipeline[0]
+		return f.Name == filter.Flate && f.DecodeParms == nil
+	}
+
+	return false
+}
+
+// recompressStream Flate encodes the content of sd at level
+// and returns the number of bytes saved or 0 if the stream was left untouched.
+func recompressStream(xRefTable *XRefTable, sd *PDFStreamDict, level int) (int64, error) {
+
+	content := sd.Content
+
+	// Free decoded content not needed before.
+	defer func() { sd.Content = content }()
+
+	err := xRefTable.decodeStream(sd)
+	if err != nil {
+		return 0, err
+	}
+
+	fi, err := filter.NewFlateFilter(nil, level)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := fi.Encode(bytes.NewReader(sd.Content))
+	if err != nil {
+		return 0, err
+	}
+
+	saved := int64(len(sd.Raw) - b.Len())
+	if saved <= 0 {
+		return 0, nil
+	}
+
+	if len(sd.FilterPipeline) == 0 {
+		sd.Insert("Filter", PDFName(filter.Flate))
+		sd.FilterPipeline = []PDFFilter{{Name: filter.Flate}}
+	}
+
+	sd.Raw = b.Bytes()
+
+	streamLength := int64(len(sd.Raw))
+	sd.StreamLength = &streamLength
+	sd.Update("Length", PDFInteger(streamLength))
+
+	return saved, nil
+}
+
+// recompressStreams recompresses uncompressed and poorly compressed streams
+// at the configured compression level whenever this reduces their size.
+func recompressStreams(ctx *PDFContext) error {
+
+	log.Debug.Println("recompressStreams begin")
+
+	xRefTable := ctx.XRefTable
+	oc := ctx.Optimize
+
+	var objNrs []int
+
+	for i := 1; i < *xRefTable.Size; i++ {
+
+		entry, found := xRefTable.Find(i)
+		if !found || entry == nil || entry.Free {
+			continue
+		}
+
+		if oc.IsDuplicateImageObject(i) || oc.IsDuplicateStreamObject(i) || isLinearizationObject(ctx, entry, i) {
+			continue
+		}
+
+		// Load lazily read streams upfront instead of during concurrent processing.
+		err := xRefTable.ensureStreamLoaded(entry, i)
+		if err != nil {
+			return err
+		}
+
+		if sd, ok := entry.Object.(PDFStreamDict); ok && recompressible(&sd) {
+			objNrs = append(objNrs, i)
+		}
+	}
+
+	saved := make([]int64, len(objNrs))
+
+	err := xRefTable.processConcurrently(len(objNrs), func(i int) error {
+
+		entry := xRefTable.Table[objNrs[i]]
+		sd := entry.Object.(PDFStreamDict)
+
+		n, err := recompressStream(xRefTable, &sd, ctx.CompressionLevel)
+		if err != nil {
+			// Leave corrupt streams alone.
+			log.Debug.Printf("recompressStreams: obj#%d: %v\n", objNrs[i], err)
+			return nil
+		}
+
+		if n > 0 {
+			log.Debug.Printf("recompressStreams: obj#%d: %d bytes saved\n", objNrs[i], n)
+			entry.Object = sd
+			saved[i] = n
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, n := range saved {
+		if n > 0 {
+			oc.RecompressedStreams++
+			oc.RecompressedBytes += n
+		}
+	}
+
+	log.Debug.Println("recompressStreams end")
+
+	return nil
+}
+
 // OptimizeXRefTable optimizes an xRefTable by locating and getting rid of redundant embedded fonts and images.
 func OptimizeXRefTable(ctx *PDFContext) error {
+	return ctx.profile(PhaseOptimize, func() error { return optimizeXRefTable(ctx) })
+}
+
+func optimizeXRefTable(ctx *PDFContext) error {
 
-	log.Info.Println("optimizing fonts & images")
+	log.Info.Println("optimizing fonts, images & streams")
 
 	log.Debug.Println("optimizeXRefTable begin")
 
+	// Fix page counts and rebuild degenerate page trees.
+	n, rebalanced, err := rebalancePageTree(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+	ctx.Optimize.FixedPageCounts = n
+	ctx.Optimize.PageTreeRebalanced = rebalanced
+
 	// Get rid of duplicate embedded fonts and images.
-	err := optimizeFontAndImages(ctx)
+	err = optimizeFontAndImages(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get rid of resources not used by any page content.
+	n, err = pruneResources(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+	ctx.Optimize.PrunedResources = n
+
+	// Get rid of any other duplicate streams.
+	err = optimizeStreams(ctx)
 	if err != nil {
 		return err
 	}
 
+	// Get rid of orphaned objects.
+	err = removeUnreachableObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Shrink uncompressed and poorly compressed streams.
+	if ctx.RecompressStreams {
+		err = recompressStreams(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Calculate memory usage of binary content for stats.
 	err = calcBinarySizes(ctx)
 	if err != nil {
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"context"
+	"io/fs"
+)
+
+// Options
+//
+// Apart from filters registered with filter.RegisterFilter pdfcpu keeps no package level configuration state,
+// every operation is controlled by the *Configuration passed in.
+// Options build configurations for individual calls on top of the defaults:
+//
+//	config := pdfcpu.NewConfiguration(pdfcpu.WithPassword("upw", "opw"), pdfcpu.WithValidationMode(pdfcpu.ValidationStrict))
+//
+// Use With to derive a configuration from a shared one. Since the shared configuration stays untouched
+// concurrent calls using different settings do not interfere.
+
+// Option modifies a Configuration.
+type Option func(*Configuration)
+
+// NewConfiguration returns the default configuration modified by opts.
+func NewConfiguration(opts ...Option) *Configuration {
+	return NewDefaultConfiguration().With(opts...)
+}
+
+// With returns a copy of c modified by opts leaving c untouched.
+func (c *Configuration) With(opts ...Option) *Configuration {
+
+	c1 := *c
+
+	// Do not share the policy map with c.
+	if c.ValidationPolicy != nil {
+		c1.ValidationPolicy = ValidationPolicy{}
+		for k, v := range c.ValidationPolicy {
+			c1.ValidationPolicy[k] = v
+		}
+	}
+
+	for _, opt := range opts {
+		opt(&c1)
+	}
+
+	return &c1
+}
+
+// WithPassword sets the user and owner password for opening or encrypting a document.
+func WithPassword(userPW, ownerPW string) Option {
+	return func(c *Configuration) {
+		c.UserPW = userPW
+		c.OwnerPW = ownerPW
+	}
+}
+
+// WithValidationMode sets the validation mode: ValidationStrict or ValidationRelaxed.
+func WithValidationMode(mode int) Option {
+	return func(c *Configuration) {
+		c.ValidationMode = mode
+	}
+}
+
+// WithValidationRule sets the action for a validation rule, see ValidationRules.
+func WithValidationRule(rule string, action int) Option {
+	return func(c *Configuration) {
+		if c.ValidationPolicy == nil {
+			c.ValidationPolicy = ValidationPolicy{}
+		}
+		c.ValidationPolicy[rule] = action
+	}
+}
+
+// WithCompression sets the zlib compression level used for Flate encoding streams
+// ranging from -1 (zlib default) and 0 (no compression) to 9 (best compression).
+func WithCompression(level int) Option {
+	return func(c *Configuration) {
+		c.CompressionLevel = level
+	}
+}
+
+// WithLazyLoading enables loading stream content on first access.
+func WithLazyLoading(lazy bool) Option {
+	return func(c *Configuration) {
+		c.LazyLoading = lazy
+	}
+}
+
+// WithContext sets the context cancelling processing.
+func WithContext(ctx context.Context) Option {
+	return func(c *Configuration) {
+		c.Context = ctx
+	}
+}
+
+// WithWorkers sets the number of goroutines processing pages concurrently.
+func WithWorkers(n int) Option {
+	return func(c *Configuration) {
+		c.Workers = n
+	}
+}
+
+// WithProducer sets the Producer written into the document info dict and the XMP metadata.
+// An empty string removes the Producer.
+func WithProducer(s string) Option {
+	return func(c *Configuration) {
+		c.ProducerOverride = &s
+	}
+}
+
+// WithCreator sets the Creator written into the document info dict and the XMP metadata.
+// An empty string removes the Creator.
+func WithCreator(s string) Option {
+	return func(c *Configuration) {
+		c.CreatorOverride = &s
+	}
+}
+
+// WithFileSystem sets the file system providing input files and the function creating output files.
+func WithFileSystem(fsys fs.FS, create CreateFunc) Option {
+	return func(c *Configuration) {
+		c.FS = fsys
+		c.CreateFile = create
+	}
+}
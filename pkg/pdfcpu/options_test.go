@@ -0,0 +1,38 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import "testing"
+
+func TestOptions(t *testing.T) {
+
+	shared := NewConfiguration(WithPassword("upw", "opw"), WithValidationRule(RuleRequired, RuleWarn))
+
+	if shared.UserPW != "upw" || shared.OwnerPW != "opw" || shared.ValidationMode != ValidationRelaxed {
+		t.Fatalf("NewConfiguration: got %+v\n", shared)
+	}
+
+	c := shared.With(WithValidationMode(ValidationStrict), WithCompression(9), WithValidationRule(RuleRequired, RuleIgnore))
+
+	if c.ValidationMode != ValidationStrict || c.CompressionLevel != 9 || c.ValidationPolicy[RuleRequired] != RuleIgnore {
+		t.Errorf("With: got %+v\n", c)
+	}
+
+	if shared.ValidationMode != ValidationRelaxed || shared.CompressionLevel == 9 || shared.ValidationPolicy[RuleRequired] != RuleWarn {
+		t.Errorf("With modified the original configuration: %+v\n", shared)
+	}
+}
@@ -0,0 +1,776 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Bookmarks
+//
+// The document outline (12.3.3) gets exported as a tree of bookmarks and may be rebuilt from such a tree.
+// A bookmark jumps to a page, to a named destination or resolves a URI.
+// Other actions are not exported.
+
+// Bookmark is an outline item along with its kids.
+type Bookmark struct {
+	Title  string      `json:"title"`
+	Page   int         `json:"page,omitempty"`   // destination page
+	View   string      `json:"view,omitempty"`   // destination view of page, eg. "Fit" or "XYZ 0 792 null", defaults to defaultDestView
+	Dest   string      `json:"dest,omitempty"`   // named destination
+	URI    string      `json:"uri,omitempty"`    // URI action
+	Bold   bool        `json:"bold,omitempty"`   // text style
+	Italic bool        `json:"italic,omitempty"` // text style
+	Color  string      `json:"color,omitempty"`  // #RRGGBB
+	Open   bool        `json:"open,omitempty"`   // kids are visible initially
+	Kids   []*Bookmark `json:"kids,omitempty"`
+}
+
+// Outline item flags, see 12.3.3 Table 153
+const (
+	outlineItalic = 1 << iota
+	outlineBold
+)
+
+// defaultDestView keeps the current position and zoom.
+const defaultDestView = "XYZ null null null"
+
+// The number of view parameters by destination type, see 12.3.2.2 Table 151
+var destViewParams = map[string]int{
+	"XYZ":   3,
+	"Fit":   0,
+	"FitH":  1,
+	"FitV":  1,
+	"FitR":  4,
+	"FitB":  0,
+	"FitBH": 1,
+	"FitBV": 1,
+}
+
+type outlineReader struct {
+	xRefTable *XRefTable
+	pages     map[int]int // page numbers by object number
+	visited   IntSet
+}
+
+func (r *outlineReader) destView(arr PDFArray) string {
+
+	var ss []string
+
+	for _, o := range arr {
+		o, _ = r.xRefTable.Dereference(o)
+		switch o := o.(type) {
+		case nil:
+			ss = append(ss, "null")
+		case PDFName:
+			ss = append(ss, o.Value())
+		case PDFInteger:
+			ss = append(ss, strconv.Itoa(o.Value()))
+		case PDFFloat:
+			ss = append(ss, strconv.FormatFloat(o.Value(), 'f', -1, 64))
+		default:
+			ss = append(ss, "null")
+		}
+	}
+
+	return strings.Join(ss, " ")
+}
+
+func (r *outlineReader) destination(o PDFObject, b *Bookmark) error {
+
+	o, err := r.xRefTable.Dereference(o)
+	if err != nil || o == nil {
+		return err
+	}
+
+	switch o := o.(type) {
+
+	case PDFName:
+		b.Dest = o.Value()
+
+	case PDFStringLiteral, PDFHexLiteral:
+		b.Dest, err = fieldValueString(r.xRefTable, o)
+
+	case PDFDict:
+		// Named destinations may map to dicts holding the destination array.
+		return r.destination(o.Dict["D"], b)
+
+	case PDFArray:
+		if len(o) == 0 {
+			return nil
+		}
+		if indRef, ok := o[0].(PDFIndirectRef); ok {
+			b.Page = r.pages[indRef.ObjectNumber.Value()]
+		}
+		if v := r.destView(o[1:]); v != defaultDestView {
+			b.View = v
+		}
+	}
+
+	return err
+}
+
+func (r *outlineReader) action(o PDFObject, b *Bookmark) error {
+
+	d, err := r.xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	switch s := d.NameEntry("S"); {
+
+	case s == nil:
+
+	case *s == "GoTo":
+		return r.destination(d.Dict["D"], b)
+
+	case *s == "URI":
+		b.URI, err = fieldValueString(r.xRefTable, d.Dict["URI"])
+	}
+
+	return err
+}
+
+func (r *outlineReader) bookmark(d *PDFDict) (*Bookmark, error) {
+
+	b := &Bookmark{}
+
+	var err error
+
+	if b.Title, err = fieldValueString(r.xRefTable, d.Dict["Title"]); err != nil {
+		return nil, err
+	}
+
+	if o, found := d.Find("Dest"); found {
+		err = r.destination(o, b)
+	} else if o, found := d.Find("A"); found {
+		err = r.action(o, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if f := d.IntEntry("F"); f != nil {
+		b.Italic = *f&outlineItalic > 0
+		b.Bold = *f&outlineBold > 0
+	}
+
+	if arr, err := r.xRefTable.DereferenceArray(d.Dict["C"]); err == nil && arr != nil && len(*arr) == 3 {
+		if c := xfdfColor(r.xRefTable, *arr); c != "#000000" {
+			b.Color = c
+		}
+	}
+
+	if c := d.IntEntry("Count"); c != nil {
+		b.Open = *c > 0
+	}
+
+	if first := d.IndirectRefEntry("First"); first != nil {
+		if b.Kids, err = r.bookmarks(*first); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func (r *outlineReader) bookmarks(first PDFIndirectRef) ([]*Bookmark, error) {
+
+	var bb []*Bookmark
+
+	for indRef := &first; indRef != nil; {
+
+		objNr := indRef.ObjectNumber.Value()
+		if r.visited[objNr] {
+			// Ignore cycles.
+			break
+		}
+		r.visited[objNr] = true
+
+		d, err := r.xRefTable.DereferenceDict(*indRef)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			break
+		}
+
+		b, err := r.bookmark(d)
+		if err != nil {
+			return nil, err
+		}
+		bb = append(bb, b)
+
+		indRef = d.IndirectRefEntry("Next")
+	}
+
+	return bb, nil
+}
+
+// Bookmarks returns the document outline, nil if there is none.
+func Bookmarks(xRefTable *XRefTable) ([]*Bookmark, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := xRefTable.DereferenceDict(rootDict.Dict["Outlines"])
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	first := d.IndirectRefEntry("First")
+	if first == nil {
+		return nil, nil
+	}
+
+	pages, err := pageNrs(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &outlineReader{xRefTable: xRefTable, pages: pages, visited: IntSet{}}
+
+	return r.bookmarks(*first)
+}
+
+func parseDestView(s string) (PDFArray, error) {
+
+	if s == "" {
+		s = defaultDestView
+	}
+
+	ss := strings.Fields(s)
+
+	n, ok := destViewParams[ss[0]]
+	if !ok || len(ss) != n+1 {
+		return nil, errors.Errorf("invalid bookmark view: %s", s)
+	}
+
+	arr := PDFArray{PDFName(ss[0])}
+
+	for _, s1 := range ss[1:] {
+		if s1 == "null" {
+			arr = append(arr, nil)
+			continue
+		}
+		f, err := strconv.ParseFloat(s1, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid bookmark view: %s", s)
+		}
+		arr = append(arr, PDFFloat(f))
+	}
+
+	return arr, nil
+}
+
+func checkBookmark(b *Bookmark, pageCount int) error {
+
+	if b.Title == "" {
+		return errors.New("bookmark: missing title")
+	}
+
+	n := 0
+	for _, ok := range []bool{b.Page != 0, b.Dest != "", b.URI != ""} {
+		if ok {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.Errorf("bookmark %q: page, dest and uri are mutually exclusive", b.Title)
+	}
+
+	if b.Page < 0 || b.Page > pageCount {
+		return errors.Errorf("bookmark %q: invalid page: %d", b.Title, b.Page)
+	}
+
+	if b.View != "" && b.Page == 0 {
+		return errors.Errorf("bookmark %q: view applies to page destinations only", b.Title)
+	}
+
+	for i := 0; i < len(b.URI); i++ {
+		if b.URI[i] >= 0x80 {
+			return errors.Errorf("bookmark %q: URI must be 7-bit ASCII: %s", b.Title, b.URI)
+		}
+	}
+
+	return nil
+}
+
+// visibleKids returns the number of descendants of an open outline item.
+func visibleKids(bb []*Bookmark) int {
+
+	n := len(bb)
+
+	for _, b := range bb {
+		if b.Open {
+			n += visibleKids(b.Kids)
+		}
+	}
+
+	return n
+}
+
+// outlineItems creates the linked list of outline items for bb and returns its first and last element.
+func outlineItems(xRefTable *XRefTable, bb []*Bookmark, parent PDFIndirectRef) (first, last *PDFIndirectRef, err error) {
+
+	var prev *PDFDict
+
+	for _, b := range bb {
+
+		if err = checkBookmark(b, xRefTable.PageCount); err != nil {
+			return nil, nil, err
+		}
+
+		d := NewPDFDict()
+		d.Insert("Title", encodeText(b.Title))
+		d.Insert("Parent", parent)
+
+		switch {
+
+		case b.Page > 0:
+			pageIndRef, err := xRefTable.PageDictIndRef(b.Page)
+			if err != nil {
+				return nil, nil, err
+			}
+			if pageIndRef == nil {
+				return nil, nil, errors.Errorf("bookmark %q: page %d not found", b.Title, b.Page)
+			}
+			view, err := parseDestView(b.View)
+			if err != nil {
+				return nil, nil, err
+			}
+			d.Insert("Dest", append(PDFArray{*pageIndRef}, view...))
+
+		case b.Dest != "":
+			d.Insert("Dest", encodeText(b.Dest))
+
+		case b.URI != "":
+			s, err := Escape(b.URI)
+			if err != nil {
+				return nil, nil, err
+			}
+			d.Insert("A", PDFDict{
+				Dict: map[string]PDFObject{
+					"S":   PDFName("URI"),
+					"URI": PDFStringLiteral(*s),
+				},
+			})
+		}
+
+		f := 0
+		if b.Italic {
+			f |= outlineItalic
+		}
+		if b.Bold {
+			f |= outlineBold
+		}
+		if f > 0 {
+			d.InsertInt("F", f)
+		}
+
+		if b.Color != "" {
+			c, err := parseXFDFColor(b.Color)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "bookmark %q", b.Title)
+			}
+			d.Insert("C", c)
+		}
+
+		indRef, err := xRefTable.IndRefForNewObject(d)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(b.Kids) > 0 {
+			kidsFirst, kidsLast, err := outlineItems(xRefTable, b.Kids, *indRef)
+			if err != nil {
+				return nil, nil, err
+			}
+			d.Insert("First", *kidsFirst)
+			d.Insert("Last", *kidsLast)
+			c := visibleKids(b.Kids)
+			if !b.Open {
+				c = -c
+			}
+			d.InsertInt("Count", c)
+		}
+
+		if prev == nil {
+			first = indRef
+		} else {
+			prev.Insert("Next", *indRef)
+			d.Insert("Prev", *last)
+		}
+
+		prev, last = &d, indRef
+	}
+
+	return first, last, nil
+}
+
+// SetBookmarks replaces the document outline by bb. An empty bb removes the outline.
+func SetBookmarks(xRefTable *XRefTable, bb []*Bookmark) error {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	if len(bb) == 0 {
+		rootDict.Delete("Outlines")
+		if m := rootDict.NameEntry("PageMode"); m != nil && *m == "UseOutlines" {
+			rootDict.Delete("PageMode")
+		}
+		return nil
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Outlines")
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	first, last, err := outlineItems(xRefTable, bb, *indRef)
+	if err != nil {
+		return err
+	}
+
+	d.Insert("First", *first)
+	d.Insert("Last", *last)
+	d.InsertInt("Count", visibleKids(bb))
+
+	// The previous outline becomes unreachable and gets dropped on write.
+	rootDict.Update("Outlines", *indRef)
+
+	log.Debug.Printf("SetBookmarks: outline obj#%d\n", indRef.ObjectNumber)
+
+	return nil
+}
+
+// GenerateBookmarks returns a flat outline with a bookmark for every n-th page.
+func GenerateBookmarks(pageCount, n int) ([]*Bookmark, error) {
+
+	if n < 1 {
+		return nil, errors.Errorf("invalid bookmark interval: %d", n)
+	}
+
+	var bb []*Bookmark
+
+	for i := 1; i <= pageCount; i += n {
+		b := &Bookmark{Title: fmt.Sprintf("Page %d", i), Page: i}
+		if j := i + n - 1; n > 1 && i < pageCount {
+			if j > pageCount {
+				j = pageCount
+			}
+			b.Title = fmt.Sprintf("Pages %d-%d", i, j)
+		}
+		bb = append(bb, b)
+	}
+
+	return bb, nil
+}
+
+// BookmarksJSON returns bb as indented JSON.
+func BookmarksJSON(bb []*Bookmark) ([]byte, error) {
+
+	if bb == nil {
+		bb = []*Bookmark{}
+	}
+
+	return json.MarshalIndent(bb, "", "\t")
+}
+
+// ParseBookmarksJSON parses bookmarks written by BookmarksJSON.
+func ParseBookmarksJSON(b []byte) ([]*Bookmark, error) {
+
+	var bb []*Bookmark
+
+	if err := json.Unmarshal(b, &bb); err != nil {
+		return nil, errors.Wrap(err, "invalid bookmarks")
+	}
+
+	return bb, nil
+}
+
+func writeBookmarksYAML(buf *bytes.Buffer, bb []*Bookmark, indent string) {
+
+	for _, b := range bb {
+
+		prefix := indent + "- "
+
+		line := func(k, v string) {
+			fmt.Fprintf(buf, "%s%s: %s\n", prefix, k, v)
+			prefix = indent + "  "
+		}
+
+		line("title", strconv.Quote(b.Title))
+
+		if b.Page > 0 {
+			line("page", strconv.Itoa(b.Page))
+		}
+		if b.View != "" {
+			line("view", strconv.Quote(b.View))
+		}
+		if b.Dest != "" {
+			line("dest", strconv.Quote(b.Dest))
+		}
+		if b.URI != "" {
+			line("uri", strconv.Quote(b.URI))
+		}
+		if b.Bold {
+			line("bold", "true")
+		}
+		if b.Italic {
+			line("italic", "true")
+		}
+		if b.Color != "" {
+			line("color", strconv.Quote(b.Color))
+		}
+		if b.Open {
+			line("open", "true")
+		}
+		if len(b.Kids) > 0 {
+			fmt.Fprintf(buf, "%skids:\n", prefix)
+			writeBookmarksYAML(buf, b.Kids, indent+"    ")
+		}
+	}
+}
+
+// BookmarksYAML returns bb as YAML sequence.
+func BookmarksYAML(bb []*Bookmark) []byte {
+
+	if len(bb) == 0 {
+		return []byte("[]\n")
+	}
+
+	var buf bytes.Buffer
+	writeBookmarksYAML(&buf, bb, "")
+
+	return buf.Bytes()
+}
+
+// yamlLine is a non empty line of a YAML document.
+type yamlLine struct {
+	nr     int
+	indent int
+	text   string
+}
+
+// bookmarksYAMLParser parses the subset of YAML used for bookmarks:
+// block sequences of block mappings holding scalars and nested sequences of bookmarks.
+type bookmarksYAMLParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func (p *bookmarksYAMLParser) errorf(format string, args ...interface{}) error {
+	nr := 0
+	if p.i < len(p.lines) {
+		nr = p.lines[p.i].nr
+	}
+	return errors.Errorf("invalid bookmarks: line %d: %s", nr, fmt.Sprintf(format, args...))
+}
+
+func yamlScalar(s string) (string, error) {
+
+	switch {
+
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", errors.New("unterminated string")
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	return s, nil
+}
+
+func yamlBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off", "":
+		return false, nil
+	}
+	return false, errors.Errorf("invalid boolean: %s", s)
+}
+
+func (p *bookmarksYAMLParser) setField(b *Bookmark, k, v string, indent int) error {
+
+	if k == "kids" {
+		if v == "[]" {
+			return nil
+		}
+		if v != "" {
+			return p.errorf("kids must be a sequence")
+		}
+		p.i++
+		if p.i == len(p.lines) || p.lines[p.i].indent < indent || !strings.HasPrefix(p.lines[p.i].text, "-") {
+			return nil
+		}
+		kids, err := p.sequence(p.lines[p.i].indent)
+		if err != nil {
+			return err
+		}
+		b.Kids = kids
+		return nil
+	}
+
+	s, err := yamlScalar(v)
+	if err != nil {
+		return p.errorf("%s: %v", k, err)
+	}
+
+	switch k {
+	case "title":
+		b.Title = s
+	case "page":
+		if b.Page, err = strconv.Atoi(s); err != nil {
+			return p.errorf("invalid page: %s", s)
+		}
+	case "view":
+		b.View = s
+	case "dest":
+		b.Dest = s
+	case "uri":
+		b.URI = s
+	case "color":
+		b.Color = s
+	case "bold":
+		b.Bold, err = yamlBool(s)
+	case "italic":
+		b.Italic, err = yamlBool(s)
+	case "open":
+		b.Open, err = yamlBool(s)
+	default:
+		return p.errorf("unknown key: %s", k)
+	}
+
+	if err != nil {
+		return p.errorf("%s: %v", k, err)
+	}
+
+	p.i++
+
+	return nil
+}
+
+func (p *bookmarksYAMLParser) keyValue(b *Bookmark, text string, indent int) error {
+
+	i := strings.Index(text, ":")
+	if i <= 0 || i+1 < len(text) && text[i+1] != ' ' {
+		return p.errorf("expected key: value")
+	}
+
+	return p.setField(b, strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), indent)
+}
+
+// sequence parses a sequence of bookmarks at indent.
+func (p *bookmarksYAMLParser) sequence(indent int) ([]*Bookmark, error) {
+
+	var bb []*Bookmark
+
+	for p.i < len(p.lines) {
+
+		l := p.lines[p.i]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent || !(l.text == "-" || strings.HasPrefix(l.text, "- ")) {
+			return nil, p.errorf("expected sequence item")
+		}
+
+		b := &Bookmark{}
+		bb = append(bb, b)
+
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		itemIndent := l.indent + len(l.text) - len(rest)
+
+		if rest == "" {
+			p.i++
+			if p.i == len(p.lines) || p.lines[p.i].indent <= indent {
+				continue
+			}
+			itemIndent = p.lines[p.i].indent
+		} else if err := p.keyValue(b, rest, itemIndent); err != nil {
+			return nil, err
+		}
+
+		for p.i < len(p.lines) && p.lines[p.i].indent == itemIndent && !strings.HasPrefix(p.lines[p.i].text, "-") {
+			if err := p.keyValue(b, p.lines[p.i].text, itemIndent); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.i < len(p.lines) && p.lines[p.i].indent > indent && p.lines[p.i].indent != itemIndent {
+			return nil, p.errorf("bad indentation")
+		}
+	}
+
+	return bb, nil
+}
+
+// ParseBookmarksYAML parses bookmarks written by BookmarksYAML.
+func ParseBookmarksYAML(b []byte) ([]*Bookmark, error) {
+
+	p := &bookmarksYAMLParser{}
+
+	for i, s := range strings.Split(string(b), "\n") {
+		s = strings.TrimRight(s, " \t\r")
+		t := strings.TrimLeft(s, " ")
+		if t == "" || strings.HasPrefix(t, "#") || t == "---" {
+			continue
+		}
+		if strings.HasPrefix(t, "\t") {
+			return nil, errors.Errorf("invalid bookmarks: line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{nr: i + 1, indent: len(s) - len(t), text: t})
+	}
+
+	if len(p.lines) == 0 || len(p.lines) == 1 && p.lines[0].text == "[]" {
+		return nil, nil
+	}
+
+	bb, err := p.sequence(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.i < len(p.lines) {
+		return nil, p.errorf("unexpected content")
+	}
+
+	return bb, nil
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Output intent subtypes, see 14.11.5.
+const (
+	OutputIntentPDFX = "GTS_PDFX"
+	OutputIntentPDFA = "GTS_PDFA1"
+)
+
+// outputIntent returns the output intent dict of the given subtype.
+func outputIntent(xRefTable *XRefTable, subtype string) (*PDFDict, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	arr, err := xRefTable.DereferenceArray(rootDict.Dict["OutputIntents"])
+	if err != nil || arr == nil {
+		return nil, err
+	}
+
+	for _, o := range *arr {
+
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		if s := d.NameEntry("S"); s != nil && *s == subtype {
+			return d, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// outputIntentProfile returns the destination output profile of the output intent d, nil if there is none.
+func outputIntentProfile(xRefTable *XRefTable, d *PDFDict) (*iccProfile, error) {
+
+	sd, err := xRefTable.DereferenceStreamDict(d.Dict["DestOutputProfile"])
+	if err != nil || sd == nil {
+		return nil, err
+	}
+
+	s := *sd
+	if err = xRefTable.decodeStream(&s); err != nil {
+		return nil, err
+	}
+
+	return newICCProfile(s.Content)
+}
+
+// embedICCProfile embeds the ICC profile b as stream object suitable for DestOutputProfile.
+func embedICCProfile(xRefTable *XRefTable, b []byte) (*PDFIndirectRef, *iccProfile, error) {
+
+	p, err := newICCProfile(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := p.components()
+	if n == 0 {
+		return nil, nil, errors.Errorf("unsupported profile color space: %s", p.dataColorSpace())
+	}
+
+	sd := &PDFStreamDict{
+		PDFDict:        NewPDFDict(),
+		Content:        b,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate}},
+	}
+	sd.InsertName("Filter", filter.Flate)
+	sd.InsertInt("N", n)
+
+	if err = encodeStream(sd); err != nil {
+		return nil, nil, err
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return indRef, p, nil
+}
+
+// AddOutputIntent embeds the ICC profile b as destination output profile of an output intent
+// of the given subtype, one of OutputIntentPDFX and OutputIntentPDFA, see 14.11.5.
+// Any existing output intent of this subtype is replaced.
+// The output condition identifier defaults to the profile description.
+func AddOutputIntent(xRefTable *XRefTable, b []byte, subtype, identifier string) error {
+
+	log.Debug.Printf("AddOutputIntent begin: %s\n", subtype)
+
+	if subtype != OutputIntentPDFX && subtype != OutputIntentPDFA {
+		return errors.Errorf("AddOutputIntent: unsupported output intent subtype: %s", subtype)
+	}
+
+	profile, p, err := embedICCProfile(xRefTable, b)
+	if err != nil {
+		return errors.Wrap(err, "AddOutputIntent")
+	}
+
+	if identifier == "" {
+		identifier = p.description()
+	}
+
+	if identifier == "" {
+		identifier = "Custom"
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "OutputIntent")
+	d.InsertName("S", subtype)
+	d.Insert("OutputConditionIdentifier", PDFStringLiteral(identifier))
+	d.Insert("Info", PDFStringLiteral(identifier))
+	d.Insert("DestOutputProfile", *profile)
+
+	indRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	arr, err := xRefTable.DereferenceArray(rootDict.Dict["OutputIntents"])
+	if err != nil {
+		return err
+	}
+
+	intents := PDFArray{}
+
+	if arr != nil {
+		for _, o := range *arr {
+			if od, err := xRefTable.DereferenceDict(o); err == nil && od != nil && od.NameEntry("S") != nil && *od.NameEntry("S") == subtype {
+				continue
+			}
+			intents = append(intents, o)
+		}
+	}
+
+	rootDict.Update("OutputIntents", append(intents, *indRef))
+
+	log.Debug.Println("AddOutputIntent end")
+
+	return nil
+}
+
+// ListOutputIntents returns a description of the output intents of a document
+// along with their destination output profiles.
+func ListOutputIntents(ctx *PDFContext) ([]string, error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	arr, err := ctx.DereferenceArray(rootDict.Dict["OutputIntents"])
+	if err != nil {
+		return nil, err
+	}
+
+	if arr == nil || len(*arr) == 0 {
+		return []string{"no output intents available"}, nil
+	}
+
+	var ss []string
+
+	for _, o := range *arr {
+
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		s := "?"
+		if st := d.NameEntry("S"); st != nil {
+			s = *st
+		}
+
+		for _, k := range []string{"OutputConditionIdentifier", "OutputCondition", "RegistryName", "Info"} {
+			if v, err := textString(ctx, d.Dict[k]); err == nil && v != "" {
+				s += fmt.Sprintf(" %s=%q", k, v)
+			}
+		}
+
+		p, err := outputIntentProfile(ctx.XRefTable, d)
+		switch {
+		case err != nil:
+			s += fmt.Sprintf(" profile: %v", err)
+		case p == nil:
+			s += " profile: none"
+		default:
+			s += fmt.Sprintf(" profile: %q %s %s v%s %d bytes", p.description(), strings.TrimSpace(p.dataColorSpace()), p.class(), p.version(), len(p.b))
+		}
+
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// OutputIntentProfile returns the destination output profile of the output intent of the given subtype.
+func OutputIntentProfile(xRefTable *XRefTable, subtype string) ([]byte, error) {
+
+	d, err := outputIntent(xRefTable, subtype)
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		return nil, errors.Errorf("no output intent %s available", subtype)
+	}
+
+	p, err := outputIntentProfile(xRefTable, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if p == nil {
+		return nil, errors.Errorf("output intent %s has no embedded profile", subtype)
+	}
+
+	return p.b, nil
+}
+
+// ReplaceOutputIntentProfile replaces the destination output profile of the output intent of the given subtype by the ICC profile b.
+// The output condition identifier is kept, Info is set to the profile description.
+func ReplaceOutputIntentProfile(xRefTable *XRefTable, subtype string, b []byte) error {
+
+	d, err := outputIntent(xRefTable, subtype)
+	if err != nil {
+		return err
+	}
+
+	if d == nil {
+		return errors.Errorf("no output intent %s available", subtype)
+	}
+
+	profile, p, err := embedICCProfile(xRefTable, b)
+	if err != nil {
+		return errors.Wrap(err, "ReplaceOutputIntentProfile")
+	}
+
+	if old, err := outputIntentProfile(xRefTable, d); err == nil && old != nil && old.components() != p.components() {
+		log.Info.Printf("ReplaceOutputIntentProfile: color space changes from %s to %s\n", old.dataColorSpace(), p.dataColorSpace())
+	}
+
+	d.Update("DestOutputProfile", *profile)
+
+	if s := p.description(); s != "" {
+		d.Update("Info", PDFStringLiteral(s))
+	}
+
+	return nil
+}
+
+// RemoveOutputIntents removes the output intents matching any of the given subtypes or all output intents if subtypes is empty.
+// It returns the number of removed output intents.
+func RemoveOutputIntents(xRefTable *XRefTable, subtypes []string) (int, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return 0, err
+	}
+
+	arr, err := xRefTable.DereferenceArray(rootDict.Dict["OutputIntents"])
+	if err != nil || arr == nil {
+		return 0, err
+	}
+
+	selected := func(d *PDFDict) bool {
+		if len(subtypes) == 0 {
+			return true
+		}
+		s := d.NameEntry("S")
+		for _, st := range subtypes {
+			if s != nil && *s == st {
+				return true
+			}
+		}
+		return false
+	}
+
+	intents := PDFArray{}
+
+	for _, o := range *arr {
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return 0, err
+		}
+		if d != nil && selected(d) {
+			continue
+		}
+		intents = append(intents, o)
+	}
+
+	n := len(*arr) - len(intents)
+
+	if len(intents) == 0 {
+		rootDict.Delete("OutputIntents")
+	} else {
+		rootDict.Update("OutputIntents", intents)
+	}
+
+	return n, nil
+}
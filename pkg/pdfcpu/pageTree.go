@@ -0,0 +1,405 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Page tree rebalancing
+//
+// Producers frequently write degenerate page trees like thousands of pages attached to a single node
+// or chains of intermediate nodes with a single kid each. Viewers open such files slowly.
+//
+// A degenerate page tree gets rebuilt as a balanced tree of at most maxPageTreeKids kids per node.
+// The page tree root dict is kept, all other intermediate nodes are replaced.
+// Attributes inherited from replaced nodes are moved to the pages.
+// Wrong Count entries get fixed regardless.
+
+// maxPageTreeKids is the maximum number of kids per node of a rebalanced page tree.
+const maxPageTreeKids = 32
+
+// The page attributes inheritable from page tree nodes, see 7.7.3.4
+var inheritablePageAttrs = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// inheritedAttr is a page attribute along with the page tree node defining it.
+type inheritedAttr struct {
+	obj   PDFObject
+	objNr int
+}
+
+// pageTreeLeaf is a page along with the attributes it inherits from intermediate page tree nodes.
+type pageTreeLeaf struct {
+	indRef PDFIndirectRef
+	dict   *PDFDict
+	attrs  map[string]inheritedAttr
+}
+
+// pageTreeKid is a node of a page tree under construction.
+type pageTreeKid struct {
+	indRef PDFIndirectRef
+	dict   *PDFDict
+	count  int
+}
+
+type pageTreeScan struct {
+	leaves      []pageTreeLeaf
+	nodes       []int  // Intermediate nodes in page order.
+	pages       IntSet // Pages seen so far.
+	shared      bool   // True if a page is a kid of more than one node.
+	degenerate  bool
+	fixedCounts int
+}
+
+func (s *pageTreeScan) scan(xRefTable *XRefTable, dict *PDFDict, path []int, attrs map[string]inheritedAttr) (int, error) {
+
+	objNr := path[len(path)-1]
+
+	// The attributes of the root stay in place.
+	if len(path) > 1 {
+		for _, k := range inheritablePageAttrs {
+			if obj, found := dict.Find(k); found && obj != nil {
+				m := map[string]inheritedAttr{}
+				for name, a := range attrs {
+					m[name] = a
+				}
+				m[k] = inheritedAttr{obj, objNr}
+				attrs = m
+			}
+		}
+	}
+
+	kids := dict.PDFArrayEntry("Kids")
+	if kids == nil {
+		return 0, errors.New("scanPageTree: corrupt \"Kids\" entry")
+	}
+
+	if len(*kids) > maxPageTreeKids || (len(path) > 1 && len(*kids) < 2) {
+		s.degenerate = true
+	}
+
+	count := 0
+
+	for _, obj := range *kids {
+
+		if obj == nil {
+			s.degenerate = true
+			continue
+		}
+
+		indRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			return 0, errors.New("scanPageTree: missing indirect reference for kid")
+		}
+
+		kidObjNr := indRef.ObjectNumber.Value()
+
+		for i, nr := range path {
+			if nr == kidObjNr {
+				return 0, xRefTable.reportCycle("scanPageTree", path[i:])
+			}
+		}
+
+		d, err := xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return 0, err
+		}
+
+		dictType, err := dictTypeForPageNodeDict(d)
+		if err != nil {
+			return 0, err
+		}
+
+		switch dictType {
+
+		case "Pages":
+			s.nodes = append(s.nodes, kidObjNr)
+			n, err := s.scan(xRefTable, d, append(path, kidObjNr), attrs)
+			if err != nil {
+				return 0, err
+			}
+			count += n
+
+		case "Page":
+			if s.pages[kidObjNr] {
+				s.shared = true
+			}
+			s.pages[kidObjNr] = true
+			s.leaves = append(s.leaves, pageTreeLeaf{indRef, d, attrs})
+			count++
+
+		default:
+			return 0, errors.Errorf("scanPageTree: Unexpected dict type: %s", dictType)
+		}
+	}
+
+	if c := dict.IntEntry("Count"); c == nil || *c != count {
+		log.Debug.Printf("scanPageTree: obj#%d: fixing Count => %d\n", objNr, count)
+		dict.Update("Count", PDFInteger(count))
+		s.fixedCounts++
+	}
+
+	return count, nil
+}
+
+// inheritPageAttrs moves the attributes pages inherit from intermediate nodes to the pages.
+func inheritPageAttrs(xRefTable *XRefTable, leaves []pageTreeLeaf) error {
+
+	// Direct resource dicts get shared as indirect objects.
+	resources := map[int]PDFIndirectRef{}
+
+	for _, l := range leaves {
+
+		for k, a := range l.attrs {
+
+			if _, found := l.dict.Find(k); found {
+				continue
+			}
+
+			obj := a.obj
+
+			if d, ok := obj.(PDFDict); ok && k == "Resources" {
+				indRef, ok := resources[a.objNr]
+				if !ok {
+					ir, err := xRefTable.IndRefForNewObject(d)
+					if err != nil {
+						return err
+					}
+					indRef = *ir
+					resources[a.objNr] = indRef
+				}
+				obj = indRef
+			}
+
+			l.dict.Insert(k, obj)
+		}
+	}
+
+	return nil
+}
+
+// balancedPageTreeLevel groups kids into as few nodes of at most maxPageTreeKids kids of about the same size as possible.
+func balancedPageTreeLevel(xRefTable *XRefTable, kids []pageTreeKid) ([]pageTreeKid, error) {
+
+	n := (len(kids) + maxPageTreeKids - 1) / maxPageTreeKids
+
+	nodes := make([]pageTreeKid, n)
+
+	for i := 0; i < n; i++ {
+
+		group := kids[i*len(kids)/n : (i+1)*len(kids)/n]
+
+		d := NewPDFDict()
+		d.InsertName("Type", "Pages")
+
+		indRef, err := xRefTable.IndRefForNewObject(d)
+		if err != nil {
+			return nil, err
+		}
+
+		arr := make(PDFArray, len(group))
+		count := 0
+
+		for j, kid := range group {
+			kid.dict.Update("Parent", *indRef)
+			arr[j] = kid.indRef
+			count += kid.count
+		}
+
+		d.Insert("Kids", arr)
+		d.InsertInt("Count", count)
+
+		nodes[i] = pageTreeKid{*indRef, &d, count}
+	}
+
+	return nodes, nil
+}
+
+// rebalancePageTree fixes wrong Count entries and rebuilds a degenerate page tree.
+// It returns the number of fixed Count entries and true if the page tree has been rebuilt.
+func rebalancePageTree(xRefTable *XRefTable) (int, bool, error) {
+
+	log.Debug.Println("rebalancePageTree begin")
+
+	indRef, err := xRefTable.Pages()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if indRef == nil {
+		return 0, false, errors.New("rebalancePageTree: missing page tree root")
+	}
+
+	root, err := xRefTable.DereferenceDict(*indRef)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if root == nil {
+		return 0, false, errors.New("rebalancePageTree: missing page tree root")
+	}
+
+	s := pageTreeScan{pages: IntSet{}}
+
+	pageCount, err := s.scan(xRefTable, root, []int{indRef.ObjectNumber.Value()}, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	xRefTable.PageCount = pageCount
+
+	if !s.degenerate {
+		log.Debug.Println("rebalancePageTree end: page tree ok")
+		return s.fixedCounts, false, nil
+	}
+
+	if s.shared {
+		// Pages with multiple parents can't be rebuilt into a tree.
+		log.Debug.Println("rebalancePageTree end: shared pages")
+		return s.fixedCounts, false, nil
+	}
+
+	if err = inheritPageAttrs(xRefTable, s.leaves); err != nil {
+		return 0, false, err
+	}
+
+	kids := make([]pageTreeKid, len(s.leaves))
+	for i, l := range s.leaves {
+		kids[i] = pageTreeKid{l.indRef, l.dict, 1}
+	}
+
+	for len(kids) > maxPageTreeKids {
+		if kids, err = balancedPageTreeLevel(xRefTable, kids); err != nil {
+			return 0, false, err
+		}
+	}
+
+	arr := make(PDFArray, len(kids))
+	for i, kid := range kids {
+		kid.dict.Update("Parent", *indRef)
+		arr[i] = kid.indRef
+	}
+
+	root.Update("Kids", arr)
+	root.Update("Count", PDFInteger(pageCount))
+
+	for _, objNr := range s.nodes {
+		if err = xRefTable.DeleteObject(objNr); err != nil {
+			return 0, false, err
+		}
+	}
+
+	log.Debug.Printf("rebalancePageTree end: %d pages, %d nodes replaced\n", pageCount, len(s.nodes))
+
+	return s.fixedCounts, true, nil
+}
+
+// removePages removes the selected pages below the page tree node indRef and returns the number of pages kept.
+// p counts the pages seen so far.
+func removePages(xRefTable *XRefTable, indRef PDFIndirectRef, p *int, pages IntSet) (int, error) {
+
+	dict, err := xRefTable.DereferenceDict(indRef)
+	if err != nil || dict == nil {
+		return 0, err
+	}
+
+	if t := dict.Type(); t != nil && *t == "Page" {
+		*p++
+		if pages[*p] {
+			return 0, nil
+		}
+		return 1, nil
+	}
+
+	kids := dict.PDFArrayEntry("Kids")
+	if kids == nil {
+		return 0, nil
+	}
+
+	arr := PDFArray{}
+	count := 0
+
+	for _, obj := range *kids {
+
+		ir, ok := obj.(PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		c, err := removePages(xRefTable, ir, p, pages)
+		if err != nil {
+			return 0, err
+		}
+
+		if c == 0 {
+			continue
+		}
+
+		arr = append(arr, ir)
+		count += c
+	}
+
+	dict.Update("Kids", arr)
+	dict.Update("Count", PDFInteger(count))
+
+	return count, nil
+}
+
+// RemovePages removes the selected pages from the page tree.
+// Intermediate nodes left without pages get removed as well. At least one page has to remain.
+// Removed objects are only unlinked from the page tree because outlines or link annotations may still refer to them.
+// Unless referenced elsewhere they are not going to be written.
+func RemovePages(xRefTable *XRefTable, pages IntSet) error {
+
+	n := 0
+	for i, v := range pages {
+		if v && i >= 1 && i <= xRefTable.PageCount {
+			n++
+		}
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	if n == xRefTable.PageCount {
+		return errors.New("pdfcpu: removePages: can't remove all pages")
+	}
+
+	indRef, err := xRefTable.Pages()
+	if err != nil {
+		return err
+	}
+
+	if indRef == nil {
+		return errors.New("pdfcpu: removePages: missing page tree root")
+	}
+
+	p := 0
+
+	pageCount, err := removePages(xRefTable, *indRef, &p, pages)
+	if err != nil {
+		return err
+	}
+
+	xRefTable.PageCount = pageCount
+
+	log.Debug.Printf("RemovePages: %d pages removed, %d pages left\n", n, pageCount)
+
+	return nil
+}
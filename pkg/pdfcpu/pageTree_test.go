@@ -0,0 +1,173 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import "testing"
+
+// checkPageTree verifies Kids, Count and Parent of the page tree rooted at objNr
+// and returns its pages in page order along with its depth.
+func checkPageTree(t *testing.T, xRefTable *XRefTable, objNr int) ([]int, int) {
+
+	d, err := xRefTable.DereferenceDict(*NewPDFIndirectRef(objNr, 0))
+	if err != nil || d == nil {
+		t.Fatalf("obj#%d: missing page tree node: %v\n", objNr, err)
+	}
+
+	if *d.Type() == "Page" {
+		return []int{objNr}, 0
+	}
+
+	kids := d.PDFArrayEntry("Kids")
+	if len(*kids) > maxPageTreeKids {
+		t.Errorf("obj#%d: %d kids\n", objNr, len(*kids))
+	}
+
+	var pages []int
+	depth := 0
+
+	for _, o := range *kids {
+
+		kid := o.(PDFIndirectRef)
+
+		kd, _ := xRefTable.DereferenceDict(kid)
+		if p := kd.IndirectRefEntry("Parent"); p == nil || p.ObjectNumber.Value() != objNr {
+			t.Errorf("obj#%d: wrong parent %v\n", kid.ObjectNumber.Value(), p)
+		}
+
+		pp, dd := checkPageTree(t, xRefTable, kid.ObjectNumber.Value())
+		pages = append(pages, pp...)
+		if dd+1 > depth {
+			depth = dd + 1
+		}
+	}
+
+	if c := d.IntEntry("Count"); c == nil || *c != len(pages) {
+		t.Errorf("obj#%d: wrong Count %v, want %d\n", objNr, c, len(pages))
+	}
+
+	return pages, depth
+}
+
+func TestRebalancePageTree(t *testing.T) {
+
+	xRefTable, err := createXRefTableWithRootDict()
+	if err != nil {
+		t.Fatalf("createXRefTableWithRootDict: %v\n", err)
+	}
+
+	rootDict, _ := xRefTable.Catalog()
+
+	pagesDict := NewPDFDict()
+	pagesDict.InsertName("Type", "Pages")
+	pagesIndRef, _ := xRefTable.IndRefForNewObject(pagesDict)
+	rootDict.Insert("Pages", *pagesIndRef)
+
+	newNode := func(parent *PDFIndirectRef, pdfType string) (*PDFIndirectRef, PDFDict) {
+		d := NewPDFDict()
+		d.InsertName("Type", pdfType)
+		d.Insert("Parent", *parent)
+		if pdfType == "Pages" {
+			d.Insert("Kids", PDFArray{})
+			d.InsertInt("Count", 1)
+		}
+		indRef, _ := xRefTable.IndRefForNewObject(d)
+		return indRef, d
+	}
+
+	addKid := func(parent PDFDict, kid *PDFIndirectRef) {
+		parent.Update("Kids", append(*parent.PDFArrayEntry("Kids"), *kid))
+	}
+
+	var want []int
+
+	// 1000 pages attached to the root.
+	kids := PDFArray{}
+	for i := 0; i < 1000; i++ {
+		indRef, _ := newNode(pagesIndRef, "Page")
+		kids = append(kids, *indRef)
+		want = append(want, indRef.ObjectNumber.Value())
+	}
+
+	// Followed by a chain of single kid nodes, the first one defining a MediaBox.
+	parentIndRef, parent := pagesIndRef, pagesDict
+	pagesDict.Insert("Kids", kids)
+	pagesDict.InsertInt("Count", 4711)
+
+	var chain []int
+
+	for i := 0; i < 10; i++ {
+		indRef, d := newNode(parentIndRef, "Pages")
+		if i == 0 {
+			d.Insert("MediaBox", NewRectangle(0, 0, 100, 100))
+		}
+		addKid(parent, indRef)
+		chain = append(chain, indRef.ObjectNumber.Value())
+		parentIndRef, parent = indRef, d
+	}
+
+	pageIndRef, pageDict := newNode(parentIndRef, "Page")
+	addKid(parent, pageIndRef)
+	want = append(want, pageIndRef.ObjectNumber.Value())
+
+	n, rebalanced, err := rebalancePageTree(xRefTable)
+	if err != nil {
+		t.Fatalf("rebalancePageTree: %v\n", err)
+	}
+
+	if !rebalanced {
+		t.Fatal("rebalancePageTree: page tree not rebalanced")
+	}
+
+	if n != 1 {
+		t.Errorf("rebalancePageTree: got %d fixed counts, want 1\n", n)
+	}
+
+	if xRefTable.PageCount != 1001 {
+		t.Errorf("rebalancePageTree: got %d pages, want 1001\n", xRefTable.PageCount)
+	}
+
+	got, depth := checkPageTree(t, xRefTable, pagesIndRef.ObjectNumber.Value())
+
+	if depth != 2 {
+		t.Errorf("rebalancePageTree: got depth %d, want 2\n", depth)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("rebalancePageTree: got %d pages, want %d\n", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("rebalancePageTree: page %d: got obj#%d, want obj#%d\n", i+1, got[i], want[i])
+		}
+	}
+
+	if pageDict.PDFArrayEntry("MediaBox") == nil {
+		t.Error("rebalancePageTree: inherited MediaBox lost")
+	}
+
+	for _, objNr := range chain {
+		if entry, _ := xRefTable.Find(objNr); !entry.Free {
+			t.Errorf("rebalancePageTree: obj#%d not freed\n", objNr)
+		}
+	}
+
+	// A balanced tree stays untouched.
+	if n, rebalanced, err = rebalancePageTree(xRefTable); err != nil || n != 0 || rebalanced {
+		t.Errorf("rebalancePageTree: got %d fixed counts, rebalanced=%t, err=%v\n", n, rebalanced, err)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Concurrent page processing
+//
+// Pages get processed by a pool of xRefTable.Workers goroutines sharing the xRefTable.
+// Workers only read the object graph with the following exceptions guarded by xRefTable:
+//
+// - Warnings and Cycles get recorded under xRefTable.mu.
+// - Lazily read streams get loaded on first access under xRefTable.loadMu.
+//
+// The same holds for read-only operations sharing a PDFContext, see PDFContext.
+// Extracted content gets decoded into copies leaving shared objects untouched.
+//
+// Anything depending on page order, like the registration of fonts and images for optimization,
+// is done sequentially once the concurrent phase is over.
+
+// pageNode is a page dict along with the page attributes it inherits from the page tree.
+type pageNode struct {
+	dict                      *PDFDict
+	objNr, genNr              int
+	hasResources, hasMediaBox bool
+}
+
+// processConcurrently calls f for 0 <= i < n using a pool of xRefTable.Workers goroutines.
+// Once a call fails the remaining calls are skipped.
+// The error returned is the one for the smallest i regardless of scheduling.
+func (xRefTable *XRefTable) processConcurrently(n int, f func(i int) error) error {
+
+	workers := xRefTable.Workers
+	if workers > n {
+		workers = n
+	}
+
+	if workers < 2 {
+		for i := 0; i < n; i++ {
+			if err := xRefTable.canceled(); err != nil {
+				return err
+			}
+			if err := f(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	var failed int32
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if atomic.LoadInt32(&failed) == 1 {
+					continue
+				}
+				err := xRefTable.canceled()
+				if err == nil {
+					err = f(i)
+				}
+				if err != nil {
+					errs[i] = err
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectPageNodes returns the page dicts of the page tree rooted at dict in page order.
+func collectPageNodes(xRefTable *XRefTable, dict *PDFDict, objNr int) ([]pageNode, error) {
+
+	var pages []pageNode
+
+	err := collectPages(xRefTable, dict, false, false, []int{objNr}, &pages)
+
+	return pages, err
+}
+
+func collectPages(xRefTable *XRefTable, dict *PDFDict, hasResources, hasMediaBox bool, path []int, pages *[]pageNode) error {
+
+	if _, found := dict.Find("Resources"); found {
+		hasResources = true
+	}
+
+	if _, found := dict.Find("MediaBox"); found {
+		hasMediaBox = true
+	}
+
+	kidsArray := dict.PDFArrayEntry("Kids")
+	if kidsArray == nil {
+		return errors.New("collectPages: corrupt \"Kids\" entry")
+	}
+
+	for _, obj := range *kidsArray {
+
+		if obj == nil {
+			continue
+		}
+
+		indRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			return errors.New("collectPages: missing indirect reference for kid")
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+
+		for i, nr := range path {
+			if nr == objNr {
+				return xRefTable.reportCycle("collectPages", path[i:])
+			}
+		}
+
+		d, err := xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		dictType, err := dictTypeForPageNodeDict(d)
+		if err != nil {
+			return err
+		}
+
+		switch dictType {
+
+		case "Pages":
+			err = collectPages(xRefTable, d, hasResources, hasMediaBox, append(path, objNr), pages)
+			if err != nil {
+				return err
+			}
+
+		case "Page":
+			*pages = append(*pages, pageNode{d, objNr, indRef.GenerationNumber.Value(), hasResources, hasMediaBox})
+
+		default:
+			return errors.Errorf("collectPages: Unexpected dict type: %s", dictType)
+		}
+	}
+
+	return nil
+}
@@ -22,7 +22,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"unicode"
 
 	"github.com/pkg/errors"
 )
@@ -48,20 +47,87 @@ var (
 	errXrefStreamCorruptIndex  = errors.New("parse: xref stream dict corrupt entry Index")
 	errObjStreamMissingN       = errors.New("parse: obj stream dict missing entry W")
 	errObjStreamMissingFirst   = errors.New("parse: obj stream dict missing entry First")
+	errNestingTooDeep          = errors.New("parse: arrays and dictionaries nested too deep")
 )
 
+// maxNestingDepth limits the nesting of arrays and dictionaries
+// so that adversarial input cannot exhaust the stack.
+const maxNestingDepth = 256
+
+// debugParse turns on the debug log of the parser.
+// The parser is a hot path, so debug log statements are guarded
+// in order to avoid evaluating their arguments.
+const debugParse = false
+
+// Character classes, see 7.2.2
+const (
+	charSpace = 1 << iota
+	charDelimiter
+)
+
+var charClass [256]byte
+
+// pdfNames holds frequently used names.
+// Parsed names never reference the parse buffer in order to avoid keeping it alive:
+// frequently used names are interned, all others get copied, see internName.
+var pdfNames map[string]PDFName
+
 func init() {
 
 	logDebugParse = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	// Turn on debugParse too.
 	//logDebugParse = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	logInfoParse = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+
+	for _, c := range []byte(" \t\n\v\f\r") {
+		charClass[c] = charSpace
+	}
+
+	for _, c := range []byte("<>[]()/") {
+		charClass[c] = charDelimiter
+	}
+
+	pdfNames = map[string]PDFName{}
+
+	for _, s := range []string{
+		"A", "AA", "AcroForm", "Annot", "Annots", "AP", "ArtBox", "Ascent", "Author",
+		"BaseEncoding", "BaseFont", "BBox", "BitsPerComponent", "BleedBox", "Border", "Bounds",
+		"CapHeight", "Catalog", "CharProcs", "CharSet", "CIDFontType0", "CIDFontType2", "CIDSystemInfo",
+		"CIDToGIDMap", "ColorSpace", "Columns", "Contents", "Count", "CreationDate", "Creator", "CropBox",
+		"D", "DA", "Decode", "DecodeParms", "DescendantFonts", "Descent", "Dest", "Dests",
+		"DeviceCMYK", "DeviceGray", "DeviceRGB", "Differences", "DR", "DW",
+		"Encoding", "Encrypt", "ExtGState", "F", "Fields", "Filter", "First", "FirstChar",
+		"FitH", "FitR", "Flags", "FlateDecode", "Font", "FontBBox", "FontDescriptor", "FontFile",
+		"FontFile2", "FontFile3", "FontName", "Form", "FT", "Functions", "Group",
+		"H", "Height", "ID", "Identity", "Identity-H", "Image", "ImageB", "ImageC", "ImageI",
+		"Index", "Info", "ItalicAngle", "JavaScript", "Kids", "Last", "LastChar", "Length",
+		"Length1", "Length2", "Length3", "Limits", "Link", "Mask", "Matrix", "MaxWidth",
+		"MediaBox", "Metadata", "MissingWidth", "ModDate", "N", "Names", "Next",
+		"ObjStm", "OpenAction", "Ordering", "Outlines", "P", "Page", "PageLabels", "PageLayout",
+		"PageMode", "Pages", "Parent", "Pattern", "PDF", "Predictor", "Prev", "Producer", "ProcSet",
+		"Rect", "Registry", "Resources", "Root", "Rotate", "S", "Shading", "Size", "SMask",
+		"StandardEncoding", "StemV", "Subtype", "Supplement", "Text", "Title", "ToUnicode",
+		"TrimBox", "TrueType", "Type", "Type0", "Type1", "Type3", "URI", "W", "WinAnsiEncoding",
+		"Width", "Widths", "XObject", "XRef", "XRefStm", "XYZ",
+	} {
+		pdfNames[s] = PDFName(s)
+	}
+}
+
+// pdfSpace returns true for PDF whitespace.
+func pdfSpace(c byte) bool {
+	return charClass[c] == charSpace
+}
+
+func delimiter(b byte) bool {
+	return charClass[b] == charDelimiter
 }
 
 func positionToNextWhitespace(s string) (int, string) {
 
-	for i, c := range s {
-		if unicode.IsSpace(c) {
+	for i := 0; i < len(s); i++ {
+		if pdfSpace(s[i]) {
 			return i, s[i:]
 		}
 	}
@@ -75,13 +141,9 @@ func positionToNextWhitespaceOrChar(s, chars string) (int, string) {
 		return positionToNextWhitespace(s)
 	}
 
-	if len(chars) > 0 {
-		for i, c := range s {
-			for _, m := range chars {
-				if c == m || unicode.IsSpace(c) {
-					return i, s[i:]
-				}
-			}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; pdfSpace(c) || strings.IndexByte(chars, c) >= 0 {
+			return i, s[i:]
 		}
 	}
 	return 0, s
@@ -89,84 +151,77 @@ func positionToNextWhitespaceOrChar(s, chars string) (int, string) {
 
 func positionToNextEOL(s string) string {
 
-	chars := "\x0A\x0D"
-
-	for i, c := range s {
-		for _, m := range chars {
-			if c == m {
-				return s[i:]
-			}
-		}
+	i := strings.IndexAny(s, "\x0A\x0D")
+	if i < 0 {
+		return ""
 	}
-	return ""
+	return s[i:]
 }
 
 // trimLeftSpace trims leading whitespace and trailing comment.
 func trimLeftSpace(s string) (outstr string, trimmedSpaces int) {
 
-	logDebugParse.Printf("TrimLeftSpace: begin %s\n", s)
-
-	whitespace := func(c rune) bool { return unicode.IsSpace(c) }
-
 	outstr = s
 
 	for {
 		// trim leading whitespace
-		outstr = strings.TrimLeftFunc(outstr, whitespace)
-		logDebugParse.Printf("1 outstr: <%s>\n", outstr)
+		i := 0
+		for i < len(outstr) && pdfSpace(outstr[i]) {
+			i++
+		}
+		outstr = outstr[i:]
 		if len(outstr) <= 1 || outstr[0] != '%' {
 			break
 		}
 		// trim PDF comment (= '%' up to eol)
 		outstr = positionToNextEOL(outstr)
-		logDebugParse.Printf("2 outstr: <%s>\n", outstr)
-
 	}
 
 	trimmedSpaces = len(s) - len(outstr)
 
-	logDebugParse.Printf("TrimLeftSpace: end %s %d\n", outstr, trimmedSpaces)
-
 	return outstr, trimmedSpaces
 }
 
 // HexString validates and formats a hex string to be of even length.
-func hexString(s string) (*string, bool) {
-
-	logDebugParse.Printf("HexString(%s)\n", s)
-
-	if len(s) == 0 {
-		s1 := ""
-		return &s1, true
-	}
+func hexString(s string) (string, bool) {
 
-	uc := strings.ToUpper(s)
+	lowerCase := false
 
-	for _, c := range uc {
-		logDebugParse.Printf("checking <%c>\n", c)
-		isHexChar := false
-		for _, hexch := range "ABCDEF1234567890" {
-			logDebugParse.Printf("checking against <%c>\n", hexch)
-			if c == hexch {
-				isHexChar = true
-				break
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9', c >= 'A' && c <= 'F':
+		case c >= 'a' && c <= 'f':
+			lowerCase = true
+		default:
+			if debugParse {
+				logDebugParse.Printf("hexString: invalid char <%c>\n", c)
 			}
-		}
-		if !isHexChar {
-			logDebugParse.Println("isHexStr returning false")
-			return nil, false
+			return "", false
 		}
 	}
 
-	logDebugParse.Println("isHexStr returning true")
+	if lowerCase {
+		s = strings.ToUpper(s)
+	}
 
 	// If the final digit of a hexadecimal string is missing -
 	// that is, if there is an odd number of digits - the final digit shall be assumed to be 0.
-	if len(uc)%2 == 1 {
-		uc = uc + "0"
+	if len(s)%2 == 1 {
+		s = s + "0"
 	}
 
-	return &uc, true
+	return s, true
+}
+
+// internName returns the name for s without referencing the parse buffer.
+// Frequently used names are shared, all others get copied.
+func internName(s string) PDFName {
+
+	if n, ok := pdfNames[s]; ok {
+		return n
+	}
+
+	return PDFName(strings.Clone(s))
 }
 
 // balancedParenthesesPrefix returns the index of the end position of the balanced parentheses prefix of s
@@ -215,28 +270,17 @@ func forwardParseBuf(buf string, pos int) string {
 	return ""
 }
 
-func delimiter(b byte) bool {
-
-	s := "<>[]()/"
-
-	for i := 0; i < len(s); i++ {
-		if b == s[i] {
-			return true
-		}
-	}
-
-	return false
-}
-
 // parseObjectAttributes parses object number and generation of the next object for given string buffer.
 func parseObjectAttributes(line *string) (objectNumber *int, generationNumber *int, err error) {
 
-	logDebugParse.Printf("ParseObjectAttributes: buf=<%s>\n", *line)
-
 	if line == nil || len(*line) == 0 {
 		return nil, nil, errors.New("ParseObjectAttributes: buf not available")
 	}
 
+	if debugParse {
+		logDebugParse.Printf("ParseObjectAttributes: buf=<%s>\n", *line)
+	}
+
 	l := *line
 	var remainder string
 
@@ -291,7 +335,7 @@ func parseObjectAttributes(line *string) (objectNumber *int, generationNumber *i
 	return objectNumber, generationNumber, nil
 }
 
-func parseArray(line *string) (*PDFArray, error) {
+func parseArray(line *string, depth int) (PDFArray, error) {
 
 	if line == nil || len(*line) == 0 {
 		return nil, errNoArray
@@ -299,8 +343,9 @@ func parseArray(line *string) (*PDFArray, error) {
 
 	l := *line
 
-	logDebugParse.Printf("ParseArray: %s\n", l)
-	//logInfoParse.Println("ParseArray begin")
+	if debugParse {
+		logDebugParse.Printf("ParseArray: %s\n", l)
+	}
 
 	if !strings.HasPrefix(l, "[") {
 		return nil, errArrayCorrupt
@@ -325,11 +370,13 @@ func parseArray(line *string) (*PDFArray, error) {
 
 	for !strings.HasPrefix(l, "]") {
 
-		obj, err := parseObject(&l)
+		obj, err := parseNestedObject(&l, depth+1)
 		if err != nil {
 			return nil, err
 		}
-		logDebugParse.Printf("ParseArray: new array obj=%v\n", obj)
+		if debugParse {
+			logDebugParse.Printf("ParseArray: new array obj=%v\n", obj)
+		}
 		arr = append(arr, obj)
 
 		// we are positioned on the char behind the last parsed array entry.
@@ -349,10 +396,11 @@ func parseArray(line *string) (*PDFArray, error) {
 
 	*line = l
 
-	//logInfoParse.Printf("ParseArray end: returning array (len=%d)\n", len(arr))
-	logDebugParse.Printf("ParseArray: returning array (len=%d): %v\n", len(arr), arr)
+	if debugParse {
+		logDebugParse.Printf("ParseArray: returning array (len=%d): %v\n", len(arr), arr)
+	}
 
-	return &arr, nil
+	return arr, nil
 }
 
 func parseStringLiteral(line *string) (PDFObject, error) {
@@ -383,7 +431,9 @@ func parseStringLiteral(line *string) (PDFObject, error) {
 
 	l := *line
 
-	logDebugParse.Printf("parseStringLiteral: begin <%s>\n", l)
+	if debugParse {
+		logDebugParse.Printf("parseStringLiteral: begin <%s>\n", l)
+	}
 
 	if len(l) < 2 || !strings.HasPrefix(l, "(") {
 		return nil, errStringLiteralCorrupt
@@ -406,10 +456,7 @@ func parseStringLiteral(line *string) (PDFObject, error) {
 	// position behind ')'
 	*line = forwardParseBuf(l[i:], 1)
 
-	stringLiteral := PDFStringLiteral(balParStr)
-	logDebugParse.Printf("parseStringLiteral: end <%s>\n", stringLiteral)
-
-	return stringLiteral, nil
+	return PDFStringLiteral(balParStr), nil
 }
 
 func parseHexLiteral(line *string) (PDFObject, error) {
@@ -422,7 +469,9 @@ func parseHexLiteral(line *string) (PDFObject, error) {
 
 	l := *line
 
-	logDebugParse.Printf("parseHexLiteral: %s\n", l)
+	if debugParse {
+		logDebugParse.Printf("parseHexLiteral: %s\n", l)
+	}
 
 	if len(l) < 3 || !strings.HasPrefix(l, "<") {
 		return nil, errHexLiteralCorrupt
@@ -444,57 +493,59 @@ func parseHexLiteral(line *string) (PDFObject, error) {
 	// position behind '>'
 	*line = forwardParseBuf(l[eov:], 1)
 
-	return PDFHexLiteral(*hexStr), nil
+	return PDFHexLiteral(hexStr), nil
 }
 
-func parseName(line *string) (*PDFName, error) {
+func parseName(line *string) (PDFName, error) {
 
 	// see 7.3.5
 
 	if line == nil || len(*line) == 0 {
-		return nil, errBufNotAvailable
+		return "", errBufNotAvailable
 	}
 
 	l := *line
 
-	logDebugParse.Printf("parseNameObject: %s\n", l)
+	if debugParse {
+		logDebugParse.Printf("parseNameObject: %s\n", l)
+	}
 
-	if len(l) < 2 || !strings.HasPrefix(l, "/") {
-		return nil, errNameObjectCorrupt
+	if len(l) < 2 || l[0] != '/' {
+		return "", errNameObjectCorrupt
 	}
 
 	// position behind '/'
-	l = forwardParseBuf(l, 1)
+	l = l[1:]
 
 	// cut off on whitespace or delimiter
-	eok, _ := positionToNextWhitespaceOrChar(l, "/<>()[]")
+	eok := 0
+	for eok < len(l) && charClass[l[eok]] == 0 {
+		eok++
+	}
 
-	if eok > 0 || unicode.IsSpace(rune(l[0])) {
-		logDebugParse.Printf("parseNameObject: wants to cut off at %d\n", eok)
-		*line = l[eok:]
-		l = l[:eok]
-	} else {
-		logDebugParse.Println("parseNameObject: nothing to cut off")
-		*line = ""
+	if eok == 0 && delimiter(l[0]) {
+		return "", errNameObjectCorrupt
 	}
 
-	nameObj := PDFName(l)
+	*line = l[eok:]
 
-	return &nameObj, nil
+	return internName(l[:eok]), nil
 }
 
-func parseDict(line *string) (*PDFDict, error) {
+func parseDict(line *string, depth int) (PDFDict, error) {
 
 	if line == nil || len(*line) == 0 {
-		return nil, errNoDictionary
+		return PDFDict{}, errNoDictionary
 	}
 
 	l := *line
 
-	logDebugParse.Printf("ParseDict: %s\n", l)
+	if debugParse {
+		logDebugParse.Printf("ParseDict: %s\n", l)
+	}
 
 	if len(l) < 4 || !strings.HasPrefix(l, "<<") {
-		return nil, errDictionaryCorrupt
+		return PDFDict{}, errDictionaryCorrupt
 	}
 
 	// position behind '<<'
@@ -505,7 +556,7 @@ func parseDict(line *string) (*PDFDict, error) {
 
 	if len(l) == 0 {
 		// only whitespace after '['
-		return nil, errDictionaryNotTerminated
+		return PDFDict{}, errDictionaryNotTerminated
 	}
 
 	dict := NewPDFDict()
@@ -514,42 +565,42 @@ func parseDict(line *string) (*PDFDict, error) {
 
 		key, err := parseName(&l)
 		if err != nil {
-			return nil, err
+			return PDFDict{}, err
 		}
-		logDebugParse.Printf("ParseDict: key = %s\n", key)
 
 		// position to first non whitespace after key
 		l, _ = trimLeftSpace(l)
 
 		if len(l) == 0 {
-			logDebugParse.Println("ParseDict: only whitespace after key")
 			// only whitespace after key
-			return nil, errDictionaryNotTerminated
+			return PDFDict{}, errDictionaryNotTerminated
 		}
 
-		obj, err := parseObject(&l)
+		obj, err := parseNestedObject(&l, depth+1)
 		if err != nil {
-			return nil, err
+			return PDFDict{}, err
 		}
 
 		// Specifying the null object as the value of a dictionary entry (7.3.7, "Dictionary Objects")
 		// shall be equivalent to omitting the entry entirely.
 		if obj != nil {
-			logDebugParse.Printf("ParseDict: dict[%s]=%v\n", key, obj)
-			if ok := dict.Insert(string(*key), obj); !ok {
-				return nil, errDictionaryDuplicateKey
+			if debugParse {
+				logDebugParse.Printf("ParseDict: dict[%s]=%v\n", key, obj)
+			}
+			if ok := dict.Insert(string(key), obj); !ok {
+				return PDFDict{}, errDictionaryDuplicateKey
 			}
 		}
 
 		// we are positioned on the char behind the last parsed dict value.
 		if len(l) == 0 {
-			return nil, errDictionaryNotTerminated
+			return PDFDict{}, errDictionaryNotTerminated
 		}
 
 		// position to next non whitespace char.
 		l, _ = trimLeftSpace(l)
 		if len(l) == 0 {
-			return nil, errDictionaryNotTerminated
+			return PDFDict{}, errDictionaryNotTerminated
 		}
 
 	}
@@ -559,9 +610,11 @@ func parseDict(line *string) (*PDFDict, error) {
 
 	*line = l
 
-	logDebugParse.Printf("ParseDict: returning dict at: %v\n", dict)
+	if debugParse {
+		logDebugParse.Printf("ParseDict: returning dict at: %v\n", dict)
+	}
 
-	return &dict, nil
+	return dict, nil
 }
 
 func noBuf(l *string) bool {
@@ -593,9 +646,16 @@ func parseNumericOrIndRef(line *string) (PDFObject, error) {
 		str = l[:i1]
 	}
 
-	// Try int
-	i, err := strconv.Atoi(str)
-	if err != nil {
+	// Try int unless there is a decimal point.
+	isInt := strings.IndexByte(str, '.') < 0
+
+	var i int
+	var err error
+	if isInt {
+		i, err = strconv.Atoi(str)
+	}
+
+	if !isInt || err != nil {
 
 		// Try float
 		f, err := strconv.ParseFloat(str, 64)
@@ -604,7 +664,6 @@ func parseNumericOrIndRef(line *string) (PDFObject, error) {
 		}
 
 		// We have a Float!
-		logDebugParse.Printf("parseNumericOrIndRef: value is numeric float: %f\n", f)
 		*line = l1
 		return PDFFloat(f), nil
 	}
@@ -613,7 +672,6 @@ func parseNumericOrIndRef(line *string) (PDFObject, error) {
 
 	// if not followed by whitespace return sole integer value.
 	if i1 == 0 || delimiter(l[i1]) {
-		logDebugParse.Printf("parseNumericOrIndRef: value is numeric int: %d\n", i)
 		*line = l1
 		return PDFInteger(i), nil
 	}
@@ -636,7 +694,6 @@ func parseNumericOrIndRef(line *string) (PDFObject, error) {
 	// if only 2 token, can't be indirect reference.
 	// if not followed by whitespace return sole integer value.
 	if i2 == 0 || delimiter(l[i2]) {
-		logDebugParse.Printf("parseNumericOrIndRef: 2 objects => value is numeric int: %d\n", i)
 		*line = l1
 		return PDFInteger(i), nil
 	}
@@ -650,7 +707,6 @@ func parseNumericOrIndRef(line *string) (PDFObject, error) {
 	if err != nil {
 		// 2nd int(generation number) not available.
 		// Can't be an indirect reference.
-		logDebugParse.Printf("parseNumericOrIndRef: 3 objects, 2nd no int, value is no indirect ref but numeric int: %d\n", i)
 		*line = l1
 		return PDFInteger(i), nil
 	}
@@ -663,25 +719,24 @@ func parseNumericOrIndRef(line *string) (PDFObject, error) {
 
 	if len(l) == 0 {
 		// only whitespace
-		l = l1
+		*line = l1
 		return PDFInteger(i), nil
 	}
 
 	if l[0] == 'R' {
 		// We have all 3 components to create an indirect reference.
 		*line = forwardParseBuf(l, 1)
-		return *NewPDFIndirectRef(iref1, iref2), nil
+		return PDFIndirectRef{ObjectNumber: PDFInteger(iref1), GenerationNumber: PDFInteger(iref2)}, nil
 	}
 
 	// 'R' not available.
 	// Can't be an indirect reference.
-	logDebugParse.Printf("parseNumericOrIndRef: value is no indirect ref(no 'R') but numeric int: %d\n", i)
 	*line = l1
 
 	return PDFInteger(i), nil
 }
 
-func parseHexLiteralOrDict(l *string) (val PDFObject, err error) {
+func parseHexLiteralOrDict(l *string, depth int) (val PDFObject, err error) {
 
 	if len(*l) < 2 {
 		return nil, errBufNotAvailable
@@ -689,15 +744,13 @@ func parseHexLiteralOrDict(l *string) (val PDFObject, err error) {
 
 	// if next char = '<' parseDict.
 	if (*l)[1] == '<' {
-		logDebugParse.Println("parseHexLiteralOrDict: value = Dictionary")
-		pdfDict, err := parseDict(l)
+		pdfDict, err := parseDict(l, depth)
 		if err != nil {
 			return nil, err
 		}
-		val = *pdfDict
+		val = pdfDict
 	} else {
 		// hex literals
-		logDebugParse.Println("parseHexLiteralOrDict: value = Hex Literal")
 		if val, err = parseHexLiteral(l); err != nil {
 			return nil, err
 		}
@@ -710,19 +763,16 @@ func parseBooleanOrNull(l string) (val PDFObject, s string, ok bool) {
 
 	// null, absent object
 	if strings.HasPrefix(l, "null") {
-		logDebugParse.Println("parseBoolean: value = null")
 		return nil, "null", true
 	}
 
 	// boolean true
 	if strings.HasPrefix(l, "true") {
-		logDebugParse.Println("parseBoolean: value = true")
 		return PDFBoolean(true), "true", true
 	}
 
 	// boolean false
 	if strings.HasPrefix(l, "false") {
-		logDebugParse.Println("parseBoolean: value = false")
 		return PDFBoolean(false), "false", true
 	}
 
@@ -731,14 +781,25 @@ func parseBooleanOrNull(l string) (val PDFObject, s string, ok bool) {
 
 // parseObject parses next PDFObject from string buffer.
 func parseObject(line *string) (PDFObject, error) {
+	return parseNestedObject(line, 0)
+}
+
+// parseNestedObject parses next PDFObject from string buffer nested into depth arrays or dictionaries.
+func parseNestedObject(line *string, depth int) (PDFObject, error) {
 
 	if noBuf(line) {
 		return nil, errBufNotAvailable
 	}
 
+	if depth > maxNestingDepth {
+		return nil, errNestingTooDeep
+	}
+
 	l := *line
 
-	logDebugParse.Printf("ParseObject: buf=<%s>\n", l)
+	if debugParse {
+		logDebugParse.Printf("ParseObject: buf=<%s>\n", l)
+	}
 
 	// position to first non whitespace char
 	l, _ = trimLeftSpace(l)
@@ -753,29 +814,26 @@ func parseObject(line *string) (PDFObject, error) {
 	switch l[0] {
 
 	case '[': // array
-		logDebugParse.Println("ParseObject: value = Array")
-		pdfArray, err := parseArray(&l)
+		pdfArray, err := parseArray(&l, depth)
 		if err != nil {
 			return nil, err
 		}
-		value = *pdfArray
+		value = pdfArray
 
 	case '/': // name
-		logDebugParse.Println("ParseObject: value = Name Object")
 		nameObj, err := parseName(&l)
 		if err != nil {
 			return nil, err
 		}
-		value = *nameObj
+		value = nameObj
 
 	case '<': // hex literal or dict
-		value, err = parseHexLiteralOrDict(&l)
+		value, err = parseHexLiteralOrDict(&l, depth)
 		if err != nil {
 			return nil, err
 		}
 
 	case '(': // string literal
-		logDebugParse.Printf("ParseObject: value = String Literal: <%s>\n", l)
 		if value, err = parseStringLiteral(&l); err != nil {
 			return nil, err
 		}
@@ -798,7 +856,9 @@ func parseObject(line *string) (PDFObject, error) {
 
 	}
 
-	logDebugParse.Printf("ParseObject returning %v\n", value)
+	if debugParse {
+		logDebugParse.Printf("ParseObject returning %v\n", value)
+	}
 
 	*line = l
 
@@ -867,8 +927,9 @@ func parseXRefStreamDict(pdfStreamDict PDFStreamDict) (*PDFXRefStreamDict, error
 		return nil, errXrefStreamCorruptW
 	}
 
+	// Fields wider than 8 bytes exceed int64.
 	f := func(ok bool, i int) bool {
-		return !ok || i < 0
+		return !ok || i < 0 || i > 8
 	}
 
 	i1, ok := arr[0].(PDFInteger)
@@ -96,3 +96,42 @@ func TestParseObject(t *testing.T) {
 	doTestParseObjectOK("[1 0 R /n 2 0 R]", t)
 	doTestParseObjectOK("<</n 1 0 R>>", t)
 }
+
+var benchObjects = []string{
+	"<</Type/Page/Parent 3 0 R/Resources<</Font<</F1 5 0 R/F2 7 0 R>>/ProcSet[/PDF/Text/ImageB]>>/MediaBox[0 0 612 792]/Contents 4 0 R>>",
+	"<</Type/Font/Subtype/Type1/BaseFont/Helvetica-Bold/Encoding/WinAnsiEncoding/FirstChar 32/LastChar 255/Widths 12 0 R>>",
+	"<</Length 1234/Filter/FlateDecode/DecodeParms<</Predictor 12/Columns 5>>>>",
+	"[0 0 612 792]",
+	"[1 0 R 2 0 R 3 0 R 4 0 R 5 0 R 6 0 R 7 0 R 8 0 R 9 0 R 10 0 R]",
+	"<</Title(An Introduction to Programming in Go)/Author(Caleb Doxsey)/CreationDate(D:20120816221049-05'00')/ID[<6E1A2FB0C3D7E8F9A0B1C2D3E4F50617><6E1A2FB0C3D7E8F9A0B1C2D3E4F50617>]>>",
+	"<</Type/Annot/Subtype/Link/Rect[72.5 700.25 144.75 712.0]/Border[0 0 0]/A<</S/URI/URI(http://golang.org)>>>>",
+}
+
+func BenchmarkParseObject(b *testing.B) {
+
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		for _, s := range benchObjects {
+			if _, err := parseObject(&s); err != nil {
+				b.Fatalf("BenchmarkParseObject: %v\n", err)
+			}
+		}
+	}
+}
+
+// Mostly names not interned, like subset font names and custom keys.
+var benchNames = "<</Type/Font/Subtype/TrueType/BaseFont/ABCDEF+Calibri-BoldItalic/FontDescriptor<</FontName/ABCDEF+Calibri-BoldItalic/FontFile2 9 0 R>>" +
+	"/PieceInfo<</ADBE_CompoundType<</Private/Headers/LastModified(D:20120816221049)>>>>/MyApp_Tag/Chapter_1_Section_2>>"
+
+func BenchmarkParseNames(b *testing.B) {
+
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		s := benchNames
+		if _, err := parseObject(&s); err != nil {
+			b.Fatalf("BenchmarkParseNames: %v\n", err)
+		}
+	}
+}
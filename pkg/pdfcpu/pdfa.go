@@ -0,0 +1,873 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// PDF/A conformance levels supported by ValidatePDFA.
+const (
+	PDFA1B = "1b" // ISO 19005-1 level B
+	PDFA2B = "2b" // ISO 19005-2 level B
+)
+
+// ComplianceRule is the outcome of checking a document against a rule of a standard like PDF/A or PDF/X.
+type ComplianceRule struct {
+	Clause      string   `json:"clause"` // clause of the ISO standard, eg. ISO 19005-1 for PDF/A-1
+	Description string   `json:"description"`
+	Passed      bool     `json:"passed"`
+	Failures    []string `json:"failures,omitempty"`
+}
+
+// ComplianceReport lists the rules of a standard checked rule by rule.
+type ComplianceReport struct {
+	Standard  string            `json:"standard"` // PDF/A, PDF/X or PDF/UA
+	Level     string            `json:"level"`
+	Compliant bool              `json:"compliant"`
+	Rules     []*ComplianceRule `json:"rules"`
+}
+
+func (rep *ComplianceReport) add(clause, description string, failures []string) {
+
+	rep.Rules = append(rep.Rules, &ComplianceRule{Clause: clause, Description: description, Passed: len(failures) == 0, Failures: failures})
+
+	if len(failures) > 0 {
+		rep.Compliant = false
+	}
+}
+
+// pdfaChecker checks a document against the rules of a PDF/A conformance level.
+type pdfaChecker struct {
+	ctx *PDFContext
+	b   []byte
+	a1  bool
+}
+
+func (c *pdfaChecker) objects(fn func(objNr int, d *PDFDict, sd *PDFStreamDict)) {
+
+	var objNrs []int
+	for objNr, entry := range c.ctx.Table {
+		if objNr > 0 && !entry.Free && entry.Object != nil {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+		if err := c.ctx.ensureStreamLoaded(c.ctx.Table[objNr], objNr); err != nil {
+			log.Info.Printf("pdfa: %v\n", err)
+		}
+		switch o := c.ctx.Table[objNr].Object.(type) {
+		case PDFDict:
+			fn(objNr, &o, nil)
+		case PDFStreamDict:
+			fn(objNr, &o.PDFDict, &o)
+		}
+	}
+}
+
+func (c *pdfaChecker) dict(o PDFObject) *PDFDict {
+	d, err := c.ctx.DereferenceDict(o)
+	if err != nil {
+		return nil
+	}
+	return d
+}
+
+func (c *pdfaChecker) name(o PDFObject) string {
+	o, err := c.ctx.Dereference(o)
+	if err != nil {
+		return ""
+	}
+	if n, ok := o.(PDFName); ok {
+		return n.Value()
+	}
+	return ""
+}
+
+func (c *pdfaChecker) number(o PDFObject) (float64, bool) {
+	o, err := c.ctx.Dereference(o)
+	if err != nil || o == nil {
+		return 0, false
+	}
+	ff, ok := numbers([]PDFObject{o})
+	if !ok {
+		return 0, false
+	}
+	return ff[0], true
+}
+
+func (c *pdfaChecker) header() []string {
+
+	var ss []string
+
+	if !regexp.MustCompile(`^%PDF-1\.[0-7]\r?\n?`).Match(c.b) {
+		ss = append(ss, "file header is not %PDF-1.n")
+	}
+
+	// The header line shall be followed by a comment of at least 4 bytes > 127.
+	lines := bytes.SplitN(c.b, []byte("\n"), 3)
+	if len(lines) < 2 {
+		return append(ss, "missing binary comment")
+	}
+
+	comment := bytes.TrimRight(lines[1], "\r")
+	if bytes.Contains(lines[0], []byte("\r%")) {
+		comment = lines[0][bytes.Index(lines[0], []byte("\r%"))+1:]
+	}
+
+	n := 0
+	for _, b := range comment {
+		if b > 127 {
+			n++
+		}
+	}
+
+	if len(comment) == 0 || comment[0] != '%' || n < 4 {
+		ss = append(ss, "missing binary comment")
+	}
+
+	return ss
+}
+
+func (c *pdfaChecker) trailer() []string {
+
+	var ss []string
+
+	if c.ctx.ID == nil {
+		ss = append(ss, "trailer: missing ID")
+	}
+
+	// Nothing but an optional EOL shall follow the last end-of-file marker.
+	i := bytes.LastIndex(c.b, []byte("%%EOF"))
+	if i < 0 {
+		return append(ss, "missing %%EOF")
+	}
+
+	if s := string(c.b[i+5:]); s != "" && s != "\n" && s != "\r" && s != "\r\n" {
+		ss = append(ss, "data after last %%EOF")
+	}
+
+	return ss
+}
+
+func (c *pdfaChecker) encryption() []string {
+	if c.ctx.Encrypt != nil {
+		return []string{"trailer: Encrypt present"}
+	}
+	return nil
+}
+
+func (c *pdfaChecker) compression() []string {
+
+	var ss []string
+
+	if c.ctx.Read.UsingXRefStreams {
+		ss = append(ss, "cross reference streams used")
+	}
+
+	if c.ctx.Read.UsingObjectStreams {
+		ss = append(ss, "object streams used")
+	}
+
+	return ss
+}
+
+func (c *pdfaChecker) streams() []string {
+
+	var ss []string
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+		if sd == nil {
+			return
+		}
+		for _, k := range []string{"F", "FFilter", "FDecodeParms"} {
+			if _, found := d.Find(k); found {
+				ss = append(ss, fmt.Sprintf("object #%d: stream dict contains %s", objNr, k))
+			}
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfaChecker) filters() []string {
+
+	var ss []string
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+		if sd == nil {
+			return
+		}
+		for _, f := range sd.FilterPipeline {
+			if f.Name == filter.LZW || f.Name == filter.Crypt || (c.a1 && f.Name == filter.JPX) {
+				ss = append(ss, fmt.Sprintf("object #%d: %s filter", objNr, f.Name))
+			}
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfaChecker) embeddedFiles() ([]string, error) {
+
+	var ss []string
+
+	if c.a1 {
+		if err := c.ctx.LocateNameTree("EmbeddedFiles", false); err != nil {
+			return nil, err
+		}
+		if c.ctx.Names["EmbeddedFiles"] != nil {
+			ss = append(ss, "embedded files present")
+		}
+		return ss, nil
+	}
+
+	// PDF/A-2 permits embedded PDF/A files only.
+	var err error
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+		if sd == nil || d.Type() == nil || *d.Type() != "EmbeddedFile" || err != nil {
+			return
+		}
+		s := *sd
+		if err = c.ctx.decodeStream(&s); err != nil {
+			return
+		}
+		if !bytes.HasPrefix(s.Content, []byte("%PDF-")) {
+			ss = append(ss, fmt.Sprintf("object #%d: embedded file is not a PDF file", objNr))
+		}
+	})
+
+	return ss, err
+}
+
+func (c *pdfaChecker) optionalContent() []string {
+	if _, found := c.ctx.RootDict.Find("OCProperties"); found {
+		return []string{"catalog: OCProperties present"}
+	}
+	return nil
+}
+
+var deviceColorOperators = map[string]bool{"g": true, "G": true, "rg": true, "RG": true, "k": true, "K": true}
+
+func isDeviceColorSpace(s string) bool {
+	return s == "DeviceGray" || s == "DeviceRGB" || s == "DeviceCMYK"
+}
+
+// deviceColors returns the objects using device dependent color spaces.
+func (c *pdfaChecker) deviceColors() []string {
+
+	var ss []string
+
+	usesDeviceColor := func(bb []byte) bool {
+		found := false
+		parseContent(bb, func(op string, operands []PDFObject) error {
+			if deviceColorOperators[op] {
+				found = true
+			}
+			if (op == "cs" || op == "CS") && len(operands) == 1 && isDeviceColorSpace(c.name(operands[0])) {
+				found = true
+			}
+			return nil
+		})
+		return found
+	}
+
+	for i := 1; i <= c.ctx.PageCount; i++ {
+		pageDict, _, err := c.ctx.PageDict(i)
+		if err != nil || pageDict == nil {
+			continue
+		}
+		bb, err := pageContent(c.ctx.XRefTable, pageDict, false)
+		if err == nil && usesDeviceColor(bb) {
+			ss = append(ss, fmt.Sprintf("page %d", i))
+		}
+	}
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		if sd == nil || d.Subtype() == nil {
+			return
+		}
+
+		switch *d.Subtype() {
+
+		case "Image":
+			if b := d.BooleanEntry("ImageMask"); b != nil && *b {
+				return
+			}
+			if isDeviceColorSpace(c.name(d.Dict["ColorSpace"])) {
+				ss = append(ss, fmt.Sprintf("image object #%d", objNr))
+			}
+
+		case "Form":
+			s := *sd
+			if err := c.ctx.decodeStream(&s); err == nil && usesDeviceColor(s.Content) {
+				ss = append(ss, fmt.Sprintf("form object #%d", objNr))
+			}
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfaChecker) outputIntent() []string {
+
+	var profiles int
+
+	if arr, err := c.ctx.DereferenceArray(c.ctx.RootDict.Dict["OutputIntents"]); err == nil && arr != nil {
+		for _, o := range *arr {
+			d := c.dict(o)
+			if d == nil || c.name(d.Dict["S"]) != "GTS_PDFA1" {
+				continue
+			}
+			if sd, err := c.ctx.DereferenceStreamDict(d.Dict["DestOutputProfile"]); err == nil && sd != nil {
+				profiles++
+			}
+		}
+	}
+
+	if profiles > 0 {
+		return nil
+	}
+
+	var ss []string
+	for _, s := range c.deviceColors() {
+		ss = append(ss, s+": device color space without PDF/A output intent")
+	}
+
+	return ss
+}
+
+func (c *pdfaChecker) images() []string {
+
+	var ss []string
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		if sd == nil || d.Subtype() == nil || *d.Subtype() != "Image" {
+			return
+		}
+
+		for _, k := range []string{"Alternates", "OPI"} {
+			if _, found := d.Find(k); found {
+				ss = append(ss, fmt.Sprintf("image object #%d: %s present", objNr, k))
+			}
+		}
+
+		if b := d.BooleanEntry("Interpolate"); b != nil && *b {
+			ss = append(ss, fmt.Sprintf("image object #%d: Interpolate true", objNr))
+		}
+
+		if bpc := d.IntEntry("BitsPerComponent"); c.a1 && bpc != nil && *bpc > 8 {
+			ss = append(ss, fmt.Sprintf("image object #%d: %d bits per component", objNr, *bpc))
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfaChecker) xObjects() []string {
+
+	var ss []string
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		if sd == nil || d.Subtype() == nil {
+			return
+		}
+
+		switch *d.Subtype() {
+
+		case "PS":
+			ss = append(ss, fmt.Sprintf("object #%d: PostScript XObject", objNr))
+
+		case "Form":
+			if c.name(d.Dict["Subtype2"]) == "PS" {
+				ss = append(ss, fmt.Sprintf("form object #%d: PostScript form", objNr))
+			}
+			for _, k := range []string{"OPI", "PS", "Ref"} {
+				if _, found := d.Find(k); found {
+					ss = append(ss, fmt.Sprintf("form object #%d: %s present", objNr, k))
+				}
+			}
+		}
+	})
+
+	return ss
+}
+
+// extGStates calls fn for all graphics state parameter dicts referenced by any resource dict.
+func (c *pdfaChecker) extGStates(fn func(name string, d *PDFDict)) {
+
+	done := map[*PDFDict]bool{}
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		res := c.dict(d.Dict["Resources"])
+		if res == nil {
+			return
+		}
+
+		gsDict := c.dict(res.Dict["ExtGState"])
+		if gsDict == nil {
+			return
+		}
+
+		for k, v := range gsDict.Dict {
+			if gs := c.dict(v); gs != nil && !done[gs] {
+				done[gs] = true
+				fn(fmt.Sprintf("object #%d: ExtGState %s", objNr, k), gs)
+			}
+		}
+	})
+}
+
+func (c *pdfaChecker) transferFunctions() []string {
+
+	var ss []string
+
+	c.extGStates(func(name string, d *PDFDict) {
+		if _, found := d.Find("TR"); found {
+			ss = append(ss, name+": TR present")
+		}
+		if o, found := d.Find("TR2"); found && c.name(o) != "Default" {
+			ss = append(ss, name+": TR2 other than Default")
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfaChecker) transparency() []string {
+
+	var ss []string
+
+	c.extGStates(func(name string, d *PDFDict) {
+		if o, found := d.Find("SMask"); found && c.name(o) != "None" {
+			ss = append(ss, name+": soft mask")
+		}
+		for _, k := range []string{"CA", "ca"} {
+			if f, ok := c.number(d.Dict[k]); ok && f != 1 {
+				ss = append(ss, fmt.Sprintf("%s: %s %g", name, k, f))
+			}
+		}
+		if o, found := d.Find("BM"); found {
+			if bm := c.name(o); bm != "Normal" && bm != "Compatible" {
+				ss = append(ss, fmt.Sprintf("%s: blend mode %s", name, bm))
+			}
+		}
+	})
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		if g := c.dict(d.Dict["Group"]); g != nil && c.name(g.Dict["S"]) == "Transparency" {
+			ss = append(ss, fmt.Sprintf("object #%d: transparency group", objNr))
+		}
+
+		if sd != nil && d.Subtype() != nil && *d.Subtype() == "Image" {
+			if _, found := d.Find("SMask"); found {
+				ss = append(ss, fmt.Sprintf("image object #%d: soft mask", objNr))
+			}
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfaChecker) fontEmbedded(d *PDFDict) bool {
+
+	fd := c.dict(d.Dict["FontDescriptor"])
+	if fd == nil {
+		return false
+	}
+
+	for _, k := range []string{"FontFile", "FontFile2", "FontFile3"} {
+		if sd, err := c.ctx.DereferenceStreamDict(fd.Dict[k]); err == nil && sd != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *pdfaChecker) fonts() []string {
+
+	var ss []string
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		if sd != nil || d.Type() == nil || *d.Type() != "Font" || d.Subtype() == nil {
+			return
+		}
+
+		fd := d
+
+		switch *d.Subtype() {
+
+		case "Type3", "CIDFontType0", "CIDFontType2":
+			// Type 3 fonts are defined in the document, descendant fonts are checked with their Type 0 font.
+			return
+
+		case "Type0":
+			arr, err := c.ctx.DereferenceArray(d.Dict["DescendantFonts"])
+			if err != nil || arr == nil || len(*arr) != 1 {
+				ss = append(ss, fmt.Sprintf("font object #%d: invalid descendant font", objNr))
+				return
+			}
+			if fd = c.dict((*arr)[0]); fd == nil {
+				ss = append(ss, fmt.Sprintf("font object #%d: invalid descendant font", objNr))
+				return
+			}
+		}
+
+		if !c.fontEmbedded(fd) {
+			ss = append(ss, fmt.Sprintf("font object #%d: %s not embedded", objNr, c.name(d.Dict["BaseFont"])))
+		}
+	})
+
+	return ss
+}
+
+var pdfaAnnotations = map[string]bool{
+	"Text": true, "Link": true, "FreeText": true, "Line": true, "Square": true, "Circle": true,
+	"Polygon": true, "PolyLine": true, "Highlight": true, "Underline": true, "Squiggly": true, "StrikeOut": true,
+	"Stamp": true, "Caret": true, "Ink": true, "Popup": true, "Widget": true, "PrinterMark": true, "TrapNet": true,
+}
+
+const (
+	annotFlagInvisible    = 1 << 0 // see 12.5.3 Table 165
+	annotFlagNoView       = 1 << 5
+	annotFlagToggleNoView = 1 << 8
+)
+
+// pdfa2Annotations are the annotation types permitted by PDF/A-2 only.
+var pdfa2Annotations = map[string]bool{"FileAttachment": true, "Watermark": true, "Redact": true}
+
+func (c *pdfaChecker) annotation(page int, d *PDFDict) []string {
+
+	var ss []string
+
+	subtype := c.name(d.Dict["Subtype"])
+
+	problem := func(format string, a ...interface{}) {
+		ss = append(ss, fmt.Sprintf("page %d: %s annotation: ", page, subtype)+fmt.Sprintf(format, a...))
+	}
+
+	if !pdfaAnnotations[subtype] && (c.a1 || !pdfa2Annotations[subtype]) {
+		problem("type not permitted")
+		return ss
+	}
+
+	if f, ok := c.number(d.Dict["F"]); ok || subtype != "Popup" || c.a1 {
+		flags := int(f)
+		if flags&annotFlagPrint == 0 {
+			problem("print flag not set")
+		}
+		mask := annotFlagInvisible | annotFlagHidden | annotFlagNoView
+		if !c.a1 {
+			mask |= annotFlagToggleNoView
+		}
+		if flags&mask > 0 {
+			problem("hidden")
+		}
+	}
+
+	if f, ok := c.number(d.Dict["CA"]); c.a1 && ok && f != 1 {
+		problem("CA %g", f)
+	}
+
+	if c.a1 || subtype == "Popup" || subtype == "Link" {
+		return ss
+	}
+
+	// All other annotations need a normal appearance unless they have no extent.
+	if arr, err := c.ctx.DereferenceArray(d.Dict["Rect"]); err == nil && arr != nil && len(*arr) == 4 {
+		ff, ok := numbers(*arr)
+		if ok && (ff[0] == ff[2] || ff[1] == ff[3]) {
+			return ss
+		}
+	}
+
+	ap := c.dict(d.Dict["AP"])
+	if ap == nil {
+		problem("missing appearance")
+		return ss
+	}
+
+	if _, found := ap.Find("N"); !found || ap.Len() != 1 {
+		problem("appearance dict other than normal appearance only")
+	}
+
+	return ss
+}
+
+func (c *pdfaChecker) annotations() []string {
+
+	var ss []string
+
+	for i := 1; i <= c.ctx.PageCount; i++ {
+
+		pageDict, _, err := c.ctx.PageDict(i)
+		if err != nil || pageDict == nil {
+			continue
+		}
+
+		arr, err := c.ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil || arr == nil {
+			continue
+		}
+
+		for _, o := range *arr {
+			if d := c.dict(o); d != nil {
+				ss = append(ss, c.annotation(i, d)...)
+			}
+		}
+	}
+
+	return ss
+}
+
+// pdfaForbiddenActions are the action types not permitted by PDF/A.
+var pdfaForbiddenActions = []string{
+	"GoTo3DView", "Hide", "ImportData", "JavaScript", "Launch", "Movie",
+	"Rendition", "ResetForm", "SetOCGState", "Sound", "Trans",
+}
+
+func (c *pdfaChecker) actions() ([]string, error) {
+
+	types := StringSet{}
+	for _, t := range pdfaForbiddenActions {
+		types[t] = true
+	}
+
+	if c.a1 {
+		types["GoToE"] = true
+	}
+
+	w, err := processActions(c.ctx.XRefTable, types, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var ss []string
+	for t, n := range w.counts {
+		ss = append(ss, fmt.Sprintf("%d %s action(s)", n, t))
+	}
+	sort.Strings(ss)
+
+	return ss, nil
+}
+
+func (c *pdfaChecker) additionalActions() []string {
+
+	var ss []string
+
+	if _, found := c.ctx.RootDict.Find("AA"); found {
+		ss = append(ss, "catalog: AA present")
+	}
+
+	if !c.a1 {
+		for i := 1; i <= c.ctx.PageCount; i++ {
+			if pageDict, _, err := c.ctx.PageDict(i); err == nil && pageDict != nil {
+				if _, found := pageDict.Find("AA"); found {
+					ss = append(ss, fmt.Sprintf("page %d: AA present", i))
+				}
+			}
+		}
+	}
+
+	processFormFields(c.ctx.XRefTable, func(f *formField) error {
+		if _, found := f.dict.Find("AA"); found {
+			ss = append(ss, fmt.Sprintf("field %s: AA present", f.name))
+		}
+		for _, indRef := range f.widgets {
+			if d := c.dict(indRef); d != nil && d != f.dict {
+				if _, found := d.Find("AA"); found {
+					ss = append(ss, fmt.Sprintf("field %s: widget AA present", f.name))
+				}
+			}
+		}
+		return nil
+	})
+
+	return ss
+}
+
+var (
+	pdfaidPart        = regexp.MustCompile(`pdfaid:part\s*(?:=\s*["']|>)\s*(\d+)`)
+	pdfaidConformance = regexp.MustCompile(`pdfaid:conformance\s*(?:=\s*["']|>)\s*([A-Za-z])`)
+)
+
+func (c *pdfaChecker) metadata() []string {
+
+	sd, err := c.ctx.DereferenceStreamDict(c.ctx.RootDict.Dict["Metadata"])
+	if err != nil || sd == nil {
+		return []string{"catalog: missing XMP metadata"}
+	}
+
+	var ss []string
+
+	if _, found := sd.Find("Filter"); found {
+		ss = append(ss, "metadata stream is filtered")
+	}
+
+	s := *sd
+	if err = c.ctx.decodeStream(&s); err != nil {
+		return append(ss, fmt.Sprintf("metadata stream: %v", err))
+	}
+
+	part, conformance := "1", "B"
+	if !c.a1 {
+		part = "2"
+	}
+
+	if m := pdfaidPart.FindSubmatch(s.Content); m == nil {
+		ss = append(ss, "XMP: missing pdfaid:part")
+	} else if string(m[1]) != part {
+		ss = append(ss, fmt.Sprintf("XMP: pdfaid:part %s", m[1]))
+	}
+
+	// Level A and U files conform to level B too.
+	if m := pdfaidConformance.FindSubmatch(s.Content); m == nil {
+		ss = append(ss, "XMP: missing pdfaid:conformance")
+	} else if v := string(m[1]); v != conformance && v != "A" && (c.a1 || v != "U") {
+		ss = append(ss, fmt.Sprintf("XMP: pdfaid:conformance %s", v))
+	}
+
+	return ss
+}
+
+func (c *pdfaChecker) forms() []string {
+
+	d := c.dict(c.ctx.RootDict.Dict["AcroForm"])
+	if d == nil {
+		return nil
+	}
+
+	var ss []string
+
+	if b := d.BooleanEntry("NeedAppearances"); b != nil && *b {
+		ss = append(ss, "AcroForm: NeedAppearances true")
+	}
+
+	if _, found := d.Find("XFA"); found && !c.a1 {
+		ss = append(ss, "AcroForm: XFA present")
+	}
+
+	return ss
+}
+
+// pdfaRules lists the rules checked along with their clauses in ISO 19005-1 and ISO 19005-2, "" if not applicable.
+var pdfaRules = []struct {
+	clause1, clause2 string
+	description      string
+	check            func(c *pdfaChecker) ([]string, error)
+}{
+	{"6.1.2", "6.1.2", "file header followed by a binary comment",
+		func(c *pdfaChecker) ([]string, error) { return c.header(), nil }},
+	{"6.1.3", "6.1.3", "trailer with file identifier, no data after end-of-file marker",
+		func(c *pdfaChecker) ([]string, error) { return c.trailer(), nil }},
+	{"6.1.3", "6.1.3", "no encryption",
+		func(c *pdfaChecker) ([]string, error) { return c.encryption(), nil }},
+	{"6.1.4", "", "no cross reference streams or object streams",
+		func(c *pdfaChecker) ([]string, error) { return c.compression(), nil }},
+	{"6.1.7", "6.1.7.1", "no external stream data",
+		func(c *pdfaChecker) ([]string, error) { return c.streams(), nil }},
+	{"6.1.10", "6.1.7.2", "permitted filters only",
+		func(c *pdfaChecker) ([]string, error) { return c.filters(), nil }},
+	{"6.1.11", "6.8", "no embedded files (PDF/A-1), embedded PDF files only (PDF/A-2)",
+		func(c *pdfaChecker) ([]string, error) { return c.embeddedFiles() }},
+	{"6.1.13", "", "no optional content",
+		func(c *pdfaChecker) ([]string, error) { return c.optionalContent(), nil }},
+	{"6.2.2", "6.2.3", "PDF/A output intent for device dependent colors",
+		func(c *pdfaChecker) ([]string, error) { return c.outputIntent(), nil }},
+	{"6.2.4", "6.2.8", "images without alternates, OPI and interpolation",
+		func(c *pdfaChecker) ([]string, error) { return c.images(), nil }},
+	{"6.2.5", "6.2.9", "no PostScript or reference XObjects",
+		func(c *pdfaChecker) ([]string, error) { return c.xObjects(), nil }},
+	{"6.2.8", "6.2.5", "no transfer functions",
+		func(c *pdfaChecker) ([]string, error) { return c.transferFunctions(), nil }},
+	{"6.3.4", "6.2.11.4", "all fonts embedded",
+		func(c *pdfaChecker) ([]string, error) { return c.fonts(), nil }},
+	{"6.4", "", "no transparency",
+		func(c *pdfaChecker) ([]string, error) { return c.transparency(), nil }},
+	{"6.5.3", "6.3", "permitted, printable annotations with appearances",
+		func(c *pdfaChecker) ([]string, error) { return c.annotations(), nil }},
+	{"6.6.1", "6.5.1", "permitted actions only",
+		func(c *pdfaChecker) ([]string, error) { return c.actions() }},
+	{"6.6.2", "6.5.2", "no additional actions",
+		func(c *pdfaChecker) ([]string, error) { return c.additionalActions(), nil }},
+	{"6.7", "6.6", "unfiltered XMP metadata identifying the PDF/A conformance level",
+		func(c *pdfaChecker) ([]string, error) { return c.metadata(), nil }},
+	{"6.9", "6.4", "interactive forms with appearances",
+		func(c *pdfaChecker) ([]string, error) { return c.forms(), nil }},
+}
+
+// ValidatePDFA checks ctx read from the file b against the rules of the PDF/A conformance level given,
+// which is one of PDFA1B and PDFA2B.
+// The report lists the outcome rule by rule.
+func ValidatePDFA(ctx *PDFContext, b []byte, level string) (*ComplianceReport, error) {
+
+	log.Debug.Printf("ValidatePDFA begin: %s\n", level)
+
+	if level != PDFA1B && level != PDFA2B {
+		return nil, errors.Errorf("ValidatePDFA: unsupported conformance level: %s", level)
+	}
+
+	c := &pdfaChecker{ctx: ctx, b: b, a1: level == PDFA1B}
+
+	rep := &ComplianceReport{Standard: "PDF/A", Level: level, Compliant: true}
+
+	// Any other checks need a syntactically valid file.
+	if err := ValidateXRefTable(ctx.XRefTable); err != nil {
+		rep.add("6.1", "valid file structure", []string{err.Error()})
+		return rep, nil
+	}
+
+	for _, r := range pdfaRules {
+
+		clause := r.clause1
+		if !c.a1 {
+			clause = r.clause2
+		}
+
+		if clause == "" {
+			continue
+		}
+
+		ss, err := r.check(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ValidatePDFA: %s", clause)
+		}
+
+		rep.add(clause, r.description, ss)
+	}
+
+	log.Debug.Println("ValidatePDFA end")
+
+	return rep, nil
+}
@@ -0,0 +1,678 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// pdfaConverter fixes the PDF/A violations of a document as far as possible.
+type pdfaConverter struct {
+	*pdfaChecker
+	fontDir string
+	report  []string
+}
+
+func (c *pdfaConverter) fixed(format string, a ...interface{}) {
+	c.report = append(c.report, fmt.Sprintf(format, a...))
+}
+
+func (c *pdfaConverter) unfixable(format string, a ...interface{}) {
+	c.report = append(c.report, "cannot fix: "+fmt.Sprintf(format, a...))
+}
+
+func (c *pdfaConverter) removeEncryption() {
+
+	if c.ctx.Encrypt == nil {
+		return
+	}
+
+	// Objects have been decrypted on read, the writer drops the encryption dict.
+	c.ctx.Encrypt = nil
+	c.ctx.EncKey = nil
+	c.fixed("removed encryption")
+}
+
+func (c *pdfaConverter) ensureID() {
+	if c.ctx.ID == nil {
+		c.ctx.ID = id(c.ctx)
+		c.fixed("added file identifier")
+	}
+}
+
+func (c *pdfaConverter) compression() {
+
+	if !c.a1 {
+		return
+	}
+
+	// PDF/A-1 is based on PDF 1.4 which predates object streams and xref streams.
+	c.ctx.WriteObjectStream = false
+	c.ctx.WriteXRefStream = false
+
+	if c.ctx.Read.UsingXRefStreams || c.ctx.Read.UsingObjectStreams {
+		c.fixed("replaced cross reference streams and object streams")
+	}
+}
+
+// filters reencodes streams using filters not permitted by PDF/A using FlateDecode.
+func (c *pdfaConverter) filters() error {
+
+	var err error
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		if sd == nil || err != nil {
+			return
+		}
+
+		var lzw bool
+		for _, f := range sd.FilterPipeline {
+			switch {
+			case f.Name == filter.LZW:
+				lzw = true
+			case c.a1 && f.Name == filter.JPX:
+				c.unfixable("object #%d: JPXDecode filter", objNr)
+				return
+			}
+		}
+
+		if !lzw {
+			return
+		}
+
+		s := *sd
+		if err = c.ctx.decodeStream(&s); err != nil {
+			return
+		}
+
+		s.FilterPipeline = []PDFFilter{{Name: filter.Flate}}
+		s.InsertName("Filter", filter.Flate)
+		s.Delete("DecodeParms")
+
+		if err = encodeStream(&s); err != nil {
+			return
+		}
+
+		c.ctx.Table[objNr].Object = s
+		c.fixed("object #%d: replaced LZWDecode by FlateDecode", objNr)
+	})
+
+	return err
+}
+
+func (c *pdfaConverter) actions() error {
+
+	n, err := RemoveJavaScript(c.ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		c.fixed("removed %d scripts", n)
+	}
+
+	types := StringSet{}
+	for _, t := range pdfaForbiddenActions {
+		types[t] = true
+	}
+
+	if c.a1 {
+		types["GoToE"] = true
+	}
+
+	w, err := processActions(c.ctx.XRefTable, types, true)
+	if err != nil {
+		return err
+	}
+
+	var ss []string
+	for t, n := range w.counts {
+		ss = append(ss, fmt.Sprintf("removed %d %s action(s)", n, t))
+	}
+	sort.Strings(ss)
+
+	c.report = append(c.report, ss...)
+
+	return nil
+}
+
+// additionalActions removes all trigger events from the catalog, pages, annotations and form fields.
+func (c *pdfaConverter) additionalActions() error {
+
+	n := 0
+
+	remove := func(d *PDFDict) {
+		if _, found := d.Find("AA"); found {
+			d.Delete("AA")
+			n++
+		}
+	}
+
+	remove(c.ctx.RootDict)
+
+	for i := 1; i <= c.ctx.PageCount; i++ {
+
+		pageDict, _, err := c.ctx.PageDict(i)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		remove(pageDict)
+
+		arr, err := c.ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil || arr == nil {
+			continue
+		}
+
+		for _, o := range *arr {
+			if d := c.dict(o); d != nil {
+				remove(d)
+			}
+		}
+	}
+
+	err := processFormFields(c.ctx.XRefTable, func(f *formField) error {
+		remove(f.dict)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		c.fixed("removed %d additional actions", n)
+	}
+
+	return nil
+}
+
+// annotationFlags sets the print flag of all annotations not hidden.
+func (c *pdfaConverter) annotationFlags() error {
+
+	n := 0
+
+	for i := 1; i <= c.ctx.PageCount; i++ {
+
+		pageDict, _, err := c.ctx.PageDict(i)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		arr, err := c.ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil || arr == nil {
+			continue
+		}
+
+		for _, o := range *arr {
+
+			d := c.dict(o)
+			if d == nil || c.name(d.Dict["Subtype"]) == "Popup" {
+				continue
+			}
+
+			f, _ := c.number(d.Dict["F"])
+			flags := int(f)
+
+			if flags&(annotFlagInvisible|annotFlagHidden|annotFlagNoView) > 0 || flags&annotFlagPrint > 0 {
+				continue
+			}
+
+			d.Update("F", PDFInteger(flags|annotFlagPrint))
+			n++
+		}
+	}
+
+	if n > 0 {
+		c.fixed("set print flag of %d annotations", n)
+	}
+
+	return nil
+}
+
+// fontFile returns the TrueType font program for baseFont in the font directory.
+func (c *pdfaConverter) fontFile(baseFont string) (string, *trueTypeFont, error) {
+
+	for _, name := range []string{baseFont, strings.Replace(baseFont, ",", "-", -1)} {
+
+		fileName := filepath.Join(c.fontDir, name+".ttf")
+
+		b, err := c.ctx.readFile(fileName)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", nil, err
+		}
+
+		f, err := parseTrueType(b)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "%s", fileName)
+		}
+
+		return fileName, f, nil
+	}
+
+	return "", nil, nil
+}
+
+// embedFont embeds the TrueType font program fileName for the simple font d using WinAnsiEncoding.
+func (c *pdfaConverter) embedFont(d *PDFDict, baseFont, fileName string, f *trueTypeFont) error {
+
+	sd, err := c.ctx.NewPDFStreamDict(fileName)
+	if err != nil {
+		return err
+	}
+
+	sd.InsertInt("Length1", len(sd.Content))
+
+	if err = encodeStream(sd); err != nil {
+		return err
+	}
+
+	fontFile, err := c.ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	flags := 1 << 5 // nonsymbolic
+	if f.fixedPitch {
+		flags |= 1
+	}
+	if f.italicAngle != 0 {
+		flags |= 1 << 6
+	}
+
+	fd := NewPDFDict()
+	fd.InsertName("Type", "FontDescriptor")
+	fd.InsertName("FontName", baseFont)
+	fd.InsertInt("Flags", flags)
+	fd.Insert("FontBBox", NewRectangle(
+		float64(f.scale(f.bbox[0])), float64(f.scale(f.bbox[1])),
+		float64(f.scale(f.bbox[2])), float64(f.scale(f.bbox[3]))))
+	fd.Insert("ItalicAngle", PDFFloat(f.italicAngle))
+	fd.InsertInt("Ascent", f.scale(f.ascent))
+	fd.InsertInt("Descent", f.scale(f.descent))
+	fd.InsertInt("CapHeight", f.scale(f.capHeight))
+	fd.InsertInt("StemV", 80)
+	fd.InsertInt("MissingWidth", f.scale(f.advances[0]))
+	fd.Insert("FontFile2", *fontFile)
+
+	indRef, err := c.ctx.IndRefForNewObject(fd)
+	if err != nil {
+		return err
+	}
+
+	// The widths have to match the embedded font program.
+	var widths PDFArray
+	for code := 32; code <= 255; code++ {
+		r := rune(code)
+		if code >= 0x80 && code <= 0x9F {
+			var found bool
+			if r, found = winAnsi[code]; !found {
+				r = 0
+			}
+		}
+		widths = append(widths, PDFInteger(f.width(r)))
+	}
+
+	d.Update("Subtype", PDFName("TrueType"))
+	d.Update("Encoding", PDFName("WinAnsiEncoding"))
+	d.Update("FirstChar", PDFInteger(32))
+	d.Update("LastChar", PDFInteger(255))
+	d.Update("Widths", widths)
+	d.Update("FontDescriptor", *indRef)
+
+	return nil
+}
+
+// fonts embeds missing simple fonts using TrueType font programs named after the font from the font directory.
+func (c *pdfaConverter) fonts() error {
+
+	var err error
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		if sd != nil || err != nil || d.Type() == nil || *d.Type() != "Font" || d.Subtype() == nil {
+			return
+		}
+
+		subtype := *d.Subtype()
+
+		switch subtype {
+		case "Type3", "CIDFontType0", "CIDFontType2":
+			return
+		}
+
+		if subtype == "Type0" {
+			arr, _ := c.ctx.DereferenceArray(d.Dict["DescendantFonts"])
+			if arr != nil && len(*arr) == 1 {
+				if fd := c.dict((*arr)[0]); fd != nil && !c.fontEmbedded(fd) {
+					c.unfixable("font object #%d: composite font %s not embedded", objNr, c.name(d.Dict["BaseFont"]))
+				}
+			}
+			return
+		}
+
+		if c.fontEmbedded(d) {
+			return
+		}
+
+		baseFont := c.name(d.Dict["BaseFont"])
+
+		// Strip any subset tag.
+		if i := strings.Index(baseFont, "+"); i == 6 {
+			baseFont = baseFont[i+1:]
+		}
+
+		if enc, found := d.Find("Encoding"); found && c.name(enc) != "WinAnsiEncoding" || baseFont == "Symbol" || baseFont == "ZapfDingbats" {
+			c.unfixable("font object #%d: %s not embedded, encoding not supported for embedding", objNr, baseFont)
+			return
+		}
+
+		if c.fontDir == "" {
+			c.unfixable("font object #%d: %s not embedded, no font directory", objNr, baseFont)
+			return
+		}
+
+		fileName, f, e := c.fontFile(baseFont)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if f == nil {
+			c.unfixable("font object #%d: %s not embedded, missing %s.ttf", objNr, baseFont, baseFont)
+			return
+		}
+
+		if err = c.embedFont(d, baseFont, fileName, f); err != nil {
+			return
+		}
+
+		c.fixed("font object #%d: embedded %s", objNr, filepath.Base(fileName))
+	})
+
+	return err
+}
+
+func (c *pdfaConverter) outputIntent() error {
+
+	d, err := outputIntent(c.ctx.XRefTable, OutputIntentPDFA)
+	if err != nil {
+		return err
+	}
+
+	if d != nil {
+		if sd, err := c.ctx.DereferenceStreamDict(d.Dict["DestOutputProfile"]); err == nil && sd != nil {
+			return nil
+		}
+	}
+
+	if err = AddOutputIntent(c.ctx.XRefTable, sRGBProfile(), OutputIntentPDFA, ""); err != nil {
+		return err
+	}
+
+	c.fixed("added sRGB output intent")
+
+	return nil
+}
+
+func xmpEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func xmpDate(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05-07:00")
+}
+
+// metadata replaces the XMP metadata of the catalog by metadata synthesized from the document info dict
+// and drops the document info dict, which the writer would modify behind the metadata's back.
+func (c *pdfaConverter) metadata() error {
+
+	info := map[string]string{}
+
+	if c.ctx.Info != nil {
+		d, err := c.ctx.DereferenceDict(*c.ctx.Info)
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			for k, o := range d.Dict {
+				if s, err := textString(c.ctx, o); err == nil && s != "" {
+					info[k] = s
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+
+	created := now
+	if t, ok := parseDate(info["CreationDate"]); ok {
+		created = t
+	}
+
+	part := "1"
+	if !c.a1 {
+		part = "2"
+	}
+
+	var props []string
+
+	if s, ok := info["Title"]; ok {
+		props = append(props, `<dc:title><rdf:Alt><rdf:li xml:lang="x-default">`+xmpEscape(s)+`</rdf:li></rdf:Alt></dc:title>`)
+	}
+	if s, ok := info["Author"]; ok {
+		props = append(props, `<dc:creator><rdf:Seq><rdf:li>`+xmpEscape(s)+`</rdf:li></rdf:Seq></dc:creator>`)
+	}
+	if s, ok := info["Subject"]; ok {
+		props = append(props, `<dc:description><rdf:Alt><rdf:li xml:lang="x-default">`+xmpEscape(s)+`</rdf:li></rdf:Alt></dc:description>`)
+	}
+	if s, ok := info["Keywords"]; ok {
+		props = append(props, `<pdf:Keywords>`+xmpEscape(s)+`</pdf:Keywords>`)
+	}
+	if s, ok := info["Creator"]; ok {
+		props = append(props, `<xmp:CreatorTool>`+xmpEscape(s)+`</xmp:CreatorTool>`)
+	}
+
+	props = append(props,
+		`<pdf:Producer>`+xmpEscape(PDFCPULongVersion)+`</pdf:Producer>`,
+		`<xmp:CreateDate>`+xmpDate(created)+`</xmp:CreateDate>`,
+		`<xmp:ModifyDate>`+xmpDate(now)+`</xmp:ModifyDate>`,
+		`<xmp:MetadataDate>`+xmpDate(now)+`</xmp:MetadataDate>`,
+		`<pdfaid:part>`+part+`</pdfaid:part>`,
+		`<pdfaid:conformance>B</pdfaid:conformance>`,
+	)
+
+	xmp := `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about=""
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+ xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+ xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+` + strings.Join(props, "\n") + `
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+	sd := &PDFStreamDict{PDFDict: NewPDFDict(), Content: []byte(xmp)}
+	sd.InsertName("Type", "Metadata")
+	sd.InsertName("Subtype", "XML")
+
+	if err := encodeStream(sd); err != nil {
+		return err
+	}
+
+	indRef, err := c.ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	c.ctx.RootDict.Update("Metadata", *indRef)
+	c.fixed("synthesized PDF/A-%sb XMP metadata", part)
+
+	if c.ctx.Info != nil {
+		c.ctx.Info = nil
+		c.fixed("moved document info into XMP metadata")
+	}
+
+	return nil
+}
+
+// ConvertToPDFA fixes the violations of the rules of the PDF/A conformance level given, one of PDFA1B and PDFA2B:
+// It removes encryption, forbidden actions and additional actions, replaces LZW compression,
+// sets the print flag of annotations, embeds missing simple fonts using the TrueType font programs
+// named after the font found in fontDir, adds an sRGB output intent unless there is one already
+// and synthesizes conformant XMP metadata.
+// ConvertToPDFA returns a report listing the fixes applied and anything it cannot fix prefixed by "cannot fix:".
+// Rules depending on the content of a document like transparency for PDF/A-1 are left to ValidatePDFA.
+func ConvertToPDFA(ctx *PDFContext, level, fontDir string) ([]string, error) {
+
+	log.Debug.Printf("ConvertToPDFA begin: %s\n", level)
+
+	if level != PDFA1B && level != PDFA2B {
+		return nil, errors.Errorf("ConvertToPDFA: unsupported conformance level: %s", level)
+	}
+
+	c := &pdfaConverter{pdfaChecker: &pdfaChecker{ctx: ctx, a1: level == PDFA1B}, fontDir: fontDir}
+
+	c.removeEncryption()
+	c.ensureID()
+	c.compression()
+
+	for _, fix := range []func() error{
+		c.filters,
+		c.actions,
+		c.additionalActions,
+		c.annotationFlags,
+		c.fonts,
+		c.outputIntent,
+		c.metadata,
+	} {
+		if err := fix(); err != nil {
+			return nil, errors.Wrap(err, "ConvertToPDFA")
+		}
+	}
+
+	log.Debug.Println("ConvertToPDFA end")
+
+	return c.report, nil
+}
+
+// sRGBProfile returns an ICC version 2 display profile for sRGB using D50 adapted primaries and a 2.2 gamma.
+func sRGBProfile() []byte {
+
+	be := binary.BigEndian
+
+	s15Fixed16 := func(f float64) []byte {
+		b := make([]byte, 4)
+		be.PutUint32(b, uint32(int32(f*65536+0.5)))
+		return b
+	}
+
+	xyz := func(x, y, z float64) []byte {
+		b := append([]byte("XYZ \x00\x00\x00\x00"), s15Fixed16(x)...)
+		return append(append(b, s15Fixed16(y)...), s15Fixed16(z)...)
+	}
+
+	desc := "sRGB IEC61966-2.1"
+	descTag := []byte("desc\x00\x00\x00\x00")
+	descTag = append(descTag, 0, 0, 0, byte(len(desc)+1))
+	descTag = append(descTag, desc+"\x00"...)
+	descTag = append(descTag, make([]byte, 4+4+2+1+67)...)
+
+	trc := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x02\x33") // gamma 2.2 as u8Fixed8Number
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", descTag},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	header := make([]byte, 128)
+	be.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	be.PutUint16(header[24:], 2018)
+	be.PutUint16(header[26:], 1)
+	be.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	copy(header[68:], xyz(0.9642, 1.0, 0.8249)[8:])
+
+	table := make([]byte, 4+12*len(tags))
+	be.PutUint32(table, uint32(len(tags)))
+
+	var data []byte
+	offsets := map[string]int{}
+	off := len(header) + len(table)
+
+	for i, t := range tags {
+		// The tone reproduction curves share their data.
+		o, found := offsets[string(t.data)]
+		if !found {
+			o = off + len(data)
+			offsets[string(t.data)] = o
+			data = append(data, t.data...)
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		rec := table[4+12*i:]
+		copy(rec, t.sig)
+		be.PutUint32(rec[4:], uint32(o))
+		be.PutUint32(rec[8:], uint32(len(t.data)))
+	}
+
+	b := append(append(header, table...), data...)
+	be.PutUint32(b, uint32(len(b)))
+
+	return b
+}
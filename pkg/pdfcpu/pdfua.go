@@ -0,0 +1,373 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// PDFUA1 is the PDF/UA conformance level checked by ValidatePDFUA.
+const PDFUA1 = "1" // ISO 14289-1
+
+// structElem is a structure element of the structure tree, see 14.7.2.
+type structElem struct {
+	objNr int // 0 for direct objects
+	typ   string
+	dict  *PDFDict
+	page  int // 0 if unknown
+	kids  []*structElem
+}
+
+func (e *structElem) String() string {
+	s := e.typ
+	if e.objNr > 0 {
+		s = fmt.Sprintf("%s (obj#%d)", s, e.objNr)
+	}
+	if e.page > 0 {
+		s = fmt.Sprintf("page %d: %s", e.page, s)
+	}
+	return s
+}
+
+// pdfuaChecker checks a document against the accessibility requirements of PDF/UA.
+type pdfuaChecker struct {
+	*pdfaChecker
+	pages   map[int]int // page number by page dict object number
+	roleMap map[string]string
+	root    *PDFDict // structure tree root
+	elems   []*structElem
+}
+
+func (c *pdfuaChecker) pageNr(o PDFObject) int {
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		return c.pages[indRef.ObjectNumber.Value()]
+	}
+	return 0
+}
+
+func (c *pdfuaChecker) flag(o PDFObject) bool {
+	o, err := c.ctx.Dereference(o)
+	if err != nil {
+		return false
+	}
+	b, ok := o.(PDFBoolean)
+	return ok && b.Value()
+}
+
+func (c *pdfuaChecker) text(o PDFObject) string {
+	if o == nil {
+		return ""
+	}
+	s, err := textString(c.ctx, o)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// standardType resolves the structure type s by the role map of the structure tree root.
+func (c *pdfuaChecker) standardType(s string) string {
+
+	// Guard against circular mappings.
+	for i := 0; i < 10; i++ {
+		t, found := c.roleMap[s]
+		if !found || t == s {
+			break
+		}
+		s = t
+	}
+
+	return s
+}
+
+func (c *pdfuaChecker) structElems(o PDFObject, page int, visited map[int]bool) []*structElem {
+
+	var objNr int
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		objNr = indRef.ObjectNumber.Value()
+		if visited[objNr] {
+			return nil
+		}
+		visited[objNr] = true
+	}
+
+	o, err := c.ctx.Dereference(o)
+	if err != nil || o == nil {
+		return nil
+	}
+
+	if arr, ok := o.(PDFArray); ok {
+		var ee []*structElem
+		for _, o := range arr {
+			ee = append(ee, c.structElems(o, page, visited)...)
+		}
+		return ee
+	}
+
+	d, ok := o.(PDFDict)
+	if !ok {
+		// Marked content identifier
+		return nil
+	}
+
+	// Marked content and object references are no structure elements.
+	if t := d.Type(); t != nil && (*t == "MCR" || *t == "OBJR") {
+		return nil
+	}
+
+	typ := c.name(d.Dict["S"])
+	if typ == "" {
+		return nil
+	}
+
+	if pg := c.pageNr(d.Dict["Pg"]); pg > 0 {
+		page = pg
+	}
+
+	e := &structElem{objNr: objNr, typ: c.standardType(typ), dict: &d, page: page}
+	c.elems = append(c.elems, e)
+
+	e.kids = c.structElems(d.Dict["K"], page, visited)
+
+	return []*structElem{e}
+}
+
+func (c *pdfuaChecker) init() error {
+
+	c.pages = map[int]int{}
+	for i := 1; i <= c.ctx.PageCount; i++ {
+		indRef, err := c.ctx.PageDictIndRef(i)
+		if err != nil {
+			return err
+		}
+		if indRef != nil {
+			c.pages[indRef.ObjectNumber.Value()] = i
+		}
+	}
+
+	c.root = c.dict(c.ctx.RootDict.Dict["StructTreeRoot"])
+	if c.root == nil {
+		return nil
+	}
+
+	c.roleMap = map[string]string{}
+	if d := c.dict(c.root.Dict["RoleMap"]); d != nil {
+		for k, v := range d.Dict {
+			if s := c.name(v); s != "" {
+				c.roleMap[k] = s
+			}
+		}
+	}
+
+	c.structElems(c.root.Dict["K"], 0, map[int]bool{})
+
+	return nil
+}
+
+func (c *pdfuaChecker) tagged() []string {
+
+	var ss []string
+
+	if c.root == nil {
+		ss = append(ss, "catalog: missing StructTreeRoot")
+	} else if len(c.elems) == 0 {
+		ss = append(ss, "structure tree: no structure elements")
+	}
+
+	if d := c.dict(c.ctx.RootDict.Dict["MarkInfo"]); d == nil || !c.flag(d.Dict["Marked"]) {
+		ss = append(ss, "catalog: MarkInfo Marked is not true")
+	}
+
+	return ss
+}
+
+func (c *pdfuaChecker) title() []string {
+
+	var ss []string
+
+	title := false
+
+	if x, err := ReadXMP(c.ctx.XRefTable); err == nil && x != nil {
+		title = x.Property("dc:title") != ""
+	}
+
+	if !title && c.ctx.Info != nil {
+		if d := c.dict(*c.ctx.Info); d != nil {
+			title = c.text(d.Dict["Title"]) != ""
+		}
+	}
+
+	if !title {
+		ss = append(ss, "document: missing title")
+	}
+
+	if d := c.dict(c.ctx.RootDict.Dict["ViewerPreferences"]); d == nil || !c.flag(d.Dict["DisplayDocTitle"]) {
+		ss = append(ss, "catalog: ViewerPreferences DisplayDocTitle is not true")
+	}
+
+	return ss
+}
+
+func (c *pdfuaChecker) language() []string {
+	if c.text(c.ctx.RootDict.Dict["Lang"]) == "" {
+		return []string{"catalog: missing Lang"}
+	}
+	return nil
+}
+
+func (c *pdfuaChecker) figures() []string {
+
+	var ss []string
+
+	for _, e := range c.elems {
+
+		if e.typ != "Figure" && e.typ != "Formula" {
+			continue
+		}
+
+		if c.text(e.dict.Dict["Alt"]) == "" && c.text(e.dict.Dict["ActualText"]) == "" {
+			ss = append(ss, fmt.Sprintf("%s: missing Alt", e))
+		}
+	}
+
+	return ss
+}
+
+func (c *pdfuaChecker) tables() []string {
+
+	var ss []string
+
+	validKids := map[string]map[string]bool{
+		"Table": {"TR": true, "THead": true, "TBody": true, "TFoot": true, "Caption": true},
+		"THead": {"TR": true},
+		"TBody": {"TR": true},
+		"TFoot": {"TR": true},
+		"TR":    {"TH": true, "TD": true},
+	}
+
+	for _, e := range c.elems {
+
+		kids, found := validKids[e.typ]
+		if !found {
+			continue
+		}
+
+		for _, k := range e.kids {
+			if !kids[k.typ] {
+				ss = append(ss, fmt.Sprintf("%s: invalid child %s", e, k.typ))
+			}
+		}
+
+		if e.typ != "Table" {
+			continue
+		}
+
+		// A table shall have header cells.
+		headers := false
+		var walk func(e *structElem)
+		walk = func(e *structElem) {
+			for _, k := range e.kids {
+				if k.typ == "TH" {
+					headers = true
+				}
+				if k.typ != "Table" {
+					walk(k)
+				}
+			}
+		}
+		walk(e)
+
+		if !headers {
+			ss = append(ss, fmt.Sprintf("%s: no header cells", e))
+		}
+	}
+
+	return ss
+}
+
+func (c *pdfuaChecker) tabOrder() []string {
+
+	var ss []string
+
+	for i := 1; i <= c.ctx.PageCount; i++ {
+
+		pageDict, _, err := c.ctx.PageDict(i)
+		if err != nil || pageDict == nil {
+			continue
+		}
+
+		arr, err := c.ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil || arr == nil || len(*arr) == 0 {
+			continue
+		}
+
+		switch tabs := c.name(pageDict.Dict["Tabs"]); tabs {
+		case "S":
+		case "":
+			ss = append(ss, fmt.Sprintf("page %d: annotations without Tabs S", i))
+		default:
+			ss = append(ss, fmt.Sprintf("page %d: annotations with Tabs %s instead of S", i, tabs))
+		}
+	}
+
+	return ss
+}
+
+// pdfuaRules lists the requirements of ISO 14289-1 checked by ValidatePDFUA.
+var pdfuaRules = []struct {
+	clause      string
+	description string
+	check       func(c *pdfuaChecker) []string
+}{
+	{"7.1", "tagged with structure tree", (*pdfuaChecker).tagged},
+	{"7.1", "document title displayed", (*pdfuaChecker).title},
+	{"7.2", "natural language specified", (*pdfuaChecker).language},
+	{"7.3", "figures with alternate text", (*pdfuaChecker).figures},
+	{"7.5", "tables tagged with rows and header cells", (*pdfuaChecker).tables},
+	{"7.18.3", "tab order follows structure", (*pdfuaChecker).tabOrder},
+}
+
+// ValidatePDFUA checks ctx against the accessibility requirements of PDF/UA-1.
+// The report lists the violations rule by rule per page or object.
+func ValidatePDFUA(ctx *PDFContext) (*ComplianceReport, error) {
+
+	log.Debug.Println("ValidatePDFUA begin")
+
+	rep := &ComplianceReport{Standard: "PDF/UA", Level: PDFUA1, Compliant: true}
+
+	if err := ValidateXRefTable(ctx.XRefTable); err != nil {
+		rep.add("syntax", "valid file structure", []string{err.Error()})
+		return rep, nil
+	}
+
+	c := &pdfuaChecker{pdfaChecker: &pdfaChecker{ctx: ctx}}
+
+	if err := c.init(); err != nil {
+		return nil, errors.Wrap(err, "ValidatePDFUA")
+	}
+
+	for _, r := range pdfuaRules {
+		rep.add(r.clause, r.description, r.check(c))
+	}
+
+	log.Debug.Println("ValidatePDFUA end")
+
+	return rep, nil
+}
@@ -0,0 +1,427 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// PDF/X conformance levels supported by ValidatePDFX.
+const (
+	PDFX1A = "1a" // ISO 15930-1 PDF/X-1a:2001
+	PDFX4  = "4"  // ISO 15930-7 PDF/X-4
+)
+
+// pdfxChecker checks a document against the conditions of a PDF/X conformance level.
+type pdfxChecker struct {
+	*pdfaChecker
+	x1a bool
+}
+
+func (c *pdfxChecker) info() *PDFDict {
+	if c.ctx.Info == nil {
+		return nil
+	}
+	return c.dict(*c.ctx.Info)
+}
+
+func (c *pdfxChecker) infoString(k string) string {
+
+	d := c.info()
+	if d == nil {
+		return ""
+	}
+
+	s, err := textString(c.ctx, d.Dict[k])
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+var (
+	pdfxidVersion = regexp.MustCompile(`pdfxid:GTS_PDFXVersion\s*(?:=\s*["']|>)\s*([^"'<]+)`)
+	pdfTrapped    = regexp.MustCompile(`pdf:Trapped\s*(?:=\s*["']|>)\s*([^"'<]+)`)
+)
+
+func (c *pdfxChecker) identification() []string {
+
+	var ss []string
+
+	var trapped string
+	if d := c.info(); d != nil {
+		trapped = c.name(d.Dict["Trapped"])
+	}
+
+	if c.x1a {
+
+		if v := c.infoString("GTS_PDFXVersion"); !strings.HasPrefix(v, "PDF/X-1") {
+			ss = append(ss, fmt.Sprintf("info: GTS_PDFXVersion %q", v))
+		} else if v != "PDF/X-1a:2001" && c.infoString("GTS_PDFXConformance") != "PDF/X-1a:2001" {
+			ss = append(ss, "info: GTS_PDFXConformance other than PDF/X-1a:2001")
+		}
+
+		if c.infoString("Title") == "" {
+			ss = append(ss, "info: missing Title")
+		}
+
+		if trapped != "True" && trapped != "False" {
+			ss = append(ss, "info: Trapped neither True nor False")
+		}
+
+		return ss
+	}
+
+	// PDF/X-4 identifies itself by XMP metadata.
+	sd, err := c.ctx.DereferenceStreamDict(c.ctx.RootDict.Dict["Metadata"])
+	if err != nil || sd == nil {
+		return []string{"catalog: missing XMP metadata"}
+	}
+
+	s := *sd
+	if err = c.ctx.decodeStream(&s); err != nil {
+		return []string{fmt.Sprintf("metadata stream: %v", err)}
+	}
+
+	if m := pdfxidVersion.FindSubmatch(s.Content); m == nil {
+		ss = append(ss, "XMP: missing pdfxid:GTS_PDFXVersion")
+	} else if v := strings.TrimSpace(string(m[1])); v != "PDF/X-4" {
+		ss = append(ss, fmt.Sprintf("XMP: pdfxid:GTS_PDFXVersion %s", v))
+	}
+
+	if m := pdfTrapped.FindSubmatch(s.Content); m == nil && trapped != "True" && trapped != "False" {
+		ss = append(ss, "XMP: missing pdf:Trapped")
+	} else if m != nil {
+		if v := strings.TrimSpace(string(m[1])); v != "True" && v != "False" {
+			ss = append(ss, fmt.Sprintf("XMP: pdf:Trapped %s", v))
+		}
+	}
+
+	return ss
+}
+
+func (c *pdfxChecker) outputIntent() ([]string, error) {
+
+	d, err := outputIntent(c.ctx.XRefTable, OutputIntentPDFX)
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		return []string{"catalog: missing GTS_PDFX output intent"}, nil
+	}
+
+	var ss []string
+
+	if s, err := textString(c.ctx, d.Dict["OutputConditionIdentifier"]); err != nil || s == "" {
+		ss = append(ss, "output intent: missing OutputConditionIdentifier")
+	}
+
+	p, err := outputIntentProfile(c.ctx.XRefTable, d)
+	if err != nil {
+		return append(ss, fmt.Sprintf("output intent: %v", err)), nil
+	}
+
+	if p == nil {
+		// PDF/X-1a permits a characterized printing condition registered by name instead.
+		if _, found := d.Find("RegistryName"); !c.x1a || !found {
+			ss = append(ss, "output intent: missing ICC profile")
+		}
+		return ss, nil
+	}
+
+	if p.class() != "prtr" {
+		ss = append(ss, fmt.Sprintf("output intent: ICC profile of class %q, not an output profile", p.class()))
+	}
+
+	switch cs := p.dataColorSpace(); {
+	case cs == "CMYK" || cs == "GRAY":
+	case cs == "RGB " && !c.x1a:
+	default:
+		ss = append(ss, fmt.Sprintf("output intent: ICC profile color space %q", cs))
+	}
+
+	return ss, nil
+}
+
+func (c *pdfxChecker) rect(o PDFObject) ([]float64, bool) {
+
+	arr, err := c.ctx.DereferenceArray(o)
+	if err != nil || arr == nil || len(*arr) != 4 {
+		return nil, false
+	}
+
+	var ff []float64
+	for _, o := range *arr {
+		f, ok := c.number(o)
+		if !ok {
+			return nil, false
+		}
+		ff = append(ff, f)
+	}
+
+	// Normalize to lower left, upper right.
+	if ff[0] > ff[2] {
+		ff[0], ff[2] = ff[2], ff[0]
+	}
+	if ff[1] > ff[3] {
+		ff[1], ff[3] = ff[3], ff[1]
+	}
+
+	return ff, true
+}
+
+func within(r, s []float64) bool {
+	const eps = 0.01
+	return r[0] >= s[0]-eps && r[1] >= s[1]-eps && r[2] <= s[2]+eps && r[3] <= s[3]+eps
+}
+
+func (c *pdfxChecker) pageBoxes() []string {
+
+	var ss []string
+
+	for i := 1; i <= c.ctx.PageCount; i++ {
+
+		pageDict, inhPAttrs, err := c.ctx.PageDict(i)
+		if err != nil || pageDict == nil {
+			continue
+		}
+
+		mediaBox, ok := c.rect(pageDict.Dict["MediaBox"])
+		if !ok && inhPAttrs != nil && inhPAttrs.mediaBox != nil {
+			mediaBox, ok = c.rect(*inhPAttrs.mediaBox)
+		}
+
+		if !ok {
+			ss = append(ss, fmt.Sprintf("page %d: missing MediaBox", i))
+			continue
+		}
+
+		trimBox, hasTrimBox := c.rect(pageDict.Dict["TrimBox"])
+		artBox, hasArtBox := c.rect(pageDict.Dict["ArtBox"])
+
+		switch {
+		case !hasTrimBox && !hasArtBox:
+			ss = append(ss, fmt.Sprintf("page %d: neither TrimBox nor ArtBox", i))
+			continue
+		case hasTrimBox && hasArtBox:
+			ss = append(ss, fmt.Sprintf("page %d: both TrimBox and ArtBox", i))
+		}
+
+		box, name := trimBox, "TrimBox"
+		if !hasTrimBox {
+			box, name = artBox, "ArtBox"
+		}
+
+		if bleedBox, ok := c.rect(pageDict.Dict["BleedBox"]); ok {
+			if !within(box, bleedBox) {
+				ss = append(ss, fmt.Sprintf("page %d: %s exceeds BleedBox", i, name))
+			}
+			if !within(bleedBox, mediaBox) {
+				ss = append(ss, fmt.Sprintf("page %d: BleedBox exceeds MediaBox", i))
+			}
+		}
+
+		if !within(box, mediaBox) {
+			ss = append(ss, fmt.Sprintf("page %d: %s exceeds MediaBox", i, name))
+		}
+	}
+
+	return ss
+}
+
+// pdfx1aColorSpaces are the color space families not permitted by PDF/X-1a.
+var pdfx1aColorSpaces = map[string]bool{"DeviceRGB": true, "CalRGB": true, "CalGray": true, "Lab": true, "ICCBased": true}
+
+// colorSpaceFamily returns the family of a color space name or array.
+func (c *pdfxChecker) colorSpaceFamily(o PDFObject) string {
+
+	if n := c.name(o); n != "" {
+		return n
+	}
+
+	arr, err := c.ctx.DereferenceArray(o)
+	if err != nil || arr == nil || len(*arr) == 0 {
+		return ""
+	}
+
+	return c.name((*arr)[0])
+}
+
+// colors returns the objects using color spaces other than CMYK, gray, spot colors or patterns thereof.
+func (c *pdfxChecker) colors() []string {
+
+	var ss []string
+
+	usesRGB := func(bb []byte) bool {
+		found := false
+		parseContent(bb, func(op string, operands []PDFObject) error {
+			if op == "rg" || op == "RG" {
+				found = true
+			}
+			if (op == "cs" || op == "CS") && len(operands) == 1 && pdfx1aColorSpaces[c.name(operands[0])] {
+				found = true
+			}
+			return nil
+		})
+		return found
+	}
+
+	for i := 1; i <= c.ctx.PageCount; i++ {
+		pageDict, _, err := c.ctx.PageDict(i)
+		if err != nil || pageDict == nil {
+			continue
+		}
+		bb, err := pageContent(c.ctx.XRefTable, pageDict, false)
+		if err == nil && usesRGB(bb) {
+			ss = append(ss, fmt.Sprintf("page %d: RGB color", i))
+		}
+	}
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+
+		// Color spaces defined as resources.
+		if res := c.dict(d.Dict["Resources"]); res != nil {
+			if csDict := c.dict(res.Dict["ColorSpace"]); csDict != nil {
+				for k, v := range csDict.Dict {
+					if f := c.colorSpaceFamily(v); pdfx1aColorSpaces[f] {
+						ss = append(ss, fmt.Sprintf("object #%d: color space %s: %s", objNr, k, f))
+					}
+				}
+			}
+		}
+
+		if sd == nil || d.Subtype() == nil {
+			return
+		}
+
+		switch *d.Subtype() {
+
+		case "Image":
+			if f := c.colorSpaceFamily(d.Dict["ColorSpace"]); pdfx1aColorSpaces[f] {
+				ss = append(ss, fmt.Sprintf("image object #%d: %s", objNr, f))
+			}
+
+		case "Form":
+			s := *sd
+			if err := c.ctx.decodeStream(&s); err == nil && usesRGB(s.Content) {
+				ss = append(ss, fmt.Sprintf("form object #%d: RGB color", objNr))
+			}
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfxChecker) filters() []string {
+
+	var ss []string
+
+	c.objects(func(objNr int, d *PDFDict, sd *PDFStreamDict) {
+		if sd == nil {
+			return
+		}
+		for _, f := range sd.FilterPipeline {
+			if f.Name == filter.JBIG2 || f.Name == filter.JPX {
+				ss = append(ss, fmt.Sprintf("object #%d: %s filter", objNr, f.Name))
+			}
+		}
+	})
+
+	return ss
+}
+
+func (c *pdfxChecker) encryption() []string {
+	if c.ctx.Encrypt != nil {
+		return []string{"trailer: Encrypt present"}
+	}
+	return nil
+}
+
+// pdfxRules lists the conditions checked by rule identifier since clauses vary between the parts of ISO 15930.
+var pdfxRules = []struct {
+	x1a, x4     bool
+	id          string
+	description string
+	check       func(c *pdfxChecker) ([]string, error)
+}{
+	{true, true, "identification", "conformance level identified, trapping status known",
+		func(c *pdfxChecker) ([]string, error) { return c.identification(), nil }},
+	{true, true, "encryption", "no encryption",
+		func(c *pdfxChecker) ([]string, error) { return c.encryption(), nil }},
+	{true, true, "outputintent", "PDF/X output intent with output condition and ICC output profile",
+		func(c *pdfxChecker) ([]string, error) { return c.outputIntent() }},
+	{true, true, "pageboxes", "TrimBox or ArtBox within BleedBox within MediaBox",
+		func(c *pdfxChecker) ([]string, error) { return c.pageBoxes(), nil }},
+	{true, false, "colors", "CMYK, gray and spot colors only",
+		func(c *pdfxChecker) ([]string, error) { return c.colors(), nil }},
+	{true, false, "filters", "no JBIG2 or JPEG2000 compression",
+		func(c *pdfxChecker) ([]string, error) { return c.filters(), nil }},
+	{true, false, "transparency", "no transparency",
+		func(c *pdfxChecker) ([]string, error) { return c.transparency(), nil }},
+	{true, true, "fonts", "all fonts embedded",
+		func(c *pdfxChecker) ([]string, error) { return c.fonts(), nil }},
+	{true, true, "xobjects", "no PostScript or reference XObjects",
+		func(c *pdfxChecker) ([]string, error) { return c.xObjects(), nil }},
+	{true, true, "actions", "no JavaScript and no actions other than navigation",
+		func(c *pdfxChecker) ([]string, error) { return c.actions() }},
+}
+
+// ValidatePDFX checks ctx against the conditions of the PDF/X conformance level given, which is one of PDFX1A and PDFX4.
+// The report lists the outcome rule by rule.
+func ValidatePDFX(ctx *PDFContext, level string) (*ComplianceReport, error) {
+
+	log.Debug.Printf("ValidatePDFX begin: %s\n", level)
+
+	if level != PDFX1A && level != PDFX4 {
+		return nil, errors.Errorf("ValidatePDFX: unsupported conformance level: %s", level)
+	}
+
+	c := &pdfxChecker{pdfaChecker: &pdfaChecker{ctx: ctx}, x1a: level == PDFX1A}
+
+	rep := &ComplianceReport{Standard: "PDF/X", Level: level, Compliant: true}
+
+	if err := ValidateXRefTable(ctx.XRefTable); err != nil {
+		rep.add("syntax", "valid file structure", []string{err.Error()})
+		return rep, nil
+	}
+
+	for _, r := range pdfxRules {
+
+		if c.x1a && !r.x1a || !c.x1a && !r.x4 {
+			continue
+		}
+
+		ss, err := r.check(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ValidatePDFX: %s", r.id)
+		}
+
+		rep.add(r.id, r.description, ss)
+	}
+
+	log.Debug.Println("ValidatePDFX end")
+
+	return rep, nil
+}
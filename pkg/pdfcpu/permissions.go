@@ -0,0 +1,141 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Permissions represents the user access permissions of an encrypted document, see Table 22.
+type Permissions struct {
+	Print         bool // bit 3: print, in degraded quality unless PrintHighRes is set (rev >= 3)
+	Modify        bool // bit 4: modify other than controlled by Annotate, FillForms and Assemble
+	Copy          bool // bit 5: copy or extract text and graphics
+	Annotate      bool // bit 6: add or modify annotations, fill in and create form fields
+	FillForms     bool // bit 9: fill in existing form fields (rev >= 3)
+	Accessibility bool // bit 10: extract text and graphics for accessibility (rev >= 3)
+	Assemble      bool // bit 11: insert, rotate or delete pages, create bookmarks and thumbnails (rev >= 3)
+	PrintHighRes  bool // bit 12: print in full quality (rev >= 3)
+}
+
+var permissionBits = []struct {
+	name string
+	mask int16
+	flag func(p *Permissions) *bool
+}{
+	{"print", 0x0004, func(p *Permissions) *bool { return &p.Print }},
+	{"modify", 0x0008, func(p *Permissions) *bool { return &p.Modify }},
+	{"copy", 0x0010, func(p *Permissions) *bool { return &p.Copy }},
+	{"annotate", 0x0020, func(p *Permissions) *bool { return &p.Annotate }},
+	{"fillforms", 0x0100, func(p *Permissions) *bool { return &p.FillForms }},
+	{"accessibility", 0x0200, func(p *Permissions) *bool { return &p.Accessibility }},
+	{"assemble", 0x0400, func(p *Permissions) *bool { return &p.Assemble }},
+	{"printhighres", 0x0800, func(p *Permissions) *bool { return &p.PrintHighRes }},
+}
+
+// NewPermissions returns the permissions set in the user access permission flags p.
+func NewPermissions(p int16) Permissions {
+
+	var perms Permissions
+
+	for _, b := range permissionBits {
+		*b.flag(&perms) = p&b.mask > 0
+	}
+
+	return perms
+}
+
+// Flags returns the user access permission flags for p, see Table 22.
+func (p Permissions) Flags() int16 {
+
+	flags := PermissionsNone
+
+	for _, b := range permissionBits {
+		if *b.flag(&p) {
+			flags |= b.mask
+		}
+	}
+
+	return flags
+}
+
+// String returns a comma separated list of the permissions granted.
+func (p Permissions) String() string {
+
+	var ss []string
+
+	for _, b := range permissionBits {
+		if *b.flag(&p) {
+			ss = append(ss, b.name)
+		}
+	}
+
+	if len(ss) == 0 {
+		return "none"
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+// ParsePermissions parses "none", "all" or a comma separated list of
+// print, printhighres, modify, copy, annotate, fillforms, accessibility and assemble.
+func ParsePermissions(s string) (*Permissions, error) {
+
+	s = strings.TrimSpace(s)
+
+	switch s {
+	case "", "none":
+		return &Permissions{}, nil
+	case "all":
+		p := NewPermissions(PermissionsAll)
+		return &p, nil
+	}
+
+	var p Permissions
+
+	for _, name := range strings.Split(s, ",") {
+
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		found := false
+		for _, b := range permissionBits {
+			if b.name == name {
+				*b.flag(&p) = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, errors.Errorf("unknown permission: %s", name)
+		}
+	}
+
+	return &p, nil
+}
+
+// Permissions returns the user access permissions of c.
+func (c *Configuration) Permissions() Permissions {
+	return NewPermissions(c.UserAccessPermissions)
+}
+
+// SetPermissions sets the user access permissions of c.
+func (c *Configuration) SetPermissions(p Permissions) {
+	c.UserAccessPermissions = p.Flags()
+}
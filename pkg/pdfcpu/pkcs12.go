@@ -0,0 +1,488 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with PKCS#12 files (.p12, .pfx) holding a private key and its certificate chain, see RFC 7292.
+// Supported are the password based encryption schemes in use by common tools:
+// pbeWithSHAAnd3-KeyTripleDES-CBC, pbeWithSHAAnd40BitRC2-CBC and PBES2 (PBKDF2 with AES-CBC).
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	oidPKCS12Key3DES      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPKCS12RC2CBC40     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256     = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512     = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidSHA1               = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidEncryptedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509CertificateBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+)
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm algorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue   `asn1:"tag:0,explicit"`
+	Attributes []asn1.RawValue `asn1:"set,optional"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                 `asn1:"optional"`
+	PRF        algorithmIdentifier `asn1:"optional"`
+}
+
+// bmpPassword encodes a password as null terminated UTF-16BE string, see RFC 7292 B.1.
+func bmpPassword(pw string) []byte {
+
+	var b []byte
+	for _, c := range utf16.Encode([]rune(pw)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+
+	return append(b, 0, 0)
+}
+
+// pkcs12KDF derives size bytes of key material for purpose id (1=key, 2=iv, 3=mac), see RFC 7292 B.2.
+func pkcs12KDF(newHash func() hash.Hash, pw, salt []byte, iterations int, id byte, size int) []byte {
+
+	h := newHash()
+	u, v := h.Size(), h.BlockSize()
+
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		n := v * ((len(b) + v - 1) / v)
+		f := make([]byte, n)
+		for i := range f {
+			f[i] = b[i%len(b)]
+		}
+		return f
+	}
+
+	d := bytes.Repeat([]byte{id}, v)
+	i := append(fill(salt), fill(pw)...)
+
+	var out []byte
+	one := big.NewInt(1)
+
+	for len(out) < size {
+
+		h.Reset()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+
+		for j := 1; j < iterations; j++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+
+		out = append(out, a...)
+
+		// I_j = (I_j + B + 1) mod 2^(v*8) for each v byte block of I.
+		b := new(big.Int).SetBytes(fill(a[:u]))
+		b.Add(b, one)
+
+		for k := 0; k < len(i); k += v {
+			x := new(big.Int).SetBytes(i[k : k+v])
+			x.Add(x, b)
+			xb := x.Bytes()
+			if len(xb) > v {
+				xb = xb[len(xb)-v:]
+			}
+			copy(i[k:k+v], make([]byte, v))
+			copy(i[k+v-len(xb):k+v], xb)
+		}
+	}
+
+	return out[:size]
+}
+
+// pbkdf2Key derives a key according to PBKDF2, see RFC 8018 5.2.
+func pbkdf2Key(newHash func() hash.Hash, pw, salt []byte, iterations, size int) []byte {
+
+	prf := hmac.New(newHash, pw)
+
+	var key []byte
+
+	for block := uint32(1); len(key) < size; block++ {
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+
+		t := append([]byte{}, u...)
+
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:size]
+}
+
+func hashForOID(oid asn1.ObjectIdentifier) func() hash.Hash {
+	switch {
+	case oid.Equal(oidSHA1), oid.Equal(oidHMACWithSHA1):
+		return sha1.New
+	case oid.Equal(oidSHA256), oid.Equal(oidHMACWithSHA256):
+		return sha256.New
+	case oid.Equal(oidSHA384):
+		return sha512.New384
+	case oid.Equal(oidSHA512), oid.Equal(oidHMACWithSHA512):
+		return sha512.New
+	}
+	return nil
+}
+
+// pbDecrypt decrypts data encrypted by a password based encryption scheme.
+func pbDecrypt(alg algorithmIdentifier, data []byte, pw string) ([]byte, error) {
+
+	var cb cipher.Block
+	var iv []byte
+
+	switch {
+
+	case alg.Algorithm.Equal(oidPKCS12Key3DES), alg.Algorithm.Equal(oidPKCS12RC2CBC40):
+
+		var params pbeParams
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+
+		bpw := bmpPassword(pw)
+		var err error
+
+		if alg.Algorithm.Equal(oidPKCS12Key3DES) {
+			key := pkcs12KDF(sha1.New, bpw, params.Salt, params.Iterations, 1, 24)
+			iv = pkcs12KDF(sha1.New, bpw, params.Salt, params.Iterations, 2, 8)
+			cb, err = des.NewTripleDESCipher(key)
+		} else {
+			key := pkcs12KDF(sha1.New, bpw, params.Salt, params.Iterations, 1, 5)
+			iv = pkcs12KDF(sha1.New, bpw, params.Salt, params.Iterations, 2, 8)
+			cb, err = newRC2Cipher(key, 40)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+	case alg.Algorithm.Equal(oidPBES2):
+
+		var params pbes2Params
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+
+		if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			return nil, errors.Errorf("unsupported key derivation function %v", params.KeyDerivationFunc.Algorithm)
+		}
+
+		var kdf pbkdf2Params
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return nil, err
+		}
+
+		prf := sha1.New
+		if len(kdf.PRF.Algorithm) > 0 {
+			if prf = hashForOID(kdf.PRF.Algorithm); prf == nil {
+				return nil, errors.Errorf("unsupported pseudorandom function %v", kdf.PRF.Algorithm)
+			}
+		}
+
+		var size int
+		enc := params.EncryptionScheme.Algorithm
+
+		switch {
+		case enc.Equal(oidAES128CBC):
+			size = 16
+		case enc.Equal(oidAES192CBC):
+			size = 24
+		case enc.Equal(oidAES256CBC):
+			size = 32
+		default:
+			return nil, errors.Errorf("unsupported encryption scheme %v", enc)
+		}
+
+		if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+			return nil, err
+		}
+
+		// PBES2 uses the UTF-8 encoded password.
+		var err error
+		if cb, err = aes.NewCipher(pbkdf2Key(prf, []byte(pw), kdf.Salt, kdf.Iterations, size)); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.Errorf("unsupported encryption algorithm %v", alg.Algorithm)
+	}
+
+	if len(iv) != cb.BlockSize() || len(data) == 0 || len(data)%cb.BlockSize() > 0 {
+		return nil, errors.New("corrupt encrypted data")
+	}
+
+	b := make([]byte, len(data))
+	cipher.NewCBCDecrypter(cb, iv).CryptBlocks(b, data)
+
+	c := int(b[len(b)-1])
+	if c == 0 || c > cb.BlockSize() || !bytes.Equal(b[len(b)-c:], bytes.Repeat([]byte{byte(c)}, c)) {
+		return nil, newErrorf(ErrWrongPassword, "decryption failed, wrong password?")
+	}
+
+	return b[:len(b)-c], nil
+}
+
+func verifyPKCS12Mac(md *macData, content []byte, pw string) error {
+
+	newHash := hashForOID(md.Mac.Algorithm.Algorithm)
+	if newHash == nil {
+		return errors.Errorf("unsupported MAC algorithm %v", md.Mac.Algorithm.Algorithm)
+	}
+
+	key := pkcs12KDF(newHash, bmpPassword(pw), md.MacSalt, md.Iterations, 3, newHash().Size())
+
+	mac := hmac.New(newHash, key)
+	mac.Write(content)
+
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return newErrorf(ErrWrongPassword, "incorrect password")
+	}
+
+	return nil
+}
+
+func parseSafeBags(b []byte, pw string) (keys []crypto.PrivateKey, certs []*x509.Certificate, err error) {
+
+	var bags []safeBag
+	if _, err = asn1.Unmarshal(b, &bags); err != nil {
+		return nil, nil, err
+	}
+
+	for _, bag := range bags {
+
+		switch {
+
+		case bag.ID.Equal(oidCertBag):
+			var cb certBag
+			if _, err = asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+				return nil, nil, err
+			}
+			if !cb.ID.Equal(oidX509CertificateBag) {
+				continue
+			}
+			cert, err := x509.ParseCertificate(cb.Data)
+			if err != nil {
+				return nil, nil, err
+			}
+			certs = append(certs, cert)
+
+		case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidShroudedKeyBag):
+			der := bag.Value.Bytes
+			if bag.ID.Equal(oidShroudedKeyBag) {
+				var epki encryptedPrivateKeyInfo
+				if _, err = asn1.Unmarshal(der, &epki); err != nil {
+					return nil, nil, err
+				}
+				if der, err = pbDecrypt(epki.Algorithm, epki.EncryptedData, pw); err != nil {
+					return nil, nil, err
+				}
+			}
+			key, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, certs, nil
+}
+
+type keyWithPublic interface {
+	Public() crypto.PublicKey
+}
+
+type publicKey interface {
+	Equal(crypto.PublicKey) bool
+}
+
+// DecodePKCS12 returns the private key and the certificates contained in a PKCS#12 file protected by password.
+// The certificate belonging to the private key comes first.
+func DecodePKCS12(data []byte, password string) (crypto.PrivateKey, []*x509.Certificate, error) {
+
+	var pfx pfxPDU
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, nil, errors.Wrap(err, "pkcs12: corrupt file")
+	}
+
+	if pfx.Version != 3 || !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, errors.New("pkcs12: unsupported file")
+	}
+
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, errors.Wrap(err, "pkcs12: corrupt file")
+	}
+
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyPKCS12Mac(&pfx.MacData, authSafe, password); err != nil {
+			return nil, nil, errors.Wrap(err, "pkcs12")
+		}
+	}
+
+	var cis []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &cis); err != nil {
+		return nil, nil, errors.Wrap(err, "pkcs12: corrupt file")
+	}
+
+	var keys []crypto.PrivateKey
+	var certs []*x509.Certificate
+
+	for _, ci := range cis {
+
+		var b []byte
+
+		switch {
+
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &b); err != nil {
+				return nil, nil, errors.Wrap(err, "pkcs12: corrupt file")
+			}
+
+		case ci.ContentType.Equal(oidEncryptedData):
+			var ed pkcs12EncryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, errors.Wrap(err, "pkcs12: corrupt file")
+			}
+			eci := ed.EncryptedContentInfo
+			var err error
+			if b, err = pbDecrypt(eci.ContentEncryptionAlgorithm, eci.EncryptedContent, password); err != nil {
+				return nil, nil, errors.Wrap(err, "pkcs12")
+			}
+
+		default:
+			continue
+		}
+
+		kk, cc, err := parseSafeBags(b, password)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "pkcs12")
+		}
+
+		keys = append(keys, kk...)
+		certs = append(certs, cc...)
+	}
+
+	if len(keys) != 1 {
+		return nil, nil, errors.Errorf("pkcs12: expected exactly one private key, found %d", len(keys))
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, errors.New("pkcs12: missing certificate")
+	}
+
+	// Move the certificate of the private key to the front.
+	if k, ok := keys[0].(keyWithPublic); ok {
+		for i, cert := range certs {
+			if pub, ok := cert.PublicKey.(publicKey); ok && pub.Equal(k.Public()) {
+				certs[0], certs[i] = certs[i], certs[0]
+				break
+			}
+		}
+	}
+
+	return keys[0], certs, nil
+}
@@ -0,0 +1,560 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Portfolios
+//
+// A portfolio (12.3.5 Collections) is a document whose embedded files are presented as a collection
+// instead of the document itself which serves as cover sheet for viewers not supporting portfolios.
+// The Collection dict of the catalog defines the view, the columns (schema fields) and the sort order.
+// The values of custom columns live in the collection item dict (CI) of the file specification of a member.
+//
+// A Portfolio describes both: the portfolio to be created and the portfolio extracted,
+// so the description written on extraction recreates the portfolio from the extracted files.
+
+// PortfolioField represents a column of the details view.
+type PortfolioField struct {
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+	Type   string `json:"type"` // text, date, number, filename, description, moddate, creationdate, size or compressedsize
+	Hidden bool   `json:"hidden,omitempty"`
+}
+
+// PortfolioMember represents an embedded file of a portfolio.
+type PortfolioMember struct {
+	File        string            `json:"file"` // path of the file to embed, the file name of an extracted member
+	Description string            `json:"description,omitempty"`
+	Values      map[string]string `json:"values,omitempty"` // values of text, date (YYYY-MM-DD) and number fields by key
+}
+
+// Portfolio represents a collection of embedded files.
+type Portfolio struct {
+	View       string            `json:"view,omitempty"` // details (default), tile or hidden
+	Fields     []PortfolioField  `json:"fields,omitempty"`
+	Sort       []string          `json:"sort,omitempty"` // field keys
+	Descending bool              `json:"descending,omitempty"`
+	Initial    string            `json:"initial,omitempty"` // file name of the member presented initially
+	Members    []PortfolioMember `json:"members"`
+}
+
+// portfolioFieldTypes maps field types to CollectionField subtypes, see 12.3.5 Table 157.
+var portfolioFieldTypes = map[string]string{
+	"text":           "S",
+	"date":           "D",
+	"number":         "N",
+	"filename":       "F",
+	"description":    "Desc",
+	"moddate":        "ModDate",
+	"creationdate":   "CreationDate",
+	"size":           "Size",
+	"compressedsize": "CompressedSize",
+}
+
+var portfolioViews = map[string]string{"details": "D", "tile": "T", "hidden": "H"}
+
+// defaultPortfolioFields are the columns of a portfolio without fields.
+var defaultPortfolioFields = []PortfolioField{
+	{Key: "FileName", Name: "Filename", Type: "filename"},
+	{Key: "Description", Name: "Description", Type: "description"},
+	{Key: "Size", Name: "Size", Type: "size"},
+	{Key: "ModDate", Name: "Last Modification", Type: "moddate"},
+}
+
+const portfolioDateLayout = "2006-01-02"
+
+func keyOf(m map[string]string, v string) string {
+	for k, v1 := range m {
+		if v1 == v {
+			return k
+		}
+	}
+	return ""
+}
+
+func (p *Portfolio) validate() error {
+
+	if len(p.Members) == 0 {
+		return errors.New("pdfcpu: portfolio: missing members")
+	}
+
+	if _, ok := portfolioViews[p.View]; p.View != "" && !ok {
+		return errors.Errorf("pdfcpu: portfolio: invalid view: %s", p.View)
+	}
+
+	fields := map[string]string{}
+
+	for _, f := range p.Fields {
+		if f.Key == "" {
+			return errors.New("pdfcpu: portfolio: missing field key")
+		}
+		if _, ok := fields[f.Key]; ok {
+			return errors.Errorf("pdfcpu: portfolio: duplicate field: %s", f.Key)
+		}
+		if _, ok := portfolioFieldTypes[f.Type]; !ok {
+			return errors.Errorf("pdfcpu: portfolio: field %s: invalid type: %s", f.Key, f.Type)
+		}
+		fields[f.Key] = f.Type
+	}
+
+	for _, k := range p.Sort {
+		if _, ok := fields[k]; !ok && len(p.Fields) > 0 {
+			return errors.Errorf("pdfcpu: portfolio: unknown sort field: %s", k)
+		}
+	}
+
+	names := StringSet{}
+
+	for _, m := range p.Members {
+		_, fn := filepath.Split(m.File)
+		if names[fn] {
+			return errors.Errorf("pdfcpu: portfolio: duplicate file name: %s", fn)
+		}
+		names[fn] = true
+		for k, v := range m.Values {
+			switch fields[k] {
+			case "text":
+			case "date":
+				if _, err := time.Parse(portfolioDateLayout, v); err != nil {
+					return errors.Errorf("pdfcpu: portfolio: %s: invalid date for %s: %s", fn, k, v)
+				}
+			case "number":
+				if _, err := strconv.ParseFloat(v, 64); err != nil {
+					return errors.Errorf("pdfcpu: portfolio: %s: invalid number for %s: %s", fn, k, v)
+				}
+			default:
+				return errors.Errorf("pdfcpu: portfolio: %s: no text, date or number field: %s", fn, k)
+			}
+		}
+	}
+
+	if p.Initial != "" && !names[p.Initial] {
+		return errors.Errorf("pdfcpu: portfolio: initial member not found: %s", p.Initial)
+	}
+
+	return nil
+}
+
+func (p *Portfolio) collectionDict(xRefTable *XRefTable) (*PDFIndirectRef, error) {
+
+	fields := p.Fields
+	if len(fields) == 0 {
+		fields = defaultPortfolioFields
+	}
+
+	schemaDict := NewPDFDict()
+	schemaDict.InsertName("Type", "CollectionSchema")
+
+	for i, f := range fields {
+		d := NewPDFDict()
+		d.InsertName("Type", "CollectionField")
+		d.InsertName("Subtype", portfolioFieldTypes[f.Type])
+		d.Insert("N", encodeText(f.Name))
+		d.InsertInt("O", i+1)
+		if f.Hidden {
+			d.Insert("V", PDFBoolean(false))
+		}
+		schemaDict.Insert(f.Key, d)
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(schemaDict)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Collection")
+	d.Insert("Schema", *indRef)
+
+	view := "details"
+	if p.View != "" {
+		view = p.View
+	}
+	d.InsertName("View", portfolioViews[view])
+
+	if p.Initial != "" {
+		d.Insert("D", encodeText(p.Initial))
+	}
+
+	if len(p.Sort) > 0 {
+		sortDict := NewPDFDict()
+		sortDict.InsertName("Type", "CollectionSort")
+		if len(p.Sort) == 1 {
+			sortDict.InsertName("S", p.Sort[0])
+		} else {
+			sortDict.Insert("S", NewNameArray(p.Sort...))
+		}
+		sortDict.Insert("A", PDFBoolean(!p.Descending))
+		d.Insert("Sort", sortDict)
+	}
+
+	return xRefTable.IndRefForNewObject(d)
+}
+
+// collectionItem returns the collection item dict holding the values of m.
+func (p *Portfolio) collectionItem(m PortfolioMember) PDFDict {
+
+	d := NewPDFDict()
+	d.InsertName("Type", "CollectionItem")
+
+	for _, f := range p.Fields {
+		v, ok := m.Values[f.Key]
+		if !ok {
+			continue
+		}
+		switch f.Type {
+		case "text":
+			d.Insert(f.Key, encodeText(v))
+		case "date":
+			t, _ := time.Parse(portfolioDateLayout, v)
+			d.Insert(f.Key, DateStringLiteral(t))
+		case "number":
+			n, _ := strconv.ParseFloat(v, 64)
+			d.Insert(f.Key, PDFFloat(n))
+		}
+	}
+
+	return d
+}
+
+// CreatePortfolio turns the document into a portfolio of the members of p.
+// The document becomes the cover sheet. Existing embedded files are kept.
+func CreatePortfolio(xRefTable *XRefTable, p *Portfolio) error {
+
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	if xRefTable.Names["EmbeddedFiles"] == nil {
+		if err := xRefTable.LocateNameTree("EmbeddedFiles", true); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range p.Members {
+
+		indRef, err := fileSpectDict(xRefTable, m.File)
+		if err != nil {
+			return err
+		}
+
+		d, err := xRefTable.DereferenceDict(*indRef)
+		if err != nil {
+			return err
+		}
+
+		// Desc shows up in the description column.
+		d.Delete("Desc")
+		if m.Description != "" {
+			d.Insert("Desc", encodeText(m.Description))
+		}
+
+		d.Update("CI", p.collectionItem(m))
+
+		_, fn := filepath.Split(m.File)
+
+		if err = xRefTable.Names["EmbeddedFiles"].Add(xRefTable, fn, *indRef); err != nil {
+			return err
+		}
+	}
+
+	indRef, err := p.collectionDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootDict.Update("Collection", *indRef)
+
+	// Collections need V1.7.
+	if xRefTable.Version() < V17 {
+		v := V17
+		xRefTable.RootVersion = &v
+	}
+
+	log.Debug.Printf("CreatePortfolio: %d members\n", len(p.Members))
+
+	return nil
+}
+
+// portfolioValue returns the collection item value o of a field of type typ.
+func portfolioValue(xRefTable *XRefTable, o PDFObject, typ string) string {
+
+	o, err := xRefTable.Dereference(o)
+	if err != nil {
+		return ""
+	}
+
+	// A collection subitem carries the value in D.
+	if d, ok := o.(PDFDict); ok {
+		if o, err = xRefTable.Dereference(d.Dict["D"]); err != nil {
+			return ""
+		}
+	}
+
+	switch o := o.(type) {
+
+	case PDFInteger:
+		return strconv.Itoa(o.Value())
+
+	case PDFFloat:
+		return strconv.FormatFloat(o.Value(), 'f', -1, 64)
+
+	case PDFStringLiteral, PDFHexLiteral:
+		s, err := fieldValueString(xRefTable, o)
+		if err != nil {
+			return ""
+		}
+		if typ == "date" {
+			if t, ok := parseDate(s); ok {
+				return t.Format(portfolioDateLayout)
+			}
+		}
+		return s
+	}
+
+	return ""
+}
+
+func portfolioFields(xRefTable *XRefTable, d *PDFDict) ([]PortfolioField, error) {
+
+	schema, err := xRefTable.DereferenceDict(d.Dict["Schema"])
+	if err != nil || schema == nil {
+		return nil, err
+	}
+
+	type field struct {
+		PortfolioField
+		order int
+	}
+
+	var ff []field
+
+	for k, o := range schema.Dict {
+
+		if k == "Type" {
+			continue
+		}
+
+		fd, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if fd == nil {
+			continue
+		}
+
+		f := field{PortfolioField: PortfolioField{Key: k}}
+
+		if st := fd.NameEntry("Subtype"); st != nil {
+			f.Type = keyOf(portfolioFieldTypes, *st)
+		}
+
+		if f.Name, err = fieldValueString(xRefTable, fd.Dict["N"]); err != nil {
+			return nil, err
+		}
+
+		if o := fd.IntEntry("O"); o != nil {
+			f.order = *o
+		}
+
+		if v := fd.BooleanEntry("V"); v != nil && !*v {
+			f.Hidden = true
+		}
+
+		ff = append(ff, f)
+	}
+
+	sort.SliceStable(ff, func(i, j int) bool {
+		if ff[i].order != ff[j].order {
+			return ff[i].order < ff[j].order
+		}
+		return ff[i].Key < ff[j].Key
+	})
+
+	fields := make([]PortfolioField, len(ff))
+	for i, f := range ff {
+		fields[i] = f.PortfolioField
+	}
+
+	return fields, nil
+}
+
+func portfolioSort(xRefTable *XRefTable, d *PDFDict, p *Portfolio) error {
+
+	sd, err := xRefTable.DereferenceDict(d.Dict["Sort"])
+	if err != nil || sd == nil {
+		return err
+	}
+
+	o, err := xRefTable.Dereference(sd.Dict["S"])
+	if err != nil {
+		return err
+	}
+
+	switch o := o.(type) {
+	case PDFName:
+		p.Sort = []string{o.Value()}
+	case PDFArray:
+		for _, o1 := range o {
+			if n, ok := o1.(PDFName); ok {
+				p.Sort = append(p.Sort, n.Value())
+			}
+		}
+	}
+
+	// Only the first field's sort direction gets exported.
+	o, err = xRefTable.Dereference(sd.Dict["A"])
+	if err != nil {
+		return err
+	}
+
+	if arr, ok := o.(PDFArray); ok && len(arr) > 0 {
+		o = arr[0]
+	}
+
+	if b, ok := o.(PDFBoolean); ok {
+		p.Descending = !b.Value()
+	}
+
+	return nil
+}
+
+// PortfolioInfo returns the portfolio of the document, nil if the document is not a portfolio.
+func PortfolioInfo(xRefTable *XRefTable) (*Portfolio, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := xRefTable.DereferenceDict(rootDict.Dict["Collection"])
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	p := &Portfolio{}
+
+	if v := d.NameEntry("View"); v != nil {
+		p.View = keyOf(portfolioViews, *v)
+	}
+
+	if p.Fields, err = portfolioFields(xRefTable, d); err != nil {
+		return nil, err
+	}
+
+	if err = portfolioSort(xRefTable, d, p); err != nil {
+		return nil, err
+	}
+
+	if o, found := d.Find("D"); found {
+		if p.Initial, err = fieldValueString(xRefTable, o); err != nil {
+			return nil, err
+		}
+	}
+
+	if xRefTable.Names["EmbeddedFiles"] == nil {
+		if err = xRefTable.LocateNameTree("EmbeddedFiles", false); err != nil {
+			return nil, err
+		}
+	}
+
+	tree := xRefTable.Names["EmbeddedFiles"]
+	if tree == nil {
+		return p, nil
+	}
+
+	types := map[string]string{}
+	for _, f := range p.Fields {
+		types[f.Key] = f.Type
+	}
+
+	err = tree.Process(xRefTable, func(xRefTable *XRefTable, k string, v PDFObject) error {
+
+		m := PortfolioMember{File: k}
+
+		fs, err := xRefTable.DereferenceDict(v)
+		if err != nil || fs == nil {
+			return err
+		}
+
+		if o, found := fs.Find("Desc"); found {
+			if m.Description, err = fieldValueString(xRefTable, o); err != nil {
+				return err
+			}
+		}
+
+		ci, err := xRefTable.DereferenceDict(fs.Dict["CI"])
+		if err != nil {
+			return err
+		}
+
+		if ci != nil {
+			for k, o := range ci.Dict {
+				typ := types[k]
+				if typ != "text" && typ != "date" && typ != "number" {
+					continue
+				}
+				if m.Values == nil {
+					m.Values = map[string]string{}
+				}
+				m.Values[k] = portfolioValue(xRefTable, o, typ)
+			}
+		}
+
+		p.Members = append(p.Members, m)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// PortfolioJSON returns p as indented JSON.
+func PortfolioJSON(p *Portfolio) ([]byte, error) {
+	return json.MarshalIndent(p, "", "\t")
+}
+
+// ParsePortfolioJSON parses a portfolio written by PortfolioJSON.
+func ParsePortfolioJSON(b []byte) (*Portfolio, error) {
+
+	p := &Portfolio{}
+
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: invalid portfolio")
+	}
+
+	return p, nil
+}
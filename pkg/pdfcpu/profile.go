@@ -0,0 +1,65 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Profiling
+//
+// With ProfileLabels set CPU profile samples get labelled "pdfcpu" with the phase being processed:
+// "read", "validate", "optimize" or "write".
+// Goroutines started during a phase, eg. for concurrent page processing, inherit its label.
+// Use eg. go tool pprof -tagfocus pdfcpu=optimize to restrict a profile to a single phase.
+
+// The label key for phases.
+const profileLabelKey = "pdfcpu"
+
+// Processing phases.
+const (
+	PhaseRead     = "read"
+	PhaseValidate = "validate"
+	PhaseOptimize = "optimize"
+	PhaseWrite    = "write"
+)
+
+// profilePhase calls f labelling CPU profile samples with phase if enabled.
+func profilePhase(c context.Context, enabled bool, phase string, f func() error) error {
+
+	if !enabled {
+		return f()
+	}
+
+	if c == nil {
+		c = context.Background()
+	}
+
+	var err error
+
+	pprof.Do(c, pprof.Labels(profileLabelKey, phase), func(context.Context) {
+		err = f()
+	})
+
+	return err
+}
+
+// profile calls f labelling CPU profile samples with phase if xRefTable enables ProfileLabels.
+func (xRefTable *XRefTable) profile(phase string, f func() error) error {
+	return profilePhase(xRefTable.Context, xRefTable.ProfileLabels, phase, f)
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Custom document properties are document info dict entries not defined by ISO 32000-1 14.3.3.
+// Like Acrobat pdfcpu mirrors them into the XMP metadata using the pdfx namespace.
+
+var standardInfoKeys = StringSet{
+	"Title":        true,
+	"Author":       true,
+	"Subject":      true,
+	"Keywords":     true,
+	"Creator":      true,
+	"Producer":     true,
+	"CreationDate": true,
+	"ModDate":      true,
+	"Trapped":      true,
+}
+
+// validPropertyName returns true if name is usable as document info key and XMP property name.
+func validPropertyName(name string) bool {
+
+	if name == "" {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c == '_':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func checkPropertyName(name string) error {
+
+	if standardInfoKeys[name] {
+		return errors.Errorf("pdfcpu: %s is no custom property", name)
+	}
+
+	if !validPropertyName(name) {
+		return errors.Errorf("pdfcpu: invalid property name: %q", name)
+	}
+
+	return nil
+}
+
+// Properties returns the custom document properties.
+func Properties(ctx *PDFContext) (map[string]string, error) {
+
+	d, err := infoDict(ctx, false)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	m := map[string]string{}
+
+	for k, o := range d.Dict {
+		if !standardInfoKeys[k] {
+			m[k] = infoEntryString(ctx, d, k, o)
+		}
+	}
+
+	return m, nil
+}
+
+// ListProperties returns the custom document properties as "name = value" sorted by name.
+func ListProperties(ctx *PDFContext) ([]string, error) {
+
+	m, err := Properties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m) == 0 {
+		return []string{"no properties"}, nil
+	}
+
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ss []string
+	for _, k := range keys {
+		ss = append(ss, k+" = "+m[k])
+	}
+
+	return ss, nil
+}
+
+// AddProperties adds or replaces the custom document properties props.
+func AddProperties(ctx *PDFContext, props map[string]string) error {
+
+	if len(props) == 0 {
+		return errors.New("pdfcpu: missing properties")
+	}
+
+	for k, v := range props {
+		if err := checkPropertyName(k); err != nil {
+			return err
+		}
+		if strings.TrimSpace(v) == "" {
+			return errors.Errorf("pdfcpu: missing value for property %s", k)
+		}
+	}
+
+	d, err := infoDict(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range props {
+		updateInfoText(d, k, v)
+	}
+
+	x, err := ReadXMP(ctx.XRefTable)
+	if err != nil || x == nil {
+		return err
+	}
+
+	for k, v := range props {
+		if err = x.SetProperty("pdfx:"+k, v); err != nil {
+			return err
+		}
+	}
+
+	return WriteXMP(ctx.XRefTable, x)
+}
+
+// RemoveProperties removes the custom document properties names or all of them if names is empty.
+func RemoveProperties(ctx *PDFContext, names []string) error {
+
+	m, err := Properties(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		for k := range m {
+			names = append(names, k)
+		}
+	}
+
+	for _, k := range names {
+		if _, found := m[k]; !found {
+			return errors.Errorf("pdfcpu: no such property: %s", k)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	d, err := infoDict(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range names {
+		d.Delete(k)
+	}
+
+	x, err := ReadXMP(ctx.XRefTable)
+	if err != nil || x == nil {
+		return err
+	}
+
+	for _, k := range names {
+		if !validPropertyName(k) {
+			// Never made it into XMP.
+			continue
+		}
+		if err = x.RemoveProperty("pdfx:" + k); err != nil {
+			return err
+		}
+	}
+
+	return WriteXMP(ctx.XRefTable, x)
+}
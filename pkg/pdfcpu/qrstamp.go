@@ -0,0 +1,151 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/qrcode"
+
+	"github.com/pkg/errors"
+)
+
+// QRCodeHashPlaceholder marks the position of the document hash within the URL template of a QR code stamp.
+const QRCodeHashPlaceholder = "{hash}"
+
+// The quiet zone surrounding a QR code in modules.
+const qrCodeQuietZone = 4
+
+// ParseQRCodeStampDetails parses a QR code stamp command string into an internal structure.
+// The first entry is the verification URL template which has to contain QRCodeHashPlaceholder.
+func ParseQRCodeStampDetails(s string) (*Watermark, error) {
+
+	// Set default QR code stamp
+	wm := &Watermark{
+		onTop:         true,
+		scale:         0.2,
+		scaleAbs:      false,
+		diagonal:      noDiagonal,
+		opacity:       1.0,
+		strokeOpacity: 1.0,
+		objs:          IntSet{},
+		fCache:        formCache{},
+	}
+
+	ss := strings.Split(s, ",")
+
+	url := strings.TrimSpace(ss[0])
+	if !strings.Contains(url, QRCodeHashPlaceholder) {
+		return nil, errors.Errorf("QR code URL template must contain %s: %s\n", QRCodeHashPlaceholder, url)
+	}
+	wm.qrURL = url
+
+	err := parseWatermarkConfig(ss[1:], wm)
+	if err != nil {
+		return nil, err
+	}
+
+	return wm, nil
+}
+
+// IsQRCode returns whether the watermark content is a QR code.
+func (wm Watermark) IsQRCode() bool {
+	return len(wm.qrURL) > 0
+}
+
+// QRCodeURL returns the verification URL for hash.
+func (wm Watermark) QRCodeURL(hash string) string {
+	return strings.Replace(wm.qrURL, QRCodeHashPlaceholder, hash, -1)
+}
+
+// SetQRCodeHash sets the QR code content to the verification URL for hash.
+func (wm *Watermark) SetQRCodeHash(hash string) error {
+
+	url := wm.QRCodeURL(hash)
+
+	c, err := qrcode.Encode([]byte(url), qrcode.Medium)
+	if err != nil {
+		return err
+	}
+
+	wm.qrCode = c
+	wm.qrCodeURL = url
+
+	return nil
+}
+
+// qrCodeImageBuf renders c including its quiet zone as 1 bit DeviceGray image data.
+func qrCodeImageBuf(c *qrcode.Code) ([]byte, int) {
+
+	n := c.Size + 2*qrCodeQuietZone
+	bytesPerRow := (n + 7) / 8
+	buf := make([]byte, bytesPerRow*n)
+
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if !c.Black(x-qrCodeQuietZone, y-qrCodeQuietZone) {
+				// 1 = white
+				buf[y*bytesPerRow+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+
+	return buf, n
+}
+
+func createQRCodeResForWM(xRefTable *XRefTable, wm *Watermark) error {
+
+	if wm.qrCode == nil {
+		return errors.New("createQRCodeResForWM: missing QR code content")
+	}
+
+	buf, n := qrCodeImageBuf(wm.qrCode)
+
+	sd := &PDFStreamDict{
+		PDFDict: PDFDict{
+			Dict: map[string]PDFObject{
+				"Type":             PDFName("XObject"),
+				"Subtype":          PDFName("Image"),
+				"Width":            PDFInteger(n),
+				"Height":           PDFInteger(n),
+				"BitsPerComponent": PDFInteger(1),
+				"ColorSpace":       PDFName(DeviceGrayCS),
+				"Interpolate":      PDFBoolean(false),
+			},
+		},
+		Content:        buf,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	sd.InsertName("Filter", filter.Flate)
+
+	err := encodeStream(sd)
+	if err != nil {
+		return err
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	wm.image = indRef
+	wm.imgWidth = n
+	wm.imgHeight = n
+
+	return nil
+}
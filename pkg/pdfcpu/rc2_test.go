@@ -0,0 +1,62 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 2268 section 5.
+func TestRC2(t *testing.T) {
+
+	for _, tt := range []struct {
+		key    string
+		t1     int
+		pt, ct string
+	}{
+		{"0000000000000000", 63, "0000000000000000", "ebb773f993278eff"},
+		{"ffffffffffffffff", 64, "ffffffffffffffff", "278b27e42e2f0d49"},
+		{"3000000000000000", 64, "1000000000000001", "30649edf9be7d2c2"},
+		{"88", 64, "0000000000000000", "61a8a244adacccf0"},
+		{"88bca90e90875a", 64, "0000000000000000", "6ccf4308974c267f"},
+		{"88bca90e90875a7f0f79c384627bafb2", 64, "0000000000000000", "1a807d272bbe5db1"},
+		{"88bca90e90875a7f0f79c384627bafb2", 128, "0000000000000000", "2269552ab0f85ca6"},
+	} {
+		key, _ := hex.DecodeString(tt.key)
+		pt, _ := hex.DecodeString(tt.pt)
+		ct, _ := hex.DecodeString(tt.ct)
+
+		c, err := newRC2Cipher(key, tt.t1)
+		if err != nil {
+			t.Fatalf("key %s: %v\n", tt.key, err)
+		}
+
+		b := make([]byte, 8)
+
+		c.Encrypt(b, pt)
+		if !bytes.Equal(b, ct) {
+			t.Errorf("key %s: encrypt got %x, want %s\n", tt.key, b, tt.ct)
+		}
+
+		c.Decrypt(b, ct)
+		if !bytes.Equal(b, pt) {
+			t.Errorf("key %s: decrypt got %x, want %s\n", tt.key, b, tt.pt)
+		}
+	}
+}
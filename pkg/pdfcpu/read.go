@@ -20,7 +20,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
-	"os"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -38,9 +38,11 @@ const (
 // ReadPDFFile reads in a PDFFile and generates a PDFContext, an in-memory representation containing a cross reference table.
 func ReadPDFFile(fileName string, config *Configuration) (*PDFContext, error) {
 
-	log.Debug.Println("readPDFFile: begin")
+	if config == nil {
+		config = NewDefaultConfiguration()
+	}
 
-	file, err := os.Open(fileName)
+	file, err := openReadSeeker(config.FS, fileName)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can't open %q", fileName)
 	}
@@ -49,11 +51,57 @@ func ReadPDFFile(fileName string, config *Configuration) (*PDFContext, error) {
 		file.Close()
 	}()
 
-	ctx, err := NewPDFContext(fileName, file, config)
+	ctx, err := readPDF(fileName, file, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.LazyLoading {
+		// Lazily loaded streams reopen the file.
+		ctx.Read.RS = nil
+	}
+
+	return ctx, nil
+}
+
+// Read reads in a PDF from rs and generates a PDFContext, an in-memory representation containing a cross reference table.
+// Since all objects are loaded into memory rs is not needed anymore once Read returns
+// unless the configuration enables LazyLoading.
+func Read(rs io.ReadSeeker, config *Configuration) (*PDFContext, error) {
+	return readPDF("", rs, config)
+}
+
+func readPDF(fileName string, rs io.ReadSeeker, config *Configuration) (ctx *PDFContext, err error) {
+
+	if config == nil {
+		config = NewDefaultConfiguration()
+	}
+
+	err = profilePhase(config.Context, config.ProfileLabels, PhaseRead, func() error {
+		if config.Mode == REPAIR {
+			ctx, err = recoverPDF(fileName, rs, config)
+		} else {
+			ctx, err = readPDFContext(fileName, rs, config)
+		}
+		return err
+	})
+
+	return ctx, err
+}
+
+func readPDFContext(fileName string, rs io.ReadSeeker, config *Configuration) (*PDFContext, error) {
+
+	log.Debug.Println("readPDF: begin")
+
+	ctx, err := NewPDFContext(fileName, rs, config)
 	if err != nil {
 		return nil, err
 	}
 
+	if ctx.LazyLoading {
+		ctx.loadStream = lazyStreamLoader(ctx)
+	}
+
 	if ctx.Reader15 {
 		log.Info.Println("PDF Version 1.5 conforming reader")
 	} else {
@@ -63,17 +111,63 @@ func ReadPDFFile(fileName string, config *Configuration) (*PDFContext, error) {
 	// Populate xRefTable.
 	err = readXRefTable(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "xRefTable failed")
+		err = errors.Wrap(err, "xRefTable failed")
+	} else {
+		// Make all objects explicitly available (load into memory) in corresponding xRefTable entries.
+		// Also decode any involved object streams.
+		err = dereferenceXRefTable(ctx, config)
 	}
 
-	// Make all objects explicitly available (load into memory) in corresponding xRefTable entries.
-	// Also decode any involved object streams.
-	err = dereferenceXRefTable(ctx, config)
+	if err != nil {
+		if !repairable(ctx) {
+			return nil, err
+		}
+		log.Info.Printf("readPDF: %v\n", err)
+		ctx1, err1 := repairPDF(fileName, rs, config)
+		if err1 != nil {
+			log.Info.Printf("readPDF: repair failed: %v\n", err1)
+			return nil, err
+		}
+		ctx = ctx1
+	} else if ctx.Mode == REPAIR && len(ctx.Read.CorruptObjs) > 0 && repairable(ctx) {
+		// Corrupt objects may be due to wrong offsets.
+		if ctx1, err := repairPDF(fileName, rs, config); err == nil && len(ctx1.Read.CorruptObjs) < len(ctx.Read.CorruptObjs) {
+			ctx = ctx1
+		}
+	}
+
+	log.Debug.Println("readPDF: end")
+
+	return ctx, nil
+}
+
+// repairable returns true if reading failed due to a corrupt cross reference table
+// as opposed to cancelation or failed authentication.
+func repairable(ctx *PDFContext) bool {
+	return ctx.canceled() == nil && (ctx.Encrypt == nil || ctx.EncKey != nil)
+}
+
+// repairPDF reads a PDF file based on a cross reference table rebuilt by scanning the file.
+func repairPDF(fileName string, rs io.ReadSeeker, config *Configuration) (*PDFContext, error) {
+
+	ctx, err := NewPDFContext(fileName, rs, config)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Debug.Println("readPDFFile: end")
+	if ctx.LazyLoading {
+		ctx.loadStream = lazyStreamLoader(ctx)
+	}
+
+	err = repairXRefTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dereferenceXRefTable(ctx, config)
+	if err != nil {
+		return nil, err
+	}
 
 	return ctx, nil
 }
@@ -138,7 +232,7 @@ func newPositionedReader(rs io.ReadSeeker, offset *int64) (*bufio.Reader, error)
 
 // Get the file offset of the last XRefSection.
 // Go to end of file and search backwards for the first occurrence of startxref {offset} %%EOF
-func offsetLastXRefSection(ra io.ReaderAt, fileSize int64) (*int64, error) {
+func offsetLastXRefSection(rs io.ReadSeeker, fileSize int64) (*int64, error) {
 
 	var bufSize int64 = defaultBufSize
 
@@ -151,7 +245,11 @@ func offsetLastXRefSection(ra io.ReaderAt, fileSize int64) (*int64, error) {
 
 	log.Debug.Printf("offsetLastXRefSection at %d\n", off)
 
-	if _, err := ra.ReadAt(buf, off); err != nil {
+	if _, err := rs.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(rs, buf); err != nil {
 		return nil, err
 	}
 
@@ -317,6 +415,9 @@ func parseObjectStream(objectStreamDict *PDFObjectStreamDict) error {
 	log.Debug.Printf("parseObjectStream begin: decoding %d objects.\n", objectStreamDict.ObjCount)
 
 	decodedContent := objectStreamDict.Content
+	if objectStreamDict.FirstObjOffset < 0 || objectStreamDict.FirstObjOffset > len(decodedContent) {
+		return errors.New("parseObjectStream: corrupt \"First\" entry")
+	}
 	prolog := decodedContent[:objectStreamDict.FirstObjOffset]
 
 	objs := strings.Fields(string(prolog))
@@ -338,6 +439,9 @@ func parseObjectStream(objectStreamDict *PDFObjectStreamDict) error {
 		}
 
 		offset += objectStreamDict.FirstObjOffset
+		if offset < offsetOld || offset > len(decodedContent) {
+			return errors.Errorf("parseObjectStream: corrupt offset for obj %s", objs[i])
+		}
 
 		if i > 0 {
 			dstr := string(decodedContent[offsetOld:offset])
@@ -390,7 +494,7 @@ func extractXRefTableEntriesFromXRefStream(buf []byte, xRefStreamDict PDFXRefStr
 	xrefEntryLen := i1 + i2 + i3
 	log.Debug.Printf("extractXRefTableEntriesFromXRefStream: begin xrefEntryLen = %d\n", xrefEntryLen)
 
-	if len(buf)%xrefEntryLen > 0 {
+	if xrefEntryLen == 0 || len(buf)%xrefEntryLen > 0 {
 		return errors.New("extractXRefTableEntriesFromXRefStream: corrupt xrefstream")
 	}
 
@@ -421,13 +525,23 @@ func extractXRefTableEntriesFromXRefStream(buf []byte, xRefStreamDict PDFXRefStr
 
 		objectNumber := xRefStreamDict.Objects[j]
 
+		// The type field defaults to 1.
+		c1 := int64(1)
+		if i1 > 0 {
+			c1 = bufToInt64(buf[i : i+i1])
+		}
+
 		i2Start := i + i1
 		c2 := bufToInt64(buf[i2Start : i2Start+i2])
 		c3 := bufToInt64(buf[i2Start+i2 : i2Start+i2+i3])
 
+		if c2 < 0 || c3 < 0 || c3 > math.MaxInt32 {
+			return errors.Errorf("extractXRefTableEntriesFromXRefStream: corrupt entry for obj#%d", objectNumber)
+		}
+
 		var xRefTableEntry XRefTableEntry
 
-		switch buf[i] {
+		switch c1 {
 
 		case 0x00:
 			// free object
@@ -457,6 +571,9 @@ func extractXRefTableEntriesFromXRefStream(buf []byte, xRefStreamDict PDFXRefStr
 			// compressed object
 			// generation always 0.
 			log.Debug.Printf("extractXRefTableEntriesFromXRefStream: Object #%d is compressed at obj %5d[%d]\n", objectNumber, c2, c3)
+			if c2 > math.MaxInt32 {
+				return errors.Errorf("extractXRefTableEntriesFromXRefStream: corrupt entry for obj#%d", objectNumber)
+			}
 			objNumberRef := int(c2)
 			objIndex := int(c3)
 
@@ -512,8 +629,9 @@ func xRefStreamDict(ctx *PDFContext, o PDFObject, objNr int, streamOffset int64)
 		return nil, err
 	}
 
-	// Decode xrefstream content
-	if err = saveDecodedStreamContent(nil, &pdfStreamDict, 0, 0, true); err != nil {
+	// Decode xrefstream content.
+	// XRefStreams are not encrypted.
+	if err = ctx.decodeStreamObj(&pdfStreamDict, objNr); err != nil {
 		return nil, errors.Wrapf(err, "xRefStreamDict: cannot decode stream for obj#:%d\n", objNr)
 	}
 
@@ -600,7 +718,7 @@ func parseHybridXRefStream(offset *int64, ctx *PDFContext) error {
 
 	log.Debug.Println("parseHybridXRefStream: begin")
 
-	rd, err := newPositionedReader(ctx.Read.File, offset)
+	rd, err := newPositionedReader(ctx.Read.RS, offset)
 	if err != nil {
 		return err
 	}
@@ -874,15 +992,19 @@ func parseXRefSection(s *bufio.Scanner, ctx *PDFContext) (*int64, error) {
 // if present, shall be used instead of the version specified in the Header.
 // Save PDF Version from header to xRefTable.
 // The header version comes as the first line of the file.
-func headerVersion(ra io.ReaderAt) (*PDFVersion, error) {
+func headerVersion(rs io.ReadSeeker) (*PDFVersion, error) {
 
 	log.Debug.Println("headerVersion begin")
 
 	// Get first line of file which holds the version of this PDFFile.
 	// We call this the header version.
 
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
 	buf := make([]byte, 10)
-	if _, err := ra.ReadAt(buf, 0); err != nil {
+	if _, err := io.ReadFull(rs, buf); err != nil {
 		return nil, err
 	}
 
@@ -911,7 +1033,7 @@ func buildXRefTableStartingAt(ctx *PDFContext, offset *int64) error {
 
 	log.Debug.Println("buildXRefTableStartingAt: begin")
 
-	file := ctx.Read.File
+	file := ctx.Read.RS
 
 	hv, err := headerVersion(file)
 	if err != nil {
@@ -922,6 +1044,12 @@ func buildXRefTableStartingAt(ctx *PDFContext, offset *int64) error {
 
 	for offset != nil {
 
+		if err = ctx.canceled(); err != nil {
+			return err
+		}
+
+		ctx.Read.XRefSections++
+
 		rd, err := newPositionedReader(file, offset)
 		if err != nil {
 			return err
@@ -974,7 +1102,11 @@ func readXRefTable(ctx *PDFContext) (err error) {
 
 	log.Debug.Println("readXRefTable: begin")
 
-	offset, err := offsetLastXRefSection(ctx.Read.File, ctx.Read.FileSize)
+	defer func() {
+		err = markError(ErrCorruptXRef, err)
+	}()
+
+	offset, err := offsetLastXRefSection(ctx.Read.RS, ctx.Read.FileSize)
 	if err != nil {
 		return
 	}
@@ -1309,7 +1441,7 @@ func dict(ctx *PDFContext, pdfDict PDFDict, objNr, genNr, endInd, streamInd int)
 func object(ctx *PDFContext, offset int64, objNr, genNr int) (o PDFObject, endInd, streamInd int, streamOffset int64, err error) {
 
 	var rd io.Reader
-	rd, err = newPositionedReader(ctx.Read.File, &offset)
+	rd, err = newPositionedReader(ctx.Read.RS, &offset)
 	if err != nil {
 		return nil, 0, 0, 0, err
 	}
@@ -1521,13 +1653,13 @@ func int64Object(ctx *PDFContext, objectNumber int) (*int64, error) {
 }
 
 // Reads and returns a file buffer with length = stream length using provided reader positioned at offset.
-func readContentStream(rd io.Reader, streamLength int) ([]byte, error) {
+func readContentStream(rd io.Reader, streamLength int64) ([]byte, error) {
 
 	log.Debug.Printf("readContentStream: begin streamLength:%d\n", streamLength)
 
 	buf := make([]byte, streamLength)
 
-	for totalCount := 0; totalCount < streamLength; {
+	for totalCount := 0; totalCount < len(buf); {
 		count, err := rd.Read(buf[totalCount:])
 		if err != nil {
 			return nil, err
@@ -1541,6 +1673,28 @@ func readContentStream(rd io.Reader, streamLength int) ([]byte, error) {
 	return buf, nil
 }
 
+// resolveStreamLength dereferences the stream length if stream length is an indirect object.
+func resolveStreamLength(ctx *PDFContext, streamDict *PDFStreamDict) (err error) {
+
+	if streamDict.StreamLength != nil {
+		return nil
+	}
+
+	if streamDict.StreamLengthObjNr == nil {
+		return errors.New("resolveStreamLength: missing streamLength")
+	}
+
+	// Get stream length from indirect object
+	streamDict.StreamLength, err = int64Object(ctx, *streamDict.StreamLengthObjNr)
+	if err != nil {
+		return err
+	}
+
+	log.Debug.Printf("resolveStreamLength: new indirect streamLength:%d\n", *streamDict.StreamLength)
+
+	return nil
+}
+
 // LoadEncodedStreamContent loads the encoded stream content from file into PDFStreamDict.
 func loadEncodedStreamContent(ctx *PDFContext, streamDict *PDFStreamDict) ([]byte, error) {
 
@@ -1556,21 +1710,17 @@ func loadEncodedStreamContent(ctx *PDFContext, streamDict *PDFStreamDict) ([]byt
 
 	// Read stream content encoded at offset with stream length.
 
-	// Dereference stream length if stream length is an indirect object.
-	if streamDict.StreamLength == nil {
-		if streamDict.StreamLengthObjNr == nil {
-			return nil, errors.New("LoadEncodedStreamContent: missing streamLength")
-		}
-		// Get stream length from indirect object
-		streamDict.StreamLength, err = int64Object(ctx, *streamDict.StreamLengthObjNr)
-		if err != nil {
-			return nil, err
-		}
-		log.Debug.Printf("LoadEncodedStreamContent: new indirect streamLength:%d\n", *streamDict.StreamLength)
+	if err = resolveStreamLength(ctx, streamDict); err != nil {
+		return nil, err
+	}
+
+	// Don't trust the stream length of a corrupt file for the allocation of the read buffer.
+	if *streamDict.StreamLength < 0 || *streamDict.StreamLength > ctx.Read.FileSize-streamDict.StreamOffset {
+		return nil, errors.Errorf("LoadEncodedStreamContent: corrupt stream length: %d", *streamDict.StreamLength)
 	}
 
 	newOffset := streamDict.StreamOffset
-	rd, err := newPositionedReader(ctx.Read.File, &newOffset)
+	rd, err := newPositionedReader(ctx.Read.RS, &newOffset)
 	if err != nil {
 		return nil, err
 	}
@@ -1579,7 +1729,7 @@ func loadEncodedStreamContent(ctx *PDFContext, streamDict *PDFStreamDict) ([]byt
 
 	// Buffer stream contents.
 	// Read content from disk.
-	rawContent, err := readContentStream(rd, int(*streamDict.StreamLength))
+	rawContent, err := readContentStream(rd, *streamDict.StreamLength)
 	if err != nil {
 		return nil, err
 	}
@@ -1600,29 +1750,27 @@ func saveDecodedStreamContent(ctx *PDFContext, streamDict *PDFStreamDict, objNr,
 
 	log.Debug.Printf("saveDecodedStreamContent: begin decode=%t\n", decode)
 
-	// If the "Identity" crypt filter is used we do not need to decrypt.
-	if ctx != nil && ctx.EncKey != nil {
-		if len(streamDict.FilterPipeline) == 1 && streamDict.FilterPipeline[0].Name == "Crypt" {
-			streamDict.Content = streamDict.Raw
-			return nil
-		}
-	}
-
 	// Special case: If the length of the encoded data is 0, we do not need to decode anything.
 	if len(streamDict.Raw) == 0 {
 		streamDict.Content = streamDict.Raw
 		return nil
 	}
 
-	// ctx gets created after XRefStream parsing.
-	// XRefStreams are not encrypted.
-	if ctx != nil && ctx.EncKey != nil {
-		streamDict.Raw, err = decryptStream(ctx.AES4Streams, streamDict.Raw, objNr, genNr, ctx.EncKey)
+	if ctx.EncKey != nil {
+
+		encrypted, aes, err := streamCrypt(ctx, streamDict)
 		if err != nil {
 			return err
 		}
-		l := int64(len(streamDict.Raw))
-		streamDict.StreamLength = &l
+
+		if encrypted {
+			streamDict.Raw, err = decryptStream(aes, streamDict.Raw, objNr, genNr, ctx.EncKey)
+			if err != nil {
+				return err
+			}
+			l := int64(len(streamDict.Raw))
+			streamDict.StreamLength = &l
+		}
 	}
 
 	if !decode {
@@ -1630,7 +1778,7 @@ func saveDecodedStreamContent(ctx *PDFContext, streamDict *PDFStreamDict, objNr,
 	}
 
 	// Actual decoding of content stream.
-	err = decodeStream(streamDict)
+	err = ctx.decodeStreamObj(streamDict, objNr)
 	if err == filter.ErrUnsupportedFilter {
 		err = nil
 	}
@@ -1730,72 +1878,90 @@ func decodeObjectStreams(ctx *PDFContext) error {
 	sort.Ints(keys)
 
 	for _, objectNumber := range keys {
-
-		// Get XRefTableEntry.
-		entry := ctx.XRefTable.Table[objectNumber]
-		if entry == nil {
-			return errors.Errorf("decodeObjectStream: missing entry for obj#%d\n", objectNumber)
+		if err := decodeObjectStream(ctx, objectNumber); err != nil {
+			if err = ctx.dropCorruptObject(objectNumber, err); err != nil {
+				return err
+			}
 		}
+	}
 
-		log.Debug.Printf("decodeObjectStreams: parsing object stream for obj#%d\n", objectNumber)
+	log.Debug.Println("decodeObjectStreams: end")
 
-		// Parse object stream from file.
-		obj, err := pdfObject(ctx, *entry.Offset, objectNumber, *entry.Generation)
-		if err != nil || obj == nil {
-			return errors.New("decodeObjectStreams: corrupt object stream")
-		}
+	return nil
+}
 
-		// Ensure PDFStreamDict
-		pdfStreamDict, ok := obj.(PDFStreamDict)
-		if !ok {
-			return errors.New("decodeObjectStreams: corrupt object stream")
-		}
+// decodeObjectStream parses the object stream objectNumber and saves it to its xRefTableEntry.
+func decodeObjectStream(ctx *PDFContext, objectNumber int) error {
 
-		// Load encoded stream content to xRefTable.
-		if _, err = loadEncodedStreamContent(ctx, &pdfStreamDict); err != nil {
-			return errors.Wrapf(err, "decodeObjectStreams: problem dereferencing object stream %d", objectNumber)
-		}
+	// Get XRefTableEntry.
+	entry := ctx.XRefTable.Table[objectNumber]
+	if entry == nil {
+		return errors.Errorf("decodeObjectStream: missing entry for obj#%d\n", objectNumber)
+	}
 
-		// Save decoded stream content to xRefTable.
-		if err = saveDecodedStreamContent(ctx, &pdfStreamDict, objectNumber, *entry.Generation, true); err != nil {
-			log.Debug.Printf("obj %d: %s", objectNumber, err)
-			return err
-		}
+	log.Debug.Printf("decodeObjectStreams: parsing object stream for obj#%d\n", objectNumber)
 
-		// Ensure decoded objectArray for object stream dicts.
-		if !pdfStreamDict.IsObjStm() {
-			return errors.New("decodeObjectStreams: corrupt object stream")
-		}
+	// Parse object stream from file.
+	obj, err := pdfObject(ctx, *entry.Offset, objectNumber, *entry.Generation)
+	if err != nil || obj == nil {
+		return errors.New("decodeObjectStreams: corrupt object stream")
+	}
 
-		// We have an object stream.
-		log.Debug.Printf("decodeObjectStreams: object stream #%d\n", objectNumber)
+	// Ensure PDFStreamDict
+	pdfStreamDict, ok := obj.(PDFStreamDict)
+	if !ok {
+		return errors.New("decodeObjectStreams: corrupt object stream")
+	}
 
-		ctx.Read.UsingObjectStreams = true
+	// Load encoded stream content to xRefTable.
+	if _, err = loadEncodedStreamContent(ctx, &pdfStreamDict); err != nil {
+		return errors.Wrapf(err, "decodeObjectStreams: problem dereferencing object stream %d", objectNumber)
+	}
 
-		// Create new object stream dict.
-		pdfObjectStreamDict, err := objectStreamDict(pdfStreamDict)
-		if err != nil {
-			return errors.Wrapf(err, "decodeObjectStreams: problem dereferencing object stream %d", objectNumber)
-		}
+	// Save decoded stream content to xRefTable.
+	if err = saveDecodedStreamContent(ctx, &pdfStreamDict, objectNumber, *entry.Generation, true); err != nil {
+		log.Debug.Printf("obj %d: %s", objectNumber, err)
+		return err
+	}
 
-		log.Debug.Printf("decodeObjectStreams: decoding object stream %d:\n", objectNumber)
+	// Ensure decoded objectArray for object stream dicts.
+	if !pdfStreamDict.IsObjStm() {
+		return errors.New("decodeObjectStreams: corrupt object stream")
+	}
 
-		// Parse all objects of this object stream and save them to pdfObjectStreamDict.ObjArray.
-		if err = parseObjectStream(pdfObjectStreamDict); err != nil {
-			return errors.Wrapf(err, "decodeObjectStreams: problem decoding object stream %d\n", objectNumber)
-		}
+	// We have an object stream.
+	log.Debug.Printf("decodeObjectStreams: object stream #%d\n", objectNumber)
 
-		if pdfObjectStreamDict.ObjArray == nil {
-			return errors.Wrap(err, "decodeObjectStreams: objArray should be set!")
-		}
+	ctx.Read.UsingObjectStreams = true
+
+	// Create new object stream dict.
+	pdfObjectStreamDict, err := objectStreamDict(pdfStreamDict)
+	if err != nil {
+		return errors.Wrapf(err, "decodeObjectStreams: problem dereferencing object stream %d", objectNumber)
+	}
 
-		log.Debug.Printf("decodeObjectStreams: decoded object stream %d:\n", objectNumber)
+	log.Debug.Printf("decodeObjectStreams: decoding object stream %d:\n", objectNumber)
 
-		// Save object stream dict to xRefTableEntry.
-		entry.Object = *pdfObjectStreamDict
+	// Parse all objects of this object stream and save them to pdfObjectStreamDict.ObjArray.
+	if err = parseObjectStream(pdfObjectStreamDict); err != nil {
+		return errors.Wrapf(err, "decodeObjectStreams: problem decoding object stream %d\n", objectNumber)
 	}
 
-	log.Debug.Println("decodeObjectStreams: end")
+	if pdfObjectStreamDict.ObjArray == nil {
+		return errors.Wrap(err, "decodeObjectStreams: objArray should be set!")
+	}
+
+	log.Debug.Printf("decodeObjectStreams: decoded object stream %d:\n", objectNumber)
+
+	// Objects of object streams are unknown to xref tables rebuilt by scanning.
+	if ctx.Read.Repaired {
+		if err = registerCompressedObjects(ctx.XRefTable, objectNumber, pdfObjectStreamDict); err != nil {
+			return err
+		}
+	}
+
+	// Save object stream dict to xRefTableEntry.
+	entry.Object = *pdfObjectStreamDict
 
 	return nil
 }
@@ -1849,6 +2015,15 @@ func handleLinearizationParmDict(ctx *PDFContext, obj PDFObject, objNr int) erro
 
 func loadPDFStreamDict(ctx *PDFContext, sd *PDFStreamDict, objNr, genNr int) error {
 
+	if ctx.LazyLoading {
+		// Stream content gets loaded on first access.
+		if err := resolveStreamLength(ctx, sd); err != nil {
+			return errors.Wrapf(err, "dereferenceObject: problem dereferencing stream %d", objNr)
+		}
+		ctx.Read.BinaryTotalSize += *sd.StreamLength
+		return nil
+	}
+
 	// Load encoded stream content for stream dicts into xRefTable entry.
 	if _, err := loadEncodedStreamContent(ctx, sd); err != nil {
 		return errors.Wrapf(err, "dereferenceObject: problem dereferencing stream %d", objNr)
@@ -1977,10 +2152,14 @@ func dereferenceObjects(ctx *PDFContext) error {
 	sort.Ints(keys)
 
 	for _, objNr := range keys {
-		err := dereferenceObject(ctx, objNr)
-		if err != nil {
+		if err := ctx.canceled(); err != nil {
 			return err
 		}
+		if err := dereferenceObject(ctx, objNr); err != nil {
+			if err = ctx.dropCorruptObject(objNr, err); err != nil {
+				return err
+			}
+		}
 	}
 
 	log.Debug.Println("dereferenceObjects: end")
@@ -2067,7 +2246,7 @@ func dereferenceXRefTable(ctx *PDFContext, config *Configuration) error {
 func handleUnencryptedFile(ctx *PDFContext) error {
 
 	if ctx.Mode == DECRYPT || ctx.Mode == ADDPERMISSIONS {
-		return errors.New("decrypt: this file is not encrypted")
+		return newErrorf(ErrNotEncrypted, "decrypt: this file is not encrypted")
 	}
 
 	if ctx.Mode != ENCRYPT {
@@ -2076,7 +2255,7 @@ func handleUnencryptedFile(ctx *PDFContext) error {
 
 	// Encrypt subcommand found.
 
-	if len(ctx.UserPW) == 0 && len(ctx.OwnerPW) == 0 {
+	if len(ctx.UserPW) == 0 && len(ctx.OwnerPW) == 0 && len(ctx.Recipients) == 0 {
 		return errors.New("encrypt: user or/and owner password missing")
 	}
 
@@ -2095,6 +2274,19 @@ func handleUnencryptedFile(ctx *PDFContext) error {
 	return nil
 }
 
+// PrepareEncryption readies ctx for getting encrypted on write.
+// Encrypted files need to be decrypted first by setting ctx.Mode to DECRYPT.
+func PrepareEncryption(ctx *PDFContext) error {
+
+	if ctx.Encrypt != nil && ctx.Mode != DECRYPT {
+		return newErrorf(ErrEncrypted, "encrypt: This file is already encrypted")
+	}
+
+	ctx.Mode = ENCRYPT
+
+	return handleUnencryptedFile(ctx)
+}
+
 func dereferenceEncryptDict(ctx *PDFContext, encryptDictObjNr int) (*PDFDict, error) {
 
 	obj, err := dereferencedObject(ctx, encryptDictObjNr)
@@ -2151,6 +2343,14 @@ func setupEncryptionKey(ctx *PDFContext, encryptDictObjNr int) error {
 
 	log.Debug.Printf("%s\n", encryptDict)
 
+	if filter := encryptDict.NameEntry("Filter"); filter != nil && *filter == "Adobe.PubSec" {
+		if err = setupPubSecKey(ctx, encryptDict); err != nil {
+			return err
+		}
+		ctx.E.ID, err = idBytes(ctx)
+		return err
+	}
+
 	enc, err := supportedEncryption(ctx, encryptDict)
 	if err != nil {
 		return err
@@ -2159,6 +2359,14 @@ func setupEncryptionKey(ctx *PDFContext, encryptDictObjNr int) error {
 		return errors.New("This encryption is not supported")
 	}
 
+	// PDF 2.0 deprecates RC4 and AES-128 and mandates AES-256, see 7.6.4.
+	if *ctx.HeaderVersion == V20 && enc.R < 6 {
+		if !ctx.XRefTable.tolerate(RuleEncryption, "PDF 2.0 file encrypted using revision %d", enc.R) {
+			return errors.Errorf("PDF 2.0 requires AES-256 encryption (revision 6), found revision %d", enc.R)
+		}
+		log.Info.Printf("PDF 2.0 file encrypted using revision %d\n", enc.R)
+	}
+
 	ctx.E = enc
 	//fmt.Printf("read: O = %0X\n", enc.O)
 	//fmt.Printf("read: U = %0X\n", enc.U)
@@ -2179,7 +2387,7 @@ func setupEncryptionKey(ctx *PDFContext, encryptDictObjNr int) error {
 	// If the owner password does not match we generally move on if the user password is correct
 	// unless we need to insist on a correct owner password.
 	if !ok && needsOwnerAndUserPassword(ctx.Mode) {
-		return errors.New("owner password authentication error")
+		return newErrorf(ErrWrongPassword, "owner password authentication error")
 	}
 
 	// Generally the owner password, which is also regarded as the master password or set permissions password
@@ -2194,7 +2402,7 @@ func setupEncryptionKey(ctx *PDFContext, encryptDictObjNr int) error {
 		return err
 	}
 	if !ok {
-		return errors.New("user password authentication error")
+		return newErrorf(ErrWrongPassword, "user password authentication error")
 	}
 
 	if !hasNeededPermissions(ctx.Mode, ctx.E) {
@@ -2218,7 +2426,7 @@ func checkForEncryption(ctx *PDFContext) error {
 
 	if ctx.Mode == ENCRYPT {
 		// We want to encrypt this file.
-		return errors.New("encrypt: This file is already encrypted")
+		return newErrorf(ErrEncrypted, "encrypt: This file is already encrypted")
 	}
 
 	// We need to decrypt this file in order to read it.
@@ -0,0 +1,491 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Recovery of damaged files
+//
+// Reading in REPAIR mode is best effort:
+//
+// - A truncated file gets read up to the end of its last complete revision if possible.
+// - Otherwise the xref table gets rebuilt by scanning the file for objects.
+// - Objects failing to read get dropped.
+//
+// Pages failing validation get removed by DropBrokenPages.
+// All damage found is recorded in ReadContext.Damage.
+
+// truncated returns true if buf does not end with an end-of-file marker.
+func truncated(buf []byte) bool {
+	return !bytes.HasSuffix(bytes.TrimRight(buf, "\x00\t\n\f\r "), []byte("%%EOF"))
+}
+
+// revisionEnds returns the offsets following the end-of-file markers of buf.
+func revisionEnds(buf []byte) []int {
+
+	var ends []int
+
+	marker := []byte("%%EOF")
+
+	for i := 0; ; {
+		j := bytes.Index(buf[i:], marker)
+		if j < 0 {
+			break
+		}
+		i += j + len(marker)
+		ends = append(ends, i)
+	}
+
+	return ends
+}
+
+// recoverPDF reads a damaged PDF file on a best effort basis.
+func recoverPDF(fileName string, rs io.ReadSeeker, config *Configuration) (*PDFContext, error) {
+
+	ctx, err := recoverRevision(fileName, rs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if n := removeDanglingRefs(ctx.XRefTable); n > 0 {
+		ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("removed %d references to missing objects", n))
+	}
+
+	if err = adoptOrphanPages(ctx); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
+
+// recoverRevision reads the last complete revision of a truncated file
+// or else as much of the file as possible.
+func recoverRevision(fileName string, rs io.ReadSeeker, config *Configuration) (*PDFContext, error) {
+
+	// Corrupt streams need to be detected while reading.
+	c := *config
+	c.LazyLoading = false
+	config = &c
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadAll(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !truncated(buf) {
+		return readPDFContext(fileName, bytes.NewReader(buf), config)
+	}
+
+	ends := revisionEnds(buf)
+
+	for i := len(ends) - 1; i >= 0; i-- {
+
+		ctx, err := readPDFContext(fileName, bytes.NewReader(buf[:ends[i]]), config)
+		if err != nil || len(ctx.Read.Damage) > 0 {
+			continue
+		}
+
+		log.Info.Printf("recoverPDF: using revision %d of %d\n", i+1, len(ends))
+		ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("truncated file: dropped %d bytes following the last complete revision", len(buf)-ends[i]))
+
+		return ctx, nil
+	}
+
+	ctx, err := readPDFContext(fileName, bytes.NewReader(buf), config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Read.Damage = append([]string{"truncated file: no complete revision found"}, ctx.Read.Damage...)
+
+	return ctx, nil
+}
+
+// dangling returns true if indRef refers to a missing or deleted object.
+func dangling(xRefTable *XRefTable, indRef PDFIndirectRef) bool {
+	entry, found := xRefTable.FindTableEntry(indRef.ObjectNumber.Value(), indRef.GenerationNumber.Value())
+	return !found || entry.Free
+}
+
+// removeDanglingRefsFromObject removes all references to missing or deleted objects from obj
+// and returns the resulting object along with the number of references removed.
+func removeDanglingRefsFromObject(xRefTable *XRefTable, obj PDFObject) (PDFObject, int) {
+
+	n := 0
+
+	switch o := obj.(type) {
+
+	case PDFDict:
+		for k, v := range o.Dict {
+			if indRef, ok := v.(PDFIndirectRef); ok && dangling(xRefTable, indRef) {
+				// A null dict entry is equivalent to a missing one.
+				delete(o.Dict, k)
+				n++
+				continue
+			}
+			v, m := removeDanglingRefsFromObject(xRefTable, v)
+			if m > 0 {
+				o.Dict[k] = v
+				n += m
+			}
+		}
+
+	case PDFStreamDict:
+		_, n = removeDanglingRefsFromObject(xRefTable, o.PDFDict)
+
+	case PDFArray:
+		arr := PDFArray{}
+		for _, v := range o {
+			if indRef, ok := v.(PDFIndirectRef); ok && dangling(xRefTable, indRef) {
+				n++
+				continue
+			}
+			v, m := removeDanglingRefsFromObject(xRefTable, v)
+			n += m
+			arr = append(arr, v)
+		}
+		if n > 0 {
+			obj = arr
+		}
+
+	}
+
+	return obj, n
+}
+
+// removeDanglingRefs removes all references to missing or deleted objects
+// and returns the number of references removed.
+func removeDanglingRefs(xRefTable *XRefTable) int {
+
+	n := 0
+
+	for objNr, entry := range xRefTable.Table {
+
+		if entry == nil || entry.Free {
+			continue
+		}
+
+		obj, k := removeDanglingRefsFromObject(xRefTable, entry.Object)
+		if k > 0 {
+			log.Info.Printf("removeDanglingRefs: obj#%d: removed %d references\n", objNr, k)
+			entry.Object = obj
+			n += k
+		}
+	}
+
+	return n
+}
+
+// orphanPageNodes returns the sorted object numbers of all page tree nodes lacking a parent except root.
+func orphanPageNodes(xRefTable *XRefTable, root int) []int {
+
+	var objNrs []int
+
+	for objNr, entry := range xRefTable.Table {
+
+		if entry == nil || entry.Free || objNr == root {
+			continue
+		}
+
+		d, ok := entry.Object.(PDFDict)
+		if !ok || d.Type() == nil || (*d.Type() != "Page" && *d.Type() != "Pages") {
+			continue
+		}
+
+		if _, found := d.Find("Parent"); !found {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+
+	sort.Ints(objNrs)
+
+	return objNrs
+}
+
+// adoptOrphanPages appends all pages and page tree nodes whose parent got lost to the page tree root.
+// A missing page tree root gets synthesized.
+func adoptOrphanPages(ctx *PDFContext) error {
+
+	xRefTable := ctx.XRefTable
+
+	catalog, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootRef := catalog.IndirectRefEntry("Pages")
+
+	root := 0
+	if rootRef != nil {
+		root = rootRef.ObjectNumber.Value()
+	} else if _, found := catalog.Find("Pages"); found {
+		return nil
+	}
+
+	orphans := orphanPageNodes(xRefTable, root)
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	if rootRef == nil {
+		d := NewPDFDict()
+		d.InsertName("Type", "Pages")
+		d.Insert("Kids", PDFArray{})
+		d.InsertInt("Count", 0)
+		d.Insert("MediaBox", NewRectangle(0, 0, 595.27, 841.89))
+		if rootRef, err = xRefTable.IndRefForNewObject(d); err != nil {
+			return err
+		}
+		catalog.Insert("Pages", *rootRef)
+		ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("missing page tree: synthesized obj#%d", rootRef.ObjectNumber.Value()))
+	}
+
+	rootDict, err := xRefTable.DereferenceDict(*rootRef)
+	if err != nil || rootDict == nil {
+		return errors.New("adoptOrphanPages: corrupt page tree root")
+	}
+
+	var kids PDFArray
+	if arr := rootDict.PDFArrayEntry("Kids"); arr != nil {
+		kids = *arr
+	}
+
+	count := 0
+	if c := rootDict.IntEntry("Count"); c != nil {
+		count = *c
+	}
+
+	for _, objNr := range orphans {
+
+		entry := xRefTable.Table[objNr]
+		d := entry.Object.(PDFDict)
+		d.Insert("Parent", *rootRef)
+
+		kids = append(kids, *NewPDFIndirectRef(objNr, *entry.Generation))
+
+		if *d.Type() == "Page" {
+			count++
+		} else if c := d.IntEntry("Count"); c != nil {
+			count += *c
+		}
+	}
+
+	rootDict.Update("Kids", kids)
+	rootDict.Update("Count", PDFInteger(count))
+
+	ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("attached %d orphaned page tree nodes to the page tree root", len(orphans)))
+
+	return nil
+}
+
+// dropCorruptObject deletes object objNr which failed to read in REPAIR mode and records the damage.
+// In any other mode err gets returned.
+func (ctx *PDFContext) dropCorruptObject(objNr int, err error) error {
+
+	if ctx.Mode != REPAIR {
+		return err
+	}
+
+	log.Info.Printf("dropping corrupt obj#%d: %v\n", objNr, err)
+
+	ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("dropped corrupt obj#%d: %v", objNr, err))
+	ctx.Read.CorruptObjs[objNr] = true
+
+	entry := ctx.Table[objNr]
+	if entry.Generation == nil {
+		g := 0
+		entry.Generation = &g
+	}
+
+	return ctx.DeleteObject(objNr)
+}
+
+func dropBrokenPages(xRefTable *XRefTable, dict *PDFDict, hasResources, hasMediaBox bool, p *int, path []int, report *[]string) (int, error) {
+
+	if _, found := dict.Find("Resources"); found {
+		hasResources = true
+	}
+
+	if _, found := dict.Find("MediaBox"); found {
+		hasMediaBox = true
+	}
+
+	kidsArray := dict.PDFArrayEntry("Kids")
+	if kidsArray == nil {
+		return 0, errors.New("dropBrokenPages: corrupt \"Kids\" entry")
+	}
+
+	var kids PDFArray
+	count := 0
+
+	for _, obj := range *kidsArray {
+
+		if obj == nil {
+			continue
+		}
+
+		if err := xRefTable.canceled(); err != nil {
+			return 0, err
+		}
+
+		indRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			*report = append(*report, fmt.Sprintf("dropped corrupt page tree node: %v", obj))
+			continue
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+
+		cyclic := false
+		for _, nr := range path {
+			cyclic = cyclic || nr == objNr
+		}
+
+		if cyclic {
+			*report = append(*report, fmt.Sprintf("dropped page tree node obj#%d: page tree cycle", objNr))
+			continue
+		}
+
+		d, err := xRefTable.DereferenceDict(indRef)
+		if err == nil && d == nil {
+			err = errors.New("missing object")
+		}
+
+		var dictType string
+		if err == nil {
+			dictType, err = dictTypeForPageNodeDict(d)
+		}
+
+		if err != nil {
+			*report = append(*report, fmt.Sprintf("dropped page tree node obj#%d: %v", objNr, err))
+			continue
+		}
+
+		switch dictType {
+
+		case "Pages":
+			n, err := dropBrokenPages(xRefTable, d, hasResources, hasMediaBox, p, append(path, objNr), report)
+			if err != nil {
+				if xRefTable.canceled() != nil {
+					return 0, err
+				}
+				*report = append(*report, fmt.Sprintf("dropped page tree node obj#%d: %v", objNr, err))
+				continue
+			}
+			if n == 0 {
+				continue
+			}
+			count += n
+
+		case "Page":
+			*p++
+			err = validateSelectedPage(xRefTable, d, objNr, indRef.GenerationNumber.Value(), hasResources, hasMediaBox)
+			if err != nil {
+				*report = append(*report, fmt.Sprintf("dropped page %d (obj#%d): %v", *p, objNr, err))
+				continue
+			}
+			count++
+
+		default:
+			*report = append(*report, fmt.Sprintf("dropped page tree node obj#%d: unexpected type %s", objNr, dictType))
+			continue
+		}
+
+		kids = append(kids, indRef)
+	}
+
+	dict.Update("Kids", kids)
+	dict.Update("Count", PDFInteger(count))
+
+	return count, nil
+}
+
+// DropBrokenPages removes all pages failing validation from the page tree
+// and returns a description of every page or page tree node dropped.
+func DropBrokenPages(xRefTable *XRefTable) ([]string, error) {
+
+	root, err := xRefTable.Pages()
+	if err != nil {
+		return nil, err
+	}
+
+	if root == nil {
+		return nil, errors.New("DropBrokenPages: missing page tree")
+	}
+
+	dict, err := xRefTable.DereferenceDict(*root)
+	if err != nil {
+		return nil, err
+	}
+
+	if dict == nil {
+		return nil, errors.New("DropBrokenPages: missing page tree root")
+	}
+
+	var report []string
+	p := 0
+
+	n, err := dropBrokenPages(xRefTable, dict, false, false, &p, []int{root.ObjectNumber.Value()}, &report)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, errors.New("DropBrokenPages: no intact pages left")
+	}
+
+	return report, nil
+}
+
+// StripCatalog removes all entries but the page tree from the catalog
+// and returns the keys of the entries removed.
+func StripCatalog(xRefTable *XRefTable) ([]string, error) {
+
+	catalog, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	for k := range catalog.Dict {
+		if k != "Type" && k != "Pages" {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		catalog.Delete(k)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,880 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Redaction removes the content of page areas for good before painting the areas as opaque boxes:
+// Glyphs overlapping an area are dropped from the text showing operators keeping the position of the remaining glyphs.
+// Images and painted paths overlapping an area are dropped except for paths enclosing an area like backgrounds or frames.
+// Form XObjects partially overlapping an area are replaced by a redacted copy.
+// Annotations overlapping an area are removed. So are form fields having a widget overlapping an area
+// along with all their widgets since any widget shows the value of its field.
+
+// RedactConfig specifies the areas to be redacted in addition to the areas of Redact annotations.
+type RedactConfig struct {
+	Rects     []types.Rectangle // areas in default user space applied to all selected pages
+	Terms     []string          // text search terms whose occurrences get redacted
+	MatchCase bool              // distinguish between upper and lower case for Terms
+	Color     string            // #RRGGBB fill color of the boxes, defaults to black
+}
+
+// redactArea is a page area to be redacted along with the fill color of its box.
+type redactArea struct {
+	r types.Rectangle
+	c PDFArray
+}
+
+func (rc RedactConfig) validate() error {
+
+	for _, r := range rc.Rects {
+		if r.Width() <= 0 || r.Height() <= 0 {
+			return errors.Errorf("invalid redaction rect: %s", r)
+		}
+	}
+
+	for _, t := range rc.Terms {
+		if len(t) == 0 {
+			return errors.New("empty redaction term")
+		}
+	}
+
+	if rc.Color != "" {
+		_, err := parseXFDFColor(rc.Color)
+		return err
+	}
+
+	return nil
+}
+
+func (rc RedactConfig) color() PDFArray {
+
+	if rc.Color == "" {
+		return NewNumberArray(0, 0, 0)
+	}
+
+	c, _ := parseXFDFColor(rc.Color)
+
+	return c
+}
+
+// ParseRedactRect parses a redaction area "rect:llx lly urx ury".
+func ParseRedactRect(s string) (types.Rectangle, error) {
+
+	l := &Link{}
+
+	if !strings.HasPrefix(s, "rect:") {
+		return l.Rect, errors.Errorf("invalid redaction rect: %s", s)
+	}
+
+	err := parseLinkRect(strings.TrimPrefix(s, "rect:"), l)
+
+	return l.Rect, err
+}
+
+// overlap returns true if r1 and r2 share some area or if a degenerated r1 lies within r2.
+func overlap(r1, r2 types.Rectangle) bool {
+	return r1.LL.X <= r2.UR.X && r1.UR.X >= r2.LL.X && r1.LL.Y <= r2.UR.Y && r1.UR.Y >= r2.LL.Y &&
+		(r1.LL.X < r2.UR.X && r1.UR.X > r2.LL.X || r1.LL.X == r1.UR.X) &&
+		(r1.LL.Y < r2.UR.Y && r1.UR.Y > r2.LL.Y || r1.LL.Y == r1.UR.Y)
+}
+
+// contains returns true if r1 contains r2.
+func contains(r1, r2 types.Rectangle) bool {
+	return r1.LL.X <= r2.LL.X && r1.LL.Y <= r2.LL.Y && r1.UR.X >= r2.UR.X && r1.UR.Y >= r2.UR.Y
+}
+
+// boundingBox returns the bounding box of rectangle r transformed by m.
+func boundingBox(m matrix, r types.Rectangle) types.Rectangle {
+	ll, lr := transform(m, r.LL.X, r.LL.Y), transform(m, r.UR.X, r.LL.Y)
+	ul, ur := transform(m, r.LL.X, r.UR.Y), transform(m, r.UR.X, r.UR.Y)
+	return quadRect([8]float64{ul[0], ul[1], ur[0], ur[1], ll[0], ll[1], lr[0], lr[1]})
+}
+
+var unitSquare = types.NewRectangle(0, 0, 1, 1)
+
+// redactor removes anything overlapping its areas from content streams.
+type redactor struct {
+	*textExtractor
+	areas []types.Rectangle
+}
+
+func (rd *redactor) overlaps(r types.Rectangle) bool {
+	for _, a := range rd.areas {
+		if overlap(r, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// encloses returns true if r contains an area.
+func (rd *redactor) encloses(r types.Rectangle) bool {
+	for _, a := range rd.areas {
+		if contains(r, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// covered returns true if r lies within an area.
+func (rd *redactor) covered(r types.Rectangle) bool {
+	for _, a := range rd.areas {
+		if contains(a, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
+
+// redactText returns a TJ operator replacing a text showing operator and true if any glyph overlaps an area.
+// Removed glyphs are replaced by a horizontal displacement keeping the position of the remaining glyphs.
+func (rd *redactor) redactText(ts *textState, op string, operands []PDFObject) (string, bool) {
+
+	var parts []string
+	var kept []byte
+	var changed bool
+
+	flush := func() {
+		if len(kept) > 0 {
+			parts = append(parts, "<"+hex.EncodeToString(kept)+">")
+			kept = nil
+		}
+	}
+
+	show := func(b []byte) {
+		rd.showGlyphs(&ts.gs, &ts.tm, b, func(code, i, n int, w float64, trm matrix, quad [8]float64) {
+
+			if !rd.overlaps(quadRect(quad)) {
+				kept = append(kept, b[i:i+n]...)
+				return
+			}
+
+			changed = true
+			flush()
+
+			if ts.gs.size == 0 {
+				return
+			}
+
+			tx := w*ts.gs.size + ts.gs.charSpacing
+			if n == 1 && code == 32 {
+				tx += ts.gs.wordSpace
+			}
+
+			parts = append(parts, formatNumber(-tx*1000/ts.gs.size))
+		})
+	}
+
+	if op == "TJ" {
+		arr, _ := operands[0].(PDFArray)
+		for _, o := range arr {
+			if b, ok := stringBytes(o); ok {
+				show(b)
+				continue
+			}
+			if ff, ok := numbers([]PDFObject{o}); ok {
+				flush()
+				parts = append(parts, formatNumber(ff[0]))
+				adjustText(&ts.gs, &ts.tm, ff[0])
+			}
+		}
+	} else if b, ok := stringBytes(operands[len(operands)-1]); ok {
+		show(b)
+	}
+
+	if !changed {
+		return "", false
+	}
+
+	flush()
+
+	s := "[" + strings.Join(parts, " ") + "] TJ"
+
+	switch op {
+	case "'":
+		s = "T* " + s
+	case "\"":
+		s = fmt.Sprintf("%s Tw %s Tc T* %s", formatNumber(ts.gs.wordSpace), formatNumber(ts.gs.charSpacing), s)
+	}
+
+	return s, true
+}
+
+// redactedForm returns a copy of a form XObject using content and resources.
+func (rd *redactor) redactedForm(sd *PDFStreamDict, content []byte, resources *PDFDict) (*PDFIndirectRef, error) {
+
+	d := NewPDFDict()
+	for k, v := range sd.Dict {
+		switch k {
+		case "Filter", "DecodeParms", "Length":
+			continue
+		}
+		d.Insert(k, v)
+	}
+
+	if resources != nil {
+		d.Update("Resources", *resources)
+	}
+
+	d.InsertName("Filter", filter.Flate)
+
+	fd := &PDFStreamDict{
+		PDFDict:        d,
+		Content:        content,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	if err := encodeStream(fd); err != nil {
+		return nil, err
+	}
+
+	return rd.xRefTable.IndRefForNewObject(*fd)
+}
+
+// redactXObject handles a Do operator and returns its replacement along with true if the operator is to be replaced.
+// forms receives redacted form copies by name.
+func (rd *redactor) redactXObject(resources *PDFDict, name string, ctm matrix, depth int, forms *PDFDict) (string, bool, error) {
+
+	obj, err := rd.resource(resources, "XObject", name)
+	if err != nil || obj == nil {
+		return "", false, err
+	}
+
+	sd, err := rd.xRefTable.DereferenceStreamDict(obj)
+	if err != nil || sd == nil {
+		return "", false, err
+	}
+
+	st := sd.NameEntry("Subtype")
+	if st == nil {
+		return "", false, nil
+	}
+
+	if *st == "Image" {
+		return "", rd.overlaps(boundingBox(ctm, unitSquare)), nil
+	}
+
+	if *st != "Form" {
+		return "", false, nil
+	}
+
+	arr := sd.PDFArrayEntry("BBox")
+	if arr == nil || len(*arr) != 4 {
+		return "", false, nil
+	}
+
+	m, res, err := rd.formContext(sd, resources, ctm)
+	if err != nil {
+		return "", false, err
+	}
+
+	bbox := boundingBox(m, rect(rd.xRefTable, *arr))
+
+	if !rd.overlaps(bbox) {
+		return "", false, nil
+	}
+
+	if rd.covered(bbox) || depth >= maxFormNesting {
+		return "", true, nil
+	}
+
+	err = rd.xRefTable.decodeStream(sd)
+	if err == filter.ErrUnsupportedFilter {
+		return "", true, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	content, res, changed, err := rd.redactContent(sd.Content, res, m, depth+1)
+	if err != nil || !changed {
+		return "", false, err
+	}
+
+	indRef, err := rd.redactedForm(sd, content, res)
+	if err != nil {
+		return "", false, err
+	}
+
+	// The form copy must not collide with the XObjects in use.
+	obj, _ = resources.Find("XObject")
+	xObjects, err := rd.xRefTable.DereferenceDict(obj)
+	if err != nil || xObjects == nil {
+		return "", false, err
+	}
+
+	id := uniqueResourceName("Redacted0", forms, xObjects)
+	forms.Insert(id, *indRef)
+
+	return "/" + id + " Do", true, nil
+}
+
+// redactedResources returns a copy of resources whose XObjects are restricted to the names used by content
+// and extended by forms.
+func (rd *redactor) redactedResources(resources *PDFDict, content []byte, forms PDFDict) (*PDFDict, error) {
+
+	used := StringSet{}
+
+	err := scanContent(content, func(op string, operands []PDFObject, start, end int) error {
+		if op == "Do" && len(operands) == 1 {
+			if name, ok := operands[0].(PDFName); ok {
+				used[name.Value()] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewPDFDict()
+	xObjects := NewPDFDict()
+
+	if resources != nil {
+
+		for k, v := range resources.Dict {
+			d.Insert(k, v)
+		}
+
+		if obj, found := resources.Find("XObject"); found {
+			x, err := rd.xRefTable.DereferenceDict(obj)
+			if err != nil {
+				return nil, err
+			}
+			if x != nil {
+				for k, v := range x.Dict {
+					if used[k] {
+						xObjects.Insert(k, v)
+					}
+				}
+			}
+		}
+	}
+
+	for k, v := range forms.Dict {
+		xObjects.Insert(k, v)
+	}
+
+	d.Update("XObject", xObjects)
+
+	return &d, nil
+}
+
+// redactContent returns content without anything overlapping the areas along with the resources to be used,
+// which are a copy of resources if XObjects got removed or replaced.
+func (rd *redactor) redactContent(bb []byte, resources *PDFDict, ctm matrix, depth int) ([]byte, *PDFDict, bool, error) {
+
+	bb = compactHexStrings(bb)
+
+	ts := &textState{gs: textGraphicsState{ctm: ctm, scale: 1}}
+
+	var b bytes.Buffer
+	var last int // end of the content copied to b
+	var changed, xObjectsChanged bool
+
+	forms := NewPDFDict()
+
+	replace := func(start, end int, s string) {
+		b.Write(bb[last:start])
+		if s != "" {
+			b.WriteString(s)
+			b.WriteByte(' ')
+		}
+		last = end
+		changed = true
+	}
+
+	// The current path starts at pathStart and its points in user space lie within path.
+	pathStart := -1
+	var path types.Rectangle
+	var pathEmpty bool
+
+	addPoints := func(ff []float64) {
+		for i := 0; i+1 < len(ff); i += 2 {
+			p := transform(ts.gs.ctm, ff[i], ff[i+1])
+			if pathEmpty {
+				path = types.NewRectangle(p[0], p[1], p[0], p[1])
+				pathEmpty = false
+				continue
+			}
+			path.LL.X, path.LL.Y = math.Min(path.LL.X, p[0]), math.Min(path.LL.Y, p[1])
+			path.UR.X, path.UR.Y = math.Max(path.UR.X, p[0]), math.Max(path.UR.Y, p[1])
+		}
+	}
+
+	err := scanContent(bb, func(op string, operands []PDFObject, start, end int) error {
+
+		if err := ts.update(rd.textExtractor, resources, op, operands); err != nil {
+			return err
+		}
+
+		ff, isNumbers := numbers(operands)
+
+		switch op {
+
+		case "m", "l", "c", "v", "y", "re":
+			if pathStart < 0 {
+				pathStart = start
+				pathEmpty = true
+			}
+			if !isNumbers {
+				break
+			}
+			if op == "re" && len(ff) == 4 {
+				x, y, w, h := ff[0], ff[1], ff[2], ff[3]
+				ff = []float64{x, y, x + w, y, x, y + h, x + w, y + h}
+			}
+			addPoints(ff)
+
+		case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*":
+			if pathStart < 0 {
+				break
+			}
+			if !pathEmpty && rd.overlaps(path) && !rd.encloses(path) {
+				replace(pathStart, end, "")
+			}
+			pathStart = -1
+
+		case "n":
+			pathStart = -1
+
+		case "Tj", "'", "\"", "TJ":
+			if len(operands) == 0 || op == "TJ" && len(operands) != 1 {
+				break
+			}
+			if s, ok := rd.redactText(ts, op, operands); ok {
+				replace(start, end, s)
+			}
+
+		case "Do":
+			if len(operands) != 1 {
+				break
+			}
+			name, _ := operands[0].(PDFName)
+			s, ok, err := rd.redactXObject(resources, name.Value(), ts.gs.ctm, depth, &forms)
+			if err != nil {
+				return err
+			}
+			if ok {
+				replace(start, end, s)
+				xObjectsChanged = true
+			}
+
+		case "BI":
+			if rd.overlaps(boundingBox(ts.gs.ctm, unitSquare)) {
+				replace(start, end, "")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if !changed {
+		return bb, resources, false, nil
+	}
+
+	b.Write(bb[last:])
+
+	content := b.Bytes()
+
+	if !xObjectsChanged {
+		return content, resources, true, nil
+	}
+
+	res, err := rd.redactedResources(resources, content, forms)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return content, res, true, nil
+}
+
+// redactPage removes anything overlapping areas from the content of a page and paints the areas as opaque boxes.
+func redactPage(xRefTable *XRefTable, page int, areas []redactArea) error {
+
+	pageDict, inhPAttrs, err := xRefTable.PageDict(page)
+	if err != nil || pageDict == nil {
+		return err
+	}
+
+	bb, err := pageContent(xRefTable, pageDict, true)
+	if err != nil {
+		return err
+	}
+
+	rd := &redactor{textExtractor: &textExtractor{xRefTable: xRefTable, fonts: map[int]*textFont{}}}
+	for _, a := range areas {
+		rd.areas = append(rd.areas, a.r)
+	}
+
+	bb, res, _, err := rd.redactContent(bb, inhPAttrs.resources, identMatrix, 0)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString("q\n")
+	b.Write(bb)
+	b.WriteString("\nQ\n")
+
+	for _, a := range areas {
+		fmt.Fprintf(&b, "q %s rg %.2f %.2f %.2f %.2f re f Q\n",
+			strings.Replace(xfdfNumbers(xRefTable, a.c), ",", " ", -1), a.r.LL.X, a.r.LL.Y, a.r.Width(), a.r.Height())
+	}
+
+	sd := &PDFStreamDict{
+		PDFDict:        NewPDFDict(),
+		Content:        b.Bytes(),
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	sd.InsertName("Filter", filter.Flate)
+
+	if err = encodeStream(sd); err != nil {
+		return err
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	pageDict.Update("Contents", *indRef)
+
+	if res != nil && res != inhPAttrs.resources {
+		pageDict.Update("Resources", *res)
+	}
+
+	return nil
+}
+
+// termAreas returns the areas of the occurrences of terms within the text of a page.
+func termAreas(xRefTable *XRefTable, page int, rc *RedactConfig) ([]redactArea, error) {
+
+	if len(rc.Terms) == 0 {
+		return nil, nil
+	}
+
+	lines, err := pageTextLines(xRefTable, page)
+	if err != nil {
+		return nil, err
+	}
+
+	var areas []redactArea
+
+	for _, tl := range lines {
+
+		line := foldRunes(tl.String(), rc.MatchCase)
+
+		for _, t := range rc.Terms {
+			for _, m := range textMatches(line, foldRunes(t, rc.MatchCase)) {
+				areas = append(areas, redactArea{r: quadRect(tl.quadPoints(m[0], m[1])), c: rc.color()})
+			}
+		}
+	}
+
+	return areas, nil
+}
+
+// redactAnnotationAreas returns the areas marked by a Redact annotation.
+func redactAnnotationAreas(xRefTable *XRefTable, d *PDFDict, c PDFArray) ([]redactArea, error) {
+
+	if obj, found := d.Find("IC"); found {
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+		if arr != nil && len(*arr) == 3 {
+			c = *arr
+		}
+	}
+
+	var areas []redactArea
+
+	if obj, found := d.Find("QuadPoints"); found {
+
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if arr != nil {
+			for i := 0; i+8 <= len(*arr); i += 8 {
+				var q [8]float64
+				for j := range q {
+					q[j] = xRefTable.DereferenceNumber((*arr)[i+j])
+				}
+				areas = append(areas, redactArea{r: quadRect(q), c: c})
+			}
+		}
+	}
+
+	if len(areas) > 0 {
+		return areas, nil
+	}
+
+	r, err := widgetRect(xRefTable, d)
+	if err != nil || r == nil {
+		return nil, err
+	}
+
+	return []redactArea{{r: types.NewRectangle(r[0], r[1], r[2], r[3]), c: c}}, nil
+}
+
+// annotationOverlaps returns true if the rect of an annotation overlaps any of areas.
+func annotationOverlaps(xRefTable *XRefTable, d *PDFDict, areas []redactArea) (bool, error) {
+
+	r, err := widgetRect(xRefTable, d)
+	if err != nil || r == nil {
+		return false, err
+	}
+
+	ar := types.NewRectangle(r[0], r[1], r[2], r[3])
+
+	for _, a := range areas {
+		if overlap(ar, a.r) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// scrubAnnotation removes the text and the appearances of an annotation
+// in case it is still referenced from elsewhere, eg. from the structure tree.
+func scrubAnnotation(d *PDFDict) {
+	for _, k := range []string{"Contents", "RC", "AP", "AS", "V", "DV", "RV"} {
+		d.Delete(k)
+	}
+}
+
+// redactFields removes the fields having any of widgets from the interactive form
+// and returns the object numbers of all their widgets.
+func redactFields(xRefTable *XRefTable, widgets IntSet) (IntSet, error) {
+
+	var fields []*formField
+
+	err := processFormFields(xRefTable, func(f *formField) error {
+		for _, w := range f.widgets {
+			if widgets[w.ObjectNumber.Value()] {
+				fields = append(fields, f)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := IntSet{}
+
+	for _, f := range fields {
+
+		for _, w := range f.widgets {
+			wd, err := xRefTable.DereferenceDict(w)
+			if err != nil {
+				return nil, err
+			}
+			if wd != nil {
+				scrubAnnotation(wd)
+			}
+			removed[w.ObjectNumber.Value()] = true
+		}
+
+		scrubAnnotation(f.dict)
+
+		if err = removeFromArrayEntry(xRefTable, acroForm, "CO", IntSet{f.objNr(): true}); err != nil {
+			return nil, err
+		}
+
+		d := acroForm
+		if indRef := f.dict.IndirectRefEntry("Parent"); indRef != nil {
+			if d, err = xRefTable.DereferenceDict(*indRef); err != nil {
+				return nil, err
+			}
+		}
+
+		if d == nil {
+			continue
+		}
+
+		key := "Fields"
+		if d != acroForm {
+			key = "Kids"
+		}
+
+		if err = removeFromArrayEntry(xRefTable, d, key, IntSet{f.objNr(): true}); err != nil {
+			return nil, err
+		}
+	}
+
+	return removed, nil
+}
+
+// redactAnnotations removes the annotations overlapping the areas of their pages
+// as well as the fields having a widget overlapping an area.
+func redactAnnotations(xRefTable *XRefTable, selectedPages IntSet, pages map[int][]redactArea) error {
+
+	widgets := IntSet{}
+
+	_, err := processAnnotations(xRefTable, selectedPages, func(page int, pageDict, d *PDFDict, subtype string, objNr int) (bool, error) {
+
+		ok, err := annotationOverlaps(xRefTable, d, pages[page])
+		if err != nil || !ok {
+			return false, err
+		}
+
+		if subtype == "Widget" && objNr > 0 {
+			// Removed along with its field.
+			widgets[objNr] = true
+			return false, nil
+		}
+
+		scrubAnnotation(d)
+
+		return true, nil
+	})
+	if err != nil || len(widgets) == 0 {
+		return err
+	}
+
+	removed, err := redactFields(xRefTable, widgets)
+	if err != nil {
+		return err
+	}
+
+	// Widgets without a field go as well.
+	for objNr := range widgets {
+		removed[objNr] = true
+	}
+
+	_, err = processAnnotations(xRefTable, nil, func(page int, pageDict, d *PDFDict, subtype string, objNr int) (bool, error) {
+		if !removed[objNr] {
+			return false, nil
+		}
+		scrubAnnotation(d)
+		return true, nil
+	})
+
+	return err
+}
+
+// Redact applies the Redact annotations of selected pages as well as the areas given by rc
+// and returns the number of redacted areas. Applied Redact annotations are removed.
+// Content overlapping an area is removed from the page and the area gets painted as an opaque box.
+// Annotations and form fields overlapping an area are removed.
+// If selectedPages is empty all pages are processed.
+func Redact(xRefTable *XRefTable, selectedPages IntSet, rc *RedactConfig) (int, error) {
+
+	log.Debug.Println("Redact begin")
+
+	if rc == nil {
+		rc = &RedactConfig{}
+	}
+
+	if err := rc.validate(); err != nil {
+		return 0, err
+	}
+
+	pages := map[int][]redactArea{}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		for _, r := range rc.Rects {
+			pages[i] = append(pages[i], redactArea{r: r, c: rc.color()})
+		}
+
+		areas, err := termAreas(xRefTable, i, rc)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Redact: page %d", i)
+		}
+
+		pages[i] = append(pages[i], areas...)
+	}
+
+	_, err := processAnnotations(xRefTable, selectedPages, func(page int, pageDict, d *PDFDict, subtype string, objNr int) (bool, error) {
+
+		if subtype != "Redact" {
+			return false, nil
+		}
+
+		areas, err := redactAnnotationAreas(xRefTable, d, rc.color())
+		if err != nil {
+			return false, err
+		}
+
+		pages[page] = append(pages[page], areas...)
+
+		return true, nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "Redact")
+	}
+
+	if err = redactAnnotations(xRefTable, selectedPages, pages); err != nil {
+		return 0, errors.Wrap(err, "Redact")
+	}
+
+	var count int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(pages[i]) == 0 {
+			continue
+		}
+
+		if err = redactPage(xRefTable, i, pages[i]); err != nil {
+			return 0, errors.Wrapf(err, "Redact: page %d", i)
+		}
+
+		count += len(pages[i])
+	}
+
+	log.Debug.Println("Redact end")
+
+	return count, nil
+}
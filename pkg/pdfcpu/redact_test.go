@@ -0,0 +1,100 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/types"
+)
+
+func TestRedactType3(t *testing.T) {
+
+	xRefTable := newXRefTable(ValidationRelaxed)
+	xRefTable.Table[0] = NewFreeHeadXRefTableEntry()
+
+	indRef := func(objNr int) PDFIndirectRef { return *NewPDFIndirectRef(objNr, 0) }
+
+	dict := func(m map[string]PDFObject) PDFDict { return PDFDict{Dict: m} }
+
+	xRefTable.Table[1] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":  PDFName("Catalog"),
+		"Pages": indRef(2),
+	}))
+
+	xRefTable.Table[2] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":  PDFName("Pages"),
+		"Kids":  PDFArray{indRef(3)},
+		"Count": PDFInteger(1),
+	}))
+
+	xRefTable.Table[3] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":      PDFName("Page"),
+		"Parent":    indRef(2),
+		"MediaBox":  NewRectangle(0, 0, 200, 200),
+		"Contents":  indRef(4),
+		"Resources": dict(map[string]PDFObject{"Font": dict(map[string]PDFObject{"T3": indRef(5)})}),
+	}))
+
+	// Glyphs a and b are 5 units wide at font size 10.
+	xRefTable.Table[4] = NewXRefTableEntryGen0(PDFStreamDict{
+		PDFDict: NewPDFDict(),
+		Content: []byte("BT /T3 10 Tf 100 100 Td (ab) Tj ET")})
+
+	// Glyph space of 100 units per text space unit.
+	xRefTable.Table[5] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":       PDFName("Font"),
+		"Subtype":    PDFName("Type3"),
+		"FontMatrix": NewNumberArray(0.01, 0, 0, 0.01, 0, 0),
+		"FontBBox":   NewNumberArray(0, 0, 50, 100),
+		"FirstChar":  PDFInteger(97),
+		"LastChar":   PDFInteger(98),
+		"Widths":     NewNumberArray(50, 50),
+		"CharProcs":  NewPDFDict(),
+		"Encoding":   NewPDFDict(),
+		"Resources":  NewPDFDict(),
+	}))
+
+	root := indRef(1)
+	xRefTable.Root = &root
+	xRefTable.PageCount = 1
+
+	size := 6
+	xRefTable.Size = &size
+
+	// The area covers b only.
+	rc := &RedactConfig{Rects: []types.Rectangle{types.NewRectangle(106, 101, 109, 108)}}
+
+	if _, err := Redact(xRefTable, IntSet{1: true}, rc); err != nil {
+		t.Fatalf("Redact: %v\n", err)
+	}
+
+	pageDict, _, err := xRefTable.PageDict(1)
+	if err != nil {
+		t.Fatalf("PageDict: %v\n", err)
+	}
+
+	bb, err := pageContent(xRefTable, pageDict, true)
+	if err != nil {
+		t.Fatalf("pageContent: %v\n", err)
+	}
+
+	if !bytes.Contains(bb, []byte("[<61> -500] TJ")) {
+		t.Errorf("Redact: want a kept and b removed, got %s\n", bb)
+	}
+}
@@ -0,0 +1,441 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Repair of corrupt cross reference tables
+//
+// If the cross reference table of a file cannot be read or points to the wrong places
+// the file gets scanned for "objNr genNr obj" markers and the xref table is rebuilt from the objects found.
+// Objects defined more than once, eg. by incremental updates, resolve to their last complete definition.
+//
+// The trailer info is taken from the trailer dicts and xref streams found, the last one taking precedence.
+// Without any trailer the last catalog found becomes the root object.
+//
+// The objects of object streams get registered once the object streams are decoded,
+// which happens after the encryption key has been set up.
+
+// scannedObject is an indirect object located by scanning a file.
+type scannedObject struct {
+	offset   int64
+	genNr    int
+	dict     string // The object text in front of any stream data.
+	complete bool   // False for objects lacking their end marker, eg. in truncated files.
+}
+
+func digit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// objectHeader parses "objNr genNr" in front of the keyword "obj" at buf[i]
+// and returns the object number, the generation number and the offset of the object.
+func objectHeader(buf []byte, i int) (objNr, genNr, offset int, ok bool) {
+
+	// The keyword needs to be terminated by whitespace or a delimiter.
+	if j := i + len("obj"); j < len(buf) && !pdfSpace(buf[j]) && !delimiter(buf[j]) && buf[j] != '%' {
+		return 0, 0, 0, false
+	}
+
+	// parseNumber walks back from j over whitespace followed by digits.
+	parseNumber := func(j int) (int, int, bool) {
+		k := j
+		for k > 0 && pdfSpace(buf[k-1]) {
+			k--
+		}
+		if k == j {
+			return 0, 0, false
+		}
+		l := k
+		for l > 0 && digit(buf[l-1]) && k-l < 10 {
+			l--
+		}
+		if l == k {
+			return 0, 0, false
+		}
+		n, err := strconv.Atoi(string(buf[l:k]))
+		return n, l, err == nil
+	}
+
+	genNr, j, ok := parseNumber(i)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	objNr, j, ok = parseNumber(j)
+	if !ok || objNr == 0 {
+		return 0, 0, 0, false
+	}
+
+	// The object number needs to start at a token boundary.
+	if j > 0 && !pdfSpace(buf[j-1]) && !delimiter(buf[j-1]) {
+		return 0, 0, 0, false
+	}
+
+	return objNr, genNr, j, true
+}
+
+// scanObjects locates all indirect objects of buf skipping stream data.
+func scanObjects(buf []byte) map[int]scannedObject {
+
+	objs := map[int]scannedObject{}
+
+	keyword := []byte("obj")
+
+	for i := 0; i < len(buf); {
+
+		k := bytes.Index(buf[i:], keyword)
+		if k < 0 {
+			break
+		}
+		k += i
+		i = k + len(keyword)
+
+		objNr, genNr, offset, ok := objectHeader(buf, k)
+		if !ok {
+			continue
+		}
+
+		rest := buf[i:]
+
+		complete := true
+
+		end := bytes.Index(rest, []byte("endobj"))
+		if end < 0 {
+			end, complete = len(rest), false
+		}
+
+		// The object text ends at the next object header if endobj is missing.
+		if next := bytes.Index(rest[:end], []byte(" obj")); next >= 0 {
+			if _, _, _, ok := objectHeader(buf, i+next+1); ok {
+				end, complete = next, false
+			}
+		}
+
+		dict := string(rest[:end])
+
+		// Skip stream data which may contain anything.
+		if s := strings.Index(dict, "stream"); s > 0 && keywordStreamRightAfterEndOfDict(dict, s) {
+			dict = dict[:s]
+			if e := bytes.Index(rest[s:], []byte("endstream")); e >= 0 {
+				i += s + e + len("endstream")
+			} else {
+				complete = false
+			}
+		}
+
+		log.Debug.Printf("scanObjects: found obj#%d gen %d at offset %d\n", objNr, genNr, offset)
+
+		// Later definitions take precedence unless truncated.
+		if prev, found := objs[objNr]; found && prev.complete && !complete {
+			continue
+		}
+
+		objs[objNr] = scannedObject{offset: int64(offset), genNr: genNr, dict: dict, complete: complete}
+	}
+
+	return objs
+}
+
+// scannedDict returns the dict of a scanned object if it is of type typ.
+func scannedDict(obj scannedObject, typ string) *PDFDict {
+
+	if !strings.Contains(obj.dict, "/"+typ) {
+		return nil
+	}
+
+	s := obj.dict
+
+	o, err := parseObject(&s)
+	if err != nil {
+		return nil
+	}
+
+	d, ok := o.(PDFDict)
+	if !ok || d.Type() == nil || *d.Type() != typ {
+		return nil
+	}
+
+	return &d
+}
+
+// scanTrailerDicts returns all trailer dicts of buf in order of appearance.
+func scanTrailerDicts(buf []byte) []PDFDict {
+
+	var dicts []PDFDict
+
+	keyword := []byte("trailer")
+
+	for i := 0; i < len(buf); {
+
+		k := bytes.Index(buf[i:], keyword)
+		if k < 0 {
+			break
+		}
+		i += k + len(keyword)
+
+		rest := buf[i:]
+		if e := bytes.Index(rest, []byte("startxref")); e >= 0 {
+			rest = rest[:e]
+		}
+
+		s := string(rest)
+
+		o, err := parseObject(&s)
+		if err != nil {
+			continue
+		}
+
+		if d, ok := o.(PDFDict); ok {
+			dicts = append(dicts, d)
+		}
+	}
+
+	return dicts
+}
+
+// repairTrailerInfo takes over trailer entries not set yet and referring to objects found.
+func repairTrailerInfo(xRefTable *XRefTable, d PDFDict, objs map[int]scannedObject) {
+
+	found := func(key string) *PDFIndirectRef {
+		indRef := d.IndirectRefEntry(key)
+		if indRef == nil {
+			return nil
+		}
+		if _, ok := objs[indRef.ObjectNumber.Value()]; !ok {
+			return nil
+		}
+		return indRef
+	}
+
+	if xRefTable.Root == nil {
+		xRefTable.Root = found("Root")
+	}
+
+	if xRefTable.Info == nil {
+		xRefTable.Info = found("Info")
+	}
+
+	if xRefTable.Encrypt == nil {
+		xRefTable.Encrypt = found("Encrypt")
+	}
+
+	if xRefTable.ID == nil {
+		xRefTable.ID = d.PDFArrayEntry("ID")
+	}
+}
+
+// repairHeaderVersion returns the header version of a file possibly preceded by garbage.
+func repairHeaderVersion(buf []byte) PDFVersion {
+
+	prefix := []byte("%PDF-")
+
+	head := buf
+	if len(head) > defaultBufSize {
+		head = head[:defaultBufSize]
+	}
+
+	if i := bytes.Index(head, prefix); i >= 0 && i+len(prefix)+3 <= len(buf) {
+		if v, err := Version(string(buf[i+len(prefix) : i+len(prefix)+3])); err == nil {
+			return v
+		}
+	}
+
+	log.Info.Println("repairXRefTable: missing header version, assuming PDF 1.7")
+
+	return V17
+}
+
+// repairXRefTable builds the xref table by scanning the file for objects.
+func repairXRefTable(ctx *PDFContext) error {
+
+	log.Info.Println("repairing xref table")
+
+	rs := ctx.Read.RS
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	buf, err := ioutil.ReadAll(rs)
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.canceled(); err != nil {
+		return err
+	}
+
+	hv := repairHeaderVersion(buf)
+	ctx.HeaderVersion = &hv
+
+	objs := scanObjects(buf)
+	if len(objs) == 0 {
+		return errors.New("repairXRefTable: no objects found")
+	}
+
+	xRefTable := ctx.XRefTable
+	xRefTable.Table[0] = NewFreeHeadXRefTableEntry()
+
+	// Process the objects in order of appearance.
+	var objNrs []int
+	for objNr := range objs {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Slice(objNrs, func(i, j int) bool { return objs[objNrs[i]].offset < objs[objNrs[j]].offset })
+
+	var xRefStreamDicts []PDFDict
+	maxObjNr := 0
+
+	for _, objNr := range objNrs {
+
+		obj := objs[objNr]
+
+		if objNr > maxObjNr {
+			maxObjNr = objNr
+		}
+
+		// Cross reference streams become obsolete.
+		if d := scannedDict(obj, "XRef"); d != nil {
+			xRefStreamDicts = append(xRefStreamDicts, *d)
+			continue
+		}
+
+		if scannedDict(obj, "ObjStm") != nil {
+			ctx.Read.ObjectStreams[objNr] = true
+		}
+
+		offset, genNr := obj.offset, obj.genNr
+		xRefTable.Table[objNr] = &XRefTableEntry{Offset: &offset, Generation: &genNr}
+	}
+
+	size := 1
+	xRefTable.Size = &size
+
+	registerFreeEntries(xRefTable, maxObjNr)
+
+	// The last trailer found takes precedence.
+	trailerDicts := append(xRefStreamDicts, scanTrailerDicts(buf)...)
+	for i := len(trailerDicts) - 1; i >= 0; i-- {
+		repairTrailerInfo(xRefTable, trailerDicts[i], objs)
+	}
+
+	if xRefTable.Root == nil {
+		for i := len(objNrs) - 1; i >= 0; i-- {
+			if scannedDict(objs[objNrs[i]], "Catalog") != nil {
+				xRefTable.Root = NewPDFIndirectRef(objNrs[i], objs[objNrs[i]].genNr)
+				ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("missing trailer: using catalog obj#%d as root", objNrs[i]))
+				break
+			}
+		}
+	}
+
+	if xRefTable.Root == nil && ctx.Mode == REPAIR {
+		// The catalog is usually written last and therefore lost in truncated files.
+		catalog := NewPDFDict()
+		catalog.InsertName("Type", "Catalog")
+		objNr := xRefTable.InsertNew(*NewXRefTableEntryGen0(catalog))
+		xRefTable.Root = NewPDFIndirectRef(objNr, 0)
+		ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("missing catalog: synthesized obj#%d", objNr))
+	}
+
+	if xRefTable.Root == nil {
+		return errors.New("repairXRefTable: missing root object")
+	}
+
+	if xRefTable.Encrypt != nil && xRefTable.ID == nil {
+		return errors.New("repairXRefTable: missing entry \"ID\"")
+	}
+
+	ctx.Read.Repaired = true
+	ctx.Read.Damage = append(ctx.Read.Damage, fmt.Sprintf("corrupt cross reference table: rebuilt from %d objects found", len(objs)))
+
+	log.Info.Printf("repairXRefTable: recovered %d objects\n", len(objs))
+
+	return nil
+}
+
+// registerFreeEntries registers missing objects up to objNr as deleted.
+func registerFreeEntries(xRefTable *XRefTable, objNr int) {
+
+	for i := 1; i <= objNr; i++ {
+		if _, found := xRefTable.Table[i]; !found {
+			g, o := FreeHeadGeneration, int64(0)
+			xRefTable.Table[i] = &XRefTableEntry{Free: true, Offset: &o, Generation: &g}
+		}
+	}
+
+	if objNr >= *xRefTable.Size {
+		size := objNr + 1
+		xRefTable.Size = &size
+	}
+}
+
+// entryOffset returns the file offset of the definition of an object.
+func entryOffset(xRefTable *XRefTable, entry *XRefTableEntry) int64 {
+
+	if entry.Compressed {
+		entry = xRefTable.Table[*entry.ObjectStream]
+	}
+
+	return *entry.Offset
+}
+
+// registerCompressedObjects adds the objects of an object stream decoded during repair to the xref table
+// unless they are defined later on in the file.
+func registerCompressedObjects(xRefTable *XRefTable, objStmNr int, osd *PDFObjectStreamDict) error {
+
+	objStmOffset := *xRefTable.Table[objStmNr].Offset
+
+	prolog := strings.Fields(string(osd.Content[:osd.FirstObjOffset]))
+
+	for i := 0; i+1 < len(prolog); i += 2 {
+
+		objNr, err := strconv.Atoi(prolog[i])
+		if err != nil || objNr <= 0 {
+			return errors.Errorf("registerCompressedObjects: corrupt object stream %d", objStmNr)
+		}
+
+		if objNr == objStmNr {
+			continue
+		}
+
+		if entry, found := xRefTable.Table[objNr]; found && !entry.Free && entryOffset(xRefTable, entry) > objStmOffset {
+			continue
+		}
+
+		log.Debug.Printf("registerCompressedObjects: found obj#%d in object stream %d\n", objNr, objStmNr)
+
+		objStm, ind, g := objStmNr, i/2, 0
+		xRefTable.Table[objNr] = &XRefTableEntry{Compressed: true, ObjectStream: &objStm, ObjectStreamInd: &ind, Generation: &g}
+
+		registerFreeEntries(xRefTable, objNr)
+	}
+
+	return nil
+}
@@ -0,0 +1,430 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// The entries of a resource dict mapping resource names to resources, see 7.8.3 Table 33.
+var resourceCategories = []string{"ExtGState", "ColorSpace", "Pattern", "Shading", "XObject", "Font", "Properties"}
+
+// resourceRenames maps resource categories to resource names and their replacements.
+type resourceRenames map[string]map[string]string
+
+// name returns the name in effect for resource name within category.
+func (rr resourceRenames) name(category, name string) string {
+	if s, ok := rr[category][name]; ok {
+		return s
+	}
+	return name
+}
+
+func (rr resourceRenames) add(category, oldName, newName string) {
+	m, ok := rr[category]
+	if !ok {
+		m = map[string]string{}
+		rr[category] = m
+	}
+	m[oldName] = newName
+}
+
+// sameResource returns true if o1 and o2 represent the same resource.
+func sameResource(xRefTable *XRefTable, o1, o2 PDFObject) (bool, error) {
+
+	indRef1, ok1 := o1.(PDFIndirectRef)
+	indRef2, ok2 := o2.(PDFIndirectRef)
+
+	if ok1 && ok2 {
+		return indRef1 == indRef2, nil
+	}
+
+	if ok1 != ok2 {
+		return false, nil
+	}
+
+	return equalPDFObjects(o1, o2, xRefTable)
+}
+
+// uniqueResourceName returns a variation of name not used in any of dd.
+func uniqueResourceName(name string, dd ...*PDFDict) string {
+
+	prefix := strings.TrimRight(name, "0123456789")
+
+	for i := 0; ; i++ {
+
+		s := prefix + strconv.Itoa(i)
+
+		used := false
+		for _, d := range dd {
+			if _, found := d.Find(s); found {
+				used = true
+				break
+			}
+		}
+
+		if !used {
+			return s
+		}
+	}
+}
+
+// resourceNameFor returns the name of an indirectly referenced resource within d.
+func resourceNameFor(d *PDFDict, o PDFObject) (string, bool) {
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		return "", false
+	}
+
+	for k, v := range d.Dict {
+		if v == indRef {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// resourceCategoryDict returns the resource dict for category within resDict.
+// A missing dict gets created.
+func resourceCategoryDict(xRefTable *XRefTable, resDict *PDFDict, category string) (*PDFDict, error) {
+
+	obj, found := resDict.Find(category)
+	if found {
+		d, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resource dict entry %s", category)
+		}
+		if d != nil {
+			return d, nil
+		}
+	}
+
+	d := NewPDFDict()
+	resDict.Update(category, d)
+
+	return &d, nil
+}
+
+// mergeResources merges the resources of src into dest.
+//
+// Resources of src whose names collide with different resources in dest are added under a new name.
+// Existing entries of dest are never overwritten.
+// The returned renames have to be applied to any content referring to the resources of src.
+func mergeResources(xRefTable *XRefTable, dest, src *PDFDict) (resourceRenames, error) {
+
+	renames := resourceRenames{}
+
+	for _, category := range resourceCategories {
+
+		obj, found := src.Find(category)
+		if !found {
+			continue
+		}
+
+		srcDict, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "mergeResources: %s", category)
+		}
+		if srcDict == nil || srcDict.Len() == 0 {
+			continue
+		}
+
+		destDict, err := resourceCategoryDict(xRefTable, dest, category)
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for name := range srcDict.Dict {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+
+			o := srcDict.Dict[name]
+
+			if o1, found := destDict.Find(name); found {
+
+				same, err := sameResource(xRefTable, o, o1)
+				if err != nil {
+					return nil, err
+				}
+				if same {
+					continue
+				}
+
+				newName, found := resourceNameFor(destDict, o)
+				if !found {
+					newName = uniqueResourceName(name, destDict, srcDict)
+				}
+
+				log.Debug.Printf("mergeResources: renaming %s %s to %s\n", category, name, newName)
+				renames.add(category, name, newName)
+
+				if found {
+					continue
+				}
+
+				name = newName
+			}
+
+			destDict.Insert(name, o)
+		}
+	}
+
+	if _, found := dest.Find("ProcSet"); !found {
+		if o, found := src.Find("ProcSet"); found {
+			dest.Insert("ProcSet", o)
+		}
+	}
+
+	return renames, nil
+}
+
+// The content stream operators taking a resource name as operand
+// along with the resource category and the index of the operand (-1 for the last operand).
+var resourceOperators = map[string]struct {
+	category string
+	operand  int
+}{
+	"Tf":  {"Font", 0},
+	"Do":  {"XObject", 0},
+	"gs":  {"ExtGState", 0},
+	"cs":  {"ColorSpace", 0},
+	"CS":  {"ColorSpace", 0},
+	"sh":  {"Shading", 0},
+	"scn": {"Pattern", -1},
+	"SCN": {"Pattern", -1},
+	"BDC": {"Properties", 1},
+	"DP":  {"Properties", 1},
+}
+
+// contentOperand represents an operand within a content stream.
+// For names start and end delimit the name without the leading slash.
+type contentOperand struct {
+	name       bool
+	start, end int
+}
+
+func contentWhitespace(c byte) bool {
+	return c == 0x00 || c == 0x09 || c == 0x0A || c == 0x0C || c == 0x0D || c == 0x20
+}
+
+func contentDelimiter(c byte) bool {
+	return delimiter(c) || c == '{' || c == '}' || c == '%'
+}
+
+// skipStringLiteral returns the position behind the string literal starting at i.
+func skipStringLiteral(bb []byte, i int) int {
+
+	depth := 0
+
+	for ; i < len(bb); i++ {
+		switch bb[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return i
+}
+
+// skipInlineImageData returns the position behind the EI operator terminating the inline image data starting at i.
+func skipInlineImageData(bb []byte, i int) int {
+
+	for ; i+1 < len(bb); i++ {
+		if bb[i] == 'E' && bb[i+1] == 'I' && i > 0 && contentWhitespace(bb[i-1]) &&
+			(i+2 == len(bb) || contentWhitespace(bb[i+2]) || contentDelimiter(bb[i+2])) {
+			return i + 2
+		}
+	}
+
+	return len(bb)
+}
+
+// scanResourceNames calls fn for every resource name used by the operators of a content stream
+// along with the operator, the resource category and the name operand.
+// Color spaces of inline images are reported for operator ID.
+func scanResourceNames(content []byte, fn func(op, category string, name contentOperand)) {
+
+	var (
+		operands []contentOperand // operands of the next operator
+		depth    int              // nesting level of arrays and dicts
+	)
+
+	for i := 0; i < len(content); {
+
+		c := content[i]
+
+		switch {
+
+		case contentWhitespace(c):
+			i++
+
+		case c == '%':
+			for i < len(content) && content[i] != 0x0A && content[i] != 0x0D {
+				i++
+			}
+
+		case c == '(':
+			i = skipStringLiteral(content, i)
+			if depth == 0 {
+				operands = append(operands, contentOperand{})
+			}
+
+		case c == '<' && i+1 < len(content) && content[i+1] == '<', c == '[':
+			depth++
+			i++
+			if c == '<' {
+				i++
+			}
+
+		case c == '>' && i+1 < len(content) && content[i+1] == '>', c == ']':
+			depth--
+			i++
+			if c == '>' {
+				i++
+			}
+			if depth == 0 {
+				operands = append(operands, contentOperand{})
+			}
+
+		case c == '<':
+			for i < len(content) && content[i] != '>' {
+				i++
+			}
+			i++
+			if depth == 0 {
+				operands = append(operands, contentOperand{})
+			}
+
+		case c == '/':
+			j := i + 1
+			for j < len(content) && !contentWhitespace(content[j]) && !contentDelimiter(content[j]) {
+				j++
+			}
+			if depth == 0 {
+				operands = append(operands, contentOperand{name: true, start: i + 1, end: j})
+			}
+			i = j
+
+		case contentDelimiter(c):
+			i++
+
+		default:
+			j := i
+			for j < len(content) && !contentWhitespace(content[j]) && !contentDelimiter(content[j]) {
+				j++
+			}
+			tok := string(content[i:j])
+			i = j
+
+			if strings.IndexByte("0123456789+-.", tok[0]) >= 0 || tok == "true" || tok == "false" || tok == "null" {
+				if depth == 0 {
+					operands = append(operands, contentOperand{})
+				}
+				continue
+			}
+
+			if depth > 0 {
+				continue
+			}
+
+			switch tok {
+
+			case "BI":
+				// Inline image dict entries are collected as operands of ID.
+				operands = operands[:0]
+				continue
+
+			case "ID":
+				for k := 0; k+1 < len(operands); k += 2 {
+					key := operands[k]
+					if !key.name || !operands[k+1].name {
+						continue
+					}
+					if s := string(content[key.start:key.end]); s == "CS" || s == "ColorSpace" {
+						fn(tok, "ColorSpace", operands[k+1])
+					}
+				}
+				// Skip the single whitespace following ID and the image data.
+				i = skipInlineImageData(content, i+1)
+
+			default:
+				if ro, ok := resourceOperators[tok]; ok {
+					k := ro.operand
+					if k < 0 {
+						k = len(operands) - 1
+					}
+					if k >= 0 && k < len(operands) && operands[k].name {
+						fn(tok, ro.category, operands[k])
+					}
+				}
+			}
+
+			operands = operands[:0]
+		}
+	}
+}
+
+// renameResourceNames replaces the resource names used by the operators of a content stream according to renames.
+func renameResourceNames(content []byte, renames resourceRenames) []byte {
+
+	if len(renames) == 0 {
+		return content
+	}
+
+	var (
+		b    bytes.Buffer
+		last int // end of the part of content already copied
+	)
+
+	scanResourceNames(content, func(op, category string, name contentOperand) {
+		s := string(content[name.start:name.end])
+		newName := renames.name(category, s)
+		if newName == s {
+			return
+		}
+		b.Write(content[last:name.start])
+		b.WriteString(newName)
+		last = name.end
+	})
+
+	if last == 0 {
+		return content
+	}
+
+	b.Write(content[last:])
+
+	return b.Bytes()
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import "testing"
+
+func TestRenameResourceNames(t *testing.T) {
+
+	renames := resourceRenames{
+		"Font":       {"F1": "F2"},
+		"XObject":    {"Fm0": "Fm1"},
+		"ExtGState":  {"GS0": "GS1"},
+		"ColorSpace": {"CS0": "CS1"},
+		"Pattern":    {"P0": "P1"},
+		"Properties": {"MC0": "MC1"},
+	}
+
+	for _, tt := range []struct{ in, want string }{
+		{"BT /F1 12 Tf (/F1 12 Tf) Tj ET", "BT /F2 12 Tf (/F1 12 Tf) Tj ET"},
+		{"q /GS0 gs /Fm0 Do Q % /Fm0 Do\n/Fm0 Do", "q /GS1 gs /Fm1 Do Q % /Fm0 Do\n/Fm1 Do"},
+		{"/CS0 cs 0.5 0.2 /P0 scn /CS0 CS", "/CS1 cs 0.5 0.2 /P1 scn /CS1 CS"},
+		{"/OC /MC0 BDC /Fm0 Do EMC /Span <</MCID 0>> BDC EMC", "/OC /MC1 BDC /Fm1 Do EMC /Span <</MCID 0>> BDC EMC"},
+		{"[(F1) 2 (x)] TJ /F1 Do /Fm0 Tf", "[(F1) 2 (x)] TJ /F1 Do /Fm0 Tf"},
+		{"BI /W 1 /H 1 /CS /CS0 /BPC 8 ID \x00/Fm0 Do EI /Fm0 Do", "BI /W 1 /H 1 /CS /CS1 /BPC 8 ID \x00/Fm0 Do EI /Fm1 Do"},
+	} {
+		got := string(renameResourceNames([]byte(tt.in), renames))
+		if got != tt.want {
+			t.Errorf("renameResourceNames(%q):\ngot  %q\nwant %q\n", tt.in, got, tt.want)
+		}
+	}
+}
+
+// formXObject returns a Form XObject drawing the XObject resource named xoName.
+func formXObject(xoName string, xo PDFObject) PDFStreamDict {
+
+	d := NewPDFDict()
+	d.InsertName("Type", "XObject")
+	d.InsertName("Subtype", "Form")
+
+	res := NewPDFDict()
+	if xo != nil {
+		res.Insert("XObject", PDFDict{Dict: map[string]PDFObject{xoName: xo}})
+	}
+	d.Insert("Resources", res)
+
+	return PDFStreamDict{PDFDict: d, Content: []byte("/" + xoName + " Do")}
+}
+
+func TestMergeResourcesNestedForms(t *testing.T) {
+
+	xRefTable := newXRefTable(ValidationRelaxed)
+
+	// 3 0 obj: innermost form, 1 0 obj: page form drawing 3 0 R as /Fm0, 2 0 obj: form to be merged drawing 3 0 R as /Fm0
+	xRefTable.Table[3] = NewXRefTableEntryGen0(formXObject("Fm0", nil))
+	xRefTable.Table[1] = NewXRefTableEntryGen0(formXObject("Fm0", *NewPDFIndirectRef(3, 0)))
+	xRefTable.Table[2] = NewXRefTableEntryGen0(formXObject("Fm0", *NewPDFIndirectRef(3, 0)))
+
+	dest := NewPDFDict()
+	dest.Insert("XObject", PDFDict{Dict: map[string]PDFObject{"Fm0": *NewPDFIndirectRef(1, 0), "Fm1": *NewPDFIndirectRef(3, 0)}})
+
+	src := NewPDFDict()
+	src.Insert("XObject", PDFDict{Dict: map[string]PDFObject{"Fm0": *NewPDFIndirectRef(2, 0), "Fm1": *NewPDFIndirectRef(3, 0)}})
+
+	renames, err := mergeResources(xRefTable, &dest, &src)
+	if err != nil {
+		t.Fatalf("mergeResources: %v\n", err)
+	}
+
+	// Fm0 collides with a different form, Fm1 refers to the same form.
+	if got := renames.name("XObject", "Fm0"); got != "Fm2" {
+		t.Errorf("Fm0: got %s want Fm2\n", got)
+	}
+	if got := renames.name("XObject", "Fm1"); got != "Fm1" {
+		t.Errorf("Fm1: got %s want Fm1\n", got)
+	}
+
+	xObjects := dest.PDFDictEntry("XObject")
+	for name, objNr := range map[string]int{"Fm0": 1, "Fm1": 3, "Fm2": 2} {
+		indRef := xObjects.IndirectRefEntry(name)
+		if indRef == nil || indRef.ObjectNumber.Value() != objNr {
+			t.Errorf("XObject %s: got %v want obj#%d\n", name, indRef, objNr)
+		}
+	}
+
+	// The resources of nested forms stay untouched.
+	for _, objNr := range []int{1, 2} {
+		sd := xRefTable.Table[objNr].Object.(PDFStreamDict)
+		res := sd.PDFDictEntry("Resources")
+		indRef := res.PDFDictEntry("XObject").IndirectRefEntry("Fm0")
+		if indRef == nil || indRef.ObjectNumber.Value() != 3 || string(sd.Content) != "/Fm0 Do" {
+			t.Errorf("nested form obj#%d has been modified\n", objNr)
+		}
+	}
+
+	// Merging again must not add anything.
+	renames, err = mergeResources(xRefTable, &dest, &src)
+	if err != nil {
+		t.Fatalf("mergeResources: %v\n", err)
+	}
+	if got := renames.name("XObject", "Fm0"); got != "Fm2" {
+		t.Errorf("Fm0: got %s want Fm2\n", got)
+	}
+	if xObjects.Len() != 3 {
+		t.Errorf("got %d XObjects want 3\n", xObjects.Len())
+	}
+}
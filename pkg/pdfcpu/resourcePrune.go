@@ -0,0 +1,403 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Pruning of unused resources
+//
+// Producers frequently attach all fonts, XObjects and graphics states of a document to every page
+// or share one resource dict among all pages. This keeps large unused objects alive.
+//
+// The content of every page is scanned for the resource names actually used
+// including the content of form XObjects and Type3 fonts lacking their own resources.
+// A resource category dict gets pruned only if it is exclusively used by pages,
+// which is not the case if it is referenced from anywhere else like form XObjects or annotation appearances.
+// If the content of any page sharing a category dict cannot be parsed the category dict remains untouched.
+
+// The resource categories subject to pruning.
+var prunedResourceCategories = []string{"Font", "XObject", "ExtGState"}
+
+// resourceUsage represents a resource category dict along with the names used by the pages sharing it.
+type resourceUsage struct {
+	dict      *PDFDict
+	objNr     int       // The object number of an indirectly referenced category dict or 0.
+	resources StringSet // The resource dicts containing the category dict.
+	resObjNrs IntSet    // The object numbers of indirectly referenced resource dicts containing the category dict.
+	used      StringSet
+	keep      bool // Usage could not be determined.
+}
+
+type resourcePruner struct {
+	xRefTable *XRefTable
+	keys      []string // Usage keys in order of appearance.
+	usages    map[string]*resourceUsage
+	resRefs   map[int]int // Number of page tree nodes referring to an indirect resource dict.
+}
+
+// usagesForPage returns the usages of the resource category dicts of resources by category.
+func (rp *resourcePruner) usagesForPage(resources PDFObject, owner int) (map[string]*resourceUsage, error) {
+
+	resKey := fmt.Sprintf("node%d", owner)
+	resObjNr := 0
+	if indRef, ok := resources.(PDFIndirectRef); ok {
+		resObjNr = indRef.ObjectNumber.Value()
+		resKey = fmt.Sprintf("obj%d", resObjNr)
+	}
+
+	resDict, err := rp.xRefTable.DereferenceDict(resources)
+	if err != nil || resDict == nil {
+		return nil, err
+	}
+
+	usages := map[string]*resourceUsage{}
+
+	for _, category := range prunedResourceCategories {
+
+		obj, found := resDict.Find(category)
+		if !found {
+			continue
+		}
+
+		key := resKey + "/" + category
+		objNr := 0
+		if indRef, ok := obj.(PDFIndirectRef); ok {
+			objNr = indRef.ObjectNumber.Value()
+			key = fmt.Sprintf("obj%d", objNr)
+		}
+
+		u, found := rp.usages[key]
+		if !found {
+			d, err := rp.xRefTable.DereferenceDict(obj)
+			if err != nil {
+				return nil, err
+			}
+			if d == nil {
+				continue
+			}
+			u = &resourceUsage{dict: d, objNr: objNr, resources: StringSet{}, resObjNrs: IntSet{}, used: StringSet{}}
+			rp.usages[key] = u
+			rp.keys = append(rp.keys, key)
+		}
+
+		u.resources[resKey] = true
+		if resObjNr > 0 {
+			u.resObjNrs[resObjNr] = true
+		}
+
+		usages[category] = u
+	}
+
+	return usages, nil
+}
+
+// scan records the resource names used by content.
+// The content of form XObjects lacking resources is scanned too.
+func (rp *resourcePruner) scan(content []byte, usages map[string]*resourceUsage, visited IntSet) error {
+
+	type resource struct {
+		op  string
+		obj PDFObject
+	}
+
+	var resources []resource
+
+	scanResourceNames(content, func(op, category string, name contentOperand) {
+
+		u := usages[category]
+		if u == nil {
+			return
+		}
+
+		s := string(content[name.start:name.end])
+		u.used[s] = true
+
+		if op != "Do" && op != "Tf" {
+			return
+		}
+
+		obj, found := u.dict.Find(s)
+		if !found {
+			return
+		}
+
+		if indRef, ok := obj.(PDFIndirectRef); ok {
+			if visited[indRef.ObjectNumber.Value()] {
+				return
+			}
+			visited[indRef.ObjectNumber.Value()] = true
+		}
+
+		resources = append(resources, resource{op, obj})
+	})
+
+	for _, r := range resources {
+
+		if r.op == "Tf" {
+			d, err := rp.xRefTable.DereferenceDict(r.obj)
+			if err != nil {
+				return err
+			}
+			if d == nil || d.Subtype() == nil || *d.Subtype() != "Type3" {
+				continue
+			}
+			if _, found := d.Find("Resources"); !found {
+				// The glyph descriptions may use any resource of the page.
+				return errors.New("scan: Type3 font without resources")
+			}
+			continue
+		}
+
+		sd, err := rp.xRefTable.DereferenceStreamDict(r.obj)
+		if err != nil {
+			return err
+		}
+		if sd == nil || sd.Subtype() == nil || *sd.Subtype() != "Form" {
+			continue
+		}
+		if _, found := sd.Find("Resources"); found {
+			continue
+		}
+		if err = rp.xRefTable.decodeStream(sd); err != nil {
+			return err
+		}
+		if err = rp.scan(sd.Content, usages, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rp *resourcePruner) page(pageDict *PDFDict, objNr int, resources PDFObject, owner int) error {
+
+	if resources == nil {
+		return nil
+	}
+
+	usages, err := rp.usagesForPage(resources, owner)
+	if err != nil {
+		return err
+	}
+
+	// Skip pages sharing resources known to be in use.
+	done := true
+	for _, u := range usages {
+		if !u.keep && len(u.used) < u.dict.Len() {
+			done = false
+			break
+		}
+	}
+
+	if done {
+		return nil
+	}
+
+	content, err := pageContent(rp.xRefTable, pageDict, true)
+	if err == nil {
+		err = rp.scan(content, usages, IntSet{})
+	}
+
+	if err != nil {
+		log.Debug.Printf("pruneResources: page obj#%d: resources kept: %v\n", objNr, err)
+		for _, u := range usages {
+			u.keep = true
+		}
+	}
+
+	return nil
+}
+
+func (rp *resourcePruner) pageTree(dict *PDFDict, objNr int, resources PDFObject, owner int, path []int) error {
+
+	if obj, found := dict.Find("Resources"); found {
+		resources, owner = obj, objNr
+		if indRef, ok := obj.(PDFIndirectRef); ok {
+			rp.resRefs[indRef.ObjectNumber.Value()]++
+		}
+	}
+
+	dictType, err := dictTypeForPageNodeDict(dict)
+	if err != nil {
+		return err
+	}
+
+	if dictType == "Page" {
+		return rp.page(dict, objNr, resources, owner)
+	}
+
+	kidsArray := dict.PDFArrayEntry("Kids")
+	if kidsArray == nil {
+		return errors.New("pruneResources: corrupt \"Kids\" entry")
+	}
+
+	for _, obj := range *kidsArray {
+
+		if obj == nil {
+			continue
+		}
+
+		if err := rp.xRefTable.canceled(); err != nil {
+			return err
+		}
+
+		indRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			return errors.New("pruneResources: missing indirect reference for kid")
+		}
+
+		kidObjNr := indRef.ObjectNumber.Value()
+
+		for i, nr := range path {
+			if nr == kidObjNr {
+				return rp.xRefTable.reportCycle("pruneResources", path[i:])
+			}
+		}
+
+		d, err := rp.xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		err = rp.pageTree(d, kidObjNr, resources, owner, append(path, kidObjNr))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// countIndirectRefs adds the number of indirect references within obj to refs by object number.
+func countIndirectRefs(obj PDFObject, refs map[int]int) {
+
+	switch obj := obj.(type) {
+
+	case PDFIndirectRef:
+		refs[obj.ObjectNumber.Value()]++
+
+	case PDFDict:
+		for _, v := range obj.Dict {
+			countIndirectRefs(v, refs)
+		}
+
+	case PDFStreamDict:
+		countIndirectRefs(obj.PDFDict, refs)
+
+	case PDFArray:
+		for _, v := range obj {
+			countIndirectRefs(v, refs)
+		}
+
+	}
+}
+
+// prunable returns true if u is exclusively used by pages.
+func (rp *resourcePruner) prunable(u *resourceUsage, refs map[int]int) bool {
+
+	if u.keep {
+		return false
+	}
+
+	if u.objNr > 0 && refs[u.objNr] > len(u.resources) {
+		return false
+	}
+
+	for objNr := range u.resObjNrs {
+		if refs[objNr] > rp.resRefs[objNr] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pruneResources removes the fonts, XObjects and graphics states not used by any content from page resource dicts
+// and returns the number of removed resource dict entries.
+func pruneResources(xRefTable *XRefTable) (int, error) {
+
+	log.Debug.Println("pruneResources begin")
+
+	indRefPages, err := xRefTable.Pages()
+	if err != nil {
+		return 0, err
+	}
+
+	if indRefPages == nil {
+		return 0, errors.New("pruneResources: missing page tree")
+	}
+
+	pagesDict, err := xRefTable.DereferenceDict(*indRefPages)
+	if err != nil {
+		return 0, err
+	}
+
+	if pagesDict == nil {
+		return 0, errors.New("pruneResources: missing page tree root dict")
+	}
+
+	rp := &resourcePruner{xRefTable: xRefTable, usages: map[string]*resourceUsage{}, resRefs: map[int]int{}}
+
+	objNr := indRefPages.ObjectNumber.Value()
+
+	err = rp.pageTree(pagesDict, objNr, nil, 0, []int{objNr})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(rp.usages) == 0 {
+		return 0, nil
+	}
+
+	refs := map[int]int{}
+	for _, entry := range xRefTable.Table {
+		if entry != nil && !entry.Free {
+			countIndirectRefs(entry.Object, refs)
+		}
+	}
+
+	n := 0
+
+	for _, key := range rp.keys {
+
+		u := rp.usages[key]
+		if !rp.prunable(u, refs) {
+			continue
+		}
+
+		for name := range u.dict.Dict {
+			if !u.used[name] {
+				log.Debug.Printf("pruneResources: %s: removing unused resource %s\n", key, name)
+				u.dict.Delete(name)
+				n++
+			}
+		}
+	}
+
+	log.Debug.Println("pruneResources end")
+
+	return n, nil
+}
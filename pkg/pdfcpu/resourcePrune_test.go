@@ -0,0 +1,122 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func resourceNames(d *PDFDict) string {
+
+	var names []string
+	for k := range d.Dict {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+func TestPruneResources(t *testing.T) {
+
+	xRefTable := newXRefTable(ValidationRelaxed)
+
+	indRef := func(objNr int) PDFIndirectRef { return *NewPDFIndirectRef(objNr, 0) }
+
+	dict := func(m map[string]PDFObject) PDFDict { return PDFDict{Dict: m} }
+
+	stream := func(d PDFDict, content string) PDFStreamDict {
+		return PDFStreamDict{PDFDict: d, Content: []byte(content)}
+	}
+
+	// The fonts are shared with the AcroForm and therefore stay untouched.
+	xRefTable.Table[1] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":     PDFName("Catalog"),
+		"Pages":    indRef(2),
+		"AcroForm": dict(map[string]PDFObject{"DR": dict(map[string]PDFObject{"Font": indRef(10)})}),
+	}))
+
+	// Both pages inherit the resources of the page tree root.
+	xRefTable.Table[2] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":      PDFName("Pages"),
+		"Kids":      PDFArray{indRef(3), indRef(4)},
+		"Count":     PDFInteger(2),
+		"Resources": indRef(5),
+	}))
+
+	xRefTable.Table[3] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":     PDFName("Page"),
+		"Parent":   indRef(2),
+		"Contents": indRef(6),
+	}))
+
+	xRefTable.Table[4] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Type":     PDFName("Page"),
+		"Parent":   indRef(2),
+		"Contents": indRef(7),
+	}))
+
+	xRefTable.Table[5] = NewXRefTableEntryGen0(dict(map[string]PDFObject{
+		"Font":      indRef(10),
+		"XObject":   dict(map[string]PDFObject{"Fm0": indRef(8), "Im0": indRef(9), "Im1": indRef(9)}),
+		"ExtGState": dict(map[string]PDFObject{"GS0": NewPDFDict(), "GS1": NewPDFDict()}),
+	}))
+
+	xRefTable.Table[6] = NewXRefTableEntryGen0(stream(NewPDFDict(), "BT /F1 12 Tf (/F2 12 Tf) Tj ET /Fm0 Do"))
+	xRefTable.Table[7] = NewXRefTableEntryGen0(stream(NewPDFDict(), "q /GS0 gs Q % /GS1 gs"))
+
+	// A form without resources uses the resources of the page.
+	xRefTable.Table[8] = NewXRefTableEntryGen0(stream(dict(map[string]PDFObject{
+		"Type":    PDFName("XObject"),
+		"Subtype": PDFName("Form"),
+	}), "/Im0 Do"))
+
+	xRefTable.Table[9] = NewXRefTableEntryGen0(stream(dict(map[string]PDFObject{
+		"Type":    PDFName("XObject"),
+		"Subtype": PDFName("Image"),
+	}), ""))
+
+	xRefTable.Table[10] = NewXRefTableEntryGen0(dict(map[string]PDFObject{"F1": indRef(11), "F2": indRef(11)}))
+	xRefTable.Table[11] = NewXRefTableEntryGen0(dict(map[string]PDFObject{"Type": PDFName("Font"), "Subtype": PDFName("Type1")}))
+
+	root := indRef(1)
+	xRefTable.Root = &root
+
+	n, err := pruneResources(xRefTable)
+	if err != nil {
+		t.Fatalf("pruneResources: %v\n", err)
+	}
+
+	if n != 2 {
+		t.Errorf("pruneResources: got %d removed resources want 2\n", n)
+	}
+
+	res := xRefTable.Table[5].Object.(PDFDict)
+
+	for category, want := range map[string]string{"XObject": "Fm0,Im0", "ExtGState": "GS0"} {
+		if got := resourceNames(res.PDFDictEntry(category)); got != want {
+			t.Errorf("%s: got %s want %s\n", category, got, want)
+		}
+	}
+
+	fonts := xRefTable.Table[10].Object.(PDFDict)
+	if got := resourceNames(&fonts); got != "F1,F2" {
+		t.Errorf("Font: got %s want F1,F2\n", got)
+	}
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Revision describes one revision of a document, that is the original file or the file as of one of its incremental updates.
+type Revision struct {
+	Number     int      // 1 for the original document.
+	Size       int64    // Length of the revision in bytes.
+	PageCount  int      // Number of pages.
+	Objects    int      // Number of objects in use.
+	Changed    int      // Number of objects added or modified compared to the previous revision.
+	ModDate    string   // Modification date of the document info dict.
+	Signatures []string // Signature fields signing exactly this revision.
+}
+
+func (r Revision) String() string {
+
+	ss := []string{
+		fmt.Sprintf("revision %d:", r.Number),
+		fmt.Sprintf("  size:        %d bytes", r.Size),
+		fmt.Sprintf("  pages:       %d", r.PageCount),
+		fmt.Sprintf("  objects:     %d", r.Objects),
+	}
+
+	if r.Number > 1 {
+		ss = append(ss, fmt.Sprintf("  changed:     %d", r.Changed))
+	}
+
+	if r.ModDate != "" {
+		ss = append(ss, fmt.Sprintf("  modified:    %s", r.ModDate))
+	}
+
+	for _, s := range r.Signatures {
+		ss = append(ss, "  signed by:   "+s)
+	}
+
+	return strings.Join(ss, "\n")
+}
+
+var (
+	startXRefEOF = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF$`)
+	objHeader    = regexp.MustCompile(`^\d+\s+\d+\s+obj`)
+)
+
+// revisionOffsets returns the end offsets of all revisions of the file b in ascending order.
+// A revision ends with its end-of-file marker including any trailing EOL,
+// unless a signature in signed covers the marker without the EOL.
+// End-of-file markers not preceded by a cross reference offset pointing into the file are skipped,
+// like the one of the first page section of a linearized file or of embedded uncompressed PDF files.
+func revisionOffsets(b []byte, signed map[int64][]string) []int64 {
+
+	var offs []int64
+
+	for i := 0; ; {
+
+		j := bytes.Index(b[i:], []byte("%%EOF"))
+		if j < 0 {
+			break
+		}
+
+		eof := i + j + 5
+		i = eof
+
+		from := eof - 64
+		if from < 0 {
+			from = 0
+		}
+
+		m := startXRefEOF.FindSubmatch(b[from:eof])
+		if m == nil {
+			continue
+		}
+
+		off, err := strconv.ParseInt(string(m[1]), 10, 64)
+		if err != nil || off <= 0 || off >= int64(eof) {
+			continue
+		}
+
+		s := b[off:]
+		if len(s) > 32 {
+			s = s[:32]
+		}
+
+		if !bytes.HasPrefix(s, []byte("xref")) && !objHeader.Match(s) {
+			continue
+		}
+
+		end := int64(eof)
+
+		if _, found := signed[end]; !found {
+			if end < int64(len(b)) && b[end] == '\r' {
+				end++
+			}
+			if end < int64(len(b)) && b[end] == '\n' {
+				end++
+			}
+		}
+
+		offs = append(offs, end)
+	}
+
+	if len(offs) == 0 {
+		offs = append(offs, int64(len(b)))
+	}
+
+	return offs
+}
+
+// signedRevisions maps the signature fields of ctx to the end offsets of the revisions they sign.
+func signedRevisions(ctx *PDFContext) (map[int64][]string, error) {
+
+	sigDicts, err := signatureDicts(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[int64][]string{}
+
+	for name, d := range sigDicts {
+
+		arr, err := ctx.DereferenceArray(d.Dict["ByteRange"])
+		if err != nil || arr == nil || len(*arr) != 4 {
+			continue
+		}
+
+		off2, err := ctx.DereferenceInteger((*arr)[2])
+		if err != nil || off2 == nil {
+			continue
+		}
+
+		len2, err := ctx.DereferenceInteger((*arr)[3])
+		if err != nil || len2 == nil {
+			continue
+		}
+
+		end := int64(*off2 + *len2)
+		m[end] = append(m[end], name)
+	}
+
+	for _, names := range m {
+		sort.Strings(names)
+	}
+
+	return m, nil
+}
+
+// revisionPageCount returns the page count of the page tree root dict since revisions are not validated.
+func revisionPageCount(ctx *PDFContext) int {
+
+	indRef, err := ctx.Pages()
+	if err != nil || indRef == nil {
+		return 0
+	}
+
+	d, err := ctx.DereferenceDict(*indRef)
+	if err != nil || d == nil {
+		return 0
+	}
+
+	if i := d.IntEntry("Count"); i != nil {
+		return *i
+	}
+
+	return 0
+}
+
+func revisionModDate(ctx *PDFContext) string {
+
+	if ctx.Info == nil {
+		return ""
+	}
+
+	d, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil || d == nil {
+		return ""
+	}
+
+	s, err := textString(ctx, d.Dict["ModDate"])
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// ListRevisions returns the revisions of ctx read from the file b, the original document first.
+func ListRevisions(ctx *PDFContext, b []byte) ([]*Revision, error) {
+
+	log.Debug.Println("ListRevisions begin")
+
+	// Signatures are looked up in the final revision, which holds the values of all signature fields.
+	signed, err := signedRevisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		revs []*Revision
+		prev map[int][20]byte
+	)
+
+	for i, end := range revisionOffsets(b, signed) {
+
+		rev, err := readRevision(b, end, ctx.Configuration)
+		if err != nil {
+			return nil, errors.Wrapf(err, "revision %d", i+1)
+		}
+
+		r := &Revision{
+			Number:     i + 1,
+			Size:       end,
+			PageCount:  revisionPageCount(rev),
+			ModDate:    revisionModDate(rev),
+			Signatures: signed[end],
+		}
+
+		fps := map[int][20]byte{}
+
+		for objNr, entry := range rev.Table {
+
+			if objNr == 0 || entry.Free {
+				continue
+			}
+
+			r.Objects++
+
+			if d, _ := dictOf(entry.Object); d != nil && d.Type() != nil && (*d.Type() == "XRef" || *d.Type() == "ObjStm") {
+				continue
+			}
+
+			fps[objNr] = objectFingerprint(entry.Object)
+
+			if fp, found := prev[objNr]; prev != nil && (!found || fp != fps[objNr]) {
+				r.Changed++
+			}
+		}
+
+		prev = fps
+		revs = append(revs, r)
+	}
+
+	log.Debug.Println("ListRevisions end")
+
+	return revs, nil
+}
+
+// ExtractRevision returns revision n of ctx read from the file b as a standalone PDF file.
+// Revision 1 is the original document.
+func ExtractRevision(ctx *PDFContext, b []byte, n int) ([]byte, error) {
+
+	signed, err := signedRevisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offs := revisionOffsets(b, signed)
+
+	if n < 1 || n > len(offs) {
+		return nil, errors.Errorf("ExtractRevision: revision %d out of range 1..%d", n, len(offs))
+	}
+
+	return b[:offs[n-1]], nil
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// externalActionTypes are the action types referring to resources outside of the document, see 12.6.4.
+var externalActionTypes = []string{"GoToE", "GoToR", "ImportData", "Launch", "SubmitForm", "URI"}
+
+type sanitizer struct {
+	xRefTable *XRefTable
+	report    []string
+}
+
+func (s *sanitizer) removed(n int, singular, plural string) {
+
+	switch {
+	case n == 1:
+		s.report = append(s.report, fmt.Sprintf("removed 1 %s", singular))
+	case n > 1:
+		s.report = append(s.report, fmt.Sprintf("removed %d %s", n, plural))
+	}
+}
+
+func (s *sanitizer) actions(removeExternalActions bool) error {
+
+	types := StringSet{"JavaScript": true}
+	if removeExternalActions {
+		for _, t := range externalActionTypes {
+			types[t] = true
+		}
+	}
+
+	w, err := processActions(s.xRefTable, types, true)
+	if err != nil {
+		return err
+	}
+
+	s.removed(w.counts["JavaScript"], "JavaScript action", "JavaScript actions")
+
+	var ss []string
+	for t := range w.counts {
+		if t != "JavaScript" {
+			ss = append(ss, t)
+		}
+	}
+	sort.Strings(ss)
+
+	for _, t := range ss {
+		s.removed(w.counts[t], t+" action", t+" actions")
+	}
+
+	return nil
+}
+
+// xfa removes XFA forms, which may carry scripts of their own.
+func (s *sanitizer) xfa() error {
+
+	rootDict, err := s.xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootDict.Delete("NeedsRendering")
+
+	obj, found := rootDict.Find("AcroForm")
+	if !found {
+		return nil
+	}
+
+	d, err := s.xRefTable.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return err
+	}
+
+	if _, found := d.Find("XFA"); found {
+		d.Delete("XFA")
+		s.report = append(s.report, "removed XFA form")
+	}
+
+	return nil
+}
+
+func (s *sanitizer) embeddedFiles() error {
+
+	xRefTable := s.xRefTable
+
+	if !xRefTable.Valid && xRefTable.Names["EmbeddedFiles"] == nil {
+		if err := xRefTable.LocateNameTree("EmbeddedFiles", false); err != nil {
+			return err
+		}
+	}
+
+	if tree := xRefTable.Names["EmbeddedFiles"]; tree != nil {
+
+		n := 0
+		err := tree.Process(xRefTable, func(xRefTable *XRefTable, k string, v PDFObject) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		delete(xRefTable.Names, "EmbeddedFiles")
+
+		if err = xRefTable.RemoveNameTree("EmbeddedFiles"); err != nil {
+			return err
+		}
+
+		s.removed(n, "embedded file", "embedded files")
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	// Associated files (PDF 2.0).
+	rootDict.Delete("AF")
+
+	n, err := RemoveAnnotations(xRefTable, nil, []string{"FileAttachment"}, nil)
+	if err != nil {
+		return err
+	}
+
+	s.removed(n, "file attachment annotation", "file attachment annotations")
+
+	return nil
+}
+
+// metadata removes the document information dictionary and all XMP metadata streams.
+func (s *sanitizer) metadata() {
+
+	if s.xRefTable.Info != nil {
+		s.xRefTable.Info = nil
+		s.report = append(s.report, "removed document info")
+	}
+
+	n := 0
+
+	for _, entry := range s.xRefTable.Table {
+
+		if entry.Free {
+			continue
+		}
+
+		var d PDFDict
+
+		switch o := entry.Object.(type) {
+		case PDFDict:
+			d = o
+		case PDFStreamDict:
+			d = o.PDFDict
+		default:
+			continue
+		}
+
+		if _, found := d.Find("Metadata"); found {
+			d.Delete("Metadata")
+			n++
+		}
+	}
+
+	s.removed(n, "metadata stream", "metadata streams")
+}
+
+func (s *sanitizer) thumbnails() error {
+
+	n := 0
+
+	for i := 1; i <= s.xRefTable.PageCount; i++ {
+
+		pageDict, _, err := s.xRefTable.PageDict(i)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		if _, found := pageDict.Find("Thumb"); found {
+			pageDict.Delete("Thumb")
+			n++
+		}
+	}
+
+	s.removed(n, "thumbnail", "thumbnails")
+
+	return nil
+}
+
+// Sanitize removes potentially harmful or revealing content from ctx:
+// JavaScript, XFA forms, embedded files, file attachment annotations, document info, XMP metadata and thumbnails.
+// If removeExternalActions is set, actions referring to resources outside of the document get removed too.
+// Prior revisions disappear once ctx is written as a whole.
+// Sanitize returns a report listing the items removed.
+func Sanitize(ctx *PDFContext, removeExternalActions bool) ([]string, error) {
+
+	log.Debug.Println("Sanitize begin")
+
+	s := &sanitizer{xRefTable: ctx.XRefTable}
+
+	if err := s.actions(removeExternalActions); err != nil {
+		return nil, errors.Wrap(err, "Sanitize")
+	}
+
+	if err := s.xfa(); err != nil {
+		return nil, errors.Wrap(err, "Sanitize")
+	}
+
+	if err := s.embeddedFiles(); err != nil {
+		return nil, errors.Wrap(err, "Sanitize")
+	}
+
+	s.metadata()
+
+	if err := s.thumbnails(); err != nil {
+		return nil, errors.Wrap(err, "Sanitize")
+	}
+
+	if ctx.Read != nil {
+		n := ctx.Read.Revisions() - 1
+		s.removed(n, "prior revision", "prior revisions")
+	}
+
+	if len(s.report) == 0 {
+		s.report = append(s.report, "nothing to remove")
+	}
+
+	log.Debug.Println("Sanitize end")
+
+	return s.report, nil
+}
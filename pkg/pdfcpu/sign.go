@@ -0,0 +1,570 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Reserved bytes for the CMS signature in addition to the certificates.
+	signatureContentsReserve = 8192
+
+	// Reserved bytes for a time stamp token including the certificates of the time stamp authority.
+	timestampReserve = 16384
+
+	// Placeholder for the byte range to be filled in after writing.
+	byteRangePlaceholder = "[0 9999999999 9999999999 9999999999]"
+)
+
+// Signer creates signatures on behalf of the holder of a signing certificate.
+// Implementations may delegate to a hardware security module, a cloud key management service or a smartcard
+// so the private key never needs to be known to pdfcpu.
+type Signer interface {
+
+	// Certificates returns the signing certificate followed by its chain.
+	Certificates() []*x509.Certificate
+
+	// Sign returns the signature of a SHA-256 digest using the private key of the signing certificate:
+	// a PKCS#1 v1.5 signature for RSA keys or an ASN.1 DER encoded signature for ECDSA keys.
+	Sign(digest []byte) ([]byte, error)
+}
+
+type keySigner struct {
+	key   crypto.Signer
+	certs []*x509.Certificate
+}
+
+// NewSigner returns a Signer using key, the private key of the signing certificate certs[0] followed by its chain.
+func NewSigner(key crypto.Signer, certs []*x509.Certificate) Signer {
+	return keySigner{key: key, certs: certs}
+}
+
+func (s keySigner) Certificates() []*x509.Certificate {
+	return s.certs
+}
+
+func (s keySigner) Sign(digest []byte) ([]byte, error) {
+	return s.key.Sign(rand.Reader, digest, crypto.SHA256)
+}
+
+// SignatureAttributes represents the details of a signature.
+type SignatureAttributes struct {
+	FieldName   string           // new or unsigned signature field, defaults to "Signature<n>"
+	Page        int              // page of a new signature field, defaults to 1
+	Rect        *types.Rectangle // widget rectangle of a new visible signature field, nil for an invisible signature
+	Reason      string
+	Location    string
+	ContactInfo string
+	Time        time.Time   // signing time, defaults to now
+	Timestamper Timestamper // optional time stamp authority for a signature time stamp
+
+	// Appearance of a visible signature.
+	Name            string // signer name, defaults to the common name of the signing certificate
+	ImageFileName   string // optional PNG or TIFF image like a scanned signature or a logo
+	ImageBackground bool   // draw the image behind the text instead of left of the text
+}
+
+// ParseSignatureAttributes parses a signature command string into signature attributes.
+// The string consists of the optional entries "field:name", "page:n", "rect:llx lly urx ury",
+// "reason:text", "location:text", "contact:text", "tsa:url", "name:text" and either "image:fileName" or "bgimage:fileName".
+func ParseSignatureAttributes(s string) (*SignatureAttributes, error) {
+
+	sa := &SignatureAttributes{Page: 1}
+
+	if len(strings.TrimSpace(s)) == 0 {
+		return sa, nil
+	}
+
+	for _, s := range strings.Split(s, ",") {
+
+		ss := strings.SplitN(s, ":", 2)
+		if len(ss) != 2 {
+			return nil, errors.Errorf("invalid signature configuration: %s", s)
+		}
+
+		k := strings.TrimSpace(ss[0])
+		v := strings.TrimSpace(ss[1])
+
+		switch k {
+
+		case "field":
+			sa.FieldName = v
+
+		case "page":
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 1 {
+				return nil, errors.Errorf("invalid page: %s", v)
+			}
+			sa.Page = i
+
+		case "rect":
+			l := &Link{}
+			if err := parseLinkRect(v, l); err != nil {
+				return nil, err
+			}
+			sa.Rect = &l.Rect
+
+		case "reason":
+			sa.Reason = v
+
+		case "location":
+			sa.Location = v
+
+		case "contact":
+			sa.ContactInfo = v
+
+		case "tsa":
+			sa.Timestamper = NewTSAClient(v)
+
+		case "name":
+			sa.Name = v
+
+		case "image", "bgimage":
+			if sa.ImageFileName != "" {
+				return nil, errors.New("invalid signature configuration: only one image allowed")
+			}
+			sa.ImageFileName = v
+			sa.ImageBackground = k == "bgimage"
+
+		default:
+			return nil, errors.Errorf("invalid signature configuration: %s", s)
+		}
+	}
+
+	return sa, nil
+}
+
+// signatureField returns the unsigned signature field to be signed, which gets created if necessary.
+func signatureField(xRefTable *XRefTable, sa *SignatureAttributes) (*formField, error) {
+
+	fields, _, err := formFieldsByName(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	name := sa.FieldName
+	for i := 1; name == ""; i++ {
+		if _, ok := fields[fmt.Sprintf("Signature%d", i)]; !ok {
+			name = fmt.Sprintf("Signature%d", i)
+		}
+	}
+
+	if f, ok := fields[name]; ok {
+
+		if f.fa.ft != "Sig" {
+			return nil, errors.Errorf("field %s is not a signature field", name)
+		}
+
+		if _, found := f.dict.Find("V"); found {
+			return nil, errors.Errorf("field %s is already signed", name)
+		}
+
+		if sa.Rect != nil {
+			return nil, errors.Errorf("field %s exists, rect not allowed", name)
+		}
+
+		return f, nil
+	}
+
+	fa := FieldAttributes{Name: name, Page: sa.Page}
+	if sa.Rect != nil {
+		fa.Rect = *sa.Rect
+	}
+
+	if err = AddSignatureField(xRefTable, fa, ""); err != nil {
+		return nil, err
+	}
+
+	if fields, _, err = formFieldsByName(xRefTable); err != nil {
+		return nil, err
+	}
+
+	return fields[name], nil
+}
+
+// signatureAppearanceLines returns the text lines of a visible signature signed at t.
+func signatureAppearanceLines(sa *SignatureAttributes, cert *x509.Certificate, t time.Time) []string {
+
+	name := sa.Name
+	if name == "" {
+		name = cert.Subject.CommonName
+	}
+	if name == "" {
+		name = cert.Subject.String()
+	}
+
+	ss := []string{
+		"Digitally signed by " + name,
+		"Date: " + t.Format("2006.01.02 15:04:05 -07'00'"),
+	}
+
+	if sa.Reason != "" {
+		ss = append(ss, "Reason: "+sa.Reason)
+	}
+
+	if sa.Location != "" {
+		ss = append(ss, "Location: "+sa.Location)
+	}
+
+	return ss
+}
+
+// signatureAppearance returns the content of a visible signature of width w and height h listing lines of text.
+// An image of iw x ih pixels referenced as /Im0 gets drawn left of the text or behind the text if background is set.
+func signatureAppearance(lines []string, w, h float64, iw, ih int, background bool) string {
+
+	const margin = 4.0
+
+	var b bytes.Buffer
+
+	x := margin
+
+	if iw > 0 && ih > 0 {
+
+		// The image box covers the left 40% or the whole widget.
+		bw, bh := 0.4*w-1.5*margin, h-2*margin
+		if background {
+			bw = w - 2*margin
+		}
+
+		s := minFloat(bw/float64(iw), bh/float64(ih))
+		sw, sh := s*float64(iw), s*float64(ih)
+
+		ix := margin
+		if background {
+			ix += (bw - sw) / 2
+		} else {
+			x = 0.4*w + 0.5*margin
+		}
+
+		fmt.Fprintf(&b, "q %.2f 0 0 %.2f %.2f %.2f cm /Im0 Do Q ", sw, sh, ix, margin+(bh-sh)/2)
+	}
+
+	// Shrink the font until all lines fit.
+	fs := minFloat(10, (h-2*margin)/(1.2*float64(len(lines))))
+	for _, s := range lines {
+		if tw := textWidth(s, "Helvetica", 1); tw > 0 {
+			fs = minFloat(fs, (w-x-margin)/tw)
+		}
+	}
+
+	fmt.Fprintf(&b, "q 0 g BT /Helv %.2f Tf %.2f TL %.2f %.2f Td ", fs, 1.2*fs, x, h-margin-fs)
+
+	for i, s := range lines {
+		esc, _ := Escape(winAnsiString(s))
+		if i > 0 {
+			b.WriteString("T* ")
+		}
+		fmt.Fprintf(&b, "(%s) Tj ", *esc)
+	}
+
+	b.WriteString("ET Q")
+
+	return b.String()
+}
+
+// addSignatureAppearance sets the appearance of the widget of a visible signature field signed at t.
+func addSignatureAppearance(xRefTable *XRefTable, f *formField, sa *SignatureAttributes, cert *x509.Certificate, t time.Time) error {
+
+	dd, err := f.widgetDicts(xRefTable)
+	if err != nil || len(dd) == 0 {
+		return err
+	}
+
+	d := dd[0]
+
+	r, err := widgetRect(xRefTable, d)
+	if err != nil {
+		return err
+	}
+
+	if r == nil {
+		return nil
+	}
+
+	w, h := r[2]-r[0], r[3]-r[1]
+	if w <= 0 || h <= 0 {
+		// Invisible signature.
+		return nil
+	}
+
+	acroForm, err := acroFormDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	font, err := defaultResourceFont(xRefTable, acroForm, "Helv", "Helvetica")
+	if err != nil {
+		return err
+	}
+
+	res := &PDFDict{Dict: map[string]PDFObject{"Font": PDFDict{Dict: map[string]PDFObject{"Helv": font}}}}
+
+	var iw, ih int
+
+	if sa.ImageFileName != "" {
+
+		sd, err := ReadImageFile(xRefTable, sa.ImageFileName)
+		if err != nil {
+			return err
+		}
+
+		iw, ih = *sd.IntEntry("Width"), *sd.IntEntry("Height")
+
+		indRef, err := xRefTable.IndRefForNewObject(*sd)
+		if err != nil {
+			return err
+		}
+
+		res.Insert("XObject", PDFDict{Dict: map[string]PDFObject{"Im0": *indRef}})
+	}
+
+	content := signatureAppearance(signatureAppearanceLines(sa, cert, t), w, h, iw, ih, sa.ImageBackground)
+
+	ap, err := appearanceStream(xRefTable, w, h, content, res)
+	if err != nil {
+		return err
+	}
+
+	d.Update("AP", PDFDict{Dict: map[string]PDFObject{"N": *ap}})
+
+	return nil
+}
+
+// signatureDict returns a signature dictionary signed at t with placeholders for ByteRange and Contents, see 12.8.1.
+func signatureDict(sa *SignatureAttributes, t time.Time, size int) PDFDict {
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Sig")
+	d.InsertName("Filter", "Adobe.PPKLite")
+	d.InsertName("SubFilter", "ETSI.CAdES.detached")
+	d.Insert("ByteRange", PDFArray{PDFInteger(0), PDFInteger(9999999999), PDFInteger(9999999999), PDFInteger(9999999999)})
+	d.Insert("Contents", PDFHexLiteral(strings.Repeat("0", 2*size)))
+	d.Insert("M", DateStringLiteral(t))
+
+	if sa.Name != "" {
+		d.Insert("Name", encodeText(sa.Name))
+	}
+
+	if sa.Reason != "" {
+		d.Insert("Reason", encodeText(sa.Reason))
+	}
+
+	if sa.Location != "" {
+		d.Insert("Location", encodeText(sa.Location))
+	}
+
+	if sa.ContactInfo != "" {
+		d.Insert("ContactInfo", encodeText(sa.ContactInfo))
+	}
+
+	return d
+}
+
+// fillSignature sets the byte range of the signature dict written at off in b
+// and embeds the signature of the SHA-256 digest of the covered bytes created by sign.
+func fillSignature(b []byte, off int, size int, sign func(digest []byte) ([]byte, error)) error {
+
+	i := bytes.Index(b[off:], []byte(byteRangePlaceholder))
+	if i < 0 {
+		return errors.New("fillSignature: missing byte range")
+	}
+	i += off
+
+	placeholder := []byte("<" + strings.Repeat("0", 2*size) + ">")
+
+	j := bytes.Index(b[off:], placeholder)
+	if j < 0 {
+		return errors.New("fillSignature: missing contents")
+	}
+	j += off
+
+	// The byte range excludes the hex string holding the signature.
+	k := j + len(placeholder)
+
+	s := fmt.Sprintf("[0 %d %d %d]", j, k, len(b)-k)
+	if len(s) > len(byteRangePlaceholder) {
+		return errors.New("fillSignature: file too large")
+	}
+	copy(b[i:], s+strings.Repeat(" ", len(byteRangePlaceholder)-len(s)))
+
+	h := sha256.New()
+	h.Write(b[:j])
+	h.Write(b[k:])
+
+	sig, err := sign(h.Sum(nil))
+	if err != nil {
+		return err
+	}
+
+	if len(sig) > size {
+		return errors.Errorf("fillSignature: signature exceeds reserved size: %d > %d", len(sig), size)
+	}
+
+	hex.Encode(b[j+1:], sig)
+
+	return nil
+}
+
+// signIncrement adds the signature dict d with room for a signature of size bytes to the field f
+// and returns orig followed by an incremental update holding the signature created by sign.
+func signIncrement(ctx *PDFContext, orig []byte, snap ObjectSnapshot, f *formField, d PDFDict, size int, sign func(digest []byte) ([]byte, error)) ([]byte, error) {
+
+	indRef, err := ctx.IndRefForNewObject(d)
+	if err != nil {
+		return nil, err
+	}
+
+	f.dict.Update("V", *indRef)
+
+	acroForm, err := acroFormDict(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	// SignaturesExist and AppendOnly.
+	sigFlags := 0
+	if i := acroForm.IntEntry("SigFlags"); i != nil {
+		sigFlags = *i
+	}
+	acroForm.Update("SigFlags", PDFInteger(sigFlags|3))
+
+	// ETSI.CAdES.detached and ETSI.RFC3161 require PDF 2.0 or the ESIC extension level 2.
+	if err = ensureExtensionLevel(ctx.XRefTable, "ESIC", 2); err != nil {
+		return nil, err
+	}
+
+	b, err := WriteIncrement(ctx, orig, ctx.ModifiedObjects(snap))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = fillSignature(b, len(orig), size, sign); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Sign signs the document ctx read from orig using signer.
+// It returns the signed document consisting of orig and an incremental update
+// holding the signature according to PAdES baseline B-B or B-T if sa specifies a time stamp authority.
+func Sign(ctx *PDFContext, orig []byte, sa *SignatureAttributes, signer Signer) ([]byte, error) {
+
+	log.Debug.Println("Sign begin")
+
+	if ctx.Encrypt != nil {
+		return nil, newErrorf(ErrEncrypted, "Sign: encrypted documents are not supported")
+	}
+
+	if signer == nil || len(signer.Certificates()) == 0 {
+		return nil, errors.New("Sign: missing signing certificate")
+	}
+
+	certs := signer.Certificates()
+
+	t := sa.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	snap := ctx.Snapshot()
+
+	f, err := signatureField(ctx.XRefTable, sa)
+	if err != nil {
+		return nil, errors.Wrap(err, "Sign")
+	}
+
+	if err = addSignatureAppearance(ctx.XRefTable, f, sa, certs[0], t); err != nil {
+		return nil, errors.Wrap(err, "Sign")
+	}
+
+	size := signatureContentsReserve
+	for _, cert := range certs {
+		size += len(cert.Raw)
+	}
+
+	if sa.Timestamper != nil {
+		size += timestampReserve
+	}
+
+	b, err := signIncrement(ctx, orig, snap, f, signatureDict(sa, t, size), size, func(digest []byte) ([]byte, error) {
+		return signCMS(digest, signer, sa.Timestamper)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("Sign end")
+
+	return b, nil
+}
+
+// DocTimeStamp adds a document time stamp obtained from ts to the document ctx read from orig, see 12.8.5.
+// It returns orig followed by an incremental update holding the time stamp in a new invisible signature field
+// or the unsigned signature field fieldName.
+func DocTimeStamp(ctx *PDFContext, orig []byte, fieldName string, ts Timestamper) ([]byte, error) {
+
+	log.Debug.Println("DocTimeStamp begin")
+
+	if ctx.Encrypt != nil {
+		return nil, newErrorf(ErrEncrypted, "DocTimeStamp: encrypted documents are not supported")
+	}
+
+	if ts == nil {
+		return nil, errors.New("DocTimeStamp: missing time stamp authority")
+	}
+
+	snap := ctx.Snapshot()
+
+	f, err := signatureField(ctx.XRefTable, &SignatureAttributes{FieldName: fieldName, Page: 1})
+	if err != nil {
+		return nil, errors.Wrap(err, "DocTimeStamp")
+	}
+
+	size := signatureContentsReserve + timestampReserve
+
+	d := NewPDFDict()
+	d.InsertName("Type", "DocTimeStamp")
+	d.InsertName("Filter", "Adobe.PPKLite")
+	d.InsertName("SubFilter", "ETSI.RFC3161")
+	d.Insert("ByteRange", PDFArray{PDFInteger(0), PDFInteger(9999999999), PDFInteger(9999999999), PDFInteger(9999999999)})
+	d.Insert("Contents", PDFHexLiteral(strings.Repeat("0", 2*size)))
+
+	b, err := signIncrement(ctx, orig, snap, f, d, size, ts.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("DocTimeStamp end")
+
+	return b, nil
+}
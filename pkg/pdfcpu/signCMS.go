@@ -0,0 +1,265 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with CMS signed data (RFC 5652) as used by PAdES signatures (ETSI EN 319 142-1).
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningCertV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	ContentInfo      encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue // issuerAndSerialNumber or [0] subjectKeyIdentifier
+	DigestAlgorithm    algorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type essCertIDv2 struct {
+	CertHash     []byte
+	IssuerSerial issuerSerial
+}
+
+type issuerSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber asn1.RawValue
+}
+
+// derSet returns the DER encoded SET OF elements, see X.690 11.6.
+func derSet(elements [][]byte) ([]byte, error) {
+
+	sort.Slice(elements, func(i, j int) bool { return bytes.Compare(elements[i], elements[j]) < 0 })
+
+	return asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(elements, nil)})
+}
+
+func newAttribute(oid asn1.ObjectIdentifier, value interface{}) ([]byte, error) {
+
+	v, err := asn1.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(attribute{Type: oid, Values: []asn1.RawValue{{FullBytes: v}}})
+}
+
+// signingCertificateV2 returns the ESS signing certificate v2 attribute value for cert, see RFC 5035.
+func signingCertificateV2(cert *x509.Certificate) (interface{}, error) {
+
+	h := sha256.Sum256(cert.Raw)
+
+	// GeneralNames holding the issuer as directoryName [4].
+	issuer, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: cert.RawIssuer})
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := asn1.Marshal(cert.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	id := essCertIDv2{
+		CertHash: h[:],
+		IssuerSerial: issuerSerial{
+			Issuer:       asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: issuer},
+			SerialNumber: asn1.RawValue{FullBytes: serial},
+		},
+	}
+
+	// SigningCertificateV2 ::= SEQUENCE { certs SEQUENCE OF ESSCertIDv2 }
+	return struct{ Certs []essCertIDv2 }{[]essCertIDv2{id}}, nil
+}
+
+// signatureAlgorithm returns the signature algorithm matching the public key of the signing certificate.
+func signatureAlgorithm(cert *x509.Certificate) (algorithmIdentifier, error) {
+
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return algorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}, nil
+	case *ecdsa.PublicKey:
+		return algorithmIdentifier{Algorithm: oidECDSAWithSHA256}, nil
+	}
+
+	return algorithmIdentifier{}, errors.Errorf("unsupported public key: %T", cert.PublicKey)
+}
+
+// signCMS returns a detached CMS signature according to PAdES baseline B-B for content with the SHA-256 digest.
+// If ts is not nil the signature value gets time stamped according to PAdES baseline B-T.
+func signCMS(digest []byte, signer Signer, ts Timestamper) ([]byte, error) {
+	return signedDataCMS(oidData, nil, digest, signer, ts)
+}
+
+// signedDataCMS returns CMS signed data of content type contentType for content with the SHA-256 digest.
+// The content is encapsulated unless eContent is nil.
+func signedDataCMS(contentType asn1.ObjectIdentifier, eContent, digest []byte, signer Signer, ts Timestamper) ([]byte, error) {
+
+	certs := signer.Certificates()
+	if len(certs) == 0 {
+		return nil, errors.New("signCMS: missing signing certificate")
+	}
+
+	cert := certs[0]
+
+	sigAlg, err := signatureAlgorithm(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	scv2, err := signingCertificateV2(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs [][]byte
+
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidAttrContentType, contentType},
+		{oidAttrMessageDigest, digest},
+		{oidAttrSigningCertV2, scv2},
+	} {
+		attr, err := newAttribute(a.oid, a.value)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+
+	// The signature covers the DER encoded SET OF signed attributes.
+	signedAttrs, err := derSet(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256(signedAttrs)
+
+	sig, err := signer.Sign(h[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "signCMS")
+	}
+
+	var unsignedAttrs asn1.RawValue
+
+	if ts != nil {
+		h := sha256.Sum256(sig)
+		token, err := ts.Timestamp(h[:])
+		if err != nil {
+			return nil, errors.Wrap(err, "signCMS")
+		}
+		attr, err := asn1.Marshal(attribute{Type: oidAttrTimeStampToken, Values: []asn1.RawValue{{FullBytes: token}}})
+		if err != nil {
+			return nil, err
+		}
+		unsignedAttrs = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: attr}
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.Raw...)
+	}
+
+	digestAlg := algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+
+	// Replace the SET tag by the implicit [0].
+	var setAttrs asn1.RawValue
+	if _, err = asn1.Unmarshal(signedAttrs, &setAttrs); err != nil {
+		return nil, err
+	}
+
+	eci := encapContentInfo{ContentType: contentType}
+
+	if eContent != nil {
+		b, err := asn1.Marshal(eContent)
+		if err != nil {
+			return nil, err
+		}
+		eci.Content = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b}
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{digestAlg},
+		ContentInfo:      eci,
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    digestAlg,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: setAttrs.Bytes},
+			SignatureAlgorithm: sigAlg,
+			Signature:          sig,
+			UnsignedAttrs:      unsignedAttrs,
+		}},
+	}
+
+	content, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+}
@@ -0,0 +1,518 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"io"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Streaming split
+//
+// A SplitSource reads the cross reference table of a PDF file only and parses objects on first access.
+// Every chunk of pages gets assembled in a new xRefTable holding copies of the objects reachable from its pages.
+// References to pages of other chunks become null.
+// Stream content is read from the source on write and resources not used by the pages of a chunk are pruned,
+// so unused resources shared with other pages never get loaded.
+// Once a chunk has been written the objects loaded for it get dropped,
+// so memory usage depends on the size of a chunk rather than the size of the file.
+//
+// A SplitSource is not safe for concurrent use.
+
+// The catalog entries every chunk inherits.
+var splitRootAttrs = []string{"Lang", "OCProperties", "PageLayout", "PageMode", "ViewerPreferences"}
+
+// splitPage is a page along with the attributes it inherits from the page tree.
+type splitPage struct {
+	indRef PDFIndirectRef
+	attrs  map[string]PDFObject
+}
+
+// SplitSource provides chunks of pages of a PDF file read on demand.
+type SplitSource struct {
+	ctx    *PDFContext
+	pages  []splitPage
+	nodes  IntSet // Page tree nodes including pages.
+	loaded []int  // Objects loaded since the last chunk.
+}
+
+// NewSplitSource reads the cross reference table and the page tree of the PDF file read from rs.
+func NewSplitSource(fileName string, rs io.ReadSeeker, config *Configuration) (*SplitSource, error) {
+
+	s := &SplitSource{nodes: IntSet{}}
+
+	ctx, onDemand, err := readSplitContext(fileName, rs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ctx = ctx
+
+	if onDemand {
+		ctx.loadObject = s.load
+	}
+
+	if err = s.collectPages(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// readSplitContext returns a PDFContext for fileName and true if its objects need to be loaded on demand.
+// Files that need to be repaired get read as a whole.
+func readSplitContext(fileName string, rs io.ReadSeeker, config *Configuration) (*PDFContext, bool, error) {
+
+	if config != nil && config.Mode == REPAIR {
+		ctx, err := readPDF(fileName, rs, config)
+		return ctx, false, err
+	}
+
+	ctx, err := NewPDFContext(fileName, rs, config)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = readXRefTable(ctx)
+	if err == nil {
+		err = checkForEncryption(ctx)
+	}
+
+	if err != nil {
+		if !repairable(ctx) {
+			return nil, false, err
+		}
+		log.Info.Printf("readSplitContext: %v\n", err)
+		ctx1, err1 := repairPDF(fileName, rs, config)
+		if err1 != nil {
+			log.Info.Printf("readSplitContext: repair failed: %v\n", err1)
+			return nil, false, err
+		}
+		return ctx1, false, nil
+	}
+
+	// Objects of object streams always have generation 0.
+	for _, entry := range ctx.Table {
+		if entry.Compressed && entry.Generation == nil {
+			zero := 0
+			entry.Generation = &zero
+		}
+	}
+
+	return ctx, true, nil
+}
+
+// load parses the object of entry from file.
+func (s *SplitSource) load(entry *XRefTableEntry, objNr int) (PDFObject, error) {
+
+	ctx := s.ctx
+
+	if entry.Compressed {
+
+		objStmNr := *entry.ObjectStream
+
+		objStm, found := ctx.Find(objStmNr)
+		if !found {
+			return nil, errors.Errorf("SplitSource: missing object stream %d for obj#%d", objStmNr, objNr)
+		}
+
+		if _, ok := objStm.Object.(PDFObjectStreamDict); !ok {
+			if err := decodeObjectStream(ctx, objStmNr); err != nil {
+				return nil, err
+			}
+			s.loaded = append(s.loaded, objStmNr)
+		}
+
+		if err := decompressXRefTableEntry(ctx.XRefTable, objNr, entry); err != nil {
+			return nil, err
+		}
+
+		s.loaded = append(s.loaded, objNr)
+
+		return entry.Object, nil
+	}
+
+	if entry.Offset == nil || *entry.Offset == 0 {
+		return nil, nil
+	}
+
+	log.Debug.Printf("SplitSource: loading obj#%d\n", objNr)
+
+	// Stream content gets loaded on write.
+	o, err := pdfObject(ctx, *entry.Offset, objNr, *entry.Generation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "SplitSource: problem dereferencing object %d", objNr)
+	}
+
+	entry.Object = o
+	s.loaded = append(s.loaded, objNr)
+
+	return o, nil
+}
+
+// unload drops all objects loaded since the last chunk.
+func (s *SplitSource) unload() {
+
+	for _, objNr := range s.loaded {
+		entry := s.ctx.Table[objNr]
+		entry.Object = nil
+		if entry.ObjectStream != nil {
+			entry.Compressed = true
+		}
+	}
+
+	s.loaded = nil
+}
+
+func (s *SplitSource) collectPages() error {
+
+	xRefTable := s.ctx.XRefTable
+
+	defer s.unload()
+
+	indRef, err := xRefTable.Pages()
+	if err != nil {
+		return err
+	}
+
+	if indRef == nil {
+		return errors.New("SplitSource: missing page tree root")
+	}
+
+	root, err := xRefTable.DereferenceDict(*indRef)
+	if err != nil {
+		return err
+	}
+
+	if root == nil {
+		return errors.New("SplitSource: missing page tree root")
+	}
+
+	rootObjNr := indRef.ObjectNumber.Value()
+
+	scan := pageTreeScan{pages: IntSet{}}
+
+	if _, err = scan.scan(xRefTable, root, []int{rootObjNr}, nil); err != nil {
+		return err
+	}
+
+	s.nodes[rootObjNr] = true
+	for _, objNr := range scan.nodes {
+		s.nodes[objNr] = true
+	}
+
+	for _, l := range scan.leaves {
+
+		attrs := map[string]PDFObject{}
+
+		for _, k := range inheritablePageAttrs {
+
+			if _, found := l.dict.Find(k); found {
+				continue
+			}
+
+			if a, ok := l.attrs[k]; ok {
+				attrs[k] = a.obj
+				continue
+			}
+
+			if o, found := root.Find(k); found && o != nil {
+				attrs[k] = o
+			}
+		}
+
+		s.pages = append(s.pages, splitPage{l.indRef, attrs})
+		s.nodes[l.indRef.ObjectNumber.Value()] = true
+	}
+
+	xRefTable.PageCount = len(s.pages)
+
+	return nil
+}
+
+// PageCount returns the number of pages of the source.
+func (s *SplitSource) PageCount() int {
+	return len(s.pages)
+}
+
+// WritePages writes the pages from thru thru as PDF file to w.
+func (s *SplitSource) WritePages(w io.Writer, from, thru int) error {
+
+	if from < 1 || thru < from || thru > len(s.pages) {
+		return errors.Errorf("SplitSource: invalid page range %d-%d", from, thru)
+	}
+
+	defer s.unload()
+
+	ctx, err := s.chunk(s.pages[from-1 : thru])
+	if err != nil {
+		return err
+	}
+
+	return WritePDF(ctx, w)
+}
+
+// splitChunk copies objects of a SplitSource into the xRefTable of a chunk.
+type splitChunk struct {
+	src       *SplitSource
+	xRefTable *XRefTable
+	objNrs    map[int]int            // Source object numbers to chunk object numbers.
+	streams   map[int]PDFIndirectRef // Chunk streams to source streams.
+}
+
+// loadStream loads the encoded content of a stream of the chunk from the source.
+func (c *splitChunk) loadStream(sd *PDFStreamDict, objNr, genNr int) error {
+
+	indRef, found := c.streams[objNr]
+	if !found {
+		return errors.Errorf("SplitSource: missing source for stream obj#%d", objNr)
+	}
+
+	srcObjNr := indRef.ObjectNumber.Value()
+
+	if _, err := loadEncodedStreamContent(c.src.ctx, sd); err != nil {
+		return errors.Wrapf(err, "SplitSource: problem loading stream %d", srcObjNr)
+	}
+
+	// Decrypt only, stream content gets copied encoded.
+	return saveDecodedStreamContent(c.src.ctx, sd, srcObjNr, indRef.GenerationNumber.Value(), false)
+}
+
+func (s *SplitSource) chunk(pages []splitPage) (*PDFContext, error) {
+
+	log.Debug.Printf("SplitSource: chunk of %d pages\n", len(pages))
+
+	xRefTable, err := createXRefTableWithRootDict()
+	if err != nil {
+		return nil, err
+	}
+
+	xRefTable.HeaderVersion = s.ctx.HeaderVersion
+
+	c := splitChunk{src: s, xRefTable: xRefTable, objNrs: map[int]int{}, streams: map[int]PDFIndirectRef{}}
+
+	// Streams get loaded on first access, unused resources never.
+	xRefTable.loadStream = c.loadStream
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	pagesDict := NewPDFDict()
+	pagesDict.InsertName("Type", "Pages")
+
+	pagesIndRef, err := xRefTable.IndRefForNewObject(pagesDict)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pages go first in order to keep references between pages of a chunk.
+	for _, p := range pages {
+		c.objNrs[p.indRef.ObjectNumber.Value()] = xRefTable.InsertNew(*NewXRefTableEntryGen0(nil))
+	}
+
+	kids := PDFArray{}
+
+	for _, p := range pages {
+
+		srcObjNr := p.indRef.ObjectNumber.Value()
+
+		d, err := s.ctx.DereferenceDict(p.indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			return nil, errors.Errorf("SplitSource: missing page dict obj#%d", srcObjNr)
+		}
+
+		// Annotations get dropped on write, see writePageDict.
+		pageDict, err := c.copyDict(*d, "Parent", "Annots")
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range p.attrs {
+			o, err := c.copy(v)
+			if err != nil {
+				return nil, err
+			}
+			if o != nil {
+				pageDict.Insert(k, o)
+			}
+		}
+
+		pageDict.Insert("Parent", *pagesIndRef)
+
+		objNr := c.objNrs[srcObjNr]
+		xRefTable.Table[objNr].Object = pageDict
+		kids = append(kids, *NewPDFIndirectRef(objNr, 0))
+	}
+
+	pagesDict.Insert("Kids", kids)
+	pagesDict.InsertInt("Count", len(kids))
+	rootDict.Insert("Pages", *pagesIndRef)
+	xRefTable.PageCount = len(kids)
+
+	srcRootDict, err := s.ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range splitRootAttrs {
+		if o, found := srcRootDict.Find(k); found {
+			if o, err = c.copy(o); err != nil {
+				return nil, err
+			}
+			if o != nil {
+				rootDict.Insert(k, o)
+			}
+		}
+	}
+
+	if s.ctx.Info != nil {
+		o, err := c.copy(*s.ctx.Info)
+		if err != nil {
+			return nil, err
+		}
+		if indRef, ok := o.(PDFIndirectRef); ok {
+			xRefTable.Info = &indRef
+		}
+	}
+
+	// Pages frequently share resources with pages of other chunks.
+	if _, err = pruneResources(xRefTable); err != nil {
+		return nil, err
+	}
+
+	ctx := &PDFContext{
+		Configuration: s.ctx.Configuration,
+		XRefTable:     xRefTable,
+		Read:          newReadContext(s.ctx.Read.FileName, nil, 0),
+		Optimize:      newOptimizationContext(),
+		Write:         NewWriteContext(s.ctx.Eol),
+	}
+
+	ctx.Write.Command = "Split"
+
+	return ctx, nil
+}
+
+// copy returns a deep copy of o referring to objects of the chunk.
+func (c *splitChunk) copy(o PDFObject) (PDFObject, error) {
+
+	switch o := o.(type) {
+
+	case PDFIndirectRef:
+		return c.copyIndRef(o)
+
+	case PDFDict:
+		return c.copyDict(o)
+
+	case PDFArray:
+		arr := make(PDFArray, len(o))
+		for i, v := range o {
+			o1, err := c.copy(v)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = o1
+		}
+		return arr, nil
+
+	}
+
+	return o, nil
+}
+
+// copyDict returns a deep copy of d without the entries for skip.
+// Entries referring to pages of other chunks are dropped.
+func (c *splitChunk) copyDict(d PDFDict, skip ...string) (PDFDict, error) {
+
+	d1 := NewPDFDict()
+
+	for k, v := range d.Dict {
+
+		if memberOf(k, skip) {
+			continue
+		}
+
+		o, err := c.copy(v)
+		if err != nil {
+			return d1, err
+		}
+
+		if v != nil && o == nil {
+			continue
+		}
+
+		d1.Dict[k] = o
+	}
+
+	return d1, nil
+}
+
+func (c *splitChunk) copyIndRef(indRef PDFIndirectRef) (PDFObject, error) {
+
+	srcObjNr := indRef.ObjectNumber.Value()
+
+	if objNr, found := c.objNrs[srcObjNr]; found {
+		return *NewPDFIndirectRef(objNr, 0), nil
+	}
+
+	// Pages of other chunks along with the page tree.
+	if c.src.nodes[srcObjNr] {
+		return nil, nil
+	}
+
+	o, err := c.src.ctx.Dereference(indRef)
+	if err != nil || o == nil {
+		return nil, err
+	}
+
+	// The object number goes first since object graphs may be cyclic.
+	objNr := c.xRefTable.InsertNew(*NewXRefTableEntryGen0(nil))
+	c.objNrs[srcObjNr] = objNr
+
+	if sd, ok := o.(PDFStreamDict); ok {
+
+		// The length of the stream gets set on write.
+		d, err := c.copyDict(sd.PDFDict, "Length")
+		if err != nil {
+			return nil, err
+		}
+
+		sd1 := NewPDFStreamDict(d, sd.StreamOffset, sd.StreamLength, sd.StreamLengthObjNr, sd.FilterPipeline)
+		sd1.Raw = sd.Raw
+
+		c.xRefTable.Table[objNr].Object = sd1
+		c.streams[objNr] = indRef
+
+		return *NewPDFIndirectRef(objNr, 0), nil
+	}
+
+	if o, err = c.copy(o); err != nil {
+		return nil, err
+	}
+
+	c.xRefTable.Table[objNr].Object = o
+
+	return *NewPDFIndirectRef(objNr, 0), nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// linkedPagesFile returns a two page PDF file whose first page refers to the second one by a private entry.
+func linkedPagesFile() []byte {
+
+	var buf bytes.Buffer
+	var offsets []int
+
+	buf.WriteString("%PDF-1.7\n")
+
+	for _, s := range []string{
+		"<</Type/Catalog/Pages 2 0 R/PageMode/UseNone>>",
+		"<</Type/Pages/Kids[3 0 R 4 0 R]/Count 2/Resources 6 0 R/MediaBox[0 0 612 792]>>",
+		"<</Type/Page/Parent 2 0 R/Contents 5 0 R/Annots[7 0 R]/PDFCPU_Next[4 0 R 6 0 R]>>",
+		"<</Type/Page/Parent 2 0 R/Contents 5 0 R>>",
+		"<</Length 8>>\nstream\n0 0 m S\n\nendstream",
+		"<</ProcSet[/PDF]>>",
+		"<</Type/Annot/Subtype/Link/Rect[0 0 100 100]/Dest[4 0 R/Fit]>>",
+	} {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), s)
+	}
+
+	xrefOff := buf.Len()
+
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<</Size %d/Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOff)
+
+	return buf.Bytes()
+}
+
+// splitNextPage writes pages from thru thru of s and returns the page the first page refers to along with the chunk read back.
+func splitNextPage(t *testing.T, s *SplitSource, from, thru int) (PDFObject, *PDFContext) {
+
+	var buf bytes.Buffer
+
+	if err := s.WritePages(&buf, from, thru); err != nil {
+		t.Fatalf("WritePages %d-%d: %v\n", from, thru, err)
+	}
+
+	ctx, err := Read(bytes.NewReader(buf.Bytes()), NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("Read %d-%d: %v\n", from, thru, err)
+	}
+
+	if err = ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("ValidateXRefTable %d-%d: %v\n", from, thru, err)
+	}
+
+	if ctx.PageCount != thru-from+1 {
+		t.Fatalf("pages %d-%d: got %d pages\n", from, thru, ctx.PageCount)
+	}
+
+	d, _, err := ctx.PageDict(1)
+	if err != nil || d == nil {
+		t.Fatalf("pages %d-%d: missing first page: %v\n", from, thru, err)
+	}
+
+	if _, found := d.Find("Resources"); !found || d.PDFArrayEntry("MediaBox") == nil {
+		t.Errorf("pages %d-%d: inherited attributes lost\n", from, thru)
+	}
+
+	if _, found := d.Find("Annots"); found {
+		t.Errorf("pages %d-%d: annotations not dropped\n", from, thru)
+	}
+
+	next := d.PDFArrayEntry("PDFCPU_Next")
+	if next == nil || len(*next) != 2 || (*next)[1] == nil {
+		t.Fatalf("pages %d-%d: corrupt private entry %v\n", from, thru, next)
+	}
+
+	return (*next)[0], ctx
+}
+
+func TestSplitSource(t *testing.T) {
+
+	s, err := NewSplitSource("", bytes.NewReader(linkedPagesFile()), NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("NewSplitSource: %v\n", err)
+	}
+
+	if s.PageCount() != 2 {
+		t.Fatalf("NewSplitSource: got %d pages, want 2\n", s.PageCount())
+	}
+
+	// A reference to a page of another chunk becomes null.
+	if next, _ := splitNextPage(t, s, 1, 1); next != nil {
+		t.Errorf("page 1: got next page %v, want null\n", next)
+	}
+
+	for objNr, entry := range s.ctx.Table {
+		if objNr > 0 && entry.Object != nil {
+			t.Errorf("obj#%d still loaded\n", objNr)
+		}
+	}
+
+	// A reference to a page of the same chunk stays intact.
+	o, ctx := splitNextPage(t, s, 1, 2)
+	next, ok := o.(PDFIndirectRef)
+	if !ok {
+		t.Fatalf("pages 1-2: got next page %v\n", next)
+	}
+
+	if d, err := ctx.DereferenceDict(next); err != nil || d == nil || *d.Type() != "Page" {
+		t.Errorf("pages 1-2: got next page %v\n", d)
+	}
+
+	if err = s.WritePages(&bytes.Buffer{}, 2, 3); err == nil {
+		t.Error("WritePages 2-3: missing error")
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/fonts/metrics"
+	"github.com/hhrutter/pdfcpu/pkg/qrcode"
 	"github.com/hhrutter/pdfcpu/pkg/types"
 
 	"github.com/pkg/errors"
@@ -57,6 +59,28 @@ const (
 	diagonalULToLR
 )
 
+// positions on the page
+const (
+	posCenter = iota
+	posTopLeft
+	posTopCenter
+	posTopRight
+	posLeft
+	posRight
+	posBottomLeft
+	posBottomCenter
+	posBottomRight
+)
+
+// BlendModes are the blend modes supported for watermarks, see 11.3.5.
+var BlendModes = []string{"Normal", "Multiply", "Screen", "Overlay", "Darken", "Lighten", "ColorDodge", "ColorBurn",
+	"HardLight", "SoftLight", "Difference", "Exclusion", "Hue", "Saturation", "Color", "Luminosity"}
+
+var positions = map[string]int{
+	"c": posCenter, "tl": posTopLeft, "tc": posTopCenter, "tr": posTopRight, "l": posLeft,
+	"r": posRight, "bl": posBottomLeft, "bc": posBottomCenter, "br": posBottomRight,
+}
+
 // render mode
 const (
 	rmFill = iota
@@ -72,20 +96,39 @@ type Watermark struct {
 	// configuration
 	text          string      // display text
 	imageFileName string      // display png image
+	qrURL         string      // display QR code for this verification URL template.
+	barcode       string      // display barcode of this type: qr, code128, ean13 or ean8.
+	barcodeData   string      // barcode data, may contain variables.
 	onTop         bool        // if true this is a STAMP else this is a WATERMARK.
 	fontName      string      // supported are Adobe base fonts only. (as of now: Helvetica, Times-Roman, Courier)
 	fontSize      int         // font scaling factor.
 	color         simpleColor // fill color(=non stroking color).
 	rotation      float64     // rotation to apply in degrees. -180 <= x <= 180
 	diagonal      int         // paint along the diagonal.
-	opacity       float64     // opacity the displayed text. 0 <= x <= 1
+	opacity       float64     // fill opacity of the displayed content. 0 <= x <= 1
+	strokeOpacity float64     // stroke opacity of the displayed content. 0 <= x <= 1
+	blendMode     string      // blend mode, Normal if empty.
+	tile          bool        // if true the watermark gets repeated across the page.
+	tileDx        float64     // horizontal gap between tiles.
+	tileDy        float64     // vertical gap between tiles.
 	renderMode    int         // fill=0, stroke=1 fill&stroke=2
 	scale         float64     // relative scale factor. 0 <= x <= 1
 	scaleAbs      bool        // true for absolute scaling
+	pos           int         // position on the page, centered by default.
+	dx, dy        float64     // offset from pos in user space units.
+
+	// variables
+	textTemplate string            // display text containing variables.
+	userVars     map[string]string // user variables.
+	fileName     string            // file name of the document stamped.
+	timestamp    time.Time         // time of stamping.
 
 	// resources
 	ocg, extGState, font, image *PDFIndirectRef
 	imgWidth, imgHeight         int
+	qrCode                      *qrcode.Code
+	qrCodeURL                   string
+	bcSymbol                    *barcodeSymbol
 
 	// page specific
 	bb      types.Rectangle // bounding box of the form representing this watermark.
@@ -96,6 +139,7 @@ type Watermark struct {
 	// house keeping
 	objs   IntSet    // objects for which wm has been applied already.
 	fCache formCache // form cache.
+	tags   *tagger   // structure tree for tagging stamps.
 }
 
 func (wm Watermark) String() string {
@@ -107,6 +151,12 @@ func (wm Watermark) String() string {
 	if len(t) == 0 {
 		t = wm.imageFileName
 	}
+	if len(t) == 0 {
+		t = wm.qrURL
+	}
+	if len(t) == 0 {
+		t = wm.barcodeData
+	}
 	sc := "relative"
 	if wm.scaleAbs {
 		sc = "absolute"
@@ -117,7 +167,9 @@ func (wm Watermark) String() string {
 		"color: %s\n"+
 		"rotation: %f\n"+
 		"diagonal: %d\n"+
-		"opacity: %f\n"+
+		"opacity: %f %f\n"+
+		"blendMode: %s\n"+
+		"tile: %t %f %f\n"+
 		"renderMode: %d\n"+
 		"bbox:%s\n"+
 		"vp:%s\n"+
@@ -128,7 +180,9 @@ func (wm Watermark) String() string {
 		wm.color,
 		wm.rotation,
 		wm.diagonal,
-		wm.opacity,
+		wm.opacity, wm.strokeOpacity,
+		wm.blendMode,
+		wm.tile, wm.tileDx, wm.tileDy,
 		wm.renderMode,
 		wm.bb,
 		wm.vp,
@@ -147,7 +201,7 @@ func (wm Watermark) OnTopString() string {
 
 // IsImage returns whether the watermark content is an image or text.
 func (wm Watermark) IsImage() bool {
-	return len(wm.imageFileName) > 0
+	return len(wm.imageFileName) > 0 || wm.IsQRCode()
 }
 
 func (wm *Watermark) calcBoundingBox() {
@@ -156,8 +210,8 @@ func (wm *Watermark) calcBoundingBox() {
 
 	var bb types.Rectangle
 
-	if wm.IsImage() {
-		// image watermark
+	if wm.IsImage() || wm.IsBarcode() {
+		// image or barcode watermark
 		bb = types.NewRectangle(0, 0, float64(wm.imgWidth), float64(wm.imgHeight))
 		ar := bb.AspectRatio()
 		//fmt.Printf("calcBB: ar:%f scale:%f\n", ar, wm.scale)
@@ -232,17 +286,44 @@ func (wm *Watermark) calcTransformMatrix() *matrix {
 	m2 := identMatrix
 
 	var dy float64
-	if !wm.IsImage() {
+	if !wm.IsImage() && !wm.IsBarcode() {
 		dy = wm.bb.LL.Y
 	}
 
-	m2[2][0] = wm.vp.Width()/2 + sin*(wm.bb.Height()/2+dy) - cos*wm.bb.Width()/2
-	m2[2][1] = wm.vp.Height()/2 - cos*(wm.bb.Height()/2+dy) - sin*wm.bb.Width()/2
+	// The extent of the rotated bounding box.
+	w := math.Abs(cos)*wm.bb.Width() + math.Abs(sin)*wm.bb.Height()
+	h := math.Abs(sin)*wm.bb.Width() + math.Abs(cos)*wm.bb.Height()
+	x, y := wm.anchor(w, h)
+
+	m2[2][0] = x + sin*(wm.bb.Height()/2+dy) - cos*wm.bb.Width()/2
+	m2[2][1] = y - cos*(wm.bb.Height()/2+dy) - sin*wm.bb.Width()/2
 
 	m := m1.multiply(m2)
 	return &m
 }
 
+// anchor returns the center of a w x h box placed on the page at wm.pos and moved by the offset.
+func (wm *Watermark) anchor(w, h float64) (float64, float64) {
+
+	x, y := wm.vp.Width()/2, wm.vp.Height()/2
+
+	switch wm.pos {
+	case posTopLeft, posLeft, posBottomLeft:
+		x = w / 2
+	case posTopRight, posRight, posBottomRight:
+		x = wm.vp.Width() - w/2
+	}
+
+	switch wm.pos {
+	case posTopLeft, posTopCenter, posTopRight:
+		y = wm.vp.Height() - h/2
+	case posBottomLeft, posBottomCenter, posBottomRight:
+		y = h / 2
+	}
+
+	return x + wm.dx, y + wm.dy
+}
+
 func onTopString(onTop bool) string {
 	e := "watermark"
 	if onTop {
@@ -258,7 +339,7 @@ func parseWatermarkError(onTop bool) error {
 
 func oneWatermarkOnlyError(onTop bool) error {
 	s := onTopString(onTop)
-	return errors.Errorf("Cannot apply %s. Only one watermark/stamp allowed.\n", s)
+	return errors.Errorf("Cannot apply %s. Only one watermark/stamp allowed, try %s update.\n", s, s)
 }
 
 func setWatermarkType(s string, wm *Watermark) {
@@ -404,14 +485,65 @@ func parseWatermarkDiagonal(v string, setRot bool, wm *Watermark) error {
 
 func parseWatermarkOpacity(v string, wm *Watermark) error {
 
-	o, err := strconv.ParseFloat(v, 64)
-	if err != nil {
-		return errors.Errorf("opacity must be a float value: %s\n", v)
+	ss := strings.Fields(v)
+	if len(ss) == 0 || len(ss) > 2 {
+		return errors.Errorf("illegal opacity string: fill opacity followed by optional stroke opacity, %s\n", v)
+	}
+
+	var oo []float64
+
+	for _, s := range ss {
+		o, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.Errorf("opacity must be a float value: %s\n", v)
+		}
+		if o < 0 || o > 1 {
+			return errors.Errorf("illegal opacity: 0.0 <= r <= 1.0, %s\n", v)
+		}
+		oo = append(oo, o)
+	}
+
+	wm.opacity = oo[0]
+	wm.strokeOpacity = oo[len(oo)-1]
+
+	return nil
+}
+
+func parseWatermarkBlendMode(v string, wm *Watermark) error {
+
+	for _, bm := range BlendModes {
+		if strings.EqualFold(v, bm) {
+			wm.blendMode = bm
+			return nil
+		}
+	}
+
+	return errors.Errorf("illegal blend mode: %s, use one of %s\n", v, strings.Join(BlendModes, ", "))
+}
+
+func parseWatermarkTile(v string, wm *Watermark) error {
+
+	ss := strings.Fields(v)
+	if len(ss) == 0 || len(ss) > 2 {
+		return errors.Errorf("illegal tile string: horizontal gap followed by optional vertical gap, %s\n", v)
 	}
-	if o < 0 || o > 1 {
-		return errors.Errorf("illegal opacity: 0.0 <= r <= 1.0, %s\n", v)
+
+	var gg []float64
+
+	for _, s := range ss {
+		g, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.Errorf("tile gap must be a float value: %s\n", v)
+		}
+		if g < 0 {
+			return errors.Errorf("illegal tile gap: x >= 0, %s\n", v)
+		}
+		gg = append(gg, g)
 	}
-	wm.opacity = o
+
+	wm.tile = true
+	wm.tileDx = gg[0]
+	wm.tileDy = gg[len(gg)-1]
 
 	return nil
 }
@@ -430,6 +562,39 @@ func parseWatermarkRenderMode(v string, wm *Watermark) error {
 	return nil
 }
 
+func parseWatermarkPosition(v string, wm *Watermark) error {
+
+	p, ok := positions[v]
+	if !ok {
+		return errors.Errorf("illegal position: tl, tc, tr, l, c, r, bl, bc, br, %s\n", v)
+	}
+	wm.pos = p
+
+	return nil
+}
+
+func parseWatermarkOffset(v string, wm *Watermark) error {
+
+	ss := strings.Fields(v)
+	if len(ss) != 2 {
+		return errors.Errorf("illegal offset string: dx dy, %s\n", v)
+	}
+
+	dx, err := strconv.ParseFloat(ss[0], 64)
+	if err != nil {
+		return errors.Errorf("offset dx must be a float value: %s\n", v)
+	}
+
+	dy, err := strconv.ParseFloat(ss[1], 64)
+	if err != nil {
+		return errors.Errorf("offset dy must be a float value: %s\n", v)
+	}
+
+	wm.dx, wm.dy = dx, dy
+
+	return nil
+}
+
 // ParseWatermarkDetails parses a Watermark/Stamp command string into an internal structure.
 func ParseWatermarkDetails(s string, onTop bool) (*Watermark, error) {
 
@@ -437,34 +602,48 @@ func ParseWatermarkDetails(s string, onTop bool) (*Watermark, error) {
 
 	// Set default watermark
 	wm := &Watermark{
-		onTop:      onTop,
-		fontName:   "Helvetica",
-		fontSize:   24,
-		scale:      0.5,
-		scaleAbs:   false,
-		color:      simpleColor{0.5, 0.5, 0.5}, // gray
-		diagonal:   diagonalLLToUR,
-		opacity:    1.0,
-		renderMode: rmFill,
-		objs:       IntSet{},
-		fCache:     formCache{},
+		onTop:         onTop,
+		fontName:      "Helvetica",
+		fontSize:      24,
+		scale:         0.5,
+		scaleAbs:      false,
+		color:         simpleColor{0.5, 0.5, 0.5}, // gray
+		diagonal:      diagonalLLToUR,
+		opacity:       1.0,
+		strokeOpacity: 1.0,
+		renderMode:    rmFill,
+		objs:          IntSet{},
+		fCache:        formCache{},
 	}
 
 	ss := strings.Split(s, ",")
 
 	setWatermarkType(ss[0], wm)
 
-	if len(ss) == 1 {
-		return wm, nil
+	err := parseWatermarkConfig(ss[1:], wm)
+	if err != nil {
+		return nil, err
 	}
 
+	if hasVars(wm.text) {
+		if err = wm.checkUserVars(wm.text); err != nil {
+			return nil, err
+		}
+		wm.textTemplate = wm.text
+	}
+
+	return wm, nil
+}
+
+func parseWatermarkConfig(ss []string, wm *Watermark) error {
+
 	var setDiag, setRot bool
 
-	for _, s := range ss[1:] {
+	for _, s := range ss {
 
 		ss1 := strings.Split(s, ":")
 		if len(ss1) != 2 {
-			return nil, parseWatermarkError(onTop)
+			return parseWatermarkError(wm.onTop)
 		}
 
 		k := strings.TrimSpace(ss1[0])
@@ -500,19 +679,34 @@ func ParseWatermarkDetails(s string, onTop bool) (*Watermark, error) {
 		case "o": // opacity
 			err = parseWatermarkOpacity(v, wm)
 
+		case "bm": // blend mode
+			err = parseWatermarkBlendMode(v, wm)
+
+		case "tile": // repeat across the page
+			err = parseWatermarkTile(v, wm)
+
 		case "m": // render mode
 			err = parseWatermarkRenderMode(v, wm)
 
+		case "pos": // position
+			err = parseWatermarkPosition(v, wm)
+
+		case "off": // offset
+			err = parseWatermarkOffset(v, wm)
+
+		case "v": // user variable
+			err = parseWatermarkUserVar(v, wm)
+
 		default:
-			err = parseWatermarkError(onTop)
+			err = parseWatermarkError(wm.onTop)
 		}
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	return wm, nil
+	return nil
 }
 
 func createFontResForWM(xRefTable *XRefTable, wm *Watermark) error {
@@ -534,12 +728,7 @@ func createFontResForWM(xRefTable *XRefTable, wm *Watermark) error {
 
 func createImageResForWM(xRefTable *XRefTable, wm *Watermark) error {
 
-	f := ReadTIFFFile
-	if filepath.Ext(wm.imageFileName) == ".png" {
-		f = ReadPNGFile
-	}
-
-	sd, err := f(xRefTable, wm.imageFileName)
+	sd, err := ReadImageFile(xRefTable, wm.imageFileName)
 	if err != nil {
 		return err
 	}
@@ -561,6 +750,15 @@ func createImageResForWM(xRefTable *XRefTable, wm *Watermark) error {
 
 func createResourcesForWM(xRefTable *XRefTable, wm *Watermark) error {
 
+	if wm.IsQRCode() {
+		return createQRCodeResForWM(xRefTable, wm)
+	}
+
+	if wm.IsBarcode() {
+		// Barcodes are encoded for each page.
+		return nil
+	}
+
 	if wm.IsImage() {
 		return createImageResForWM(xRefTable, wm)
 	}
@@ -570,10 +768,21 @@ func createResourcesForWM(xRefTable *XRefTable, wm *Watermark) error {
 
 // AddWatermarks adds watermarks to all pages selected.
 func AddWatermarks(xRefTable *XRefTable, selectedPages IntSet, wm *Watermark) error {
+	return AddMultipleWatermarks(xRefTable, []IntSet{selectedPages}, []*Watermark{wm})
+}
 
-	err := createOCG(xRefTable, wm)
-	if err != nil {
-		return err
+// AddMultipleWatermarks adds each watermark to its selected pages in one go.
+// All watermarks share one optional content group and so do all stamps.
+func AddMultipleWatermarks(xRefTable *XRefTable, selectedPages []IntSet, wms []*Watermark) error {
+	return addWatermarks(xRefTable, selectedPages, wms, false)
+}
+
+// addWatermarks adds each watermark to its selected pages.
+// Unless merge is true optional content properties already present are an error.
+func addWatermarks(xRefTable *XRefTable, selectedPages []IntSet, wms []*Watermark, merge bool) error {
+
+	if len(wms) == 0 || len(selectedPages) != len(wms) {
+		return errors.New("AddMultipleWatermarks: need one page selection per watermark")
 	}
 
 	rootDict, err := xRefTable.Catalog()
@@ -581,26 +790,62 @@ func AddWatermarks(xRefTable *XRefTable, selectedPages IntSet, wm *Watermark) er
 		return err
 	}
 
-	err = prepareOCPropertiesInRoot(rootDict, wm)
-	if err != nil {
-		return err
+	// Date and time variables resolve to the same time for all pages.
+	now := time.Now()
+
+	ocgs := map[bool]*PDFIndirectRef{}
+	ocgRefs := PDFArray{}
+
+	// Watermarks are background artifacts, only stamps make it into the structure tree.
+	var tags *tagger
+	if xRefTable.TagContent {
+		tags = newTagger(xRefTable)
 	}
 
-	err = createResourcesForWM(xRefTable, wm)
-	if err != nil {
-		return err
+	for _, wm := range wms {
+		ocg, ok := ocgs[wm.onTop]
+		if !ok {
+			if ocg, err = createOCG(xRefTable, wm.onTop); err != nil {
+				return err
+			}
+			ocgs[wm.onTop] = ocg
+			ocgRefs = append(ocgRefs, *ocg)
+		}
+		wm.ocg = ocg
 	}
 
-	err = createExtGStateForStamp(xRefTable, wm)
+	err = prepareOCPropertiesInRoot(xRefTable, rootDict, ocgRefs, wms[0].onTop, merge)
 	if err != nil {
 		return err
 	}
 
-	for k, v := range selectedPages {
-		if v {
-			err := watermarkPage(xRefTable, k, wm)
-			if err != nil {
-				return err
+	for _, wm := range wms {
+
+		err = createResourcesForWM(xRefTable, wm)
+		if err != nil {
+			return err
+		}
+
+		err = createExtGStateForStamp(xRefTable, wm)
+		if err != nil {
+			return err
+		}
+
+		wm.tags = nil
+		if wm.onTop {
+			wm.tags = tags
+		}
+
+		wm.timestamp = now
+	}
+
+	for i, wm := range wms {
+		for k, v := range selectedPages[i] {
+			if v {
+				err := watermarkPage(xRefTable, k, wm)
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -608,11 +853,11 @@ func AddWatermarks(xRefTable *XRefTable, selectedPages IntSet, wm *Watermark) er
 	return nil
 }
 
-func createOCG(xRefTable *XRefTable, wm *Watermark) error {
+func createOCG(xRefTable *XRefTable, onTop bool) (*PDFIndirectRef, error) {
 
 	name := "Background"
 	subt := "BG"
-	if wm.onTop {
+	if onTop {
 		name = "Watermark"
 		subt = "FG"
 	}
@@ -632,17 +877,12 @@ func createOCG(xRefTable *XRefTable, wm *Watermark) error {
 		},
 	}
 
-	indRef, err := xRefTable.IndRefForNewObject(d)
-	if err != nil {
-		return err
-	}
-
-	wm.ocg = indRef
-
-	return nil
+	return xRefTable.IndRefForNewObject(d)
 }
 
-func prepareOCPropertiesInRoot(rootDict *PDFDict, wm *Watermark) error {
+func prepareOCPropertiesInRoot(xRefTable *XRefTable, rootDict *PDFDict, ocgs PDFArray, onTop, merge bool) error {
+
+	refs := func() PDFArray { return append(PDFArray{}, ocgs...) }
 
 	optionalContentConfigDict := PDFDict{
 		Dict: map[string]PDFObject{
@@ -651,25 +891,25 @@ func prepareOCPropertiesInRoot(rootDict *PDFDict, wm *Watermark) error {
 					Dict: map[string]PDFObject{
 						"Category": NewNameArray("View"),
 						"Event":    PDFName("View"),
-						"OCGs":     PDFArray{*wm.ocg},
+						"OCGs":     refs(),
 					},
 				},
 				PDFDict{
 					Dict: map[string]PDFObject{
 						"Category": NewNameArray("Print"),
 						"Event":    PDFName("Print"),
-						"OCGs":     PDFArray{*wm.ocg},
+						"OCGs":     refs(),
 					},
 				},
 				PDFDict{
 					Dict: map[string]PDFObject{
 						"Category": NewNameArray("Export"),
 						"Event":    PDFName("Export"),
-						"OCGs":     PDFArray{*wm.ocg},
+						"OCGs":     refs(),
 					},
 				},
 			},
-			"ON":       PDFArray{*wm.ocg},
+			"ON":       refs(),
 			"Order":    PDFArray{},
 			"RBGroups": PDFArray{},
 		},
@@ -677,7 +917,7 @@ func prepareOCPropertiesInRoot(rootDict *PDFDict, wm *Watermark) error {
 
 	d := PDFDict{
 		Dict: map[string]PDFObject{
-			"OCGs": PDFArray{*wm.ocg},
+			"OCGs": refs(),
 			"D":    optionalContentConfigDict,
 		},
 	}
@@ -688,11 +928,46 @@ func prepareOCPropertiesInRoot(rootDict *PDFDict, wm *Watermark) error {
 		return nil
 	}
 
-	return oneWatermarkOnlyError(wm.onTop)
+	if !merge {
+		return oneWatermarkOnlyError(onTop)
+	}
+
+	// Add the groups to the layers present, visible in the default configuration.
+	l, err := loadLayers(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	if l == nil {
+		rootDict.Update("OCProperties", d)
+		return nil
+	}
+
+	add := func(arr PDFArray) PDFArray { return append(arr, refs()...) }
+
+	l.updateRefArray(l.ocp, "OCGs", add)
+	l.updateRefArray(l.configs[0], "ON", add)
+
+	as, err := xRefTable.DereferenceArray(l.configs[0].Dict["AS"])
+	if err != nil || as == nil {
+		return err
+	}
+
+	for _, o := range *as {
+		if d, err := xRefTable.DereferenceDict(o); err == nil && d != nil {
+			l.updateRefArray(d, "OCGs", add)
+		}
+	}
+
+	return nil
 }
 
 func createFormResDict(xRefTable *XRefTable, wm *Watermark) *PDFDict {
 
+	if wm.IsBarcode() {
+		return &PDFDict{Dict: map[string]PDFObject{"ProcSet": NewNameArray("PDF")}}
+	}
+
 	if wm.IsImage() {
 		return &PDFDict{
 			Dict: map[string]PDFObject{
@@ -726,11 +1001,14 @@ func createForm(xRefTable *XRefTable, wm *Watermark, withBB bool) error {
 
 	if wm.IsImage() {
 		fmt.Fprintf(&b, "q %f 0 0 %f 0 0 cm /Im0 Do Q", bb.Width(), bb.Height())
+	} else if wm.IsBarcode() {
+		b.Write(wm.bcSymbol.content(bb.Width(), bb.Height(), wm.color))
 	} else {
 		// 12 font points result in a vertical displacement of 9.47
 		dy := -float64(wm.fontSize) / 12 * 9.47
 		wmForm := "0 g 0 G 0 i 0 J []0 d 0 j 1 w 10 M 0 Tc 0 Tw 100 Tz 0 TL %d Tr 0 Ts BT /%s %d Tf %f %f %f rg 0 %f Td (%s)Tj ET"
-		fmt.Fprintf(&b, wmForm, wm.renderMode, wm.fontName, wm.fontSize, wm.color.r, wm.color.g, wm.color.b, dy, wm.text)
+		t, _ := Escape(wm.text)
+		fmt.Fprintf(&b, wmForm, wm.renderMode, wm.fontName, wm.fontSize, wm.color.r, wm.color.g, wm.color.b, dy, *t)
 	}
 
 	// Paint bounding box
@@ -752,6 +1030,9 @@ func createForm(xRefTable *XRefTable, wm *Watermark, withBB bool) error {
 				"Matrix":    NewIntegerArray(1, 0, 0, 1, 0, 0),
 				"OC":        *wm.ocg,
 				"Resources": *createFormResDict(xRefTable, wm),
+				// Identifies forms added by pdfcpu for removal.
+				"LastModified": DateStringLiteral(wm.timestamp),
+				"PieceInfo":    stampPieceInfo(wm),
 			},
 		},
 		Content: b.Bytes(),
@@ -780,11 +1061,15 @@ func createExtGStateForStamp(xRefTable *XRefTable, wm *Watermark) error {
 	d := PDFDict{
 		Dict: map[string]PDFObject{
 			"Type": PDFName("ExtGState"),
-			"CA":   PDFFloat(wm.opacity),
+			"CA":   PDFFloat(wm.strokeOpacity),
 			"ca":   PDFFloat(wm.opacity),
 		},
 	}
 
+	if wm.blendMode != "" {
+		d.InsertName("BM", wm.blendMode)
+	}
+
 	indRef, err := xRefTable.IndRefForNewObject(d)
 	if err != nil {
 		return err
@@ -803,72 +1088,107 @@ func rect(xRefTable *XRefTable, rect PDFArray) types.Rectangle {
 	return types.NewRectangle(llx, lly, urx, ury)
 }
 
-func insertPageResourcesForWM(xRefTable *XRefTable, pageDict *PDFDict, wm *Watermark, gsID, xoID string) error {
-
-	resourceDict := PDFDict{
+// wmResources returns the page resources used by wmContent.
+func wmResources(wm *Watermark) *PDFDict {
+	return &PDFDict{
 		Dict: map[string]PDFObject{
-			"ExtGState": PDFDict{Dict: map[string]PDFObject{gsID: *wm.extGState}},
-			"XObject":   PDFDict{Dict: map[string]PDFObject{xoID: *wm.form}},
+			"ExtGState": PDFDict{Dict: map[string]PDFObject{"GS0": *wm.extGState}},
+			"XObject":   PDFDict{Dict: map[string]PDFObject{"Fm0": *wm.form}},
 		},
 	}
+}
 
-	pageDict.Insert("Resources", resourceDict)
+// wmContent returns the content for wm.
+// Unless tagged by the caller the content is marked as a pagination artifact.
+func wmContent(wm *Watermark, artifact bool) []byte {
 
-	return nil
+	m := wm.calcTransformMatrix()
+
+	var b bytes.Buffer
+
+	if artifact {
+		b.WriteString(" /Artifact <</Subtype /Watermark /Type /Pagination >>BDC")
+	}
+
+	for _, t := range wm.tiles(m) {
+		fmt.Fprintf(&b, " q %f %f %f %f %f %f cm /%s gs /%s Do Q", m[0][0], m[0][1], m[1][0], m[1][1], t[0], t[1], "GS0", "Fm0")
+	}
+
+	if artifact {
+		b.WriteString(" EMC")
+	}
+
+	b.WriteString(" ")
+
+	return b.Bytes()
 }
 
-func updatePageResourcesForWM(xRefTable *XRefTable, resDict *PDFDict, wm *Watermark, gsID, xoID *string) error {
+// tiles returns the translations of the forms painted on a page for the transformation m of the form at the watermark position.
+// A tiled watermark gets repeated along the rotated axes of m until the page is covered.
+func (wm *Watermark) tiles(m *matrix) [][2]float64 {
 
-	o, ok := resDict.Find("ExtGState")
-	if !ok {
-		resDict.Insert("ExtGState", PDFDict{Dict: map[string]PDFObject{*gsID: *wm.extGState}})
-	} else {
-		d, _ := xRefTable.DereferenceDict(o)
-		for i := 0; i < 1000; i++ {
-			*gsID = "GS" + strconv.Itoa(i)
-			if _, found := d.Find(*gsID); !found {
-				break
-			}
-		}
-		d.Insert(*gsID, *wm.extGState)
+	if !wm.tile {
+		return [][2]float64{{m[2][0], m[2][1]}}
 	}
-	//println("extGState done")
 
-	o, ok = resDict.Find("XObject")
-	if !ok {
-		resDict.Insert("XObject", PDFDict{Dict: map[string]PDFObject{*xoID: *wm.form}})
-	} else {
-		d, _ := xRefTable.DereferenceDict(o)
-		for i := 0; i < 1000; i++ {
-			*xoID = "Fm" + strconv.Itoa(i)
-			if _, found := d.Find(*xoID); !found {
-				break
+	// The steps between tiles along the rotated x and y axes.
+	sx, sy := wm.bb.Width()+wm.tileDx, wm.bb.Height()+wm.tileDy
+	ux := [2]float64{m[0][0] * sx, m[0][1] * sx}
+	uy := [2]float64{m[1][0] * sy, m[1][1] * sy}
+
+	// Tiles reaching the page lie within the page diagonal of the form at the watermark position.
+	d := math.Hypot(wm.vp.Width(), wm.vp.Height()) + math.Hypot(wm.bb.Width(), wm.bb.Height())
+	nx, ny := int(math.Ceil(d/sx)), int(math.Ceil(d/sy))
+
+	var tt [][2]float64
+
+	for j := -ny; j <= ny; j++ {
+		for i := -nx; i <= nx; i++ {
+
+			x := m[2][0] + float64(i)*ux[0] + float64(j)*uy[0]
+			y := m[2][1] + float64(i)*ux[1] + float64(j)*uy[1]
+
+			// Skip tiles missing the page, the form origin may lie anywhere on its bounding box.
+			cx, cy := x+(m[0][0]*wm.bb.Width()+m[1][0]*wm.bb.Height())/2, y+(m[0][1]*wm.bb.Width()+m[1][1]*wm.bb.Height())/2
+			r := math.Hypot(wm.bb.Width(), wm.bb.Height())
+			if cx+r < 0 || cx-r > wm.vp.Width() || cy+r < 0 || cy-r > wm.vp.Height() {
+				continue
 			}
+
+			tt = append(tt, [2]float64{x, y})
 		}
-		d.Insert(*xoID, *wm.form)
 	}
-	//println("xObject done")
 
-	return nil
+	return tt
 }
 
-func wmContent(wm *Watermark, gsID, xoID string) []byte {
+// structType returns the structure type and the alternate description for tagging a stamp.
+func (wm Watermark) structType() (string, string) {
 
-	m := wm.calcTransformMatrix()
+	if wm.IsQRCode() {
+		url := wm.qrCodeURL
+		if url == "" {
+			url = wm.qrURL
+		}
+		return "Figure", "QR code: " + url
+	}
 
-	insertOCG := " /Artifact <</Subtype /Watermark /Type /Pagination >>BDC q %f %f %f %f %f %f cm /%s gs /%s Do Q EMC "
+	if wm.IsBarcode() {
+		return "Figure", "Barcode: " + wm.bcSymbol.text
+	}
 
-	var b bytes.Buffer
-	fmt.Fprintf(&b, insertOCG, m[0][0], m[0][1], m[1][0], m[1][1], m[2][0], m[2][1], gsID, xoID)
+	if wm.IsImage() {
+		return "Figure", filepath.Base(wm.imageFileName)
+	}
 
-	return b.Bytes()
+	return "P", wm.text
 }
 
-func insertPageContentsForWM(xRefTable *XRefTable, pageDict *PDFDict, wm *Watermark, gsID, xoID string) error {
+func insertPageContentsForWM(xRefTable *XRefTable, pageDict *PDFDict, bb []byte) error {
 
 	sd := &PDFStreamDict{PDFDict: NewPDFDict()}
 
-	sd.Content = wmContent(wm, gsID, xoID)
+	sd.Content = bb
 
 	err := encodeStream(sd)
 	if err != nil {
@@ -885,7 +1205,7 @@ func insertPageContentsForWM(xRefTable *XRefTable, pageDict *PDFDict, wm *Waterm
 	return nil
 }
 
-func updatePageContentsForWM(xRefTable *XRefTable, obj PDFObject, wm *Watermark, gsID, xoID string) error {
+func updatePageContentsForWM(xRefTable *XRefTable, obj PDFObject, wm *Watermark, bb []byte) error {
 
 	var entry *XRefTableEntry
 	var objNr int
@@ -909,7 +1229,7 @@ func updatePageContentsForWM(xRefTable *XRefTable, obj PDFObject, wm *Watermark,
 		//fmt.Printf("%T %T\n", &o, o)
 		//fmt.Printf("Content obj#%d addr:%v\n%s\n", objNr, &o, o)
 
-		err := patchContentForWM(&o, gsID, xoID, wm)
+		err := patchContentForWM(xRefTable, &o, bb, wm)
 		if err != nil {
 			return err
 		}
@@ -938,7 +1258,7 @@ func updatePageContentsForWM(xRefTable *XRefTable, obj PDFObject, wm *Watermark,
 		generationNumber := indRef.GenerationNumber.Value()
 		entry, _ := xRefTable.FindTableEntry(objNr, generationNumber)
 		sd, _ := (entry.Object).(PDFStreamDict)
-		err := patchContentForWM(&sd, gsID, xoID, wm)
+		err := patchContentForWM(xRefTable, &sd, bb, wm)
 		if err != nil {
 			return err
 		}
@@ -970,6 +1290,14 @@ func watermarkPage(xRefTable *XRefTable, i int, wm *Watermark) error {
 	//fmt.Printf("vp = %f %f %f %f\n", vp.Llx, vp.Lly, vp.Urx, vp.Ury)
 	wm.vp = vp
 
+	if wm.IsBarcode() {
+		if err = wm.setBarcodePage(i, xRefTable.PageCount); err != nil {
+			return err
+		}
+	}
+
+	wm.setTextPage(i, xRefTable.PageCount)
+
 	err = createForm(xRefTable, wm, true)
 	if err != nil {
 		return err
@@ -988,30 +1316,38 @@ func watermarkPage(xRefTable *XRefTable, i int, wm *Watermark) error {
 	// }
 	// fmt.Printf("%s\n", *d)
 
-	gsID := "GS0"
-	xoID := "Fm0"
+	bb := wmContent(wm, wm.tags == nil)
 
 	if inhPAttrs.resources == nil {
-		err = insertPageResourcesForWM(xRefTable, d, wm, gsID, xoID)
+		d.Insert("Resources", *wmResources(wm))
 	} else {
-		err = updatePageResourcesForWM(xRefTable, inhPAttrs.resources, wm, &gsID, &xoID)
+		// Rename watermark resources colliding with page resources.
+		renames, err := mergeResources(xRefTable, inhPAttrs.resources, wmResources(wm))
+		if err != nil {
+			return err
+		}
+		bb = renameResourceNames(bb, renames)
 	}
-	if err != nil {
-		return err
+
+	if wm.tags != nil {
+		typ, alt := wm.structType()
+		if bb, err = wm.tags.markContent(i, d, typ, encodeText(alt), bb); err != nil {
+			return err
+		}
 	}
 
 	obj, found := d.Find("Contents")
 	if !found {
-		return insertPageContentsForWM(xRefTable, d, wm, gsID, xoID)
+		return insertPageContentsForWM(xRefTable, d, bb)
 	}
 
-	return updatePageContentsForWM(xRefTable, obj, wm, gsID, xoID)
+	return updatePageContentsForWM(xRefTable, obj, wm, bb)
 }
 
-func patchContentForWM(sd *PDFStreamDict, gsID, xoID string, wm *Watermark) error {
+func patchContentForWM(xRefTable *XRefTable, sd *PDFStreamDict, bb []byte, wm *Watermark) error {
 
 	// Decode streamDict for supported filters only.
-	err := decodeStream(sd)
+	err := xRefTable.decodeStream(sd)
 	if err == filter.ErrUnsupportedFilter {
 		fmt.Println("unsupported filter")
 		return nil
@@ -1020,8 +1356,6 @@ func patchContentForWM(sd *PDFStreamDict, gsID, xoID string, wm *Watermark) erro
 		return err
 	}
 
-	bb := wmContent(wm, gsID, xoID)
-
 	if wm.onTop {
 		sd.Content = append(sd.Content, bb...)
 	} else {
@@ -0,0 +1,369 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Removable stamps
+//
+// The forms of stamps and watermarks carry a page-piece dict (14.5) with an entry for pdfcpu
+// whose private data is the kind of form: Stamp or Watermark.
+// Removing stamps drops all marked-content sequences painting nothing but these forms,
+// which covers the artifact and structure wrappers written along with them, and any other invocations of these forms.
+// Structure elements of removed marked content, unused resources and optional content groups go as well.
+
+const stampPieceKey = "pdfcpu"
+
+// stampKind returns the private data of the page-piece dict identifying the forms of stamps or watermarks.
+func stampKind(onTop bool) string {
+	if onTop {
+		return "Stamp"
+	}
+	return "Watermark"
+}
+
+// stampPieceInfo returns the page-piece dict of the forms of wm.
+func stampPieceInfo(wm *Watermark) PDFDict {
+	return PDFDict{
+		Dict: map[string]PDFObject{
+			stampPieceKey: PDFDict{
+				Dict: map[string]PDFObject{
+					"LastModified": DateStringLiteral(wm.timestamp),
+					"Private":      PDFName(stampKind(wm.onTop)),
+				},
+			},
+		},
+	}
+}
+
+// stampRemover removes the forms of one kind added by pdfcpu from content.
+type stampRemover struct {
+	xRefTable *XRefTable
+	kind      string // Stamp or Watermark
+	ocgs      IntSet // optional content groups of the forms found
+}
+
+// form returns true if the XObject name is a form of kind r.kind.
+func (r *stampRemover) form(resources *PDFDict, name string) (bool, error) {
+
+	if resources == nil {
+		return false, nil
+	}
+
+	d, err := r.xRefTable.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || d == nil {
+		return false, err
+	}
+
+	indRef, ok := d.Dict[name].(PDFIndirectRef)
+	if !ok {
+		return false, nil
+	}
+
+	sd, err := r.xRefTable.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return false, err
+	}
+
+	pi, err := r.xRefTable.DereferenceDict(sd.Dict["PieceInfo"])
+	if err != nil || pi == nil {
+		return false, err
+	}
+
+	data, err := r.xRefTable.DereferenceDict(pi.Dict[stampPieceKey])
+	if err != nil || data == nil {
+		return false, err
+	}
+
+	if kind := data.NameEntry("Private"); kind == nil || *kind != r.kind {
+		return false, nil
+	}
+
+	if oc, ok := sd.Dict["OC"].(PDFIndirectRef); ok {
+		r.ocgs[oc.ObjectNumber.Value()] = true
+	}
+
+	return true, nil
+}
+
+// content returns bb without the forms of kind r.kind along with the MCIDs of removed marked content.
+func (r *stampRemover) content(bb []byte, resources *PDFDict) ([]byte, []int, bool, error) {
+
+	bb = compactHexStrings(bb)
+
+	type span struct {
+		start, end int
+	}
+
+	// A marked-content sequence along with the spans to remove unless the sequence goes as a whole.
+	type seq struct {
+		start int
+		mcid  int
+		stamp bool // paints forms of r.kind
+		other bool // paints anything else
+		spans []span
+	}
+
+	seqs := []*seq{{mcid: -1}}
+	var mcids []int
+
+	err := scanContent(bb, func(op string, operands []PDFObject, start, end int) error {
+
+		s := seqs[len(seqs)-1]
+
+		switch op {
+
+		case "BMC", "BDC":
+			mcid := -1
+			if len(operands) == 2 {
+				if d, ok := operands[1].(PDFDict); ok {
+					if i := d.IntEntry("MCID"); i != nil {
+						mcid = *i
+					}
+				}
+			}
+			seqs = append(seqs, &seq{start: start, mcid: mcid})
+
+		case "EMC":
+			if len(seqs) == 1 {
+				s.other = true
+				break
+			}
+			seqs = seqs[:len(seqs)-1]
+			p := seqs[len(seqs)-1]
+			if s.stamp && !s.other {
+				p.spans = append(p.spans, span{s.start, end})
+				p.stamp = true
+				if s.mcid >= 0 {
+					mcids = append(mcids, s.mcid)
+				}
+				break
+			}
+			p.spans = append(p.spans, s.spans...)
+			p.stamp = p.stamp || s.stamp
+			p.other = p.other || s.other
+
+		case "q", "Q", "cm", "gs":
+
+		case "Do":
+			if len(operands) == 1 {
+				if name, ok := operands[0].(PDFName); ok {
+					stamp, err := r.form(resources, name.Value())
+					if err != nil {
+						return err
+					}
+					if stamp {
+						s.stamp = true
+						s.spans = append(s.spans, span{start, end})
+						break
+					}
+				}
+			}
+			s.other = true
+
+		default:
+			s.other = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// Sequences left open keep their marks.
+	for i := len(seqs) - 1; i > 0; i-- {
+		seqs[i-1].spans = append(seqs[i-1].spans, seqs[i].spans...)
+	}
+
+	spans := seqs[0].spans
+	if len(spans) == 0 {
+		return bb, nil, false, nil
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b bytes.Buffer
+	var last int
+
+	for _, sp := range spans {
+		b.Write(bb[last:sp.start])
+		b.WriteByte(' ')
+		last = sp.end
+	}
+
+	b.Write(bb[last:])
+
+	return b.Bytes(), mcids, true, nil
+}
+
+// removeWatermarks removes the stamps or watermarks added by pdfcpu from the selected pages
+// and returns the number of pages affected.
+func removeWatermarks(xRefTable *XRefTable, selectedPages IntSet, onTop bool) (int, error) {
+
+	kind := stampKind(onTop)
+
+	removed := &stampRemover{xRefTable: xRefTable, kind: kind, ocgs: IntSet{}}
+	kept := &stampRemover{xRefTable: xRefTable, kind: kind, ocgs: IntSet{}}
+
+	tags := newTagger(xRefTable)
+
+	var n int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, inhPAttrs, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		bb, err := pageContent(xRefTable, pageDict, true)
+		if err != nil {
+			return 0, err
+		}
+
+		if !selectedPages[i] {
+			// Look for forms staying in use.
+			if _, _, _, err = kept.content(bb, inhPAttrs.resources); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		bb, mcids, changed, err := removed.content(bb, inhPAttrs.resources)
+		if err != nil {
+			return 0, err
+		}
+
+		if !changed {
+			continue
+		}
+
+		sd := &PDFStreamDict{
+			PDFDict:        NewPDFDict(),
+			Content:        bb,
+			FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+		sd.InsertName("Filter", filter.Flate)
+
+		if err = encodeStream(sd); err != nil {
+			return 0, err
+		}
+
+		indRef, err := xRefTable.IndRefForNewObject(*sd)
+		if err != nil {
+			return 0, err
+		}
+
+		pageDict.Update("Contents", *indRef)
+
+		if err = tags.unmarkContent(pageDict, mcids); err != nil {
+			return 0, err
+		}
+
+		n++
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	if _, err := pruneResources(xRefTable); err != nil {
+		return 0, err
+	}
+
+	// Drop the optional content groups no longer in use.
+	m := IntSet{}
+	for objNr := range removed.ocgs {
+		if !kept.ocgs[objNr] {
+			m[objNr] = true
+		}
+	}
+
+	if len(m) > 0 {
+		l, err := loadLayers(xRefTable)
+		if err != nil {
+			return 0, err
+		}
+		if l != nil {
+			if err = l.forget(m); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	log.Debug.Printf("removeWatermarks: %d pages\n", n)
+
+	return n, nil
+}
+
+// RemoveWatermarks removes the stamps (onTop) or watermarks added by pdfcpu from the selected pages.
+func RemoveWatermarks(xRefTable *XRefTable, selectedPages IntSet, onTop bool) error {
+
+	n, err := removeWatermarks(xRefTable, selectedPages, onTop)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errors.Errorf("no %ss found", onTopString(onTop))
+	}
+
+	return nil
+}
+
+// UpdateWatermarks replaces the stamps or watermarks added by pdfcpu on the selected pages of each watermark.
+// Pages without stamps or watermarks just get them added.
+func UpdateWatermarks(xRefTable *XRefTable, selectedPages []IntSet, wms []*Watermark) error {
+
+	if len(wms) == 0 || len(selectedPages) != len(wms) {
+		return errors.New("UpdateWatermarks: need one page selection per watermark")
+	}
+
+	// Remove each kind from all pages it is about to be applied to.
+	pages := map[bool]IntSet{}
+
+	for i, wm := range wms {
+		if pages[wm.onTop] == nil {
+			pages[wm.onTop] = IntSet{}
+		}
+		for k, v := range selectedPages[i] {
+			if v {
+				pages[wm.onTop][k] = true
+			}
+		}
+	}
+
+	for onTop, m := range pages {
+		if _, err := removeWatermarks(xRefTable, m, onTop); err != nil {
+			return err
+		}
+	}
+
+	return addWatermarks(xRefTable, selectedPages, wms, true)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Stamp variables
+//
+// The text of stamps and watermarks as well as barcode data may contain variables
+// which get resolved for each page stamped. %% stands for a literal %.
+
+// Variables of stamp texts and barcode data.
+const (
+	PageNrVar    = "%p" // page number
+	PageCountVar = "%P" // page count
+	FileNameVar  = "%f" // file name of the document stamped
+	DateVar      = "%d" // date of stamping: yyyy-mm-dd
+	TimeVar      = "%t" // time of stamping: hh:mm
+	UserVar      = "%u" // user variable followed by its name in braces, eg. %u{dept}
+)
+
+// hasVars returns true if s contains any variables.
+func hasVars(s string) bool {
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '%' && strings.IndexByte("pPfdtu%", s[i+1]) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// userVarName returns the name of the user variable starting at s[i] following %u and its length including the braces.
+func userVarName(s string, i int) (string, int) {
+	if i >= len(s) || s[i] != '{' {
+		return "", 0
+	}
+	j := strings.IndexByte(s[i:], '}')
+	if j < 0 {
+		return "", 0
+	}
+	return s[i+1 : i+j], j + 1
+}
+
+// checkUserVars returns an error if s refers to undefined user variables.
+func (wm Watermark) checkUserVars(s string) error {
+
+	for i := 0; i < len(s)-1; i++ {
+
+		if s[i] != '%' {
+			continue
+		}
+
+		i++
+
+		if s[i] != 'u' {
+			continue
+		}
+
+		name, n := userVarName(s, i+1)
+		if n == 0 {
+			return errors.Errorf("user variable without {name}: %s", s)
+		}
+
+		if _, ok := wm.userVars[name]; !ok {
+			return errors.Errorf("undefined user variable: %s, use v:%s=value", name, name)
+		}
+
+		i += n
+	}
+
+	return nil
+}
+
+// resolveVars replaces the variables of s for page pageNr of a document with pageCount pages.
+func (wm Watermark) resolveVars(s string, pageNr, pageCount int) string {
+
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+
+		if s[i] != '%' || i == len(s)-1 {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i++
+
+		switch s[i] {
+		case 'p':
+			sb.WriteString(strconv.Itoa(pageNr))
+		case 'P':
+			sb.WriteString(strconv.Itoa(pageCount))
+		case 'f':
+			sb.WriteString(wm.fileName)
+		case 'd':
+			sb.WriteString(wm.timestamp.Format("2006-01-02"))
+		case 't':
+			sb.WriteString(wm.timestamp.Format("15:04"))
+		case 'u':
+			name, n := userVarName(s, i+1)
+			if n == 0 {
+				sb.WriteString(UserVar)
+				continue
+			}
+			sb.WriteString(wm.userVars[name])
+			i += n
+		case '%':
+			sb.WriteByte('%')
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(s[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// parseWatermarkUserVar parses a user variable definition: name=value.
+func parseWatermarkUserVar(v string, wm *Watermark) error {
+
+	i := strings.Index(v, "=")
+	if i <= 0 {
+		return errors.Errorf("user variable: name=value expected: %s", v)
+	}
+
+	if wm.userVars == nil {
+		wm.userVars = map[string]string{}
+	}
+
+	wm.userVars[strings.TrimSpace(v[:i])] = strings.TrimSpace(v[i+1:])
+
+	return nil
+}
+
+// SetFileName sets the file name resolving FileNameVar.
+func (wm *Watermark) SetFileName(fileName string) {
+	wm.fileName = fileName
+}
+
+// setTextPage resolves the variables of the watermark text for a page.
+func (wm *Watermark) setTextPage(pageNr, pageCount int) {
+
+	if len(wm.textTemplate) == 0 {
+		return
+	}
+
+	text := wm.resolveVars(wm.textTemplate, pageNr, pageCount)
+	if text == wm.text {
+		return
+	}
+
+	wm.text = text
+
+	// Forms depend on the text now.
+	wm.fCache = formCache{}
+}
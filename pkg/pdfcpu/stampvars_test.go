@@ -0,0 +1,59 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStampVars(t *testing.T) {
+
+	wm, err := ParseWatermarkDetails("%u{dept}: page %p of %P - %f %d %t 100%% %x, v:dept=Sales", true)
+	if err != nil {
+		t.Fatalf("TestStampVars: %v\n", err)
+	}
+
+	wm.SetFileName("in.pdf")
+	wm.timestamp = time.Date(2018, 7, 4, 9, 5, 0, 0, time.UTC)
+
+	want := "Sales: page 3 of 12 - in.pdf 2018-07-04 09:05 100% %x"
+	if got := wm.resolveVars(wm.textTemplate, 3, 12); got != want {
+		t.Errorf("TestStampVars:\ngot  %s\nwant %s\n", got, want)
+	}
+
+	wm.setTextPage(3, 12)
+	wm.fCache[wm.bb] = &PDFIndirectRef{}
+	wm.setTextPage(3, 12)
+	if len(wm.fCache) != 1 {
+		t.Errorf("TestStampVars: form cache reset for unchanged text\n")
+	}
+	wm.setTextPage(4, 12)
+	if len(wm.fCache) != 0 || wm.text != "Sales: page 4 of 12 - in.pdf 2018-07-04 09:05 100% %x" {
+		t.Errorf("TestStampVars: page 4: %s\n", wm.text)
+	}
+
+	for _, s := range []string{"%u{dept}", "%u", "%u{dept, v:dept=Sales", "%u{x}, v:dept=Sales", "%u{x}, v:=x"} {
+		if _, err := ParseWatermarkDetails(s, true); err == nil {
+			t.Errorf("TestStampVars: %s: should have failed\n", s)
+		}
+	}
+
+	if _, err := ParseBarcodeStampDetails("code128:%u{nr}-%p, v:nr=4711"); err != nil {
+		t.Errorf("TestStampVars: barcode: %v\n", err)
+	}
+}
@@ -45,6 +45,8 @@ const (
 	RootRequirements
 	RootCollection
 	RootNeedsRendering
+	RootAF
+	RootDPartRoot
 )
 
 // The PDF page object fields.
@@ -77,6 +79,9 @@ const (
 	PagePresSteps
 	PageUserUnit
 	PageVP
+	PageAF
+	PageOutputIntents
+	PageDPart
 )
 
 // PDFStats is a container for stats.
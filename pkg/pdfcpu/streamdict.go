@@ -92,6 +92,9 @@ func (oStreamDict *PDFObjectStreamDict) IndexedObject(index int) (PDFObject, err
 	if oStreamDict.ObjArray == nil {
 		return nil, errors.Errorf("IndexedObject(%d): object not available", index)
 	}
+	if index < 0 || index >= len(oStreamDict.ObjArray) {
+		return nil, errors.Errorf("IndexedObject(%d): index out of range", index)
+	}
 	return oStreamDict.ObjArray[index], nil
 }
 
@@ -137,6 +140,9 @@ func (oStreamDict *PDFObjectStreamDict) AddObject(objNumber int, entry *XRefTabl
 	case PDFName:
 		pdfString = obj.PDFString()
 
+	case nil:
+		pdfString = "null"
+
 	default:
 		return errors.Errorf("AddObject: undefined PDF object #%d\n", objNumber)
 
@@ -0,0 +1,610 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// StructNode represents a structure element of the logical structure of a tagged PDF, see 14.7.
+// Nodes without type represent the text of marked content belonging to their parent element.
+type StructNode struct {
+	Type          string        `json:"type,omitempty"` // standard structure type
+	Role          string        `json:"role,omitempty"` // structure type used by the document if mapped by the role map
+	Page          int           `json:"page,omitempty"`
+	Title         string        `json:"title,omitempty"`
+	Lang          string        `json:"lang,omitempty"`
+	Alt           string        `json:"alt,omitempty"`
+	ActualText    string        `json:"actualText,omitempty"`
+	ListNumbering string        `json:"listNumbering,omitempty"`
+	URI           string        `json:"uri,omitempty"` // link target
+	Text          string        `json:"text,omitempty"`
+	Kids          []*StructNode `json:"kids,omitempty"`
+}
+
+// structTreeReader builds StructNodes from the structure tree of a document.
+type structTreeReader struct {
+	ctx     *PDFContext
+	pages   map[int]int // page number by page dict object number
+	roleMap map[string]string
+	texts   map[int]map[int]string // marked content text by page and MCID
+	visited map[int]bool
+}
+
+func (r *structTreeReader) text(o PDFObject) string {
+	if o == nil {
+		return ""
+	}
+	s, err := textString(r.ctx, o)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+func (r *structTreeReader) name(o PDFObject) string {
+	o, err := r.ctx.Dereference(o)
+	if err != nil {
+		return ""
+	}
+	n, ok := o.(PDFName)
+	if !ok {
+		return ""
+	}
+	return n.Value()
+}
+
+func (r *structTreeReader) pageNr(o PDFObject) int {
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		return r.pages[indRef.ObjectNumber.Value()]
+	}
+	return 0
+}
+
+// standardType resolves the structure type s by the role map.
+func (r *structTreeReader) standardType(s string) string {
+
+	// Guard against circular mappings.
+	for i := 0; i < 10; i++ {
+		t, found := r.roleMap[s]
+		if !found || t == s {
+			break
+		}
+		s = t
+	}
+
+	return s
+}
+
+// markedContent returns the text of the marked-content sequence mcid of a page.
+func (r *structTreeReader) markedContent(page, mcid int) string {
+
+	if page == 0 {
+		return ""
+	}
+
+	texts, found := r.texts[page]
+	if !found {
+		var err error
+		if texts, err = pageMarkedContentText(r.ctx.XRefTable, page); err != nil {
+			// Keep the structure even if the content can't be interpreted.
+			log.Info.Printf("structure tree: page %d: %v\n", page, err)
+		}
+		r.texts[page] = texts
+	}
+
+	return texts[mcid]
+}
+
+// addText adds the text of marked content to n.
+func (r *structTreeReader) addText(n *StructNode, page, mcid int) {
+
+	s := r.markedContent(page, mcid)
+	if s == "" {
+		return
+	}
+
+	// Merge adjacent pieces of marked content.
+	if l := len(n.Kids); l > 0 && n.Kids[l-1].Type == "" {
+		n.Kids[l-1].Text += " " + s
+		return
+	}
+
+	n.Kids = append(n.Kids, &StructNode{Page: page, Text: s})
+}
+
+// annotation handles an object reference to an annotation owned by n.
+func (r *structTreeReader) annotation(n *StructNode, d *PDFDict) {
+
+	annot, err := r.ctx.DereferenceDict(d.Dict["Obj"])
+	if err != nil || annot == nil {
+		return
+	}
+
+	if n.URI == "" {
+		if a, _ := r.ctx.DereferenceDict(annot.Dict["A"]); a != nil && r.name(a.Dict["S"]) == "URI" {
+			n.URI = r.text(a.Dict["URI"])
+		}
+	}
+
+	if n.Alt == "" {
+		n.Alt = r.text(annot.Dict["Contents"])
+	}
+}
+
+// listNumbering returns the ListNumbering attribute of the list attributes of a structure element.
+func (r *structTreeReader) listNumbering(d *PDFDict) string {
+
+	o, err := r.ctx.Dereference(d.Dict["A"])
+	if err != nil || o == nil {
+		return ""
+	}
+
+	arr, ok := o.(PDFArray)
+	if !ok {
+		arr = PDFArray{o}
+	}
+
+	for _, o := range arr {
+		a, err := r.ctx.DereferenceDict(o)
+		if err != nil || a == nil {
+			continue
+		}
+		if s := r.name(a.Dict["ListNumbering"]); s != "" {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// kids adds the content items o of a structure element to n.
+func (r *structTreeReader) kids(n *StructNode, o PDFObject, page int) {
+
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		objNr := indRef.ObjectNumber.Value()
+		if r.visited[objNr] {
+			return
+		}
+		r.visited[objNr] = true
+	}
+
+	o, err := r.ctx.Dereference(o)
+	if err != nil || o == nil {
+		return
+	}
+
+	switch o := o.(type) {
+
+	case PDFArray:
+		for _, o1 := range o {
+			r.kids(n, o1, page)
+		}
+
+	case PDFInteger:
+		r.addText(n, page, o.Value())
+
+	case PDFDict:
+		t := o.Type()
+
+		if t != nil && *t == "MCR" {
+			// Marked content of form XObjects is not supported.
+			if _, found := o.Find("Stm"); found {
+				return
+			}
+			if pg := r.pageNr(o.Dict["Pg"]); pg > 0 {
+				page = pg
+			}
+			if mcid := o.IntEntry("MCID"); mcid != nil {
+				r.addText(n, page, *mcid)
+			}
+			return
+		}
+
+		if t != nil && *t == "OBJR" {
+			r.annotation(n, &o)
+			return
+		}
+
+		if e := r.elem(&o, page); e != nil {
+			n.Kids = append(n.Kids, e)
+		}
+	}
+}
+
+// elem returns the StructNode for a structure element.
+func (r *structTreeReader) elem(d *PDFDict, page int) *StructNode {
+
+	typ := r.name(d.Dict["S"])
+	if typ == "" {
+		return nil
+	}
+
+	if pg := r.pageNr(d.Dict["Pg"]); pg > 0 {
+		page = pg
+	}
+
+	n := &StructNode{
+		Type:       r.standardType(typ),
+		Page:       page,
+		Title:      r.text(d.Dict["T"]),
+		Lang:       r.text(d.Dict["Lang"]),
+		Alt:        r.text(d.Dict["Alt"]),
+		ActualText: r.text(d.Dict["ActualText"]),
+	}
+
+	if n.Type != typ {
+		n.Role = typ
+	}
+
+	if n.Type == "L" {
+		n.ListNumbering = r.listNumbering(d)
+	}
+
+	r.kids(n, d.Dict["K"], page)
+
+	return n
+}
+
+// StructTree returns the logical structure of a tagged PDF or nil if there is no structure tree.
+func StructTree(ctx *PDFContext) ([]*StructNode, error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := ctx.DereferenceDict(rootDict.Dict["StructTreeRoot"])
+	if err != nil || root == nil {
+		return nil, err
+	}
+
+	r := &structTreeReader{
+		ctx:     ctx,
+		pages:   map[int]int{},
+		roleMap: map[string]string{},
+		texts:   map[int]map[int]string{},
+		visited: map[int]bool{},
+	}
+
+	for i := 1; i <= ctx.PageCount; i++ {
+		indRef, err := ctx.PageDictIndRef(i)
+		if err != nil {
+			return nil, err
+		}
+		if indRef != nil {
+			r.pages[indRef.ObjectNumber.Value()] = i
+		}
+	}
+
+	if d, _ := ctx.DereferenceDict(root.Dict["RoleMap"]); d != nil {
+		for k, v := range d.Dict {
+			if s := r.name(v); s != "" {
+				r.roleMap[k] = s
+			}
+		}
+	}
+
+	top := &StructNode{}
+	r.kids(top, root.Dict["K"], 0)
+
+	// Drop text not owned by any structure element.
+	var nodes []*StructNode
+	for _, n := range top.Kids {
+		if n.Type != "" {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes, nil
+}
+
+func (n *StructNode) lines(ss []string, indent string) []string {
+
+	if n.Type == "" {
+		return append(ss, fmt.Sprintf("%s%q", indent, n.Text))
+	}
+
+	s := indent + n.Type
+	if n.Role != "" {
+		s += " (" + n.Role + ")"
+	}
+	if n.Page > 0 {
+		s += fmt.Sprintf(" page %d", n.Page)
+	}
+	for _, attr := range []struct{ k, v string }{
+		{"title", n.Title}, {"lang", n.Lang}, {"alt", n.Alt}, {"actualText", n.ActualText}, {"uri", n.URI},
+	} {
+		if attr.v != "" {
+			s += fmt.Sprintf(" %s=%q", attr.k, attr.v)
+		}
+	}
+	ss = append(ss, s)
+
+	for _, k := range n.Kids {
+		ss = k.lines(ss, indent+"  ")
+	}
+
+	return ss
+}
+
+// ListStructTree returns the logical structure of a tagged PDF as indented list of structure elements and their text.
+func ListStructTree(ctx *PDFContext) ([]string, error) {
+
+	nodes, err := StructTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if nodes == nil {
+		return nil, errors.New("no structure tree available")
+	}
+
+	var ss []string
+	for _, n := range nodes {
+		ss = n.lines(ss, "")
+	}
+
+	return ss, nil
+}
+
+// ExportStructTreeJSON returns the logical structure of a tagged PDF as JSON.
+func ExportStructTreeJSON(ctx *PDFContext) ([]byte, error) {
+
+	nodes, err := StructTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if nodes == nil {
+		return nil, errors.New("no structure tree available")
+	}
+
+	return json.MarshalIndent(nodes, "", "\t")
+}
+
+// htmlBlockTags maps standard structure types to HTML block elements.
+var htmlBlockTags = map[string]string{
+	"Document":   "div",
+	"Part":       "div",
+	"Art":        "article",
+	"Sect":       "section",
+	"Div":        "div",
+	"BlockQuote": "blockquote",
+	"Caption":    "figcaption",
+	"TOC":        "ul",
+	"TOCI":       "li",
+	"Index":      "div",
+	"NonStruct":  "div",
+	"Private":    "div",
+	"P":          "p",
+	"H1":         "h1",
+	"H2":         "h2",
+	"H3":         "h3",
+	"H4":         "h4",
+	"H5":         "h5",
+	"H6":         "h6",
+	"L":          "ul",
+	"LI":         "li",
+	"LBody":      "div",
+	"Table":      "table",
+	"THead":      "thead",
+	"TBody":      "tbody",
+	"TFoot":      "tfoot",
+	"TR":         "tr",
+	"TH":         "th",
+	"TD":         "td",
+	"Figure":     "figure",
+	"Note":       "aside",
+}
+
+// htmlInlineTags maps standard structure types to HTML inline elements.
+var htmlInlineTags = map[string]string{
+	"Span":      "span",
+	"Quote":     "q",
+	"Code":      "code",
+	"Link":      "a",
+	"Lbl":       "span",
+	"Reference": "span",
+	"BibEntry":  "span",
+	"Annot":     "span",
+	"Form":      "span",
+	"Formula":   "span",
+	"Ruby":      "ruby",
+	"RB":        "rb",
+	"RT":        "rt",
+	"RP":        "rp",
+}
+
+// htmlWriter renders StructNodes as HTML.
+type htmlWriter struct {
+	b     bytes.Buffer
+	depth int // section nesting level for headings of type H
+}
+
+func (w *htmlWriter) tag(n *StructNode, parent string) string {
+
+	if n.Type == "H" {
+		return fmt.Sprintf("h%d", min(w.depth+1, 6))
+	}
+
+	if n.Type == "Caption" && parent == "Table" {
+		return "caption"
+	}
+
+	if n.Type == "L" && n.ListNumbering != "" && n.ListNumbering != "None" &&
+		n.ListNumbering != "Disc" && n.ListNumbering != "Circle" && n.ListNumbering != "Square" {
+		return "ol"
+	}
+
+	if s, found := htmlBlockTags[n.Type]; found {
+		return s
+	}
+
+	if s, found := htmlInlineTags[n.Type]; found {
+		return s
+	}
+
+	// Unknown types
+	for _, k := range n.Kids {
+		if k.Type != "" {
+			return "div"
+		}
+	}
+
+	return "span"
+}
+
+// empty returns true if n neither holds nor describes any content.
+func (n *StructNode) empty() bool {
+
+	if n.Text != "" || n.Alt != "" || n.ActualText != "" {
+		return false
+	}
+
+	for _, k := range n.Kids {
+		if !k.empty() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (w *htmlWriter) write(n *StructNode, parent string) {
+
+	if n.Type == "" {
+		w.b.WriteString(html.EscapeString(n.Text))
+		return
+	}
+
+	// Table cells keep the table layout intact.
+	if n.empty() && n.Type != "TD" && n.Type != "TH" && n.Type != "TR" {
+		return
+	}
+
+	tag := w.tag(n, parent)
+	_, block := htmlBlockTags[n.Type]
+
+	w.b.WriteString("<" + tag)
+	if n.Lang != "" {
+		fmt.Fprintf(&w.b, " lang=\"%s\"", html.EscapeString(n.Lang))
+	}
+	if n.Title != "" {
+		fmt.Fprintf(&w.b, " title=\"%s\"", html.EscapeString(n.Title))
+	}
+	if tag == "a" && n.URI != "" {
+		fmt.Fprintf(&w.b, " href=\"%s\"", html.EscapeString(n.URI))
+	}
+	w.b.WriteString(">")
+
+	if n.Type == "Sect" || n.Type == "Part" || n.Type == "Art" {
+		w.depth++
+		defer func() { w.depth-- }()
+	}
+
+	switch {
+
+	case n.ActualText != "":
+		w.b.WriteString(html.EscapeString(n.ActualText))
+
+	case n.Type == "Figure" && n.Alt != "":
+		fmt.Fprintf(&w.b, "<figcaption>%s</figcaption>", html.EscapeString(n.Alt))
+
+	default:
+		for i, k := range n.Kids {
+			if i > 0 && k.Type == "" && n.Kids[i-1].Type == "" {
+				w.b.WriteByte(' ')
+			}
+			w.write(k, n.Type)
+		}
+
+	}
+
+	w.b.WriteString("</" + tag + ">")
+	if block {
+		w.b.WriteByte('\n')
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// documentTitle returns the title of a document taken from the XMP metadata or the document info dict.
+func documentTitle(ctx *PDFContext) string {
+
+	if x, err := ReadXMP(ctx.XRefTable); err == nil && x != nil {
+		if s := x.Property("dc:title"); s != "" {
+			return s
+		}
+	}
+
+	if ctx.Info == nil {
+		return ""
+	}
+
+	d, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil || d == nil {
+		return ""
+	}
+
+	s, _ := textString(ctx, d.Dict["Title"])
+
+	return s
+}
+
+// ExportStructTreeHTML returns the logical structure of a tagged PDF as simple reflowable HTML.
+// Figures are represented by their alternate description.
+func ExportStructTreeHTML(ctx *PDFContext) ([]byte, error) {
+
+	nodes, err := StructTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if nodes == nil {
+		return nil, errors.New("no structure tree available")
+	}
+
+	w := &htmlWriter{}
+
+	w.b.WriteString("<!DOCTYPE html>\n<html")
+	if s, _ := textString(ctx, ctx.RootDict.Dict["Lang"]); s != "" {
+		fmt.Fprintf(&w.b, " lang=\"%s\"", html.EscapeString(s))
+	}
+	w.b.WriteString(">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&w.b, "<title>%s</title>\n", html.EscapeString(documentTitle(ctx)))
+	w.b.WriteString("</head>\n<body>\n")
+
+	for _, n := range nodes {
+		w.write(n, "")
+	}
+
+	w.b.WriteString("</body>\n</html>\n")
+
+	return w.b.Bytes(), nil
+}
@@ -0,0 +1,729 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// tagger hooks content generated by pdfcpu into the structure tree of a document, see 14.7 and 14.8.
+// Marked content is registered in the parent tree under the StructParents key of its page,
+// annotations are referenced by object reference dicts and registered under their StructParent key.
+type tagger struct {
+	xRefTable  *XRefTable
+	root       *PDFDict       // structure tree root
+	parent     PDFIndirectRef // structure element new elements are appended to
+	parentTree *PDFDict       // number tree root
+	nextKey    int
+}
+
+// newTagger returns a tagger for xRefTable.
+func newTagger(xRefTable *XRefTable) *tagger {
+	return &tagger{xRefTable: xRefTable}
+}
+
+// ensureStructTree locates the structure tree and creates it if necessary.
+// A newly created structure tree does not mark the document as tagged,
+// since it only covers the generated content.
+func (t *tagger) ensureStructTree() error {
+
+	if t.root != nil {
+		return nil
+	}
+
+	rootDict, err := t.xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	obj, found := rootDict.Find("StructTreeRoot")
+	if !found || obj == nil {
+		return t.createStructTree(rootDict)
+	}
+
+	rootRef, ok := obj.(PDFIndirectRef)
+	if !ok {
+		// Structure elements refer to their parent by indirect reference.
+		indRef, err := t.xRefTable.IndRefForNewObject(obj)
+		if err != nil {
+			return err
+		}
+		rootDict.Update("StructTreeRoot", *indRef)
+		rootRef = *indRef
+	}
+
+	root, err := t.xRefTable.DereferenceDict(rootRef)
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		return errors.New("tagger: corrupt StructTreeRoot")
+	}
+	t.root = root
+
+	if err = t.locateParent(rootRef); err != nil {
+		return err
+	}
+
+	return t.locateParentTree()
+}
+
+func (t *tagger) createStructTree(rootDict *PDFDict) error {
+
+	parentTree, err := t.xRefTable.IndRefForNewObject(PDFDict{Dict: map[string]PDFObject{"Nums": PDFArray{}}})
+	if err != nil {
+		return err
+	}
+
+	root := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type":       PDFName("StructTreeRoot"),
+			"ParentTree": *parentTree,
+		},
+	}
+
+	rootRef, err := t.xRefTable.IndRefForNewObject(root)
+	if err != nil {
+		return err
+	}
+
+	doc, err := t.xRefTable.IndRefForNewObject(PDFDict{
+		Dict: map[string]PDFObject{
+			"Type": PDFName("StructElem"),
+			"S":    PDFName("Document"),
+			"P":    *rootRef,
+			"K":    PDFArray{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	root.Insert("K", *doc)
+	rootDict.Insert("StructTreeRoot", *rootRef)
+
+	t.root = &root
+	t.parent = *doc
+	t.parentTree, err = t.xRefTable.DereferenceDict(*parentTree)
+
+	return err
+}
+
+// locateParent picks the structure element new elements are appended to.
+// This is the single top level element, usually Document, if there is one or else the structure tree root.
+func (t *tagger) locateParent(rootRef PDFIndirectRef) error {
+
+	t.parent = rootRef
+
+	o, err := t.xRefTable.Dereference(t.root.Dict["K"])
+	if err != nil {
+		return err
+	}
+
+	if arr, ok := o.(PDFArray); ok && len(arr) == 1 {
+		o = arr[0]
+	}
+
+	if indRef, ok := o.(PDFIndirectRef); ok {
+		d, err := t.xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+		if d != nil && d.NameEntry("S") != nil {
+			t.parent = indRef
+		}
+	}
+
+	return nil
+}
+
+func (t *tagger) locateParentTree() error {
+
+	obj, found := t.root.Find("ParentTree")
+	if !found || obj == nil {
+		indRef, err := t.xRefTable.IndRefForNewObject(PDFDict{Dict: map[string]PDFObject{"Nums": PDFArray{}}})
+		if err != nil {
+			return err
+		}
+		t.root.Insert("ParentTree", *indRef)
+		obj = *indRef
+	}
+
+	d, err := t.xRefTable.DereferenceDict(obj)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return errors.New("tagger: corrupt ParentTree")
+	}
+	t.parentTree = d
+
+	// ParentTreeNextKey is optional and may be stale.
+	max, err := t.maxKey(d, 0)
+	if err != nil {
+		return err
+	}
+	t.nextKey = max + 1
+
+	if i := t.root.IntEntry("ParentTreeNextKey"); i != nil && *i > t.nextKey {
+		t.nextKey = *i
+	}
+
+	return nil
+}
+
+// maxKey returns the largest key of the number tree node d or -1 for an empty tree.
+func (t *tagger) maxKey(d *PDFDict, level int) (int, error) {
+
+	if level > 32 {
+		return 0, errors.New("tagger: ParentTree too deep")
+	}
+
+	max := -1
+
+	nums, err := t.xRefTable.DereferenceArray(d.Dict["Nums"])
+	if err != nil {
+		return 0, err
+	}
+	if nums != nil {
+		for i := 0; i < len(*nums); i += 2 {
+			if k, ok := (*nums)[i].(PDFInteger); ok && k.Value() > max {
+				max = k.Value()
+			}
+		}
+	}
+
+	kids, err := t.xRefTable.DereferenceArray(d.Dict["Kids"])
+	if err != nil || kids == nil {
+		return max, err
+	}
+
+	for _, o := range *kids {
+		kid, err := t.xRefTable.DereferenceDict(o)
+		if err != nil {
+			return 0, err
+		}
+		if kid == nil {
+			continue
+		}
+		m, err := t.maxKey(kid, level+1)
+		if err != nil {
+			return 0, err
+		}
+		if m > max {
+			max = m
+		}
+	}
+
+	return max, nil
+}
+
+// lookup returns the value for key in the number tree node d along with the leaf node and the index of key in Nums.
+func (t *tagger) lookup(d *PDFDict, key, level int) (PDFObject, *PDFDict, int, error) {
+
+	if level > 32 {
+		return nil, nil, 0, errors.New("tagger: ParentTree too deep")
+	}
+
+	nums, err := t.xRefTable.DereferenceArray(d.Dict["Nums"])
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if nums != nil {
+		for i := 0; i+1 < len(*nums); i += 2 {
+			if k, ok := (*nums)[i].(PDFInteger); ok && k.Value() == key {
+				return (*nums)[i+1], d, i, nil
+			}
+		}
+	}
+
+	kids, err := t.xRefTable.DereferenceArray(d.Dict["Kids"])
+	if err != nil || kids == nil {
+		return nil, nil, 0, err
+	}
+
+	for _, o := range *kids {
+
+		kid, err := t.xRefTable.DereferenceDict(o)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if kid == nil {
+			continue
+		}
+
+		if limits, _ := t.xRefTable.DereferenceArray(kid.Dict["Limits"]); limits != nil && len(*limits) == 2 {
+			lo, ok1 := (*limits)[0].(PDFInteger)
+			hi, ok2 := (*limits)[1].(PDFInteger)
+			if ok1 && ok2 && (key < lo.Value() || key > hi.Value()) {
+				continue
+			}
+		}
+
+		v, leaf, i, err := t.lookup(kid, key, level+1)
+		if err != nil || leaf != nil {
+			return v, leaf, i, err
+		}
+	}
+
+	return nil, nil, 0, nil
+}
+
+// insert adds a new key to the parent tree.
+// New keys are larger than any existing key and therefore go into the rightmost leaf.
+func (t *tagger) insert(key int, v PDFObject) error {
+
+	d := t.parentTree
+
+	for i := 0; i < 32; i++ {
+
+		kids, err := t.xRefTable.DereferenceArray(d.Dict["Kids"])
+		if err != nil {
+			return err
+		}
+
+		if kids == nil || len(*kids) == 0 {
+			nums, err := t.xRefTable.DereferenceArray(d.Dict["Nums"])
+			if err != nil {
+				return err
+			}
+			arr := PDFArray{}
+			if nums != nil {
+				arr = *nums
+			}
+			d.Update("Nums", append(arr, PDFInteger(key), v))
+			if key >= t.nextKey {
+				t.nextKey = key + 1
+			}
+			t.root.Update("ParentTreeNextKey", PDFInteger(t.nextKey))
+			return nil
+		}
+
+		kid, err := t.xRefTable.DereferenceDict((*kids)[len(*kids)-1])
+		if err != nil {
+			return err
+		}
+		if kid == nil {
+			return errors.New("tagger: corrupt ParentTree")
+		}
+
+		if limits, _ := t.xRefTable.DereferenceArray(kid.Dict["Limits"]); limits != nil && len(*limits) == 2 {
+			kid.Update("Limits", PDFArray{(*limits)[0], PDFInteger(key)})
+		}
+
+		d = kid
+	}
+
+	return errors.New("tagger: ParentTree too deep")
+}
+
+// newStructElem creates a structure element of type typ as a kid of the tagger's parent element.
+// alt is an optional text string describing the content.
+func (t *tagger) newStructElem(typ string, alt PDFObject, pageRef PDFIndirectRef, k PDFObject) (*PDFIndirectRef, error) {
+
+	d := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type": PDFName("StructElem"),
+			"S":    PDFName(typ),
+			"P":    t.parent,
+			"Pg":   pageRef,
+			"K":    k,
+		},
+	}
+
+	if alt != nil {
+		d.Insert("Alt", alt)
+	}
+
+	indRef, err := t.xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := t.xRefTable.DereferenceDict(t.parent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = t.appendKid(parent, *indRef); err != nil {
+		return nil, err
+	}
+
+	return indRef, nil
+}
+
+// appendKid appends o to the K entry of a structure element or the structure tree root.
+func (t *tagger) appendKid(d *PDFDict, o PDFObject) error {
+
+	k, found := d.Find("K")
+	if !found || k == nil {
+		d.Update("K", PDFArray{o})
+		return nil
+	}
+
+	if _, ok := k.(PDFArray); ok {
+		return appendToArrayEntry(t.xRefTable, d, "K", o)
+	}
+
+	if indRef, ok := k.(PDFIndirectRef); ok {
+		if arr, _ := t.xRefTable.DereferenceArray(indRef); arr != nil {
+			return appendToArrayEntry(t.xRefTable, d, "K", o)
+		}
+	}
+
+	// A single kid
+	d.Update("K", PDFArray{k, o})
+
+	return nil
+}
+
+// pageElems returns the parent tree array of structure elements owning the marked content of a page
+// along with a function storing the updated array.
+func (t *tagger) pageElems(pageDict *PDFDict) (PDFArray, func(PDFArray) error, error) {
+
+	sp := pageDict.IntEntry("StructParents")
+	if sp == nil {
+		key := t.nextKey
+		t.nextKey++
+		pageDict.Update("StructParents", PDFInteger(key))
+		store := func(arr PDFArray) error {
+			indRef, err := t.xRefTable.IndRefForNewObject(arr)
+			if err != nil {
+				return err
+			}
+			return t.insert(key, *indRef)
+		}
+		return nil, store, nil
+	}
+
+	v, leaf, i, err := t.lookup(t.parentTree, *sp, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if leaf == nil {
+		// The page claims a key missing in the parent tree.
+		key := *sp
+		store := func(arr PDFArray) error {
+			indRef, err := t.xRefTable.IndRefForNewObject(arr)
+			if err != nil {
+				return err
+			}
+			if key >= t.nextKey {
+				return t.insert(key, *indRef)
+			}
+			return t.insertUnordered(key, *indRef)
+		}
+		return nil, store, nil
+	}
+
+	arr, err := t.xRefTable.DereferenceArray(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var elems PDFArray
+	if arr != nil {
+		elems = *arr
+	}
+
+	store := func(arr PDFArray) error {
+		if indRef, ok := v.(PDFIndirectRef); ok {
+			if entry, found := t.xRefTable.FindTableEntryForIndRef(&indRef); found {
+				entry.Object = arr
+				return nil
+			}
+		}
+		nums, err := t.xRefTable.DereferenceArray(leaf.Dict["Nums"])
+		if err != nil {
+			return err
+		}
+		(*nums)[i+1] = arr
+		leaf.Update("Nums", *nums)
+		return nil
+	}
+
+	return elems, store, nil
+}
+
+// insertUnordered adds key to the root node of the parent tree keeping Nums sorted.
+func (t *tagger) insertUnordered(key int, v PDFObject) error {
+
+	nums, err := t.xRefTable.DereferenceArray(t.parentTree.Dict["Nums"])
+	if err != nil {
+		return err
+	}
+
+	if nums == nil {
+		if _, found := t.parentTree.Find("Kids"); found {
+			return errors.Errorf("tagger: unable to register StructParents key %d", key)
+		}
+		nums = &PDFArray{}
+	}
+
+	var i int
+	for i = 0; i+1 < len(*nums); i += 2 {
+		if k, ok := (*nums)[i].(PDFInteger); ok && k.Value() > key {
+			break
+		}
+	}
+
+	arr := append(PDFArray{}, (*nums)[:i]...)
+	arr = append(arr, PDFInteger(key), v)
+	arr = append(arr, (*nums)[i:]...)
+	t.parentTree.Update("Nums", arr)
+
+	return nil
+}
+
+// markContent creates a structure element of type typ for new content of a page
+// and returns the content wrapped into a marked-content sequence bound to this element.
+func (t *tagger) markContent(pageNr int, pageDict *PDFDict, typ string, alt PDFObject, bb []byte) ([]byte, error) {
+
+	if err := t.ensureStructTree(); err != nil {
+		return nil, err
+	}
+
+	pageRef, err := t.xRefTable.PageDictIndRef(pageNr)
+	if err != nil {
+		return nil, err
+	}
+	if pageRef == nil {
+		return nil, errors.Errorf("tagger: page %d not found", pageNr)
+	}
+
+	elems, store, err := t.pageElems(pageDict)
+	if err != nil {
+		return nil, err
+	}
+
+	mcid := len(elems)
+
+	elem, err := t.newStructElem(typ, alt, *pageRef, PDFInteger(mcid))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = store(append(elems, *elem)); err != nil {
+		return nil, err
+	}
+
+	return markedContent(typ, mcid, bb), nil
+}
+
+// unmarkContent detaches the structure elements owning the marked content mcids of a page from the structure tree.
+func (t *tagger) unmarkContent(pageDict *PDFDict, mcids []int) error {
+
+	if len(mcids) == 0 || pageDict.IntEntry("StructParents") == nil {
+		return nil
+	}
+
+	if err := t.ensureStructTree(); err != nil {
+		return err
+	}
+
+	elems, store, err := t.pageElems(pageDict)
+	if err != nil || elems == nil {
+		return err
+	}
+
+	for _, mcid := range mcids {
+		if mcid < 0 || mcid >= len(elems) {
+			continue
+		}
+		indRef, ok := elems[mcid].(PDFIndirectRef)
+		if !ok {
+			continue
+		}
+		if err = t.removeKid(indRef); err != nil {
+			return err
+		}
+		elems[mcid] = nil
+	}
+
+	return store(elems)
+}
+
+// removeKid removes the structure element indRef from the K entry of its parent.
+func (t *tagger) removeKid(indRef PDFIndirectRef) error {
+
+	d, err := t.xRefTable.DereferenceDict(indRef)
+	if err != nil || d == nil {
+		return err
+	}
+
+	p, err := t.xRefTable.DereferenceDict(d.Dict["P"])
+	if err != nil || p == nil {
+		return err
+	}
+
+	m := IntSet{indRef.ObjectNumber.Value(): true}
+
+	k := p.Dict["K"]
+
+	if ir, ok := k.(PDFIndirectRef); ok {
+		if m[ir.ObjectNumber.Value()] {
+			p.Delete("K")
+			return nil
+		}
+		if o, _ := t.xRefTable.Dereference(ir); o != nil {
+			k = o
+		}
+	}
+
+	if arr, ok := k.(PDFArray); ok {
+		p.Update("K", filterRefs(arr, m))
+	}
+
+	return nil
+}
+
+// retagAnnotation moves the structure element of an annotation about to be flattened onto its marked content.
+// It returns the content wrapped into a marked-content sequence bound to this element
+// or false if the annotation is not part of the structure tree.
+func (t *tagger) retagAnnotation(pageNr int, pageDict, annotDict *PDFDict, annotObjNr int, bb []byte) ([]byte, bool, error) {
+
+	sp := annotDict.IntEntry("StructParent")
+	if sp == nil {
+		return nil, false, nil
+	}
+
+	if err := t.ensureStructTree(); err != nil {
+		return nil, false, err
+	}
+
+	v, leaf, _, err := t.lookup(t.parentTree, *sp, 0)
+	if err != nil || leaf == nil {
+		return nil, false, err
+	}
+
+	elemRef, ok := v.(PDFIndirectRef)
+	if !ok {
+		return nil, false, nil
+	}
+
+	elem, err := t.xRefTable.DereferenceDict(elemRef)
+	if err != nil || elem == nil {
+		return nil, false, err
+	}
+
+	typ := elem.NameEntry("S")
+	if typ == nil {
+		return nil, false, nil
+	}
+
+	pageRef, err := t.xRefTable.PageDictIndRef(pageNr)
+	if err != nil || pageRef == nil {
+		return nil, false, err
+	}
+
+	elems, store, err := t.pageElems(pageDict)
+	if err != nil {
+		return nil, false, err
+	}
+
+	mcid := len(elems)
+	mcr := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type": PDFName("MCR"),
+			"Pg":   *pageRef,
+			"MCID": PDFInteger(mcid),
+		},
+	}
+
+	// Replace the object reference to the annotation.
+	isAnnotRef := func(o PDFObject) bool {
+		d, err := t.xRefTable.DereferenceDict(o)
+		if err != nil || d == nil || d.Type() == nil || *d.Type() != "OBJR" {
+			return false
+		}
+		indRef := d.IndirectRefEntry("Obj")
+		return indRef != nil && indRef.ObjectNumber.Value() == annotObjNr
+	}
+
+	k, err := t.xRefTable.Dereference(elem.Dict["K"])
+	if err != nil {
+		return nil, false, err
+	}
+
+	replaced := false
+	if arr, ok := k.(PDFArray); ok {
+		kids := PDFArray{}
+		for _, o := range arr {
+			if !replaced && isAnnotRef(o) {
+				o, replaced = mcr, true
+			}
+			kids = append(kids, o)
+		}
+		elem.Update("K", kids)
+	} else if isAnnotRef(elem.Dict["K"]) {
+		elem.Update("K", mcr)
+		replaced = true
+	}
+
+	if !replaced {
+		return nil, false, nil
+	}
+
+	if err = store(append(elems, elemRef)); err != nil {
+		return nil, false, err
+	}
+
+	return markedContent(*typ, mcid, bb), true, nil
+}
+
+// tagAnnotation creates a structure element of type typ referencing an annotation.
+func (t *tagger) tagAnnotation(pageNr int, annotRef PDFIndirectRef, annotDict *PDFDict, typ string, alt PDFObject) error {
+
+	if err := t.ensureStructTree(); err != nil {
+		return err
+	}
+
+	pageRef, err := t.xRefTable.PageDictIndRef(pageNr)
+	if err != nil {
+		return err
+	}
+	if pageRef == nil {
+		return errors.Errorf("tagger: page %d not found", pageNr)
+	}
+
+	objr := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type": PDFName("OBJR"),
+			"Pg":   *pageRef,
+			"Obj":  annotRef,
+		},
+	}
+
+	elem, err := t.newStructElem(typ, alt, *pageRef, objr)
+	if err != nil {
+		return err
+	}
+
+	key := t.nextKey
+	t.nextKey++
+	annotDict.Update("StructParent", PDFInteger(key))
+
+	return t.insert(key, *elem)
+}
+
+// markedContent wraps bb into a marked-content sequence tagged by typ, see 14.6.
+func markedContent(typ string, mcid int, bb []byte) []byte {
+	return append(append([]byte(fmt.Sprintf(" /%s <</MCID %d>> BDC", typ, mcid)), bb...), []byte(" EMC ")...)
+}
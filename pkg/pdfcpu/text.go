@@ -0,0 +1,1135 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/fonts/metrics"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Text extraction locates the characters shown on a page in default user space.
+// Glyph boxes are approximated using the font widths and a fixed ascent and descent
+// except for Type3 fonts whose glyphs are bounded by the font bbox mapped to text space using the font matrix.
+// Character codes are mapped to Unicode using the ToUnicode CMap of a font
+// and fall back to WinAnsiEncoding for simple fonts.
+
+const (
+	textAscent  = 0.8  // ascent in text space units
+	textDescent = -0.2 // descent in text space units
+
+	maxFormNesting = 8
+)
+
+// winAnsi maps the WinAnsiEncoding codes 0x80 - 0x9F deviating from Latin-1 to Unicode.
+var winAnsi = map[int]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ',
+	0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž', 0x91: '‘', 0x92: '’', 0x93: '“',
+	0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›',
+	0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+// textChar represents a character shown on a page.
+type textChar struct {
+	r          rune
+	quad       [8]float64 // upper left, upper right, lower left, lower right corner as used for QuadPoints
+	start, end [2]float64 // baseline start and end point
+	height     float64    // font size in user space
+	mcid       int        // marked-content identifier in effect or -1
+}
+
+// textLine represents a sequence of characters shown along the same baseline.
+type textLine struct {
+	chars []textChar
+}
+
+func (tl textLine) String() string {
+	rr := make([]rune, len(tl.chars))
+	for i, c := range tl.chars {
+		rr[i] = c.r
+	}
+	return string(rr)
+}
+
+// quadPoints returns the quadrilateral enclosing the characters i to j-1 of a line.
+func (tl textLine) quadPoints(i, j int) [8]float64 {
+	q1, q2 := tl.chars[i].quad, tl.chars[j-1].quad
+	return [8]float64{q1[0], q1[1], q2[2], q2[3], q1[4], q1[5], q2[6], q2[7]}
+}
+
+// textFont provides the glyph widths and the Unicode mapping of a font.
+type textFont struct {
+	twoByte   bool            // Type0 font using 2 byte codes
+	toUnicode map[int]string  // character code to Unicode mapping
+	widths    map[int]float64 // glyph widths in thousandths of text space units or in glyph space units for Type3 fonts
+	defWidth  float64
+	stdFont   string // name of a standard 14 font lacking widths
+
+	fontMatrix *matrix          // Type3 fonts: glyph space to text space
+	glyphBox   *types.Rectangle // Type3 fonts: font bbox in text space
+}
+
+func (f *textFont) width(code int) float64 {
+	if w, found := f.widths[code]; found {
+		return w
+	}
+	if f.stdFont != "" {
+		return float64(metrics.CharWidth(f.stdFont, code))
+	}
+	return f.defWidth
+}
+
+// glyphWidth returns the horizontal displacement of a glyph in text space units.
+func (f *textFont) glyphWidth(code int) float64 {
+	if f.fontMatrix != nil {
+		return f.width(code) * f.fontMatrix[0][0]
+	}
+	return f.width(code) / 1000
+}
+
+// glyphQuad returns the quadrilateral enclosing a glyph of width w rendered using trm.
+func (f *textFont) glyphQuad(trm matrix, w float64) [8]float64 {
+
+	llx, lly, urx, ury := 0.0, textDescent, w, textAscent
+
+	if f.glyphBox != nil {
+		// Type3 glyphs may paint anywhere within the font bbox.
+		llx, urx = math.Min(math.Min(0, w), f.glyphBox.LL.X), math.Max(math.Max(0, w), f.glyphBox.UR.X)
+		lly, ury = f.glyphBox.LL.Y, f.glyphBox.UR.Y
+	}
+
+	ul, ur := transform(trm, llx, ury), transform(trm, urx, ury)
+	ll, lr := transform(trm, llx, lly), transform(trm, urx, lly)
+
+	return [8]float64{ul[0], ul[1], ur[0], ur[1], ll[0], ll[1], lr[0], lr[1]}
+}
+
+func (f *textFont) text(code int) string {
+	if s, found := f.toUnicode[code]; found {
+		return s
+	}
+	if f.twoByte {
+		return "\uFFFD"
+	}
+	if r, found := winAnsi[code]; found {
+		return string(r)
+	}
+	return string(rune(code))
+}
+
+// parseToUnicode parses the bfchar and bfrange sections of a ToUnicode CMap.
+func parseToUnicode(bb []byte) map[int]string {
+
+	m := map[int]string{}
+
+	hexValue := func(s string) []byte {
+		s = strings.Trim(s, "<>")
+		if len(s)%2 == 1 {
+			s += "0"
+		}
+		b, err := PDFHexLiteral(s).Bytes()
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+
+	code := func(b []byte) int {
+		var c int
+		for _, v := range b {
+			c = c<<8 | int(v)
+		}
+		return c
+	}
+
+	utf16BE := func(b []byte) string {
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		}
+		return string(utf16.Decode(u))
+	}
+
+	var section string
+	var tt []string
+
+	for _, t := range strings.Fields(strings.NewReplacer("<", " <", ">", "> ", "[", " [ ", "]", " ] ").Replace(string(bb))) {
+
+		switch t {
+		case "beginbfchar", "beginbfrange":
+			section, tt = t, nil
+			continue
+		case "endbfchar", "endbfrange":
+			section = ""
+			continue
+		}
+
+		if section == "" {
+			continue
+		}
+
+		tt = append(tt, t)
+
+		if section == "beginbfchar" && len(tt) == 2 {
+			m[code(hexValue(tt[0]))] = utf16BE(hexValue(tt[1]))
+			tt = nil
+			continue
+		}
+
+		if section != "beginbfrange" || len(tt) < 3 {
+			continue
+		}
+
+		lo, hi := code(hexValue(tt[0])), code(hexValue(tt[1]))
+
+		if tt[2] == "[" {
+			if tt[len(tt)-1] != "]" {
+				continue
+			}
+			for i, s := range tt[3 : len(tt)-1] {
+				m[lo+i] = utf16BE(hexValue(s))
+			}
+			tt = nil
+			continue
+		}
+
+		dst := hexValue(tt[2])
+		for c := lo; c <= hi && c-lo < 0x10000 && len(dst) >= 2; c++ {
+			m[c] = utf16BE(dst)
+			dst = append([]byte{}, dst...)
+			dst[len(dst)-1]++
+		}
+		tt = nil
+	}
+
+	return m
+}
+
+// newTextFont extracts glyph widths and Unicode mapping from a font dict.
+func newTextFont(xRefTable *XRefTable, d *PDFDict) (*textFont, error) {
+
+	f := &textFont{widths: map[int]float64{}, defWidth: 500}
+
+	if indRef := d.IndirectRefEntry("ToUnicode"); indRef != nil {
+		sd, err := xRefTable.DereferenceStreamDict(*indRef)
+		if err != nil {
+			return nil, err
+		}
+		if sd != nil {
+			if err = xRefTable.decodeStream(sd); err == nil {
+				f.toUnicode = parseToUnicode(sd.Content)
+			} else if err != filter.ErrUnsupportedFilter {
+				return nil, err
+			}
+		}
+	}
+
+	if st := d.NameEntry("Subtype"); st != nil && *st == "Type0" {
+		return f, type0FontWidths(xRefTable, d, f)
+	}
+
+	if st := d.NameEntry("Subtype"); st != nil && *st == "Type3" {
+		if err := type3FontMatrix(xRefTable, d, f); err != nil {
+			return nil, err
+		}
+	}
+
+	obj, found := d.Find("Widths")
+	if !found {
+		if bf := d.NameEntry("BaseFont"); bf != nil && supportedWatermarkFont(*bf) {
+			f.stdFont = *bf
+		}
+		return f, nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return f, err
+	}
+
+	first := d.IntEntry("FirstChar")
+	if first == nil {
+		return f, nil
+	}
+
+	for i, o := range *arr {
+		f.widths[*first+i] = xRefTable.DereferenceNumber(o)
+	}
+
+	if indRef := d.IndirectRefEntry("FontDescriptor"); indRef != nil {
+		fd, err := xRefTable.DereferenceDict(*indRef)
+		if err != nil {
+			return nil, err
+		}
+		if fd != nil {
+			if o, found := fd.Find("MissingWidth"); found {
+				f.defWidth = xRefTable.DereferenceNumber(o)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// type0FontWidths extracts the glyph widths of the descendant font of a Type0 font.
+func type0FontWidths(xRefTable *XRefTable, d *PDFDict, f *textFont) error {
+
+	f.twoByte = true
+	f.defWidth = 1000
+
+	obj, _ := d.Find("DescendantFonts")
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil || len(*arr) == 0 {
+		return err
+	}
+
+	df, err := xRefTable.DereferenceDict((*arr)[0])
+	if err != nil || df == nil {
+		return err
+	}
+
+	if o, found := df.Find("DW"); found {
+		f.defWidth = xRefTable.DereferenceNumber(o)
+	}
+
+	obj, _ = df.Find("W")
+
+	w, err := xRefTable.DereferenceArray(obj)
+	if err != nil || w == nil {
+		return err
+	}
+
+	// W consists of entries "c [w1 w2 ...]" and "cFirst cLast w".
+	for i := 0; i+1 < len(*w); {
+
+		c := int(xRefTable.DereferenceNumber((*w)[i]))
+
+		o, err := xRefTable.Dereference((*w)[i+1])
+		if err != nil {
+			return err
+		}
+
+		if ww, ok := o.(PDFArray); ok {
+			for j, v := range ww {
+				f.widths[c+j] = xRefTable.DereferenceNumber(v)
+			}
+			i += 2
+			continue
+		}
+
+		if i+2 >= len(*w) {
+			break
+		}
+
+		last := int(xRefTable.DereferenceNumber(o))
+		v := xRefTable.DereferenceNumber((*w)[i+2])
+		for j := c; j <= last && j-c < 0x10000; j++ {
+			f.widths[j] = v
+		}
+		i += 3
+	}
+
+	return nil
+}
+
+// type3FontMatrix extracts the font matrix and the font bbox of a Type3 font.
+func type3FontMatrix(xRefTable *XRefTable, d *PDFDict, f *textFont) error {
+
+	// Missing widths are 0 for Type3 fonts.
+	f.defWidth = 0
+
+	obj, _ := d.Find("FontMatrix")
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil || len(*arr) != 6 {
+		return err
+	}
+
+	ff := make([]float64, 6)
+	for i, o := range *arr {
+		ff[i] = xRefTable.DereferenceNumber(o)
+	}
+
+	m := newMatrix(ff)
+	f.fontMatrix = &m
+
+	obj, _ = d.Find("FontBBox")
+
+	arr, err = xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil || len(*arr) != 4 {
+		return err
+	}
+
+	// A bbox of all zeros is no statement about the glyphs.
+	r := rect(xRefTable, *arr)
+	if r.Width() == 0 && r.Height() == 0 {
+		return nil
+	}
+
+	r = boundingBox(m, r)
+	f.glyphBox = &r
+
+	return nil
+}
+
+// compactHexStrings removes the whitespace allowed within the hex strings of a content stream.
+func compactHexStrings(bb []byte) []byte {
+
+	var b bytes.Buffer
+
+	for i := 0; i < len(bb); {
+
+		c := bb[i]
+
+		switch {
+
+		case c == '(':
+			j := skipStringLiteral(bb, i)
+			b.Write(bb[i:j])
+			i = j
+
+		case c == '<' && i+1 < len(bb) && bb[i+1] != '<':
+			for ; i < len(bb) && bb[i] != '>'; i++ {
+				if !contentWhitespace(bb[i]) {
+					b.WriteByte(bb[i])
+				}
+			}
+
+		case c == '<':
+			b.WriteString("<<")
+			i += 2
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.Bytes()
+}
+
+// parseContent calls fn for every operator of a content stream along with its operands.
+func parseContent(bb []byte, fn func(op string, operands []PDFObject) error) error {
+	return scanContent(compactHexStrings(bb), func(op string, operands []PDFObject, start, end int) error {
+		return fn(op, operands)
+	})
+}
+
+// scanContent calls fn for every operator of a content stream free of whitespace within hex strings
+// along with its operands and the offsets delimiting the operands and the operator within bb.
+// For BI the inline image up to and including EI is part of the operator.
+func scanContent(bb []byte, fn func(op string, operands []PDFObject, start, end int) error) error {
+
+	s := string(bb)
+
+	var operands []PDFObject
+	start := -1
+
+	for i := 0; i < len(s); {
+
+		c := s[i]
+
+		switch {
+
+		case contentWhitespace(c):
+			i++
+
+		case c == '%':
+			for i < len(s) && s[i] != 0x0A && s[i] != 0x0D {
+				i++
+			}
+
+		case c == '(' || c == '<' || c == '[' || c == '/':
+			if start < 0 {
+				start = i
+			}
+			l := s[i:]
+			o, err := parseObject(&l)
+			if err != nil {
+				return errors.Wrapf(err, "parseContent: corrupt operand at offset %d", i)
+			}
+			operands = append(operands, o)
+			i = len(s) - len(l)
+
+		case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+			i++
+
+		default:
+			if start < 0 {
+				start = i
+			}
+			j := i + 1
+			for j < len(s) && !contentWhitespace(s[j]) && !contentDelimiter(s[j]) {
+				j++
+			}
+			t := s[i:j]
+			i = j
+
+			if f, err := strconv.ParseFloat(t, 64); err == nil {
+				operands = append(operands, PDFFloat(f))
+				continue
+			}
+
+			switch t {
+			case "true", "false":
+				operands = append(operands, PDFBoolean(t == "true"))
+				continue
+			case "null":
+				operands = append(operands, nil)
+				continue
+			}
+
+			if t == "BI" {
+				i = skipInlineImageData(bb, i)
+			}
+
+			if err := fn(t, operands, start, i); err != nil {
+				return err
+			}
+			operands = nil
+			start = -1
+		}
+	}
+
+	return nil
+}
+
+// textExtractor tracks the graphics and text state while interpreting content streams.
+type textExtractor struct {
+	xRefTable *XRefTable
+	fonts     map[int]*textFont // fonts by object number
+	chars     []textChar
+	mcids     []int // marked-content identifiers of the open marked-content sequences
+
+	// image is called for each image XObject painted, if set.
+	image func(o PDFObject, sd *PDFStreamDict, ctm matrix)
+}
+
+// mcid returns the marked-content identifier in effect or -1.
+func (te *textExtractor) mcid() int {
+	if len(te.mcids) == 0 {
+		return -1
+	}
+	return te.mcids[len(te.mcids)-1]
+}
+
+// beginMarkedContent opens a marked-content sequence.
+// Nested sequences lacking an MCID belong to the enclosing sequence.
+// MCIDs within form XObjects refer to the structural parents of the form and are ignored.
+func (te *textExtractor) beginMarkedContent(resources *PDFDict, operands []PDFObject, depth int) {
+
+	mcid := te.mcid()
+
+	if len(operands) == 2 && depth == 0 {
+		var d *PDFDict
+		switch o := operands[1].(type) {
+		case PDFDict:
+			d = &o
+		case PDFName:
+			if obj, err := te.resource(resources, "Properties", o.Value()); err == nil && obj != nil {
+				d, _ = te.xRefTable.DereferenceDict(obj)
+			}
+		}
+		if d != nil {
+			switch o := d.Dict["MCID"].(type) {
+			case PDFInteger:
+				mcid = o.Value()
+			case PDFFloat:
+				mcid = int(o.Value())
+			}
+		}
+	}
+
+	te.mcids = append(te.mcids, mcid)
+}
+
+type textGraphicsState struct {
+	ctm                    matrix
+	font                   *textFont
+	size                   float64
+	charSpacing, wordSpace float64
+	scale                  float64
+	leading, rise          float64
+}
+
+func numbers(operands []PDFObject) ([]float64, bool) {
+
+	ff := make([]float64, len(operands))
+
+	for i, o := range operands {
+		switch o := o.(type) {
+		case PDFFloat:
+			ff[i] = o.Value()
+		case PDFInteger:
+			ff[i] = float64(o.Value())
+		default:
+			return nil, false
+		}
+	}
+
+	return ff, true
+}
+
+func newMatrix(ff []float64) matrix {
+	return matrix{{ff[0], ff[1], 0}, {ff[2], ff[3], 0}, {ff[4], ff[5], 1}}
+}
+
+func transform(m matrix, x, y float64) [2]float64 {
+	return [2]float64{x*m[0][0] + y*m[1][0] + m[2][0], x*m[0][1] + y*m[1][1] + m[2][1]}
+}
+
+// resource returns the resource called name of category within resources.
+func (te *textExtractor) resource(resources *PDFDict, category, name string) (PDFObject, error) {
+
+	if resources == nil {
+		return nil, nil
+	}
+
+	obj, found := resources.Find(category)
+	if !found {
+		return nil, nil
+	}
+
+	d, err := te.xRefTable.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	obj, _ = d.Find(name)
+
+	return obj, nil
+}
+
+func (te *textExtractor) font(resources *PDFDict, name string) (*textFont, error) {
+
+	obj, err := te.resource(resources, "Font", name)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+
+	var objNr int
+	if indRef, ok := obj.(PDFIndirectRef); ok {
+		objNr = indRef.ObjectNumber.Value()
+		if f, found := te.fonts[objNr]; found {
+			return f, nil
+		}
+	}
+
+	d, err := te.xRefTable.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	f, err := newTextFont(te.xRefTable, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if objNr > 0 {
+		te.fonts[objNr] = f
+	}
+
+	return f, nil
+}
+
+// textState tracks the graphics state and the text matrices while interpreting a content stream.
+type textState struct {
+	gs      textGraphicsState
+	stack   []textGraphicsState
+	tm, tlm matrix
+}
+
+func (ts *textState) newLine(tx, ty float64) {
+	ts.tlm = matrix{{1, 0, 0}, {0, 1, 0}, {tx, ty, 1}}.multiply(ts.tlm)
+	ts.tm = ts.tlm
+}
+
+// update applies the state changes of an operator.
+// For the text showing operators ' and " this covers the move to the next line only.
+func (ts *textState) update(te *textExtractor, resources *PDFDict, op string, operands []PDFObject) error {
+
+	ff, isNumbers := numbers(operands)
+
+	switch op {
+
+	case "q":
+		ts.stack = append(ts.stack, ts.gs)
+
+	case "Q":
+		if len(ts.stack) > 0 {
+			ts.gs, ts.stack = ts.stack[len(ts.stack)-1], ts.stack[:len(ts.stack)-1]
+		}
+
+	case "cm":
+		if isNumbers && len(ff) == 6 {
+			ts.gs.ctm = newMatrix(ff).multiply(ts.gs.ctm)
+		}
+
+	case "BT":
+		ts.tm, ts.tlm = identMatrix, identMatrix
+
+	case "Tf":
+		if len(operands) == 2 {
+			name, _ := operands[0].(PDFName)
+			if size, ok := numbers(operands[1:]); ok {
+				ts.gs.size = size[0]
+			}
+			f, err := te.font(resources, name.Value())
+			if err != nil {
+				return err
+			}
+			ts.gs.font = f
+		}
+
+	case "Tc", "Tw", "Tz", "TL", "Ts":
+		if !isNumbers || len(ff) != 1 {
+			break
+		}
+		switch op {
+		case "Tc":
+			ts.gs.charSpacing = ff[0]
+		case "Tw":
+			ts.gs.wordSpace = ff[0]
+		case "Tz":
+			ts.gs.scale = ff[0] / 100
+		case "TL":
+			ts.gs.leading = ff[0]
+		case "Ts":
+			ts.gs.rise = ff[0]
+		}
+
+	case "Td", "TD":
+		if isNumbers && len(ff) == 2 {
+			if op == "TD" {
+				ts.gs.leading = -ff[1]
+			}
+			ts.newLine(ff[0], ff[1])
+		}
+
+	case "Tm":
+		if isNumbers && len(ff) == 6 {
+			ts.tlm = newMatrix(ff)
+			ts.tm = ts.tlm
+		}
+
+	case "T*":
+		ts.newLine(0, -ts.gs.leading)
+
+	case "'", "\"":
+		if op == "\"" && len(operands) == 3 {
+			if ff, ok := numbers(operands[:2]); ok {
+				ts.gs.wordSpace, ts.gs.charSpacing = ff[0], ff[1]
+			}
+		}
+		ts.newLine(0, -ts.gs.leading)
+	}
+
+	return nil
+}
+
+// showGlyphs calls fn for every glyph of a string with its character code, its position within b,
+// its width in text space units, its text rendering matrix and its quadrilateral in user space
+// and advances the text matrix.
+func (te *textExtractor) showGlyphs(gs *textGraphicsState, tm *matrix, b []byte, fn func(code, i, n int, w float64, trm matrix, quad [8]float64)) {
+
+	f := gs.font
+	if f == nil {
+		f = &textFont{defWidth: 500}
+	}
+
+	n := 1
+	if f.twoByte {
+		n = 2
+	}
+
+	for i := 0; i+n <= len(b); i += n {
+
+		code := int(b[i])
+		if n == 2 {
+			code = code<<8 | int(b[i+1])
+		}
+
+		w := f.glyphWidth(code)
+
+		trm := matrix{{gs.size * gs.scale, 0, 0}, {0, gs.size, 0}, {0, gs.rise, 1}}.multiply(*tm).multiply(gs.ctm)
+
+		fn(code, i, n, w, trm, f.glyphQuad(trm, w))
+
+		tx := w*gs.size + gs.charSpacing
+		if n == 1 && code == 32 {
+			tx += gs.wordSpace
+		}
+
+		*tm = matrix{{1, 0, 0}, {0, 1, 0}, {tx * gs.scale, 0, 1}}.multiply(*tm)
+	}
+}
+
+// showText records the characters of a string and advances the text matrix.
+func (te *textExtractor) showText(gs *textGraphicsState, tm *matrix, b []byte) {
+
+	f := gs.font
+	if f == nil {
+		f = &textFont{defWidth: 500}
+	}
+
+	te.showGlyphs(gs, tm, b, func(code, i, n int, w float64, trm matrix, quad [8]float64) {
+
+		start, end := transform(trm, 0, 0), transform(trm, w, 0)
+		top := transform(trm, 0, 1)
+
+		h := math.Hypot(top[0]-start[0], top[1]-start[1])
+
+		for _, r := range f.text(code) {
+			te.chars = append(te.chars, textChar{
+				r:      r,
+				quad:   quad,
+				start:  start,
+				end:    end,
+				height: h,
+				mcid:   te.mcid(),
+			})
+		}
+	})
+}
+
+// adjustText advances the text matrix according to a number within a TJ array.
+func adjustText(gs *textGraphicsState, tm *matrix, f float64) {
+	tx := -f / 1000 * gs.size * gs.scale
+	*tm = matrix{{1, 0, 0}, {0, 1, 0}, {tx, 0, 1}}.multiply(*tm)
+}
+
+func stringBytes(o PDFObject) ([]byte, bool) {
+
+	switch o := o.(type) {
+
+	case PDFStringLiteral:
+		b, err := Unescape(o.Value())
+		return b, err == nil
+
+	case PDFHexLiteral:
+		b, err := o.Bytes()
+		return b, err == nil
+	}
+
+	return nil, false
+}
+
+// processContent interprets a content stream using resources.
+func (te *textExtractor) processContent(bb []byte, resources *PDFDict, ctm matrix, depth int) error {
+
+	ts := &textState{gs: textGraphicsState{ctm: ctm, scale: 1}}
+
+	return parseContent(bb, func(op string, operands []PDFObject) error {
+
+		if err := ts.update(te, resources, op, operands); err != nil {
+			return err
+		}
+
+		switch op {
+
+		case "Tj", "'", "\"":
+			if len(operands) == 0 {
+				break
+			}
+			if b, ok := stringBytes(operands[len(operands)-1]); ok {
+				te.showText(&ts.gs, &ts.tm, b)
+			}
+
+		case "TJ":
+			if len(operands) != 1 {
+				break
+			}
+			arr, _ := operands[0].(PDFArray)
+			for _, o := range arr {
+				if b, ok := stringBytes(o); ok {
+					te.showText(&ts.gs, &ts.tm, b)
+					continue
+				}
+				if ff, ok := numbers([]PDFObject{o}); ok {
+					adjustText(&ts.gs, &ts.tm, ff[0])
+				}
+			}
+
+		case "BMC", "BDC":
+			te.beginMarkedContent(resources, operands, depth)
+
+		case "EMC":
+			if len(te.mcids) > 0 {
+				te.mcids = te.mcids[:len(te.mcids)-1]
+			}
+
+		case "Do":
+			if len(operands) == 1 && depth < maxFormNesting {
+				name, _ := operands[0].(PDFName)
+				return te.processForm(resources, name.Value(), ts.gs.ctm, depth+1)
+			}
+		}
+
+		return nil
+	})
+}
+
+// formContext returns the matrix mapping form space to user space and the resources in effect for a form XObject.
+func (te *textExtractor) formContext(sd *PDFStreamDict, resources *PDFDict, ctm matrix) (matrix, *PDFDict, error) {
+
+	if obj, found := sd.Find("Matrix"); found {
+		arr, err := te.xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return ctm, nil, err
+		}
+		if arr != nil {
+			if ff, ok := numbers(*arr); ok && len(ff) == 6 {
+				ctm = newMatrix(ff).multiply(ctm)
+			}
+		}
+	}
+
+	if obj, found := sd.Find("Resources"); found {
+		d, err := te.xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return ctm, nil, err
+		}
+		if d != nil {
+			resources = d
+		}
+	}
+
+	return ctm, resources, nil
+}
+
+// processForm interprets the content of a form XObject.
+func (te *textExtractor) processForm(resources *PDFDict, name string, ctm matrix, depth int) error {
+
+	obj, err := te.resource(resources, "XObject", name)
+	if err != nil || obj == nil {
+		return err
+	}
+
+	sd, err := te.xRefTable.DereferenceStreamDict(obj)
+	if err != nil || sd == nil {
+		return err
+	}
+
+	if st := sd.NameEntry("Subtype"); st == nil || *st != "Form" {
+		if st != nil && *st == "Image" && te.image != nil {
+			te.image(obj, sd, ctm)
+		}
+		return nil
+	}
+
+	ctm, resources, err = te.formContext(sd, resources, ctm)
+	if err != nil {
+		return err
+	}
+
+	err = te.xRefTable.decodeStream(sd)
+	if err == filter.ErrUnsupportedFilter {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return te.processContent(sd.Content, resources, ctm, depth)
+}
+
+// pageContent returns the concatenated decoded content streams of a page.
+// Streams using unsupported filters are skipped unless strict is set.
+func pageContent(xRefTable *XRefTable, pageDict *PDFDict, strict bool) ([]byte, error) {
+
+	obj, found := pageDict.Find("Contents")
+	if !found || obj == nil {
+		return nil, nil
+	}
+
+	o, err := xRefTable.Dereference(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	arr, ok := o.(PDFArray)
+	if !ok {
+		arr = PDFArray{obj}
+	}
+
+	var b bytes.Buffer
+
+	for _, o := range arr {
+
+		sd, err := xRefTable.DereferenceStreamDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if sd == nil {
+			continue
+		}
+
+		err = xRefTable.decodeStream(sd)
+		if err == filter.ErrUnsupportedFilter && !strict {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(sd.Content)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes(), nil
+}
+
+// sameLine returns true if c continues the text of a line ending with p.
+// space is true if a word break separates c from p.
+func sameLine(p, c textChar) (ok, space bool) {
+
+	h := p.height
+	if h == 0 {
+		return false, false
+	}
+
+	dx, dy := p.end[0]-p.start[0], p.end[1]-p.start[1]
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		dx, dy, l = 1, 0, 1
+	}
+	dx, dy = dx/l, dy/l
+
+	gx, gy := c.start[0]-p.end[0], c.start[1]-p.end[1]
+
+	along := gx*dx + gy*dy
+	across := gy*dx - gx*dy
+
+	if math.Abs(across) > h/2 || along < -h || along > 1.5*h {
+		return false, false
+	}
+
+	return true, along > 0.15*h && p.r != ' ' && c.r != ' '
+}
+
+// textLines groups characters into lines and inserts spaces between words separated by positioning only.
+func textLines(chars []textChar) []textLine {
+
+	var lines []textLine
+	var tl textLine
+
+	for _, c := range chars {
+
+		if len(tl.chars) == 0 {
+			tl.chars = append(tl.chars, c)
+			continue
+		}
+
+		p := tl.chars[len(tl.chars)-1]
+
+		ok, space := sameLine(p, c)
+		if !ok {
+			lines = append(lines, tl)
+			tl = textLine{chars: []textChar{c}}
+			continue
+		}
+
+		if space {
+			tl.chars = append(tl.chars, textChar{
+				r:      ' ',
+				quad:   [8]float64{p.quad[2], p.quad[3], c.quad[0], c.quad[1], p.quad[6], p.quad[7], c.quad[4], c.quad[5]},
+				start:  p.end,
+				end:    c.start,
+				height: p.height,
+				mcid:   c.mcid,
+			})
+		}
+
+		tl.chars = append(tl.chars, c)
+	}
+
+	if len(tl.chars) > 0 {
+		lines = append(lines, tl)
+	}
+
+	return lines
+}
+
+// pageChars returns the characters shown on a page.
+func pageChars(xRefTable *XRefTable, page int) ([]textChar, error) {
+
+	pageDict, inhPAttrs, err := xRefTable.PageDict(page)
+	if err != nil || pageDict == nil {
+		return nil, err
+	}
+
+	bb, err := pageContent(xRefTable, pageDict, false)
+	if err != nil || len(bb) == 0 {
+		return nil, err
+	}
+
+	te := &textExtractor{xRefTable: xRefTable, fonts: map[int]*textFont{}}
+
+	err = te.processContent(bb, inhPAttrs.resources, identMatrix, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "page %d", page)
+	}
+
+	return te.chars, nil
+}
+
+// pageTextLines returns the text lines shown on a page.
+func pageTextLines(xRefTable *XRefTable, page int) ([]textLine, error) {
+
+	chars, err := pageChars(xRefTable, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return textLines(chars), nil
+}
+
+// pageMarkedContentText returns the text of the marked-content sequences of a page by marked-content identifier.
+func pageMarkedContentText(xRefTable *XRefTable, page int) (map[int]string, error) {
+
+	chars, err := pageChars(xRefTable, page)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[int][]textChar{}
+	for _, c := range chars {
+		if c.mcid >= 0 {
+			m[c.mcid] = append(m[c.mcid], c)
+		}
+	}
+
+	texts := map[int]string{}
+	for mcid, cc := range m {
+		var ss []string
+		for _, tl := range textLines(cc) {
+			ss = append(ss, tl.String())
+		}
+		texts[mcid] = strings.Join(ss, " ")
+	}
+
+	return texts, nil
+}
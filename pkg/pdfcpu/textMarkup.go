@@ -0,0 +1,214 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"unicode"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// TextMarkupConfig controls the annotations created for text search hits.
+type TextMarkupConfig struct {
+	Subtype   string // Highlight, Underline, StrikeOut or Squiggly
+	Color     string // #RRGGBB, defaults to yellow for Highlight and red otherwise
+	MatchCase bool   // Distinguish between upper and lower case.
+}
+
+// NewTextMarkupConfig returns a configuration for case insensitive yellow highlighting.
+func NewTextMarkupConfig() *TextMarkupConfig {
+	return &TextMarkupConfig{Subtype: "Highlight"}
+}
+
+func (tmc TextMarkupConfig) validate() error {
+
+	switch tmc.Subtype {
+	case "Highlight", "Underline", "StrikeOut", "Squiggly":
+	default:
+		return errors.Errorf("unsupported text markup annotation: %s", tmc.Subtype)
+	}
+
+	if tmc.Color != "" {
+		_, err := parseXFDFColor(tmc.Color)
+		return err
+	}
+
+	return nil
+}
+
+func (tmc TextMarkupConfig) color() PDFArray {
+
+	s := tmc.Color
+	if s == "" {
+		s = "#FF0000"
+		if tmc.Subtype == "Highlight" {
+			s = "#FFFF00"
+		}
+	}
+
+	c, _ := parseXFDFColor(s)
+
+	return c
+}
+
+// foldRunes returns the runes of s in lower case unless matchCase is set.
+// Each rune is mapped individually so that indices remain valid.
+func foldRunes(s string, matchCase bool) []rune {
+
+	rr := []rune(s)
+
+	if !matchCase {
+		for i, r := range rr {
+			rr[i] = unicode.ToLower(r)
+		}
+	}
+
+	return rr
+}
+
+// textMatches returns the rune index ranges of the non overlapping occurrences of query in line.
+func textMatches(line, query []rune) [][2]int {
+
+	var mm [][2]int
+
+	for i := 0; i+len(query) <= len(line); {
+
+		j := 0
+		for j < len(query) && line[i+j] == query[j] {
+			j++
+		}
+
+		if j == len(query) {
+			mm = append(mm, [2]int{i, i + j})
+			i += j
+			continue
+		}
+
+		i++
+	}
+
+	return mm
+}
+
+// textMarkupDict creates a text markup annotation dict along with its appearance for quadrilateral q.
+func textMarkupDict(xRefTable *XRefTable, tmc *TextMarkupConfig, q [8]float64, text string) (*PDFDict, error) {
+
+	r := quadRect(q)
+	rect := NewRectangle(r.LL.X, r.LL.Y, r.UR.X, r.UR.Y)
+
+	quadPoints := NewNumberArray(q[:]...)
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Annot")
+	d.InsertName("Subtype", tmc.Subtype)
+	d.Insert("Rect", rect)
+	d.Insert("QuadPoints", quadPoints)
+	d.Insert("C", tmc.color())
+	d.Insert("Contents", encodeText(text))
+	d.InsertInt("F", annotFlagPrint)
+
+	if err := markupAppearance(xRefTable, &d, tmc.Subtype, rect, quadPoints, nil, 1); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// pageTextMarkup adds a text markup annotation for every occurrence of query within the text of a page.
+func pageTextMarkup(xRefTable *XRefTable, page int, query []rune, tmc *TextMarkupConfig) (int, error) {
+
+	lines, err := pageTextLines(xRefTable, page)
+	if err != nil || len(lines) == 0 {
+		return 0, err
+	}
+
+	pageDict, _, err := xRefTable.PageDict(page)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+
+	for _, tl := range lines {
+
+		s := tl.String()
+
+		for _, m := range textMatches(foldRunes(s, tmc.MatchCase), query) {
+
+			d, err := textMarkupDict(xRefTable, tmc, tl.quadPoints(m[0], m[1]), string([]rune(s)[m[0]:m[1]]))
+			if err != nil {
+				return 0, err
+			}
+
+			indRef, err := xRefTable.IndRefForNewObject(*d)
+			if err != nil {
+				return 0, err
+			}
+
+			if err = appendToArrayEntry(xRefTable, pageDict, "Annots", *indRef); err != nil {
+				return 0, err
+			}
+
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// HighlightText adds a text markup annotation for every occurrence of query within the text of selected pages
+// and returns the number of annotations added. Occurrences spanning multiple lines are not found.
+// If selectedPages is empty all pages are processed.
+func HighlightText(xRefTable *XRefTable, selectedPages IntSet, query string, tmc *TextMarkupConfig) (int, error) {
+
+	log.Debug.Println("HighlightText begin")
+
+	if len(query) == 0 {
+		return 0, errors.New("HighlightText: missing query")
+	}
+
+	if tmc == nil {
+		tmc = NewTextMarkupConfig()
+	}
+
+	if err := tmc.validate(); err != nil {
+		return 0, err
+	}
+
+	q := foldRunes(query, tmc.MatchCase)
+
+	var count int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		if len(selectedPages) > 0 && !selectedPages[i] {
+			continue
+		}
+
+		n, err := pageTextMarkup(xRefTable, i, q, tmc)
+		if err != nil {
+			return 0, errors.Wrap(err, "HighlightText")
+		}
+
+		count += n
+	}
+
+	log.Debug.Println("HighlightText end")
+
+	return count, nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with RFC 3161 time stamps as used by signature time stamps (PAdES B-T)
+// and document time stamps, see 12.8.5.
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	oidTSTInfo            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttrTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+)
+
+type messageImprint struct {
+	HashAlgorithm algorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional,default:false"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// Timestamper obtains time stamp tokens from a time stamp authority.
+type Timestamper interface {
+
+	// Timestamp returns a DER encoded RFC 3161 time stamp token for a SHA-256 digest.
+	Timestamp(digest []byte) ([]byte, error)
+}
+
+type tsaClient struct {
+	url string
+}
+
+// NewTSAClient returns a Timestamper requesting time stamps from the time stamp authority at url
+// using the HTTP protocol of RFC 3161.
+func NewTSAClient(url string) Timestamper {
+	return tsaClient{url: url}
+}
+
+func (c tsaClient) Timestamp(digest []byte) ([]byte, error) {
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(c.url, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, errors.Wrap(err, "time stamp request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("time stamp request: %s", resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "time stamp request")
+	}
+
+	var tsr timeStampResp
+	if _, err = asn1.Unmarshal(b, &tsr); err != nil {
+		return nil, errors.Wrap(err, "corrupt time stamp response")
+	}
+
+	// granted or grantedWithMods
+	if tsr.Status.Status > 1 || len(tsr.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.Errorf("time stamp request rejected: status %d", tsr.Status.Status)
+	}
+
+	token := tsr.TimeStampToken.FullBytes
+
+	tst, err := parseTimeStampToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(tst.MessageImprint.HashedMessage, digest) || tst.Nonce == nil || tst.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("time stamp response does not match request")
+	}
+
+	return token, nil
+}
+
+// parseTimeStampToken returns the time stamp info of a time stamp token.
+func parseTimeStampToken(token []byte) (*tstInfo, error) {
+
+	sd, err := parseSignedData(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sd.ContentInfo.ContentType.Equal(oidTSTInfo) {
+		return nil, errors.New("time stamp token without time stamp info")
+	}
+
+	return parseTSTInfo(sd.ContentInfo.Content.Bytes)
+}
+
+// parseTSTInfo parses the encapsulated content of a time stamp token.
+func parseTSTInfo(eContent []byte) (*tstInfo, error) {
+
+	var b []byte
+	if _, err := asn1.Unmarshal(eContent, &b); err != nil {
+		return nil, errors.Wrap(err, "corrupt time stamp info")
+	}
+
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(b, &tst); err != nil {
+		return nil, errors.Wrap(err, "corrupt time stamp info")
+	}
+
+	return &tst, nil
+}
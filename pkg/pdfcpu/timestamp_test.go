@@ -0,0 +1,206 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCertificate(cn string, tsa bool, t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %v\n", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	if tsa {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v\n", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v\n", err)
+	}
+
+	return cert, key
+}
+
+// testTSA returns a time stamp authority answering RFC 3161 requests, optionally with a wrong message imprint.
+func testTSA(signer Signer, wrongImprint bool, t *testing.T) *httptest.Server {
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		b, _ := ioutil.ReadAll(r.Body)
+
+		var req timeStampReq
+		if _, err := asn1.Unmarshal(b, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if wrongImprint {
+			req.MessageImprint.HashedMessage = make([]byte, sha256.Size)
+		}
+
+		tst, err := asn1.Marshal(tstInfo{
+			Version:        1,
+			Policy:         asn1.ObjectIdentifier{1, 2, 3},
+			MessageImprint: req.MessageImprint,
+			SerialNumber:   big.NewInt(42),
+			GenTime:        time.Now().UTC().Truncate(time.Second),
+			Nonce:          req.Nonce,
+		})
+		if err != nil {
+			t.Fatalf("testTSA: %v\n", err)
+		}
+
+		h := sha256.Sum256(tst)
+
+		token, err := signedDataCMS(oidTSTInfo, tst, h[:], signer, nil)
+		if err != nil {
+			t.Fatalf("testTSA: %v\n", err)
+		}
+
+		resp, err := asn1.Marshal(timeStampResp{TimeStampToken: asn1.RawValue{FullBytes: token}})
+		if err != nil {
+			t.Fatalf("testTSA: %v\n", err)
+		}
+
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(resp)
+	}))
+}
+
+func TestTimestamps(t *testing.T) {
+
+	cert, key := testCertificate("alice", false, t)
+	tsaCert, tsaKey := testCertificate("tsa", true, t)
+
+	tsa := testTSA(NewSigner(tsaKey, []*x509.Certificate{tsaCert}), false, t)
+	defer tsa.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	roots.AddCert(tsaCert)
+
+	xRefTable, err := CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("CreateDemoXRef: %v\n", err)
+	}
+
+	fileName := filepath.Join(outDir, "timestamps.pdf")
+	if err = CreatePDF(xRefTable, outDir+"/", "timestamps.pdf"); err != nil {
+		t.Fatalf("CreatePDF: %v\n", err)
+	}
+
+	// Sign with signature time stamp, then add a document time stamp.
+	for i, f := range []func(ctx *PDFContext, orig []byte) ([]byte, error){
+		func(ctx *PDFContext, orig []byte) ([]byte, error) {
+			sa := &SignatureAttributes{Page: 1, Timestamper: NewTSAClient(tsa.URL)}
+			return Sign(ctx, orig, sa, NewSigner(key, []*x509.Certificate{cert}))
+		},
+		func(ctx *PDFContext, orig []byte) ([]byte, error) {
+			return DocTimeStamp(ctx, orig, "", NewTSAClient(tsa.URL))
+		},
+	} {
+
+		orig, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			t.Fatalf("%d: %v\n", i, err)
+		}
+
+		ctx, err := ReadPDFFile(fileName, NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("%d: ReadPDFFile: %v\n", i, err)
+		}
+
+		b, err := f(ctx, orig)
+		if err != nil {
+			t.Fatalf("%d: %v\n", i, err)
+		}
+
+		if err = ioutil.WriteFile(fileName, b, 0644); err != nil {
+			t.Fatalf("%d: %v\n", i, err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("%v\n", err)
+	}
+
+	ctx, err := ReadPDFFile(fileName, NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("ReadPDFFile: %v\n", err)
+	}
+
+	if err = ValidateXRefTable(ctx.XRefTable); err != nil {
+		t.Fatalf("ValidateXRefTable: %v\n", err)
+	}
+
+	srs, err := VerifySignatures(ctx, b, roots)
+	if err != nil {
+		t.Fatalf("VerifySignatures: %v\n", err)
+	}
+
+	if len(srs) != 2 {
+		t.Fatalf("VerifySignatures: got %d signatures, want 2\n", len(srs))
+	}
+
+	for _, sr := range srs {
+		if !sr.Valid() || sr.Timestamp.IsZero() {
+			t.Errorf("VerifySignatures:\n%s\n", sr)
+		}
+	}
+
+	if srs[1].SubFilter != "ETSI.RFC3161" || !srs[1].WholeFile {
+		t.Errorf("VerifySignatures: missing document time stamp:\n%s\n", srs[1])
+	}
+
+	// A time stamp authority answering with a wrong message imprint is detected.
+	wrong := testTSA(NewSigner(tsaKey, []*x509.Certificate{tsaCert}), true, t)
+	defer wrong.Close()
+
+	digest := sha256.Sum256([]byte("pdfcpu"))
+	if _, err = NewTSAClient(wrong.URL).Timestamp(digest[:]); err == nil {
+		t.Fatal("Timestamp: should have failed for wrong message imprint")
+	}
+}
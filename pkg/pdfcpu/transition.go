@@ -0,0 +1,338 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Page transitions
+//
+// Viewers in presentation mode use the Trans dict of a page (12.4.4) for the effect moving to the page
+// and advance to the next page automatically after the Dur seconds of a page.
+// Print versions usually go without both.
+
+// Transition represents the presentation settings of a page.
+type Transition struct {
+	Style     string  // Split, Blinds, Box, Wipe, Dissolve, Glitter, R, Fly, Push, Cover, Uncover or Fade, empty for Dur only
+	Duration  float64 // D: duration of the effect in seconds, 0 for the default of 1 second
+	Dimension string  // Dm: H or V, Split and Blinds only
+	Motion    string  // M: I or O, Split, Box and Fly only
+	Direction int     // Di: 0, 90, 180, 270 or 315 degrees, Wipe, Glitter, Fly, Cover, Uncover and Push only
+	PageDur   float64 // Dur: display duration in seconds before advancing to the next page, 0 for none
+}
+
+var transitionStyles = []string{"Split", "Blinds", "Box", "Wipe", "Dissolve", "Glitter", "R", "Fly", "Push", "Cover", "Uncover", "Fade"}
+
+// transitionStyle returns the transition style for s ignoring case.
+func transitionStyle(s string) (string, error) {
+
+	for _, style := range transitionStyles {
+		if strings.EqualFold(s, style) {
+			return style, nil
+		}
+	}
+
+	return "", errors.Errorf("unsupported transition style: %s, use one of %s\n", s, strings.Join(transitionStyles, ", "))
+}
+
+func parseTransitionSeconds(k, v string) (float64, error) {
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, errors.Errorf("%s must be a positive number of seconds: %s\n", k, v)
+	}
+
+	return f, nil
+}
+
+func parseTransitionConfig(ss []string, t *Transition) error {
+
+	for _, s := range ss {
+
+		ss1 := strings.Split(s, ":")
+		if len(ss1) != 2 {
+			return errors.Errorf("illegal transition details: %s\n", s)
+		}
+
+		k := strings.TrimSpace(ss1[0])
+		v := strings.TrimSpace(ss1[1])
+
+		var err error
+
+		switch k {
+
+		case "d": // duration of the effect
+			t.Duration, err = parseTransitionSeconds("duration", v)
+
+		case "dm": // dimension
+			if v != "H" && v != "V" {
+				err = errors.Errorf("illegal dimension: H or V, %s\n", v)
+			}
+			t.Dimension = v
+
+		case "m": // motion
+			if v != "I" && v != "O" {
+				err = errors.Errorf("illegal motion: I or O, %s\n", v)
+			}
+			t.Motion = v
+
+		case "di": // direction
+			t.Direction, err = strconv.Atoi(v)
+			if err != nil || !memberOf(v, []string{"0", "90", "180", "270", "315"}) {
+				err = errors.Errorf("illegal direction: 0, 90, 180, 270 or 315, %s\n", v)
+			}
+
+		case "dur": // display duration of the page
+			t.PageDur, err = parseTransitionSeconds("dur", v)
+
+		default:
+			err = errors.Errorf("unknown transition parameter: %s\n", k)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseTransitionDetails parses a transition command string like "wipe, d:1.5, di:90, dur:5" into a Transition.
+// The style may be omitted for a display duration only.
+func ParseTransitionDetails(s string) (*Transition, error) {
+
+	t := &Transition{}
+
+	ss := strings.Split(s, ",")
+
+	if !strings.Contains(ss[0], ":") {
+		style, err := transitionStyle(strings.TrimSpace(ss[0]))
+		if err != nil {
+			return nil, err
+		}
+		t.Style = style
+		ss = ss[1:]
+	}
+
+	if err := parseTransitionConfig(ss, t); err != nil {
+		return nil, err
+	}
+
+	if t.Style == "" && t.PageDur == 0 {
+		return nil, errors.New("missing transition style or dur")
+	}
+
+	return t, nil
+}
+
+// transitionDict returns the Trans dict for t.
+func (t Transition) transitionDict() PDFDict {
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Trans")
+	d.InsertName("S", t.Style)
+
+	if t.Duration > 0 {
+		d.Insert("D", PDFFloat(t.Duration))
+	}
+
+	if t.Dimension != "" && (t.Style == "Split" || t.Style == "Blinds") {
+		d.InsertName("Dm", t.Dimension)
+	}
+
+	if t.Motion != "" && (t.Style == "Split" || t.Style == "Box" || t.Style == "Fly") {
+		d.InsertName("M", t.Motion)
+	}
+
+	if memberOf(t.Style, []string{"Wipe", "Glitter", "Fly", "Cover", "Uncover", "Push"}) {
+		d.InsertInt("Di", t.Direction)
+	}
+
+	return d
+}
+
+// String returns t in the syntax of ParseTransitionDetails.
+func (t Transition) String() string {
+
+	var ss []string
+
+	if t.Style != "" {
+		ss = append(ss, t.Style)
+	}
+
+	if t.Duration > 0 {
+		ss = append(ss, "d:"+strconv.FormatFloat(t.Duration, 'f', -1, 64))
+	}
+
+	if t.Dimension != "" {
+		ss = append(ss, "dm:"+t.Dimension)
+	}
+
+	if t.Motion != "" {
+		ss = append(ss, "m:"+t.Motion)
+	}
+
+	if memberOf(t.Style, []string{"Wipe", "Glitter", "Fly", "Cover", "Uncover", "Push"}) {
+		ss = append(ss, fmt.Sprintf("di:%d", t.Direction))
+	}
+
+	if t.PageDur > 0 {
+		ss = append(ss, "dur:"+strconv.FormatFloat(t.PageDur, 'f', -1, 64))
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+func numberEntry(xRefTable *XRefTable, d *PDFDict, key string) float64 {
+
+	o, err := xRefTable.Dereference(d.Dict[key])
+	if err != nil {
+		return 0
+	}
+
+	switch o := o.(type) {
+	case PDFInteger:
+		return float64(o.Value())
+	case PDFFloat:
+		return o.Value()
+	}
+
+	return 0
+}
+
+// Transitions returns the transitions of all pages by page number.
+func Transitions(xRefTable *XRefTable) (map[int]Transition, error) {
+
+	m := map[int]Transition{}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		t := Transition{PageDur: numberEntry(xRefTable, pageDict, "Dur")}
+
+		d, err := xRefTable.DereferenceDict(pageDict.Dict["Trans"])
+		if err != nil {
+			return nil, err
+		}
+
+		if d != nil {
+			t.Style = "R"
+			if s := nameEntry(xRefTable, d, "S"); s != "" {
+				t.Style = s
+			}
+			t.Duration = numberEntry(xRefTable, d, "D")
+			t.Dimension = nameEntry(xRefTable, d, "Dm")
+			t.Motion = nameEntry(xRefTable, d, "M")
+			t.Direction = int(numberEntry(xRefTable, d, "Di"))
+		}
+
+		if d != nil || t.PageDur > 0 {
+			m[i] = t
+		}
+	}
+
+	return m, nil
+}
+
+// SetTransitions sets the transition t for the selected pages.
+// A transition without style leaves the Trans dicts alone and sets the display duration only.
+func SetTransitions(xRefTable *XRefTable, pages IntSet, t Transition) error {
+
+	if t.Style != "" {
+		style, err := transitionStyle(t.Style)
+		if err != nil {
+			return err
+		}
+		t.Style = style
+	}
+
+	for i, v := range pages {
+
+		if !v || i < 1 || i > xRefTable.PageCount {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		if t.Style != "" {
+			pageDict.Update("Trans", t.transitionDict())
+		}
+
+		if t.PageDur > 0 {
+			pageDict.Update("Dur", PDFFloat(t.PageDur))
+		}
+	}
+
+	// Styles added in PDF 1.5 need V1.5.
+	if memberOf(t.Style, []string{"Fly", "Push", "Cover", "Uncover", "Fade"}) && xRefTable.Version() < V15 {
+		log.Debug.Printf("SetTransitions: %s ensures V1.5\n", t.Style)
+		v := V15
+		xRefTable.RootVersion = &v
+	}
+
+	return nil
+}
+
+// RemoveTransitions removes the Trans and Dur entries of the selected pages.
+func RemoveTransitions(xRefTable *XRefTable, pages IntSet) (int, error) {
+
+	var n int
+
+	for i, v := range pages {
+
+		if !v || i < 1 || i > xRefTable.PageCount {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		trans, dur := pageDict.Delete("Trans"), pageDict.Delete("Dur")
+		if trans != nil || dur != nil {
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// TrueType font programs use big endian always.
+
+// trueTypeFont holds the metrics of a TrueType font program needed for embedding it as a simple font.
+type trueTypeFont struct {
+	unitsPerEm      int
+	bbox            [4]int // xMin, yMin, xMax, yMax in font units
+	ascent, descent int
+	capHeight       int
+	italicAngle     float64
+	fixedPitch      bool
+	advances        []int // advance widths by glyph index in font units
+	cmap            []byte
+}
+
+func u16(b []byte, off int) int { return int(binary.BigEndian.Uint16(b[off:])) }
+
+func i16(b []byte, off int) int { return int(int16(binary.BigEndian.Uint16(b[off:]))) }
+
+// parseTrueType reads the metrics and the Unicode cmap of the TrueType font program b.
+func parseTrueType(b []byte) (*trueTypeFont, error) {
+
+	if len(b) < 12 {
+		return nil, errors.New("truetype: file too short")
+	}
+
+	if v := binary.BigEndian.Uint32(b); v != 0x00010000 && v != 0x74727565 { // 'true'
+		return nil, errors.New("truetype: no TrueType outlines")
+	}
+
+	tables := map[string][]byte{}
+
+	n := u16(b, 4)
+	for i := 0; i < n; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(b) {
+			return nil, errors.New("truetype: corrupt table directory")
+		}
+		off, l := int(binary.BigEndian.Uint32(b[rec+8:])), int(binary.BigEndian.Uint32(b[rec+12:]))
+		if off < 0 || l < 0 || off+l > len(b) {
+			return nil, errors.Errorf("truetype: corrupt table %s", b[rec:rec+4])
+		}
+		tables[string(b[rec:rec+4])] = b[off : off+l]
+	}
+
+	for tag, min := range map[string]int{"head": 54, "hhea": 36, "hmtx": 0, "cmap": 4, "post": 16} {
+		if len(tables[tag]) < min {
+			return nil, errors.Errorf("truetype: missing table %s", tag)
+		}
+	}
+
+	head, hhea, post := tables["head"], tables["hhea"], tables["post"]
+
+	f := &trueTypeFont{
+		unitsPerEm:  u16(head, 18),
+		bbox:        [4]int{i16(head, 36), i16(head, 38), i16(head, 40), i16(head, 42)},
+		ascent:      i16(hhea, 4),
+		descent:     i16(hhea, 6),
+		italicAngle: float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536,
+		fixedPitch:  binary.BigEndian.Uint32(post[12:]) != 0,
+	}
+
+	if f.unitsPerEm == 0 {
+		return nil, errors.New("truetype: unitsPerEm 0")
+	}
+
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && u16(os2, 0) >= 2 {
+		f.capHeight = i16(os2, 88)
+	}
+
+	hmtx := tables["hmtx"]
+	for i := 0; i < u16(hhea, 34) && 4*i+2 <= len(hmtx); i++ {
+		f.advances = append(f.advances, u16(hmtx, 4*i))
+	}
+
+	if len(f.advances) == 0 {
+		return nil, errors.New("truetype: missing horizontal metrics")
+	}
+
+	// Use the Windows Unicode BMP cmap or else any Unicode cmap in format 4.
+	cmap := tables["cmap"]
+	for i := 0; i < u16(cmap, 2) && 4+8*i+8 <= len(cmap); i++ {
+		rec := 4 + 8*i
+		platformID, encodingID := u16(cmap, rec), u16(cmap, rec+2)
+		off := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if off+14 > len(cmap) || u16(cmap, off) != 4 {
+			continue
+		}
+		if platformID == 3 && encodingID == 1 || platformID == 0 && f.cmap == nil {
+			f.cmap = cmap[off:]
+		}
+	}
+
+	if f.cmap == nil {
+		return nil, errors.New("truetype: missing Unicode cmap")
+	}
+
+	return f, nil
+}
+
+// glyphIndex returns the glyph index for r, 0 if r is not mapped.
+func (f *trueTypeFont) glyphIndex(r rune) int {
+
+	c := int(r)
+	if c > 0xFFFF {
+		return 0
+	}
+
+	b := f.cmap
+	segCount := u16(b, 6) / 2
+
+	endCodes := 14
+	startCodes := endCodes + 2*segCount + 2
+	idDeltas := startCodes + 2*segCount
+	idRangeOffsets := idDeltas + 2*segCount
+
+	if idRangeOffsets+2*segCount > len(b) {
+		return 0
+	}
+
+	for i := 0; i < segCount; i++ {
+
+		if c > u16(b, endCodes+2*i) {
+			continue
+		}
+
+		start := u16(b, startCodes+2*i)
+		if c < start {
+			return 0
+		}
+
+		delta := u16(b, idDeltas+2*i)
+
+		ro := u16(b, idRangeOffsets+2*i)
+		if ro == 0 {
+			return (c + delta) & 0xFFFF
+		}
+
+		off := idRangeOffsets + 2*i + ro + 2*(c-start)
+		if off+2 > len(b) {
+			return 0
+		}
+
+		g := u16(b, off)
+		if g == 0 {
+			return 0
+		}
+
+		return (g + delta) & 0xFFFF
+	}
+
+	return 0
+}
+
+// width returns the advance width of r in glyph space, that is in 1/1000 of text space.
+func (f *trueTypeFont) width(r rune) int {
+
+	g := f.glyphIndex(r)
+
+	// Glyphs beyond the horizontal metrics share the last advance width.
+	if g >= len(f.advances) {
+		g = len(f.advances) - 1
+	}
+
+	return f.scale(f.advances[g])
+}
+
+// scale converts font units into glyph space.
+func (f *trueTypeFont) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
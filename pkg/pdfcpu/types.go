@@ -20,6 +20,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -94,7 +95,14 @@ type PDFFloat float64
 
 func (f PDFFloat) String() string {
 	// strconv may be faster.
-	return fmt.Sprintf("%.2f", float64(f))
+	s := fmt.Sprintf("%.2f", float64(f))
+
+	// Keep the precision of eg. geographic coordinates.
+	if v, err := strconv.ParseFloat(s, 64); err == nil && v != float64(f) {
+		s = strings.TrimSuffix(strings.TrimRight(strconv.FormatFloat(float64(f), 'f', 10, 64), "0"), ".")
+	}
+
+	return s
 }
 
 // PDFString returns a string representation as found in and written to a PDF file.
@@ -0,0 +1,68 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// RemoveUsageRights removes the usage rights signatures (UR, UR3) enabling additional features in viewers, see 12.8.2.3.
+// Once a Reader enabled form has been edited by anything but the viewer these signatures no longer verify,
+// which makes viewers warn about invalidated rights and disable the features granted.
+// The permissions dict of the document catalog is removed unless it still holds a DocMDP certification signature.
+// RemoveUsageRights returns true if any usage rights were removed.
+func RemoveUsageRights(xRefTable *XRefTable) (bool, error) {
+
+	log.Debug.Println("RemoveUsageRights begin")
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return false, errors.Wrap(err, "RemoveUsageRights")
+	}
+
+	obj, found := rootDict.Find("Perms")
+	if !found {
+		log.Debug.Println("RemoveUsageRights end: no permissions dict")
+		return false, nil
+	}
+
+	d, err := xRefTable.DereferenceDict(obj)
+	if err != nil {
+		return false, errors.Wrap(err, "RemoveUsageRights")
+	}
+
+	removed := false
+
+	if d != nil {
+		for _, k := range []string{"UR", "UR3"} {
+			if _, found := d.Find(k); found {
+				d.Delete(k)
+				removed = true
+			}
+		}
+	}
+
+	// The signature dicts are no longer referenced and therefore dropped on write.
+	if d == nil || d.Len() == 0 {
+		rootDict.Delete("Perms")
+	}
+
+	log.Debug.Println("RemoveUsageRights end")
+
+	return removed, nil
+}
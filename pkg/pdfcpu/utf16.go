@@ -142,6 +142,21 @@ func DecodeUTF16String(s string) (string, error) {
 	return decodeUTF16String([]byte(s))
 }
 
+// EncodeUTF16String encodes s as UTF-16BE including the byte order mark.
+func EncodeUTF16String(s string) string {
+
+	rr := utf16.Encode([]rune(s))
+
+	b := make([]byte, 2+2*len(rr))
+	b[0], b[1] = 0xFE, 0xFF
+	for i, r := range rr {
+		b[2+2*i] = byte(r >> 8)
+		b[3+2*i] = byte(r)
+	}
+
+	return string(b)
+}
+
 // StringLiteralToString returns the best possible string rep for a string literal.
 func StringLiteralToString(s string) (string, error) {
 
@@ -88,7 +88,7 @@ func validateAppearanceDict(xRefTable *XRefTable, obj PDFObject) error {
 	// Normal Appearance
 	obj, ok := dict.Find("N")
 	if !ok {
-		if xRefTable.ValidationMode == ValidationStrict {
+		if xRefTable.required(dict, "appearanceDict", "N") {
 			return errors.New("validateAppearanceDict: missing required entry \"N\"")
 		}
 	} else {
@@ -807,6 +807,30 @@ func validateActionDictCore(xRefTable *XRefTable, n *PDFName, dict *PDFDict) err
 }
 
 func validateActionDict(xRefTable *XRefTable, dict *PDFDict) error {
+	return validateActionDictChain(xRefTable, dict, nil)
+}
+
+// validateNextAction validates a follow up action and guards against "Next" chains leading back to an ancestor.
+func validateNextAction(xRefTable *XRefTable, obj PDFObject, d *PDFDict, path []int) error {
+
+	if d == nil {
+		return nil
+	}
+
+	if indRef, ok := obj.(PDFIndirectRef); ok {
+		objNr := indRef.ObjectNumber.Value()
+		for i, nr := range path {
+			if nr == objNr {
+				return xRefTable.reportCycle("validateActionDict", path[i:])
+			}
+		}
+		path = append(path, objNr)
+	}
+
+	return validateActionDictChain(xRefTable, d, path)
+}
+
+func validateActionDictChain(xRefTable *XRefTable, dict *PDFDict, path []int) error {
 
 	dictName := "actionDict"
 
@@ -832,11 +856,7 @@ func validateActionDict(xRefTable *XRefTable, dict *PDFDict) error {
 		// either optional action dict
 		d, err := xRefTable.DereferenceDict(obj)
 		if err == nil {
-			err = validateActionDict(xRefTable, d)
-			if err != nil {
-				return err
-			}
-			return nil
+			return validateNextAction(xRefTable, obj, d, path)
 		}
 
 		// or optional array of action dicts
@@ -856,7 +876,7 @@ func validateActionDict(xRefTable *XRefTable, dict *PDFDict) error {
 				continue
 			}
 
-			err = validateActionDict(xRefTable, d)
+			err = validateNextAction(xRefTable, v, d, path)
 			if err != nil {
 				return err
 			}
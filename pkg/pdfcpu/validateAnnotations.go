@@ -333,10 +333,7 @@ func validateAnnotationDictLink(xRefTable *XRefTable, dict *PDFDict, dictName st
 	}
 
 	// BS, optional, border style dict, since V1.6
-	sinceVersion := V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "BS", V16, V13)
 
 	return validateBorderStyleDict(xRefTable, dict, dictName, "BS", OPTIONAL, sinceVersion)
 }
@@ -350,20 +347,14 @@ func validateAnnotationDictFreeTextPart1(xRefTable *XRefTable, dict *PDFDict, di
 	}
 
 	// Q, optional, integer, since V1.4, 0,1,2
-	sinceVersion = V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "Q", V14, V13)
 	_, err = validateIntegerEntry(xRefTable, dict, dictName, "Q", OPTIONAL, sinceVersion, func(i int) bool { return 0 <= i && i <= 2 })
 	if err != nil {
 		return err
 	}
 
 	// RC, optional, text string or text stream, since V1.5
-	sinceVersion = V15
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "RC", V15, V14)
 	err = validateStringOrStreamEntry(xRefTable, dict, dictName, "RC", OPTIONAL, sinceVersion)
 	if err != nil {
 		return err
@@ -376,10 +367,7 @@ func validateAnnotationDictFreeTextPart1(xRefTable *XRefTable, dict *PDFDict, di
 	}
 
 	// CL, optional, number array, since V1.6, len: 4 or 6
-	sinceVersion = V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "CL", V16, V14)
 
 	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "CL", OPTIONAL, sinceVersion, func(a PDFArray) bool { return len(a) == 4 || len(a) == 6 })
 
@@ -389,10 +377,7 @@ func validateAnnotationDictFreeTextPart1(xRefTable *XRefTable, dict *PDFDict, di
 func validateAnnotationDictFreeTextPart2(xRefTable *XRefTable, dict *PDFDict, dictName string, sinceVersion PDFVersion) error {
 
 	// IT, optional, name, since V1.6
-	sinceVersion = V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "IT", V16, V14)
 	validate := func(s string) bool {
 		return memberOf(s, []string{"FreeText", "FreeTextCallout", "FreeTextTypeWriter", "FreeTextTypewriter"})
 	}
@@ -408,30 +393,21 @@ func validateAnnotationDictFreeTextPart2(xRefTable *XRefTable, dict *PDFDict, di
 	}
 
 	// RD, optional, rectangle, since V1.6
-	sinceVersion = V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "RD", V16, V14)
 	_, err = validateRectangleEntry(xRefTable, dict, dictName, "RD", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
 	}
 
 	// BS, optional, border style dict, since V1.6
-	sinceVersion = V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "BS", V16, V13)
 	err = validateBorderStyleDict(xRefTable, dict, dictName, "BS", OPTIONAL, sinceVersion)
 	if err != nil {
 		return err
 	}
 
 	// LE, optional, name, since V1.6
-	sinceVersion = V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "LE", V16, V14)
 	_, err = validateNameEntry(xRefTable, dict, dictName, "LE", OPTIONAL, sinceVersion, nil)
 
 	return err
@@ -485,16 +461,14 @@ func validateAnnotationDictLine(xRefTable *XRefTable, dict *PDFDict, dictName st
 	}
 
 	// LE, optional, name array, since V1.4, len:2
-	sinceVersion := V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "LE", V14, V13)
 	_, err = validateNameArrayEntry(xRefTable, dict, dictName, "LE", OPTIONAL, sinceVersion, func(a PDFArray) bool { return len(a) == 2 })
 	if err != nil {
 		return err
 	}
 
 	// IC, optional, number array, since V1.4, len:0,1,3,4
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "IC", V14, V13)
 	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "IC", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -559,10 +533,7 @@ func validateAnnotationDictCircleOrSquare(xRefTable *XRefTable, dict *PDFDict, d
 	}
 
 	// IC, optional, array, since V1.4
-	sinceVersion := V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "IC", V14, V13)
 	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "IC", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -589,7 +560,7 @@ func validateEntryIT(xRefTable *XRefTable, dict *PDFDict, dictName string, requi
 			return s == "PolygonCloud"
 		}
 
-		if xRefTable.Version() == V17 {
+		if xRefTable.Version() >= V17 {
 			if memberOf(s, []string{"PolygonCloud", "PolyLineDimension", "PolygonDimension"}) {
 				return true
 			}
@@ -1161,6 +1132,54 @@ func validateAnnotationDictRedact(xRefTable *XRefTable, dict *PDFDict, dictName
 	return err
 }
 
+func validateRichMediaContentDict(xRefTable *XRefTable, dict *PDFDict) error {
+
+	dictName := "richMediaContentDict"
+
+	_, err := validateNameEntry(xRefTable, dict, dictName, "Type", OPTIONAL, V17, func(s string) bool { return s == "RichMediaContent" })
+	if err != nil {
+		return err
+	}
+
+	// Assets, optional, name tree of embedded file specifications
+	_, err = validateDictEntry(xRefTable, dict, dictName, "Assets", OPTIONAL, V17, nil)
+	if err != nil {
+		return err
+	}
+
+	// Configurations, optional, array of configuration dicts
+	_, err = validateArrayEntry(xRefTable, dict, dictName, "Configurations", OPTIONAL, V17, nil)
+	if err != nil {
+		return err
+	}
+
+	// Views, optional, array of 3D view dicts
+	_, err = validateArrayEntry(xRefTable, dict, dictName, "Views", OPTIONAL, V17, nil)
+
+	return err
+}
+
+func validateAnnotationDictRichMedia(xRefTable *XRefTable, dict *PDFDict, dictName string) error {
+
+	// see Adobe Supplement to ISO 32000, extension level 3, 9.6.2
+
+	// RichMediaContent, required, dict
+	d, err := validateDictEntry(xRefTable, dict, dictName, "RichMediaContent", REQUIRED, V17, nil)
+	if err != nil {
+		return err
+	}
+
+	err = validateRichMediaContentDict(xRefTable, d)
+	if err != nil {
+		return err
+	}
+
+	// RichMediaSettings, optional, dict
+	_, err = validateDictEntry(xRefTable, dict, dictName, "RichMediaSettings", OPTIONAL, V17, nil)
+
+	return err
+}
+
 func validateExDataDict(xRefTable *XRefTable, dict *PDFDict) error {
 
 	dictName := "ExData"
@@ -1257,10 +1276,7 @@ func validateMarkupAnnotation(xRefTable *XRefTable, dict *PDFDict) error {
 	}
 
 	// Subj, optional, text string, since V1.5
-	sinceVersion := V15
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "Subj", V15, V14)
 	_, err = validateStringEntry(xRefTable, dict, dictName, "Subj", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -1423,6 +1439,12 @@ func validateAnnotationDictGeneral(xRefTable *XRefTable, dict *PDFDict, dictName
 		return nil, err
 	}
 
+	// AF, optional, array of file specification dicts, since V2.0
+	err = validateAssociatedFilesEntry(xRefTable, dict, dictName, OPTIONAL, V20)
+	if err != nil {
+		return nil, err
+	}
+
 	return subtype, nil
 }
 
@@ -1461,6 +1483,7 @@ func validateAnnotationDictConcrete(xRefTable *XRefTable, dict *PDFDict, subtype
 		"Watermark":      {validateAnnotationDictWatermark, V16, false},
 		"3D":             {validateAnnotationDict3D, V16, false},
 		"Redact":         {validateAnnotationDictRedact, V17, true},
+		"RichMedia":      {validateAnnotationDictRichMedia, V17, false},
 	} {
 		if subtype.Value() == k {
 
@@ -1531,6 +1554,10 @@ func validatePageAnnotations(xRefTable *XRefTable, dict *PDFDict) error {
 			return errors.New("validatePageAnnotations: corrupted page annotation list, \"TrapNet\" has to be the last entry")
 		}
 
+		if err = xRefTable.canceled(); err != nil {
+			return err
+		}
+
 		if indRef, ok := v.(PDFIndirectRef); ok {
 
 			log.Debug.Printf("processing annotDict %d\n", indRef.ObjectNumber)
@@ -1613,3 +1640,15 @@ func validatePagesAnnotations(xRefTable *XRefTable, dict *PDFDict) error {
 
 	return nil
 }
+
+func validatePageAnnotationsConcurrently(xRefTable *XRefTable, dict *PDFDict, objNr int) error {
+
+	pages, err := collectPageNodes(xRefTable, dict, objNr)
+	if err != nil {
+		return err
+	}
+
+	return xRefTable.processConcurrently(len(pages), func(i int) error {
+		return validatePageAnnotations(xRefTable, pages[i].dict)
+	})
+}
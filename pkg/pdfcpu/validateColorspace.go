@@ -411,10 +411,7 @@ func validateDeviceNColorSpaceAttributesDict(xRefTable *XRefTable, obj PDFObject
 		}
 	}
 
-	sinceVersion := V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "Process", V16, V13)
 
 	d, err = validateDictEntry(xRefTable, dict, dictName, "Process", OPTIONAL, sinceVersion, nil)
 	if err != nil {
@@ -73,10 +73,9 @@ func validateDestinationArray(xRefTable *XRefTable, arr *PDFArray) error {
 	switch len(*arr) {
 
 	case 2:
-		if xRefTable.ValidationMode == ValidationRelaxed {
-			nameErr = !memberOf(name.Value(), []string{"Fit", "FitB", "FitH"})
-		} else {
-			nameErr = !memberOf(name.Value(), []string{"Fit", "FitB"})
+		nameErr = !memberOf(name.Value(), []string{"Fit", "FitB"})
+		if name.Value() == "FitH" {
+			nameErr = !xRefTable.tolerate(RuleDestination, "FitH destination without top coordinate")
 		}
 
 	case 3:
@@ -787,50 +787,35 @@ func validateExtGStateDictPart2(xRefTable *XRefTable, dict *PDFDict, dictName st
 func validateExtGStateDictPart3(xRefTable *XRefTable, dict *PDFDict, dictName string) error {
 
 	// BM, name or array, optional, since V1.4
-	sinceVersion := V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "BM", V14, V13)
 	err := validateBlendModeEntry(xRefTable, dict, dictName, "BM", OPTIONAL, sinceVersion)
 	if err != nil {
 		return err
 	}
 
 	// SMask, dict or name, optional, since V1.4
-	sinceVersion = V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "SMask", V14, V13)
 	err = validateSoftMaskEntry(xRefTable, dict, dictName, "SMask", OPTIONAL, sinceVersion)
 	if err != nil {
 		return err
 	}
 
 	// CA, number, optional, since V1.4, current stroking alpha constant, see 11.3.7.2 and 11.6.4.4
-	sinceVersion = V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "CA", V14, V13)
 	_, err = validateNumberEntry(xRefTable, dict, dictName, "CA", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
 	}
 
 	// ca, number, optional, since V1.4, same as CA but for nonstroking operations.
-	sinceVersion = V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "ca", V14, V13)
 	_, err = validateNumberEntry(xRefTable, dict, dictName, "ca", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
 	}
 
 	// AIS, alpha source flag "alpha is shape", boolean, optional, since V1.4
-	sinceVersion = V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "AIS", V14, V13)
 	_, err = validateBooleanEntry(xRefTable, dict, dictName, "AIS", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
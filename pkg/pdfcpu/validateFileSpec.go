@@ -78,7 +78,7 @@ func validateEmbeddedFileStreamMacParameterDict(xRefTable *XRefTable, dict *PDFD
 func validateEmbeddedFileStreamParameterDict(xRefTable *XRefTable, obj PDFObject) error {
 
 	dict, err := xRefTable.DereferenceDict(obj)
-	if err != nil || obj == nil {
+	if err != nil || dict == nil {
 		return err
 	}
 
@@ -274,7 +274,9 @@ func validateFileSpecDictEntriesEFAndRF(xRefTable *XRefTable, efDict, rfDict *PD
 
 func validateFileSpecDictType(xRefTable *XRefTable, dict *PDFDict) error {
 
-	if dict.Type() == nil || (*dict.Type() != "Filespec" && (xRefTable.ValidationMode == ValidationRelaxed && *dict.Type() != "F")) {
+	t := dict.Type()
+
+	if t == nil || *t != "Filespec" && !(*t == "F" && xRefTable.tolerate(RuleFileSpec, "file specification of type F")) {
 		return errors.New("validateFileSpecDictType: missing type: FileSpec")
 	}
 
@@ -301,7 +303,7 @@ func validateFileSpecDictEFAndRF(xRefTable *XRefTable, dict *PDFDict, dictName s
 
 	// Type, required if EF present, name
 	validate := func(s string) bool {
-		return s == "Filespec" || (xRefTable.ValidationMode == ValidationRelaxed && s == "F")
+		return s == "Filespec" || s == "F" && xRefTable.tolerate(RuleFileSpec, "file specification of type F")
 	}
 	_, err = validateNameEntry(xRefTable, dict, dictName, "Type", efDict != nil, V10, validate)
 	if err != nil {
@@ -357,10 +359,7 @@ func validateFileSpecDict(xRefTable *XRefTable, dict *PDFDict) error {
 	}
 
 	// UF, optional, text string
-	sinceVersion := V17
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "UF", V17, V14)
 	_, err = validateStringEntry(xRefTable, dict, dictName, "UF", OPTIONAL, sinceVersion, validateFileSpecString)
 	if err != nil {
 		return err
@@ -384,10 +383,7 @@ func validateFileSpecDict(xRefTable *XRefTable, dict *PDFDict) error {
 	}
 
 	// Desc, optional, text string, since V1.6
-	sinceVersion = V16
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V10
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "Desc", V16, V10)
 	_, err = validateStringEntry(xRefTable, dict, dictName, "Desc", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -395,10 +391,104 @@ func validateFileSpecDict(xRefTable *XRefTable, dict *PDFDict) error {
 
 	// CI, optional, collection item dict, since V1.7
 	_, err = validateDictEntry(xRefTable, dict, dictName, "CI", OPTIONAL, V17, nil)
+	if err != nil {
+		return err
+	}
+
+	// AFRelationship, optional, name, since V2.0
+	// PDF/A-3 uses associated files with PDF 1.7.
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "AFRelationship", V20, V17)
+	afRel, err := validateNameEntry(xRefTable, dict, dictName, "AFRelationship", OPTIONAL, sinceVersion, validateAFRelationship(xRefTable))
+	if err != nil {
+		return err
+	}
+
+	// EP, optional, encrypted payload dict, since V2.0
+	return validateEncryptedPayloadDictEntry(xRefTable, dict, dictName, afRel)
+}
+
+func validateAFRelationship(xRefTable *XRefTable) func(string) bool {
+
+	return func(s string) bool {
+
+		// see 14.13.2
+		if memberOf(s, AFRelationships) {
+			return true
+		}
+
+		// Second-class names are allowed in relaxed mode.
+		return xRefTable.tolerate(RuleFileSpec, "second-class AFRelationship name %s", s)
+	}
+}
+
+func validateEncryptedPayloadDictEntry(xRefTable *XRefTable, dict *PDFDict, dictName string, afRel *PDFName) error {
+
+	// see 7.6.7 Unencrypted wrapper document
+
+	d, err := validateDictEntry(xRefTable, dict, dictName, "EP", OPTIONAL, V20, nil)
+	if err != nil || d == nil {
+		return err
+	}
+
+	// The encrypted payload shall be embedded and declared as such.
+	if afRel == nil || afRel.Value() != "EncryptedPayload" {
+		return errors.New("validateEncryptedPayloadDictEntry: \"EP\" requires \"AFRelationship\" EncryptedPayload")
+	}
+
+	if _, found := dict.Find("EF"); !found {
+		return errors.New("validateEncryptedPayloadDictEntry: \"EP\" requires \"EF\"")
+	}
+
+	dictName = "encryptedPayloadDict"
+
+	// Type, required, name
+	_, err = validateNameEntry(xRefTable, d, dictName, "Type", REQUIRED, V20, func(s string) bool { return s == "EncryptedPayload" })
+	if err != nil {
+		return err
+	}
+
+	// Subtype, required, name of the cryptographic filter
+	_, err = validateNameEntry(xRefTable, d, dictName, "Subtype", REQUIRED, V20, nil)
+	if err != nil {
+		return err
+	}
+
+	// Version, optional, text string
+	_, err = validateStringEntry(xRefTable, d, dictName, "Version", OPTIONAL, V20, nil)
 
 	return err
 }
 
+// validateAssociatedFilesEntry validates an AF array of file specification dicts, see 14.13.
+func validateAssociatedFilesEntry(xRefTable *XRefTable, dict *PDFDict, dictName string, required bool, sinceVersion PDFVersion) error {
+
+	// PDF/A-3 uses associated files with PDF 1.7.
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "AF", sinceVersion, V17)
+
+	arr, err := validateArrayEntry(xRefTable, dict, dictName, "AF", required, sinceVersion, nil)
+	if err != nil || arr == nil {
+		return err
+	}
+
+	for _, obj := range *arr {
+
+		d, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return err
+		}
+
+		if d == nil {
+			return errors.Errorf("validateAssociatedFilesEntry: dict=%s entry=AF: missing file specification dict", dictName)
+		}
+
+		if err = validateFileSpecDict(xRefTable, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func validateFileSpecification(xRefTable *XRefTable, obj PDFObject) (PDFObject, error) {
 
 	// See 7.11.4
@@ -17,7 +17,6 @@ limitations under the License.
 package pdfcpu
 
 import (
-	"github.com/hhrutter/pdfcpu/pkg/log"
 	"github.com/pkg/errors"
 )
 
@@ -99,14 +98,8 @@ func validateFontDescriptorType(xRefTable *XRefTable, dict *PDFDict) (err error)
 
 	dictType := dict.Type()
 
-	if dictType == nil {
-
-		if xRefTable.ValidationMode == ValidationRelaxed {
-			log.Debug.Println("validateFontDescriptor: missing entry \"Type\"")
-		} else {
-			return errors.New("validateFontDescriptor: missing entry \"Type\"")
-		}
-
+	if dictType == nil && !xRefTable.tolerate(RuleRequired, "fontDescriptor: missing required entry \"Type\"") {
+		return errors.New("validateFontDescriptor: missing entry \"Type\"")
 	}
 
 	if dictType != nil && *dictType != "FontDescriptor" {
@@ -128,28 +121,19 @@ func validateFontDescriptorPart1(xRefTable *XRefTable, dict *PDFDict, dictName,
 		return err
 	}
 
-	sinceVersion := V15
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "FontFamily", V15, V13)
 	_, err = validateStringEntry(xRefTable, dict, dictName, "FontFamily", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
 	}
 
-	sinceVersion = V15
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "FontStretch", V15, V13)
 	_, err = validateNameEntry(xRefTable, dict, dictName, "FontStretch", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
 	}
 
-	sinceVersion = V15
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "FontWeight", V15, V13)
 	_, err = validateNumberEntry(xRefTable, dict, dictName, "FontWeight", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -372,40 +356,28 @@ func validateTrueTypeFontDict(xRefTable *XRefTable, dict *PDFDict) error {
 	}
 
 	// FirstChar, required, integer
-	required := REQUIRED
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		required = OPTIONAL
-	}
+	required := xRefTable.required(dict, dictName, "FirstChar")
 	_, err = validateIntegerEntry(xRefTable, dict, dictName, "FirstChar", required, V10, nil)
 	if err != nil {
 		return err
 	}
 
 	// LastChar, required, integer
-	required = REQUIRED
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		required = OPTIONAL
-	}
+	required = xRefTable.required(dict, dictName, "LastChar")
 	_, err = validateIntegerEntry(xRefTable, dict, dictName, "LastChar", required, V10, nil)
 	if err != nil {
 		return err
 	}
 
 	// Widths, array of numbers.
-	required = REQUIRED
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		required = OPTIONAL
-	}
+	required = xRefTable.required(dict, dictName, "Widths")
 	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "Widths", required, V10, nil)
 	if err != nil {
 		return err
 	}
 
 	// FontDescriptor, required, dictionary
-	required = REQUIRED
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		required = OPTIONAL
-	}
+	required = xRefTable.required(dict, dictName, "FontDescriptor")
 	err = validateFontDescriptor(xRefTable, dict, dictName, "TrueType", required, V10)
 	if err != nil {
 		return err
@@ -668,39 +640,36 @@ func validateType1FontDict(xRefTable *XRefTable, dict *PDFDict) error {
 		return err
 	}
 
-	required := xRefTable.Version() >= V15 || !validateStandardType1Font((*fontName).String())
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		required = !validateStandardType1Font((*fontName).String())
+	standardFont := validateStandardType1Font((*fontName).String())
+	strict := !standardFont || xRefTable.Version() >= V15
+
+	required := func(key string) bool {
+		return !standardFont || strict && xRefTable.required(dict, dictName, key)
 	}
+
 	// FirstChar,  required except for standard 14 fonts. since 1.5 always required, integer
-	fc, err := validateIntegerEntry(xRefTable, dict, dictName, "FirstChar", required, V10, nil)
+	fc, err := validateIntegerEntry(xRefTable, dict, dictName, "FirstChar", required("FirstChar"), V10, nil)
 	if err != nil {
 		return err
 	}
 
-	if !required && fc != nil {
-		// For the standard 14 fonts, the entries FirstChar, LastChar, Widths and FontDescriptor shall either all be present or all be absent.
-		if xRefTable.ValidationMode == ValidationStrict {
-			required = true
-		} else {
-			// relaxed: do nothing
-		}
-	}
+	// For the standard 14 fonts, the entries FirstChar, LastChar, Widths and FontDescriptor shall either all be present or all be absent.
+	strict = strict || fc != nil
 
 	// LastChar, required except for standard 14 fonts. since 1.5 always required, integer
-	_, err = validateIntegerEntry(xRefTable, dict, dictName, "LastChar", required, V10, nil)
+	_, err = validateIntegerEntry(xRefTable, dict, dictName, "LastChar", required("LastChar"), V10, nil)
 	if err != nil {
 		return err
 	}
 
 	// Widths, required except for standard 14 fonts. since 1.5 always required, array of numbers
-	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "Widths", required, V10, nil)
+	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "Widths", required("Widths"), V10, nil)
 	if err != nil {
 		return err
 	}
 
 	// FontDescriptor, required since version 1.5; required unless standard font for version < 1.5, dict
-	err = validateFontDescriptor(xRefTable, dict, dictName, "Type1", required, V10)
+	err = validateFontDescriptor(xRefTable, dict, dictName, "Type1", required("FontDescriptor"), V10)
 	if err != nil {
 		return err
 	}
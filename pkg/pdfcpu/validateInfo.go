@@ -42,10 +42,16 @@ func intMemberOf(i int, list []int) bool {
 
 func validateCreationDate(xRefTable *XRefTable, o PDFObject) (err error) {
 
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		_, err = validateString(xRefTable, o, nil)
-	} else {
-		_, err = validateDateObject(xRefTable, o, V10)
+	if _, err = validateDateObject(xRefTable, o, V10); err == nil {
+		return nil
+	}
+
+	if _, err1 := validateString(xRefTable, o, nil); err1 != nil {
+		return err1
+	}
+
+	if xRefTable.tolerate(RuleInfo, "infoDict: malformed \"CreationDate\"") {
+		return nil
 	}
 
 	return err
@@ -53,9 +59,8 @@ func validateCreationDate(xRefTable *XRefTable, o PDFObject) (err error) {
 
 func handleDefault(xRefTable *XRefTable, o PDFObject) (err error) {
 
-	if xRefTable.ValidationMode == ValidationStrict {
-		_, err = xRefTable.DereferenceStringOrHexLiteral(o, V10, nil)
-	} else {
+	_, err = xRefTable.DereferenceStringOrHexLiteral(o, V10, nil)
+	if err != nil && xRefTable.tolerate(RuleInfo, "infoDict: entry is not a text string") {
 		_, err = xRefTable.Dereference(o)
 	}
 
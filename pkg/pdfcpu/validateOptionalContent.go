@@ -397,9 +397,7 @@ func validateOCProperties(xRefTable *XRefTable, rootDict *PDFDict, required bool
 
 	// => 8.11.4 Configuring Optional Content
 
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V14
-	}
+	sinceVersion = xRefTable.sinceVersion(rootDict, "rootDict", "OCProperties", sinceVersion, V14)
 
 	dict, err := validateDictEntry(xRefTable, rootDict, "rootDict", "OCProperties", required, sinceVersion, nil)
 	if err != nil || dict == nil {
@@ -409,10 +407,7 @@ func validateOCProperties(xRefTable *XRefTable, rootDict *PDFDict, required bool
 	dictName := "optContentPropertiesDict"
 
 	// "OCGs" required array of already written indRefs
-	r := true
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		r = false
-	}
+	r := xRefTable.required(dict, dictName, "OCGs")
 	_, err = validateIndRefArrayEntry(xRefTable, dict, dictName, "OCGs", r, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -76,7 +76,7 @@ func validateOutlineItemDict(xRefTable *XRefTable, dict *PDFDict) error {
 	return validateActionOrDestination(xRefTable, dict, dictName, V11)
 }
 
-func validateOutlineTree(xRefTable *XRefTable, first, last *PDFIndirectRef) error {
+func validateOutlineTree(xRefTable *XRefTable, first, last *PDFIndirectRef, visited IntSet) error {
 
 	var (
 		dict      *PDFDict
@@ -89,6 +89,17 @@ func validateOutlineTree(xRefTable *XRefTable, first, last *PDFIndirectRef) erro
 
 		objNumber = indRef.ObjectNumber.Value()
 
+		// Each outline item may only occur once, otherwise we would be looping forever.
+		if visited[objNumber] {
+			err = xRefTable.reportCycle("validateOutlineTree", []int{objNumber})
+			if !xRefTable.tolerate(RuleOutlines, "outline item %d occurs more than once", objNumber) {
+				return err
+			}
+			// Relaxed validation: ignore the remainder of this list.
+			return nil
+		}
+		visited[objNumber] = true
+
 		// outline item dict
 		dict, err = xRefTable.DereferenceDict(*indRef)
 		if err != nil {
@@ -113,7 +124,7 @@ func validateOutlineTree(xRefTable *XRefTable, first, last *PDFIndirectRef) erro
 
 		if firstChild != nil && lastChild != nil {
 			// Recurse into subtree.
-			err = validateOutlineTree(xRefTable, firstChild, lastChild)
+			err = validateOutlineTree(xRefTable, firstChild, lastChild, visited)
 			if err != nil {
 				return err
 			}
@@ -125,7 +136,7 @@ func validateOutlineTree(xRefTable *XRefTable, first, last *PDFIndirectRef) erro
 	}
 
 	// Relaxed validation
-	if objNumber != last.ObjectNumber.Value() && xRefTable.ValidationMode == ValidationStrict {
+	if objNumber != last.ObjectNumber.Value() && !xRefTable.tolerate(RuleOutlines, "corrupted outline item list %d <> %d", objNumber, last.ObjectNumber) {
 		return errors.Errorf("validateOutlineTree: corrupted child list %d <> %d\n", objNumber, last.ObjectNumber)
 	}
 
@@ -167,5 +178,5 @@ func validateOutlines(xRefTable *XRefTable, rootDict *PDFDict, required bool, si
 		return errors.New("validateOutlines: corrupted, root needs both first and last")
 	}
 
-	return validateOutlineTree(xRefTable, first, last)
+	return validateOutlineTree(xRefTable, first, last, IntSet{})
 }
@@ -599,11 +599,15 @@ func validateMeasureDict(xRefTable *XRefTable, dict *PDFDict, sinceVersion PDFVe
 		return err
 	}
 
-	_, err = validateNameEntry(xRefTable, dict, dictName, "Subtype", OPTIONAL, sinceVersion, func(s string) bool { return s == "RL" })
+	subtype, err := validateNameEntry(xRefTable, dict, dictName, "Subtype", OPTIONAL, sinceVersion, func(s string) bool { return s == "RL" || s == "GEO" })
 	if err != nil {
 		return err
 	}
 
+	if subtype != nil && *subtype == "GEO" {
+		return validateGeoMeasureDict(xRefTable, dict)
+	}
+
 	// R, text string, required, scale ratio
 	_, err = validateStringEntry(xRefTable, dict, dictName, "R", REQUIRED, sinceVersion, nil)
 	if err != nil {
@@ -661,6 +665,78 @@ func validateMeasureDict(xRefTable *XRefTable, dict *PDFDict, sinceVersion PDFVe
 	return nil
 }
 
+func validateGeoCoordSysDictEntry(xRefTable *XRefTable, dict *PDFDict, dictName, entryName string, required bool, sinceVersion PDFVersion) error {
+
+	d, err := validateDictEntry(xRefTable, dict, dictName, entryName, required, sinceVersion, nil)
+	if err != nil || d == nil {
+		return err
+	}
+
+	dictName = "coordSysDict"
+
+	// Type, required, name
+	_, err = validateNameEntry(xRefTable, d, dictName, "Type", REQUIRED, sinceVersion, func(s string) bool { return s == "GEOGCS" || s == "PROJCS" })
+	if err != nil {
+		return err
+	}
+
+	// EPSG, optional, integer
+	_, err = validateIntegerEntry(xRefTable, d, dictName, "EPSG", OPTIONAL, sinceVersion, nil)
+	if err != nil {
+		return err
+	}
+
+	// WKT, optional, ASCII string
+	_, err = validateStringEntry(xRefTable, d, dictName, "WKT", OPTIONAL, sinceVersion, nil)
+
+	return err
+}
+
+func validateGeoMeasureDict(xRefTable *XRefTable, dict *PDFDict) error {
+
+	// see ISO 32000-2 12.10.2
+
+	dictName := "geoMeasureDict"
+
+	// GeoPDFs use geospatial measure dicts with PDF 1.7 and Adobe extension level 3.
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "GCS", V20, V17)
+
+	// Bounds, optional, number array, pairs of points in the unit square
+	_, err := validateNumberArrayEntry(xRefTable, dict, dictName, "Bounds", OPTIONAL, sinceVersion, func(a PDFArray) bool { return len(a)%2 == 0 })
+	if err != nil {
+		return err
+	}
+
+	// GCS, required, geographic or projected coordinate system dict
+	err = validateGeoCoordSysDictEntry(xRefTable, dict, dictName, "GCS", REQUIRED, sinceVersion)
+	if err != nil {
+		return err
+	}
+
+	// DCS, optional, display coordinate system dict
+	err = validateGeoCoordSysDictEntry(xRefTable, dict, dictName, "DCS", OPTIONAL, sinceVersion)
+	if err != nil {
+		return err
+	}
+
+	// PDU, optional, name array of preferred linear, area and angular display units
+	_, err = validateNameArrayEntry(xRefTable, dict, dictName, "PDU", OPTIONAL, sinceVersion, func(a PDFArray) bool { return len(a) == 3 })
+	if err != nil {
+		return err
+	}
+
+	// GPTS, required, number array, latitude longitude pairs
+	gpts, err := validateNumberArrayEntry(xRefTable, dict, dictName, "GPTS", REQUIRED, sinceVersion, func(a PDFArray) bool { return len(a)%2 == 0 })
+	if err != nil {
+		return err
+	}
+
+	// LPTS, optional, number array, the points of GPTS in the unit square
+	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "LPTS", OPTIONAL, sinceVersion, func(a PDFArray) bool { return len(a) == len(*gpts) })
+
+	return err
+}
+
 func validateViewportDict(xRefTable *XRefTable, dict *PDFDict, sinceVersion PDFVersion) error {
 
 	dictName := "viewportDict"
@@ -732,6 +808,13 @@ func validatePageEntryVP(xRefTable *XRefTable, pagesDict *PDFDict, required bool
 	return nil
 }
 
+func validatePageEntryAF(xRefTable *XRefTable, dict *PDFDict, required bool, sinceVersion PDFVersion) error {
+
+	// see 14.13 Associated files
+
+	return validateAssociatedFilesEntry(xRefTable, dict, "pageDict", required, sinceVersion)
+}
+
 func validatePageDict(xRefTable *XRefTable, pageDict *PDFDict, objNumber, genNumber int, hasResources, hasMediaBox bool) error {
 
 	dictName := "pageDict"
@@ -759,10 +842,7 @@ func validatePageDict(xRefTable *XRefTable, pageDict *PDFDict, objNumber, genNum
 	}
 
 	// PieceInfo
-	sinceVersion := V13
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V10
-	}
+	sinceVersion := xRefTable.sinceVersion(pageDict, dictName, "PieceInfo", V13, V10)
 	hasPieceInfo, err := validatePieceInfo(xRefTable, pageDict, dictName, "PieceInfo", OPTIONAL, sinceVersion)
 	if err != nil {
 		return err
@@ -774,7 +854,7 @@ func validatePageDict(xRefTable *XRefTable, pageDict *PDFDict, objNumber, genNum
 		return err
 	}
 
-	if hasPieceInfo && lm == nil && xRefTable.ValidationMode == ValidationStrict {
+	if hasPieceInfo && lm == nil && xRefTable.required(pageDict, dictName, "LastModified") {
 		return errors.New("validatePageDict: missing \"LastModified\" (required by \"PieceInfo\")")
 	}
 
@@ -812,6 +892,7 @@ func validatePageDict(xRefTable *XRefTable, pageDict *PDFDict, objNumber, genNum
 		{validatePageEntryPresSteps, OPTIONAL, V15},
 		{validatePageEntryUserUnit, OPTIONAL, V16},
 		{validatePageEntryVP, OPTIONAL, V16},
+		{validatePageEntryAF, OPTIONAL, V20},
 	} {
 		err = f.validate(xRefTable, pageDict, f.required, f.sinceVersion)
 		if err != nil {
@@ -888,7 +969,11 @@ func validateResources(xRefTable *XRefTable, dict *PDFDict) (hasResources bool,
 	return validateResourceDict(xRefTable, obj)
 }
 
-func validatePagesDict(xRefTable *XRefTable, dict *PDFDict, objNumber, genNumber int, hasResources, hasMediaBox bool) error {
+// validatePagesDict validates the page tree rooted at dict.
+// If pages is not nil the page dicts are collected for concurrent validation instead of being validated.
+func validatePagesDict(xRefTable *XRefTable, dict *PDFDict, objNumber, genNumber int, hasResources, hasMediaBox bool, path []int, pages *[]pageNode) error {
+
+	path = append(path, objNumber)
 
 	// Resources and Mediabox are inherited.
 	//var dHasResources, dHasMediaBox bool
@@ -917,6 +1002,10 @@ func validatePagesDict(xRefTable *XRefTable, dict *PDFDict, objNumber, genNumber
 			continue
 		}
 
+		if err = xRefTable.canceled(); err != nil {
+			return err
+		}
+
 		// Dereference next page node dict.
 		indRef, ok := obj.(PDFIndirectRef)
 		if !ok {
@@ -928,6 +1017,13 @@ func validatePagesDict(xRefTable *XRefTable, dict *PDFDict, objNumber, genNumber
 		objNumber := indRef.ObjectNumber.Value()
 		genNumber := indRef.GenerationNumber.Value()
 
+		// A kid pointing to one of its ancestors would send us into an endless loop.
+		for i, nr := range path {
+			if nr == objNumber {
+				return xRefTable.reportCycle("validatePagesDict", path[i:])
+			}
+		}
+
 		var pageNodeDict *PDFDict
 		pageNodeDict, err = xRefTable.DereferenceDict(indRef)
 		if err != nil {
@@ -943,12 +1039,16 @@ func validatePagesDict(xRefTable *XRefTable, dict *PDFDict, objNumber, genNumber
 
 		case "Pages":
 			// Recurse over pagetree
-			err = validatePagesDict(xRefTable, pageNodeDict, objNumber, genNumber, hasResources, hasMediaBox)
+			err = validatePagesDict(xRefTable, pageNodeDict, objNumber, genNumber, hasResources, hasMediaBox, path, pages)
 			if err != nil {
 				return err
 			}
 
 		case "Page":
+			if pages != nil {
+				*pages = append(*pages, pageNode{pageNodeDict, objNumber, genNumber, hasResources, hasMediaBox})
+				continue
+			}
 			err = validatePageDict(xRefTable, pageNodeDict, objNumber, genNumber, hasResources, hasMediaBox)
 			if err != nil {
 				return err
@@ -986,8 +1086,20 @@ func validatePages(xRefTable *XRefTable, rootDict *PDFDict) (*PDFDict, error) {
 		return nil, errors.New("validatePagesDict: cannot dereference pageNodeDict")
 	}
 
+	// Pages get validated once the page tree has been processed in order to locate errors by page number.
+	pages := &[]pageNode{}
+
 	// Process page node tree.
-	err = validatePagesDict(xRefTable, rootPageNodeDict, objNumber, genNumber, false, false)
+	err = validatePagesDict(xRefTable, rootPageNodeDict, objNumber, genNumber, false, false, nil, pages)
+	if err != nil {
+		return nil, err
+	}
+
+	err = xRefTable.processConcurrently(len(*pages), func(i int) error {
+		p := (*pages)[i]
+		err := validatePageDict(xRefTable, p.dict, p.objNr, p.genNr, p.hasResources, p.hasMediaBox)
+		return validationError(err, p.objNr, i+1)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,387 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// EnsurePageCount sets the page count of xRefTable using the page tree root dict
+// for xRefTables that did not go through validation.
+func (xRefTable *XRefTable) EnsurePageCount() error {
+
+	if xRefTable.PageCount > 0 {
+		return nil
+	}
+
+	root, err := xRefTable.Pages()
+	if err != nil {
+		return err
+	}
+
+	if root == nil {
+		return errors.New("EnsurePageCount: missing page tree")
+	}
+
+	dict, err := xRefTable.DereferenceDict(*root)
+	if err != nil || dict == nil {
+		return errors.New("EnsurePageCount: corrupt page tree root")
+	}
+
+	pageCount := dict.IntEntry("Count")
+	if pageCount == nil {
+		return errors.New("EnsurePageCount: missing \"Count\"")
+	}
+
+	xRefTable.PageCount = *pageCount
+
+	return nil
+}
+
+// inheritedPageNodeAttrs walks up the page tree starting at the parent of dict
+// and reports whether Resources and MediaBox are inherited.
+func inheritedPageNodeAttrs(xRefTable *XRefTable, dict *PDFDict) (hasResources, hasMediaBox bool, err error) {
+
+	visited := IntSet{}
+
+	for indRef := dict.IndirectRefEntry("Parent"); indRef != nil; indRef = dict.IndirectRefEntry("Parent") {
+
+		objNr := indRef.ObjectNumber.Value()
+		if visited[objNr] {
+			return false, false, errors.Errorf("inheritedPageNodeAttrs: page tree cycle at obj#%d", objNr)
+		}
+		visited[objNr] = true
+
+		dict, err = xRefTable.DereferenceDict(*indRef)
+		if err != nil {
+			return false, false, err
+		}
+
+		if dict == nil {
+			return false, false, errors.Errorf("inheritedPageNodeAttrs: corrupt parent obj#%d", objNr)
+		}
+
+		if _, found := dict.Find("Resources"); found {
+			hasResources = true
+		}
+
+		if _, found := dict.Find("MediaBox"); found {
+			hasMediaBox = true
+		}
+	}
+
+	return hasResources, hasMediaBox, nil
+}
+
+func validateSelectedPage(xRefTable *XRefTable, pageDict *PDFDict, objNumber, genNumber int, hasResources, hasMediaBox bool) error {
+
+	err := validatePageDict(xRefTable, pageDict, objNumber, genNumber, hasResources, hasMediaBox)
+	if err != nil {
+		return err
+	}
+
+	return validatePageAnnotations(xRefTable, pageDict)
+}
+
+func validateSelectedPages(xRefTable *XRefTable, dict *PDFDict, pages IntSet, p *int, hasResources, hasMediaBox bool, path []int) error {
+
+	if _, found := dict.Find("Resources"); found {
+		hasResources = true
+	}
+
+	if _, found := dict.Find("MediaBox"); found {
+		hasMediaBox = true
+	}
+
+	kidsArray := dict.PDFArrayEntry("Kids")
+	if kidsArray == nil {
+		return errors.New("validateSelectedPages: corrupt \"Kids\" entry")
+	}
+
+	for _, obj := range *kidsArray {
+
+		if obj == nil {
+			continue
+		}
+
+		if err := xRefTable.canceled(); err != nil {
+			return err
+		}
+
+		indRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			return errors.New("validateSelectedPages: missing indirect reference for kid")
+		}
+
+		objNumber := indRef.ObjectNumber.Value()
+		genNumber := indRef.GenerationNumber.Value()
+
+		for i, nr := range path {
+			if nr == objNumber {
+				return xRefTable.reportCycle("validateSelectedPages", path[i:])
+			}
+		}
+
+		pageNodeDict, err := xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		dictType, err := dictTypeForPageNodeDict(pageNodeDict)
+		if err != nil {
+			return err
+		}
+
+		switch dictType {
+
+		case "Pages":
+			// Skip page subtrees not containing any selected page.
+			if count := pageNodeDict.IntEntry("Count"); count != nil && !pagesInRange(pages, *p+1, *p+*count) {
+				*p += *count
+				continue
+			}
+			err = validateSelectedPages(xRefTable, pageNodeDict, pages, p, hasResources, hasMediaBox, append(path, objNumber))
+
+		case "Page":
+			*p++
+			if pages[*p] {
+				log.Debug.Printf("validateSelectedPages: validating page %d\n", *p)
+				err = validateSelectedPage(xRefTable, pageNodeDict, objNumber, genNumber, hasResources, hasMediaBox)
+				err = validationError(err, objNumber, *p)
+			}
+
+		default:
+			err = errors.Errorf("validateSelectedPages: Unexpected dict type: %s", dictType)
+
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func pagesInRange(pages IntSet, from, thru int) bool {
+
+	for p, v := range pages {
+		if v && p >= from && p <= thru {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidatePages validates the page dicts of selected pages including their resources and annotations.
+// Page subtrees not containing any selected page are skipped.
+func ValidatePages(xRefTable *XRefTable, pages IntSet) error {
+
+	log.Info.Println("validating pages")
+
+	root, err := xRefTable.Pages()
+	if err != nil {
+		return err
+	}
+
+	if root == nil {
+		return errors.New("ValidatePages: missing indirect obj for pages dict")
+	}
+
+	dict, err := xRefTable.DereferenceDict(*root)
+	if err != nil {
+		return err
+	}
+
+	if dict == nil {
+		return errors.New("ValidatePages: cannot dereference pageNodeDict")
+	}
+
+	p := 0
+
+	err = validateSelectedPages(xRefTable, dict, pages, &p, false, false, []int{root.ObjectNumber.Value()})
+	if err != nil {
+		return validationError(err, 0, 0)
+	}
+
+	for _, w := range xRefTable.Warnings {
+		log.Info.Printf("ValidatePages: %s\n", w)
+	}
+
+	return nil
+}
+
+func isAnnotationDict(dict *PDFDict) bool {
+
+	if t := dict.Type(); t != nil {
+		return *t == "Annot"
+	}
+
+	_, found := dict.Find("Rect")
+
+	return found && dict.Subtype() != nil
+}
+
+func validateObjectDict(xRefTable *XRefTable, dict *PDFDict, objNr, genNr int) error {
+
+	if isAnnotationDict(dict) {
+		_, err := validateAnnotationDict(xRefTable, dict)
+		return err
+	}
+
+	if dict.Type() == nil {
+
+		if _, found := dict.Find("ShadingType"); found {
+			return validateShading(xRefTable, *dict)
+		}
+
+		if _, found := dict.Find("PatternType"); found {
+			return validatePattern(xRefTable, *dict)
+		}
+
+		if _, found := dict.Find("S"); found {
+			return validateActionDict(xRefTable, dict)
+		}
+
+		return errors.Errorf("ValidateObject: obj#%d: unable to identify dict", objNr)
+	}
+
+	switch t := *dict.Type(); t {
+
+	case "Page":
+		hasResources, hasMediaBox, err := inheritedPageNodeAttrs(xRefTable, dict)
+		if err != nil {
+			return err
+		}
+		return validateSelectedPage(xRefTable, dict, objNr, genNr, hasResources, hasMediaBox)
+
+	case "Pages":
+		// Prevent validatePagesDict from taking the page count of this subtree for the document's.
+		if err := xRefTable.EnsurePageCount(); err != nil {
+			return err
+		}
+		hasResources, hasMediaBox, err := inheritedPageNodeAttrs(xRefTable, dict)
+		if err != nil {
+			return err
+		}
+		err = validatePagesDict(xRefTable, dict, objNr, genNr, hasResources, hasMediaBox, nil, nil)
+		if err != nil {
+			return err
+		}
+		return validatePagesAnnotations(xRefTable, dict)
+
+	case "Font":
+		return validateFontDict(xRefTable, *dict)
+
+	case "ExtGState":
+		return validateExtGStateDict(xRefTable, *dict)
+
+	case "Pattern":
+		return validatePattern(xRefTable, *dict)
+
+	case "Action":
+		return validateActionDict(xRefTable, dict)
+
+	case "Filespec":
+		return validateFileSpecDict(xRefTable, dict)
+
+	case "StructElem":
+		return validateStructElementDict(xRefTable, dict)
+
+	default:
+		return errors.Errorf("ValidateObject: obj#%d: unsupported dict type: %s", objNr, t)
+	}
+}
+
+func validateObjectStreamDict(xRefTable *XRefTable, sd *PDFStreamDict, objNr int) error {
+
+	if t := sd.Type(); t != nil && *t == "XObject" {
+		return validateXObjectStreamDict(xRefTable, *sd)
+	}
+
+	if t := sd.Type(); t != nil && *t == "Pattern" {
+		return validatePattern(xRefTable, *sd)
+	}
+
+	if st := sd.Subtype(); st != nil && (*st == "Image" || *st == "Form") {
+		return validateXObjectStreamDict(xRefTable, *sd)
+	}
+
+	if _, found := sd.Find("PatternType"); found {
+		return validatePattern(xRefTable, *sd)
+	}
+
+	if _, found := sd.Find("ShadingType"); found {
+		return validateShading(xRefTable, *sd)
+	}
+
+	return errors.Errorf("ValidateObject: obj#%d: unable to identify stream dict", objNr)
+}
+
+// ValidateObject validates the object with number objNr along with all objects reachable from it.
+// Supported are page tree nodes, annotations, fonts, XObjects, graphics states,
+// patterns, shadings, actions, file specifications and structure elements.
+func ValidateObject(xRefTable *XRefTable, objNr int) error {
+
+	log.Info.Printf("validating obj#%d\n", objNr)
+
+	entry, found := xRefTable.FindTableEntryLight(objNr)
+	if !found || entry == nil {
+		return errors.Errorf("ValidateObject: obj#%d not registered in xRefTable", objNr)
+	}
+
+	if entry.Free {
+		return errors.Errorf("ValidateObject: obj#%d is free", objNr)
+	}
+
+	genNr := 0
+	if entry.Generation != nil {
+		genNr = *entry.Generation
+	}
+
+	err := xRefTable.ensureStreamLoaded(entry, objNr)
+	if err != nil {
+		return err
+	}
+
+	switch obj := entry.Object.(type) {
+
+	case PDFDict:
+		err = validateObjectDict(xRefTable, &obj, objNr, genNr)
+
+	case PDFStreamDict:
+		err = validateObjectStreamDict(xRefTable, &obj, objNr)
+
+	case nil:
+		err = errors.Errorf("ValidateObject: obj#%d is null", objNr)
+
+	default:
+		err = errors.Errorf("ValidateObject: obj#%d: unsupported object type: %T", objNr, obj)
+	}
+
+	if err != nil {
+		return validationError(err, objNr, 0)
+	}
+
+	for _, w := range xRefTable.Warnings {
+		log.Info.Printf("ValidateObject: %s\n", w)
+	}
+
+	return nil
+}
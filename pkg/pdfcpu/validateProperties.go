@@ -42,7 +42,7 @@ func validatePropertiesDict(xRefTable *XRefTable, obj PDFObject) error {
 	// Optional Lang string RFC 3066 see 14.9.2
 
 	dict, err := xRefTable.DereferenceDict(obj)
-	if err != nil || obj == nil {
+	if err != nil || dict == nil {
 		return err
 	}
 
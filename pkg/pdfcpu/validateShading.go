@@ -216,9 +216,8 @@ func validateCoonsPatchMeshesDict(xRefTable *XRefTable, dict *PDFDict) error {
 		return err
 	}
 
-	validateBitsPerFlag := func(i int) bool { return i >= 0 && i <= 3 }
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		validateBitsPerFlag = func(i int) bool { return i >= 0 && i <= 8 }
+	validateBitsPerFlag := func(i int) bool {
+		return i >= 0 && (i <= 3 || i <= 8 && xRefTable.tolerate(RuleShading, "%s: invalid \"BitsPerFlag\" %d", dictName, i))
 	}
 	_, err = validateIntegerEntry(xRefTable, dict, dictName, "BitsPerFlag", REQUIRED, V10, validateBitsPerFlag)
 	if err != nil {
@@ -247,9 +246,8 @@ func validateTensorProductPatchMeshesDict(xRefTable *XRefTable, dict *PDFDict) e
 		return err
 	}
 
-	validateBitsPerFlag := func(i int) bool { return i >= 0 && i <= 3 }
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		validateBitsPerFlag = func(i int) bool { return i >= 0 && i <= 8 }
+	validateBitsPerFlag := func(i int) bool {
+		return i >= 0 && (i <= 3 || i <= 8 && xRefTable.tolerate(RuleShading, "%s: invalid \"BitsPerFlag\" %d", dictName, i))
 	}
 	_, err = validateIntegerEntry(xRefTable, dict, dictName, "BitsPerFlag", REQUIRED, V10, validateBitsPerFlag)
 	if err != nil {
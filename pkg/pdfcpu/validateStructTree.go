@@ -412,10 +412,7 @@ func validateStructElementDictPart2(xRefTable *XRefTable, dict *PDFDict, dictNam
 	}
 
 	// Lang: optional, text string, since 1.4
-	sinceVersion := V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(dict, dictName, "Lang", V14, V13)
 	_, err = validateStringEntry(xRefTable, dict, dictName, "Lang", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -439,6 +436,112 @@ func validateStructElementDictPart2(xRefTable *XRefTable, dict *PDFDict, dictNam
 		return err
 	}
 
+	return validateStructElementDictPart3(xRefTable, dict, dictName)
+}
+
+func validateStructElementDictPart3(xRefTable *XRefTable, dict *PDFDict, dictName string) error {
+
+	// NS: optional, indRef of namespace dict, since 2.0
+	indRef, err := validateIndRefEntry(xRefTable, dict, dictName, "NS", OPTIONAL, V20)
+	if err != nil {
+		return err
+	}
+
+	if indRef != nil {
+		if err = validateNamespaceDict(xRefTable, *indRef); err != nil {
+			return err
+		}
+	}
+
+	// Ref: optional, array of indRefs of struct element dicts, since 2.0
+	_, err = validateIndRefArrayEntry(xRefTable, dict, dictName, "Ref", OPTIONAL, V20, nil)
+	if err != nil {
+		return err
+	}
+
+	// PhoneticAlphabet: optional, name, since 2.0
+	_, err = validateNameEntry(xRefTable, dict, dictName, "PhoneticAlphabet", OPTIONAL, V20, nil)
+	if err != nil {
+		return err
+	}
+
+	// Phoneme: optional, text string, since 2.0
+	_, err = validateStringEntry(xRefTable, dict, dictName, "Phoneme", OPTIONAL, V20, nil)
+	if err != nil {
+		return err
+	}
+
+	// AF: optional, array of file specification dicts, since 2.0
+	return validateAssociatedFilesEntry(xRefTable, dict, dictName, OPTIONAL, V20)
+}
+
+func validateNamespaceDict(xRefTable *XRefTable, obj PDFObject) error {
+
+	// see 14.7.4 Namespaces
+
+	dict, err := xRefTable.DereferenceDict(obj)
+	if err != nil {
+		return err
+	}
+
+	if dict == nil {
+		return errors.New("validateNamespaceDict: missing namespace dict")
+	}
+
+	dictName := "namespaceDict"
+
+	// Type: optional, name
+	_, err = validateNameEntry(xRefTable, dict, dictName, "Type", OPTIONAL, V20, func(s string) bool { return s == "Namespace" })
+	if err != nil {
+		return err
+	}
+
+	// NS: required, text string, the namespace URI
+	_, err = validateStringEntry(xRefTable, dict, dictName, "NS", REQUIRED, V20, nil)
+	if err != nil {
+		return err
+	}
+
+	// Schema: optional, file specification
+	_, err = validateFileSpecEntry(xRefTable, dict, dictName, "Schema", OPTIONAL, V20)
+	if err != nil {
+		return err
+	}
+
+	// RoleMapNS: optional, dict
+	d, err := validateDictEntry(xRefTable, dict, dictName, "RoleMapNS", OPTIONAL, V20, nil)
+	if err != nil || d == nil {
+		return err
+	}
+
+	// Values are either a name or an array of a name and an indRef of a namespace dict.
+	for k, v := range d.Dict {
+
+		o, err := xRefTable.Dereference(v)
+		if err != nil {
+			return err
+		}
+
+		switch o := o.(type) {
+
+		case PDFName:
+
+		case PDFArray:
+			if len(o) != 2 {
+				return errors.Errorf("validateNamespaceDict: RoleMapNS entry %s: invalid array length", k)
+			}
+			if _, ok := o[0].(PDFName); !ok {
+				return errors.Errorf("validateNamespaceDict: RoleMapNS entry %s: missing structure type", k)
+			}
+			if _, ok := o[1].(PDFIndirectRef); !ok {
+				return errors.Errorf("validateNamespaceDict: RoleMapNS entry %s: missing namespace", k)
+			}
+
+		default:
+			return errors.Errorf("validateNamespaceDict: RoleMapNS entry %s: invalid type", k)
+		}
+	}
+
 	return nil
 }
 
@@ -583,26 +686,20 @@ func processStructTreeClassMapDict(xRefTable *XRefTable, dict *PDFDict) error {
 
 func validateStructTreeRootDictEntryParentTree(xRefTable *XRefTable, indRef *PDFIndirectRef) error {
 
-	if xRefTable.ValidationMode == ValidationRelaxed {
-
-		// Accept empty dict
-
-		d, err := xRefTable.DereferenceDict(*indRef)
-		if err != nil {
-			return err
-		}
-
-		if d == nil || len(d.Dict) == 0 {
-			return errors.New("validateStructTreeRootDict: corrupt entry \"ParentTree\"")
-		}
+	_, _, err := validateNumberTree(xRefTable, "StructTree", *indRef, true)
+	if err == nil || !xRefTable.tolerate(RuleStructTree, "corrupt parent tree: %v", err) {
+		return err
+	}
 
-	} else {
+	// Accept any non empty dict.
 
-		_, _, err := validateNumberTree(xRefTable, "StructTree", *indRef, true)
-		if err != nil {
-			return err
-		}
+	d, err := xRefTable.DereferenceDict(*indRef)
+	if err != nil {
+		return err
+	}
 
+	if d == nil || len(d.Dict) == 0 {
+		return errors.New("validateStructTreeRootDict: corrupt entry \"ParentTree\"")
 	}
 
 	return nil
@@ -673,7 +770,36 @@ func validateStructTreeRootDict(xRefTable *XRefTable, dict *PDFDict) error {
 		}
 	}
 
-	return nil
+	// Optional entry Namespaces: array of namespace dicts, since 2.0
+	arr, err := validateArrayEntry(xRefTable, dict, dictName, "Namespaces", OPTIONAL, V20, nil)
+	if err != nil {
+		return err
+	}
+
+	if arr != nil {
+		for _, obj := range *arr {
+			if err = validateNamespaceDict(xRefTable, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Optional entry PronunciationLexicon: array of file specifications, since 2.0
+	arr, err = validateArrayEntry(xRefTable, dict, dictName, "PronunciationLexicon", OPTIONAL, V20, nil)
+	if err != nil {
+		return err
+	}
+
+	if arr != nil {
+		for _, obj := range *arr {
+			if _, err = validateFileSpecification(xRefTable, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Optional entry AF: array of file specification dicts, since 2.0
+	return validateAssociatedFilesEntry(xRefTable, dict, dictName, OPTIONAL, V20)
 }
 
 func validateStructTree(xRefTable *XRefTable, rootDict *PDFDict, required bool, sinceVersion PDFVersion) error {
@@ -441,8 +441,8 @@ func validateImageStreamDictPart1(xRefTable *XRefTable, streamDict *PDFStreamDic
 			required = OPTIONAL
 		}
 
-		if streamDict.HasSoleFilterNamed(filter.CCITTFax) && xRefTable.ValidationMode == ValidationRelaxed {
-			required = OPTIONAL
+		if streamDict.HasSoleFilterNamed(filter.CCITTFax) {
+			required = xRefTable.required(&dict, dictName, "ColorSpace")
 		}
 
 		err = validateColorSpaceEntry(xRefTable, &dict, dictName, "ColorSpace", required, ExcludePatternCS)
@@ -535,10 +535,7 @@ func validateImageStreamDict(xRefTable *XRefTable, streamDict *PDFStreamDict, is
 	}
 
 	// SMask, stream, optional, since V1.4
-	sinceVersion := V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion := xRefTable.sinceVersion(&dict, dictName, "SMask", V14, V13)
 	sd, err := validateStreamDictEntry(xRefTable, &dict, dictName, "SMask", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -753,7 +750,7 @@ func validateXObjectStreamDict(xRefTable *XRefTable, obj PDFObject) error {
 	// see 8.8 External Objects
 
 	sd, err := xRefTable.DereferenceStreamDict(obj)
-	if err != nil || obj == nil {
+	if err != nil || sd == nil {
 		return err
 	}
 
@@ -765,10 +762,7 @@ func validateXObjectStreamDict(xRefTable *XRefTable, obj PDFObject) error {
 		return err
 	}
 
-	required := REQUIRED
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		required = OPTIONAL
-	}
+	required := xRefTable.required(&d, dictName, "Subtype")
 	subtype, err := validateNameEntry(xRefTable, &d, dictName, "Subtype", required, V10, nil)
 	if err != nil {
 		return err
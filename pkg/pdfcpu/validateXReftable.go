@@ -23,6 +23,11 @@ import (
 
 // ValidateXRefTable validates a PDF cross reference table obeying the validation mode.
 func ValidateXRefTable(xRefTable *XRefTable) error {
+	err := xRefTable.profile(PhaseValidate, func() error { return validateXRefTable(xRefTable) })
+	return validationError(err, 0, 0)
+}
+
+func validateXRefTable(xRefTable *XRefTable) error {
 
 	log.Info.Println("validating")
 	log.Debug.Println("*** validateXRefTable begin ***")
@@ -47,6 +52,16 @@ func ValidateXRefTable(xRefTable *XRefTable) error {
 
 	xRefTable.Valid = true
 
+	// Report reference cycles tolerated during validation.
+	for _, c := range xRefTable.Cycles {
+		log.Info.Printf("validateXRefTable: %v\n", c)
+	}
+
+	// Report violations of validation rules set to RuleWarn.
+	for _, w := range xRefTable.Warnings {
+		log.Info.Printf("validateXRefTable: %s\n", w)
+	}
+
 	log.Debug.Println("*** validateXRefTable end ***")
 
 	return nil
@@ -206,10 +221,7 @@ func validateViewerPreferences(xRefTable *XRefTable, rootDict *PDFDict, required
 		return err
 	}
 
-	sinceVersion = V14
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V10
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, dictName, "DisplayDocTitle", V14, V10)
 	_, err = validateBooleanEntry(xRefTable, dict, dictName, "DisplayDocTitle", OPTIONAL, sinceVersion, nil)
 	if err != nil {
 		return err
@@ -308,9 +320,7 @@ func validateMetadata(xRefTable *XRefTable, dict *PDFDict, required bool, sinceV
 	// as opposed to serving as an implementation artifact.
 	// Some PDF constructs are considered implementational, and hence may not have associated metadata.
 
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(dict, "dict", "Metadata", sinceVersion, V13)
 
 	streamDict, err := validateStreamDictEntry(xRefTable, dict, "dict", "Metadata", required, sinceVersion, nil)
 	if err != nil || streamDict == nil {
@@ -487,9 +497,7 @@ func validateOutputIntents(xRefTable *XRefTable, rootDict *PDFDict, required boo
 
 	// => 14.11.5 Output Intents
 
-	if xRefTable.ValidationMode == ValidationRelaxed {
-		sinceVersion = V13
-	}
+	sinceVersion = xRefTable.sinceVersion(rootDict, "rootDict", "OutputIntents", sinceVersion, V13)
 
 	arr, err := validateArrayEntry(xRefTable, rootDict, "rootDict", "OutputIntents", required, sinceVersion, nil)
 	if err != nil || arr == nil {
@@ -813,6 +821,13 @@ func validateNeedsRendering(xRefTable *XRefTable, rootDict *PDFDict, required bo
 	return err
 }
 
+func validateRootAF(xRefTable *XRefTable, rootDict *PDFDict, required bool, sinceVersion PDFVersion) error {
+
+	// see 14.13 Associated files
+
+	return validateAssociatedFilesEntry(xRefTable, rootDict, "rootDict", required, sinceVersion)
+}
+
 func validateRootObject(xRefTable *XRefTable) error {
 
 	log.Debug.Println("*** validateRootObject begin ***")
@@ -900,6 +915,7 @@ func validateRootObject(xRefTable *XRefTable) error {
 		{validateRequirements, OPTIONAL, V17},
 		{validateCollection, OPTIONAL, V17},
 		{validateNeedsRendering, OPTIONAL, V17},
+		{validateRootAF, OPTIONAL, V20},
 	} {
 		err = f.validate(xRefTable, rootDict, f.required, f.sinceVersion)
 		if err != nil {
@@ -908,7 +924,11 @@ func validateRootObject(xRefTable *XRefTable) error {
 	}
 
 	// Validate remainder of annotations after AcroForm validation only.
-	err = validatePagesAnnotations(xRefTable, rootPageNodeDict)
+	if xRefTable.Workers > 1 {
+		err = validatePageAnnotationsConcurrently(xRefTable, rootPageNodeDict, rootDict.IndirectRefEntry("Pages").ObjectNumber.Value())
+	} else {
+		err = validatePagesAnnotations(xRefTable, rootPageNodeDict)
+	}
 
 	log.Debug.Println("*** validateRootObject end ***")
 
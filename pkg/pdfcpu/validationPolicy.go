@@ -0,0 +1,177 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Actions taken on a violation of a validation rule.
+const (
+	RuleError  = iota // Validation fails.
+	RuleWarn          // A warning is recorded and validation continues.
+	RuleIgnore        // Validation continues silently.
+)
+
+// Validation rules covering deviations from ISO 32000 frequently produced by PDF writers.
+const (
+	RuleVersion     = "version"     // Entries introduced by a PDF version later than the version of the file.
+	RuleRequired    = "required"    // Required entries missing, eg. Widths of TrueType fonts or Subtype of form XObjects.
+	RuleFileSpec    = "filespec"    // File specifications of type F and second-class AFRelationship names.
+	RuleInfo        = "info"        // Document info dict entries not being text strings or dates.
+	RuleOutlines    = "outlines"    // Cyclic or corrupted outline item lists.
+	RuleDestination = "destination" // FitH destinations lacking the top coordinate.
+	RuleShading     = "shading"     // Shading BitsPerFlag values greater than 3.
+	RuleStructTree  = "structtree"  // Structure parent trees not being valid number trees.
+	RuleEncryption  = "encryption"  // PDF 2.0 files not encrypted using AES-256.
+)
+
+// ValidationRules lists the rules configurable by a ValidationPolicy.
+var ValidationRules = []string{
+	RuleVersion,
+	RuleRequired,
+	RuleFileSpec,
+	RuleInfo,
+	RuleOutlines,
+	RuleDestination,
+	RuleShading,
+	RuleStructTree,
+	RuleEncryption,
+}
+
+var ruleActions = map[string]int{"error": RuleError, "warn": RuleWarn, "ignore": RuleIgnore}
+
+// ValidationPolicy maps validation rules to the action taken on violations.
+// Rules not covered fall back to the validation mode:
+// RuleError for ValidationStrict and RuleIgnore for ValidationRelaxed.
+type ValidationPolicy map[string]int
+
+// ParseValidationPolicy parses a validation policy of the form 'rule:action, rule:action, ...'
+// where action is one of error, warn and ignore.
+func ParseValidationPolicy(s string) (ValidationPolicy, error) {
+
+	p := ValidationPolicy{}
+
+	for _, s1 := range strings.Split(s, ",") {
+
+		ss := strings.Split(s1, ":")
+		if len(ss) != 2 {
+			return nil, errors.Errorf("invalid validation rule setting: %s", s1)
+		}
+
+		rule, action := strings.TrimSpace(ss[0]), strings.TrimSpace(ss[1])
+
+		if !memberOf(rule, ValidationRules) {
+			return nil, errors.Errorf("unknown validation rule: %s, must be one of: %s", rule, strings.Join(ValidationRules, ", "))
+		}
+
+		a, found := ruleActions[action]
+		if !found {
+			return nil, errors.Errorf("invalid action for validation rule %s: %s, must be one of: error, warn, ignore", rule, action)
+		}
+
+		p[rule] = a
+	}
+
+	return p, nil
+}
+
+func (p ValidationPolicy) String() string {
+
+	var ss []string
+
+	for rule, a := range p {
+		for s, a1 := range ruleActions {
+			if a == a1 {
+				ss = append(ss, rule+":"+s)
+			}
+		}
+	}
+
+	sort.Strings(ss)
+
+	return strings.Join(ss, ", ")
+}
+
+// ruleAction returns the action to be taken on a violation of rule.
+func (xRefTable *XRefTable) ruleAction(rule string) int {
+
+	if a, found := xRefTable.ValidationPolicy[rule]; found {
+		return a
+	}
+
+	if xRefTable.ValidationMode == ValidationStrict {
+		return RuleError
+	}
+
+	return RuleIgnore
+}
+
+// tolerate returns true if a violation of rule is acceptable according to the validation policy
+// and records a warning for rules set to RuleWarn.
+func (xRefTable *XRefTable) tolerate(rule, format string, args ...interface{}) bool {
+
+	switch xRefTable.ruleAction(rule) {
+
+	case RuleIgnore:
+		return true
+
+	case RuleWarn:
+		s := fmt.Sprintf("%s: %s", rule, fmt.Sprintf(format, args...))
+		xRefTable.mu.Lock()
+		defer xRefTable.mu.Unlock()
+		if !memberOf(s, xRefTable.Warnings) {
+			xRefTable.Warnings = append(xRefTable.Warnings, s)
+		}
+		return true
+	}
+
+	return false
+}
+
+// sinceVersion returns the PDF version for validating entry key of dict, which has been introduced with version.
+// The entry is also accepted for files of version relaxed and later if the validation policy tolerates RuleVersion.
+func (xRefTable *XRefTable) sinceVersion(dict *PDFDict, dictName, key string, version, relaxed PDFVersion) PDFVersion {
+
+	if relaxed >= version || xRefTable.Version() >= version || xRefTable.Version() < relaxed {
+		return version
+	}
+
+	if _, found := dict.Find(key); !found {
+		return version
+	}
+
+	if xRefTable.tolerate(RuleVersion, "%s: entry \"%s\" requires PDF %s", dictName, key, VersionString(version)) {
+		return relaxed
+	}
+
+	return version
+}
+
+// required returns true if the missing entry key of dict is required according to the validation policy.
+func (xRefTable *XRefTable) required(dict *PDFDict, dictName, key string) bool {
+
+	if _, found := dict.Find(key); found {
+		return REQUIRED
+	}
+
+	return !xRefTable.tolerate(RuleRequired, "%s: missing required entry \"%s\"", dictName, key)
+}
@@ -0,0 +1,819 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with the verification of digital signatures, see 12.8.
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+var oidAttrSigningTime = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+// SignatureReport represents the verification result of a signature.
+type SignatureReport struct {
+	FieldName       string
+	SubFilter       string
+	Signer          string    // subject of the signing certificate
+	SigningTime     time.Time // as claimed by the signer
+	Timestamp       time.Time // of a valid signature or document time stamp
+	Reason          string
+	Location        string
+	ByteRange       []int64
+	WholeFile       bool     // the byte range covers the whole file
+	DigestValid     bool     // the message digest matches the signed bytes
+	SignatureValid  bool     // the signature verifies against the signing certificate
+	ChainTrusted    bool     // the signing certificate chains up to a trusted root
+	ContentModified bool     // a later incremental update modified signed content
+	Changes         []string // modifications of later incremental updates
+	Problems        []string
+}
+
+// Valid returns true if the signature is intact, trusted and signed content was not modified since signing.
+func (sr SignatureReport) Valid() bool {
+	return sr.DigestValid && sr.SignatureValid && sr.ChainTrusted && !sr.ContentModified && len(sr.Problems) == 0
+}
+
+func (sr SignatureReport) String() string {
+
+	status := "valid"
+	if !sr.Valid() {
+		status = "invalid"
+	}
+
+	ss := []string{
+		fmt.Sprintf("signature %s: %s", sr.FieldName, status),
+		fmt.Sprintf("  signer:      %s", sr.Signer),
+		fmt.Sprintf("  sub filter:  %s", sr.SubFilter),
+	}
+
+	if !sr.SigningTime.IsZero() {
+		ss = append(ss, fmt.Sprintf("  signed at:   %s", sr.SigningTime.Format(time.RFC3339)))
+	}
+
+	if !sr.Timestamp.IsZero() {
+		ss = append(ss, fmt.Sprintf("  time stamp:  %s", sr.Timestamp.Format(time.RFC3339)))
+	}
+
+	if sr.Reason != "" {
+		ss = append(ss, fmt.Sprintf("  reason:      %s", sr.Reason))
+	}
+
+	if sr.Location != "" {
+		ss = append(ss, fmt.Sprintf("  location:    %s", sr.Location))
+	}
+
+	ss = append(ss,
+		fmt.Sprintf("  byte range:  %v whole file: %t", sr.ByteRange, sr.WholeFile),
+		fmt.Sprintf("  digest:      %t", sr.DigestValid),
+		fmt.Sprintf("  signature:   %t", sr.SignatureValid),
+		fmt.Sprintf("  trusted:     %t", sr.ChainTrusted),
+		fmt.Sprintf("  modified:    %t", sr.ContentModified),
+	)
+
+	for _, s := range sr.Changes {
+		ss = append(ss, "  change:      "+s)
+	}
+
+	for _, s := range sr.Problems {
+		ss = append(ss, "  problem:     "+s)
+	}
+
+	return strings.Join(ss, "\n")
+}
+
+func (sr *SignatureReport) problem(format string, a ...interface{}) {
+	sr.Problems = append(sr.Problems, fmt.Sprintf(format, a...))
+}
+
+// parseDate parses a PDF date string, see 7.9.4.
+func parseDate(s string) (time.Time, bool) {
+
+	s = strings.Replace(strings.TrimPrefix(s, "D:"), "'", "", -1)
+
+	// Year, month, day, hour, minute and second, all but the year optional.
+	i := 0
+	for i < len(s) && i < 14 && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+
+	if i < 4 || i%2 != 0 {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse("20060102150405"[:i], s[:i])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	tz := s[i:]
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') {
+		return t, true
+	}
+
+	h, err1 := strconv.Atoi(tz[1:3])
+	m, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return t, true
+	}
+
+	off := h*3600 + m*60
+	if tz[0] == '-' {
+		off = -off
+	}
+
+	return t.Add(-time.Duration(off) * time.Second).In(time.FixedZone("", off)), true
+}
+
+// signatureDicts returns the signature dicts of all signed signature fields by field name.
+func signatureDicts(xRefTable *XRefTable) (map[string]*PDFDict, error) {
+
+	fields, _, err := formFieldsByName(xRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]*PDFDict{}
+
+	for name, f := range fields {
+
+		if f.fa.ft != "Sig" {
+			continue
+		}
+
+		o, found := f.dict.Find("V")
+		if !found {
+			continue
+		}
+
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if d != nil {
+			m[name] = d
+		}
+	}
+
+	return m, nil
+}
+
+// signatureByteRange checks the byte range of a signature dict against the file b
+// and returns the signed bytes along with the embedded CMS signature.
+func signatureByteRange(xRefTable *XRefTable, d *PDFDict, b []byte, sr *SignatureReport) ([]byte, []byte, error) {
+
+	arr, err := xRefTable.DereferenceArray(d.Dict["ByteRange"])
+	if err != nil || arr == nil || len(*arr) != 4 {
+		return nil, nil, errors.New("invalid byte range")
+	}
+
+	for _, o := range *arr {
+		i, err := xRefTable.DereferenceInteger(o)
+		if err != nil || i == nil || *i < 0 {
+			return nil, nil, errors.New("invalid byte range")
+		}
+		sr.ByteRange = append(sr.ByteRange, int64(*i))
+	}
+
+	off1, len1, off2, len2 := sr.ByteRange[0], sr.ByteRange[1], sr.ByteRange[2], sr.ByteRange[3]
+
+	if off1 != 0 {
+		return nil, nil, errors.New("byte range does not start at the beginning of the file")
+	}
+
+	if off1+len1 >= off2 || off2+len2 > int64(len(b)) {
+		return nil, nil, errors.New("byte range exceeds file")
+	}
+
+	sr.WholeFile = off2+len2 == int64(len(b))
+
+	// The gap has to be the hex string holding the signature.
+	gap := b[len1:off2]
+	if gap[0] != '<' || gap[len(gap)-1] != '>' {
+		return nil, nil, errors.New("byte range gap does not match contents")
+	}
+
+	sig, err := hex.DecodeString(string(gap[1 : len(gap)-1]))
+	if err != nil {
+		return nil, nil, errors.New("byte range gap does not match contents")
+	}
+
+	if hl := d.PDFHexLiteralEntry("Contents"); hl != nil {
+		if c, err := hl.Bytes(); err != nil || !bytes.Equal(c, sig) {
+			return nil, nil, errors.New("byte range gap does not match contents")
+		}
+	}
+
+	if !sr.WholeFile && !bytes.HasSuffix(bytes.TrimRight(b[:off2+len2], "\r\n"), []byte("%%EOF")) {
+		sr.problem("byte range does not cover a complete revision")
+	}
+
+	signed := append(append([]byte{}, b[:len1]...), b[off2:off2+len2]...)
+
+	return signed, sig, nil
+}
+
+// signerCertificate returns the certificate identified by sid.
+func signerCertificate(sid asn1.RawValue, certs []*x509.Certificate) *x509.Certificate {
+
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+
+	var ias issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil
+	}
+
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// x509SignatureAlgorithm returns the algorithm for verifying a signature of cert using digest algorithm digestAlg.
+func x509SignatureAlgorithm(cert *x509.Certificate, digestAlg asn1.ObjectIdentifier) x509.SignatureAlgorithm {
+
+	switch cert.PublicKey.(type) {
+
+	case *rsa.PublicKey:
+		switch {
+		case digestAlg.Equal(oidSHA1):
+			return x509.SHA1WithRSA
+		case digestAlg.Equal(oidSHA256):
+			return x509.SHA256WithRSA
+		case digestAlg.Equal(oidSHA384):
+			return x509.SHA384WithRSA
+		case digestAlg.Equal(oidSHA512):
+			return x509.SHA512WithRSA
+		}
+
+	case *ecdsa.PublicKey:
+		switch {
+		case digestAlg.Equal(oidSHA1):
+			return x509.ECDSAWithSHA1
+		case digestAlg.Equal(oidSHA256):
+			return x509.ECDSAWithSHA256
+		case digestAlg.Equal(oidSHA384):
+			return x509.ECDSAWithSHA384
+		case digestAlg.Equal(oidSHA512):
+			return x509.ECDSAWithSHA512
+		}
+	}
+
+	return x509.UnknownSignatureAlgorithm
+}
+
+// attributeValue returns the first value of the attribute oid.
+func attributeValue(attrs []attribute, oid asn1.ObjectIdentifier) *asn1.RawValue {
+	for _, a := range attrs {
+		if a.Type.Equal(oid) && len(a.Values) > 0 {
+			return &a.Values[0]
+		}
+	}
+	return nil
+}
+
+// parseSignedData returns the signed data of a CMS signature.
+func parseSignedData(sig []byte) (*signedData, error) {
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(sig, &ci); err != nil {
+		return nil, errors.Wrap(err, "corrupt CMS signature")
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("CMS signature is not signed data")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, errors.Wrap(err, "corrupt CMS signed data")
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, errors.Errorf("CMS signature with %d signers", len(sd.SignerInfos))
+	}
+
+	return &sd, nil
+}
+
+// signedDataCertificates returns the certificates of sd starting with the signing certificate.
+func signedDataCertificates(sd *signedData) ([]*x509.Certificate, error) {
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "corrupt certificates")
+	}
+
+	cert := signerCertificate(sd.SignerInfos[0].SID, certs)
+	if cert == nil {
+		return nil, errors.New("missing signing certificate")
+	}
+
+	ss := []*x509.Certificate{cert}
+	for _, c := range certs {
+		if c != cert {
+			ss = append(ss, c)
+		}
+	}
+
+	return ss, nil
+}
+
+// verifyCMS verifies a detached CMS signature of signed, the bytes covered by the byte range.
+// It returns the signer's certificates starting with the signing certificate.
+func verifyCMS(sig, signed []byte, roots *x509.CertPool, sr *SignatureReport) ([]*x509.Certificate, error) {
+
+	sd, err := parseSignedData(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := signedDataCertificates(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	si := sd.SignerInfos[0]
+	cert := certs[0]
+
+	sr.Signer = cert.Subject.String()
+
+	newHash := hashForOID(si.DigestAlgorithm.Algorithm)
+	if newHash == nil {
+		return nil, errors.Errorf("unsupported digest algorithm: %v", si.DigestAlgorithm.Algorithm)
+	}
+
+	content, contentValid := signed, true
+
+	if len(sd.ContentInfo.Content.Bytes) > 0 {
+
+		var ec []byte
+		if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &ec); err != nil {
+			return nil, errors.Wrap(err, "corrupt encapsulated content")
+		}
+
+		var digest []byte
+
+		if sd.ContentInfo.ContentType.Equal(oidTSTInfo) {
+			// A time stamp token holds the digest of the time stamped data.
+			tst, err := parseTSTInfo(sd.ContentInfo.Content.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			newImprintHash := hashForOID(tst.MessageImprint.HashAlgorithm.Algorithm)
+			if newImprintHash == nil {
+				return nil, errors.Errorf("unsupported digest algorithm: %v", tst.MessageImprint.HashAlgorithm.Algorithm)
+			}
+			h := newImprintHash()
+			h.Write(signed)
+			digest = h.Sum(nil)
+			contentValid = bytes.Equal(tst.MessageImprint.HashedMessage, digest)
+			sr.SigningTime, sr.Timestamp = tst.GenTime, tst.GenTime
+		} else {
+			// adbe.pkcs7.sha1 signs the SHA-1 digest of the byte range.
+			h := hashForOID(oidSHA1)()
+			h.Write(signed)
+			contentValid = bytes.Equal(h.Sum(nil), ec)
+		}
+
+		content = ec
+	}
+
+	h := newHash()
+	h.Write(content)
+	digest := h.Sum(nil)
+
+	alg := x509SignatureAlgorithm(cert, si.DigestAlgorithm.Algorithm)
+	if alg == x509.UnknownSignatureAlgorithm {
+		return nil, errors.Errorf("unsupported signature algorithm: %v", si.SignatureAlgorithm.Algorithm)
+	}
+
+	if len(si.SignedAttrs.FullBytes) == 0 {
+		// The signature covers the content itself.
+		sr.DigestValid = contentValid
+		sr.SignatureValid = cert.CheckSignature(alg, content, si.Signature) == nil
+		return certs, nil
+	}
+
+	var attrs []attribute
+	if _, err := asn1.UnmarshalWithParams(si.SignedAttrs.FullBytes, &attrs, "set,tag:0"); err != nil {
+		return nil, errors.Wrap(err, "corrupt signed attributes")
+	}
+
+	if v := attributeValue(attrs, oidAttrMessageDigest); v != nil {
+		var md []byte
+		if _, err := asn1.Unmarshal(v.FullBytes, &md); err == nil {
+			sr.DigestValid = contentValid && bytes.Equal(md, digest)
+		}
+	}
+
+	if v := attributeValue(attrs, oidAttrSigningTime); v != nil && sr.Timestamp.IsZero() {
+		var t time.Time
+		if _, err := asn1.Unmarshal(v.FullBytes, &t); err == nil {
+			sr.SigningTime = t
+		}
+	}
+
+	// The signature covers the DER encoded SET OF signed attributes.
+	signedAttrs := append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+
+	sr.SignatureValid = cert.CheckSignature(alg, signedAttrs, si.Signature) == nil
+
+	if len(si.UnsignedAttrs.FullBytes) > 0 {
+		if err := verifySignatureTimestamp(si, roots, sr); err != nil {
+			sr.problem("signature time stamp: %v", err)
+		}
+	}
+
+	return certs, nil
+}
+
+// verifySignatureTimestamp verifies the time stamp token of the signature value of si, if any.
+func verifySignatureTimestamp(si signerInfo, roots *x509.CertPool, sr *SignatureReport) error {
+
+	var attrs []attribute
+	if _, err := asn1.UnmarshalWithParams(si.UnsignedAttrs.FullBytes, &attrs, "set,tag:1"); err != nil {
+		return errors.Wrap(err, "corrupt unsigned attributes")
+	}
+
+	v := attributeValue(attrs, oidAttrTimeStampToken)
+	if v == nil {
+		return nil
+	}
+
+	tsr := &SignatureReport{}
+
+	certs, err := verifyCMS(v.FullBytes, si.Signature, roots, tsr)
+	if err != nil {
+		return err
+	}
+
+	if tsr.Timestamp.IsZero() {
+		return errors.New("missing time stamp info")
+	}
+
+	if !tsr.DigestValid || !tsr.SignatureValid {
+		return errors.New("invalid")
+	}
+
+	verifyChain(certs, roots, tsr.Timestamp, tsr)
+	if !tsr.ChainTrusted {
+		return errors.New(strings.Join(tsr.Problems, ", "))
+	}
+
+	sr.Timestamp = tsr.Timestamp
+
+	return nil
+}
+
+// verifyChain checks the signing certificate certs[0] against roots or the system roots if nil
+// at the verified time stamp t or now if t is zero.
+func verifyChain(certs []*x509.Certificate, roots *x509.CertPool, t time.Time, sr *SignatureReport) {
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+
+	if _, err := certs[0].Verify(opts); err != nil {
+		sr.problem("certificate: %v", err)
+		return
+	}
+
+	sr.ChainTrusted = true
+}
+
+func dictOf(o PDFObject) (*PDFDict, bool) {
+	switch o := o.(type) {
+	case PDFDict:
+		return &o, true
+	case PDFStreamDict:
+		return &o.PDFDict, false
+	}
+	return nil, false
+}
+
+// changedKeys returns the sorted keys of all entries differing between d1 and d2.
+func changedKeys(d1, d2 *PDFDict) []string {
+
+	m := map[string]bool{}
+
+	for k, v := range d1.Dict {
+		if v2, found := d2.Find(k); !found || v2.PDFString() != v.PDFString() {
+			m[k] = true
+		}
+	}
+
+	for k := range d2.Dict {
+		if _, found := d1.Find(k); !found {
+			m[k] = true
+		}
+	}
+
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// classifyChange describes the modification of object objNr from o1 to o2
+// and whether it is compatible with signing, ie. does not touch signed content.
+func classifyChange(objNr int, o1, o2 PDFObject, contents IntSet) (string, bool) {
+
+	if contents[objNr] {
+		return fmt.Sprintf("object #%d: page content modified", objNr), false
+	}
+
+	d2, isDict := dictOf(o2)
+	if d2 == nil {
+		if _, ok := o2.(PDFArray); ok {
+			return fmt.Sprintf("object #%d: array modified", objNr), true
+		}
+		return fmt.Sprintf("object #%d modified", objNr), false
+	}
+
+	if !isDict {
+		return fmt.Sprintf("object #%d: stream modified", objNr), false
+	}
+
+	var t, st string
+	if s := d2.Type(); s != nil {
+		t = *s
+	}
+	if s := d2.Subtype(); s != nil {
+		st = *s
+	}
+
+	switch {
+
+	case t == "Sig" || t == "DocTimeStamp":
+		return fmt.Sprintf("object #%d: signature modified", objNr), true
+
+	case t == "DSS" || t == "VRI":
+		return fmt.Sprintf("object #%d: document security store modified", objNr), true
+
+	case d2.NameEntry("FT") != nil || st == "Widget":
+		return fmt.Sprintf("object #%d: form field modified", objNr), true
+
+	case t == "Annot" || (t == "" && st != "" && st != "Form" && st != "Image"):
+		return fmt.Sprintf("object #%d: annotation modified", objNr), true
+
+	case t == "" && d2.PDFArrayEntry("Fields") != nil:
+		return fmt.Sprintf("object #%d: interactive form modified", objNr), true
+
+	case t == "Catalog" || t == "Page":
+		d1, _ := dictOf(o1)
+		if d1 == nil {
+			break
+		}
+		allowed := map[string]bool{"Annots": true}
+		if t == "Catalog" {
+			allowed = map[string]bool{"AcroForm": true, "DSS": true, "Extensions": true, "Perms": true, "Version": true}
+		}
+		keys := changedKeys(d1, d2)
+		for _, k := range keys {
+			if !allowed[k] {
+				return fmt.Sprintf("object #%d: %s %s modified", objNr, strings.ToLower(t), k), false
+			}
+		}
+		return fmt.Sprintf("object #%d: %s %s modified", objNr, strings.ToLower(t), strings.Join(keys, ",")), true
+	}
+
+	return fmt.Sprintf("object #%d modified", objNr), false
+}
+
+// pageContents returns the object numbers of all page content streams and arrays.
+func pageContents(xRefTable *XRefTable) IntSet {
+
+	m := IntSet{}
+
+	for _, entry := range xRefTable.Table {
+
+		if entry.Free {
+			continue
+		}
+
+		d, ok := entry.Object.(PDFDict)
+		if !ok || d.Type() == nil || *d.Type() != "Page" {
+			continue
+		}
+
+		o, found := d.Find("Contents")
+		if !found {
+			continue
+		}
+
+		if indRef, ok := o.(PDFIndirectRef); ok {
+			m[indRef.ObjectNumber.Value()] = true
+			o, _ = xRefTable.Dereference(indRef)
+		}
+
+		if arr, ok := o.(PDFArray); ok {
+			for _, o := range arr {
+				if indRef, ok := o.(PDFIndirectRef); ok {
+					m[indRef.ObjectNumber.Value()] = true
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+// readRevision reads the revision of the document ending at offset end of the file b.
+func readRevision(b []byte, end int64, config *Configuration) (*PDFContext, error) {
+	return Read(bytes.NewReader(b[:end]), config)
+}
+
+// checkModifications compares the signed revision of ctx with the final one.
+func checkModifications(ctx *PDFContext, b []byte, sr *SignatureReport) {
+
+	rev, err := readRevision(b, sr.ByteRange[2]+sr.ByteRange[3], ctx.Configuration)
+	if err != nil {
+		sr.problem("signed revision: %v", err)
+		return
+	}
+
+	contents := pageContents(rev.XRefTable)
+	for objNr := range pageContents(ctx.XRefTable) {
+		contents[objNr] = true
+	}
+
+	var objNrs []int
+	for objNr := range rev.Table {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+
+		entry1 := rev.Table[objNr]
+		if objNr == 0 || entry1.Free {
+			continue
+		}
+
+		if d, _ := dictOf(entry1.Object); d != nil && d.Type() != nil && (*d.Type() == "XRef" || *d.Type() == "ObjStm") {
+			continue
+		}
+
+		entry2, found := ctx.FindTableEntryLight(objNr)
+		if !found || entry2.Free {
+			sr.Changes = append(sr.Changes, fmt.Sprintf("object #%d deleted", objNr))
+			sr.ContentModified = true
+			continue
+		}
+
+		if objectFingerprint(entry1.Object) == objectFingerprint(entry2.Object) {
+			continue
+		}
+
+		s, ok := classifyChange(objNr, entry1.Object, entry2.Object, contents)
+		sr.Changes = append(sr.Changes, s)
+		if !ok {
+			sr.ContentModified = true
+		}
+	}
+}
+
+func verifySignature(ctx *PDFContext, fieldName string, d *PDFDict, b []byte, roots *x509.CertPool) *SignatureReport {
+
+	sr := &SignatureReport{FieldName: fieldName}
+
+	if s := d.NameEntry("SubFilter"); s != nil {
+		sr.SubFilter = *s
+	}
+
+	if s, err := textString(ctx, d.Dict["Reason"]); err == nil {
+		sr.Reason = s
+	}
+
+	if s, err := textString(ctx, d.Dict["Location"]); err == nil {
+		sr.Location = s
+	}
+
+	if s, err := textString(ctx, d.Dict["M"]); err == nil {
+		sr.SigningTime, _ = parseDate(s)
+	}
+
+	signed, sig, err := signatureByteRange(ctx.XRefTable, d, b, sr)
+	if err != nil {
+		sr.problem("%v", err)
+		return sr
+	}
+
+	switch sr.SubFilter {
+	case "adbe.pkcs7.detached", "adbe.pkcs7.sha1", "ETSI.CAdES.detached", "ETSI.RFC3161":
+	default:
+		sr.problem("unsupported sub filter: %s", sr.SubFilter)
+		return sr
+	}
+
+	certs, err := verifyCMS(sig, signed, roots, sr)
+	if err != nil {
+		sr.problem("%v", err)
+		return sr
+	}
+
+	if sr.SubFilter == "ETSI.RFC3161" && sr.Timestamp.IsZero() {
+		sr.problem("document time stamp without time stamp info")
+	}
+
+	// Only a verified time stamp proves the signature existed at that time.
+	// The signing time is claimed by the signer and never used for chain validation.
+	var t time.Time
+	if !sr.Timestamp.IsZero() && sr.DigestValid && sr.SignatureValid {
+		t = sr.Timestamp
+	}
+
+	verifyChain(certs, roots, t, sr)
+
+	if !sr.WholeFile {
+		checkModifications(ctx, b, sr)
+	}
+
+	return sr
+}
+
+// VerifySignatures verifies all signatures of ctx read from the file b using roots as trust anchors.
+// If roots is nil the system roots are used.
+func VerifySignatures(ctx *PDFContext, b []byte, roots *x509.CertPool) ([]*SignatureReport, error) {
+
+	log.Debug.Println("VerifySignatures begin")
+
+	if ctx.Encrypt != nil {
+		return nil, newErrorf(ErrEncrypted, "VerifySignatures: encrypted documents are not supported")
+	}
+
+	m, err := signatureDicts(ctx.XRefTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var srs []*SignatureReport
+	for _, name := range names {
+		srs = append(srs, verifySignature(ctx, name, m[name], b, roots))
+	}
+
+	log.Debug.Println("VerifySignatures end")
+
+	return srs, nil
+}
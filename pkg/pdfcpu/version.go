@@ -17,7 +17,7 @@ const (
 // PDFVersion is a type for the internal representation of PDF versions.
 type PDFVersion int
 
-// Constants for all PDF versions up to v1.7
+// Constants for all PDF versions up to v2.0
 const (
 	V10 PDFVersion = iota
 	V11
@@ -27,6 +27,7 @@ const (
 	V15
 	V16
 	V17
+	V20
 )
 
 // Version returns the PDFVersion for a version string.
@@ -49,6 +50,8 @@ func Version(versionStr string) (PDFVersion, error) {
 		return V16, nil
 	case "1.7":
 		return V17, nil
+	case "2.0":
+		return V20, nil
 	}
 
 	return -1, errors.New(versionStr)
@@ -56,5 +59,43 @@ func Version(versionStr string) (PDFVersion, error) {
 
 // VersionString returns a string representation for a given PDFVersion.
 func VersionString(version PDFVersion) string {
+	if version == V20 {
+		return "2.0"
+	}
 	return "1." + fmt.Sprintf("%d", version)
 }
+
+// ensureExtensionLevel declares the developer extension prefix with at least level based on PDF 1.7, see 7.12.
+func ensureExtensionLevel(xRefTable *XRefTable, prefix string, level int) error {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	d := NewPDFDict()
+	if obj, found := rootDict.Find("Extensions"); found {
+		ext, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return err
+		}
+		if ext != nil {
+			d = *ext
+		}
+	}
+
+	if e := d.PDFDictEntry(prefix); e != nil {
+		if l := e.IntEntry("ExtensionLevel"); l != nil && *l >= level {
+			return nil
+		}
+	}
+
+	d.Update(prefix, PDFDict{Dict: map[string]PDFObject{
+		"BaseVersion":    PDFName("1.7"),
+		"ExtensionLevel": PDFInteger(level),
+	}})
+
+	rootDict.Update("Extensions", d)
+
+	return nil
+}
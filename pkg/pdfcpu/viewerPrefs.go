@@ -0,0 +1,306 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Viewer preferences
+//
+// How a document gets presented on opening is controlled by the catalog entries PageLayout, PageMode,
+// OpenAction and the ViewerPreferences dict (12.2).
+// The open action either is a destination or an action performed on opening like running JavaScript.
+// Removing the open action gets rid of such auto-run actions.
+
+// ViewerPrefs represents the presentation settings of a document.
+// Empty strings and nil flags stand for entries not present.
+type ViewerPrefs struct {
+	PageLayout      string `json:"pageLayout,omitempty"`
+	PageMode        string `json:"pageMode,omitempty"`
+	HideToolbar     *bool  `json:"hideToolbar,omitempty"`
+	HideMenubar     *bool  `json:"hideMenubar,omitempty"`
+	HideWindowUI    *bool  `json:"hideWindowUI,omitempty"`
+	FitWindow       *bool  `json:"fitWindow,omitempty"`
+	CenterWindow    *bool  `json:"centerWindow,omitempty"`
+	DisplayDocTitle *bool  `json:"displayDocTitle,omitempty"`
+	Duplex          string `json:"duplex,omitempty"`
+	OpenPage        int    `json:"openPage,omitempty"`   // page of an open action destination
+	OpenView        string `json:"openView,omitempty"`   // view of an open action destination, eg. "XYZ null null 1.5"
+	OpenAction      string `json:"openAction,omitempty"` // type of an open action other than a destination, eg. JavaScript
+}
+
+// See 7.7.2 Table 28 and 12.2 Table 150
+var (
+	pageLayouts = []string{"SinglePage", "OneColumn", "TwoColumnLeft", "TwoColumnRight", "TwoPageLeft", "TwoPageRight"}
+	pageModes   = []string{"UseNone", "UseOutlines", "UseThumbs", "FullScreen", "UseOC", "UseAttachments"}
+	duplexModes = []string{"Simplex", "DuplexFlipShortEdge", "DuplexFlipLongEdge"}
+)
+
+// flags maps the flag entries of the ViewerPreferences dict to the fields of vp.
+func (vp *ViewerPrefs) flags() map[string]**bool {
+	return map[string]**bool{
+		"HideToolbar":     &vp.HideToolbar,
+		"HideMenubar":     &vp.HideMenubar,
+		"HideWindowUI":    &vp.HideWindowUI,
+		"FitWindow":       &vp.FitWindow,
+		"CenterWindow":    &vp.CenterWindow,
+		"DisplayDocTitle": &vp.DisplayDocTitle,
+	}
+}
+
+// ViewerPrefKeys returns the keys accepted by RemoveViewerPreferences.
+func ViewerPrefKeys() []string {
+
+	kk := []string{"PageLayout", "PageMode", "Duplex", "OpenAction"}
+	for k := range (&ViewerPrefs{}).flags() {
+		kk = append(kk, k)
+	}
+
+	sort.Strings(kk)
+
+	return kk
+}
+
+func viewerPrefsDict(xRefTable *XRefTable, rootDict *PDFDict) (*PDFDict, error) {
+
+	obj, found := rootDict.Find("ViewerPreferences")
+	if !found {
+		return nil, nil
+	}
+
+	return xRefTable.DereferenceDict(obj)
+}
+
+func nameEntry(xRefTable *XRefTable, d *PDFDict, key string) string {
+
+	o, err := xRefTable.Dereference(d.Dict[key])
+	if err != nil {
+		return ""
+	}
+
+	if n, ok := o.(PDFName); ok {
+		return n.Value()
+	}
+
+	return ""
+}
+
+// openAction fills in the open action of vp.
+func openAction(xRefTable *XRefTable, rootDict *PDFDict, vp *ViewerPrefs) error {
+
+	o, err := xRefTable.Dereference(rootDict.Dict["OpenAction"])
+	if err != nil || o == nil {
+		return err
+	}
+
+	if d, ok := o.(PDFDict); ok {
+		s := d.NameEntry("S")
+		if s == nil || *s != "GoTo" {
+			vp.OpenAction = "unknown"
+			if s != nil {
+				vp.OpenAction = *s
+			}
+			return nil
+		}
+		if o, err = xRefTable.Dereference(d.Dict["D"]); err != nil {
+			return err
+		}
+	}
+
+	pages, err := pageNrs(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	r := &outlineReader{xRefTable: xRefTable, pages: pages, visited: IntSet{}}
+
+	b := &Bookmark{}
+	if err = r.destination(o, b); err != nil {
+		return err
+	}
+
+	if b.Dest != "" {
+		vp.OpenAction = "GoTo " + b.Dest
+		return nil
+	}
+
+	vp.OpenPage, vp.OpenView = b.Page, b.View
+
+	return nil
+}
+
+// ViewerPreferences returns the presentation settings of the document.
+func ViewerPreferences(xRefTable *XRefTable) (*ViewerPrefs, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	vp := &ViewerPrefs{
+		PageLayout: nameEntry(xRefTable, rootDict, "PageLayout"),
+		PageMode:   nameEntry(xRefTable, rootDict, "PageMode"),
+	}
+
+	d, err := viewerPrefsDict(xRefTable, rootDict)
+	if err != nil {
+		return nil, err
+	}
+
+	if d != nil {
+		for k, f := range vp.flags() {
+			*f = d.BooleanEntry(k)
+		}
+		vp.Duplex = nameEntry(xRefTable, d, "Duplex")
+	}
+
+	if err = openAction(xRefTable, rootDict, vp); err != nil {
+		return nil, err
+	}
+
+	return vp, nil
+}
+
+func checkViewerPrefName(key, s string, names []string) error {
+	if s != "" && !memberOf(s, names) {
+		return errors.Errorf("pdfcpu: invalid %s: %s", key, s)
+	}
+	return nil
+}
+
+// SetViewerPreferences sets the non empty entries of vp.
+// An OpenPage sets the open action to a destination replacing any action performed on opening.
+func SetViewerPreferences(xRefTable *XRefTable, vp ViewerPrefs) error {
+
+	if err := checkViewerPrefName("PageLayout", vp.PageLayout, pageLayouts); err != nil {
+		return err
+	}
+
+	if err := checkViewerPrefName("PageMode", vp.PageMode, pageModes); err != nil {
+		return err
+	}
+
+	if err := checkViewerPrefName("Duplex", vp.Duplex, duplexModes); err != nil {
+		return err
+	}
+
+	if vp.OpenAction != "" {
+		return errors.New("pdfcpu: open actions other than destinations may not be set")
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	if vp.OpenPage != 0 {
+		if vp.OpenPage < 0 || vp.OpenPage > xRefTable.PageCount {
+			return errors.Errorf("pdfcpu: open action: invalid page: %d", vp.OpenPage)
+		}
+		view, err := parseDestView(vp.OpenView)
+		if err != nil {
+			return err
+		}
+		pageIndRef, err := xRefTable.PageDictIndRef(vp.OpenPage)
+		if err != nil {
+			return err
+		}
+		if pageIndRef == nil {
+			return errors.Errorf("pdfcpu: open action: page %d not found", vp.OpenPage)
+		}
+		rootDict.Update("OpenAction", append(PDFArray{*pageIndRef}, view...))
+	}
+
+	if vp.PageLayout != "" {
+		rootDict.Update("PageLayout", PDFName(vp.PageLayout))
+	}
+
+	if vp.PageMode != "" {
+		rootDict.Update("PageMode", PDFName(vp.PageMode))
+	}
+
+	d, err := viewerPrefsDict(xRefTable, rootDict)
+	if err != nil {
+		return err
+	}
+
+	if d == nil {
+		dict := NewPDFDict()
+		d = &dict
+	}
+
+	for k, f := range vp.flags() {
+		if *f != nil {
+			d.Update(k, PDFBoolean(**f))
+		}
+	}
+
+	if vp.Duplex != "" {
+		d.Update("Duplex", PDFName(vp.Duplex))
+	}
+
+	if d.Len() > 0 {
+		if _, found := rootDict.Find("ViewerPreferences"); !found {
+			rootDict.Insert("ViewerPreferences", *d)
+		}
+	}
+
+	return nil
+}
+
+// RemoveViewerPreferences removes the entries keys, see ViewerPrefKeys.
+// An empty ViewerPreferences dict gets removed as well.
+func RemoveViewerPreferences(xRefTable *XRefTable, keys []string) error {
+
+	valid := ViewerPrefKeys()
+
+	for _, k := range keys {
+		if !memberOf(k, valid) {
+			return errors.Errorf("pdfcpu: invalid viewer preference: %s", k)
+		}
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	d, err := viewerPrefsDict(xRefTable, rootDict)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		switch k {
+		case "PageLayout", "PageMode", "OpenAction":
+			rootDict.Delete(k)
+		default:
+			if d != nil {
+				d.Delete(k)
+			}
+		}
+	}
+
+	if d != nil && d.Len() == 0 {
+		rootDict.Delete("ViewerPreferences")
+	}
+
+	return nil
+}
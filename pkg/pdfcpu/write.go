@@ -21,7 +21,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"os"
+	"io"
 	"sort"
 	"strings"
 
@@ -30,19 +30,17 @@ import (
 )
 
 // WritePDFFile generates a PDF file for the cross reference table contained in PDFContext.
-func WritePDFFile(ctx *PDFContext) error {
+func WritePDFFile(ctx *PDFContext) (err error) {
 
 	fileName := ctx.Write.DirName + ctx.Write.FileName
 
 	log.Info.Printf("writing to %s\n", fileName)
 
-	file, err := os.Create(fileName)
+	file, err := ctx.createFile(fileName)
 	if err != nil {
 		return errors.Wrapf(err, "can't create %s\n%s", fileName, err)
 	}
 
-	ctx.Write.Writer = bufio.NewWriter(file)
-
 	defer func() {
 
 		// The underlying bufio.Writer has already been flushed.
@@ -58,23 +56,62 @@ func WritePDFFile(ctx *PDFContext) error {
 
 	}()
 
-	err = handleEncryption(ctx)
+	return WritePDF(ctx, file)
+}
+
+// countingWriter keeps track of the number of bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// WritePDF generates a PDF for the cross reference table contained in PDFContext and writes it to w.
+func WritePDF(ctx *PDFContext, w io.Writer) error {
+	return ctx.profile(PhaseWrite, func() error { return writePDF(ctx, w) })
+}
+
+func writePDF(ctx *PDFContext, w io.Writer) error {
+
+	cw := &countingWriter{w: w}
+	ctx.Write.Writer = bufio.NewWriter(cw)
+
+	err := handleEncryption(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Since we support PDF Collections (since V1.7) for file attachments
 	// we need to always generate V1.7 PDF filess.
-	err = writeHeader(ctx.Write, V17)
+	// PDF 2.0 files stay PDF 2.0 files.
+	v := V17
+	if ctx.Version() == V20 {
+		v = V20
+	}
+
+	err = writeHeader(ctx.Write, v)
 	if err != nil {
 		return err
 	}
 
+	ctx.Write.CompressObjects = ctx.CompressObjects && ctx.WriteObjectStream && ctx.WriteXRefStream && !ctx.WriteHybridXRef
+
 	// Ensure there is no root version.
 	if ctx.RootVersion != nil {
 		ctx.RootDict.Delete("Version")
 	}
 
+	// Update the document info dict and keep it in sync with the XMP metadata.
+	err = syncMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
 	log.Debug.Printf("offset after writeHeader: %d\n", ctx.Write.Offset)
 
 	// Write root object(aka the document catalog) and page tree.
@@ -101,6 +138,14 @@ func WritePDFFile(ctx *PDFContext) error {
 		}
 	}
 
+	// The encryption dict must not go into an object stream.
+	ctx.Write.CompressObjects = false
+
+	err = flushObjectStream(ctx)
+	if err != nil {
+		return err
+	}
+
 	err = writeEncryptDict(ctx)
 	if err != nil {
 		return err
@@ -121,11 +166,14 @@ func WritePDFFile(ctx *PDFContext) error {
 		return err
 	}
 
-	err = setFileSizeOfWrittenFile(ctx.Write, file)
+	// Flush first to get the correct file size.
+	err = ctx.Write.Flush()
 	if err != nil {
 		return err
 	}
 
+	ctx.Write.FileSize = cw.n
+
 	if ctx.Read != nil {
 		ctx.Write.BinaryImageSize = ctx.Read.BinaryImageSize
 		ctx.Write.BinaryFontSize = ctx.Read.BinaryFontSize
@@ -181,6 +229,11 @@ func writePages(ctx *PDFContext, rootDict *PDFDict) error {
 		}
 	}
 
+	if ctx.WriteHybridXRef {
+		// PDF 1.4 readers of hybrid-reference files need access to the page tree.
+		return writePagesDict(ctx, indRef, 0)
+	}
+
 	// Embed all page tree objects into objects stream.
 	ctx.Write.WriteToObjectStream = true
 
@@ -301,6 +354,8 @@ func writeRootObject(ctx *PDFContext) error {
 		{"Requirements", RootRequirements},
 		{"Collection", RootCollection},
 		{"NeedsRendering", RootNeedsRendering},
+		{"AF", RootAF},
+		{"DPartRoot", RootDPartRoot},
 	} {
 		err = writeRootEntry(ctx, dict, dictName, e.entryName, e.statsAttr)
 		if err != nil {
@@ -346,6 +401,10 @@ func writeTrailerDict(ctx *PDFContext) error {
 		dict.Insert("ID", *xRefTable.ID)
 	}
 
+	if w.XRefStm != nil {
+		dict.Insert("XRefStm", PDFInteger(*w.XRefStm))
+	}
+
 	_, err = w.WriteString(dict.PDFString())
 	if err != nil {
 		return err
@@ -356,6 +415,9 @@ func writeTrailerDict(ctx *PDFContext) error {
 	return nil
 }
 
+// maxXRefTableOffset is the largest offset fitting into the 10 digits of an xref table entry.
+const maxXRefTableOffset int64 = 9999999999
+
 func writeXRefSubsection(ctx *PDFContext, start int, size int) error {
 
 	log.Debug.Printf("writeXRefSubsection: start=%d size=%d\n", start, size)
@@ -407,7 +469,8 @@ func writeXRefSubsection(ctx *PDFContext, start int, size int) error {
 func deleteRedundantObject(ctx *PDFContext, objNr int) {
 
 	if ctx.Write.ExtractPageNr == 0 &&
-		(ctx.Optimize.IsDuplicateFontObject(objNr) || ctx.Optimize.IsDuplicateImageObject(objNr)) {
+		(ctx.Optimize.IsDuplicateFontObject(objNr) || ctx.Optimize.IsDuplicateImageObject(objNr) ||
+			ctx.Optimize.IsDuplicateStreamObject(objNr)) {
 		ctx.DeleteObject(objNr)
 	}
 
@@ -449,6 +512,7 @@ func deleteRedundantObjects(ctx *PDFContext) {
 			delete(ctx.Optimize.DuplicateFontObjs, i)
 			delete(ctx.Optimize.DuplicateImageObjs, i)
 			delete(ctx.Optimize.DuplicateInfoObjects, i)
+			delete(ctx.Optimize.DuplicateStreamObjs, i)
 			continue
 		}
 
@@ -482,21 +546,6 @@ func deleteRedundantObjects(ctx *PDFContext) {
 	log.Debug.Println("deleteRedundantObjects end")
 }
 
-func sortedWritableKeys(ctx *PDFContext) []int {
-
-	var keys []int
-
-	for i, e := range ctx.Table {
-		if e.Free || ctx.Write.HasWriteOffset(i) {
-			keys = append(keys, i)
-		}
-	}
-
-	sort.Ints(keys)
-
-	return keys
-}
-
 // After inserting the last object write the cross reference table to disk.
 func writeXRefTable(ctx *PDFContext) error {
 
@@ -505,7 +554,7 @@ func writeXRefTable(ctx *PDFContext) error {
 		return err
 	}
 
-	keys := sortedWritableKeys(ctx)
+	keys := writableKeys(ctx, func(e *XRefTableEntry) bool { return !e.Compressed })
 
 	objCount := len(keys)
 	log.Debug.Printf("xref has %d entries\n", objCount)
@@ -550,27 +599,7 @@ func writeXRefTable(ctx *PDFContext) error {
 		return err
 	}
 
-	err = ctx.Write.WriteEol()
-	if err != nil {
-		return err
-	}
-
-	_, err = ctx.Write.WriteString("startxref")
-	if err != nil {
-		return err
-	}
-
-	err = ctx.Write.WriteEol()
-	if err != nil {
-		return err
-	}
-
-	_, err = ctx.Write.WriteString(fmt.Sprintf("%d", ctx.Write.Offset))
-	if err != nil {
-		return err
-	}
-
-	return ctx.Write.WriteEol()
+	return writeStartXRef(ctx.Write, ctx.Write.Offset)
 }
 
 // int64ToBuf returns a byte slice with length byteCount representing integer i.
@@ -599,7 +628,51 @@ func int64ToBuf(i int64, byteCount int) (buf []byte) {
 	return
 }
 
-func createXRefStream(ctx *PDFContext, i1, i2, i3 int) ([]byte, *PDFArray, error) {
+// byteCount returns the number of bytes needed to represent i, at least 1.
+func byteCount(i int64) int {
+
+	c := 1
+
+	for i >>= 8; i > 0; i >>= 8 {
+		c++
+	}
+
+	return c
+}
+
+// xRefStreamFieldWidths returns the minimal field widths of the xref stream entries for keys
+// given the offset of the last object written.
+func xRefStreamFieldWidths(ctx *PDFContext, keys []int, offset int64) (i1, i2, i3 int) {
+
+	// Field 1: 0, 1 or 2 always fit into 1 byte.
+	i1 = 1
+
+	// Field 2: offset, object number of the next free object or of an object stream.
+	max2 := offset
+	if s := int64(*ctx.Size); s > max2 {
+		max2 = s
+	}
+
+	// Field 3: generation number or index within an object stream.
+	var max3 int64
+
+	for _, k := range keys {
+		entry := ctx.Table[k]
+		if entry.Compressed {
+			if i := int64(*entry.ObjectStreamInd); i > max3 {
+				max3 = i
+			}
+			continue
+		}
+		if g := int64(*entry.Generation); g > max3 {
+			max3 = g
+		}
+	}
+
+	return i1, byteCount(max2), byteCount(max3)
+}
+
+func createXRefStream(ctx *PDFContext, keys []int, i1, i2, i3 int) ([]byte, *PDFArray, error) {
 
 	log.Debug.Println("createXRefStream begin")
 
@@ -610,14 +683,6 @@ func createXRefStream(ctx *PDFContext, i1, i2, i3 int) ([]byte, *PDFArray, error
 		arr PDFArray
 	)
 
-	var keys []int
-	for i, e := range xRefTable.Table {
-		if e.Free || ctx.Write.HasWriteOffset(i) {
-			keys = append(keys, i)
-		}
-	}
-	sort.Ints(keys)
-
 	objCount := len(keys)
 	log.Debug.Printf("createXRefStream: xref has %d entries\n", objCount)
 
@@ -652,7 +717,7 @@ func createXRefStream(ctx *PDFContext, i1, i2, i3 int) ([]byte, *PDFArray, error
 
 			off, found := ctx.Write.Table[j]
 			if !found {
-				return nil, nil, errors.Errorf("createXRefStream: missing write offset for obj #%d\n", i)
+				return nil, nil, errors.Errorf("createXRefStream: missing write offset for obj #%d\n", j)
 			}
 
 			// in use, uncompressed
@@ -691,96 +756,120 @@ func createXRefStream(ctx *PDFContext, i1, i2, i3 int) ([]byte, *PDFArray, error
 	return buf, &arr, nil
 }
 
-func writeXRefStream(ctx *PDFContext) error {
+// writableKeys returns the sorted object numbers of all free or written objects selected by include.
+func writableKeys(ctx *PDFContext, include func(e *XRefTableEntry) bool) []int {
 
-	log.Debug.Println("writeXRefStream begin")
+	var keys []int
+
+	for i, e := range ctx.Table {
+		if (e.Free || ctx.Write.HasWriteOffset(i)) && include(e) {
+			keys = append(keys, i)
+		}
+	}
+
+	sort.Ints(keys)
+
+	return keys
+}
+
+// insertXRefStream inserts a new xref stream dict covering keys plus the xref stream itself
+// given the xref stream will be written at offset.
+func insertXRefStream(ctx *PDFContext, keys func() []int, offset int64) (*PDFXRefStreamDict, int, error) {
 
 	xRefTable := ctx.XRefTable
 	xRefStreamDict := NewPDFXRefStreamDict(ctx)
 	xRefTableEntry := NewXRefTableEntryGen0(*xRefStreamDict)
 
 	// Reuse free objects (including recycled objects from this run).
-	var objNumber int
 	objNumber, err := xRefTable.InsertAndUseRecycled(*xRefTableEntry)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	// After the last insert of an object.
 	err = xRefTable.EnsureValidFreeList()
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	xRefStreamDict.Insert("Size", PDFInteger(*xRefTable.Size))
-
-	offset := ctx.Write.Offset
-
-	i2Base := int64(*ctx.Size)
-	if offset > i2Base {
-		i2Base = offset
-	}
+	// The xref stream is an uncompressed entry.
+	ctx.Write.Table[objNumber] = offset
 
-	i1 := 1 // 0, 1 or 2 always fit into 1 byte.
+	xRefStreamDict.Insert("Size", PDFInteger(*xRefTable.Size))
 
-	i2 := func(i int64) (byteCount int) {
-		for i > 0 {
-			i >>= 8
-			byteCount++
-		}
-		return byteCount
-	}(i2Base)
+	kk := keys()
 
-	i3 := 2 // scale for max objectstream index <= 0x ff ff
+	i1, i2, i3 := xRefStreamFieldWidths(ctx, kk, offset)
 
 	wArr := PDFArray{PDFInteger(i1), PDFInteger(i2), PDFInteger(i3)}
 	xRefStreamDict.Insert("W", wArr)
 
 	// Generate xRefStreamDict data = xref entries -> xRefStreamDict.Content
-	content, indArr, err := createXRefStream(ctx, i1, i2, i3)
+	content, indArr, err := createXRefStream(ctx, kk, i1, i2, i3)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	xRefStreamDict.Content = content
 	xRefStreamDict.Insert("Index", *indArr)
 
 	// Encode xRefStreamDict.Content -> xRefStreamDict.Raw
-	err = encodeStream(&xRefStreamDict.PDFStreamDict)
+	err = encodeStreamLevel(&xRefStreamDict.PDFStreamDict, ctx.CompressionLevel)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	log.Debug.Printf("writeXRefStream: xRefStreamDict: %s\n", xRefStreamDict)
+	log.Debug.Printf("insertXRefStream: xRefStreamDict: %s\n", xRefStreamDict)
 
-	err = writePDFStreamDictObject(ctx, objNumber, 0, xRefStreamDict.PDFStreamDict)
+	return xRefStreamDict, objNumber, nil
+}
+
+func writeStartXRef(w *WriteContext, offset int64) error {
+
+	err := w.WriteEol()
 	if err != nil {
 		return err
 	}
 
-	w := ctx.Write
+	_, err = w.WriteString("startxref")
+	if err != nil {
+		return err
+	}
 
 	err = w.WriteEol()
 	if err != nil {
 		return err
 	}
 
-	_, err = w.WriteString("startxref")
+	_, err = w.WriteString(fmt.Sprintf("%d", offset))
 	if err != nil {
 		return err
 	}
 
-	err = w.WriteEol()
+	return w.WriteEol()
+}
+
+func writeXRefStream(ctx *PDFContext) error {
+
+	log.Debug.Println("writeXRefStream begin")
+
+	offset := ctx.Write.Offset
+
+	keys := func() []int {
+		return writableKeys(ctx, func(*XRefTableEntry) bool { return true })
+	}
+
+	xRefStreamDict, objNumber, err := insertXRefStream(ctx, keys, offset)
 	if err != nil {
 		return err
 	}
 
-	_, err = w.WriteString(fmt.Sprintf("%d", offset))
+	err = writePDFStreamDictObject(ctx, objNumber, 0, xRefStreamDict.PDFStreamDict)
 	if err != nil {
 		return err
 	}
 
-	err = w.WriteEol()
+	err = writeStartXRef(ctx.Write, offset)
 	if err != nil {
 		return err
 	}
@@ -790,6 +879,49 @@ func writeXRefStream(ctx *PDFContext) error {
 	return nil
 }
 
+// writeHybridXRef writes a hybrid-reference file section:
+// an xref stream covering all objects written into object streams
+// followed by an xref table for all other objects whose trailer refers to the xref stream via XRefStm.
+// PDF 1.4 readers ignore the xref stream and therefore any objects within object streams.
+func writeHybridXRef(ctx *PDFContext) error {
+
+	log.Debug.Println("writeHybridXRef begin")
+
+	keys := func() []int {
+		return writableKeys(ctx, func(e *XRefTableEntry) bool { return e.Compressed })
+	}
+
+	if len(keys()) == 0 {
+		// Nothing hidden from PDF 1.4 readers.
+		return writeXRefTable(ctx)
+	}
+
+	offset := ctx.Write.Offset
+
+	xRefStreamDict, objNumber, err := insertXRefStream(ctx, keys, offset)
+	if err != nil {
+		return err
+	}
+
+	// An xref stream of a hybrid file section must not contain trailer entries.
+	for _, k := range []string{"Root", "Info", "ID", "Encrypt"} {
+		xRefStreamDict.Delete(k)
+	}
+
+	err = writePDFStreamDictObject(ctx, objNumber, 0, xRefStreamDict.PDFStreamDict)
+	if err != nil {
+		return err
+	}
+
+	ctx.Write.XRefStm = &offset
+
+	err = writeXRefTable(ctx)
+
+	log.Debug.Println("writeHybridXRef end")
+
+	return err
+}
+
 func writeEncryptDict(ctx *PDFContext) error {
 
 	// Bail out unless we really have to write encrypted.
@@ -815,7 +947,29 @@ func setupEncryption(ctx *PDFContext) error {
 
 	var err error
 
-	dict := newEncryptDict(ctx.EncryptUsingAES, ctx.EncryptUsing128BitKey, ctx.UserAccessPermissions)
+	if len(ctx.Recipients) > 0 {
+		dict, err := setupPubSecEncryption(ctx)
+		if err != nil {
+			return err
+		}
+		return insertEncryptDict(ctx, dict)
+	}
+
+	keyLength := 40
+	switch {
+	case ctx.EncryptUsing256BitKey:
+		keyLength = 256
+	case ctx.EncryptUsing128BitKey:
+		keyLength = 128
+	}
+
+	// PDF 2.0 deprecates RC4 and AES-128 and mandates AES-256, see 7.6.4.
+	if ctx.Version() == V20 && keyLength != 256 {
+		log.Info.Println("encrypt: using AES-256 for PDF 2.0")
+		keyLength = 256
+	}
+
+	dict := newEncryptDict(ctx.EncryptUsingAES, keyLength, ctx.UserAccessPermissions)
 
 	ctx.E, err = supportedEncryption(ctx, dict)
 	if err != nil {
@@ -834,29 +988,50 @@ func setupEncryption(ctx *PDFContext) error {
 
 	ctx.E.ID = id
 
-	//fmt.Printf("opw before: length:%d <%s>\n", len(ctx.E.O), ctx.E.O)
-	ctx.E.O, err = o(ctx)
-	if err != nil {
-		return err
-	}
-	//fmt.Printf("opw after: length:%d <%s> %0X\n", len(ctx.E.O), ctx.E.O, ctx.E.O)
+	if keyLength == 256 {
 
-	//fmt.Printf("upw before: length:%d <%s>\n", len(ctx.E.U), ctx.E.U)
-	ctx.E.U, ctx.EncKey, err = u(ctx)
-	if err != nil {
-		return err
+		if ctx.EncKey, err = fileKeyAES256(); err != nil {
+			return err
+		}
+
+		if err = passwordEntriesAES256(ctx, dict); err != nil {
+			return err
+		}
+
+		if err = ensureExtensionLevelAES256(ctx); err != nil {
+			return err
+		}
+
+	} else {
+
+		//fmt.Printf("opw before: length:%d <%s>\n", len(ctx.E.O), ctx.E.O)
+		ctx.E.O, err = o(ctx)
+		if err != nil {
+			return err
+		}
+		//fmt.Printf("opw after: length:%d <%s> %0X\n", len(ctx.E.O), ctx.E.O, ctx.E.O)
+
+		//fmt.Printf("upw before: length:%d <%s>\n", len(ctx.E.U), ctx.E.U)
+		ctx.E.U, ctx.EncKey, err = u(ctx)
+		if err != nil {
+			return err
+		}
+		//fmt.Printf("upw after: length:%d <%s> %0X\n", len(ctx.E.U), ctx.E.U, ctx.E.U)
+		//fmt.Printf("encKey = %0X\n", ctx.EncKey)
+
+		dict.Update("U", PDFHexLiteral(hex.EncodeToString(ctx.E.U)))
+		dict.Update("O", PDFHexLiteral(hex.EncodeToString(ctx.E.O)))
 	}
-	//fmt.Printf("upw after: length:%d <%s> %0X\n", len(ctx.E.U), ctx.E.U, ctx.E.U)
-	//fmt.Printf("encKey = %0X\n", ctx.EncKey)
 
-	dict.Update("U", PDFHexLiteral(hex.EncodeToString(ctx.E.U)))
-	dict.Update("O", PDFHexLiteral(hex.EncodeToString(ctx.E.O)))
+	return insertEncryptDict(ctx, dict)
+}
+
+func insertEncryptDict(ctx *PDFContext, dict *PDFDict) error {
 
 	xRefTableEntry := NewXRefTableEntryGen0(*dict)
 
 	// Reuse free objects (including recycled objects from this run).
-	var objNumber int
-	objNumber, err = ctx.InsertAndUseRecycled(*xRefTableEntry)
+	objNumber, err := ctx.InsertAndUseRecycled(*xRefTableEntry)
 	if err != nil {
 		return err
 	}
@@ -883,6 +1058,17 @@ func updateEncryption(ctx *PDFContext) error {
 	// Change user or owner password.
 	//fmt.Println("change upw or opw")
 
+	if ctx.E.R >= 5 {
+		if ctx.UserPWNew != nil {
+			ctx.UserPW = *ctx.UserPWNew
+		}
+		if ctx.OwnerPWNew != nil {
+			ctx.OwnerPW = *ctx.OwnerPWNew
+		}
+		// The file encryption key stays the same.
+		return passwordEntriesAES256(ctx, d)
+	}
+
 	if ctx.UserPWNew != nil {
 		//fmt.Printf("change upw from <%s> to <%s>\n", ctx.UserPW, *ctx.UserPWNew)
 		ctx.UserPW = *ctx.UserPWNew
@@ -951,7 +1137,17 @@ func handleEncryption(ctx *PDFContext) error {
 
 func writeXRef(ctx *PDFContext) error {
 
+	// Offsets beyond 10 digits can't be written into an xref table.
+	if ctx.Write.Offset > maxXRefTableOffset {
+		log.Info.Printf("writeXRef: writing xref stream for file size %d\n", ctx.Write.Offset)
+		return writeXRefStream(ctx)
+	}
+
 	if ctx.WriteXRefStream {
+		if ctx.WriteHybridXRef {
+			// Write cross reference table along with a cross reference stream for PDF 1.5 readers.
+			return writeHybridXRef(ctx)
+		}
 		// Write cross reference stream and generate objectstreams.
 		return writeXRefStream(ctx)
 	}
@@ -959,22 +1155,3 @@ func writeXRef(ctx *PDFContext) error {
 	// Write cross reference table section.
 	return writeXRefTable(ctx)
 }
-
-func setFileSizeOfWrittenFile(w *WriteContext, f *os.File) error {
-
-	// Get file info for file just written but flush first to get correct file size.
-
-	err := w.Flush()
-	if err != nil {
-		return err
-	}
-
-	fileInfo, err := f.Stat()
-	if err != nil {
-		return err
-	}
-
-	w.FileSize = fileInfo.Size()
-
-	return nil
-}
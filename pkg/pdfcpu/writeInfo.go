@@ -18,7 +18,6 @@ package pdfcpu
 
 import (
 	"strings"
-	"time"
 
 	"github.com/hhrutter/pdfcpu/pkg/log"
 	"github.com/pkg/errors"
@@ -112,7 +111,7 @@ func writeInfoDict(ctx *PDFContext, dict *PDFDict) (err error) {
 }
 
 // Write the document info object for this PDF file.
-// Add pdfcpu as Producer with proper creation date and mod date.
+// pdfcpu has been added as Producer with proper creation date and mod date by syncMetadata.
 func writeDocumentInfoDict(ctx *PDFContext) error {
 
 	// => 14.3.3 Document Information Dictionary
@@ -152,13 +151,7 @@ func writeDocumentInfoDict(ctx *PDFContext) error {
 		return err
 	}
 
-	// These are the modifications for the info dict of this PDF file:
-
-	dateStringLiteral := DateStringLiteral(time.Now())
-
-	dict.Update("CreationDate", dateStringLiteral)
-	dict.Update("ModDate", dateStringLiteral)
-	dict.Update("Producer", PDFStringLiteral(PDFCPULongVersion))
+	// Producer and dates have already been updated by syncMetadata.
 
 	_, _, err = writeDeepObject(ctx, obj)
 	if err != nil {
@@ -102,12 +102,19 @@ func stopObjectStream(ctx *PDFContext) error {
 	entry, _ := xRefTable.FindTableEntry(*ctx.Write.CurrentObjStream, 0)
 	objStreamDict, _ := (entry.Object).(PDFObjectStreamDict)
 
+	// Keep filling the current object stream when compressing all objects.
+	if ctx.Write.CompressObjects && objStreamDict.ObjCount < ObjectStreamMaxObjects {
+		ctx.Write.WriteToObjectStream = false
+		log.Debug.Println("stopObjectStream end (object stream kept open)")
+		return nil
+	}
+
 	// When we are ready to write: append prolog and content
 	objStreamDict.Finalize()
 
 	// Encode objStreamDict.Content -> objStreamDict.Raw
 	// and wipe (decoded) content to free up memory.
-	err := encodeStream(&objStreamDict.PDFStreamDict)
+	err := encodeStreamLevel(&objStreamDict.PDFStreamDict, ctx.CompressionLevel)
 	if err != nil {
 		return err
 	}
@@ -137,6 +144,18 @@ func stopObjectStream(ctx *PDFContext) error {
 	return nil
 }
 
+// flushObjectStream writes the object stream currently in use.
+func flushObjectStream(ctx *PDFContext) error {
+
+	if ctx.Write.CurrentObjStream == nil {
+		return nil
+	}
+
+	ctx.Write.WriteToObjectStream = true
+
+	return stopObjectStream(ctx)
+}
+
 func writeToObjectStream(ctx *PDFContext, objNumber, genNumber int) (ok bool, err error) {
 
 	log.Debug.Printf("addToObjectStream begin, obj#:%d gen#:%d\n", objNumber, genNumber)
@@ -145,7 +164,7 @@ func writeToObjectStream(ctx *PDFContext, objNumber, genNumber int) (ok bool, er
 
 	if ctx.WriteXRefStream && // object streams assume an xRefStream to be generated.
 		ctx.WriteObjectStream && // signal for compression into object stream is on.
-		ctx.Write.WriteToObjectStream && // currently writing to object stream.
+		(w.WriteToObjectStream || w.CompressObjects) && // currently writing to object stream.
 		genNumber == 0 {
 
 		if w.CurrentObjStream == nil {
@@ -182,11 +201,13 @@ func writeToObjectStream(ctx *PDFContext, objNumber, genNumber int) (ok bool, er
 		log.Debug.Printf("writePDFObject end, obj#%d written to objectStream #%d\n", objNumber, *ctx.Write.CurrentObjStream)
 
 		if objStreamDict.ObjCount == ObjectStreamMaxObjects {
-			err = stopObjectStream(ctx)
+			// Objects may also get here by CompressObjects.
+			writing := w.WriteToObjectStream
+			err = flushObjectStream(ctx)
 			if err != nil {
 				return false, err
 			}
-			w.WriteToObjectStream = true
+			w.WriteToObjectStream = writing
 		}
 
 		ok = true
@@ -463,19 +484,28 @@ func writePDFStreamDictObject(ctx *PDFContext, objNumber, genNumber int, streamD
 
 	var err error
 
-	// Unless the "Identity" crypt filter is used we have to encrypt.
-	isXRefStreamDict := streamDict.Type() != nil && *streamDict.Type() == "XRef"
-	if ctx.EncKey != nil &&
-		!isXRefStreamDict &&
-		!(len(streamDict.FilterPipeline) == 1 && streamDict.FilterPipeline[0].Name == "Crypt") {
+	// Crypt filters decide whether and how to encrypt.
+	if ctx.EncKey == nil {
+		removeCryptFilter(&streamDict)
+	} else {
 
-		streamDict.Raw, err = encryptStream(ctx.AES4Streams, streamDict.Raw, objNumber, genNumber, ctx.EncKey)
+		encrypted, aes, err := streamCrypt(ctx, &streamDict)
 		if err != nil {
 			return err
 		}
 
-		l := int64(len(streamDict.Raw))
-		streamDict.StreamLength = &l
+		if encrypted {
+			streamDict.Raw, err = encryptStream(aes, streamDict.Raw, objNumber, genNumber, ctx.EncKey)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Decryption on read and encryption both change the length of the raw stream.
+	l := int64(len(streamDict.Raw))
+	streamDict.StreamLength = &l
+	if i, err := ctx.DereferenceInteger(streamDict.Dict["Length"]); err != nil || i == nil || int64(*i) != l {
 		streamDict.Update("Length", PDFInteger(l))
 	}
 
@@ -628,6 +658,10 @@ func writeIndirectObject(ctx *PDFContext, indRef PDFIndirectRef) (PDFObject, err
 		return nil, nil
 	}
 
+	if err := ctx.canceled(); err != nil {
+		return nil, err
+	}
+
 	o, err := ctx.Dereference(indRef)
 	if err != nil {
 		return nil, errors.Wrapf(err, "writeIndirectObject: unable to dereference indirect object #%d", objNumber)
@@ -93,6 +93,9 @@ func writePageDict(ctx *PDFContext, indRef *PDFIndirectRef, pageDict *PDFDict) e
 		{"PresSteps", PagePresSteps},
 		{"UserUnit", PageUserUnit},
 		{"VP", PageVP},
+		{"AF", PageAF},
+		{"OutputIntents", PageOutputIntents},
+		{"DPart", PageDPart},
 	} {
 		err = writePageEntry(ctx, pageDict, dictName, e.entryName, e.statsAttr)
 		if err != nil {
@@ -61,6 +61,23 @@ func logWriteStats(ctx *PDFContext) {
 	l, str = ctx.Optimize.DuplicateImageObjectsString()
 	log.Stats.Printf("%d original redundant image entries: %s", l, str)
 
+	// Duplicate stream objects
+	l, str = ctx.Optimize.DuplicateStreamObjectsString()
+	log.Stats.Printf("%d original redundant stream entries: %s", l, str)
+
+	// Page tree
+	log.Stats.Printf("%d page counts fixed, page tree rebalanced: %t\n", ctx.Optimize.FixedPageCounts, ctx.Optimize.PageTreeRebalanced)
+
+	// Unused resources
+	log.Stats.Printf("%d unused page resources pruned\n", ctx.Optimize.PrunedResources)
+
+	// Unreachable objects
+	l, str = ctx.Optimize.UnreachableObjectsString()
+	log.Stats.Printf("%d original unreachable entries (%d bytes): %s", l, ctx.Optimize.UnreachableBytes, str)
+
+	// Recompressed streams
+	log.Stats.Printf("%d streams recompressed (%d bytes saved)\n", ctx.Optimize.RecompressedStreams, ctx.Optimize.RecompressedBytes)
+
 	// Duplicate info objects
 	l, str = ctx.Optimize.DuplicateInfoObjectsString()
 	log.Stats.Printf("%d original redundant info entries: %s", l, str)
@@ -85,11 +102,11 @@ func statsHeadLine() *string {
 	hl += "R_Version;R_Extensions;R_PageLabels;R_Names;R_Dests;R_ViewerPrefs;R_PageLayout;R_PageMode;"
 	hl += "R_Outlines;R_Threads;R_OpenAction;R_AA;R_URI;R_AcroForm;R_Metadata;R_StructTreeRoot;R_MarkInfo;"
 	hl += "R_Lang;R_SpiderInfo;R_OutputIntents;R_PieceInfo;R_OCProperties;R_Perms;R_Legal;R_Requirements;"
-	hl += "R_Collection;R_NeedsRendering;"
+	hl += "R_Collection;R_NeedsRendering;R_AF;R_DPartRoot;"
 	hl += "P_LastModified;P_Resources;P_MediaBox;P_CropBox;P_BleedBox;P_TrimBox;P_ArtBox;"
 	hl += "P_BoxColorInfo;P_Contents;P_Rotate;P_Group;P_Thumb;P_B;P_Dur;P_Trans;P_Annots;"
 	hl += "P_AA;P_Metadata;P_PieceInfo;P_StructParents;P_ID;P_PZ;P_SeparationInfo;P_Tabs;"
-	hl += "P_TemplateInstantiated;P_PresSteps;P_UserUnit;P_VP;\n"
+	hl += "P_TemplateInstantiated;P_PresSteps;P_UserUnit;P_VP;P_AF;P_OutputIntents;P_DPart;\n"
 
 	return &hl
 }
@@ -153,7 +170,7 @@ func statsLine(ctx *PDFContext) *string {
 		nonreferencedObjs = fmt.Sprintf("%d:%s", len(ctx.Optimize.NonReferencedObjs), strings.Join(s, ","))
 	}
 
-	line := fmt.Sprintf("%s;%s;%s;%s;%s;%s;%s;%s;%s;%v;%v;%v;%v;%d;%d;%s;%s;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v\n",
+	line := fmt.Sprintf("%s;%s;%s;%s;%s;%s;%s;%s;%s;%v;%v;%v;%v;%d;%d;%s;%s;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v\n",
 		filepath.Base(ctx.Read.FileName),
 		version,
 		xRefTable.Author,
@@ -198,6 +215,8 @@ func statsLine(ctx *PDFContext) *string {
 		xRefTable.Stats.UsesRootAttr(RootRequirements),
 		xRefTable.Stats.UsesRootAttr(RootCollection),
 		xRefTable.Stats.UsesRootAttr(RootNeedsRendering),
+		xRefTable.Stats.UsesRootAttr(RootAF),
+		xRefTable.Stats.UsesRootAttr(RootDPartRoot),
 		xRefTable.Stats.UsesPageAttr(PageLastModified),
 		xRefTable.Stats.UsesPageAttr(PageResources),
 		xRefTable.Stats.UsesPageAttr(PageMediaBox),
@@ -225,7 +244,10 @@ func statsLine(ctx *PDFContext) *string {
 		xRefTable.Stats.UsesPageAttr(PageTemplateInstantiated),
 		xRefTable.Stats.UsesPageAttr(PagePresSteps),
 		xRefTable.Stats.UsesPageAttr(PageUserUnit),
-		xRefTable.Stats.UsesPageAttr(PageVP))
+		xRefTable.Stats.UsesPageAttr(PageVP),
+		xRefTable.Stats.UsesPageAttr(PageAF),
+		xRefTable.Stats.UsesPageAttr(PageOutputIntents),
+		xRefTable.Stats.UsesPageAttr(PageDPart))
 
 	return &line
 }
@@ -0,0 +1,774 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// This file implements the exchange of markup annotations using XFDF (see the Adobe XFDF specification).
+
+// xfdfAnnotSubtypes maps XFDF element names to the supported annotation subtypes.
+var xfdfAnnotSubtypes = map[string]string{
+	"text":      "Text",
+	"freetext":  "FreeText",
+	"square":    "Square",
+	"circle":    "Circle",
+	"highlight": "Highlight",
+	"underline": "Underline",
+	"strikeout": "StrikeOut",
+	"squiggly":  "Squiggly",
+	"stamp":     "Stamp",
+	"ink":       "Ink",
+}
+
+// xfdfAnnotFlags lists the XFDF names of the annotation flags in bit order (see 12.5.3 Table 165).
+var xfdfAnnotFlags = []string{"invisible", "hidden", "print", "nozoom", "norotate", "noview", "readonly", "locked", "togglenoview", "lockedcontents"}
+
+// xfdfAnnotsDoc represents the root element of an XFDF file containing annotations.
+type xfdfAnnotsDoc struct {
+	XMLName xml.Name   `xml:"xfdf"`
+	Xmlns   string     `xml:"xmlns,attr,omitempty"`
+	Annots  xfdfAnnots `xml:"annots"`
+	F       *xfdfFile  `xml:"f,omitempty"`
+}
+
+// xfdfAnnots holds annotations named after their subtype.
+type xfdfAnnots struct {
+	Annots []xfdfAnnot `xml:",any"`
+}
+
+type xfdfAnnot struct {
+	XMLName      xml.Name
+	Page         int          `xml:"page,attr"`
+	Rect         string       `xml:"rect,attr"`
+	Name         string       `xml:"name,attr,omitempty"`
+	Title        string       `xml:"title,attr,omitempty"`
+	Subject      string       `xml:"subject,attr,omitempty"`
+	Date         string       `xml:"date,attr,omitempty"`
+	CreationDate string       `xml:"creationdate,attr,omitempty"`
+	Color        string       `xml:"color,attr,omitempty"`
+	Opacity      string       `xml:"opacity,attr,omitempty"`
+	Flags        string       `xml:"flags,attr,omitempty"`
+	Icon         string       `xml:"icon,attr,omitempty"`
+	Coords       string       `xml:"coords,attr,omitempty"`
+	Width        string       `xml:"width,attr,omitempty"`
+	Contents     string       `xml:"contents,omitempty"`
+	DA           string       `xml:"defaultappearance,omitempty"`
+	InkList      *xfdfInkList `xml:"inklist,omitempty"`
+	Popup        *xfdfPopup   `xml:"popup,omitempty"`
+}
+
+type xfdfInkList struct {
+	Gestures []string `xml:"gesture"`
+}
+
+type xfdfPopup struct {
+	Page  int    `xml:"page,attr"`
+	Rect  string `xml:"rect,attr"`
+	Open  string `xml:"open,attr,omitempty"`
+	Flags string `xml:"flags,attr,omitempty"`
+}
+
+func xfdfNumbers(xRefTable *XRefTable, arr PDFArray) string {
+
+	ss := make([]string, len(arr))
+	for i, o := range arr {
+		ss[i] = strconv.FormatFloat(xRefTable.DereferenceNumber(o), 'f', -1, 64)
+	}
+
+	return strings.Join(ss, ",")
+}
+
+func parseXFDFNumbers(s string) (PDFArray, error) {
+
+	arr := PDFArray{}
+
+	for _, s1 := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s1), 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number: %s", s1)
+		}
+		arr = append(arr, PDFFloat(f))
+	}
+
+	return arr, nil
+}
+
+func xfdfFlags(f int) string {
+
+	var ss []string
+	for i, s := range xfdfAnnotFlags {
+		if f&(1<<uint(i)) > 0 {
+			ss = append(ss, s)
+		}
+	}
+
+	return strings.Join(ss, ",")
+}
+
+func parseXFDFFlags(s string) (int, error) {
+
+	var f int
+
+	for _, s1 := range strings.Split(s, ",") {
+		s1 = strings.TrimSpace(s1)
+		if len(s1) == 0 {
+			continue
+		}
+		i := 0
+		for ; i < len(xfdfAnnotFlags); i++ {
+			if xfdfAnnotFlags[i] == s1 {
+				break
+			}
+		}
+		if i == len(xfdfAnnotFlags) {
+			return 0, errors.Errorf("invalid annotation flag: %s", s1)
+		}
+		f |= 1 << uint(i)
+	}
+
+	return f, nil
+}
+
+// xfdfColor returns the XFDF representation #rrggbb of a color array.
+func xfdfColor(xRefTable *XRefTable, arr PDFArray) string {
+
+	var r, g, b float64
+
+	switch len(arr) {
+
+	case 1:
+		r = xRefTable.DereferenceNumber(arr[0])
+		g, b = r, r
+
+	case 3:
+		r = xRefTable.DereferenceNumber(arr[0])
+		g = xRefTable.DereferenceNumber(arr[1])
+		b = xRefTable.DereferenceNumber(arr[2])
+
+	case 4:
+		k := xRefTable.DereferenceNumber(arr[3])
+		r = (1 - xRefTable.DereferenceNumber(arr[0])) * (1 - k)
+		g = (1 - xRefTable.DereferenceNumber(arr[1])) * (1 - k)
+		b = (1 - xRefTable.DereferenceNumber(arr[2])) * (1 - k)
+
+	default:
+		return ""
+	}
+
+	c := func(f float64) int { return int(math.Round(math.Max(0, math.Min(1, f)) * 255)) }
+
+	return fmt.Sprintf("#%02X%02X%02X", c(r), c(g), c(b))
+}
+
+func parseXFDFColor(s string) (PDFArray, error) {
+
+	if len(s) != 7 || s[0] != '#' {
+		return nil, errors.Errorf("invalid color: %s", s)
+	}
+
+	i, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return nil, errors.Errorf("invalid color: %s", s)
+	}
+
+	r := float64(i>>16&0xFF) / 255
+	g := float64(i>>8&0xFF) / 255
+	b := float64(i&0xFF) / 255
+
+	return PDFArray{PDFFloat(r), PDFFloat(g), PDFFloat(b)}, nil
+}
+
+// xfdfAnnotFor returns the XFDF representation of an annotation.
+func xfdfAnnotFor(xRefTable *XRefTable, d *PDFDict, elem string, page int) (*xfdfAnnot, error) {
+
+	a := &xfdfAnnot{XMLName: xml.Name{Local: elem}, Page: page - 1}
+
+	if obj, found := d.Find("Rect"); found {
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+		if arr != nil {
+			a.Rect = xfdfNumbers(xRefTable, *arr)
+		}
+	}
+
+	for k, s := range map[string]*string{
+		"NM":           &a.Name,
+		"T":            &a.Title,
+		"Subj":         &a.Subject,
+		"M":            &a.Date,
+		"CreationDate": &a.CreationDate,
+		"Name":         &a.Icon,
+		"Contents":     &a.Contents,
+		"DA":           &a.DA,
+	} {
+		if o, found := d.Find(k); found {
+			v, err := fieldValueString(xRefTable, o)
+			if err != nil {
+				return nil, err
+			}
+			*s = v
+		}
+	}
+
+	if obj, found := d.Find("C"); found {
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+		if arr != nil {
+			a.Color = xfdfColor(xRefTable, *arr)
+		}
+	}
+
+	if obj, found := d.Find("CA"); found {
+		a.Opacity = strconv.FormatFloat(xRefTable.DereferenceNumber(obj), 'f', -1, 64)
+	}
+
+	if f := d.IntEntry("F"); f != nil {
+		a.Flags = xfdfFlags(*f)
+	}
+
+	if obj, found := d.Find("QuadPoints"); found {
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+		if arr != nil {
+			a.Coords = xfdfNumbers(xRefTable, *arr)
+		}
+	}
+
+	if obj, found := d.Find("BS"); found {
+		bs, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+		if bs != nil {
+			if w, found := bs.Find("W"); found {
+				a.Width = strconv.FormatFloat(xRefTable.DereferenceNumber(w), 'f', -1, 64)
+			}
+		}
+	}
+
+	if obj, found := d.Find("InkList"); found {
+		arr, err := xRefTable.DereferenceArray(obj)
+		if err != nil {
+			return nil, err
+		}
+		if arr != nil {
+			a.InkList = &xfdfInkList{}
+			for _, o := range *arr {
+				path, err := xRefTable.DereferenceArray(o)
+				if err != nil {
+					return nil, err
+				}
+				if path == nil {
+					continue
+				}
+				var pp []string
+				for i := 0; i+1 < len(*path); i += 2 {
+					pp = append(pp, xfdfNumbers(xRefTable, (*path)[i:i+2]))
+				}
+				a.InkList.Gestures = append(a.InkList.Gestures, strings.Join(pp, ";"))
+			}
+		}
+	}
+
+	if indRef := d.IndirectRefEntry("Popup"); indRef != nil {
+		pd, err := xRefTable.DereferenceDict(*indRef)
+		if err != nil {
+			return nil, err
+		}
+		if pd != nil {
+			p := &xfdfPopup{Page: page - 1}
+			if r, err := widgetRect(xRefTable, pd); err == nil && r != nil {
+				p.Rect = xfdfNumbers(xRefTable, NewNumberArray(r...))
+			}
+			if b := pd.BooleanEntry("Open"); b != nil && *b {
+				p.Open = "yes"
+			}
+			if f := pd.IntEntry("F"); f != nil {
+				p.Flags = xfdfFlags(*f)
+			}
+			a.Popup = p
+		}
+	}
+
+	return a, nil
+}
+
+// ExportXFDFAnnotations returns the markup annotations of a PDF as XFDF file.
+// Supported are text, free text, square, circle, text markup, stamp and ink annotations along with their popups.
+// fileName is the name of the PDF file the annotations belong to and may be empty.
+func ExportXFDFAnnotations(xRefTable *XRefTable, fileName string) ([]byte, error) {
+
+	log.Debug.Println("ExportXFDFAnnotations begin")
+
+	elems := map[string]string{}
+	for k, v := range xfdfAnnotSubtypes {
+		elems[v] = k
+	}
+
+	x := xfdfAnnotsDoc{Xmlns: xfdfNamespace}
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		arr, err := pageAnnotations(xRefTable, pageDict)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range arr {
+
+			d, err := xRefTable.DereferenceDict(v)
+			if err != nil {
+				return nil, err
+			}
+
+			if d == nil {
+				continue
+			}
+
+			st := d.NameEntry("Subtype")
+			if st == nil {
+				continue
+			}
+
+			elem, ok := elems[*st]
+			if !ok {
+				continue
+			}
+
+			a, err := xfdfAnnotFor(xRefTable, d, elem, i)
+			if err != nil {
+				return nil, err
+			}
+
+			x.Annots.Annots = append(x.Annots.Annots, *a)
+		}
+	}
+
+	if len(fileName) > 0 {
+		x.F = &xfdfFile{Href: fileName}
+	}
+
+	bb, err := xml.MarshalIndent(x, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("ExportXFDFAnnotations end")
+
+	return append([]byte(xml.Header), append(bb, '\n')...), nil
+}
+
+// quadBoxes returns the bounding boxes of the quadrilaterals given by quadPoints.
+func quadBoxes(quadPoints PDFArray) [][4]float64 {
+
+	var bb [][4]float64
+
+	for i := 0; i+8 <= len(quadPoints); i += 8 {
+		b := [4]float64{math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64}
+		for j := 0; j < 8; j += 2 {
+			x, _ := quadPoints[i+j].(PDFFloat)
+			y, _ := quadPoints[i+j+1].(PDFFloat)
+			b[0], b[1] = math.Min(b[0], float64(x)), math.Min(b[1], float64(y))
+			b[2], b[3] = math.Max(b[2], float64(x)), math.Max(b[3], float64(y))
+		}
+		bb = append(bb, b)
+	}
+
+	return bb
+}
+
+// markupAppearanceContent returns the page space content drawing a text markup or ink annotation.
+func markupAppearanceContent(subtype string, quadPoints PDFArray, inkList []PDFArray, width float64) string {
+
+	var b bytes.Buffer
+
+	switch subtype {
+
+	case "Highlight":
+		b.WriteString("/GS0 gs ")
+		for _, q := range quadBoxes(quadPoints) {
+			fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f re ", q[0], q[1], q[2]-q[0], q[3]-q[1])
+		}
+		b.WriteString("f")
+
+	case "Underline", "StrikeOut":
+		for _, q := range quadBoxes(quadPoints) {
+			h := q[3] - q[1]
+			y := q[1] + h/14
+			if subtype == "StrikeOut" {
+				y = q[1] + h/2
+			}
+			fmt.Fprintf(&b, "%.2f w %.2f %.2f m %.2f %.2f l S ", h/14, q[0], y, q[2], y)
+		}
+
+	case "Squiggly":
+		for _, q := range quadBoxes(quadPoints) {
+			h := q[3] - q[1]
+			step := h / 6
+			fmt.Fprintf(&b, "%.2f w %.2f %.2f m ", h/20, q[0], q[1])
+			for i, x := 1, q[0]+step; x <= q[2]; i, x = i+1, x+step {
+				fmt.Fprintf(&b, "%.2f %.2f l ", x, q[1]+float64(i%2)*step)
+			}
+			b.WriteString("S ")
+		}
+
+	case "Ink":
+		fmt.Fprintf(&b, "%.2f w 1 J 1 j ", width)
+		for _, path := range inkList {
+			for i := 0; i+1 < len(path); i += 2 {
+				x, _ := path[i].(PDFFloat)
+				y, _ := path[i+1].(PDFFloat)
+				op := "l"
+				if i == 0 {
+					op = "m"
+				}
+				fmt.Fprintf(&b, "%.2f %.2f %s ", x, y, op)
+			}
+			b.WriteString("S ")
+		}
+	}
+
+	return b.String()
+}
+
+// markupAppearance creates the normal appearance of a text markup or ink annotation.
+func markupAppearance(xRefTable *XRefTable, d *PDFDict, subtype string, r PDFArray, quadPoints PDFArray, inkList []PDFArray, width float64) error {
+
+	content := markupAppearanceContent(subtype, quadPoints, inkList, width)
+	if len(content) == 0 {
+		return nil
+	}
+
+	col := "0 0 0"
+	if c, found := d.Find("C"); found {
+		if arr, ok := c.(PDFArray); ok && len(arr) == 3 {
+			col = xfdfNumbers(xRefTable, arr)
+			col = strings.Replace(col, ",", " ", -1)
+		}
+	}
+
+	var res *PDFDict
+	if subtype == "Highlight" {
+		gs := PDFDict{Dict: map[string]PDFObject{"Type": PDFName("ExtGState"), "BM": PDFName("Multiply")}}
+		res = &PDFDict{Dict: map[string]PDFObject{"ExtGState": PDFDict{Dict: map[string]PDFObject{"GS0": gs}}}}
+	}
+
+	rect := rect(xRefTable, r)
+
+	s := fmt.Sprintf("q 1 0 0 1 %.2f %.2f cm %s rg %s RG %s Q", -rect.LL.X, -rect.LL.Y, col, col, content)
+
+	indRef, err := appearanceStream(xRefTable, rect.Width(), rect.Height(), s, res)
+	if err != nil {
+		return err
+	}
+
+	d.Insert("AP", PDFDict{Dict: map[string]PDFObject{"N": *indRef}})
+
+	return nil
+}
+
+// xfdfAnnotDict creates the annotation dict for an XFDF annotation.
+func xfdfAnnotDict(xRefTable *XRefTable, a xfdfAnnot, subtype string) (*PDFDict, error) {
+
+	r, err := parseXFDFNumbers(a.Rect)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r) != 4 {
+		return nil, errors.Errorf("invalid rect: %s", a.Rect)
+	}
+
+	d := NewPDFDict()
+	d.Insert("Type", PDFName("Annot"))
+	d.Insert("Subtype", PDFName(subtype))
+	d.Insert("Rect", r)
+
+	for k, s := range map[string]string{
+		"NM":       a.Name,
+		"T":        a.Title,
+		"Subj":     a.Subject,
+		"Contents": a.Contents,
+		"DA":       a.DA,
+	} {
+		if len(s) > 0 {
+			d.Insert(k, encodeText(s))
+		}
+	}
+
+	for k, s := range map[string]string{"M": a.Date, "CreationDate": a.CreationDate} {
+		if len(s) > 0 {
+			d.Insert(k, PDFStringLiteral(s))
+		}
+	}
+
+	if len(a.Icon) > 0 {
+		d.Insert("Name", PDFName(a.Icon))
+	}
+
+	if len(a.Color) > 0 {
+		c, err := parseXFDFColor(a.Color)
+		if err != nil {
+			return nil, err
+		}
+		d.Insert("C", c)
+	}
+
+	if len(a.Opacity) > 0 {
+		f, err := strconv.ParseFloat(a.Opacity, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid opacity: %s", a.Opacity)
+		}
+		d.Insert("CA", PDFFloat(f))
+	}
+
+	if len(a.Flags) > 0 {
+		f, err := parseXFDFFlags(a.Flags)
+		if err != nil {
+			return nil, err
+		}
+		d.InsertInt("F", f)
+	}
+
+	var quadPoints PDFArray
+	if len(a.Coords) > 0 {
+		if quadPoints, err = parseXFDFNumbers(a.Coords); err != nil {
+			return nil, err
+		}
+		d.Insert("QuadPoints", quadPoints)
+	}
+
+	width := 1.0
+	if len(a.Width) > 0 {
+		if width, err = strconv.ParseFloat(a.Width, 64); err != nil {
+			return nil, errors.Errorf("invalid width: %s", a.Width)
+		}
+		d.Insert("BS", PDFDict{Dict: map[string]PDFObject{"W": PDFFloat(width)}})
+	}
+
+	var inkList []PDFArray
+	if a.InkList != nil && len(a.InkList.Gestures) > 0 {
+		arr := PDFArray{}
+		for _, g := range a.InkList.Gestures {
+			path, err := parseXFDFNumbers(g)
+			if err != nil {
+				return nil, err
+			}
+			inkList = append(inkList, path)
+			arr = append(arr, path)
+		}
+		d.Insert("InkList", arr)
+	}
+
+	switch subtype {
+
+	case "Highlight", "Underline", "StrikeOut", "Squiggly":
+		if quadPoints == nil {
+			return nil, errors.Errorf("%s annotation without coords", subtype)
+		}
+		err = markupAppearance(xRefTable, &d, subtype, r, quadPoints, nil, width)
+
+	case "Ink":
+		if inkList == nil {
+			return nil, errors.New("Ink annotation without inklist")
+		}
+		err = markupAppearance(xRefTable, &d, subtype, r, nil, inkList, width)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// xfdfPopupDict creates the popup annotation dict for an XFDF popup.
+func xfdfPopupDict(p *xfdfPopup, parent PDFIndirectRef) (*PDFDict, error) {
+
+	r, err := parseXFDFNumbers(p.Rect)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r) != 4 {
+		return nil, errors.Errorf("invalid popup rect: %s", p.Rect)
+	}
+
+	d := NewPDFDict()
+	d.Insert("Type", PDFName("Annot"))
+	d.Insert("Subtype", PDFName("Popup"))
+	d.Insert("Rect", r)
+	d.Insert("Parent", parent)
+	d.Insert("Open", PDFBoolean(p.Open == "yes" || p.Open == "true"))
+
+	if len(p.Flags) > 0 {
+		f, err := parseXFDFFlags(p.Flags)
+		if err != nil {
+			return nil, err
+		}
+		d.InsertInt("F", f)
+	}
+
+	return &d, nil
+}
+
+// parseXFDFAnnotations parses the annotations of an XFDF file.
+func parseXFDFAnnotations(bb []byte) ([]xfdfAnnot, error) {
+
+	var x xfdfAnnotsDoc
+
+	err := xml.Unmarshal(bb, &x)
+	if err != nil {
+		return nil, err
+	}
+
+	return x.Annots.Annots, nil
+}
+
+// ImportXFDFAnnotations adds the markup annotations of an XFDF file to the pages of a PDF
+// and returns the number of imported annotations.
+// An existing annotation with the same name (NM) on the same page is replaced.
+func ImportXFDFAnnotations(xRefTable *XRefTable, bb []byte) (int, error) {
+
+	log.Debug.Println("ImportXFDFAnnotations begin")
+
+	aa, err := parseXFDFAnnotations(bb)
+	if err != nil {
+		return 0, errors.Wrap(err, "ImportXFDFAnnotations")
+	}
+
+	// Group annotations by page.
+	pages := map[int][]xfdfAnnot{}
+	for _, a := range aa {
+		if _, ok := xfdfAnnotSubtypes[a.XMLName.Local]; !ok {
+			return 0, errors.Errorf("ImportXFDFAnnotations: unsupported annotation: %s", a.XMLName.Local)
+		}
+		if a.Page < 0 || a.Page >= xRefTable.PageCount {
+			return 0, errors.Errorf("ImportXFDFAnnotations: invalid page: %d", a.Page)
+		}
+		pages[a.Page+1] = append(pages[a.Page+1], a)
+	}
+
+	var count int
+
+	for i := 1; i <= xRefTable.PageCount; i++ {
+
+		aa := pages[i]
+		if len(aa) == 0 {
+			continue
+		}
+
+		pageDict, _, err := xRefTable.PageDict(i)
+		if err != nil {
+			return 0, err
+		}
+
+		// Remove annotations replaced by name.
+		names := StringSet{}
+		for _, a := range aa {
+			if len(a.Name) > 0 {
+				names[a.Name] = true
+			}
+		}
+
+		_, popups, err := processPageAnnotations(xRefTable, i, pageDict, func(page int, pageDict, d *PDFDict, subtype string, objNr int) (bool, error) {
+			o, found := d.Find("NM")
+			if !found {
+				return false, nil
+			}
+			s, err := fieldValueString(xRefTable, o)
+			return names[s], err
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if len(popups) > 0 {
+			if _, err = removePopups(xRefTable, pageDict, popups); err != nil {
+				return 0, err
+			}
+		}
+
+		for _, a := range aa {
+
+			d, err := xfdfAnnotDict(xRefTable, a, xfdfAnnotSubtypes[a.XMLName.Local])
+			if err != nil {
+				return 0, errors.Wrap(err, "ImportXFDFAnnotations")
+			}
+
+			indRef, err := xRefTable.IndRefForNewObject(*d)
+			if err != nil {
+				return 0, err
+			}
+
+			if err = appendToArrayEntry(xRefTable, pageDict, "Annots", *indRef); err != nil {
+				return 0, err
+			}
+
+			count++
+
+			if a.Popup == nil {
+				continue
+			}
+
+			pd, err := xfdfPopupDict(a.Popup, *indRef)
+			if err != nil {
+				return 0, errors.Wrap(err, "ImportXFDFAnnotations")
+			}
+
+			pIndRef, err := xRefTable.IndRefForNewObject(*pd)
+			if err != nil {
+				return 0, err
+			}
+
+			d.Insert("Popup", *pIndRef)
+
+			if err = appendToArrayEntry(xRefTable, pageDict, "Annots", *pIndRef); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	log.Debug.Println("ImportXFDFAnnotations end")
+
+	return count, nil
+}
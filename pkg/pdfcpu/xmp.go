@@ -0,0 +1,772 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// xmpNamespaces maps the well known prefixes of XMP property names to their namespace URIs.
+var xmpNamespaces = map[string]string{
+	"dc":        "http://purl.org/dc/elements/1.1/",
+	"xmp":       "http://ns.adobe.com/xap/1.0/",
+	"xmpMM":     "http://ns.adobe.com/xap/1.0/mm/",
+	"xmpRights": "http://ns.adobe.com/xap/1.0/rights/",
+	"pdf":       "http://ns.adobe.com/pdf/1.3/",
+	"pdfx":      "http://ns.adobe.com/pdfx/1.3/",
+	"pdfaid":    "http://www.aiim.org/pdfa/ns/id/",
+	"pdfxid":    "http://www.npes.org/pdfx/ns/id/",
+	"pdfuaid":   "http://www.aiim.org/pdfua/ns/id/",
+	"photoshop": "http://ns.adobe.com/photoshop/1.0/",
+}
+
+// xmpArrays maps array valued Dublin Core properties to their array type.
+var xmpArrays = map[string]string{
+	"dc:contributor": "Bag",
+	"dc:creator":     "Seq",
+	"dc:date":        "Seq",
+	"dc:description": "Alt",
+	"dc:language":    "Bag",
+	"dc:publisher":   "Bag",
+	"dc:rights":      "Alt",
+	"dc:subject":     "Bag",
+	"dc:title":       "Alt",
+	"dc:type":        "Bag",
+}
+
+// xmpInfo maps document info dict entries to the equivalent XMP properties, see ISO 19005-1 6.7.3.
+var xmpInfo = []struct {
+	key, prop string
+}{
+	{"Title", "dc:title"},
+	{"Author", "dc:creator"},
+	{"Subject", "dc:description"},
+	{"Keywords", "pdf:Keywords"},
+	{"Creator", "xmp:CreatorTool"},
+	{"Producer", "pdf:Producer"},
+	{"CreationDate", "xmp:CreateDate"},
+	{"ModDate", "xmp:ModifyDate"},
+	{"Trapped", "pdf:Trapped"},
+}
+
+// xmlNode is an element of an XML document keeping its namespace prefix.
+type xmlNode struct {
+	name xml.Name // Space holds the prefix
+	attr []xml.Attr
+	text string
+	kids []*xmlNode
+}
+
+func (n *xmlNode) qname(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func (n *xmlNode) attrValue(space, local string) (string, bool) {
+	for _, a := range n.attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *xmlNode) write(w *bytes.Buffer, indent string) {
+
+	w.WriteString(indent + "<" + n.qname(n.name))
+	for _, a := range n.attr {
+		w.WriteString(" " + n.qname(a.Name) + `="` + xmpEscape(a.Value) + `"`)
+	}
+
+	if len(n.kids) == 0 {
+		if n.text == "" {
+			w.WriteString("/>\n")
+			return
+		}
+		w.WriteString(">" + xmpEscape(n.text) + "</" + n.qname(n.name) + ">\n")
+		return
+	}
+
+	w.WriteString(">\n")
+	for _, k := range n.kids {
+		k.write(w, indent+" ")
+	}
+	w.WriteString(indent + "</" + n.qname(n.name) + ">\n")
+}
+
+// XMP represents an XMP metadata packet, see ISO 16684-1.
+// Properties are addressed by prefixed names like "dc:title" or "pdf:Producer" using the well known prefixes
+// dc, xmp, xmpMM, xmpRights, pdf, pdfaid, pdfxid, pdfuaid and photoshop or any prefix declared by the packet.
+type XMP struct {
+	root *xmlNode
+	ns   map[string]string // namespace URI by prefix
+}
+
+// NewXMP returns an empty XMP packet.
+func NewXMP() *XMP {
+
+	rdf := &xmlNode{
+		name: xml.Name{Space: "rdf", Local: "RDF"},
+		attr: []xml.Attr{{Name: xml.Name{Space: "xmlns", Local: "rdf"}, Value: nsRDF}},
+	}
+
+	root := &xmlNode{
+		name: xml.Name{Space: "x", Local: "xmpmeta"},
+		attr: []xml.Attr{{Name: xml.Name{Space: "xmlns", Local: "x"}, Value: "adobe:ns:meta/"}},
+		kids: []*xmlNode{rdf},
+	}
+
+	return &XMP{root: root, ns: map[string]string{"x": "adobe:ns:meta/", "rdf": nsRDF}}
+}
+
+// ParseXMP parses an XMP metadata packet.
+func ParseXMP(b []byte) (*XMP, error) {
+
+	x := &XMP{ns: map[string]string{}}
+
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))))
+
+	var stack []*xmlNode
+
+	for {
+
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseXMP")
+		}
+
+		switch t := t.(type) {
+
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name, attr: append([]xml.Attr{}, t.Attr...)}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					x.ns[a.Name.Local] = a.Value
+				}
+			}
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.kids = append(p.kids, n)
+			} else if x.root == nil {
+				x.root = n
+			}
+			stack = append(stack, n)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, errors.New("ParseXMP: unbalanced end element")
+			}
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+
+		}
+	}
+
+	if x.rdf() == nil {
+		return nil, errors.New("ParseXMP: missing rdf:RDF")
+	}
+
+	return x, nil
+}
+
+// prefixes returns all prefixes bound to uri.
+func (x *XMP) prefixes(uri string) []string {
+	var pp []string
+	for p, u := range x.ns {
+		if u == uri {
+			pp = append(pp, p)
+		}
+	}
+	return pp
+}
+
+func (x *XMP) isRDF(name xml.Name, local string) bool {
+	return name.Local == local && x.ns[name.Space] == nsRDF
+}
+
+func (x *XMP) rdf() *xmlNode {
+
+	var find func(n *xmlNode) *xmlNode
+	find = func(n *xmlNode) *xmlNode {
+		if n == nil || x.isRDF(n.name, "RDF") {
+			return n
+		}
+		for _, k := range n.kids {
+			if r := find(k); r != nil {
+				return r
+			}
+		}
+		return nil
+	}
+
+	return find(x.root)
+}
+
+func (x *XMP) descriptions() []*xmlNode {
+	var nn []*xmlNode
+	for _, n := range x.rdf().kids {
+		if x.isRDF(n.name, "Description") {
+			nn = append(nn, n)
+		}
+	}
+	return nn
+}
+
+// resolve returns the namespace URI and local name of the property name.
+func (x *XMP) resolve(name string) (string, string, error) {
+
+	i := strings.Index(name, ":")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", errors.Errorf("XMP: invalid property name %q", name)
+	}
+
+	prefix, local := name[:i], name[i+1:]
+
+	if uri, found := xmpNamespaces[prefix]; found {
+		return uri, local, nil
+	}
+
+	if uri, found := x.ns[prefix]; found {
+		return uri, local, nil
+	}
+
+	return "", "", errors.Errorf("XMP: unknown prefix of property %q", name)
+}
+
+// find returns the description holding the property uri:local along with the property as attribute index or element.
+func (x *XMP) find(uri, local string) (*xmlNode, int, *xmlNode) {
+
+	for _, p := range x.prefixes(uri) {
+		for _, d := range x.descriptions() {
+			for i, a := range d.attr {
+				if a.Name.Space == p && a.Name.Local == local {
+					return d, i, nil
+				}
+			}
+			for _, k := range d.kids {
+				if k.name.Space == p && k.name.Local == local {
+					return d, -1, k
+				}
+			}
+		}
+	}
+
+	return nil, -1, nil
+}
+
+// values returns the values of a simple or array valued property element
+// or nil for structured properties.
+func (x *XMP) values(n *xmlNode) []string {
+
+	if len(n.kids) == 0 {
+		if v, found := n.attrValue("rdf", "resource"); found {
+			return []string{v}
+		}
+		return []string{strings.TrimSpace(n.text)}
+	}
+
+	for _, arr := range n.kids {
+
+		alt := x.isRDF(arr.name, "Alt")
+		if !alt && !x.isRDF(arr.name, "Seq") && !x.isRDF(arr.name, "Bag") {
+			continue
+		}
+
+		var ss []string
+		for _, li := range arr.kids {
+			if !x.isRDF(li.name, "li") {
+				continue
+			}
+			if len(li.kids) > 0 {
+				// Array of structures
+				return nil
+			}
+			s := strings.TrimSpace(li.text)
+			if lang, _ := li.attrValue("xml", "lang"); alt && lang == "x-default" {
+				return []string{s}
+			}
+			ss = append(ss, s)
+		}
+
+		if alt && len(ss) > 1 {
+			ss = ss[:1]
+		}
+
+		return ss
+	}
+
+	return nil
+}
+
+// Values returns the values of the property name.
+func (x *XMP) Values(name string) []string {
+
+	uri, local, err := x.resolve(name)
+	if err != nil {
+		return nil
+	}
+
+	d, i, n := x.find(uri, local)
+	if d == nil {
+		return nil
+	}
+
+	if n == nil {
+		return []string{d.attr[i].Value}
+	}
+
+	return x.values(n)
+}
+
+// Property returns the value of the property name, array values joined by commas.
+func (x *XMP) Property(name string) string {
+	return strings.Join(x.Values(name), ", ")
+}
+
+// Properties returns the values of all simple and array valued properties by property name.
+func (x *XMP) Properties() map[string]string {
+
+	m := map[string]string{}
+
+	name := func(n xml.Name) string {
+		uri := x.ns[n.Space]
+		for p, u := range xmpNamespaces {
+			if u == uri {
+				return p + ":" + n.Local
+			}
+		}
+		return n.Space + ":" + n.Local
+	}
+
+	for _, d := range x.descriptions() {
+		for _, a := range d.attr {
+			if a.Name.Space != "" && a.Name.Space != "xmlns" && a.Name.Space != "xml" && x.ns[a.Name.Space] != nsRDF {
+				m[name(a.Name)] = a.Value
+			}
+		}
+		for _, k := range d.kids {
+			if vv := x.values(k); vv != nil {
+				m[name(k.name)] = strings.Join(vv, ", ")
+			}
+		}
+	}
+
+	return m
+}
+
+// prefix returns the prefix to be used for uri, declaring it on d if necessary.
+func (x *XMP) prefix(d *xmlNode, uri, name string) string {
+
+	if pp := x.prefixes(uri); len(pp) > 0 {
+		sort.Strings(pp)
+		return pp[0]
+	}
+
+	p := name[:strings.Index(name, ":")]
+	d.attr = append(d.attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: p}, Value: uri})
+	x.ns[p] = uri
+
+	return p
+}
+
+func (x *XMP) rdfName(local string) xml.Name {
+	p := "rdf"
+	if pp := x.prefixes(nsRDF); len(pp) > 0 {
+		p = pp[0]
+	}
+	return xml.Name{Space: p, Local: local}
+}
+
+// SetProperty sets the values of the property name.
+// Alternative language arrays like dc:title take a single value for the default language.
+func (x *XMP) SetProperty(name string, values ...string) error {
+
+	uri, local, err := x.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	canonical := name
+	for p, u := range xmpNamespaces {
+		if u == uri {
+			canonical = p + ":" + local
+		}
+	}
+
+	d, i, n := x.find(uri, local)
+
+	if d != nil && n == nil {
+		if len(values) == 1 {
+			d.attr[i].Value = values[0]
+			return nil
+		}
+		// Turn the attribute into an element.
+		d.attr = append(d.attr[:i], d.attr[i+1:]...)
+		d = nil
+	}
+
+	if d == nil {
+		dd := x.descriptions()
+		if len(dd) == 0 {
+			r := x.rdf()
+			d = &xmlNode{name: x.rdfName("Description"), attr: []xml.Attr{{Name: x.rdfName("about"), Value: ""}}}
+			r.kids = append(r.kids, d)
+		} else {
+			d = dd[0]
+		}
+		n = &xmlNode{name: xml.Name{Space: x.prefix(d, uri, canonical), Local: local}}
+		d.kids = append(d.kids, n)
+	}
+
+	typ := xmpArrays[canonical]
+	for _, k := range n.kids {
+		for _, t := range []string{"Alt", "Seq", "Bag"} {
+			if x.isRDF(k.name, t) {
+				typ = t
+			}
+		}
+	}
+
+	n.text, n.kids = "", nil
+
+	if typ == "" {
+		n.text = strings.Join(values, ", ")
+		return nil
+	}
+
+	arr := &xmlNode{name: x.rdfName(typ)}
+	for _, v := range values {
+		li := &xmlNode{name: x.rdfName("li"), text: v}
+		if typ == "Alt" {
+			li.attr = []xml.Attr{{Name: xml.Name{Space: "xml", Local: "lang"}, Value: "x-default"}}
+			arr.kids = append(arr.kids, li)
+			break
+		}
+		arr.kids = append(arr.kids, li)
+	}
+	n.kids = []*xmlNode{arr}
+
+	return nil
+}
+
+// RemoveProperty removes the property name.
+func (x *XMP) RemoveProperty(name string) error {
+
+	uri, local, err := x.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	d, i, n := x.find(uri, local)
+	if d == nil {
+		return nil
+	}
+
+	if n == nil {
+		d.attr = append(d.attr[:i], d.attr[i+1:]...)
+		return nil
+	}
+
+	for j, k := range d.kids {
+		if k == n {
+			d.kids = append(d.kids[:j], d.kids[j+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// Bytes returns the serialized XMP packet.
+func (x *XMP) Bytes() []byte {
+
+	var buf bytes.Buffer
+
+	buf.WriteString(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>` + "\n")
+	x.root.write(&buf, "")
+	buf.WriteString(`<?xpacket end="w"?>`)
+
+	return buf.Bytes()
+}
+
+// parseXMPDate parses a date as used by XMP, see ISO 16684-1 8.2.1.1.
+func parseXMPDate(s string) (time.Time, bool) {
+
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// ReadXMP returns the XMP metadata of the document catalog or nil if there is none.
+func ReadXMP(xRefTable *XRefTable) (*XMP, error) {
+
+	sd, err := xRefTable.DereferenceStreamDict(xRefTable.RootDict.Dict["Metadata"])
+	if err != nil || sd == nil {
+		return nil, err
+	}
+
+	s := *sd
+	if err = xRefTable.decodeStream(&s); err != nil {
+		return nil, err
+	}
+
+	return ParseXMP(s.Content)
+}
+
+// WriteXMP replaces the XMP metadata of the document catalog by x.
+// The metadata stream is left unfiltered as required by PDF/A.
+func WriteXMP(xRefTable *XRefTable, x *XMP) error {
+
+	sd := &PDFStreamDict{PDFDict: NewPDFDict()}
+	sd.InsertName("Type", "Metadata")
+	sd.InsertName("Subtype", "XML")
+	sd.Content = x.Bytes()
+
+	if err := encodeStream(sd); err != nil {
+		return err
+	}
+
+	if indRef := xRefTable.RootDict.IndirectRefEntry("Metadata"); indRef != nil {
+		if entry, found := xRefTable.FindTableEntryLight(indRef.ObjectNumber.Value()); found && !entry.Free {
+			entry.Object = *sd
+			return nil
+		}
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	xRefTable.RootDict.Update("Metadata", *indRef)
+
+	return nil
+}
+
+// infoValue returns the document info dict entry key as XMP property value.
+func infoValue(ctx *PDFContext, d *PDFDict, key string) string {
+
+	o, found := d.Find(key)
+	if !found || o == nil {
+		return ""
+	}
+
+	if n, ok := o.(PDFName); ok {
+		return n.Value()
+	}
+
+	s, err := textString(ctx, o)
+	if err != nil {
+		return ""
+	}
+
+	if key == "CreationDate" || key == "ModDate" {
+		t, ok := parseDate(s)
+		if !ok {
+			return ""
+		}
+		return xmpDate(t)
+	}
+
+	return s
+}
+
+// setInfoValue sets the document info dict entry key to the XMP property value s.
+func setInfoValue(d *PDFDict, key, s string) {
+
+	switch key {
+
+	case "CreationDate", "ModDate":
+		if t, ok := parseXMPDate(s); ok {
+			d.Update(key, DateStringLiteral(t))
+		}
+
+	case "Trapped":
+		d.Update(key, PDFName(s))
+
+	default:
+		d.Update(key, encodeText(s))
+
+	}
+}
+
+// SetMetadata sets the XMP properties props and keeps the corresponding document info dict entries in sync.
+// An empty value removes a property.
+func SetMetadata(xRefTable *XRefTable, props map[string]string) error {
+
+	x, err := ReadXMP(xRefTable)
+	if err != nil {
+		return err
+	}
+	if x == nil {
+		x = NewXMP()
+	}
+
+	var info *PDFDict
+	if xRefTable.Info != nil {
+		if info, err = xRefTable.DereferenceDict(*xRefTable.Info); err != nil {
+			return err
+		}
+	}
+
+	for name, v := range props {
+
+		if v == "" {
+			err = x.RemoveProperty(name)
+		} else {
+			err = x.SetProperty(name, v)
+		}
+		if err != nil {
+			return err
+		}
+
+		if info == nil {
+			continue
+		}
+
+		for _, e := range xmpInfo {
+			if e.prop != name {
+				continue
+			}
+			if v == "" {
+				info.Delete(e.key)
+			} else {
+				setInfoValue(info, e.key, v)
+			}
+		}
+	}
+
+	return WriteXMP(xRefTable, x)
+}
+
+// syncMetadata updates Producer, Creator and modification date of the document info dict and the XMP metadata
+// and makes both agree on the properties they have in common.
+// Values of the document info dict take precedence.
+func syncMetadata(ctx *PDFContext) error {
+
+	now := time.Now()
+
+	producer := PDFCPULongVersion
+	if ctx.ProducerOverride != nil {
+		producer = *ctx.ProducerOverride
+	}
+
+	// Create a document info dict for a producer or creator supplied by the caller.
+	create := ctx.ProducerOverride != nil && *ctx.ProducerOverride != "" ||
+		ctx.CreatorOverride != nil && *ctx.CreatorOverride != ""
+
+	info, err := infoDict(ctx, create)
+	if err != nil {
+		return err
+	}
+
+	if info != nil {
+		if _, found := info.Find("CreationDate"); !found {
+			info.Insert("CreationDate", DateStringLiteral(now))
+		}
+		info.Update("ModDate", DateStringLiteral(now))
+		updateInfoText(info, "Producer", producer)
+		if ctx.CreatorOverride != nil {
+			updateInfoText(info, "Creator", *ctx.CreatorOverride)
+		}
+	}
+
+	if _, found := ctx.RootDict.Find("Metadata"); !found {
+		return nil
+	}
+
+	x, err := ReadXMP(ctx.XRefTable)
+	if err != nil || x == nil {
+		// Leave corrupt metadata alone.
+		log.Info.Printf("syncMetadata: skipping XMP metadata: %v\n", err)
+		return nil
+	}
+
+	if info != nil {
+		for _, e := range xmpInfo {
+			if e.key == "Producer" || e.key == "Creator" && ctx.CreatorOverride != nil {
+				// Taken care of below, a removed entry must not be restored from XMP.
+				continue
+			}
+			if s := infoValue(ctx, info, e.key); s != "" {
+				if s != x.Property(e.prop) {
+					if err = x.SetProperty(e.prop, s); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if s := x.Property(e.prop); s != "" {
+				setInfoValue(info, e.key, s)
+			}
+		}
+	}
+
+	props := map[string]string{
+		"pdf:Producer":     producer,
+		"xmp:ModifyDate":   xmpDate(now),
+		"xmp:MetadataDate": xmpDate(now),
+	}
+
+	if ctx.CreatorOverride != nil {
+		props["xmp:CreatorTool"] = *ctx.CreatorOverride
+	}
+
+	for name, s := range props {
+		if s == "" {
+			err = x.RemoveProperty(name)
+		} else {
+			err = x.SetProperty(name, s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return WriteXMP(ctx.XRefTable, x)
+}
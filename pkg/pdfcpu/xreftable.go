@@ -17,12 +17,15 @@ limitations under the License.
 package pdfcpu
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"io/fs"
 	"path"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/log"
@@ -68,8 +71,11 @@ func NewFreeHeadXRefTableEntry() *XRefTableEntry {
 // Enc wraps around all defined encryption attributes.
 type Enc struct {
 	O, U       []byte
+	OE, UE     []byte // revision 5 and 6 only
+	Perms      []byte // revision 5 and 6 only
 	L, P, R, V int
 	Emd        bool // encrypt meta data
+	PubSec     bool // public-key security handler
 	ID         []byte
 }
 
@@ -87,6 +93,8 @@ type XRefTable struct {
 	AES4Strings         bool
 	AES4Streams         bool
 	AES4EmbeddedStreams bool
+	CryptFilters        map[string]string // Crypt filter methods (CFM) by crypt filter name, V 4 and 5 only.
+	StmF, EFF           string            // Crypt filter names for streams and embedded file streams, V 4 and 5 only.
 
 	// PDF Version
 	HeaderVersion *PDFVersion // The PDF version the source is claiming to us as per its header.
@@ -112,11 +120,46 @@ type XRefTable struct {
 
 	Tagged bool // File is using tags. This is important for ???
 
+	// Reference cycles detected while dereferencing or walking object trees.
+	Cycles []*ReferenceCycleError
+
 	// Validation
-	Valid          bool // true means successful validated against ISO 32000.
-	ValidationMode int  // see Configuration
+	Valid            bool             // true means successful validated against ISO 32000.
+	ValidationMode   int              // see Configuration
+	ValidationPolicy ValidationPolicy // see Configuration
+	Warnings         []string         // Violations of validation rules set to RuleWarn.
+
+	// Generated content
+	TagContent bool // see Configuration
 
 	Optimized bool
+
+	// Cancellation of long running operations.
+	Context context.Context // see Configuration
+
+	// Loads stream content on first access for files read using LazyLoading.
+	loadStream streamLoader
+	loadMu     sync.Mutex
+
+	// Parses objects on first access for files split on demand.
+	loadObject objectLoader
+
+	// Memory limits.
+	MaxStreamSize  int64 // see Configuration
+	MaxDecodedSize int64 // see Configuration
+	MaxImagePixels int64 // see Configuration
+	decodedSize    int64 // Total size of decoded streams.
+
+	// Concurrent page processing.
+	Workers int        // see Configuration
+	mu      sync.Mutex // Guards Warnings, Cycles and decodedSize.
+
+	// Profiling.
+	ProfileLabels bool // see Configuration
+
+	// File system.
+	FS         fs.FS      // see Configuration
+	CreateFile CreateFunc // see Configuration
 }
 
 // NewXRefTable creates a new XRefTable.
@@ -223,7 +266,7 @@ func (xRefTable *XRefTable) FindObject(objNumber int) (PDFObject, error) {
 		return nil, errors.Errorf("FindObject: obj#%d not registered in xRefTable", objNumber)
 	}
 
-	return entry.Object, nil
+	return xRefTable.object(entry, objNumber)
 }
 
 // Free returns the cross ref table entry for given number of a free object.
@@ -265,8 +308,9 @@ func (xRefTable *XRefTable) FindTableEntry(objNumber int, generationNumber int)
 	entry, found := xRefTable.Find(objNumber)
 	if found && entry == nil {
 		fmt.Printf("FindTableEntry(%d,%d) finds entry = nil!\n", objNumber, generationNumber)
+		return nil, false
 	}
-	if found && *entry.Generation == generationNumber {
+	if found && entry.Generation != nil && *entry.Generation == generationNumber {
 		return entry, found
 	}
 	return nil, false
@@ -355,7 +399,7 @@ func (xRefTable *XRefTable) IndRefForNewObject(obj PDFObject) (*PDFIndirectRef,
 // NewPDFStreamDict creates a streamDict for buf.
 func (xRefTable *XRefTable) NewPDFStreamDict(filename string) (*PDFStreamDict, error) {
 
-	buf, err := ioutil.ReadFile(filename)
+	buf, err := xRefTable.readFile(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -379,16 +423,24 @@ func (xRefTable *XRefTable) NewEmbeddedFileStreamDict(filename string) (*PDFStre
 		return nil, err
 	}
 
-	fi, err := os.Stat(filename)
+	fi, err := xRefTable.statFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
 	sd.InsertName("Type", "EmbeddedFile")
 
+	if mt := mimeType(filename, sd.Content); mt != "" {
+		sd.InsertName("Subtype", encodeName(mt))
+	}
+
+	// The checksum lets consumers detect corrupted attachments.
+	sum := md5.Sum(sd.Content)
+
 	d := NewPDFDict()
 	d.InsertInt("Size", int(fi.Size()))
 	d.Insert("ModDate", DateStringLiteral(fi.ModTime()))
+	d.Insert("CheckSum", PDFHexLiteral(hex.EncodeToString(sum[:])))
 	sd.Insert("Params", d)
 
 	return sd, nil
@@ -691,7 +743,57 @@ func (xRefTable *XRefTable) UndeleteObject(objectNumber int) error {
 	return nil
 }
 
+// ReferenceCycleError reports a chain of indirect references leading back to itself.
+type ReferenceCycleError struct {
+	Context string // Where the cycle was detected.
+	ObjNrs  []int  // The object numbers forming the cycle.
+}
+
+func (e *ReferenceCycleError) Error() string {
+	return fmt.Sprintf("%s: circular reference %v", e.Context, e.ObjNrs)
+}
+
+// canceled returns the error of xRefTable's context once it has been canceled or its deadline has passed.
+func (xRefTable *XRefTable) canceled() error {
+
+	if xRefTable.Context == nil {
+		return nil
+	}
+
+	return xRefTable.Context.Err()
+}
+
+// reportCycle records a reference cycle once and returns it as error.
+func (xRefTable *XRefTable) reportCycle(context string, objNrs []int) error {
+
+	// Rotate the smallest object number to the front for a canonical form.
+	min := 0
+	for i, nr := range objNrs {
+		if nr < objNrs[min] {
+			min = i
+		}
+	}
+	objNrs = append(append([]int{}, objNrs[min:]...), objNrs[:min]...)
+
+	xRefTable.mu.Lock()
+	defer xRefTable.mu.Unlock()
+
+	for _, c := range xRefTable.Cycles {
+		if c.Context == context && fmt.Sprint(c.ObjNrs) == fmt.Sprint(objNrs) {
+			return c
+		}
+	}
+
+	e := &ReferenceCycleError{Context: context, ObjNrs: objNrs}
+	xRefTable.Cycles = append(xRefTable.Cycles, e)
+
+	log.Info.Printf("%v\n", e)
+
+	return e
+}
+
 // indRefToObject dereferences an indirect object from the xRefTable and returns the result.
+// References to free or undefined objects are treated as references to the null object (see 7.3.10).
 func (xRefTable *XRefTable) indRefToObject(indObjRef *PDFIndirectRef) (PDFObject, error) {
 
 	if indObjRef == nil {
@@ -703,24 +805,17 @@ func (xRefTable *XRefTable) indRefToObject(indObjRef *PDFIndirectRef) (PDFObject
 	generationNumber := indObjRef.GenerationNumber.Value()
 
 	entry, found := xRefTable.FindTableEntry(objectNumber, generationNumber)
-	if !found {
-		return nil, nil
-	}
-
-	if entry.Free {
-		// TODO return err
-		return nil, nil
-	}
-
-	if entry.Object == nil {
+	if !found || entry.Free {
 		return nil, nil
 	}
 
 	// return dereferenced object
-	return entry.Object, nil
+	return xRefTable.object(entry, objectNumber)
 }
 
 // Dereference resolves an indirect object and returns the resulting PDF object.
+// Chains of indirect objects whose value is an indirect reference are followed
+// and a *ReferenceCycleError is returned for chains leading back to themselves.
 func (xRefTable *XRefTable) Dereference(obj PDFObject) (PDFObject, error) {
 
 	indRef, ok := obj.(PDFIndirectRef)
@@ -729,7 +824,30 @@ func (xRefTable *XRefTable) Dereference(obj PDFObject) (PDFObject, error) {
 		return obj, nil
 	}
 
-	return xRefTable.indRefToObject(&indRef)
+	var objNrs []int
+
+	for {
+
+		objNr := indRef.ObjectNumber.Value()
+
+		for i, nr := range objNrs {
+			if nr == objNr {
+				return nil, xRefTable.reportCycle("Dereference", objNrs[i:])
+			}
+		}
+
+		objNrs = append(objNrs, objNr)
+
+		o, err := xRefTable.indRefToObject(&indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		if indRef, ok = o.(PDFIndirectRef); !ok {
+			return o, nil
+		}
+	}
+
 }
 
 // DereferenceInteger resolves and validates an integer object, which may be an indirect reference.
@@ -945,7 +1063,7 @@ func (xRefTable *XRefTable) Catalog() (*PDFDict, error) {
 	}
 
 	o, err := xRefTable.indRefToObject(xRefTable.Root)
-	if err != nil || o == nil {
+	if err != nil {
 		return nil, err
 	}
 
@@ -1589,6 +1707,61 @@ func (xRefTable *XRefTable) processPageTree(root *PDFIndirectRef, pAttrs *Inheri
 	return nil, nil
 }
 
+func (xRefTable *XRefTable) pageDictIndRef(indRef PDFIndirectRef, p *int, page int) (*PDFIndirectRef, error) {
+
+	dict, err := xRefTable.DereferenceDict(indRef)
+	if err != nil || dict == nil {
+		return nil, err
+	}
+
+	if t := dict.Type(); t != nil && *t == "Page" {
+		*p++
+		if *p == page {
+			return &indRef, nil
+		}
+		return nil, nil
+	}
+
+	if pageCount := dict.IntEntry("Count"); pageCount != nil && *p+*pageCount < page {
+		// Skip sub pagetree.
+		*p += *pageCount
+		return nil, nil
+	}
+
+	kids := dict.PDFArrayEntry("Kids")
+	if kids == nil {
+		return nil, nil
+	}
+
+	for _, obj := range *kids {
+
+		ir, ok := obj.(PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		pageIndRef, err := xRefTable.pageDictIndRef(ir, p, page)
+		if err != nil || pageIndRef != nil {
+			return pageIndRef, err
+		}
+	}
+
+	return nil, nil
+}
+
+// PageDictIndRef returns the indirect reference of a specific page dict.
+func (xRefTable *XRefTable) PageDictIndRef(page int) (*PDFIndirectRef, error) {
+
+	root, err := xRefTable.Pages()
+	if err != nil {
+		return nil, err
+	}
+
+	pageCount := 0
+
+	return xRefTable.pageDictIndRef(*root, &pageCount, page)
+}
+
 // PageDict returns a specific page dict along with the resources, mediaBox and CropBox in effect.
 func (xRefTable *XRefTable) PageDict(page int) (*PDFDict, *InheritedPageAttrs, error) {
 
@@ -0,0 +1,562 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package qrcode implements a QR Code Model 2 encoder as specified in ISO/IEC 18004.
+//
+// Only byte mode encoding is supported which is sufficient for URLs.
+package qrcode
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Level represents the error correction level of a QR code.
+type Level int
+
+// The error correction levels.
+const (
+	Low      Level = iota // recovers 7% of data
+	Medium                // recovers 15% of data
+	Quartile              // recovers 25% of data
+	High                  // recovers 30% of data
+)
+
+// formatBits returns the 2 bit error correction level indicator.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// Error correction codewords per block indexed by level and version.
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// Number of error correction blocks indexed by level and version.
+var numErrorCorrectionBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Code represents an encoded QR code symbol.
+type Code struct {
+	Version int
+	Level   Level
+	Size    int // number of modules per side excluding the quiet zone.
+	Mask    int
+
+	modules    [][]bool // true for dark modules, indexed by row and column.
+	isFunction [][]bool // true for function patterns, which are excluded from masking.
+}
+
+// Black returns true if the module in column x and row y is dark.
+// Coordinates outside the symbol are treated as light (quiet zone).
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// numRawDataModules returns the number of data modules available after all function patterns have been excluded.
+func numRawDataModules(ver int) int {
+
+	result := (16*ver+128)*ver + 64
+
+	if ver >= 2 {
+		numAlign := ver/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if ver >= 7 {
+			result -= 36
+		}
+	}
+
+	return result
+}
+
+// numDataCodewords returns the number of 8 bit data codewords for a version and level.
+func numDataCodewords(ver int, l Level) int {
+	return numRawDataModules(ver)/8 - eccCodewordsPerBlock[l][ver]*numErrorCorrectionBlocks[l][ver]
+}
+
+// charCountBits returns the length of the character count indicator for byte mode.
+func charCountBits(ver int) int {
+	if ver <= 9 {
+		return 8
+	}
+	return 16
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) appendBits(val, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, (val>>uint(i))&1 != 0)
+	}
+}
+
+// Encode returns the QR code for data using the smallest version possible for the given error correction level.
+func Encode(data []byte, l Level) (*Code, error) {
+
+	if l < Low || l > High {
+		return nil, errors.Errorf("qrcode: invalid error correction level %d", l)
+	}
+
+	ver := minVersion
+	for ; ; ver++ {
+		if ver > maxVersion {
+			return nil, errors.Errorf("qrcode: data too long: %d bytes", len(data))
+		}
+		if 4+charCountBits(ver)+8*len(data) <= numDataCodewords(ver, l)*8 {
+			break
+		}
+	}
+
+	// Byte mode segment.
+	var bb bitBuffer
+	bb.appendBits(0x4, 4)
+	bb.appendBits(len(data), charCountBits(ver))
+	for _, b := range data {
+		bb.appendBits(int(b), 8)
+	}
+
+	// Terminator and padding.
+	capacity := numDataCodewords(ver, l) * 8
+	n := capacity - len(bb)
+	if n > 4 {
+		n = 4
+	}
+	bb.appendBits(0, n)
+	bb.appendBits(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.appendBits(pad, 8)
+	}
+
+	codewords := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			codewords[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+
+	c := newCode(ver, l)
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.addECCAndInterleave(codewords))
+	c.applyBestMask()
+
+	return c, nil
+}
+
+func newCode(ver int, l Level) *Code {
+
+	size := ver*4 + 17
+
+	c := &Code{Version: ver, Level: l, Size: size}
+
+	c.modules = make([][]bool, size)
+	c.isFunction = make([][]bool, size)
+	for i := 0; i < size; i++ {
+		c.modules[i] = make([]bool, size)
+		c.isFunction[i] = make([]bool, size)
+	}
+
+	return c
+}
+
+func (c *Code) setFunctionModule(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// alignmentPatternPositions returns the ascending center coordinates of the alignment patterns.
+func alignmentPatternPositions(ver int) []int {
+
+	if ver == 1 {
+		return nil
+	}
+
+	numAlign := ver/7 + 2
+	step := (ver*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, ver*4+17-7; i > 0; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+
+	return result
+}
+
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			dist := max(abs(dx), abs(dy))
+			xx, yy := x+dx, y+dy
+			if xx >= 0 && xx < c.Size && yy >= 0 && yy < c.Size {
+				c.setFunctionModule(xx, yy, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunctionModule(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (c *Code) drawFunctionPatterns() {
+
+	// Timing patterns
+	for i := 0; i < c.Size; i++ {
+		c.setFunctionModule(6, i, i%2 == 0)
+		c.setFunctionModule(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns including separators
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.Size-4, 3)
+	c.drawFinderPattern(3, c.Size-4)
+
+	// Alignment patterns except those overlapping with finder patterns
+	pos := alignmentPatternPositions(c.Version)
+	n := len(pos)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == 0 && j == 0 || i == 0 && j == n-1 || i == n-1 && j == 0 {
+				continue
+			}
+			c.drawAlignmentPattern(pos[i], pos[j])
+		}
+	}
+
+	// Reserve the format areas using a dummy mask.
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// formatBits returns the 15 bit BCH encoded format information.
+func formatBits(l Level, mask int) int {
+
+	data := l.formatBits()<<3 | mask
+
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits returns the 18 bit BCH encoded version information.
+func versionBits(ver int) int {
+
+	rem := ver
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+
+	return ver<<12 | rem
+}
+
+func bit(x, i int) bool {
+	return (x>>uint(i))&1 != 0
+}
+
+func (c *Code) drawFormatBits(mask int) {
+
+	bits := formatBits(c.Level, mask)
+
+	// First copy around the top left finder pattern.
+	for i := 0; i <= 5; i++ {
+		c.setFunctionModule(8, i, bit(bits, i))
+	}
+	c.setFunctionModule(8, 7, bit(bits, 6))
+	c.setFunctionModule(8, 8, bit(bits, 7))
+	c.setFunctionModule(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunctionModule(14-i, 8, bit(bits, i))
+	}
+
+	// Second copy split between the top right and bottom left finder patterns.
+	for i := 0; i < 8; i++ {
+		c.setFunctionModule(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunctionModule(8, c.Size-15+i, bit(bits, i))
+	}
+
+	// The dark module.
+	c.setFunctionModule(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersion() {
+
+	if c.Version < 7 {
+		return
+	}
+
+	bits := versionBits(c.Version)
+
+	for i := 0; i < 18; i++ {
+		dark := bit(bits, i)
+		a, b := c.Size-11+i%3, i/3
+		c.setFunctionModule(a, b, dark)
+		c.setFunctionModule(b, a, dark)
+	}
+}
+
+// addECCAndInterleave splits data into blocks, appends the Reed-Solomon error correction codewords
+// to each block and interleaves the result.
+func (c *Code) addECCAndInterleave(data []byte) []byte {
+
+	ver, l := c.Version, c.Level
+
+	numBlocks := numErrorCorrectionBlocks[l][ver]
+	blockECCLen := eccCodewordsPerBlock[l][ver]
+	rawCodewords := numRawDataModules(ver) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockECCLen)
+
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		datLen := shortBlockLen - blockECCLen
+		if i >= numShortBlocks {
+			datLen++
+		}
+		dat := data[k : k+datLen]
+		k += datLen
+		block := append([]byte{}, dat...)
+		if i < numShortBlocks {
+			// Pad short blocks so all blocks have equal length.
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, reedSolomonRemainder(dat, divisor)...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i < len(blocks[0]); i++ {
+		for j, block := range blocks {
+			// Skip the padding byte of short blocks.
+			if i != shortBlockLen-blockECCLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	return result
+}
+
+// drawCodewords places the data bits in the zigzag pattern starting at the bottom right corner.
+func (c *Code) drawCodewords(data []byte) {
+
+	i := 0
+
+	for right := c.Size - 1; right >= 1; right -= 2 {
+
+		if right == 6 {
+			// Skip the vertical timing pattern.
+			right = 5
+		}
+
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					// Moving upwards.
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y][x] && i < len(data)*8 {
+					c.modules[y][x] = bit(int(data[i>>3]), 7-i&7)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func maskBit(mask, x, y int) bool {
+
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	}
+
+	return ((x+y)%2+x*y%3)%2 == 0
+}
+
+// applyMask toggles all data modules for mask. Applying the same mask twice reverts it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.isFunction[y][x] && maskBit(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+func (c *Code) applyBestMask() {
+
+	best, minPenalty := 0, -1
+
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		p := c.penalty()
+		if minPenalty < 0 || p < minPenalty {
+			best, minPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+// finderLike returns true if a 1:1:3:1:1 pattern with 4 light modules on either side starts at position i of line.
+func finderLike(line func(i int) bool, i, size int) bool {
+
+	dark := func(j int) bool {
+		return j >= 0 && j < size && line(j)
+	}
+
+	for j, d := range []bool{true, false, true, true, true, false, true} {
+		if dark(i+j) != d {
+			return false
+		}
+	}
+
+	before, after := true, true
+	for j := 1; j <= 4; j++ {
+		if dark(i - j) {
+			before = false
+		}
+		if dark(i + 6 + j) {
+			after = false
+		}
+	}
+
+	return before || after
+}
+
+// linePenalty evaluates rule 1 (runs of equal color) and rule 3 (finder like patterns) for a single row or column.
+func linePenalty(line func(i int) bool, size int) int {
+
+	p := 0
+
+	run := 1
+	for i := 1; i <= size; i++ {
+		if i < size && line(i) == line(i-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += 3 + run - 5
+		}
+		run = 1
+	}
+
+	for i := -4; i < size; i++ {
+		if finderLike(line, i, size) {
+			p += 40
+		}
+	}
+
+	return p
+}
+
+// penalty calculates the mask penalty score of the current symbol.
+func (c *Code) penalty() int {
+
+	p := 0
+	n := c.Size
+
+	for i := 0; i < n; i++ {
+		y := i
+		p += linePenalty(func(x int) bool { return c.modules[y][x] }, n)
+		x := i
+		p += linePenalty(func(y int) bool { return c.modules[y][x] }, n)
+	}
+
+	// Rule 2: 2x2 blocks of equal color.
+	for y := 0; y < n-1; y++ {
+		for x := 0; x < n-1; x++ {
+			d := c.modules[y][x]
+			if d == c.modules[y][x+1] && d == c.modules[y+1][x] && d == c.modules[y+1][x+1] {
+				p += 3
+			}
+		}
+	}
+
+	// Rule 4: balance of dark and light modules.
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+		}
+	}
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		p += k * 10
+	}
+
+	return p
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+
+	// "HELLO WORLD" encoded as version 1-M, see ISO/IEC 18004 Annex I.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("reedSolomonRemainder: got %v want %v\n", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+
+	for _, tt := range []struct {
+		l    Level
+		mask int
+		want int
+	}{
+		{Medium, 0, 0x5412},
+		{Low, 0, 0x77C4},
+		{High, 7, 0x083B},
+	} {
+		if got := formatBits(tt.l, tt.mask); got != tt.want {
+			t.Errorf("formatBits(%d, %d): got %015b want %015b\n", tt.l, tt.mask, got, tt.want)
+		}
+	}
+
+	if got := versionBits(7); got != 0x07C94 {
+		t.Errorf("versionBits(7): got %018b want %018b\n", got, 0x07C94)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+
+	// Byte mode capacities of the largest symbol.
+	for l, want := range []int{2953, 2331, 1663, 1273} {
+		if _, err := Encode(bytes.Repeat([]byte{'a'}, want), Level(l)); err != nil {
+			t.Errorf("level %d: %v\n", l, err)
+		}
+		if _, err := Encode(bytes.Repeat([]byte{'a'}, want+1), Level(l)); err == nil {
+			t.Errorf("level %d: expected error for %d bytes\n", l, want+1)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+
+	for _, s := range []string{"", "https://example.com/verify?h=0123456789abcdef", strings.Repeat("pdfcpu", 100)} {
+
+		c, err := Encode([]byte(s), Medium)
+		if err != nil {
+			t.Fatalf("Encode %q: %v\n", s, err)
+		}
+
+		if c.Size != c.Version*4+17 {
+			t.Fatalf("Encode %q: invalid size %d for version %d\n", s, c.Size, c.Version)
+		}
+
+		// Check the finder pattern in the top left corner.
+		for i := 0; i < 7; i++ {
+			if !c.Black(i, 0) || !c.Black(0, i) || c.Black(i, 7) || c.Black(7, i) {
+				t.Fatalf("Encode %q: corrupt finder pattern\n", s)
+			}
+		}
+	}
+}
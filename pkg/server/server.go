@@ -0,0 +1,471 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package server provides HTTP handlers for the main pdfcpu operations.
+//
+// All operations expect a POST request with a multipart/form-data body
+// carrying the PDF file(s) in the form field "file" and respond with the processed result:
+//
+//	POST /validate  file [mode=strict|relaxed]                          JSON {"warnings": [..]}
+//	POST /optimize  file                                                PDF
+//	POST /merge     file file..                                         PDF
+//	POST /split     file                                                ZIP of single page PDFs
+//	POST /extract   file mode=image|font|content|page [pages]           ZIP
+//	POST /stamp     file stamp=description [watermark=true] [pages]     PDF
+//
+// Every operation optionally takes the form fields upw and opw for encrypted files.
+// Failures get reported as JSON {"error": ".."} along with a 4xx or 5xx status code.
+//
+// Use eg.
+//
+//	http.ListenAndServe(":8080", server.NewHandler(nil))
+//
+// and mount the handler using http.StripPrefix below a path prefix if needed.
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/api"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/pkg/errors"
+)
+
+// Defaults for Config.
+const (
+	DefaultMaxRequestSize = 32 << 20
+	DefaultTimeout        = time.Minute
+)
+
+// Config configures the handler returned by NewHandler.
+type Config struct {
+
+	// MaxRequestSize limits the size of request bodies in bytes.
+	// Larger requests get rejected with 413 Request Entity Too Large.
+	MaxRequestSize int64
+
+	// Timeout limits the processing time of a request.
+	// Requests taking longer get cancelled with 503 Service Unavailable.
+	Timeout time.Duration
+
+	// NewConfiguration returns the pdfcpu configuration for a request,
+	// eg. for setting memory limits or the number of workers.
+	// Defaults to pdfcpu.NewDefaultConfiguration.
+	NewConfiguration func() *pdfcpu.Configuration
+}
+
+// server handles requests for pdfcpu operations.
+type server struct {
+	config Config
+	mux    *http.ServeMux
+}
+
+// NewHandler returns a handler serving the pdfcpu operations configured by c.
+// A nil c uses the defaults.
+func NewHandler(c *Config) http.Handler {
+
+	s := &server{mux: http.NewServeMux()}
+
+	if c != nil {
+		s.config = *c
+	}
+
+	if s.config.MaxRequestSize <= 0 {
+		s.config.MaxRequestSize = DefaultMaxRequestSize
+	}
+
+	if s.config.Timeout <= 0 {
+		s.config.Timeout = DefaultTimeout
+	}
+
+	if s.config.NewConfiguration == nil {
+		s.config.NewConfiguration = pdfcpu.NewDefaultConfiguration
+	}
+
+	for path, op := range map[string]operation{
+		"/validate": validate,
+		"/optimize": optimize,
+		"/merge":    merge,
+		"/split":    split,
+		"/extract":  extract,
+		"/stamp":    stamp,
+	} {
+		s.mux.Handle(path, s.handler(op))
+	}
+
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// request is a parsed request for an operation.
+type request struct {
+	form   *multipart.Form
+	files  []io.ReadSeeker
+	config *pdfcpu.Configuration
+}
+
+func (req *request) close() {
+	for _, f := range req.files {
+		f.(io.Closer).Close()
+	}
+}
+
+// value returns the form value for key.
+func (req *request) value(key string) string {
+
+	if vv := req.form.Value[key]; len(vv) > 0 {
+		return vv[0]
+	}
+
+	return ""
+}
+
+func (req *request) pages() ([]string, error) {
+
+	pages, err := api.ParsePageSelection(req.value("pages"))
+	if err != nil {
+		return nil, badRequest(err)
+	}
+
+	return pages, nil
+}
+
+// noFS is a file system without files.
+type noFS struct{}
+
+func (noFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func noCreate(name string) (io.WriteCloser, error) {
+	return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrPermission}
+}
+
+// response is the result of an operation.
+type response struct {
+	contentType string
+	fileName    string
+	body        []byte
+}
+
+// operation processes req.
+type operation func(req *request) (*response, error)
+
+// statusError is an error carrying its HTTP status code.
+type statusError struct {
+	error
+	status int
+}
+
+func badRequest(err error) error {
+	return &statusError{err, http.StatusBadRequest}
+}
+
+// status returns the HTTP status code for err.
+func status(err error) int {
+
+	if e, ok := err.(*statusError); ok {
+		return e.status
+	}
+
+	switch {
+	case errors.Cause(err) == context.DeadlineExceeded, errors.Cause(err) == context.Canceled:
+		return http.StatusServiceUnavailable
+	case pdfcpu.IsMemoryLimitError(err):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, pdfcpu.ErrWrongPassword):
+		return http.StatusUnauthorized
+	}
+
+	// The PDF could not be processed.
+	return http.StatusUnprocessableEntity
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, status(err), map[string]string{"error": err.Error()})
+}
+
+// parse parses the multipart form of r.
+func (s *server) parse(w http.ResponseWriter, r *http.Request) (*request, error) {
+
+	if r.Method != http.MethodPost {
+		return nil, &statusError{errors.Errorf("method %s not allowed", r.Method), http.StatusMethodNotAllowed}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxRequestSize)
+
+	// Parts exceeding maxMemory get stored in temporary files.
+	if err := r.ParseMultipartForm(s.config.MaxRequestSize); err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			return nil, &statusError{err, http.StatusRequestEntityTooLarge}
+		}
+		return nil, badRequest(err)
+	}
+
+	req := &request{form: r.MultipartForm}
+
+	for _, fh := range r.MultipartForm.File["file"] {
+		f, err := fh.Open()
+		if err != nil {
+			return req, err
+		}
+		req.files = append(req.files, f)
+	}
+
+	if len(req.files) == 0 {
+		return req, badRequest(errors.New("missing file"))
+	}
+
+	// Requests must not access files of the server, eg. images referred to by stamps.
+	req.config = s.config.NewConfiguration().With(
+		pdfcpu.WithPassword(req.value("upw"), req.value("opw")),
+		pdfcpu.WithFileSystem(noFS{}, noCreate),
+	)
+
+	return req, nil
+}
+
+// handler returns a handler processing op.
+func (s *server) handler(op operation) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		req, err := s.parse(w, r)
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+		if req != nil {
+			defer req.close()
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		c, cancel := context.WithTimeout(r.Context(), s.config.Timeout)
+		defer cancel()
+
+		req.config.Context = c
+
+		resp, err := process(op, req)
+		if err == nil {
+			// Processing may have ignored cancellation in its final steps.
+			err = c.Err()
+		}
+		if err != nil {
+			log.Info.Printf("server: %s: %v\n", r.URL.Path, err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", resp.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp.body)))
+		if resp.fileName != "" {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", resp.fileName))
+		}
+		w.Write(resp.body)
+	})
+}
+
+// process runs op recovering from panics caused by malformed input.
+func process(op operation, req *request) (resp *response, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("unexpected panic attack: %v", r)
+		}
+	}()
+
+	return op(req)
+}
+
+func pdfResponse(buf *bytes.Buffer) *response {
+	return &response{contentType: "application/pdf", fileName: "out.pdf", body: buf.Bytes()}
+}
+
+// zipWriter collects the files written by operations into a ZIP archive.
+type zipWriter struct {
+	buf bytes.Buffer
+	zw  *zip.Writer
+}
+
+func newZipWriter() *zipWriter {
+	z := &zipWriter{}
+	z.zw = zip.NewWriter(&z.buf)
+	return z
+}
+
+func (z *zipWriter) create(name string) (io.Writer, error) {
+	return z.zw.Create(name)
+}
+
+func (z *zipWriter) response() (*response, error) {
+
+	if err := z.zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &response{contentType: "application/zip", fileName: "out.zip", body: z.buf.Bytes()}, nil
+}
+
+func validate(req *request) (*response, error) {
+
+	switch req.value("mode") {
+	case "", "relaxed":
+	case "strict":
+		req.config.ValidationMode = pdfcpu.ValidationStrict
+	default:
+		return nil, badRequest(errors.Errorf("unsupported mode %q", req.value("mode")))
+	}
+
+	warnings, err := api.ValidateContext(req.files[0], req.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	body, err := json.Marshal(map[string][]string{"warnings": warnings})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response{contentType: "application/json", body: body}, nil
+}
+
+func optimize(req *request) (*response, error) {
+
+	var buf bytes.Buffer
+
+	if err := api.OptimizeContext(req.files[0], &buf, req.config); err != nil {
+		return nil, err
+	}
+
+	return pdfResponse(&buf), nil
+}
+
+func merge(req *request) (*response, error) {
+
+	if len(req.files) < 2 {
+		return nil, badRequest(errors.New("merge needs at least 2 files"))
+	}
+
+	var buf bytes.Buffer
+
+	if err := api.MergeContext(req.files, &buf, req.config); err != nil {
+		return nil, err
+	}
+
+	return pdfResponse(&buf), nil
+}
+
+func split(req *request) (*response, error) {
+
+	z := newZipWriter()
+
+	err := api.SplitContext(req.files[0], req.config, func(pageNr int) (io.Writer, error) {
+		return z.create(fmt.Sprintf("page_%d.pdf", pageNr))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return z.response()
+}
+
+func extract(req *request) (*response, error) {
+
+	pages, err := req.pages()
+	if err != nil {
+		return nil, err
+	}
+
+	z := newZipWriter()
+
+	extractWriter := func(pageNr, objNr int, name string) (io.Writer, error) {
+		return z.create(name)
+	}
+
+	switch req.value("mode") {
+	case "image":
+		err = api.ExtractImagesContext(req.files[0], pages, req.config, extractWriter)
+	case "font":
+		err = api.ExtractFontsContext(req.files[0], pages, req.config, extractWriter)
+	case "content":
+		err = api.ExtractContentContext(req.files[0], pages, req.config, extractWriter)
+	case "page":
+		err = api.ExtractPagesContext(req.files[0], pages, req.config, func(pageNr int) (io.Writer, error) {
+			return z.create(fmt.Sprintf("page_%d.pdf", pageNr))
+		})
+	default:
+		err = badRequest(errors.Errorf("unsupported mode %q", req.value("mode")))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return z.response()
+}
+
+func stamp(req *request) (*response, error) {
+
+	pages, err := req.pages()
+	if err != nil {
+		return nil, err
+	}
+
+	desc := req.value("stamp")
+	if desc == "" {
+		return nil, badRequest(errors.New("missing stamp"))
+	}
+
+	wm, err := pdfcpu.ParseWatermarkDetails(desc, req.value("watermark") != "true")
+	if err != nil {
+		return nil, badRequest(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err = api.AddWatermarksContext(req.files[0], &buf, pages, wm, req.config); err != nil {
+		return nil, err
+	}
+
+	return pdfResponse(&buf), nil
+}
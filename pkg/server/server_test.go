@@ -0,0 +1,158 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/api"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+)
+
+const inDir = "../api/testdata"
+
+// post sends a multipart request carrying files and fields to path.
+func post(t *testing.T, h http.Handler, path string, files []string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, fileName := range files {
+		b, err := ioutil.ReadFile(filepath.Join(inDir, fileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw, _ := mw.CreateFormFile("file", fileName)
+		fw.Write(b)
+	}
+
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, path, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func checkPDF(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("got %d %s: %s\n", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	if _, err := api.ValidateContext(bytes.NewReader(w.Body.Bytes()), pdfcpu.NewDefaultConfiguration()); err != nil {
+		t.Fatalf("invalid PDF: %v\n", err)
+	}
+}
+
+func checkZIP(t *testing.T, w *httptest.ResponseRecorder) []*zip.File {
+	t.Helper()
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("got %d %s: %s\n", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return zr.File
+}
+
+func TestOperations(t *testing.T) {
+
+	h := NewHandler(nil)
+
+	w := post(t, h, "/validate", []string{"go.pdf"}, map[string]string{"mode": "strict"})
+	var v struct{ Warnings []string }
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &v) != nil || v.Warnings == nil {
+		t.Errorf("validate: got %d: %s\n", w.Code, w.Body)
+	}
+
+	checkPDF(t, post(t, h, "/optimize", []string{"go.pdf"}, nil))
+	checkPDF(t, post(t, h, "/merge", []string{"go.pdf", "testImage.pdf"}, nil))
+	checkPDF(t, post(t, h, "/stamp", []string{"go.pdf"}, map[string]string{"stamp": "Draft, s:0.5", "pages": "1-2"}))
+
+	if files := checkZIP(t, post(t, h, "/split", []string{"testImage.pdf"}, nil)); len(files) != 2 {
+		t.Errorf("split: got %d files, want 2\n", len(files))
+	}
+
+	for _, mode := range []string{"image", "font", "content", "page"} {
+		files := checkZIP(t, post(t, h, "/extract", []string{"testImage.pdf"}, map[string]string{"mode": mode, "pages": "1"}))
+		if len(files) == 0 && mode != "font" {
+			t.Errorf("extract %s: empty ZIP\n", mode)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+
+	h := NewHandler(&Config{MaxRequestSize: 1 << 20})
+
+	for _, tt := range []struct {
+		path   string
+		files  []string
+		fields map[string]string
+		status int
+	}{
+		{"/optimize", nil, nil, http.StatusBadRequest},
+		{"/extract", []string{"go.pdf"}, map[string]string{"mode": "bogus"}, http.StatusBadRequest},
+		{"/merge", []string{"go.pdf"}, nil, http.StatusBadRequest},
+		{"/stamp", []string{"go.pdf"}, map[string]string{"stamp": "/etc/passwd.png"}, http.StatusUnprocessableEntity},
+		{"/optimize", []string{"gobook.0.pdf"}, nil, http.StatusRequestEntityTooLarge},
+		{"/nope", []string{"go.pdf"}, nil, http.StatusNotFound},
+	} {
+		w := post(t, h, tt.path, tt.files, tt.fields)
+		if w.Code != tt.status {
+			t.Errorf("%s %v: got %d, want %d: %s\n", tt.path, tt.fields, w.Code, tt.status, w.Body)
+		}
+		if tt.status != http.StatusNotFound && !strings.Contains(w.Body.String(), `"error"`) {
+			t.Errorf("%s: missing error: %s\n", tt.path, w.Body)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/optimize", nil)
+	w := httptest.NewRecorder()
+	if h.ServeHTTP(w, r); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got %d\n", w.Code)
+	}
+
+	h = NewHandler(&Config{Timeout: time.Nanosecond})
+	if w := post(t, h, "/optimize", []string{"gobook.0.pdf"}, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("timeout: got %d: %s\n", w.Code, w.Body)
+	}
+}
@@ -12,24 +12,30 @@ type buffer struct {
 	buf []byte
 }
 
+// chunkSize limits the memory allocated ahead of reading data
+// since sizes and offsets are taken from possibly corrupt input.
+const chunkSize = 1 << 20
+
 // fill reads data from b.r until the buffer contains at least end bytes.
 func (b *buffer) fill(end int) error {
-	m := len(b.buf)
-	if end > m {
-		if end > cap(b.buf) {
+	for m := len(b.buf); end > m; m = len(b.buf) {
+		next := end
+		if next-m > chunkSize {
+			next = m + chunkSize
+		}
+		if next > cap(b.buf) {
 			newcap := 1024
-			for newcap < end {
+			for newcap < next {
 				newcap *= 2
 			}
-			newbuf := make([]byte, end, newcap)
+			newbuf := make([]byte, next, newcap)
 			copy(newbuf, b.buf)
 			b.buf = newbuf
 		} else {
-			b.buf = b.buf[:end]
+			b.buf = b.buf[:next]
 		}
-		if n, err := io.ReadFull(b.r, b.buf[m:end]); err != nil {
-			end = m + n
-			b.buf = b.buf[:end]
+		if n, err := io.ReadFull(b.r, b.buf[m:next]); err != nil {
+			b.buf = b.buf[:m+n]
 			return err
 		}
 	}
@@ -57,6 +63,29 @@ func (b *buffer) Slice(off, n int) ([]byte, error) {
 	return b.buf[off:end], nil
 }
 
+// readAt reads n bytes starting at offset off from r.
+// The result grows in chunks so that a corrupt n does not cause a huge allocation.
+func readAt(r io.ReaderAt, off, n int64) ([]byte, error) {
+	if n <= chunkSize {
+		p := make([]byte, n)
+		_, err := r.ReadAt(p, off)
+		return p, err
+	}
+	var p []byte
+	for int64(len(p)) < n {
+		c := n - int64(len(p))
+		if c > chunkSize {
+			c = chunkSize
+		}
+		q := make([]byte, c)
+		if _, err := r.ReadAt(q, off+int64(len(p))); err != nil {
+			return nil, err
+		}
+		p = append(p, q...)
+	}
+	return p, nil
+}
+
 // newReaderAt converts an io.Reader into an io.ReaderAt.
 func newReaderAt(r io.Reader) io.ReaderAt {
 	if ra, ok := r.(io.ReaderAt); ok {
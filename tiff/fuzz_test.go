@@ -0,0 +1,46 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// Run eg. go test -fuzz FuzzDecode ./tiff for fuzzing.
+func FuzzDecode(f *testing.F) {
+
+	for _, name := range []string{
+		"bw-deflate.tiff",
+		"bw-lzw-8bpp.tiff",
+		"bw-packbits.tiff",
+		"bw-uncompressed.tiff",
+		"go-aqua-cmyk.tiff",
+		"no_compress.tiff",
+		"no_rps.tiff",
+	} {
+		b, err := ioutil.ReadFile(testdataDir + name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+
+		cfg, err := DecodeConfig(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+
+		// Images of this size are legitimate, just too large for fuzzing.
+		if cfg.Width*cfg.Height > 1e6 {
+			return
+		}
+
+		Decode(bytes.NewReader(b))
+	})
+}
@@ -81,8 +81,7 @@ func (d *decoder) ifdUint(p []byte) (u []uint, err error) {
 	}
 	if datalen := lengths[datatype] * count; datalen > 4 {
 		// The IFD contains a pointer to the real value.
-		raw = make([]byte, datalen)
-		_, err = d.r.ReadAt(raw, int64(d.byteOrder.Uint32(p[8:12])))
+		raw, err = readAt(d.r, int64(d.byteOrder.Uint32(p[8:12])), int64(datalen))
 	} else {
 		raw = p[8 : 8+datalen]
 	}
@@ -666,8 +665,7 @@ func Decode(r io.Reader) (img image.Image, err error) {
 				if b, ok := d.r.(*buffer); ok {
 					d.buf, err = b.Slice(int(offset), int(n))
 				} else {
-					d.buf = make([]byte, n)
-					_, err = d.r.ReadAt(d.buf, offset)
+					d.buf, err = readAt(d.r, offset, n)
 				}
 			case cLZW:
 				// Horst Rutter